@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/logging"
+	"github.com/weaveworks/common/server"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/tools/backfill"
+)
+
+type Config struct {
+	LogLevel       logging.Level
+	BackfillConfig backfill.Config
+}
+
+func main() {
+	// Parse CLI flags.
+	cfg := Config{}
+	cfg.LogLevel.RegisterFlags(flag.CommandLine)
+	cfg.BackfillConfig.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	util.InitLogger(&server.Config{
+		LogLevel: cfg.LogLevel,
+	})
+
+	if err := backfill.Backfill(context.Background(), cfg.BackfillConfig, util.Logger, prometheus.NewRegistry()); err != nil {
+		level.Error(util.Logger).Log("msg", "Backfill failed", "err", err.Error())
+		os.Exit(1)
+	}
+}
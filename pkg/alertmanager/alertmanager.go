@@ -13,8 +13,10 @@ import (
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
 	"github.com/prometheus/alertmanager/api"
 	"github.com/prometheus/alertmanager/cluster"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	"github.com/prometheus/alertmanager/config"
 	"github.com/prometheus/alertmanager/dispatch"
 	"github.com/prometheus/alertmanager/inhibit"
@@ -37,6 +39,9 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/common/route"
+	"golang.org/x/time/rate"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
 const notificationLogMaintenancePeriod = 15 * time.Minute
@@ -49,6 +54,10 @@ type Config struct {
 	Logger      log.Logger
 	Peer        *cluster.Peer
 	PeerTimeout time.Duration
+	// Used to replicate the state to the other replicas of the tenant when
+	// sharding is enabled, instead of gossiping it to the Peer.
+	Replicator  Replicator
+	Limits      Limits
 	Retention   time.Duration
 	ExternalURL *url.URL
 }
@@ -70,6 +79,16 @@ type Alertmanager struct {
 	mux             *http.ServeMux
 	registry        *prometheus.Registry
 
+	// The state replicated to the other replicas of the tenant, when sharding is enabled.
+	state *state
+
+	// The receivers firewall and the notification rate limiters of each integration,
+	// enforcing the tenant's limits. The rate limiters are kept across config reloads.
+	firewall                     *receiversFirewall
+	rateLimiters                 map[string]*rate.Limiter
+	rateLimitedNotifications     *prometheus.CounterVec
+	firewallBlockedNotifications *prometheus.CounterVec
+
 	// The Dispatcher is the only component we need to recreate when we call ApplyConfig.
 	// Given its metrics don't have any variable labels we need to re-use the same metrics.
 	dispatcherMetrics *dispatch.DispatcherMetrics
@@ -109,10 +128,24 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 			Name: "alertmanager_config_hash",
 			Help: "Hash of the currently loaded alertmanager configuration.",
 		}),
+		firewall:     newReceiversFirewall(cfg.UserID, cfg.Limits),
+		rateLimiters: map[string]*rate.Limiter{},
+		rateLimitedNotifications: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "alertmanager_notification_rate_limited_total",
+			Help: "Number of rate-limited notifications per integration.",
+		}, []string{"integration"}),
+		firewallBlockedNotifications: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "alertmanager_notification_firewall_blocked_total",
+			Help: "Number of notifications blocked by the receivers firewall per integration.",
+		}, []string{"integration"}),
 	}
 
 	am.registry = reg
 
+	if cfg.Peer == nil && cfg.Replicator != nil {
+		am.state = newReplicatedStates(cfg.UserID, cfg.Replicator, am.logger, am.registry)
+	}
+
 	am.wg.Add(1)
 	nflogID := fmt.Sprintf("nflog:%s", cfg.UserID)
 	var err error
@@ -129,6 +162,9 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 	if cfg.Peer != nil {
 		c := cfg.Peer.AddState("nfl:"+cfg.UserID, am.nflog, am.registry)
 		am.nflog.SetBroadcast(c.Broadcast)
+	} else if am.state != nil {
+		c := am.state.AddState("nfl:"+cfg.UserID, am.nflog)
+		am.nflog.SetBroadcast(c.Broadcast)
 	}
 
 	am.marker = types.NewMarker(am.registry)
@@ -146,6 +182,15 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 	if cfg.Peer != nil {
 		c := cfg.Peer.AddState("sil:"+cfg.UserID, am.silences, am.registry)
 		am.silences.SetBroadcast(c.Broadcast)
+	} else if am.state != nil {
+		c := am.state.AddState("sil:"+cfg.UserID, am.silences)
+		am.silences.SetBroadcast(c.Broadcast)
+	}
+
+	if am.state != nil {
+		if err := services.StartAndAwaitRunning(context.Background(), am.state); err != nil {
+			return nil, errors.Wrap(err, "failed to start the state replication service")
+		}
 	}
 
 	am.pipelineBuilder = notify.NewPipelineBuilder(am.registry)
@@ -187,12 +232,24 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 
 // clusterWait returns a function that inspects the current peer state and returns
 // a duration of one base timeout for each peer with a higher ID than ourselves.
-func clusterWait(p *cluster.Peer, timeout time.Duration) func() time.Duration {
+func clusterWait(position func() int, timeout time.Duration) func() time.Duration {
 	return func() time.Duration {
-		return time.Duration(p.Position()) * timeout
+		return time.Duration(position()) * timeout
 	}
 }
 
+// position returns the position of this Alertmanager among the peers (or
+// replicas) of the tenant, or 0 if the state is neither gossiped nor replicated.
+func (am *Alertmanager) position() int {
+	if am.state != nil {
+		return am.state.Position()
+	}
+	if am.cfg.Peer != nil {
+		return am.cfg.Peer.Position()
+	}
+	return 0
+}
+
 // ApplyConfig applies a new configuration to an Alertmanager.
 func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg string) error {
 	templateFiles := make([]string, len(conf.Templates))
@@ -222,7 +279,7 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 
 	am.inhibitor = inhibit.NewInhibitor(am.alerts, conf.InhibitRules, am.marker, log.With(am.logger, "component", "inhibitor"))
 
-	waitFunc := clusterWait(am.cfg.Peer, am.cfg.PeerTimeout)
+	waitFunc := clusterWait(am.position, am.cfg.PeerTimeout)
 	timeoutFunc := func(d time.Duration) time.Duration {
 		if d < notify.MinTimeout {
 			d = notify.MinTimeout
@@ -230,7 +287,7 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 		return d + waitFunc()
 	}
 
-	integrationsMap, err := buildIntegrationsMap(conf.Receivers, tmpl, am.logger)
+	integrationsMap, err := buildIntegrationsMap(conf.Receivers, tmpl, am.wrapNotifier, am.logger)
 	if err != nil {
 		return nil
 	}
@@ -243,6 +300,15 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 		am.nflog,
 		am.cfg.Peer,
 	)
+
+	// When the state is replicated, hold the notifications until it has been
+	// settled from the other replicas, to not send duplicated notifications.
+	if am.state != nil {
+		for name, stage := range pipeline {
+			pipeline[name] = notify.MultiStage{&stateReadyStage{state: am.state}, stage}
+		}
+	}
+
 	am.dispatcher = dispatch.NewDispatcher(
 		am.alerts,
 		dispatch.NewRoute(conf.Route, nil),
@@ -315,17 +381,68 @@ func (am *Alertmanager) Stop() {
 		am.dispatcher.Stop()
 	}
 
+	if am.state != nil {
+		if err := services.StopAndAwaitTerminated(context.Background(), am.state); err != nil {
+			level.Warn(am.logger).Log("msg", "failed to stop the state replication service", "err", err)
+		}
+	}
+
 	am.alerts.Close()
 	close(am.stop)
 	am.wg.Wait()
 }
 
+// mergePartialExternalState merges a partial state received from another replica.
+func (am *Alertmanager) mergePartialExternalState(part *clusterpb.Part) error {
+	if am.state == nil {
+		return errors.New("state replication is not enabled")
+	}
+	return am.state.MergePartialState(part)
+}
+
+// getFullState returns the full state to be sent to another replica.
+func (am *Alertmanager) getFullState() (*clusterpb.FullState, error) {
+	if am.state == nil {
+		return nil, errors.New("state replication is not enabled")
+	}
+	return am.state.GetFullState()
+}
+
+// wrapNotifier wraps the notifier of an integration, enforcing the tenant's
+// receivers firewall and notification rate limit.
+func (am *Alertmanager) wrapNotifier(integrationName, host string, n notify.Notifier) notify.Notifier {
+	limiter, ok := am.rateLimiters[integrationName]
+	if !ok {
+		limiter = newNotificationRateLimiter(am.cfg.UserID, integrationName, am.cfg.Limits)
+		am.rateLimiters[integrationName] = limiter
+	}
+
+	n = &rateLimitedNotifier{
+		upstream:    n,
+		userID:      am.cfg.UserID,
+		integration: integrationName,
+		limits:      am.cfg.Limits,
+		limiter:     limiter,
+		rateLimited: am.rateLimitedNotifications.WithLabelValues(integrationName),
+	}
+
+	return &firewallNotifier{
+		upstream: n,
+		host:     host,
+		firewall: am.firewall,
+		blocked:  am.firewallBlockedNotifications.WithLabelValues(integrationName),
+	}
+}
+
+// notifierWrapper wraps the notifier of an integration sending notifications to the given host.
+type notifierWrapper func(integrationName, host string, n notify.Notifier) notify.Notifier
+
 // buildIntegrationsMap builds a map of name to the list of integration notifiers off of a
 // list of receiver config.
-func buildIntegrationsMap(nc []*config.Receiver, tmpl *template.Template, logger log.Logger) (map[string][]notify.Integration, error) {
+func buildIntegrationsMap(nc []*config.Receiver, tmpl *template.Template, wrapper notifierWrapper, logger log.Logger) (map[string][]notify.Integration, error) {
 	integrationsMap := make(map[string][]notify.Integration, len(nc))
 	for _, rcv := range nc {
-		integrations, err := buildReceiverIntegrations(rcv, tmpl, logger)
+		integrations, err := buildReceiverIntegrations(rcv, tmpl, wrapper, logger)
 		if err != nil {
 			return nil, err
 		}
@@ -337,43 +454,45 @@ func buildIntegrationsMap(nc []*config.Receiver, tmpl *template.Template, logger
 // buildReceiverIntegrations builds a list of integration notifiers off of a
 // receiver config.
 // Taken from https://github.com/prometheus/alertmanager/blob/94d875f1227b29abece661db1a68c001122d1da5/cmd/alertmanager/main.go#L112-L159.
-func buildReceiverIntegrations(nc *config.Receiver, tmpl *template.Template, logger log.Logger) ([]notify.Integration, error) {
+func buildReceiverIntegrations(nc *config.Receiver, tmpl *template.Template, wrapper notifierWrapper, logger log.Logger) ([]notify.Integration, error) {
 	var (
 		errs         types.MultiError
 		integrations []notify.Integration
-		add          = func(name string, i int, rs notify.ResolvedSender, f func(l log.Logger) (notify.Notifier, error)) {
+		add          = func(name string, i int, rs notify.ResolvedSender, host string, f func(l log.Logger) (notify.Notifier, error)) {
 			n, err := f(log.With(logger, "integration", name))
 			if err != nil {
 				errs.Add(err)
 				return
 			}
+			n = wrapper(name, host, n)
 			integrations = append(integrations, notify.NewIntegration(n, rs, name, i))
 		}
 	)
 
 	for i, c := range nc.WebhookConfigs {
-		add("webhook", i, c, func(l log.Logger) (notify.Notifier, error) { return webhook.New(c, tmpl, l) })
+		add("webhook", i, c, configURLHost(c.URL), func(l log.Logger) (notify.Notifier, error) { return webhook.New(c, tmpl, l) })
 	}
 	for i, c := range nc.EmailConfigs {
-		add("email", i, c, func(l log.Logger) (notify.Notifier, error) { return email.New(c, tmpl, l), nil })
+		add("email", i, c, c.Smarthost.Host, func(l log.Logger) (notify.Notifier, error) { return email.New(c, tmpl, l), nil })
 	}
 	for i, c := range nc.PagerdutyConfigs {
-		add("pagerduty", i, c, func(l log.Logger) (notify.Notifier, error) { return pagerduty.New(c, tmpl, l) })
+		add("pagerduty", i, c, configURLHost(c.URL), func(l log.Logger) (notify.Notifier, error) { return pagerduty.New(c, tmpl, l) })
 	}
 	for i, c := range nc.OpsGenieConfigs {
-		add("opsgenie", i, c, func(l log.Logger) (notify.Notifier, error) { return opsgenie.New(c, tmpl, l) })
+		add("opsgenie", i, c, configURLHost(c.APIURL), func(l log.Logger) (notify.Notifier, error) { return opsgenie.New(c, tmpl, l) })
 	}
 	for i, c := range nc.WechatConfigs {
-		add("wechat", i, c, func(l log.Logger) (notify.Notifier, error) { return wechat.New(c, tmpl, l) })
+		add("wechat", i, c, configURLHost(c.APIURL), func(l log.Logger) (notify.Notifier, error) { return wechat.New(c, tmpl, l) })
 	}
 	for i, c := range nc.SlackConfigs {
-		add("slack", i, c, func(l log.Logger) (notify.Notifier, error) { return slack.New(c, tmpl, l) })
+		add("slack", i, c, configURLHost((*config.URL)(c.APIURL)), func(l log.Logger) (notify.Notifier, error) { return slack.New(c, tmpl, l) })
 	}
 	for i, c := range nc.VictorOpsConfigs {
-		add("victorops", i, c, func(l log.Logger) (notify.Notifier, error) { return victorops.New(c, tmpl, l) })
+		add("victorops", i, c, configURLHost(c.APIURL), func(l log.Logger) (notify.Notifier, error) { return victorops.New(c, tmpl, l) })
 	}
 	for i, c := range nc.PushoverConfigs {
-		add("pushover", i, c, func(l log.Logger) (notify.Notifier, error) { return pushover.New(c, tmpl, l) })
+		// Pushover notifications are always sent to the public Pushover API.
+		add("pushover", i, c, "", func(l log.Logger) (notify.Notifier, error) { return pushover.New(c, tmpl, l) })
 	}
 	if errs.Len() > 0 {
 		return nil, &errs
@@ -381,6 +500,15 @@ func buildReceiverIntegrations(nc *config.Receiver, tmpl *template.Template, log
 	return integrations, nil
 }
 
+// configURLHost returns the host of an Alertmanager config URL, or an empty
+// string if the URL is not set.
+func configURLHost(u *config.URL) string {
+	if u == nil {
+		return ""
+	}
+	return urlHost(u.URL)
+}
+
 func md5HashAsMetricValue(data []byte) float64 {
 	sum := md5.Sum(data)
 	// We only want 48 bits as a float64 only has a 53 bit mantissa.
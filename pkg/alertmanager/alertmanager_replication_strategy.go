@@ -0,0 +1,39 @@
+package alertmanager
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+)
+
+type alertmanagerReplicationStrategy struct {
+}
+
+func (s alertmanagerReplicationStrategy) Filter(instances []ring.IngesterDesc, op ring.Operation, _ int, heartbeatTimeout time.Duration, _ bool) (healthy []ring.IngesterDesc, maxFailures int, err error) {
+	// Filter out unhealthy instances.
+	for i := 0; i < len(instances); {
+		if instances[i].IsHealthy(op, heartbeatTimeout) {
+			i++
+		} else {
+			instances = append(instances[:i], instances[i+1:]...)
+		}
+	}
+
+	// The alerts are sent to all the replicas of a tenant's Alertmanager, which
+	// is enough to be received by a single healthy one.
+	if len(instances) == 0 {
+		return nil, 0, errors.New("no healthy alertmanager instance found for the replication set")
+	}
+
+	return instances, len(instances) - 1, nil
+}
+
+func (s alertmanagerReplicationStrategy) ShouldExtendReplicaSet(instance ring.IngesterDesc, op ring.Operation) bool {
+	// Only ACTIVE alertmanagers get any tenant. If instance is not ACTIVE, we need to find another alertmanager.
+	if op == RingOp && instance.GetState() != ring.ACTIVE {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package alertmanager
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+const (
+	// RingKey is the key under which we store the alertmanager ring in the KVStore.
+	RingKey = ring.AlertmanagerRingKey
+
+	// RingNameForServer is the name of the ring used by the alertmanager server.
+	RingNameForServer = "alertmanager"
+
+	// RingNumTokens is a safe default instead of exposing to config option to the user
+	// in order to simplify the config.
+	RingNumTokens = 128
+
+	// RingOp is the operation used for distributing tenants between alertmanagers.
+	RingOp = ring.Alertmanager
+
+	// If an alertmanager is unable to heartbeat the ring, its better to quickly remove it
+	// from the ring, because its tenants are still served by the other replicas.
+	ringAutoForgetUnhealthyPeriods = 5
+)
+
+// RingConfig masks the ring lifecycler config which contains
+// many options not really required by the alertmanagers ring. This config
+// is used to strip down the config to the minimum, and avoid confusion
+// to the user.
+type RingConfig struct {
+	KVStore           kv.Config     `yaml:"kvstore" doc:"description=The key-value store used to share the hash ring across multiple instances."`
+	HeartbeatPeriod   time.Duration `yaml:"heartbeat_period"`
+	HeartbeatTimeout  time.Duration `yaml:"heartbeat_timeout"`
+	ReplicationFactor int           `yaml:"replication_factor"`
+
+	AutoForgetUnhealthyPeriods int `yaml:"auto_forget_unhealthy_periods"`
+
+	// Instance details
+	InstanceID             string   `yaml:"instance_id" doc:"hidden"`
+	InstanceInterfaceNames []string `yaml:"instance_interface_names"`
+	InstancePort           int      `yaml:"instance_port" doc:"hidden"`
+	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
+
+	// Injected internally
+	ListenPort int `yaml:"-"`
+
+	RingCheckPeriod time.Duration `yaml:"-"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "failed to get hostname", "err", err)
+		os.Exit(1)
+	}
+
+	cfg.RingCheckPeriod = 5 * time.Second
+
+	// Ring flags
+	cfg.KVStore.RegisterFlagsWithPrefix("alertmanager.sharding-ring.", "alertmanagers/", f)
+	f.DurationVar(&cfg.HeartbeatPeriod, "alertmanager.sharding-ring.heartbeat-period", 15*time.Second, "Period at which to heartbeat to the ring.")
+	f.DurationVar(&cfg.HeartbeatTimeout, "alertmanager.sharding-ring.heartbeat-timeout", time.Minute, "The heartbeat timeout after which alertmanagers are considered unhealthy within the ring.")
+	f.IntVar(&cfg.ReplicationFactor, "alertmanager.sharding-ring.replication-factor", 3, "The replication factor to use when sharding the alertmanager: the number of alertmanagers running the Alertmanager of each tenant.")
+	f.IntVar(&cfg.AutoForgetUnhealthyPeriods, "alertmanager.sharding-ring.auto-forget-unhealthy-periods", ringAutoForgetUnhealthyPeriods, "Number of consecutive heartbeat timeouts after which an unhealthy alertmanager is automatically removed from the ring by the healthy alertmanagers. 0 to disable.")
+
+	// Instance flags
+	cfg.InstanceInterfaceNames = []string{"eth0", "en0"}
+	f.Var((*flagext.StringSlice)(&cfg.InstanceInterfaceNames), "alertmanager.sharding-ring.instance-interface-names", "Name of network interface to read address from.")
+	f.StringVar(&cfg.InstanceAddr, "alertmanager.sharding-ring.instance-addr", "", "IP address to advertise in the ring.")
+	f.IntVar(&cfg.InstancePort, "alertmanager.sharding-ring.instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
+	f.StringVar(&cfg.InstanceID, "alertmanager.sharding-ring.instance-id", hostname, "Instance ID to register in the ring.")
+}
+
+// ToLifecyclerConfig returns a LifecyclerConfig based on the alertmanager
+// ring config.
+func (cfg *RingConfig) ToLifecyclerConfig() (ring.BasicLifecyclerConfig, error) {
+	instanceAddr, err := ring.GetInstanceAddr(cfg.InstanceAddr, cfg.InstanceInterfaceNames)
+	if err != nil {
+		return ring.BasicLifecyclerConfig{}, err
+	}
+
+	instancePort := ring.GetInstancePort(cfg.InstancePort, cfg.ListenPort)
+
+	return ring.BasicLifecyclerConfig{
+		ID:                  cfg.InstanceID,
+		Addr:                fmt.Sprintf("%s:%d", instanceAddr, instancePort),
+		HeartbeatPeriod:     cfg.HeartbeatPeriod,
+		TokensObservePeriod: 0,
+		NumTokens:           RingNumTokens,
+	}, nil
+}
+
+func (cfg *RingConfig) ToRingConfig() ring.Config {
+	rc := ring.Config{}
+	flagext.DefaultValues(&rc)
+
+	rc.KVStore = cfg.KVStore
+	rc.HeartbeatTimeout = cfg.HeartbeatTimeout
+	rc.ReplicationFactor = cfg.ReplicationFactor
+
+	return rc
+}
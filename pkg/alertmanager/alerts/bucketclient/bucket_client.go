@@ -0,0 +1,115 @@
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alerts"
+)
+
+// Bucket Alert Storage Schema
+// =======================
+// Object Name: "alerts/<user_id>"
+// Storage Format: Encoded AlertConfigDesc
+//
+// The schema is the same used by the object client alert store, so that the alertmanager
+// configs can be moved between the two stores by copying the objects.
+
+const (
+	alertPrefix = "alerts/"
+)
+
+// BucketAlertStore is used to support the AlertStore interface against an object
+// storage bucket, configured like the blocks storage bucket.
+type BucketAlertStore struct {
+	bucket objstore.Bucket
+	logger log.Logger
+}
+
+// NewBucketAlertStore returns a new BucketAlertStore.
+func NewBucketAlertStore(bkt objstore.Bucket, logger log.Logger) *BucketAlertStore {
+	return &BucketAlertStore{
+		bucket: bkt,
+		logger: logger,
+	}
+}
+
+// ListAlertConfigs returns all of the active alert configs in this store.
+func (s *BucketAlertStore) ListAlertConfigs(ctx context.Context) (map[string]alerts.AlertConfigDesc, error) {
+	var keys []string
+	err := s.bucket.Iter(ctx, alertPrefix, func(key string) error {
+		if !strings.HasSuffix(key, "/") {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list alertmanager configs in bucket")
+	}
+
+	cfgs := map[string]alerts.AlertConfigDesc{}
+	for _, key := range keys {
+		cfg, err := s.getAlertConfig(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		cfgs[cfg.User] = cfg
+	}
+
+	return cfgs, nil
+}
+
+// GetAlertConfig returns a specified user's alertmanager configuration.
+func (s *BucketAlertStore) GetAlertConfig(ctx context.Context, user string) (alerts.AlertConfigDesc, error) {
+	return s.getAlertConfig(ctx, alertPrefix+user)
+}
+
+// SetAlertConfig sets a specified user's alertmanager configuration.
+func (s *BucketAlertStore) SetAlertConfig(ctx context.Context, cfg alerts.AlertConfigDesc) error {
+	cfgBytes, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return s.bucket.Upload(ctx, alertPrefix+cfg.User, bytes.NewReader(cfgBytes))
+}
+
+// DeleteAlertConfig deletes a specified user's alertmanager configuration.
+func (s *BucketAlertStore) DeleteAlertConfig(ctx context.Context, user string) error {
+	err := s.bucket.Delete(ctx, alertPrefix+user)
+	if s.bucket.IsObjNotFoundErr(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *BucketAlertStore) getAlertConfig(ctx context.Context, key string) (alerts.AlertConfigDesc, error) {
+	reader, err := s.bucket.Get(ctx, key)
+	if s.bucket.IsObjNotFoundErr(err) {
+		return alerts.AlertConfigDesc{}, alerts.ErrNotFound
+	}
+	if err != nil {
+		return alerts.AlertConfigDesc{}, errors.Wrapf(err, "failed to get alertmanager config %s", key)
+	}
+
+	defer runutil.CloseWithLogOnErr(s.logger, reader, "close alertmanager config reader")
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return alerts.AlertConfigDesc{}, errors.Wrapf(err, "failed to read alertmanager config %s", key)
+	}
+
+	config := alerts.AlertConfigDesc{}
+	if err := config.Unmarshal(buf); err != nil {
+		return alerts.AlertConfigDesc{}, errors.Wrapf(err, "failed to unmarshal alertmanager config %s", key)
+	}
+
+	return config, nil
+}
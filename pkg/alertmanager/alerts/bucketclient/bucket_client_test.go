@@ -0,0 +1,55 @@
+package bucketclient
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alerts"
+)
+
+func TestBucketAlertStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewBucketAlertStore(objstore.NewInMemBucket(), log.NewNopLogger())
+
+	user1Cfg := alerts.AlertConfigDesc{User: "user-1", RawConfig: "content of user-1"}
+	user2Cfg := alerts.AlertConfigDesc{User: "user-2", RawConfig: "content of user-2"}
+
+	// The user doesn't exist yet.
+	_, err := store.GetAlertConfig(ctx, "user-1")
+	assert.Equal(t, alerts.ErrNotFound, err)
+
+	configs, err := store.ListAlertConfigs(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, configs)
+
+	// Store the configs.
+	require.NoError(t, store.SetAlertConfig(ctx, user1Cfg))
+	require.NoError(t, store.SetAlertConfig(ctx, user2Cfg))
+
+	config, err := store.GetAlertConfig(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, user1Cfg, config)
+
+	configs, err = store.ListAlertConfigs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]alerts.AlertConfigDesc{
+		"user-1": user1Cfg,
+		"user-2": user2Cfg,
+	}, configs)
+
+	// Delete a config, and ensure deleting a non existing one doesn't fail.
+	require.NoError(t, store.DeleteAlertConfig(ctx, "user-1"))
+	require.NoError(t, store.DeleteAlertConfig(ctx, "user-1"))
+
+	_, err = store.GetAlertConfig(ctx, "user-1")
+	assert.Equal(t, alerts.ErrNotFound, err)
+
+	configs, err = store.ListAlertConfigs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]alerts.AlertConfigDesc{"user-2": user2Cfg}, configs)
+}
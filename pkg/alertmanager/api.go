@@ -2,10 +2,12 @@ package alertmanager
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts"
 	"github.com/cortexproject/cortex/pkg/tenant"
@@ -25,6 +27,8 @@ const (
 	errStoringConfiguration  = "unable to store the Alertmanager config"
 	errDeletingConfiguration = "unable to delete the Alertmanager config"
 	errNoOrgID               = "unable to determine the OrgID"
+	errInvalidDryRun         = "invalid dry_run parameter"
+	errConfigurationTooBig   = "Alertmanager configuration is too big, limit: %d bytes"
 )
 
 // UserConfig is used to communicate a users alertmanager configs
@@ -80,13 +84,36 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 		return
 	}
 
-	payload, err := ioutil.ReadAll(r.Body)
+	// When dry_run is set, the configuration is only validated and not stored.
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, fmt.Sprintf("%s: %s", errInvalidDryRun, err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var input io.Reader = r.Body
+	maxConfigSize := am.limits.AlertmanagerMaxConfigSize(userID)
+	if maxConfigSize > 0 {
+		// Read one more byte than the limit, to detect when the payload exceeds it.
+		input = io.LimitReader(r.Body, int64(maxConfigSize)+1)
+	}
+
+	payload, err := ioutil.ReadAll(input)
 	if err != nil {
 		level.Error(logger).Log("msg", errReadingConfiguration, "err", err.Error())
 		http.Error(w, fmt.Sprintf("%s: %s", errReadingConfiguration, err.Error()), http.StatusBadRequest)
 		return
 	}
 
+	if maxConfigSize > 0 && len(payload) > maxConfigSize {
+		msg := fmt.Sprintf(errConfigurationTooBig, maxConfigSize)
+		level.Warn(logger).Log("msg", msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
 	cfg := &UserConfig{}
 	err = yaml.Unmarshal(payload, cfg)
 	if err != nil {
@@ -96,12 +123,17 @@ func (am *MultitenantAlertmanager) SetUserConfig(w http.ResponseWriter, r *http.
 	}
 
 	cfgDesc := alerts.ToProto(cfg.AlertmanagerConfig, cfg.TemplateFiles, userID)
-	if err := validateUserConfig(logger, cfgDesc); err != nil {
+	if err := validateUserConfig(logger, cfgDesc, am.limits); err != nil {
 		level.Warn(logger).Log("msg", errValidatingConfig, "err", err.Error())
 		http.Error(w, fmt.Sprintf("%s: %s", errValidatingConfig, err.Error()), http.StatusBadRequest)
 		return
 	}
 
+	if dryRun {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	err = am.store.SetAlertConfig(r.Context(), cfgDesc)
 	if err != nil {
 		level.Error(logger).Log("msg", errStoringConfiguration, "err", err.Error())
@@ -132,7 +164,7 @@ func (am *MultitenantAlertmanager) DeleteUserConfig(w http.ResponseWriter, r *ht
 }
 
 // Partially copied from: https://github.com/prometheus/alertmanager/blob/8e861c646bf67599a1704fc843c6a94d519ce312/cli/check_config.go#L65-L96
-func validateUserConfig(logger log.Logger, cfg alerts.AlertConfigDesc) error {
+func validateUserConfig(logger log.Logger, cfg alerts.AlertConfigDesc, limits Limits) error {
 	// We don't have a valid use case for empty configurations. If a tenant does not have a
 	// configuration set and issue a request to the Alertmanager, we'll a) upload an empty
 	// config and b) immediately start an Alertmanager instance for them if a fallback
@@ -146,6 +178,10 @@ func validateUserConfig(logger log.Logger, cfg alerts.AlertConfigDesc) error {
 		return err
 	}
 
+	if maxReceivers := limits.AlertmanagerMaxReceivers(cfg.User); maxReceivers > 0 && len(amCfg.Receivers) > maxReceivers {
+		return fmt.Errorf("the number of receivers (%d) exceeds the limit (%d)", len(amCfg.Receivers), maxReceivers)
+	}
+
 	// Create templates on disk in a temporary directory.
 	// Note: This means the validation will succeed if we can write to tmp but
 	// not to configured data dir, and on the flipside, it'll fail if we can't write
@@ -126,6 +126,7 @@ template_files:
 
 	am := &MultitenantAlertmanager{
 		store:  noopAlertStore{},
+		limits: &mockAlertmanagerLimits{},
 		logger: util.Logger,
 	}
 	for _, tc := range testCases {
@@ -151,6 +152,119 @@ template_files:
 	}
 }
 
+func TestAMConfigValidationAPI_Limits(t *testing.T) {
+	const cfg = `
+alertmanager_config: |
+  route:
+    receiver: 'default-receiver'
+  receivers:
+    - name: default-receiver
+    - name: other-receiver
+`
+
+	testCases := map[string]struct {
+		limits           mockAlertmanagerLimits
+		expectedStatus   int
+		expectedResponse string
+	}{
+		"no limits": {
+			expectedStatus: http.StatusCreated,
+		},
+		"config within the size limit": {
+			limits:         mockAlertmanagerLimits{maxConfigSize: len(cfg)},
+			expectedStatus: http.StatusCreated,
+		},
+		"config exceeding the size limit": {
+			limits:           mockAlertmanagerLimits{maxConfigSize: len(cfg) - 1},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: fmt.Sprintf("Alertmanager configuration is too big, limit: %d bytes\n", len(cfg)-1),
+		},
+		"receivers within the limit": {
+			limits:         mockAlertmanagerLimits{maxReceivers: 2},
+			expectedStatus: http.StatusCreated,
+		},
+		"receivers exceeding the limit": {
+			limits:           mockAlertmanagerLimits{maxReceivers: 1},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: "error validating Alertmanager config: the number of receivers (2) exceeds the limit (1)\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			limits := tc.limits
+			am := &MultitenantAlertmanager{
+				store:  noopAlertStore{},
+				limits: &limits,
+				logger: util.Logger,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://alertmanager/api/v1/alerts", bytes.NewReader([]byte(cfg)))
+			ctx := user.InjectOrgID(req.Context(), "testing")
+			w := httptest.NewRecorder()
+			am.SetUserConfig(w, req.WithContext(ctx))
+			resp := w.Result()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedStatus, resp.StatusCode)
+			require.Equal(t, tc.expectedResponse, string(body))
+		})
+	}
+}
+
+func TestAMConfigValidationAPI_DryRun(t *testing.T) {
+	const cfg = `
+alertmanager_config: |
+  route:
+    receiver: 'default-receiver'
+  receivers:
+    - name: default-receiver
+`
+
+	testCases := map[string]struct {
+		query          string
+		expectedStatus int
+		expectedStored bool
+	}{
+		"dry run disabled": {
+			query:          "",
+			expectedStatus: http.StatusCreated,
+			expectedStored: true,
+		},
+		"dry run enabled": {
+			query:          "?dry_run=true",
+			expectedStatus: http.StatusOK,
+			expectedStored: false,
+		},
+		"invalid dry run parameter": {
+			query:          "?dry_run=maybe",
+			expectedStatus: http.StatusBadRequest,
+			expectedStored: false,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			store := &mockAlertStore{configs: map[string]alerts.AlertConfigDesc{}}
+			am := &MultitenantAlertmanager{
+				store:  store,
+				limits: &mockAlertmanagerLimits{},
+				logger: util.Logger,
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "http://alertmanager/api/v1/alerts"+tc.query, bytes.NewReader([]byte(cfg)))
+			ctx := user.InjectOrgID(req.Context(), "testing")
+			w := httptest.NewRecorder()
+			am.SetUserConfig(w, req.WithContext(ctx))
+
+			require.Equal(t, tc.expectedStatus, w.Result().StatusCode)
+			_, stored := store.configs["testing"]
+			require.Equal(t, tc.expectedStored, stored)
+		})
+	}
+}
+
 type noopAlertStore struct{}
 
 func (noopAlertStore) ListAlertConfigs(ctx context.Context) (map[string]alerts.AlertConfigDesc, error) {
@@ -0,0 +1,188 @@
+package alertmanager
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/httpgrpc/server"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+// Distributor forwards the requests received by an alertmanager to the
+// alertmanagers owning the tenant in the ring.
+type Distributor struct {
+	cfg              ClientConfig
+	alertmanagerRing ring.ReadRing
+	clientsPool      ClientsPool
+	logger           log.Logger
+}
+
+// NewDistributor constructs a new Distributor.
+func NewDistributor(cfg ClientConfig, alertmanagersRing ring.ReadRing, clientsPool ClientsPool, logger log.Logger) *Distributor {
+	return &Distributor{
+		cfg:              cfg,
+		alertmanagerRing: alertmanagersRing,
+		clientsPool:      clientsPool,
+		logger:           logger,
+	}
+}
+
+// isWriteRequest returns whether the request writes the alerts, in which case
+// it must be sent to all the replicas of the tenant. All the other requests,
+// including the UI and the silences, are served by a single replica.
+func (d *Distributor) isWriteRequest(req *http.Request) bool {
+	return req.Method == http.MethodPost && strings.HasSuffix(req.URL.Path, "/alerts")
+}
+
+// DistributeRequest forwards the request to the alertmanagers owning the tenant.
+func (d *Distributor) DistributeRequest(w http.ResponseWriter, req *http.Request) {
+	userID, err := tenant.TenantID(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	logger := log.With(d.logger, "user", userID)
+
+	if d.isWriteRequest(req) {
+		d.doWrite(userID, w, req, logger)
+		return
+	}
+
+	d.doUnary(userID, w, req, logger)
+}
+
+// doWrite sends the request to all the replicas of the tenant, succeeding as
+// long as at least one of them accepts it.
+func (d *Distributor) doWrite(userID string, w http.ResponseWriter, req *http.Request, logger log.Logger) {
+	replicationSet, err := d.alertmanagerRing.Get(shardByUser(userID), RingOp, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get the replication set of the user", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	grpcReq, err := server.HTTPRequest(req)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to convert the HTTP request to gRPC", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		mtx       sync.Mutex
+		firstResp *httpgrpc.HTTPResponse
+		lastErr   error
+		wg        sync.WaitGroup
+	)
+
+	for _, instance := range replicationSet.Ingesters {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+
+			resp, err := d.doRequest(req.Context(), userID, addr, grpcReq, logger)
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				lastErr = err
+				return
+			}
+			if firstResp == nil || firstResp.Code/100 != 2 {
+				firstResp = resp
+			}
+		}(instance.Addr)
+	}
+	wg.Wait()
+
+	if firstResp != nil {
+		writeResponse(w, firstResp, logger)
+		return
+	}
+
+	respondFromError(lastErr, w, logger)
+}
+
+// doUnary sends the request to a single replica of the tenant, picked at random.
+// This is used to serve the UI, the reads and the silences, whose state is
+// replicated to the other replicas. If the replica fails, the request is retried
+// on the other replicas of the tenant.
+func (d *Distributor) doUnary(userID string, w http.ResponseWriter, req *http.Request, logger log.Logger) {
+	replicationSet, err := d.alertmanagerRing.Get(shardByUser(userID), RingOp, nil)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get the replication set of the user", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	grpcReq, err := server.HTTPRequest(req)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to convert the HTTP request to gRPC", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var (
+		resp      *httpgrpc.HTTPResponse
+		instances = replicationSet.Ingesters
+	)
+
+	for _, idx := range rand.Perm(len(instances)) {
+		resp, err = d.doRequest(req.Context(), userID, instances[idx].Addr, grpcReq, logger)
+		if err == nil && resp.Code/100 != 5 {
+			break
+		}
+	}
+
+	if resp != nil && err == nil {
+		writeResponse(w, resp, logger)
+		return
+	}
+
+	respondFromError(err, w, logger)
+}
+
+func (d *Distributor) doRequest(ctx context.Context, userID, addr string, grpcReq *httpgrpc.HTTPRequest, logger log.Logger) (*httpgrpc.HTTPResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.cfg.RemoteTimeout)
+	defer cancel()
+
+	amClient, err := d.clientsPool.GetClientFor(addr)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to get the alertmanager client", "addr", addr, "err", err)
+		return nil, err
+	}
+
+	resp, err := amClient.HandleRequest(user.InjectOrgID(ctx, userID), grpcReq)
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to forward the request to the alertmanager", "addr", addr, "err", err)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func respondFromError(err error, w http.ResponseWriter, logger log.Logger) {
+	httpResp, ok := httpgrpc.HTTPResponseFromError(err)
+	if !ok {
+		level.Error(logger).Log("msg", "failed to process the request to the alertmanager", "err", err)
+		http.Error(w, "Failed to process the request to the alertmanager", http.StatusInternalServerError)
+		return
+	}
+	writeResponse(w, httpResp, logger)
+}
+
+func writeResponse(w http.ResponseWriter, resp *httpgrpc.HTTPResponse, logger log.Logger) {
+	if err := server.WriteResponse(w, resp); err != nil {
+		level.Error(logger).Log("msg", "failed to write the response", "err", err)
+	}
+}
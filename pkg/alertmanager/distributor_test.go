@@ -0,0 +1,156 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertmanagerpb"
+	"github.com/cortexproject/cortex/pkg/ring"
+)
+
+func TestDistributor_DistributeRequest(t *testing.T) {
+	tests := map[string]struct {
+		method              string
+		path                string
+		failingReplicas     int
+		expectedStatus      int
+		expectedMinRequests int
+		expectedMaxRequests int
+	}{
+		"alerts writes are sent to all the replicas": {
+			method:              http.MethodPost,
+			path:                "/alertmanager/api/v2/alerts",
+			expectedStatus:      http.StatusOK,
+			expectedMinRequests: 3,
+			expectedMaxRequests: 3,
+		},
+		"alerts writes succeed if at least one replica succeeds": {
+			method:              http.MethodPost,
+			path:                "/alertmanager/api/v1/alerts",
+			failingReplicas:     2,
+			expectedStatus:      http.StatusOK,
+			expectedMinRequests: 3,
+			expectedMaxRequests: 3,
+		},
+		"silences are sent to a single replica": {
+			method:              http.MethodPost,
+			path:                "/alertmanager/api/v2/silences",
+			expectedStatus:      http.StatusOK,
+			expectedMinRequests: 1,
+			expectedMaxRequests: 1,
+		},
+		"UI requests are sent to a single replica": {
+			method:              http.MethodGet,
+			path:                "/alertmanager/",
+			expectedStatus:      http.StatusOK,
+			expectedMinRequests: 1,
+			expectedMaxRequests: 1,
+		},
+		"reads fail over to the other replicas": {
+			method:              http.MethodGet,
+			path:                "/alertmanager/api/v2/silences",
+			failingReplicas:     2,
+			expectedStatus:      http.StatusOK,
+			expectedMinRequests: 1,
+			expectedMaxRequests: 3,
+		},
+		"reads fail if all the replicas fail": {
+			method:              http.MethodGet,
+			path:                "/alertmanager/api/v2/alerts",
+			failingReplicas:     3,
+			expectedStatus:      http.StatusInternalServerError,
+			expectedMinRequests: 3,
+			expectedMaxRequests: 3,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			pool := &mockAlertmanagerClientsPool{clients: map[string]*mockAlertmanagerClient{}}
+			var instances []ring.IngesterDesc
+			for i, addr := range []string{"am-1", "am-2", "am-3"} {
+				instances = append(instances, ring.IngesterDesc{Addr: addr})
+				pool.clients[addr] = &mockAlertmanagerClient{addr: addr, failing: i < testData.failingReplicas}
+			}
+
+			d := NewDistributor(ClientConfig{}, &mockReadRing{instances: instances}, pool, log.NewNopLogger())
+
+			req := httptest.NewRequest(testData.method, testData.path, nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+			w := httptest.NewRecorder()
+			d.DistributeRequest(w, req)
+
+			assert.Equal(t, testData.expectedStatus, w.Code)
+
+			assert.GreaterOrEqual(t, pool.numRequests(), testData.expectedMinRequests)
+			assert.LessOrEqual(t, pool.numRequests(), testData.expectedMaxRequests)
+		})
+	}
+}
+
+type mockReadRing struct {
+	ring.ReadRing
+
+	instances []ring.IngesterDesc
+}
+
+func (r *mockReadRing) Get(_ uint32, _ ring.Operation, _ []ring.IngesterDesc) (ring.ReplicationSet, error) {
+	return ring.ReplicationSet{Ingesters: r.instances}, nil
+}
+
+type mockAlertmanagerClientsPool struct {
+	clients map[string]*mockAlertmanagerClient
+}
+
+func (p *mockAlertmanagerClientsPool) GetClientFor(addr string) (Client, error) {
+	return p.clients[addr], nil
+}
+
+func (p *mockAlertmanagerClientsPool) numRequests() int {
+	total := 0
+	for _, c := range p.clients {
+		total += c.numRequests()
+	}
+	return total
+}
+
+type mockAlertmanagerClient struct {
+	alertmanagerpb.AlertmanagerClient
+
+	addr    string
+	failing bool
+
+	mtx      sync.Mutex
+	requests int
+}
+
+func (c *mockAlertmanagerClient) HandleRequest(_ context.Context, _ *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.requests++
+
+	if c.failing {
+		return nil, errors.New("replica failed")
+	}
+	return &httpgrpc.HTTPResponse{Code: http.StatusOK}, nil
+}
+
+func (c *mockAlertmanagerClient) RemoteAddress() string {
+	return c.addr
+}
+
+func (c *mockAlertmanagerClient) numRequests() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.requests
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"hash/fnv"
 	"html/template"
 	"io/ioutil"
 	"net/http"
@@ -17,11 +18,19 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/alertmanager/cluster"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
 	amconfig "github.com/prometheus/alertmanager/config"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/httpgrpc/server"
+	"github.com/weaveworks/common/user"
 
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertmanagerpb"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts"
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/client"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -45,6 +54,7 @@ const (
 	<head><title>Cortex Alertmanager Status</title></head>
 	<body>
 		<h1>Cortex Alertmanager Status</h1>
+		{{ if . }}
 		<h2>Node</h2>
 		<dl>
 			<dt>Name</dt><dd>{{.self.Name}}</dd>
@@ -62,11 +72,27 @@ const (
 		{{ else }}
 		<p>No peers</p>
 		{{ end }}
+		{{ else }}
+		<p>Alertmanager gossip-based clustering is disabled.</p>
+		{{ end }}
 	</body>
 </html>
 `
 )
 
+const unshardedRingPage = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Cortex Alertmanager Ring</title>
+	</head>
+	<body>
+		<h1>Cortex Alertmanager Ring</h1>
+		<p>Alertmanager running with sharding disabled</p>
+	</body>
+</html>`
+
 var (
 	statusTemplate *template.Template
 )
@@ -100,9 +126,26 @@ type MultitenantAlertmanagerConfig struct {
 	Store AlertStoreConfig `yaml:"storage"`
 
 	EnableAPI bool `yaml:"enable_api"`
+
+	// Enable sharding for the Alertmanager
+	ShardingEnabled bool       `yaml:"sharding_enabled"`
+	ShardingRing    RingConfig `yaml:"sharding_ring"`
+
+	AlertmanagerClient ClientConfig `yaml:"alertmanager_client"`
 }
 
-const defaultClusterAddr = "0.0.0.0:9094"
+const (
+	defaultClusterAddr = "0.0.0.0:9094"
+
+	// Reasons for (re)syncing the tenants' configurations.
+	reasonPeriodic   = "periodic"
+	reasonInitial    = "initial"
+	reasonRingChange = "ring-change"
+)
+
+var (
+	errInvalidReplicationFactor = errors.New("invalid replication factor: it must be greater than zero when sharding is enabled")
+)
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
 func (cfg *MultitenantAlertmanagerConfig) RegisterFlags(f *flag.FlagSet) {
@@ -122,6 +165,10 @@ func (cfg *MultitenantAlertmanagerConfig) RegisterFlags(f *flag.FlagSet) {
 
 	f.BoolVar(&cfg.EnableAPI, "experimental.alertmanager.enable-api", false, "Enable the experimental alertmanager config api.")
 
+	f.BoolVar(&cfg.ShardingEnabled, "alertmanager.sharding-enabled", false, "Shard tenants across multiple alertmanager instances.")
+
+	cfg.AlertmanagerClient.RegisterFlagsWithPrefix("alertmanager.alertmanager-client", f)
+	cfg.ShardingRing.RegisterFlags(f)
 	cfg.Store.RegisterFlags(f)
 }
 
@@ -130,12 +177,20 @@ func (cfg *MultitenantAlertmanagerConfig) Validate() error {
 	if err := cfg.Store.Validate(); err != nil {
 		return errors.Wrap(err, "invalid storage config")
 	}
+
+	if cfg.ShardingEnabled && cfg.ShardingRing.ReplicationFactor <= 0 {
+		return errInvalidReplicationFactor
+	}
+
 	return nil
 }
 
 type multitenantAlertmanagerMetrics struct {
 	lastReloadSuccessful          *prometheus.GaugeVec
 	lastReloadSuccessfulTimestamp *prometheus.GaugeVec
+	syncConfigsTotal              *prometheus.CounterVec
+	ringCheckErrors               prometheus.Counter
+	tenantsOwned                  prometheus.Gauge
 }
 
 func newMultitenantAlertmanagerMetrics(reg prometheus.Registerer) *multitenantAlertmanagerMetrics {
@@ -153,9 +208,38 @@ func newMultitenantAlertmanagerMetrics(reg prometheus.Registerer) *multitenantAl
 		Help:      "Timestamp of the last successful configuration reload.",
 	}, []string{"user"})
 
+	m.syncConfigsTotal = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_alertmanager_sync_configs_total",
+		Help: "Total number of times the alertmanager sync operation triggered.",
+	}, []string{"reason"})
+
+	m.ringCheckErrors = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_alertmanager_ring_check_errors_total",
+		Help: "Number of errors that have occurred when checking the ring for ownership.",
+	})
+
+	m.tenantsOwned = promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name: "cortex_alertmanager_tenants_owned",
+		Help: "Current number of tenants owned by the alertmanager instance.",
+	})
+
 	return m
 }
 
+// Limits defines the per-tenant limits applied by the Alertmanager.
+type Limits interface {
+	// AlertmanagerMaxConfigSize returns the maximum size of the configuration (including
+	// the template files) a tenant can upload via the API, in bytes. 0 = no limit.
+	AlertmanagerMaxConfigSize(userID string) int
+
+	// AlertmanagerMaxReceivers returns the maximum number of receivers in the
+	// configuration of a tenant. 0 = no limit.
+	AlertmanagerMaxReceivers(userID string) int
+
+	ReceiversFirewallLimits
+	NotificationLimits
+}
+
 // A MultitenantAlertmanager manages Alertmanager instances for multiple
 // organizations.
 type MultitenantAlertmanager struct {
@@ -163,7 +247,8 @@ type MultitenantAlertmanager struct {
 
 	cfg *MultitenantAlertmanagerConfig
 
-	store AlertStore
+	store  AlertStore
+	limits Limits
 
 	// The fallback config is stored as a string and parsed every time it's needed
 	// because we mutate the parsed results and don't want those changes to take
@@ -181,10 +266,23 @@ type MultitenantAlertmanager struct {
 	multitenantMetrics  *multitenantAlertmanagerMetrics
 
 	peer *cluster.Peer
+
+	// Ring used for sharding the tenants across the alertmanager instances.
+	ringLifecycler *ring.BasicLifecycler
+	ring           *ring.Ring
+
+	// Pool of clients used to talk to the other alertmanager instances.
+	alertmanagerClientsPool *alertmanagerClientsPool
+	distributor             *Distributor
+	grpcServer              *server.Server
+
+	// Subservices manager (ring, lifecycler, clients pool).
+	subservices        *services.Manager
+	subservicesWatcher *services.FailureWatcher
 }
 
 // NewMultitenantAlertmanager creates a new MultitenantAlertmanager.
-func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, logger log.Logger, registerer prometheus.Registerer) (*MultitenantAlertmanager, error) {
+func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, limits Limits, logger log.Logger, registerer prometheus.Registerer) (*MultitenantAlertmanager, error) {
 	err := os.MkdirAll(cfg.DataDir, 0777)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create Alertmanager data directory %q: %s", cfg.DataDir, err)
@@ -206,8 +304,10 @@ func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, logger log.L
 		}
 	}
 
+	// When sharding is enabled, the state is replicated to the other replicas
+	// of each tenant via the ring, so the gossip mesh is not used.
 	var peer *cluster.Peer
-	if cfg.ClusterBindAddr != "" {
+	if !cfg.ShardingEnabled && cfg.ClusterBindAddr != "" {
 		peer, err = cluster.Create(
 			log.With(logger, "component", "cluster"),
 			registerer,
@@ -231,15 +331,27 @@ func NewMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, logger log.L
 		go peer.Settle(context.Background(), cluster.DefaultGossipInterval)
 	}
 
-	store, err := NewAlertStore(cfg.Store)
+	store, err := NewAlertStore(cfg.Store, logger, registerer)
 	if err != nil {
 		return nil, err
 	}
 
-	return createMultitenantAlertmanager(cfg, fallbackConfig, peer, store, logger, registerer), nil
+	var ringStore kv.Client
+	if cfg.ShardingEnabled {
+		ringStore, err = kv.NewClient(
+			cfg.ShardingRing.KVStore,
+			ring.GetCodec(),
+			kv.RegistererWithKVName(registerer, "alertmanager"),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "create KV store client")
+		}
+	}
+
+	return createMultitenantAlertmanager(cfg, fallbackConfig, peer, store, ringStore, limits, logger, registerer)
 }
 
-func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackConfig []byte, peer *cluster.Peer, store AlertStore, logger log.Logger, registerer prometheus.Registerer) *MultitenantAlertmanager {
+func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackConfig []byte, peer *cluster.Peer, store AlertStore, ringStore kv.Client, limits Limits, logger log.Logger, registerer prometheus.Registerer) (*MultitenantAlertmanager, error) {
 	am := &MultitenantAlertmanager{
 		cfg:                 cfg,
 		fallbackConfig:      string(fallbackConfig),
@@ -249,31 +361,141 @@ func createMultitenantAlertmanager(cfg *MultitenantAlertmanagerConfig, fallbackC
 		multitenantMetrics:  newMultitenantAlertmanagerMetrics(registerer),
 		peer:                peer,
 		store:               store,
+		limits:              limits,
 		logger:              log.With(logger, "component", "MultiTenantAlertmanager"),
 	}
 
+	am.grpcServer = server.NewServer(&handlerForGRPCServer{am: am})
+
 	if registerer != nil {
 		registerer.MustRegister(am.alertmanagerMetrics)
 	}
 
-	am.Service = services.NewTimerService(am.cfg.PollInterval, am.starting, am.iteration, am.stopping)
-	return am
+	if cfg.ShardingEnabled {
+		lifecyclerCfg, err := am.cfg.ShardingRing.ToLifecyclerConfig()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize Alertmanager's lifecycler config")
+		}
+
+		// Define lifecycler delegates in reverse order (last to be called defined first because they're
+		// chained via "next delegate").
+		delegate := ring.BasicLifecyclerDelegate(am)
+		delegate = ring.NewLeaveOnStoppingDelegate(delegate, am.logger)
+		if am.cfg.ShardingRing.AutoForgetUnhealthyPeriods > 0 {
+			delegate = ring.NewAutoForgetDelegate(am.cfg.ShardingRing.HeartbeatTimeout*time.Duration(am.cfg.ShardingRing.AutoForgetUnhealthyPeriods), delegate, am.logger)
+		}
+
+		am.ringLifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, RingNameForServer, RingKey, ringStore, delegate, am.logger, registerer)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize Alertmanager's lifecycler")
+		}
+
+		am.ring, err = ring.NewWithStoreClientAndStrategy(am.cfg.ShardingRing.ToRingConfig(), RingNameForServer, RingKey, ringStore, alertmanagerReplicationStrategy{})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to initialize Alertmanager's ring")
+		}
+
+		if registerer != nil {
+			registerer.MustRegister(am.ring)
+		}
+
+		am.alertmanagerClientsPool = newAlertmanagerClientsPool(client.NewRingServiceDiscovery(am.ring), cfg.AlertmanagerClient, logger, registerer)
+		am.distributor = NewDistributor(cfg.AlertmanagerClient, am.ring, am.alertmanagerClientsPool, log.With(logger, "component", "AlertmanagerDistributor"))
+	}
+
+	am.Service = services.NewBasicService(am.starting, am.run, am.stopping)
+	return am, nil
+}
+
+// handlerForGRPCServer acts as a handler for gRPC server to serve
+// the serveRequest() via the standard ServeHTTP.
+type handlerForGRPCServer struct {
+	am *MultitenantAlertmanager
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (h *handlerForGRPCServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	h.am.serveRequest(w, req)
 }
 
-func (am *MultitenantAlertmanager) starting(ctx context.Context) error {
+func (am *MultitenantAlertmanager) starting(ctx context.Context) (err error) {
+	defer func() {
+		if err == nil || am.subservices == nil {
+			return
+		}
+
+		if stopErr := services.StopManagerAndAwaitStopped(context.Background(), am.subservices); stopErr != nil {
+			level.Error(am.logger).Log("msg", "failed to gracefully stop alertmanager dependencies", "err", stopErr)
+		}
+	}()
+
+	if am.cfg.ShardingEnabled {
+		if am.subservices, err = services.NewManager(am.ringLifecycler, am.ring, am.alertmanagerClientsPool); err != nil {
+			return errors.Wrap(err, "failed to start alertmanager's subservices")
+		}
+
+		if err = services.StartManagerAndAwaitHealthy(ctx, am.subservices); err != nil {
+			return errors.Wrap(err, "failed to start alertmanager's subservices")
+		}
+
+		am.subservicesWatcher = services.NewFailureWatcher()
+		am.subservicesWatcher.WatchManager(am.subservices)
+
+		// We wait until the instance is in the ACTIVE state, so that the
+		// configurations are loaded with the tenants owned by this instance.
+		level.Info(am.logger).Log("msg", "waiting until alertmanager is ACTIVE in the ring")
+		if err = ring.WaitInstanceState(ctx, am.ring, am.ringLifecycler.GetInstanceID(), ring.ACTIVE); err != nil {
+			return err
+		}
+		level.Info(am.logger).Log("msg", "alertmanager is ACTIVE in the ring")
+	}
+
 	// Load initial set of all configurations before polling for new ones.
+	am.multitenantMetrics.syncConfigsTotal.WithLabelValues(reasonInitial).Inc()
 	am.syncConfigs(am.loadAllConfigs())
 	return nil
 }
 
-func (am *MultitenantAlertmanager) iteration(ctx context.Context) error {
-	err := am.updateConfigs()
-	if err != nil {
-		level.Warn(am.logger).Log("msg", "error updating configs", "err", err)
+func (am *MultitenantAlertmanager) run(ctx context.Context) error {
+	tick := time.NewTicker(am.cfg.PollInterval)
+	defer tick.Stop()
+
+	var ringTickerChan <-chan time.Time
+	var ringLastState ring.ReplicationSet
+
+	if am.cfg.ShardingEnabled {
+		ringLastState, _ = am.ring.GetAllHealthy(RingOp)
+		ringTicker := time.NewTicker(util.DurationWithJitter(am.cfg.ShardingRing.RingCheckPeriod, 0.2))
+		defer ringTicker.Stop()
+		ringTickerChan = ringTicker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-am.subservicesWatcher.Chan():
+			return errors.Wrap(err, "alertmanager subservices failed")
+		case <-tick.C:
+			am.multitenantMetrics.syncConfigsTotal.WithLabelValues(reasonPeriodic).Inc()
+			if err := am.updateConfigs(); err != nil {
+				// We don't want to stop the service, just log what happened.
+				level.Warn(am.logger).Log("msg", "error updating configs", "err", err)
+			}
+		case <-ringTickerChan:
+			// We ignore the error because in case of error it will return an empty
+			// replication set which we use to compare with the previous state.
+			currRingState, _ := am.ring.GetAllHealthy(RingOp)
+
+			if ring.HasReplicationSetChanged(ringLastState, currRingState) {
+				ringLastState = currRingState
+				am.multitenantMetrics.syncConfigsTotal.WithLabelValues(reasonRingChange).Inc()
+				if err := am.updateConfigs(); err != nil {
+					level.Warn(am.logger).Log("msg", "error updating configs", "err", err)
+				}
+			}
+		}
 	}
-	// Returning error here would stop "MultitenantAlertmanager" service completely,
-	// so we return nil to keep service running.
-	return nil
 }
 
 // stopping runs when MultitenantAlertmanager transitions to Stopping state.
@@ -283,9 +505,16 @@ func (am *MultitenantAlertmanager) stopping(_ error) error {
 		am.Stop()
 	}
 	am.alertmanagersMtx.Unlock()
-	err := am.peer.Leave(am.cfg.PeerTimeout)
-	if err != nil {
-		level.Warn(am.logger).Log("msg", "failed to leave the cluster", "err", err)
+	if am.peer != nil {
+		err := am.peer.Leave(am.cfg.PeerTimeout)
+		if err != nil {
+			level.Warn(am.logger).Log("msg", "failed to leave the cluster", "err", err)
+		}
+	}
+
+	if am.subservices != nil {
+		// subservices manages ring, lifecycler and clients pool, if sharding was enabled.
+		_ = services.StopManagerAndAwaitStopped(context.Background(), am.subservices)
 	}
 	level.Debug(am.logger).Log("msg", "stopping")
 	return nil
@@ -325,6 +554,18 @@ func (am *MultitenantAlertmanager) poll() (map[string]alerts.AlertConfigDesc, er
 }
 
 func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alerts.AlertConfigDesc) {
+	// When sharding is enabled, only the tenants owned by this instance get an Alertmanager.
+	if am.cfg.ShardingEnabled {
+		owned := make(map[string]alerts.AlertConfigDesc, len(cfgs))
+		for user, cfg := range cfgs {
+			if am.isUserOwned(user) {
+				owned[user] = cfg
+			}
+		}
+		cfgs = owned
+		am.multitenantMetrics.tenantsOwned.Set(float64(len(cfgs)))
+	}
+
 	level.Debug(am.logger).Log("msg", "adding configurations", "num_configs", len(cfgs))
 	for user, cfg := range cfgs {
 		err := am.setConfig(cfg)
@@ -341,7 +582,19 @@ func (am *MultitenantAlertmanager) syncConfigs(cfgs map[string]alerts.AlertConfi
 	am.alertmanagersMtx.Lock()
 	defer am.alertmanagersMtx.Unlock()
 	for user, userAM := range am.alertmanagers {
-		if _, exists := cfgs[user]; !exists {
+		if _, exists := cfgs[user]; !exists && am.cfg.ShardingEnabled {
+			// The tenant is no longer owned by this instance (or has been deleted), so
+			// its Alertmanager is stopped: if it's owned again, the state will be
+			// read back from the other replicas.
+			level.Info(am.logger).Log("msg", "stopping per-tenant alertmanager", "user", user)
+			userAM.Stop()
+			delete(am.alertmanagers, user)
+			delete(am.cfgs, user)
+			am.alertmanagerMetrics.removeUserRegistry(user)
+			am.multitenantMetrics.lastReloadSuccessful.DeleteLabelValues(user)
+			am.multitenantMetrics.lastReloadSuccessfulTimestamp.DeleteLabelValues(user)
+			level.Info(am.logger).Log("msg", "stopped per-tenant alertmanager", "user", user)
+		} else if !exists {
 			// The user alertmanager is only paused in order to retain the prometheus metrics
 			// it has reported to its registry. If a new config for this user appears, this structure
 			// will be reused.
@@ -445,6 +698,23 @@ func (am *MultitenantAlertmanager) setConfig(cfg alerts.AlertConfigDesc) error {
 	return nil
 }
 
+// isUserOwned returns whether the tenant is owned by this alertmanager instance.
+func (am *MultitenantAlertmanager) isUserOwned(userID string) bool {
+	// If sharding is disabled, any alertmanager instance owns all the tenants.
+	if !am.cfg.ShardingEnabled {
+		return true
+	}
+
+	alertmanagers, err := am.ring.Get(shardByUser(userID), RingOp, nil)
+	if err != nil {
+		am.multitenantMetrics.ringCheckErrors.Inc()
+		level.Error(am.logger).Log("msg", "failed to check the ring for the tenant's ownership", "user", userID, "err", err)
+		return false
+	}
+
+	return alertmanagers.Includes(am.ringLifecycler.GetInstanceAddr())
+}
+
 func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amconfig.Config, rawCfg string) (*Alertmanager, error) {
 	reg := prometheus.NewRegistry()
 	newAM, err := New(&Config{
@@ -453,6 +723,8 @@ func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amco
 		Logger:      util.Logger,
 		Peer:        am.peer,
 		PeerTimeout: am.cfg.PeerTimeout,
+		Replicator:  am.replicator(),
+		Limits:      am.limits,
 		Retention:   am.cfg.Retention,
 		ExternalURL: am.cfg.ExternalURL.URL,
 	}, reg)
@@ -468,8 +740,254 @@ func (am *MultitenantAlertmanager) newAlertmanager(userID string, amConfig *amco
 	return newAM, nil
 }
 
+// replicator returns the Replicator of the tenants' state, if sharding is enabled.
+func (am *MultitenantAlertmanager) replicator() Replicator {
+	if !am.cfg.ShardingEnabled {
+		return nil
+	}
+	return am
+}
+
 // ServeHTTP serves the Alertmanager's web UI and API.
 func (am *MultitenantAlertmanager) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	// When sharding is enabled, the request is forwarded to the alertmanagers
+	// owning the tenant, which serve it via the gRPC HandleRequest.
+	if am.cfg.ShardingEnabled {
+		am.distributor.DistributeRequest(w, req)
+		return
+	}
+
+	am.serveRequest(w, req)
+}
+
+// HandleRequest implements gRPC Alertmanager service, which receives the
+// requests forwarded by the distributor.
+func (am *MultitenantAlertmanager) HandleRequest(ctx context.Context, in *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	return am.grpcServer.Handle(ctx, in)
+}
+
+// ReplicateStateForUser replicates a partial state of the tenant's Alertmanager
+// to the other replicas of the tenant.
+func (am *MultitenantAlertmanager) ReplicateStateForUser(ctx context.Context, userID string, part *clusterpb.Part) error {
+	replicas, err := am.getOtherReplicasForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	// All the other replicas are expected to merge the partial state.
+	replicas.MaxErrors = 0
+
+	ctx = user.InjectOrgID(ctx, userID)
+	_, err = replicas.Do(ctx, 0, func(ctx context.Context, desc *ring.IngesterDesc) (interface{}, error) {
+		c, err := am.alertmanagerClientsPool.GetClientFor(desc.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, am.cfg.AlertmanagerClient.RemoteTimeout)
+		defer cancel()
+
+		resp, err := c.UpdateState(ctx, part)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.Status {
+		case alertmanagerpb.MERGE_ERROR:
+			return nil, fmt.Errorf("failed to merge the state on alertmanager %s: %s", desc.Addr, resp.Error)
+		case alertmanagerpb.USER_NOT_FOUND:
+			// The replica has not loaded the tenant's Alertmanager yet: it will
+			// read the full state once it does.
+			level.Debug(am.logger).Log("msg", "user not found while replicating state", "user", userID, "key", part.Key, "addr", desc.Addr)
+		}
+
+		return nil, nil
+	})
+
+	return err
+}
+
+// ReadFullStateForUser reads the full state of the tenant's Alertmanager from
+// the other replicas of the tenant.
+func (am *MultitenantAlertmanager) ReadFullStateForUser(ctx context.Context, userID string) ([]*clusterpb.FullState, error) {
+	replicas, err := am.getOtherReplicasForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing to read if this is the only replica of the tenant.
+	if len(replicas.Ingesters) == 0 {
+		return nil, nil
+	}
+
+	// The full state of a single replica is enough to settle.
+	replicas.MaxErrors = len(replicas.Ingesters) - 1
+
+	var (
+		mtx     sync.Mutex
+		results []*clusterpb.FullState
+	)
+
+	ctx = user.InjectOrgID(ctx, userID)
+	_, err = replicas.Do(ctx, 0, func(ctx context.Context, desc *ring.IngesterDesc) (interface{}, error) {
+		c, err := am.alertmanagerClientsPool.GetClientFor(desc.Addr)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, am.cfg.AlertmanagerClient.RemoteTimeout)
+		defer cancel()
+
+		resp, err := c.ReadState(ctx, &alertmanagerpb.ReadStateRequest{})
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.Status {
+		case alertmanagerpb.READ_OK:
+			mtx.Lock()
+			results = append(results, resp.State)
+			mtx.Unlock()
+		case alertmanagerpb.READ_USER_NOT_FOUND:
+			// The replica doesn't run the tenant's Alertmanager yet, so it has no state.
+		default:
+			return nil, fmt.Errorf("failed to read the state from alertmanager %s: %s", desc.Addr, resp.Error)
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	mtx.Lock()
+	defer mtx.Unlock()
+	return results, nil
+}
+
+// GetPositionForUser returns the position this Alertmanager instance holds in
+// the replication set of the tenant.
+func (am *MultitenantAlertmanager) GetPositionForUser(userID string) int {
+	if am.cfg.ShardingRing.ReplicationFactor <= 1 {
+		return 0
+	}
+
+	set, err := am.ring.Get(shardByUser(userID), RingOp, nil)
+	if err != nil {
+		level.Error(am.logger).Log("msg", "unable to read the ring while trying to determine the alertmanager position", "err", err)
+		// If we're unable to determine the position, we don't want a tenant to miss out on the notification - instead,
+		// just assume we're the first in line and run the risk of a double notification.
+		return 0
+	}
+
+	var position int
+	for i, instance := range set.Ingesters {
+		if instance.Addr == am.ringLifecycler.GetInstanceAddr() {
+			position = i
+			break
+		}
+	}
+
+	return position
+}
+
+// getOtherReplicasForUser returns the replication set of the tenant, excluding
+// this alertmanager instance.
+func (am *MultitenantAlertmanager) getOtherReplicasForUser(userID string) (ring.ReplicationSet, error) {
+	set, err := am.ring.Get(shardByUser(userID), RingOp, nil)
+	if err != nil {
+		return ring.ReplicationSet{}, errors.Wrap(err, "failed to get the replication set of the user")
+	}
+
+	selfAddr := am.ringLifecycler.GetInstanceAddr()
+	others := make([]ring.IngesterDesc, 0, len(set.Ingesters))
+	for _, instance := range set.Ingesters {
+		if instance.Addr != selfAddr {
+			others = append(others, instance)
+		}
+	}
+
+	return ring.ReplicationSet{Ingesters: others}, nil
+}
+
+// UpdateState implements the gRPC Alertmanager service, merging a partial
+// state replicated by another replica of the tenant.
+func (am *MultitenantAlertmanager) UpdateState(ctx context.Context, part *clusterpb.Part) (*alertmanagerpb.UpdateStateResponse, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	am.alertmanagersMtx.Lock()
+	userAM, ok := am.alertmanagers[userID]
+	am.alertmanagersMtx.Unlock()
+
+	if !ok {
+		return &alertmanagerpb.UpdateStateResponse{
+			Status: alertmanagerpb.USER_NOT_FOUND,
+			Error:  "alertmanager for this user does not exist",
+		}, nil
+	}
+
+	if err = userAM.mergePartialExternalState(part); err != nil {
+		return &alertmanagerpb.UpdateStateResponse{
+			Status: alertmanagerpb.MERGE_ERROR,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &alertmanagerpb.UpdateStateResponse{Status: alertmanagerpb.OK}, nil
+}
+
+// ReadState implements the gRPC Alertmanager service, returning the full
+// state of the tenant's Alertmanager.
+func (am *MultitenantAlertmanager) ReadState(ctx context.Context, _ *alertmanagerpb.ReadStateRequest) (*alertmanagerpb.ReadStateResponse, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	am.alertmanagersMtx.Lock()
+	userAM, ok := am.alertmanagers[userID]
+	am.alertmanagersMtx.Unlock()
+
+	if !ok {
+		return &alertmanagerpb.ReadStateResponse{
+			Status: alertmanagerpb.READ_USER_NOT_FOUND,
+			Error:  "alertmanager for this user does not exist",
+		}, nil
+	}
+
+	state, err := userAM.getFullState()
+	if err != nil {
+		return &alertmanagerpb.ReadStateResponse{
+			Status: alertmanagerpb.READ_ERROR,
+			Error:  err.Error(),
+		}, nil
+	}
+
+	return &alertmanagerpb.ReadStateResponse{
+		Status: alertmanagerpb.READ_OK,
+		State:  state,
+	}, nil
+}
+
+// RingHandler serves the alertmanager ring page, or a message if sharding is disabled.
+func (am *MultitenantAlertmanager) RingHandler(w http.ResponseWriter, req *http.Request) {
+	if !am.cfg.ShardingEnabled {
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(unshardedRingPage))
+		if err != nil {
+			level.Error(am.logger).Log("msg", "unable to serve alertmanager ring page", "err", err)
+		}
+		return
+	}
+
+	am.ring.ServeHTTP(w, req)
+}
+
+// serveRequest serves the Alertmanager's web UI and API of the tenant locally.
+func (am *MultitenantAlertmanager) serveRequest(w http.ResponseWriter, req *http.Request) {
 	userID, err := tenant.TenantID(req.Context())
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnauthorized)
@@ -540,12 +1058,24 @@ type StatusHandler struct {
 
 // ServeHTTP serves the status of the alertmanager.
 func (s StatusHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	err := statusTemplate.Execute(w, s.am.peer.Info())
+	var info map[string]interface{}
+	if s.am.peer != nil {
+		info = s.am.peer.Info()
+	}
+	err := statusTemplate.Execute(w, info)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 }
 
+// shardByUser returns the ring token of the tenant.
+func shardByUser(userID string) uint32 {
+	ringHasher := fnv.New32a()
+	// Hasher never returns err.
+	_, _ = ringHasher.Write([]byte(userID))
+	return ringHasher.Sum32()
+}
+
 func createTemplateFile(dataDir, userID, fn, content string) (bool, error) {
 	if fn != filepath.Base(fn) {
 		return false, fmt.Errorf("template file name '%s' is not not valid", fn)
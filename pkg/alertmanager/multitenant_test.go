@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
@@ -16,9 +17,14 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
+	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts"
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
 )
 
 var (
@@ -57,6 +63,49 @@ func (m *mockAlertStore) DeleteAlertConfig(ctx context.Context, user string) err
 	return fmt.Errorf("not implemented")
 }
 
+type mockAlertmanagerLimits struct {
+	maxConfigSize int
+	maxReceivers  int
+
+	blockCIDRNetworks     []flagext.CIDR
+	blockPrivateAddresses bool
+	allowCIDRNetworks     []flagext.CIDR
+
+	notificationRateLimit rate.Limit
+	notificationBurstSize int
+}
+
+func (m *mockAlertmanagerLimits) AlertmanagerMaxConfigSize(_ string) int {
+	return m.maxConfigSize
+}
+
+func (m *mockAlertmanagerLimits) AlertmanagerMaxReceivers(_ string) int {
+	return m.maxReceivers
+}
+
+func (m *mockAlertmanagerLimits) AlertmanagerReceiversBlockCIDRNetworks(_ string) []flagext.CIDR {
+	return m.blockCIDRNetworks
+}
+
+func (m *mockAlertmanagerLimits) AlertmanagerReceiversBlockPrivateAddresses(_ string) bool {
+	return m.blockPrivateAddresses
+}
+
+func (m *mockAlertmanagerLimits) AlertmanagerReceiversAllowCIDRNetworks(_ string) []flagext.CIDR {
+	return m.allowCIDRNetworks
+}
+
+func (m *mockAlertmanagerLimits) NotificationRateLimit(_ string, _ string) rate.Limit {
+	if m.notificationRateLimit == 0 {
+		return rate.Inf
+	}
+	return m.notificationRateLimit
+}
+
+func (m *mockAlertmanagerLimits) NotificationBurstSize(_ string, _ string) int {
+	return m.notificationBurstSize
+}
+
 func TestLoadAllConfigs(t *testing.T) {
 	mockStore := &mockAlertStore{
 		configs: map[string]alerts.AlertConfigDesc{
@@ -82,10 +131,11 @@ func TestLoadAllConfigs(t *testing.T) {
 	defer os.RemoveAll(tempDir)
 
 	reg := prometheus.NewPedanticRegistry()
-	am := createMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
+	am, err := createMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
 		ExternalURL: externalURL,
 		DataDir:     tempDir,
-	}, nil, nil, mockStore, log.NewNopLogger(), reg)
+	}, nil, nil, mockStore, nil, &mockAlertmanagerLimits{}, log.NewNopLogger(), reg)
+	require.NoError(t, err)
 
 	// Ensure the configs are synced correctly
 	require.NoError(t, am.updateConfigs())
@@ -187,7 +237,7 @@ func TestAlertmanager_NoExternalURL(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 	_, err = NewMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
 		DataDir: tempDir,
-	}, log.NewNopLogger(), reg)
+	}, &mockAlertmanagerLimits{}, log.NewNopLogger(), reg)
 
 	require.EqualError(t, err, "unable to create Alertmanager because the external URL has not been configured")
 }
@@ -207,10 +257,11 @@ func TestAlertmanager_ServeHTTP(t *testing.T) {
 
 	// Create the Multitenant Alertmanager.
 	reg := prometheus.NewPedanticRegistry()
-	am := createMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
+	am, err := createMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
 		ExternalURL: externalURL,
 		DataDir:     tempDir,
-	}, nil, nil, mockStore, log.NewNopLogger(), reg)
+	}, nil, nil, mockStore, nil, &mockAlertmanagerLimits{}, log.NewNopLogger(), reg)
+	require.NoError(t, err)
 
 	// Request when no user configuration is present.
 	req := httptest.NewRequest("GET", externalURL.String(), nil)
@@ -270,10 +321,11 @@ receivers:
 `
 
 	// Create the Multitenant Alertmanager.
-	am := createMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
+	am, err := createMultitenantAlertmanager(&MultitenantAlertmanagerConfig{
 		ExternalURL: externalURL,
 		DataDir:     tempDir,
-	}, nil, nil, mockStore, log.NewNopLogger(), nil)
+	}, nil, nil, mockStore, nil, &mockAlertmanagerLimits{}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
 	am.fallbackConfig = fallbackCfg
 
 	// Request when no user configuration is present.
@@ -308,3 +360,79 @@ receivers:
 	body, _ := ioutil.ReadAll(resp.Body)
 	require.Equal(t, "the Alertmanager is not configured\n", string(body))
 }
+
+func TestMultitenantAlertmanager_ShardingByRing(t *testing.T) {
+	const (
+		numInstances      = 3
+		numUsers          = 10
+		replicationFactor = 2
+	)
+
+	ctx := context.Background()
+	externalURL := flagext.URLValue{}
+	require.NoError(t, externalURL.Set("http://localhost:8080/alertmanager"))
+
+	mockStore := &mockAlertStore{configs: map[string]alerts.AlertConfigDesc{}}
+	for i := 0; i < numUsers; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		mockStore.configs[userID] = alerts.AlertConfigDesc{User: userID, RawConfig: simpleConfigOne}
+	}
+
+	ringStore := consul.NewInMemoryClient(ring.GetCodec())
+
+	var instances []*MultitenantAlertmanager
+	for i := 0; i < numInstances; i++ {
+		tempDir, err := ioutil.TempDir(os.TempDir(), "alertmanager")
+		require.NoError(t, err)
+		t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+		cfg := &MultitenantAlertmanagerConfig{
+			ExternalURL:     externalURL,
+			DataDir:         tempDir,
+			PollInterval:    time.Minute,
+			ShardingEnabled: true,
+			ShardingRing: RingConfig{
+				InstanceID:        fmt.Sprintf("alertmanager-%d", i),
+				InstanceAddr:      "127.0.0.1",
+				InstancePort:      9095 + i,
+				HeartbeatPeriod:   time.Second,
+				HeartbeatTimeout:  time.Minute,
+				ReplicationFactor: replicationFactor,
+				RingCheckPeriod:   100 * time.Millisecond,
+			},
+			AlertmanagerClient: ClientConfig{RemoteTimeout: time.Second},
+		}
+
+		am, err := createMultitenantAlertmanager(cfg, nil, nil, mockStore, ringStore, &mockAlertmanagerLimits{}, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		require.NoError(t, services.StartAndAwaitRunning(ctx, am))
+		t.Cleanup(func() {
+			require.NoError(t, services.StopAndAwaitTerminated(ctx, am))
+		})
+
+		instances = append(instances, am)
+	}
+
+	// Once all the instances have joined the ring, each tenant's Alertmanager
+	// should run on exactly replication factor instances.
+	test.Poll(t, 5*time.Second, true, func() interface{} {
+		for i := 0; i < numUsers; i++ {
+			userID := fmt.Sprintf("user-%d", i)
+
+			owners := 0
+			for _, am := range instances {
+				am.alertmanagersMtx.Lock()
+				_, ok := am.alertmanagers[userID]
+				am.alertmanagersMtx.Unlock()
+				if ok {
+					owners++
+				}
+			}
+
+			if owners != replicationFactor {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,60 @@
+package alertmanager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+)
+
+var errRateLimited = errors.New("failed to notify due to rate limits")
+
+// NotificationLimits defines the per-tenant notification rate limits.
+type NotificationLimits interface {
+	// NotificationRateLimit returns the limit used by the rate limiter of the
+	// integration of the tenant. rate.Inf disables the rate limit.
+	NotificationRateLimit(userID string, integration string) rate.Limit
+
+	// NotificationBurstSize returns the burst size used by the rate limiter of
+	// the integration of the tenant.
+	NotificationBurstSize(userID string, integration string) int
+}
+
+// newNotificationRateLimiter returns a rate limiter initialised with the
+// current limits of the integration of the tenant, so that the whole burst
+// is available from the start.
+func newNotificationRateLimiter(userID, integration string, limits NotificationLimits) *rate.Limiter {
+	return rate.NewLimiter(limits.NotificationRateLimit(userID, integration), limits.NotificationBurstSize(userID, integration))
+}
+
+// rateLimitedNotifier wraps a notifier, enforcing the tenant's notification
+// rate limit of the integration.
+type rateLimitedNotifier struct {
+	upstream    notify.Notifier
+	userID      string
+	integration string
+	limits      NotificationLimits
+	limiter     *rate.Limiter
+	rateLimited prometheus.Counter
+}
+
+// Notify implements notify.Notifier.
+func (r *rateLimitedNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	// The limits are read at every notification, so that the changes of the
+	// per-tenant overrides are applied without recreating the limiter.
+	r.limiter.SetLimit(r.limits.NotificationRateLimit(r.userID, r.integration))
+	r.limiter.SetBurst(r.limits.NotificationBurstSize(r.userID, r.integration))
+
+	if !r.limiter.Allow() {
+		r.rateLimited.Inc()
+
+		// The notification is not retried immediately: it will be retried at
+		// the next flush of the aggregation group.
+		return false, errRateLimited
+	}
+
+	return r.upstream.Notify(ctx, alerts...)
+}
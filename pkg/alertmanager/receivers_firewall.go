@@ -0,0 +1,247 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	// privateNetworks are the private and local networks blocked when the
+	// tenant's receivers firewall blocks the private addresses.
+	privateNetworks = mustParseCIDRs(
+		// RFC 1918 (IPv4 private networks).
+		"10.0.0.0/8",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		// RFC 4193 (IPv6 unique local addresses).
+		"fc00::/7",
+		// Loopback.
+		"127.0.0.0/8",
+		"::1/128",
+		// Link-local unicast and multicast.
+		"169.254.0.0/16",
+		"fe80::/10",
+		"224.0.0.0/24",
+		"ff02::/16",
+		// Unspecified.
+		"0.0.0.0/32",
+		"::/128",
+	)
+)
+
+// ReceiversFirewallLimits defines the per-tenant limits of the receivers firewall.
+type ReceiversFirewallLimits interface {
+	// AlertmanagerReceiversBlockCIDRNetworks returns the network CIDRs the receivers
+	// of the tenant are not allowed to send notifications to.
+	AlertmanagerReceiversBlockCIDRNetworks(userID string) []flagext.CIDR
+
+	// AlertmanagerReceiversBlockPrivateAddresses returns whether the receivers of the
+	// tenant are not allowed to send notifications to private and local addresses.
+	AlertmanagerReceiversBlockPrivateAddresses(userID string) bool
+
+	// AlertmanagerReceiversAllowCIDRNetworks returns the network CIDRs the receivers
+	// of the tenant are always allowed to send notifications to.
+	AlertmanagerReceiversAllowCIDRNetworks(userID string) []flagext.CIDR
+}
+
+// receiversFirewall checks whether the destination of a notification is
+// allowed by the tenant's limits.
+type receiversFirewall struct {
+	userID   string
+	limits   ReceiversFirewallLimits
+	resolver *net.Resolver
+}
+
+func newReceiversFirewall(userID string, limits ReceiversFirewallLimits) *receiversFirewall {
+	return &receiversFirewall{
+		userID:   userID,
+		limits:   limits,
+		resolver: net.DefaultResolver,
+	}
+}
+
+// enabled returns whether any firewall rule is configured for the tenant.
+func (f *receiversFirewall) enabled() bool {
+	return f.limits.AlertmanagerReceiversBlockPrivateAddresses(f.userID) || len(f.limits.AlertmanagerReceiversBlockCIDRNetworks(f.userID)) > 0
+}
+
+// checkHost returns an error if any of the addresses the host resolves to is blocked.
+func (f *receiversFirewall) checkHost(ctx context.Context, host string) error {
+	if host == "" || !f.enabled() {
+		return nil
+	}
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := f.resolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+
+	for _, ip := range ips {
+		if f.isBlocked(ip) {
+			return fmt.Errorf("notification to %s blocked by the receivers firewall: address %s is not allowed", host, ip.String())
+		}
+	}
+
+	return nil
+}
+
+// checkAddr returns an error if the "host:port" address, as dialed, is blocked.
+func (f *receiversFirewall) checkAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	if ip := net.ParseIP(host); ip != nil && f.isBlocked(ip) {
+		return fmt.Errorf("notification blocked by the receivers firewall: address %s is not allowed", ip.String())
+	}
+	return nil
+}
+
+func (f *receiversFirewall) isBlocked(ip net.IP) bool {
+	// The allowed networks take precedence over the blocked ones.
+	if containsIP(f.limits.AlertmanagerReceiversAllowCIDRNetworks(f.userID), ip) {
+		return false
+	}
+
+	if f.limits.AlertmanagerReceiversBlockPrivateAddresses(f.userID) && containsIP(privateNetworks, ip) {
+		return true
+	}
+
+	return containsIP(f.limits.AlertmanagerReceiversBlockCIDRNetworks(f.userID), ip)
+}
+
+// firewallNotifier wraps a notifier, blocking the notifications whose
+// destination isn't allowed by the tenant's receivers firewall.
+type firewallNotifier struct {
+	upstream notify.Notifier
+	host     string
+	firewall *receiversFirewall
+	blocked  prometheus.Counter
+}
+
+// Notify implements notify.Notifier.
+func (n *firewallNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	if !n.firewall.enabled() {
+		return n.upstream.Notify(ctx, alerts...)
+	}
+
+	// The configured host is checked upfront, to fail fast without sending anything.
+	if err := n.firewall.checkHost(ctx, n.host); err != nil {
+		n.blocked.Inc()
+
+		// The notification is not retried, because the firewall rules are not
+		// expected to change before the retries run out.
+		return false, err
+	}
+
+	// The address resolved by the host may change by the time it's dialed, and the
+	// notification may be redirected or sent through a proxy, so the firewall is
+	// enforced on every address actually dialed by the notifier.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	guard := &firewallDialGuard{firewall: n.firewall, cancel: cancel}
+	retry, err := n.upstream.Notify(guard.withClientTrace(ctx), alerts...)
+	if blockedErr := guard.error(); blockedErr != nil {
+		n.blocked.Inc()
+		return false, blockedErr
+	}
+
+	return retry, err
+}
+
+// firewallDialGuard aborts a notification as soon as it dials, or gets a connection
+// to, an address blocked by the receivers firewall.
+type firewallDialGuard struct {
+	firewall *receiversFirewall
+	cancel   context.CancelFunc
+
+	mtx sync.Mutex
+	err error
+}
+
+// withClientTrace returns a context tracing the connections of the requests and dials
+// run with it, which are checked against the firewall.
+func (g *firewallDialGuard) withClientTrace(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		// Called with the resolved address, before each connection attempt.
+		ConnectStart: func(_, addr string) {
+			if err := g.firewall.checkAddr(addr); err != nil {
+				g.block(err)
+			}
+		},
+		// Called before sending the request, including on a connection re-used
+		// from a previous notification.
+		GotConn: func(info httptrace.GotConnInfo) {
+			if err := g.firewall.checkAddr(info.Conn.RemoteAddr().String()); err != nil {
+				g.block(err)
+				_ = info.Conn.Close()
+			}
+		},
+	})
+}
+
+// block aborts the notification because of the input error.
+func (g *firewallDialGuard) block(err error) {
+	g.mtx.Lock()
+	if g.err == nil {
+		g.err = err
+	}
+	g.mtx.Unlock()
+
+	g.cancel()
+}
+
+func (g *firewallDialGuard) error() error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.err
+}
+
+// urlHost returns the host of the URL, or an empty string if the URL is not set.
+func urlHost(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+func containsIP(networks []flagext.CIDR, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Value != nil && network.Value.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(values ...string) []flagext.CIDR {
+	cidrs := make([]flagext.CIDR, 0, len(values))
+	for _, value := range values {
+		cidr := flagext.CIDR{}
+		if err := cidr.Set(value); err != nil {
+			panic(err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs
+}
@@ -0,0 +1,213 @@
+package alertmanager
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestReceiversFirewall_IsBlocked(t *testing.T) {
+	tests := map[string]struct {
+		limits   mockAlertmanagerLimits
+		ip       string
+		expected bool
+	}{
+		"no rules": {
+			ip:       "10.0.0.1",
+			expected: false,
+		},
+		"private address blocked": {
+			limits:   mockAlertmanagerLimits{blockPrivateAddresses: true},
+			ip:       "10.0.0.1",
+			expected: true,
+		},
+		"loopback IPv6 address blocked": {
+			limits:   mockAlertmanagerLimits{blockPrivateAddresses: true},
+			ip:       "::1",
+			expected: true,
+		},
+		"public address not blocked by the private addresses rule": {
+			limits:   mockAlertmanagerLimits{blockPrivateAddresses: true},
+			ip:       "1.1.1.1",
+			expected: false,
+		},
+		"address in a blocked network": {
+			limits:   mockAlertmanagerLimits{blockCIDRNetworks: mustParseCIDRs("1.1.1.0/24")},
+			ip:       "1.1.1.1",
+			expected: true,
+		},
+		"address outside a blocked network": {
+			limits:   mockAlertmanagerLimits{blockCIDRNetworks: mustParseCIDRs("1.1.1.0/24")},
+			ip:       "1.1.2.1",
+			expected: false,
+		},
+		"allowed network takes precedence": {
+			limits:   mockAlertmanagerLimits{blockPrivateAddresses: true, allowCIDRNetworks: mustParseCIDRs("10.0.0.0/24")},
+			ip:       "10.0.0.1",
+			expected: false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := testData.limits
+			firewall := newReceiversFirewall("user-1", &limits)
+			assert.Equal(t, testData.expected, firewall.isBlocked(net.ParseIP(testData.ip)))
+		})
+	}
+}
+
+func TestFirewallNotifier(t *testing.T) {
+	limits := &mockAlertmanagerLimits{blockPrivateAddresses: true}
+	blocked := prometheus.NewCounter(prometheus.CounterOpts{})
+
+	for _, host := range []string{"127.0.0.1", "192.168.1.1", "localhost"} {
+		upstream := &mockNotifier{}
+		n := &firewallNotifier{upstream: upstream, host: host, firewall: newReceiversFirewall("user-1", limits), blocked: blocked}
+
+		retry, err := n.Notify(context.Background(), &types.Alert{})
+		require.Error(t, err, host)
+		assert.False(t, retry)
+		assert.Equal(t, 0, upstream.calls)
+	}
+	assert.Equal(t, float64(3), testutil.ToFloat64(blocked))
+
+	upstream := &mockNotifier{}
+	n := &firewallNotifier{upstream: upstream, host: "1.1.1.1", firewall: newReceiversFirewall("user-1", limits), blocked: blocked}
+	_, err := n.Notify(context.Background(), &types.Alert{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, upstream.calls)
+}
+
+func TestFirewallNotifier_ShouldEnforceTheFirewallOnTheDialedAddresses(t *testing.T) {
+	// The blocked server listens on a different loopback address than the allowed one.
+	blockedHits := atomic.NewInt64(0)
+	blockedListener, err := net.Listen("tcp", "127.0.0.2:0")
+	if err != nil {
+		t.Skip("127.0.0.2 is not available:", err)
+	}
+	blockedServer := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		blockedHits.Inc()
+	}))
+	blockedServer.Listener = blockedListener
+	blockedServer.Start()
+	defer blockedServer.Close()
+
+	redirectServer := httptest.NewServer(http.RedirectHandler(blockedServer.URL, http.StatusFound))
+	defer redirectServer.Close()
+
+	blockedURL, err := url.Parse(blockedServer.URL)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		client *http.Client
+		url    string
+	}{
+		"redirect to a blocked address": {
+			client: &http.Client{Transport: &http.Transport{}},
+			url:    redirectServer.URL,
+		},
+		"proxy on a blocked address": {
+			client: &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(blockedURL)}},
+			url:    "http://1.1.1.1/",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			limits := &mockAlertmanagerLimits{blockCIDRNetworks: mustParseCIDRs("127.0.0.2/32")}
+			blocked := prometheus.NewCounter(prometheus.CounterOpts{})
+
+			u, err := url.Parse(testData.url)
+			require.NoError(t, err)
+
+			n := &firewallNotifier{
+				upstream: &httpNotifier{client: testData.client, url: testData.url},
+				host:     u.Hostname(),
+				firewall: newReceiversFirewall("user-1", limits),
+				blocked:  blocked,
+			}
+
+			retry, err := n.Notify(context.Background(), &types.Alert{})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "blocked by the receivers firewall")
+			assert.False(t, retry)
+			assert.Equal(t, int64(0), blockedHits.Load())
+			assert.Equal(t, float64(1), testutil.ToFloat64(blocked))
+		})
+	}
+}
+
+func TestRateLimitedNotifier(t *testing.T) {
+	upstream := &mockNotifier{}
+	limits := &mockAlertmanagerLimits{notificationRateLimit: 0.0001, notificationBurstSize: 2}
+	rateLimited := prometheus.NewCounter(prometheus.CounterOpts{})
+
+	n := &rateLimitedNotifier{
+		upstream:    upstream,
+		userID:      "user-1",
+		integration: "webhook",
+		limits:      limits,
+		limiter:     newNotificationRateLimiter("user-1", "webhook", limits),
+		rateLimited: rateLimited,
+	}
+
+	// The burst is allowed, then the notifications are rate limited.
+	for i := 0; i < 2; i++ {
+		_, err := n.Notify(context.Background(), &types.Alert{})
+		require.NoError(t, err)
+	}
+
+	retry, err := n.Notify(context.Background(), &types.Alert{})
+	assert.Equal(t, errRateLimited, err)
+	assert.False(t, retry)
+	assert.Equal(t, 2, upstream.calls)
+	assert.Equal(t, float64(1), testutil.ToFloat64(rateLimited))
+
+	// Disabling the rate limit is applied without recreating the notifier.
+	limits.notificationRateLimit = 0
+	_, err = n.Notify(context.Background(), &types.Alert{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, upstream.calls)
+}
+
+type mockNotifier struct {
+	calls int
+}
+
+func (m *mockNotifier) Notify(_ context.Context, _ ...*types.Alert) (bool, error) {
+	m.calls++
+	return false, nil
+}
+
+var _ notify.Notifier = &mockNotifier{}
+
+// httpNotifier is a notifier sending a request to the URL, like the HTTP based integrations.
+type httpNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (n *httpNotifier) Notify(ctx context.Context, _ ...*types.Alert) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, nil)
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	return false, resp.Body.Close()
+}
@@ -0,0 +1,265 @@
+package alertmanager
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/cluster"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	defaultSettleReadTimeout = 15 * time.Second
+
+	// The number of partial states which can be queued for replication before
+	// the new ones get dropped.
+	stateReplicationBufferSize = 1024
+
+	syncFromReplica = "from-replica"
+	syncEmpty       = "empty"
+	syncFailed      = "failed"
+)
+
+// Replicator is used to exchange the state of a tenant's Alertmanager with
+// the other replicas of the same tenant.
+type Replicator interface {
+	// ReplicateStateForUser sends a partial state to the other replicas of the user.
+	ReplicateStateForUser(ctx context.Context, userID string, part *clusterpb.Part) error
+
+	// ReadFullStateForUser reads the full state of the user from the other replicas.
+	ReadFullStateForUser(ctx context.Context, userID string) ([]*clusterpb.FullState, error)
+
+	// GetPositionForUser returns the position this Alertmanager instance holds
+	// among the replicas of the user.
+	GetPositionForUser(userID string) int
+}
+
+// state holds the Alertmanager state (silences and notification log) of a
+// tenant, keeping it in sync with the other replicas of the same tenant.
+type state struct {
+	services.Service
+
+	userID     string
+	replicator Replicator
+	logger     log.Logger
+
+	settleReadTimeout time.Duration
+
+	mtx    sync.Mutex
+	states map[string]cluster.State
+
+	msgc   chan *clusterpb.Part
+	readyc chan struct{}
+
+	partialStateMergesTotal  prometheus.Counter
+	partialStateMergesFailed prometheus.Counter
+	stateReplicationTotal    prometheus.Counter
+	stateReplicationFailed   prometheus.Counter
+	fetchReplicaStateTotal   prometheus.Counter
+	fetchReplicaStateFailed  prometheus.Counter
+	initialSyncCompleted     *prometheus.CounterVec
+}
+
+// newReplicatedStates creates a new state for the given user, replicated via the replicator.
+func newReplicatedStates(userID string, replicator Replicator, logger log.Logger, r prometheus.Registerer) *state {
+	s := &state{
+		userID:            userID,
+		replicator:        replicator,
+		logger:            logger,
+		settleReadTimeout: defaultSettleReadTimeout,
+		states:            make(map[string]cluster.State, 2), // we use two, one for the notifications and one for silences.
+		msgc:              make(chan *clusterpb.Part, stateReplicationBufferSize),
+		readyc:            make(chan struct{}),
+		partialStateMergesTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_partial_state_merges_total",
+			Help: "Number of times we have received a partial state to merge for a key.",
+		}),
+		partialStateMergesFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_partial_state_merges_failed_total",
+			Help: "Number of times we have failed to merge a partial state received for a key.",
+		}),
+		stateReplicationTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_replication_total",
+			Help: "Number of times we have tried to replicate a state to other alertmanagers.",
+		}),
+		stateReplicationFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_replication_failed_total",
+			Help: "Number of times we have failed to replicate a state to other alertmanagers.",
+		}),
+		fetchReplicaStateTotal: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_fetch_replica_state_total",
+			Help: "Number of times we have tried to read and merge the full state from another replica.",
+		}),
+		fetchReplicaStateFailed: promauto.With(r).NewCounter(prometheus.CounterOpts{
+			Name: "alertmanager_state_fetch_replica_state_failed_total",
+			Help: "Number of times we have failed to read and merge the full state from another replica.",
+		}),
+		initialSyncCompleted: promauto.With(r).NewCounterVec(prometheus.CounterOpts{
+			Name: "alertmanager_state_initial_sync_completed_total",
+			Help: "Number of times we have completed syncing initial state for each possible outcome.",
+		}, []string{"outcome"}),
+	}
+
+	s.Service = services.NewBasicService(nil, s.running, nil)
+	return s
+}
+
+// AddState adds a new state that will be replicated. It returns a channel
+// to which the client can broadcast the changes of the state to be sent.
+func (s *state) AddState(key string, cs cluster.State) *stateChannel {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.states[key] = cs
+
+	return &stateChannel{s: s, key: key}
+}
+
+// MergePartialState merges a received partial message with an internal state.
+func (s *state) MergePartialState(p *clusterpb.Part) error {
+	s.partialStateMergesTotal.Inc()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	st, ok := s.states[p.Key]
+	if !ok {
+		s.partialStateMergesFailed.Inc()
+		return fmt.Errorf("key not found while merging")
+	}
+
+	if err := st.Merge(p.Data); err != nil {
+		s.partialStateMergesFailed.Inc()
+		return err
+	}
+
+	return nil
+}
+
+// GetFullState returns the full internal state.
+func (s *state) GetFullState() (*clusterpb.FullState, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	all := &clusterpb.FullState{
+		Parts: make([]clusterpb.Part, 0, len(s.states)),
+	}
+
+	for key, st := range s.states {
+		b, err := st.MarshalBinary()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode state for key: %v", key)
+		}
+		all.Parts = append(all.Parts, clusterpb.Part{Key: key, Data: b})
+	}
+
+	return all, nil
+}
+
+// Position returns the position of this Alertmanager among the replicas of the
+// tenant, used to determine how long to wait before sending a notification.
+func (s *state) Position() int {
+	return s.replicator.GetPositionForUser(s.userID)
+}
+
+// WaitReady blocks until the state has been settled from the other replicas,
+// or the context is done.
+func (s *state) WaitReady(ctx context.Context) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-s.readyc:
+		return nil
+	}
+}
+
+func (s *state) running(ctx context.Context) error {
+	s.settle(ctx)
+	close(s.readyc)
+
+	for {
+		select {
+		case p := <-s.msgc:
+			s.stateReplicationTotal.Inc()
+			if err := s.replicator.ReplicateStateForUser(ctx, s.userID, p); err != nil {
+				s.stateReplicationFailed.Inc()
+				level.Error(s.logger).Log("msg", "failed to replicate state to other alertmanagers", "key", p.Key, "err", err)
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// settle reads the full state from the other replicas and merges it into the
+// local state. A failure isn't fatal: the state will eventually converge with
+// the partial states replicated afterwards.
+func (s *state) settle(ctx context.Context) {
+	readCtx, cancel := context.WithTimeout(ctx, s.settleReadTimeout)
+	defer cancel()
+
+	s.fetchReplicaStateTotal.Inc()
+	fullStates, err := s.replicator.ReadFullStateForUser(readCtx, s.userID)
+	if err != nil {
+		s.fetchReplicaStateFailed.Inc()
+		s.initialSyncCompleted.WithLabelValues(syncFailed).Inc()
+		level.Warn(s.logger).Log("msg", "failed to read the state from the other replicas, proceeding without it", "err", err)
+		return
+	}
+
+	if len(fullStates) == 0 {
+		s.initialSyncCompleted.WithLabelValues(syncEmpty).Inc()
+		return
+	}
+
+	for _, fs := range fullStates {
+		for i := range fs.Parts {
+			if err := s.MergePartialState(&fs.Parts[i]); err != nil {
+				level.Warn(s.logger).Log("msg", "failed to merge the state read from another replica", "key", fs.Parts[i].Key, "err", err)
+			}
+		}
+	}
+
+	s.initialSyncCompleted.WithLabelValues(syncFromReplica).Inc()
+}
+
+// stateChannel allows a state to broadcast its changes to the other replicas.
+type stateChannel struct {
+	s   *state
+	key string
+}
+
+// Broadcast queues a partial state to be replicated. It never blocks: if the
+// replication queue is full, the partial state is dropped.
+func (c *stateChannel) Broadcast(b []byte) {
+	select {
+	case c.s.msgc <- &clusterpb.Part{Key: c.key, Data: b}:
+	default:
+		c.s.stateReplicationFailed.Inc()
+		level.Warn(c.s.logger).Log("msg", "state replication queue is full, dropping partial state", "key", c.key)
+	}
+}
+
+// stateReadyStage is a notify.Stage waiting for the state to be settled before
+// letting the notifications through.
+type stateReadyStage struct {
+	state *state
+}
+
+// Exec implements notify.Stage.
+func (s *stateReadyStage) Exec(ctx context.Context, l log.Logger, alerts ...*types.Alert) (context.Context, []*types.Alert, error) {
+	if err := s.state.WaitReady(ctx); err != nil {
+		return ctx, nil, err
+	}
+	return ctx, alerts, nil
+}
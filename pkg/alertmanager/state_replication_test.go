@@ -0,0 +1,170 @@
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+type fakeState struct {
+	binary []byte
+	merges [][]byte
+}
+
+func (s *fakeState) MarshalBinary() ([]byte, error) {
+	return s.binary, nil
+}
+
+func (s *fakeState) Merge(data []byte) error {
+	s.merges = append(s.merges, data)
+	return nil
+}
+
+type fakeReplicator struct {
+	mtx        sync.Mutex
+	results    map[string]*clusterpb.Part
+	readStates []*clusterpb.FullState
+	readErr    error
+}
+
+func newFakeReplicator() *fakeReplicator {
+	return &fakeReplicator{
+		results: make(map[string]*clusterpb.Part),
+	}
+}
+
+func (f *fakeReplicator) ReplicateStateForUser(_ context.Context, userID string, p *clusterpb.Part) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.results[userID] = p
+	return nil
+}
+
+func (f *fakeReplicator) ReadFullStateForUser(_ context.Context, _ string) ([]*clusterpb.FullState, error) {
+	return f.readStates, f.readErr
+}
+
+func (f *fakeReplicator) GetPositionForUser(_ string) int {
+	return 0
+}
+
+func (f *fakeReplicator) getResult(userID string) *clusterpb.Part {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return f.results[userID]
+}
+
+func TestStateReplication(t *testing.T) {
+	ctx := context.Background()
+
+	reg := prometheus.NewPedanticRegistry()
+	replicator := newFakeReplicator()
+	s := newReplicatedStates("user-1", replicator, log.NewNopLogger(), reg)
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, s))
+	})
+
+	require.NoError(t, s.WaitReady(ctx))
+
+	ch := s.AddState("nflog", &fakeState{})
+	ch.Broadcast([]byte("entry"))
+
+	test.Poll(t, time.Second, true, func() interface{} {
+		p := replicator.getResult("user-1")
+		return p != nil && p.Key == "nflog" && string(p.Data) == "entry"
+	})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, bytes.NewBufferString(`
+		# HELP alertmanager_state_replication_total Number of times we have tried to replicate a state to other alertmanagers.
+		# TYPE alertmanager_state_replication_total counter
+		alertmanager_state_replication_total 1
+		# HELP alertmanager_state_replication_failed_total Number of times we have failed to replicate a state to other alertmanagers.
+		# TYPE alertmanager_state_replication_failed_total counter
+		alertmanager_state_replication_failed_total 0
+		# HELP alertmanager_state_initial_sync_completed_total Number of times we have completed syncing initial state for each possible outcome.
+		# TYPE alertmanager_state_initial_sync_completed_total counter
+		alertmanager_state_initial_sync_completed_total{outcome="empty"} 1
+	`), "alertmanager_state_replication_total", "alertmanager_state_replication_failed_total", "alertmanager_state_initial_sync_completed_total"))
+}
+
+func TestStateReplication_Settle(t *testing.T) {
+	tests := map[string]struct {
+		readStates      []*clusterpb.FullState
+		readErr         error
+		expectedMerges  int
+		expectedOutcome string
+	}{
+		"should merge the full states read from the other replicas": {
+			readStates: []*clusterpb.FullState{
+				{Parts: []clusterpb.Part{{Key: "nflog", Data: []byte("a")}}},
+				{Parts: []clusterpb.Part{{Key: "nflog", Data: []byte("b")}, {Key: "unknown", Data: []byte("c")}}},
+			},
+			expectedMerges:  2,
+			expectedOutcome: syncFromReplica,
+		},
+		"should become ready when there are no other replicas": {
+			expectedMerges:  0,
+			expectedOutcome: syncEmpty,
+		},
+		"should become ready when reading from the other replicas fails": {
+			readErr:         errors.New("read failed"),
+			expectedMerges:  0,
+			expectedOutcome: syncFailed,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := context.Background()
+
+			reg := prometheus.NewPedanticRegistry()
+			replicator := newFakeReplicator()
+			replicator.readStates = testData.readStates
+			replicator.readErr = testData.readErr
+
+			s := newReplicatedStates("user-1", replicator, log.NewNopLogger(), reg)
+			nflog := &fakeState{}
+			s.AddState("nflog", nflog)
+
+			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+			t.Cleanup(func() {
+				require.NoError(t, services.StopAndAwaitTerminated(ctx, s))
+			})
+
+			require.NoError(t, s.WaitReady(ctx))
+			assert.Len(t, nflog.merges, testData.expectedMerges)
+			assert.Equal(t, float64(1), testutil.ToFloat64(s.initialSyncCompleted.WithLabelValues(testData.expectedOutcome)))
+		})
+	}
+}
+
+func TestStateReplication_GetFullState(t *testing.T) {
+	s := newReplicatedStates("user-1", newFakeReplicator(), log.NewNopLogger(), nil)
+	s.AddState("nflog", &fakeState{binary: []byte("nflog-state")})
+	s.AddState("silences", &fakeState{binary: []byte("silences-state")})
+
+	all, err := s.GetFullState()
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []clusterpb.Part{
+		{Key: "nflog", Data: []byte("nflog-state")},
+		{Key: "silences", Data: []byte("silences-state")},
+	}, all.Parts)
+
+	// Merging a partial state of an unknown key fails.
+	require.Error(t, s.MergePartialState(&clusterpb.Part{Key: "unknown"}))
+}
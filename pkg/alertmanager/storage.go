@@ -5,9 +5,12 @@ import (
 	"flag"
 	"fmt"
 
+	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alerts/bucketclient"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts/configdb"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts/local"
 	"github.com/cortexproject/cortex/pkg/alertmanager/alerts/objectclient"
@@ -15,6 +18,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/chunk/aws"
 	"github.com/cortexproject/cortex/pkg/chunk/gcp"
 	"github.com/cortexproject/cortex/pkg/configs/client"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
 )
 
 // AlertStore stores and configures users rule configs
@@ -33,16 +37,20 @@ type AlertStoreConfig struct {
 
 	GCS gcp.GCSConfig `yaml:"gcs"`
 	S3  aws.S3Config  `yaml:"s3"`
+
+	// Bucket config, shared with the blocks storage.
+	Bucket bucket.Config `yaml:"bucket"`
 }
 
 // RegisterFlags registers flags.
 func (cfg *AlertStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.Local.RegisterFlags(f)
 	cfg.ConfigDB.RegisterFlagsWithPrefix("alertmanager.", f)
-	f.StringVar(&cfg.Type, "alertmanager.storage.type", "configdb", "Type of backend to use to store alertmanager configs. Supported values are: \"configdb\", \"gcs\", \"s3\", \"local\".")
+	f.StringVar(&cfg.Type, "alertmanager.storage.type", "configdb", "Type of backend to use to store alertmanager configs. Supported values are: \"configdb\", \"gcs\", \"s3\", \"local\", \"bucket\". The bucket storage supports the same backends of the blocks storage, configured via the -alertmanager.storage.bucket.* flags.")
 
 	cfg.GCS.RegisterFlagsWithPrefix("alertmanager.storage.", f)
 	cfg.S3.RegisterFlagsWithPrefix("alertmanager.storage.", f)
+	cfg.Bucket.RegisterFlagsWithPrefix("alertmanager.storage.bucket.", f)
 }
 
 // Validate config and returns error on failure
@@ -50,11 +58,16 @@ func (cfg *AlertStoreConfig) Validate() error {
 	if err := cfg.S3.Validate(); err != nil {
 		return errors.Wrap(err, "invalid S3 Storage config")
 	}
+	if cfg.Type == "bucket" {
+		if err := cfg.Bucket.Validate(); err != nil {
+			return errors.Wrap(err, "invalid bucket config")
+		}
+	}
 	return nil
 }
 
 // NewAlertStore returns a new rule storage backend poller and store
-func NewAlertStore(cfg AlertStoreConfig) (AlertStore, error) {
+func NewAlertStore(cfg AlertStoreConfig, logger log.Logger, reg prometheus.Registerer) (AlertStore, error) {
 	switch cfg.Type {
 	case "configdb":
 		c, err := client.New(cfg.ConfigDB)
@@ -68,8 +81,14 @@ func NewAlertStore(cfg AlertStoreConfig) (AlertStore, error) {
 		return newObjAlertStore(gcp.NewGCSObjectClient(context.Background(), cfg.GCS))
 	case "s3":
 		return newObjAlertStore(aws.NewS3ObjectClient(cfg.S3))
+	case "bucket":
+		bucketClient, err := bucket.NewClient(context.Background(), cfg.Bucket, "alertmanager-storage", logger, reg)
+		if err != nil {
+			return nil, err
+		}
+		return bucketclient.NewBucketAlertStore(bucketClient, logger), nil
 	default:
-		return nil, fmt.Errorf("unrecognized alertmanager storage backend %v, choose one of: azure, configdb, gcs, local, s3", cfg.Type)
+		return nil, fmt.Errorf("unrecognized alertmanager storage backend %v, choose one of: bucket, configdb, gcs, local, s3", cfg.Type)
 	}
 }
 
@@ -17,6 +17,7 @@ import (
 	"github.com/weaveworks/common/server"
 
 	"github.com/cortexproject/cortex/pkg/alertmanager"
+	"github.com/cortexproject/cortex/pkg/alertmanager/alertmanagerpb"
 	"github.com/cortexproject/cortex/pkg/chunk/purger"
 	"github.com/cortexproject/cortex/pkg/compactor"
 	"github.com/cortexproject/cortex/pkg/distributor"
@@ -25,6 +26,7 @@ import (
 	frontendv2 "github.com/cortexproject/cortex/pkg/frontend/v2"
 	"github.com/cortexproject/cortex/pkg/frontend/v2/frontendv2pb"
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/limitsapi"
 	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ruler"
@@ -33,6 +35,8 @@ import (
 	"github.com/cortexproject/cortex/pkg/storegateway"
 	"github.com/cortexproject/cortex/pkg/storegateway/storegatewaypb"
 	"github.com/cortexproject/cortex/pkg/util/push"
+	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 type Config struct {
@@ -142,9 +146,13 @@ func (a *API) RegisterRoutesWithPrefix(prefix string, handler http.Handler, auth
 // RegisterAlertmanager registers endpoints associated with the alertmanager. It will only
 // serve endpoints using the legacy http-prefix if it is not run as a single binary.
 func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, target, apiEnabled bool) {
-	a.indexPage.AddLink(SectionAdminEndpoints, "/multitenant_alertmanager/status", "Alertmanager Status")
+	alertmanagerpb.RegisterAlertmanagerServer(a.server.GRPC, am)
+
+	a.indexPage.AddLink(SectionAlertmanager, "/multitenant_alertmanager/status", "Alertmanager Status")
+	a.indexPage.AddLink(SectionAlertmanager, "/multitenant_alertmanager/ring", "Alertmanager Ring Status")
 	// Ensure this route is registered before the prefixed AM route
 	a.RegisterRoute("/multitenant_alertmanager/status", am.GetStatusHandler(), false, "GET")
+	a.RegisterRoute("/multitenant_alertmanager/ring", http.HandlerFunc(am.RingHandler), false, "GET", "POST")
 
 	// UI components lead to a large number of routes to support, utilize a path prefix instead
 	a.RegisterRoutesWithPrefix(a.cfg.AlertmanagerHTTPPrefix, am, true)
@@ -166,28 +174,46 @@ func (a *API) RegisterAlertmanager(am *alertmanager.MultitenantAlertmanager, tar
 }
 
 // RegisterAPI registers the standard endpoints associated with a running Cortex.
-func (a *API) RegisterAPI(httpPathPrefix string, cfg interface{}) {
-	a.indexPage.AddLink(SectionAdminEndpoints, "/config", "Current Config")
+func (a *API) RegisterAPI(httpPathPrefix string, actualCfg interface{}, defaultCfg interface{}) {
+	a.indexPage.AddLink(SectionCortex, "/config", "Current Config (including the default values)")
+	a.indexPage.AddLink(SectionCortex, "/config?mode=diff", "Current Config (show only values that differ from the defaults)")
+	a.indexPage.AddLink(SectionCortex, "/config?mode=defaults", "Default Config")
 
-	a.RegisterRoute("/config", configHandler(cfg), false, "GET")
+	a.RegisterRoute("/config", configHandler(actualCfg, defaultCfg), false, "GET")
 	a.RegisterRoute("/", indexHandler(httpPathPrefix, a.indexPage), false, "GET")
 	a.RegisterRoute("/debug/fgprof", fgprof.Handler(), false, "GET")
 }
 
+// RegisterRuntimeConfig registers the endpoints associated with the runtime configuration.
+func (a *API) RegisterRuntimeConfig(m *runtimeconfig.Manager) {
+	a.indexPage.AddLink(SectionRuntimeConfig, "/runtime_config", "Current Runtime Config")
+	a.indexPage.AddLink(SectionRuntimeConfig, "/runtime_config?mode=diff", "Runtime Config Changes of the Last Reload")
+	a.indexPage.AddLink(SectionRuntimeConfig, "/runtime_config?mode=history", "Runtime Config Reloads History")
+
+	a.RegisterRoute("/runtime_config", m, false, "GET")
+	a.RegisterRoute("/runtime_config/validate", http.HandlerFunc(m.ValidateHandler), false, "POST")
+}
+
+// RegisterTenantLimits registers the endpoint serving the limits applied to a tenant.
+func (a *API) RegisterTenantLimits(overrides *validation.Overrides, defaults validation.Limits) {
+	a.RegisterRoute("/runtime_config/tenants/{tenant}", tenantLimitsHandler(overrides, defaults), false, "GET")
+}
+
 // RegisterDistributor registers the endpoints associated with the distributor.
-func (a *API) RegisterDistributor(d *distributor.Distributor, pushConfig distributor.Config) {
+func (a *API) RegisterDistributor(d *distributor.Distributor, pushConfig distributor.Config, limits *validation.Overrides) {
 	a.RegisterRoute("/api/v1/push", push.Handler(pushConfig, a.sourceIPs, d.Push), true, "POST")
+	a.RegisterRoute("/otlp/v1/metrics", push.OTLPHandler(pushConfig, a.sourceIPs, limits, d.Push), true, "POST")
 
-	a.indexPage.AddLink(SectionAdminEndpoints, "/distributor/all_user_stats", "Usage Statistics")
-	a.indexPage.AddLink(SectionAdminEndpoints, "/distributor/ha_tracker", "HA Tracking Status")
+	a.indexPage.AddLink(SectionDistributor, "/distributor/all_user_stats", "Usage Statistics")
+	a.indexPage.AddLink(SectionDistributor, "/distributor/ha_tracker", "HA Tracking Status")
 
 	a.RegisterRoute("/distributor/all_user_stats", http.HandlerFunc(d.AllUserStatsHandler), false, "GET")
-	a.RegisterRoute("/distributor/ha_tracker", d.HATracker, false, "GET")
+	a.RegisterRoute("/distributor/ha_tracker", d.HATracker, false, "GET", "POST")
 
 	// Legacy Routes
 	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/push", push.Handler(pushConfig, a.sourceIPs, d.Push), true, "POST")
 	a.RegisterRoute("/all_user_stats", http.HandlerFunc(d.AllUserStatsHandler), false, "GET")
-	a.RegisterRoute("/ha-tracker", d.HATracker, false, "GET")
+	a.RegisterRoute("/ha-tracker", d.HATracker, false, "GET", "POST")
 }
 
 // Ingester is defined as an interface to allow for alternative implementations
@@ -196,6 +222,8 @@ type Ingester interface {
 	client.IngesterServer
 	FlushHandler(http.ResponseWriter, *http.Request)
 	ShutdownHandler(http.ResponseWriter, *http.Request)
+	PrepareShutdownHandler(http.ResponseWriter, *http.Request)
+	TSDBStatsHandler(http.ResponseWriter, *http.Request)
 	Push(context.Context, *client.WriteRequest) (*client.WriteResponse, error)
 }
 
@@ -203,10 +231,14 @@ type Ingester interface {
 func (a *API) RegisterIngester(i Ingester, pushConfig distributor.Config) {
 	client.RegisterIngesterServer(a.server.GRPC, i)
 
-	a.indexPage.AddLink(SectionDangerous, "/ingester/flush", "Trigger a Flush of data from Ingester to storage")
-	a.indexPage.AddLink(SectionDangerous, "/ingester/shutdown", "Trigger Ingester Shutdown (Dangerous)")
+	a.indexPage.AddLink(SectionIngester, "/ingester/tsdb_stats", "TSDB Stats of the Tenants")
+	a.indexPage.AddLink(SectionIngester, "/ingester/prepare-shutdown", "Check whether the Ingester has been prepared for shutdown")
+	a.indexPage.AddDangerousLink(SectionIngester, "/ingester/flush", "Trigger a Flush of data from Ingester to storage")
+	a.indexPage.AddDangerousLink(SectionIngester, "/ingester/shutdown", "Trigger Ingester Shutdown")
+	a.RegisterRoute("/ingester/tsdb_stats", http.HandlerFunc(i.TSDBStatsHandler), false, "GET")
 	a.RegisterRoute("/ingester/flush", http.HandlerFunc(i.FlushHandler), false, "GET", "POST")
 	a.RegisterRoute("/ingester/shutdown", http.HandlerFunc(i.ShutdownHandler), false, "GET", "POST")
+	a.RegisterRoute("/ingester/prepare-shutdown", http.HandlerFunc(i.PrepareShutdownHandler), false, "GET", "POST", "DELETE")
 	a.RegisterRoute("/ingester/push", push.Handler(pushConfig, a.sourceIPs, i.Push), true, "POST") // For testing and debugging.
 
 	// Legacy Routes
@@ -231,14 +263,35 @@ func (a *API) RegisterChunksPurger(store *purger.DeleteStore, deleteRequestCance
 	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/api/v1/admin/tsdb/cancel_delete_request", http.HandlerFunc(deleteRequestHandler.CancelDeleteRequestHandler), true, "PUT", "POST")
 }
 
-func (a *API) RegisterBlocksPurger(api *purger.BlocksPurgerAPI) {
+// RegisterLimitsAPI registers the endpoints to read and update the per-tenant limits.
+func (a *API) RegisterLimitsAPI(l *limitsapi.API) {
+	a.RegisterRoute("/api/v1/admin/limits", http.HandlerFunc(l.ListTenantsHandler), true, "GET")
+	a.RegisterRoute("/api/v1/admin/limits/{tenant}", http.HandlerFunc(l.GetLimitsHandler), true, "GET")
+	a.RegisterRoute("/api/v1/admin/limits/{tenant}", http.HandlerFunc(l.SetLimitsHandler), true, "PUT")
+	a.RegisterRoute("/api/v1/admin/limits/{tenant}", http.HandlerFunc(l.DeleteLimitsHandler), true, "DELETE")
+}
+
+func (a *API) RegisterBlocksPurger(api *purger.BlocksPurgerAPI, seriesDeletionEnabled bool) {
 	a.RegisterRoute("/purger/delete_tenant", http.HandlerFunc(api.DeleteTenant), true, "POST")
 	a.RegisterRoute("/purger/delete_tenant_status", http.HandlerFunc(api.DeleteTenantStatus), true, "GET")
+
+	if !seriesDeletionEnabled {
+		return
+	}
+
+	a.RegisterRoute(a.cfg.PrometheusHTTPPrefix+"/api/v1/admin/tsdb/delete_series", http.HandlerFunc(api.AddDeleteRequestHandler), true, "PUT", "POST")
+	a.RegisterRoute(a.cfg.PrometheusHTTPPrefix+"/api/v1/admin/tsdb/delete_series", http.HandlerFunc(api.GetAllDeleteRequestsHandler), true, "GET")
+	a.RegisterRoute(a.cfg.PrometheusHTTPPrefix+"/api/v1/admin/tsdb/cancel_delete_request", http.HandlerFunc(api.CancelDeleteRequestHandler), true, "PUT", "POST")
+
+	// Legacy Routes
+	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/api/v1/admin/tsdb/delete_series", http.HandlerFunc(api.AddDeleteRequestHandler), true, "PUT", "POST")
+	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/api/v1/admin/tsdb/delete_series", http.HandlerFunc(api.GetAllDeleteRequestsHandler), true, "GET")
+	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/api/v1/admin/tsdb/cancel_delete_request", http.HandlerFunc(api.CancelDeleteRequestHandler), true, "PUT", "POST")
 }
 
 // RegisterRuler registers routes associated with the Ruler service.
 func (a *API) RegisterRuler(r *ruler.Ruler) {
-	a.indexPage.AddLink(SectionAdminEndpoints, "/ruler/ring", "Ruler Ring Status")
+	a.indexPage.AddLink(SectionRuler, "/ruler/ring", "Ruler Ring Status")
 	a.RegisterRoute("/ruler/ring", r, false, "GET", "POST")
 
 	// Legacy Ring Route
@@ -261,6 +314,11 @@ func (a *API) RegisterRulerAPI(r *ruler.API) {
 	a.RegisterRoute("/api/v1/rules/{namespace}/{groupName}", http.HandlerFunc(r.DeleteRuleGroup), true, "DELETE")
 	a.RegisterRoute("/api/v1/rules/{namespace}", http.HandlerFunc(r.DeleteNamespace), true, "DELETE")
 
+	// Rule groups backup and restore, across all the tenants.
+	a.indexPage.AddLink(SectionRuler, "/ruler/rule_groups/export", "Export the Rule Groups of all Tenants")
+	a.RegisterRoute("/ruler/rule_groups/export", http.HandlerFunc(r.ExportRuleGroups), false, "GET")
+	a.RegisterRoute("/ruler/rule_groups/import", http.HandlerFunc(r.ImportRuleGroups), false, "POST")
+
 	// Legacy Prometheus Rule API Routes
 	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/api/v1/rules", http.HandlerFunc(r.PrometheusRules), true, "GET")
 	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/api/v1/alerts", http.HandlerFunc(r.PrometheusAlerts), true, "GET")
@@ -276,25 +334,42 @@ func (a *API) RegisterRulerAPI(r *ruler.API) {
 
 // RegisterRing registers the ring UI page associated with the distributor for writes.
 func (a *API) RegisterRing(r *ring.Ring) {
-	a.indexPage.AddLink(SectionAdminEndpoints, "/ingester/ring", "Ingester Ring Status")
+	a.indexPage.AddLink(SectionIngester, "/ingester/ring", "Ingester Ring Status")
 	a.RegisterRoute("/ingester/ring", r, false, "GET", "POST")
 
 	// Legacy Route
 	a.RegisterRoute("/ring", r, false, "GET", "POST")
 }
 
-// RegisterStoreGateway registers the ring UI page associated with the store-gateway.
+// RegisterMemberlistKV registers the memberlist status page.
+func (a *API) RegisterMemberlistKV(handler http.Handler) {
+	a.indexPage.AddLink(SectionCortex, "/memberlist", "Memberlist Status")
+	a.RegisterRoute("/memberlist", handler, false, "GET")
+}
+
+// RegisterStoreGateway registers the ring UI page and the tenants and blocks pages associated with the store-gateway.
 func (a *API) RegisterStoreGateway(s *storegateway.StoreGateway) {
 	storegatewaypb.RegisterStoreGatewayServer(a.server.GRPC, s)
 
-	a.indexPage.AddLink(SectionAdminEndpoints, "/store-gateway/ring", "Store Gateway Ring")
+	a.indexPage.AddLink(SectionStoreGateway, "/store-gateway/ring", "Store Gateway Ring Status")
 	a.RegisterRoute("/store-gateway/ring", http.HandlerFunc(s.RingHandler), false, "GET", "POST")
+
+	a.indexPage.AddLink(SectionStoreGateway, "/store-gateway/tenants", "Store Gateway Tenants and Blocks")
+	a.RegisterRoute("/store-gateway/tenants", http.HandlerFunc(s.TenantsHandler), false, "GET")
+	a.RegisterRoute("/store-gateway/tenant/{tenant}/blocks", http.HandlerFunc(s.TenantBlocksHandler), false, "GET")
 }
 
-// RegisterCompactor registers the ring UI page associated with the compactor.
+// RegisterCompactor registers the ring UI page and the compaction plan page associated with the compactor.
 func (a *API) RegisterCompactor(c *compactor.Compactor) {
-	a.indexPage.AddLink(SectionAdminEndpoints, "/compactor/ring", "Compactor Ring Status")
+	a.indexPage.AddLink(SectionCompactor, "/compactor/ring", "Compactor Ring Status")
 	a.RegisterRoute("/compactor/ring", http.HandlerFunc(c.RingHandler), false, "GET", "POST")
+
+	a.indexPage.AddLink(SectionCompactor, "/compactor/compaction_plan", "Compactor Compaction Plan")
+	a.RegisterRoute("/compactor/compaction_plan", http.HandlerFunc(c.CompactionPlanHandler), false, "GET")
+
+	a.RegisterRoute("/api/v1/upload/block/{block}/start", http.HandlerFunc(c.StartBlockUpload), true, "POST")
+	a.RegisterRoute("/api/v1/upload/block/{block}/files", http.HandlerFunc(c.UploadBlockFile), true, "POST")
+	a.RegisterRoute("/api/v1/upload/block/{block}/finish", http.HandlerFunc(c.FinishBlockUpload), true, "POST")
 }
 
 // RegisterQueryable registers the the default routes associated with the querier
@@ -307,8 +382,13 @@ func (a *API) RegisterQueryable(
 	a.RegisterRoute("/api/v1/user_stats", http.HandlerFunc(distributor.UserStatsHandler), true, "GET")
 	a.RegisterRoute("/api/v1/chunks", querier.ChunksHandler(queryable), true, "GET")
 
+	a.RegisterRoute("/api/v1/cardinality/label_names", http.HandlerFunc(distributor.LabelNamesCardinalityHandler), true, "GET", "POST")
+	a.RegisterRoute("/api/v1/cardinality/label_values", http.HandlerFunc(distributor.LabelValuesCardinalityHandler), true, "GET", "POST")
+
 	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/user_stats", http.HandlerFunc(distributor.UserStatsHandler), true, "GET")
 	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/chunks", querier.ChunksHandler(queryable), true, "GET")
+	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/cardinality/label_names", http.HandlerFunc(distributor.LabelNamesCardinalityHandler), true, "GET", "POST")
+	a.RegisterRoute(a.cfg.LegacyHTTPPrefix+"/cardinality/label_values", http.HandlerFunc(distributor.LabelValuesCardinalityHandler), true, "GET", "POST")
 }
 
 // RegisterQueryAPI registers the Prometheus API routes with the provided handler.
@@ -351,10 +431,12 @@ func (a *API) RegisterQueryScheduler(f *scheduler.Scheduler) {
 	schedulerpb.RegisterSchedulerForQuerierServer(a.server.GRPC, f)
 }
 
-// RegisterServiceMapHandler registers the Cortex structs service handler
+// RegisterServiceMapHandler registers the Cortex structs service handler, and
+// the status of the running modules displayed in the index page.
 // TODO: Refactor this code to be accomplished using the services.ServiceManager
 // or a future module manager #2291
-func (a *API) RegisterServiceMapHandler(handler http.Handler) {
-	a.indexPage.AddLink(SectionAdminEndpoints, "/services", "Service Status")
+func (a *API) RegisterServiceMapHandler(handler http.Handler, modulesStatus func() []ModuleStatus) {
+	a.indexPage.AddLink(SectionCortex, "/services", "Service Status")
+	a.indexPage.SetModulesStatus(modulesStatus)
 	a.RegisterRoute("/services", handler, false, "GET")
 }
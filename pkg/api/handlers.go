@@ -2,10 +2,13 @@ package api
 
 import (
 	"context"
+	"fmt"
 	"html/template"
 	"net/http"
 	"path"
+	"reflect"
 	"regexp"
+	"sort"
 	"sync"
 
 	"github.com/go-kit/kit/log"
@@ -19,7 +22,6 @@ import (
 	dto "github.com/prometheus/client_model/go"
 	"github.com/prometheus/common/route"
 	"github.com/prometheus/prometheus/config"
-	"github.com/prometheus/prometheus/promql"
 	"github.com/prometheus/prometheus/storage"
 	v1 "github.com/prometheus/prometheus/web/api/v1"
 	"github.com/weaveworks/common/instrument"
@@ -30,53 +32,148 @@ import (
 	"github.com/cortexproject/cortex/pkg/distributor"
 	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
+// Sections of the index page, displayed in this order. Each component lists
+// its admin endpoints in its own section.
 const (
-	SectionAdminEndpoints = "Admin Endpoints:"
-	SectionDangerous      = "Dangerous:"
+	SectionCortex        = "Cortex"
+	SectionRuntimeConfig = "Runtime Configuration"
+	SectionDistributor   = "Distributor"
+	SectionIngester      = "Ingester"
+	SectionRuler         = "Ruler"
+	SectionAlertmanager  = "Alertmanager"
+	SectionStoreGateway  = "Store-gateway"
+	SectionCompactor     = "Compactor"
 )
 
+var sectionsOrder = []string{
+	SectionCortex,
+	SectionRuntimeConfig,
+	SectionDistributor,
+	SectionIngester,
+	SectionRuler,
+	SectionAlertmanager,
+	SectionStoreGateway,
+	SectionCompactor,
+}
+
 func newIndexPageContent() *IndexPageContent {
 	return &IndexPageContent{
-		content: map[string]map[string]string{},
+		content: map[string][]IndexPageLink{},
 	}
 }
 
-// IndexPageContent is a map of sections to path -> description.
+// IndexPageLink is a link of the index page.
+type IndexPageLink struct {
+	Path        string
+	Description string
+	Dangerous   bool
+}
+
+// IndexPageSection is a section of the index page, with its links.
+type IndexPageSection struct {
+	Name  string
+	Links []IndexPageLink
+}
+
+// ModuleStatus is the status of a module, displayed in the index page.
+type ModuleStatus struct {
+	Name  string
+	State string
+}
+
+// IndexPageContent is a map of sections to links, in the order they were added,
+// and the status of the running modules.
 type IndexPageContent struct {
-	mu      sync.Mutex
-	content map[string]map[string]string
+	mu            sync.Mutex
+	content       map[string][]IndexPageLink
+	modulesStatus func() []ModuleStatus
 }
 
+// AddLink adds a link to the section. A link with the same path of an existing
+// link of the section replaces it.
 func (pc *IndexPageContent) AddLink(section, path, description string) {
+	pc.addLink(section, IndexPageLink{Path: path, Description: description})
+}
+
+// AddDangerousLink adds a link to the section, marked as dangerous because
+// following it changes the state of the component (e.g. shuts it down).
+func (pc *IndexPageContent) AddDangerousLink(section, path, description string) {
+	pc.addLink(section, IndexPageLink{Path: path, Description: description, Dangerous: true})
+}
+
+func (pc *IndexPageContent) addLink(section string, link IndexPageLink) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	sectionMap := pc.content[section]
-	if sectionMap == nil {
-		sectionMap = make(map[string]string)
-		pc.content[section] = sectionMap
+	links := pc.content[section]
+	for i := range links {
+		if links[i].Path == link.Path {
+			links[i] = link
+			return
+		}
 	}
+	pc.content[section] = append(links, link)
+}
+
+// SetModulesStatus sets the function returning the status of the running modules.
+func (pc *IndexPageContent) SetModulesStatus(f func() []ModuleStatus) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
 
-	sectionMap[path] = description
+	pc.modulesStatus = f
 }
 
-func (pc *IndexPageContent) GetContent() map[string]map[string]string {
+// GetContent returns the sections with at least a link, sorted by sectionsOrder.
+// The unknown sections follow, sorted by name.
+func (pc *IndexPageContent) GetContent() []IndexPageSection {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
-	result := map[string]map[string]string{}
-	for k, v := range pc.content {
-		sm := map[string]string{}
-		for smK, smV := range v {
-			sm[smK] = smV
+	names := make([]string, 0, len(pc.content))
+	for name := range pc.content {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		iPos, jPos := sectionPosition(names[i]), sectionPosition(names[j])
+		if iPos != jPos {
+			return iPos < jPos
 		}
-		result[k] = sm
+		return names[i] < names[j]
+	})
+
+	result := make([]IndexPageSection, 0, len(names))
+	for _, name := range names {
+		links := make([]IndexPageLink, len(pc.content[name]))
+		copy(links, pc.content[name])
+		result = append(result, IndexPageSection{Name: name, Links: links})
 	}
 	return result
 }
 
+// GetModulesStatus returns the status of the running modules, if set.
+func (pc *IndexPageContent) GetModulesStatus() []ModuleStatus {
+	pc.mu.Lock()
+	f := pc.modulesStatus
+	pc.mu.Unlock()
+
+	if f == nil {
+		return nil
+	}
+	return f()
+}
+
+func sectionPosition(name string) int {
+	for i, s := range sectionsOrder {
+		if s == name {
+			return i
+		}
+	}
+	return len(sectionsOrder)
+}
+
 var indexPageTemplate = ` 
 <!DOCTYPE html>
 <html>
@@ -86,11 +183,30 @@ var indexPageTemplate = `
 	</head>
 	<body>
 		<h1>Cortex</h1>
-		{{ range $s, $links := . }}
-		<p>{{ $s }}</p>
+		{{ if .Modules }}
+		<h2>Modules</h2>
+		<table border="1" cellpadding="5" style="border-collapse: collapse">
+			<thead>
+				<tr>
+					<th>Module</th>
+					<th>State</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range .Modules }}
+				<tr>
+					<td>{{ .Name }}</td>
+					<td>{{ .State }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+		{{ end }}
+		{{ range .Sections }}
+		<h2>{{ .Name }}</h2>
 		<ul>
-			{{ range $path, $desc := $links }}
-				<li><a href="{{ AddPathPrefix $path }}">{{ $desc }}</a></li>
+			{{ range .Links }}
+				<li><a href="{{ AddPathPrefix .Path }}">{{ .Description }}</a>{{ if .Dangerous }} <strong>(dangerous)</strong>{{ end }}</li>
 			{{ end }}
 		</ul>
 		{{ end }}
@@ -107,16 +223,40 @@ func indexHandler(httpPathPrefix string, content *IndexPageContent) http.Handler
 	template.Must(templ.Parse(indexPageTemplate))
 
 	return func(w http.ResponseWriter, r *http.Request) {
-		err := templ.Execute(w, content.GetContent())
+		err := templ.Execute(w, struct {
+			Modules  []ModuleStatus
+			Sections []IndexPageSection
+		}{
+			Modules:  content.GetModulesStatus(),
+			Sections: content.GetContent(),
+		})
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		}
 	}
 }
 
-func configHandler(cfg interface{}) http.HandlerFunc {
+func configHandler(actualCfg interface{}, defaultCfg interface{}) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		out, err := yaml.Marshal(cfg)
+		var output interface{}
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "":
+			output = actualCfg
+		case "defaults":
+			output = defaultCfg
+		case "diff":
+			diff, err := getConfigDiff(defaultCfg, actualCfg)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			output = diff
+		default:
+			http.Error(w, fmt.Sprintf("unknown mode %q, supported modes: diff, defaults", mode), http.StatusBadRequest)
+			return
+		}
+
+		out, err := yaml.Marshal(output)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -130,15 +270,93 @@ func configHandler(cfg interface{}) http.HandlerFunc {
 	}
 }
 
+// tenantLimitsHandler serves the limits applied to the tenant in the URL path,
+// resolved from the runtime config (and the limits API, if enabled) and the defaults.
+func tenantLimitsHandler(overrides *validation.Overrides, defaults validation.Limits) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limits := overrides.Limits(mux.Vars(r)["tenant"])
+
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "":
+			util.WriteYAMLResponse(w, limits)
+		case "diff":
+			diff, err := getConfigDiff(defaults, limits)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			util.WriteYAMLResponse(w, diff)
+		default:
+			http.Error(w, fmt.Sprintf("unknown mode %q, supported modes: diff", mode), http.StatusBadRequest)
+		}
+	}
+}
+
+// getConfigDiff returns the values of the actual config which differ from the
+// default config, with the same YAML structure of the config.
+func getConfigDiff(defaultCfg, actualCfg interface{}) (map[interface{}]interface{}, error) {
+	defaultValues, err := yamlMarshalUnmarshal(defaultCfg)
+	if err != nil {
+		return nil, err
+	}
+	actualValues, err := yamlMarshalUnmarshal(actualCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return diffConfigValues(defaultValues, actualValues), nil
+}
+
+func diffConfigValues(defaultValues, actualValues map[interface{}]interface{}) map[interface{}]interface{} {
+	diff := map[interface{}]interface{}{}
+	for key, actualValue := range actualValues {
+		defaultValue, ok := defaultValues[key]
+		if !ok {
+			diff[key] = actualValue
+			continue
+		}
+
+		actualMap, actualIsMap := actualValue.(map[interface{}]interface{})
+		defaultMap, defaultIsMap := defaultValue.(map[interface{}]interface{})
+		if actualIsMap && defaultIsMap {
+			if nested := diffConfigValues(defaultMap, actualMap); len(nested) > 0 {
+				diff[key] = nested
+			}
+			continue
+		}
+
+		if !reflect.DeepEqual(defaultValue, actualValue) {
+			diff[key] = actualValue
+		}
+	}
+	return diff
+}
+
+// yamlMarshalUnmarshal returns the config as a generic map, as serialized in YAML.
+func yamlMarshalUnmarshal(in interface{}) (map[interface{}]interface{}, error) {
+	buf, err := yaml.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	out := map[interface{}]interface{}{}
+	if err := yaml.Unmarshal(buf, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NewQuerierHandler returns a HTTP handler that can be used by the querier service to
 // either register with the frontend worker query processor or with the external HTTP
 // server to fulfill the Prometheus query API.
 func NewQuerierHandler(
 	cfg Config,
+	querierCfg querier.Config,
 	queryable storage.SampleAndChunkQueryable,
-	engine *promql.Engine,
+	engines *querier.Engines,
 	distributor *distributor.Distributor,
 	tombstonesLoader *purger.TombstonesLoader,
+	limits *validation.Overrides,
 	reg prometheus.Registerer,
 	logger log.Logger,
 ) http.Handler {
@@ -170,8 +388,10 @@ func NewQuerierHandler(
 		Help:      "Current number of inflight requests to the querier.",
 	}, []string{"method", "route"})
 
+	// The Prometheus API evaluates the queries not supported by the Cortex handlers below
+	// with the default engine, ignoring the per-tenant engine options.
 	api := v1.NewAPI(
-		engine,
+		engines.Default(),
 		errorTranslateQueryable{queryable}, // Translate errors to errors expected by API.
 		func(context.Context) v1.TargetRetriever { return &querier.DummyTargetRetriever{} },
 		func(context.Context) v1.AlertmanagerRetriever { return &querier.DummyAlertmanagerRetriever{} },
@@ -217,28 +437,48 @@ func NewQuerierHandler(
 	legacyPromRouter := route.New().WithPrefix(legacyPrefix + "/api/v1")
 	api.Register(legacyPromRouter)
 
+	// Instant and range queries are evaluated by Cortex handlers streaming the JSON response (or
+	// sending a protobuf response to the query-frontend), falling back to the Prometheus API for
+	// the requests they don't support.
+	newQueryHandler := func(next http.Handler) http.Handler {
+		return &streamingQueryHandler{engines: engines, queryable: errorTranslateQueryable{queryable}, limits: limits, next: next, logger: logger}
+	}
+	newQueryRangeHandler := func(next http.Handler) http.Handler {
+		return &protobufQueryRangeHandler{
+			engines:   engines,
+			queryable: errorTranslateQueryable{queryable},
+			limits:    limits,
+			next:      &streamingQueryHandler{engines: engines, queryable: errorTranslateQueryable{queryable}, limits: limits, rangeQuery: true, next: next, logger: logger},
+			logger:    logger,
+		}
+	}
+
+	newSeriesHandler := func(next http.Handler) http.Handler {
+		return &seriesHandler{queryable: errorTranslateQueryable{queryable}, limits: limits, next: next, logger: logger}
+	}
+
 	// TODO(gotjosh): This custom handler is temporary until we're able to vendor the changes in:
 	// https://github.com/prometheus/prometheus/pull/7125/files
 	router.Path(prefix + "/api/v1/metadata").Handler(querier.MetadataHandler(distributor))
-	router.Path(prefix + "/api/v1/read").Handler(querier.RemoteReadHandler(queryable))
+	router.Path(prefix + "/api/v1/read").Handler(querier.RemoteReadHandler(queryable, querierCfg.RemoteReadMaxBytesInFrame))
 	router.Path(prefix + "/api/v1/read").Methods("POST").Handler(promRouter)
-	router.Path(prefix+"/api/v1/query").Methods("GET", "POST").Handler(promRouter)
-	router.Path(prefix+"/api/v1/query_range").Methods("GET", "POST").Handler(promRouter)
+	router.Path(prefix+"/api/v1/query").Methods("GET", "POST").Handler(newQueryHandler(promRouter))
+	router.Path(prefix+"/api/v1/query_range").Methods("GET", "POST").Handler(newQueryRangeHandler(promRouter))
 	router.Path(prefix+"/api/v1/labels").Methods("GET", "POST").Handler(promRouter)
 	router.Path(prefix + "/api/v1/label/{name}/values").Methods("GET").Handler(promRouter)
-	router.Path(prefix+"/api/v1/series").Methods("GET", "POST", "DELETE").Handler(promRouter)
+	router.Path(prefix+"/api/v1/series").Methods("GET", "POST", "DELETE").Handler(newSeriesHandler(promRouter))
 	router.Path(prefix + "/api/v1/metadata").Methods("GET").Handler(promRouter)
 
 	// TODO(gotjosh): This custom handler is temporary until we're able to vendor the changes in:
 	// https://github.com/prometheus/prometheus/pull/7125/files
 	router.Path(legacyPrefix + "/api/v1/metadata").Handler(querier.MetadataHandler(distributor))
-	router.Path(legacyPrefix + "/api/v1/read").Handler(querier.RemoteReadHandler(queryable))
+	router.Path(legacyPrefix + "/api/v1/read").Handler(querier.RemoteReadHandler(queryable, querierCfg.RemoteReadMaxBytesInFrame))
 	router.Path(legacyPrefix + "/api/v1/read").Methods("POST").Handler(legacyPromRouter)
-	router.Path(legacyPrefix+"/api/v1/query").Methods("GET", "POST").Handler(legacyPromRouter)
-	router.Path(legacyPrefix+"/api/v1/query_range").Methods("GET", "POST").Handler(legacyPromRouter)
+	router.Path(legacyPrefix+"/api/v1/query").Methods("GET", "POST").Handler(newQueryHandler(legacyPromRouter))
+	router.Path(legacyPrefix+"/api/v1/query_range").Methods("GET", "POST").Handler(newQueryRangeHandler(legacyPromRouter))
 	router.Path(legacyPrefix+"/api/v1/labels").Methods("GET", "POST").Handler(legacyPromRouter)
 	router.Path(legacyPrefix + "/api/v1/label/{name}/values").Methods("GET").Handler(legacyPromRouter)
-	router.Path(legacyPrefix+"/api/v1/series").Methods("GET", "POST", "DELETE").Handler(legacyPromRouter)
+	router.Path(legacyPrefix+"/api/v1/series").Methods("GET", "POST", "DELETE").Handler(newSeriesHandler(legacyPromRouter))
 	router.Path(legacyPrefix + "/api/v1/metadata").Methods("GET").Handler(legacyPromRouter)
 
 	// Add a middleware to extract the trace context and add a header.
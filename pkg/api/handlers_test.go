@@ -4,13 +4,18 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 func TestIndexHandlerPrefix(t *testing.T) {
 	c := newIndexPageContent()
-	c.AddLink(SectionAdminEndpoints, "/ingester/ring", "Ingester Ring")
+	c.AddLink(SectionIngester, "/ingester/ring", "Ingester Ring")
 
 	for _, tc := range []struct {
 		prefix    string
@@ -35,9 +40,26 @@ func TestIndexHandlerPrefix(t *testing.T) {
 
 func TestIndexPageContent(t *testing.T) {
 	c := newIndexPageContent()
-	c.AddLink(SectionAdminEndpoints, "/ingester/ring", "Ingester Ring")
-	c.AddLink(SectionAdminEndpoints, "/store-gateway/ring", "Store Gateway Ring")
-	c.AddLink(SectionDangerous, "/shutdown", "Shutdown")
+	c.AddLink(SectionIngester, "/ingester/ring", "Ingester Ring")
+	c.AddLink(SectionStoreGateway, "/store-gateway/ring", "Store Gateway Ring")
+	c.AddDangerousLink(SectionIngester, "/ingester/shutdown", "Shutdown")
+	c.AddLink(SectionCortex, "/config", "Config")
+	c.AddLink("Custom", "/custom", "Custom")
+	c.SetModulesStatus(func() []ModuleStatus {
+		return []ModuleStatus{{Name: "ingester", State: "Running"}}
+	})
+
+	// The sections are sorted by their position, followed by the unknown ones.
+	// The links are sorted in the order they were added.
+	assert.Equal(t, []IndexPageSection{
+		{Name: SectionCortex, Links: []IndexPageLink{{Path: "/config", Description: "Config"}}},
+		{Name: SectionIngester, Links: []IndexPageLink{
+			{Path: "/ingester/ring", Description: "Ingester Ring"},
+			{Path: "/ingester/shutdown", Description: "Shutdown", Dangerous: true},
+		}},
+		{Name: SectionStoreGateway, Links: []IndexPageLink{{Path: "/store-gateway/ring", Description: "Store Gateway Ring"}}},
+		{Name: "Custom", Links: []IndexPageLink{{Path: "/custom", Description: "Custom"}}},
+	}, c.GetContent())
 
 	h := indexHandler("", c)
 
@@ -47,9 +69,89 @@ func TestIndexPageContent(t *testing.T) {
 	h.ServeHTTP(resp, req)
 
 	require.Equal(t, 200, resp.Code)
-	require.True(t, strings.Contains(resp.Body.String(), SectionAdminEndpoints))
-	require.True(t, strings.Contains(resp.Body.String(), SectionDangerous))
+	require.True(t, strings.Contains(resp.Body.String(), SectionIngester))
 	require.True(t, strings.Contains(resp.Body.String(), "Store Gateway Ring"))
-	require.True(t, strings.Contains(resp.Body.String(), "/shutdown"))
+	require.True(t, strings.Contains(resp.Body.String(), "/ingester/shutdown\">Shutdown</a> <strong>(dangerous)</strong>"))
+	require.True(t, strings.Contains(resp.Body.String(), "<td>ingester</td>"))
 	require.False(t, strings.Contains(resp.Body.String(), "/compactor/ring"))
 }
+
+func TestConfigHandler(t *testing.T) {
+	type nestedConfig struct {
+		Name  string `yaml:"name"`
+		Value int    `yaml:"value"`
+	}
+	type testConfig struct {
+		Enabled bool         `yaml:"enabled"`
+		Nested  nestedConfig `yaml:"nested"`
+		List    []string     `yaml:"list"`
+	}
+
+	defaultCfg := testConfig{Nested: nestedConfig{Name: "default", Value: 1}, List: []string{"a"}}
+	actualCfg := testConfig{Enabled: true, Nested: nestedConfig{Name: "default", Value: 2}, List: []string{"a"}}
+
+	for _, tc := range []struct {
+		mode         string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			mode:         "",
+			expectedCode: 200,
+			expectedBody: "enabled: true\nnested:\n  name: default\n  value: 2\nlist:\n- a\n",
+		},
+		{
+			mode:         "defaults",
+			expectedCode: 200,
+			expectedBody: "enabled: false\nnested:\n  name: default\n  value: 1\nlist:\n- a\n",
+		},
+		{
+			mode:         "diff",
+			expectedCode: 200,
+			expectedBody: "enabled: true\nnested:\n  value: 2\n",
+		},
+		{
+			mode:         "unknown",
+			expectedCode: 400,
+		},
+	} {
+		t.Run(tc.mode, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/config?mode="+tc.mode, nil)
+			resp := httptest.NewRecorder()
+
+			configHandler(actualCfg, defaultCfg).ServeHTTP(resp, req)
+
+			require.Equal(t, tc.expectedCode, resp.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestTenantLimitsHandler(t *testing.T) {
+	defaults := validation.Limits{IngestionRate: 10, MaxQueryLength: time.Hour}
+	overrides, err := validation.NewOverrides(defaults, func(userID string) *validation.Limits {
+		if userID == "user-1" {
+			return &validation.Limits{IngestionRate: 20, MaxQueryLength: time.Hour}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+	router.Path("/runtime_config/tenants/{tenant}").Handler(tenantLimitsHandler(overrides, defaults))
+
+	req := httptest.NewRequest("GET", "/runtime_config/tenants/user-1?mode=diff", nil)
+	resp := httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, 200, resp.Code)
+	assert.Equal(t, "ingestion_rate: 20\n", resp.Body.String())
+
+	req = httptest.NewRequest("GET", "/runtime_config/tenants/user-2", nil)
+	resp = httptest.NewRecorder()
+	router.ServeHTTP(resp, req)
+	require.Equal(t, 200, resp.Code)
+	assert.Contains(t, resp.Body.String(), "ingestion_rate: 10\n")
+	assert.Contains(t, resp.Body.String(), "max_query_length: 1h0m0s\n")
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/httputil"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/querier"
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// protobufQueryRangeHandler serves the query range requests accepting a protobuf-encoded
+// response (sent by the query-frontend), skipping the JSON encoding of the Prometheus API.
+// Any other request, including the invalid ones, is served by the next handler.
+type protobufQueryRangeHandler struct {
+	engines   *querier.Engines
+	queryable storage.Queryable
+	limits    *validation.Overrides
+	next      http.Handler
+	logger    log.Logger
+}
+
+func (h *protobufQueryRangeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Requests with parameters not supported by the PrometheusResponse are served by the Prometheus API.
+	if !strings.Contains(r.Header.Get("Accept"), queryrange.ProtobufResponseContentType) || r.FormValue("timeout") != "" || r.FormValue("stats") != "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	maxResponseBytes := maxQueryResponseBytes(tenantIDs, h.limits)
+
+	// Invalid requests are served by the Prometheus API too, so that the error is the same.
+	req, err := queryrange.PrometheusCodec.DecodeRequest(r.Context(), r)
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	qry, err := h.engines.ForTenants(tenantIDs).NewRangeQuery(h.queryable, req.GetQuery(), util.TimeFromMillis(req.GetStart()), util.TimeFromMillis(req.GetEnd()), time.Duration(req.GetStep())*time.Millisecond)
+	if err != nil {
+		respondError(w, h.logger, "bad_data", http.StatusBadRequest, err)
+		return
+	}
+	defer qry.Close()
+
+	res := qry.Exec(httputil.ContextFromRequest(r.Context(), r))
+	if res.Err != nil {
+		respondQueryError(w, h.logger, h.engines.TranslateError(tenantIDs, res.Err))
+		return
+	}
+
+	matrix, err := res.Matrix()
+	if err != nil {
+		respondError(w, h.logger, "internal", http.StatusInternalServerError, err)
+		return
+	}
+
+	resp := queryrange.PrometheusResponse{
+		Status: queryrange.StatusSuccess,
+		Data: queryrange.PrometheusData{
+			ResultType: model.ValMatrix.String(),
+			Result:     make([]queryrange.SampleStream, 0, len(matrix)),
+		},
+	}
+
+	for _, series := range matrix {
+		stream := queryrange.SampleStream{
+			Labels:  client.FromLabelsToLabelAdapters(series.Metric),
+			Samples: make([]client.Sample, 0, len(series.Points)),
+		}
+		for _, p := range series.Points {
+			stream.Samples = append(stream.Samples, client.Sample{TimestampMs: p.T, Value: p.V})
+		}
+		resp.Data.Result = append(resp.Data.Result, stream)
+	}
+
+	b, err := resp.Marshal()
+	if err != nil {
+		respondError(w, h.logger, "internal", http.StatusInternalServerError, err)
+		return
+	}
+
+	// The response is not streamed, so the max response size is enforced before sending it.
+	if maxResponseBytes > 0 && len(b) > maxResponseBytes {
+		respondError(w, h.logger, "execution", http.StatusUnprocessableEntity, fmt.Errorf(errMaxQueryResponseSize, maxResponseBytes))
+		return
+	}
+
+	w.Header().Set("Content-Type", queryrange.ProtobufResponseContentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		level.Error(h.logger).Log("msg", "error writing response", "err", err)
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/querier"
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+func TestProtobufQueryRangeHandler(t *testing.T) {
+	db := teststorage.New(t)
+	defer db.Close()
+
+	app := db.Appender(context.Background())
+	for ts := int64(0); ts <= 120000; ts += 15000 {
+		_, err := app.Add(labels.FromStrings(labels.MetricName, "up", "job", "a"), ts, float64(ts))
+		require.NoError(t, err)
+		_, err = app.Add(labels.FromStrings(labels.MetricName, "up", "job", "b"), ts, float64(ts*2))
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	tests := map[string]struct {
+		queryable          storage.SampleAndChunkQueryable
+		url                string
+		expectedStatusCode int
+	}{
+		"valid query": {
+			queryable:          db,
+			url:                "/api/v1/query_range?query=up&start=0&end=120&step=30",
+			expectedStatusCode: http.StatusOK,
+		},
+		"valid query with empty result": {
+			queryable:          db,
+			url:                "/api/v1/query_range?query=down&start=0&end=120&step=30",
+			expectedStatusCode: http.StatusOK,
+		},
+		"invalid query": {
+			queryable:          db,
+			url:                "/api/v1/query_range?query=up{&start=0&end=120&step=30",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"invalid parameters": {
+			queryable:          db,
+			url:                "/api/v1/query_range?query=up&start=120&end=0&step=30",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"query execution error": {
+			queryable:          testQueryable{err: httpgrpc.Errorf(http.StatusBadRequest, "bad request")},
+			url:                "/api/v1/query_range?query=up&start=0&end=120&step=30",
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+		"storage error": {
+			queryable:          testQueryable{err: httpgrpc.Errorf(http.StatusInternalServerError, "internal")},
+			url:                "/api/v1/query_range?query=up&start=0&end=120&step=30",
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			queryable := errorTranslateQueryable{q: testData.queryable}
+			handler := &protobufQueryRangeHandler{
+				engines:   querier.NewEngines(querier.Config{MaxSamples: 100, Timeout: 5 * time.Second}, nil, nil),
+				queryable: queryable,
+				next:      createPrometheusAPI(queryable),
+				logger:    util.Logger,
+			}
+
+			serve := func(accept string) *http.Response {
+				req := httptest.NewRequest("GET", testData.url, nil)
+				req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+				if accept != "" {
+					req.Header.Set("Accept", accept)
+				}
+
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				return rec.Result()
+			}
+
+			// The response without the Accept header is served by the Prometheus API.
+			jsonResp := serve("")
+			assert.Equal(t, "application/json", jsonResp.Header.Get("Content-Type"))
+
+			protoResp := serve(queryrange.ProtobufResponseContentType + ", application/json")
+			require.Equal(t, testData.expectedStatusCode, protoResp.StatusCode)
+			require.Equal(t, jsonResp.StatusCode, protoResp.StatusCode)
+
+			if testData.expectedStatusCode != http.StatusOK {
+				assert.Equal(t, "application/json", protoResp.Header.Get("Content-Type"))
+				return
+			}
+			assert.Equal(t, queryrange.ProtobufResponseContentType, protoResp.Header.Get("Content-Type"))
+
+			// The decoded responses should be equal, except for the content type header.
+			expected, err := queryrange.PrometheusCodec.DecodeResponse(context.Background(), jsonResp, nil)
+			require.NoError(t, err)
+			actual, err := queryrange.PrometheusCodec.DecodeResponse(context.Background(), protoResp, nil)
+			require.NoError(t, err)
+
+			expected.(*queryrange.PrometheusResponse).Headers = nil
+			actual.(*queryrange.PrometheusResponse).Headers = nil
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
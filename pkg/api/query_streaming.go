@@ -0,0 +1,381 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/httputil"
+
+	"github.com/cortexproject/cortex/pkg/querier"
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	// The JSON encoded by the streaming query handler is written to the client
+	// each time the buffered response grows above this size.
+	streamingQueryFlushBytes = 64 * 1024
+
+	errMaxQueryResponseSize = "the query response exceeded the max response size limit (limit: %d bytes)"
+)
+
+// streamingQueryHandler serves the instant (or range) queries encoding the result in JSON
+// while it's written to the client, instead of buffering the whole JSON response like the
+// Prometheus API does. The response is the same of the Prometheus API, except for the
+// "status" field written last, given the response is aborted with an error, and the partial
+// result written so far, once the tenant's max query response size is exceeded. Requests
+// with parameters not supported, including the invalid ones, are served by the next handler.
+type streamingQueryHandler struct {
+	engines    *querier.Engines
+	queryable  storage.Queryable
+	limits     *validation.Overrides
+	rangeQuery bool
+	next       http.Handler
+	logger     log.Logger
+}
+
+func (h *streamingQueryHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("timeout") != "" || r.FormValue("stats") != "" {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	maxResponseBytes := maxQueryResponseBytes(tenantIDs, h.limits)
+
+	qry, err := h.newQuery(r, h.engines.ForTenants(tenantIDs))
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+	defer qry.Close()
+
+	res := qry.Exec(httputil.ContextFromRequest(r.Context(), r))
+	if res.Err != nil {
+		respondQueryError(w, h.logger, h.engines.TranslateError(tenantIDs, res.Err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+
+	enc := newJSONResultEncoder(w, maxResponseBytes)
+	if err := enc.encode(res); err != nil {
+		level.Error(h.logger).Log("msg", "error writing response", "err", err)
+	}
+}
+
+func (h *streamingQueryHandler) newQuery(r *http.Request, engine *promql.Engine) (promql.Query, error) {
+	if !h.rangeQuery {
+		ts := time.Now()
+		if t := r.FormValue("time"); t != "" {
+			ms, err := util.ParseTime(t)
+			if err != nil {
+				return nil, err
+			}
+			ts = util.TimeFromMillis(ms)
+		}
+
+		return engine.NewInstantQuery(h.queryable, r.FormValue("query"), ts)
+	}
+
+	req, err := queryrange.PrometheusCodec.DecodeRequest(r.Context(), r)
+	if err != nil {
+		return nil, err
+	}
+
+	return engine.NewRangeQuery(h.queryable, req.GetQuery(), util.TimeFromMillis(req.GetStart()), util.TimeFromMillis(req.GetEnd()), time.Duration(req.GetStep())*time.Millisecond)
+}
+
+// maxQueryResponseBytes returns the smallest max query response size of the given
+// tenants, or 0 if the limit is disabled.
+func maxQueryResponseBytes(tenantIDs []string, limits *validation.Overrides) int {
+	if limits == nil {
+		return 0
+	}
+
+	return validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, limits.MaxQueryResponseSizeBytes)
+}
+
+// jsonResultEncoder encodes a query result in the JSON format of the Prometheus API,
+// writing it to the output each time the buffered JSON grows above streamingQueryFlushBytes.
+type jsonResultEncoder struct {
+	stream           *jsoniter.Stream
+	written          int
+	maxResponseBytes int
+}
+
+func newJSONResultEncoder(w http.ResponseWriter, maxResponseBytes int) *jsonResultEncoder {
+	return &jsonResultEncoder{
+		stream:           jsoniter.NewStream(jsoniter.ConfigCompatibleWithStandardLibrary, w, streamingQueryFlushBytes),
+		maxResponseBytes: maxResponseBytes,
+	}
+}
+
+func (e *jsonResultEncoder) encode(res *promql.Result) error {
+	s := e.stream
+
+	s.WriteObjectStart()
+	s.WriteObjectField("data")
+	s.WriteObjectStart()
+	s.WriteObjectField("resultType")
+	s.WriteString(string(res.Value.Type()))
+	s.WriteMore()
+	s.WriteObjectField("result")
+
+	var completed bool
+	switch v := res.Value.(type) {
+	case promql.Matrix:
+		completed = e.encodeMatrix(v)
+	case promql.Vector:
+		completed = e.encodeVector(v)
+	case promql.Scalar:
+		e.writePoint(v.T, v.V, false)
+		completed = true
+	case promql.String:
+		e.writeTimestamp(v.T)
+		s.WriteMore()
+		s.WriteStringWithHTMLEscaped(v.V)
+		s.WriteArrayEnd()
+		completed = true
+	default:
+		s.WriteNil()
+		completed = true
+	}
+	s.WriteObjectEnd()
+
+	if completed {
+		if len(res.Warnings) > 0 {
+			s.WriteMore()
+			s.WriteObjectField("warnings")
+			s.WriteArrayStart()
+			for i, warning := range res.Warnings {
+				if i > 0 {
+					s.WriteMore()
+				}
+				s.WriteStringWithHTMLEscaped(warning.Error())
+			}
+			s.WriteArrayEnd()
+		}
+
+		s.WriteMore()
+		s.WriteObjectField("status")
+		s.WriteString("success")
+	} else {
+		s.WriteMore()
+		s.WriteObjectField("status")
+		s.WriteString("error")
+		s.WriteMore()
+		s.WriteObjectField("errorType")
+		s.WriteString("execution")
+		s.WriteMore()
+		s.WriteObjectField("error")
+		s.WriteString(fmt.Sprintf(errMaxQueryResponseSize+", the result is partial", e.maxResponseBytes))
+	}
+	s.WriteObjectEnd()
+
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	return s.Error
+}
+
+// encodeMatrix writes the series of the input matrix, and returns false if the
+// encoding has been aborted because the max response size has been exceeded.
+func (e *jsonResultEncoder) encodeMatrix(matrix promql.Matrix) bool {
+	s := e.stream
+
+	s.WriteArrayStart()
+	defer s.WriteArrayEnd()
+
+	for i, series := range matrix {
+		if i > 0 {
+			s.WriteMore()
+		}
+
+		s.WriteObjectStart()
+		s.WriteObjectField("metric")
+		e.writeLabels(series.Metric)
+		s.WriteMore()
+		s.WriteObjectField("values")
+		s.WriteArrayStart()
+		for j, p := range series.Points {
+			if j > 0 {
+				s.WriteMore()
+			}
+			e.writePoint(p.T, p.V, true)
+		}
+		s.WriteArrayEnd()
+		s.WriteObjectEnd()
+
+		if !e.flushIfNeeded() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// encodeVector writes the samples of the input vector, and returns false if the
+// encoding has been aborted because the max response size has been exceeded.
+func (e *jsonResultEncoder) encodeVector(vector promql.Vector) bool {
+	s := e.stream
+
+	s.WriteArrayStart()
+	defer s.WriteArrayEnd()
+
+	for i, sample := range vector {
+		if i > 0 {
+			s.WriteMore()
+		}
+
+		s.WriteObjectStart()
+		s.WriteObjectField("metric")
+		e.writeLabels(sample.Metric)
+		s.WriteMore()
+		s.WriteObjectField("value")
+		e.writePoint(sample.T, sample.V, false)
+		s.WriteObjectEnd()
+
+		if !e.flushIfNeeded() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// flushIfNeeded writes the buffered JSON to the output if it's grown above the flush size,
+// and returns false if the encoding should stop, because the max response size has been
+// exceeded or the output can't be written anymore.
+func (e *jsonResultEncoder) flushIfNeeded() bool {
+	s := e.stream
+
+	if s.Error != nil {
+		return false
+	}
+
+	size := e.written + s.Buffered()
+	if e.maxResponseBytes > 0 && size > e.maxResponseBytes {
+		return false
+	}
+
+	if s.Buffered() >= streamingQueryFlushBytes {
+		e.written = size
+		return s.Flush() == nil
+	}
+
+	return true
+}
+
+func (e *jsonResultEncoder) writeLabels(lbls labels.Labels) {
+	s := e.stream
+
+	s.WriteObjectStart()
+	for i, l := range lbls {
+		if i > 0 {
+			s.WriteMore()
+		}
+		s.WriteStringWithHTMLEscaped(l.Name)
+		s.WriteRaw(":")
+		s.WriteStringWithHTMLEscaped(l.Value)
+	}
+	s.WriteObjectEnd()
+}
+
+// writePoint writes a [<timestamp>, "<value>"] pair. The value is formatted like the
+// Prometheus API does: range query points use the exponent format for very large and
+// very small values, while instant query samples and scalars never do.
+func (e *jsonResultEncoder) writePoint(t int64, v float64, exponentFormat bool) {
+	s := e.stream
+
+	e.writeTimestamp(t)
+	s.WriteMore()
+	s.WriteRaw(`"`)
+
+	format := byte('f')
+	if abs := math.Abs(v); exponentFormat && abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	s.SetBuffer(strconv.AppendFloat(s.Buffer(), v, format, -1, 64))
+
+	s.WriteRaw(`"`)
+	s.WriteArrayEnd()
+}
+
+// writeTimestamp opens an array and writes the input timestamp in seconds.
+func (e *jsonResultEncoder) writeTimestamp(t int64) {
+	s := e.stream
+
+	s.WriteArrayStart()
+	if t < 0 {
+		s.WriteRaw(`-`)
+		t = -t
+	}
+	s.WriteInt64(t / 1000)
+	if fraction := t % 1000; fraction != 0 {
+		s.WriteRaw(`.`)
+		if fraction < 100 {
+			s.WriteRaw(`0`)
+		}
+		if fraction < 10 {
+			s.WriteRaw(`0`)
+		}
+		s.WriteInt64(fraction)
+	}
+}
+
+// respondQueryError writes the error returned by a query execution, with the same
+// status code and format of the Prometheus API.
+func respondQueryError(w http.ResponseWriter, logger log.Logger, err error) {
+	switch errors.Cause(err).(type) {
+	case promql.ErrQueryCanceled:
+		respondError(w, logger, "canceled", http.StatusServiceUnavailable, err)
+	case promql.ErrQueryTimeout:
+		respondError(w, logger, "timeout", http.StatusServiceUnavailable, err)
+	case promql.ErrStorage:
+		respondError(w, logger, "internal", http.StatusInternalServerError, err)
+	default:
+		respondError(w, logger, "execution", http.StatusUnprocessableEntity, err)
+	}
+}
+
+// respondError writes the error in the same JSON format of the Prometheus API.
+func respondError(w http.ResponseWriter, logger log.Logger, errorType string, code int, err error) {
+	b, marshalErr := json.Marshal(struct {
+		Status    string `json:"status"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+	}{
+		Status:    "error",
+		ErrorType: errorType,
+		Error:     err.Error(),
+	})
+	if marshalErr != nil {
+		http.Error(w, marshalErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if _, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "err", err)
+	}
+}
@@ -0,0 +1,231 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/querier"
+	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestStreamingQueryHandler_ShouldReturnTheSameResponseOfThePrometheusAPI(t *testing.T) {
+	db := teststorage.New(t)
+	defer db.Close()
+
+	app := db.Appender(context.Background())
+	for ts := int64(0); ts <= 120000; ts += 15000 {
+		for _, s := range []struct {
+			lbls  labels.Labels
+			value float64
+		}{
+			{lbls: labels.FromStrings(labels.MetricName, "up", "job", "a"), value: float64(ts)},
+			{lbls: labels.FromStrings(labels.MetricName, "up", "job", "<b&c>"), value: 1e-7},
+			{lbls: labels.FromStrings(labels.MetricName, "up", "job", "\"quoted\" ünicode"), value: 1e22},
+			{lbls: labels.FromStrings(labels.MetricName, "special", "value", "nan"), value: math.NaN()},
+			{lbls: labels.FromStrings(labels.MetricName, "special", "value", "inf"), value: math.Inf(-1)},
+		} {
+			_, err := app.Add(s.lbls, ts+1, s.value)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, app.Commit())
+
+	tests := map[string]struct {
+		path       string
+		rangeQuery bool
+	}{
+		"instant query returning a vector": {
+			path: "/api/v1/query?query=up&time=60.5",
+		},
+		"instant query returning special values": {
+			path: "/api/v1/query?query=special&time=60",
+		},
+		"instant query returning an empty vector": {
+			path: "/api/v1/query?query=down&time=60",
+		},
+		"instant query returning a scalar": {
+			path: "/api/v1/query?query=1e-7&time=60",
+		},
+		"instant query returning a string": {
+			path: "/api/v1/query?query=\"<string>\"&time=60",
+		},
+		"range query returning a matrix": {
+			path:       "/api/v1/query_range?query=up&start=0&end=120&step=15",
+			rangeQuery: true,
+		},
+		"range query returning special values": {
+			path:       "/api/v1/query_range?query=special&start=0&end=120&step=30",
+			rangeQuery: true,
+		},
+		"range query returning an empty matrix": {
+			path:       "/api/v1/query_range?query=down&start=0&end=120&step=30",
+			rangeQuery: true,
+		},
+		"invalid instant query": {
+			path: "/api/v1/query?query=up{&time=60",
+		},
+		"invalid range query": {
+			path:       "/api/v1/query_range?query=up&start=120&end=0&step=30",
+			rangeQuery: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			queryable := errorTranslateQueryable{q: db}
+			promAPI := createPrometheusAPI(queryable)
+			handler := &streamingQueryHandler{
+				engines:    newTestEngines(),
+				queryable:  queryable,
+				rangeQuery: testData.rangeQuery,
+				next:       promAPI,
+				logger:     util.Logger,
+			}
+
+			expected := serveQuery(t, promAPI, testData.path)
+			actual := serveQuery(t, handler, testData.path)
+
+			require.Equal(t, expected.StatusCode, actual.StatusCode)
+			assert.Equal(t, expected.Header.Get("Content-Type"), actual.Header.Get("Content-Type"))
+			assert.Equal(t, decodeJSONBody(t, expected), decodeJSONBody(t, actual))
+		})
+	}
+}
+
+func TestStreamingQueryHandler_ShouldReturnTheSameErrorsOfThePrometheusAPI(t *testing.T) {
+	for _, err := range []error{
+		httpgrpc.Errorf(http.StatusBadRequest, "bad request"),
+		httpgrpc.Errorf(http.StatusInternalServerError, "internal"),
+		promql.ErrQueryCanceled("query execution"),
+	} {
+		queryable := errorTranslateQueryable{q: testQueryable{err: err}}
+		promAPI := createPrometheusAPI(queryable)
+		handler := &streamingQueryHandler{
+			engines:   newTestEngines(),
+			queryable: queryable,
+			next:      promAPI,
+			logger:    util.Logger,
+		}
+
+		expected := serveQuery(t, promAPI, "/api/v1/query?query=up&time=60")
+		actual := serveQuery(t, handler, "/api/v1/query?query=up&time=60")
+
+		require.Equal(t, expected.StatusCode, actual.StatusCode)
+		assert.Equal(t, decodeJSONBody(t, expected), decodeJSONBody(t, actual))
+	}
+}
+
+func TestStreamingQueryHandler_ShouldAbortOnceTheMaxResponseSizeIsExceeded(t *testing.T) {
+	db := teststorage.New(t)
+	defer db.Close()
+
+	app := db.Appender(context.Background())
+	for i := 0; i < 1000; i++ {
+		_, err := app.Add(labels.FromStrings(labels.MetricName, "up", "instance", time.Duration(i).String()), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	limits := defaultLimitsConfig()
+	limits.MaxQueryResponseSizeBytes = 1000
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	queryable := errorTranslateQueryable{q: db}
+	handler := &streamingQueryHandler{
+		engines:    newTestEngines(),
+		queryable:  queryable,
+		limits:     overrides,
+		rangeQuery: true,
+		next:       createPrometheusAPI(queryable),
+		logger:     util.Logger,
+	}
+
+	// The response is already started once the limit is exceeded.
+	resp := serveQuery(t, handler, "/api/v1/query_range?query=up&start=0&end=60&step=30")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body := decodeJSONBody(t, resp)
+	assert.Equal(t, "error", body["status"])
+	assert.Equal(t, "execution", body["errorType"])
+	assert.Equal(t, "the query response exceeded the max response size limit (limit: 1000 bytes), the result is partial", body["error"])
+
+	result := body["data"].(map[string]interface{})["result"].([]interface{})
+	assert.NotEmpty(t, result)
+	assert.Less(t, len(result), 1000)
+
+	// The query-frontend should not accept the partial result.
+	resp = serveQuery(t, handler, "/api/v1/query_range?query=up&start=0&end=60&step=30")
+	_, err = queryrange.PrometheusCodec.DecodeResponse(context.Background(), resp, nil)
+	require.Error(t, err)
+	errResp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusUnprocessableEntity), errResp.Code)
+
+	// The protobuf response is not streamed, so it's rejected before sending it.
+	protobufHandler := &protobufQueryRangeHandler{
+		engines:   newTestEngines(),
+		queryable: queryable,
+		limits:    overrides,
+		next:      handler,
+		logger:    util.Logger,
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/query_range?query=up&start=0&end=60&step=30", nil)
+	req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+	req.Header.Set("Accept", queryrange.ProtobufResponseContentType)
+	rec := httptest.NewRecorder()
+	protobufHandler.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "the query response exceeded the max response size limit (limit: 1000 bytes)")
+}
+
+func newTestEngines() *querier.Engines {
+	return querier.NewEngines(querier.Config{MaxSamples: 100000, Timeout: 5 * time.Second}, nil, nil)
+}
+
+func defaultLimitsConfig() validation.Limits {
+	limits := validation.Limits{}
+	flagext.DefaultValues(&limits)
+	return limits
+}
+
+func serveQuery(t *testing.T, handler http.Handler, path string) *http.Response {
+	req := httptest.NewRequest("GET", path, nil)
+	req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func decodeJSONBody(t *testing.T, resp *http.Response) map[string]interface{} {
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	// Restore the body, so that the response can be read again.
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	decoded := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(body, &decoded), string(body))
+	return decoded
+}
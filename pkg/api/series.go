@@ -0,0 +1,292 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	errMaxSeriesPerSeriesRequest = "the series request matched more than %d series, which is the max number of series per series request: use the 'limit' parameter to paginate the series, or more specific matchers"
+)
+
+var (
+	// Same defaults of the Prometheus API for the start and end parameters.
+	seriesRequestMinTime = time.Unix(math.MinInt64/1000+62135596801, 0).UTC()
+	seriesRequestMaxTime = time.Unix(math.MaxInt64/1000-62135596801, 999999999).UTC()
+)
+
+// seriesHandler serves the series API, enforcing the tenant's max series per series request
+// and supporting an opt-in pagination: when the "limit" parameter is set, up to "limit" series
+// are returned, sorted by labels, together with a "nextToken" to pass in the "next_token"
+// parameter of the next request, if there are more series. The response is otherwise the same
+// of the Prometheus API. Requests not paginated, when the limit is disabled, and requests with
+// invalid parameters are served by the next handler.
+//
+// The page size is not pushed down to the queriers, which don't support a limit, so all the
+// series matching the request from the page onward are fetched and the ones exceeding the page
+// are discarded here. The series before the page are excluded by the stores through a matcher on
+// their metric name, as long as the metric name is enforced (see seriesCursorMatcher).
+type seriesHandler struct {
+	queryable storage.Queryable
+	limits    *validation.Overrides
+	next      http.Handler
+	logger    log.Logger
+}
+
+type seriesResponse struct {
+	Status    string          `json:"status"`
+	Data      []labels.Labels `json:"data"`
+	Warnings  []string        `json:"warnings,omitempty"`
+	NextToken string          `json:"nextToken,omitempty"`
+}
+
+func (h *seriesHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	maxSeries, err := maxSeriesPerSeriesRequest(r, h.limits)
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Pagination is opt-in.
+	pageSize := 0
+	if s := r.FormValue("limit"); s != "" {
+		if pageSize, err = strconv.Atoi(s); err != nil || pageSize <= 0 {
+			respondError(w, h.logger, "bad_data", http.StatusBadRequest, errors.New("invalid 'limit' parameter: it must be a positive integer"))
+			return
+		}
+	}
+
+	if maxSeries <= 0 && pageSize <= 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	var after labels.Labels
+	if s := r.FormValue("next_token"); s != "" {
+		if after, err = decodeSeriesNextToken(s); err != nil {
+			respondError(w, h.logger, "bad_data", http.StatusBadRequest, errors.New("invalid 'next_token' parameter"))
+			return
+		}
+	}
+
+	start, end, matcherSets, err := parseSeriesRequest(r)
+	if err != nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	// Let the stores skip the series before the page.
+	if after != nil && metricNameEnforced(r, h.limits) {
+		if m := seriesCursorMatcher(after); m != nil {
+			for i := range matcherSets {
+				matcherSets[i] = append(matcherSets[i], m)
+			}
+		}
+	}
+
+	// The tenant's limit caps the page size too.
+	paginated := pageSize > 0
+	if !paginated || (maxSeries > 0 && maxSeries < pageSize) {
+		pageSize = maxSeries
+	}
+
+	q, err := h.queryable.Querier(r.Context(), start, end)
+	if err != nil {
+		respondQueryError(w, h.logger, err)
+		return
+	}
+	defer q.Close()
+
+	hints := &storage.SelectHints{Start: start, End: end, Func: "series"}
+
+	sets := make([]storage.SeriesSet, 0, len(matcherSets))
+	for _, matchers := range matcherSets {
+		// The series are sorted, to merge (deduplicate) the series sets and paginate them.
+		sets = append(sets, q.Select(true, hints, matchers...))
+	}
+	set := storage.NewMergeSeriesSet(sets, storage.ChainedSeriesMerge)
+
+	resp := seriesResponse{Status: "success", Data: []labels.Labels{}}
+	for set.Next() {
+		lbls := set.At().Labels()
+		if after != nil && labels.Compare(lbls, after) <= 0 {
+			continue
+		}
+
+		if len(resp.Data) >= pageSize {
+			if !paginated {
+				respondError(w, h.logger, "execution", http.StatusUnprocessableEntity, fmt.Errorf(errMaxSeriesPerSeriesRequest, maxSeries))
+				return
+			}
+
+			// There are more series than the page size.
+			resp.NextToken = encodeSeriesNextToken(resp.Data[len(resp.Data)-1])
+			break
+		}
+
+		resp.Data = append(resp.Data, lbls)
+	}
+
+	if err := set.Err(); err != nil {
+		respondQueryError(w, h.logger, err)
+		return
+	}
+
+	for _, warning := range set.Warnings() {
+		resp.Warnings = append(resp.Warnings, warning.Error())
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		respondError(w, h.logger, "internal", http.StatusInternalServerError, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(b); err != nil {
+		level.Error(h.logger).Log("msg", "error writing response", "err", err)
+	}
+}
+
+// parseSeriesRequest parses the parameters of a series request like the Prometheus API does.
+func parseSeriesRequest(r *http.Request) (start, end int64, matcherSets [][]*labels.Matcher, err error) {
+	if err = r.ParseForm(); err != nil {
+		return 0, 0, nil, err
+	}
+	if len(r.Form["match[]"]) == 0 {
+		return 0, 0, nil, errors.New("no match[] parameter provided")
+	}
+
+	start = util.TimeToMillis(seriesRequestMinTime)
+	if s := r.FormValue("start"); s != "" {
+		if start, err = util.ParseTime(s); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	end = util.TimeToMillis(seriesRequestMaxTime)
+	if s := r.FormValue("end"); s != "" {
+		if end, err = util.ParseTime(s); err != nil {
+			return 0, 0, nil, err
+		}
+	}
+
+	for _, s := range r.Form["match[]"] {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return 0, 0, nil, err
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+
+	return start, end, matcherSets, nil
+}
+
+// maxSeriesPerSeriesRequest returns the smallest max series per series request of the
+// request tenants, or 0 if the limit is disabled.
+func maxSeriesPerSeriesRequest(r *http.Request, limits *validation.Overrides) (int, error) {
+	if limits == nil {
+		return 0, nil
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return 0, err
+	}
+
+	return validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, limits.MaxSeriesPerSeriesRequest), nil
+}
+
+// metricNameEnforced returns whether all the series of the request tenants have a valid metric name.
+func metricNameEnforced(r *http.Request, limits *validation.Overrides) bool {
+	if limits == nil {
+		return false
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return false
+	}
+
+	for _, tenantID := range tenantIDs {
+		if !limits.EnforceMetricName(tenantID) {
+			return false
+		}
+	}
+	return true
+}
+
+// seriesCursorMatcher returns a matcher selecting the series whose metric name is greater than
+// or equal to the one of the given series, or nil if the series before it can't be excluded by
+// their metric name. The labels are sorted by name, so the series sorted after the given one either
+// have a greater or equal metric name or have a first label name greater than "__name__", which
+// means they have no metric name: the matcher can only be used when the metric name is enforced.
+func seriesCursorMatcher(after labels.Labels) *labels.Matcher {
+	if len(after) == 0 || after[0].Name != labels.MetricName {
+		return nil
+	}
+
+	// The valid metric names are ASCII strings, whose byte order is the one of their runes.
+	name := after[0].Value
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			return nil
+		}
+	}
+
+	// The strings greater than or equal to name[i:] either start with name[i] followed by a string
+	// greater than or equal to name[i+1:], or start with a rune greater than name[i].
+	re := "(?s:.*)"
+	for i := len(name) - 1; i >= 0; i-- {
+		re = fmt.Sprintf(`%s(?:%s)|[\x{%x}-\x{10FFFF}](?s:.*)`, regexp.QuoteMeta(name[i:i+1]), re, name[i]+1)
+	}
+
+	m, err := labels.NewMatcher(labels.MatchRegexp, labels.MetricName, re)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// encodeSeriesNextToken returns the opaque token of the page following the given series.
+func encodeSeriesNextToken(last labels.Labels) string {
+	b, _ := json.Marshal(last)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeSeriesNextToken(token string) (labels.Labels, error) {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var last labels.Labels
+	if err := json.Unmarshal(b, &last); err != nil {
+		return nil, err
+	}
+	return last, nil
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/querier/series"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestSeriesHandler(t *testing.T) {
+	db := teststorage.New(t)
+	defer db.Close()
+
+	app := db.Appender(context.Background())
+	for i := 0; i < 10; i++ {
+		_, err := app.Add(labels.FromStrings(labels.MetricName, "up", "instance", fmt.Sprintf("instance-%d", i)), 0, 1)
+		require.NoError(t, err)
+		_, err = app.Add(labels.FromStrings(labels.MetricName, "down", "instance", fmt.Sprintf("instance-%d", i)), 0, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	queryable := errorTranslateQueryable{q: db}
+	promAPI := createPrometheusAPI(queryable)
+
+	newHandler := func(maxSeries int) *seriesHandler {
+		limits := defaultLimitsConfig()
+		limits.MaxSeriesPerSeriesRequest = maxSeries
+		overrides, err := validation.NewOverrides(limits, nil)
+		require.NoError(t, err)
+
+		return &seriesHandler{queryable: queryable, limits: overrides, next: promAPI, logger: util.Logger}
+	}
+
+	// Iterates all the pages of the series API, and returns the series and the number of pages.
+	paginate := func(handler http.Handler, path string) ([]interface{}, int) {
+		var (
+			series []interface{}
+			pages  int
+			token  string
+		)
+
+		for {
+			p := path
+			if token != "" {
+				p += "&next_token=" + url.QueryEscape(token)
+			}
+
+			resp := serveQuery(t, handler, p)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+
+			body := decodeJSONBody(t, resp)
+			require.Equal(t, "success", body["status"])
+			series = append(series, body["data"].([]interface{})...)
+			pages++
+
+			next, ok := body["nextToken"]
+			if !ok {
+				return series, pages
+			}
+			token = next.(string)
+		}
+	}
+
+	t.Run("should return the same response of the Prometheus API when the limit is not exceeded", func(t *testing.T) {
+		path := "/api/v1/series?match[]=up&match[]=down&start=0&end=60"
+
+		expected := serveQuery(t, promAPI, path)
+		actual := serveQuery(t, newHandler(20), path)
+
+		require.Equal(t, expected.StatusCode, actual.StatusCode)
+		assert.Equal(t, decodeJSONBody(t, expected), decodeJSONBody(t, actual))
+	})
+
+	t.Run("should fail if the limit is exceeded and the request is not paginated", func(t *testing.T) {
+		resp := serveQuery(t, newHandler(15), "/api/v1/series?match[]=up&match[]=down&start=0&end=60")
+		require.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+
+		body := decodeJSONBody(t, resp)
+		assert.Equal(t, "error", body["status"])
+		assert.Equal(t, "execution", body["errorType"])
+		assert.Equal(t, fmt.Sprintf(errMaxSeriesPerSeriesRequest, 15), body["error"])
+	})
+
+	t.Run("should paginate the series", func(t *testing.T) {
+		expected := decodeJSONBody(t, serveQuery(t, promAPI, "/api/v1/series?match[]=up&match[]=down&start=0&end=60"))
+
+		// The page size is set by the request.
+		series, pages := paginate(newHandler(0), "/api/v1/series?match[]=up&match[]=down&start=0&end=60&limit=3")
+		assert.Equal(t, expected["data"], series)
+		assert.Equal(t, 7, pages)
+
+		// The page size is capped by the limit.
+		series, pages = paginate(newHandler(5), "/api/v1/series?match[]=up&match[]=down&start=0&end=60&limit=100")
+		assert.Equal(t, expected["data"], series)
+		assert.Equal(t, 4, pages)
+	})
+
+	t.Run("should not select the series before the page", func(t *testing.T) {
+		selected := 0
+		countingQueryable := storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+			q, err := queryable.Querier(ctx, mint, maxt)
+			if err != nil {
+				return nil, err
+			}
+			return &countingQuerier{Querier: q, selected: &selected}, nil
+		})
+
+		handler := newHandler(0)
+		handler.queryable = countingQueryable
+
+		expected := decodeJSONBody(t, serveQuery(t, promAPI, "/api/v1/series?match[]=up&match[]=down&start=0&end=60"))
+		series, pages := paginate(handler, "/api/v1/series?match[]=up&match[]=down&start=0&end=60&limit=15")
+		assert.Equal(t, expected["data"], series)
+		assert.Equal(t, 2, pages)
+
+		// The series of the "down" metric are excluded by the stores from the second page.
+		assert.Equal(t, 20+10, selected)
+	})
+
+	t.Run("should fail on invalid pagination parameters", func(t *testing.T) {
+		for _, path := range []string{
+			"/api/v1/series?match[]=up&limit=0",
+			"/api/v1/series?match[]=up&limit=abc",
+			"/api/v1/series?match[]=up&limit=10&next_token=invalid",
+		} {
+			resp := serveQuery(t, newHandler(0), path)
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode, path)
+			assert.Equal(t, "bad_data", decodeJSONBody(t, resp)["errorType"], path)
+		}
+	})
+
+	t.Run("should let the Prometheus API serve invalid requests", func(t *testing.T) {
+		for _, path := range []string{
+			"/api/v1/series?start=0&end=60",
+			"/api/v1/series?match[]=up{&start=0&end=60",
+			"/api/v1/series?match[]=up&start=abc",
+		} {
+			expected := serveQuery(t, promAPI, path)
+			actual := serveQuery(t, newHandler(5), path)
+
+			require.Equal(t, expected.StatusCode, actual.StatusCode, path)
+			assert.Equal(t, decodeJSONBody(t, expected), decodeJSONBody(t, actual), path)
+		}
+	})
+}
+
+func TestSeriesCursorMatcher(t *testing.T) {
+	values := []string{"", "a", "b", "u", "up", "up_", "upa", "upz", "uq", "u\n", "up\n", "UP", "_up", "é", "up:rate5m", "zzz"}
+
+	for _, name := range []string{"a", "up", "up:rate5m", "_up", "UP"} {
+		m := seriesCursorMatcher(labels.FromStrings(labels.MetricName, name, "instance", "instance-1"))
+		require.NotNil(t, m, name)
+
+		for _, value := range values {
+			assert.Equal(t, value >= name, m.Matches(value), "name: %q value: %q", name, value)
+		}
+	}
+
+	// The series before the page can't be excluded without a metric name.
+	assert.Nil(t, seriesCursorMatcher(nil))
+	assert.Nil(t, seriesCursorMatcher(labels.FromStrings("instance", "instance-1")))
+	assert.Nil(t, seriesCursorMatcher(labels.FromStrings(labels.MetricName, "é")))
+}
+
+// countingQuerier counts the series selected from the wrapped querier.
+type countingQuerier struct {
+	storage.Querier
+	selected *int
+}
+
+func (q *countingQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	set := q.Querier.Select(sortSeries, hints, matchers...)
+
+	var all []storage.Series
+	for set.Next() {
+		all = append(all, set.At())
+	}
+	*q.selected += len(all)
+
+	if err := set.Err(); err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+	return series.NewConcreteSeriesSet(all)
+}
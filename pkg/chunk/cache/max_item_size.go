@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+type maxItemSizeCache struct {
+	Cache
+	maxItemSize int
+
+	skippedItems prometheus.Counter
+}
+
+// NewMaxItemSize returns a new Cache that doesn't store the items bigger than maxItemSize bytes,
+// which would be rejected by the backend (e.g. memcached) anyway, after being sent over the network.
+func NewMaxItemSize(name string, maxItemSize int, cache Cache, reg prometheus.Registerer) Cache {
+	return &maxItemSizeCache{
+		Cache:       cache,
+		maxItemSize: maxItemSize,
+		skippedItems: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Namespace:   "cortex",
+			Name:        "cache_skipped_items_too_large_total",
+			Help:        "Total count of items not stored in the cache because bigger than the max item size.",
+			ConstLabels: prometheus.Labels{"name": name},
+		}),
+	}
+}
+
+// Store implements Cache.
+func (c *maxItemSizeCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	storeKeys := make([]string, 0, len(keys))
+	storeBufs := make([][]byte, 0, len(bufs))
+
+	for i := range keys {
+		if len(bufs[i]) > c.maxItemSize {
+			c.skippedItems.Inc()
+			continue
+		}
+
+		storeKeys = append(storeKeys, keys[i])
+		storeBufs = append(storeBufs, bufs[i])
+	}
+
+	if len(storeKeys) > 0 {
+		c.Cache.Store(ctx, storeKeys, storeBufs)
+	}
+}
@@ -0,0 +1,33 @@
+package cache_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+func TestMaxItemSizeCache(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	c := cache.NewMaxItemSize("test", 3, cache.NewMockCache(), reg)
+
+	c.Store(ctx, []string{"a", "b", "c"}, [][]byte{[]byte("1"), []byte("1234"), []byte("123")})
+
+	found, bufs, missing := c.Fetch(ctx, []string{"a", "b", "c"})
+	assert.Equal(t, []string{"a", "c"}, found)
+	assert.Equal(t, [][]byte{[]byte("1"), []byte("123")}, bufs)
+	assert.Equal(t, []string{"b"}, missing)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_cache_skipped_items_too_large_total Total count of items not stored in the cache because bigger than the max item size.
+		# TYPE cortex_cache_skipped_items_too_large_total counter
+		cortex_cache_skipped_items_too_large_total{name="test"} 1
+	`)))
+}
@@ -89,6 +89,12 @@ func (c *RedisClient) Ping(ctx context.Context) error {
 }
 
 func (c *RedisClient) MSet(ctx context.Context, keys []string, values [][]byte) error {
+	return c.MSetWithExpiration(ctx, keys, values, c.expiration)
+}
+
+// MSetWithExpiration is like MSet but stores the keys with the input expiration
+// instead of the configured one.
+func (c *RedisClient) MSetWithExpiration(ctx context.Context, keys []string, values [][]byte, expiration time.Duration) error {
 	var cancel context.CancelFunc
 	if c.timeout > 0 {
 		ctx, cancel = context.WithTimeout(ctx, c.timeout)
@@ -97,7 +103,7 @@ func (c *RedisClient) MSet(ctx context.Context, keys []string, values [][]byte)
 
 	pipe := c.rdb.TxPipeline()
 	for i := range keys {
-		pipe.Set(ctx, keys[i], values[i], c.expiration)
+		pipe.Set(ctx, keys[i], values[i], expiration)
 	}
 	_, err := pipe.Exec(ctx)
 	return err
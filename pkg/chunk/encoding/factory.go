@@ -25,12 +25,16 @@ func (Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&bigchunkSizeCapBytes, "store.bigchunk-size-cap-bytes", bigchunkSizeCapBytes, "When using bigchunk encoding, start a new bigchunk if over this size (0 = unlimited)")
 }
 
-// Validate errors out if the encoding is set to Delta.
+// Validate errors out if the encoding is set to Delta or PrometheusXorChunk.
 func (Config) Validate() error {
 	if DefaultEncoding == Delta {
 		// Delta is deprecated.
 		return errors.New("delta encoding is deprecated")
 	}
+	if DefaultEncoding == PrometheusXorChunk {
+		// PrometheusXorChunk is read-only, and only used to transfer chunks to the queriers.
+		return errors.New("PrometheusXorChunk encoding is not supported for ingested chunks")
+	}
 	return nil
 }
 
@@ -52,6 +56,8 @@ const (
 	Varbit
 	// Bigchunk encoding
 	Bigchunk
+	// PrometheusXorChunk is a wrapper around Prometheus XOR-encoded chunk.
+	PrometheusXorChunk
 )
 
 type encoding struct {
@@ -78,6 +84,12 @@ var encodings = map[Encoding]encoding{
 			return newBigchunk()
 		},
 	},
+	PrometheusXorChunk: {
+		Name: "PrometheusXorChunk",
+		New: func() Chunk {
+			return newPrometheusXorChunk()
+		},
+	},
 }
 
 // Set implements flag.Value.
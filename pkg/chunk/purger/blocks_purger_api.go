@@ -2,38 +2,176 @@ package purger
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"path"
 	"strings"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/oklog/ulid"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/thanos-io/thanos/pkg/objstore"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
 type BlocksPurgerAPI struct {
-	bucketClient objstore.Bucket
-	logger       log.Logger
+	bucketClient              objstore.Bucket
+	logger                    log.Logger
+	deleteRequestCancelPeriod time.Duration
 }
 
-func NewBlocksPurgerAPI(storageCfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (*BlocksPurgerAPI, error) {
+func NewBlocksPurgerAPI(storageCfg cortex_tsdb.BlocksStorageConfig, deleteRequestCancelPeriod time.Duration, logger log.Logger, reg prometheus.Registerer) (*BlocksPurgerAPI, error) {
 	bucketClient, err := createBucketClient(storageCfg, logger, reg)
 	if err != nil {
 		return nil, err
 	}
 
-	return newBlocksPurgerAPI(bucketClient, logger), nil
+	return newBlocksPurgerAPI(bucketClient, deleteRequestCancelPeriod, logger), nil
 }
 
-func newBlocksPurgerAPI(bkt objstore.Bucket, logger log.Logger) *BlocksPurgerAPI {
-	return &BlocksPurgerAPI{bucketClient: bkt, logger: logger}
+func newBlocksPurgerAPI(bkt objstore.Bucket, deleteRequestCancelPeriod time.Duration, logger log.Logger) *BlocksPurgerAPI {
+	return &BlocksPurgerAPI{bucketClient: bkt, deleteRequestCancelPeriod: deleteRequestCancelPeriod, logger: logger}
+}
+
+// AddDeleteRequestHandler stores a tombstone for the series matching the request selectors.
+func (api *BlocksPurgerAPI) AddDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	params := r.URL.Query()
+	match := params["match[]"]
+	if len(match) == 0 {
+		http.Error(w, "selectors not set", http.StatusBadRequest)
+		return
+	}
+
+	for i := range match {
+		_, err := parser.ParseMetricSelector(match[i])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	startTime := int64(0)
+	if startParam := params.Get("start"); startParam != "" {
+		startTime, err = util.ParseTime(startParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	endTime := int64(model.Now())
+	if endParam := params.Get("end"); endParam != "" {
+		endTime, err = util.ParseTime(endParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if endTime > int64(model.Now()) {
+			http.Error(w, "deletes in future not allowed", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if startTime > endTime {
+		http.Error(w, "start time can't be greater than end time", http.StatusBadRequest)
+		return
+	}
+
+	tombstone := cortex_tsdb.NewTombstone(userID, int64(model.Now()), startTime, endTime, match)
+	if err := cortex_tsdb.WriteTombstone(ctx, api.bucketClient, userID, tombstone); err != nil {
+		level.Error(api.logger).Log("msg", "error adding delete request to the bucket", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(api.logger).Log("msg", "delete request created", "user", userID, "request_id", tombstone.RequestID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetAllDeleteRequestsHandler returns all the delete requests of the tenant.
+func (api *BlocksPurgerAPI) GetAllDeleteRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tombstones, err := cortex_tsdb.ReadTombstones(ctx, api.bucketClient, userID, api.logger)
+	if err != nil {
+		level.Error(api.logger).Log("msg", "error getting delete requests from the bucket", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	deleteRequests := make([]DeleteRequest, 0, len(tombstones))
+	for _, t := range tombstones {
+		deleteRequests = append(deleteRequests, tombstoneToDeleteRequest(userID, t))
+	}
+
+	util.WriteJSONResponse(w, deleteRequests)
+}
+
+// CancelDeleteRequestHandler deletes the tombstone of a delete request, as long as
+// it's still within the cancellation period.
+func (api *BlocksPurgerAPI) CancelDeleteRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	requestID := r.URL.Query().Get("request_id")
+
+	tombstone, err := cortex_tsdb.ReadTombstone(ctx, api.bucketClient, userID, requestID, api.logger)
+	if err != nil {
+		level.Error(api.logger).Log("msg", "error getting delete request from the bucket", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if tombstone == nil {
+		http.Error(w, "could not find delete request with given id", http.StatusBadRequest)
+		return
+	}
+
+	if tombstone.State != cortex_tsdb.TombstonePending {
+		http.Error(w, "deletion of request which is in process or already processed is not allowed", http.StatusBadRequest)
+		return
+	}
+
+	if model.Time(tombstone.RequestCreatedAt).Add(api.deleteRequestCancelPeriod).Before(model.Now()) {
+		http.Error(w, fmt.Sprintf("deletion of request past the deadline of %s since its creation is not allowed", api.deleteRequestCancelPeriod.String()), http.StatusBadRequest)
+		return
+	}
+
+	if err := cortex_tsdb.DeleteTombstone(ctx, api.bucketClient, userID, requestID); err != nil {
+		level.Error(api.logger).Log("msg", "error cancelling the delete request", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (api *BlocksPurgerAPI) DeleteTenant(w http.ResponseWriter, r *http.Request) {
@@ -57,7 +195,10 @@ func (api *BlocksPurgerAPI) DeleteTenant(w http.ResponseWriter, r *http.Request)
 
 type DeleteTenantStatusResponse struct {
 	TenantID                  string `json:"tenant_id"`
+	MarkedForDeletion         bool   `json:"marked_for_deletion"`
+	DeletionRequestedAt       int64  `json:"deletion_requested_at,omitempty"`
 	BlocksDeleted             bool   `json:"blocks_deleted"`
+	BucketIndexDeleted        bool   `json:"bucket_index_deleted"`
 	RuleGroupsDeleted         bool   `json:"rule_groups_deleted"`
 	AlertManagerConfigDeleted bool   `json:"alert_manager_config_deleted"`
 }
@@ -72,12 +213,29 @@ func (api *BlocksPurgerAPI) DeleteTenantStatus(w http.ResponseWriter, r *http.Re
 
 	result := DeleteTenantStatusResponse{}
 	result.TenantID = userID
+
+	mark, err := cortex_tsdb.ReadTenantDeletionMark(ctx, api.bucketClient, userID, api.logger)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if mark != nil {
+		result.MarkedForDeletion = true
+		result.DeletionRequestedAt = mark.DeletionTime
+	}
+
 	result.BlocksDeleted, err = api.checkBlocksForUser(ctx, userID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	indexExists, err := api.bucketClient.Exists(ctx, path.Join(userID, bucketindex.IndexCompressedFilename))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	result.BucketIndexDeleted = !indexExists
 
 	util.WriteJSONResponse(w, result)
 }
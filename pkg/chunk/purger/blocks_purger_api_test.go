@@ -3,22 +3,26 @@ package purger
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"path"
 	"testing"
+	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/weaveworks/common/user"
 
 	"github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 )
 
 func TestDeleteTenant(t *testing.T) {
 	bkt := objstore.NewInMemBucket()
-	api := newBlocksPurgerAPI(bkt, log.NewNopLogger())
+	api := newBlocksPurgerAPI(bkt, time.Hour, log.NewNopLogger())
 
 	{
 		resp := httptest.NewRecorder()
@@ -80,7 +84,7 @@ func TestDeleteTenantStatus(t *testing.T) {
 				require.NoError(t, bkt.Upload(context.Background(), objName, bytes.NewReader(data)))
 			}
 
-			api := newBlocksPurgerAPI(bkt, log.NewNopLogger())
+			api := newBlocksPurgerAPI(bkt, time.Hour, log.NewNopLogger())
 
 			res, err := api.checkBlocksForUser(context.Background(), username)
 			require.NoError(t, err)
@@ -88,3 +92,118 @@ func TestDeleteTenantStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteTenantStatus_ShouldReportDeletionProgress(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	api := newBlocksPurgerAPI(bkt, time.Hour, log.NewNopLogger())
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	getStatus := func() DeleteTenantStatusResponse {
+		req := &http.Request{}
+		resp := httptest.NewRecorder()
+		api.DeleteTenantStatus(resp, req.WithContext(ctx))
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		status := DeleteTenantStatusResponse{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &status))
+		return status
+	}
+
+	require.NoError(t, bkt.Upload(ctx, "user/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json", bytes.NewReader([]byte("data"))))
+	require.NoError(t, bkt.Upload(ctx, path.Join("user", bucketindex.IndexCompressedFilename), bytes.NewReader([]byte("data"))))
+
+	status := getStatus()
+	require.Equal(t, "user", status.TenantID)
+	require.False(t, status.MarkedForDeletion)
+	require.False(t, status.BlocksDeleted)
+	require.False(t, status.BucketIndexDeleted)
+
+	// Mark the tenant for deletion.
+	require.NoError(t, tsdb.WriteTenantDeletionMark(ctx, bkt, "user"))
+
+	status = getStatus()
+	require.True(t, status.MarkedForDeletion)
+	require.NotZero(t, status.DeletionRequestedAt)
+	require.False(t, status.BlocksDeleted)
+
+	// Simulate the cleanup done by the compactor.
+	require.NoError(t, bkt.Delete(ctx, "user/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json"))
+	require.NoError(t, bkt.Delete(ctx, path.Join("user", bucketindex.IndexCompressedFilename)))
+
+	status = getStatus()
+	require.True(t, status.MarkedForDeletion)
+	require.True(t, status.BlocksDeleted)
+	require.True(t, status.BucketIndexDeleted)
+}
+
+func TestBlocksPurgerAPI_DeleteSeries(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	api := newBlocksPurgerAPI(bkt, time.Hour, log.NewNopLogger())
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	call := func(handler http.HandlerFunc, query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/admin/tsdb/delete_series?"+query, nil)
+		resp := httptest.NewRecorder()
+		handler(resp, req.WithContext(ctx))
+		return resp
+	}
+
+	// Invalid requests.
+	require.Equal(t, http.StatusBadRequest, call(api.AddDeleteRequestHandler, "").Code)
+	require.Equal(t, http.StatusBadRequest, call(api.AddDeleteRequestHandler, "match[]={job=").Code)
+	require.Equal(t, http.StatusBadRequest, call(api.AddDeleteRequestHandler, "match[]={job=\"a\"}&start=20&end=10").Code)
+
+	// Valid request.
+	require.Equal(t, http.StatusNoContent, call(api.AddDeleteRequestHandler, "match[]={job=\"a\"}&start=10&end=20").Code)
+
+	resp := call(api.GetAllDeleteRequestsHandler, "")
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var requests []DeleteRequest
+	require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &requests))
+	require.Len(t, requests, 1)
+	require.Equal(t, model.Time(10000), requests[0].StartTime)
+	require.Equal(t, model.Time(20000), requests[0].EndTime)
+	require.Equal(t, []string{`{job="a"}`}, requests[0].Selectors)
+	require.Equal(t, StatusReceived, requests[0].Status)
+
+	// The delete request should be exposed to the tombstones loader.
+	store := newBlocksDeleteStore(bkt, log.NewNopLogger())
+	pending, err := store.GetPendingDeleteRequestsForUser(ctx, "user")
+	require.NoError(t, err)
+	require.Equal(t, requests[0].RequestID, pending[0].RequestID)
+
+	genNumbers, err := store.getCacheGenerationNumbers(ctx, "user")
+	require.NoError(t, err)
+	require.NotEmpty(t, genNumbers.results)
+
+	// Cancel the request.
+	require.Equal(t, http.StatusBadRequest, call(api.CancelDeleteRequestHandler, "request_id=unknown").Code)
+	require.Equal(t, http.StatusNoContent, call(api.CancelDeleteRequestHandler, "request_id="+requests[0].RequestID).Code)
+
+	pending, err = store.GetPendingDeleteRequestsForUser(ctx, "user")
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	genNumbers, err = store.getCacheGenerationNumbers(ctx, "user")
+	require.NoError(t, err)
+	require.Empty(t, genNumbers.results)
+}
+
+func TestBlocksPurgerAPI_CancelDeleteRequest_ShouldFailPastTheCancelPeriod(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	api := newBlocksPurgerAPI(bkt, time.Hour, log.NewNopLogger())
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	tombstone := tsdb.NewTombstone("user", int64(model.Now().Add(-2*time.Hour)), 0, 10, []string{`{job="a"}`})
+	require.NoError(t, tsdb.WriteTombstone(ctx, bkt, "user", tombstone))
+
+	req := httptest.NewRequest("POST", "/api/v1/admin/tsdb/cancel_delete_request?request_id="+tombstone.RequestID, nil)
+	resp := httptest.NewRecorder()
+	api.CancelDeleteRequestHandler(resp, req.WithContext(ctx))
+	require.Equal(t, http.StatusBadRequest, resp.Code)
+
+	actual, err := tsdb.ReadTombstone(ctx, bkt, "user", tombstone.RequestID, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NotNil(t, actual)
+}
@@ -0,0 +1,97 @@
+package purger
+
+import (
+	"context"
+	"encoding/hex"
+	"hash/fnv"
+	"sort"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+// blocksDeleteStore exposes the tombstones stored in the blocks storage bucket
+// to the TombstonesLoader.
+type blocksDeleteStore struct {
+	bucketClient objstore.Bucket
+	logger       log.Logger
+}
+
+// NewBlocksTombstonesLoader creates a TombstonesLoader reading the delete requests
+// from the blocks storage bucket.
+func NewBlocksTombstonesLoader(storageCfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, reg prometheus.Registerer) (*TombstonesLoader, error) {
+	bucketClient, err := createBucketClient(storageCfg, logger, reg)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewTombstonesLoader(newBlocksDeleteStore(bucketClient, logger), reg), nil
+}
+
+func newBlocksDeleteStore(bkt objstore.Bucket, logger log.Logger) *blocksDeleteStore {
+	return &blocksDeleteStore{bucketClient: bkt, logger: logger}
+}
+
+// GetPendingDeleteRequestsForUser returns the delete requests to apply at query time. Processed
+// tombstones are returned as well, because their series may still be in the ingesters or in
+// blocks uploaded after the tombstone has been processed.
+func (s *blocksDeleteStore) GetPendingDeleteRequestsForUser(ctx context.Context, userID string) ([]DeleteRequest, error) {
+	tombstones, err := cortex_tsdb.ReadTombstones(ctx, s.bucketClient, userID, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	deleteRequests := make([]DeleteRequest, 0, len(tombstones))
+	for _, t := range tombstones {
+		deleteRequests = append(deleteRequests, tombstoneToDeleteRequest(userID, t))
+	}
+
+	return deleteRequests, nil
+}
+
+// getCacheGenerationNumbers returns a gen number which changes whenever a tombstone
+// is added, cancelled or processed.
+func (s *blocksDeleteStore) getCacheGenerationNumbers(ctx context.Context, userID string) (*cacheGenNumbers, error) {
+	tombstones, err := cortex_tsdb.ReadTombstones(ctx, s.bucketClient, userID, s.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tombstones) == 0 {
+		return &cacheGenNumbers{}, nil
+	}
+
+	sort.Slice(tombstones, func(i, j int) bool {
+		return tombstones[i].RequestID < tombstones[j].RequestID
+	})
+
+	h := fnv.New64a()
+	for _, t := range tombstones {
+		_, _ = h.Write([]byte(t.RequestID))
+		_, _ = h.Write([]byte(t.State))
+	}
+
+	genNumber := hex.EncodeToString(h.Sum(nil))
+	return &cacheGenNumbers{store: genNumber, results: genNumber}, nil
+}
+
+func tombstoneToDeleteRequest(userID string, t *cortex_tsdb.Tombstone) DeleteRequest {
+	status := StatusReceived
+	if t.State == cortex_tsdb.TombstoneProcessed {
+		status = StatusProcessed
+	}
+
+	return DeleteRequest{
+		RequestID: t.RequestID,
+		UserID:    userID,
+		StartTime: model.Time(t.StartTime),
+		EndTime:   model.Time(t.EndTime),
+		Selectors: t.Selectors,
+		Status:    status,
+		CreatedAt: model.Time(t.RequestCreatedAt),
+	}
+}
@@ -3,6 +3,7 @@ package purger
 import (
 	"context"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -158,6 +159,11 @@ func (tl *TombstonesLoader) GetPendingTombstones(userID string) (*TombstonesSet,
 	}
 
 	tl.tombstonesMtx.RUnlock()
+
+	// Make sure the gen numbers are loaded before the tombstones, otherwise
+	// the tombstones of the user would never be reloaded on updates.
+	tl.getCacheGenNumbers(userID)
+
 	err := tl.loadPendingTombstones(userID)
 	if err != nil {
 		return nil, err
@@ -251,9 +257,29 @@ func (tl *TombstonesLoader) GetStoreCacheGenNumber(userID string) string {
 
 }
 
-// GetResultsCacheGenNumber returns results cache gen number for a user
-func (tl *TombstonesLoader) GetResultsCacheGenNumber(userID string) string {
-	return tl.getCacheGenNumbers(userID).results
+// GetResultsCacheGenNumber returns results cache gen number for the tenants. The gen numbers
+// of multiple tenants are joined, so that the results cached for a query spanning the tenants
+// are invalidated when the gen number of any of them changes.
+func (tl *TombstonesLoader) GetResultsCacheGenNumber(tenantIDs []string) string {
+	if len(tenantIDs) == 1 {
+		return tl.getCacheGenNumbers(tenantIDs[0]).results
+	}
+
+	var (
+		genNumbers = make([]string, 0, len(tenantIDs))
+		anySet     = false
+	)
+
+	for _, tenantID := range tenantIDs {
+		genNumber := tl.getCacheGenNumbers(tenantID).results
+		genNumbers = append(genNumbers, genNumber)
+		anySet = anySet || genNumber != ""
+	}
+
+	if !anySet {
+		return ""
+	}
+	return strings.Join(genNumbers, ",")
 }
 
 func (tl *TombstonesLoader) getCacheGenNumbers(userID string) *cacheGenNumbers {
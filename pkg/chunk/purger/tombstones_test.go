@@ -145,6 +145,18 @@ func TestTombstonesReloadDoesntDeadlockOnFailure(t *testing.T) {
 	require.NotNil(t, tombstonesLoader.getCacheGenNumbers("test2"))
 }
 
+func TestTombstonesLoader_GetResultsCacheGenNumber(t *testing.T) {
+	tombstonesLoader := NewTombstonesLoader(nil, nil)
+	tombstonesLoader.cacheGenNumbers["user-1"] = &cacheGenNumbers{results: "1"}
+	tombstonesLoader.cacheGenNumbers["user-2"] = &cacheGenNumbers{results: "2"}
+
+	require.Equal(t, "1", tombstonesLoader.GetResultsCacheGenNumber([]string{"user-1"}))
+	require.Equal(t, "", tombstonesLoader.GetResultsCacheGenNumber([]string{"user-3"}))
+	require.Equal(t, "1,2", tombstonesLoader.GetResultsCacheGenNumber([]string{"user-1", "user-2"}))
+	require.Equal(t, "1,", tombstonesLoader.GetResultsCacheGenNumber([]string{"user-1", "user-3"}))
+	require.Equal(t, "", tombstonesLoader.GetResultsCacheGenNumber([]string{"user-3", "user-4"}))
+}
+
 type store struct {
 	err error
 }
@@ -0,0 +1,445 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	// uploadingMetaFilename is the meta.json of a block being uploaded via the blocks upload API. The
+	// actual meta.json is uploaded only once the upload has completed and the block has been validated,
+	// so that queriers and store-gateways don't discover the block until then.
+	uploadingMetaFilename = "uploading-" + block.MetaFilename
+
+	// blockUploadSource is the source of the blocks uploaded via the blocks upload API.
+	blockUploadSource metadata.SourceType = "upload"
+
+	// maxBlockUploadMetaSize is the max size of the meta.json sent to start the upload of a block.
+	maxBlockUploadMetaSize = 1024 * 1024
+)
+
+var (
+	errBlockUploadNotStarted = errors.New("the upload of the block has not been started")
+
+	// Files of a block which can be uploaded via the blocks upload API.
+	blockUploadFilePathRegexp = regexp.MustCompile(`^(index|chunks/\d{6})$`)
+)
+
+// StartBlockUpload starts the upload of a block, validating the block meta.json received in the request body.
+func (c *Compactor) StartBlockUpload(w http.ResponseWriter, r *http.Request) {
+	userID, blockID, status, err := c.parseBlockUploadRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx := r.Context()
+	logger := log.With(util.WithUserID(userID, c.logger), "block", blockID.String())
+	userBucket := bucket.NewSSEBucketClient(userID, bucket.NewUserBucketClient(userID, c.bucketClient), c.cfgProvider)
+
+	exists, err := userBucket.Exists(ctx, path.Join(blockID.String(), block.MetaFilename))
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to check if the uploaded block exists", "err", err)
+		http.Error(w, "failed to check if the block exists", http.StatusInternalServerError)
+		return
+	}
+	if exists {
+		http.Error(w, "the block already exists", http.StatusConflict)
+		return
+	}
+
+	meta := metadata.Meta{}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBlockUploadMetaSize)).Decode(&meta); err != nil {
+		http.Error(w, fmt.Sprintf("malformed block meta: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := c.validateUploadedMeta(userID, blockID, &meta); err != nil {
+		http.Error(w, fmt.Sprintf("invalid block meta: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	content, err := json.Marshal(meta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := userBucket.Upload(ctx, path.Join(blockID.String(), uploadingMetaFilename), bytes.NewReader(content)); err != nil {
+		level.Error(logger).Log("msg", "failed to upload the block meta", "err", err)
+		http.Error(w, "failed to upload the block meta", http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(logger).Log("msg", "started block upload", "min_time", meta.MinTime, "max_time", meta.MaxTime)
+	w.WriteHeader(http.StatusOK)
+}
+
+// UploadBlockFile uploads a file of a block whose upload has been started. The file path,
+// relative to the block directory, is passed in the "path" query parameter.
+func (c *Compactor) UploadBlockFile(w http.ResponseWriter, r *http.Request) {
+	userID, blockID, status, err := c.parseBlockUploadRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx := r.Context()
+	logger := log.With(util.WithUserID(userID, c.logger), "block", blockID.String())
+	userBucket := bucket.NewSSEBucketClient(userID, bucket.NewUserBucketClient(userID, c.bucketClient), c.cfgProvider)
+
+	relPath := r.URL.Query().Get("path")
+	if !blockUploadFilePathRegexp.MatchString(relPath) {
+		http.Error(w, fmt.Sprintf("invalid file path %q", relPath), http.StatusBadRequest)
+		return
+	}
+
+	meta, err := readUploadingMeta(ctx, userBucket, blockID, logger)
+	if errors.Is(err, errBlockUploadNotStarted) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read the block meta", "err", err)
+		http.Error(w, "failed to read the block meta", http.StatusInternalServerError)
+		return
+	}
+
+	file, ok := findFileInMeta(meta, relPath)
+	if !ok {
+		http.Error(w, fmt.Sprintf("the file %q is not listed in the block meta", relPath), http.StatusBadRequest)
+		return
+	}
+
+	// The file can't be larger than the size declared in the block meta.
+	if r.ContentLength > file.SizeBytes {
+		http.Error(w, fmt.Sprintf("the file %q is larger than its size %d listed in the block meta", relPath, file.SizeBytes), http.StatusRequestEntityTooLarge)
+		return
+	}
+	body := http.MaxBytesReader(w, r.Body, file.SizeBytes)
+
+	if err := userBucket.Upload(ctx, path.Join(blockID.String(), relPath), body); err != nil {
+		level.Error(logger).Log("msg", "failed to upload the block file", "path", relPath, "err", err)
+		http.Error(w, "failed to upload the block file", http.StatusInternalServerError)
+		return
+	}
+
+	level.Debug(logger).Log("msg", "uploaded block file", "path", relPath)
+	w.WriteHeader(http.StatusOK)
+}
+
+// FinishBlockUpload completes the upload of a block. The block is validated and, if valid,
+// its meta.json is uploaded so that the block gets discovered by the other Cortex services.
+func (c *Compactor) FinishBlockUpload(w http.ResponseWriter, r *http.Request) {
+	userID, blockID, status, err := c.parseBlockUploadRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx := r.Context()
+	logger := log.With(util.WithUserID(userID, c.logger), "block", blockID.String())
+	userBucket := bucket.NewSSEBucketClient(userID, bucket.NewUserBucketClient(userID, c.bucketClient), c.cfgProvider)
+
+	meta, err := readUploadingMeta(ctx, userBucket, blockID, logger)
+	if errors.Is(err, errBlockUploadNotStarted) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", "failed to read the block meta", "err", err)
+		http.Error(w, "failed to read the block meta", http.StatusInternalServerError)
+		return
+	}
+
+	if err := c.validateUploadedBlock(ctx, userBucket, meta, logger); err != nil {
+		c.blockUploadValidationFailures.Inc()
+		level.Warn(logger).Log("msg", "uploaded block failed validation", "err", err)
+		http.Error(w, fmt.Sprintf("invalid block: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	content, err := json.Marshal(meta)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The meta.json is uploaded last, like for any other block.
+	if err := userBucket.Upload(ctx, path.Join(blockID.String(), block.MetaFilename), bytes.NewReader(content)); err != nil {
+		level.Error(logger).Log("msg", "failed to upload the block meta.json", "err", err)
+		http.Error(w, "failed to upload the block meta.json", http.StatusInternalServerError)
+		return
+	}
+
+	if err := userBucket.Delete(ctx, path.Join(blockID.String(), uploadingMetaFilename)); err != nil {
+		level.Warn(logger).Log("msg", "failed to delete the uploading block meta", "err", err)
+	}
+
+	c.blocksUploaded.Inc()
+	level.Info(logger).Log("msg", "successfully completed block upload")
+	w.WriteHeader(http.StatusOK)
+}
+
+// parseBlockUploadRequest returns the tenant and block of a blocks upload API request.
+// If the request is not valid, returns the error and the HTTP status code to respond with.
+func (c *Compactor) parseBlockUploadRequest(r *http.Request) (string, ulid.ULID, int, error) {
+	if c.State() != services.Running {
+		return "", ulid.ULID{}, http.StatusServiceUnavailable, errors.New("compactor is not running")
+	}
+
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		return "", ulid.ULID{}, http.StatusBadRequest, err
+	}
+
+	if !c.cfgProvider.CompactorBlockUploadEnabled(userID) {
+		return "", ulid.ULID{}, http.StatusForbidden, errors.New("block upload is disabled for the tenant")
+	}
+
+	blockID, err := ulid.Parse(mux.Vars(r)["block"])
+	if err != nil {
+		return "", ulid.ULID{}, http.StatusBadRequest, errors.Wrap(err, "invalid block ID")
+	}
+
+	return userID, blockID, 0, nil
+}
+
+// validateUploadedMeta validates the meta.json of a block to upload, and sets the
+// Cortex specific fields of the meta.json.
+func (c *Compactor) validateUploadedMeta(userID string, blockID ulid.ULID, meta *metadata.Meta) error {
+	if meta.ULID != blockID {
+		return fmt.Errorf("the block ID %s doesn't match the meta.json block ID %s", blockID.String(), meta.ULID.String())
+	}
+
+	if meta.Version != metadata.TSDBVersion1 {
+		return fmt.Errorf("unsupported block meta version %d", meta.Version)
+	}
+
+	if meta.MinTime < 0 || meta.MaxTime <= meta.MinTime {
+		return fmt.Errorf("invalid block time range (min time: %d, max time: %d)", meta.MinTime, meta.MaxTime)
+	}
+
+	if meta.MaxTime > util.TimeToMillis(time.Now()) {
+		return fmt.Errorf("the block max time %d is in the future", meta.MaxTime)
+	}
+
+	if ranges := c.compactorCfg.BlockRanges.ToMilliseconds(); len(ranges) > 0 && meta.MaxTime-meta.MinTime > ranges[len(ranges)-1] {
+		return fmt.Errorf("the block time range is longer than the largest compaction block range %s", c.compactorCfg.BlockRanges[len(ranges)-1].String())
+	}
+
+	for name, value := range meta.Thanos.Labels {
+		if name == cortex_tsdb.TenantIDExternalLabel && value == userID {
+			continue
+		}
+		return fmt.Errorf("unsupported block external label %s=%q", name, value)
+	}
+
+	if meta.Thanos.Downsample.Resolution != 0 {
+		return errors.New("downsampled blocks are not supported")
+	}
+
+	hasIndex, hasChunks := false, false
+	files := make([]metadata.File, 0, len(meta.Thanos.Files))
+	for _, f := range meta.Thanos.Files {
+		// The meta.json is not uploaded as a file, because it's generated from the uploading meta.
+		if f.RelPath == block.MetaFilename {
+			continue
+		}
+		if !blockUploadFilePathRegexp.MatchString(f.RelPath) {
+			return fmt.Errorf("unsupported block file %q", f.RelPath)
+		}
+		if f.SizeBytes <= 0 {
+			return fmt.Errorf("missing size of the block file %q", f.RelPath)
+		}
+
+		if f.RelPath == block.IndexFilename {
+			hasIndex = true
+		} else {
+			hasChunks = true
+		}
+		files = append(files, f)
+	}
+	if !hasIndex || !hasChunks {
+		return errors.New("the block files must include the index and the chunks")
+	}
+
+	// Blocks are uploaded with the same external labels of the blocks shipped by ingesters,
+	// so that they're compacted together.
+	meta.Thanos.Version = metadata.ThanosVersion1
+	meta.Thanos.Files = files
+	meta.Thanos.Labels = map[string]string{cortex_tsdb.TenantIDExternalLabel: userID}
+	meta.Thanos.Source = blockUploadSource
+
+	// The uploaded block is a level 1 block whose only source is itself, whatever the client sent:
+	// a block without sources would be considered included in any other block, while a block listing
+	// other blocks as sources would hide them, and both would be filtered out by the deduplication.
+	meta.Compaction = tsdb.BlockMetaCompaction{
+		Level:   1,
+		Sources: []ulid.ULID{blockID},
+	}
+
+	return nil
+}
+
+// validateUploadedBlock downloads the block whose upload has completed and validates its index and chunks.
+func (c *Compactor) validateUploadedBlock(ctx context.Context, userBucket objstore.Bucket, meta *metadata.Meta, logger log.Logger) error {
+	if err := os.MkdirAll(filepath.Join(c.compactorCfg.DataDir, "upload"), os.ModePerm); err != nil {
+		return errors.Wrap(err, "create the upload directory")
+	}
+
+	dir, err := ioutil.TempDir(filepath.Join(c.compactorCfg.DataDir, "upload"), meta.ULID.String())
+	if err != nil {
+		return errors.Wrap(err, "create the block directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			level.Warn(logger).Log("msg", "failed to remove the uploaded block directory", "dir", dir, "err", err)
+		}
+	}()
+
+	for _, f := range meta.Thanos.Files {
+		dst := filepath.Join(dir, filepath.FromSlash(f.RelPath))
+		if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+			return errors.Wrap(err, "create the block directory")
+		}
+		if err := objstore.DownloadFile(ctx, logger, userBucket, path.Join(meta.ULID.String(), f.RelPath), dst); err != nil {
+			if userBucket.IsObjNotFoundErr(errors.Cause(err)) {
+				return fmt.Errorf("the block file %q has not been uploaded", f.RelPath)
+			}
+			return errors.Wrapf(err, "download block file %q", f.RelPath)
+		}
+
+		info, err := os.Stat(dst)
+		if err != nil {
+			return err
+		}
+		if info.Size() != f.SizeBytes {
+			return fmt.Errorf("the size of the block file %q is %d bytes, while %d bytes are listed in the block meta", f.RelPath, info.Size(), f.SizeBytes)
+		}
+	}
+
+	if err := meta.WriteToDir(logger, dir); err != nil {
+		return errors.Wrap(err, "write block meta")
+	}
+
+	if err := block.VerifyIndex(logger, filepath.Join(dir, block.IndexFilename), meta.MinTime, meta.MaxTime); err != nil {
+		return errors.Wrap(err, "index")
+	}
+
+	return verifyBlockChunks(dir, meta, logger)
+}
+
+// verifyBlockChunks reads all the chunks of the block in dir, checking they can be
+// decoded and their samples are within the block time range.
+func verifyBlockChunks(dir string, meta *metadata.Meta, logger log.Logger) error {
+	b, err := tsdb.OpenBlock(logger, dir, nil)
+	if err != nil {
+		return errors.Wrap(err, "open block")
+	}
+	defer runutil.CloseWithLogOnErr(logger, b, "close uploaded block")
+
+	idx, err := b.Index()
+	if err != nil {
+		return errors.Wrap(err, "open block index")
+	}
+	defer runutil.CloseWithLogOnErr(logger, idx, "close uploaded block index")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return errors.Wrap(err, "open block chunks")
+	}
+	defer runutil.CloseWithLogOnErr(logger, chunkr, "close uploaded block chunks")
+
+	p, err := idx.Postings(index.AllPostingsKey())
+	if err != nil {
+		return errors.Wrap(err, "read block postings")
+	}
+
+	var (
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+
+	for p.Next() {
+		if err := idx.Series(p.At(), &lset, &chks); err != nil {
+			return errors.Wrap(err, "read series")
+		}
+
+		for _, chk := range chks {
+			c, err := chunkr.Chunk(chk.Ref)
+			if err != nil {
+				return errors.Wrapf(err, "read chunk of series %s", lset.String())
+			}
+
+			it := c.Iterator(nil)
+			for it.Next() {
+				if ts, _ := it.At(); ts < meta.MinTime || ts >= meta.MaxTime {
+					return fmt.Errorf("series %s has a sample at %d outside the block time range", lset.String(), ts)
+				}
+			}
+			if err := it.Err(); err != nil {
+				return errors.Wrapf(err, "decode chunk of series %s", lset.String())
+			}
+		}
+	}
+
+	return errors.Wrap(p.Err(), "iterate block postings")
+}
+
+// readUploadingMeta reads the meta.json of a block being uploaded.
+func readUploadingMeta(ctx context.Context, userBucket objstore.Bucket, blockID ulid.ULID, logger log.Logger) (*metadata.Meta, error) {
+	r, err := userBucket.Get(ctx, path.Join(blockID.String(), uploadingMetaFilename))
+	if userBucket.IsObjNotFoundErr(err) {
+		return nil, errBlockUploadNotStarted
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close uploading block meta")
+
+	meta := &metadata.Meta{}
+	if err := json.NewDecoder(r).Decode(meta); err != nil {
+		return nil, errors.Wrap(err, "decode uploading block meta")
+	}
+
+	return meta, nil
+}
+
+func findFileInMeta(meta *metadata.Meta, relPath string) (metadata.File, bool) {
+	for _, f := range meta.Thanos.Files {
+		if f.RelPath == relPath {
+			return f, true
+		}
+	}
+	return metadata.File{}, false
+}
@@ -0,0 +1,268 @@
+package compactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestCompactor_BlockUpload(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	sourceDir, err := ioutil.TempDir(os.TempDir(), "source")
+	require.NoError(t, err)
+	defer os.RemoveAll(sourceDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient)
+
+	// Build the block to upload, like it would be built from a Prometheus snapshot.
+	blockID := createTSDBBlock(t, sourceDir, 10, 20, nil)
+	blockDir := filepath.Join(sourceDir, blockID.String())
+	meta, err := metadata.ReadFromDir(blockDir)
+	require.NoError(t, err)
+
+	for _, relPath := range []string{block.IndexFilename, path.Join(block.ChunksDirname, "000001")} {
+		info, err := os.Stat(filepath.Join(blockDir, relPath))
+		require.NoError(t, err)
+		meta.Thanos.Files = append(meta.Thanos.Files, metadata.File{RelPath: relPath, SizeBytes: info.Size()})
+	}
+
+	metaContent, err := json.Marshal(meta)
+	require.NoError(t, err)
+
+	c, _, tsdbPlanner, _, _, cleanup := prepare(t, prepareConfig(), bucketClient)
+	defer cleanup()
+
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil)
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, c))
+	defer services.StopAndAwaitTerminated(ctx, c) //nolint:errcheck
+
+	request := func(handler http.HandlerFunc, action, filePath string, body []byte) *httptest.ResponseRecorder {
+		target := "/api/v1/upload/block/" + blockID.String() + "/" + action
+		if filePath != "" {
+			target += "?path=" + filePath
+		}
+
+		req := httptest.NewRequest("POST", target, bytes.NewReader(body))
+		req = mux.SetURLVars(req, map[string]string{"block": blockID.String()})
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	uploadFile := func(relPath string) *httptest.ResponseRecorder {
+		content, err := ioutil.ReadFile(filepath.Join(blockDir, relPath))
+		require.NoError(t, err)
+		return request(c.UploadBlockFile, "files", relPath, content)
+	}
+
+	objectExists := func(name string) bool {
+		exists, err := userBucket.Exists(ctx, path.Join(blockID.String(), name))
+		require.NoError(t, err)
+		return exists
+	}
+
+	// The upload is rejected if not enabled for the tenant.
+	rec := request(c.StartBlockUpload, "start", "", metaContent)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	c.cfgProvider.(*mockConfigProvider).blockUploadEnabled = true
+
+	// Files can't be uploaded before starting the upload.
+	rec = uploadFile(block.IndexFilename)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = request(c.StartBlockUpload, "start", "", metaContent)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, objectExists(uploadingMetaFilename))
+
+	// The upload can't be finished until all the files have been uploaded.
+	rec = uploadFile(block.IndexFilename)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = request(c.FinishBlockUpload, "finish", "", nil)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "has not been uploaded")
+	assert.False(t, objectExists(block.MetaFilename))
+
+	// Files not listed in the meta.json can't be uploaded.
+	rec = request(c.UploadBlockFile, "files", "chunks/000002", []byte("chunks"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	rec = request(c.UploadBlockFile, "files", "../meta.json", []byte("{}"))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Files larger than the size listed in the meta.json can't be uploaded.
+	chunksFile, ok := findFileInMeta(meta, path.Join(block.ChunksDirname, "000001"))
+	require.True(t, ok)
+	rec = request(c.UploadBlockFile, "files", chunksFile.RelPath, make([]byte, chunksFile.SizeBytes+1))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	rec = uploadFile(path.Join(block.ChunksDirname, "000001"))
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	rec = request(c.FinishBlockUpload, "finish", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.True(t, objectExists(block.MetaFilename))
+	assert.False(t, objectExists(uploadingMetaFilename))
+
+	uploaded, err := block.DownloadMeta(ctx, c.logger, userBucket, blockID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}, uploaded.Thanos.Labels)
+	assert.Equal(t, blockUploadSource, uploaded.Thanos.Source)
+	assert.Equal(t, meta.MinTime, uploaded.MinTime)
+	assert.Equal(t, meta.MaxTime, uploaded.MaxTime)
+
+	// The block can't be uploaded again.
+	rec = request(c.StartBlockUpload, "start", "", metaContent)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.blocksUploaded))
+	assert.Equal(t, float64(1), testutil.ToFloat64(c.blockUploadValidationFailures))
+}
+
+func TestCompactor_ValidateUploadedMeta(t *testing.T) {
+	blockID := ulid.MustNew(1, nil)
+	now := util.TimeToMillis(time.Now())
+
+	validMeta := func() *metadata.Meta {
+		return &metadata.Meta{
+			BlockMeta: tsdb.BlockMeta{
+				ULID:    blockID,
+				MinTime: now - time.Hour.Milliseconds(),
+				MaxTime: now,
+				Version: metadata.TSDBVersion1,
+			},
+			Thanos: metadata.Thanos{
+				Files: []metadata.File{
+					{RelPath: "index", SizeBytes: 100},
+					{RelPath: "chunks/000001", SizeBytes: 100},
+					{RelPath: "meta.json"},
+				},
+			},
+		}
+	}
+
+	tests := map[string]struct {
+		mutate      func(m *metadata.Meta)
+		expectedErr string
+	}{
+		"valid meta": {
+			mutate: func(m *metadata.Meta) {},
+		},
+		"valid meta with compaction sources of other blocks": {
+			mutate: func(m *metadata.Meta) {
+				m.Compaction = tsdb.BlockMetaCompaction{
+					Level:   3,
+					Sources: []ulid.ULID{ulid.MustNew(2, nil), ulid.MustNew(3, nil)},
+					Parents: []tsdb.BlockDesc{{ULID: ulid.MustNew(2, nil)}},
+				}
+			},
+		},
+		"valid meta with the tenant external label": {
+			mutate: func(m *metadata.Meta) {
+				m.Thanos.Labels = map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}
+			},
+		},
+		"mismatching block ID": {
+			mutate:      func(m *metadata.Meta) { m.ULID = ulid.MustNew(2, nil) },
+			expectedErr: "doesn't match the meta.json block ID",
+		},
+		"unsupported version": {
+			mutate:      func(m *metadata.Meta) { m.Version = 2 },
+			expectedErr: "unsupported block meta version",
+		},
+		"invalid time range": {
+			mutate:      func(m *metadata.Meta) { m.MaxTime = m.MinTime },
+			expectedErr: "invalid block time range",
+		},
+		"max time in the future": {
+			mutate:      func(m *metadata.Meta) { m.MaxTime = now + time.Hour.Milliseconds() },
+			expectedErr: "is in the future",
+		},
+		"time range longer than the largest block range": {
+			mutate:      func(m *metadata.Meta) { m.MinTime = now - 48*time.Hour.Milliseconds() },
+			expectedErr: "longer than the largest compaction block range",
+		},
+		"external label of another tenant": {
+			mutate: func(m *metadata.Meta) {
+				m.Thanos.Labels = map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-2"}
+			},
+			expectedErr: "unsupported block external label",
+		},
+		"downsampled block": {
+			mutate:      func(m *metadata.Meta) { m.Thanos.Downsample.Resolution = 300000 },
+			expectedErr: "downsampled blocks are not supported",
+		},
+		"unsupported file": {
+			mutate: func(m *metadata.Meta) {
+				m.Thanos.Files = append(m.Thanos.Files, metadata.File{RelPath: "tombstones", SizeBytes: 10})
+			},
+			expectedErr: "unsupported block file",
+		},
+		"missing file size": {
+			mutate:      func(m *metadata.Meta) { m.Thanos.Files[0].SizeBytes = 0 },
+			expectedErr: "missing size",
+		},
+		"missing chunks": {
+			mutate:      func(m *metadata.Meta) { m.Thanos.Files = m.Thanos.Files[:1] },
+			expectedErr: "must include the index and the chunks",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			c, _, _, _, _, cleanup := prepare(t, prepareConfig(), nil)
+			defer cleanup()
+
+			meta := validMeta()
+			testData.mutate(meta)
+
+			err := c.validateUploadedMeta("user-1", blockID, meta)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}, meta.Thanos.Labels)
+			assert.Equal(t, blockUploadSource, meta.Thanos.Source)
+			assert.Len(t, meta.Thanos.Files, 2)
+			assert.Equal(t, tsdb.BlockMetaCompaction{Level: 1, Sources: []ulid.ULID{blockID}}, meta.Compaction)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package compactor
 import (
 	"context"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -13,44 +14,46 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
-	"github.com/thanos-io/thanos/pkg/compact"
 	"github.com/thanos-io/thanos/pkg/objstore"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/concurrency"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
 type BlocksCleanerConfig struct {
-	DataDir             string
-	MetaSyncConcurrency int
-	DeletionDelay       time.Duration
-	CleanupInterval     time.Duration
-	CleanupConcurrency  int
+	DeletionDelay              time.Duration
+	PartialBlocksDeletionDelay time.Duration // 0 to disable the deletion of partial blocks without deletion mark.
+	CleanupInterval            time.Duration
+	CleanupConcurrency         int
 }
 
 type BlocksCleaner struct {
 	services.Service
 
 	cfg          BlocksCleanerConfig
+	cfgProvider  ConfigProvider
 	logger       log.Logger
 	bucketClient objstore.Bucket
 	usersScanner *cortex_tsdb.UsersScanner
 
 	// Metrics.
-	runsStarted        prometheus.Counter
-	runsCompleted      prometheus.Counter
-	runsFailed         prometheus.Counter
-	runsLastSuccess    prometheus.Gauge
-	blocksCleanedTotal prometheus.Counter
-	blocksFailedTotal  prometheus.Counter
+	runsStarted                        prometheus.Counter
+	runsCompleted                      prometheus.Counter
+	runsFailed                         prometheus.Counter
+	runsLastSuccess                    prometheus.Gauge
+	blocksCleanedTotal                 prometheus.Counter
+	blocksFailedTotal                  prometheus.Counter
+	blocksMarkedForDeletionByRetention prometheus.Counter
 }
 
-func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, usersScanner *cortex_tsdb.UsersScanner, logger log.Logger, reg prometheus.Registerer) *BlocksCleaner {
+func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, usersScanner *cortex_tsdb.UsersScanner, cfgProvider ConfigProvider, logger log.Logger, reg prometheus.Registerer) *BlocksCleaner {
 	c := &BlocksCleaner{
 		cfg:          cfg,
+		cfgProvider:  cfgProvider,
 		bucketClient: bucketClient,
 		usersScanner: usersScanner,
 		logger:       log.With(logger, "component", "cleaner"),
@@ -78,6 +81,10 @@ func NewBlocksCleaner(cfg BlocksCleanerConfig, bucketClient objstore.Bucket, use
 			Name: "cortex_compactor_block_cleanup_failures_total",
 			Help: "Total number of blocks failed to be deleted.",
 		}),
+		blocksMarkedForDeletionByRetention: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_marked_for_deletion_by_retention_total",
+			Help: "Total number of blocks marked for deletion because older than the tenant retention period.",
+		}),
 	}
 
 	c.Service = services.NewTimerService(cfg.CleanupInterval, c.starting, c.ticker, nil)
@@ -177,87 +184,226 @@ func (c *BlocksCleaner) deleteUser(ctx context.Context, userID string) error {
 	}
 
 	level.Info(userLogger).Log("msg", "finished deleting blocks for user marked for deletion", "deletedBlocks", deleted)
+
+	// Given all blocks have been deleted, we can also remove the bucket index.
+	if err := bucketindex.DeleteIndex(ctx, c.bucketClient, userID); err != nil {
+		return errors.Wrap(err, "failed to delete bucket index")
+	}
+
+	// Delete the debug meta files and all the markers, except the tenant deletion mark which
+	// is kept to not re-discover the tenant as a new one while there are still other objects
+	// (e.g. in-flight uploads) in the bucket.
+	if err := deleteObjectsWithPrefix(ctx, userBucket, block.DebugMetas, nil); err != nil {
+		return errors.Wrap(err, "failed to delete debug meta files")
+	}
+
+	if err := deleteObjectsWithPrefix(ctx, userBucket, cortex_tsdb.TombstonesPath, nil); err != nil {
+		return errors.Wrap(err, "failed to delete tombstones")
+	}
+
+	if err := deleteObjectsWithPrefix(ctx, userBucket, path.Dir(cortex_tsdb.TenantDeletionMarkPath), func(name string) bool {
+		return name != cortex_tsdb.TenantDeletionMarkPath
+	}); err != nil {
+		return errors.Wrap(err, "failed to delete markers")
+	}
+
+	level.Info(userLogger).Log("msg", "finished deleting bucket index, debug meta files, tombstones and markers for user marked for deletion")
 	return nil
 }
 
+// deleteObjectsWithPrefix deletes all objects in the bucket whose name has the input
+// prefix (directory) and are accepted by the optional filter function.
+func deleteObjectsWithPrefix(ctx context.Context, bkt objstore.Bucket, prefix string, filter func(name string) bool) error {
+	return bkt.Iter(ctx, prefix, func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Recursively delete sub-directories.
+		if strings.HasSuffix(name, objstore.DirDelim) {
+			return deleteObjectsWithPrefix(ctx, bkt, name, filter)
+		}
+
+		if filter != nil && !filter(name) {
+			return nil
+		}
+
+		if err := bkt.Delete(ctx, name); err != nil && !bkt.IsObjNotFoundErr(err) {
+			return errors.Wrapf(err, "failed to delete %s", name)
+		}
+		return nil
+	})
+}
+
 func (c *BlocksCleaner) cleanUser(ctx context.Context, userID string) error {
 	userLogger := util.WithUserID(userID, c.logger)
-	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient)
+	userBucket := bucket.NewSSEBucketClient(userID, bucket.NewUserBucketClient(userID, c.bucketClient), c.cfgProvider)
+
+	// Read the bucket index.
+	idx, err := bucketindex.ReadIndex(ctx, c.bucketClient, userID, userLogger)
+	if errors.Is(err, bucketindex.ErrIndexCorrupted) {
+		level.Warn(userLogger).Log("msg", "found a corrupted bucket index, recreating it")
+	} else if err != nil && !errors.Is(err, bucketindex.ErrIndexNotFound) {
+		return errors.Wrap(err, "read bucket index")
+	}
 
-	ignoreDeletionMarkFilter := block.NewIgnoreDeletionMarkFilter(userLogger, userBucket, c.cfg.DeletionDelay, c.cfg.MetaSyncConcurrency)
-
-	fetcher, err := block.NewMetaFetcher(
-		userLogger,
-		c.cfg.MetaSyncConcurrency,
-		userBucket,
-		// The fetcher stores cached metas in the "meta-syncer/" sub directory,
-		// but we prefix it in order to guarantee no clashing with the compactor.
-		path.Join(c.cfg.DataDir, "blocks-cleaner-meta-"+userID),
-		// No metrics.
-		nil,
-		[]block.MetadataFilter{ignoreDeletionMarkFilter},
-		nil,
-	)
+	// Generate an updated in-memory version of the bucket index. Errors are expected to
+	// be returned only on object storage failures, so it's safe to bail out.
+	idx, partials, err := bucketindex.NewUpdater(c.bucketClient, userID, c.logger).UpdateIndex(ctx, idx)
 	if err != nil {
-		return errors.Wrap(err, "error creating metadata fetcher")
+		return errors.Wrap(err, "update bucket index")
 	}
 
-	// Runs a bucket scan to get a fresh list of all blocks and populate
-	// the list of deleted blocks in filter.
-	_, partials, err := fetcher.Fetch(ctx)
-	if err != nil {
-		return errors.Wrap(err, "error fetching metadata")
+	// Mark for deletion the blocks older than the retention period. They will be
+	// hard deleted by a later cleanup, once the deletion delay has elapsed.
+	if retention := c.cfgProvider.CompactorBlocksRetentionPeriod(userID); retention > 0 {
+		if err := c.applyUserRetentionPeriod(ctx, idx, retention, userBucket, userLogger); err != nil {
+			return errors.Wrap(err, "error applying retention period")
+		}
 	}
 
-	cleaner := compact.NewBlocksCleaner(
-		userLogger,
-		userBucket,
-		ignoreDeletionMarkFilter,
-		c.cfg.DeletionDelay,
-		c.blocksCleanedTotal,
-		c.blocksFailedTotal)
+	// Delete the blocks marked for deletion since longer than the deletion delay. We iterate
+	// over a copy of the deletion marks because the blocks deleted are removed from the index.
+	level.Info(userLogger).Log("msg", "started cleaning of blocks marked for deletion")
+	for _, mark := range append([]*bucketindex.BlockDeletionMark(nil), idx.BlockDeletionMarks...) {
+		if time.Since(mark.GetDeletionTime()) <= c.cfg.DeletionDelay {
+			continue
+		}
+
+		if err := block.Delete(ctx, userLogger, userBucket, mark.ID); err != nil {
+			c.blocksFailedTotal.Inc()
+			level.Warn(userLogger).Log("msg", "failed to delete block marked for deletion", "block", mark.ID, "err", err)
+			continue
+		}
+
+		// Remove the block from the bucket index too.
+		idx.RemoveBlock(mark.ID)
 
-	if err := cleaner.DeleteMarkedBlocks(ctx); err != nil {
-		return errors.Wrap(err, "error cleaning blocks")
+		c.blocksCleanedTotal.Inc()
+		level.Info(userLogger).Log("msg", "deleted block marked for deletion", "block", mark.ID)
 	}
+	level.Info(userLogger).Log("msg", "cleaning of blocks marked for deletion done")
 
-	// Partial blocks with a deletion mark can be cleaned up. This is a best effort, so we don't return
-	// error if the cleanup of partial blocks fail.
+	// Partial blocks with a deletion mark, or left over since longer than the partial blocks
+	// deletion delay, can be cleaned up. This is a best effort, so we don't return error if
+	// the cleanup of partial blocks fail.
 	if len(partials) > 0 {
-		level.Info(userLogger).Log("msg", "started cleaning of partial blocks marked for deletion")
+		level.Info(userLogger).Log("msg", "started cleaning of partial blocks")
 		c.cleanUserPartialBlocks(ctx, partials, userBucket, userLogger)
-		level.Info(userLogger).Log("msg", "cleaning of partial blocks marked for deletion done")
+		level.Info(userLogger).Log("msg", "cleaning of partial blocks done")
+	}
+
+	// Upload the updated index to the storage.
+	if err := bucketindex.WriteIndex(ctx, c.bucketClient, userID, c.cfgProvider, idx); err != nil {
+		return errors.Wrap(err, "write bucket index")
 	}
 
 	return nil
 }
 
-func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map[ulid.ULID]error, userBucket *bucket.UserBucketClient, userLogger log.Logger) {
+// applyUserRetentionPeriod marks for deletion the blocks whose samples are all older than the
+// retention period, and adds the new deletion marks to the in-memory bucket index.
+func (c *BlocksCleaner) applyUserRetentionPeriod(ctx context.Context, idx *bucketindex.Index, retention time.Duration, userBucket objstore.InstrumentedBucket, userLogger log.Logger) error {
+	threshold := time.Now().Add(-retention).Unix() * 1000
+
+	marked := make(map[ulid.ULID]struct{}, len(idx.BlockDeletionMarks))
+	for _, mark := range idx.BlockDeletionMarks {
+		marked[mark.ID] = struct{}{}
+	}
+
+	for _, b := range idx.Blocks {
+		if b.MaxTime >= threshold {
+			continue
+		}
+
+		// Skip blocks which have already been marked for deletion.
+		if _, ok := marked[b.ID]; ok {
+			continue
+		}
+
+		level.Info(userLogger).Log("msg", "marking block for deletion because older than the retention period", "block", b.ID, "maxTime", b.MaxTime, "retention", retention)
+		if err := block.MarkForDeletion(ctx, userLogger, userBucket, b.ID, "block exceeding retention period", c.blocksMarkedForDeletionByRetention); err != nil {
+			return errors.Wrapf(err, "failed to mark block %s for deletion", b.ID)
+		}
+
+		idx.BlockDeletionMarks = append(idx.BlockDeletionMarks, &bucketindex.BlockDeletionMark{ID: b.ID, DeletionTime: time.Now().Unix()})
+	}
+
+	return nil
+}
+
+func (c *BlocksCleaner) cleanUserPartialBlocks(ctx context.Context, partials map[ulid.ULID]error, userBucket objstore.InstrumentedBucket, userLogger log.Logger) {
 	for blockID, blockErr := range partials {
 		// We can safely delete only blocks which are partial because the meta.json is missing.
-		if blockErr != block.ErrorSyncMetaNotFound {
+		if !errors.Is(blockErr, bucketindex.ErrBlockMetaNotFound) {
 			continue
 		}
 
-		// We can safely delete only partial blocks with a deletion mark.
+		// We can safely delete partial blocks with a deletion mark, even if the deletion threshold
+		// has not been reached yet, or partial blocks not modified since longer than the partial
+		// blocks deletion delay, because they're likely the leftover of an aborted upload.
 		err := metadata.ReadMarker(ctx, userLogger, userBucket, blockID.String(), &metadata.DeletionMark{})
-		if err == metadata.ErrorMarkerNotFound {
-			continue
-		}
-		if err != nil {
+		if errors.Is(err, metadata.ErrorMarkerNotFound) {
+			if c.cfg.PartialBlocksDeletionDelay <= 0 {
+				continue
+			}
+
+			lastModified, err := lastModifiedTime(ctx, userBucket, blockID.String()+objstore.DirDelim)
+			if err != nil {
+				level.Warn(userLogger).Log("msg", "error reading partial block last modified time", "block", blockID, "err", err)
+				continue
+			}
+			if lastModified.IsZero() || time.Since(lastModified) <= c.cfg.PartialBlocksDeletionDelay {
+				continue
+			}
+		} else if err != nil {
 			level.Warn(userLogger).Log("msg", "error reading partial block deletion mark", "block", blockID, "err", err)
 			continue
 		}
 
-		// Hard-delete partial blocks having a deletion mark, even if the deletion threshold has not
-		// been reached yet.
 		if err := block.Delete(ctx, userLogger, userBucket, blockID); err != nil {
 			c.blocksFailedTotal.Inc()
-			level.Warn(userLogger).Log("msg", "error deleting partial block marked for deletion", "block", blockID, "err", err)
+			level.Warn(userLogger).Log("msg", "error deleting partial block", "block", blockID, "err", err)
 			continue
 		}
 
 		c.blocksCleanedTotal.Inc()
-		level.Info(userLogger).Log("msg", "deleted partial block marked for deletion", "block", blockID)
+		level.Info(userLogger).Log("msg", "deleted partial block", "block", blockID)
 	}
 }
+
+// lastModifiedTime returns the most recent last modified time of the objects whose name has
+// the input prefix (directory). A zero time is returned if there are no objects.
+func lastModifiedTime(ctx context.Context, bkt objstore.Bucket, prefix string) (time.Time, error) {
+	var lastModified time.Time
+
+	err := bkt.Iter(ctx, prefix, func(name string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var modified time.Time
+
+		// Recursively look into sub-directories.
+		if strings.HasSuffix(name, objstore.DirDelim) {
+			t, err := lastModifiedTime(ctx, bkt, name)
+			if err != nil {
+				return err
+			}
+			modified = t
+		} else {
+			attrs, err := bkt.Attributes(ctx, name)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read attributes of %s", name)
+			}
+			modified = attrs.LastModified
+		}
+
+		if modified.After(lastModified) {
+			lastModified = modified
+		}
+		return nil
+	})
+
+	return lastModified, err
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,10 +17,12 @@ import (
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
@@ -41,11 +44,6 @@ func testBlocksCleanerWithConcurrency(t *testing.T, concurrency int) {
 	require.NoError(t, err)
 	defer os.RemoveAll(storageDir) //nolint:errcheck
 
-	// Create a temporary directory for cleaner.
-	dataDir, err := ioutil.TempDir(os.TempDir(), "data")
-	require.NoError(t, err)
-	defer os.RemoveAll(dataDir) //nolint:errcheck
-
 	// Create a bucket client on the local storage.
 	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
 	require.NoError(t, err)
@@ -73,19 +71,22 @@ func testBlocksCleanerWithConcurrency(t *testing.T, concurrency int) {
 	require.NoError(t, tsdb.WriteTenantDeletionMark(context.Background(), bucketClient, "user-3"))
 	block9 := createTSDBBlock(t, filepath.Join(storageDir, "user-3"), 10, 30, nil)
 	block10 := createTSDBBlock(t, filepath.Join(storageDir, "user-3"), 30, 50, nil)
+	require.NoError(t, bucketindex.WriteIndex(ctx, bucketClient, "user-3", nil, &bucketindex.Index{Version: bucketindex.IndexVersion1}))
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-3", block.DebugMetas, block9.String()+".json"), strings.NewReader("{}")))
+	tombstone := tsdb.NewTombstone("user-3", 0, 0, 10, []string{`{job="a"}`})
+	require.NoError(t, tsdb.WriteTombstone(ctx, bucketClient, "user-3", tombstone))
+	require.NoError(t, bucketClient.Upload(ctx, path.Join("user-3", "markers", "another-mark.json"), strings.NewReader("{}")))
 
 	cfg := BlocksCleanerConfig{
-		DataDir:             dataDir,
-		MetaSyncConcurrency: 10,
-		DeletionDelay:       deletionDelay,
-		CleanupInterval:     time.Minute,
-		CleanupConcurrency:  concurrency,
+		DeletionDelay:      deletionDelay,
+		CleanupInterval:    time.Minute,
+		CleanupConcurrency: concurrency,
 	}
 
 	logger := log.NewNopLogger()
 	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
 
-	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, logger, nil)
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, newMockConfigProvider(), logger, nil)
 	require.NoError(t, services.StartAndAwaitRunning(ctx, cleaner))
 	defer services.StopAndAwaitTerminated(ctx, cleaner) //nolint:errcheck
 
@@ -111,6 +112,11 @@ func testBlocksCleanerWithConcurrency(t *testing.T, concurrency int) {
 		{path: path.Join("user-3", block9.String(), "index"), expectedExists: false},
 		{path: path.Join("user-3", block10.String(), metadata.MetaFilename), expectedExists: false},
 		{path: path.Join("user-3", block10.String(), "index"), expectedExists: false},
+		// Bucket index, debug metas, tombstones and other markers are removed for user-3.
+		{path: path.Join("user-3", bucketindex.IndexCompressedFilename), expectedExists: false},
+		{path: path.Join("user-3", block.DebugMetas, block9.String()+".json"), expectedExists: false},
+		{path: path.Join("user-3", tsdb.TombstonesPath, tombstone.RequestID+".json"), expectedExists: false},
+		{path: path.Join("user-3", "markers", "another-mark.json"), expectedExists: false},
 		// Tenant deletion mark is not removed.
 		{path: path.Join("user-3", tsdb.TenantDeletionMarkPath), expectedExists: true},
 	} {
@@ -124,4 +130,179 @@ func testBlocksCleanerWithConcurrency(t *testing.T, concurrency int) {
 	assert.Equal(t, float64(0), testutil.ToFloat64(cleaner.runsFailed))
 	assert.Equal(t, float64(6), testutil.ToFloat64(cleaner.blocksCleanedTotal))
 	assert.Equal(t, float64(0), testutil.ToFloat64(cleaner.blocksFailedTotal))
+
+	// Check the updated bucket index.
+	for _, tc := range []struct {
+		userID         string
+		expectedIndex  bool
+		expectedBlocks []ulid.ULID
+		expectedMarks  []ulid.ULID
+	}{
+		{
+			userID:         "user-1",
+			expectedIndex:  true,
+			expectedBlocks: []ulid.ULID{block1, block2},
+			expectedMarks:  []ulid.ULID{block2},
+		}, {
+			userID:         "user-2",
+			expectedIndex:  true,
+			expectedBlocks: []ulid.ULID{block8},
+			expectedMarks:  []ulid.ULID{},
+		}, {
+			userID:        "user-3",
+			expectedIndex: false,
+		},
+	} {
+		idx, err := bucketindex.ReadIndex(ctx, bucketClient, tc.userID, logger)
+		if !tc.expectedIndex {
+			assert.Equal(t, bucketindex.ErrIndexNotFound, err)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.ElementsMatch(t, tc.expectedBlocks, bucketindex.Blocks(idx.Blocks).GetULIDs())
+
+		actualMarks := []ulid.ULID{}
+		for _, m := range idx.BlockDeletionMarks {
+			actualMarks = append(actualMarks, m.ID)
+		}
+		assert.ElementsMatch(t, tc.expectedMarks, actualMarks)
+	}
+}
+
+func TestBlocksCleaner_ShouldDeletePartialBlocksWithoutDeletionMarkAfterDeletionDelay(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	partialDeletionDelay := time.Hour
+
+	// Create two partial blocks, one of which hasn't been modified since longer than the delay.
+	block1 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 10, 20, nil)
+	block2 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 20, 30, nil)
+	block3 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 30, 40, nil)
+	require.NoError(t, bucketClient.Delete(ctx, path.Join("user-1", block1.String(), metadata.MetaFilename)))
+	require.NoError(t, bucketClient.Delete(ctx, path.Join("user-1", block2.String(), metadata.MetaFilename)))
+
+	oldTime := time.Now().Add(-2 * partialDeletionDelay)
+	require.NoError(t, filepath.Walk(filepath.Join(storageDir, "user-1", block1.String()), func(path string, _ os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, oldTime, oldTime)
+	}))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:              12 * time.Hour,
+		PartialBlocksDeletionDelay: partialDeletionDelay,
+		CleanupInterval:            time.Minute,
+		CleanupConcurrency:         1,
+	}
+
+	logger := log.NewNopLogger()
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, newMockConfigProvider(), logger, nil)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, cleaner))
+	defer services.StopAndAwaitTerminated(ctx, cleaner) //nolint:errcheck
+
+	for _, tc := range []struct {
+		path           string
+		expectedExists bool
+	}{
+		{path: path.Join("user-1", block1.String(), "index"), expectedExists: false},
+		{path: path.Join("user-1", block2.String(), "index"), expectedExists: true},
+		{path: path.Join("user-1", block3.String(), metadata.MetaFilename), expectedExists: true},
+	} {
+		exists, err := bucketClient.Exists(ctx, tc.path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedExists, exists, tc.path)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleaner.blocksCleanedTotal))
+
+	// Partial blocks should not be included in the bucket index.
+	idx, err := bucketindex.ReadIndex(ctx, bucketClient, "user-1", logger)
+	require.NoError(t, err)
+	assert.Equal(t, []ulid.ULID{block3}, bucketindex.Blocks(idx.Blocks).GetULIDs())
+}
+
+func TestBlocksCleaner_ShouldMarkBlocksOlderThanRetentionPeriodForDeletion(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now()
+	oldMaxT := now.Add(-48*time.Hour).Unix() * 1000
+	newMaxT := now.Add(-time.Hour).Unix() * 1000
+
+	block1 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), oldMaxT-1000, oldMaxT, nil)
+	block2 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), newMaxT-1000, newMaxT, nil)
+	block3 := createTSDBBlock(t, filepath.Join(storageDir, "user-2"), oldMaxT-1000, oldMaxT, nil)
+
+	// Create a bucket index for user-1, which is expected to be updated with the new deletion marks.
+	require.NoError(t, bucketindex.WriteIndex(ctx, bucketClient, "user-1", nil, &bucketindex.Index{
+		Version: bucketindex.IndexVersion1,
+		Blocks: bucketindex.Blocks{
+			{ID: block1, MinTime: oldMaxT - 1000, MaxTime: oldMaxT},
+			{ID: block2, MinTime: newMaxT - 1000, MaxTime: newMaxT},
+		},
+	}))
+
+	cfg := BlocksCleanerConfig{
+		DeletionDelay:      time.Hour,
+		CleanupInterval:    time.Minute,
+		CleanupConcurrency: 1,
+	}
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.userRetentionPeriods["user-1"] = 24 * time.Hour
+
+	logger := log.NewNopLogger()
+	scanner := tsdb.NewUsersScanner(bucketClient, tsdb.AllUsers, logger)
+
+	cleaner := NewBlocksCleaner(cfg, bucketClient, scanner, cfgProvider, logger, nil)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, cleaner))
+	defer services.StopAndAwaitTerminated(ctx, cleaner) //nolint:errcheck
+
+	for _, tc := range []struct {
+		path           string
+		expectedExists bool
+	}{
+		// Blocks older than the retention should be marked for deletion (but not deleted yet).
+		{path: path.Join("user-1", block1.String(), metadata.MetaFilename), expectedExists: true},
+		{path: path.Join("user-1", block1.String(), metadata.DeletionMarkFilename), expectedExists: true},
+		{path: path.Join("user-1", block2.String(), metadata.DeletionMarkFilename), expectedExists: false},
+		// Retention is disabled for user-2.
+		{path: path.Join("user-2", block3.String(), metadata.DeletionMarkFilename), expectedExists: false},
+	} {
+		exists, err := bucketClient.Exists(ctx, tc.path)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expectedExists, exists, tc.path)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleaner.blocksMarkedForDeletionByRetention))
+	assert.Equal(t, float64(0), testutil.ToFloat64(cleaner.blocksCleanedTotal))
+
+	// The bucket index should contain the new deletion mark.
+	idx, err := bucketindex.ReadIndex(ctx, bucketClient, "user-1", logger)
+	require.NoError(t, err)
+	require.Len(t, idx.BlockDeletionMarks, 1)
+	assert.Equal(t, block1, idx.BlockDeletionMarks[0].ID)
+
+	// Running the cleanup again should not mark the block for deletion twice.
+	cleaner.runCleanup(ctx)
+	assert.Equal(t, float64(1), testutil.ToFloat64(cleaner.blocksMarkedForDeletionByRetention))
+
+	idx, err = bucketindex.ReadIndex(ctx, bucketClient, "user-1", logger)
+	require.NoError(t, err)
+	assert.Len(t, idx.BlockDeletionMarks, 1)
 }
@@ -0,0 +1,225 @@
+package compactor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+)
+
+// tenantCompactionStatus holds the compaction status of a tenant owned by this compactor.
+type tenantCompactionStatus struct {
+	UserID string `json:"user_id"`
+
+	// Whether the tenant is being compacted, and when the current (or last) compaction started.
+	InProgress bool      `json:"in_progress"`
+	StartedAt  time.Time `json:"started_at"`
+
+	// When the last compaction of the tenant successfully completed or failed.
+	LastSuccessfulCompaction time.Time `json:"last_successful_compaction"`
+	LastFailedCompaction     time.Time `json:"last_failed_compaction"`
+	LastError                string    `json:"last_error,omitempty"`
+
+	// The groups of blocks planned to be compacted and not compacted yet, and their estimated size.
+	PendingGroups       []*compactionGroupPlan `json:"pending_groups"`
+	EstimatedInputBytes int64                  `json:"estimated_input_bytes"`
+}
+
+// compactionGroupPlan holds the blocks planned to be compacted together.
+type compactionGroupPlan struct {
+	Key     string    `json:"key"`
+	Blocks  []string  `json:"blocks"`
+	MinTime time.Time `json:"min_time"`
+	MaxTime time.Time `json:"max_time"`
+
+	// The size of the blocks to compact, based on the files listed in the blocks meta.json.
+	// It's 0 if the meta.json of the blocks don't list the files size.
+	EstimatedInputBytes int64 `json:"estimated_input_bytes"`
+}
+
+func newCompactionGroupPlan(key string, metas []*metadata.Meta) *compactionGroupPlan {
+	p := &compactionGroupPlan{Key: key}
+
+	for i, m := range metas {
+		p.Blocks = append(p.Blocks, m.ULID.String())
+
+		if minTime := time.Unix(0, m.MinTime*int64(time.Millisecond)).UTC(); i == 0 || minTime.Before(p.MinTime) {
+			p.MinTime = minTime
+		}
+		if maxTime := time.Unix(0, m.MaxTime*int64(time.Millisecond)).UTC(); i == 0 || maxTime.After(p.MaxTime) {
+			p.MaxTime = maxTime
+		}
+
+		for _, f := range m.Thanos.Files {
+			p.EstimatedInputBytes += f.SizeBytes
+		}
+	}
+
+	return p
+}
+
+// compactionStatusTracker keeps track of the compaction status of the tenants owned by this compactor.
+type compactionStatusTracker struct {
+	mtx     sync.Mutex
+	tenants map[string]*tenantCompactionStatus
+}
+
+func newCompactionStatusTracker() *compactionStatusTracker {
+	return &compactionStatusTracker{
+		tenants: map[string]*tenantCompactionStatus{},
+	}
+}
+
+func (t *compactionStatusTracker) getOrCreate(userID string) *tenantCompactionStatus {
+	s, ok := t.tenants[userID]
+	if !ok {
+		s = &tenantCompactionStatus{UserID: userID}
+		t.tenants[userID] = s
+	}
+	return s
+}
+
+// compactionStarted tracks the compaction of the tenant has started.
+func (t *compactionStatusTracker) compactionStarted(userID string) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	s := t.getOrCreate(userID)
+	s.InProgress = true
+	s.StartedAt = time.Now()
+	s.PendingGroups = nil
+}
+
+// compactionFinished tracks the compaction of the tenant has completed, successfully or not.
+func (t *compactionStatusTracker) compactionFinished(userID string, err error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	s := t.getOrCreate(userID)
+	s.InProgress = false
+
+	if err != nil {
+		s.LastFailedCompaction = time.Now()
+		s.LastError = err.Error()
+		return
+	}
+
+	s.LastSuccessfulCompaction = time.Now()
+	s.LastError = ""
+	s.PendingGroups = nil
+}
+
+// setGroupPlan sets the plan of a group of blocks of the tenant. An empty
+// plan means there's nothing left to compact in the group.
+func (t *compactionStatusTracker) setGroupPlan(userID, key string, metas []*metadata.Meta) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	s := t.getOrCreate(userID)
+
+	for i, g := range s.PendingGroups {
+		if g.Key != key {
+			continue
+		}
+
+		if len(metas) == 0 {
+			s.PendingGroups = append(s.PendingGroups[:i], s.PendingGroups[i+1:]...)
+		} else {
+			s.PendingGroups[i] = newCompactionGroupPlan(key, metas)
+		}
+		return
+	}
+
+	if len(metas) > 0 {
+		s.PendingGroups = append(s.PendingGroups, newCompactionGroupPlan(key, metas))
+	}
+}
+
+// retainTenants removes the tracked status of the tenants not in the input set,
+// because they're not owned by this compactor anymore.
+func (t *compactionStatusTracker) retainTenants(userIDs map[string]struct{}) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for userID := range t.tenants {
+		if _, ok := userIDs[userID]; !ok {
+			delete(t.tenants, userID)
+		}
+	}
+}
+
+// getTenants returns a copy of the tracked status of all tenants, sorted by user ID.
+func (t *compactionStatusTracker) getTenants() []*tenantCompactionStatus {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	out := make([]*tenantCompactionStatus, 0, len(t.tenants))
+	for _, s := range t.tenants {
+		c := *s
+		c.PendingGroups = append([]*compactionGroupPlan(nil), s.PendingGroups...)
+		sort.Slice(c.PendingGroups, func(i, j int) bool {
+			return c.PendingGroups[i].Key < c.PendingGroups[j].Key
+		})
+
+		c.EstimatedInputBytes = 0
+		for _, g := range c.PendingGroups {
+			c.EstimatedInputBytes += g.EstimatedInputBytes
+		}
+		out = append(out, &c)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].UserID < out[j].UserID
+	})
+
+	return out
+}
+
+// trackingPlanner is a compact.Planner tracking the plan of each group of blocks
+// of a tenant, so that the pending groups are updated while the compaction progresses.
+type trackingPlanner struct {
+	compact.Planner
+
+	userID  string
+	tracker *compactionStatusTracker
+}
+
+// Plan implements compact.Planner.
+func (p *trackingPlanner) Plan(ctx context.Context, metasByMinTime []*metadata.Meta) ([]*metadata.Meta, error) {
+	toCompact, err := p.Planner.Plan(ctx, metasByMinTime)
+	if err != nil || len(metasByMinTime) == 0 {
+		return toCompact, err
+	}
+
+	p.tracker.setGroupPlan(p.userID, compact.DefaultGroupKey(metasByMinTime[0].Thanos), toCompact)
+	return toCompact, nil
+}
+
+// planUserCompaction plans the compaction of each group of the input blocks,
+// and tracks the groups with blocks to compact.
+func (c *Compactor) planUserCompaction(ctx context.Context, userID string, metas map[ulid.ULID]*metadata.Meta) error {
+	groups := map[string][]*metadata.Meta{}
+	for _, m := range metas {
+		key := compact.DefaultGroupKey(m.Thanos)
+		groups[key] = append(groups[key], m)
+	}
+
+	for key, groupMetas := range groups {
+		sort.Slice(groupMetas, func(i, j int) bool {
+			return groupMetas[i].MinTime < groupMetas[j].MinTime
+		})
+
+		toCompact, err := c.tsdbPlanner.Plan(ctx, groupMetas)
+		if err != nil {
+			return err
+		}
+
+		c.compactionStatus.setGroupPlan(userID, key, toCompact)
+	}
+
+	return nil
+}
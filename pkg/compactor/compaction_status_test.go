@@ -0,0 +1,167 @@
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+)
+
+func TestCompactionStatusTracker(t *testing.T) {
+	block1 := mockCompactionStatusMeta(1, 0, 10, 100)
+	block2 := mockCompactionStatusMeta(2, 10, 20, 200)
+	block3 := mockCompactionStatusMeta(3, 20, 30, 0)
+
+	tracker := newCompactionStatusTracker()
+
+	// Start the compaction of two tenants.
+	tracker.compactionStarted("user-1")
+	tracker.setGroupPlan("user-1", "group-1", []*metadata.Meta{block1, block2})
+	tracker.setGroupPlan("user-1", "group-2", []*metadata.Meta{block3})
+	tracker.setGroupPlan("user-1", "group-3", nil)
+	tracker.compactionStarted("user-2")
+
+	tenants := tracker.getTenants()
+	require.Len(t, tenants, 2)
+	assert.Equal(t, "user-1", tenants[0].UserID)
+	assert.True(t, tenants[0].InProgress)
+	assert.False(t, tenants[0].StartedAt.IsZero())
+	assert.Equal(t, int64(300), tenants[0].EstimatedInputBytes)
+	assert.Equal(t, []*compactionGroupPlan{
+		{
+			Key:                 "group-1",
+			Blocks:              []string{block1.ULID.String(), block2.ULID.String()},
+			MinTime:             time.Unix(0, 0).UTC(),
+			MaxTime:             time.Unix(0, 20*int64(time.Millisecond)).UTC(),
+			EstimatedInputBytes: 300,
+		}, {
+			Key:                 "group-2",
+			Blocks:              []string{block3.ULID.String()},
+			MinTime:             time.Unix(0, 20*int64(time.Millisecond)).UTC(),
+			MaxTime:             time.Unix(0, 30*int64(time.Millisecond)).UTC(),
+			EstimatedInputBytes: 0,
+		},
+	}, tenants[0].PendingGroups)
+	assert.Equal(t, "user-2", tenants[1].UserID)
+	assert.Empty(t, tenants[1].PendingGroups)
+
+	// A group with nothing left to compact is removed from the pending ones.
+	tracker.setGroupPlan("user-1", "group-1", nil)
+	tenants = tracker.getTenants()
+	require.Len(t, tenants[0].PendingGroups, 1)
+	assert.Equal(t, "group-2", tenants[0].PendingGroups[0].Key)
+
+	// Finish the compaction of the two tenants.
+	tracker.compactionFinished("user-1", errors.New("compaction failed"))
+	tracker.compactionFinished("user-2", nil)
+
+	tenants = tracker.getTenants()
+	assert.False(t, tenants[0].InProgress)
+	assert.True(t, tenants[0].LastSuccessfulCompaction.IsZero())
+	assert.False(t, tenants[0].LastFailedCompaction.IsZero())
+	assert.Equal(t, "compaction failed", tenants[0].LastError)
+	assert.Len(t, tenants[0].PendingGroups, 1)
+	assert.False(t, tenants[1].InProgress)
+	assert.False(t, tenants[1].LastSuccessfulCompaction.IsZero())
+	assert.Empty(t, tenants[1].LastError)
+
+	// Tenants not owned anymore are not tracked anymore.
+	tracker.retainTenants(map[string]struct{}{"user-2": {}})
+	tenants = tracker.getTenants()
+	require.Len(t, tenants, 1)
+	assert.Equal(t, "user-2", tenants[0].UserID)
+}
+
+func TestTrackingPlanner(t *testing.T) {
+	block1 := mockCompactionStatusMeta(1, 0, 10, 100)
+	block2 := mockCompactionStatusMeta(2, 10, 20, 200)
+	groupKey := compact.DefaultGroupKey(block1.Thanos)
+
+	tracker := newCompactionStatusTracker()
+	tracker.compactionStarted("user-1")
+
+	planner := &tsdbPlannerMock{}
+	planner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{block1, block2}, nil).Once()
+	planner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil).Once()
+
+	p := &trackingPlanner{Planner: planner, userID: "user-1", tracker: tracker}
+
+	_, err := p.Plan(context.Background(), []*metadata.Meta{block1, block2})
+	require.NoError(t, err)
+
+	tenants := tracker.getTenants()
+	require.Len(t, tenants[0].PendingGroups, 1)
+	assert.Equal(t, groupKey, tenants[0].PendingGroups[0].Key)
+
+	// Once there's nothing left to compact, the group is not pending anymore.
+	_, err = p.Plan(context.Background(), []*metadata.Meta{block1, block2})
+	require.NoError(t, err)
+
+	tenants = tracker.getTenants()
+	assert.Empty(t, tenants[0].PendingGroups)
+}
+
+func TestCompactor_CompactionPlanHandler(t *testing.T) {
+	c, _, _, _, _, cleanup := prepare(t, prepareConfig(), nil)
+	defer cleanup()
+
+	block1 := mockCompactionStatusMeta(1, 0, 10, 100)
+	c.compactionStatus.compactionStarted("user-1")
+	c.compactionStatus.setGroupPlan("user-1", "group-1", []*metadata.Meta{block1})
+
+	// JSON response.
+	req := httptest.NewRequest("GET", "/compactor/compaction_plan", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	c.CompactionPlanHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	res := struct {
+		Tenants []*tenantCompactionStatus `json:"tenants"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(t, res.Tenants, 1)
+	assert.Equal(t, "user-1", res.Tenants[0].UserID)
+	assert.True(t, res.Tenants[0].InProgress)
+	assert.Equal(t, int64(100), res.Tenants[0].EstimatedInputBytes)
+	require.Len(t, res.Tenants[0].PendingGroups, 1)
+	assert.Equal(t, []string{block1.ULID.String()}, res.Tenants[0].PendingGroups[0].Blocks)
+
+	// HTML response.
+	req = httptest.NewRequest("GET", "/compactor/compaction_plan", nil)
+	rec = httptest.NewRecorder()
+	c.CompactionPlanHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "user-1")
+	assert.Contains(t, rec.Body.String(), block1.ULID.String())
+}
+
+func mockCompactionStatusMeta(id uint64, minTime, maxTime, size int64) *metadata.Meta {
+	m := &metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:    ulid.MustNew(id, nil),
+			MinTime: minTime,
+			MaxTime: maxTime,
+		},
+		Thanos: metadata.Thanos{
+			Labels: map[string]string{"key": "value"},
+		},
+	}
+
+	if size > 0 {
+		m.Thanos.Files = []metadata.File{{RelPath: "index", SizeBytes: size}}
+	}
+
+	return m
+}
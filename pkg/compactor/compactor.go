@@ -28,10 +28,15 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 var (
-	errInvalidBlockRanges = "compactor block range periods should be divisible by the previous one, but %s is not divisible by %s"
+	errInvalidBlockRanges      = "compactor block range periods should be divisible by the previous one, but %s is not divisible by %s"
+	errInvalidShardingStrategy = errors.New("invalid sharding strategy")
+	errInvalidTenantShardSize  = errors.New("invalid tenant shard size, the value must be greater than 0")
+
+	supportedShardingStrategies = []string{util.ShardingStrategyDefault, util.ShardingStrategyShuffle}
 )
 
 // Config holds the Compactor config.
@@ -47,12 +52,19 @@ type Config struct {
 	CleanupConcurrency    int                      `yaml:"cleanup_concurrency"`
 	DeletionDelay         time.Duration            `yaml:"deletion_delay"`
 
+	PartialBlocksDeletionDelay time.Duration `yaml:"partial_blocks_deletion_delay"`
+
 	EnabledTenants  flagext.StringSliceCSV `yaml:"enabled_tenants"`
 	DisabledTenants flagext.StringSliceCSV `yaml:"disabled_tenants"`
 
 	// Compactors sharding.
-	ShardingEnabled bool       `yaml:"sharding_enabled"`
-	ShardingRing    RingConfig `yaml:"sharding_ring"`
+	ShardingEnabled  bool       `yaml:"sharding_enabled"`
+	ShardingStrategy string     `yaml:"sharding_strategy"`
+	ShardingRing     RingConfig `yaml:"sharding_ring"`
+
+	// Time after which a delete request can't be cancelled anymore and its series
+	// are removed from the blocks. Set from the purger config.
+	SeriesDeletionGracePeriod time.Duration `yaml:"-"`
 
 	// No need to add options to customize the retry backoff,
 	// given the defaults should be fine, but allow to override
@@ -79,16 +91,19 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.CompactionConcurrency, "compactor.compaction-concurrency", 1, "Max number of concurrent compactions running.")
 	f.IntVar(&cfg.CleanupConcurrency, "compactor.cleanup-concurrency", 20, "Max number of tenants for which blocks should be cleaned up concurrently (deletion of blocks previously marked for deletion).")
 	f.BoolVar(&cfg.ShardingEnabled, "compactor.sharding-enabled", false, "Shard tenants across multiple compactor instances. Sharding is required if you run multiple compactor instances, in order to coordinate compactions and avoid race conditions leading to the same tenant blocks simultaneously compacted by different instances.")
+	f.StringVar(&cfg.ShardingStrategy, "compactor.sharding-strategy", util.ShardingStrategyDefault, fmt.Sprintf("The sharding strategy to use. Supported values are: %s.", strings.Join(supportedShardingStrategies, ", ")))
 	f.DurationVar(&cfg.DeletionDelay, "compactor.deletion-delay", 12*time.Hour, "Time before a block marked for deletion is deleted from bucket. "+
 		"If not 0, blocks will be marked for deletion and compactor component will delete blocks marked for deletion from the bucket. "+
 		"If delete-delay is 0, blocks will be deleted straight away. Note that deleting blocks immediately can cause query failures, "+
 		"if store gateway still has the block loaded, or compactor is ignoring the deletion because it's compacting the block at the same time.")
+	f.DurationVar(&cfg.PartialBlocksDeletionDelay, "compactor.partial-blocks-deletion-delay", 0, "Time after which a partial block (a block without meta.json) with no deletion mark is deleted from the bucket, if none of its objects has been modified in the meantime. "+
+		"The delay should be greater than the time it takes to upload a block, otherwise blocks being uploaded may be deleted. 0 to disable it.")
 
 	f.Var(&cfg.EnabledTenants, "compactor.enabled-tenants", "Comma separated list of tenants that can be compacted. If specified, only these tenants will be compacted by compactor, otherwise all tenants can be compacted. Subject to sharding.")
 	f.Var(&cfg.DisabledTenants, "compactor.disabled-tenants", "Comma separated list of tenants that cannot be compacted by this compactor. If specified, and compactor would normally pick given tenant for compaction (via -compactor.enabled-tenants or sharding), it will be ignored instead.")
 }
 
-func (cfg *Config) Validate() error {
+func (cfg *Config) Validate(limits validation.Limits) error {
 	// Each block range period should be divisible by the previous one.
 	for i := 1; i < len(cfg.BlockRanges); i++ {
 		if cfg.BlockRanges[i]%cfg.BlockRanges[i-1] != 0 {
@@ -96,15 +111,45 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if cfg.ShardingEnabled {
+		if !util.StringsContain(supportedShardingStrategies, cfg.ShardingStrategy) {
+			return errInvalidShardingStrategy
+		}
+
+		if cfg.ShardingStrategy == util.ShardingStrategyShuffle && limits.CompactorTenantShardSize <= 0 {
+			return errInvalidTenantShardSize
+		}
+	}
 	return nil
 }
 
+// ConfigProvider defines the per-tenant config provider for the Compactor.
+type ConfigProvider interface {
+	// CompactorBlocksRetentionPeriod returns the retention period for a given user.
+	CompactorBlocksRetentionPeriod(user string) time.Duration
+
+	// CompactorTenantShardSize returns the number of compactors that this user can use.
+	// 0 = all compactors.
+	CompactorTenantShardSize(user string) int
+
+	// CompactorSplitAndMergeShards returns the number of shards the blocks of the user
+	// are split into by the split-and-merge compaction strategy. 0 = disabled.
+	CompactorSplitAndMergeShards(user string) int
+
+	// CompactorBlockUploadEnabled returns whether the user is allowed to upload blocks
+	// via the blocks upload API.
+	CompactorBlockUploadEnabled(user string) bool
+
+	bucket.TenantConfigProvider
+}
+
 // Compactor is a multi-tenant TSDB blocks compactor based on Thanos.
 type Compactor struct {
 	services.Service
 
 	compactorCfg Config
 	storageCfg   cortex_tsdb.BlocksStorageConfig
+	cfgProvider  ConfigProvider
 	logger       log.Logger
 	parentLogger log.Logger
 	registerer   prometheus.Registerer
@@ -125,6 +170,9 @@ type Compactor struct {
 	// Blocks cleaner is responsible to hard delete blocks marked for deletion.
 	blocksCleaner *BlocksCleaner
 
+	// Tracks the compaction status of the tenants owned by this compactor.
+	compactionStatus *compactionStatusTracker
+
 	// Underlying compactor and planner used to compact TSDB blocks.
 	tsdbCompactor tsdb.Compactor
 	tsdbPlanner   compact.Planner
@@ -139,23 +187,32 @@ type Compactor struct {
 	ringSubservicesWatcher *services.FailureWatcher
 
 	// Metrics.
-	compactionRunsStarted          prometheus.Counter
-	compactionRunsCompleted        prometheus.Counter
-	compactionRunsFailed           prometheus.Counter
-	compactionRunsLastSuccess      prometheus.Gauge
-	compactionRunDiscoveredTenants prometheus.Gauge
-	compactionRunSkippedTenants    prometheus.Gauge
-	compactionRunSucceededTenants  prometheus.Gauge
-	compactionRunFailedTenants     prometheus.Gauge
-	blocksMarkedForDeletion        prometheus.Counter
-	garbageCollectedBlocks         prometheus.Counter
+	compactionRunsStarted           prometheus.Counter
+	compactionRunsCompleted         prometheus.Counter
+	compactionRunsFailed            prometheus.Counter
+	compactionRunsLastSuccess       prometheus.Gauge
+	compactionRunDiscoveredTenants  prometheus.Gauge
+	compactionRunOwnedTenants       prometheus.Gauge
+	compactionRunSkippedTenants     prometheus.Gauge
+	compactionRunSucceededTenants   prometheus.Gauge
+	compactionRunFailedTenants      prometheus.Gauge
+	blocksMarkedForDeletion         prometheus.Counter
+	garbageCollectedBlocks          prometheus.Counter
+	blocksMarkedForNoCompaction     *prometheus.CounterVec
+	blocksSkippedFromCompaction     *prometheus.CounterVec
+	blocksRewrittenBySeriesDeletion prometheus.Counter
+	tombstonesProcessed             prometheus.Counter
+	splitAndMergeJobsCompleted      *prometheus.CounterVec
+	splitAndMergeJobsFailed         *prometheus.CounterVec
+	blocksUploaded                  prometheus.Counter
+	blockUploadValidationFailures   prometheus.Counter
 
 	// TSDB syncer metrics
 	syncerMetrics *syncerMetrics
 }
 
 // NewCompactor makes a new Compactor.
-func NewCompactor(compactorCfg Config, storageCfg cortex_tsdb.BlocksStorageConfig, logger log.Logger, registerer prometheus.Registerer) (*Compactor, error) {
+func NewCompactor(compactorCfg Config, storageCfg cortex_tsdb.BlocksStorageConfig, cfgProvider ConfigProvider, logger log.Logger, registerer prometheus.Registerer) (*Compactor, error) {
 	createDependencies := func(ctx context.Context) (objstore.Bucket, tsdb.Compactor, compact.Planner, error) {
 		bucketClient, err := bucket.NewClient(ctx, storageCfg.Bucket, "compactor", logger, registerer)
 		if err != nil {
@@ -171,7 +228,7 @@ func NewCompactor(compactorCfg Config, storageCfg cortex_tsdb.BlocksStorageConfi
 		return bucketClient, compactor, planner, nil
 	}
 
-	cortexCompactor, err := newCompactor(compactorCfg, storageCfg, logger, registerer, createDependencies)
+	cortexCompactor, err := newCompactor(compactorCfg, storageCfg, cfgProvider, logger, registerer, createDependencies)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create Cortex blocks compactor")
 	}
@@ -182,6 +239,7 @@ func NewCompactor(compactorCfg Config, storageCfg cortex_tsdb.BlocksStorageConfi
 func newCompactor(
 	compactorCfg Config,
 	storageCfg cortex_tsdb.BlocksStorageConfig,
+	cfgProvider ConfigProvider,
 	logger log.Logger,
 	registerer prometheus.Registerer,
 	createDependencies func(ctx context.Context) (objstore.Bucket, tsdb.Compactor, compact.Planner, error),
@@ -189,6 +247,8 @@ func newCompactor(
 	c := &Compactor{
 		compactorCfg:       compactorCfg,
 		storageCfg:         storageCfg,
+		cfgProvider:        cfgProvider,
+		compactionStatus:   newCompactionStatusTracker(),
 		parentLogger:       logger,
 		logger:             log.With(logger, "component", "compactor"),
 		registerer:         registerer,
@@ -215,6 +275,10 @@ func newCompactor(
 			Name: "cortex_compactor_tenants_discovered",
 			Help: "Number of tenants discovered during the current compaction run. Reset to 0 when compactor is idle.",
 		}),
+		compactionRunOwnedTenants: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_compactor_tenants_owned",
+			Help: "Number of tenants owned by this compactor instance, as of the last compaction run.",
+		}),
 		compactionRunSkippedTenants: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
 			Name: "cortex_compactor_tenants_skipped",
 			Help: "Number of tenants skipped during the current compaction run. Reset to 0 when compactor is idle.",
@@ -235,6 +299,38 @@ func newCompactor(
 			Name: "cortex_compactor_garbage_collected_blocks_total",
 			Help: "Total number of blocks marked for deletion by compactor.",
 		}),
+		blocksMarkedForNoCompaction: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_marked_for_no_compaction_total",
+			Help: "Total number of blocks marked for no compaction by compactor, by reason.",
+		}, []string{"reason"}),
+		blocksSkippedFromCompaction: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_skipped_total",
+			Help: "Total number of blocks skipped by tenant compactions because marked for no compaction, by reason.",
+		}, []string{"reason"}),
+		blocksRewrittenBySeriesDeletion: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_rewritten_by_series_deletion_total",
+			Help: "Total number of blocks rewritten to remove the series deleted by tombstones.",
+		}),
+		tombstonesProcessed: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_tombstones_processed_total",
+			Help: "Total number of tombstones whose series have been removed from the blocks.",
+		}),
+		splitAndMergeJobsCompleted: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_split_and_merge_jobs_completed_total",
+			Help: "Total number of split-and-merge compaction jobs successfully completed, by stage.",
+		}, []string{"stage"}),
+		splitAndMergeJobsFailed: promauto.With(registerer).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_split_and_merge_jobs_failed_total",
+			Help: "Total number of split-and-merge compaction jobs failed, by stage.",
+		}, []string{"stage"}),
+		blocksUploaded: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_uploaded_total",
+			Help: "Total number of blocks successfully uploaded via the blocks upload API.",
+		}),
+		blockUploadValidationFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_compactor_block_upload_validation_failures_total",
+			Help: "Total number of blocks uploaded via the blocks upload API which failed validation.",
+		}),
 	}
 
 	if len(compactorCfg.EnabledTenants) > 0 {
@@ -328,12 +424,11 @@ func (c *Compactor) starting(ctx context.Context) error {
 
 	// Create the blocks cleaner (service).
 	c.blocksCleaner = NewBlocksCleaner(BlocksCleanerConfig{
-		DataDir:             c.compactorCfg.DataDir,
-		MetaSyncConcurrency: c.compactorCfg.MetaSyncConcurrency,
-		DeletionDelay:       c.compactorCfg.DeletionDelay,
-		CleanupInterval:     util.DurationWithJitter(c.compactorCfg.CompactionInterval, 0.1),
-		CleanupConcurrency:  c.compactorCfg.CleanupConcurrency,
-	}, c.bucketClient, c.usersScanner, c.parentLogger, c.registerer)
+		DeletionDelay:              c.compactorCfg.DeletionDelay,
+		PartialBlocksDeletionDelay: c.compactorCfg.PartialBlocksDeletionDelay,
+		CleanupInterval:            util.DurationWithJitter(c.compactorCfg.CompactionInterval, 0.1),
+		CleanupConcurrency:         c.compactorCfg.CleanupConcurrency,
+	}, c.bucketClient, c.usersScanner, c.cfgProvider, c.parentLogger, c.registerer)
 
 	// Ensure an initial cleanup occurred before starting the compactor.
 	if err := services.StartAndAwaitRunning(ctx, c.blocksCleaner); err != nil {
@@ -424,6 +519,16 @@ func (c *Compactor) compactUsers(ctx context.Context) error {
 	})
 
 	errs := tsdb_errors.NewMulti()
+	ownedTenants := map[string]struct{}{}
+
+	// Update the number of owned tenants once done, and stop tracking the compaction
+	// status of the tenants not owned anymore, unless the run has been interrupted.
+	defer func() {
+		if ctx.Err() == nil {
+			c.compactionRunOwnedTenants.Set(float64(len(ownedTenants)))
+			c.compactionStatus.retainTenants(ownedTenants)
+		}
+	}()
 
 	for _, userID := range users {
 		// Ensure the context has not been canceled (ie. compactor shutdown has been triggered).
@@ -433,7 +538,7 @@ func (c *Compactor) compactUsers(ctx context.Context) error {
 		}
 
 		// Ensure the user ID belongs to our shard.
-		if owned, err := c.ownUser(userID); err != nil {
+		if owned, err := c.ownUserForCompaction(userID); err != nil {
 			c.compactionRunSkippedTenants.Inc()
 			level.Warn(c.logger).Log("msg", "unable to check if user is owned by this shard", "user", userID, "err", err)
 			continue
@@ -443,6 +548,8 @@ func (c *Compactor) compactUsers(ctx context.Context) error {
 			continue
 		}
 
+		ownedTenants[userID] = struct{}{}
+
 		if markedForDeletion, err := cortex_tsdb.TenantDeletionMarkExists(ctx, c.bucketClient, userID); err != nil {
 			c.compactionRunSkippedTenants.Inc()
 			level.Warn(c.logger).Log("msg", "unable to check if user is marked for deletion", "user", userID, "err", err)
@@ -455,7 +562,11 @@ func (c *Compactor) compactUsers(ctx context.Context) error {
 
 		level.Info(c.logger).Log("msg", "starting compaction of user blocks", "user", userID)
 
-		if err = c.compactUser(ctx, userID); err != nil {
+		c.compactionStatus.compactionStarted(userID)
+		err = c.compactUser(ctx, userID)
+		c.compactionStatus.compactionFinished(userID, err)
+
+		if err != nil {
 			c.compactionRunFailedTenants.Inc()
 			level.Error(c.logger).Log("msg", "failed to compact user blocks", "user", userID, "err", err)
 			errs.Add(errors.Wrapf(err, "failed to compact user blocks (user: %s)", userID))
@@ -470,7 +581,7 @@ func (c *Compactor) compactUsers(ctx context.Context) error {
 }
 
 func (c *Compactor) compactUser(ctx context.Context, userID string) error {
-	bucket := bucket.NewUserBucketClient(userID, c.bucketClient)
+	bucket := bucket.NewSSEBucketClient(userID, bucket.NewUserBucketClient(userID, c.bucketClient), c.cfgProvider)
 
 	reg := prometheus.NewRegistry()
 	defer c.syncerMetrics.gatherThanosSyncerMetrics(reg)
@@ -479,7 +590,7 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 
 	// Filters out duplicate blocks that can be formed from two or more overlapping
 	// blocks that fully submatches the source blocks of the older blocks.
-	deduplicateBlocksFilter := block.NewDeduplicateFilter()
+	deduplicateBlocksFilter := NewShardAwareDeduplicateFilter()
 
 	// While fetching blocks, we filter out blocks that were marked for deletion by using IgnoreDeletionMarkFilter.
 	// The delay of deleteDelay/2 is added to ensure we fetch blocks that are meant to be deleted but do not have a replacement yet.
@@ -489,6 +600,15 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		time.Duration(c.compactorCfg.DeletionDelay.Seconds()/2)*time.Second,
 		c.compactorCfg.MetaSyncConcurrency)
 
+	// Blocks marked for no compaction are filtered out, so that they're neither compacted
+	// nor rewritten, while they're still queried.
+	noCompactionMarkFilter := NewNoCompactionMarkFilter(ulogger, bucket, c.compactorCfg.MetaSyncConcurrency)
+	defer func() {
+		for _, m := range noCompactionMarkFilter.NoCompactMarkedBlocks() {
+			c.blocksSkippedFromCompaction.WithLabelValues(string(m.Reason)).Inc()
+		}
+	}()
+
 	fetcher, err := block.NewMetaFetcher(
 		ulogger,
 		c.compactorCfg.MetaSyncConcurrency,
@@ -506,6 +626,7 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 			block.NewConsistencyDelayMetaFilter(ulogger, c.compactorCfg.ConsistencyDelay, reg),
 			ignoreDeletionMarkFilter,
 			deduplicateBlocksFilter,
+			noCompactionMarkFilter,
 		},
 		nil,
 	)
@@ -513,12 +634,17 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		return err
 	}
 
+	// Remove the deleted series before compacting, so that deleted data is never compacted into new blocks.
+	if err := c.applyTombstones(ctx, userID, bucket, fetcher, ignoreDeletionMarkFilter, ulogger); err != nil {
+		return errors.Wrap(err, "failed to apply tombstones")
+	}
+
 	syncer, err := compact.NewSyncer(
 		ulogger,
 		reg,
 		bucket,
 		fetcher,
-		deduplicateBlocksFilter,
+		deduplicateBlocksFilter.DeduplicateFilter,
 		ignoreDeletionMarkFilter,
 		c.blocksMarkedForDeletion,
 		c.garbageCollectedBlocks,
@@ -528,6 +654,19 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		return errors.Wrap(err, "failed to create syncer")
 	}
 
+	if shardCount := c.cfgProvider.CompactorSplitAndMergeShards(userID); shardCount > 0 {
+		return c.compactUserWithSplitAndMerge(ctx, userID, bucket, syncer, shardCount, ulogger)
+	}
+
+	// Plan the compaction upfront, to track the groups of blocks pending compaction. This
+	// is a best effort, so the compaction runs even if the planning fails.
+	if err := syncer.SyncMetas(ctx); err != nil {
+		return errors.Wrap(err, "sync")
+	}
+	if err := c.planUserCompaction(ctx, userID, syncer.Metas()); err != nil {
+		level.Warn(ulogger).Log("msg", "failed to plan the compaction", "err", err)
+	}
+
 	grouper := compact.NewDefaultGrouper(
 		ulogger,
 		bucket,
@@ -538,13 +677,14 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 		c.garbageCollectedBlocks,
 	)
 
+	compactDir := path.Join(c.compactorCfg.DataDir, "compact")
 	compactor, err := compact.NewBucketCompactor(
 		ulogger,
 		syncer,
 		grouper,
-		c.tsdbPlanner,
+		&trackingPlanner{Planner: c.tsdbPlanner, userID: userID, tracker: c.compactionStatus},
 		c.tsdbCompactor,
-		path.Join(c.compactorCfg.DataDir, "compact"),
+		compactDir,
 		bucket,
 		c.compactorCfg.CompactionConcurrency,
 	)
@@ -553,6 +693,13 @@ func (c *Compactor) compactUser(ctx context.Context, userID string) error {
 	}
 
 	if err := compactor.Compact(ctx); err != nil {
+		// The compaction halts when a block has an unhealthy index (ie. out-of-order chunks). The downloaded
+		// blocks are left in the compaction directory, so we check them and mark the unhealthy ones for no
+		// compaction, in order to skip them from the next compactions.
+		if compact.IsHaltError(err) {
+			c.markDownloadedBlocksIfUnhealthyIndex(ctx, bucket, compactDir, ulogger)
+		}
+
 		return errors.Wrap(err, "compaction")
 	}
 
@@ -586,7 +733,7 @@ func (c *Compactor) ownUser(userID string) (bool, error) {
 	userHash := hasher.Sum32()
 
 	// Check whether this compactor instance owns the user.
-	rs, err := c.ring.Get(userHash, ring.Compactor, []ring.IngesterDesc{})
+	rs, err := c.userRing(userID).Get(userHash, ring.Compactor, []ring.IngesterDesc{})
 	if err != nil {
 		return false, err
 	}
@@ -598,6 +745,33 @@ func (c *Compactor) ownUser(userID string) (bool, error) {
 	return rs.Ingesters[0].Addr == c.ringLifecycler.Addr, nil
 }
 
+// ownUserForCompaction returns whether this compactor compacts the blocks of the user.
+// The tenants compacted with the split-and-merge compaction strategy are compacted by
+// all the compactors of the tenant's shard, each one running the jobs it owns.
+func (c *Compactor) ownUserForCompaction(userID string) (bool, error) {
+	if !c.compactorCfg.ShardingEnabled || c.cfgProvider.CompactorSplitAndMergeShards(userID) <= 0 {
+		return c.ownUser(userID)
+	}
+
+	if !isAllowedUser(c.enabledUsers, c.disabledUsers, userID) {
+		return false, nil
+	}
+
+	return c.userRing(userID).HasInstance(c.ringLifecycler.ID), nil
+}
+
+// userRing returns the ring of the compactors the user is sharded across. When using
+// the shuffle sharding strategy, this is the user's subring.
+func (c *Compactor) userRing(userID string) ring.ReadRing {
+	if c.compactorCfg.ShardingStrategy == util.ShardingStrategyShuffle {
+		if shardSize := c.cfgProvider.CompactorTenantShardSize(userID); shardSize > 0 {
+			return c.ring.ShuffleShard(userID, shardSize)
+		}
+	}
+
+	return c.ring
+}
+
 func isAllowedUser(enabledUsers, disabledUsers map[string]struct{}, userID string) bool {
 	if len(enabledUsers) > 0 {
 		if _, ok := enabledUsers[userID]; !ok {
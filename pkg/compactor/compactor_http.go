@@ -3,6 +3,7 @@ package compactor
 import (
 	"html/template"
 	"net/http"
+	"time"
 
 	"github.com/go-kit/kit/log/level"
 
@@ -23,6 +24,78 @@ var (
 			<p>{{ .Message }}</p>
 		</body>
 	</html>`))
+
+	compactionPlanPageTemplate = template.Must(template.New("plan").Funcs(template.FuncMap{
+		"formatTime": func(t time.Time) string {
+			if t.IsZero() {
+				return "-"
+			}
+			return t.UTC().Format(time.RFC3339)
+		},
+	}).Parse(`
+	<!DOCTYPE html>
+	<html>
+		<head>
+			<meta charset="UTF-8">
+			<title>Cortex Compactor Compaction Plan</title>
+		</head>
+		<body>
+			<h1>Cortex Compactor Compaction Plan</h1>
+			<p>Current time: {{ formatTime .Now }}</p>
+			<table border="1" cellpadding="5" style="border-collapse: collapse">
+				<thead>
+					<tr>
+						<th>Tenant</th>
+						<th>In progress</th>
+						<th>Started at</th>
+						<th>Last successful compaction</th>
+						<th>Last failed compaction</th>
+						<th>Pending groups</th>
+						<th>Estimated input bytes</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{ range .Tenants }}
+					<tr>
+						<td><a href="#{{ .UserID }}">{{ .UserID }}</a></td>
+						<td>{{ .InProgress }}</td>
+						<td>{{ formatTime .StartedAt }}</td>
+						<td>{{ formatTime .LastSuccessfulCompaction }}</td>
+						<td>{{ formatTime .LastFailedCompaction }}{{ if .LastError }} ({{ .LastError }}){{ end }}</td>
+						<td align="right">{{ len .PendingGroups }}</td>
+						<td align="right">{{ .EstimatedInputBytes }}</td>
+					</tr>
+					{{ end }}
+				</tbody>
+			</table>
+
+			{{ range .Tenants }}{{ if .PendingGroups }}
+			<h2 id="{{ .UserID }}">Tenant {{ .UserID }}</h2>
+			<table border="1" cellpadding="5" style="border-collapse: collapse">
+				<thead>
+					<tr>
+						<th>Group</th>
+						<th>Min time</th>
+						<th>Max time</th>
+						<th>Blocks</th>
+						<th>Estimated input bytes</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{ range .PendingGroups }}
+					<tr>
+						<td>{{ .Key }}</td>
+						<td>{{ formatTime .MinTime }}</td>
+						<td>{{ formatTime .MaxTime }}</td>
+						<td>{{ range .Blocks }}{{ . }}<br>{{ end }}</td>
+						<td align="right">{{ .EstimatedInputBytes }}</td>
+					</tr>
+					{{ end }}
+				</tbody>
+			</table>
+			{{ end }}{{ end }}
+		</body>
+	</html>`))
 )
 
 func writeMessage(w http.ResponseWriter, message string) {
@@ -51,3 +124,14 @@ func (c *Compactor) RingHandler(w http.ResponseWriter, req *http.Request) {
 
 	c.ring.ServeHTTP(w, req)
 }
+
+// CompactionPlanHandler shows the compaction status and plan of the tenants owned by this compactor.
+func (c *Compactor) CompactionPlanHandler(w http.ResponseWriter, req *http.Request) {
+	util.RenderHTTPResponse(w, struct {
+		Now     time.Time                 `json:"now"`
+		Tenants []*tenantCompactionStatus `json:"tenants"`
+	}{
+		Now:     time.Now(),
+		Tenants: c.compactionStatus.getTenants(),
+	}, compactionPlanPageTemplate, req)
+}
@@ -26,6 +26,8 @@ type RingConfig struct {
 	WaitStabilityMinDuration time.Duration `yaml:"wait_stability_min_duration"`
 	WaitStabilityMaxDuration time.Duration `yaml:"wait_stability_max_duration"`
 
+	AutoForgetUnhealthyPeriods int `yaml:"auto_forget_unhealthy_periods"`
+
 	// Instance details
 	InstanceID             string   `yaml:"instance_id" doc:"hidden"`
 	InstanceInterfaceNames []string `yaml:"instance_interface_names"`
@@ -53,6 +55,8 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.WaitStabilityMinDuration, "compactor.ring.wait-stability-min-duration", time.Minute, "Minimum time to wait for ring stability at startup. 0 to disable.")
 	f.DurationVar(&cfg.WaitStabilityMaxDuration, "compactor.ring.wait-stability-max-duration", 5*time.Minute, "Maximum time to wait for ring stability at startup. If the compactor ring keep changing after this period of time, the compactor will start anyway.")
 
+	f.IntVar(&cfg.AutoForgetUnhealthyPeriods, "compactor.ring.auto-forget-unhealthy-periods", 0, "Number of consecutive heartbeat timeouts after which an unhealthy compactor is automatically removed from the ring by the healthy compactors, so that its tenants are compacted by the other compactors. 0 to disable.")
+
 	// Instance flags
 	cfg.InstanceInterfaceNames = []string{"eth0", "en0"}
 	f.Var((*flagext.StringSlice)(&cfg.InstanceInterfaceNames), "compactor.ring.instance-interface-names", "Name of network interface to read address from.")
@@ -90,6 +94,7 @@ func (cfg *RingConfig) ToLifecyclerConfig() ring.LifecyclerConfig {
 	lc.JoinAfter = 0
 	lc.MinReadyDuration = 0
 	lc.FinalSleep = 0
+	lc.AutoForgetUnhealthyPeriods = cfg.AutoForgetUnhealthyPeriods
 
 	// We use a safe default instead of exposing to config option to the user
 	// in order to simplify the config.
@@ -34,10 +34,12 @@ import (
 	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/concurrency"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	cortex_testutil "github.com/cortexproject/cortex/pkg/util/test"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 func TestConfig_ShouldSupportYamlConfig(t *testing.T) {
@@ -84,34 +86,58 @@ func TestConfig_ShouldSupportCliFlags(t *testing.T) {
 
 func TestConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
-		setup    func(cfg *Config)
+		setup    func(cfg *Config, limits *validation.Limits)
 		expected string
 	}{
 		"should pass with the default config": {
-			setup:    func(cfg *Config) {},
+			setup:    func(cfg *Config, limits *validation.Limits) {},
 			expected: "",
 		},
 		"should pass with only 1 block range period": {
-			setup: func(cfg *Config) {
+			setup: func(cfg *Config, limits *validation.Limits) {
 				cfg.BlockRanges = cortex_tsdb.DurationList{time.Hour}
 			},
 			expected: "",
 		},
 		"should fail with non divisible block range periods": {
-			setup: func(cfg *Config) {
+			setup: func(cfg *Config, limits *validation.Limits) {
 				cfg.BlockRanges = cortex_tsdb.DurationList{2 * time.Hour, 12 * time.Hour, 24 * time.Hour, 30 * time.Hour}
 			},
 			expected: errors.Errorf(errInvalidBlockRanges, 30*time.Hour, 24*time.Hour).Error(),
 		},
+		"should fail with an unsupported sharding strategy": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.ShardingEnabled = true
+				cfg.ShardingStrategy = "unknown"
+			},
+			expected: errInvalidShardingStrategy.Error(),
+		},
+		"should fail with the shuffle-sharding strategy and no tenant shard size": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.ShardingEnabled = true
+				cfg.ShardingStrategy = util.ShardingStrategyShuffle
+				limits.CompactorTenantShardSize = 0
+			},
+			expected: errInvalidTenantShardSize.Error(),
+		},
+		"should pass with the shuffle-sharding strategy and a tenant shard size": {
+			setup: func(cfg *Config, limits *validation.Limits) {
+				cfg.ShardingEnabled = true
+				cfg.ShardingStrategy = util.ShardingStrategyShuffle
+				limits.CompactorTenantShardSize = 1
+			},
+			expected: "",
+		},
 	}
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
 			cfg := &Config{}
-			flagext.DefaultValues(cfg)
-			testData.setup(cfg)
+			limits := &validation.Limits{}
+			flagext.DefaultValues(cfg, limits)
+			testData.setup(cfg, limits)
 
-			if actualErr := cfg.Validate(); testData.expected != "" {
+			if actualErr := cfg.Validate(*limits); testData.expected != "" {
 				assert.EqualError(t, actualErr, testData.expected)
 			} else {
 				assert.NoError(t, actualErr)
@@ -422,11 +448,21 @@ func TestCompactor_ShouldIterateOverUsersAndRunCompaction(t *testing.T) {
 	bucketClient.MockExists(path.Join("user-1", cortex_tsdb.TenantDeletionMarkPath), false, nil)
 	bucketClient.MockExists(path.Join("user-2", cortex_tsdb.TenantDeletionMarkPath), false, nil)
 	bucketClient.MockIter("user-1/", []string{"user-1/01DTVP434PA9VFXSW2JKB3392D"}, nil)
+	bucketClient.MockIter("user-1/tombstones", nil, nil)
+	bucketClient.MockGet("user-1/bucket-index.json.gz", "", nil)
+	bucketClient.MockUpload("user-1/bucket-index.json.gz", nil)
 	bucketClient.MockIter("user-2/", []string{"user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ"}, nil)
+	bucketClient.MockIter("user-2/tombstones", nil, nil)
+	bucketClient.MockGet("user-2/bucket-index.json.gz", "", nil)
+	bucketClient.MockUpload("user-2/bucket-index.json.gz", nil)
 	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", mockBlockMetaJSON("01DTVP434PA9VFXSW2JKB3392D"), nil)
+	bucketClient.MockAttributes("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/deletion-mark.json", "", nil)
+	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/no-compact-mark.json", "", nil)
 	bucketClient.MockGet("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", mockBlockMetaJSON("01DTW0ZCPDDNV4BV83Q2SV4QAZ"), nil)
+	bucketClient.MockAttributes("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 	bucketClient.MockGet("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/deletion-mark.json", "", nil)
+	bucketClient.MockGet("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/no-compact-mark.json", "", nil)
 
 	c, _, tsdbPlanner, logs, registry, cleanup := prepare(t, prepareConfig(), bucketClient)
 	defer cleanup()
@@ -446,8 +482,9 @@ func TestCompactor_ShouldIterateOverUsersAndRunCompaction(t *testing.T) {
 
 	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
 
-	// Ensure a plan has been executed for the blocks of each user.
-	tsdbPlanner.AssertNumberOfCalls(t, "Plan", 2)
+	// Ensure a plan has been executed for the blocks of each user (the planner is called twice
+	// for each user: once to track the pending groups and once by the compaction).
+	tsdbPlanner.AssertNumberOfCalls(t, "Plan", 4)
 
 	assert.ElementsMatch(t, []string{
 		`level=info component=cleaner msg="started hard deletion of blocks marked for deletion, and blocks for tenants marked for deletion"`,
@@ -528,13 +565,20 @@ func TestCompactor_ShouldNotCompactBlocksMarkedForDeletion(t *testing.T) {
 	bucketClient := &bucket.ClientMock{}
 	bucketClient.MockIter("", []string{"user-1"}, nil)
 	bucketClient.MockIter("user-1/", []string{"user-1/01DTVP434PA9VFXSW2JKB3392D", "user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ"}, nil)
+	bucketClient.MockIter("user-1/tombstones", nil, nil)
+	bucketClient.MockGet("user-1/bucket-index.json.gz", "", nil)
+	bucketClient.MockUpload("user-1/bucket-index.json.gz", nil)
 	bucketClient.MockExists(path.Join("user-1", cortex_tsdb.TenantDeletionMarkPath), false, nil)
 
 	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", mockBlockMetaJSON("01DTVP434PA9VFXSW2JKB3392D"), nil)
+	bucketClient.MockAttributes("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/deletion-mark.json", mockDeletionMarkJSON("01DTVP434PA9VFXSW2JKB3392D", time.Now()), nil)
+	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/no-compact-mark.json", "", nil)
 
 	bucketClient.MockGet("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", mockBlockMetaJSON("01DTW0ZCPDDNV4BV83Q2SV4QAZ"), nil)
+	bucketClient.MockAttributes("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 	bucketClient.MockGet("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/deletion-mark.json", mockDeletionMarkJSON("01DTW0ZCPDDNV4BV83Q2SV4QAZ", time.Now().Add(-cfg.DeletionDelay)), nil)
+	bucketClient.MockGet("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/no-compact-mark.json", "", nil)
 	bucketClient.MockIter("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ", []string{"user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", "user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/deletion-mark.json"}, nil)
 	bucketClient.MockDelete("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", nil)
 	bucketClient.MockDelete("user-1/01DTW0ZCPDDNV4BV83Q2SV4QAZ/deletion-mark.json", nil)
@@ -558,8 +602,9 @@ func TestCompactor_ShouldNotCompactBlocksMarkedForDeletion(t *testing.T) {
 
 	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
 
-	// Only one user's block is compacted.
-	tsdbPlanner.AssertNumberOfCalls(t, "Plan", 1)
+	// Only one user's block is compacted (the planner is called twice for each user:
+	// once to track the pending groups and once by the compaction).
+	tsdbPlanner.AssertNumberOfCalls(t, "Plan", 2)
 
 	assert.ElementsMatch(t, []string{
 		`level=info component=cleaner msg="started hard deletion of blocks marked for deletion, and blocks for tenants marked for deletion"`,
@@ -644,6 +689,12 @@ func TestCompactor_ShouldNotCompactBlocksForUsersMarkedForCompaction(t *testing.
 	bucketClient.MockDelete("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", nil)
 	bucketClient.MockDelete("user-1/01DTVP434PA9VFXSW2JKB3392D/index", nil)
 
+	// Once blocks have been deleted, the other tenant objects are deleted too.
+	bucketClient.MockDelete("user-1/bucket-index.json.gz", nil)
+	bucketClient.MockIter("user-1/debug/metas", nil, nil)
+	bucketClient.MockIter("user-1/tombstones", nil, nil)
+	bucketClient.MockIter("user-1/markers", []string{"user-1/markers/tenant-deletion-mark.json"}, nil)
+
 	c, _, tsdbPlanner, logs, registry, cleanup := prepare(t, cfg, bucketClient)
 	defer cleanup()
 
@@ -672,6 +723,7 @@ func TestCompactor_ShouldNotCompactBlocksForUsersMarkedForCompaction(t *testing.
 		`level=debug component=cleaner org_id=user-1 msg="deleted file" file=01DTVP434PA9VFXSW2JKB3392D/index bucket=mock`,
 		`level=info component=cleaner org_id=user-1 msg="deleted block" block=01DTVP434PA9VFXSW2JKB3392D`,
 		`level=info component=cleaner org_id=user-1 msg="finished deleting blocks for user marked for deletion" deletedBlocks=1`,
+		`level=info component=cleaner org_id=user-1 msg="finished deleting bucket index, debug meta files, tombstones and markers for user marked for deletion"`,
 		`level=info component=cleaner msg="successfully completed hard deletion of blocks marked for deletion, and blocks for tenants marked for deletion"`,
 		`level=info component=compactor msg="discovering users from bucket"`,
 		`level=info component=compactor msg="discovered users from bucket" users=1`,
@@ -733,11 +785,21 @@ func TestCompactor_ShouldCompactAllUsersOnShardingEnabledButOnlyOneInstanceRunni
 	bucketClient.MockExists(path.Join("user-1", cortex_tsdb.TenantDeletionMarkPath), false, nil)
 	bucketClient.MockExists(path.Join("user-2", cortex_tsdb.TenantDeletionMarkPath), false, nil)
 	bucketClient.MockIter("user-1/", []string{"user-1/01DTVP434PA9VFXSW2JKB3392D"}, nil)
+	bucketClient.MockIter("user-1/tombstones", nil, nil)
+	bucketClient.MockGet("user-1/bucket-index.json.gz", "", nil)
+	bucketClient.MockUpload("user-1/bucket-index.json.gz", nil)
 	bucketClient.MockIter("user-2/", []string{"user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ"}, nil)
+	bucketClient.MockIter("user-2/tombstones", nil, nil)
+	bucketClient.MockGet("user-2/bucket-index.json.gz", "", nil)
+	bucketClient.MockUpload("user-2/bucket-index.json.gz", nil)
 	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", mockBlockMetaJSON("01DTVP434PA9VFXSW2JKB3392D"), nil)
+	bucketClient.MockAttributes("user-1/01DTVP434PA9VFXSW2JKB3392D/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/deletion-mark.json", "", nil)
+	bucketClient.MockGet("user-1/01DTVP434PA9VFXSW2JKB3392D/no-compact-mark.json", "", nil)
 	bucketClient.MockGet("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", mockBlockMetaJSON("01DTW0ZCPDDNV4BV83Q2SV4QAZ"), nil)
+	bucketClient.MockAttributes("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 	bucketClient.MockGet("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/deletion-mark.json", "", nil)
+	bucketClient.MockGet("user-2/01DTW0ZCPDDNV4BV83Q2SV4QAZ/no-compact-mark.json", "", nil)
 
 	cfg := prepareConfig()
 	cfg.ShardingEnabled = true
@@ -763,8 +825,9 @@ func TestCompactor_ShouldCompactAllUsersOnShardingEnabledButOnlyOneInstanceRunni
 
 	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
 
-	// Ensure a plan has been executed for the blocks of each user.
-	tsdbPlanner.AssertNumberOfCalls(t, "Plan", 2)
+	// Ensure a plan has been executed for the blocks of each user (the planner is called twice
+	// for each user: once to track the pending groups and once by the compaction).
+	tsdbPlanner.AssertNumberOfCalls(t, "Plan", 4)
 
 	assert.ElementsMatch(t, []string{
 		`level=info component=compactor msg="waiting until compactor is ACTIVE in the ring"`,
@@ -808,9 +871,14 @@ func TestCompactor_ShouldCompactOnlyUsersOwnedByTheInstanceOnShardingEnabledAndM
 	bucketClient.MockIter("", userIDs, nil)
 	for _, userID := range userIDs {
 		bucketClient.MockIter(userID+"/", []string{userID + "/01DTVP434PA9VFXSW2JKB3392D"}, nil)
+		bucketClient.MockIter(userID+"/tombstones", nil, nil)
+		bucketClient.MockGet(userID+"/bucket-index.json.gz", "", nil)
+		bucketClient.MockUpload(userID+"/bucket-index.json.gz", nil)
 		bucketClient.MockExists(path.Join(userID, cortex_tsdb.TenantDeletionMarkPath), false, nil)
 		bucketClient.MockGet(userID+"/01DTVP434PA9VFXSW2JKB3392D/meta.json", mockBlockMetaJSON("01DTVP434PA9VFXSW2JKB3392D"), nil)
+		bucketClient.MockAttributes(userID+"/01DTVP434PA9VFXSW2JKB3392D/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
 		bucketClient.MockGet(userID+"/01DTVP434PA9VFXSW2JKB3392D/deletion-mark.json", "", nil)
+		bucketClient.MockGet(userID+"/01DTVP434PA9VFXSW2JKB3392D/no-compact-mark.json", "", nil)
 	}
 
 	// Create a shared KV Store
@@ -863,6 +931,93 @@ func TestCompactor_ShouldCompactOnlyUsersOwnedByTheInstanceOnShardingEnabledAndM
 	}
 }
 
+func TestCompactor_ShouldCompactOnlyUsersOwnedByTheInstanceOnShuffleShardingEnabledAndMultipleInstancesRunning(t *testing.T) {
+	t.Parallel()
+
+	numUsers := 30
+
+	// Setup user IDs
+	userIDs := make([]string, 0, numUsers)
+	for i := 1; i <= numUsers; i++ {
+		userIDs = append(userIDs, fmt.Sprintf("user-%d", i))
+	}
+
+	// Mock the bucket to contain all users, each one with one block.
+	bucketClient := &bucket.ClientMock{}
+	bucketClient.MockIter("", userIDs, nil)
+	for _, userID := range userIDs {
+		bucketClient.MockIter(userID+"/", []string{userID + "/01DTVP434PA9VFXSW2JKB3392D"}, nil)
+		bucketClient.MockIter(userID+"/tombstones", nil, nil)
+		bucketClient.MockGet(userID+"/bucket-index.json.gz", "", nil)
+		bucketClient.MockUpload(userID+"/bucket-index.json.gz", nil)
+		bucketClient.MockExists(path.Join(userID, cortex_tsdb.TenantDeletionMarkPath), false, nil)
+		bucketClient.MockGet(userID+"/01DTVP434PA9VFXSW2JKB3392D/meta.json", mockBlockMetaJSON("01DTVP434PA9VFXSW2JKB3392D"), nil)
+		bucketClient.MockAttributes(userID+"/01DTVP434PA9VFXSW2JKB3392D/meta.json", objstore.ObjectAttributes{LastModified: time.Now()}, nil)
+		bucketClient.MockGet(userID+"/01DTVP434PA9VFXSW2JKB3392D/deletion-mark.json", "", nil)
+		bucketClient.MockGet(userID+"/01DTVP434PA9VFXSW2JKB3392D/no-compact-mark.json", "", nil)
+	}
+
+	// Create a shared KV Store
+	kvstore := consul.NewInMemoryClient(ring.GetCodec())
+
+	// Create three compactors, with a tenant shard size of 2.
+	var compactors []*Compactor
+	var logs []*concurrency.SyncBuffer
+
+	for i := 1; i <= 3; i++ {
+		cfg := prepareConfig()
+		cfg.ShardingEnabled = true
+		cfg.ShardingStrategy = util.ShardingStrategyShuffle
+		cfg.ShardingRing.InstanceID = fmt.Sprintf("compactor-%d", i)
+		cfg.ShardingRing.InstanceAddr = fmt.Sprintf("127.0.0.%d", i)
+		cfg.ShardingRing.WaitStabilityMinDuration = 3 * time.Second
+		cfg.ShardingRing.WaitStabilityMaxDuration = 10 * time.Second
+		cfg.ShardingRing.KVStore.Mock = kvstore
+
+		c, _, tsdbPlanner, l, _, cleanup := prepare(t, cfg, bucketClient)
+		defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+		defer cleanup()
+
+		c.cfgProvider.(*mockConfigProvider).tenantShardSize = 2
+
+		compactors = append(compactors, c)
+		logs = append(logs, l)
+
+		tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil)
+	}
+
+	// Start all compactors at the same time, so that they all join the ring
+	// before the ring topology is considered stable.
+	for _, c := range compactors {
+		require.NoError(t, c.StartAsync(context.Background()))
+	}
+	for _, c := range compactors {
+		require.NoError(t, c.AwaitRunning(context.Background()))
+	}
+
+	// Wait until a run has been completed on each compactor
+	for _, c := range compactors {
+		cortex_testutil.Poll(t, 10*time.Second, 1.0, func() interface{} {
+			return prom_testutil.ToFloat64(c.compactionRunsCompleted)
+		})
+	}
+
+	// Ensure that each user has been compacted by the correct instance, belonging to its shard.
+	for _, userID := range userIDs {
+		c, l, err := findCompactorByUserID(compactors, logs, userID)
+		require.NoError(t, err)
+		assert.Contains(t, l.String(), fmt.Sprintf(`level=info component=compactor msg="successfully compacted user blocks" user=%s`, userID))
+		assert.True(t, c.ring.ShuffleShard(userID, 2).HasInstance(c.ringLifecycler.ID))
+	}
+
+	// Ensure the owned tenants are tracked.
+	ownedTenants := 0.0
+	for _, c := range compactors {
+		ownedTenants += prom_testutil.ToFloat64(c.compactionRunOwnedTenants)
+	}
+	assert.Equal(t, float64(numUsers), ownedTenants)
+}
+
 func createTSDBBlock(t *testing.T, dir string, minT, maxT int64, externalLabels map[string]string) ulid.ULID {
 	// Create a temporary dir for TSDB.
 	tempDir, err := ioutil.TempDir(os.TempDir(), "tsdb")
@@ -1009,7 +1164,7 @@ func prepare(t *testing.T, compactorCfg Config, bucketClient objstore.Bucket) (*
 	logger := log.NewLogfmtLogger(logs)
 	registry := prometheus.NewRegistry()
 
-	c, err := newCompactor(compactorCfg, storageCfg, logger, registry, func(ctx context.Context) (objstore.Bucket, tsdb.Compactor, compact.Planner, error) {
+	c, err := newCompactor(compactorCfg, storageCfg, newMockConfigProvider(), logger, registry, func(ctx context.Context) (objstore.Bucket, tsdb.Compactor, compact.Planner, error) {
 		return bucketClient, tsdbCompactor, tsdbPlanner, nil
 	})
 	require.NoError(t, err)
@@ -1017,6 +1172,50 @@ func prepare(t *testing.T, compactorCfg Config, bucketClient objstore.Bucket) (*
 	return c, tsdbCompactor, tsdbPlanner, logs, registry, cleanup
 }
 
+type mockConfigProvider struct {
+	userRetentionPeriods map[string]time.Duration
+	tenantShardSize      int
+	splitAndMergeShards  int
+	blockUploadEnabled   bool
+}
+
+func newMockConfigProvider() *mockConfigProvider {
+	return &mockConfigProvider{
+		userRetentionPeriods: make(map[string]time.Duration),
+	}
+}
+
+func (m *mockConfigProvider) CompactorTenantShardSize(_ string) int {
+	return m.tenantShardSize
+}
+
+func (m *mockConfigProvider) CompactorSplitAndMergeShards(_ string) int {
+	return m.splitAndMergeShards
+}
+
+func (m *mockConfigProvider) CompactorBlockUploadEnabled(_ string) bool {
+	return m.blockUploadEnabled
+}
+
+func (m *mockConfigProvider) S3SSEType(user string) string {
+	return ""
+}
+
+func (m *mockConfigProvider) S3SSEKMSKeyID(userID string) string {
+	return ""
+}
+
+func (m *mockConfigProvider) S3SSEKMSEncryptionContext(userID string) string {
+	return ""
+}
+
+func (m *mockConfigProvider) CompactorBlocksRetentionPeriod(user string) time.Duration {
+	if result, ok := m.userRetentionPeriods[user]; ok {
+		return result
+	}
+	return 0
+}
+
 type tsdbCompactorMock struct {
 	mock.Mock
 }
@@ -0,0 +1,186 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// OutOfOrderChunksNoCompactReason is the reason of excluding from compaction a block whose
+	// index has series with out-of-order chunks.
+	OutOfOrderChunksNoCompactReason metadata.NoCompactReason = "block-index-out-of-order-chunk"
+
+	// CorruptedIndexNoCompactReason is the reason of excluding from compaction a block whose
+	// index has chunks outside the block time range.
+	CorruptedIndexNoCompactReason metadata.NoCompactReason = "block-index-corrupted"
+)
+
+// NoCompactionMarkFilter is a block.Fetcher filter that removes the blocks marked for no compaction
+// from the fetched metas, while gathering their no-compact-mark.json markers. Not goroutine safe.
+type NoCompactionMarkFilter struct {
+	logger      log.Logger
+	bkt         objstore.InstrumentedBucketReader
+	concurrency int
+	markedMetas map[ulid.ULID]*metadata.NoCompactMark
+}
+
+// NewNoCompactionMarkFilter creates a NoCompactionMarkFilter reading the markers with the given concurrency.
+func NewNoCompactionMarkFilter(logger log.Logger, bkt objstore.InstrumentedBucketReader, concurrency int) *NoCompactionMarkFilter {
+	return &NoCompactionMarkFilter{
+		logger:      logger,
+		bkt:         bkt,
+		concurrency: concurrency,
+	}
+}
+
+// NoCompactMarkedBlocks returns the no compaction markers of the blocks filtered out by the last Filter() call.
+func (f *NoCompactionMarkFilter) NoCompactMarkedBlocks() map[ulid.ULID]*metadata.NoCompactMark {
+	return f.markedMetas
+}
+
+// Filter removes the blocks marked for no compaction from the input metas.
+func (f *NoCompactionMarkFilter) Filter(ctx context.Context, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec) error {
+	f.markedMetas = map[ulid.ULID]*metadata.NoCompactMark{}
+
+	// Make a copy of block IDs to check, in order to avoid concurrency issues
+	// between the scheduler and workers.
+	blockIDs := make([]ulid.ULID, 0, len(metas))
+	for id := range metas {
+		blockIDs = append(blockIDs, id)
+	}
+
+	var (
+		eg  errgroup.Group
+		ch  = make(chan ulid.ULID, f.concurrency)
+		mtx sync.Mutex
+	)
+
+	for i := 0; i < f.concurrency; i++ {
+		eg.Go(func() error {
+			for id := range ch {
+				m := &metadata.NoCompactMark{}
+				if err := metadata.ReadMarker(ctx, f.logger, f.bkt, id.String(), m); err != nil {
+					if errors.Is(err, metadata.ErrorMarkerNotFound) {
+						continue
+					}
+					if errors.Is(err, metadata.ErrorUnmarshalMarker) {
+						level.Warn(f.logger).Log("msg", "found partial no-compact-mark.json; if we will see it happening often for the same block, consider manually deleting no-compact-mark.json from the object storage", "block", id, "err", err)
+						continue
+					}
+					return err
+				}
+
+				mtx.Lock()
+				synced.WithLabelValues(block.MarkedForNoCompactionMeta).Inc()
+				f.markedMetas[id] = m
+				delete(metas, id)
+				mtx.Unlock()
+			}
+
+			return nil
+		})
+	}
+
+	// Workers scheduled, distribute blocks.
+	eg.Go(func() error {
+		defer close(ch)
+
+		for _, id := range blockIDs {
+			select {
+			case ch <- id:
+				// Nothing to do.
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		return nil
+	})
+
+	if err := eg.Wait(); err != nil {
+		return errors.Wrap(err, "filter blocks marked for no compaction")
+	}
+
+	return nil
+}
+
+// markBlockIfUnhealthyIndex checks the index of a block downloaded to blockDir and marks the block
+// for no compaction if the index has issues which would prevent the block from being compacted.
+// Returns the index issues if the block has been marked, nil otherwise.
+func (c *Compactor) markBlockIfUnhealthyIndex(ctx context.Context, userBucket objstore.Bucket, meta *metadata.Meta, blockDir string, logger log.Logger) (indexErr, _ error) {
+	stats, err := block.GatherIndexHealthStats(logger, filepath.Join(blockDir, block.IndexFilename), meta.MinTime, meta.MaxTime)
+	if err != nil {
+		return nil, errors.Wrapf(err, "gather index issues for block %s", meta.ULID.String())
+	}
+
+	indexErr = stats.CriticalErr()
+	if indexErr == nil {
+		return nil, nil
+	}
+
+	reason := CorruptedIndexNoCompactReason
+	if stats.OutOfOrderSeries > 0 {
+		reason = OutOfOrderChunksNoCompactReason
+	}
+
+	if err := block.MarkForNoCompact(ctx, logger, userBucket, meta.ULID, reason, indexErr.Error(), c.blocksMarkedForNoCompaction.WithLabelValues(string(reason))); err != nil {
+		return nil, errors.Wrapf(err, "mark block %s for no compaction", meta.ULID.String())
+	}
+
+	level.Warn(logger).Log("msg", "block with not healthy index has been marked for no compaction", "block", meta.ULID.String(), "reason", reason, "err", indexErr)
+	return indexErr, nil
+}
+
+// markDownloadedBlocksIfUnhealthyIndex checks the index of the blocks left in the compaction
+// directory by a halted compaction, marking for no compaction the blocks with an unhealthy index,
+// so that the next compactions will skip them instead of halting again.
+func (c *Compactor) markDownloadedBlocksIfUnhealthyIndex(ctx context.Context, userBucket objstore.Bucket, compactDir string, logger log.Logger) {
+	// The compaction directory contains a sub directory for each compaction group.
+	groupDirs, err := ioutil.ReadDir(compactDir)
+	if err != nil {
+		level.Warn(logger).Log("msg", "failed to read the compaction directory", "dir", compactDir, "err", err)
+		return
+	}
+
+	for _, groupDir := range groupDirs {
+		if !groupDir.IsDir() {
+			continue
+		}
+
+		blockDirs, err := ioutil.ReadDir(filepath.Join(compactDir, groupDir.Name()))
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to read the compaction group directory", "dir", groupDir.Name(), "err", err)
+			continue
+		}
+
+		for _, blockDir := range blockDirs {
+			if _, err := ulid.Parse(blockDir.Name()); err != nil || !blockDir.IsDir() {
+				continue
+			}
+
+			dir := filepath.Join(compactDir, groupDir.Name(), blockDir.Name())
+
+			meta, err := metadata.ReadFromDir(dir)
+			if err != nil {
+				continue
+			}
+
+			// The download of the block may have not been completed, so failing to check the index is not an error.
+			if _, err := c.markBlockIfUnhealthyIndex(ctx, userBucket, meta, dir, logger); err != nil {
+				level.Debug(logger).Log("msg", "failed to check the index of a block left by a halted compaction", "block", meta.ULID.String(), "err", err)
+			}
+		}
+	}
+}
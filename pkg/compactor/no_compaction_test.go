@@ -0,0 +1,145 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+)
+
+func TestNoCompactionMarkFilter(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient)
+
+	block1 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 10, 20, nil)
+	block2 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 20, 30, nil)
+	require.NoError(t, block.MarkForNoCompact(ctx, log.NewNopLogger(), userBucket, block1, metadata.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	metas := map[ulid.ULID]*metadata.Meta{
+		block1: {},
+		block2: {},
+	}
+
+	f := NewNoCompactionMarkFilter(log.NewNopLogger(), userBucket, 2)
+	synced := extprom.NewTxGaugeVec(nil, prometheus.GaugeOpts{}, []string{"state"})
+	require.NoError(t, f.Filter(ctx, metas, synced))
+
+	assert.Equal(t, map[ulid.ULID]*metadata.Meta{block2: {}}, metas)
+	require.Len(t, f.NoCompactMarkedBlocks(), 1)
+	assert.Equal(t, metadata.ManualNoCompactReason, f.NoCompactMarkedBlocks()[block1].Reason)
+}
+
+func TestCompactor_ShouldSkipBlocksMarkedForNoCompaction(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient)
+
+	block1 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 10, 20, map[string]string{"key": "value"})
+	block2 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 20, 30, map[string]string{"key": "value"})
+	block3 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 30, 40, map[string]string{"key": "value"})
+	require.NoError(t, block.MarkForNoCompact(ctx, log.NewNopLogger(), userBucket, block2, metadata.ManualNoCompactReason, "", prometheus.NewCounter(prometheus.CounterOpts{})))
+
+	c, tsdbCompactor, tsdbPlanner, _, registry, cleanup := prepare(t, prepareConfig(), bucketClient)
+	defer cleanup()
+
+	c.bucketClient = bucketClient
+	c.tsdbCompactor = tsdbCompactor
+	c.tsdbPlanner = tsdbPlanner
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil)
+
+	require.NoError(t, c.compactUser(ctx, "user-1"))
+
+	// The block marked for no compaction should never be planned for compaction.
+	require.NotEmpty(t, tsdbPlanner.Calls)
+	for _, call := range tsdbPlanner.Calls {
+		var planned []ulid.ULID
+		for _, meta := range call.Arguments.Get(1).([]*metadata.Meta) {
+			planned = append(planned, meta.ULID)
+		}
+		assert.ElementsMatch(t, []ulid.ULID{block1, block3}, planned)
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(registry.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_compactor_blocks_skipped_total Total number of blocks skipped by tenant compactions because marked for no compaction, by reason.
+		# TYPE cortex_compactor_blocks_skipped_total counter
+		cortex_compactor_blocks_skipped_total{reason="manual"} 1
+	`), "cortex_compactor_blocks_skipped_total"))
+}
+
+func TestCompactor_MarkBlockIfUnhealthyIndex(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient)
+
+	c, _, _, _, registry, cleanup := prepare(t, prepareConfig(), bucketClient)
+	defer cleanup()
+
+	blockID := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 10, 20, nil)
+	blockDir := filepath.Join(storageDir, "user-1", blockID.String())
+	meta, err := metadata.ReadFromDir(blockDir)
+	require.NoError(t, err)
+
+	// A healthy block should not be marked.
+	indexErr, err := c.markBlockIfUnhealthyIndex(ctx, userBucket, meta, blockDir, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NoError(t, indexErr)
+
+	exists, err := userBucket.Exists(ctx, path.Join(blockID.String(), metadata.NoCompactMarkFilename))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// A block whose chunks are outside the block time range should be marked.
+	meta.MinTime = 100
+	meta.MaxTime = 200
+
+	indexErr, err = c.markBlockIfUnhealthyIndex(ctx, userBucket, meta, blockDir, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Error(t, indexErr)
+
+	mark := &metadata.NoCompactMark{}
+	require.NoError(t, metadata.ReadMarker(ctx, log.NewNopLogger(), userBucket, blockID.String(), mark))
+	assert.Equal(t, CorruptedIndexNoCompactReason, mark.Reason)
+	assert.Equal(t, indexErr.Error(), mark.Details)
+
+	assert.NoError(t, testutil.GatherAndCompare(registry.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_compactor_blocks_marked_for_no_compaction_total Total number of blocks marked for no compaction by compactor, by reason.
+		# TYPE cortex_compactor_blocks_marked_for_no_compaction_total counter
+		cortex_compactor_blocks_marked_for_no_compaction_total{reason="block-index-corrupted"} 1
+	`), "cortex_compactor_blocks_marked_for_no_compaction_total"))
+}
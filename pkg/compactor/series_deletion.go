@@ -0,0 +1,185 @@
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+// applyTombstones removes the series deleted by the pending tombstones, past the grace
+// period, from the user blocks. Each block overlapping with a tombstone is rewritten
+// without the deleted series and then marked for deletion. Once all the blocks have
+// been rewritten, the tombstones are marked as processed.
+func (c *Compactor) applyTombstones(ctx context.Context, userID string, userBucket objstore.Bucket, fetcher *block.MetaFetcher, deletionMarkFilter *block.IgnoreDeletionMarkFilter, logger log.Logger) error {
+	all, err := cortex_tsdb.ReadTombstones(ctx, c.bucketClient, userID, logger)
+	if err != nil {
+		return errors.Wrap(err, "failed to read tombstones")
+	}
+
+	var pending []*cortex_tsdb.Tombstone
+	for _, t := range all {
+		if t.State != cortex_tsdb.TombstonePending {
+			continue
+		}
+
+		// The request can still be cancelled.
+		if time.Since(time.Unix(0, t.RequestCreatedAt*int64(time.Millisecond))) < c.compactorCfg.SeriesDeletionGracePeriod {
+			continue
+		}
+
+		pending = append(pending, t)
+	}
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	metas, _, err := fetcher.Fetch(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch blocks metadata")
+	}
+
+	deletionMarks := deletionMarkFilter.DeletionMarkBlocks()
+
+	for id, meta := range metas {
+		// Blocks marked for deletion (ie. already rewritten) will be removed anyway.
+		if _, ok := deletionMarks[id]; ok {
+			continue
+		}
+
+		var blockTombstones []*cortex_tsdb.Tombstone
+		for _, t := range pending {
+			// Block max time is exclusive, while tombstones end time is inclusive.
+			if t.StartTime < meta.MaxTime && t.EndTime >= meta.MinTime {
+				blockTombstones = append(blockTombstones, t)
+			}
+		}
+
+		if len(blockTombstones) == 0 {
+			continue
+		}
+
+		if err := c.rewriteBlock(ctx, userBucket, meta, blockTombstones, logger); err != nil {
+			return errors.Wrapf(err, "failed to remove deleted series from block %s", id.String())
+		}
+	}
+
+	for _, t := range pending {
+		t.State = cortex_tsdb.TombstoneProcessed
+		if err := cortex_tsdb.WriteTombstone(ctx, c.bucketClient, userID, t); err != nil {
+			return errors.Wrapf(err, "failed to mark tombstone %s as processed", t.RequestID)
+		}
+
+		c.tombstonesProcessed.Inc()
+		level.Info(logger).Log("msg", "processed tombstone", "request_id", t.RequestID)
+	}
+
+	return nil
+}
+
+// rewriteBlock rewrites the block without the series deleted by the input tombstones,
+// uploads the new block (if not empty) and marks the input one for deletion.
+func (c *Compactor) rewriteBlock(ctx context.Context, userBucket objstore.Bucket, meta *metadata.Meta, blockTombstones []*cortex_tsdb.Tombstone, logger log.Logger) error {
+	dir := filepath.Join(c.compactorCfg.DataDir, "series-deletion")
+	blockDir := filepath.Join(dir, meta.ULID.String())
+
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrap(err, "failed to clean up the series deletion directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			level.Warn(logger).Log("msg", "failed to clean up the series deletion directory", "dir", dir, "err", err)
+		}
+	}()
+
+	if err := block.Download(ctx, logger, userBucket, meta.ULID, blockDir); err != nil {
+		return errors.Wrap(err, "download block")
+	}
+
+	b, err := tsdb.OpenBlock(logger, blockDir, nil)
+	if err != nil {
+		return errors.Wrap(err, "open block")
+	}
+	defer b.Close()
+
+	var deletions []metadata.DeletionRequest
+	for _, t := range blockTombstones {
+		matchers, err := t.Matchers()
+		if err != nil {
+			return err
+		}
+
+		// Each selector deletes its own set of series.
+		for _, m := range matchers {
+			if err := b.Delete(t.StartTime, t.EndTime, m...); err != nil {
+				return errors.Wrap(err, "delete series")
+			}
+
+			deletions = append(deletions, metadata.DeletionRequest{
+				Matchers:  m,
+				Intervals: tombstones.Intervals{{Mint: t.StartTime, Maxt: t.EndTime}},
+			})
+		}
+	}
+
+	// No series in the block matches the tombstones, so there's nothing to rewrite.
+	if b.Meta().Stats.NumTombstones == 0 {
+		return nil
+	}
+
+	newID, err := c.tsdbCompactor.Compact(dir, []string{blockDir}, []*tsdb.Block{b})
+	if err != nil {
+		return errors.Wrap(err, "rewrite block")
+	}
+
+	// An empty ULID means all the block series have been deleted.
+	if newID != (ulid.ULID{}) {
+		newBlockDir := filepath.Join(dir, newID.String())
+
+		newMeta, err := metadata.InjectThanos(logger, newBlockDir, metadata.Thanos{
+			Labels:     meta.Thanos.Labels,
+			Downsample: meta.Thanos.Downsample,
+			Source:     metadata.CompactorSource,
+			Rewrites: append(meta.Thanos.Rewrites, metadata.Rewrite{
+				Sources:          meta.Compaction.Sources,
+				DeletionsApplied: deletions,
+			}),
+		}, nil)
+		if err != nil {
+			return errors.Wrap(err, "inject Thanos metadata")
+		}
+
+		// The rewritten block has the same sources of the original one, so we add its own ID
+		// to make sure the deduplicate filter drops the original block instead of the new one.
+		newMeta.Compaction.Sources = append(newMeta.Compaction.Sources, newID)
+		if err := newMeta.WriteToDir(logger, newBlockDir); err != nil {
+			return errors.Wrap(err, "write rewritten block meta")
+		}
+
+		if err := block.Upload(ctx, logger, userBucket, newBlockDir); err != nil {
+			return errors.Wrap(err, "upload rewritten block")
+		}
+	}
+
+	if err := block.MarkForDeletion(ctx, logger, userBucket, meta.ULID, "series deleted by tombstones", c.blocksMarkedForDeletion); err != nil {
+		return errors.Wrap(err, "mark block for deletion")
+	}
+
+	c.blocksRewrittenBySeriesDeletion.Inc()
+	level.Info(logger).Log("msg", "removed deleted series from block", "block", meta.ULID.String(), "new_block", newID.String())
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestCompactor_ShouldRemoveSeriesDeletedByTombstones(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now()
+
+	// Each block contains series_id="0" at min time and series_id="1" at max time.
+	block1 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 10, 20, map[string]string{"key": "value"})
+	block2 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 100, 200, map[string]string{"key": "value"})
+
+	// Past the grace period, overlapping block1 only.
+	tombstone1 := cortex_tsdb.NewTombstone("user-1", now.Add(-2*time.Hour).UnixNano()/int64(time.Millisecond), 0, 50, []string{`{series_id="0"}`})
+	// Still within the grace period.
+	tombstone2 := cortex_tsdb.NewTombstone("user-1", now.UnixNano()/int64(time.Millisecond), 0, 200, []string{`{series_id="1"}`})
+	require.NoError(t, cortex_tsdb.WriteTombstone(ctx, bucketClient, "user-1", tombstone1))
+	require.NoError(t, cortex_tsdb.WriteTombstone(ctx, bucketClient, "user-1", tombstone2))
+
+	cfg := prepareConfig()
+	cfg.SeriesDeletionGracePeriod = time.Hour
+
+	c, _, tsdbPlanner, _, registry, cleanup := prepare(t, cfg, bucketClient)
+	defer cleanup()
+
+	// Use a real TSDB compactor to rewrite the blocks.
+	c.tsdbCompactor, err = tsdb.NewLeveledCompactor(ctx, nil, log.NewNopLogger(), cfg.BlockRanges.ToMilliseconds(), downsample.NewPool())
+	require.NoError(t, err)
+	c.bucketClient = bucketClient
+	c.tsdbPlanner = tsdbPlanner
+	tsdbPlanner.On("Plan", mock.Anything, mock.Anything).Return([]*metadata.Meta{}, nil)
+
+	require.NoError(t, c.compactUser(ctx, "user-1"))
+
+	// block1 should have been replaced by a rewritten block.
+	exists, err := bucketClient.Exists(ctx, filepath.Join("user-1", block1.String(), metadata.DeletionMarkFilename))
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = bucketClient.Exists(ctx, filepath.Join("user-1", block2.String(), metadata.DeletionMarkFilename))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	var newBlocks []ulid.ULID
+	require.NoError(t, bucketClient.Iter(ctx, "user-1/", func(name string) error {
+		if id, err := ulid.Parse(strings.TrimSuffix(strings.TrimPrefix(name, "user-1/"), "/")); err == nil && id != block1 && id != block2 {
+			newBlocks = append(newBlocks, id)
+		}
+		return nil
+	}))
+	require.Len(t, newBlocks, 1)
+
+	newMeta, err := block.DownloadMeta(ctx, log.NewNopLogger(), bucket.NewUserBucketClient("user-1", bucketClient), newBlocks[0])
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), newMeta.Stats.NumSeries)
+	assert.Equal(t, map[string]string{"key": "value"}, newMeta.Thanos.Labels)
+	assert.Contains(t, newMeta.Compaction.Sources, block1)
+	require.Len(t, newMeta.Thanos.Rewrites, 1)
+	assert.Len(t, newMeta.Thanos.Rewrites[0].DeletionsApplied, 1)
+
+	// Only the tombstone past the grace period should have been processed.
+	actual, err := cortex_tsdb.ReadTombstone(ctx, bucketClient, "user-1", tombstone1.RequestID, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, cortex_tsdb.TombstoneProcessed, actual.State)
+
+	actual, err = cortex_tsdb.ReadTombstone(ctx, bucketClient, "user-1", tombstone2.RequestID, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, cortex_tsdb.TombstonePending, actual.State)
+
+	assert.NoError(t, testutil.GatherAndCompare(registry.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_compactor_blocks_rewritten_by_series_deletion_total Total number of blocks rewritten to remove the series deleted by tombstones.
+		# TYPE cortex_compactor_blocks_rewritten_by_series_deletion_total counter
+		cortex_compactor_blocks_rewritten_by_series_deletion_total 1
+		# HELP cortex_compactor_tombstones_processed_total Total number of tombstones whose series have been removed from the blocks.
+		# TYPE cortex_compactor_tombstones_processed_total counter
+		cortex_compactor_tombstones_processed_total 1
+	`), "cortex_compactor_blocks_rewritten_by_series_deletion_total", "cortex_compactor_tombstones_processed_total"))
+}
@@ -0,0 +1,65 @@
+package compactor
+
+import (
+	"context"
+	"hash/fnv"
+
+	"github.com/oklog/ulid"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+// ShardAwareDeduplicateFilter is a block.DeduplicateFilter only deduplicating the blocks of
+// the same compactor shard. The blocks split by the split-and-merge compaction have the same
+// sources, while each one contains a different shard of the series, so they must not be
+// considered duplicates of each other nor of their source blocks.
+type ShardAwareDeduplicateFilter struct {
+	// The wrapped filter, which keeps track of the duplicate blocks garbage collected by the syncer.
+	*block.DeduplicateFilter
+}
+
+// NewShardAwareDeduplicateFilter creates a ShardAwareDeduplicateFilter.
+func NewShardAwareDeduplicateFilter() *ShardAwareDeduplicateFilter {
+	return &ShardAwareDeduplicateFilter{DeduplicateFilter: block.NewDeduplicateFilter()}
+}
+
+// Filter filters out the duplicate blocks, that is the blocks whose sources are included in
+// the sources of another block of the same compactor shard.
+func (f *ShardAwareDeduplicateFilter) Filter(ctx context.Context, metas map[ulid.ULID]*metadata.Meta, synced *extprom.TxGaugeVec) error {
+	// The blocks are deduplicated by their sources, so a source identifying the shard is added
+	// to the sources of each block, in order to never include the sources of a block into the
+	// sources of a block of another shard.
+	shardedMetas := make(map[ulid.ULID]*metadata.Meta, len(metas))
+	for id, meta := range metas {
+		sharded := *meta
+		sharded.Compaction.Sources = make([]ulid.ULID, 0, len(meta.Compaction.Sources)+1)
+		sharded.Compaction.Sources = append(sharded.Compaction.Sources, meta.Compaction.Sources...)
+		sharded.Compaction.Sources = append(sharded.Compaction.Sources, shardSource(meta.Thanos.Labels[cortex_tsdb.CompactorShardIDExternalLabel]))
+		shardedMetas[id] = &sharded
+	}
+
+	if err := f.DeduplicateFilter.Filter(ctx, shardedMetas, synced); err != nil {
+		return err
+	}
+
+	for id := range metas {
+		if _, ok := shardedMetas[id]; !ok {
+			delete(metas, id)
+		}
+	}
+
+	return nil
+}
+
+// shardSource returns the source identifying the compactor shard.
+func shardSource(shardID string) ulid.ULID {
+	hasher := fnv.New128a()
+	_, _ = hasher.Write([]byte(shardID))
+
+	var id ulid.ULID
+	copy(id[:], hasher.Sum(nil))
+	return id
+}
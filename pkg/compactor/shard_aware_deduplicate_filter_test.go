@@ -0,0 +1,109 @@
+package compactor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestShardAwareDeduplicateFilter(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	split1 := ulid.MustNew(3, nil)
+	split2 := ulid.MustNew(4, nil)
+	split1Copy := ulid.MustNew(5, nil)
+	merged1 := ulid.MustNew(6, nil)
+
+	newMeta := func(id ulid.ULID, shardID string, sources ...ulid.ULID) *metadata.Meta {
+		meta := &metadata.Meta{BlockMeta: tsdb.BlockMeta{ULID: id, Compaction: tsdb.BlockMetaCompaction{Sources: sources}}}
+		meta.Thanos.Labels = map[string]string{}
+		if shardID != "" {
+			meta.Thanos.Labels[cortex_tsdb.CompactorShardIDExternalLabel] = shardID
+		}
+		return meta
+	}
+
+	tests := map[string]struct {
+		input              map[ulid.ULID]*metadata.Meta
+		expectedDuplicates []ulid.ULID
+	}{
+		"should not deduplicate the split blocks, nor their source blocks": {
+			input: map[ulid.ULID]*metadata.Meta{
+				block1: newMeta(block1, "", block1),
+				block2: newMeta(block2, "", block2),
+				split1: newMeta(split1, "1_of_2", block1, block2),
+				split2: newMeta(split2, "2_of_2", block1, block2),
+			},
+		},
+		"should deduplicate the blocks of the same shard with the same sources": {
+			input: map[ulid.ULID]*metadata.Meta{
+				split1:     newMeta(split1, "1_of_2", block1, block2),
+				split2:     newMeta(split2, "2_of_2", block1, block2),
+				split1Copy: newMeta(split1Copy, "1_of_2", block1, block2),
+			},
+			expectedDuplicates: []ulid.ULID{split1Copy},
+		},
+		"should deduplicate the blocks of the same shard merged into another block": {
+			input: map[ulid.ULID]*metadata.Meta{
+				split1:  newMeta(split1, "1_of_2", block1),
+				split2:  newMeta(split2, "1_of_2", block2),
+				merged1: newMeta(merged1, "1_of_2", block1, block2),
+			},
+			expectedDuplicates: []ulid.ULID{split1, split2},
+		},
+		"should deduplicate the blocks not sharded": {
+			input: map[ulid.ULID]*metadata.Meta{
+				block1:  newMeta(block1, "", block1),
+				block2:  newMeta(block2, "", block2),
+				merged1: newMeta(merged1, "", block1, block2),
+			},
+			expectedDuplicates: []ulid.ULID{block1, block2},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			synced := extprom.NewTxGaugeVec(nil, prometheus.GaugeOpts{Name: "synced"}, []string{"state"})
+
+			f := NewShardAwareDeduplicateFilter()
+			metas := testData.input
+			inputIDs := make([]ulid.ULID, 0, len(metas))
+			for id := range metas {
+				inputIDs = append(inputIDs, id)
+			}
+
+			require.NoError(t, f.Filter(context.Background(), metas, synced))
+			assert.ElementsMatch(t, testData.expectedDuplicates, f.DuplicateIDs())
+
+			for _, id := range inputIDs {
+				_, ok := metas[id]
+				assert.Equal(t, !containsULID(testData.expectedDuplicates, id), ok, id.String())
+			}
+
+			// The sources of the input metas should be left untouched.
+			for _, meta := range metas {
+				for _, source := range meta.Compaction.Sources {
+					assert.Contains(t, []ulid.ULID{block1, block2}, source)
+				}
+			}
+		})
+	}
+}
+
+func containsULID(ids []ulid.ULID, id ulid.ULID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,368 @@
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+// compactUserWithSplitAndMerge compacts the blocks of a tenant using the split-and-merge
+// compaction strategy. When sharding is enabled, the tenant is compacted by all the
+// compactors of its shard, each one running the jobs it owns in the ring.
+func (c *Compactor) compactUserWithSplitAndMerge(ctx context.Context, userID string, userBucket objstore.Bucket, syncer *compact.Syncer, shardCount int, logger log.Logger) error {
+	if err := syncer.SyncMetas(ctx); err != nil {
+		return errors.Wrap(err, "sync")
+	}
+
+	if err := syncer.GarbageCollect(ctx); err != nil {
+		return errors.Wrap(err, "garbage collect")
+	}
+
+	jobs := planSplitAndMergeJobs(syncer.Metas(), c.compactorCfg.BlockRanges.ToMilliseconds(), shardCount)
+
+	// Filter out the jobs not owned by this compactor, and track the owned ones as pending.
+	owned := make([]*splitAndMergeJob, 0, len(jobs))
+	for _, j := range jobs {
+		if ok, err := c.ownJob(userID, j); err != nil {
+			level.Warn(logger).Log("msg", "unable to check if compaction job is owned by this shard", "job", j.key(), "err", err)
+			continue
+		} else if !ok {
+			level.Debug(logger).Log("msg", "skipping compaction job because it is not owned by this shard", "job", j.key())
+			continue
+		}
+
+		owned = append(owned, j)
+		c.compactionStatus.setGroupPlan(userID, j.key(), j.blocks)
+	}
+
+	for _, j := range owned {
+		// Ensure the context has not been canceled (ie. compactor shutdown has been triggered).
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		level.Info(logger).Log("msg", "starting compaction job", "job", j.key(), "blocks", len(j.blocks))
+
+		if err := c.runSplitAndMergeJob(ctx, userID, userBucket, j, shardCount, logger); err != nil {
+			c.splitAndMergeJobsFailed.WithLabelValues(j.stage).Inc()
+			return errors.Wrapf(err, "compaction job %s", j.key())
+		}
+
+		c.splitAndMergeJobsCompleted.WithLabelValues(j.stage).Inc()
+		c.compactionStatus.setGroupPlan(userID, j.key(), nil)
+		level.Info(logger).Log("msg", "compaction job done", "job", j.key())
+	}
+
+	return nil
+}
+
+// ownJob returns whether the compaction job of the tenant is owned by this compactor.
+func (c *Compactor) ownJob(userID string, j *splitAndMergeJob) (bool, error) {
+	// Always owned if sharding is disabled.
+	if !c.compactorCfg.ShardingEnabled {
+		return true, nil
+	}
+
+	hasher := fnv.New32a()
+	_, _ = hasher.Write([]byte(userID))
+	_, _ = hasher.Write([]byte(j.key()))
+
+	rs, err := c.userRing(userID).Get(hasher.Sum32(), ring.Compactor, []ring.IngesterDesc{})
+	if err != nil {
+		return false, err
+	}
+
+	if len(rs.Ingesters) != 1 {
+		return false, fmt.Errorf("unexpected number of compactors in the shard (expected 1, got %d)", len(rs.Ingesters))
+	}
+
+	return rs.Ingesters[0].Addr == c.ringLifecycler.Addr, nil
+}
+
+// runSplitAndMergeJob downloads the blocks of the job, compacts them and uploads
+// the compacted blocks. The source blocks are marked for deletion once all the
+// compacted blocks have been uploaded.
+func (c *Compactor) runSplitAndMergeJob(ctx context.Context, userID string, userBucket objstore.Bucket, j *splitAndMergeJob, shardCount int, logger log.Logger) error {
+	dir := filepath.Join(c.compactorCfg.DataDir, "split-and-merge", userID)
+
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrap(err, "failed to clean up the compaction directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(dir); err != nil {
+			level.Warn(logger).Log("msg", "failed to clean up the compaction directory", "dir", dir, "err", err)
+		}
+	}()
+
+	dirs := make([]string, 0, len(j.blocks))
+	for _, meta := range j.blocks {
+		blockDir := filepath.Join(dir, meta.ULID.String())
+		if err := block.Download(ctx, logger, userBucket, meta.ULID, blockDir); err != nil {
+			return errors.Wrapf(err, "download block %s", meta.ULID.String())
+		}
+
+		// Ensure the block is healthy, otherwise mark it for no compaction to skip it from the next compactions.
+		if indexErr, err := c.markBlockIfUnhealthyIndex(ctx, userBucket, meta, blockDir, logger); err != nil {
+			return err
+		} else if indexErr != nil {
+			return errors.Wrapf(indexErr, "block %s with not healthy index", meta.ULID.String())
+		}
+		dirs = append(dirs, blockDir)
+	}
+
+	var (
+		outDir = filepath.Join(dir, "out")
+		outIDs []ulid.ULID
+		err    error
+	)
+
+	if err := os.MkdirAll(outDir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "create the compaction output directory")
+	}
+
+	switch j.stage {
+	case splitJobStage:
+		outIDs, err = c.splitBlocks(dir, outDir, dirs, j, shardCount, logger)
+	case mergeJobStage:
+		outIDs, err = c.mergeBlocks(outDir, dirs, j, logger)
+	default:
+		err = fmt.Errorf("unknown compaction job stage %s", j.stage)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, id := range outIDs {
+		if err := block.Upload(ctx, logger, userBucket, filepath.Join(outDir, id.String())); err != nil {
+			return errors.Wrapf(err, "upload block %s", id.String())
+		}
+	}
+
+	for _, meta := range j.blocks {
+		if err := block.MarkForDeletion(ctx, logger, userBucket, meta.ULID, fmt.Sprintf("source of the %s compaction job", j.stage), c.blocksMarkedForDeletion); err != nil {
+			return errors.Wrapf(err, "mark block %s for deletion", meta.ULID.String())
+		}
+	}
+
+	return nil
+}
+
+// splitBlocks compacts the input blocks into a block for each shard, assigning each
+// series to a shard by its hash.
+func (c *Compactor) splitBlocks(dir, outDir string, dirs []string, j *splitAndMergeJob, shardCount int, logger log.Logger) ([]ulid.ULID, error) {
+	srcDir := dirs[0]
+
+	// Merge the input blocks first, so that they can be split from a single block.
+	if len(dirs) > 1 {
+		mergedID, err := c.tsdbCompactor.Compact(dir, dirs, nil)
+		if err != nil {
+			return nil, errors.Wrap(err, "merge blocks")
+		}
+
+		// An empty ULID means the input blocks are empty.
+		if mergedID == (ulid.ULID{}) {
+			return nil, nil
+		}
+		srcDir = filepath.Join(dir, mergedID.String())
+	}
+
+	src, err := tsdb.OpenBlock(logger, srcDir, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "open block")
+	}
+	defer src.Close()
+
+	shardsRefs, err := shardSeriesRefs(src, uint64(shardCount))
+	if err != nil {
+		return nil, errors.Wrap(err, "shard series")
+	}
+
+	var outIDs []ulid.ULID
+
+	for shardIndex := 0; shardIndex < shardCount; shardIndex++ {
+		shardID := formatShardID(shardIndex, shardCount)
+		reader := &shardedBlockReader{BlockReader: src, refs: shardsRefs[shardIndex]}
+
+		id, err := c.tsdbCompactor.Write(outDir, reader, j.minTime(), j.maxTime(), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "write block of shard %s", shardID)
+		}
+
+		// An empty ULID means no series belong to the shard.
+		if id == (ulid.ULID{}) {
+			continue
+		}
+
+		if err := injectSplitAndMergeMeta(logger, filepath.Join(outDir, id.String()), j, shardID); err != nil {
+			return nil, err
+		}
+
+		outIDs = append(outIDs, id)
+	}
+
+	return outIDs, nil
+}
+
+// mergeBlocks compacts the input blocks, belonging to the same shard, into a single block.
+func (c *Compactor) mergeBlocks(outDir string, dirs []string, j *splitAndMergeJob, logger log.Logger) ([]ulid.ULID, error) {
+	id, err := c.tsdbCompactor.Compact(outDir, dirs, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "merge blocks")
+	}
+
+	// An empty ULID means the input blocks are empty.
+	if id == (ulid.ULID{}) {
+		return nil, nil
+	}
+
+	if err := injectSplitAndMergeMeta(logger, filepath.Join(outDir, id.String()), j, j.shardID); err != nil {
+		return nil, err
+	}
+
+	return []ulid.ULID{id}, nil
+}
+
+// injectSplitAndMergeMeta sets the Thanos metadata of a block compacted by a job,
+// including the shard ID external label, and its compaction metadata.
+func injectSplitAndMergeMeta(logger log.Logger, blockDir string, j *splitAndMergeJob, shardID string) error {
+	lbls := map[string]string{}
+	for name, value := range j.blocks[0].Thanos.Labels {
+		lbls[name] = value
+	}
+	lbls[cortex_tsdb.CompactorShardIDExternalLabel] = shardID
+
+	meta, err := metadata.InjectThanos(logger, blockDir, metadata.Thanos{
+		Labels:     lbls,
+		Downsample: j.blocks[0].Thanos.Downsample,
+		Source:     metadata.CompactorSource,
+	}, nil)
+	if err != nil {
+		return errors.Wrap(err, "inject Thanos metadata")
+	}
+
+	// The blocks written by a split job are written from scratch, so their compaction
+	// metadata is set from the blocks of the job, like for the blocks merged together.
+	meta.Compaction = splitAndMergeCompactionMeta(meta.ULID, j)
+
+	return errors.Wrap(meta.WriteToDir(logger, blockDir), "write compaction metadata")
+}
+
+// splitAndMergeCompactionMeta returns the compaction metadata of a block compacted by the job,
+// whose sources are the sources of the blocks of the job and whose parents are the job blocks.
+func splitAndMergeCompactionMeta(id ulid.ULID, j *splitAndMergeJob) tsdb.BlockMetaCompaction {
+	compaction := tsdb.BlockMetaCompaction{}
+	seen := map[ulid.ULID]struct{}{}
+
+	for _, b := range j.blocks {
+		if b.Compaction.Level > compaction.Level {
+			compaction.Level = b.Compaction.Level
+		}
+
+		for _, source := range b.Compaction.Sources {
+			if _, ok := seen[source]; ok {
+				continue
+			}
+			seen[source] = struct{}{}
+			compaction.Sources = append(compaction.Sources, source)
+		}
+
+		compaction.Parents = append(compaction.Parents, tsdb.BlockDesc{ULID: b.ULID, MinTime: b.MinTime, MaxTime: b.MaxTime})
+	}
+
+	compaction.Level++
+	if len(compaction.Sources) == 0 {
+		compaction.Sources = []ulid.ULID{id}
+	}
+	sort.Slice(compaction.Sources, func(i, k int) bool {
+		return compaction.Sources[i].Compare(compaction.Sources[k]) < 0
+	})
+
+	return compaction
+}
+
+// shardSeriesRefs returns the sorted refs of the series of each shard, computing the shard
+// of each series of the block once.
+func shardSeriesRefs(b tsdb.BlockReader, shardCount uint64) ([][]uint64, error) {
+	idx, err := b.Index()
+	if err != nil {
+		return nil, err
+	}
+	defer idx.Close()
+
+	p, err := idx.Postings(index.AllPostingsKey())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		refs = make([][]uint64, shardCount)
+		lset labels.Labels
+		chks []chunks.Meta
+	)
+
+	for p.Next() {
+		if err := idx.Series(p.At(), &lset, &chks); err != nil {
+			return nil, err
+		}
+
+		shardIndex := lset.Hash() % shardCount
+		refs[shardIndex] = append(refs[shardIndex], p.At())
+	}
+
+	return refs, p.Err()
+}
+
+// shardedBlockReader is a tsdb.BlockReader exposing only the series of a shard.
+type shardedBlockReader struct {
+	tsdb.BlockReader
+
+	// The sorted refs of the series of the shard.
+	refs []uint64
+}
+
+// Index implements tsdb.BlockReader.
+func (r *shardedBlockReader) Index() (tsdb.IndexReader, error) {
+	idx, err := r.BlockReader.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	return &shardedIndexReader{IndexReader: idx, refs: r.refs}, nil
+}
+
+// shardedIndexReader is a tsdb.IndexReader whose postings only include the series of a shard.
+type shardedIndexReader struct {
+	tsdb.IndexReader
+
+	// The sorted refs of the series of the shard.
+	refs []uint64
+}
+
+// Postings implements tsdb.IndexReader.
+func (r *shardedIndexReader) Postings(name string, values ...string) (index.Postings, error) {
+	p, err := r.IndexReader.Postings(name, values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return index.Intersect(p, index.NewListPostings(r.refs)), nil
+}
@@ -0,0 +1,162 @@
+package compactor
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/compact/downsample"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestCompactor_ShouldSplitAndMergeBlocksOfTenantsWithSplitAndMergeShards(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	// Each block contains series_id="0" at min time and series_id="1" at max time.
+	block1 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 10, 20, map[string]string{"key": "value"})
+	block2 := createTSDBBlock(t, filepath.Join(storageDir, "user-1"), 100, 200, map[string]string{"key": "value"})
+
+	cfg := prepareConfig()
+	c, _, _, _, registry, cleanup := prepare(t, cfg, bucketClient)
+	defer cleanup()
+
+	// Use a real TSDB compactor to split the blocks.
+	c.tsdbCompactor, err = tsdb.NewLeveledCompactor(ctx, nil, log.NewNopLogger(), cfg.BlockRanges.ToMilliseconds(), downsample.NewPool())
+	require.NoError(t, err)
+	c.bucketClient = bucketClient
+	c.cfgProvider.(*mockConfigProvider).splitAndMergeShards = 2
+
+	userBucket := bucket.NewUserBucketClient("user-1", bucketClient)
+	listNewBlocks := func(excluded ...ulid.ULID) []*metadata.Meta {
+		var metas []*metadata.Meta
+		require.NoError(t, userBucket.Iter(ctx, "", func(name string) error {
+			id, err := ulid.Parse(strings.TrimSuffix(name, "/"))
+			if err != nil {
+				return nil
+			}
+			for _, e := range excluded {
+				if id == e {
+					return nil
+				}
+			}
+
+			meta, err := block.DownloadMeta(ctx, log.NewNopLogger(), userBucket, id)
+			require.NoError(t, err)
+			metas = append(metas, &meta)
+			return nil
+		}))
+		return metas
+	}
+
+	// The first run should split the blocks, as they're within the same range.
+	require.NoError(t, c.compactUser(ctx, "user-1"))
+
+	for _, id := range []ulid.ULID{block1, block2} {
+		exists, err := userBucket.Exists(ctx, filepath.Join(id.String(), metadata.DeletionMarkFilename))
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+
+	splitBlocks := listNewBlocks(block1, block2)
+	require.NotEmpty(t, splitBlocks)
+
+	// The split blocks should replace the source blocks.
+	expectedSources := []ulid.ULID{block1, block2}
+	if block2.Compare(block1) < 0 {
+		expectedSources = []ulid.ULID{block2, block1}
+	}
+
+	totalSeries := uint64(0)
+	for _, meta := range splitBlocks {
+		totalSeries += meta.Stats.NumSeries
+		assert.Equal(t, "value", meta.Thanos.Labels["key"])
+		assert.Contains(t, []string{"1_of_2", "2_of_2"}, meta.Thanos.Labels[cortex_tsdb.CompactorShardIDExternalLabel])
+		assert.Equal(t, 2, meta.Compaction.Level)
+		assert.Equal(t, expectedSources, meta.Compaction.Sources)
+		assert.ElementsMatch(t, []tsdb.BlockDesc{
+			{ULID: block1, MinTime: 10, MaxTime: 20},
+			{ULID: block2, MinTime: 100, MaxTime: 200},
+		}, meta.Compaction.Parents)
+	}
+	assert.Equal(t, uint64(2), totalSeries)
+
+	assert.NoError(t, testutil.GatherAndCompare(registry.(*prometheus.Registry), strings.NewReader(`
+		# HELP cortex_compactor_split_and_merge_jobs_completed_total Total number of split-and-merge compaction jobs successfully completed, by stage.
+		# TYPE cortex_compactor_split_and_merge_jobs_completed_total counter
+		cortex_compactor_split_and_merge_jobs_completed_total{stage="split"} 1
+	`), "cortex_compactor_split_and_merge_jobs_completed_total"))
+}
+
+func TestShardedBlockReader(t *testing.T) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	blockID := createTSDBBlock(t, storageDir, 10, 20, nil)
+
+	b, err := tsdb.OpenBlock(log.NewNopLogger(), filepath.Join(storageDir, blockID.String()), nil)
+	require.NoError(t, err)
+	defer b.Close() //nolint:errcheck
+
+	const shardCount = 3
+	seen := map[uint64]int{}
+
+	shardsRefs, err := shardSeriesRefs(b, shardCount)
+	require.NoError(t, err)
+	require.Len(t, shardsRefs, shardCount)
+
+	for shardIndex := uint64(0); shardIndex < shardCount; shardIndex++ {
+		reader := &shardedBlockReader{BlockReader: b, refs: shardsRefs[shardIndex]}
+
+		idx, err := reader.Index()
+		require.NoError(t, err)
+
+		k, v := index.AllPostingsKey()
+		p, err := idx.Postings(k, v)
+		require.NoError(t, err)
+
+		for p.Next() {
+			var (
+				lset labels.Labels
+				chks []chunks.Meta
+			)
+			require.NoError(t, idx.Series(p.At(), &lset, &chks))
+
+			// Each series must belong to the shard and be returned only once.
+			assert.Equal(t, shardIndex, lset.Hash()%shardCount)
+			seen[p.At()]++
+		}
+		require.NoError(t, p.Err())
+		require.NoError(t, idx.Close())
+	}
+
+	assert.Len(t, seen, 2)
+	for _, count := range seen {
+		assert.Equal(t, 1, count)
+	}
+}
@@ -0,0 +1,202 @@
+package compactor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/oklog/ulid"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+const (
+	splitJobStage = "split"
+	mergeJobStage = "merge"
+)
+
+// splitAndMergeJob is a compaction job of the split-and-merge compaction strategy.
+// A split job compacts the blocks not sharded yet into a block for each shard,
+// while a merge job compacts the blocks of the same shard into a single block.
+type splitAndMergeJob struct {
+	stage string
+
+	// The shard ID of the blocks compacted by a merge job. Empty for split jobs.
+	shardID string
+
+	// The time range of the job, aligned to the compaction block range.
+	rangeStart int64
+	rangeEnd   int64
+
+	blocks []*metadata.Meta
+}
+
+// key returns the key identifying the job, which doesn't depend on the blocks
+// of the job so that it's stable across compaction runs.
+func (j *splitAndMergeJob) key() string {
+	if j.stage == splitJobStage {
+		return fmt.Sprintf("%s-%d-%d", j.stage, j.rangeStart, j.rangeEnd)
+	}
+	return fmt.Sprintf("%s-%s-%d-%d", j.stage, j.shardID, j.rangeStart, j.rangeEnd)
+}
+
+// minTime returns the min time of the blocks of the job.
+func (j *splitAndMergeJob) minTime() int64 {
+	minTime := j.blocks[0].MinTime
+	for _, b := range j.blocks[1:] {
+		if b.MinTime < minTime {
+			minTime = b.MinTime
+		}
+	}
+	return minTime
+}
+
+// maxTime returns the max time of the blocks of the job.
+func (j *splitAndMergeJob) maxTime() int64 {
+	maxTime := j.blocks[0].MaxTime
+	for _, b := range j.blocks[1:] {
+		if b.MaxTime > maxTime {
+			maxTime = b.MaxTime
+		}
+	}
+	return maxTime
+}
+
+// formatShardID returns the shard ID of the shard at the input (0-based) index.
+func formatShardID(shardIndex, shardCount int) string {
+	return fmt.Sprintf("%d_of_%d", shardIndex+1, shardCount)
+}
+
+// planSplitAndMergeJobs plans the compaction jobs of a tenant using the
+// split-and-merge compaction strategy:
+//
+//   - The blocks not sharded yet are split into shardCount blocks, grouping the
+//     blocks by the smallest compaction range fully containing them.
+//   - The blocks of the same shard are merged, grouping the blocks by compaction
+//     range from the smallest to the largest one. The blocks are merged within a
+//     range larger than the smallest one only once the range is complete, that is
+//     once there are blocks with samples after the end of the range.
+//
+// The jobs are returned sorted by stage (split first) and time range.
+func planSplitAndMergeJobs(metas map[ulid.ULID]*metadata.Meta, ranges []int64, shardCount int) []*splitAndMergeJob {
+	if len(ranges) == 0 || shardCount <= 0 {
+		return nil
+	}
+
+	var (
+		jobs          []*splitAndMergeJob
+		splitJobs     = map[string]*splitAndMergeJob{}
+		blocksByShard = map[string][]*metadata.Meta{}
+		maxTime       int64
+	)
+
+	for _, meta := range metas {
+		if meta.MaxTime > maxTime {
+			maxTime = meta.MaxTime
+		}
+
+		if shardID := meta.Thanos.Labels[cortex_tsdb.CompactorShardIDExternalLabel]; shardID != "" {
+			blocksByShard[shardID] = append(blocksByShard[shardID], meta)
+			continue
+		}
+
+		rangeStart, rangeEnd := smallestRangeContaining(meta, ranges)
+		j := &splitAndMergeJob{stage: splitJobStage, rangeStart: rangeStart, rangeEnd: rangeEnd}
+		if existing, ok := splitJobs[j.key()]; ok {
+			j = existing
+		} else {
+			splitJobs[j.key()] = j
+			jobs = append(jobs, j)
+		}
+		j.blocks = append(j.blocks, meta)
+	}
+
+	for shardID, blocks := range blocksByShard {
+		merged := map[ulid.ULID]struct{}{}
+
+		for i, r := range ranges {
+			mergeJobs := map[int64]*splitAndMergeJob{}
+
+			for _, meta := range blocks {
+				if _, ok := merged[meta.ULID]; ok {
+					continue
+				}
+
+				// The block must fit in a single range.
+				rangeStart := meta.MinTime - mod(meta.MinTime, r)
+				if meta.MaxTime > rangeStart+r {
+					continue
+				}
+
+				j, ok := mergeJobs[rangeStart]
+				if !ok {
+					j = &splitAndMergeJob{stage: mergeJobStage, shardID: shardID, rangeStart: rangeStart, rangeEnd: rangeStart + r}
+					mergeJobs[rangeStart] = j
+				}
+				j.blocks = append(j.blocks, meta)
+			}
+
+			for _, j := range mergeJobs {
+				if len(j.blocks) < 2 {
+					continue
+				}
+
+				// Do not merge the blocks within a range larger than the smallest one
+				// until the range is complete, to avoid merging the same range again
+				// and again while new blocks are added to it.
+				if i > 0 && j.rangeEnd > maxTime {
+					continue
+				}
+
+				for _, meta := range j.blocks {
+					merged[meta.ULID] = struct{}{}
+				}
+				jobs = append(jobs, j)
+			}
+		}
+	}
+
+	for _, j := range jobs {
+		sort.Slice(j.blocks, func(a, b int) bool {
+			return j.blocks[a].MinTime < j.blocks[b].MinTime || (j.blocks[a].MinTime == j.blocks[b].MinTime && j.blocks[a].ULID.Compare(j.blocks[b].ULID) < 0)
+		})
+	}
+
+	sort.Slice(jobs, func(a, b int) bool {
+		if jobs[a].stage != jobs[b].stage {
+			return jobs[a].stage == splitJobStage
+		}
+		if jobs[a].rangeStart != jobs[b].rangeStart {
+			return jobs[a].rangeStart < jobs[b].rangeStart
+		}
+		if jobs[a].rangeEnd != jobs[b].rangeEnd {
+			return jobs[a].rangeEnd < jobs[b].rangeEnd
+		}
+		return jobs[a].shardID < jobs[b].shardID
+	})
+
+	return jobs
+}
+
+// smallestRangeContaining returns the time range, aligned to the smallest compaction
+// range, fully containing the block. If the block doesn't fit in any range, the
+// block time range is returned.
+func smallestRangeContaining(meta *metadata.Meta, ranges []int64) (int64, int64) {
+	for _, r := range ranges {
+		rangeStart := meta.MinTime - mod(meta.MinTime, r)
+		if meta.MaxTime <= rangeStart+r {
+			return rangeStart, rangeStart + r
+		}
+	}
+
+	return meta.MinTime, meta.MaxTime
+}
+
+// mod returns the non negative remainder of the division of t by r.
+func mod(t, r int64) int64 {
+	m := t % r
+	if m < 0 {
+		m += r
+	}
+	return m
+}
@@ -0,0 +1,109 @@
+package compactor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+func TestPlanSplitAndMergeJobs(t *testing.T) {
+	const (
+		h2  = int64(2 * time.Hour / time.Millisecond)
+		h12 = int64(12 * time.Hour / time.Millisecond)
+	)
+
+	ranges := []int64{h2, h12}
+
+	block1 := mockSplitAndMergeMeta(1, 0, h2, "")
+	block2 := mockSplitAndMergeMeta(2, 0, h2, "")
+	block3 := mockSplitAndMergeMeta(3, h2, 2*h2, "")
+	block4 := mockSplitAndMergeMeta(4, 0, h2, "1_of_2")
+	block5 := mockSplitAndMergeMeta(5, 0, h2, "1_of_2")
+	block6 := mockSplitAndMergeMeta(6, h2, 2*h2, "1_of_2")
+	block7 := mockSplitAndMergeMeta(7, 0, h2, "2_of_2")
+	block8 := mockSplitAndMergeMeta(8, h2, 2*h2, "2_of_2")
+	block9 := mockSplitAndMergeMeta(9, h12, h12+h2, "2_of_2")
+
+	tests := map[string]struct {
+		blocks   []*metadata.Meta
+		expected []*splitAndMergeJob
+	}{
+		"no blocks": {
+			expected: nil,
+		},
+		"should split the blocks not sharded yet, grouped by the smallest range": {
+			blocks: []*metadata.Meta{block1, block2, block3},
+			expected: []*splitAndMergeJob{
+				{stage: splitJobStage, rangeStart: 0, rangeEnd: h2, blocks: []*metadata.Meta{block1, block2}},
+				{stage: splitJobStage, rangeStart: h2, rangeEnd: 2 * h2, blocks: []*metadata.Meta{block3}},
+			},
+		},
+		"should merge the blocks of the same shard within the smallest range": {
+			blocks: []*metadata.Meta{block4, block5, block7},
+			expected: []*splitAndMergeJob{
+				{stage: mergeJobStage, shardID: "1_of_2", rangeStart: 0, rangeEnd: h2, blocks: []*metadata.Meta{block4, block5}},
+			},
+		},
+		"should not merge the blocks within a larger range until the range is complete": {
+			blocks:   []*metadata.Meta{block7, block8},
+			expected: nil,
+		},
+		"should merge the blocks within a larger range once the range is complete": {
+			blocks: []*metadata.Meta{block7, block8, block9},
+			expected: []*splitAndMergeJob{
+				{stage: mergeJobStage, shardID: "2_of_2", rangeStart: 0, rangeEnd: h12, blocks: []*metadata.Meta{block7, block8}},
+			},
+		},
+		"should not include the same block in multiple jobs": {
+			blocks: []*metadata.Meta{block1, block4, block5, block6, block7, block8, block9},
+			expected: []*splitAndMergeJob{
+				{stage: splitJobStage, rangeStart: 0, rangeEnd: h2, blocks: []*metadata.Meta{block1}},
+				{stage: mergeJobStage, shardID: "1_of_2", rangeStart: 0, rangeEnd: h2, blocks: []*metadata.Meta{block4, block5}},
+				{stage: mergeJobStage, shardID: "2_of_2", rangeStart: 0, rangeEnd: h12, blocks: []*metadata.Meta{block7, block8}},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			metas := map[ulid.ULID]*metadata.Meta{}
+			for _, b := range testData.blocks {
+				metas[b.ULID] = b
+			}
+
+			assert.Equal(t, testData.expected, planSplitAndMergeJobs(metas, ranges, 2))
+		})
+	}
+}
+
+func TestSplitAndMergeJob_Key(t *testing.T) {
+	split := &splitAndMergeJob{stage: splitJobStage, rangeStart: 10, rangeEnd: 20}
+	merge := &splitAndMergeJob{stage: mergeJobStage, shardID: "1_of_4", rangeStart: 10, rangeEnd: 20}
+
+	assert.Equal(t, "split-10-20", split.key())
+	assert.Equal(t, "merge-1_of_4-10-20", merge.key())
+}
+
+func mockSplitAndMergeMeta(id uint64, minTime, maxTime int64, shardID string) *metadata.Meta {
+	lbls := map[string]string{}
+	if shardID != "" {
+		lbls[cortex_tsdb.CompactorShardIDExternalLabel] = shardID
+	}
+
+	return &metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:    ulid.MustNew(id, nil),
+			MinTime: minTime,
+			MaxTime: maxTime,
+		},
+		Thanos: metadata.Thanos{
+			Labels: lbls,
+		},
+	}
+}
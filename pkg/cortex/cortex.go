@@ -13,7 +13,6 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/prometheus/promql"
 	prom_storage "github.com/prometheus/prometheus/storage"
 	"github.com/weaveworks/common/server"
 	"github.com/weaveworks/common/signals"
@@ -37,8 +36,10 @@ import (
 	frontendv1 "github.com/cortexproject/cortex/pkg/frontend/v1"
 	"github.com/cortexproject/cortex/pkg/ingester"
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/limitsapi"
 	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/querier/tenantfederation"
 	querier_worker "github.com/cortexproject/cortex/pkg/querier/worker"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
@@ -47,6 +48,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/scheduler"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storegateway"
+	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/fakeauth"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -55,6 +57,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/process"
 	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/tlsauth"
 	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
@@ -110,6 +113,11 @@ type Config struct {
 	RuntimeConfig  runtimeconfig.ManagerConfig                `yaml:"runtime_config"`
 	MemberlistKV   memberlist.KVConfig                        `yaml:"memberlist"`
 	QueryScheduler scheduler.Config                           `yaml:"query_scheduler"`
+
+	TenantFederation tenantfederation.Config `yaml:"tenant_federation"`
+	TLSAuth          tlsauth.Config          `yaml:"tls_auth"`
+	TenantValidation tenant.ValidationConfig `yaml:"tenant_validation"`
+	LimitsAPI        limitsapi.Config        `yaml:"limits_api"`
 }
 
 // RegisterFlags registers flag.
@@ -156,11 +164,27 @@ func (c *Config) RegisterFlags(f *flag.FlagSet) {
 	c.RuntimeConfig.RegisterFlags(f)
 	c.MemberlistKV.RegisterFlags(f, "")
 	c.QueryScheduler.RegisterFlags(f)
+	c.TenantFederation.RegisterFlags(f)
+	c.TLSAuth.RegisterFlags(f)
+	c.TenantValidation.RegisterFlags(f)
+	c.LimitsAPI.RegisterFlags(f)
 
 	// These don't seem to have a home.
 	f.IntVar(&chunk_util.QueryParallelism, "querier.query-parallelism", 100, "Max subqueries run in parallel per higher-level query.")
 }
 
+// newDefaultConfig returns the Cortex config with the default values.
+func newDefaultConfig() *Config {
+	// Registering the flags sets the global variables bound to them to their
+	// default value too, so they're restored.
+	queryParallelism := chunk_util.QueryParallelism
+	defer func() { chunk_util.QueryParallelism = queryParallelism }()
+
+	defaultConfig := &Config{}
+	defaultConfig.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	return defaultConfig
+}
+
 // Validate the cortex config and returns an error if the validation
 // doesn't pass
 func (c *Config) Validate(log log.Logger) error {
@@ -183,6 +207,9 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.Ruler.Validate(c.LimitsConfig, log); err != nil {
 		return errors.Wrap(err, "invalid ruler config")
 	}
+	if c.Ruler.TenantFederation.Enabled && !c.TenantFederation.Enabled {
+		return errors.New("invalid ruler config: the ruler tenant federation requires the tenant federation to be enabled (-tenant-federation.enabled)")
+	}
 	if err := c.BlocksStorage.Validate(); err != nil {
 		return errors.Wrap(err, "invalid TSDB config")
 	}
@@ -195,12 +222,24 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.Querier.Validate(); err != nil {
 		return errors.Wrap(err, "invalid querier config")
 	}
+	if err := c.LimitsConfig.ValidateQueryTimeRanges(c.Querier.QueryIngestersWithin, c.Querier.QueryStoreAfter, c.Querier.ShuffleShardingIngestersLookbackPeriod); err != nil {
+		return errors.Wrap(err, "invalid limits config")
+	}
+	if err := c.LimitsConfig.ValidateRulerEvaluationJitter(c.Ruler.EvaluationInterval); err != nil {
+		return errors.Wrap(err, "invalid limits config")
+	}
 	if err := c.IngesterClient.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid ingester_client config")
 	}
 	if err := c.Worker.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid frontend_worker config")
 	}
+	if err := c.QueryScheduler.Validate(); err != nil {
+		return errors.Wrap(err, "invalid query-scheduler config")
+	}
+	if err := c.Frontend.Validate(); err != nil {
+		return errors.Wrap(err, "invalid query-frontend config")
+	}
 	if err := c.QueryRange.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid query_range config")
 	}
@@ -210,12 +249,27 @@ func (c *Config) Validate(log log.Logger) error {
 	if err := c.StoreGateway.Validate(c.LimitsConfig); err != nil {
 		return errors.Wrap(err, "invalid store-gateway config")
 	}
-	if err := c.Compactor.Validate(); err != nil {
+	if err := c.Compactor.Validate(c.LimitsConfig); err != nil {
 		return errors.Wrap(err, "invalid compactor config")
 	}
 	if err := c.Alertmanager.Validate(); err != nil {
 		return errors.Wrap(err, "invalid alertmanager config")
 	}
+	if err := c.TLSAuth.Validate(); err != nil {
+		return errors.Wrap(err, "invalid TLS auth config")
+	}
+	if c.TLSAuth.Enabled && !c.AuthEnabled {
+		return errors.New("invalid TLS auth config: the TLS auth requires the auth to be enabled (-auth.enabled)")
+	}
+	if err := c.TenantValidation.Validate(); err != nil {
+		return errors.Wrap(err, "invalid tenant validation config")
+	}
+	if c.TenantValidation.Enabled && !c.AuthEnabled {
+		return errors.New("invalid tenant validation config: the tenant validation requires the auth to be enabled (-auth.enabled)")
+	}
+	if err := c.LimitsAPI.Validate(); err != nil {
+		return errors.Wrap(err, "invalid limits API config")
+	}
 
 	if c.Storage.Engine == storage.StorageEngineBlocks && c.Querier.SecondStoreEngine != storage.StorageEngineChunks && len(c.Schema.Configs) > 0 {
 		level.Warn(log).Log("schema configuration is not used by the blocks storage engine, and will have no effect")
@@ -278,7 +332,7 @@ type Cortex struct {
 	Purger                   *purger.Purger
 	TombstonesLoader         *purger.TombstonesLoader
 	QuerierQueryable         prom_storage.SampleAndChunkQueryable
-	QuerierEngine            *promql.Engine
+	QuerierEngines           *querier.Engines
 	QueryFrontendTripperware queryrange.Tripperware
 
 	Ruler        *ruler.Ruler
@@ -290,6 +344,9 @@ type Cortex struct {
 	StoreGateway *storegateway.StoreGateway
 	MemberlistKV *memberlist.KVInitService
 
+	TLSAuthenticator *tlsauth.Authenticator
+	LimitsStore      *limitsapi.Store
+
 	// Queryables that the querier should use to query the long
 	// term storage. It depends on the storage engine used.
 	StoreQueryables []querier.QueryableWithFilter
@@ -316,8 +373,30 @@ func New(cfg Config) (*Cortex, error) {
 			"/schedulerpb.SchedulerForQuerier/QuerierLoop",
 		})
 
+	// The tenant ID of the HTTP requests is derived from the client TLS certificate, if enabled.
+	// The gRPC requests are sent by the Cortex components, which propagate the tenant ID.
+	var tlsAuthenticator *tlsauth.Authenticator
+	if cfg.TLSAuth.Enabled {
+		var err error
+		if tlsAuthenticator, err = tlsauth.NewAuthenticator(cfg.TLSAuth, util.Logger, prometheus.DefaultRegisterer); err != nil {
+			return nil, err
+		}
+		cfg.API.HTTPAuthMiddleware = tlsAuthenticator
+	}
+
+	if cfg.TenantValidation.Enabled {
+		cfg.API.HTTPAuthMiddleware = tenant.NewValidationMiddleware(cfg.TenantValidation, cfg.API.HTTPAuthMiddleware)
+	}
+
+	// Swap out the default resolver to support multiple tenant IDs separated by a '|'.
+	if cfg.TenantFederation.Enabled {
+		util.WarnExperimentalUse("tenant-federation")
+		tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	}
+
 	cortex := &Cortex{
-		Cfg: cfg,
+		Cfg:              cfg,
+		TLSAuthenticator: tlsAuthenticator,
 	}
 
 	cortex.setupThanosTracing()
@@ -357,7 +436,7 @@ func (t *Cortex) Run() error {
 		return err
 	}
 
-	t.API.RegisterServiceMapHandler(http.HandlerFunc(t.servicesHandler))
+	t.API.RegisterServiceMapHandler(http.HandlerFunc(t.servicesHandler), t.modulesStatus)
 
 	// get all services, create service manager and tell it to start
 	servs := []services.Service(nil)
@@ -8,6 +8,7 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/chunk/aws"
 	"github.com/cortexproject/cortex/pkg/chunk/storage"
+	chunk_util "github.com/cortexproject/cortex/pkg/chunk/util"
 	"github.com/cortexproject/cortex/pkg/ingester"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
@@ -94,3 +95,14 @@ func TestCortex(t *testing.T) {
 	// check that compactor is configured which is not part of Target=All
 	require.NotNil(t, serviceMap[Compactor])
 }
+
+func TestNewDefaultConfig(t *testing.T) {
+	// The global variables bound to the flags must not be reset.
+	chunk_util.QueryParallelism = 5
+	defer func() { chunk_util.QueryParallelism = 100 }()
+
+	cfg := newDefaultConfig()
+	require.Equal(t, 5, chunk_util.QueryParallelism)
+	require.True(t, cfg.AuthEnabled)
+	require.Equal(t, "/api/prom", cfg.HTTPPrefix)
+}
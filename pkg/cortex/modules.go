@@ -28,14 +28,17 @@ import (
 	frontend "github.com/cortexproject/cortex/pkg/frontend"
 	"github.com/cortexproject/cortex/pkg/frontend/transport"
 	"github.com/cortexproject/cortex/pkg/ingester"
+	"github.com/cortexproject/cortex/pkg/limitsapi"
 	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/querier/queryrange"
+	"github.com/cortexproject/cortex/pkg/querier/tenantfederation"
 	querier_worker "github.com/cortexproject/cortex/pkg/querier/worker"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/cortexproject/cortex/pkg/scheduler"
+	"github.com/cortexproject/cortex/pkg/scheduler/schedulerdiscovery"
 	"github.com/cortexproject/cortex/pkg/storegateway"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
@@ -76,6 +79,11 @@ const (
 	BlocksPurger             string = "blocks-purger"
 	Purger                   string = "purger"
 	QueryScheduler           string = "query-scheduler"
+	TLSAuth                  string = "tls-auth"
+	LimitsStore              string = "limits-store"
+	LimitsAPI                string = "limits-api"
+	OverridesExporter        string = "overrides-exporter"
+	TenantFederation         string = "tenant-federation"
 	All                      string = "all"
 )
 
@@ -90,11 +98,20 @@ func (t *Cortex) initAPI() (services.Service, error) {
 
 	t.API = a
 
-	t.API.RegisterAPI(t.Cfg.Server.PathPrefix, t.Cfg)
+	t.API.RegisterAPI(t.Cfg.Server.PathPrefix, t.Cfg, newDefaultConfig())
 
 	return nil, nil
 }
 
+func (t *Cortex) initTLSAuth() (services.Service, error) {
+	// The authenticator is created with the Cortex config, because the auth middleware
+	// is set up before the modules. This module runs its mapping file reloading.
+	if t.TLSAuthenticator == nil {
+		return nil, nil
+	}
+	return t.TLSAuthenticator, nil
+}
+
 func (t *Cortex) initServer() (services.Service, error) {
 	// Cortex handles signals on its own.
 	DisableSignalHandling(&t.Cfg.Server)
@@ -157,25 +174,85 @@ func (t *Cortex) initRuntimeConfig() (services.Service, error) {
 		// no need to initialize module if load path is empty
 		return nil, nil
 	}
-	t.Cfg.RuntimeConfig.Loader = loadRuntimeConfig
+	t.Cfg.RuntimeConfig.Loader = runtimeConfigLoader(t.Cfg.Querier, t.Cfg.Ruler)
 
 	// make sure to set default limits before we start loading configuration into memory
 	validation.SetDefaultLimitsForYAMLUnmarshalling(t.Cfg.LimitsConfig)
 
 	serv, err := runtimeconfig.NewRuntimeConfigManager(t.Cfg.RuntimeConfig, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
 	t.RuntimeConfig = serv
-	return serv, err
+	t.API.RegisterRuntimeConfig(serv)
+	return serv, nil
+}
+
+func (t *Cortex) initLimitsStore() (services.Service, error) {
+	if !t.Cfg.LimitsAPI.Enabled {
+		return nil, nil
+	}
+
+	// make sure to set default limits before we start loading the limits into memory
+	validation.SetDefaultLimitsForYAMLUnmarshalling(t.Cfg.LimitsConfig)
+
+	store, err := limitsapi.NewStore(t.Cfg.LimitsAPI, util.Logger, prometheus.DefaultRegisterer)
+	if err != nil {
+		return nil, err
+	}
+
+	t.LimitsStore = store
+	return store, nil
 }
 
 func (t *Cortex) initOverrides() (serv services.Service, err error) {
-	t.Overrides, err = validation.NewOverrides(t.Cfg.LimitsConfig, tenantLimitsFromRuntimeConfig(t.RuntimeConfig))
+	tenantLimits := tenantLimitsFromRuntimeConfig(t.RuntimeConfig)
+	if t.LimitsStore != nil {
+		// The limits set via the API are applied on top of the runtime config.
+		tenantLimits = t.LimitsStore.TenantLimits(tenantLimits)
+	}
+
+	t.Overrides, err = validation.NewOverrides(t.Cfg.LimitsConfig, tenantLimits)
+	if err != nil {
+		return nil, err
+	}
+
+	t.API.RegisterTenantLimits(t.Overrides, t.Cfg.LimitsConfig)
+
 	// overrides don't have operational state, nor do they need to do anything more in starting/stopping phase,
 	// so there is no need to return any service.
-	return nil, err
+	return nil, nil
+}
+
+func (t *Cortex) initLimitsAPI() (services.Service, error) {
+	if t.LimitsStore == nil {
+		return nil, nil
+	}
+
+	t.API.RegisterLimitsAPI(limitsapi.NewAPI(t.Cfg.LimitsAPI, t.LimitsStore, t.Overrides, t.Cfg.Distributor.ShardByAllLabels, util.Logger))
+	return nil, nil
+}
+
+func (t *Cortex) initOverridesExporter() (services.Service, error) {
+	tenantLimits := allTenantLimitsFromRuntimeConfig(t.RuntimeConfig)
+	if t.LimitsStore != nil {
+		// The limits set via the API are applied on top of the runtime config.
+		tenantLimits = t.LimitsStore.AllTenantLimits(tenantLimits)
+	}
+
+	exporter := validation.NewOverridesExporter(&t.Cfg.LimitsConfig, tenantLimits)
+	prometheus.MustRegister(exporter)
+
+	// the exporter doesn't need to do anything in starting/stopping phase,
+	// so there is no need to return any service.
+	return nil, nil
 }
 
 func (t *Cortex) initDistributorService() (serv services.Service, err error) {
 	t.Cfg.Distributor.DistributorRing.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Distributor.DistributorRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
+	t.Cfg.Distributor.HATrackerConfig.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
 	t.Cfg.Distributor.ShuffleShardingLookbackPeriod = t.Cfg.Querier.ShuffleShardingIngestersLookbackPeriod
 
 	// Check whether the distributor can join the distributors ring, which is
@@ -192,18 +269,18 @@ func (t *Cortex) initDistributorService() (serv services.Service, err error) {
 }
 
 func (t *Cortex) initDistributor() (serv services.Service, err error) {
-	t.API.RegisterDistributor(t.Distributor, t.Cfg.Distributor)
+	t.API.RegisterDistributor(t.Distributor, t.Cfg.Distributor, t.Overrides)
 
 	return nil, nil
 }
 
-// initQueryable instantiates the queryable and promQL engine used to service queries to
+// initQueryable instantiates the queryable and promQL engines used to service queries to
 // Cortex. It also registers the API endpoints associated with those two services.
 func (t *Cortex) initQueryable() (serv services.Service, err error) {
 	querierRegisterer := prometheus.WrapRegistererWith(prometheus.Labels{"engine": "querier"}, prometheus.DefaultRegisterer)
 
-	// Create a querier queryable and PromQL engine
-	t.QuerierQueryable, t.QuerierEngine = querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, t.TombstonesLoader, querierRegisterer)
+	// Create a querier queryable and PromQL engines
+	t.QuerierQueryable, t.QuerierEngines = querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, t.TombstonesLoader, querierRegisterer)
 
 	// Register the default endpoints that are always enabled for the querier module
 	t.API.RegisterQueryable(t.QuerierQueryable, t.Distributor)
@@ -211,6 +288,15 @@ func (t *Cortex) initQueryable() (serv services.Service, err error) {
 	return nil, nil
 }
 
+// initTenantFederation wraps the querier queryable, in order to federate the queries
+// spanning multiple tenants, if the tenant federation is enabled.
+func (t *Cortex) initTenantFederation() (serv services.Service, err error) {
+	if t.Cfg.TenantFederation.Enabled {
+		t.QuerierQueryable = querier.NewSampleAndChunkQueryable(tenantfederation.NewQueryable(t.QuerierQueryable, t.Cfg.TenantFederation.MaxTenantsPerQuery))
+	}
+	return nil, nil
+}
+
 // initQuerier registers an internal HTTP router with a Prometheus API backed by the
 // Cortex Queryable. Then it does one of the following:
 //
@@ -266,10 +352,12 @@ func (t *Cortex) initQuerier() (serv services.Service, err error) {
 	// to a Prometheus API struct instantiated with the Cortex Queryable.
 	internalQuerierRouter := api.NewQuerierHandler(
 		t.Cfg.API,
+		t.Cfg.Querier,
 		t.QuerierQueryable,
-		t.QuerierEngine,
+		t.QuerierEngines,
 		t.Distributor,
 		t.TombstonesLoader,
+		t.Overrides,
 		prometheus.DefaultRegisterer,
 		util.Logger,
 	)
@@ -300,8 +388,10 @@ func (t *Cortex) initQuerier() (serv services.Service, err error) {
 		internalQuerierRouter = t.API.AuthMiddleware.Wrap(internalQuerierRouter)
 	}
 
-	// If neither frontend address or scheduler address is configured, no worker is needed.
-	if t.Cfg.Worker.FrontendAddress == "" && t.Cfg.Worker.SchedulerAddress == "" {
+	// If neither frontend address or scheduler address is configured, and query-schedulers
+	// are not discovered via the ring, no worker is needed.
+	t.Cfg.Worker.QuerySchedulerDiscovery = t.Cfg.QueryScheduler.ServiceDiscovery
+	if t.Cfg.Worker.FrontendAddress == "" && t.Cfg.Worker.SchedulerAddress == "" && t.Cfg.Worker.QuerySchedulerDiscovery.Mode != schedulerdiscovery.ModeRing {
 		return nil, nil
 	}
 
@@ -439,6 +529,12 @@ func (t *Cortex) initChunkStore() (serv services.Service, err error) {
 }
 
 func (t *Cortex) initDeleteRequestsStore() (serv services.Service, err error) {
+	if t.Cfg.Storage.Engine == storage.StorageEngineBlocks && t.Cfg.PurgerConfig.Enable {
+		// With the blocks storage, delete requests are stored as tombstones in the bucket.
+		t.TombstonesLoader, err = purger.NewBlocksTombstonesLoader(t.Cfg.BlocksStorage, util.Logger, prometheus.DefaultRegisterer)
+		return
+	}
+
 	if t.Cfg.Storage.Engine != storage.StorageEngineChunks || !t.Cfg.PurgerConfig.Enable {
 		// until we need to explicitly enable delete series support we need to do create TombstonesLoader without DeleteStore which acts as noop
 		t.TombstonesLoader = purger.NewTombstonesLoader(nil, nil)
@@ -512,6 +608,8 @@ func (t *Cortex) initQueryFrontendTripperware() (serv services.Service, err erro
 }
 
 func (t *Cortex) initQueryFrontend() (serv services.Service, err error) {
+	t.Cfg.Frontend.FrontendV2.QuerySchedulerDiscovery = t.Cfg.QueryScheduler.ServiceDiscovery
+
 	roundTripper, frontendV1, frontendV2, err := frontend.InitFrontend(t.Cfg.Frontend, t.Overrides, t.Cfg.Server.GRPCListenPort, util.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
@@ -520,7 +618,7 @@ func (t *Cortex) initQueryFrontend() (serv services.Service, err error) {
 	// Wrap roundtripper into Tripperware.
 	roundTripper = t.QueryFrontendTripperware(roundTripper)
 
-	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util.Logger)
+	handler := transport.NewHandler(t.Cfg.Frontend.Handler, roundTripper, util.Logger, prometheus.DefaultRegisterer)
 	if t.Cfg.Frontend.CompressResponses {
 		handler = gziphandler.GzipHandler(handler)
 	}
@@ -609,7 +707,7 @@ func (t *Cortex) initRulerStorage() (serv services.Service, err error) {
 		return
 	}
 
-	t.RulerStorage, err = ruler.NewRuleStorage(t.Cfg.Ruler.StoreConfig, rules.FileLoader{})
+	t.RulerStorage, err = ruler.NewRuleStorage(t.Cfg.Ruler.StoreConfig, rules.FileLoader{}, util.Logger, prometheus.DefaultRegisterer)
 	return
 }
 
@@ -620,10 +718,27 @@ func (t *Cortex) initRuler() (serv services.Service, err error) {
 	}
 
 	t.Cfg.Ruler.Ring.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Ruler.Ring.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
 	rulerRegisterer := prometheus.WrapRegistererWith(prometheus.Labels{"engine": "ruler"}, prometheus.DefaultRegisterer)
-	queryable, engine := querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, t.TombstonesLoader, rulerRegisterer)
+	queryable, engines := querier.New(t.Cfg.Querier, t.Overrides, t.Distributor, t.StoreQueryables, t.TombstonesLoader, rulerRegisterer)
 
-	managerFactory := ruler.DefaultTenantManagerFactory(t.Cfg.Ruler, t.Distributor, queryable, engine, t.Overrides)
+	// Federate the queries of the rule groups having source tenants.
+	if t.Cfg.Ruler.TenantFederation.Enabled {
+		queryable = querier.NewSampleAndChunkQueryable(tenantfederation.NewQueryable(queryable, t.Cfg.TenantFederation.MaxTenantsPerQuery))
+	}
+
+	managerFactory := ruler.DefaultTenantManagerFactory(t.Cfg.Ruler, t.Distributor, queryable, engines, t.Overrides)
+
+	// Evaluate the rule queries remotely via the query-frontend, if configured.
+	if t.Cfg.Ruler.QueryFrontend.Address != "" {
+		frontendClient, err := ruler.DialQueryFrontend(t.Cfg.Ruler.QueryFrontend)
+		if err != nil {
+			return nil, err
+		}
+
+		remoteQuerier := ruler.NewRemoteQuerier(frontendClient, t.Cfg.Ruler.QueryFrontend, t.Cfg.API.PrometheusHTTPPrefix, util.Logger, prometheus.DefaultRegisterer)
+		managerFactory = ruler.TenantManagerFactory(t.Cfg.Ruler, t.Distributor, queryable, remoteQuerier.Query, t.Overrides)
+	}
 	manager, err := ruler.NewDefaultMultiTenantManager(t.Cfg.Ruler, managerFactory, prometheus.DefaultRegisterer, util.Logger)
 	if err != nil {
 		return nil, err
@@ -667,7 +782,10 @@ func (t *Cortex) initConfig() (serv services.Service, err error) {
 }
 
 func (t *Cortex) initAlertManager() (serv services.Service, err error) {
-	t.Alertmanager, err = alertmanager.NewMultitenantAlertmanager(&t.Cfg.Alertmanager, util.Logger, prometheus.DefaultRegisterer)
+	t.Cfg.Alertmanager.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Alertmanager.ShardingRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
+
+	t.Alertmanager, err = alertmanager.NewMultitenantAlertmanager(&t.Cfg.Alertmanager, t.Overrides, util.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return
 	}
@@ -678,8 +796,10 @@ func (t *Cortex) initAlertManager() (serv services.Service, err error) {
 
 func (t *Cortex) initCompactor() (serv services.Service, err error) {
 	t.Cfg.Compactor.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.Compactor.ShardingRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
+	t.Cfg.Compactor.SeriesDeletionGracePeriod = t.Cfg.PurgerConfig.DeleteRequestCancelPeriod
 
-	t.Compactor, err = compactor.NewCompactor(t.Cfg.Compactor, t.Cfg.BlocksStorage, util.Logger, prometheus.DefaultRegisterer)
+	t.Compactor, err = compactor.NewCompactor(t.Cfg.Compactor, t.Cfg.BlocksStorage, t.Overrides, util.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return
 	}
@@ -698,6 +818,7 @@ func (t *Cortex) initStoreGateway() (serv services.Service, err error) {
 	}
 
 	t.Cfg.StoreGateway.ShardingRing.ListenPort = t.Cfg.Server.GRPCListenPort
+	t.Cfg.StoreGateway.ShardingRing.KVStore.Multi.ConfigProvider = multiClientRuntimeConfigChannel(t.RuntimeConfig)
 
 	t.StoreGateway, err = storegateway.NewStoreGateway(t.Cfg.StoreGateway, t.Cfg.BlocksStorage, t.Overrides, t.Cfg.Server.LogLevel, util.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
@@ -714,15 +835,20 @@ func (t *Cortex) initMemberlistKV() (services.Service, error) {
 	t.Cfg.MemberlistKV.MetricsRegisterer = prometheus.DefaultRegisterer
 	t.Cfg.MemberlistKV.Codecs = []codec.Codec{
 		ring.GetCodec(),
+		distributor.GetReplicaDescCodec(),
 	}
 	t.MemberlistKV = memberlist.NewKVInitService(&t.Cfg.MemberlistKV, util.Logger)
 
 	// Update the config.
 	t.Cfg.Distributor.DistributorRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+	t.Cfg.Distributor.HATrackerConfig.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.Ingester.LifecyclerConfig.RingConfig.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.StoreGateway.ShardingRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.Compactor.ShardingRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
 	t.Cfg.Ruler.Ring.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+	t.Cfg.Alertmanager.ShardingRing.KVStore.MemberlistKV = t.MemberlistKV.GetMemberlistKV
+
+	t.API.RegisterMemberlistKV(t.MemberlistKV)
 
 	return t.MemberlistKV, nil
 }
@@ -752,16 +878,18 @@ func (t *Cortex) initBlocksPurger() (services.Service, error) {
 		return nil, nil
 	}
 
-	purgerAPI, err := purger.NewBlocksPurgerAPI(t.Cfg.BlocksStorage, util.Logger, prometheus.DefaultRegisterer)
+	purgerAPI, err := purger.NewBlocksPurgerAPI(t.Cfg.BlocksStorage, t.Cfg.PurgerConfig.DeleteRequestCancelPeriod, util.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, err
 	}
 
-	t.API.RegisterBlocksPurger(purgerAPI)
+	t.API.RegisterBlocksPurger(purgerAPI, t.Cfg.PurgerConfig.Enable)
 	return nil, nil
 }
 
 func (t *Cortex) initQueryScheduler() (services.Service, error) {
+	t.Cfg.QueryScheduler.ServiceDiscovery.SchedulerRing.ListenPort = t.Cfg.Server.GRPCListenPort
+
 	s, err := scheduler.NewScheduler(t.Cfg.QueryScheduler, t.Overrides, util.Logger, prometheus.DefaultRegisterer)
 	if err != nil {
 		return nil, errors.Wrap(err, "query-scheduler init")
@@ -778,10 +906,14 @@ func (t *Cortex) setupModuleManager() error {
 	// RegisterModule(name string, initFn func()(services.Service, error))
 	mm.RegisterModule(Server, t.initServer, modules.UserInvisibleModule)
 	mm.RegisterModule(API, t.initAPI, modules.UserInvisibleModule)
+	mm.RegisterModule(TLSAuth, t.initTLSAuth, modules.UserInvisibleModule)
 	mm.RegisterModule(RuntimeConfig, t.initRuntimeConfig, modules.UserInvisibleModule)
 	mm.RegisterModule(MemberlistKV, t.initMemberlistKV, modules.UserInvisibleModule)
 	mm.RegisterModule(Ring, t.initRing, modules.UserInvisibleModule)
+	mm.RegisterModule(LimitsStore, t.initLimitsStore, modules.UserInvisibleModule)
 	mm.RegisterModule(Overrides, t.initOverrides, modules.UserInvisibleModule)
+	mm.RegisterModule(LimitsAPI, t.initLimitsAPI)
+	mm.RegisterModule(OverridesExporter, t.initOverridesExporter)
 	mm.RegisterModule(Distributor, t.initDistributor)
 	mm.RegisterModule(DistributorService, t.initDistributorService, modules.UserInvisibleModule)
 	mm.RegisterModule(Store, t.initChunkStore, modules.UserInvisibleModule)
@@ -790,6 +922,7 @@ func (t *Cortex) setupModuleManager() error {
 	mm.RegisterModule(IngesterService, t.initIngesterService, modules.UserInvisibleModule)
 	mm.RegisterModule(Flusher, t.initFlusher)
 	mm.RegisterModule(Queryable, t.initQueryable, modules.UserInvisibleModule)
+	mm.RegisterModule(TenantFederation, t.initTenantFederation, modules.UserInvisibleModule)
 	mm.RegisterModule(Querier, t.initQuerier)
 	mm.RegisterModule(StoreQueryable, t.initStoreQueryables, modules.UserInvisibleModule)
 	mm.RegisterModule(QueryFrontendTripperware, t.initQueryFrontendTripperware, modules.UserInvisibleModule)
@@ -809,9 +942,14 @@ func (t *Cortex) setupModuleManager() error {
 
 	// Add dependencies
 	deps := map[string][]string{
-		API:                      {Server},
+		API:                      {Server, TLSAuth},
+		MemberlistKV:             {API},
 		Ring:                     {API, RuntimeConfig, MemberlistKV},
-		Overrides:                {RuntimeConfig},
+		RuntimeConfig:            {API},
+		LimitsStore:              {API},
+		Overrides:                {RuntimeConfig, LimitsStore},
+		LimitsAPI:                {API, Overrides},
+		OverridesExporter:        {API, RuntimeConfig, LimitsStore},
 		Distributor:              {DistributorService, API},
 		DistributorService:       {Ring, Overrides},
 		Store:                    {Overrides, DeleteRequestsStore},
@@ -819,7 +957,8 @@ func (t *Cortex) setupModuleManager() error {
 		IngesterService:          {Overrides, Store, RuntimeConfig, MemberlistKV},
 		Flusher:                  {Store, API},
 		Queryable:                {Overrides, DistributorService, Store, Ring, API, StoreQueryable, MemberlistKV},
-		Querier:                  {Queryable},
+		Querier:                  {TenantFederation},
+		TenantFederation:         {Queryable},
 		StoreQueryable:           {Overrides, Store, MemberlistKV},
 		QueryFrontendTripperware: {API, Overrides, DeleteRequestsStore},
 		QueryFrontend:            {QueryFrontendTripperware},
@@ -827,13 +966,13 @@ func (t *Cortex) setupModuleManager() error {
 		TableManager:             {API},
 		Ruler:                    {Overrides, DistributorService, Store, StoreQueryable, RulerStorage},
 		Configs:                  {API},
-		AlertManager:             {API},
-		Compactor:                {API, MemberlistKV},
+		AlertManager:             {API, MemberlistKV, Overrides},
+		Compactor:                {API, MemberlistKV, Overrides},
 		StoreGateway:             {API, Overrides, MemberlistKV},
 		ChunksPurger:             {Store, DeleteRequestsStore, API},
 		BlocksPurger:             {Store, API},
 		Purger:                   {ChunksPurger, BlocksPurger},
-		All:                      {QueryFrontend, Querier, Ingester, Distributor, TableManager, Purger, StoreGateway, Ruler},
+		All:                      {QueryFrontend, Querier, Ingester, Distributor, TableManager, Purger, StoreGateway, Ruler, LimitsAPI},
 	}
 	for mod, targets := range deps {
 		if err := mm.AddDependency(mod, targets...); err != nil {
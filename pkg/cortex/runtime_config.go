@@ -3,9 +3,12 @@ package cortex
 import (
 	"io"
 
+	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 
+	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ruler"
 	"github.com/cortexproject/cortex/pkg/util/runtimeconfig"
 	"github.com/cortexproject/cortex/pkg/util/validation"
 )
@@ -19,7 +22,15 @@ type runtimeConfigValues struct {
 	Multi kv.MultiRuntimeConfig `yaml:"multi_kv_config"`
 }
 
-func loadRuntimeConfig(r io.Reader) (interface{}, error) {
+// runtimeConfigLoader returns the loader of the runtime config, validating the per-tenant
+// overrides against the input querier and ruler configs.
+func runtimeConfigLoader(querierCfg querier.Config, rulerCfg ruler.Config) runtimeconfig.Loader {
+	return func(r io.Reader) (interface{}, error) {
+		return loadRuntimeConfig(r, querierCfg, rulerCfg)
+	}
+}
+
+func loadRuntimeConfig(r io.Reader, querierCfg querier.Config, rulerCfg ruler.Config) (interface{}, error) {
 	var overrides = &runtimeConfigValues{}
 
 	decoder := yaml.NewDecoder(r)
@@ -28,6 +39,42 @@ func loadRuntimeConfig(r io.Reader) (interface{}, error) {
 		return nil, err
 	}
 
+	for userID, limits := range overrides.TenantLimits {
+		if limits == nil {
+			continue
+		}
+
+		for _, ls := range limits.LimitsPerLabelSet {
+			if err := ls.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "invalid limits per label set for tenant %s", userID)
+			}
+		}
+
+		if err := limits.ActiveSeriesCustomTrackers.Validate(); err != nil {
+			return nil, errors.Wrapf(err, "invalid active series custom trackers for tenant %s", userID)
+		}
+
+		for _, q := range limits.BlockedQueries {
+			if err := q.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "invalid blocked queries for tenant %s", userID)
+			}
+		}
+
+		for _, p := range limits.QueryPriorities {
+			if err := p.Validate(); err != nil {
+				return nil, errors.Wrapf(err, "invalid query priorities for tenant %s", userID)
+			}
+		}
+
+		if err := limits.ValidateQueryTimeRanges(querierCfg.QueryIngestersWithin, querierCfg.QueryStoreAfter, querierCfg.ShuffleShardingIngestersLookbackPeriod); err != nil {
+			return nil, errors.Wrapf(err, "invalid query time ranges for tenant %s", userID)
+		}
+
+		if err := limits.ValidateRulerEvaluationJitter(rulerCfg.EvaluationInterval); err != nil {
+			return nil, errors.Wrapf(err, "invalid ruler evaluation jitter for tenant %s", userID)
+		}
+	}
+
 	return overrides, nil
 }
 
@@ -45,6 +92,20 @@ func tenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) validation.TenantLi
 	}
 }
 
+func allTenantLimitsFromRuntimeConfig(c *runtimeconfig.Manager) validation.AllTenantLimits {
+	if c == nil {
+		return nil
+	}
+	return func() map[string]*validation.Limits {
+		cfg, ok := c.GetConfig().(*runtimeConfigValues)
+		if !ok || cfg == nil {
+			return nil
+		}
+
+		return cfg.TenantLimits
+	}
+}
+
 func multiClientRuntimeConfigChannel(manager *runtimeconfig.Manager) func() <-chan kv.MultiRuntimeConfig {
 	if manager == nil {
 		return nil
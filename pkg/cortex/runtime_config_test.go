@@ -0,0 +1,128 @@
+package cortex
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/querier"
+	"github.com/cortexproject/cortex/pkg/ruler"
+)
+
+func TestLoadRuntimeConfig_ShouldValidateTenantLimits(t *testing.T) {
+	tests := map[string]struct {
+		yaml        string
+		expectedErr string
+	}{
+		"valid limits per label set": {
+			yaml: `
+overrides:
+  user-1:
+    limits_per_label_set:
+      - label_set:
+          team: a
+        max_global_series: 10
+`,
+		},
+		"limits per label set with an empty label set": {
+			yaml: `
+overrides:
+  user-1:
+    limits_per_label_set:
+      - max_global_series: 10
+`,
+			expectedErr: "invalid limits per label set for tenant user-1: the label set of a limit per label set is empty",
+		},
+		"limits per label set with a negative max global series": {
+			yaml: `
+overrides:
+  user-1:
+    limits_per_label_set:
+      - label_set:
+          team: a
+        max_global_series: -1
+`,
+			expectedErr: "invalid limits per label set for tenant user-1",
+		},
+		"active series custom trackers with an invalid selector": {
+			yaml: `
+overrides:
+  user-1:
+    active_series_custom_trackers:
+      - name: foo
+        selector: '{foo='
+`,
+			expectedErr: "invalid active series custom trackers for tenant user-1",
+		},
+		"per-tenant query store after lower than the querier query ingesters within": {
+			yaml: `
+overrides:
+  user-1:
+    query_store_after: 1h
+`,
+		},
+		"per-tenant query store after greater than the querier query ingesters within": {
+			yaml: `
+overrides:
+  user-1:
+    query_store_after: 3h
+`,
+			expectedErr: "invalid query time ranges for tenant user-1",
+		},
+		"per-tenant query ingesters within lower than the querier query store after": {
+			yaml: `
+overrides:
+  user-1:
+    query_ingesters_within: 30m
+`,
+			expectedErr: "invalid query time ranges for tenant user-1",
+		},
+		"per-tenant ruler evaluation jitter lower than the ruler evaluation interval": {
+			yaml: `
+overrides:
+  user-1:
+    ruler_evaluation_jitter: 30s
+`,
+		},
+		"per-tenant ruler evaluation jitter equal to the ruler evaluation interval": {
+			yaml: `
+overrides:
+  user-1:
+    ruler_evaluation_jitter: 1m
+`,
+			expectedErr: "invalid ruler evaluation jitter for tenant user-1",
+		},
+		"per-tenant negative ruler evaluation jitter": {
+			yaml: `
+overrides:
+  user-1:
+    ruler_evaluation_jitter: -1s
+`,
+			expectedErr: "invalid ruler evaluation jitter for tenant user-1",
+		},
+	}
+
+	querierCfg := querier.Config{
+		QueryIngestersWithin: 2 * time.Hour,
+		QueryStoreAfter:      time.Hour,
+	}
+
+	rulerCfg := ruler.Config{
+		EvaluationInterval: time.Minute,
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			_, err := loadRuntimeConfig(strings.NewReader(testData.yaml), querierCfg, rulerCfg)
+			if testData.expectedErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"sort"
 	"time"
 
+	"github.com/cortexproject/cortex/pkg/api"
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
@@ -53,16 +54,13 @@ func (t *Cortex) servicesHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(200)
 	w.Header().Set("Content-Type", "text/plain")
 
-	svcs := make([]renderService, 0)
-	for mod, s := range t.ServiceMap {
+	svcs := make([]renderService, 0, len(t.ServiceMap))
+	for _, m := range t.modulesStatus() {
 		svcs = append(svcs, renderService{
-			Name:   mod,
-			Status: s.State().String(),
+			Name:   m.Name,
+			Status: m.State,
 		})
 	}
-	sort.Slice(svcs, func(i, j int) bool {
-		return svcs[i].Name < svcs[j].Name
-	})
 
 	// TODO: this could be extended to also print sub-services, if given service has any
 	util.RenderHTTPResponse(w, struct {
@@ -73,3 +71,18 @@ func (t *Cortex) servicesHandler(w http.ResponseWriter, r *http.Request) {
 		Services: svcs,
 	}, tmpl, r)
 }
+
+// modulesStatus returns the state of the running modules, sorted by name.
+func (t *Cortex) modulesStatus() []api.ModuleStatus {
+	out := make([]api.ModuleStatus, 0, len(t.ServiceMap))
+	for mod, s := range t.ServiceMap {
+		out = append(out, api.ModuleStatus{
+			Name:  mod,
+			State: s.State().String(),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
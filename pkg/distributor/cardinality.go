@@ -0,0 +1,155 @@
+package distributor
+
+import (
+	"context"
+	"sort"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	ingester_client "github.com/cortexproject/cortex/pkg/ingester/client"
+)
+
+// LabelNameCardinality holds the cardinality of a label name.
+type LabelNameCardinality struct {
+	LabelName        string `json:"label_name"`
+	SeriesCount      uint64 `json:"series_count"`
+	LabelValuesCount uint64 `json:"label_values_count"`
+}
+
+// LabelValueCardinality holds the cardinality of a label value.
+type LabelValueCardinality struct {
+	LabelValue  string `json:"label_value"`
+	SeriesCount uint64 `json:"series_count"`
+}
+
+// LabelNamesCardinality returns the number of series and distinct values for each label name of
+// the in-memory series matching the input matchers, sorted by the number of series (desc) and
+// truncated to limit. The total number of matching series is returned too.
+//
+// Each ingester aggregates its own series and returns its top label names only, so the result
+// is approximated when the label names are more than the limit. The number of series is the sum
+// across the ingesters divided by the replication factor, while the number of label values is
+// the highest number of values found in a single ingester.
+func (d *Distributor) LabelNamesCardinality(ctx context.Context, limit int, matchers ...*labels.Matcher) ([]LabelNameCardinality, uint64, error) {
+	replicationSet, err := d.GetIngestersForMetadata(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Make sure we get a successful response from all of them.
+	replicationSet.MaxErrors = 0
+
+	req, err := ingester_client.ToLabelNamesCardinalityRequest(matchers, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resps, err := d.ForReplicationSet(ctx, replicationSet, func(ctx context.Context, client ingester_client.IngesterClient) (interface{}, error) {
+		return client.LabelNamesCardinality(ctx, req)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total uint64
+	merged := map[string]*LabelNameCardinality{}
+	for _, resp := range resps {
+		r := resp.(*ingester_client.LabelNamesCardinalityResponse)
+		total += r.SeriesCountTotal
+
+		for _, item := range r.LabelNames {
+			entry, ok := merged[item.LabelName]
+			if !ok {
+				entry = &LabelNameCardinality{LabelName: item.LabelName}
+				merged[item.LabelName] = entry
+			}
+
+			entry.SeriesCount += item.SeriesCount
+			if item.LabelValuesCount > entry.LabelValuesCount {
+				entry.LabelValuesCount = item.LabelValuesCount
+			}
+		}
+	}
+
+	replicationFactor := uint64(d.ingestersRing.ReplicationFactor())
+	result := make([]LabelNameCardinality, 0, len(merged))
+	for _, entry := range merged {
+		entry.SeriesCount /= replicationFactor
+		result = append(result, *entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SeriesCount != result[j].SeriesCount {
+			return result[i].SeriesCount > result[j].SeriesCount
+		}
+		return result[i].LabelName < result[j].LabelName
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, total / replicationFactor, nil
+}
+
+// LabelValuesCardinality returns the number of series for each value of the input label name,
+// among the in-memory series matching the input matchers, sorted by the number of series (desc)
+// and truncated to limit. The total number of matching series having the label is returned too.
+//
+// Each ingester aggregates its own series and returns its top label values only, so the result
+// is approximated when the label values are more than the limit. The number of series is the sum
+// across the ingesters divided by the replication factor.
+func (d *Distributor) LabelValuesCardinality(ctx context.Context, labelName string, limit int, matchers ...*labels.Matcher) ([]LabelValueCardinality, uint64, error) {
+	replicationSet, err := d.GetIngestersForMetadata(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Make sure we get a successful response from all of them.
+	replicationSet.MaxErrors = 0
+
+	req, err := ingester_client.ToLabelValuesCardinalityRequest(labelName, matchers, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	resps, err := d.ForReplicationSet(ctx, replicationSet, func(ctx context.Context, client ingester_client.IngesterClient) (interface{}, error) {
+		return client.LabelValuesCardinality(ctx, req)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total uint64
+	merged := map[string]uint64{}
+	for _, resp := range resps {
+		r := resp.(*ingester_client.LabelValuesCardinalityResponse)
+		total += r.SeriesCountTotal
+
+		for _, item := range r.LabelValues {
+			merged[item.LabelValue] += item.SeriesCount
+		}
+	}
+
+	replicationFactor := uint64(d.ingestersRing.ReplicationFactor())
+	result := make([]LabelValueCardinality, 0, len(merged))
+	for value, count := range merged {
+		result = append(result, LabelValueCardinality{
+			LabelValue:  value,
+			SeriesCount: count / replicationFactor,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SeriesCount != result[j].SeriesCount {
+			return result[i].SeriesCount > result[j].SeriesCount
+		}
+		return result[i].LabelValue < result[j].LabelValue
+	})
+
+	if len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, total / replicationFactor, nil
+}
@@ -0,0 +1,146 @@
+package distributor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestDistributor_CardinalityHandlers(t *testing.T) {
+	fixtures := []labels.Labels{
+		labels.FromStrings(labels.MetricName, "http_requests_total", "status", "200", "path", "/a"),
+		labels.FromStrings(labels.MetricName, "http_requests_total", "status", "200", "path", "/b"),
+		labels.FromStrings(labels.MetricName, "http_requests_total", "status", "500", "path", "/a"),
+		labels.FromStrings(labels.MetricName, "up", "job", "a"),
+	}
+
+	ds, ingesters, r := prepare(t, prepConfig{
+		numIngesters:     3,
+		happyIngesters:   3,
+		numDistributors:  1,
+		shardByAllLabels: true,
+	})
+	defer stopAll(ds, r)
+
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+	for _, series := range fixtures {
+		_, err := ds[0].Push(ctx, mockWriteRequest(series, 1, 100000))
+		require.NoError(t, err)
+	}
+
+	// The push succeeds once the quorum is reached, so wait until all the ingesters received the
+	// series, given the cardinality is divided by the replication factor.
+	for i := range ingesters {
+		test.Poll(t, time.Second, len(fixtures), func() interface{} {
+			return len(ingesters[i].series())
+		})
+	}
+
+	tests := map[string]struct {
+		handler          http.HandlerFunc
+		params           url.Values
+		expectedStatus   int
+		expectedResponse interface{}
+	}{
+		"label names of all series": {
+			handler:        ds[0].LabelNamesCardinalityHandler,
+			expectedStatus: http.StatusOK,
+			expectedResponse: &LabelNamesCardinalityResponse{
+				SeriesCountTotal: 4,
+				LabelNames: []LabelNameCardinality{
+					{LabelName: labels.MetricName, SeriesCount: 4, LabelValuesCount: 2},
+					{LabelName: "path", SeriesCount: 3, LabelValuesCount: 2},
+					{LabelName: "status", SeriesCount: 3, LabelValuesCount: 2},
+					{LabelName: "job", SeriesCount: 1, LabelValuesCount: 1},
+				},
+			},
+		},
+		"label names with selector and limit": {
+			handler:        ds[0].LabelNamesCardinalityHandler,
+			params:         url.Values{"selector": []string{`{status="200"}`}, "limit": []string{"2"}},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &LabelNamesCardinalityResponse{
+				SeriesCountTotal: 2,
+				LabelNames: []LabelNameCardinality{
+					{LabelName: labels.MetricName, SeriesCount: 2, LabelValuesCount: 1},
+					{LabelName: "path", SeriesCount: 2, LabelValuesCount: 2},
+				},
+			},
+		},
+		"label values": {
+			handler:        ds[0].LabelValuesCardinalityHandler,
+			params:         url.Values{"label_name": []string{"status"}},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &LabelValuesCardinalityResponse{
+				SeriesCountTotal: 3,
+				LabelName:        "status",
+				LabelValues: []LabelValueCardinality{
+					{LabelValue: "200", SeriesCount: 2},
+					{LabelValue: "500", SeriesCount: 1},
+				},
+			},
+		},
+		"label values with selector": {
+			handler:        ds[0].LabelValuesCardinalityHandler,
+			params:         url.Values{"label_name": []string{"status"}, "selector": []string{`{path="/a"}`}},
+			expectedStatus: http.StatusOK,
+			expectedResponse: &LabelValuesCardinalityResponse{
+				SeriesCountTotal: 2,
+				LabelName:        "status",
+				LabelValues: []LabelValueCardinality{
+					{LabelValue: "200", SeriesCount: 1},
+					{LabelValue: "500", SeriesCount: 1},
+				},
+			},
+		},
+		"label values without label name": {
+			handler:        ds[0].LabelValuesCardinalityHandler,
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid selector": {
+			handler:        ds[0].LabelNamesCardinalityHandler,
+			params:         url.Values{"selector": []string{`{status=`}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid limit": {
+			handler:        ds[0].LabelNamesCardinalityHandler,
+			params:         url.Values{"limit": []string{"0"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/?"+testData.params.Encode(), nil).WithContext(ctx)
+			rec := httptest.NewRecorder()
+			testData.handler(rec, req)
+
+			require.Equal(t, testData.expectedStatus, rec.Code)
+			if testData.expectedResponse == nil {
+				return
+			}
+
+			switch expected := testData.expectedResponse.(type) {
+			case *LabelNamesCardinalityResponse:
+				actual := &LabelNamesCardinalityResponse{}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), actual))
+				assert.Equal(t, expected, actual)
+			case *LabelValuesCardinalityResponse:
+				actual := &LabelValuesCardinalityResponse{}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), actual))
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/instrument"
 	"github.com/weaveworks/common/user"
+	"go.uber.org/atomic"
 
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	ingester_client "github.com/cortexproject/cortex/pkg/ingester/client"
@@ -30,6 +31,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/extract"
 	"github.com/cortexproject/cortex/pkg/util/limiter"
+	util_math "github.com/cortexproject/cortex/pkg/util/math"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/validation"
 )
@@ -113,12 +115,18 @@ var (
 	// Validation errors.
 	errInvalidShardingStrategy = errors.New("invalid sharding strategy")
 	errInvalidTenantShardSize  = errors.New("invalid tenant shard size, the value must be greater than 0")
+
+	// Instance limits errors.
+	errMaxInflightRequestsReached = errors.New("the distributor has reached the max number of inflight push requests, please retry later")
+	errMaxIngestionRateReached    = errors.New("the distributor has reached the max ingestion rate, please retry later")
 )
 
 const (
 	typeSamples  = "samples"
 	typeMetadata = "metadata"
 
+	instanceIngestionRateTickInterval = time.Second
+
 	// Supported sharding strategies.
 
 )
@@ -143,6 +151,13 @@ type Distributor struct {
 	// Per-user rate limiter.
 	ingestionRateLimiter *limiter.RateLimiter
 
+	// Ingesters shard sizes observed over the shuffle sharding lookback period.
+	shardSizes *shardSizeTracker
+
+	// Instance-level state, used to enforce the instance limits.
+	inflightPushRequests atomic.Int64
+	ingestionRate        *util_math.EwmaRate
+
 	// Manager for subservices (HA Tracker, distributor ring and client pool)
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -174,6 +189,23 @@ type Config struct {
 
 	// This config is dynamically injected because defined in the querier config.
 	ShuffleShardingLookbackPeriod time.Duration `yaml:"-"`
+
+	// Limits for the distributor instance, across all tenants.
+	InstanceLimits InstanceLimits `yaml:"instance_limits"`
+
+	MaxPartialErrorsInResponse int `yaml:"max_partial_errors_in_response"`
+}
+
+// InstanceLimits configures the limits of a single distributor instance, across all tenants.
+type InstanceLimits struct {
+	MaxIngestionRate        float64 `yaml:"max_ingestion_rate"`
+	MaxInflightPushRequests int     `yaml:"max_inflight_push_requests"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *InstanceLimits) RegisterFlags(f *flag.FlagSet) {
+	f.Float64Var(&cfg.MaxIngestionRate, "distributor.instance-limits.max-ingestion-rate", 0, "Max ingestion rate (samples/sec) that this distributor will accept, across all tenants. Push requests received when the limit is exceeded are rejected with a 429 status code. This limit is per-distributor, not per-tenant. 0 = unlimited.")
+	f.IntVar(&cfg.MaxInflightPushRequests, "distributor.instance-limits.max-inflight-push-requests", 0, "Max number of inflight push requests that this distributor can handle, across all tenants. Push requests received when the limit is reached are rejected with a 429 status code. 0 = unlimited.")
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet
@@ -181,12 +213,14 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.PoolConfig.RegisterFlags(f)
 	cfg.HATrackerConfig.RegisterFlags(f)
 	cfg.DistributorRing.RegisterFlags(f)
+	cfg.InstanceLimits.RegisterFlags(f)
 
 	f.IntVar(&cfg.MaxRecvMsgSize, "distributor.max-recv-msg-size", 100<<20, "remote_write API max receive message size (bytes).")
 	f.DurationVar(&cfg.RemoteTimeout, "distributor.remote-timeout", 2*time.Second, "Timeout for downstream ingesters.")
 	f.DurationVar(&cfg.ExtraQueryDelay, "distributor.extra-query-delay", 0, "Time to wait before sending more than the minimum successful query requests.")
 	f.BoolVar(&cfg.ShardByAllLabels, "distributor.shard-by-all-labels", false, "Distribute samples based on all labels, as opposed to solely by user and metric name.")
 	f.StringVar(&cfg.ShardingStrategy, "distributor.sharding-strategy", util.ShardingStrategyDefault, fmt.Sprintf("The sharding strategy to use. Supported values are: %s.", strings.Join(supportedShardingStrategies, ", ")))
+	f.IntVar(&cfg.MaxPartialErrorsInResponse, "distributor.max-partial-errors-in-response", 1, "Maximum number of series and metadata validation errors reported in the push response. If greater than 1, the response contains a summary of up to this number of errors, and the total number of errors in the request. The valid series and metadata are ingested anyway.")
 }
 
 // Validate config and returns error on failure
@@ -213,7 +247,7 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 	replicationFactor.Set(float64(ingestersRing.ReplicationFactor()))
 	cfg.PoolConfig.RemoteTimeout = cfg.RemoteTimeout
 
-	replicas, err := newClusterTracker(cfg.HATrackerConfig, reg)
+	replicas, err := newClusterTracker(cfg.HATrackerConfig, limits, reg)
 	if err != nil {
 		return nil, err
 	}
@@ -250,7 +284,33 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 		limits:               limits,
 		ingestionRateLimiter: limiter.NewRateLimiter(ingestionRateStrategy, 10*time.Second),
 		HATracker:            replicas,
-	}
+		shardSizes:           newShardSizeTracker(),
+		ingestionRate:        util_math.NewEWMARate(0.2, instanceIngestionRateTickInterval),
+	}
+
+	promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name:        "cortex_distributor_instance_limits",
+		Help:        "Instance limits used by this distributor.",
+		ConstLabels: map[string]string{"limit": "max_inflight_push_requests"},
+	}).Set(float64(cfg.InstanceLimits.MaxInflightPushRequests))
+	promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+		Name:        "cortex_distributor_instance_limits",
+		Help:        "Instance limits used by this distributor.",
+		ConstLabels: map[string]string{"limit": "max_ingestion_rate"},
+	}).Set(cfg.InstanceLimits.MaxIngestionRate)
+
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_distributor_inflight_push_requests",
+		Help: "Current number of inflight push requests in distributor.",
+	}, func() float64 {
+		return float64(d.inflightPushRequests.Load())
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "cortex_distributor_ingestion_rate_samples_per_second",
+		Help: "Current ingestion rate in samples/sec that distributor is using to limit access.",
+	}, func() float64 {
+		return d.ingestionRate.Rate()
+	})
 
 	subservices = append(subservices, d.ingesterPool)
 	d.subservices, err = services.NewManager(subservices...)
@@ -270,11 +330,20 @@ func (d *Distributor) starting(ctx context.Context) error {
 }
 
 func (d *Distributor) running(ctx context.Context) error {
-	select {
-	case <-ctx.Done():
-		return nil
-	case err := <-d.subservicesWatcher.Chan():
-		return errors.Wrap(err, "distributor subservice failed")
+	ingestionRateTicker := time.NewTicker(instanceIngestionRateTickInterval)
+	defer ingestionRateTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ingestionRateTicker.C:
+			d.ingestionRate.Tick()
+
+		case err := <-d.subservicesWatcher.Chan():
+			return errors.Wrap(err, "distributor subservice failed")
+		}
 	}
 }
 
@@ -383,6 +452,22 @@ func (d *Distributor) validateSeries(ts ingester_client.PreallocTimeseries, user
 		nil
 }
 
+// checkInstanceLimits returns an error if the distributor instance limits have been reached.
+// Such errors are returned with a 429 status code, so that the clients will retry later.
+func (d *Distributor) checkInstanceLimits(inflight int64) error {
+	limits := d.cfg.InstanceLimits
+
+	if limits.MaxInflightPushRequests > 0 && inflight > int64(limits.MaxInflightPushRequests) {
+		return httpgrpc.Errorf(http.StatusTooManyRequests, errMaxInflightRequestsReached.Error())
+	}
+
+	if limits.MaxIngestionRate > 0 && d.ingestionRate.Rate() >= limits.MaxIngestionRate {
+		return httpgrpc.Errorf(http.StatusTooManyRequests, errMaxIngestionRateReached.Error())
+	}
+
+	return nil
+}
+
 // Push implements client.IngesterServer
 func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*client.WriteResponse, error) {
 	userID, err := tenant.TenantID(ctx)
@@ -391,7 +476,17 @@ func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*clie
 	}
 	source := util.GetSourceIPsFromOutgoingCtx(ctx)
 
-	var firstPartialErr error
+	inflight := d.inflightPushRequests.Inc()
+	defer d.inflightPushRequests.Dec()
+
+	if err := d.checkInstanceLimits(inflight); err != nil {
+		// Ensure the request slice is reused if the request is rejected.
+		client.ReuseSlice(req.Timeseries)
+
+		return nil, err
+	}
+
+	partialErrs := newPartialErrors(d.cfg.MaxPartialErrorsInResponse)
 	removeReplica := false
 
 	numSamples := 0
@@ -486,9 +581,7 @@ func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*clie
 
 		// Errors in validation are considered non-fatal, as one series in a request may contain
 		// invalid data but all the remaining series could be perfectly valid.
-		if err != nil && firstPartialErr == nil {
-			firstPartialErr = err
-		}
+		partialErrs.add(err)
 
 		// validateSeries would have returned an emptyPreallocSeries if there were no valid samples.
 		if validatedSeries == emptyPreallocSeries {
@@ -504,10 +597,7 @@ func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*clie
 		err := validation.ValidateMetadata(d.limits, userID, m)
 
 		if err != nil {
-			if firstPartialErr == nil {
-				firstPartialErr = err
-			}
-
+			partialErrs.add(err)
 			continue
 		}
 
@@ -522,7 +612,7 @@ func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*clie
 		// Ensure the request slice is reused if there's no series or metadata passing the validation.
 		client.ReuseSlice(req.Timeseries)
 
-		return &client.WriteResponse{}, firstPartialErr
+		return &client.WriteResponse{}, partialErrs.err()
 	}
 
 	now := time.Now()
@@ -538,6 +628,9 @@ func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*clie
 		return nil, httpgrpc.Errorf(http.StatusTooManyRequests, "ingestion rate limit (%v) exceeded while adding %d samples and %d metadata", d.ingestionRateLimiter.Limit(now, userID), validatedSamples, len(validatedMetadata))
 	}
 
+	// Track the samples accepted by this instance, to enforce the instance ingestion rate limit.
+	d.ingestionRate.Add(int64(validatedSamples))
+
 	subRing := d.ingestersRing.(ring.ReadRing)
 
 	// Obtain a subring if required.
@@ -576,7 +669,7 @@ func (d *Distributor) Push(ctx context.Context, req *client.WriteRequest) (*clie
 	if err != nil {
 		return nil, err
 	}
-	return &client.WriteResponse{}, firstPartialErr
+	return &client.WriteResponse{}, partialErrs.err()
 }
 
 func sortLabelsIfNeeded(labels []client.LabelAdapter) {
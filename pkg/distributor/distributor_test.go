@@ -359,6 +359,136 @@ func TestDistributor_PushIngestionRateLimiter(t *testing.T) {
 	}
 }
 
+func TestDistributor_PushInstanceLimits(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	tests := map[string]struct {
+		instanceLimits InstanceLimits
+		// Number of requests already inflight when the push is received.
+		inflightRequests int64
+		// Ingestion rate observed by the distributor before the push is received.
+		preIngestedSamples int
+		expectedError      error
+	}{
+		"should succeed if no instance limits are configured": {
+			inflightRequests:   100,
+			preIngestedSamples: 1000,
+			expectedError:      nil,
+		},
+		"should succeed if below the max inflight push requests": {
+			instanceLimits:   InstanceLimits{MaxInflightPushRequests: 2},
+			inflightRequests: 1,
+			expectedError:    nil,
+		},
+		"should fail if the max inflight push requests is reached": {
+			instanceLimits:   InstanceLimits{MaxInflightPushRequests: 2},
+			inflightRequests: 2,
+			expectedError:    httpgrpc.Errorf(http.StatusTooManyRequests, errMaxInflightRequestsReached.Error()),
+		},
+		"should succeed if below the max ingestion rate": {
+			instanceLimits:     InstanceLimits{MaxIngestionRate: 1000},
+			preIngestedSamples: 500,
+			expectedError:      nil,
+		},
+		"should fail if the max ingestion rate is reached": {
+			instanceLimits:     InstanceLimits{MaxIngestionRate: 1000},
+			preIngestedSamples: 10000,
+			expectedError:      httpgrpc.Errorf(http.StatusTooManyRequests, errMaxIngestionRateReached.Error()),
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			distributors, _, r := prepare(t, prepConfig{
+				numIngesters:     3,
+				happyIngesters:   3,
+				numDistributors:  1,
+				shardByAllLabels: true,
+				instanceLimits:   testData.instanceLimits,
+			})
+			defer stopAll(distributors, r)
+
+			d := distributors[0]
+			d.inflightPushRequests.Add(testData.inflightRequests)
+			d.ingestionRate.Add(int64(testData.preIngestedSamples))
+			d.ingestionRate.Tick()
+
+			response, err := d.Push(ctx, makeWriteRequest(0, 10, 0))
+			if testData.expectedError == nil {
+				assert.Equal(t, success, response)
+				assert.NoError(t, err)
+			} else {
+				assert.Nil(t, response)
+				assert.Equal(t, testData.expectedError, err)
+			}
+
+			// The inflight requests must be released once the push has completed.
+			assert.Equal(t, testData.inflightRequests, d.inflightPushRequests.Load())
+		})
+	}
+}
+
+func TestDistributor_PushPartialErrors(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	tests := map[string]struct {
+		maxPartialErrorsInResponse int
+		expectedError              error
+	}{
+		"should return only the first error by default": {
+			expectedError: httpgrpc.Errorf(http.StatusBadRequest, "sample for 'foo' has timestamp too old: 0"),
+		},
+		"should return a summary of all errors if below the max number of errors in the response": {
+			maxPartialErrorsInResponse: 5,
+			expectedError:              httpgrpc.Errorf(http.StatusBadRequest, "3 errors in the request: sample for 'foo' has timestamp too old: 0; sample for 'foo' has timestamp too old: 1; sample for 'foo' has timestamp too old: 2"),
+		},
+		"should return a summary of the first errors if above the max number of errors in the response": {
+			maxPartialErrorsInResponse: 2,
+			expectedError:              httpgrpc.Errorf(http.StatusBadRequest, "3 errors in the request (showing the first 2): sample for 'foo' has timestamp too old: 0; sample for 'foo' has timestamp too old: 1"),
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			limits := &validation.Limits{}
+			flagext.DefaultValues(limits)
+			limits.RejectOldSamples = true
+			limits.RejectOldSamplesMaxAge = time.Hour
+
+			distributors, ingesters, r := prepare(t, prepConfig{
+				numIngesters:               3,
+				happyIngesters:             3,
+				numDistributors:            1,
+				shardByAllLabels:           true,
+				limits:                     limits,
+				maxPartialErrorsInResponse: testData.maxPartialErrorsInResponse,
+			})
+			defer stopAll(distributors, r)
+
+			// Push 3 series with too old samples, and a valid one.
+			req := makeWriteRequest(0, 3, 0)
+			req.Timeseries = append(req.Timeseries, makeWriteRequest(time.Now().UnixNano()/int64(time.Millisecond), 1, 0).Timeseries...)
+
+			response, err := distributors[0].Push(ctx, req)
+			assert.Equal(t, success, response)
+			assert.Equal(t, testData.expectedError, err)
+
+			// The valid series is ingested anyway.
+			test.Poll(t, time.Second, len(ingesters), func() interface{} {
+				count := 0
+				for i := range ingesters {
+					count += len(ingesters[i].series())
+				}
+				return count
+			})
+		})
+	}
+}
+
 func TestDistributor_PushHAInstances(t *testing.T) {
 	ctx = user.InjectOrgID(context.Background(), "user")
 
@@ -422,7 +552,7 @@ func TestDistributor_PushHAInstances(t *testing.T) {
 						KVStore:         kv.Config{Mock: mock},
 						UpdateTimeout:   100 * time.Millisecond,
 						FailoverTimeout: time.Second,
-					}, nil)
+					}, d.limits, nil)
 					require.NoError(t, err)
 					require.NoError(t, services.StartAndAwaitRunning(context.Background(), r))
 					d.HATracker = r
@@ -1058,6 +1188,8 @@ type prepConfig struct {
 	shuffleShardSize             int
 	limits                       *validation.Limits
 	numDistributors              int
+	instanceLimits               InstanceLimits
+	maxPartialErrorsInResponse   int
 }
 
 func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *ring.Ring) {
@@ -1137,6 +1269,10 @@ func prepare(t *testing.T, cfg prepConfig) ([]*Distributor, []mockIngester, *rin
 		distributorCfg.DistributorRing.InstanceID = strconv.Itoa(i)
 		distributorCfg.DistributorRing.KVStore.Mock = kvStore
 		distributorCfg.DistributorRing.InstanceAddr = "127.0.0.1"
+		distributorCfg.InstanceLimits = cfg.instanceLimits
+		if cfg.maxPartialErrorsInResponse > 0 {
+			distributorCfg.MaxPartialErrorsInResponse = cfg.maxPartialErrorsInResponse
+		}
 
 		if cfg.shuffleShardEnabled {
 			distributorCfg.ShardingStrategy = util.ShardingStrategyShuffle
@@ -1491,6 +1627,85 @@ func (i *mockIngester) MetricsMetadata(ctx context.Context, req *client.MetricsM
 	return resp, nil
 }
 
+func (i *mockIngester) LabelNamesCardinality(ctx context.Context, req *client.LabelNamesCardinalityRequest, opts ...grpc.CallOption) (*client.LabelNamesCardinalityResponse, error) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.trackCall("LabelNamesCardinality")
+
+	if !i.happy {
+		return nil, errFail
+	}
+
+	matchers, _, err := client.FromLabelNamesCardinalityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &client.LabelNamesCardinalityResponse{}
+	items := map[string]*client.LabelNameCardinality{}
+	values := map[string]map[string]struct{}{}
+	for _, ts := range i.timeseries {
+		if !match(ts.Labels, matchers) {
+			continue
+		}
+
+		resp.SeriesCountTotal++
+		for _, l := range ts.Labels {
+			if _, ok := items[l.Name]; !ok {
+				items[l.Name] = &client.LabelNameCardinality{LabelName: l.Name}
+				values[l.Name] = map[string]struct{}{}
+			}
+			items[l.Name].SeriesCount++
+			values[l.Name][l.Value] = struct{}{}
+		}
+	}
+
+	for name, item := range items {
+		item.LabelValuesCount = uint64(len(values[name]))
+		resp.LabelNames = append(resp.LabelNames, item)
+	}
+
+	return resp, nil
+}
+
+func (i *mockIngester) LabelValuesCardinality(ctx context.Context, req *client.LabelValuesCardinalityRequest, opts ...grpc.CallOption) (*client.LabelValuesCardinalityResponse, error) {
+	i.Lock()
+	defer i.Unlock()
+
+	i.trackCall("LabelValuesCardinality")
+
+	if !i.happy {
+		return nil, errFail
+	}
+
+	labelName, matchers, _, err := client.FromLabelValuesCardinalityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &client.LabelValuesCardinalityResponse{}
+	items := map[string]*client.LabelValueCardinality{}
+	for _, ts := range i.timeseries {
+		value := client.FromLabelAdaptersToLabels(ts.Labels).Get(labelName)
+		if value == "" || !match(ts.Labels, matchers) {
+			continue
+		}
+
+		resp.SeriesCountTotal++
+		if _, ok := items[value]; !ok {
+			items[value] = &client.LabelValueCardinality{LabelValue: value}
+		}
+		items[value].SeriesCount++
+	}
+
+	for _, item := range items {
+		resp.LabelValues = append(resp.LabelValues, item)
+	}
+
+	return resp, nil
+}
+
 func (i *mockIngester) trackCall(name string) {
 	if i.calls == nil {
 		i.calls = map[string]int{}
@@ -23,6 +23,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
@@ -51,6 +52,7 @@ var (
 	}, []string{"user", "cluster"})
 
 	errNegativeUpdateTimeoutJitterMax = errors.New("HA tracker max update timeout jitter shouldn't be negative")
+	errHATrackerDisabled              = errors.New("HA tracker is disabled")
 	errInvalidFailoverTimeout         = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
 )
 
@@ -64,6 +66,48 @@ func NewReplicaDesc() *ReplicaDesc {
 	return &ReplicaDesc{}
 }
 
+// haTrackerLimits provides the per-tenant settings of the HA tracker.
+type haTrackerLimits interface {
+	// HATrackerFailoverTimeout returns the failover timeout of the tenant's HA clusters,
+	// or 0 to use the one configured in the HA tracker config.
+	HATrackerFailoverTimeout(userID string) time.Duration
+}
+
+// Merge merges the other ReplicaDesc into this one, when using the memberlist KV store.
+// The most recently received replica wins; on a tie, the replica with the greatest name
+// wins, so that the merge is commutative. Returns the change, or nil if nothing changed.
+func (r *ReplicaDesc) Merge(other memberlist.Mergeable, _ bool) (memberlist.Mergeable, error) {
+	if other == nil {
+		return nil, nil
+	}
+
+	otherReplica, ok := other.(*ReplicaDesc)
+	if !ok {
+		return nil, fmt.Errorf("expected *distributor.ReplicaDesc, got %T", other)
+	}
+
+	if otherReplica == nil {
+		return nil, nil
+	}
+
+	if otherReplica.ReceivedAt < r.ReceivedAt || (otherReplica.ReceivedAt == r.ReceivedAt && otherReplica.Replica <= r.Replica) {
+		return nil, nil
+	}
+
+	r.Replica = otherReplica.Replica
+	r.ReceivedAt = otherReplica.ReceivedAt
+
+	return &ReplicaDesc{Replica: r.Replica, ReceivedAt: r.ReceivedAt}, nil
+}
+
+// MergeContent describes the content of this ReplicaDesc, when using the memberlist KV store.
+func (r *ReplicaDesc) MergeContent() []string {
+	return []string{r.Replica}
+}
+
+// RemoveTombstones is a noop, because the HA tracker never deletes the elected replicas.
+func (r *ReplicaDesc) RemoveTombstones(_ time.Time) {}
+
 // Track the replica we're accepting samples from
 // for each HA cluster we know about.
 type haTracker struct {
@@ -71,6 +115,7 @@ type haTracker struct {
 
 	logger              log.Logger
 	cfg                 HATrackerConfig
+	limits              haTrackerLimits
 	client              kv.Client
 	updateTimeoutJitter time.Duration
 
@@ -94,7 +139,7 @@ type HATrackerConfig struct {
 	// more than this duration
 	FailoverTimeout time.Duration `yaml:"ha_tracker_failover_timeout"`
 
-	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the ring. Please be aware that memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes."`
+	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the ring. When using memberlist, a change of the elected replica takes longer to propagate to all distributors than with Consul or etcd, so during a failover the distributors may temporarily accept samples from different replicas."`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -143,7 +188,7 @@ func GetReplicaDescCodec() codec.Proto {
 
 // NewClusterTracker returns a new HA cluster tracker using either Consul
 // or in-memory KV store. Tracker must be started via StartAsync().
-func newClusterTracker(cfg HATrackerConfig, reg prometheus.Registerer) (*haTracker, error) {
+func newClusterTracker(cfg HATrackerConfig, limits haTrackerLimits, reg prometheus.Registerer) (*haTracker, error) {
 	var jitter time.Duration
 	if cfg.UpdateTimeoutJitterMax > 0 {
 		jitter = time.Duration(rand.Int63n(int64(2*cfg.UpdateTimeoutJitterMax))) - cfg.UpdateTimeoutJitterMax
@@ -152,6 +197,7 @@ func newClusterTracker(cfg HATrackerConfig, reg prometheus.Registerer) (*haTrack
 	t := &haTracker{
 		logger:              util.Logger,
 		cfg:                 cfg,
+		limits:              limits,
 		updateTimeoutJitter: jitter,
 		elected:             map[string]ReplicaDesc{},
 	}
@@ -230,7 +276,7 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 		return nil
 	}
 
-	err := c.checkKVStore(ctx, key, replica, now)
+	err := c.checkKVStore(ctx, key, replica, c.failoverTimeout(userID), now)
 	kvCASCalls.WithLabelValues(userID, cluster).Inc()
 	if err != nil {
 		// The callback within checkKVStore will return a 202 if the sample is being deduped,
@@ -242,7 +288,7 @@ func (c *haTracker) checkReplica(ctx context.Context, userID, cluster, replica s
 	return err
 }
 
-func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, now time.Time) error {
+func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, failoverTimeout time.Duration, now time.Time) error {
 	return c.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
 		if desc, ok := in.(*ReplicaDesc); ok {
 
@@ -254,7 +300,7 @@ func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, now t
 
 			// We shouldn't failover to accepting a new replica if the timestamp we've received this sample at
 			// is less than failOver timeout amount of time since the timestamp in the KV store.
-			if desc.Replica != replica && now.Sub(timestamp.Time(desc.ReceivedAt)) < c.cfg.FailoverTimeout {
+			if desc.Replica != replica && now.Sub(timestamp.Time(desc.ReceivedAt)) < failoverTimeout {
 				// Return a 202.
 				return nil, false, replicasNotMatchError(replica, desc.Replica)
 			}
@@ -269,6 +315,45 @@ func (c *haTracker) checkKVStore(ctx context.Context, key, replica string, now t
 	})
 }
 
+// failoverTimeout returns the failover timeout of the user's HA clusters. The per-tenant timeout
+// can't be lower than the minimum failover timeout allowed by the HA tracker config, otherwise
+// the tracker would failover between replicas before the elected replica's timestamp is updated.
+func (c *haTracker) failoverTimeout(userID string) time.Duration {
+	timeout := c.limits.HATrackerFailoverTimeout(userID)
+	if timeout <= 0 {
+		return c.cfg.FailoverTimeout
+	}
+
+	if minTimeout := c.cfg.UpdateTimeout + c.cfg.UpdateTimeoutJitterMax + time.Second; timeout < minTimeout {
+		return minTimeout
+	}
+	return timeout
+}
+
+// forceElectReplica elects the input replica for the user's cluster, regardless of the currently
+// elected one. The elected replica can still failover if it doesn't send any sample within the
+// failover timeout.
+func (c *haTracker) forceElectReplica(ctx context.Context, userID, cluster, replica string) error {
+	if !c.cfg.EnableHATracker {
+		return errHATrackerDisabled
+	}
+
+	key := fmt.Sprintf("%s/%s", userID, cluster)
+	now := mtime.Now()
+
+	err := c.client.CAS(ctx, key, func(_ interface{}) (out interface{}, retry bool, err error) {
+		return &ReplicaDesc{
+			Replica: replica, ReceivedAt: timestamp.FromTime(now),
+		}, true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	level.Info(c.logger).Log("msg", "forcibly elected HA replica", "user", userID, "cluster", cluster, "replica", replica)
+	return nil
+}
+
 func replicasNotMatchError(replica, elected string) error {
 	return httpgrpc.Errorf(http.StatusAccepted, "replicas did not mach, rejecting sample: replica=%s, elected=%s", replica, elected)
 }
@@ -1,12 +1,14 @@
 package distributor
 
 import (
+	"errors"
 	"html/template"
 	"net/http"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/prometheus/pkg/timestamp"
 
 	"github.com/cortexproject/cortex/pkg/util"
@@ -46,6 +48,15 @@ const trackerTpl = `
 				{{ end }}
 			</tbody>
 		</table>
+
+		<h2>Force elect replica</h2>
+		<p>Elect the replica for the HA cluster, regardless of the currently elected one. The elected replica still fails over if it doesn't send any sample within the failover timeout.</p>
+		<form action="" method="POST">
+			<label for="user">User ID</label> <input type="text" id="user" name="user">
+			<label for="cluster">Cluster</label> <input type="text" id="cluster" name="cluster">
+			<label for="replica">Replica</label> <input type="text" id="replica" name="replica">
+			<input type="submit" value="Elect">
+		</form>
 	</body>
 </html>`
 
@@ -56,6 +67,11 @@ func init() {
 }
 
 func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodPost {
+		h.forceElectReplicaHandler(w, req)
+		return
+	}
+
 	h.electedLock.RLock()
 	type replica struct {
 		UserID       string        `json:"userID"`
@@ -76,7 +92,7 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			Replica:      desc.Replica,
 			ElectedAt:    timestamp.Time(desc.ReceivedAt),
 			UpdateTime:   time.Until(timestamp.Time(desc.ReceivedAt).Add(h.cfg.UpdateTimeout)),
-			FailoverTime: time.Until(timestamp.Time(desc.ReceivedAt).Add(h.cfg.FailoverTimeout)),
+			FailoverTime: time.Until(timestamp.Time(desc.ReceivedAt).Add(h.failoverTimeout(chunks[0]))),
 		})
 	}
 	h.electedLock.RUnlock()
@@ -99,3 +115,28 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		Now:     time.Now(),
 	}, trackerTmpl, req)
 }
+
+// forceElectReplicaHandler elects the replica of the HA cluster specified in the request form.
+func (h *haTracker) forceElectReplicaHandler(w http.ResponseWriter, req *http.Request) {
+	userID := req.FormValue("user")
+	cluster := req.FormValue("cluster")
+	replica := req.FormValue("replica")
+	if userID == "" || cluster == "" || replica == "" {
+		http.Error(w, "the user, cluster and replica are required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.forceElectReplica(req.Context(), userID, cluster, replica); err != nil {
+		if errors.Is(err, errHATrackerDisabled) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		level.Error(h.logger).Log("msg", "failed to force elect HA replica", "user", userID, "cluster", cluster, "replica", replica, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Redirect to the status page, which will show the elected replica once propagated.
+	http.Redirect(w, req, req.URL.Path, http.StatusSeeOther)
+}
@@ -3,9 +3,14 @@ package distributor
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-kit/kit/log"
 	"github.com/prometheus/prometheus/pkg/timestamp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +20,9 @@ import (
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
 	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/ring/kv/memberlist"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
@@ -135,7 +142,7 @@ func TestWatchPrefixAssignment(t *testing.T) {
 		UpdateTimeout:          time.Millisecond,
 		UpdateTimeoutJitterMax: 0,
 		FailoverTimeout:        time.Millisecond * 2,
-	}, nil)
+	}, trackerLimits{}, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -165,7 +172,7 @@ func TestCheckReplicaOverwriteTimeout(t *testing.T) {
 		UpdateTimeout:          100 * time.Millisecond,
 		UpdateTimeoutJitterMax: 0,
 		FailoverTimeout:        time.Second,
-	}, nil)
+	}, trackerLimits{}, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -190,6 +197,255 @@ func TestCheckReplicaOverwriteTimeout(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestCheckReplicaShouldHonorPerTenantFailoverTimeout(t *testing.T) {
+	replica1 := "replica1"
+	replica2 := "replica2"
+	start := mtime.Now()
+	defer mtime.NowReset()
+
+	codec := GetReplicaDescCodec()
+	mock := kv.PrefixClient(consul.NewInMemoryClient(codec), "prefix")
+	c, err := newClusterTracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Mock: mock},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{failoverTimeout: 5 * time.Second}, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	// Write the first time.
+	mtime.NowForce(start)
+	err = c.checkReplica(context.Background(), "user", "test", replica1)
+	assert.NoError(t, err)
+
+	// Wait more than the default failover timeout, but less than the tenant's one.
+	mtime.NowForce(start.Add(1100 * time.Millisecond))
+
+	// Throw away a sample from replica2.
+	err = c.checkReplica(context.Background(), "user", "test", replica2)
+	assert.Error(t, err)
+
+	// Wait more than the tenant's failover timeout.
+	mtime.NowForce(start.Add(5100 * time.Millisecond))
+
+	// Accept from replica 2, this should overwrite the saved replica of replica 1.
+	err = c.checkReplica(context.Background(), "user", "test", replica2)
+	assert.NoError(t, err)
+}
+
+func TestHATracker_failoverTimeout(t *testing.T) {
+	cfg := HATrackerConfig{
+		UpdateTimeout:          10 * time.Second,
+		UpdateTimeoutJitterMax: 5 * time.Second,
+		FailoverTimeout:        30 * time.Second,
+	}
+
+	tests := map[string]struct {
+		tenantTimeout time.Duration
+		expected      time.Duration
+	}{
+		"should use the default failover timeout if the tenant's one is not set": {
+			tenantTimeout: 0,
+			expected:      30 * time.Second,
+		},
+		"should use the tenant's failover timeout if set": {
+			tenantTimeout: time.Minute,
+			expected:      time.Minute,
+		},
+		"should raise the tenant's failover timeout to the minimum allowed": {
+			tenantTimeout: time.Second,
+			expected:      16 * time.Second,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			c, err := newClusterTracker(cfg, trackerLimits{failoverTimeout: testData.tenantTimeout}, nil)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expected, c.failoverTimeout("user"))
+		})
+	}
+}
+
+func TestHATracker_ForceElectReplicaHandler(t *testing.T) {
+	cluster := "c1"
+	replica1 := "r1"
+	replica2 := "r2"
+	start := mtime.Now()
+	defer mtime.NowReset()
+
+	codec := GetReplicaDescCodec()
+	mock := kv.PrefixClient(consul.NewInMemoryClient(codec), "prefix")
+	c, err := newClusterTracker(HATrackerConfig{
+		EnableHATracker:        true,
+		KVStore:                kv.Config{Mock: mock},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	// Elect the first replica.
+	mtime.NowForce(start)
+	require.NoError(t, c.checkReplica(context.Background(), "user", cluster, replica1))
+
+	// A request missing any of the required fields should be rejected.
+	form := url.Values{"user": {"user"}, "cluster": {cluster}}
+	req := httptest.NewRequest(http.MethodPost, "/distributor/ha_tracker", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Force elect the second replica.
+	mtime.NowForce(start.Add(10 * time.Millisecond))
+	form.Set("replica", replica2)
+	req = httptest.NewRequest(http.MethodPost, "/distributor/ha_tracker", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+	// Wait until the elected replica has been propagated.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, checkReplicaTimestamp(ctx, c, "user", cluster, replica2, start.Add(10*time.Millisecond)))
+
+	// Samples from the second replica should be accepted, while the first replica's ones rejected.
+	assert.NoError(t, c.checkReplica(context.Background(), "user", cluster, replica2))
+	assert.Error(t, c.checkReplica(context.Background(), "user", cluster, replica1))
+}
+
+func TestHATracker_ForceElectReplicaHandler_ShouldFailIfTrackerIsDisabled(t *testing.T) {
+	c, err := newClusterTracker(HATrackerConfig{EnableHATracker: false}, trackerLimits{}, nil)
+	require.NoError(t, err)
+
+	form := url.Values{"user": {"user"}, "cluster": {"c1"}, "replica": {"r1"}}
+	req := httptest.NewRequest(http.MethodPost, "/distributor/ha_tracker", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestReplicaDesc_Merge(t *testing.T) {
+	tests := map[string]struct {
+		local           *ReplicaDesc
+		incoming        *ReplicaDesc
+		expectedLocal   *ReplicaDesc
+		expectedChanged bool
+	}{
+		"should not change on nil incoming value": {
+			local:         &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			incoming:      nil,
+			expectedLocal: &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+		},
+		"should not change on older incoming value": {
+			local:         &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			incoming:      &ReplicaDesc{Replica: "r2", ReceivedAt: 5},
+			expectedLocal: &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+		},
+		"should not change on same incoming value": {
+			local:         &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			incoming:      &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			expectedLocal: &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+		},
+		"should change on newer incoming value": {
+			local:           &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			incoming:        &ReplicaDesc{Replica: "r2", ReceivedAt: 15},
+			expectedLocal:   &ReplicaDesc{Replica: "r2", ReceivedAt: 15},
+			expectedChanged: true,
+		},
+		"should change on the same timestamp if the incoming replica wins the tie": {
+			local:           &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			incoming:        &ReplicaDesc{Replica: "r2", ReceivedAt: 10},
+			expectedLocal:   &ReplicaDesc{Replica: "r2", ReceivedAt: 10},
+			expectedChanged: true,
+		},
+		"should not change on the same timestamp if the local replica wins the tie": {
+			local:         &ReplicaDesc{Replica: "r2", ReceivedAt: 10},
+			incoming:      &ReplicaDesc{Replica: "r1", ReceivedAt: 10},
+			expectedLocal: &ReplicaDesc{Replica: "r2", ReceivedAt: 10},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var incoming memberlist.Mergeable
+			if testData.incoming != nil {
+				incoming = testData.incoming
+			}
+
+			change, err := testData.local.Merge(incoming, false)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedLocal, testData.local)
+
+			if testData.expectedChanged {
+				assert.Equal(t, testData.expectedLocal, change)
+			} else {
+				assert.Nil(t, change)
+			}
+		})
+	}
+}
+
+func TestHATrackerWithMemberlist(t *testing.T) {
+	replica1 := "replica1"
+	replica2 := "replica2"
+	start := mtime.Now()
+	defer mtime.NowReset()
+
+	mkv := memberlist.NewKV(memberlist.KVConfig{
+		TCPTransport: memberlist.TCPTransportConfig{},
+		Codecs:       []codec.Codec{GetReplicaDescCodec()},
+	}, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv) //nolint:errcheck
+
+	c, err := newClusterTracker(HATrackerConfig{
+		EnableHATracker: true,
+		KVStore: kv.Config{
+			Store:  "memberlist",
+			Prefix: "ha-tracker/",
+			StoreConfig: kv.StoreConfig{
+				MemberlistKV: func() (*memberlist.KV, error) { return mkv, nil },
+			},
+		},
+		UpdateTimeout:          100 * time.Millisecond,
+		UpdateTimeoutJitterMax: 0,
+		FailoverTimeout:        time.Second,
+	}, trackerLimits{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+	// Elect the first replica.
+	mtime.NowForce(start)
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica1))
+
+	// The elected replica should be propagated to the tracker via the watch.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	require.NoError(t, checkReplicaTimestamp(ctx, c, "user", "test", replica1, start))
+
+	// Throw away a sample from replica2, after the update timeout but before the failover timeout.
+	mtime.NowForce(start.Add(500 * time.Millisecond))
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica2))
+
+	// Wait more than the failover timeout, then accept from replica2.
+	mtime.NowForce(start.Add(1100 * time.Millisecond))
+	require.NoError(t, c.checkReplica(context.Background(), "user", "test", replica2))
+	require.NoError(t, checkReplicaTimestamp(ctx, c, "user", "test", replica2, start.Add(1100*time.Millisecond)))
+
+	// We timed out accepting samples from replica 1 and should now reject them.
+	assert.Error(t, c.checkReplica(context.Background(), "user", "test", replica1))
+}
+
 func TestCheckReplicaMultiCluster(t *testing.T) {
 	replica1 := "replica1"
 	replica2 := "replica2"
@@ -200,7 +456,7 @@ func TestCheckReplicaMultiCluster(t *testing.T) {
 		UpdateTimeout:          100 * time.Millisecond,
 		UpdateTimeoutJitterMax: 0,
 		FailoverTimeout:        time.Second,
-	}, nil)
+	}, trackerLimits{}, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -235,7 +491,7 @@ func TestCheckReplicaMultiClusterTimeout(t *testing.T) {
 		UpdateTimeout:          100 * time.Millisecond,
 		UpdateTimeoutJitterMax: 0,
 		FailoverTimeout:        time.Second,
-	}, nil)
+	}, trackerLimits{}, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -286,7 +542,7 @@ func TestCheckReplicaUpdateTimeout(t *testing.T) {
 		UpdateTimeout:          time.Second,
 		UpdateTimeoutJitterMax: 0,
 		FailoverTimeout:        time.Second,
-	}, nil)
+	}, trackerLimits{}, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -347,7 +603,7 @@ func TestCheckReplicaMultiUser(t *testing.T) {
 		UpdateTimeout:          100 * time.Millisecond,
 		UpdateTimeoutJitterMax: 0,
 		FailoverTimeout:        time.Second,
-	}, nil)
+	}, trackerLimits{}, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 	defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -430,7 +686,7 @@ func TestCheckReplicaUpdateTimeoutJitter(t *testing.T) {
 				UpdateTimeout:          testData.updateTimeout,
 				UpdateTimeoutJitterMax: 0,
 				FailoverTimeout:        time.Second,
-			}, nil)
+			}, trackerLimits{}, nil)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
 			defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
@@ -518,3 +774,11 @@ func TestHATrackerConfig_ShouldCustomizePrefixDefaultValue(t *testing.T) {
 	assert.Equal(t, "ha-tracker/", haConfig.KVStore.Prefix)
 	assert.NotEqual(t, haConfig.KVStore.Prefix, ringConfig.KVStore.Prefix)
 }
+
+type trackerLimits struct {
+	failoverTimeout time.Duration
+}
+
+func (l trackerLimits) HATrackerFailoverTimeout(_ string) time.Duration {
+	return l.failoverTimeout
+}
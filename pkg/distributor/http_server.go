@@ -1,7 +1,13 @@
 package distributor
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
 
 	"github.com/cortexproject/cortex/pkg/util"
 )
@@ -24,3 +30,91 @@ func (d *Distributor) UserStatsHandler(w http.ResponseWriter, r *http.Request) {
 
 	util.WriteJSONResponse(w, stats)
 }
+
+const defaultCardinalityLimit = 20
+
+// LabelNamesCardinalityResponse is the response of the label names cardinality API.
+type LabelNamesCardinalityResponse struct {
+	SeriesCountTotal uint64                 `json:"series_count_total"`
+	LabelNames       []LabelNameCardinality `json:"label_names"`
+}
+
+// LabelValuesCardinalityResponse is the response of the label values cardinality API.
+type LabelValuesCardinalityResponse struct {
+	SeriesCountTotal uint64                  `json:"series_count_total"`
+	LabelName        string                  `json:"label_name"`
+	LabelValues      []LabelValueCardinality `json:"label_values"`
+}
+
+// LabelNamesCardinalityHandler returns the label names with the highest number of in-memory series.
+func (d *Distributor) LabelNamesCardinalityHandler(w http.ResponseWriter, r *http.Request) {
+	matchers, limit, err := parseCardinalityRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, total, err := d.LabelNamesCardinality(r.Context(), limit, matchers...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, LabelNamesCardinalityResponse{
+		SeriesCountTotal: total,
+		LabelNames:       result,
+	})
+}
+
+// LabelValuesCardinalityHandler returns the values of a label name with the highest number of in-memory series.
+func (d *Distributor) LabelValuesCardinalityHandler(w http.ResponseWriter, r *http.Request) {
+	matchers, limit, err := parseCardinalityRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	labelName := r.FormValue("label_name")
+	if !model.LabelName(labelName).IsValid() {
+		http.Error(w, fmt.Sprintf("invalid label_name: %q", labelName), http.StatusBadRequest)
+		return
+	}
+
+	result, total, err := d.LabelValuesCardinality(r.Context(), labelName, limit, matchers...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, LabelValuesCardinalityResponse{
+		SeriesCountTotal: total,
+		LabelName:        labelName,
+		LabelValues:      result,
+	})
+}
+
+// parseCardinalityRequest parses the optional series selector and the limit of a cardinality request.
+func parseCardinalityRequest(r *http.Request) ([]*labels.Matcher, int, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, 0, err
+	}
+
+	// By default, all series are selected.
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, labels.MetricName, "")}
+	if selector := r.FormValue("selector"); selector != "" {
+		var err error
+		if matchers, err = parser.ParseMetricSelector(selector); err != nil {
+			return nil, 0, fmt.Errorf("invalid selector: %s", err.Error())
+		}
+	}
+
+	limit := defaultCardinalityLimit
+	if value := r.FormValue("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit <= 0 {
+			return nil, 0, fmt.Errorf("invalid limit: %q, it must be a positive integer", value)
+		}
+	}
+
+	return matchers, limit, nil
+}
@@ -0,0 +1,65 @@
+package distributor
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/weaveworks/common/httpgrpc"
+)
+
+// partialErrors collects the non-fatal errors (ie. validation errors) of a push request.
+// By default, only the first error is returned to the client. If configured to report
+// more errors, a summary of up to maxReported errors is returned instead.
+type partialErrors struct {
+	maxReported int
+	errs        []error
+	total       int
+}
+
+func newPartialErrors(maxReported int) *partialErrors {
+	return &partialErrors{maxReported: maxReported}
+}
+
+func (p *partialErrors) add(err error) {
+	if err == nil {
+		return
+	}
+
+	p.total++
+	if len(p.errs) < p.maxReported || len(p.errs) == 0 {
+		p.errs = append(p.errs, err)
+	}
+}
+
+// err returns the error to return to the client, or nil if no error has been collected.
+func (p *partialErrors) err() error {
+	if p.total == 0 {
+		return nil
+	}
+
+	// Keep the original error as is if there's nothing to summarize.
+	if p.total == 1 || p.maxReported <= 1 {
+		return p.errs[0]
+	}
+
+	// The summary is returned with the status code of the first error, which is a 4xx for validation errors.
+	code := int32(http.StatusBadRequest)
+	if resp, ok := httpgrpc.HTTPResponseFromError(p.errs[0]); ok {
+		code = resp.GetCode()
+	}
+
+	msgs := make([]string, 0, len(p.errs))
+	for _, err := range p.errs {
+		msg := err.Error()
+		if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+			msg = string(resp.GetBody())
+		}
+		msgs = append(msgs, msg)
+	}
+
+	summary := strings.Join(msgs, "; ")
+	if omitted := p.total - len(p.errs); omitted > 0 {
+		return httpgrpc.Errorf(int(code), "%d errors in the request (showing the first %d): %s", p.total, len(p.errs), summary)
+	}
+	return httpgrpc.Errorf(int(code), "%d errors in the request: %s", p.total, summary)
+}
@@ -84,11 +84,8 @@ func (d *Distributor) GetIngestersForQuery(ctx context.Context, matchers ...*lab
 	// If shuffle sharding is enabled we should only query ingesters which are
 	// part of the tenant's subring.
 	if d.cfg.ShardingStrategy == util.ShardingStrategyShuffle {
-		shardSize := d.limits.IngestionTenantShardSize(userID)
-		lookbackPeriod := d.cfg.ShuffleShardingLookbackPeriod
-
-		if shardSize > 0 && lookbackPeriod > 0 {
-			return d.ingestersRing.ShuffleShardWithLookback(userID, shardSize, lookbackPeriod, time.Now()).GetReplicationSetForOperation(ring.Read)
+		if subRing := d.getIngestersShardForQuery(userID); subRing != nil {
+			return subRing.GetReplicationSetForOperation(ring.Read)
 		}
 	}
 
@@ -115,17 +112,32 @@ func (d *Distributor) GetIngestersForMetadata(ctx context.Context) (ring.Replica
 	// If shuffle sharding is enabled we should only query ingesters which are
 	// part of the tenant's subring.
 	if d.cfg.ShardingStrategy == util.ShardingStrategyShuffle {
-		shardSize := d.limits.IngestionTenantShardSize(userID)
-		lookbackPeriod := d.cfg.ShuffleShardingLookbackPeriod
-
-		if shardSize > 0 && lookbackPeriod > 0 {
-			return d.ingestersRing.ShuffleShardWithLookback(userID, shardSize, lookbackPeriod, time.Now()).GetReplicationSetForOperation(ring.Read)
+		if subRing := d.getIngestersShardForQuery(userID); subRing != nil {
+			return subRing.GetReplicationSetForOperation(ring.Read)
 		}
 	}
 
 	return d.ingestersRing.GetReplicationSetForOperation(ring.Read)
 }
 
+// getIngestersShardForQuery returns the subring of the ingesters which may have received series
+// of the tenant since "now - lookback period", or nil if all the ingesters should be queried.
+func (d *Distributor) getIngestersShardForQuery(userID string) ring.ReadRing {
+	lookbackPeriod := d.cfg.ShuffleShardingLookbackPeriod
+	if lookbackPeriod <= 0 {
+		return nil
+	}
+
+	// Take in account the shard size changes occurred during the lookback period.
+	now := time.Now()
+	shardSize := d.shardSizes.maxShardSize(userID, d.limits.IngestionTenantShardSize(userID), lookbackPeriod, now)
+	if shardSize <= 0 {
+		return nil
+	}
+
+	return d.ingestersRing.ShuffleShardWithLookback(userID, shardSize, lookbackPeriod, now)
+}
+
 // queryIngesters queries the ingesters via the older, sample-based API.
 func (d *Distributor) queryIngesters(ctx context.Context, replicationSet ring.ReplicationSet, req *client.QueryRequest) (model.Matrix, error) {
 	// Fetch samples from multiple ingesters in parallel, using the replicationSet
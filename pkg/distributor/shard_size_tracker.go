@@ -0,0 +1,93 @@
+package distributor
+
+import (
+	"sync"
+	"time"
+)
+
+// shardSizeTracker keeps track of the ingesters shard sizes of each tenant observed over
+// the shuffle sharding lookback period. Since the shard of a given size is a superset of
+// the shards of any smaller size, querying the shard of the max observed size guarantees
+// that the ingesters which have been part of the tenant's shard before its size has been
+// decreased are still queried, as far as the previous size has been observed.
+type shardSizeTracker struct {
+	mtx sync.Mutex
+
+	// Shard sizes observed for each tenant, keyed by tenant ID.
+	history map[string][]shardSizeSample
+
+	// Last time the tenants not queried within the lookback period have been removed.
+	lastPruned time.Time
+}
+
+// shardSizeTrackerPruneInterval is how frequently the history of the tenants not queried
+// within the lookback period is removed.
+const shardSizeTrackerPruneInterval = time.Minute
+
+type shardSizeSample struct {
+	size     int
+	lastSeen time.Time
+}
+
+func newShardSizeTracker() *shardSizeTracker {
+	return &shardSizeTracker{
+		history: map[string][]shardSizeSample{},
+	}
+}
+
+// maxShardSize records the current shard size of the tenant and returns the max shard size
+// observed since "now - lookbackPeriod". A shard size of 0 (shuffle sharding disabled for the
+// tenant) takes precedence over any other size, since all the ingesters may have tenant's data.
+func (t *shardSizeTracker) maxShardSize(userID string, current int, lookbackPeriod time.Duration, now time.Time) int {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	minLastSeen := now.Add(-lookbackPeriod)
+	if now.Sub(t.lastPruned) >= shardSizeTrackerPruneInterval {
+		t.prune(minLastSeen)
+		t.lastPruned = now
+	}
+
+	samples := removeSamplesBefore(t.history[userID], minLastSeen)
+
+	if last := len(samples) - 1; last >= 0 && samples[last].size == current {
+		samples[last].lastSeen = now
+	} else {
+		samples = append(samples, shardSizeSample{size: current, lastSeen: now})
+	}
+
+	t.history[userID] = samples
+
+	result := current
+	for _, sample := range samples {
+		if sample.size <= 0 {
+			return 0
+		}
+		if sample.size > result {
+			result = sample.size
+		}
+	}
+
+	return result
+}
+
+// prune removes the tenants whose shard sizes have not been observed since minLastSeen.
+// Must be called with the lock held.
+func (t *shardSizeTracker) prune(minLastSeen time.Time) {
+	for userID, samples := range t.history {
+		if samples = removeSamplesBefore(samples, minLastSeen); len(samples) == 0 {
+			delete(t.history, userID)
+		} else {
+			t.history[userID] = samples
+		}
+	}
+}
+
+// removeSamplesBefore removes the samples not observed since minLastSeen. The samples
+// are sorted by lastSeen.
+func removeSamplesBefore(samples []shardSizeSample, minLastSeen time.Time) []shardSizeSample {
+	for len(samples) > 0 && samples[0].lastSeen.Before(minLastSeen) {
+		samples = samples[1:]
+	}
+	return samples
+}
@@ -0,0 +1,51 @@
+package distributor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShardSizeTracker_MaxShardSize(t *testing.T) {
+	const lookback = time.Hour
+	now := time.Now()
+	tracker := newShardSizeTracker()
+
+	assert.Equal(t, 3, tracker.maxShardSize("user-1", 3, lookback, now))
+
+	// The shard size has been increased.
+	assert.Equal(t, 6, tracker.maxShardSize("user-1", 6, lookback, now.Add(10*time.Minute)))
+
+	// The shard size has been decreased: the larger shard is still queried during the lookback period.
+	assert.Equal(t, 6, tracker.maxShardSize("user-1", 3, lookback, now.Add(20*time.Minute)))
+	assert.Equal(t, 6, tracker.maxShardSize("user-1", 3, lookback, now.Add(70*time.Minute)))
+	assert.Equal(t, 3, tracker.maxShardSize("user-1", 3, lookback, now.Add(80*time.Minute)))
+
+	// Other tenants are not affected.
+	assert.Equal(t, 2, tracker.maxShardSize("user-2", 2, lookback, now.Add(80*time.Minute)))
+
+	// Shuffle sharding has been disabled and then re-enabled for the tenant: all the ingesters
+	// should be queried during the lookback period.
+	assert.Equal(t, 0, tracker.maxShardSize("user-1", 0, lookback, now.Add(90*time.Minute)))
+	assert.Equal(t, 0, tracker.maxShardSize("user-1", 3, lookback, now.Add(100*time.Minute)))
+	assert.Equal(t, 3, tracker.maxShardSize("user-1", 3, lookback, now.Add(160*time.Minute)))
+}
+
+func TestShardSizeTracker_ShouldRemoveIdleTenants(t *testing.T) {
+	const lookback = time.Hour
+	now := time.Now()
+	tracker := newShardSizeTracker()
+
+	assert.Equal(t, 3, tracker.maxShardSize("user-1", 3, lookback, now))
+	assert.Equal(t, 6, tracker.maxShardSize("user-2", 6, lookback, now.Add(30*time.Minute)))
+	assert.Len(t, tracker.history, 2)
+
+	// The idle tenant is removed once its shard size has not been observed for the lookback period.
+	assert.Equal(t, 6, tracker.maxShardSize("user-2", 6, lookback, now.Add(59*time.Minute)))
+	assert.Len(t, tracker.history, 2)
+
+	assert.Equal(t, 6, tracker.maxShardSize("user-2", 6, lookback, now.Add(61*time.Minute)))
+	assert.Len(t, tracker.history, 1)
+	assert.Contains(t, tracker.history, "user-2")
+}
@@ -8,9 +8,11 @@ import (
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/cortexproject/cortex/pkg/frontend/federation"
 	"github.com/cortexproject/cortex/pkg/frontend/transport"
 	v1 "github.com/cortexproject/cortex/pkg/frontend/v1"
 	v2 "github.com/cortexproject/cortex/pkg/frontend/v2"
+	"github.com/cortexproject/cortex/pkg/scheduler/schedulerdiscovery"
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
@@ -22,19 +24,30 @@ type CombinedFrontendConfig struct {
 
 	CompressResponses bool   `yaml:"compress_responses"`
 	DownstreamURL     string `yaml:"downstream_url"`
+
+	Federation federation.Config `yaml:"federation"`
 }
 
 func (cfg *CombinedFrontendConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.Handler.RegisterFlags(f)
 	cfg.FrontendV1.RegisterFlags(f)
 	cfg.FrontendV2.RegisterFlags(f)
+	cfg.Federation.RegisterFlags(f)
 
 	f.BoolVar(&cfg.CompressResponses, "querier.compress-http-responses", false, "Compress HTTP responses.")
 	f.StringVar(&cfg.DownstreamURL, "frontend.downstream-url", "", "URL of downstream Prometheus.")
 }
 
+// Validate the config and returns an error if the validation fails.
+func (cfg *CombinedFrontendConfig) Validate() error {
+	if cfg.DownstreamURL != "" && cfg.Federation.Enabled() {
+		return errors.New("the downstream URL and the query federation can't be configured together")
+	}
+	return errors.Wrap(cfg.Federation.Validate(), "invalid federation config")
+}
+
 // Initializes frontend (either V1 -- without scheduler, or V2 -- with scheduler) or no frontend at
-// all if downstream Prometheus URL is used instead.
+// all if downstream Prometheus URL or the query federation across remote clusters is used instead.
 //
 // Returned RoundTripper can be wrapped in more round-tripper middlewares, and then eventually registered
 // into HTTP server using the Handler from this package. Returned RoundTripper is always non-nil
@@ -46,8 +59,13 @@ func InitFrontend(cfg CombinedFrontendConfig, limits v1.Limits, grpcListenPort i
 		rt, err := NewDownstreamRoundTripper(cfg.DownstreamURL)
 		return rt, nil, nil, err
 
-	case cfg.FrontendV2.SchedulerAddress != "":
-		// If query-scheduler address is configured, use Frontend.
+	case cfg.Federation.Enabled():
+		// If remote clusters are configured, federate the queries across them.
+		rt, err := federation.NewRoundTripper(cfg.Federation, log, reg)
+		return rt, nil, nil, err
+
+	case cfg.FrontendV2.SchedulerAddress != "" || cfg.FrontendV2.QuerySchedulerDiscovery.Mode == schedulerdiscovery.ModeRing:
+		// If query-scheduler address or ring-based discovery is configured, use Frontend.
 		if cfg.FrontendV2.Addr == "" {
 			addr, err := util.GetFirstAddressOf(cfg.FrontendV2.InfNames)
 			if err != nil {
@@ -0,0 +1,72 @@
+package federation
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/util/tls"
+)
+
+var (
+	errMissingClusterName  = errors.New("the name of a federated cluster must not be empty")
+	errMissingClusterLabel = errors.New("the cluster label must not be empty")
+)
+
+// Config configures the federation of the queries across remote Cortex clusters.
+type Config struct {
+	ClusterLabel string           `yaml:"cluster_label"`
+	Clusters     []*ClusterConfig `yaml:"clusters" doc:"nocli|description=List of remote Cortex clusters the query-frontend federates the queries to. Each entry has a 'name' (the value of the cluster label added to the series of the cluster), a 'url' (the base URL of the cluster's Prometheus API, e.g. 'https://cortex-eu.example.com/prometheus'), an optional 'tenant_mapping' (map of local tenant ID to the tenant ID to query in the cluster; tenants not in the map are queried with the same ID) and the optional client TLS options (tls_cert_path, tls_key_path, tls_ca_path, tls_insecure_skip_verify). If empty, the query federation is disabled."`
+}
+
+// ClusterConfig configures a remote Cortex cluster of the query federation.
+type ClusterConfig struct {
+	Name          string            `yaml:"name"`
+	URL           string            `yaml:"url"`
+	TenantMapping map[string]string `yaml:"tenant_mapping"`
+	TLS           tls.ClientConfig  `yaml:",inline"`
+}
+
+// RegisterFlags registers the flags for the query federation config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.ClusterLabel, "frontend.federation.cluster-label", "cluster", "Name of the label added to the series returned by a federated query, holding the name of the cluster the series belongs to. The label overrides any label with the same name in the series of the remote clusters.")
+}
+
+// Enabled returns whether the queries are federated across remote clusters.
+func (cfg *Config) Enabled() bool {
+	return len(cfg.Clusters) > 0
+}
+
+// Validate the config and returns an error if the validation fails.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled() {
+		return nil
+	}
+
+	if cfg.ClusterLabel == "" {
+		return errMissingClusterLabel
+	}
+
+	names := map[string]struct{}{}
+	for _, c := range cfg.Clusters {
+		if c.Name == "" {
+			return errMissingClusterName
+		}
+		if _, ok := names[c.Name]; ok {
+			return fmt.Errorf("the federated cluster %s is configured more than once", c.Name)
+		}
+		names[c.Name] = struct{}{}
+
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return errors.Wrapf(err, "invalid URL of the federated cluster %s", c.Name)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid URL of the federated cluster %s: the scheme and host must be set", c.Name)
+		}
+	}
+
+	return nil
+}
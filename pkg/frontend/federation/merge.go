@@ -0,0 +1,275 @@
+package federation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+const (
+	statusSuccess = "success"
+	statusError   = "error"
+
+	errorTypeBadData  = "bad_data"
+	errorTypeInternal = "internal"
+)
+
+var labelValuesPathRegexp = regexp.MustCompile(`/label/([^/]+)/values$`)
+
+// mergeFunc merges the successful responses of the remote clusters.
+type mergeFunc func(responses []*clusterResponse) *http.Response
+
+// apiResponse is the envelope of the Prometheus API responses.
+type apiResponse struct {
+	Status    string      `json:"status"`
+	Data      interface{} `json:"data,omitempty"`
+	ErrorType string      `json:"errorType,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Warnings  []string    `json:"warnings,omitempty"`
+}
+
+type queryData struct {
+	ResultType string            `json:"resultType"`
+	Result     []json.RawMessage `json:"result"`
+}
+
+// mergeFuncFor returns the function merging the responses of the API endpoint
+// at the input path, or false if the endpoint can't be federated.
+func mergeFuncFor(path, clusterLabel string) (mergeFunc, bool) {
+	switch {
+	case strings.HasSuffix(path, "/query_range"), strings.HasSuffix(path, "/query"):
+		return func(responses []*clusterResponse) *http.Response {
+			return mergeResponses(responses, func(data []json.RawMessage) (interface{}, error) {
+				return mergeQueryData(responses, data, clusterLabel)
+			})
+		}, true
+
+	case strings.HasSuffix(path, "/series"):
+		return func(responses []*clusterResponse) *http.Response {
+			return mergeResponses(responses, func(data []json.RawMessage) (interface{}, error) {
+				return mergeSeriesData(responses, data, clusterLabel)
+			})
+		}, true
+
+	case strings.HasSuffix(path, "/labels"):
+		return func(responses []*clusterResponse) *http.Response {
+			return mergeResponses(responses, func(data []json.RawMessage) (interface{}, error) {
+				return mergeStringsData(data, clusterLabel)
+			})
+		}, true
+
+	case labelValuesPathRegexp.MatchString(path):
+		name := labelValuesPathRegexp.FindStringSubmatch(path)[1]
+		return func(responses []*clusterResponse) *http.Response {
+			// The values of the cluster label are the names of the clusters.
+			if name == clusterLabel {
+				clusters := make([]string, 0, len(responses))
+				for _, resp := range responses {
+					clusters = append(clusters, resp.cluster)
+				}
+				sort.Strings(clusters)
+				return jsonResponse(http.StatusOK, apiResponse{Status: statusSuccess, Data: clusters})
+			}
+
+			return mergeResponses(responses, func(data []json.RawMessage) (interface{}, error) {
+				return mergeStringsData(data)
+			})
+		}, true
+	}
+
+	return nil, false
+}
+
+// mergeResponses decodes the envelope of the responses and merges their data
+// with the input function. The warnings are annotated with the cluster name.
+func mergeResponses(responses []*clusterResponse, mergeData func([]json.RawMessage) (interface{}, error)) *http.Response {
+	var warnings []string
+	data := make([]json.RawMessage, 0, len(responses))
+
+	for _, resp := range responses {
+		var envelope struct {
+			Status   string          `json:"status"`
+			Data     json.RawMessage `json:"data"`
+			Warnings []string        `json:"warnings"`
+		}
+		if err := json.Unmarshal(resp.body, &envelope); err != nil {
+			return errorResponse(http.StatusInternalServerError, fmt.Sprintf("failed to decode the response of the federated cluster %s: %s", resp.cluster, err))
+		}
+		if envelope.Status != statusSuccess {
+			return errorResponse(http.StatusInternalServerError, fmt.Sprintf("unexpected status %q in the response of the federated cluster %s", envelope.Status, resp.cluster))
+		}
+
+		data = append(data, envelope.Data)
+		for _, w := range envelope.Warnings {
+			warnings = append(warnings, fmt.Sprintf("cluster %s: %s", resp.cluster, w))
+		}
+	}
+
+	merged, err := mergeData(data)
+	if err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error())
+	}
+
+	return jsonResponse(http.StatusOK, apiResponse{
+		Status:   statusSuccess,
+		Data:     merged,
+		Warnings: warnings,
+	})
+}
+
+// mergeQueryData merges the vectors or matrices returned by the clusters, adding
+// the cluster label to each series. The series of a matrix are sorted by labels.
+func mergeQueryData(responses []*clusterResponse, data []json.RawMessage, clusterLabel string) (interface{}, error) {
+	type labeledSeries struct {
+		labels labels.Labels
+		raw    json.RawMessage
+	}
+
+	var (
+		resultType string
+		series     []labeledSeries
+	)
+
+	for i, d := range data {
+		var qd queryData
+		if err := json.Unmarshal(d, &qd); err != nil {
+			return nil, fmt.Errorf("failed to decode the query result of the federated cluster %s: %s", responses[i].cluster, err)
+		}
+
+		if qd.ResultType != "vector" && qd.ResultType != "matrix" {
+			return nil, fmt.Errorf("the query result type %q can't be federated across clusters", qd.ResultType)
+		}
+		if resultType != "" && qd.ResultType != resultType {
+			return nil, fmt.Errorf("the federated clusters returned different query result types (%s and %s)", resultType, qd.ResultType)
+		}
+		resultType = qd.ResultType
+
+		for _, raw := range qd.Result {
+			var s map[string]json.RawMessage
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return nil, fmt.Errorf("failed to decode a series of the federated cluster %s: %s", responses[i].cluster, err)
+			}
+
+			metric := map[string]string{}
+			if m, ok := s["metric"]; ok {
+				if err := json.Unmarshal(m, &metric); err != nil {
+					return nil, fmt.Errorf("failed to decode a series of the federated cluster %s: %s", responses[i].cluster, err)
+				}
+			}
+			metric[clusterLabel] = responses[i].cluster
+
+			encodedMetric, err := json.Marshal(metric)
+			if err != nil {
+				return nil, err
+			}
+			s["metric"] = encodedMetric
+
+			encoded, err := json.Marshal(s)
+			if err != nil {
+				return nil, err
+			}
+			series = append(series, labeledSeries{labels: labels.FromMap(metric), raw: encoded})
+		}
+	}
+
+	if resultType == "matrix" {
+		sort.SliceStable(series, func(i, j int) bool {
+			return labels.Compare(series[i].labels, series[j].labels) < 0
+		})
+	}
+
+	merged := queryData{ResultType: resultType, Result: make([]json.RawMessage, 0, len(series))}
+	for _, s := range series {
+		merged.Result = append(merged.Result, s.raw)
+	}
+	return merged, nil
+}
+
+// mergeSeriesData merges the label sets returned by the clusters, adding the
+// cluster label to each of them.
+func mergeSeriesData(responses []*clusterResponse, data []json.RawMessage, clusterLabel string) (interface{}, error) {
+	var series []labels.Labels
+
+	for i, d := range data {
+		var sets []map[string]string
+		if err := json.Unmarshal(d, &sets); err != nil {
+			return nil, fmt.Errorf("failed to decode the series of the federated cluster %s: %s", responses[i].cluster, err)
+		}
+
+		for _, set := range sets {
+			set[clusterLabel] = responses[i].cluster
+			series = append(series, labels.FromMap(set))
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return labels.Compare(series[i], series[j]) < 0
+	})
+
+	merged := make([]map[string]string, 0, len(series))
+	for _, s := range series {
+		merged = append(merged, s.Map())
+	}
+	return merged, nil
+}
+
+// mergeStringsData merges the lists of strings returned by the clusters, along
+// with the additional input strings, removing duplicates and sorting them.
+func mergeStringsData(data []json.RawMessage, additional ...string) (interface{}, error) {
+	unique := map[string]struct{}{}
+	for _, s := range additional {
+		unique[s] = struct{}{}
+	}
+
+	for _, d := range data {
+		var values []string
+		if err := json.Unmarshal(d, &values); err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			unique[v] = struct{}{}
+		}
+	}
+
+	merged := make([]string, 0, len(unique))
+	for v := range unique {
+		merged = append(merged, v)
+	}
+	sort.Strings(merged)
+	return merged, nil
+}
+
+func errorResponse(statusCode int, msg string) *http.Response {
+	errorType := errorTypeBadData
+	if statusCode/100 == 5 {
+		errorType = errorTypeInternal
+	}
+
+	return jsonResponse(statusCode, apiResponse{
+		Status:    statusError,
+		ErrorType: errorType,
+		Error:     msg,
+	})
+}
+
+func jsonResponse(statusCode int, resp apiResponse) *http.Response {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		statusCode = http.StatusInternalServerError
+		body = []byte(fmt.Sprintf(`{"status":%q,"errorType":%q,"error":%q}`, statusError, errorTypeInternal, err.Error()))
+	}
+
+	return &http.Response{
+		StatusCode:    statusCode,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+}
@@ -0,0 +1,209 @@
+package federation
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+// roundTripper federates the requests across remote Cortex clusters, merging
+// their responses and adding the cluster label to the returned series.
+type roundTripper struct {
+	clusterLabel string
+	clusters     []*cluster
+	logger       log.Logger
+
+	requestDuration *prometheus.HistogramVec
+}
+
+type cluster struct {
+	name          string
+	url           *url.URL
+	tenantMapping map[string]string
+	transport     http.RoundTripper
+}
+
+// clusterResponse is the response of a remote cluster, read in memory.
+type clusterResponse struct {
+	cluster    string
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+// NewRoundTripper returns a RoundTripper federating the requests across the
+// configured remote clusters.
+func NewRoundTripper(cfg Config, logger log.Logger, reg prometheus.Registerer) (http.RoundTripper, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	clusters := make([]*cluster, 0, len(cfg.Clusters))
+	for _, c := range cfg.Clusters {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid URL of the federated cluster %s", c.Name)
+		}
+
+		tlsCfg, err := c.TLS.GetTLSConfig()
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid TLS config of the federated cluster %s", c.Name)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsCfg
+
+		clusters = append(clusters, &cluster{
+			name:          c.Name,
+			url:           u,
+			tenantMapping: c.TenantMapping,
+			transport:     transport,
+		})
+	}
+
+	return &roundTripper{
+		clusterLabel: cfg.ClusterLabel,
+		clusters:     clusters,
+		logger:       logger,
+		requestDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "cortex",
+			Name:      "frontend_federation_request_duration_seconds",
+			Help:      "Time spent by the query-frontend running the requests federated to the remote clusters.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"cluster", "status_code"}),
+	}, nil
+}
+
+// RoundTrip sends the request to all the remote clusters, and merges their
+// responses. If a cluster fails the request, its response is returned as is.
+func (f *roundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	merge, ok := mergeFuncFor(r.URL.Path, f.clusterLabel)
+	if !ok {
+		return errorResponse(http.StatusBadRequest, "the endpoint "+r.URL.Path+" is not supported by the query federation"), nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]*clusterResponse, len(f.clusters))
+	g, ctx := errgroup.WithContext(r.Context())
+	for i, c := range f.clusters {
+		i, c := i, c
+		g.Go(func() error {
+			resp, err := f.doRequest(ctx, c, r, body)
+			responses[i] = resp
+			return err
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	for _, resp := range responses {
+		if resp.statusCode/100 != 2 {
+			return &http.Response{
+				StatusCode:    resp.statusCode,
+				Header:        resp.header,
+				Body:          ioutil.NopCloser(bytes.NewReader(resp.body)),
+				ContentLength: int64(len(resp.body)),
+			}, nil
+		}
+	}
+
+	return merge(responses), nil
+}
+
+// doRequest sends the request to the remote cluster, querying the mapped tenants.
+func (f *roundTripper) doRequest(ctx context.Context, c *cluster, r *http.Request, body []byte) (*clusterResponse, error) {
+	req := r.Clone(ctx)
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.RequestURI = ""
+	req.URL.Scheme = c.url.Scheme
+	req.URL.Host = c.url.Host
+	req.URL.Path = path.Join(c.url.Path, r.URL.Path)
+	req.Host = ""
+
+	if orgID := r.Header.Get(user.OrgIDHeaderName); orgID != "" {
+		mapped, err := c.mapTenants(orgID)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(user.OrgIDHeaderName, mapped)
+	}
+
+	// The responses are merged as JSON, uncompressed by the transport.
+	req.Header.Set("Accept", "application/json")
+	req.Header.Del("Accept-Encoding")
+
+	tracer, span := opentracing.GlobalTracer(), opentracing.SpanFromContext(ctx)
+	if tracer != nil && span != nil {
+		carrier := opentracing.HTTPHeadersCarrier(req.Header)
+		if err := tracer.Inject(span.Context(), opentracing.HTTPHeaders, carrier); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.transport.RoundTrip(req)
+	if err != nil {
+		f.requestDuration.WithLabelValues(c.name, "error").Observe(time.Since(start).Seconds())
+		return nil, errors.Wrapf(err, "failed to query the federated cluster %s", c.name)
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	f.requestDuration.WithLabelValues(c.name, strconv.Itoa(resp.StatusCode)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read the response of the federated cluster %s", c.name)
+	}
+
+	return &clusterResponse{
+		cluster:    c.name,
+		statusCode: resp.StatusCode,
+		header:     resp.Header,
+		body:       buf,
+	}, nil
+}
+
+// mapTenants maps each tenant of the input org ID to the tenant to query in the
+// remote cluster. Tenants not in the mapping are queried with the same ID.
+func (c *cluster) mapTenants(orgID string) (string, error) {
+	if len(c.tenantMapping) == 0 {
+		return orgID, nil
+	}
+
+	tenantIDs, err := tenant.TenantIDsFromOrgID(orgID)
+	if err != nil {
+		return "", err
+	}
+
+	mapped := make([]string, 0, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		if remote, ok := c.tenantMapping[tenantID]; ok {
+			tenantID = remote
+		}
+		mapped = append(mapped, tenantID)
+	}
+	return tenant.JoinTenantIDs(tenant.NormalizeTenantIDs(mapped)), nil
+}
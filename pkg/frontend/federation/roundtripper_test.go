@@ -0,0 +1,206 @@
+package federation
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+)
+
+func TestRoundTripper(t *testing.T) {
+	tests := map[string]struct {
+		path             string
+		euResponse       string
+		usResponse       string
+		usStatusCode     int
+		expectedStatus   int
+		expectedResponse string
+	}{
+		"range query": {
+			path:             "/api/v1/query_range?query=up&start=0&end=60&step=30",
+			euResponse:       `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up","job":"b"},"values":[[0,"1"]]}]}}`,
+			usResponse:       `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up","job":"a","cluster":"original"},"values":[[30,"0"]]}]},"warnings":["partial data"]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up","cluster":"eu","job":"b"},"values":[[0,"1"]]},{"metric":{"__name__":"up","cluster":"us","job":"a"},"values":[[30,"0"]]}]},"warnings":["cluster us: partial data"]}`,
+		},
+		"instant query": {
+			path:             "/api/v1/query?query=up",
+			euResponse:       `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"b"},"value":[0,"1"]}]}}`,
+			usResponse:       `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"cluster":"eu","job":"b"},"value":[0,"1"]}]}}`,
+		},
+		"scalar query": {
+			path:             "/api/v1/query?query=1",
+			euResponse:       `{"status":"success","data":{"resultType":"scalar","result":[0,"1"]}}`,
+			usResponse:       `{"status":"success","data":{"resultType":"scalar","result":[0,"1"]}}`,
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"status":"error","errorType":"bad_data","error":"the query result type \"scalar\" can't be federated across clusters"}`,
+		},
+		"series": {
+			path:             "/api/v1/series?match[]=up",
+			euResponse:       `{"status":"success","data":[{"__name__":"up","job":"b"}]}`,
+			usResponse:       `{"status":"success","data":[{"__name__":"up","job":"a"}]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"status":"success","data":[{"__name__":"up","cluster":"eu","job":"b"},{"__name__":"up","cluster":"us","job":"a"}]}`,
+		},
+		"label names": {
+			path:             "/api/v1/labels",
+			euResponse:       `{"status":"success","data":["__name__","job"]}`,
+			usResponse:       `{"status":"success","data":["__name__","instance"]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"status":"success","data":["__name__","cluster","instance","job"]}`,
+		},
+		"label values": {
+			path:             "/api/v1/label/job/values",
+			euResponse:       `{"status":"success","data":["b"]}`,
+			usResponse:       `{"status":"success","data":["a","b"]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"status":"success","data":["a","b"]}`,
+		},
+		"cluster label values": {
+			path:             "/api/v1/label/cluster/values",
+			euResponse:       `{"status":"success","data":[]}`,
+			usResponse:       `{"status":"success","data":["original"]}`,
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"status":"success","data":["eu","us"]}`,
+		},
+		"error returned by a cluster": {
+			path:             "/api/v1/query?query=up",
+			euResponse:       `{"status":"success","data":{"resultType":"vector","result":[]}}`,
+			usResponse:       `{"status":"error","errorType":"execution","error":"too many samples"}`,
+			usStatusCode:     http.StatusUnprocessableEntity,
+			expectedStatus:   http.StatusUnprocessableEntity,
+			expectedResponse: `{"status":"error","errorType":"execution","error":"too many samples"}`,
+		},
+		"unsupported endpoint": {
+			path:             "/api/v1/metadata",
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: `{"status":"error","errorType":"bad_data","error":"the endpoint /api/v1/metadata is not supported by the query federation"}`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			eu := newMockCluster(t, http.StatusOK, testData.euResponse)
+			defer eu.Close()
+
+			usStatusCode := testData.usStatusCode
+			if usStatusCode == 0 {
+				usStatusCode = http.StatusOK
+			}
+			us := newMockCluster(t, usStatusCode, testData.usResponse)
+			defer us.Close()
+
+			rt, err := NewRoundTripper(Config{
+				ClusterLabel: "cluster",
+				Clusters: []*ClusterConfig{
+					{Name: "eu", URL: eu.URL + "/prometheus"},
+					{Name: "us", URL: us.URL + "/prometheus"},
+				},
+			}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+			require.NoError(t, err)
+
+			req := httptest.NewRequest(http.MethodGet, testData.path, nil)
+			req.Header.Set(user.OrgIDHeaderName, "team-a")
+
+			resp, err := rt.RoundTrip(req)
+			require.NoError(t, err)
+
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedStatus, resp.StatusCode)
+			assert.Equal(t, testData.expectedResponse, string(body))
+		})
+	}
+}
+
+func TestRoundTripper_TenantMapping(t *testing.T) {
+	received := make(chan string, 2)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.URL.Path, "/prometheus/api/v1/labels"))
+		received <- r.Header.Get(user.OrgIDHeaderName)
+		_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+	})
+
+	eu := httptest.NewServer(handler)
+	defer eu.Close()
+	us := httptest.NewServer(handler)
+	defer us.Close()
+
+	rt, err := NewRoundTripper(Config{
+		ClusterLabel: "cluster",
+		Clusters: []*ClusterConfig{
+			{Name: "eu", URL: eu.URL + "/prometheus", TenantMapping: map[string]string{"team-a": "team-a-eu"}},
+			{Name: "us", URL: us.URL + "/prometheus"},
+		},
+	}, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/labels", nil)
+	req.Header.Set(user.OrgIDHeaderName, "team-a")
+
+	resp, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	assert.ElementsMatch(t, []string{"team-a-eu", "team-a"}, []string{<-received, <-received})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      Config
+		expected string
+	}{
+		"disabled": {
+			cfg: Config{},
+		},
+		"valid": {
+			cfg: Config{ClusterLabel: "cluster", Clusters: []*ClusterConfig{{Name: "eu", URL: "https://eu.example.com/prometheus"}}},
+		},
+		"missing cluster label": {
+			cfg:      Config{Clusters: []*ClusterConfig{{Name: "eu", URL: "https://eu.example.com"}}},
+			expected: errMissingClusterLabel.Error(),
+		},
+		"missing cluster name": {
+			cfg:      Config{ClusterLabel: "cluster", Clusters: []*ClusterConfig{{URL: "https://eu.example.com"}}},
+			expected: errMissingClusterName.Error(),
+		},
+		"duplicated cluster": {
+			cfg:      Config{ClusterLabel: "cluster", Clusters: []*ClusterConfig{{Name: "eu", URL: "https://eu.example.com"}, {Name: "eu", URL: "https://eu2.example.com"}}},
+			expected: "the federated cluster eu is configured more than once",
+		},
+		"invalid URL": {
+			cfg:      Config{ClusterLabel: "cluster", Clusters: []*ClusterConfig{{Name: "eu", URL: "eu.example.com"}}},
+			expected: "invalid URL of the federated cluster eu: the scheme and host must be set",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := testData.cfg.Validate()
+			if testData.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, testData.expected)
+			}
+		})
+	}
+}
+
+func newMockCluster(t *testing.T, statusCode int, response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, strings.HasPrefix(r.URL.Path, "/prometheus/api/v1/"))
+		assert.Equal(t, "application/json", r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statusCode)
+		_, _ = w.Write([]byte(response))
+	}))
+}
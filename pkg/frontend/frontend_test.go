@@ -30,6 +30,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/util/concurrency"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 const (
@@ -248,7 +249,7 @@ func testFrontend(t *testing.T, config CombinedFrontendConfig, handler http.Hand
 	r.PathPrefix("/").Handler(middleware.Merge(
 		middleware.AuthenticateUser,
 		middleware.Tracer{},
-	).Wrap(transport.NewHandler(config.Handler, rt, logger)))
+	).Wrap(transport.NewHandler(config.Handler, rt, logger, nil)))
 
 	httpServer := http.Server{
 		Handler: r,
@@ -284,3 +285,15 @@ type limits struct {
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
+
+func (l limits) QueryPriorities(_ string) []*validation.QueryPriority {
+	return nil
+}
+
+func (l limits) DefaultQueryPriority(_ string) int64 {
+	return 0
+}
+
+func (l limits) QueryPriorityHeaderEnabled(_ string) bool {
+	return false
+}
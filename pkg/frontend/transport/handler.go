@@ -8,15 +8,21 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/httpgrpc/server"
 
+	querier_stats "github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/querypriority"
 )
 
 const (
@@ -34,11 +40,15 @@ var (
 type HandlerConfig struct {
 	LogQueriesLongerThan time.Duration `yaml:"log_queries_longer_than"`
 	MaxBodySize          int64         `yaml:"max_body_size"`
+	QueryStatsEnabled    bool          `yaml:"query_stats_enabled"`
+	QueryStatsHeader     bool          `yaml:"query_stats_header_enabled"`
 }
 
 func (cfg *HandlerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.LogQueriesLongerThan, "frontend.log-queries-longer-than", 0, "Log queries that are slower than the specified duration. Set to 0 to disable. Set to < 0 to enable on all queries.")
 	f.Int64Var(&cfg.MaxBodySize, "frontend.max-body-size", 10*1024*1024, "Max body size for downstream prometheus.")
+	f.BoolVar(&cfg.QueryStatsEnabled, "frontend.query-stats-enabled", false, "True to enable query statistics tracking. When enabled, a message with some statistics is logged for every query and the per-tenant cortex_query_* metrics are tracked.")
+	f.BoolVar(&cfg.QueryStatsHeader, "frontend.query-stats-header-enabled", false, "True to return the query statistics to the client in the "+querier_stats.HeaderName+" response header, URL-encoded. The statistics include the querier wall time, the number of fetched series and chunks, the bytes fetched from the ingesters and the store-gateways, and the number of queries the query has been split and sharded into.")
 }
 
 // Handler accepts queries and forwards them to RoundTripper. It can log slow queries,
@@ -47,18 +57,67 @@ type Handler struct {
 	cfg          HandlerConfig
 	log          log.Logger
 	roundTripper http.RoundTripper
+
+	// Metrics.
+	querySeconds    *prometheus.CounterVec
+	querySeries     *prometheus.CounterVec
+	queryChunks     *prometheus.CounterVec
+	queryChunkBytes *prometheus.CounterVec
 }
 
 // New creates a new frontend handler.
-func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger) http.Handler {
-	return &Handler{
+func NewHandler(cfg HandlerConfig, roundTripper http.RoundTripper, log log.Logger, reg prometheus.Registerer) http.Handler {
+	h := &Handler{
 		cfg:          cfg,
 		log:          log,
 		roundTripper: roundTripper,
 	}
+
+	if cfg.QueryStatsEnabled {
+		h.querySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_seconds_total",
+			Help: "Total amount of wall clock time spend processing queries.",
+		}, []string{"user"})
+
+		h.querySeries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_fetched_series_total",
+			Help: "Number of series fetched to execute a query.",
+		}, []string{"user"})
+
+		h.queryChunks = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_fetched_chunks_total",
+			Help: "Number of chunks fetched to execute a query.",
+		}, []string{"user"})
+
+		h.queryChunkBytes = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_fetched_chunks_bytes_total",
+			Help: "Size of all chunks fetched to execute a query in bytes.",
+		}, []string{"user"})
+	}
+
+	return h
 }
 
 func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		stats       *querier_stats.Stats
+		queryString url.Values
+	)
+
+	// Initialise the stats in the context and make sure it's propagated
+	// down the request chain.
+	if f.cfg.QueryStatsEnabled || f.cfg.QueryStatsHeader {
+		var ctx context.Context
+		stats, ctx = querier_stats.ContextWithEmptyStats(r.Context())
+		r = r.WithContext(ctx)
+	}
+
+	// Propagate the query priority set by the client (if any) to the requests
+	// derived from this one, which don't carry the original headers.
+	if value := r.Header.Get(querypriority.HeaderName); value != "" {
+		r = r.WithContext(querypriority.ContextWithHeaderValue(r.Context(), value))
+	}
+
 	defer func() {
 		_ = r.Body.Close()
 	}()
@@ -73,7 +132,12 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	queryResponseTime := time.Since(startTime)
 
 	if err != nil {
-		writeError(w, err)
+		statusCode := writeError(w, err)
+
+		if f.cfg.QueryStatsEnabled {
+			queryString = f.parseRequestQueryString(r, buf)
+			f.reportQueryStats(r, queryString, queryResponseTime, stats, statusCode, err)
+		}
 		return
 	}
 
@@ -82,45 +146,107 @@ func (f *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		hs[h] = vs
 	}
 
+	if f.cfg.QueryStatsHeader {
+		hs.Set(querier_stats.HeaderName, stats.Encode())
+	}
+
 	w.WriteHeader(resp.StatusCode)
 	// we don't check for copy error as there is no much we can do at this point
 	_, _ = io.Copy(w, resp.Body)
 
-	f.reportSlowQuery(queryResponseTime, r, buf)
-}
+	// Check whether we should parse the query string.
+	shouldReportSlowQuery := f.cfg.LogQueriesLongerThan != 0 && queryResponseTime > f.cfg.LogQueriesLongerThan
+	if shouldReportSlowQuery || f.cfg.QueryStatsEnabled {
+		queryString = f.parseRequestQueryString(r, buf)
+	}
 
-// reportSlowQuery reports slow queries if LogQueriesLongerThan is set to <0, where 0 disables logging
-func (f *Handler) reportSlowQuery(queryResponseTime time.Duration, r *http.Request, bodyBuf bytes.Buffer) {
-	if f.cfg.LogQueriesLongerThan == 0 || queryResponseTime <= f.cfg.LogQueriesLongerThan {
-		return
+	if shouldReportSlowQuery {
+		f.reportSlowQuery(r, queryString, queryResponseTime)
 	}
+	if f.cfg.QueryStatsEnabled {
+		f.reportQueryStats(r, queryString, queryResponseTime, stats, resp.StatusCode, nil)
+	}
+}
 
-	logMessage := []interface{}{
+// reportSlowQuery reports slow queries.
+func (f *Handler) reportSlowQuery(r *http.Request, queryString url.Values, queryResponseTime time.Duration) {
+	logMessage := append([]interface{}{
 		"msg", "slow query detected",
 		"method", r.Method,
 		"host", r.Host,
 		"path", r.URL.Path,
 		"time_taken", queryResponseTime.String(),
+	}, formatQueryString(queryString)...)
+
+	level.Info(util.WithContext(r.Context(), f.log)).Log(logMessage...)
+}
+
+// reportQueryStats logs the statistics of the query and tracks them in the per-tenant metrics.
+func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, queryResponseTime time.Duration, stats *querier_stats.Stats, statusCode int, queryErr error) {
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return
 	}
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	wallTime := stats.LoadWallTime()
+	numSeries := stats.LoadFetchedSeries()
+	numChunks := stats.LoadFetchedChunks()
+	numBytes := stats.LoadFetchedChunkBytes()
 
-	// use previously buffered body
+	// Track stats.
+	f.querySeconds.WithLabelValues(userID).Add(wallTime.Seconds())
+	f.querySeries.WithLabelValues(userID).Add(float64(numSeries))
+	f.queryChunks.WithLabelValues(userID).Add(float64(numChunks))
+	f.queryChunkBytes.WithLabelValues(userID).Add(float64(numBytes))
+
+	// Log stats.
+	logMessage := append([]interface{}{
+		"msg", "query stats",
+		"component", "query-frontend",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"response_time", queryResponseTime,
+		"query_wall_time_seconds", wallTime.Seconds(),
+		"fetched_series_count", numSeries,
+		"fetched_chunks_count", numChunks,
+		"fetched_chunks_bytes", numBytes,
+		"fetched_ingester_chunks_bytes", stats.LoadFetchedIngesterChunkBytes(),
+		"fetched_store_gateway_chunks_bytes", stats.LoadFetchedStoreGatewayChunkBytes(),
+		"split_queries", stats.LoadSplitQueries(),
+		"sharded_queries", stats.LoadShardedQueries(),
+		"status_code", statusCode,
+	}, formatQueryString(queryString)...)
+
+	if queryErr != nil {
+		logMessage = append(logMessage, "error", queryErr)
+	}
+
+	level.Info(util.WithContext(r.Context(), f.log)).Log(logMessage...)
+}
+
+func (f *Handler) parseRequestQueryString(r *http.Request, bodyBuf bytes.Buffer) url.Values {
+	// Use previously buffered body.
 	r.Body = ioutil.NopCloser(&bodyBuf)
 
 	// Ensure the form has been parsed so all the parameters are present
 	err := r.ParseForm()
 	if err != nil {
-		level.Warn(util.WithContext(r.Context(), f.log)).Log("msg", "unable to parse form for request", "err", err)
+		level.Warn(util.WithContext(r.Context(), f.log)).Log("msg", "unable to parse request form", "err", err)
+		return nil
 	}
 
-	// Attempt to iterate through the Form to log any filled in values
-	for k, v := range r.Form {
-		logMessage = append(logMessage, fmt.Sprintf("param_%s", k), strings.Join(v, ","))
-	}
+	return r.Form
+}
 
-	level.Info(util.WithContext(r.Context(), f.log)).Log(logMessage...)
+func formatQueryString(queryString url.Values) (fields []interface{}) {
+	for k, v := range queryString {
+		fields = append(fields, fmt.Sprintf("param_%s", k), strings.Join(v, ","))
+	}
+	return fields
 }
 
-func writeError(w http.ResponseWriter, err error) {
+// writeError writes the error to the response and returns the HTTP status code.
+func writeError(w http.ResponseWriter, err error) int {
 	switch err {
 	case context.Canceled:
 		err = errCanceled
@@ -132,4 +258,9 @@ func writeError(w http.ResponseWriter, err error) {
 		}
 	}
 	server.WriteError(w, err)
+
+	if resp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return int(resp.Code)
+	}
+	return http.StatusInternalServerError
 }
@@ -2,13 +2,23 @@ package transport
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+
+	querier_stats "github.com/cortexproject/cortex/pkg/querier/stats"
 )
 
 func TestWriteError(t *testing.T) {
@@ -28,3 +38,110 @@ func TestWriteError(t *testing.T) {
 		})
 	}
 }
+
+type grpcRoundTripperFunc func(context.Context, *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error)
+
+func (f grpcRoundTripperFunc) RoundTripGRPC(ctx context.Context, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	return f(ctx, req)
+}
+
+func TestHandler_ServeHTTP_QueryStats(t *testing.T) {
+	stats := &querier_stats.Stats{}
+	stats.AddWallTime(2 * time.Second)
+	stats.AddFetchedSeries(10)
+	stats.AddFetchedChunks(20)
+	stats.AddFetchedChunkBytes(1024)
+
+	// The querier is called twice, like in the case of a query split by interval.
+	rt := AdaptGrpcRoundTripperToHTTPRoundTripper(grpcRoundTripperFunc(func(context.Context, *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+		return &httpgrpc.HTTPResponse{
+			Code: http.StatusOK,
+			Headers: []*httpgrpc.Header{
+				{Key: "Content-Type", Values: []string{"application/json"}},
+				{Key: querier_stats.HeaderName, Values: []string{stats.Encode()}},
+			},
+			Body: []byte("{}"),
+		}, nil
+	}))
+	next := http.RoundTripper(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if _, err := rt.RoundTrip(r); err != nil {
+			return nil, err
+		}
+		return rt.RoundTrip(r)
+	}))
+
+	for _, enabled := range []bool{true, false} {
+		reg := prometheus.NewPedanticRegistry()
+		handler := NewHandler(HandlerConfig{QueryStatsEnabled: enabled, MaxBodySize: 1024}, next, log.NewNopLogger(), reg)
+
+		req := httptest.NewRequest("GET", "/api/v1/query?query=up", nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+
+		require.Equal(t, http.StatusOK, resp.Code)
+		assert.Empty(t, resp.Header().Get(querier_stats.HeaderName))
+		assert.Equal(t, "application/json", resp.Header().Get("Content-Type"))
+
+		if !enabled {
+			count, err := testutil.GatherAndCount(reg)
+			require.NoError(t, err)
+			assert.Equal(t, 0, count)
+			continue
+		}
+
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_query_seconds_total Total amount of wall clock time spend processing queries.
+			# TYPE cortex_query_seconds_total counter
+			cortex_query_seconds_total{user="user-1"} 4
+			# HELP cortex_query_fetched_series_total Number of series fetched to execute a query.
+			# TYPE cortex_query_fetched_series_total counter
+			cortex_query_fetched_series_total{user="user-1"} 20
+			# HELP cortex_query_fetched_chunks_total Number of chunks fetched to execute a query.
+			# TYPE cortex_query_fetched_chunks_total counter
+			cortex_query_fetched_chunks_total{user="user-1"} 40
+			# HELP cortex_query_fetched_chunks_bytes_total Size of all chunks fetched to execute a query in bytes.
+			# TYPE cortex_query_fetched_chunks_bytes_total counter
+			cortex_query_fetched_chunks_bytes_total{user="user-1"} 2048
+		`)))
+	}
+}
+
+func TestHandler_ServeHTTP_QueryStatsHeader(t *testing.T) {
+	next := http.RoundTripper(roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		// Simulate the stats tracked while executing the query.
+		stats := querier_stats.FromContext(r.Context())
+		stats.AddWallTime(time.Second)
+		stats.AddFetchedSeries(10)
+		stats.AddFetchedChunkBytes(3072)
+		stats.AddFetchedIngesterChunkBytes(1024)
+		stats.AddFetchedStoreGatewayChunkBytes(2048)
+		stats.AddSplitQueries(3)
+
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	}))
+
+	handler := NewHandler(HandlerConfig{QueryStatsHeader: true, MaxBodySize: 1024}, next, log.NewNopLogger(), nil)
+
+	req := httptest.NewRequest("GET", "/api/v1/query_range?query=up", nil)
+	req = req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	stats, err := querier_stats.Decode(resp.Header().Get(querier_stats.HeaderName))
+	require.NoError(t, err)
+	assert.Equal(t, time.Second, stats.LoadWallTime())
+	assert.Equal(t, uint64(10), stats.LoadFetchedSeries())
+	assert.Equal(t, uint64(3072), stats.LoadFetchedChunkBytes())
+	assert.Equal(t, uint64(1024), stats.LoadFetchedIngesterChunkBytes())
+	assert.Equal(t, uint64(2048), stats.LoadFetchedStoreGatewayChunkBytes())
+	assert.Equal(t, uint64(3), stats.LoadSplitQueries())
+	assert.Equal(t, uint64(0), stats.LoadShardedQueries())
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
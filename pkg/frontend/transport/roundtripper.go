@@ -6,8 +6,13 @@ import (
 	"io/ioutil"
 	"net/http"
 
+	"github.com/go-kit/kit/log/level"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/httpgrpc/server"
+
+	querier_stats "github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/querypriority"
 )
 
 // GrpcRoundTripper is similar to http.RoundTripper, but works with HTTP requests converted to protobuf messages.
@@ -30,6 +35,10 @@ func (a *grpcRoundTripperAdapter) RoundTrip(r *http.Request) (*http.Response, er
 		return nil, err
 	}
 
+	if value := querypriority.HeaderValueFromContext(r.Context()); value != "" && r.Header.Get(querypriority.HeaderName) == "" {
+		req.Headers = append(req.Headers, &httpgrpc.Header{Key: querypriority.HeaderName, Values: []string{value}})
+	}
+
 	resp, err := a.roundTripper.RoundTripGRPC(r.Context(), req)
 	if err != nil {
 		return nil, err
@@ -41,7 +50,31 @@ func (a *grpcRoundTripperAdapter) RoundTrip(r *http.Request) (*http.Response, er
 		Header:     http.Header{},
 	}
 	for _, h := range resp.Headers {
+		// The query stats reported by the querier are merged into the request
+		// stats (if any) and not forwarded to the client.
+		if h.Key == querier_stats.HeaderName {
+			mergeQueryStats(r.Context(), h.Values)
+			continue
+		}
+
 		httpResp.Header[h.Key] = h.Values
 	}
 	return httpResp, nil
 }
+
+func mergeQueryStats(ctx context.Context, values []string) {
+	stats := querier_stats.FromContext(ctx)
+	if stats == nil {
+		return
+	}
+
+	for _, value := range values {
+		received, err := querier_stats.Decode(value)
+		if err != nil {
+			level.Warn(util.WithContext(ctx, util.Logger)).Log("msg", "failed to decode query stats received from querier", "err", err)
+			continue
+		}
+
+		stats.Merge(received)
+	}
+}
@@ -19,6 +19,8 @@ import (
 	"github.com/cortexproject/cortex/pkg/scheduler/queue"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util/grpcutil"
+	"github.com/cortexproject/cortex/pkg/util/querypriority"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 var (
@@ -36,6 +38,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 }
 
 type Limits interface {
+	querypriority.Limits
+
 	// Returns max queriers to use per tenant, or 0 if shuffle sharding is disabled.
 	MaxQueriersPerUser(user string) int
 }
@@ -58,12 +62,18 @@ type request struct {
 	enqueueTime time.Time
 	queueSpan   opentracing.Span
 	originalCtx context.Context
+	priority    int64
 
 	request  *httpgrpc.HTTPRequest
 	err      chan error
 	response chan *httpgrpc.HTTPResponse
 }
 
+// Priority implements queue.PrioritizedRequest.
+func (r *request) Priority() int64 {
+	return r.priority
+}
+
 // New creates a new frontend.
 func New(cfg Config, limits Limits, log log.Logger, registerer prometheus.Registerer) (*Frontend, error) {
 	queueLength := promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
@@ -250,15 +260,18 @@ func getQuerierID(server frontendv1pb.Frontend_ProcessServer) (string, error) {
 }
 
 func (f *Frontend) queueRequest(ctx context.Context, req *request) error {
-	userID, err := tenant.TenantID(ctx)
+	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
 		return err
 	}
 
 	req.enqueueTime = time.Now()
 	req.queueSpan, _ = opentracing.StartSpanFromContext(ctx, "queued")
+	req.priority = querypriority.GetForTenants(req.request, tenantIDs, f.limits)
 
-	maxQueriers := f.limits.MaxQueriersPerUser(userID)
+	// A query spanning multiple tenants is queued as a separate tenant.
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, f.limits.MaxQueriersPerUser)
 
 	err = f.requestQueue.EnqueueRequest(userID, req, maxQueriers, nil)
 	if err == queue.ErrTooManyRequests {
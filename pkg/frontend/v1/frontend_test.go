@@ -31,6 +31,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/scheduler/queue"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 const (
@@ -217,7 +218,7 @@ func testFrontend(t *testing.T, config Config, handler http.Handler, test func(a
 	r.PathPrefix("/").Handler(middleware.Merge(
 		middleware.AuthenticateUser,
 		middleware.Tracer{},
-	).Wrap(transport.NewHandler(handlerCfg, rt, logger)))
+	).Wrap(transport.NewHandler(handlerCfg, rt, logger, nil)))
 
 	httpServer := http.Server{
 		Handler: r,
@@ -250,3 +251,15 @@ type limits struct {
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
+
+func (l limits) QueryPriorities(_ string) []*validation.QueryPriority {
+	return nil
+}
+
+func (l limits) DefaultQueryPriority(_ string) int64 {
+	return 0
+}
+
+func (l limits) QueryPriorityHeaderEnabled(_ string) bool {
+	return false
+}
@@ -20,6 +20,7 @@ const (
 
 // ActiveSeries is keeping track of recently active series for a single tenant.
 type ActiveSeries struct {
+	asm     *ActiveSeriesMatchers
 	stripes [numActiveSeriesStripes]activeSeriesStripe
 }
 
@@ -30,23 +31,32 @@ type activeSeriesStripe struct {
 	// without holding the lock -- hence the atomic).
 	oldestEntryTs atomic.Int64
 
-	mu     sync.RWMutex
-	refs   map[uint64][]activeSeriesEntry
-	active int // Number of active entries in this stripe. Only decreased during purge or clear.
+	mu             sync.RWMutex
+	refs           map[uint64][]activeSeriesEntry
+	active         int   // Number of active entries in this stripe. Only decreased during purge or clear.
+	activeMatching []int // Number of active entries in this stripe matching each custom tracker.
 }
 
 // activeSeriesEntry holds a timestamp for single series.
 type activeSeriesEntry struct {
-	lbs   labels.Labels
-	nanos *atomic.Int64 // Unix timestamp in nanoseconds. Needs to be a pointer because we don't store pointers to entries in the stripe.
+	lbs     labels.Labels
+	nanos   *atomic.Int64 // Unix timestamp in nanoseconds. Needs to be a pointer because we don't store pointers to entries in the stripe.
+	matches []bool        // Whether the series matches each custom tracker.
 }
 
-func NewActiveSeries() *ActiveSeries {
-	c := &ActiveSeries{}
+// NewActiveSeries makes a new ActiveSeries. The input matchers are used to additionally
+// track the number of active series matching each custom tracker; they can be nil.
+func NewActiveSeries(asm *ActiveSeriesMatchers) *ActiveSeries {
+	if asm == nil {
+		asm = &ActiveSeriesMatchers{}
+	}
+
+	c := &ActiveSeries{asm: asm}
 
 	// Stripes are pre-allocated so that we only read on them and no lock is required.
 	for i := 0; i < numActiveSeriesStripes; i++ {
 		c.stripes[i].refs = map[uint64][]activeSeriesEntry{}
+		c.stripes[i].activeMatching = make([]int, len(asm.Names()))
 	}
 
 	return c
@@ -57,7 +67,7 @@ func (c *ActiveSeries) UpdateSeries(series labels.Labels, now time.Time, labelsC
 	fp := fingerprint(series)
 	stripeID := fp % numActiveSeriesStripes
 
-	c.stripes[stripeID].updateSeriesTimestamp(now, series, fp, labelsCopy)
+	c.stripes[stripeID].updateSeriesTimestamp(now, series, fp, c.asm, labelsCopy)
 }
 
 var sep = []byte{model.SeparatorByte}
@@ -102,13 +112,23 @@ func (c *ActiveSeries) Active() int {
 	return total
 }
 
-func (s *activeSeriesStripe) updateSeriesTimestamp(now time.Time, series labels.Labels, fingerprint uint64, labelsCopy func(labels.Labels) labels.Labels) {
+// ActiveMatching returns the number of active series matching each custom tracker,
+// in the same order of ActiveSeriesMatchers.Names().
+func (c *ActiveSeries) ActiveMatching() []int {
+	total := make([]int, len(c.asm.Names()))
+	for s := 0; s < numActiveSeriesStripes; s++ {
+		c.stripes[s].addActiveMatching(total)
+	}
+	return total
+}
+
+func (s *activeSeriesStripe) updateSeriesTimestamp(now time.Time, series labels.Labels, fingerprint uint64, asm *ActiveSeriesMatchers, labelsCopy func(labels.Labels) labels.Labels) {
 	nowNanos := now.UnixNano()
 
 	e := s.findEntryForSeries(fingerprint, series)
 	entryTimeSet := false
 	if e == nil {
-		e, entryTimeSet = s.findOrCreateEntryForSeries(fingerprint, series, nowNanos, labelsCopy)
+		e, entryTimeSet = s.findOrCreateEntryForSeries(fingerprint, series, nowNanos, asm, labelsCopy)
 	}
 
 	if !entryTimeSet {
@@ -142,7 +162,7 @@ func (s *activeSeriesStripe) findEntryForSeries(fingerprint uint64, series label
 	return nil
 }
 
-func (s *activeSeriesStripe) findOrCreateEntryForSeries(fingerprint uint64, series labels.Labels, nowNanos int64, labelsCopy func(labels.Labels) labels.Labels) (*atomic.Int64, bool) {
+func (s *activeSeriesStripe) findOrCreateEntryForSeries(fingerprint uint64, series labels.Labels, nowNanos int64, asm *ActiveSeriesMatchers, labelsCopy func(labels.Labels) labels.Labels) (*atomic.Int64, bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -153,10 +173,19 @@ func (s *activeSeriesStripe) findOrCreateEntryForSeries(fingerprint uint64, seri
 		}
 	}
 
+	matches := asm.Matches(series)
+
 	s.active++
+	for i, match := range matches {
+		if match {
+			s.activeMatching[i]++
+		}
+	}
+
 	e := activeSeriesEntry{
-		lbs:   labelsCopy(series),
-		nanos: atomic.NewInt64(nowNanos),
+		lbs:     labelsCopy(series),
+		nanos:   atomic.NewInt64(nowNanos),
+		matches: matches,
 	}
 
 	s.refs[fingerprint] = append(s.refs[fingerprint], e)
@@ -172,6 +201,9 @@ func (s *activeSeriesStripe) clear() {
 	s.oldestEntryTs.Store(0)
 	s.refs = map[uint64][]activeSeriesEntry{}
 	s.active = 0
+	for i := range s.activeMatching {
+		s.activeMatching[i] = 0
+	}
 }
 
 func (s *activeSeriesStripe) purge(keepUntil time.Time) {
@@ -185,6 +217,7 @@ func (s *activeSeriesStripe) purge(keepUntil time.Time) {
 	defer s.mu.Unlock()
 
 	active := 0
+	activeMatching := make([]int, len(s.activeMatching))
 
 	oldest := int64(math.MaxInt64)
 	for fp, entries := range s.refs {
@@ -198,6 +231,7 @@ func (s *activeSeriesStripe) purge(keepUntil time.Time) {
 			}
 
 			active++
+			addMatches(activeMatching, entries[0].matches)
 			if ts < oldest {
 				oldest = ts
 			}
@@ -224,6 +258,9 @@ func (s *activeSeriesStripe) purge(keepUntil time.Time) {
 			delete(s.refs, fp)
 		} else {
 			active += cnt
+			for _, e := range entries {
+				addMatches(activeMatching, e.matches)
+			}
 			s.refs[fp] = entries
 		}
 	}
@@ -234,6 +271,7 @@ func (s *activeSeriesStripe) purge(keepUntil time.Time) {
 		s.oldestEntryTs.Store(oldest)
 	}
 	s.active = active
+	s.activeMatching = activeMatching
 }
 
 func (s *activeSeriesStripe) getActive() int {
@@ -242,3 +280,20 @@ func (s *activeSeriesStripe) getActive() int {
 
 	return s.active
 }
+
+func (s *activeSeriesStripe) addActiveMatching(total []int) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i, cnt := range s.activeMatching {
+		total[i] += cnt
+	}
+}
+
+func addMatches(activeMatching []int, matches []bool) {
+	for i, match := range matches {
+		if match {
+			activeMatching[i]++
+		}
+	}
+}
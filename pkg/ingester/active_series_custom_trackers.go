@@ -0,0 +1,106 @@
+package ingester
+
+import (
+	"sort"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// ActiveSeriesMatchers holds the compiled matchers of the active series custom trackers.
+type ActiveSeriesMatchers struct {
+	names    []string
+	matchers [][]*labels.Matcher
+}
+
+// NewActiveSeriesMatchers compiles the input custom trackers config. Trackers are sorted by name.
+func NewActiveSeriesMatchers(cfg validation.ActiveSeriesCustomTrackersConfig) (*ActiveSeriesMatchers, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	trackers := append(validation.ActiveSeriesCustomTrackersConfig{}, cfg...)
+	sort.Slice(trackers, func(i, j int) bool {
+		return trackers[i].Name < trackers[j].Name
+	})
+
+	asm := &ActiveSeriesMatchers{}
+	for _, tracker := range trackers {
+		matchers, err := parser.ParseMetricSelector(tracker.Selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse the selector of the active series custom tracker %q", tracker.Name)
+		}
+
+		asm.names = append(asm.names, tracker.Name)
+		asm.matchers = append(asm.matchers, matchers)
+	}
+
+	return asm, nil
+}
+
+// Names returns the trackers names, in the same order of the results returned by Matches().
+func (asm *ActiveSeriesMatchers) Names() []string {
+	return asm.names
+}
+
+// Matches returns, for each tracker, whether the input series matches its selector.
+func (asm *ActiveSeriesMatchers) Matches(series labels.Labels) []bool {
+	if len(asm.matchers) == 0 {
+		return nil
+	}
+
+	matches := make([]bool, len(asm.matchers))
+	for i, matchers := range asm.matchers {
+		matches[i] = matchesAll(series, matchers)
+	}
+
+	return matches
+}
+
+func matchesAll(series labels.Labels, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(series.Get(m.Name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// newActiveSeriesForUser makes a new ActiveSeries tracking the custom trackers configured for the user.
+// The limiter is nil when running the flusher, which doesn't track active series.
+func newActiveSeriesForUser(limiter *Limiter, userID string) *ActiveSeries {
+	if limiter == nil {
+		return NewActiveSeries(nil)
+	}
+
+	asm, err := NewActiveSeriesMatchers(limiter.limits.ActiveSeriesCustomTrackers(userID))
+	if err != nil {
+		// The config is validated when loaded (both the defaults and the runtime
+		// config overrides), so this should never happen.
+		level.Warn(util.Logger).Log("msg", "invalid active series custom trackers, ignoring them", "user", userID, "err", err)
+		asm = nil
+	}
+
+	return NewActiveSeries(asm)
+}
+
+// updateActiveSeriesCustomTrackersMetrics sets the per-tracker active series of the user.
+func updateActiveSeriesCustomTrackersMetrics(gauge *prometheus.GaugeVec, userID string, activeSeries *ActiveSeries) {
+	for idx, count := range activeSeries.ActiveMatching() {
+		gauge.WithLabelValues(userID, activeSeries.asm.Names()[idx]).Set(float64(count))
+	}
+}
+
+// deleteActiveSeriesCustomTrackersMetrics removes the per-tracker active series of the user.
+func deleteActiveSeriesCustomTrackersMetrics(gauge *prometheus.GaugeVec, userID string, activeSeries *ActiveSeries) {
+	for _, name := range activeSeries.asm.Names() {
+		gauge.DeleteLabelValues(userID, name)
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 func copyFn(l labels.Labels) labels.Labels { return l }
@@ -22,7 +23,7 @@ func TestActiveSeries_UpdateSeries(t *testing.T) {
 	ls1 := []labels.Label{{Name: "a", Value: "1"}}
 	ls2 := []labels.Label{{Name: "a", Value: "2"}}
 
-	c := NewActiveSeries()
+	c := NewActiveSeries(nil)
 	assert.Equal(t, 0, c.Active())
 
 	c.UpdateSeries(ls1, time.Now(), copyFn)
@@ -42,7 +43,7 @@ func TestActiveSeries_ShouldCorrectlyHandleFingerprintCollisions(t *testing.T) {
 
 	require.True(t, client.Fingerprint(ls1) == client.Fingerprint(ls2))
 
-	c := NewActiveSeries()
+	c := NewActiveSeries(nil)
 	c.UpdateSeries(ls1, time.Now(), copyFn)
 	c.UpdateSeries(ls2, time.Now(), copyFn)
 
@@ -60,7 +61,7 @@ func TestActiveSeries_Purge(t *testing.T) {
 
 	// Run the same test for increasing TTL values
 	for ttl := 0; ttl < len(series); ttl++ {
-		c := NewActiveSeries()
+		c := NewActiveSeries(nil)
 
 		for i := 0; i < len(series); i++ {
 			c.UpdateSeries(series[i], time.Unix(int64(i), 0), copyFn)
@@ -80,7 +81,7 @@ func TestActiveSeries_PurgeOpt(t *testing.T) {
 	ls1 := metric.Set("_", "ypfajYg2lsv").Labels()
 	ls2 := metric.Set("_", "KiqbryhzUpn").Labels()
 
-	c := NewActiveSeries()
+	c := NewActiveSeries(nil)
 
 	now := time.Now()
 	c.UpdateSeries(ls1, now.Add(-2*time.Minute), copyFn)
@@ -117,7 +118,7 @@ func benchmarkActiveSeriesConcurrencySingleSeries(b *testing.B, goroutines int)
 		{Name: "a", Value: "a"},
 	}
 
-	c := NewActiveSeries()
+	c := NewActiveSeries(nil)
 
 	wg := &sync.WaitGroup{}
 	start := make(chan struct{})
@@ -144,7 +145,7 @@ func benchmarkActiveSeriesConcurrencySingleSeries(b *testing.B, goroutines int)
 }
 
 func BenchmarkActiveSeries_UpdateSeries(b *testing.B) {
-	c := NewActiveSeries()
+	c := NewActiveSeries(nil)
 
 	// Prepare series
 	nameBuf := bytes.Buffer{}
@@ -179,7 +180,7 @@ func benchmarkPurge(b *testing.B, twice bool) {
 	const numExpiresSeries = numSeries / 25
 
 	now := time.Now()
-	c := NewActiveSeries()
+	c := NewActiveSeries(nil)
 
 	series := [numSeries]labels.Labels{}
 	for s := 0; s < numSeries; s++ {
@@ -211,3 +212,53 @@ func benchmarkPurge(b *testing.B, twice bool) {
 		}
 	}
 }
+
+func TestActiveSeries_ActiveMatching(t *testing.T) {
+	asm, err := NewActiveSeriesMatchers(validation.ActiveSeriesCustomTrackersConfig{
+		{Name: "team_b", Selector: `{team="b"}`},
+		{Name: "team_a", Selector: `{team="a"}`},
+		{Name: "http", Selector: `{__name__=~"http_.+"}`},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"http", "team_a", "team_b"}, asm.Names())
+
+	ls1 := labels.FromStrings("__name__", "http_requests_total", "team", "a")
+	ls2 := labels.FromStrings("__name__", "http_errors_total", "team", "b")
+	ls3 := labels.FromStrings("__name__", "up", "team", "a")
+
+	now := time.Now()
+	c := NewActiveSeries(asm)
+	assert.Equal(t, []int{0, 0, 0}, c.ActiveMatching())
+
+	c.UpdateSeries(ls1, now.Add(-2*time.Minute), copyFn)
+	c.UpdateSeries(ls2, now, copyFn)
+	c.UpdateSeries(ls3, now, copyFn)
+	assert.Equal(t, 3, c.Active())
+	assert.Equal(t, []int{2, 2, 1}, c.ActiveMatching())
+
+	// Updating an existing series should not change the counts.
+	c.UpdateSeries(ls3, now, copyFn)
+	assert.Equal(t, []int{2, 2, 1}, c.ActiveMatching())
+
+	// Purging should remove the expired series from the counts.
+	c.Purge(now.Add(-time.Minute))
+	assert.Equal(t, 2, c.Active())
+	assert.Equal(t, []int{1, 1, 1}, c.ActiveMatching())
+
+	c.clear()
+	assert.Equal(t, 0, c.Active())
+	assert.Equal(t, []int{0, 0, 0}, c.ActiveMatching())
+}
+
+func TestActiveSeries_ActiveMatchingWithoutMatchers(t *testing.T) {
+	c := NewActiveSeries(nil)
+	c.UpdateSeries(labels.FromStrings("a", "1"), time.Now(), copyFn)
+
+	assert.Equal(t, 1, c.Active())
+	assert.Empty(t, c.ActiveMatching())
+}
+
+func TestNewActiveSeriesMatchers_InvalidSelector(t *testing.T) {
+	_, err := NewActiveSeriesMatchers(validation.ActiveSeriesCustomTrackersConfig{{Name: "foo", Selector: `{foo=`}})
+	require.Error(t, err)
+}
@@ -0,0 +1,117 @@
+package ingester
+
+import (
+	"sort"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+)
+
+// labelNamesCardinality accumulates the number of series and distinct values of each label name.
+type labelNamesCardinality struct {
+	series uint64
+	counts map[string]uint64
+	values map[string]map[string]struct{}
+}
+
+func newLabelNamesCardinality() *labelNamesCardinality {
+	return &labelNamesCardinality{
+		counts: map[string]uint64{},
+		values: map[string]map[string]struct{}{},
+	}
+}
+
+func (c *labelNamesCardinality) add(lset labels.Labels) {
+	c.series++
+
+	for _, l := range lset {
+		c.counts[l.Name]++
+
+		values, ok := c.values[l.Name]
+		if !ok {
+			values = map[string]struct{}{}
+			c.values[l.Name] = values
+		}
+		values[l.Value] = struct{}{}
+	}
+}
+
+// response returns the label names with the highest number of series, up to limit (0 means unlimited).
+func (c *labelNamesCardinality) response(limit int) *client.LabelNamesCardinalityResponse {
+	items := make([]*client.LabelNameCardinality, 0, len(c.counts))
+	for name, count := range c.counts {
+		items = append(items, &client.LabelNameCardinality{
+			LabelName:        name,
+			SeriesCount:      count,
+			LabelValuesCount: uint64(len(c.values[name])),
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].SeriesCount != items[j].SeriesCount {
+			return items[i].SeriesCount > items[j].SeriesCount
+		}
+		return items[i].LabelName < items[j].LabelName
+	})
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	return &client.LabelNamesCardinalityResponse{
+		SeriesCountTotal: c.series,
+		LabelNames:       items,
+	}
+}
+
+// labelValuesCardinality accumulates the number of series of each value of a label name.
+type labelValuesCardinality struct {
+	labelName string
+	series    uint64
+	counts    map[string]uint64
+}
+
+func newLabelValuesCardinality(labelName string) *labelValuesCardinality {
+	return &labelValuesCardinality{
+		labelName: labelName,
+		counts:    map[string]uint64{},
+	}
+}
+
+func (c *labelValuesCardinality) add(lset labels.Labels) {
+	value := lset.Get(c.labelName)
+	if value == "" {
+		return
+	}
+
+	c.series++
+	c.counts[value]++
+}
+
+// response returns the label values with the highest number of series, up to limit (0 means unlimited).
+func (c *labelValuesCardinality) response(limit int) *client.LabelValuesCardinalityResponse {
+	items := make([]*client.LabelValueCardinality, 0, len(c.counts))
+	for value, count := range c.counts {
+		items = append(items, &client.LabelValueCardinality{
+			LabelValue:  value,
+			SeriesCount: count,
+		})
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].SeriesCount != items[j].SeriesCount {
+			return items[i].SeriesCount > items[j].SeriesCount
+		}
+		return items[i].LabelValue < items[j].LabelValue
+	})
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+
+	return &client.LabelValuesCardinalityResponse{
+		SeriesCountTotal: c.series,
+		LabelValues:      items,
+	}
+}
@@ -1,17 +1,19 @@
 package client
 
 import (
+	"errors"
 	"flag"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
-	"google.golang.org/grpc"
 	"google.golang.org/grpc/health/grpc_health_v1"
 
 	"github.com/cortexproject/cortex/pkg/util/grpcclient"
 )
 
+var errInvalidConnectionsPerTarget = errors.New("the number of connections per ingester must be greater than 0")
+
 var ingesterClientRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
 	Namespace: "cortex",
 	Name:      "ingester_client_request_duration_seconds",
@@ -26,43 +28,27 @@ type HealthAndIngesterClient interface {
 	Close() error
 }
 
-type closableHealthAndIngesterClient struct {
-	IngesterClient
-	grpc_health_v1.HealthClient
-	conn *grpc.ClientConn
-}
-
-// MakeIngesterClient makes a new IngesterClient
+// MakeIngesterClient makes a new IngesterClient. The client opens the configured number
+// of connections to the ingester, and balances the requests across them.
 func MakeIngesterClient(addr string, cfg Config) (HealthAndIngesterClient, error) {
-	dialOpts, err := cfg.GRPCClientConfig.DialOption(grpcclient.Instrument(ingesterClientRequestDuration))
-	if err != nil {
-		return nil, err
-	}
-	conn, err := grpc.Dial(addr, dialOpts...)
-	if err != nil {
-		return nil, err
-	}
-	return &closableHealthAndIngesterClient{
-		IngesterClient: NewIngesterClient(conn),
-		HealthClient:   grpc_health_v1.NewHealthClient(conn),
-		conn:           conn,
-	}, nil
-}
-
-func (c *closableHealthAndIngesterClient) Close() error {
-	return c.conn.Close()
+	return newConnPoolClient(addr, cfg)
 }
 
 // Config is the configuration struct for the ingester client
 type Config struct {
-	GRPCClientConfig grpcclient.ConfigWithTLS `yaml:"grpc_client_config"`
+	GRPCClientConfig     grpcclient.ConfigWithTLS `yaml:"grpc_client_config"`
+	ConnectionsPerTarget int                      `yaml:"connections_per_target"`
 }
 
 // RegisterFlags registers configuration settings used by the ingester client config.
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("ingester.client", f)
+	f.IntVar(&cfg.ConnectionsPerTarget, "ingester.client.connections-per-target", 1, "Number of gRPC connections opened to each ingester. The requests are balanced across the connections, picking the healthy connection with the fewest in-flight requests.")
 }
 
 func (cfg *Config) Validate(log log.Logger) error {
+	if cfg.ConnectionsPerTarget < 1 {
+		return errInvalidConnectionsPerTarget
+	}
 	return cfg.GRPCClientConfig.Validate(log)
 }
@@ -130,6 +130,51 @@ func FromMetricsForLabelMatchersRequest(req *MetricsForLabelMatchersRequest) (mo
 	return from, to, matchersSet, nil
 }
 
+// ToLabelNamesCardinalityRequest builds a LabelNamesCardinalityRequest proto
+func ToLabelNamesCardinalityRequest(matchers []*labels.Matcher, limit int) (*LabelNamesCardinalityRequest, error) {
+	ms, err := toLabelMatchers(matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LabelNamesCardinalityRequest{
+		Matchers: ms,
+		Limit:    int32(limit),
+	}, nil
+}
+
+// FromLabelNamesCardinalityRequest unpacks a LabelNamesCardinalityRequest proto
+func FromLabelNamesCardinalityRequest(req *LabelNamesCardinalityRequest) ([]*labels.Matcher, int, error) {
+	matchers, err := fromLabelMatchers(req.Matchers)
+	if err != nil {
+		return nil, 0, err
+	}
+	return matchers, int(req.Limit), nil
+}
+
+// ToLabelValuesCardinalityRequest builds a LabelValuesCardinalityRequest proto
+func ToLabelValuesCardinalityRequest(labelName string, matchers []*labels.Matcher, limit int) (*LabelValuesCardinalityRequest, error) {
+	ms, err := toLabelMatchers(matchers)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LabelValuesCardinalityRequest{
+		LabelName: labelName,
+		Matchers:  ms,
+		Limit:     int32(limit),
+	}, nil
+}
+
+// FromLabelValuesCardinalityRequest unpacks a LabelValuesCardinalityRequest proto
+func FromLabelValuesCardinalityRequest(req *LabelValuesCardinalityRequest) (string, []*labels.Matcher, int, error) {
+	matchers, err := fromLabelMatchers(req.Matchers)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	return req.LabelName, matchers, int(req.Limit), nil
+}
+
 // FromMetricsForLabelMatchersResponse unpacks a MetricsForLabelMatchersResponse proto
 func FromMetricsForLabelMatchersResponse(resp *MetricsForLabelMatchersResponse) []model.Metric {
 	metrics := []model.Metric{}
@@ -0,0 +1,252 @@
+package client
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/cortexproject/cortex/pkg/util/grpcclient"
+)
+
+var ingesterClientInflightRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "cortex",
+	Name:      "ingester_client_inflight_requests",
+	Help:      "Current number of in-flight requests to each ingester.",
+}, []string{"ingester"})
+
+// The number of clients sharing the in-flight requests series of each ingester, so that
+// the series is removed only when the last client connected to the ingester is closed.
+var (
+	inflightRequestsRefsMtx sync.Mutex
+	inflightRequestsRefs    = map[string]int{}
+)
+
+func acquireInflightRequestsGauge(addr string) prometheus.Gauge {
+	inflightRequestsRefsMtx.Lock()
+	defer inflightRequestsRefsMtx.Unlock()
+
+	inflightRequestsRefs[addr]++
+	return ingesterClientInflightRequests.WithLabelValues(addr)
+}
+
+func releaseInflightRequestsGauge(addr string) {
+	inflightRequestsRefsMtx.Lock()
+	defer inflightRequestsRefsMtx.Unlock()
+
+	inflightRequestsRefs[addr]--
+	if inflightRequestsRefs[addr] <= 0 {
+		delete(inflightRequestsRefs, addr)
+		ingesterClientInflightRequests.DeleteLabelValues(addr)
+	}
+}
+
+// pooledConn is a gRPC connection to an ingester, keeping track of its in-flight requests.
+type pooledConn struct {
+	IngesterClient
+	grpc_health_v1.HealthClient
+
+	conn     *grpc.ClientConn
+	inflight atomic.Int64
+}
+
+// connPoolClient is a HealthAndIngesterClient balancing the requests across a pool of
+// gRPC connections to the same ingester.
+type connPoolClient struct {
+	addr  string
+	conns []*pooledConn
+
+	// Used to pick the first connection to consider, so that the requests are spread
+	// across the connections with the same number of in-flight requests.
+	next atomic.Uint32
+}
+
+func newConnPoolClient(addr string, cfg Config) (*connPoolClient, error) {
+	c := &connPoolClient{
+		addr:  addr,
+		conns: make([]*pooledConn, 0, cfg.ConnectionsPerTarget),
+	}
+
+	inflight := acquireInflightRequestsGauge(addr)
+
+	for i := 0; i < cfg.ConnectionsPerTarget; i++ {
+		pc := &pooledConn{}
+
+		unary, stream := grpcclient.Instrument(ingesterClientRequestDuration)
+		unary = append(unary, inflightUnaryClientInterceptor(&pc.inflight, inflight))
+		stream = append(stream, inflightStreamClientInterceptor(&pc.inflight, inflight))
+
+		dialOpts, err := cfg.GRPCClientConfig.DialOption(unary, stream)
+		if err == nil {
+			pc.conn, err = grpc.Dial(addr, dialOpts...)
+		}
+		if err != nil {
+			// Close the connections already opened.
+			_ = c.Close()
+			return nil, err
+		}
+
+		pc.IngesterClient = NewIngesterClient(pc.conn)
+		pc.HealthClient = grpc_health_v1.NewHealthClient(pc.conn)
+		c.conns = append(c.conns, pc)
+	}
+
+	return c, nil
+}
+
+// pick returns the connection with the fewest in-flight requests, among the connections
+// which are not failing. If all connections are failing, all of them are considered.
+func (c *connPoolClient) pick() *pooledConn {
+	if len(c.conns) == 1 {
+		return c.conns[0]
+	}
+
+	var (
+		picked        *pooledConn
+		pickedHealthy bool
+		start         = int(c.next.Inc() % uint32(len(c.conns)))
+	)
+
+	for i := 0; i < len(c.conns); i++ {
+		pc := c.conns[(start+i)%len(c.conns)]
+		state := pc.conn.GetState()
+		healthy := state != connectivity.TransientFailure && state != connectivity.Shutdown
+
+		switch {
+		case picked == nil, healthy && !pickedHealthy:
+			picked, pickedHealthy = pc, healthy
+		case healthy == pickedHealthy && pc.inflight.Load() < picked.inflight.Load():
+			picked = pc
+		}
+	}
+
+	return picked
+}
+
+func (c *connPoolClient) Push(ctx context.Context, in *WriteRequest, opts ...grpc.CallOption) (*WriteResponse, error) {
+	return c.pick().Push(ctx, in, opts...)
+}
+
+func (c *connPoolClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	return c.pick().Query(ctx, in, opts...)
+}
+
+func (c *connPoolClient) QueryStream(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (Ingester_QueryStreamClient, error) {
+	return c.pick().QueryStream(ctx, in, opts...)
+}
+
+func (c *connPoolClient) LabelValues(ctx context.Context, in *LabelValuesRequest, opts ...grpc.CallOption) (*LabelValuesResponse, error) {
+	return c.pick().LabelValues(ctx, in, opts...)
+}
+
+func (c *connPoolClient) LabelNames(ctx context.Context, in *LabelNamesRequest, opts ...grpc.CallOption) (*LabelNamesResponse, error) {
+	return c.pick().LabelNames(ctx, in, opts...)
+}
+
+func (c *connPoolClient) UserStats(ctx context.Context, in *UserStatsRequest, opts ...grpc.CallOption) (*UserStatsResponse, error) {
+	return c.pick().UserStats(ctx, in, opts...)
+}
+
+func (c *connPoolClient) AllUserStats(ctx context.Context, in *UserStatsRequest, opts ...grpc.CallOption) (*UsersStatsResponse, error) {
+	return c.pick().AllUserStats(ctx, in, opts...)
+}
+
+func (c *connPoolClient) MetricsForLabelMatchers(ctx context.Context, in *MetricsForLabelMatchersRequest, opts ...grpc.CallOption) (*MetricsForLabelMatchersResponse, error) {
+	return c.pick().MetricsForLabelMatchers(ctx, in, opts...)
+}
+
+func (c *connPoolClient) MetricsMetadata(ctx context.Context, in *MetricsMetadataRequest, opts ...grpc.CallOption) (*MetricsMetadataResponse, error) {
+	return c.pick().MetricsMetadata(ctx, in, opts...)
+}
+
+func (c *connPoolClient) LabelNamesCardinality(ctx context.Context, in *LabelNamesCardinalityRequest, opts ...grpc.CallOption) (*LabelNamesCardinalityResponse, error) {
+	return c.pick().LabelNamesCardinality(ctx, in, opts...)
+}
+
+func (c *connPoolClient) LabelValuesCardinality(ctx context.Context, in *LabelValuesCardinalityRequest, opts ...grpc.CallOption) (*LabelValuesCardinalityResponse, error) {
+	return c.pick().LabelValuesCardinality(ctx, in, opts...)
+}
+
+func (c *connPoolClient) TransferChunks(ctx context.Context, opts ...grpc.CallOption) (Ingester_TransferChunksClient, error) {
+	return c.pick().TransferChunks(ctx, opts...)
+}
+
+// Check implements grpc_health_v1.HealthClient. The health check is run on each connection
+// and fails if any of them fails, so that the whole client gets replaced by the pool.
+func (c *connPoolClient) Check(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (*grpc_health_v1.HealthCheckResponse, error) {
+	var resp *grpc_health_v1.HealthCheckResponse
+
+	for _, pc := range c.conns {
+		var err error
+		resp, err = pc.Check(ctx, in, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			return resp, nil
+		}
+	}
+
+	return resp, nil
+}
+
+// Watch implements grpc_health_v1.HealthClient.
+func (c *connPoolClient) Watch(ctx context.Context, in *grpc_health_v1.HealthCheckRequest, opts ...grpc.CallOption) (grpc_health_v1.Health_WatchClient, error) {
+	return c.pick().Watch(ctx, in, opts...)
+}
+
+// Close closes all the connections of the pool.
+func (c *connPoolClient) Close() error {
+	var firstErr error
+	for _, pc := range c.conns {
+		if err := pc.conn.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	releaseInflightRequestsGauge(c.addr)
+	return firstErr
+}
+
+func inflightUnaryClientInterceptor(connInflight *atomic.Int64, targetInflight prometheus.Gauge) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		connInflight.Inc()
+		targetInflight.Inc()
+		defer func() {
+			connInflight.Dec()
+			targetInflight.Dec()
+		}()
+
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func inflightStreamClientInterceptor(connInflight *atomic.Int64, targetInflight prometheus.Gauge) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		connInflight.Inc()
+		targetInflight.Inc()
+		done := func() {
+			connInflight.Dec()
+			targetInflight.Dec()
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			done()
+			return nil, err
+		}
+
+		// The stream context is canceled once the stream is finished.
+		go func() {
+			<-stream.Context().Done()
+			done()
+		}()
+
+		return stream, nil
+	}
+}
@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.Validate(log.NewNopLogger()))
+
+	cfg.ConnectionsPerTarget = 0
+	require.Equal(t, errInvalidConnectionsPerTarget, cfg.Validate(log.NewNopLogger()))
+}
+
+func TestMakeIngesterClient_ShouldBalanceRequestsAcrossConnections(t *testing.T) {
+	const numConns = 3
+
+	listen, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	// Block the Push requests until released, so that they're in-flight.
+	release := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(numConns)
+
+	serverMock := &IngesterServerMock{}
+	serverMock.On("Push", mock.Anything, mock.Anything).Return(&WriteResponse{}, nil).Run(func(args mock.Arguments) {
+		wg.Done()
+		<-release
+	})
+
+	server := grpc.NewServer()
+	RegisterIngesterServer(server, serverMock)
+	grpc_health_v1.RegisterHealthServer(server, health.NewServer())
+	go server.Serve(listen) //nolint:errcheck
+	defer server.Stop()
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.ConnectionsPerTarget = numConns
+
+	addr := listen.Addr().String()
+	c, err := MakeIngesterClient(addr, cfg)
+	require.NoError(t, err)
+
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+
+	resp, err := c.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, resp.Status)
+
+	done := make(chan struct{}, numConns)
+	for i := 0; i < numConns; i++ {
+		go func() {
+			_, err := c.Push(ctx, &WriteRequest{})
+			assert.NoError(t, err)
+			done <- struct{}{}
+		}()
+
+		// Wait until the request is in-flight before sending the next one.
+		test.Poll(t, time.Second, float64(i+1), func() interface{} {
+			return testutil.ToFloat64(ingesterClientInflightRequests.WithLabelValues(addr))
+		})
+	}
+	wg.Wait()
+
+	// Each request should have been sent through a different connection.
+	for _, pc := range c.(*connPoolClient).conns {
+		assert.Equal(t, int64(1), pc.inflight.Load())
+	}
+
+	close(release)
+	for i := 0; i < numConns; i++ {
+		<-done
+	}
+
+	assert.Equal(t, float64(0), testutil.ToFloat64(ingesterClientInflightRequests.WithLabelValues(addr)))
+	require.NoError(t, c.Close())
+	assert.Equal(t, 0, testutil.CollectAndCount(ingesterClientInflightRequests))
+}
@@ -1599,6 +1599,328 @@ func (m *TimeSeriesFile) GetData() []byte {
 	return nil
 }
 
+type LabelNamesCardinalityRequest struct {
+	Matchers []*LabelMatcher `protobuf:"bytes,1,rep,name=matchers,proto3" json:"matchers,omitempty"`
+	Limit    int32           `protobuf:"varint,2,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (m *LabelNamesCardinalityRequest) Reset()      { *m = LabelNamesCardinalityRequest{} }
+func (*LabelNamesCardinalityRequest) ProtoMessage() {}
+func (*LabelNamesCardinalityRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_893a47d0a749d749, []int{30}
+}
+func (m *LabelNamesCardinalityRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LabelNamesCardinalityRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LabelNamesCardinalityRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LabelNamesCardinalityRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelNamesCardinalityRequest.Merge(m, src)
+}
+func (m *LabelNamesCardinalityRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *LabelNamesCardinalityRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelNamesCardinalityRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelNamesCardinalityRequest proto.InternalMessageInfo
+
+func (m *LabelNamesCardinalityRequest) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+func (m *LabelNamesCardinalityRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type LabelNamesCardinalityResponse struct {
+	SeriesCountTotal uint64                  `protobuf:"varint,1,opt,name=series_count_total,json=seriesCountTotal,proto3" json:"series_count_total,omitempty"`
+	LabelNames       []*LabelNameCardinality `protobuf:"bytes,2,rep,name=label_names,json=labelNames,proto3" json:"label_names,omitempty"`
+}
+
+func (m *LabelNamesCardinalityResponse) Reset()      { *m = LabelNamesCardinalityResponse{} }
+func (*LabelNamesCardinalityResponse) ProtoMessage() {}
+func (*LabelNamesCardinalityResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_893a47d0a749d749, []int{31}
+}
+func (m *LabelNamesCardinalityResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LabelNamesCardinalityResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LabelNamesCardinalityResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LabelNamesCardinalityResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelNamesCardinalityResponse.Merge(m, src)
+}
+func (m *LabelNamesCardinalityResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *LabelNamesCardinalityResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelNamesCardinalityResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelNamesCardinalityResponse proto.InternalMessageInfo
+
+func (m *LabelNamesCardinalityResponse) GetSeriesCountTotal() uint64 {
+	if m != nil {
+		return m.SeriesCountTotal
+	}
+	return 0
+}
+
+func (m *LabelNamesCardinalityResponse) GetLabelNames() []*LabelNameCardinality {
+	if m != nil {
+		return m.LabelNames
+	}
+	return nil
+}
+
+type LabelNameCardinality struct {
+	LabelName        string `protobuf:"bytes,1,opt,name=label_name,json=labelName,proto3" json:"label_name,omitempty"`
+	SeriesCount      uint64 `protobuf:"varint,2,opt,name=series_count,json=seriesCount,proto3" json:"series_count,omitempty"`
+	LabelValuesCount uint64 `protobuf:"varint,3,opt,name=label_values_count,json=labelValuesCount,proto3" json:"label_values_count,omitempty"`
+}
+
+func (m *LabelNameCardinality) Reset()      { *m = LabelNameCardinality{} }
+func (*LabelNameCardinality) ProtoMessage() {}
+func (*LabelNameCardinality) Descriptor() ([]byte, []int) {
+	return fileDescriptor_893a47d0a749d749, []int{32}
+}
+func (m *LabelNameCardinality) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LabelNameCardinality) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LabelNameCardinality.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LabelNameCardinality) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelNameCardinality.Merge(m, src)
+}
+func (m *LabelNameCardinality) XXX_Size() int {
+	return m.Size()
+}
+func (m *LabelNameCardinality) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelNameCardinality.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelNameCardinality proto.InternalMessageInfo
+
+func (m *LabelNameCardinality) GetLabelName() string {
+	if m != nil {
+		return m.LabelName
+	}
+	return ""
+}
+
+func (m *LabelNameCardinality) GetSeriesCount() uint64 {
+	if m != nil {
+		return m.SeriesCount
+	}
+	return 0
+}
+
+func (m *LabelNameCardinality) GetLabelValuesCount() uint64 {
+	if m != nil {
+		return m.LabelValuesCount
+	}
+	return 0
+}
+
+type LabelValuesCardinalityRequest struct {
+	LabelName string          `protobuf:"bytes,1,opt,name=label_name,json=labelName,proto3" json:"label_name,omitempty"`
+	Matchers  []*LabelMatcher `protobuf:"bytes,2,rep,name=matchers,proto3" json:"matchers,omitempty"`
+	Limit     int32           `protobuf:"varint,3,opt,name=limit,proto3" json:"limit,omitempty"`
+}
+
+func (m *LabelValuesCardinalityRequest) Reset()      { *m = LabelValuesCardinalityRequest{} }
+func (*LabelValuesCardinalityRequest) ProtoMessage() {}
+func (*LabelValuesCardinalityRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_893a47d0a749d749, []int{33}
+}
+func (m *LabelValuesCardinalityRequest) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LabelValuesCardinalityRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LabelValuesCardinalityRequest.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LabelValuesCardinalityRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelValuesCardinalityRequest.Merge(m, src)
+}
+func (m *LabelValuesCardinalityRequest) XXX_Size() int {
+	return m.Size()
+}
+func (m *LabelValuesCardinalityRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelValuesCardinalityRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelValuesCardinalityRequest proto.InternalMessageInfo
+
+func (m *LabelValuesCardinalityRequest) GetLabelName() string {
+	if m != nil {
+		return m.LabelName
+	}
+	return ""
+}
+
+func (m *LabelValuesCardinalityRequest) GetMatchers() []*LabelMatcher {
+	if m != nil {
+		return m.Matchers
+	}
+	return nil
+}
+
+func (m *LabelValuesCardinalityRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+type LabelValuesCardinalityResponse struct {
+	SeriesCountTotal uint64                   `protobuf:"varint,1,opt,name=series_count_total,json=seriesCountTotal,proto3" json:"series_count_total,omitempty"`
+	LabelValues      []*LabelValueCardinality `protobuf:"bytes,2,rep,name=label_values,json=labelValues,proto3" json:"label_values,omitempty"`
+}
+
+func (m *LabelValuesCardinalityResponse) Reset()      { *m = LabelValuesCardinalityResponse{} }
+func (*LabelValuesCardinalityResponse) ProtoMessage() {}
+func (*LabelValuesCardinalityResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_893a47d0a749d749, []int{34}
+}
+func (m *LabelValuesCardinalityResponse) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LabelValuesCardinalityResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LabelValuesCardinalityResponse.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LabelValuesCardinalityResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelValuesCardinalityResponse.Merge(m, src)
+}
+func (m *LabelValuesCardinalityResponse) XXX_Size() int {
+	return m.Size()
+}
+func (m *LabelValuesCardinalityResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelValuesCardinalityResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelValuesCardinalityResponse proto.InternalMessageInfo
+
+func (m *LabelValuesCardinalityResponse) GetSeriesCountTotal() uint64 {
+	if m != nil {
+		return m.SeriesCountTotal
+	}
+	return 0
+}
+
+func (m *LabelValuesCardinalityResponse) GetLabelValues() []*LabelValueCardinality {
+	if m != nil {
+		return m.LabelValues
+	}
+	return nil
+}
+
+type LabelValueCardinality struct {
+	LabelValue  string `protobuf:"bytes,1,opt,name=label_value,json=labelValue,proto3" json:"label_value,omitempty"`
+	SeriesCount uint64 `protobuf:"varint,2,opt,name=series_count,json=seriesCount,proto3" json:"series_count,omitempty"`
+}
+
+func (m *LabelValueCardinality) Reset()      { *m = LabelValueCardinality{} }
+func (*LabelValueCardinality) ProtoMessage() {}
+func (*LabelValueCardinality) Descriptor() ([]byte, []int) {
+	return fileDescriptor_893a47d0a749d749, []int{35}
+}
+func (m *LabelValueCardinality) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *LabelValueCardinality) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_LabelValueCardinality.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *LabelValueCardinality) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_LabelValueCardinality.Merge(m, src)
+}
+func (m *LabelValueCardinality) XXX_Size() int {
+	return m.Size()
+}
+func (m *LabelValueCardinality) XXX_DiscardUnknown() {
+	xxx_messageInfo_LabelValueCardinality.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_LabelValueCardinality proto.InternalMessageInfo
+
+func (m *LabelValueCardinality) GetLabelValue() string {
+	if m != nil {
+		return m.LabelValue
+	}
+	return ""
+}
+
+func (m *LabelValueCardinality) GetSeriesCount() uint64 {
+	if m != nil {
+		return m.SeriesCount
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterEnum("cortex.MatchType", MatchType_name, MatchType_value)
 	proto.RegisterEnum("cortex.WriteRequest_SourceEnum", WriteRequest_SourceEnum_name, WriteRequest_SourceEnum_value)
@@ -1638,100 +1960,113 @@ func init() {
 func init() { proto.RegisterFile("cortex.proto", fileDescriptor_893a47d0a749d749) }
 
 var fileDescriptor_893a47d0a749d749 = []byte{
-	// 1478 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x57, 0xcf, 0x6f, 0x1b, 0x45,
-	0x14, 0xde, 0xf1, 0xaf, 0xc4, 0xcf, 0x8e, 0xb3, 0x99, 0xa4, 0xad, 0xeb, 0x8a, 0x75, 0x3b, 0x52,
-	0x4b, 0x04, 0x34, 0x2d, 0x41, 0x85, 0x1c, 0x40, 0x95, 0xd3, 0x3a, 0xa9, 0x21, 0x76, 0xd2, 0xb1,
-	0x43, 0x01, 0x09, 0x59, 0x1b, 0x7b, 0x92, 0xac, 0xba, 0xbb, 0x76, 0xf7, 0x07, 0x22, 0x07, 0x24,
-	0x24, 0x8e, 0x1c, 0xe8, 0xb1, 0x7f, 0x02, 0x67, 0x2e, 0xdc, 0x39, 0xf5, 0xd8, 0x63, 0xc5, 0xa1,
-	0xa2, 0xee, 0x85, 0x1b, 0x15, 0x7f, 0x01, 0xda, 0x99, 0xd9, 0xf5, 0xae, 0x6b, 0x43, 0x0b, 0xf4,
-	0xe6, 0x79, 0xef, 0x9b, 0x6f, 0xde, 0x7e, 0xf3, 0xe6, 0xbd, 0x67, 0x28, 0xf6, 0x06, 0x8e, 0xc7,
-	0xbe, 0x5e, 0x1b, 0x3a, 0x03, 0x6f, 0x80, 0x73, 0x62, 0x55, 0xb9, 0x7c, 0x64, 0x78, 0xc7, 0xfe,
-	0xc1, 0x5a, 0x6f, 0x60, 0x5d, 0x39, 0x1a, 0x1c, 0x0d, 0xae, 0x70, 0xf7, 0x81, 0x7f, 0xc8, 0x57,
-	0x7c, 0xc1, 0x7f, 0x89, 0x6d, 0xe4, 0x4f, 0x04, 0xc5, 0x3b, 0x8e, 0xe1, 0x31, 0xca, 0xee, 0xf9,
-	0xcc, 0xf5, 0x70, 0x0b, 0xc0, 0x33, 0x2c, 0xe6, 0x32, 0xc7, 0x60, 0x6e, 0x19, 0x9d, 0x4f, 0xaf,
-	0x16, 0xd6, 0xf1, 0x9a, 0x3c, 0xaa, 0x63, 0x58, 0xac, 0xcd, 0x3d, 0x9b, 0x95, 0x87, 0x4f, 0xaa,
-	0xca, 0xaf, 0x4f, 0xaa, 0x78, 0xcf, 0x61, 0xba, 0x69, 0x0e, 0x7a, 0x9d, 0x68, 0x17, 0x8d, 0x31,
-	0xe0, 0x0f, 0x20, 0xd7, 0x1e, 0xf8, 0x4e, 0x8f, 0x95, 0x53, 0xe7, 0xd1, 0x6a, 0x69, 0xbd, 0x1a,
-	0x72, 0xc5, 0x4f, 0x5d, 0x13, 0x90, 0xba, 0xed, 0x5b, 0x54, 0xc2, 0xf1, 0x06, 0xcc, 0x5b, 0xcc,
-	0xd3, 0xfb, 0xba, 0xa7, 0x97, 0xd3, 0x3c, 0x8c, 0xd3, 0xe1, 0xd6, 0x26, 0xf3, 0x1c, 0xa3, 0xd7,
-	0x94, 0xde, 0xcd, 0xcc, 0xc3, 0x27, 0x55, 0x44, 0x23, 0x34, 0xa9, 0x02, 0x8c, 0xf9, 0xf0, 0x1c,
-	0xa4, 0x6b, 0x7b, 0x0d, 0x55, 0xc1, 0xf3, 0x90, 0xa1, 0xfb, 0x3b, 0x75, 0x15, 0x91, 0x45, 0x58,
-	0x90, 0xa7, 0xbb, 0xc3, 0x81, 0xed, 0x32, 0xf2, 0x11, 0x14, 0x28, 0xd3, 0xfb, 0xa1, 0x06, 0x6b,
-	0x30, 0x77, 0xcf, 0x8f, 0x0b, 0xb0, 0x12, 0x9e, 0x7c, 0xdb, 0x67, 0xce, 0x89, 0x84, 0xd1, 0x10,
-	0x44, 0xae, 0x43, 0x51, 0x6c, 0x17, 0x74, 0xf8, 0x0a, 0xcc, 0x39, 0xcc, 0xf5, 0x4d, 0x2f, 0xdc,
-	0x7f, 0x6a, 0x62, 0xbf, 0xc0, 0xd1, 0x10, 0x45, 0x1e, 0x20, 0x28, 0xc6, 0xa9, 0xf1, 0x3b, 0x80,
-	0x5d, 0x4f, 0x77, 0xbc, 0x2e, 0x57, 0xd2, 0xd3, 0xad, 0x61, 0xd7, 0x0a, 0xc8, 0xd0, 0x6a, 0x9a,
-	0xaa, 0xdc, 0xd3, 0x09, 0x1d, 0x4d, 0x17, 0xaf, 0x82, 0xca, 0xec, 0x7e, 0x12, 0x9b, 0xe2, 0xd8,
-	0x12, 0xb3, 0xfb, 0x71, 0xe4, 0x55, 0x98, 0xb7, 0x74, 0xaf, 0x77, 0xcc, 0x1c, 0x57, 0x8a, 0x1a,
-	0x7d, 0xda, 0x8e, 0x7e, 0xc0, 0xcc, 0xa6, 0x70, 0xd2, 0x08, 0x45, 0x1a, 0xb0, 0x90, 0x08, 0x1a,
-	0x6f, 0xbc, 0x64, 0x82, 0x04, 0xb7, 0xa2, 0xc4, 0x53, 0x81, 0xdc, 0x47, 0xb0, 0xcc, 0xb9, 0xda,
-	0x9e, 0xc3, 0x74, 0x2b, 0x62, 0xbc, 0x0e, 0x85, 0xde, 0xb1, 0x6f, 0xdf, 0x4d, 0x50, 0x9e, 0x79,
-	0x91, 0xf2, 0x46, 0x00, 0x92, 0xbc, 0xf1, 0x1d, 0x13, 0x21, 0xa5, 0x5e, 0x21, 0xa4, 0xef, 0x11,
-	0x60, 0xfe, 0xe1, 0x9f, 0xea, 0xa6, 0xcf, 0xdc, 0x50, 0xfe, 0x37, 0x00, 0xcc, 0xc0, 0xda, 0xb5,
-	0x75, 0x8b, 0x71, 0xd9, 0xf3, 0x34, 0xcf, 0x2d, 0x2d, 0xdd, 0x62, 0x33, 0x6e, 0x27, 0xf5, 0x0a,
-	0xb7, 0x93, 0x9e, 0x76, 0x3b, 0x64, 0x03, 0x96, 0x13, 0xc1, 0x48, 0x7d, 0x2e, 0x40, 0x51, 0x44,
-	0xf3, 0x15, 0xb7, 0x73, 0x81, 0xf2, 0xb4, 0x60, 0x8e, 0xa1, 0xe4, 0x2e, 0x2c, 0xed, 0x84, 0xe1,
-	0xb9, 0xaf, 0x39, 0x89, 0xc8, 0x35, 0xa9, 0x99, 0x3c, 0x4c, 0x46, 0x59, 0x85, 0xc2, 0x58, 0xb3,
-	0x30, 0x48, 0x88, 0x44, 0x73, 0x09, 0x06, 0x75, 0xdf, 0x65, 0x4e, 0xdb, 0xd3, 0xbd, 0x30, 0x44,
-	0xf2, 0x33, 0x82, 0xa5, 0x98, 0x51, 0x52, 0x5d, 0x84, 0x92, 0x61, 0x1f, 0x31, 0xd7, 0x33, 0x06,
-	0x76, 0xd7, 0xd1, 0x3d, 0x71, 0x05, 0x88, 0x2e, 0x44, 0x56, 0xaa, 0x7b, 0x2c, 0xb8, 0x25, 0xdb,
-	0xb7, 0xba, 0xd1, 0xb5, 0xa3, 0xd5, 0x0c, 0xcd, 0xdb, 0xbe, 0x25, 0x6e, 0x3b, 0xf8, 0x7c, 0x7d,
-	0x68, 0x74, 0x27, 0x98, 0xd2, 0x9c, 0x49, 0xd5, 0x87, 0x46, 0x23, 0x41, 0xb6, 0x06, 0xcb, 0x8e,
-	0x6f, 0xb2, 0x49, 0x78, 0x86, 0xc3, 0x97, 0x02, 0x57, 0x02, 0x4f, 0xbe, 0x84, 0xe5, 0x20, 0xf0,
-	0xc6, 0xcd, 0x64, 0xe8, 0x67, 0x60, 0xce, 0x77, 0x99, 0xd3, 0x35, 0xfa, 0x32, 0x6d, 0x72, 0xc1,
-	0xb2, 0xd1, 0xc7, 0x97, 0x21, 0xc3, 0x4b, 0x59, 0x10, 0x66, 0x61, 0xfd, 0x6c, 0x98, 0x9d, 0x2f,
-	0x7c, 0x3c, 0xe5, 0x30, 0xb2, 0x0d, 0x38, 0x70, 0xb9, 0x49, 0xf6, 0x77, 0x21, 0xeb, 0x06, 0x06,
-	0xf9, 0x46, 0xce, 0xc5, 0x59, 0x26, 0x22, 0xa1, 0x02, 0x49, 0x7e, 0x42, 0xa0, 0x89, 0x7a, 0xe9,
-	0x6e, 0x0d, 0x9c, 0xf8, 0x23, 0x7f, 0xdd, 0x79, 0x82, 0x37, 0xa0, 0x18, 0x96, 0x91, 0xae, 0xcb,
-	0x3c, 0x59, 0x70, 0x4e, 0x4d, 0x2b, 0x38, 0x2e, 0x2d, 0x84, 0xd0, 0x36, 0xf3, 0x48, 0x03, 0xaa,
-	0x33, 0x63, 0x96, 0x52, 0x5c, 0x82, 0x9c, 0xc5, 0x21, 0x52, 0x8b, 0x52, 0xb2, 0x39, 0x50, 0xe9,
-	0x25, 0x65, 0x38, 0x2d, 0xa9, 0xc2, 0x7e, 0x11, 0xe6, 0x5e, 0x13, 0xce, 0xbc, 0xe0, 0x91, 0xe4,
-	0xeb, 0xb1, 0xde, 0x83, 0xfe, 0xae, 0xf7, 0xc4, 0xba, 0xce, 0x2f, 0x08, 0x16, 0x27, 0x6a, 0x55,
-	0xa0, 0xd5, 0xa1, 0x33, 0xb0, 0x64, 0x52, 0xc5, 0xd3, 0xa2, 0x14, 0xd8, 0x1b, 0xd2, 0xdc, 0xe8,
-	0xc7, 0xf3, 0x26, 0x95, 0xc8, 0x9b, 0xeb, 0x90, 0xe3, 0x6f, 0x28, 0xac, 0xd7, 0x4b, 0x09, 0xf9,
-	0xf6, 0x74, 0xc3, 0xd9, 0x5c, 0x91, 0xad, 0xb8, 0xc8, 0x4d, 0xb5, 0xbe, 0x3e, 0xf4, 0x98, 0x43,
-	0xe5, 0x36, 0xfc, 0x36, 0xe4, 0x44, 0xad, 0x2c, 0x67, 0x38, 0xc1, 0x42, 0x48, 0x10, 0x2f, 0xa7,
-	0x12, 0x42, 0x7e, 0x40, 0x90, 0x15, 0xa1, 0xbf, 0xae, 0xa4, 0xa8, 0xc0, 0x3c, 0xb3, 0x7b, 0x83,
-	0xbe, 0x61, 0x1f, 0xf1, 0xb7, 0x98, 0xa5, 0xd1, 0x1a, 0x63, 0xf9, 0x46, 0x82, 0x47, 0x57, 0x94,
-	0x0f, 0xa1, 0x0c, 0xa7, 0x3b, 0x8e, 0x6e, 0xbb, 0x87, 0xcc, 0xe1, 0x81, 0x45, 0x19, 0x40, 0xbe,
-	0x01, 0x18, 0xeb, 0x1d, 0xd3, 0x09, 0xfd, 0x3b, 0x9d, 0xd6, 0x60, 0xce, 0xd5, 0xad, 0xa1, 0x19,
-	0x75, 0x90, 0x28, 0xa3, 0xda, 0xdc, 0x2c, 0x95, 0x0a, 0x41, 0xe4, 0x1a, 0xe4, 0x23, 0xea, 0x20,
-	0xf2, 0xa8, 0x55, 0x14, 0x29, 0xff, 0x8d, 0x57, 0x20, 0xcb, 0x0b, 0x36, 0x17, 0xa2, 0x48, 0xc5,
-	0x82, 0xd4, 0x20, 0x27, 0xf8, 0xc6, 0x7e, 0x51, 0xdc, 0xc4, 0x22, 0x28, 0xf6, 0x53, 0x54, 0x2c,
-	0x78, 0xb1, 0xfa, 0x5b, 0x83, 0x85, 0xc4, 0x9b, 0x48, 0x74, 0x75, 0xf4, 0x52, 0x5d, 0xfd, 0x41,
-	0x0a, 0x4a, 0xc9, 0x4c, 0xc6, 0xd7, 0x20, 0xe3, 0x9d, 0x0c, 0x45, 0x34, 0xa5, 0xf5, 0x0b, 0xd3,
-	0xf3, 0x5d, 0x2e, 0x3b, 0x27, 0x43, 0x46, 0x39, 0x3c, 0xc8, 0x13, 0xf1, 0xd2, 0xba, 0x87, 0xba,
-	0x65, 0x98, 0x27, 0xa2, 0x65, 0x8a, 0x1c, 0x56, 0x85, 0x67, 0x8b, 0x3b, 0x78, 0xe7, 0xc4, 0x90,
-	0x39, 0x66, 0xe6, 0x90, 0xdf, 0x70, 0x9e, 0xf2, 0xdf, 0x81, 0xcd, 0xb7, 0x0d, 0xaf, 0x9c, 0x15,
-	0xb6, 0xe0, 0x37, 0x39, 0x01, 0x18, 0x9f, 0x84, 0x0b, 0x30, 0xb7, 0xdf, 0xfa, 0xa4, 0xb5, 0x7b,
-	0xa7, 0xa5, 0x2a, 0xc1, 0xe2, 0xc6, 0xee, 0x7e, 0xab, 0x53, 0xa7, 0x2a, 0xc2, 0x79, 0xc8, 0x6e,
-	0xd7, 0xf6, 0xb7, 0xeb, 0x6a, 0x0a, 0x2f, 0x40, 0xfe, 0x56, 0xa3, 0xdd, 0xd9, 0xdd, 0xa6, 0xb5,
-	0xa6, 0x9a, 0xc6, 0x18, 0x4a, 0xdc, 0x33, 0xb6, 0x65, 0x82, 0xad, 0xed, 0xfd, 0x66, 0xb3, 0x46,
-	0x3f, 0x57, 0xb3, 0xc1, 0x38, 0xd8, 0x68, 0x6d, 0xed, 0xaa, 0x39, 0x5c, 0x84, 0xf9, 0x76, 0xa7,
-	0xd6, 0xa9, 0xb7, 0xeb, 0x1d, 0x75, 0x8e, 0x34, 0x20, 0x27, 0x8e, 0xfe, 0xcf, 0x29, 0x45, 0xba,
-	0x50, 0x8c, 0xeb, 0x8f, 0x2f, 0x26, 0x24, 0x8e, 0xe8, 0xb8, 0x3b, 0x26, 0x69, 0x98, 0x4c, 0x42,
-	0xc4, 0x89, 0x64, 0x4a, 0x73, 0xa3, 0x4c, 0xa6, 0xef, 0x10, 0x94, 0xc6, 0x6f, 0x60, 0xcb, 0x30,
-	0xd9, 0xff, 0x51, 0x72, 0x2a, 0x30, 0x7f, 0x68, 0x98, 0x8c, 0xc7, 0x20, 0x8e, 0x8b, 0xd6, 0xd3,
-	0x9e, 0xe8, 0x5b, 0x1f, 0x43, 0x3e, 0xfa, 0x84, 0xe0, 0x46, 0xea, 0xb7, 0xf7, 0x6b, 0x3b, 0xaa,
-	0x12, 0xdc, 0x48, 0x6b, 0xb7, 0xd3, 0x15, 0x4b, 0x84, 0x17, 0xa1, 0x40, 0xeb, 0xdb, 0xf5, 0xcf,
-	0xba, 0xcd, 0x5a, 0xe7, 0xc6, 0x2d, 0x35, 0x15, 0x5c, 0x91, 0x30, 0xb4, 0x76, 0xa5, 0x2d, 0xbd,
-	0xfe, 0x47, 0x16, 0xe6, 0xc3, 0x18, 0x83, 0x94, 0xdc, 0xf3, 0xdd, 0x63, 0xbc, 0x32, 0xed, 0x3f,
-	0x43, 0xe5, 0xd4, 0x84, 0x55, 0x96, 0x05, 0x05, 0xbf, 0x0f, 0x59, 0x3e, 0x66, 0xe2, 0xa9, 0x63,
-	0x7b, 0x65, 0xfa, 0x30, 0x4e, 0x14, 0x7c, 0x13, 0x0a, 0xb1, 0xf1, 0x74, 0xc6, 0xee, 0x73, 0x09,
-	0x6b, 0x72, 0x92, 0x25, 0xca, 0x55, 0x84, 0x6f, 0x41, 0x21, 0x36, 0xc4, 0xe1, 0x4a, 0x22, 0x69,
-	0x12, 0x63, 0xe6, 0x98, 0x6b, 0xca, 0xd4, 0x47, 0x14, 0x5c, 0x07, 0x18, 0xcf, 0x59, 0xf8, 0x6c,
-	0x02, 0x1c, 0x1f, 0xf4, 0x2a, 0x95, 0x69, 0xae, 0x88, 0x66, 0x13, 0xf2, 0xd1, 0x94, 0x81, 0xcb,
-	0x53, 0x06, 0x0f, 0x41, 0x32, 0x7b, 0x24, 0x21, 0x0a, 0xde, 0x82, 0x62, 0xcd, 0x34, 0x5f, 0x86,
-	0xa6, 0x12, 0xf7, 0xb8, 0x93, 0x3c, 0x66, 0xd4, 0x73, 0x27, 0x1b, 0x3b, 0xbe, 0x94, 0xac, 0x38,
-	0xb3, 0xa6, 0x95, 0xca, 0x9b, 0xff, 0x88, 0x8b, 0x4e, 0xeb, 0xc0, 0xe2, 0x44, 0x87, 0xc7, 0xda,
-	0xc4, 0xee, 0x89, 0xa1, 0xa0, 0x52, 0x9d, 0xe9, 0x8f, 0x58, 0x9b, 0x50, 0x4a, 0x76, 0x24, 0x3c,
-	0xeb, 0xbf, 0x4a, 0x25, 0x3a, 0x6d, 0x46, 0x0b, 0x53, 0x56, 0xd1, 0xe6, 0x87, 0x8f, 0x9e, 0x6a,
-	0xca, 0xe3, 0xa7, 0x9a, 0xf2, 0xfc, 0xa9, 0x86, 0xbe, 0x1d, 0x69, 0xe8, 0xc7, 0x91, 0x86, 0x1e,
-	0x8e, 0x34, 0xf4, 0x68, 0xa4, 0xa1, 0xdf, 0x46, 0x1a, 0xfa, 0x7d, 0xa4, 0x29, 0xcf, 0x47, 0x1a,
-	0xba, 0xff, 0x4c, 0x53, 0x1e, 0x3d, 0xd3, 0x94, 0xc7, 0xcf, 0x34, 0xe5, 0x8b, 0x5c, 0xcf, 0x34,
-	0x98, 0xed, 0x1d, 0xe4, 0xf8, 0xdf, 0xf8, 0xf7, 0xfe, 0x0a, 0x00, 0x00, 0xff, 0xff, 0x0f, 0x73,
-	0x78, 0x05, 0x0d, 0x10, 0x00, 0x00,
+	// 1690 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x58, 0xcd, 0x6f, 0x1b, 0xc7,
+	0x15, 0xdf, 0xe1, 0xa7, 0xf8, 0x48, 0xd1, 0xeb, 0xb1, 0x6c, 0x33, 0x4c, 0xbc, 0x8c, 0x17, 0x95,
+	0x2b, 0xb4, 0x8d, 0x9c, 0xaa, 0x70, 0xab, 0x43, 0x03, 0x97, 0x52, 0x28, 0x99, 0xad, 0x48, 0x29,
+	0x4b, 0x2a, 0xe9, 0x07, 0x0a, 0x62, 0x45, 0x8e, 0xa4, 0x45, 0x76, 0x97, 0xcc, 0xee, 0x6c, 0x51,
+	0x1d, 0x0a, 0x14, 0x28, 0x90, 0x4b, 0x0b, 0xd4, 0xc7, 0xfc, 0x09, 0x3d, 0xf7, 0xd2, 0x7b, 0x4e,
+	0x3e, 0xfa, 0x18, 0xf4, 0x60, 0xd4, 0xf2, 0xa5, 0xc7, 0xa0, 0x7f, 0x41, 0x31, 0x1f, 0xbb, 0x9c,
+	0xe5, 0x47, 0x65, 0xa5, 0xf1, 0x8d, 0xf3, 0xde, 0x9b, 0xdf, 0x7b, 0xf3, 0xbe, 0x97, 0x50, 0x19,
+	0x8e, 0x03, 0x4a, 0x7e, 0xbf, 0x39, 0x09, 0xc6, 0x74, 0x8c, 0x0b, 0xe2, 0x54, 0x7f, 0xef, 0xcc,
+	0xa1, 0xe7, 0xd1, 0xc9, 0xe6, 0x70, 0xec, 0x3d, 0x3c, 0x1b, 0x9f, 0x8d, 0x1f, 0x72, 0xf6, 0x49,
+	0x74, 0xca, 0x4f, 0xfc, 0xc0, 0x7f, 0x89, 0x6b, 0xe6, 0x7f, 0x10, 0x54, 0x3e, 0x09, 0x1c, 0x4a,
+	0x2c, 0xf2, 0x59, 0x44, 0x42, 0x8a, 0xbb, 0x00, 0xd4, 0xf1, 0x48, 0x48, 0x02, 0x87, 0x84, 0x35,
+	0xf4, 0x6e, 0x76, 0xa3, 0xbc, 0x85, 0x37, 0xa5, 0xaa, 0xbe, 0xe3, 0x91, 0x1e, 0xe7, 0xec, 0xd4,
+	0x9f, 0xbd, 0x68, 0x68, 0xff, 0x7c, 0xd1, 0xc0, 0x47, 0x01, 0xb1, 0x5d, 0x77, 0x3c, 0xec, 0x27,
+	0xb7, 0x2c, 0x05, 0x01, 0xff, 0x04, 0x0a, 0xbd, 0x71, 0x14, 0x0c, 0x49, 0x2d, 0xf3, 0x2e, 0xda,
+	0xa8, 0x6e, 0x35, 0x62, 0x2c, 0x55, 0xeb, 0xa6, 0x10, 0x69, 0xf9, 0x91, 0x67, 0x49, 0x71, 0xbc,
+	0x0d, 0x2b, 0x1e, 0xa1, 0xf6, 0xc8, 0xa6, 0x76, 0x2d, 0xcb, 0xcd, 0xb8, 0x13, 0x5f, 0xed, 0x10,
+	0x1a, 0x38, 0xc3, 0x8e, 0xe4, 0xee, 0xe4, 0x9e, 0xbd, 0x68, 0x20, 0x2b, 0x91, 0x36, 0x1b, 0x00,
+	0x53, 0x3c, 0x5c, 0x84, 0x6c, 0xf3, 0xa8, 0xad, 0x6b, 0x78, 0x05, 0x72, 0xd6, 0xf1, 0x41, 0x4b,
+	0x47, 0xe6, 0x0d, 0x58, 0x95, 0xda, 0xc3, 0xc9, 0xd8, 0x0f, 0x89, 0xf9, 0x01, 0x94, 0x2d, 0x62,
+	0x8f, 0x62, 0x1f, 0x6c, 0x42, 0xf1, 0xb3, 0x48, 0x75, 0xc0, 0x5a, 0xac, 0xf9, 0xa3, 0x88, 0x04,
+	0x17, 0x52, 0xcc, 0x8a, 0x85, 0xcc, 0xc7, 0x50, 0x11, 0xd7, 0x05, 0x1c, 0x7e, 0x08, 0xc5, 0x80,
+	0x84, 0x91, 0x4b, 0xe3, 0xfb, 0xb7, 0x67, 0xee, 0x0b, 0x39, 0x2b, 0x96, 0x32, 0xbf, 0x40, 0x50,
+	0x51, 0xa1, 0xf1, 0x0f, 0x00, 0x87, 0xd4, 0x0e, 0xe8, 0x80, 0x7b, 0x92, 0xda, 0xde, 0x64, 0xe0,
+	0x31, 0x30, 0xb4, 0x91, 0xb5, 0x74, 0xce, 0xe9, 0xc7, 0x8c, 0x4e, 0x88, 0x37, 0x40, 0x27, 0xfe,
+	0x28, 0x2d, 0x9b, 0xe1, 0xb2, 0x55, 0xe2, 0x8f, 0x54, 0xc9, 0xf7, 0x61, 0xc5, 0xb3, 0xe9, 0xf0,
+	0x9c, 0x04, 0x61, 0x2d, 0x9b, 0x7e, 0xda, 0x81, 0x7d, 0x42, 0xdc, 0x8e, 0x60, 0x5a, 0x89, 0x94,
+	0xd9, 0x86, 0xd5, 0x94, 0xd1, 0x78, 0xfb, 0x35, 0x13, 0x84, 0x45, 0x45, 0x53, 0x53, 0xc1, 0x7c,
+	0x8a, 0xe0, 0x16, 0xc7, 0xea, 0xd1, 0x80, 0xd8, 0x5e, 0x82, 0xf8, 0x18, 0xca, 0xc3, 0xf3, 0xc8,
+	0xff, 0x34, 0x05, 0x79, 0x77, 0x1e, 0x72, 0x97, 0x09, 0x49, 0x5c, 0xf5, 0xc6, 0x8c, 0x49, 0x99,
+	0x6b, 0x98, 0xf4, 0x67, 0x04, 0x98, 0x3f, 0xfc, 0x63, 0xdb, 0x8d, 0x48, 0x18, 0xbb, 0xff, 0x1e,
+	0x80, 0xcb, 0xa8, 0x03, 0xdf, 0xf6, 0x08, 0x77, 0x7b, 0xc9, 0x2a, 0x71, 0x4a, 0xd7, 0xf6, 0xc8,
+	0x92, 0xe8, 0x64, 0xae, 0x11, 0x9d, 0xec, 0xa2, 0xe8, 0x98, 0xdb, 0x70, 0x2b, 0x65, 0x8c, 0xf4,
+	0xcf, 0x7d, 0xa8, 0x08, 0x6b, 0x7e, 0xc7, 0xe9, 0xdc, 0x41, 0x25, 0xab, 0xec, 0x4e, 0x45, 0xcd,
+	0x4f, 0xe1, 0xe6, 0x41, 0x6c, 0x5e, 0xf8, 0x86, 0x93, 0xc8, 0x7c, 0x04, 0x58, 0x55, 0x26, 0xad,
+	0x6c, 0x40, 0x79, 0xea, 0xb3, 0xd8, 0x48, 0x48, 0x9c, 0x16, 0x9a, 0x18, 0xf4, 0xe3, 0x90, 0x04,
+	0x3d, 0x6a, 0xd3, 0xd8, 0x44, 0xf3, 0x1f, 0x08, 0x6e, 0x2a, 0x44, 0x09, 0xb5, 0x0e, 0x55, 0xc7,
+	0x3f, 0x23, 0x21, 0x75, 0xc6, 0xfe, 0x20, 0xb0, 0xa9, 0x08, 0x01, 0xb2, 0x56, 0x13, 0xaa, 0x65,
+	0x53, 0xc2, 0xa2, 0xe4, 0x47, 0xde, 0x20, 0x09, 0x3b, 0xda, 0xc8, 0x59, 0x25, 0x3f, 0xf2, 0x44,
+	0xb4, 0xd9, 0xf3, 0xed, 0x89, 0x33, 0x98, 0x41, 0xca, 0x72, 0x24, 0xdd, 0x9e, 0x38, 0xed, 0x14,
+	0xd8, 0x26, 0xdc, 0x0a, 0x22, 0x97, 0xcc, 0x8a, 0xe7, 0xb8, 0xf8, 0x4d, 0xc6, 0x4a, 0xc9, 0x9b,
+	0xbf, 0x85, 0x5b, 0xcc, 0xf0, 0xf6, 0x87, 0x69, 0xd3, 0xef, 0x42, 0x31, 0x0a, 0x49, 0x30, 0x70,
+	0x46, 0x32, 0x6d, 0x0a, 0xec, 0xd8, 0x1e, 0xe1, 0xf7, 0x20, 0xc7, 0x5b, 0x19, 0x33, 0xb3, 0xbc,
+	0xf5, 0x56, 0x9c, 0x9d, 0x73, 0x8f, 0xb7, 0xb8, 0x98, 0xb9, 0x0f, 0x98, 0xb1, 0xc2, 0x34, 0xfa,
+	0x0f, 0x21, 0x1f, 0x32, 0x82, 0xac, 0x91, 0xb7, 0x55, 0x94, 0x19, 0x4b, 0x2c, 0x21, 0x69, 0xfe,
+	0x1d, 0x81, 0x21, 0xfa, 0x65, 0xb8, 0x37, 0x0e, 0xd4, 0x22, 0x7f, 0xd3, 0x79, 0x82, 0xb7, 0xa1,
+	0x12, 0xb7, 0x91, 0x41, 0x48, 0x68, 0x2d, 0x9b, 0xee, 0x85, 0x69, 0x5b, 0xca, 0xb1, 0x68, 0x8f,
+	0x50, 0xb3, 0x0d, 0x8d, 0xa5, 0x36, 0x4b, 0x57, 0x3c, 0x80, 0x82, 0xc7, 0x45, 0xa4, 0x2f, 0xaa,
+	0xe9, 0xe1, 0x60, 0x49, 0xae, 0x59, 0x83, 0x3b, 0x12, 0x2a, 0x9e, 0x17, 0x71, 0xee, 0x75, 0xe0,
+	0xee, 0x1c, 0x47, 0x82, 0x6f, 0x29, 0xb3, 0x07, 0xfd, 0xaf, 0xd9, 0xa3, 0x4c, 0x9d, 0x2f, 0x11,
+	0xdc, 0x98, 0xe9, 0x55, 0xcc, 0x57, 0xa7, 0xc1, 0xd8, 0x93, 0x49, 0xa5, 0xa6, 0x45, 0x95, 0xd1,
+	0xdb, 0x92, 0xdc, 0x1e, 0xa9, 0x79, 0x93, 0x49, 0xe5, 0xcd, 0x63, 0x28, 0xf0, 0x1a, 0x8a, 0xfb,
+	0xf5, 0xcd, 0x94, 0xfb, 0x8e, 0x6c, 0x27, 0xd8, 0x59, 0x93, 0xa3, 0xb8, 0xc2, 0x49, 0xcd, 0x91,
+	0x3d, 0xa1, 0x24, 0xb0, 0xe4, 0x35, 0xfc, 0x7d, 0x28, 0x88, 0x5e, 0x59, 0xcb, 0x71, 0x80, 0xd5,
+	0x18, 0x40, 0x6d, 0xa7, 0x52, 0xc4, 0xfc, 0x2b, 0x82, 0xbc, 0x30, 0xfd, 0x4d, 0x25, 0x45, 0x1d,
+	0x56, 0x88, 0x3f, 0x1c, 0x8f, 0x1c, 0xff, 0x8c, 0xd7, 0x62, 0xde, 0x4a, 0xce, 0x18, 0xcb, 0x1a,
+	0x61, 0x45, 0x57, 0x91, 0x85, 0x50, 0x83, 0x3b, 0xfd, 0xc0, 0xf6, 0xc3, 0x53, 0x12, 0x70, 0xc3,
+	0x92, 0x0c, 0x30, 0xff, 0x00, 0x30, 0xf5, 0xb7, 0xe2, 0x27, 0xf4, 0xcd, 0xfc, 0xb4, 0x09, 0xc5,
+	0xd0, 0xf6, 0x26, 0x6e, 0x32, 0x41, 0x92, 0x8c, 0xea, 0x71, 0xb2, 0xf4, 0x54, 0x2c, 0x64, 0x3e,
+	0x82, 0x52, 0x02, 0xcd, 0x2c, 0x4f, 0x46, 0x45, 0xc5, 0xe2, 0xbf, 0xf1, 0x1a, 0xe4, 0x79, 0xc3,
+	0xe6, 0x8e, 0xa8, 0x58, 0xe2, 0x60, 0x36, 0xa1, 0x20, 0xf0, 0xa6, 0x7c, 0xd1, 0xdc, 0xc4, 0x81,
+	0x35, 0xfb, 0x05, 0x5e, 0x2c, 0x53, 0xa5, 0xff, 0x36, 0x61, 0x35, 0x55, 0x13, 0xa9, 0xa9, 0x8e,
+	0x5e, 0x6b, 0xaa, 0x7f, 0x91, 0x81, 0x6a, 0x3a, 0x93, 0xf1, 0x23, 0xc8, 0xd1, 0x8b, 0x89, 0xb0,
+	0xa6, 0xba, 0x75, 0x7f, 0x71, 0xbe, 0xcb, 0x63, 0xff, 0x62, 0x42, 0x2c, 0x2e, 0xce, 0xf2, 0x44,
+	0x54, 0xda, 0xe0, 0xd4, 0xf6, 0x1c, 0xf7, 0x42, 0x8c, 0x4c, 0x91, 0xc3, 0xba, 0xe0, 0xec, 0x71,
+	0x06, 0x9f, 0x9c, 0x18, 0x72, 0xe7, 0xc4, 0x9d, 0xf0, 0x08, 0x97, 0x2c, 0xfe, 0x9b, 0xd1, 0x22,
+	0xdf, 0xa1, 0xb5, 0xbc, 0xa0, 0xb1, 0xdf, 0xe6, 0x05, 0xc0, 0x54, 0x13, 0x2e, 0x43, 0xf1, 0xb8,
+	0xfb, 0x8b, 0xee, 0xe1, 0x27, 0x5d, 0x5d, 0x63, 0x87, 0xdd, 0xc3, 0xe3, 0x6e, 0xbf, 0x65, 0xe9,
+	0x08, 0x97, 0x20, 0xbf, 0xdf, 0x3c, 0xde, 0x6f, 0xe9, 0x19, 0xbc, 0x0a, 0xa5, 0x27, 0xed, 0x5e,
+	0xff, 0x70, 0xdf, 0x6a, 0x76, 0xf4, 0x2c, 0xc6, 0x50, 0xe5, 0x9c, 0x29, 0x2d, 0xc7, 0xae, 0xf6,
+	0x8e, 0x3b, 0x9d, 0xa6, 0xf5, 0x2b, 0x3d, 0xcf, 0xd6, 0xc1, 0x76, 0x77, 0xef, 0x50, 0x2f, 0xe0,
+	0x0a, 0xac, 0xf4, 0xfa, 0xcd, 0x7e, 0xab, 0xd7, 0xea, 0xeb, 0x45, 0xb3, 0x0d, 0x05, 0xa1, 0xfa,
+	0xff, 0x4e, 0x29, 0x73, 0x00, 0x15, 0xd5, 0xff, 0x78, 0x3d, 0xe5, 0xe2, 0x04, 0x8e, 0xb3, 0x15,
+	0x97, 0xc6, 0xc9, 0x24, 0x9c, 0x38, 0x93, 0x4c, 0x59, 0x4e, 0x94, 0xc9, 0xf4, 0x27, 0x04, 0xd5,
+	0x69, 0x0d, 0xec, 0x39, 0x2e, 0xf9, 0x36, 0x5a, 0x4e, 0x1d, 0x56, 0x4e, 0x1d, 0x97, 0x70, 0x1b,
+	0x84, 0xba, 0xe4, 0xbc, 0xb0, 0x44, 0x4f, 0xe1, 0x9d, 0xe9, 0x3e, 0xb0, 0x6b, 0x07, 0x23, 0xc7,
+	0xb7, 0x5d, 0x87, 0x26, 0xcb, 0xec, 0xb5, 0xd3, 0x93, 0xbd, 0xd6, 0x75, 0x3c, 0x87, 0x72, 0xc3,
+	0xf2, 0x96, 0x38, 0x98, 0x7f, 0x41, 0x70, 0x6f, 0x89, 0x22, 0xd9, 0xb7, 0x59, 0xd3, 0xe2, 0xae,
+	0x18, 0x0c, 0xc7, 0x91, 0x4f, 0x07, 0x74, 0x4c, 0x6d, 0x97, 0x3f, 0x3f, 0x67, 0xe9, 0x82, 0xb3,
+	0xcb, 0x18, 0x7d, 0x46, 0xc7, 0x1f, 0xa4, 0x37, 0x16, 0x51, 0xf5, 0xef, 0xa4, 0x4c, 0x63, 0x9a,
+	0x54, 0x45, 0xea, 0x3e, 0xf3, 0x39, 0x82, 0xb5, 0x45, 0x42, 0x57, 0x6d, 0x8f, 0xf7, 0xa1, 0xa2,
+	0x1a, 0x29, 0x17, 0x97, 0xb2, 0x62, 0x1e, 0x7b, 0x87, 0xba, 0xf1, 0x49, 0xc1, 0xac, 0x78, 0x87,
+	0xb2, 0xf7, 0x71, 0x69, 0xf3, 0xf3, 0xd8, 0x2f, 0x92, 0x38, 0x1f, 0x81, 0x2b, 0x2c, 0x52, 0x03,
+	0x94, 0xb9, 0x5e, 0x80, 0xb2, 0x6a, 0x80, 0x9e, 0x22, 0x30, 0x96, 0x19, 0xf2, 0x8d, 0x22, 0xf4,
+	0xb3, 0x99, 0xcd, 0x57, 0x18, 0x77, 0x2f, 0x65, 0x1c, 0xd7, 0xa5, 0xaa, 0x4a, 0x2d, 0xc6, 0xbf,
+	0x81, 0xdb, 0x0b, 0xa5, 0xa6, 0xeb, 0xea, 0xb4, 0x07, 0xc7, 0xeb, 0xea, 0xc7, 0x71, 0x23, 0xbe,
+	0x22, 0x4c, 0xdf, 0xfb, 0x39, 0x94, 0x92, 0xda, 0x65, 0xad, 0xa8, 0xf5, 0xd1, 0x71, 0xf3, 0x40,
+	0xd7, 0x58, 0x2b, 0xea, 0x1e, 0xf6, 0x07, 0xe2, 0x88, 0xf0, 0x0d, 0x28, 0x5b, 0xad, 0xfd, 0xd6,
+	0x2f, 0x07, 0x9d, 0x66, 0x7f, 0xf7, 0x89, 0x9e, 0x61, 0xbd, 0x49, 0x10, 0xba, 0x87, 0x92, 0x96,
+	0xdd, 0xfa, 0xb2, 0x08, 0x2b, 0x71, 0x71, 0xb2, 0x5e, 0x7c, 0x14, 0x85, 0xe7, 0x78, 0x6d, 0xd1,
+	0xc7, 0x72, 0xfd, 0xf6, 0x0c, 0x55, 0xce, 0x43, 0x0d, 0xff, 0x18, 0xf2, 0xfc, 0xfb, 0x0a, 0x2f,
+	0xfc, 0x5e, 0xad, 0x2f, 0xfe, 0x0a, 0x35, 0x35, 0xfc, 0x21, 0x94, 0x95, 0xef, 0xb2, 0x25, 0xb7,
+	0xdf, 0x4e, 0x51, 0xd3, 0x9f, 0x70, 0xa6, 0xf6, 0x3e, 0xc2, 0x4f, 0xa0, 0xac, 0x04, 0x1f, 0xd7,
+	0xe7, 0xa3, 0x14, 0xce, 0x61, 0x2d, 0xf8, 0xdc, 0x31, 0x35, 0xdc, 0x02, 0x98, 0xd6, 0x39, 0x7e,
+	0x6b, 0xae, 0x22, 0x13, 0x9c, 0xfa, 0x22, 0x56, 0x02, 0xb3, 0x03, 0xa5, 0x64, 0xbd, 0xc6, 0xb5,
+	0x05, 0x1b, 0xb7, 0x00, 0x59, 0xbe, 0x8b, 0x9b, 0x1a, 0xde, 0x83, 0x4a, 0xd3, 0x75, 0x5f, 0x07,
+	0xa6, 0xae, 0x72, 0xc2, 0x59, 0x1c, 0x17, 0xee, 0x2e, 0xd9, 0x68, 0xf1, 0x83, 0xf4, 0xa8, 0x5d,
+	0xb6, 0xa6, 0xd7, 0xbf, 0x7b, 0xa5, 0x5c, 0xa2, 0xad, 0x0f, 0x37, 0x66, 0x56, 0x5b, 0x6c, 0xcc,
+	0xdc, 0x9e, 0xd9, 0x86, 0xeb, 0x8d, 0xa5, 0xfc, 0x04, 0xf5, 0x14, 0x6e, 0x4f, 0xfd, 0xac, 0xd6,
+	0xd2, 0x77, 0xe6, 0xc3, 0x30, 0xdf, 0x84, 0xea, 0xeb, 0x57, 0x48, 0x25, 0x7a, 0x1c, 0xb8, 0xb3,
+	0xb8, 0x8b, 0xe0, 0xf5, 0x05, 0x79, 0xb3, 0x40, 0xd3, 0x83, 0xab, 0xc4, 0x12, 0x55, 0x1d, 0xa8,
+	0xa6, 0xb7, 0x4b, 0xbc, 0xec, 0x7f, 0x87, 0x7a, 0xe2, 0xc0, 0x25, 0xeb, 0xa8, 0xb6, 0x81, 0x76,
+	0x7e, 0xfa, 0xfc, 0xa5, 0xa1, 0x7d, 0xf5, 0xd2, 0xd0, 0xbe, 0x7e, 0x69, 0xa0, 0x3f, 0x5e, 0x1a,
+	0xe8, 0x6f, 0x97, 0x06, 0x7a, 0x76, 0x69, 0xa0, 0xe7, 0x97, 0x06, 0xfa, 0xd7, 0xa5, 0x81, 0xfe,
+	0x7d, 0x69, 0x68, 0x5f, 0x5f, 0x1a, 0xe8, 0xe9, 0x2b, 0x43, 0x7b, 0xfe, 0xca, 0xd0, 0xbe, 0x7a,
+	0x65, 0x68, 0xbf, 0x2e, 0x0c, 0x5d, 0x87, 0xf8, 0xf4, 0xa4, 0xc0, 0xff, 0x92, 0xfb, 0xd1, 0x7f,
+	0x07, 0x00, 0x9f, 0x76, 0x6b, 0x62, 0xd9, 0x13, 0x00, 0x00,
 }
 
 func (x MatchType) String() string {
@@ -2656,42 +2991,230 @@ func (this *TimeSeriesFile) Equal(that interface{}) bool {
 	}
 	return true
 }
-func (this *WriteRequest) GoString() string {
-	if this == nil {
-		return "nil"
+func (this *LabelNamesCardinalityRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
 	}
-	s := make([]string, 0, 7)
-	s = append(s, "&client.WriteRequest{")
-	s = append(s, "Timeseries: "+fmt.Sprintf("%#v", this.Timeseries)+",\n")
-	s = append(s, "Source: "+fmt.Sprintf("%#v", this.Source)+",\n")
-	if this.Metadata != nil {
-		s = append(s, "Metadata: "+fmt.Sprintf("%#v", this.Metadata)+",\n")
+
+	that1, ok := that.(*LabelNamesCardinalityRequest)
+	if !ok {
+		that2, ok := that.(LabelNamesCardinalityRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
 	}
-	s = append(s, "}")
-	return strings.Join(s, "")
-}
-func (this *WriteResponse) GoString() string {
-	if this == nil {
-		return "nil"
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
 	}
-	s := make([]string, 0, 4)
-	s = append(s, "&client.WriteResponse{")
-	s = append(s, "}")
-	return strings.Join(s, "")
-}
-func (this *ReadRequest) GoString() string {
-	if this == nil {
-		return "nil"
+	if len(this.Matchers) != len(that1.Matchers) {
+		return false
 	}
-	s := make([]string, 0, 5)
-	s = append(s, "&client.ReadRequest{")
-	if this.Queries != nil {
-		s = append(s, "Queries: "+fmt.Sprintf("%#v", this.Queries)+",\n")
+	for i := range this.Matchers {
+		if !this.Matchers[i].Equal(that1.Matchers[i]) {
+			return false
+		}
 	}
-	s = append(s, "}")
-	return strings.Join(s, "")
+	if this.Limit != that1.Limit {
+		return false
+	}
+	return true
 }
-func (this *ReadResponse) GoString() string {
+func (this *LabelNamesCardinalityResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LabelNamesCardinalityResponse)
+	if !ok {
+		that2, ok := that.(LabelNamesCardinalityResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.SeriesCountTotal != that1.SeriesCountTotal {
+		return false
+	}
+	if len(this.LabelNames) != len(that1.LabelNames) {
+		return false
+	}
+	for i := range this.LabelNames {
+		if !this.LabelNames[i].Equal(that1.LabelNames[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *LabelNameCardinality) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LabelNameCardinality)
+	if !ok {
+		that2, ok := that.(LabelNameCardinality)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.LabelName != that1.LabelName {
+		return false
+	}
+	if this.SeriesCount != that1.SeriesCount {
+		return false
+	}
+	if this.LabelValuesCount != that1.LabelValuesCount {
+		return false
+	}
+	return true
+}
+func (this *LabelValuesCardinalityRequest) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LabelValuesCardinalityRequest)
+	if !ok {
+		that2, ok := that.(LabelValuesCardinalityRequest)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.LabelName != that1.LabelName {
+		return false
+	}
+	if len(this.Matchers) != len(that1.Matchers) {
+		return false
+	}
+	for i := range this.Matchers {
+		if !this.Matchers[i].Equal(that1.Matchers[i]) {
+			return false
+		}
+	}
+	if this.Limit != that1.Limit {
+		return false
+	}
+	return true
+}
+func (this *LabelValuesCardinalityResponse) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LabelValuesCardinalityResponse)
+	if !ok {
+		that2, ok := that.(LabelValuesCardinalityResponse)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.SeriesCountTotal != that1.SeriesCountTotal {
+		return false
+	}
+	if len(this.LabelValues) != len(that1.LabelValues) {
+		return false
+	}
+	for i := range this.LabelValues {
+		if !this.LabelValues[i].Equal(that1.LabelValues[i]) {
+			return false
+		}
+	}
+	return true
+}
+func (this *LabelValueCardinality) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*LabelValueCardinality)
+	if !ok {
+		that2, ok := that.(LabelValueCardinality)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.LabelValue != that1.LabelValue {
+		return false
+	}
+	if this.SeriesCount != that1.SeriesCount {
+		return false
+	}
+	return true
+}
+func (this *WriteRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&client.WriteRequest{")
+	s = append(s, "Timeseries: "+fmt.Sprintf("%#v", this.Timeseries)+",\n")
+	s = append(s, "Source: "+fmt.Sprintf("%#v", this.Source)+",\n")
+	if this.Metadata != nil {
+		s = append(s, "Metadata: "+fmt.Sprintf("%#v", this.Metadata)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *WriteResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 4)
+	s = append(s, "&client.WriteResponse{")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReadRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 5)
+	s = append(s, "&client.ReadRequest{")
+	if this.Queries != nil {
+		s = append(s, "Queries: "+fmt.Sprintf("%#v", this.Queries)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *ReadResponse) GoString() string {
 	if this == nil {
 		return "nil"
 	}
@@ -3033,6 +3556,82 @@ func (this *TimeSeriesFile) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *LabelNamesCardinalityRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&client.LabelNamesCardinalityRequest{")
+	if this.Matchers != nil {
+		s = append(s, "Matchers: "+fmt.Sprintf("%#v", this.Matchers)+",\n")
+	}
+	s = append(s, "Limit: "+fmt.Sprintf("%#v", this.Limit)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LabelNamesCardinalityResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&client.LabelNamesCardinalityResponse{")
+	s = append(s, "SeriesCountTotal: "+fmt.Sprintf("%#v", this.SeriesCountTotal)+",\n")
+	if this.LabelNames != nil {
+		s = append(s, "LabelNames: "+fmt.Sprintf("%#v", this.LabelNames)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LabelNameCardinality) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&client.LabelNameCardinality{")
+	s = append(s, "LabelName: "+fmt.Sprintf("%#v", this.LabelName)+",\n")
+	s = append(s, "SeriesCount: "+fmt.Sprintf("%#v", this.SeriesCount)+",\n")
+	s = append(s, "LabelValuesCount: "+fmt.Sprintf("%#v", this.LabelValuesCount)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LabelValuesCardinalityRequest) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 7)
+	s = append(s, "&client.LabelValuesCardinalityRequest{")
+	s = append(s, "LabelName: "+fmt.Sprintf("%#v", this.LabelName)+",\n")
+	if this.Matchers != nil {
+		s = append(s, "Matchers: "+fmt.Sprintf("%#v", this.Matchers)+",\n")
+	}
+	s = append(s, "Limit: "+fmt.Sprintf("%#v", this.Limit)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LabelValuesCardinalityResponse) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&client.LabelValuesCardinalityResponse{")
+	s = append(s, "SeriesCountTotal: "+fmt.Sprintf("%#v", this.SeriesCountTotal)+",\n")
+	if this.LabelValues != nil {
+		s = append(s, "LabelValues: "+fmt.Sprintf("%#v", this.LabelValues)+",\n")
+	}
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
+func (this *LabelValueCardinality) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 6)
+	s = append(s, "&client.LabelValueCardinality{")
+	s = append(s, "LabelValue: "+fmt.Sprintf("%#v", this.LabelValue)+",\n")
+	s = append(s, "SeriesCount: "+fmt.Sprintf("%#v", this.SeriesCount)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringCortex(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -3063,6 +3662,8 @@ type IngesterClient interface {
 	AllUserStats(ctx context.Context, in *UserStatsRequest, opts ...grpc.CallOption) (*UsersStatsResponse, error)
 	MetricsForLabelMatchers(ctx context.Context, in *MetricsForLabelMatchersRequest, opts ...grpc.CallOption) (*MetricsForLabelMatchersResponse, error)
 	MetricsMetadata(ctx context.Context, in *MetricsMetadataRequest, opts ...grpc.CallOption) (*MetricsMetadataResponse, error)
+	LabelNamesCardinality(ctx context.Context, in *LabelNamesCardinalityRequest, opts ...grpc.CallOption) (*LabelNamesCardinalityResponse, error)
+	LabelValuesCardinality(ctx context.Context, in *LabelValuesCardinalityRequest, opts ...grpc.CallOption) (*LabelValuesCardinalityResponse, error)
 	// TransferChunks allows leaving ingester (client) to stream chunks directly to joining ingesters (server).
 	TransferChunks(ctx context.Context, opts ...grpc.CallOption) (Ingester_TransferChunksClient, error)
 }
@@ -3179,6 +3780,24 @@ func (c *ingesterClient) MetricsMetadata(ctx context.Context, in *MetricsMetadat
 	return out, nil
 }
 
+func (c *ingesterClient) LabelNamesCardinality(ctx context.Context, in *LabelNamesCardinalityRequest, opts ...grpc.CallOption) (*LabelNamesCardinalityResponse, error) {
+	out := new(LabelNamesCardinalityResponse)
+	err := c.cc.Invoke(ctx, "/cortex.Ingester/LabelNamesCardinality", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *ingesterClient) LabelValuesCardinality(ctx context.Context, in *LabelValuesCardinalityRequest, opts ...grpc.CallOption) (*LabelValuesCardinalityResponse, error) {
+	out := new(LabelValuesCardinalityResponse)
+	err := c.cc.Invoke(ctx, "/cortex.Ingester/LabelValuesCardinality", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *ingesterClient) TransferChunks(ctx context.Context, opts ...grpc.CallOption) (Ingester_TransferChunksClient, error) {
 	stream, err := c.cc.NewStream(ctx, &_Ingester_serviceDesc.Streams[1], "/cortex.Ingester/TransferChunks", opts...)
 	if err != nil {
@@ -3224,6 +3843,8 @@ type IngesterServer interface {
 	AllUserStats(context.Context, *UserStatsRequest) (*UsersStatsResponse, error)
 	MetricsForLabelMatchers(context.Context, *MetricsForLabelMatchersRequest) (*MetricsForLabelMatchersResponse, error)
 	MetricsMetadata(context.Context, *MetricsMetadataRequest) (*MetricsMetadataResponse, error)
+	LabelNamesCardinality(context.Context, *LabelNamesCardinalityRequest) (*LabelNamesCardinalityResponse, error)
+	LabelValuesCardinality(context.Context, *LabelValuesCardinalityRequest) (*LabelValuesCardinalityResponse, error)
 	// TransferChunks allows leaving ingester (client) to stream chunks directly to joining ingesters (server).
 	TransferChunks(Ingester_TransferChunksServer) error
 }
@@ -3259,6 +3880,12 @@ func (*UnimplementedIngesterServer) MetricsForLabelMatchers(ctx context.Context,
 func (*UnimplementedIngesterServer) MetricsMetadata(ctx context.Context, req *MetricsMetadataRequest) (*MetricsMetadataResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method MetricsMetadata not implemented")
 }
+func (*UnimplementedIngesterServer) LabelNamesCardinality(ctx context.Context, req *LabelNamesCardinalityRequest) (*LabelNamesCardinalityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LabelNamesCardinality not implemented")
+}
+func (*UnimplementedIngesterServer) LabelValuesCardinality(ctx context.Context, req *LabelValuesCardinalityRequest) (*LabelValuesCardinalityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LabelValuesCardinality not implemented")
+}
 func (*UnimplementedIngesterServer) TransferChunks(srv Ingester_TransferChunksServer) error {
 	return status.Errorf(codes.Unimplemented, "method TransferChunks not implemented")
 }
@@ -3432,27 +4059,63 @@ func _Ingester_MetricsMetadata_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
-func _Ingester_TransferChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(IngesterServer).TransferChunks(&ingesterTransferChunksServer{stream})
-}
-
-type Ingester_TransferChunksServer interface {
-	SendAndClose(*TransferChunksResponse) error
-	Recv() (*TimeSeriesChunk, error)
-	grpc.ServerStream
-}
-
-type ingesterTransferChunksServer struct {
-	grpc.ServerStream
-}
-
-func (x *ingesterTransferChunksServer) SendAndClose(m *TransferChunksResponse) error {
-	return x.ServerStream.SendMsg(m)
-}
-
-func (x *ingesterTransferChunksServer) Recv() (*TimeSeriesChunk, error) {
-	m := new(TimeSeriesChunk)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
+func _Ingester_LabelNamesCardinality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LabelNamesCardinalityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IngesterServer).LabelNamesCardinality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cortex.Ingester/LabelNamesCardinality",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IngesterServer).LabelNamesCardinality(ctx, req.(*LabelNamesCardinalityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ingester_LabelValuesCardinality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LabelValuesCardinalityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IngesterServer).LabelValuesCardinality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/cortex.Ingester/LabelValuesCardinality",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IngesterServer).LabelValuesCardinality(ctx, req.(*LabelValuesCardinalityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Ingester_TransferChunks_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(IngesterServer).TransferChunks(&ingesterTransferChunksServer{stream})
+}
+
+type Ingester_TransferChunksServer interface {
+	SendAndClose(*TransferChunksResponse) error
+	Recv() (*TimeSeriesChunk, error)
+	grpc.ServerStream
+}
+
+type ingesterTransferChunksServer struct {
+	grpc.ServerStream
+}
+
+func (x *ingesterTransferChunksServer) SendAndClose(m *TransferChunksResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *ingesterTransferChunksServer) Recv() (*TimeSeriesChunk, error) {
+	m := new(TimeSeriesChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
@@ -3494,6 +4157,14 @@ var _Ingester_serviceDesc = grpc.ServiceDesc{
 			MethodName: "MetricsMetadata",
 			Handler:    _Ingester_MetricsMetadata_Handler,
 		},
+		{
+			MethodName: "LabelNamesCardinality",
+			Handler:    _Ingester_LabelNamesCardinality_Handler,
+		},
+		{
+			MethodName: "LabelValuesCardinality",
+			Handler:    _Ingester_LabelValuesCardinality_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -4698,138 +5369,388 @@ func (m *TimeSeriesFile) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
-func encodeVarintCortex(dAtA []byte, offset int, v uint64) int {
-	offset -= sovCortex(v)
-	base := offset
-	for v >= 1<<7 {
-		dAtA[offset] = uint8(v&0x7f | 0x80)
-		v >>= 7
-		offset++
+func (m *LabelNamesCardinalityRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	dAtA[offset] = uint8(v)
-	return base
+	return dAtA[:n], nil
 }
-func (m *WriteRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+
+func (m *LabelNamesCardinalityRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LabelNamesCardinalityRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Timeseries) > 0 {
-		for _, e := range m.Timeseries {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
-		}
-	}
-	if m.Source != 0 {
-		n += 1 + sovCortex(uint64(m.Source))
+	if m.Limit != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x10
 	}
-	if len(m.Metadata) > 0 {
-		for _, e := range m.Metadata {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
+	if len(m.Matchers) > 0 {
+		for iNdEx := len(m.Matchers) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Matchers[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintCortex(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0xa
 		}
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *WriteResponse) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *LabelNamesCardinalityResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	var l int
-	_ = l
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *ReadRequest) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if len(m.Queries) > 0 {
-		for _, e := range m.Queries {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
-		}
-	}
-	return n
+func (m *LabelNamesCardinalityResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
 }
 
-func (m *ReadResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *LabelNamesCardinalityResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Results) > 0 {
-		for _, e := range m.Results {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
+	if len(m.LabelNames) > 0 {
+		for iNdEx := len(m.LabelNames) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.LabelNames[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintCortex(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
 		}
 	}
-	return n
+	if m.SeriesCountTotal != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.SeriesCountTotal))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *QueryRequest) Size() (n int) {
-	if m == nil {
-		return 0
+func (m *LabelNameCardinality) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
+	return dAtA[:n], nil
+}
+
+func (m *LabelNameCardinality) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LabelNameCardinality) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if m.StartTimestampMs != 0 {
-		n += 1 + sovCortex(uint64(m.StartTimestampMs))
+	if m.LabelValuesCount != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.LabelValuesCount))
+		i--
+		dAtA[i] = 0x18
 	}
-	if m.EndTimestampMs != 0 {
-		n += 1 + sovCortex(uint64(m.EndTimestampMs))
+	if m.SeriesCount != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.SeriesCount))
+		i--
+		dAtA[i] = 0x10
 	}
-	if len(m.Matchers) > 0 {
-		for _, e := range m.Matchers {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
-		}
+	if len(m.LabelName) > 0 {
+		i -= len(m.LabelName)
+		copy(dAtA[i:], m.LabelName)
+		i = encodeVarintCortex(dAtA, i, uint64(len(m.LabelName)))
+		i--
+		dAtA[i] = 0xa
 	}
-	return n
+	return len(dAtA) - i, nil
 }
 
-func (m *QueryResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
-	var l int
-	_ = l
-	if len(m.Timeseries) > 0 {
-		for _, e := range m.Timeseries {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
-		}
+func (m *LabelValuesCardinalityRequest) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
 	}
-	return n
+	return dAtA[:n], nil
 }
 
-func (m *QueryStreamResponse) Size() (n int) {
-	if m == nil {
-		return 0
-	}
+func (m *LabelValuesCardinalityRequest) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LabelValuesCardinalityRequest) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
 	var l int
 	_ = l
-	if len(m.Chunkseries) > 0 {
-		for _, e := range m.Chunkseries {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
-		}
+	if m.Limit != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.Limit))
+		i--
+		dAtA[i] = 0x18
 	}
-	if len(m.Timeseries) > 0 {
-		for _, e := range m.Timeseries {
-			l = e.Size()
-			n += 1 + l + sovCortex(uint64(l))
+	if len(m.Matchers) > 0 {
+		for iNdEx := len(m.Matchers) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.Matchers[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintCortex(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
 		}
 	}
-	return n
+	if len(m.LabelName) > 0 {
+		i -= len(m.LabelName)
+		copy(dAtA[i:], m.LabelName)
+		i = encodeVarintCortex(dAtA, i, uint64(len(m.LabelName)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
 }
 
-func (m *LabelValuesRequest) Size() (n int) {
+func (m *LabelValuesCardinalityResponse) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LabelValuesCardinalityResponse) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LabelValuesCardinalityResponse) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if len(m.LabelValues) > 0 {
+		for iNdEx := len(m.LabelValues) - 1; iNdEx >= 0; iNdEx-- {
+			{
+				size, err := m.LabelValues[iNdEx].MarshalToSizedBuffer(dAtA[:i])
+				if err != nil {
+					return 0, err
+				}
+				i -= size
+				i = encodeVarintCortex(dAtA, i, uint64(size))
+			}
+			i--
+			dAtA[i] = 0x12
+		}
+	}
+	if m.SeriesCountTotal != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.SeriesCountTotal))
+		i--
+		dAtA[i] = 0x8
+	}
+	return len(dAtA) - i, nil
+}
+
+func (m *LabelValueCardinality) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *LabelValueCardinality) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *LabelValueCardinality) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.SeriesCount != 0 {
+		i = encodeVarintCortex(dAtA, i, uint64(m.SeriesCount))
+		i--
+		dAtA[i] = 0x10
+	}
+	if len(m.LabelValue) > 0 {
+		i -= len(m.LabelValue)
+		copy(dAtA[i:], m.LabelValue)
+		i = encodeVarintCortex(dAtA, i, uint64(len(m.LabelValue)))
+		i--
+		dAtA[i] = 0xa
+	}
+	return len(dAtA) - i, nil
+}
+
+func encodeVarintCortex(dAtA []byte, offset int, v uint64) int {
+	offset -= sovCortex(v)
+	base := offset
+	for v >= 1<<7 {
+		dAtA[offset] = uint8(v&0x7f | 0x80)
+		v >>= 7
+		offset++
+	}
+	dAtA[offset] = uint8(v)
+	return base
+}
+func (m *WriteRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Timeseries) > 0 {
+		for _, e := range m.Timeseries {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	if m.Source != 0 {
+		n += 1 + sovCortex(uint64(m.Source))
+	}
+	if len(m.Metadata) > 0 {
+		for _, e := range m.Metadata {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *WriteResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	return n
+}
+
+func (m *ReadRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Queries) > 0 {
+		for _, e := range m.Queries {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *ReadResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Results) > 0 {
+		for _, e := range m.Results {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *QueryRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.StartTimestampMs != 0 {
+		n += 1 + sovCortex(uint64(m.StartTimestampMs))
+	}
+	if m.EndTimestampMs != 0 {
+		n += 1 + sovCortex(uint64(m.EndTimestampMs))
+	}
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *QueryResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Timeseries) > 0 {
+		for _, e := range m.Timeseries {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *QueryStreamResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if len(m.Chunkseries) > 0 {
+		for _, e := range m.Chunkseries {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	if len(m.Timeseries) > 0 {
+		for _, e := range m.Timeseries {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LabelValuesRequest) Size() (n int) {
 	if m == nil {
 		return 0
 	}
@@ -5227,54 +6148,165 @@ func (m *TimeSeriesFile) Size() (n int) {
 	return n
 }
 
-func sovCortex(x uint64) (n int) {
-	return (math_bits.Len64(x|1) + 6) / 7
-}
-func sozCortex(x uint64) (n int) {
-	return sovCortex(uint64((x << 1) ^ uint64((int64(x) >> 63))))
-}
-func (this *WriteRequest) String() string {
-	if this == nil {
-		return "nil"
+func (m *LabelNamesCardinalityRequest) Size() (n int) {
+	if m == nil {
+		return 0
 	}
-	repeatedStringForMetadata := "[]*MetricMetadata{"
-	for _, f := range this.Metadata {
-		repeatedStringForMetadata += strings.Replace(f.String(), "MetricMetadata", "MetricMetadata", 1) + ","
+	var l int
+	_ = l
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
 	}
-	repeatedStringForMetadata += "}"
-	s := strings.Join([]string{`&WriteRequest{`,
-		`Timeseries:` + fmt.Sprintf("%v", this.Timeseries) + `,`,
-		`Source:` + fmt.Sprintf("%v", this.Source) + `,`,
-		`Metadata:` + repeatedStringForMetadata + `,`,
-		`}`,
-	}, "")
-	return s
-}
-func (this *WriteResponse) String() string {
-	if this == nil {
-		return "nil"
+	if m.Limit != 0 {
+		n += 1 + sovCortex(uint64(m.Limit))
 	}
-	s := strings.Join([]string{`&WriteResponse{`,
-		`}`,
-	}, "")
-	return s
+	return n
 }
-func (this *ReadRequest) String() string {
-	if this == nil {
-		return "nil"
+
+func (m *LabelNamesCardinalityResponse) Size() (n int) {
+	if m == nil {
+		return 0
 	}
-	repeatedStringForQueries := "[]*QueryRequest{"
-	for _, f := range this.Queries {
-		repeatedStringForQueries += strings.Replace(f.String(), "QueryRequest", "QueryRequest", 1) + ","
+	var l int
+	_ = l
+	if m.SeriesCountTotal != 0 {
+		n += 1 + sovCortex(uint64(m.SeriesCountTotal))
 	}
-	repeatedStringForQueries += "}"
-	s := strings.Join([]string{`&ReadRequest{`,
-		`Queries:` + repeatedStringForQueries + `,`,
-		`}`,
-	}, "")
-	return s
+	if len(m.LabelNames) > 0 {
+		for _, e := range m.LabelNames {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
 }
-func (this *ReadResponse) String() string {
+
+func (m *LabelNameCardinality) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.LabelName)
+	if l > 0 {
+		n += 1 + l + sovCortex(uint64(l))
+	}
+	if m.SeriesCount != 0 {
+		n += 1 + sovCortex(uint64(m.SeriesCount))
+	}
+	if m.LabelValuesCount != 0 {
+		n += 1 + sovCortex(uint64(m.LabelValuesCount))
+	}
+	return n
+}
+
+func (m *LabelValuesCardinalityRequest) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.LabelName)
+	if l > 0 {
+		n += 1 + l + sovCortex(uint64(l))
+	}
+	if len(m.Matchers) > 0 {
+		for _, e := range m.Matchers {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	if m.Limit != 0 {
+		n += 1 + sovCortex(uint64(m.Limit))
+	}
+	return n
+}
+
+func (m *LabelValuesCardinalityResponse) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	if m.SeriesCountTotal != 0 {
+		n += 1 + sovCortex(uint64(m.SeriesCountTotal))
+	}
+	if len(m.LabelValues) > 0 {
+		for _, e := range m.LabelValues {
+			l = e.Size()
+			n += 1 + l + sovCortex(uint64(l))
+		}
+	}
+	return n
+}
+
+func (m *LabelValueCardinality) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = len(m.LabelValue)
+	if l > 0 {
+		n += 1 + l + sovCortex(uint64(l))
+	}
+	if m.SeriesCount != 0 {
+		n += 1 + sovCortex(uint64(m.SeriesCount))
+	}
+	return n
+}
+
+func sovCortex(x uint64) (n int) {
+	return (math_bits.Len64(x|1) + 6) / 7
+}
+func sozCortex(x uint64) (n int) {
+	return sovCortex(uint64((x << 1) ^ uint64((int64(x) >> 63))))
+}
+func (this *WriteRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForMetadata := "[]*MetricMetadata{"
+	for _, f := range this.Metadata {
+		repeatedStringForMetadata += strings.Replace(f.String(), "MetricMetadata", "MetricMetadata", 1) + ","
+	}
+	repeatedStringForMetadata += "}"
+	s := strings.Join([]string{`&WriteRequest{`,
+		`Timeseries:` + fmt.Sprintf("%v", this.Timeseries) + `,`,
+		`Source:` + fmt.Sprintf("%v", this.Source) + `,`,
+		`Metadata:` + repeatedStringForMetadata + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *WriteResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&WriteResponse{`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReadRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForQueries := "[]*QueryRequest{"
+	for _, f := range this.Queries {
+		repeatedStringForQueries += strings.Replace(f.String(), "QueryRequest", "QueryRequest", 1) + ","
+	}
+	repeatedStringForQueries += "}"
+	s := strings.Join([]string{`&ReadRequest{`,
+		`Queries:` + repeatedStringForQueries + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *ReadResponse) String() string {
 	if this == nil {
 		return "nil"
 	}
@@ -5630,6 +6662,94 @@ func (this *TimeSeriesFile) String() string {
 	}, "")
 	return s
 }
+func (this *LabelNamesCardinalityRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForMatchers := "[]*LabelMatcher{"
+	for _, f := range this.Matchers {
+		repeatedStringForMatchers += strings.Replace(f.String(), "LabelMatcher", "LabelMatcher", 1) + ","
+	}
+	repeatedStringForMatchers += "}"
+	s := strings.Join([]string{`&LabelNamesCardinalityRequest{`,
+		`Matchers:` + repeatedStringForMatchers + `,`,
+		`Limit:` + fmt.Sprintf("%v", this.Limit) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LabelNamesCardinalityResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForLabelNames := "[]*LabelNameCardinality{"
+	for _, f := range this.LabelNames {
+		repeatedStringForLabelNames += strings.Replace(f.String(), "LabelNameCardinality", "LabelNameCardinality", 1) + ","
+	}
+	repeatedStringForLabelNames += "}"
+	s := strings.Join([]string{`&LabelNamesCardinalityResponse{`,
+		`SeriesCountTotal:` + fmt.Sprintf("%v", this.SeriesCountTotal) + `,`,
+		`LabelNames:` + repeatedStringForLabelNames + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LabelNameCardinality) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&LabelNameCardinality{`,
+		`LabelName:` + fmt.Sprintf("%v", this.LabelName) + `,`,
+		`SeriesCount:` + fmt.Sprintf("%v", this.SeriesCount) + `,`,
+		`LabelValuesCount:` + fmt.Sprintf("%v", this.LabelValuesCount) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LabelValuesCardinalityRequest) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForMatchers := "[]*LabelMatcher{"
+	for _, f := range this.Matchers {
+		repeatedStringForMatchers += strings.Replace(f.String(), "LabelMatcher", "LabelMatcher", 1) + ","
+	}
+	repeatedStringForMatchers += "}"
+	s := strings.Join([]string{`&LabelValuesCardinalityRequest{`,
+		`LabelName:` + fmt.Sprintf("%v", this.LabelName) + `,`,
+		`Matchers:` + repeatedStringForMatchers + `,`,
+		`Limit:` + fmt.Sprintf("%v", this.Limit) + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LabelValuesCardinalityResponse) String() string {
+	if this == nil {
+		return "nil"
+	}
+	repeatedStringForLabelValues := "[]*LabelValueCardinality{"
+	for _, f := range this.LabelValues {
+		repeatedStringForLabelValues += strings.Replace(f.String(), "LabelValueCardinality", "LabelValueCardinality", 1) + ","
+	}
+	repeatedStringForLabelValues += "}"
+	s := strings.Join([]string{`&LabelValuesCardinalityResponse{`,
+		`SeriesCountTotal:` + fmt.Sprintf("%v", this.SeriesCountTotal) + `,`,
+		`LabelValues:` + repeatedStringForLabelValues + `,`,
+		`}`,
+	}, "")
+	return s
+}
+func (this *LabelValueCardinality) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&LabelValueCardinality{`,
+		`LabelValue:` + fmt.Sprintf("%v", this.LabelValue) + `,`,
+		`SeriesCount:` + fmt.Sprintf("%v", this.SeriesCount) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringCortex(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -8808,6 +9928,689 @@ func (m *TimeSeriesFile) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *LabelNamesCardinalityRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCortex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelNamesCardinalityRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelNamesCardinalityRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matchers = append(m.Matchers, &LabelMatcher{})
+			if err := m.Matchers[len(m.Matchers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCortex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LabelNamesCardinalityResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCortex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelNamesCardinalityResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelNamesCardinalityResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesCountTotal", wireType)
+			}
+			m.SeriesCountTotal = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SeriesCountTotal |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LabelNames", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LabelNames = append(m.LabelNames, &LabelNameCardinality{})
+			if err := m.LabelNames[len(m.LabelNames)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCortex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LabelNameCardinality) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCortex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelNameCardinality: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelNameCardinality: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LabelName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LabelName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesCount", wireType)
+			}
+			m.SeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LabelValuesCount", wireType)
+			}
+			m.LabelValuesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.LabelValuesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCortex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LabelValuesCardinalityRequest) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCortex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelValuesCardinalityRequest: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelValuesCardinalityRequest: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LabelName", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LabelName = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Matchers", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Matchers = append(m.Matchers, &LabelMatcher{})
+			if err := m.Matchers[len(m.Matchers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Limit", wireType)
+			}
+			m.Limit = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Limit |= int32(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCortex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LabelValuesCardinalityResponse) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCortex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelValuesCardinalityResponse: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelValuesCardinalityResponse: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesCountTotal", wireType)
+			}
+			m.SeriesCountTotal = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SeriesCountTotal |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LabelValues", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LabelValues = append(m.LabelValues, &LabelValueCardinality{})
+			if err := m.LabelValues[len(m.LabelValues)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCortex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *LabelValueCardinality) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowCortex
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: LabelValueCardinality: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: LabelValueCardinality: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LabelValue", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthCortex
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.LabelValue = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SeriesCount", wireType)
+			}
+			m.SeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowCortex
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.SeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipCortex(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthCortex
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipCortex(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
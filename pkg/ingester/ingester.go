@@ -17,6 +17,7 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	tsdb_record "github.com/prometheus/prometheus/tsdb/record"
 	"github.com/weaveworks/common/httpgrpc"
+	"go.uber.org/atomic"
 	"golang.org/x/time/rate"
 	"google.golang.org/grpc/codes"
 
@@ -26,6 +27,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 	"github.com/cortexproject/cortex/pkg/util/validation"
@@ -76,6 +78,9 @@ type Config struct {
 	ActiveSeriesMetricsUpdatePeriod time.Duration `yaml:"active_series_metrics_update_period"`
 	ActiveSeriesMetricsIdleTimeout  time.Duration `yaml:"active_series_metrics_idle_timeout"`
 
+	// Controls whether the blocks storage streams chunks instead of samples to the queriers.
+	StreamChunksWhenUsingBlocks bool `yaml:"stream_chunks_when_using_blocks"`
+
 	// Use blocks storage.
 	BlocksStorageEnabled bool                     `yaml:"-"`
 	BlocksStorageConfig  tsdb.BlocksStorageConfig `yaml:"-"`
@@ -112,6 +117,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.ActiveSeriesMetricsEnabled, "ingester.active-series-metrics-enabled", false, "Enable tracking of active series and export them as metrics.")
 	f.DurationVar(&cfg.ActiveSeriesMetricsUpdatePeriod, "ingester.active-series-metrics-update-period", 1*time.Minute, "How often to update active series metrics.")
 	f.DurationVar(&cfg.ActiveSeriesMetricsIdleTimeout, "ingester.active-series-metrics-idle-timeout", 10*time.Minute, "After what time a series is considered to be inactive.")
+	f.BoolVar(&cfg.StreamChunksWhenUsingBlocks, "ingester.stream-chunks-when-using-blocks", false, "If enabled, the ingester streams the raw TSDB chunks to the queriers on QueryStream() when using the blocks storage, instead of decoding them and sending samples. The queriers decode the chunks lazily while evaluating the query. Queriers must be running a version supporting it before enabling it.")
 }
 
 // Ingester deals with "in flight" chunks.  Based on Prometheus 1.x
@@ -134,6 +140,12 @@ type Ingester struct {
 	userStates    *userStates
 	stopped       bool // protected by userStatesMtx
 
+	// Set when the ingester has been prepared for shutdown through the HTTP API,
+	// in which case the writes are rejected.
+	prepareShutdownMtx sync.Mutex
+	prepareShutdown    prepareShutdownState // protected by prepareShutdownMtx
+	readOnly           atomic.Bool
+
 	// For storing metadata ingested.
 	usersMetadataMtx sync.RWMutex
 	usersMetadata    map[string]*userMetricsMetadata
@@ -432,6 +444,13 @@ func (i *Ingester) Push(ctx context.Context, req *client.WriteRequest) (*client.
 		return nil, err
 	}
 
+	if i.readOnly.Load() {
+		// NOTE: because we use `unsafe` in deserialisation, we must not
+		// retain anything from `req` past the call to ReuseSlice
+		client.ReuseSlice(req.Timeseries)
+		return nil, errIngesterReadOnly
+	}
+
 	if i.cfg.BlocksStorageEnabled {
 		return i.v2Push(ctx, req)
 	}
@@ -577,11 +596,11 @@ func (i *Ingester) append(ctx context.Context, userID string, labels labelPairs,
 	i.metrics.ingestedSamples.Inc()
 	switch source {
 	case client.RULE:
-		state.ingestedRuleSamples.inc()
+		state.ingestedRuleSamples.Inc()
 	case client.API:
 		fallthrough
 	default:
-		state.ingestedAPISamples.inc()
+		state.ingestedAPISamples.Inc()
 	}
 
 	return err
@@ -769,6 +788,7 @@ func (i *Ingester) QueryStream(req *client.QueryRequest, stream client.Ingester_
 		return nil
 	}
 
+	queryLimiter := i.newQueryLimiter(state.userID)
 	numSeries, numChunks := 0, 0
 	reuseWireChunks := [queryStreamBatchSize][]client.Chunk{}
 	batch := make([]client.TimeSeriesChunk, 0, queryStreamBatchSize)
@@ -796,6 +816,10 @@ func (i *Ingester) QueryStream(req *client.QueryRequest, stream client.Ingester_
 		}
 		reuseWireChunks[reusePos] = wireChunks
 
+		if err := enforceQueryLimits(queryLimiter, series.metric, wireChunks); err != nil {
+			return err
+		}
+
 		numChunks += len(wireChunks)
 		batch = append(batch, client.TimeSeriesChunk{
 			Labels: client.FromLabelsToLabelAdapters(series.metric),
@@ -824,6 +848,35 @@ func (i *Ingester) QueryStream(req *client.QueryRequest, stream client.Ingester_
 	return err
 }
 
+// newQueryLimiter makes a limiter enforcing, on the data returned by this ingester,
+// the per-query limits on fetched series, chunks and chunk bytes.
+func (i *Ingester) newQueryLimiter(userID string) *limiter.QueryLimiter {
+	return limiter.NewQueryLimiter(
+		i.limits.MaxFetchedSeriesPerQuery(userID),
+		i.limits.MaxFetchedChunkBytesPerQuery(userID),
+		i.limits.MaxFetchedChunksPerQuery(userID),
+		nil,
+	)
+}
+
+func enforceQueryLimits(queryLimiter *limiter.QueryLimiter, series labels.Labels, chunks []client.Chunk) error {
+	chunkBytes := 0
+	for _, c := range chunks {
+		chunkBytes += len(c.Data)
+	}
+
+	if err := queryLimiter.AddSeries(series); err != nil {
+		return httpgrpc.Errorf(http.StatusRequestEntityTooLarge, "%s", err.Error())
+	}
+	if err := queryLimiter.AddChunks(len(chunks)); err != nil {
+		return httpgrpc.Errorf(http.StatusRequestEntityTooLarge, "%s", err.Error())
+	}
+	if err := queryLimiter.AddChunkBytes(chunkBytes); err != nil {
+		return httpgrpc.Errorf(http.StatusRequestEntityTooLarge, "%s", err.Error())
+	}
+	return nil
+}
+
 // LabelValues returns all label values that are associated with a given label name.
 func (i *Ingester) LabelValues(ctx context.Context, req *client.LabelValuesRequest) (*client.LabelValuesResponse, error) {
 	if err := i.checkRunningOrStopping(); err != nil {
@@ -921,6 +974,81 @@ func (i *Ingester) MetricsForLabelMatchers(ctx context.Context, req *client.Metr
 	return result, nil
 }
 
+// LabelNamesCardinality returns the label names with the highest number of in-memory series
+// matching the input matchers.
+func (i *Ingester) LabelNamesCardinality(ctx context.Context, req *client.LabelNamesCardinalityRequest) (*client.LabelNamesCardinalityResponse, error) {
+	if err := i.checkRunningOrStopping(); err != nil {
+		return nil, err
+	}
+
+	if i.cfg.BlocksStorageEnabled {
+		return i.v2LabelNamesCardinality(ctx, req)
+	}
+
+	matchers, limit, err := client.FromLabelNamesCardinalityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	i.userStatesMtx.RLock()
+	defer i.userStatesMtx.RUnlock()
+	state, ok, err := i.userStates.getViaContext(ctx)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return &client.LabelNamesCardinalityResponse{}, nil
+	}
+
+	cardinality := newLabelNamesCardinality()
+	if err := state.forSeriesMatching(ctx, matchers, func(ctx context.Context, _ model.Fingerprint, series *memorySeries) error {
+		cardinality.add(series.metric)
+		return nil
+	}, nil, 0); err != nil {
+		return nil, err
+	}
+
+	return cardinality.response(limit), nil
+}
+
+// LabelValuesCardinality returns the values of a label name with the highest number of in-memory
+// series matching the input matchers.
+func (i *Ingester) LabelValuesCardinality(ctx context.Context, req *client.LabelValuesCardinalityRequest) (*client.LabelValuesCardinalityResponse, error) {
+	if err := i.checkRunningOrStopping(); err != nil {
+		return nil, err
+	}
+
+	if i.cfg.BlocksStorageEnabled {
+		return i.v2LabelValuesCardinality(ctx, req)
+	}
+
+	labelName, matchers, limit, err := client.FromLabelValuesCardinalityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	i.userStatesMtx.RLock()
+	defer i.userStatesMtx.RUnlock()
+	state, ok, err := i.userStates.getViaContext(ctx)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return &client.LabelValuesCardinalityResponse{}, nil
+	}
+
+	// Only series having the label are relevant.
+	matchers = append(matchers, labels.MustNewMatcher(labels.MatchNotEqual, labelName, ""))
+
+	cardinality := newLabelValuesCardinality(labelName)
+	if err := state.forSeriesMatching(ctx, matchers, func(ctx context.Context, _ model.Fingerprint, series *memorySeries) error {
+		cardinality.add(series.metric)
+		return nil
+	}, nil, 0); err != nil {
+		return nil, err
+	}
+
+	return cardinality.response(limit), nil
+}
+
 // MetricsMetadata returns all the metric metadata of a user.
 func (i *Ingester) MetricsMetadata(ctx context.Context, req *client.MetricsMetadataRequest) (*client.MetricsMetadataResponse, error) {
 	i.userStatesMtx.RLock()
@@ -963,8 +1091,8 @@ func (i *Ingester) UserStats(ctx context.Context, req *client.UserStatsRequest)
 		return &client.UserStatsResponse{}, nil
 	}
 
-	apiRate := state.ingestedAPISamples.rate()
-	ruleRate := state.ingestedRuleSamples.rate()
+	apiRate := state.ingestedAPISamples.Rate()
+	ruleRate := state.ingestedRuleSamples.Rate()
 	return &client.UserStatsResponse{
 		IngestionRate:     apiRate + ruleRate,
 		ApiIngestionRate:  apiRate,
@@ -991,8 +1119,8 @@ func (i *Ingester) AllUserStats(ctx context.Context, req *client.UserStatsReques
 		Stats: make([]*client.UserIDStatsResponse, 0, len(users)),
 	}
 	for userID, state := range users {
-		apiRate := state.ingestedAPISamples.rate()
-		ruleRate := state.ingestedRuleSamples.rate()
+		apiRate := state.ingestedAPISamples.Rate()
+		ruleRate := state.ingestedRuleSamples.Rate()
 		response.Stats = append(response.Stats, &client.UserIDStatsResponse{
 			UserId: userID,
 			Data: &client.UserStatsResponse{
@@ -746,6 +746,108 @@ func TestIngesterMetricLimitExceeded(t *testing.T) {
 	}
 }
 
+func TestIngesterLabelSetLimitExceeded(t *testing.T) {
+	limits := defaultLimitsTestConfig()
+	limits.LimitsPerLabelSet = []*validation.LimitsPerLabelSet{
+		{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: 2},
+	}
+
+	dir, err := ioutil.TempDir("", "limits")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	chunksDir := filepath.Join(dir, "chunks")
+	blocksDir := filepath.Join(dir, "blocks")
+	require.NoError(t, os.Mkdir(chunksDir, os.ModePerm))
+	require.NoError(t, os.Mkdir(blocksDir, os.ModePerm))
+
+	ingesterTestConfig := func() Config {
+		cfg := defaultIngesterTestConfig()
+		cfg.DistributorShardByAllLabels = true
+		cfg.LifecyclerConfig.RingConfig.ReplicationFactor = 1
+		return cfg
+	}
+
+	chunksIngesterGenerator := func() *Ingester {
+		cfg := ingesterTestConfig()
+		cfg.WALConfig.WALEnabled = true
+		cfg.WALConfig.Recover = true
+		cfg.WALConfig.Dir = chunksDir
+		cfg.WALConfig.CheckpointDuration = 100 * time.Minute
+
+		_, ing := newTestStore(t, cfg, defaultClientTestConfig(), limits, nil)
+		return ing
+	}
+
+	blocksIngesterGenerator := func() *Ingester {
+		ing, err := newIngesterMockWithTSDBStorageAndLimits(ingesterTestConfig(), limits, blocksDir, nil)
+		require.NoError(t, err)
+		require.NoError(t, services.StartAndAwaitRunning(context.Background(), ing))
+		// Wait until it's ACTIVE
+		test.Poll(t, time.Second, ring.ACTIVE, func() interface{} {
+			return ing.lifecycler.GetState()
+		})
+
+		return ing
+	}
+
+	tests := []string{"chunks", "blocks"}
+	for i, ingGenerator := range []func() *Ingester{chunksIngesterGenerator, blocksIngesterGenerator} {
+		t.Run(tests[i], func(t *testing.T) {
+			ing := ingGenerator()
+
+			userID := "1"
+			sample := client.Sample{TimestampMs: 0, Value: 1}
+			teamA1 := labels.Labels{{Name: labels.MetricName, Value: "metric_1"}, {Name: "team", Value: "a"}}
+			teamA2 := labels.Labels{{Name: labels.MetricName, Value: "metric_2"}, {Name: "team", Value: "a"}}
+			teamA3 := labels.Labels{{Name: labels.MetricName, Value: "metric_3"}, {Name: "team", Value: "a"}}
+			teamB1 := labels.Labels{{Name: labels.MetricName, Value: "metric_1"}, {Name: "team", Value: "b"}}
+
+			// Append the series of team "a" up to the limit, expect no error.
+			ctx := user.InjectOrgID(context.Background(), userID)
+			_, err = ing.Push(ctx, client.ToWriteRequest([]labels.Labels{teamA1, teamA2}, []client.Sample{sample, sample}, nil, client.API))
+			require.NoError(t, err)
+
+			testLimits := func() {
+				// Append another series of team "a", expect series-exceeded error.
+				_, err = ing.Push(ctx, client.ToWriteRequest([]labels.Labels{teamA3}, []client.Sample{sample}, nil, client.API))
+				if resp, ok := httpgrpc.HTTPResponseFromError(err); !ok || resp.Code != http.StatusTooManyRequests {
+					t.Fatalf("expected error about exceeding series per label set, got %v", err)
+				}
+
+				// Series of other teams are not affected by the limit.
+				_, err = ing.Push(ctx, client.ToWriteRequest([]labels.Labels{teamB1}, []client.Sample{sample}, nil, client.API))
+				require.NoError(t, err)
+
+				// Read series back via ingester queries.
+				res, _, err := runTestQuery(ctx, t, ing, labels.MatchRegexp, "team", "a|b")
+				require.NoError(t, err)
+
+				actual := make([]string, 0, len(res))
+				for _, stream := range res {
+					actual = append(actual, stream.Metric.String())
+				}
+				assert.ElementsMatch(t, []string{
+					`metric_1{team="a"}`,
+					`metric_2{team="a"}`,
+					`metric_1{team="b"}`,
+				}, actual)
+			}
+
+			testLimits()
+
+			// Limits should hold after restart.
+			services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+			ing = ingGenerator()
+			defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
+
+			testLimits()
+		})
+	}
+}
+
 func TestIngesterValidation(t *testing.T) {
 	_, ing := newDefaultTestStore(t)
 	defer services.StopAndAwaitTerminated(context.Background(), ing) //nolint:errcheck
@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,6 +22,7 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/thanos-io/thanos/pkg/shipper"
@@ -27,7 +30,9 @@ import (
 	"go.uber.org/atomic"
 	"golang.org/x/sync/errgroup"
 
+	"github.com/cortexproject/cortex/pkg/chunk/encoding"
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
@@ -35,6 +40,8 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/concurrency"
 	"github.com/cortexproject/cortex/pkg/util/extract"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
+	util_math "github.com/cortexproject/cortex/pkg/util/math"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 	"github.com/cortexproject/cortex/pkg/util/validation"
@@ -42,6 +49,9 @@ import (
 
 const (
 	errTSDBCreateIncompatibleState = "cannot create a new TSDB while the ingester is not in active state (current state: %s)"
+
+	// Prefix of the directories of the quarantined WALs, followed by the quarantine Unix timestamp.
+	quarantineDirPrefix = "quarantine-"
 )
 
 // Shipper interface is used to have an easy way to mock it in tests.
@@ -80,12 +90,13 @@ func (r tsdbCloseCheckResult) shouldClose() bool {
 }
 
 type userTSDB struct {
-	db             *tsdb.DB
-	userID         string
-	refCache       *cortex_tsdb.RefCache
-	activeSeries   *ActiveSeries
-	seriesInMetric *metricCounter
-	limiter        *Limiter
+	db               *tsdb.DB
+	userID           string
+	refCache         *cortex_tsdb.RefCache
+	activeSeries     *ActiveSeries
+	seriesInMetric   *metricCounter
+	seriesInLabelSet *labelSetCounter
+	limiter          *Limiter
 
 	stateMtx       sync.RWMutex
 	state          tsdbState
@@ -97,6 +108,10 @@ type userTSDB struct {
 	// Thanos shipper used to ship blocks to the storage.
 	shipper Shipper
 
+	// Serializes the shipper synchronizations, which can be run both by the
+	// shipping loop and when closing an idle TSDB.
+	shipperMtx sync.Mutex
+
 	// When deletion marker is found for the tenant (checked before shipping),
 	// shipping stops and TSDB is closed before reaching idle timeout time (if enabled).
 	deletionMarkFound atomic.Bool
@@ -105,8 +120,16 @@ type userTSDB struct {
 	lastDeletionMarkCheck atomic.Int64
 
 	// for statistics
-	ingestedAPISamples  *ewmaRate
-	ingestedRuleSamples *ewmaRate
+	ingestedAPISamples  *util_math.EwmaRate
+	ingestedRuleSamples *util_math.EwmaRate
+}
+
+// syncShipper runs the shipper's Sync() to upload unshipped blocks.
+func (u *userTSDB) syncShipper(ctx context.Context) (int, error) {
+	u.shipperMtx.Lock()
+	defer u.shipperMtx.Unlock()
+
+	return u.shipper.Sync(ctx)
 }
 
 // Explicitly wrapping the tsdb.DB functions that we use.
@@ -119,6 +142,10 @@ func (u *userTSDB) Querier(ctx context.Context, mint, maxt int64) (storage.Queri
 	return u.db.Querier(ctx, mint, maxt)
 }
 
+func (u *userTSDB) ChunkQuerier(ctx context.Context, mint, maxt int64) (storage.ChunkQuerier, error) {
+	return u.db.ChunkQuerier(ctx, mint, maxt)
+}
+
 func (u *userTSDB) Head() *tsdb.Head {
 	return u.db.Head()
 }
@@ -201,6 +228,11 @@ func (u *userTSDB) PreCreation(metric labels.Labels) error {
 		return makeMetricLimitError(perMetricSeriesLimit, metric, err)
 	}
 
+	// Series per label set limit.
+	if err := u.seriesInLabelSet.canAddSeriesFor(u.userID, metric); err != nil {
+		return makeMetricLimitError(perLabelSetSeriesLimit, metric, err)
+	}
+
 	return nil
 }
 
@@ -212,6 +244,7 @@ func (u *userTSDB) PostCreation(metric labels.Labels) {
 		return
 	}
 	u.seriesInMetric.increaseSeriesForMetric(metricName)
+	u.seriesInLabelSet.increaseSeriesFor(u.userID, metric)
 }
 
 // PostDeletion implements SeriesLifecycleCallback interface.
@@ -223,6 +256,7 @@ func (u *userTSDB) PostDeletion(metrics ...labels.Labels) {
 			continue
 		}
 		u.seriesInMetric.decreaseSeriesForMetric(metricName)
+		u.seriesInLabelSet.decreaseSeriesFor(u.userID, metric)
 	}
 }
 
@@ -325,6 +359,9 @@ type TSDBState struct {
 	compactionsTriggered   prometheus.Counter
 	compactionsFailed      prometheus.Counter
 	walReplayTime          prometheus.Histogram
+	walReplayRemaining     prometheus.Gauge
+	walQuarantined         prometheus.Counter
+	walQuarantinedTenants  prometheus.Gauge
 	appenderAddDuration    prometheus.Histogram
 	appenderCommitDuration prometheus.Histogram
 	refCachePurgeDuration  prometheus.Histogram
@@ -369,6 +406,18 @@ func newTSDBState(bucketClient objstore.Bucket, registerer prometheus.Registerer
 			Help:    "The total time it takes to open and replay a TSDB WAL.",
 			Buckets: prometheus.DefBuckets,
 		}),
+		walReplayRemaining: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ingester_tsdb_wal_replay_tenants_remaining",
+			Help: "The number of tenants whose TSDB is still to be opened, replaying the WAL, on startup.",
+		}),
+		walQuarantined: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ingester_tsdb_wal_quarantined_total",
+			Help: "Total number of tenants WALs quarantined because the TSDB failed to open on startup.",
+		}),
+		walQuarantinedTenants: promauto.With(registerer).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_ingester_tsdb_wal_quarantined_tenants",
+			Help: "The number of tenants having quarantined WALs on the local disk.",
+		}),
 		appenderAddDuration: promauto.With(registerer).NewHistogram(prometheus.HistogramOpts{
 			Name:    "cortex_ingester_tsdb_appender_add_duration_seconds",
 			Help:    "The total time it takes for a push request to add samples to the TSDB appender.",
@@ -575,8 +624,8 @@ func (i *Ingester) updateLoop(ctx context.Context) error {
 		case <-rateUpdateTicker.C:
 			i.userStatesMtx.RLock()
 			for _, db := range i.TSDBState.dbs {
-				db.ingestedAPISamples.tick()
-				db.ingestedRuleSamples.tick()
+				db.ingestedAPISamples.Tick()
+				db.ingestedRuleSamples.Tick()
 			}
 			i.userStatesMtx.RUnlock()
 		case <-refCachePurgeTicker.C:
@@ -613,6 +662,7 @@ func (i *Ingester) v2UpdateActiveSeries() {
 
 		userDB.activeSeries.Purge(purgeTime)
 		i.metrics.activeSeriesPerUser.WithLabelValues(userID).Set(float64(userDB.activeSeries.Active()))
+		updateActiveSeriesCustomTrackersMetrics(i.metrics.activeSeriesCustomTrackers, userID, userDB.activeSeries)
 	}
 }
 
@@ -778,11 +828,11 @@ func (i *Ingester) v2Push(ctx context.Context, req *client.WriteRequest) (*clien
 
 	switch req.Source {
 	case client.RULE:
-		db.ingestedRuleSamples.add(int64(succeededSamplesCount))
+		db.ingestedRuleSamples.Add(int64(succeededSamplesCount))
 	case client.API:
 		fallthrough
 	default:
-		db.ingestedAPISamples.add(int64(succeededSamplesCount))
+		db.ingestedAPISamples.Add(int64(succeededSamplesCount))
 	}
 
 	if firstPartialErr != nil {
@@ -831,6 +881,13 @@ func (i *Ingester) v2Query(ctx context.Context, req *client.QueryRequest) (*clie
 		return nil, err
 	}
 
+	// The query shard label doesn't exist in the TSDB, so it's removed from the matchers
+	// and the series are filtered by shard once selected.
+	shard, matchers, err := astmapper.RemoveShardFromMatchers(matchers)
+	if err != nil {
+		return nil, err
+	}
+
 	i.metrics.queries.Inc()
 
 	db := i.getTSDB(userID)
@@ -856,6 +913,12 @@ func (i *Ingester) v2Query(ctx context.Context, req *client.QueryRequest) (*clie
 	for ss.Next() {
 		series := ss.At()
 
+		// The series chunks are read only when iterating the series, so the series not
+		// belonging to the shard are skipped without reading their chunks.
+		if shard != nil && !shard.Matches(series.Labels()) {
+			continue
+		}
+
 		ts := client.TimeSeries{
 			Labels: client.FromLabelsToLabelAdapters(series.Labels()),
 		}
@@ -1001,6 +1064,78 @@ func (i *Ingester) v2MetricsForLabelMatchers(ctx context.Context, req *client.Me
 	return result, nil
 }
 
+func (i *Ingester) v2LabelNamesCardinality(ctx context.Context, req *client.LabelNamesCardinalityRequest) (*client.LabelNamesCardinalityResponse, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := i.getTSDB(userID)
+	if db == nil {
+		return &client.LabelNamesCardinalityResponse{}, nil
+	}
+
+	matchers, limit, err := client.FromLabelNamesCardinalityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	cardinality := newLabelNamesCardinality()
+	if err := forHeadSeriesMatching(ctx, db, matchers, cardinality.add); err != nil {
+		return nil, err
+	}
+
+	return cardinality.response(limit), nil
+}
+
+func (i *Ingester) v2LabelValuesCardinality(ctx context.Context, req *client.LabelValuesCardinalityRequest) (*client.LabelValuesCardinalityResponse, error) {
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	db := i.getTSDB(userID)
+	if db == nil {
+		return &client.LabelValuesCardinalityResponse{}, nil
+	}
+
+	labelName, matchers, limit, err := client.FromLabelValuesCardinalityRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only series having the label are relevant.
+	matchers = append(matchers, labels.MustNewMatcher(labels.MatchNotEqual, labelName, ""))
+
+	cardinality := newLabelValuesCardinality(labelName)
+	if err := forHeadSeriesMatching(ctx, db, matchers, cardinality.add); err != nil {
+		return nil, err
+	}
+
+	return cardinality.response(limit), nil
+}
+
+// forHeadSeriesMatching calls fn with the labels of each in-memory series matching the input matchers.
+func forHeadSeriesMatching(ctx context.Context, db *userTSDB, matchers []*labels.Matcher, fn func(labels.Labels)) error {
+	q, err := db.Querier(ctx, db.Head().MinTime(), db.Head().MaxTime())
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	ss := q.Select(false, nil, matchers...)
+	for ss.Next() {
+		// Interrupt if the context has been canceled.
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		fn(ss.At().Labels())
+	}
+
+	return ss.Err()
+}
+
 func (i *Ingester) v2UserStats(ctx context.Context, req *client.UserStatsRequest) (*client.UserStatsResponse, error) {
 	userID, err := tenant.TenantID(ctx)
 	if err != nil {
@@ -1034,8 +1169,8 @@ func (i *Ingester) v2AllUserStats(ctx context.Context, req *client.UserStatsRequ
 }
 
 func createUserStats(db *userTSDB) *client.UserStatsResponse {
-	apiRate := db.ingestedAPISamples.rate()
-	ruleRate := db.ingestedRuleSamples.rate()
+	apiRate := db.ingestedAPISamples.Rate()
+	ruleRate := db.ingestedRuleSamples.Rate()
 	return &client.UserStatsResponse{
 		IngestionRate:     apiRate + ruleRate,
 		ApiIngestionRate:  apiRate,
@@ -1061,6 +1196,13 @@ func (i *Ingester) v2QueryStream(req *client.QueryRequest, stream client.Ingeste
 		return err
 	}
 
+	// The query shard label doesn't exist in the TSDB, so it's removed from the matchers
+	// and the series are filtered by shard once selected.
+	shard, matchers, err := astmapper.RemoveShardFromMatchers(matchers)
+	if err != nil {
+		return err
+	}
+
 	i.metrics.queries.Inc()
 
 	db := i.getTSDB(userID)
@@ -1068,25 +1210,60 @@ func (i *Ingester) v2QueryStream(req *client.QueryRequest, stream client.Ingeste
 		return nil
 	}
 
-	q, err := db.Querier(ctx, int64(from), int64(through))
+	numSamples := 0
+	numSeries := 0
+	queryLimiter := i.newQueryLimiter(userID)
+
+	if i.cfg.StreamChunksWhenUsingBlocks {
+		numChunks := 0
+		numSeries, numChunks, err = i.v2QueryStreamChunks(ctx, db, int64(from), int64(through), matchers, shard, queryLimiter, stream)
+		if err != nil {
+			return err
+		}
+
+		i.metrics.queriedChunks.Observe(float64(numChunks))
+		level.Debug(log).Log("series", numSeries, "chunks", numChunks)
+	} else {
+		numSeries, numSamples, err = i.v2QueryStreamSamples(ctx, db, int64(from), int64(through), matchers, shard, queryLimiter, stream)
+		if err != nil {
+			return err
+		}
+
+		i.metrics.queriedSamples.Observe(float64(numSamples))
+		level.Debug(log).Log("series", numSeries, "samples", numSamples)
+	}
+
+	i.metrics.queriedSeries.Observe(float64(numSeries))
+	return nil
+}
+
+func (i *Ingester) v2QueryStreamSamples(ctx context.Context, db *userTSDB, from, through int64, matchers []*labels.Matcher, shard *astmapper.ShardAnnotation, queryLimiter *limiter.QueryLimiter, stream client.Ingester_QueryStreamServer) (numSeries, numSamples int, _ error) {
+	q, err := db.Querier(ctx, from, through)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	defer q.Close()
 
 	// It's not required to return sorted series because series are sorted by the Cortex querier.
 	ss := q.Select(false, nil, matchers...)
 	if ss.Err() != nil {
-		return ss.Err()
+		return 0, 0, ss.Err()
 	}
 
 	timeseries := make([]client.TimeSeries, 0, queryStreamBatchSize)
 	batchSizeBytes := 0
-	numSamples := 0
-	numSeries := 0
 	for ss.Next() {
 		series := ss.At()
 
+		// The series not belonging to the shard are skipped before reading their chunks.
+		if shard != nil && !shard.Matches(series.Labels()) {
+			continue
+		}
+
+		if err := enforceQueryLimits(queryLimiter, series.Labels(), nil); err != nil {
+			return 0, 0, err
+		}
+
 		// convert labels to LabelAdapter
 		ts := client.TimeSeries{
 			Labels: client.FromLabelsToLabelAdapters(series.Labels()),
@@ -1108,7 +1285,7 @@ func (i *Ingester) v2QueryStream(req *client.QueryRequest, stream client.Ingeste
 				Timeseries: timeseries,
 			})
 			if err != nil {
-				return err
+				return 0, 0, err
 			}
 
 			batchSizeBytes = 0
@@ -1121,7 +1298,7 @@ func (i *Ingester) v2QueryStream(req *client.QueryRequest, stream client.Ingeste
 
 	// Ensure no error occurred while iterating the series set.
 	if err := ss.Err(); err != nil {
-		return err
+		return 0, 0, err
 	}
 
 	// Final flush any existing metrics
@@ -1130,14 +1307,113 @@ func (i *Ingester) v2QueryStream(req *client.QueryRequest, stream client.Ingeste
 			Timeseries: timeseries,
 		})
 		if err != nil {
-			return err
+			return 0, 0, err
 		}
 	}
 
-	i.metrics.queriedSeries.Observe(float64(numSeries))
-	i.metrics.queriedSamples.Observe(float64(numSamples))
-	level.Debug(log).Log("series", numSeries, "samples", numSamples)
-	return nil
+	return numSeries, numSamples, nil
+}
+
+// v2QueryStreamChunks streams the TSDB chunks of the matching series as is, without
+// decoding them. The chunks are decoded by the querier while evaluating the query.
+func (i *Ingester) v2QueryStreamChunks(ctx context.Context, db *userTSDB, from, through int64, matchers []*labels.Matcher, shard *astmapper.ShardAnnotation, queryLimiter *limiter.QueryLimiter, stream client.Ingester_QueryStreamServer) (numSeries, numChunks int, _ error) {
+	q, err := db.ChunkQuerier(ctx, from, through)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer q.Close()
+
+	// It's not required to return sorted series because series are sorted by the Cortex querier.
+	ss := q.Select(false, nil, matchers...)
+	if ss.Err() != nil {
+		return 0, 0, ss.Err()
+	}
+
+	chunkSeries := make([]client.TimeSeriesChunk, 0, queryStreamBatchSize)
+	batchSizeBytes := 0
+	for ss.Next() {
+		series := ss.At()
+
+		// The series not belonging to the shard are skipped before reading their chunks.
+		if shard != nil && !shard.Matches(series.Labels()) {
+			continue
+		}
+
+		// convert labels to LabelAdapter
+		ts := client.TimeSeriesChunk{
+			Labels: client.FromLabelsToLabelAdapters(series.Labels()),
+		}
+
+		it := series.Iterator()
+		for it.Next() {
+			// Chunks are ordered by min time.
+			meta := it.At()
+
+			// The chunk returned by the iterator is expected to be populated.
+			if meta.Chunk == nil {
+				return 0, 0, errors.Errorf("unfilled chunk returned from TSDB chunk querier")
+			}
+
+			if meta.Chunk.Encoding() != chunkenc.EncXOR {
+				return 0, 0, errors.Errorf("unknown chunk encoding from TSDB chunk querier: %v", meta.Chunk.Encoding())
+			}
+
+			// The head chunk may be appended while we're sending it, so we copy its data.
+			ts.Chunks = append(ts.Chunks, client.Chunk{
+				StartTimestampMs: meta.MinTime,
+				EndTimestampMs:   meta.MaxTime,
+				Encoding:         int32(encoding.PrometheusXorChunk),
+				Data:             append([]byte(nil), meta.Chunk.Bytes()...),
+			})
+		}
+
+		// Ensure no error occurred while iterating the chunks.
+		if err := it.Err(); err != nil {
+			return 0, 0, err
+		}
+
+		if err := enforceQueryLimits(queryLimiter, series.Labels(), ts.Chunks); err != nil {
+			return 0, 0, err
+		}
+
+		numSeries++
+		numChunks += len(ts.Chunks)
+		tsSize := ts.Size()
+
+		if (batchSizeBytes > 0 && batchSizeBytes+tsSize > queryStreamBatchMessageSize) || len(chunkSeries) >= queryStreamBatchSize {
+			// Adding this series to the batch would make it too big,
+			// flush the data and add it to new batch instead.
+			err = client.SendQueryStream(stream, &client.QueryStreamResponse{
+				Chunkseries: chunkSeries,
+			})
+			if err != nil {
+				return 0, 0, err
+			}
+
+			batchSizeBytes = 0
+			chunkSeries = chunkSeries[:0]
+		}
+
+		chunkSeries = append(chunkSeries, ts)
+		batchSizeBytes += tsSize
+	}
+
+	// Ensure no error occurred while iterating the series set.
+	if err := ss.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	// Final flush any existing metrics
+	if batchSizeBytes != 0 {
+		err = client.SendQueryStream(stream, &client.QueryStreamResponse{
+			Chunkseries: chunkSeries,
+		})
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return numSeries, numChunks, nil
 }
 
 func (i *Ingester) getTSDB(userID string) *userTSDB {
@@ -1211,10 +1487,11 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 	userDB := &userTSDB{
 		userID:              userID,
 		refCache:            cortex_tsdb.NewRefCache(),
-		activeSeries:        NewActiveSeries(),
+		activeSeries:        newActiveSeriesForUser(i.limiter, userID),
 		seriesInMetric:      newMetricCounter(i.limiter),
-		ingestedAPISamples:  newEWMARate(0.2, i.cfg.RateUpdatePeriod),
-		ingestedRuleSamples: newEWMARate(0.2, i.cfg.RateUpdatePeriod),
+		seriesInLabelSet:    newLabelSetCounter(i.limiter),
+		ingestedAPISamples:  util_math.NewEWMARate(0.2, i.cfg.RateUpdatePeriod),
+		ingestedRuleSamples: util_math.NewEWMARate(0.2, i.cfg.RateUpdatePeriod),
 	}
 
 	// Create a new user database
@@ -1241,6 +1518,7 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 	level.Info(userLogger).Log("msg", "Running compaction after WAL replay")
 	err = db.Compact()
 	if err != nil {
+		_ = db.Close()
 		return nil, errors.Wrapf(err, "failed to compact TSDB: %s", udir)
 	}
 
@@ -1277,7 +1555,7 @@ func (i *Ingester) createTSDB(userID string) (*userTSDB, error) {
 			userLogger,
 			tsdbPromReg,
 			udir,
-			bucket.NewUserBucketClient(userID, i.TSDBState.bucket),
+			bucket.NewSSEBucketClient(userID, bucket.NewUserBucketClient(userID, i.TSDBState.bucket), i.limits),
 			func() labels.Labels { return l },
 			metadata.ReceiveSource,
 			false, // No need to upload compacted blocks. Cortex compactor takes care of that.
@@ -1327,6 +1605,14 @@ func (i *Ingester) closeAllTSDB() {
 func (i *Ingester) openExistingTSDB(ctx context.Context) error {
 	level.Info(util.Logger).Log("msg", "opening existing TSDBs")
 
+	userIDs, err := i.getTSDBUsersOnDisk()
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "error while opening existing TSDBs", "err", err)
+		return err
+	}
+
+	i.TSDBState.walReplayRemaining.Set(float64(len(userIDs)))
+
 	queue := make(chan string)
 	group, groupCtx := errgroup.WithContext(ctx)
 
@@ -1336,10 +1622,9 @@ func (i *Ingester) openExistingTSDB(ctx context.Context) error {
 			for userID := range queue {
 				startTime := time.Now()
 
-				db, err := i.createTSDB(userID)
+				db, err := i.openExistingUserTSDB(userID)
 				if err != nil {
-					level.Error(util.Logger).Log("msg", "unable to open TSDB", "err", err, "user", userID)
-					return errors.Wrapf(err, "unable to open TSDB for user %s", userID)
+					return err
 				}
 
 				// Add the database to the map of user databases
@@ -1349,53 +1634,18 @@ func (i *Ingester) openExistingTSDB(ctx context.Context) error {
 				i.metrics.memUsers.Inc()
 
 				i.TSDBState.walReplayTime.Observe(time.Since(startTime).Seconds())
+				i.TSDBState.walReplayRemaining.Dec()
 			}
 
 			return nil
 		})
 	}
 
-	// Spawn a goroutine to find all users with a TSDB on the filesystem.
+	// Enqueue the users to be processed, and close the queue once done.
 	group.Go(func() error {
-		// Close the queue once filesystem walking is done.
 		defer close(queue)
 
-		walkErr := filepath.Walk(i.cfg.BlocksStorageConfig.TSDB.Dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				// If the root directory doesn't exist, we're OK (not needed to be created upfront).
-				if os.IsNotExist(err) && path == i.cfg.BlocksStorageConfig.TSDB.Dir {
-					return filepath.SkipDir
-				}
-
-				level.Error(util.Logger).Log("msg", "an error occurred while iterating the filesystem storing TSDBs", "path", path, "err", err)
-				return errors.Wrapf(err, "an error occurred while iterating the filesystem storing TSDBs at %s", path)
-			}
-
-			// Skip root dir and all other files
-			if path == i.cfg.BlocksStorageConfig.TSDB.Dir || !info.IsDir() {
-				return nil
-			}
-
-			// Top level directories are assumed to be user TSDBs
-			userID := info.Name()
-			f, err := os.Open(path)
-			if err != nil {
-				level.Error(util.Logger).Log("msg", "unable to open TSDB dir", "err", err, "user", userID, "path", path)
-				return errors.Wrapf(err, "unable to open TSDB dir %s for user %s", path, userID)
-			}
-			defer f.Close()
-
-			// If the dir is empty skip it
-			if _, err := f.Readdirnames(1); err != nil {
-				if err == io.EOF {
-					return filepath.SkipDir
-				}
-
-				level.Error(util.Logger).Log("msg", "unable to read TSDB dir", "err", err, "user", userID, "path", path)
-				return errors.Wrapf(err, "unable to read TSDB dir %s for user %s", path, userID)
-			}
-
-			// Enqueue the user to be processed.
+		for _, userID := range userIDs {
 			select {
 			case queue <- userID:
 				// Nothing to do.
@@ -1403,25 +1653,156 @@ func (i *Ingester) openExistingTSDB(ctx context.Context) error {
 				// Interrupt in case a failure occurred in another goroutine.
 				return nil
 			}
+		}
 
-			// Don't descend into subdirectories.
-			return filepath.SkipDir
-		})
-
-		return errors.Wrapf(walkErr, "unable to walk directory %s containing existing TSDBs", i.cfg.BlocksStorageConfig.TSDB.Dir)
+		return nil
 	})
 
 	// Wait for all workers to complete.
-	err := group.Wait()
+	err = group.Wait()
 	if err != nil {
 		level.Error(util.Logger).Log("msg", "error while opening existing TSDBs", "err", err)
 		return err
 	}
 
+	i.cleanupQuarantinedTSDBWALs(time.Now())
+
 	level.Info(util.Logger).Log("msg", "successfully opened existing TSDBs")
 	return nil
 }
 
+// openExistingUserTSDB opens the existing TSDB of a user. If opening fails and the WAL quarantine is
+// enabled, the WAL is quarantined and the TSDB opened again.
+func (i *Ingester) openExistingUserTSDB(userID string) (*userTSDB, error) {
+	db, err := i.createTSDB(userID)
+	if err == nil {
+		return db, nil
+	}
+
+	level.Error(util.Logger).Log("msg", "unable to open TSDB", "err", err, "user", userID)
+	if !i.cfg.BlocksStorageConfig.TSDB.QuarantineWALOnOpenFailure {
+		return nil, errors.Wrapf(err, "unable to open TSDB for user %s", userID)
+	}
+
+	quarantineDir, qErr := quarantineTSDBWAL(i.cfg.BlocksStorageConfig.TSDB.BlocksDir(userID), time.Now())
+	if qErr != nil {
+		level.Error(util.Logger).Log("msg", "unable to quarantine the TSDB WAL", "err", qErr, "user", userID)
+		return nil, errors.Wrapf(err, "unable to open TSDB for user %s", userID)
+	}
+
+	i.TSDBState.walQuarantined.Inc()
+	level.Warn(util.Logger).Log("msg", "quarantined the TSDB WAL, opening the TSDB again", "user", userID, "quarantine_dir", quarantineDir)
+
+	db, err = i.createTSDB(userID)
+	if err != nil {
+		level.Error(util.Logger).Log("msg", "unable to open TSDB after quarantining the WAL", "err", err, "user", userID)
+		return nil, errors.Wrapf(err, "unable to open TSDB for user %s", userID)
+	}
+
+	return db, nil
+}
+
+// cleanupQuarantinedTSDBWALs deletes the quarantined WALs older than the retention, if any, and
+// updates the number of tenants having quarantined WALs on the local disk.
+func (i *Ingester) cleanupQuarantinedTSDBWALs(now time.Time) {
+	retention := i.cfg.BlocksStorageConfig.TSDB.QuarantinedWALRetention
+
+	dirs, err := filepath.Glob(filepath.Join(i.cfg.BlocksStorageConfig.TSDB.Dir, "*", quarantineDirPrefix+"*"))
+	if err != nil {
+		level.Warn(util.Logger).Log("msg", "unable to list the quarantined TSDB WALs", "err", err)
+		return
+	}
+
+	tenants := map[string]struct{}{}
+	for _, dir := range dirs {
+		quarantinedAt, err := strconv.ParseInt(strings.TrimPrefix(filepath.Base(dir), quarantineDirPrefix), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		userID := filepath.Base(filepath.Dir(dir))
+		if retention > 0 && now.Sub(time.Unix(quarantinedAt, 0)) > retention {
+			if err := os.RemoveAll(dir); err != nil {
+				level.Warn(util.Logger).Log("msg", "unable to delete the quarantined TSDB WAL", "user", userID, "quarantine_dir", dir, "err", err)
+			} else {
+				level.Info(util.Logger).Log("msg", "deleted the quarantined TSDB WAL after its retention", "user", userID, "quarantine_dir", dir)
+				continue
+			}
+		}
+
+		tenants[userID] = struct{}{}
+	}
+
+	i.TSDBState.walQuarantinedTenants.Set(float64(len(tenants)))
+}
+
+// quarantineTSDBWAL moves the WAL and the head chunks of the TSDB stored in the input directory
+// to a quarantine directory inside it, and returns the quarantine directory. The quarantine
+// directory is ignored when the TSDB is opened, because it's not a block.
+func quarantineTSDBWAL(dir string, now time.Time) (string, error) {
+	quarantineDir := filepath.Join(dir, fmt.Sprintf("%s%d", quarantineDirPrefix, now.Unix()))
+	if err := os.MkdirAll(quarantineDir, os.ModePerm); err != nil {
+		return "", err
+	}
+
+	for _, name := range []string{"wal", "chunks_head"} {
+		err := os.Rename(filepath.Join(dir, name), filepath.Join(quarantineDir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	return quarantineDir, nil
+}
+
+// getTSDBUsersOnDisk returns the users having a non-empty TSDB directory on the local disk.
+func (i *Ingester) getTSDBUsersOnDisk() ([]string, error) {
+	var userIDs []string
+
+	walkErr := filepath.Walk(i.cfg.BlocksStorageConfig.TSDB.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// If the root directory doesn't exist, we're OK (not needed to be created upfront).
+			if os.IsNotExist(err) && path == i.cfg.BlocksStorageConfig.TSDB.Dir {
+				return filepath.SkipDir
+			}
+
+			level.Error(util.Logger).Log("msg", "an error occurred while iterating the filesystem storing TSDBs", "path", path, "err", err)
+			return errors.Wrapf(err, "an error occurred while iterating the filesystem storing TSDBs at %s", path)
+		}
+
+		// Skip root dir and all other files
+		if path == i.cfg.BlocksStorageConfig.TSDB.Dir || !info.IsDir() {
+			return nil
+		}
+
+		// Top level directories are assumed to be user TSDBs
+		userID := info.Name()
+		f, err := os.Open(path)
+		if err != nil {
+			level.Error(util.Logger).Log("msg", "unable to open TSDB dir", "err", err, "user", userID, "path", path)
+			return errors.Wrapf(err, "unable to open TSDB dir %s for user %s", path, userID)
+		}
+		defer f.Close()
+
+		// If the dir is empty skip it
+		if _, err := f.Readdirnames(1); err != nil {
+			if err == io.EOF {
+				return filepath.SkipDir
+			}
+
+			level.Error(util.Logger).Log("msg", "unable to read TSDB dir", "err", err, "user", userID, "path", path)
+			return errors.Wrapf(err, "unable to read TSDB dir %s for user %s", path, userID)
+		}
+
+		userIDs = append(userIDs, userID)
+
+		// Don't descend into subdirectories.
+		return filepath.SkipDir
+	})
+
+	return userIDs, errors.Wrapf(walkErr, "unable to walk directory %s containing existing TSDBs", i.cfg.BlocksStorageConfig.TSDB.Dir)
+}
+
 // numSeriesInTSDB returns the total number of in-memory series across all open TSDBs.
 func (i *Ingester) numSeriesInTSDB() float64 {
 	i.userStatesMtx.RLock()
@@ -1502,7 +1883,7 @@ func (i *Ingester) shipBlocks(ctx context.Context) {
 		}
 
 		// Run the shipper's Sync() to upload unshipped blocks.
-		if uploaded, err := userDB.shipper.Sync(ctx); err != nil {
+		if uploaded, err := userDB.syncShipper(ctx); err != nil {
 			level.Warn(util.Logger).Log("msg", "shipper failed to synchronize TSDB blocks with the storage", "user", userID, "uploaded", uploaded, "err", err)
 		} else {
 			level.Debug(util.Logger).Log("msg", "shipper successfully synchronized TSDB blocks with storage", "user", userID, "uploaded", uploaded)
@@ -1520,6 +1901,7 @@ func (i *Ingester) compactionLoop(ctx context.Context) error {
 		select {
 		case <-ticker.C:
 			i.compactBlocks(ctx, false)
+			i.cleanupQuarantinedTSDBWALs(time.Now())
 
 		case ch := <-i.TSDBState.forceCompactTrigger:
 			i.compactBlocks(ctx, true)
@@ -1597,7 +1979,7 @@ func (i *Ingester) closeAndDeleteIdleUserTSDBs(ctx context.Context) error {
 			return nil
 		}
 
-		result := i.closeAndDeleteUserTSDBIfIdle(userID)
+		result := i.closeAndDeleteUserTSDBIfIdle(ctx, userID)
 
 		i.TSDBState.idleTsdbChecks.WithLabelValues(string(result)).Inc()
 	}
@@ -1605,14 +1987,26 @@ func (i *Ingester) closeAndDeleteIdleUserTSDBs(ctx context.Context) error {
 	return nil
 }
 
-func (i *Ingester) closeAndDeleteUserTSDBIfIdle(userID string) tsdbCloseCheckResult {
+func (i *Ingester) closeAndDeleteUserTSDBIfIdle(ctx context.Context, userID string) tsdbCloseCheckResult {
 	userDB := i.getTSDB(userID)
 	if userDB == nil || userDB.shipper == nil {
 		// We will not delete local data when not using shipping to storage.
 		return tsdbShippingDisabled
 	}
 
-	if result, err := userDB.shouldCloseTSDB(i.cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBTimeout); !result.shouldClose() {
+	result, err := userDB.shouldCloseTSDB(i.cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBTimeout)
+	if result == tsdbNotCompacted || result == tsdbNotShipped {
+		// The TSDB is idle but its data hasn't been shipped yet: we compact the head and ship the
+		// blocks right away, instead of waiting for the head compaction and shipping loops.
+		if err := i.compactAndShipIdleUserTSDB(ctx, userDB); err != nil {
+			level.Warn(util.Logger).Log("msg", "failed to compact and ship idle TSDB before closing it", "user", userID, "err", err)
+			return result
+		}
+
+		result, err = userDB.shouldCloseTSDB(i.cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBTimeout)
+	}
+
+	if !result.shouldClose() {
 		if err != nil {
 			level.Error(util.Logger).Log("msg", "cannot close idle TSDB", "user", userID, "err", err)
 		}
@@ -1658,6 +2052,7 @@ func (i *Ingester) closeAndDeleteUserTSDBIfIdle(userID string) tsdbCloseCheckRes
 	i.userStatesMtx.Unlock()
 
 	i.TSDBState.tsdbMetrics.removeRegistryForUser(userID)
+	deleteActiveSeriesCustomTrackersMetrics(i.metrics.activeSeriesCustomTrackers, userID, userDB.activeSeries)
 
 	// And delete local data.
 	if err := os.RemoveAll(dir); err != nil {
@@ -1674,6 +2069,30 @@ func (i *Ingester) closeAndDeleteUserTSDBIfIdle(userID string) tsdbCloseCheckRes
 	return tsdbIdleClosed
 }
 
+// compactAndShipIdleUserTSDB compacts the whole head of an idle TSDB and ships its blocks to the storage.
+func (i *Ingester) compactAndShipIdleUserTSDB(ctx context.Context, userDB *userTSDB) error {
+	// Without a shipper the blocks would never be shipped, so the head is not compacted either.
+	if userDB.shipper == nil {
+		return errors.New("shipping is disabled")
+	}
+
+	if userDB.Head().NumSeries() > 0 {
+		level.Info(util.Logger).Log("msg", "TSDB is idle, forcing compaction before closing it", "user", userDB.userID)
+
+		i.TSDBState.compactionsTriggered.Inc()
+		if err := userDB.compactHead(i.cfg.BlocksStorageConfig.TSDB.BlockRanges[0].Milliseconds()); err != nil {
+			i.TSDBState.compactionsFailed.Inc()
+			return errors.Wrap(err, "compact head")
+		}
+	}
+
+	if _, err := userDB.syncShipper(ctx); err != nil {
+		return errors.Wrap(err, "ship blocks")
+	}
+
+	return nil
+}
+
 // This method will flush all data. It is called as part of Lifecycler's shutdown (if flush on shutdown is configured), or from the flusher.
 //
 // When called as during Lifecycler shutdown, this happens as part of normal Ingester shutdown (see stoppingV2 method).
@@ -1695,58 +2114,62 @@ func (i *Ingester) v2LifecyclerFlush() {
 
 // Blocks version of Flush handler. It force-compacts blocks, and triggers shipping.
 func (i *Ingester) v2FlushHandler(w http.ResponseWriter, _ *http.Request) {
-	go func() {
-		ingCtx := i.BasicService.ServiceContext()
-		if ingCtx == nil || ingCtx.Err() != nil {
-			level.Info(util.Logger).Log("msg", "flushing TSDB blocks: ingester not running, ignoring flush request")
-			return
-		}
+	go i.v2CompactAndShipBlocks()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// v2CompactAndShipBlocks force-compacts the TSDB heads and triggers the shipping of the blocks,
+// waiting until done. It's a no-op if the ingester is not running.
+func (i *Ingester) v2CompactAndShipBlocks() {
+	ingCtx := i.BasicService.ServiceContext()
+	if ingCtx == nil || ingCtx.Err() != nil {
+		level.Info(util.Logger).Log("msg", "flushing TSDB blocks: ingester not running, ignoring flush request")
+		return
+	}
+
+	ch := make(chan struct{}, 1)
+
+	level.Info(util.Logger).Log("msg", "flushing TSDB blocks: triggering compaction")
+	select {
+	case i.TSDBState.forceCompactTrigger <- ch:
+		// Compacting now.
+	case <-ingCtx.Done():
+		level.Warn(util.Logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+		return
+	}
+
+	// Wait until notified about compaction being finished.
+	select {
+	case <-ch:
+		level.Info(util.Logger).Log("msg", "finished compacting TSDB blocks")
+	case <-ingCtx.Done():
+		level.Warn(util.Logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+		return
+	}
 
-		ch := make(chan struct{}, 1)
+	if i.cfg.BlocksStorageConfig.TSDB.ShipInterval > 0 {
+		level.Info(util.Logger).Log("msg", "flushing TSDB blocks: triggering shipping")
 
-		level.Info(util.Logger).Log("msg", "flushing TSDB blocks: triggering compaction")
 		select {
-		case i.TSDBState.forceCompactTrigger <- ch:
-			// Compacting now.
+		case i.TSDBState.shipTrigger <- ch:
+			// shipping now
 		case <-ingCtx.Done():
-			level.Warn(util.Logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+			level.Warn(util.Logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
 			return
 		}
 
-		// Wait until notified about compaction being finished.
+		// Wait until shipping finished.
 		select {
 		case <-ch:
-			level.Info(util.Logger).Log("msg", "finished compacting TSDB blocks")
+			level.Info(util.Logger).Log("msg", "shipping of TSDB blocks finished")
 		case <-ingCtx.Done():
-			level.Warn(util.Logger).Log("msg", "failed to compact TSDB blocks, ingester not running anymore")
+			level.Warn(util.Logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
 			return
 		}
+	}
 
-		if i.cfg.BlocksStorageConfig.TSDB.ShipInterval > 0 {
-			level.Info(util.Logger).Log("msg", "flushing TSDB blocks: triggering shipping")
-
-			select {
-			case i.TSDBState.shipTrigger <- ch:
-				// shipping now
-			case <-ingCtx.Done():
-				level.Warn(util.Logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
-
-			// Wait until shipping finished.
-			select {
-			case <-ch:
-				level.Info(util.Logger).Log("msg", "shipping of TSDB blocks finished")
-			case <-ingCtx.Done():
-				level.Warn(util.Logger).Log("msg", "failed to ship TSDB blocks, ingester not running anymore")
-				return
-			}
-		}
-
-		level.Info(util.Logger).Log("msg", "flushing TSDB blocks: finished")
-	}()
-
-	w.WriteHeader(http.StatusNoContent)
+	level.Info(util.Logger).Log("msg", "flushing TSDB blocks: finished")
 }
 
 // metadataQueryRange returns the best range to query for metadata queries based on the timerange in the ingester.
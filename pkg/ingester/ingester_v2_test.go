@@ -35,10 +35,13 @@ import (
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
 
+	"github.com/cortexproject/cortex/pkg/chunk/encoding"
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
 	"github.com/cortexproject/cortex/pkg/ring"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/test"
 	"github.com/cortexproject/cortex/pkg/util/validation"
@@ -382,6 +385,61 @@ func TestIngester_v2Push(t *testing.T) {
 	}
 }
 
+func TestIngester_v2Push_ShouldTrackActiveSeriesCustomTrackers(t *testing.T) {
+	registry := prometheus.NewRegistry()
+
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	cfg.ActiveSeriesMetricsEnabled = true
+
+	limits := defaultLimitsTestConfig()
+	limits.ActiveSeriesCustomTrackers = validation.ActiveSeriesCustomTrackersConfig{
+		{Name: "team_a", Selector: `{team="a"}`},
+		{Name: "team_b", Selector: `{team="b"}`},
+	}
+
+	tempDir, err := ioutil.TempDir("", "tsdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir) //nolint:errcheck
+
+	i, err := newIngesterMockWithTSDBStorageAndLimits(cfg, limits, tempDir, registry)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	// Wait until the ingester is ACTIVE
+	test.Poll(t, 100*time.Millisecond, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	ctx := user.InjectOrgID(context.Background(), userID)
+	req := client.ToWriteRequest(
+		[]labels.Labels{
+			labels.FromStrings(labels.MetricName, "test", "team", "a"),
+			labels.FromStrings(labels.MetricName, "test", "team", "b"),
+			labels.FromStrings(labels.MetricName, "test", "team", "a", "instance", "1"),
+			labels.FromStrings(labels.MetricName, "test", "team", "c"),
+		},
+		[]client.Sample{{Value: 1, TimestampMs: 9}, {Value: 1, TimestampMs: 9}, {Value: 1, TimestampMs: 9}, {Value: 1, TimestampMs: 9}},
+		nil,
+		client.API)
+
+	_, err = i.v2Push(ctx, req)
+	require.NoError(t, err)
+
+	i.v2UpdateActiveSeries()
+
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(`
+		# HELP cortex_ingester_active_series Number of currently active series per user.
+		# TYPE cortex_ingester_active_series gauge
+		cortex_ingester_active_series{user="1"} 4
+		# HELP cortex_ingester_active_series_custom_tracker Number of currently active series matching a custom tracker, per user.
+		# TYPE cortex_ingester_active_series_custom_tracker gauge
+		cortex_ingester_active_series_custom_tracker{name="team_a",user="1"} 2
+		cortex_ingester_active_series_custom_tracker{name="team_b",user="1"} 1
+	`), "cortex_ingester_active_series", "cortex_ingester_active_series_custom_tracker"))
+}
+
 func TestIngester_v2Push_ShouldHandleTheCaseTheCachedReferenceIsInvalid(t *testing.T) {
 	metricLabelAdapters := []client.LabelAdapter{{Name: labels.MetricName, Value: "test"}}
 	metricLabels := client.FromLabelAdaptersToLabels(metricLabelAdapters)
@@ -759,6 +817,66 @@ func Test_Ingester_v2LabelValues(t *testing.T) {
 	}
 }
 
+func Test_Ingester_v2Cardinality(t *testing.T) {
+	series := []labels.Labels{
+		{{Name: labels.MetricName, Value: "test_1"}, {Name: "route", Value: "get_user"}, {Name: "status", Value: "200"}},
+		{{Name: labels.MetricName, Value: "test_1"}, {Name: "route", Value: "get_user"}, {Name: "status", Value: "500"}},
+		{{Name: labels.MetricName, Value: "test_1"}, {Name: "route", Value: "get_post"}, {Name: "status", Value: "200"}},
+		{{Name: labels.MetricName, Value: "test_2"}},
+	}
+
+	// Create ingester
+	i, cleanup, err := newIngesterMockWithTSDBStorage(defaultIngesterTestConfig(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+	defer cleanup()
+
+	// Wait until it's ACTIVE
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	// Push series
+	ctx := user.InjectOrgID(context.Background(), "test")
+
+	for _, lbls := range series {
+		req, _, _ := mockWriteRequest(lbls, 1, 100000)
+		_, err := i.v2Push(ctx, req)
+		require.NoError(t, err)
+	}
+
+	t.Run("label names", func(t *testing.T) {
+		req, err := client.ToLabelNamesCardinalityRequest([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "test_1")}, 2)
+		require.NoError(t, err)
+
+		res, err := i.LabelNamesCardinality(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, &client.LabelNamesCardinalityResponse{
+			SeriesCountTotal: 3,
+			LabelNames: []*client.LabelNameCardinality{
+				{LabelName: labels.MetricName, SeriesCount: 3, LabelValuesCount: 1},
+				{LabelName: "route", SeriesCount: 3, LabelValuesCount: 2},
+			},
+		}, res)
+	})
+
+	t.Run("label values", func(t *testing.T) {
+		req, err := client.ToLabelValuesCardinalityRequest("status", []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, labels.MetricName, "")}, 0)
+		require.NoError(t, err)
+
+		res, err := i.LabelValuesCardinality(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, &client.LabelValuesCardinalityResponse{
+			SeriesCountTotal: 3,
+			LabelValues: []*client.LabelValueCardinality{
+				{LabelValue: "200", SeriesCount: 2},
+				{LabelValue: "500", SeriesCount: 1},
+			},
+		}, res)
+	})
+}
+
 func Test_Ingester_v2Query(t *testing.T) {
 	series := []struct {
 		lbls      labels.Labels
@@ -1356,6 +1474,201 @@ func TestIngester_v2QueryStream(t *testing.T) {
 	require.Equal(t, expectedResponse, lastResp)
 }
 
+func TestIngester_v2QueryStream_ShouldEnforceMaxFetchedSeriesPerQueryLimit(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "tsdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(tempDir) //nolint:errcheck
+
+	limits := defaultLimitsTestConfig()
+	limits.MaxFetchedSeriesPerQuery = 1
+
+	i, err := newIngesterMockWithTSDBStorageAndLimits(defaultIngesterTestConfig(), limits, tempDir, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+
+	// Wait until it's ACTIVE.
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	// Push two series.
+	ctx := user.InjectOrgID(context.Background(), userID)
+	for _, lbls := range []labels.Labels{
+		{{Name: labels.MetricName, Value: "foo"}, {Name: "l", Value: "1"}},
+		{{Name: labels.MetricName, Value: "foo"}, {Name: "l", Value: "2"}},
+	} {
+		req, _, _ := mockWriteRequest(lbls, 1, 1000)
+		_, err = i.v2Push(ctx, req)
+		require.NoError(t, err)
+	}
+
+	req := &client.QueryRequest{
+		StartTimestampMs: 0,
+		EndTimestampMs:   2000,
+		Matchers: []*client.LabelMatcher{{
+			Type:  client.EQUAL,
+			Name:  model.MetricNameLabel,
+			Value: "foo",
+		}},
+	}
+
+	err = i.v2QueryStream(req, &mockQueryStreamServer{ctx: ctx})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf(limiter.ErrMaxFetchedSeriesPerQuery, 1))
+}
+
+func TestIngester_v2QueryStream_ShouldStreamChunksWhenEnabled(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.StreamChunksWhenUsingBlocks = true
+
+	// Create ingester.
+	i, cleanup, err := newIngesterMockWithTSDBStorage(cfg, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+	defer cleanup()
+
+	// Wait until it's ACTIVE.
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	// Push a series with enough samples to span multiple TSDB chunks.
+	ctx := user.InjectOrgID(context.Background(), userID)
+
+	const samplesCount = 1000
+	samples := make([]client.Sample, 0, samplesCount)
+	for i := 0; i < samplesCount; i++ {
+		samples = append(samples, client.Sample{Value: float64(i), TimestampMs: int64(i)})
+	}
+
+	_, err = i.v2Push(ctx, writeRequestSingleSeries(labels.Labels{{Name: labels.MetricName, Value: "foo"}}, samples))
+	require.NoError(t, err)
+
+	req := &client.QueryRequest{
+		StartTimestampMs: 0,
+		EndTimestampMs:   samplesCount,
+		Matchers: []*client.LabelMatcher{{
+			Type:  client.EQUAL,
+			Name:  model.MetricNameLabel,
+			Value: "foo",
+		}},
+	}
+
+	stream := &mockQueryStreamServer{ctx: ctx, trackResponses: true}
+	require.NoError(t, i.v2QueryStream(req, stream))
+	require.Len(t, stream.responses, 1)
+	assert.Empty(t, stream.responses[0].Timeseries)
+	require.Len(t, stream.responses[0].Chunkseries, 1)
+
+	series := stream.responses[0].Chunkseries[0]
+	assert.Equal(t, []client.LabelAdapter{{Name: labels.MetricName, Value: "foo"}}, series.Labels)
+	assert.Greater(t, len(series.Chunks), 1)
+
+	// Decode the received chunks and ensure all samples have been returned.
+	var actual []client.Sample
+	for _, c := range series.Chunks {
+		require.Equal(t, int32(encoding.PrometheusXorChunk), c.Encoding)
+
+		chk, err := encoding.NewForEncoding(encoding.Encoding(c.Encoding))
+		require.NoError(t, err)
+		require.NoError(t, chk.UnmarshalFromBuf(c.Data))
+
+		it := chk.NewIterator(nil)
+		for it.Scan() {
+			pair := it.Value()
+			actual = append(actual, client.Sample{Value: float64(pair.Value), TimestampMs: int64(pair.Timestamp)})
+		}
+		require.NoError(t, it.Err())
+	}
+
+	assert.Equal(t, samples, actual)
+}
+
+func TestIngester_v2QueryStream_ShouldFilterSeriesByQueryShard(t *testing.T) {
+	const (
+		numSeries = 20
+		numShards = 3
+	)
+
+	for _, streamChunks := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream chunks: %t", streamChunks), func(t *testing.T) {
+			cfg := defaultIngesterTestConfig()
+			cfg.StreamChunksWhenUsingBlocks = streamChunks
+
+			i, cleanup, err := newIngesterMockWithTSDBStorage(cfg, nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+			defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+			defer cleanup()
+
+			// Wait until it's ACTIVE.
+			test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+				return i.lifecycler.GetState()
+			})
+
+			ctx := user.InjectOrgID(context.Background(), userID)
+			for s := 0; s < numSeries; s++ {
+				req, _, _ := mockWriteRequest(labels.Labels{{Name: labels.MetricName, Value: "foo"}, {Name: "id", Value: strconv.Itoa(s)}}, 1, 1000)
+				_, err = i.v2Push(ctx, req)
+				require.NoError(t, err)
+			}
+
+			// Query each shard and ensure each series is returned by exactly one shard.
+			seen := map[string]int{}
+			for shard := 0; shard < numShards; shard++ {
+				req := &client.QueryRequest{
+					StartTimestampMs: 0,
+					EndTimestampMs:   2000,
+					Matchers: []*client.LabelMatcher{
+						{Type: client.EQUAL, Name: model.MetricNameLabel, Value: "foo"},
+						{Type: client.EQUAL, Name: astmapper.ShardLabel, Value: fmt.Sprintf("%d_of_%d", shard, numShards)},
+					},
+				}
+
+				stream := &mockQueryStreamServer{ctx: ctx, trackResponses: true}
+				require.NoError(t, i.v2QueryStream(req, stream))
+
+				var series [][]client.LabelAdapter
+				for _, resp := range stream.responses {
+					for _, ts := range resp.Timeseries {
+						series = append(series, ts.Labels)
+					}
+					for _, ts := range resp.Chunkseries {
+						series = append(series, ts.Labels)
+					}
+				}
+				assert.NotEmpty(t, series)
+
+				for _, lbls := range series {
+					promLbls := client.FromLabelAdaptersToLabels(lbls)
+					assert.Equal(t, uint64(shard), promLbls.Hash()%numShards)
+					seen[promLbls.String()]++
+				}
+
+				// The non-streaming query should return the same series.
+				res, err := i.v2Query(ctx, req)
+				require.NoError(t, err)
+				assert.Len(t, res.Timeseries, len(series))
+			}
+
+			assert.Len(t, seen, numSeries)
+			for series, count := range seen {
+				assert.Equal(t, 1, count, series)
+			}
+
+			// A selector made of the shard matcher only should be rejected.
+			req := &client.QueryRequest{
+				StartTimestampMs: 0,
+				EndTimestampMs:   2000,
+				Matchers:         []*client.LabelMatcher{{Type: client.EQUAL, Name: astmapper.ShardLabel, Value: "1_of_3"}},
+			}
+			assert.Equal(t, astmapper.ErrShardOnlySelector, i.v2QueryStream(req, &mockQueryStreamServer{ctx: ctx}))
+		})
+	}
+}
+
 func TestIngester_v2QueryStreamManySamples(t *testing.T) {
 	// Create ingester.
 	i, cleanup, err := newIngesterMockWithTSDBStorage(defaultIngesterTestConfig(), nil)
@@ -1467,9 +1780,21 @@ func writeRequestSingleSeries(lbls labels.Labels, samples []client.Sample) *clie
 type mockQueryStreamServer struct {
 	grpc.ServerStream
 	ctx context.Context
+
+	// Responses sent to the client, only tracked if enabled.
+	trackResponses bool
+	responses      []*client.QueryStreamResponse
 }
 
 func (m *mockQueryStreamServer) Send(response *client.QueryStreamResponse) error {
+	if m.trackResponses {
+		// The ingester may reuse the response slices once sent, so we keep a copy.
+		m.responses = append(m.responses, &client.QueryStreamResponse{
+			Chunkseries: append([]client.TimeSeriesChunk(nil), response.Chunkseries...),
+			Timeseries:  append([]client.TimeSeries(nil), response.Timeseries...),
+		})
+	}
+
 	return nil
 }
 
@@ -1605,6 +1930,7 @@ func TestIngester_v2OpenExistingTSDBOnStartup(t *testing.T) {
 
 	tests := map[string]struct {
 		concurrency int
+		quarantine  bool
 		setup       func(*testing.T, string)
 		check       func(*testing.T, *Ingester)
 		expectedErr string
@@ -1710,6 +2036,36 @@ func TestIngester_v2OpenExistingTSDBOnStartup(t *testing.T) {
 			},
 			expectedErr: "unable to open TSDB for user user2",
 		},
+		"should quarantine the WAL and load the TSDB if an error occur while loading a TSDB and the quarantine is enabled": {
+			concurrency: 2,
+			quarantine:  true,
+			setup: func(t *testing.T, dir string) {
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "user0", "dummy"), 0700))
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "user1", "dummy"), 0700))
+
+				// Create a fake TSDB on disk with an empty chunks head segment file (it's invalid unless
+				// it's the last one and opening TSDB should fail).
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "user2", "wal", ""), 0700))
+				require.NoError(t, os.MkdirAll(filepath.Join(dir, "user2", "chunks_head", ""), 0700))
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user2", "chunks_head", "00000001"), nil, 0700))
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user2", "chunks_head", "00000002"), nil, 0700))
+			},
+			check: func(t *testing.T, i *Ingester) {
+				require.Equal(t, 3, len(i.TSDBState.dbs))
+				require.NotNil(t, i.getTSDB("user0"))
+				require.NotNil(t, i.getTSDB("user1"))
+				require.NotNil(t, i.getTSDB("user2"))
+
+				assert.Equal(t, float64(0), testutil.ToFloat64(i.TSDBState.walReplayRemaining))
+				assert.Equal(t, float64(1), testutil.ToFloat64(i.TSDBState.walQuarantined))
+				assert.Equal(t, float64(1), testutil.ToFloat64(i.TSDBState.walQuarantinedTenants))
+
+				// The head chunks have been moved to the quarantine directory.
+				quarantined, err := filepath.Glob(filepath.Join(i.cfg.BlocksStorageConfig.TSDB.Dir, "user2", "quarantine-*", "chunks_head", "00000001"))
+				require.NoError(t, err)
+				assert.Len(t, quarantined, 1)
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -1731,6 +2087,7 @@ func TestIngester_v2OpenExistingTSDBOnStartup(t *testing.T) {
 			ingesterCfg.BlocksStorageEnabled = true
 			ingesterCfg.BlocksStorageConfig.TSDB.Dir = tempDir
 			ingesterCfg.BlocksStorageConfig.TSDB.MaxTSDBOpeningConcurrencyOnStartup = testData.concurrency
+			ingesterCfg.BlocksStorageConfig.TSDB.QuarantineWALOnOpenFailure = testData.quarantine
 			ingesterCfg.BlocksStorageConfig.Bucket.Backend = "s3"
 			ingesterCfg.BlocksStorageConfig.Bucket.S3.Endpoint = "localhost"
 
@@ -1754,6 +2111,55 @@ func TestIngester_v2OpenExistingTSDBOnStartup(t *testing.T) {
 	}
 }
 
+func TestIngester_cleanupQuarantinedTSDBWALs(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+
+	tests := map[string]struct {
+		retention       time.Duration
+		expectedDirs    []string
+		expectedTenants int
+	}{
+		"should keep the quarantined WALs if the retention is disabled": {
+			retention:       0,
+			expectedDirs:    []string{"user1/quarantine-1599990000", "user1/quarantine-1599999000", "user2/quarantine-1599990000", "user3/quarantine-invalid"},
+			expectedTenants: 2,
+		},
+		"should delete the quarantined WALs older than the retention": {
+			retention:       time.Hour,
+			expectedDirs:    []string{"user1/quarantine-1599999000", "user3/quarantine-invalid"},
+			expectedTenants: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			tempDir, err := ioutil.TempDir("", "tsdb")
+			require.NoError(t, err)
+			defer os.RemoveAll(tempDir) //nolint:errcheck
+
+			for _, dir := range []string{"user1/quarantine-1599990000", "user1/quarantine-1599999000", "user2/quarantine-1599990000", "user3/quarantine-invalid"} {
+				require.NoError(t, os.MkdirAll(filepath.Join(tempDir, dir, "wal"), 0700))
+			}
+
+			cfg := defaultIngesterTestConfig()
+			cfg.BlocksStorageConfig.TSDB.Dir = tempDir
+			cfg.BlocksStorageConfig.TSDB.QuarantinedWALRetention = testData.retention
+
+			i := &Ingester{cfg: cfg, TSDBState: newTSDBState(nil, prometheus.NewPedanticRegistry())}
+			i.cleanupQuarantinedTSDBWALs(now)
+
+			dirs, err := filepath.Glob(filepath.Join(tempDir, "*", "quarantine-*"))
+			require.NoError(t, err)
+			for idx := range dirs {
+				dirs[idx], err = filepath.Rel(tempDir, dirs[idx])
+				require.NoError(t, err)
+			}
+			assert.ElementsMatch(t, testData.expectedDirs, dirs)
+			assert.Equal(t, float64(testData.expectedTenants), testutil.ToFloat64(i.TSDBState.walQuarantinedTenants))
+		})
+	}
+}
+
 func TestIngester_shipBlocks(t *testing.T) {
 	cfg := defaultIngesterTestConfig()
 	cfg.LifecyclerConfig.JoinAfter = 0
@@ -1836,7 +2242,7 @@ func TestIngester_dontShipBlocksWhenTenantDeletionMarkerIsPresent(t *testing.T)
 
 	numObjectsAfterMarkingTenantForDeletion := len(bucket.Objects())
 	require.Equal(t, numObjects, numObjectsAfterMarkingTenantForDeletion)
-	require.Equal(t, tsdbTenantMarkedForDeletion, i.closeAndDeleteUserTSDBIfIdle(userID))
+	require.Equal(t, tsdbTenantMarkedForDeletion, i.closeAndDeleteUserTSDBIfIdle(context.Background(), userID))
 }
 
 type shipperMock struct {
@@ -2113,8 +2519,8 @@ func Test_Ingester_v2UserStats(t *testing.T) {
 
 	// force update statistics
 	for _, db := range i.TSDBState.dbs {
-		db.ingestedAPISamples.tick()
-		db.ingestedRuleSamples.tick()
+		db.ingestedAPISamples.Tick()
+		db.ingestedRuleSamples.Tick()
 	}
 
 	// Get label names
@@ -2159,8 +2565,8 @@ func Test_Ingester_v2AllUserStats(t *testing.T) {
 
 	// force update statistics
 	for _, db := range i.TSDBState.dbs {
-		db.ingestedAPISamples.tick()
-		db.ingestedRuleSamples.tick()
+		db.ingestedAPISamples.Tick()
+		db.ingestedRuleSamples.Tick()
 	}
 
 	// Get label names
@@ -2475,6 +2881,98 @@ func TestIngester_CloseTSDBsOnShutdown(t *testing.T) {
 	require.Nil(t, db)
 }
 
+func TestIngesterCloseIdleTSDB_ShouldCompactAndShipTheHeadBeforeClosing(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	cfg.BlocksStorageConfig.TSDB.ShipInterval = time.Hour // Required to enable shipping, but never triggered by the test.
+	cfg.BlocksStorageConfig.TSDB.ShipConcurrency = 1
+	cfg.BlocksStorageConfig.TSDB.HeadCompactionInterval = time.Minute
+	cfg.BlocksStorageConfig.TSDB.HeadCompactionIdleTimeout = 0 // Idle head compaction disabled.
+	cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBTimeout = 1 * time.Second
+	cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBInterval = 1 * time.Second
+
+	// Create ingester
+	i, cleanup, err := newIngesterMockWithTSDBStorage(cfg, nil)
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), i)
+	})
+
+	// Wait until it's ACTIVE
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	// The mocked shipper marks all blocks as uploaded, like the real shipper would do.
+	m := mockUserShipper(t, i)
+	m.On("Sync", mock.Anything).Run(func(mock.Arguments) {
+		db := i.getTSDB(userID)
+		meta := &shipper.Meta{Version: shipper.MetaVersion1}
+		for _, b := range db.Blocks() {
+			meta.Uploaded = append(meta.Uploaded, b.Meta().ULID)
+		}
+		require.NoError(t, shipper.WriteMetaFile(util.Logger, db.db.Dir(), meta))
+	}).Return(1, nil)
+
+	pushSingleSample(t, i)
+
+	// Wait until the idle TSDB is compacted, shipped, closed and removed by the close idle TSDB job.
+	test.Poll(t, 10*time.Second, 0, func() interface{} {
+		i.userStatesMtx.Lock()
+		defer i.userStatesMtx.Unlock()
+		return len(i.TSDBState.dbs)
+	})
+
+	m.AssertCalled(t, "Sync", mock.Anything)
+	assert.Equal(t, float64(1), testutil.ToFloat64(i.TSDBState.compactionsTriggered))
+	assert.Equal(t, float64(1), testutil.ToFloat64(i.TSDBState.idleTsdbChecks.WithLabelValues(string(tsdbIdleClosed))))
+
+	// Pushing another sample will reopen the TSDB.
+	pushSingleSample(t, i)
+	require.NotNil(t, i.getTSDB(userID))
+}
+
+func TestIngesterCloseIdleTSDB_ShouldNotCompactNorCloseTheTSDBWhenShippingIsDisabled(t *testing.T) {
+	cfg := defaultIngesterTestConfig()
+	cfg.LifecyclerConfig.JoinAfter = 0
+	cfg.BlocksStorageConfig.TSDB.ShipInterval = 0 // Shipping disabled.
+	cfg.BlocksStorageConfig.TSDB.HeadCompactionInterval = time.Minute
+	cfg.BlocksStorageConfig.TSDB.HeadCompactionIdleTimeout = 0 // Idle head compaction disabled.
+	cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBTimeout = 1 * time.Second
+	cfg.BlocksStorageConfig.TSDB.CloseIdleTSDBInterval = 1 * time.Second
+
+	// Create ingester
+	i, cleanup, err := newIngesterMockWithTSDBStorage(cfg, nil)
+	require.NoError(t, err)
+	t.Cleanup(cleanup)
+
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	t.Cleanup(func() {
+		_ = services.StopAndAwaitTerminated(context.Background(), i)
+	})
+
+	// Wait until it's ACTIVE
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	pushSingleSample(t, i)
+
+	// Wait until the close idle TSDB job has checked the TSDB at least once after it became idle.
+	test.Poll(t, 10*time.Second, true, func() interface{} {
+		return testutil.ToFloat64(i.TSDBState.idleTsdbChecks.WithLabelValues(string(tsdbShippingDisabled))) > 0
+	})
+
+	db := i.getTSDB(userID)
+	require.NotNil(t, db)
+	assert.Equal(t, uint64(1), db.Head().NumSeries())
+	assert.Equal(t, float64(0), testutil.ToFloat64(i.TSDBState.compactionsTriggered))
+	assert.Equal(t, float64(0), testutil.ToFloat64(i.TSDBState.idleTsdbChecks.WithLabelValues(string(tsdbIdleClosed))))
+}
+
 func TestIngesterNotDeleteUnshippedBlocks(t *testing.T) {
 	chunkRange := 2 * time.Hour
 	chunkRangeMilliSec := chunkRange.Milliseconds()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net/http"
 	"net/http/httptest"
@@ -120,6 +121,74 @@ func TestIngester_ShutdownHandler(t *testing.T) {
 	}
 }
 
+func TestIngester_PrepareShutdownHandler(t *testing.T) {
+	config := defaultIngesterTestConfig()
+	clientConfig := defaultClientTestConfig()
+	limits := defaultLimitsTestConfig()
+	config.LifecyclerConfig.UnregisterOnShutdown = false
+	_, ingester := newTestStore(t, config, clientConfig, limits, nil)
+
+	// Make sure the ingester is ACTIVE in the ring.
+	test.Poll(t, time.Second, ring.ACTIVE, func() interface{} {
+		return ingester.lifecycler.GetState()
+	})
+
+	ctx := user.InjectOrgID(context.Background(), userID)
+	push := func() error {
+		req := client.ToWriteRequest([]labels.Labels{{{Name: labels.MetricName, Value: "foo"}}}, []client.Sample{{TimestampMs: 1, Value: 1}}, nil, client.API)
+		_, err := ingester.Push(ctx, req)
+		return err
+	}
+
+	serve := func(method, target string) *http.Response {
+		recorder := httptest.NewRecorder()
+		ingester.PrepareShutdownHandler(recorder, httptest.NewRequest(method, target, nil))
+		return recorder.Result()
+	}
+
+	assertPrepared := func(expected string) {
+		res := serve(http.MethodGet, "/ingester/prepare-shutdown")
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(body))
+	}
+
+	assertPrepared("unset")
+	require.NoError(t, push())
+
+	// Prepare the shutdown, keeping the instance registered in the ring on shutdown.
+	require.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/ingester/prepare-shutdown?unregister=false").StatusCode)
+	assertPrepared("set")
+	assert.Equal(t, ring.LEAVING, ingester.lifecycler.GetState())
+	assert.True(t, ingester.lifecycler.FlushOnShutdown())
+	assert.False(t, ingester.lifecycler.ShouldUnregisterOnShutdown())
+	assert.Equal(t, errIngesterReadOnly, push())
+
+	// Cancel the preparation, restoring the original state.
+	require.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/ingester/prepare-shutdown").StatusCode)
+	assertPrepared("unset")
+	assert.Equal(t, ring.ACTIVE, ingester.lifecycler.GetState())
+	assert.False(t, ingester.lifecycler.ShouldUnregisterOnShutdown())
+	require.NoError(t, push())
+
+	// An invalid unregister parameter is rejected.
+	require.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/ingester/prepare-shutdown?unregister=foo").StatusCode)
+	assertPrepared("unset")
+
+	// Prepare the shutdown again, unregistering from the ring on shutdown.
+	require.Equal(t, http.StatusNoContent, serve(http.MethodPost, "/ingester/prepare-shutdown").StatusCode)
+	assert.True(t, ingester.lifecycler.ShouldUnregisterOnShutdown())
+
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), ingester))
+	test.Poll(t, 100*time.Millisecond, 0, func() interface{} {
+		return testutils.NumTokens(config.LifecyclerConfig.RingConfig.KVStore.Mock, "localhost", ring.IngesterRingKey)
+	})
+
+	// The shutdown can't be prepared once the ingester is not running.
+	require.Equal(t, http.StatusServiceUnavailable, serve(http.MethodPost, "/ingester/prepare-shutdown").StatusCode)
+}
+
 func TestIngesterChunksTransfer(t *testing.T) {
 	limits, err := validation.NewOverrides(defaultLimitsTestConfig(), nil)
 	require.NoError(t, err)
@@ -11,6 +11,7 @@ import (
 const (
 	errMaxSeriesPerMetricLimitExceeded   = "per-metric series limit (local limit: %d global limit: %d actual local limit: %d) exceeded"
 	errMaxSeriesPerUserLimitExceeded     = "per-user series limit (local limit: %d global limit: %d actual local limit: %d) exceeded"
+	errMaxSeriesPerLabelSetLimitExceeded = "per-label set series limit (label set: %s global limit: %d actual local limit: %d) exceeded"
 	errMaxMetadataPerMetricLimitExceeded = "per-metric metadata limit (local limit: %d global limit: %d actual local limit: %d) exceeded"
 	errMaxMetadataPerUserLimitExceeded   = "per-user metric metadata limit (local limit: %d global limit: %d actual local limit: %d) exceeded"
 )
@@ -95,6 +96,17 @@ func (l *Limiter) AssertMaxSeriesPerUser(userID string, series int) error {
 	return fmt.Errorf(errMaxSeriesPerUserLimitExceeded, localLimit, globalLimit, actualLimit)
 }
 
+// AssertMaxSeriesPerLabelSet limit has not been reached compared to the current
+// number of series matching the input label set limit and returns an error if so.
+func (l *Limiter) AssertMaxSeriesPerLabelSet(userID string, limit *validation.LimitsPerLabelSet, series int) error {
+	actualLimit := l.maxSeriesPerLabelSet(userID, limit)
+	if series < actualLimit {
+		return nil
+	}
+
+	return fmt.Errorf(errMaxSeriesPerLabelSetLimitExceeded, limit.String(), limit.MaxGlobalSeries, actualLimit)
+}
+
 // AssertMaxMetricsWithMetadataPerUser limit has not been reached compared to the current
 // number of metrics with metadata in input and returns an error if so.
 func (l *Limiter) AssertMaxMetricsWithMetadataPerUser(userID string, metrics int) error {
@@ -168,6 +180,14 @@ func (l *Limiter) maxSeriesPerUser(userID string) int {
 	)
 }
 
+func (l *Limiter) maxSeriesPerLabelSet(userID string, limit *validation.LimitsPerLabelSet) int {
+	return l.maxByLocalAndGlobal(
+		userID,
+		func(string) int { return 0 },
+		func(string) int { return limit.MaxGlobalSeries },
+	)
+}
+
 func (l *Limiter) maxMetadataPerUser(userID string) int {
 	return l.maxByLocalAndGlobal(
 		userID,
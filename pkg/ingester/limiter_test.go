@@ -423,6 +423,73 @@ func TestLimiter_AssertMaxSeriesPerUser(t *testing.T) {
 	}
 }
 
+func TestLimiter_AssertMaxSeriesPerLabelSet(t *testing.T) {
+	tests := map[string]struct {
+		maxGlobalSeries       int
+		ringReplicationFactor int
+		ringIngesterCount     int
+		shardByAllLabels      bool
+		series                int
+		expected              error
+	}{
+		"limit is disabled": {
+			maxGlobalSeries:       0,
+			ringReplicationFactor: 1,
+			ringIngesterCount:     1,
+			shardByAllLabels:      true,
+			series:                100,
+			expected:              nil,
+		},
+		"limit is ignored if shard-by-all-labels is disabled": {
+			maxGlobalSeries:       1000,
+			ringReplicationFactor: 3,
+			ringIngesterCount:     10,
+			shardByAllLabels:      false,
+			series:                1000,
+			expected:              nil,
+		},
+		"current number of series is below the limit": {
+			maxGlobalSeries:       1000,
+			ringReplicationFactor: 3,
+			ringIngesterCount:     10,
+			shardByAllLabels:      true,
+			series:                299,
+			expected:              nil,
+		},
+		"current number of series is above the limit": {
+			maxGlobalSeries:       1000,
+			ringReplicationFactor: 3,
+			ringIngesterCount:     10,
+			shardByAllLabels:      true,
+			series:                300,
+			expected:              fmt.Errorf(errMaxSeriesPerLabelSetLimitExceeded, `{team="a"}`, 1000, 300),
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			// Mock the ring
+			ring := &ringCountMock{}
+			ring.On("HealthyInstancesCount").Return(testData.ringIngesterCount)
+			ring.On("ZonesCount").Return(1)
+
+			// Mock limits
+			limit := &validation.LimitsPerLabelSet{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: testData.maxGlobalSeries}
+			limits, err := validation.NewOverrides(validation.Limits{
+				LimitsPerLabelSet: []*validation.LimitsPerLabelSet{limit},
+			}, nil)
+			require.NoError(t, err)
+
+			limiter := NewLimiter(limits, ring, util.ShardingStrategyDefault, testData.shardByAllLabels, testData.ringReplicationFactor, false)
+			actual := limiter.AssertMaxSeriesPerLabelSet("test", limit, testData.series)
+
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
 func TestLimiter_AssertMaxMetricsWithMetadataPerUser(t *testing.T) {
 	tests := map[string]struct {
 		maxLocalMetadataPerUser  int
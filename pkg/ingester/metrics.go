@@ -52,7 +52,8 @@ type ingesterMetrics struct {
 	droppedChunks                 prometheus.Counter
 	oldestUnflushedChunkTimestamp prometheus.Gauge
 
-	activeSeriesPerUser *prometheus.GaugeVec
+	activeSeriesPerUser        *prometheus.GaugeVec
+	activeSeriesCustomTrackers *prometheus.GaugeVec
 }
 
 func newIngesterMetrics(r prometheus.Registerer, createMetricsConflictingWithTSDB bool, activeSeriesEnabled bool) *ingesterMetrics {
@@ -195,10 +196,15 @@ func newIngesterMetrics(r prometheus.Registerer, createMetricsConflictingWithTSD
 			Name: "cortex_ingester_active_series",
 			Help: "Number of currently active series per user.",
 		}, []string{"user"}),
+		activeSeriesCustomTrackers: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ingester_active_series_custom_tracker",
+			Help: "Number of currently active series matching a custom tracker, per user.",
+		}, []string{"user", "name"}),
 	}
 
 	if activeSeriesEnabled && r != nil {
 		r.MustRegister(m.activeSeriesPerUser)
+		r.MustRegister(m.activeSeriesCustomTrackers)
 	}
 
 	if createMetricsConflictingWithTSDB {
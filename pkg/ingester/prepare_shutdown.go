@@ -0,0 +1,147 @@
+package ingester
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+var (
+	errIngesterReadOnly   = httpgrpc.Errorf(http.StatusServiceUnavailable, "ingester is read-only because it has been prepared for shutdown")
+	errIngesterNotRunning = errors.New("ingester is not running")
+)
+
+// prepareShutdownState holds the state of an ingester prepared for shutdown, and the
+// lifecycler settings to restore if the preparation is canceled.
+type prepareShutdownState struct {
+	prepared                     bool
+	originalFlushOnShutdown      bool
+	originalUnregisterOnShutdown bool
+}
+
+// PrepareShutdownHandler prepares the ingester for a clean shutdown, typically before a scale-down:
+//   - GET returns whether the ingester has been prepared for shutdown ("set" or "unset").
+//   - POST switches the ingester to read-only (the instance is marked as LEAVING in the ring and
+//     new writes are rejected), enables the flush on shutdown and triggers a flush of the in-memory
+//     data to the storage. The instance is unregistered from the ring on shutdown unless the
+//     "unregister" parameter is set to false.
+//   - DELETE cancels the preparation, restoring the ingester to its original state.
+func (i *Ingester) PrepareShutdownHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		i.prepareShutdownMtx.Lock()
+		prepared := i.prepareShutdown.prepared
+		i.prepareShutdownMtx.Unlock()
+
+		if prepared {
+			util.WriteTextResponse(w, "set")
+		} else {
+			util.WriteTextResponse(w, "unset")
+		}
+
+	case http.MethodPost:
+		unregister := true
+		if value := r.FormValue("unregister"); value != "" {
+			var err error
+			if unregister, err = strconv.ParseBool(value); err != nil {
+				http.Error(w, "invalid unregister parameter: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if err := i.prepareForShutdown(r.Context(), unregister); err != nil {
+			level.Error(util.Logger).Log("msg", "failed to prepare the ingester for shutdown", "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		if err := i.cancelPrepareForShutdown(r.Context()); err != nil {
+			level.Error(util.Logger).Log("msg", "failed to cancel the ingester shutdown preparation", "err", err)
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (i *Ingester) prepareForShutdown(ctx context.Context, unregister bool) error {
+	i.prepareShutdownMtx.Lock()
+	defer i.prepareShutdownMtx.Unlock()
+
+	if i.State() != services.Running {
+		return errIngesterNotRunning
+	}
+
+	if !i.prepareShutdown.prepared {
+		// Stop receiving writes from the distributors before flushing.
+		if err := i.lifecycler.ChangeState(ctx, ring.LEAVING); err != nil {
+			return err
+		}
+
+		i.prepareShutdown = prepareShutdownState{
+			prepared:                     true,
+			originalFlushOnShutdown:      i.lifecycler.FlushOnShutdown(),
+			originalUnregisterOnShutdown: i.lifecycler.ShouldUnregisterOnShutdown(),
+		}
+		i.readOnly.Store(true)
+		i.lifecycler.SetFlushOnShutdown(true)
+	}
+
+	// The unregister setting can be changed by preparing the shutdown again.
+	i.lifecycler.SetUnregisterOnShutdown(unregister)
+
+	level.Info(util.Logger).Log("msg", "ingester prepared for shutdown, flushing in-memory data", "unregister_on_shutdown", unregister)
+	i.flushForShutdown()
+	return nil
+}
+
+func (i *Ingester) cancelPrepareForShutdown(ctx context.Context) error {
+	i.prepareShutdownMtx.Lock()
+	defer i.prepareShutdownMtx.Unlock()
+
+	if !i.prepareShutdown.prepared {
+		return nil
+	}
+
+	if i.State() != services.Running {
+		return errIngesterNotRunning
+	}
+
+	if err := i.lifecycler.ChangeState(ctx, ring.ACTIVE); err != nil {
+		return err
+	}
+
+	i.readOnly.Store(false)
+	i.lifecycler.SetFlushOnShutdown(i.prepareShutdown.originalFlushOnShutdown)
+	i.lifecycler.SetUnregisterOnShutdown(i.prepareShutdown.originalUnregisterOnShutdown)
+	i.prepareShutdown = prepareShutdownState{}
+
+	level.Info(util.Logger).Log("msg", "ingester shutdown preparation canceled")
+	return nil
+}
+
+// flushForShutdown triggers a flush of the in-memory data to the storage, without waiting
+// for its completion. Data received after the flush is flushed on shutdown anyway.
+func (i *Ingester) flushForShutdown() {
+	if i.cfg.BlocksStorageEnabled {
+		go i.v2CompactAndShipBlocks()
+		return
+	}
+
+	i.sweepUsers(true)
+}
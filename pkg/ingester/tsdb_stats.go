@@ -0,0 +1,108 @@
+package ingester
+
+import (
+	"html/template"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+var tsdbStatsPageTemplate = template.Must(template.New("tsdb_stats").Funcs(template.FuncMap{
+	"formatTimestamp": func(ts int64) string {
+		if ts == math.MinInt64 || ts == math.MaxInt64 || ts == 0 {
+			return "-"
+		}
+		return util.TimeFromMillis(ts).UTC().Format(time.RFC3339)
+	},
+}).Parse(`
+	<!DOCTYPE html>
+	<html>
+		<head>
+			<meta charset="UTF-8">
+			<title>Cortex Ingester TSDB Stats</title>
+		</head>
+		<body>
+			<h1>Cortex Ingester TSDB Stats</h1>
+			<p>Current time: {{ .Now.UTC.Format "2006-01-02T15:04:05Z07:00" }}</p>
+			<table border="1" cellpadding="5" style="border-collapse: collapse">
+				<thead>
+					<tr>
+						<th>Tenant</th>
+						<th>Series</th>
+						<th>Active series</th>
+						<th>Ingestion rate</th>
+						<th>Head min time</th>
+						<th>Head max time</th>
+						<th>Local blocks</th>
+						<th>Last push</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{ range .Tenants }}
+					<tr>
+						<td>{{ .UserID }}</td>
+						<td align="right">{{ .NumSeries }}</td>
+						<td align="right">{{ .ActiveSeries }}</td>
+						<td align="right">{{ printf "%.2f" .IngestionRate }}</td>
+						<td>{{ formatTimestamp .HeadMinTime }}</td>
+						<td>{{ formatTimestamp .HeadMaxTime }}</td>
+						<td align="right">{{ .NumBlocks }}</td>
+						<td>{{ formatTimestamp .LastUpdate }}</td>
+					</tr>
+					{{ end }}
+				</tbody>
+			</table>
+		</body>
+	</html>`))
+
+// tenantTSDBStats holds the stats of a tenant TSDB.
+type tenantTSDBStats struct {
+	UserID        string  `json:"user_id"`
+	NumSeries     uint64  `json:"num_series"`
+	ActiveSeries  int     `json:"active_series"`
+	IngestionRate float64 `json:"ingestion_rate"`
+	HeadMinTime   int64   `json:"head_min_time"`
+	HeadMaxTime   int64   `json:"head_max_time"`
+	NumBlocks     int     `json:"num_blocks"`
+	LastUpdate    int64   `json:"last_update"`
+}
+
+// TSDBStatsHandler shows the stats of the tenant TSDBs open in the ingester.
+// It's only supported by the blocks storage.
+func (i *Ingester) TSDBStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if !i.cfg.BlocksStorageEnabled {
+		http.Error(w, "the TSDB stats are only available with the blocks storage", http.StatusNotFound)
+		return
+	}
+
+	i.userStatesMtx.RLock()
+	tenants := make([]tenantTSDBStats, 0, len(i.TSDBState.dbs))
+	for userID, db := range i.TSDBState.dbs {
+		tenants = append(tenants, tenantTSDBStats{
+			UserID:        userID,
+			NumSeries:     db.Head().NumSeries(),
+			ActiveSeries:  db.activeSeries.Active(),
+			IngestionRate: db.ingestedAPISamples.Rate() + db.ingestedRuleSamples.Rate(),
+			HeadMinTime:   db.Head().MinTime(),
+			HeadMaxTime:   db.Head().MaxTime(),
+			NumBlocks:     len(db.Blocks()),
+			LastUpdate:    db.lastUpdate.Load() * 1000,
+		})
+	}
+	i.userStatesMtx.RUnlock()
+
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].UserID < tenants[j].UserID
+	})
+
+	util.RenderHTTPResponse(w, struct {
+		Now     time.Time         `json:"now"`
+		Tenants []tenantTSDBStats `json:"tenants"`
+	}{
+		Now:     time.Now(),
+		Tenants: tenants,
+	}, tsdbStatsPageTemplate, r)
+}
@@ -0,0 +1,65 @@
+package ingester
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestIngester_TSDBStatsHandler(t *testing.T) {
+	i, cleanup, err := newIngesterMockWithTSDBStorage(defaultIngesterTestConfig(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), i))
+	defer services.StopAndAwaitTerminated(context.Background(), i) //nolint:errcheck
+	defer cleanup()
+
+	// Wait until it's ACTIVE
+	test.Poll(t, 1*time.Second, ring.ACTIVE, func() interface{} {
+		return i.lifecycler.GetState()
+	})
+
+	for _, userID := range []string{"user-2", "user-1"} {
+		ctx := user.InjectOrgID(context.Background(), userID)
+		req, _, _ := mockWriteRequest(labels.Labels{{Name: labels.MetricName, Value: "test"}}, 1, 100000)
+		_, err := i.v2Push(ctx, req)
+		require.NoError(t, err)
+	}
+
+	// The stats are returned in JSON if requested.
+	req := httptest.NewRequest("GET", "/ingester/tsdb_stats", nil)
+	req.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	i.TSDBStatsHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	res := struct {
+		Tenants []tenantTSDBStats `json:"tenants"`
+	}{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(t, res.Tenants, 2)
+	assert.Equal(t, "user-1", res.Tenants[0].UserID)
+	assert.Equal(t, "user-2", res.Tenants[1].UserID)
+	assert.Equal(t, uint64(1), res.Tenants[0].NumSeries)
+	assert.Equal(t, int64(100000), res.Tenants[0].HeadMinTime)
+	assert.Equal(t, int64(100000), res.Tenants[0].HeadMaxTime)
+
+	// The stats are rendered in HTML otherwise.
+	req = httptest.NewRequest("GET", "/ingester/tsdb_stats", nil)
+	rec = httptest.NewRecorder()
+	i.TSDBStatsHandler(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<td>user-1</td>")
+	assert.Contains(t, rec.Body.String(), "1970-01-01T00:01:40Z")
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/extract"
+	util_math "github.com/cortexproject/cortex/pkg/util/math"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 	"github.com/cortexproject/cortex/pkg/util/validation"
 )
@@ -40,11 +41,12 @@ type userState struct {
 	fpToSeries          *seriesMap
 	mapper              *fpMapper
 	index               *index.InvertedIndex
-	ingestedAPISamples  *ewmaRate
-	ingestedRuleSamples *ewmaRate
+	ingestedAPISamples  *util_math.EwmaRate
+	ingestedRuleSamples *util_math.EwmaRate
 	activeSeries        *ActiveSeries
 
-	seriesInMetric *metricCounter
+	seriesInMetric   *metricCounter
+	seriesInLabelSet *labelSetCounter
 
 	// Series metrics.
 	memSeries             prometheus.Gauge
@@ -57,8 +59,9 @@ type userState struct {
 
 // DiscardedSamples metric labels
 const (
-	perUserSeriesLimit   = "per_user_series_limit"
-	perMetricSeriesLimit = "per_metric_series_limit"
+	perUserSeriesLimit     = "per_user_series_limit"
+	perMetricSeriesLimit   = "per_metric_series_limit"
+	perLabelSetSeriesLimit = "per_label_set_series_limit"
 )
 
 func newUserStates(limiter *Limiter, cfg Config, metrics *ingesterMetrics) *userStates {
@@ -86,6 +89,7 @@ func (us *userStates) gc() {
 			us.states.Delete(key)
 			state.activeSeries.clear()
 			state.activeSeriesGauge.Set(0)
+			deleteActiveSeriesCustomTrackersMetrics(us.metrics.activeSeriesCustomTrackers, state.userID, state.activeSeries)
 		}
 		return true
 	})
@@ -94,8 +98,8 @@ func (us *userStates) gc() {
 func (us *userStates) updateRates() {
 	us.states.Range(func(key, value interface{}) bool {
 		state := value.(*userState)
-		state.ingestedAPISamples.tick()
-		state.ingestedRuleSamples.tick()
+		state.ingestedAPISamples.Tick()
+		state.ingestedRuleSamples.Tick()
 		return true
 	})
 }
@@ -112,6 +116,7 @@ func (us *userStates) purgeAndUpdateActiveSeries(purgeTime time.Time) {
 		state := value.(*userState)
 		state.activeSeries.Purge(purgeTime)
 		state.activeSeriesGauge.Set(float64(state.activeSeries.Active()))
+		updateActiveSeriesCustomTrackersMetrics(us.metrics.activeSeriesCustomTrackers, state.userID, state.activeSeries)
 		return true
 	})
 }
@@ -137,9 +142,10 @@ func (us *userStates) getOrCreate(userID string) *userState {
 			fpToSeries:          newSeriesMap(),
 			fpLocker:            newFingerprintLocker(16 * 1024),
 			index:               index.New(),
-			ingestedAPISamples:  newEWMARate(0.2, us.cfg.RateUpdatePeriod),
-			ingestedRuleSamples: newEWMARate(0.2, us.cfg.RateUpdatePeriod),
+			ingestedAPISamples:  util_math.NewEWMARate(0.2, us.cfg.RateUpdatePeriod),
+			ingestedRuleSamples: util_math.NewEWMARate(0.2, us.cfg.RateUpdatePeriod),
 			seriesInMetric:      newMetricCounter(us.limiter),
+			seriesInLabelSet:    newLabelSetCounter(us.limiter),
 
 			memSeries:             us.metrics.memSeries,
 			memSeriesCreatedTotal: us.metrics.memSeriesCreatedTotal.WithLabelValues(userID),
@@ -147,7 +153,7 @@ func (us *userStates) getOrCreate(userID string) *userState {
 			discardedSamples:      validation.DiscardedSamples.MustCurryWith(prometheus.Labels{"user": userID}),
 			createdChunks:         us.metrics.createdChunks,
 
-			activeSeries:      NewActiveSeries(),
+			activeSeries:      newActiveSeriesForUser(us.limiter, userID),
 			activeSeriesGauge: us.metrics.activeSeriesPerUser.WithLabelValues(userID),
 		}
 		state.mapper = newFPMapper(state.fpToSeries)
@@ -167,6 +173,7 @@ func (us *userStates) teardown() {
 		u.memSeriesRemovedTotal.Add(float64(u.fpToSeries.length()))
 		u.memSeries.Sub(float64(u.fpToSeries.length()))
 		u.activeSeriesGauge.Set(0)
+		deleteActiveSeriesCustomTrackersMetrics(us.metrics.activeSeriesCustomTrackers, u.userID, u.activeSeries)
 		us.metrics.memUsers.Dec()
 	}
 }
@@ -239,11 +246,18 @@ func (u *userState) createSeriesWithFingerprint(fp model.Fingerprint, metric lab
 			// WARNING: returns a reference to `metric`
 			return nil, makeMetricLimitError(perMetricSeriesLimit, client.FromLabelAdaptersToLabels(metric), err)
 		}
+
+		// Check if any of the per-label set limits has been exceeded
+		if err = u.seriesInLabelSet.canAddSeriesFor(u.userID, client.FromLabelAdaptersToLabels(metric)); err != nil {
+			// WARNING: returns a reference to `metric`
+			return nil, makeMetricLimitError(perLabelSetSeriesLimit, client.FromLabelAdaptersToLabels(metric), err)
+		}
 	}
 
 	u.memSeriesCreatedTotal.Inc()
 	u.memSeries.Inc()
 	u.seriesInMetric.increaseSeriesForMetric(metricName)
+	u.seriesInLabelSet.increaseSeriesFor(u.userID, client.FromLabelAdaptersToLabels(metric))
 
 	if record != nil {
 		lbls := make(labels.Labels, 0, len(metric))
@@ -275,6 +289,7 @@ func (u *userState) removeSeries(fp model.Fingerprint, metric labels.Labels) {
 	}
 
 	u.seriesInMetric.decreaseSeriesForMetric(metricName)
+	u.seriesInLabelSet.decreaseSeriesFor(u.userID, metric)
 
 	u.memSeriesRemovedTotal.Inc()
 	u.memSeries.Dec()
@@ -400,3 +415,92 @@ func (m *metricCounter) increaseSeriesForMetric(metric string) {
 	shard.m[metric]++
 	shard.mtx.Unlock()
 }
+
+// labelSetCounter keeps track of the number of series matching each of the
+// tenant's limits per label set.
+type labelSetCounter struct {
+	limiter *Limiter
+
+	mtx sync.Mutex
+	m   map[string]int
+}
+
+func newLabelSetCounter(limiter *Limiter) *labelSetCounter {
+	return &labelSetCounter{
+		limiter: limiter,
+		m:       map[string]int{},
+	}
+}
+
+// limitsFor returns the limits per label set of the input user. The limiter
+// is not set when the ingester runs as a flusher, and no limit applies.
+func (c *labelSetCounter) limitsFor(userID string) []*validation.LimitsPerLabelSet {
+	if c.limiter == nil {
+		return nil
+	}
+
+	return c.limiter.limits.LimitsPerLabelSet(userID)
+}
+
+func (c *labelSetCounter) canAddSeriesFor(userID string, series labels.Labels) error {
+	limits := c.limitsFor(userID)
+	if len(limits) == 0 {
+		return nil
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, limit := range limits {
+		if !limit.Matches(series) {
+			continue
+		}
+
+		if err := c.limiter.AssertMaxSeriesPerLabelSet(userID, limit, c.m[limit.String()]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *labelSetCounter) increaseSeriesFor(userID string, series labels.Labels) {
+	limits := c.limitsFor(userID)
+	if len(limits) == 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, limit := range limits {
+		if limit.Matches(series) {
+			c.m[limit.String()]++
+		}
+	}
+}
+
+func (c *labelSetCounter) decreaseSeriesFor(userID string, series labels.Labels) {
+	limits := c.limitsFor(userID)
+	if len(limits) == 0 {
+		return
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, limit := range limits {
+		if !limit.Matches(series) {
+			continue
+		}
+
+		// The series may have been created before the label set was configured,
+		// in which case it hasn't been accounted.
+		key := limit.String()
+		if c.m[key] <= 1 {
+			delete(c.m, key)
+		} else {
+			c.m[key]--
+		}
+	}
+}
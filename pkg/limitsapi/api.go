@@ -0,0 +1,175 @@
+package limitsapi
+
+import (
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// maxLimitsRequestSize is the max size of the limits posted to the API.
+const maxLimitsRequestSize = 1024 * 1024
+
+// API serves the endpoints to read and update the per-tenant limits.
+type API struct {
+	cfg              Config
+	store            *Store
+	overrides        *validation.Overrides
+	shardByAllLabels bool
+	logger           log.Logger
+}
+
+// NewAPI makes a new API. The overrides are used to return the limits currently
+// applied to the tenants, and shardByAllLabels to validate the updated limits.
+func NewAPI(cfg Config, store *Store, overrides *validation.Overrides, shardByAllLabels bool, logger log.Logger) *API {
+	return &API{
+		cfg:              cfg,
+		store:            store,
+		overrides:        overrides,
+		shardByAllLabels: shardByAllLabels,
+		logger:           logger,
+	}
+}
+
+// ListTenantsHandler returns the tenants with limits set via the API.
+func (a *API) ListTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	if !a.authorize(w, r) {
+		return
+	}
+
+	userIDs, err := a.store.ListTenants(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.Strings(userIDs)
+	util.WriteYAMLResponse(w, struct {
+		Tenants []string `yaml:"tenants"`
+	}{Tenants: userIDs})
+}
+
+// GetLimitsHandler returns the limits of the tenant. By default the limits set via
+// the API are returned as set, while the "applied" URL parameter set to "true" returns
+// the limits currently applied to the tenant, whatever the source.
+func (a *API) GetLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if r.URL.Query().Get("applied") == "true" {
+		util.WriteYAMLResponse(w, a.overrides.Limits(userID))
+		return
+	}
+
+	doc, err := a.store.GetLimits(r.Context(), userID)
+	if errors.Is(err, ErrLimitsNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/yaml")
+	// Ignore inactionable errors.
+	_, _ = w.Write(doc)
+}
+
+// SetLimitsHandler sets the limits of the tenant, replacing the limits previously
+// set via the API. The limits not set in the request keep the value of the runtime
+// config for the tenant, or the global limits.
+func (a *API) SetLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxLimitsRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limits, err := parseLimits(buf)
+	if err == nil {
+		err = limits.Validate(a.shardByAllLabels)
+	}
+	if err != nil {
+		http.Error(w, "invalid limits: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.store.SetLimits(r.Context(), userID, buf); err != nil {
+		level.Error(a.logger).Log("msg", "failed to set the tenant limits", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(a.logger).Log("msg", "tenant limits updated", "user", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteLimitsHandler deletes the limits of the tenant set via the API.
+func (a *API) DeleteLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := a.tenantFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	err := a.store.DeleteLimits(r.Context(), userID)
+	if errors.Is(err, ErrLimitsNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		level.Error(a.logger).Log("msg", "failed to delete the tenant limits", "user", userID, "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(a.logger).Log("msg", "tenant limits deleted", "user", userID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// tenantFromRequest authorizes the request and returns the tenant in the URL path.
+func (a *API) tenantFromRequest(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !a.authorize(w, r) {
+		return "", false
+	}
+
+	userID := mux.Vars(r)["tenant"]
+	if err := validateTenantID(userID); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+
+	return userID, true
+}
+
+// authorize returns whether the request has been sent by an admin tenant,
+// otherwise it writes the error response.
+func (a *API) authorize(w http.ResponseWriter, r *http.Request) bool {
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return false
+	}
+
+	if !util.StringsContain(a.cfg.AdminTenants, userID) {
+		http.Error(w, "the tenant is not allowed to manage the limits", http.StatusForbidden)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,81 @@
+package limitsapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestAPI(t *testing.T) {
+	defaults := setDefaultLimits(t)
+
+	cfg := Config{AdminTenants: []string{"admin"}}
+	s := newStore(consul.NewInMemoryClient(GetCodec()), log.NewNopLogger(), nil)
+
+	overrides, err := validation.NewOverrides(defaults, s.TenantLimits(nil))
+	require.NoError(t, err)
+
+	a := NewAPI(cfg, s, overrides, true, log.NewNopLogger())
+	router := mux.NewRouter()
+	router.Path("/api/v1/admin/limits").Methods("GET").HandlerFunc(a.ListTenantsHandler)
+	router.Path("/api/v1/admin/limits/{tenant}").Methods("GET").HandlerFunc(a.GetLimitsHandler)
+	router.Path("/api/v1/admin/limits/{tenant}").Methods("PUT").HandlerFunc(a.SetLimitsHandler)
+	router.Path("/api/v1/admin/limits/{tenant}").Methods("DELETE").HandlerFunc(a.DeleteLimitsHandler)
+
+	do := func(orgID, method, path, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req = req.WithContext(user.InjectOrgID(context.Background(), orgID))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// Only the admin tenants are allowed.
+	assert.Equal(t, http.StatusForbidden, do("user-1", "PUT", "/api/v1/admin/limits/user-1", "ingestion_rate: 100\n").Code)
+	assert.Equal(t, http.StatusForbidden, do("user-1", "GET", "/api/v1/admin/limits", "").Code)
+
+	// Invalid requests are rejected.
+	assert.Equal(t, http.StatusBadRequest, do("admin", "PUT", "/api/v1/admin/limits/user-1", "unknown_limit: 1\n").Code)
+	assert.Equal(t, http.StatusBadRequest, do("admin", "PUT", "/api/v1/admin/limits/user-1", "ingestion_rate: not-a-number\n").Code)
+	assert.Equal(t, http.StatusBadRequest, do("admin", "PUT", "/api/v1/admin/limits/user%201", "ingestion_rate: 100\n").Code)
+	assert.Equal(t, http.StatusNotFound, do("admin", "GET", "/api/v1/admin/limits/user-1", "").Code)
+
+	// The limits are set and applied.
+	assert.Equal(t, http.StatusNoContent, do("admin", "PUT", "/api/v1/admin/limits/user-1", "ingestion_rate: 100\n").Code)
+	assert.Equal(t, float64(100), overrides.IngestionRate("user-1"))
+	assert.Equal(t, defaults.IngestionRate, overrides.IngestionRate("user-2"))
+
+	res := do("admin", "GET", "/api/v1/admin/limits", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "tenants:\n- user-1\n", res.Body.String())
+
+	res = do("admin", "GET", "/api/v1/admin/limits/user-1", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Equal(t, "ingestion_rate: 100\n", res.Body.String())
+
+	res = do("admin", "GET", "/api/v1/admin/limits/user-2?applied=true", "")
+	assert.Equal(t, http.StatusOK, res.Code)
+	assert.Contains(t, res.Body.String(), "ingestion_rate: 25000\n")
+
+	// The limits are deleted.
+	assert.Equal(t, http.StatusNoContent, do("admin", "DELETE", "/api/v1/admin/limits/user-1", "").Code)
+	assert.Equal(t, defaults.IngestionRate, overrides.IngestionRate("user-1"))
+	assert.Equal(t, http.StatusNotFound, do("admin", "GET", "/api/v1/admin/limits/user-1", "").Code)
+}
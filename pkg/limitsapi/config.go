@@ -0,0 +1,47 @@
+package limitsapi
+
+import (
+	"flag"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	errNoAdminTenants     = errors.New("the limits API requires at least one admin tenant")
+	errUnsupportedKVStore = errors.New("the limits API doesn't support the memberlist KV store")
+)
+
+// Config configures the per-tenant limits API and the storage of the limits.
+type Config struct {
+	Enabled      bool                   `yaml:"enabled"`
+	AdminTenants flagext.StringSliceCSV `yaml:"admin_tenants"`
+
+	KVStore kv.Config `yaml:"kvstore" doc:"description=The key-value store used to store the per-tenant limits, watched by all the components to apply the changes. The memberlist backend is not supported."`
+}
+
+// RegisterFlags registers the flags for the limits API config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.KVStore.RegisterFlagsWithPrefix("limits-api.", "limits-api/", f)
+
+	f.BoolVar(&cfg.Enabled, "limits-api.enabled", false, "Enable the API to read and update the per-tenant limits. The limits set via the API are stored in the KV store and applied on top of the limits of the runtime config for the same tenant.")
+	f.Var(&cfg.AdminTenants, "limits-api.admin-tenants", "Comma separated list of tenants allowed to read and update the limits of any tenant via the API.")
+}
+
+// Validate the config and returns an error if the validation fails.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if len(cfg.AdminTenants) == 0 {
+		return errNoAdminTenants
+	}
+	if cfg.KVStore.Store == "memberlist" {
+		return errUnsupportedKVStore
+	}
+
+	return nil
+}
@@ -0,0 +1,401 @@
+package limitsapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// KV Limits Storage Schema
+// ========================
+// Key: "<prefix>limits"
+// Value: JSON object holding, for each tenant, the limits set via the API as a YAML document
+// with the same schema of a tenant in the runtime config overrides.
+//
+// All the limits are stored under a single key, so that each component watches a single key
+// and the updates of the limits of different tenants are serialized by the CAS.
+
+const limitsKey = "limits"
+
+var (
+	// ErrLimitsNotFound is returned when no limits are stored for a tenant.
+	ErrLimitsNotFound = errors.New("limits not found")
+
+	errEmptyLimits = errors.New("the limits are empty")
+)
+
+// tenantsLimits is the value stored in the KV store.
+type tenantsLimits struct {
+	// The YAML documents of the limits set via the API, keyed by tenant ID.
+	Tenants map[string]string `json:"tenants"`
+}
+
+func (l *tenantsLimits) clone() *tenantsLimits {
+	out := &tenantsLimits{Tenants: make(map[string]string, len(l.Tenants))}
+	for userID, doc := range l.Tenants {
+		out.Tenants[userID] = doc
+	}
+	return out
+}
+
+// limitsCodec encodes the tenantsLimits in JSON.
+type limitsCodec struct{}
+
+func (limitsCodec) CodecID() string {
+	return "limitsapi"
+}
+
+func (limitsCodec) Decode(data []byte) (interface{}, error) {
+	out := &tenantsLimits{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (limitsCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// GetCodec returns the codec used to encode the limits in the KV store.
+func GetCodec() codec.Codec {
+	return limitsCodec{}
+}
+
+// Store stores the per-tenant limits in the KV store, and watches them to apply the
+// changes in memory.
+type Store struct {
+	services.Service
+
+	kv     kv.Client
+	logger log.Logger
+
+	limitsMtx sync.RWMutex
+	docs      map[string]string
+	limits    map[string]*validation.Limits
+	merged    map[string]mergedLimits
+
+	// Metrics.
+	loadSuccess prometheus.Gauge
+	tenants     prometheus.Gauge
+}
+
+// mergedLimits caches the limits set via the API applied on top of the base limits of a tenant.
+type mergedLimits struct {
+	base   *validation.Limits
+	limits *validation.Limits
+}
+
+// NewStore makes a new Store.
+func NewStore(cfg Config, logger log.Logger, reg prometheus.Registerer) (*Store, error) {
+	kvClient, err := kv.NewClient(cfg.KVStore, GetCodec(), kv.RegistererWithKVName(reg, "limits-api"))
+	if err != nil {
+		return nil, err
+	}
+
+	return newStore(kvClient, logger, reg), nil
+}
+
+func newStore(kvClient kv.Client, logger log.Logger, reg prometheus.Registerer) *Store {
+	s := &Store{
+		kv:     kvClient,
+		logger: logger,
+		docs:   map[string]string{},
+		limits: map[string]*validation.Limits{},
+		merged: map[string]mergedLimits{},
+		loadSuccess: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_limits_api_last_reload_successful",
+			Help: "Whether the last reload of the per-tenant limits from the KV store was successful.",
+		}),
+		tenants: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_limits_api_tenants",
+			Help: "Number of tenants with limits loaded from the KV store.",
+		}),
+	}
+
+	s.Service = services.NewBasicService(s.starting, s.running, nil)
+	return s
+}
+
+// The limits are loaded before the service is running, so that the
+// components depending on it start with the stored limits.
+func (s *Store) starting(ctx context.Context) error {
+	value, err := s.kv.Get(ctx, limitsKey)
+	if err != nil {
+		return errors.Wrap(err, "failed to load the per-tenant limits")
+	}
+
+	return errors.Wrap(s.reload(value), "failed to load the per-tenant limits")
+}
+
+func (s *Store) running(ctx context.Context) error {
+	s.kv.WatchKey(ctx, limitsKey, func(value interface{}) bool {
+		if err := s.reload(value); err != nil {
+			// Keep serving with the last loaded limits.
+			level.Warn(s.logger).Log("msg", "failed to reload the per-tenant limits", "err", err)
+		}
+		return true
+	})
+
+	return nil
+}
+
+// reload applies the limits read from the KV store.
+func (s *Store) reload(value interface{}) error {
+	docs := map[string]string{}
+	if value != nil {
+		docs = value.(*tenantsLimits).Tenants
+	}
+
+	limits := make(map[string]*validation.Limits, len(docs))
+	for userID, doc := range docs {
+		l, err := parseLimits([]byte(doc))
+		if err != nil {
+			s.loadSuccess.Set(0)
+			return errors.Wrapf(err, "failed to parse the limits of tenant %s", userID)
+		}
+		limits[userID] = l
+	}
+
+	s.limitsMtx.Lock()
+	s.docs = docs
+	s.limits = limits
+	s.merged = map[string]mergedLimits{}
+	s.limitsMtx.Unlock()
+
+	s.loadSuccess.Set(1)
+	s.tenants.Set(float64(len(limits)))
+	return nil
+}
+
+// TenantLimits returns a TenantLimits applying the limits set via the API on top of the limits
+// returned by base for the same tenant, field by field: the limits not set via the API keep the
+// value returned by base, or the global limits if base has no limits for the tenant.
+func (s *Store) TenantLimits(base validation.TenantLimits) validation.TenantLimits {
+	return func(userID string) *validation.Limits {
+		var baseLimits *validation.Limits
+		if base != nil {
+			baseLimits = base(userID)
+		}
+		return s.tenantLimits(userID, baseLimits)
+	}
+}
+
+// AllTenantLimits returns an AllTenantLimits applying the limits set via the API on top of
+// the limits returned by base, like TenantLimits.
+func (s *Store) AllTenantLimits(base validation.AllTenantLimits) validation.AllTenantLimits {
+	return func() map[string]*validation.Limits {
+		out := map[string]*validation.Limits{}
+		if base != nil {
+			for userID, limits := range base() {
+				out[userID] = limits
+			}
+		}
+
+		s.limitsMtx.RLock()
+		userIDs := make([]string, 0, len(s.docs))
+		for userID := range s.docs {
+			userIDs = append(userIDs, userID)
+		}
+		s.limitsMtx.RUnlock()
+
+		for _, userID := range userIDs {
+			if limits := s.tenantLimits(userID, out[userID]); limits != nil {
+				out[userID] = limits
+			}
+		}
+		return out
+	}
+}
+
+func (s *Store) tenantLimits(userID string, base *validation.Limits) *validation.Limits {
+	s.limitsMtx.RLock()
+	doc, ok := s.docs[userID]
+	limits := s.limits[userID]
+	cached, cachedOK := s.merged[userID]
+	s.limitsMtx.RUnlock()
+
+	switch {
+	case !ok:
+		return base
+	case base == nil:
+		// The limits not set via the API default to the global limits.
+		return limits
+	case cachedOK && cached.base == base:
+		return cached.limits
+	}
+
+	merged, err := mergeLimits(base, []byte(doc))
+	if err != nil {
+		// Can't happen, since the limits have been already parsed on reload.
+		level.Warn(s.logger).Log("msg", "failed to apply the limits set via the API", "user", userID, "err", err)
+		return limits
+	}
+
+	// Cache the merged limits, unless the limits set via the API have changed in the meanwhile.
+	s.limitsMtx.Lock()
+	if current, ok := s.docs[userID]; ok && current == doc {
+		s.merged[userID] = mergedLimits{base: base, limits: merged}
+	}
+	s.limitsMtx.Unlock()
+
+	return merged
+}
+
+// ListTenants returns the tenants with limits in the KV store.
+func (s *Store) ListTenants(ctx context.Context) ([]string, error) {
+	stored, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, 0, len(stored.Tenants))
+	for userID := range stored.Tenants {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+	return userIDs, nil
+}
+
+// GetLimits returns the limits of the tenant set via the API, as stored in YAML format.
+func (s *Store) GetLimits(ctx context.Context, userID string) ([]byte, error) {
+	stored, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, ok := stored.Tenants[userID]
+	if !ok {
+		return nil, ErrLimitsNotFound
+	}
+	return []byte(doc), nil
+}
+
+func (s *Store) get(ctx context.Context) (*tenantsLimits, error) {
+	value, err := s.kv.Get(ctx, limitsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get the limits")
+	}
+	if value == nil {
+		return &tenantsLimits{}, nil
+	}
+	return value.(*tenantsLimits), nil
+}
+
+// SetLimits stores the limits of the tenant, in YAML format. The limits are applied
+// to the local store immediately, and to the other components once they're notified
+// by the KV store.
+func (s *Store) SetLimits(ctx context.Context, userID string, buf []byte) error {
+	if _, err := parseLimits(buf); err != nil {
+		return err
+	}
+
+	var updated *tenantsLimits
+	err := s.kv.CAS(ctx, limitsKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		updated = &tenantsLimits{Tenants: map[string]string{}}
+		if in != nil {
+			updated = in.(*tenantsLimits).clone()
+		}
+
+		updated.Tenants[userID] = string(buf)
+		return updated, true, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to store the limits of tenant %s", userID)
+	}
+
+	return s.reload(updated)
+}
+
+// DeleteLimits deletes the limits of the tenant. The limits are removed from the local
+// store immediately, and from the other components once they're notified by the KV store.
+func (s *Store) DeleteLimits(ctx context.Context, userID string) error {
+	var updated *tenantsLimits
+	err := s.kv.CAS(ctx, limitsKey, func(in interface{}) (out interface{}, retry bool, err error) {
+		if in == nil {
+			return nil, false, ErrLimitsNotFound
+		}
+		if _, ok := in.(*tenantsLimits).Tenants[userID]; !ok {
+			return nil, false, ErrLimitsNotFound
+		}
+
+		updated = in.(*tenantsLimits).clone()
+		delete(updated.Tenants, userID)
+		return updated, true, nil
+	})
+	if errors.Is(err, ErrLimitsNotFound) {
+		return ErrLimitsNotFound
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete the limits of tenant %s", userID)
+	}
+
+	return s.reload(updated)
+}
+
+// parseLimits parses the limits in YAML format, applying the global limits as
+// defaults. It's strict, so that typos in the limit names are rejected.
+func parseLimits(buf []byte) (*validation.Limits, error) {
+	limits := &validation.Limits{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.SetStrict(true)
+	if err := decoder.Decode(limits); err == io.EOF {
+		return nil, errEmptyLimits
+	} else if err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// plainLimits has the same fields of validation.Limits, but it's decoded without
+// applying the global limits as defaults.
+type plainLimits validation.Limits
+
+// mergeLimits parses the limits in YAML format, applying the base limits as defaults.
+func mergeLimits(base *validation.Limits, buf []byte) (*validation.Limits, error) {
+	// Deep copy the base limits through YAML, so that the decoding doesn't
+	// change the maps of the base limits.
+	data, err := yaml.Marshal((*plainLimits)(base))
+	if err != nil {
+		return nil, err
+	}
+
+	limits := &plainLimits{}
+	if err := yaml.UnmarshalStrict(data, limits); err != nil {
+		return nil, err
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(buf))
+	decoder.SetStrict(true)
+	if err := decoder.Decode(limits); err != nil {
+		return nil, err
+	}
+
+	return (*validation.Limits)(limits), nil
+}
+
+// validateTenantID returns an error if the tenant ID is invalid.
+func validateTenantID(userID string) error {
+	if userID == "" || userID == "." || userID == ".." {
+		return errors.Errorf("invalid tenant ID '%s'", userID)
+	}
+	return tenant.ValidTenantID(userID)
+}
@@ -0,0 +1,144 @@
+package limitsapi
+
+import (
+	"context"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestStore_WatchLimits(t *testing.T) {
+	defaults := setDefaultLimits(t)
+
+	kvClient := consul.NewInMemoryClient(GetCodec())
+	require.NoError(t, kvClient.Put(context.Background(), limitsKey, &tenantsLimits{Tenants: map[string]string{"user-1": "ingestion_rate: 100\n"}}))
+
+	s := newStore(kvClient, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), s))
+	defer services.StopAndAwaitTerminated(context.Background(), s) //nolint:errcheck
+
+	tenantLimits := s.TenantLimits(nil)
+
+	// The limits are loaded at startup, and the limits not set default to the global limits.
+	limits := tenantLimits("user-1")
+	require.NotNil(t, limits)
+	assert.Equal(t, float64(100), limits.IngestionRate)
+	assert.Equal(t, defaults.IngestionBurstSize, limits.IngestionBurstSize)
+	assert.Nil(t, tenantLimits("user-2"))
+
+	// The limits updated in the KV store by another component are applied.
+	other := newStore(kvClient, log.NewNopLogger(), nil)
+	require.NoError(t, other.SetLimits(context.Background(), "user-2", []byte("ingestion_rate: 200\n")))
+	require.NoError(t, other.DeleteLimits(context.Background(), "user-1"))
+	test.Poll(t, time.Second, true, func() interface{} {
+		return tenantLimits("user-1") == nil && tenantLimits("user-2") != nil
+	})
+	assert.Equal(t, float64(200), tenantLimits("user-2").IngestionRate)
+
+	// The last loaded limits are kept if the KV store contains invalid limits.
+	require.NoError(t, kvClient.Put(context.Background(), limitsKey, &tenantsLimits{Tenants: map[string]string{"user-3": "unknown_limit: 1\n"}}))
+	test.Poll(t, time.Second, float64(0), func() interface{} {
+		return testutil.ToFloat64(s.loadSuccess)
+	})
+	assert.Equal(t, float64(200), tenantLimits("user-2").IngestionRate)
+}
+
+func TestStore_SetAndDeleteLimits(t *testing.T) {
+	setDefaultLimits(t)
+
+	s := newStore(consul.NewInMemoryClient(GetCodec()), log.NewNopLogger(), nil)
+	tenantLimits := s.TenantLimits(nil)
+	ctx := context.Background()
+
+	// Invalid limits are not stored.
+	assert.Error(t, s.SetLimits(ctx, "user-1", []byte("unknown_limit: 1\n")))
+	assert.Equal(t, errEmptyLimits, s.SetLimits(ctx, "user-1", nil))
+
+	// The limits are applied to the local store immediately.
+	require.NoError(t, s.SetLimits(ctx, "user-1", []byte("max_global_series_per_user: 1000\n")))
+	assert.Equal(t, 1000, tenantLimits("user-1").MaxGlobalSeriesPerUser)
+
+	userIDs, err := s.ListTenants(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user-1"}, userIDs)
+
+	doc, err := s.GetLimits(ctx, "user-1")
+	require.NoError(t, err)
+	assert.Equal(t, "max_global_series_per_user: 1000\n", string(doc))
+
+	require.NoError(t, s.DeleteLimits(ctx, "user-1"))
+	assert.Nil(t, tenantLimits("user-1"))
+	assert.Equal(t, ErrLimitsNotFound, s.DeleteLimits(ctx, "user-1"))
+
+	_, err = s.GetLimits(ctx, "user-1")
+	assert.Equal(t, ErrLimitsNotFound, err)
+}
+
+func TestStore_ShouldMergeTheLimitsWithTheBaseLimits(t *testing.T) {
+	defaults := setDefaultLimits(t)
+
+	s := newStore(consul.NewInMemoryClient(GetCodec()), log.NewNopLogger(), nil)
+	ctx := context.Background()
+
+	base := defaults
+	base.IngestionRate = 10
+	base.MaxGlobalSeriesPerUser = 20
+	base.NotificationRateLimitPerIntegration = map[string]float64{"email": 1}
+
+	tenantLimits := s.TenantLimits(func(userID string) *validation.Limits {
+		if userID == "user-1" {
+			return &base
+		}
+		return nil
+	})
+	allTenantLimits := s.AllTenantLimits(func() map[string]*validation.Limits {
+		return map[string]*validation.Limits{"user-1": &base}
+	})
+
+	require.NoError(t, s.SetLimits(ctx, "user-1", []byte("ingestion_rate: 100\nalertmanager_notification_rate_limit_per_integration:\n  slack: 2\n")))
+	require.NoError(t, s.SetLimits(ctx, "user-2", []byte("ingestion_rate: 200\n")))
+
+	// The limits set via the API are applied on top of the base limits, field by field.
+	limits := tenantLimits("user-1")
+	assert.Equal(t, float64(100), limits.IngestionRate)
+	assert.Equal(t, 20, limits.MaxGlobalSeriesPerUser)
+	assert.Equal(t, map[string]float64{"email": 1, "slack": 2}, limits.NotificationRateLimitPerIntegration)
+	assert.Same(t, limits, tenantLimits("user-1"))
+
+	// The base limits are not modified.
+	assert.Equal(t, map[string]float64{"email": 1}, base.NotificationRateLimitPerIntegration)
+
+	// The tenants without base limits default to the global limits.
+	assert.Equal(t, float64(200), tenantLimits("user-2").IngestionRate)
+	assert.Equal(t, defaults.MaxGlobalSeriesPerUser, tenantLimits("user-2").MaxGlobalSeriesPerUser)
+
+	all := allTenantLimits()
+	require.Len(t, all, 2)
+	assert.Equal(t, float64(100), all["user-1"].IngestionRate)
+	assert.Equal(t, 20, all["user-1"].MaxGlobalSeriesPerUser)
+	assert.Equal(t, float64(200), all["user-2"].IngestionRate)
+
+	// The base limits are applied again once the limits set via the API are deleted.
+	require.NoError(t, s.DeleteLimits(ctx, "user-1"))
+	assert.Same(t, &base, tenantLimits("user-1"))
+}
+
+func setDefaultLimits(t *testing.T) validation.Limits {
+	defaults := validation.Limits{}
+	defaults.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+
+	validation.SetDefaultLimitsForYAMLUnmarshalling(defaults)
+	t.Cleanup(func() { validation.SetDefaultLimitsForYAMLUnmarshalling(validation.Limits{}) })
+	return defaults
+}
@@ -312,3 +312,42 @@ func ShardFromMatchers(matchers []*labels.Matcher) (shard *ShardAnnotation, idx
 	}
 	return nil, 0, nil
 }
+
+// ErrShardOnlySelector is returned when the shard label matcher is the only matcher of a selector.
+var ErrShardOnlySelector = errors.Errorf("the %s label matcher must be used along with at least another label matcher", ShardLabel)
+
+// RemoveShardFromMatchers returns the ShardAnnotation selected by the matchers (if any) and the
+// matchers without the shard one. A selector made of the shard matcher only is rejected.
+func RemoveShardFromMatchers(matchers []*labels.Matcher) (*ShardAnnotation, []*labels.Matcher, error) {
+	for i, matcher := range matchers {
+		if matcher.Name != ShardLabel {
+			continue
+		}
+
+		if matcher.Type != labels.MatchEqual {
+			return nil, nil, errors.Errorf("the %s label matcher only supports equality", ShardLabel)
+		}
+
+		shard, err := ParseShard(matcher.Value)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(matchers) == 1 {
+			return nil, nil, ErrShardOnlySelector
+		}
+
+		filtered := make([]*labels.Matcher, 0, len(matchers)-1)
+		filtered = append(filtered, matchers[:i]...)
+		filtered = append(filtered, matchers[i+1:]...)
+		return &shard, filtered, nil
+	}
+
+	return nil, matchers, nil
+}
+
+// Matches returns whether the series with the input labels belongs to the shard, based on
+// the series labels hash. Used by the stores which don't shard series in their index.
+func (shard ShardAnnotation) Matches(lset labels.Labels) bool {
+	return lset.Hash()%uint64(shard.Of) == uint64(shard.Shard)
+}
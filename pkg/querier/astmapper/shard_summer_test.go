@@ -267,3 +267,51 @@ func TestShardFromMatchers(t *testing.T) {
 	}
 
 }
+
+func TestRemoveShardFromMatchers(t *testing.T) {
+	nameMatcher := labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "foo")
+
+	var testExpr = []struct {
+		input    []*labels.Matcher
+		shard    *ShardAnnotation
+		matchers []*labels.Matcher
+		err      bool
+	}{
+		{
+			input:    []*labels.Matcher{nameMatcher, labels.MustNewMatcher(labels.MatchEqual, ShardLabel, "1_of_2")},
+			shard:    &ShardAnnotation{Shard: 1, Of: 2},
+			matchers: []*labels.Matcher{nameMatcher},
+		},
+		{
+			input:    []*labels.Matcher{nameMatcher},
+			shard:    nil,
+			matchers: []*labels.Matcher{nameMatcher},
+		},
+		{
+			// Shard-only selector.
+			input: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, ShardLabel, "1_of_2")},
+			err:   true,
+		},
+		{
+			input: []*labels.Matcher{nameMatcher, labels.MustNewMatcher(labels.MatchRegexp, ShardLabel, "1_of_2")},
+			err:   true,
+		},
+		{
+			input: []*labels.Matcher{nameMatcher, labels.MustNewMatcher(labels.MatchEqual, ShardLabel, "invalid-fmt")},
+			err:   true,
+		},
+	}
+
+	for i, c := range testExpr {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			shard, matchers, err := RemoveShardFromMatchers(c.input)
+			if c.err {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, c.shard, shard)
+				require.Equal(t, c.matchers, matchers)
+			}
+		})
+	}
+}
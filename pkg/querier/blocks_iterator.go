@@ -0,0 +1,50 @@
+package querier
+
+import (
+	"github.com/oklog/ulid"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+)
+
+// sliceBlocksIterator is a BlocksIterator over a slice of blocks, sorted by MaxTime descending.
+type sliceBlocksIterator struct {
+	blocks        bucketindex.Blocks
+	deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark
+	idx           int
+}
+
+func newSliceBlocksIterator(blocks bucketindex.Blocks, deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark) *sliceBlocksIterator {
+	return &sliceBlocksIterator{
+		blocks:        blocks,
+		deletionMarks: deletionMarks,
+		idx:           -1,
+	}
+}
+
+func (it *sliceBlocksIterator) Next() bool {
+	it.idx++
+	return it.idx < len(it.blocks)
+}
+
+func (it *sliceBlocksIterator) At() (*bucketindex.Block, *bucketindex.BlockDeletionMark) {
+	b := it.blocks[it.idx]
+	return b, it.deletionMarks[b.ID]
+}
+
+// readBlocksBatch reads up to batchSize blocks from the iterator, along with their
+// deletion marks. If batchSize is <= 0, all the remaining blocks are read.
+func readBlocksBatch(it BlocksIterator, batchSize int) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark) {
+	blocks := bucketindex.Blocks(nil)
+	deletionMarks := map[ulid.ULID]*bucketindex.BlockDeletionMark{}
+
+	for (batchSize <= 0 || len(blocks) < batchSize) && it.Next() {
+		b, m := it.At()
+
+		blocks = append(blocks, b)
+		if m != nil {
+			deletionMarks[b.ID] = m
+		}
+	}
+
+	return blocks, deletionMarks
+}
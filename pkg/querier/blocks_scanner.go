@@ -2,6 +2,7 @@ package querier
 
 import (
 	"context"
+	"os"
 	"path"
 	"path/filepath"
 	"sort"
@@ -19,6 +20,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/block/metadata"
 	"github.com/thanos-io/thanos/pkg/objstore"
+	"go.uber.org/atomic"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
@@ -40,12 +42,24 @@ type BlocksScannerConfig struct {
 	CacheDir                 string
 	ConsistencyDelay         time.Duration
 	IgnoreDeletionMarksDelay time.Duration
+	BlocksDiscoveryStrategy  string
+
+	// When lazy loading is enabled, the blocks of a tenant are discovered the first time
+	// the tenant is queried (instead of at startup) and then kept updated by the periodic
+	// scan, until the tenant is not queried for longer than the idle timeout.
+	LazyLoadingEnabled     bool
+	LazyLoadingIdleTimeout time.Duration
+
+	// If > 0, the result of each successful scan is persisted to a snapshot in the
+	// cache dir, which is loaded at startup if not older than the max staleness.
+	SnapshotMaxStaleness time.Duration
 }
 
 type BlocksScanner struct {
 	services.Service
 
 	cfg             BlocksScannerConfig
+	scanInterval    time.Duration
 	logger          log.Logger
 	bucketClient    objstore.Bucket
 	fetchersMetrics *storegateway.MetadataFetcherMetrics
@@ -62,8 +76,19 @@ type BlocksScanner struct {
 	userMetasLookup   map[string]map[ulid.ULID]*bucketindex.Block
 	userDeletionMarks map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark
 
-	scanDuration    prometheus.Histogram
-	scanLastSuccess prometheus.Gauge
+	// Keep track of the tenants lazy loaded (only used if lazy loading is enabled).
+	lazyUsersMx sync.Mutex
+	lazyUsers   map[string]*lazyUser
+
+	// Whether the initial state has been loaded from the snapshot.
+	loadedFromSnapshot bool
+
+	scanDuration         prometheus.Histogram
+	scanLastSuccess      prometheus.Gauge
+	bucketIndexFallbacks prometheus.Counter
+	lazyLoads            prometheus.Counter
+	lazyLoadFailures     prometheus.Counter
+	lazyEvictions        prometheus.Counter
 }
 
 func NewBlocksScanner(cfg BlocksScannerConfig, bucketClient objstore.Bucket, logger log.Logger, reg prometheus.Registerer) *BlocksScanner {
@@ -76,6 +101,7 @@ func NewBlocksScanner(cfg BlocksScannerConfig, bucketClient objstore.Bucket, log
 		userMetas:         make(map[string]bucketindex.Blocks),
 		userMetasLookup:   make(map[string]map[ulid.ULID]*bucketindex.Block),
 		userDeletionMarks: map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark{},
+		lazyUsers:         map[string]*lazyUser{},
 		fetchersMetrics:   storegateway.NewMetadataFetcherMetrics(),
 		scanDuration: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
 			Name:    "cortex_querier_blocks_scan_duration_seconds",
@@ -86,6 +112,22 @@ func NewBlocksScanner(cfg BlocksScannerConfig, bucketClient objstore.Bucket, log
 			Name: "cortex_querier_blocks_last_successful_scan_timestamp_seconds",
 			Help: "Unix timestamp of the last successful blocks scan.",
 		}),
+		bucketIndexFallbacks: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_blocks_scan_bucket_index_fallbacks_total",
+			Help: "Total number of times the blocks of a tenant have been discovered listing the bucket because the bucket index was missing or corrupted.",
+		}),
+		lazyLoads: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_blocks_scan_lazy_loads_total",
+			Help: "Total number of tenants whose blocks have been lazy loaded because queried for the first time.",
+		}),
+		lazyLoadFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_blocks_scan_lazy_load_failures_total",
+			Help: "Total number of tenants whose blocks have failed to be lazy loaded.",
+		}),
+		lazyEvictions: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_querier_blocks_scan_lazy_evictions_total",
+			Help: "Total number of lazy loaded tenants which have been evicted because idle.",
+		}),
 	}
 
 	if reg != nil {
@@ -94,60 +136,75 @@ func NewBlocksScanner(cfg BlocksScannerConfig, bucketClient objstore.Bucket, log
 
 	// Apply a jitter to the sync frequency in order to increase the probability
 	// of hitting the shared cache (if any).
-	scanInterval := util.DurationWithJitter(cfg.ScanInterval, 0.2)
-	d.Service = services.NewTimerService(scanInterval, d.starting, d.scan, nil)
+	d.scanInterval = util.DurationWithJitter(cfg.ScanInterval, 0.2)
+	d.Service = services.NewBasicService(d.starting, d.running, nil)
 
 	return d
 }
 
 // GetBlocks returns known blocks for userID containing samples within the range minT
 // and maxT (milliseconds, both included). Returned blocks are sorted by MaxTime descending.
-func (d *BlocksScanner) GetBlocks(_ context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+func (d *BlocksScanner) GetBlocks(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+	it, err := d.GetBlocksIter(ctx, userID, minT, maxT)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Deletion marks are returned only for matching blocks.
+	matchingMetas, matchingDeletionMarks := readBlocksBatch(it, 0)
+	return matchingMetas, matchingDeletionMarks, nil
+}
+
+// GetBlocksIter is like GetBlocks() but returns an iterator over the matching blocks,
+// which are found while iterating instead of being collected upfront.
+func (d *BlocksScanner) GetBlocksIter(ctx context.Context, userID string, minT, maxT int64) (BlocksIterator, error) {
 	// We need to ensure the initial full bucket scan succeeded.
 	if d.State() != services.Running {
-		return nil, nil, errBlocksScannerNotRunning
+		return nil, errBlocksScannerNotRunning
 	}
 	if maxT < minT {
-		return nil, nil, errInvalidBlocksRange
+		return nil, errInvalidBlocksRange
 	}
 
-	d.userMx.RLock()
-	defer d.userMx.RUnlock()
-
-	userMetas, ok := d.userMetas[userID]
-	if !ok {
-		return nil, nil, nil
+	if d.cfg.LazyLoadingEnabled {
+		if err := d.lazyLoadUser(ctx, userID); err != nil {
+			return nil, err
+		}
 	}
 
-	// Given we do expect the large majority of queries to have a time range close
-	// to "now", we're going to find matching blocks iterating the list in reverse order.
-	var matchingMetas bucketindex.Blocks
-	for i := len(userMetas) - 1; i >= 0; i-- {
-		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
-		if userMetas[i].MinTime <= maxT && minT < userMetas[i].MaxTime {
-			matchingMetas = append(matchingMetas, userMetas[i])
-		}
+	// The slice and map of a user are never modified once stored (they're replaced
+	// on update), so it's safe to iterate them without holding the lock.
+	d.userMx.RLock()
+	userMetas := d.userMetas[userID]
+	userDeletionMarks := d.userDeletionMarks[userID]
+	d.userMx.RUnlock()
+
+	return &blocksScannerIterator{
+		metas:         userMetas,
+		deletionMarks: userDeletionMarks,
+		minT:          minT,
+		maxT:          maxT,
+		idx:           len(userMetas),
+	}, nil
+}
 
-		// We can safely break the loop because metas are sorted by MaxTime.
-		if userMetas[i].MaxTime <= minT {
-			break
-		}
+func (d *BlocksScanner) starting(ctx context.Context) error {
+	// When lazy loading is enabled, tenants are discovered at query time.
+	if d.cfg.LazyLoadingEnabled {
+		return nil
 	}
 
-	// Filter deletion marks by matching blocks only.
-	matchingDeletionMarks := map[ulid.ULID]*bucketindex.BlockDeletionMark{}
-	if userDeletionMarks, ok := d.userDeletionMarks[userID]; ok {
-		for _, m := range matchingMetas {
-			if d := userDeletionMarks[m.ID]; d != nil {
-				matchingDeletionMarks[m.ID] = d
-			}
+	// If a recent snapshot is available, we load it and refresh it asynchronously,
+	// so that we don't have to wait for the initial scan to complete.
+	if d.cfg.SnapshotMaxStaleness > 0 {
+		if err := d.loadSnapshot(); err == nil {
+			d.loadedFromSnapshot = true
+			return nil
+		} else if !os.IsNotExist(errors.Cause(err)) {
+			level.Warn(d.logger).Log("msg", "unable to load the blocks scanner snapshot, running the initial blocks scan", "err", err)
 		}
 	}
 
-	return matchingMetas, matchingDeletionMarks, nil
-}
-
-func (d *BlocksScanner) starting(ctx context.Context) error {
 	// Before the service is in the running state it must have successfully
 	// complete the initial scan.
 	if err := d.scanBucket(ctx); err != nil {
@@ -158,7 +215,39 @@ func (d *BlocksScanner) starting(ctx context.Context) error {
 	return nil
 }
 
+func (d *BlocksScanner) running(ctx context.Context) error {
+	// The state loaded from the snapshot may be stale, so we refresh it right away.
+	if d.loadedFromSnapshot {
+		if err := d.scan(ctx); err != nil {
+			return err
+		}
+	}
+
+	t := time.NewTicker(d.scanInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := d.scan(ctx); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
 func (d *BlocksScanner) scan(ctx context.Context) error {
+	if d.cfg.LazyLoadingEnabled {
+		if err := d.scanLazyUsers(ctx); err != nil {
+			level.Error(d.logger).Log("msg", "failed to scan bucket storage to find blocks of lazy loaded tenants", "err", err)
+		}
+
+		// Never return error, otherwise the service terminates.
+		return nil
+	}
+
 	if err := d.scanBucket(ctx); err != nil {
 		level.Error(d.logger).Log("msg", "failed to scan bucket storage to find blocks", "err", err)
 	}
@@ -181,6 +270,111 @@ func (d *BlocksScanner) scanBucket(ctx context.Context) (returnErr error) {
 		return err
 	}
 
+	resMetas, resMetasLookup, resDeletionMarks, err := d.scanUsers(ctx, userIDs)
+
+	d.userMx.Lock()
+	if err == nil {
+		// Replace the map, so that we discard tenants fully deleted from storage.
+		d.userMetas = resMetas
+		d.userMetasLookup = resMetasLookup
+		d.userDeletionMarks = resDeletionMarks
+	} else {
+		// If an error occurred, we prefer to partially update the metas map instead of
+		// not updating it at all. At least we'll update blocks for the successful tenants.
+		d.mergeUserBlocks(resMetas, resMetasLookup, resDeletionMarks)
+	}
+	d.userMx.Unlock()
+
+	// Persist the snapshot only after a fully successful scan, so that we never
+	// load a partial state at startup.
+	if err == nil && d.cfg.SnapshotMaxStaleness > 0 {
+		if snapshotErr := writeBlocksScannerSnapshot(d.cfg.CacheDir, newBlocksScannerSnapshot(resMetas, resDeletionMarks)); snapshotErr != nil {
+			level.Warn(d.logger).Log("msg", "failed to write the blocks scanner snapshot", "err", snapshotErr)
+		}
+	}
+
+	return err
+}
+
+// loadSnapshot loads the blocks of all tenants from the snapshot, if not stale.
+func (d *BlocksScanner) loadSnapshot() error {
+	snapshot, err := readBlocksScannerSnapshot(d.cfg.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	if age := time.Since(snapshot.GetCreatedAt()); age > d.cfg.SnapshotMaxStaleness {
+		return errors.Errorf("the snapshot is stale (age: %s, max staleness: %s)", age.String(), d.cfg.SnapshotMaxStaleness.String())
+	}
+
+	userMetas := make(map[string]bucketindex.Blocks, len(snapshot.Users))
+	userMetasLookup := make(map[string]map[ulid.ULID]*bucketindex.Block, len(snapshot.Users))
+	userDeletionMarks := make(map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark, len(snapshot.Users))
+
+	for userID, idx := range snapshot.Users {
+		metas := bucketindex.Blocks(idx.Blocks)
+		sortBlockMetasByMaxTime(metas)
+
+		lookup := make(map[ulid.ULID]*bucketindex.Block, len(metas))
+		for _, m := range metas {
+			lookup[m.ID] = m
+		}
+
+		marks := make(map[ulid.ULID]*bucketindex.BlockDeletionMark, len(idx.BlockDeletionMarks))
+		for _, m := range idx.BlockDeletionMarks {
+			marks[m.ID] = m
+		}
+
+		userMetas[userID] = metas
+		userMetasLookup[userID] = lookup
+		userDeletionMarks[userID] = marks
+	}
+
+	d.userMx.Lock()
+	d.userMetas = userMetas
+	d.userMetasLookup = userMetasLookup
+	d.userDeletionMarks = userDeletionMarks
+	d.userMx.Unlock()
+
+	level.Info(d.logger).Log("msg", "loaded blocks from the blocks scanner snapshot", "tenants", len(userMetas), "created_at", snapshot.GetCreatedAt().String())
+	return nil
+}
+
+// scanLazyUsers evicts the lazy loaded tenants which have been idle for longer than
+// the configured timeout, and then updates the blocks of the remaining ones.
+func (d *BlocksScanner) scanLazyUsers(ctx context.Context) (returnErr error) {
+	defer func(start time.Time) {
+		d.scanDuration.Observe(time.Since(start).Seconds())
+		if returnErr == nil {
+			d.scanLastSuccess.SetToCurrentTime()
+		}
+	}(time.Now())
+
+	d.evictIdleLazyUsers()
+
+	var userIDs []string
+
+	d.lazyUsersMx.Lock()
+	for userID, u := range d.lazyUsers {
+		if u.loaded.Load() {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	d.lazyUsersMx.Unlock()
+
+	resMetas, resMetasLookup, resDeletionMarks, err := d.scanUsers(ctx, userIDs)
+
+	// Tenants may be concurrently lazy loaded, so we always merge the results.
+	d.userMx.Lock()
+	d.mergeUserBlocks(resMetas, resMetasLookup, resDeletionMarks)
+	d.userMx.Unlock()
+
+	return err
+}
+
+// scanUsers discovers the blocks of the input users. The returned maps contain only
+// the users whose blocks have been successfully discovered.
+func (d *BlocksScanner) scanUsers(ctx context.Context, userIDs []string) (map[string]bucketindex.Blocks, map[string]map[ulid.ULID]*bucketindex.Block, map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
 	jobsChan := make(chan string)
 	resMx := sync.Mutex{}
 	resMetas := map[string]bucketindex.Blocks{}
@@ -238,30 +432,106 @@ pushJobsLoop:
 	close(jobsChan)
 	wg.Wait()
 
+	return resMetas, resMetasLookup, resDeletionMarks, resErrs.Err()
+}
+
+// mergeUserBlocks updates the blocks of the input users, keeping the other users
+// untouched. This function must be called while holding the userMx lock.
+func (d *BlocksScanner) mergeUserBlocks(metas map[string]bucketindex.Blocks, metasLookup map[string]map[ulid.ULID]*bucketindex.Block, deletionMarks map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark) {
+	for userID, userMetas := range metas {
+		d.userMetas[userID] = userMetas
+	}
+
+	for userID, userMetasLookup := range metasLookup {
+		d.userMetasLookup[userID] = userMetasLookup
+	}
+
+	for userID, userDeletionMarks := range deletionMarks {
+		d.userDeletionMarks[userID] = userDeletionMarks
+	}
+}
+
+// lazyLoadUser discovers the blocks of the input user, if not already loaded,
+// and keeps track of when the user has been queried the last time.
+func (d *BlocksScanner) lazyLoadUser(ctx context.Context, userID string) error {
+	d.lazyUsersMx.Lock()
+	u, ok := d.lazyUsers[userID]
+	if !ok {
+		u = &lazyUser{}
+		d.lazyUsers[userID] = u
+	}
+	u.lastQueriedAt.Store(time.Now().Unix())
+	d.lazyUsersMx.Unlock()
+
+	// Concurrent queries for the same user wait until the first one has
+	// completed to load the user blocks.
+	u.loadMx.Lock()
+	defer u.loadMx.Unlock()
+
+	if u.loaded.Load() {
+		return nil
+	}
+
+	d.lazyLoads.Inc()
+
+	metas, deletionMarks, err := d.scanUserBlocks(ctx, userID)
+	if err != nil {
+		d.lazyLoadFailures.Inc()
+		return err
+	}
+
+	lookup := map[ulid.ULID]*bucketindex.Block{}
+	for _, m := range metas {
+		lookup[m.ID] = m
+	}
+
 	d.userMx.Lock()
-	if len(resErrs) == 0 {
-		// Replace the map, so that we discard tenants fully deleted from storage.
-		d.userMetas = resMetas
-		d.userMetasLookup = resMetasLookup
-		d.userDeletionMarks = resDeletionMarks
-	} else {
-		// If an error occurred, we prefer to partially update the metas map instead of
-		// not updating it at all. At least we'll update blocks for the successful tenants.
-		for userID, metas := range resMetas {
-			d.userMetas[userID] = metas
-		}
+	d.userMetas[userID] = metas
+	d.userMetasLookup[userID] = lookup
+	d.userDeletionMarks[userID] = deletionMarks
+	d.userMx.Unlock()
+
+	u.loaded.Store(true)
+	return nil
+}
 
-		for userID, metas := range resMetasLookup {
-			d.userMetasLookup[userID] = metas
+// evictIdleLazyUsers removes all the data about the lazy loaded users which
+// have not been queried for longer than the configured idle timeout.
+func (d *BlocksScanner) evictIdleLazyUsers() {
+	if d.cfg.LazyLoadingIdleTimeout <= 0 {
+		return
+	}
+
+	threshold := time.Now().Add(-d.cfg.LazyLoadingIdleTimeout).Unix()
+
+	// The lock is held while evicting users, so that a concurrent query can't
+	// find a user in the process of being evicted.
+	d.lazyUsersMx.Lock()
+	defer d.lazyUsersMx.Unlock()
+
+	for userID, u := range d.lazyUsers {
+		if u.lastQueriedAt.Load() >= threshold {
+			continue
 		}
 
-		for userID, deletionMarks := range resDeletionMarks {
-			d.userDeletionMarks[userID] = deletionMarks
+		delete(d.lazyUsers, userID)
+
+		d.userMx.Lock()
+		delete(d.userMetas, userID)
+		delete(d.userMetasLookup, userID)
+		delete(d.userDeletionMarks, userID)
+		d.userMx.Unlock()
+
+		d.fetchersMx.Lock()
+		if _, ok := d.fetchers[userID]; ok {
+			delete(d.fetchers, userID)
+			d.fetchersMetrics.RemoveUserRegistry(userID)
 		}
-	}
-	d.userMx.Unlock()
+		d.fetchersMx.Unlock()
 
-	return resErrs.Err()
+		d.lazyEvictions.Inc()
+		level.Debug(d.logger).Log("msg", "evicted idle lazy loaded tenant", "user", userID)
+	}
 }
 
 // scanUserBlocksWithRetries runs scanUserBlocks() retrying multiple times
@@ -286,6 +556,58 @@ func (d *BlocksScanner) scanUserBlocksWithRetries(ctx context.Context, userID st
 }
 
 func (d *BlocksScanner) scanUserBlocks(ctx context.Context, userID string) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+	if d.cfg.BlocksDiscoveryStrategy == cortex_tsdb.BlocksDiscoveryBucketIndex {
+		metas, deletionMarks, err := d.readUserBucketIndex(ctx, userID)
+		if err == nil {
+			return metas, deletionMarks, nil
+		}
+
+		// Fallback to list the bucket only if the index is unusable. Any other error
+		// (eg. object storage failure) is returned as is, because the listing would fail too.
+		if !errors.Is(err, bucketindex.ErrIndexNotFound) && !errors.Is(err, bucketindex.ErrIndexCorrupted) {
+			return nil, nil, errors.Wrapf(err, "read bucket index for user %s", userID)
+		}
+
+		level.Debug(d.logger).Log("msg", "unable to use the bucket index, falling back to scan the bucket", "user", userID, "err", err)
+		d.bucketIndexFallbacks.Inc()
+	}
+
+	return d.listUserBlocks(ctx, userID)
+}
+
+// readUserBucketIndex discovers the user blocks and deletion marks reading the bucket index.
+func (d *BlocksScanner) readUserBucketIndex(ctx context.Context, userID string) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+	idx, err := bucketindex.ReadIndex(ctx, d.bucketClient, userID, d.logger)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	marks := make(map[ulid.ULID]*bucketindex.BlockDeletionMark, len(idx.BlockDeletionMarks))
+	for _, m := range idx.BlockDeletionMarks {
+		marks[m.ID] = m
+	}
+
+	// Filter out blocks marked for deletion since longer than the ignore delay,
+	// consistently with the filter applied when listing the bucket.
+	threshold := time.Now().Add(-d.cfg.IgnoreDeletionMarksDelay).Unix()
+
+	res := make(bucketindex.Blocks, 0, len(idx.Blocks))
+	for _, b := range idx.Blocks {
+		if m := marks[b.ID]; m != nil && m.DeletionTime < threshold {
+			continue
+		}
+
+		res = append(res, b)
+	}
+
+	// The blocks scanner expects all blocks to be sorted by max time.
+	sortBlockMetasByMaxTime(res)
+
+	return res, marks, nil
+}
+
+// listUserBlocks discovers the user blocks and deletion marks listing the bucket.
+func (d *BlocksScanner) listUserBlocks(ctx context.Context, userID string) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
 	fetcher, userBucket, deletionMarkFilter, err := d.getOrCreateMetaFetcher(userID)
 	if err != nil {
 		return nil, nil, errors.Wrapf(err, "create meta fetcher for user %s", userID)
@@ -422,6 +744,53 @@ func logPartialBlocks(userID string, partials map[ulid.ULID]error, logger log.Lo
 	level.Warn(logger).Log("msg", "found partial blocks", "user", userID, "blocks", strings.Join(ids, ","), "err", strings.Join(errs, ","))
 }
 
+// blocksScannerIterator iterates over the blocks of a user sorted by MaxTime ascending,
+// returning the blocks within the time range sorted by MaxTime descending.
+type blocksScannerIterator struct {
+	metas         bucketindex.Blocks
+	deletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark
+	minT, maxT    int64
+
+	// Index of the current block.
+	idx int
+}
+
+func (it *blocksScannerIterator) Next() bool {
+	// Given we do expect the large majority of queries to have a time range close
+	// to "now", we're going to find matching blocks iterating the list in reverse order.
+	for it.idx--; it.idx >= 0; it.idx-- {
+		m := it.metas[it.idx]
+
+		// We can safely stop because metas are sorted by MaxTime.
+		if m.MaxTime <= it.minT {
+			break
+		}
+
+		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+		if m.MinTime <= it.maxT && it.minT < m.MaxTime {
+			return true
+		}
+	}
+
+	// Ensure any next call returns false too.
+	it.idx = 0
+	return false
+}
+
+func (it *blocksScannerIterator) At() (*bucketindex.Block, *bucketindex.BlockDeletionMark) {
+	m := it.metas[it.idx]
+	return m, it.deletionMarks[m.ID]
+}
+
+type lazyUser struct {
+	// Unix timestamp (seconds) of when the user has been queried the last time.
+	lastQueriedAt atomic.Int64
+
+	// The loaded flag is set while holding loadMx, but can be read without it.
+	loadMx sync.Mutex
+	loaded atomic.Bool
+}
+
 type userFetcher struct {
 	metadataFetcher    block.MetadataFetcher
 	deletionMarkFilter *block.IgnoreDeletionMarkFilter
@@ -0,0 +1,103 @@
+package querier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
+)
+
+const (
+	// blocksScannerSnapshotFilename is the name of the snapshot file, stored in the scanner cache dir.
+	blocksScannerSnapshotFilename = "blocks-scanner-snapshot.json"
+
+	blocksScannerSnapshotVersion1 = 1
+)
+
+// blocksScannerSnapshot holds the blocks and deletion marks of all tenants
+// found by the last successful scan.
+type blocksScannerSnapshot struct {
+	// Version of the snapshot format.
+	Version int `json:"version"`
+
+	// CreatedAt is a unix timestamp (seconds precision) of when the snapshot has been created.
+	CreatedAt int64 `json:"created_at"`
+
+	// Per-tenant blocks and deletion marks. The index format is reused for simplicity.
+	Users map[string]*bucketindex.Index `json:"users"`
+}
+
+func (s *blocksScannerSnapshot) GetCreatedAt() time.Time {
+	return time.Unix(s.CreatedAt, 0)
+}
+
+func newBlocksScannerSnapshot(userMetas map[string]bucketindex.Blocks, userDeletionMarks map[string]map[ulid.ULID]*bucketindex.BlockDeletionMark) *blocksScannerSnapshot {
+	now := time.Now().Unix()
+	snapshot := &blocksScannerSnapshot{
+		Version:   blocksScannerSnapshotVersion1,
+		CreatedAt: now,
+		Users:     make(map[string]*bucketindex.Index, len(userMetas)),
+	}
+
+	for userID, metas := range userMetas {
+		idx := &bucketindex.Index{
+			Version:   bucketindex.IndexVersion1,
+			Blocks:    metas,
+			UpdatedAt: now,
+		}
+
+		for _, m := range userDeletionMarks[userID] {
+			idx.BlockDeletionMarks = append(idx.BlockDeletionMarks, m)
+		}
+
+		snapshot.Users[userID] = idx
+	}
+
+	return snapshot
+}
+
+// writeBlocksScannerSnapshot atomically writes the snapshot to the given directory.
+func writeBlocksScannerSnapshot(dir string, snapshot *blocksScannerSnapshot) error {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return errors.Wrap(err, "marshal blocks scanner snapshot")
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return errors.Wrap(err, "create blocks scanner snapshot directory")
+	}
+
+	// Write to a temporary file first and then rename it, so that a partially
+	// written snapshot is never read back.
+	snapshotPath := filepath.Join(dir, blocksScannerSnapshotFilename)
+	if err := ioutil.WriteFile(snapshotPath+".tmp", content, 0644); err != nil {
+		return errors.Wrap(err, "write blocks scanner snapshot")
+	}
+
+	return errors.Wrap(os.Rename(snapshotPath+".tmp", snapshotPath), "rename blocks scanner snapshot")
+}
+
+// readBlocksScannerSnapshot reads the snapshot from the given directory.
+func readBlocksScannerSnapshot(dir string) (*blocksScannerSnapshot, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, blocksScannerSnapshotFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &blocksScannerSnapshot{}
+	if err := json.Unmarshal(content, snapshot); err != nil {
+		return nil, errors.Wrap(err, "unmarshal blocks scanner snapshot")
+	}
+
+	if snapshot.Version != blocksScannerSnapshotVersion1 {
+		return nil, errors.Errorf("unsupported blocks scanner snapshot version %d", snapshot.Version)
+	}
+
+	return snapshot, nil
+}
@@ -402,6 +402,188 @@ func TestBlocksScanner_PeriodicScanFindsUserWhichWasPreviouslyDeleted(t *testing
 	assert.Empty(t, deletionMarks)
 }
 
+func TestBlocksScanner_BucketIndexDiscoveryStrategy(t *testing.T) {
+	ctx := context.Background()
+	cfg := prepareBlocksScannerConfig()
+	cfg.BlocksDiscoveryStrategy = cortex_tsdb.BlocksDiscoveryBucketIndex
+	s, bucket, _, reg, cleanup := prepareBlocksScanner(t, cfg)
+	defer cleanup()
+
+	// The blocks of user-1 are only tracked in the bucket index, so the scanner
+	// can find them only if it reads the index instead of listing the bucket.
+	user1Block1 := &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 10, MaxTime: 20}
+	user1Block2 := &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 20, MaxTime: 30}
+	user1Block3 := &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 30, MaxTime: 40}
+	user1Mark2 := &bucketindex.BlockDeletionMark{ID: user1Block2.ID, DeletionTime: time.Now().Add(-time.Minute).Unix()}
+	user1Mark3 := &bucketindex.BlockDeletionMark{ID: user1Block3.ID, DeletionTime: time.Now().Add(-2 * cfg.IgnoreDeletionMarksDelay).Unix()}
+
+	require.NoError(t, bucketindex.WriteIndex(ctx, bucket, "user-1", nil, &bucketindex.Index{
+		Version:            bucketindex.IndexVersion1,
+		Blocks:             bucketindex.Blocks{user1Block1, user1Block2, user1Block3},
+		BlockDeletionMarks: []*bucketindex.BlockDeletionMark{user1Mark2, user1Mark3},
+		UpdatedAt:          time.Now().Unix(),
+	}))
+
+	// The user-2 has no bucket index, so the scanner should fallback to list the bucket.
+	user2Block1 := mockStorageBlock(t, bucket, "user-2", 10, 20)
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+
+	// The block marked for deletion since longer than the ignore delay should be filtered out.
+	blocks, deletionMarks, err := s.GetBlocks(ctx, "user-1", 0, 40)
+	require.NoError(t, err)
+	assert.Equal(t, bucketindex.Blocks{user1Block2, user1Block1}, blocks)
+	assert.Equal(t, map[ulid.ULID]*bucketindex.BlockDeletionMark{
+		user1Block2.ID: user1Mark2,
+	}, deletionMarks)
+
+	blocks, deletionMarks, err = s.GetBlocks(ctx, "user-2", 0, 30)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(blocks))
+	assert.Equal(t, user2Block1.ULID, blocks[0].ID)
+	assert.Empty(t, deletionMarks)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_querier_blocks_scan_bucket_index_fallbacks_total Total number of times the blocks of a tenant have been discovered listing the bucket because the bucket index was missing or corrupted.
+		# TYPE cortex_querier_blocks_scan_bucket_index_fallbacks_total counter
+		cortex_querier_blocks_scan_bucket_index_fallbacks_total 1
+	`), "cortex_querier_blocks_scan_bucket_index_fallbacks_total"))
+}
+
+func TestBlocksScanner_LazyLoading(t *testing.T) {
+	ctx := context.Background()
+	cfg := prepareBlocksScannerConfig()
+	cfg.LazyLoadingEnabled = true
+	cfg.LazyLoadingIdleTimeout = time.Hour
+	s, bucket, _, reg, cleanup := prepareBlocksScanner(t, cfg)
+	defer cleanup()
+
+	user1Block1 := mockStorageBlock(t, bucket, "user-1", 10, 20)
+	user2Block1 := mockStorageBlock(t, bucket, "user-2", 10, 20)
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+
+	// No tenant should have been loaded at startup.
+	s.userMx.RLock()
+	assert.Empty(t, s.userMetas)
+	s.userMx.RUnlock()
+
+	// The first query for a tenant should lazy load it, while the next ones should not.
+	for i := 0; i < 2; i++ {
+		blocks, _, err := s.GetBlocks(ctx, "user-1", 0, 30)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(blocks))
+		assert.Equal(t, user1Block1.ULID, blocks[0].ID)
+	}
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(s.lazyLoads))
+
+	// The periodic scan should only update the lazy loaded tenants.
+	user1Block2 := mockStorageBlock(t, bucket, "user-1", 20, 30)
+	require.NoError(t, s.scan(ctx))
+
+	s.userMx.RLock()
+	assert.Len(t, s.userMetas, 1)
+	s.userMx.RUnlock()
+
+	blocks, _, err := s.GetBlocks(ctx, "user-1", 0, 30)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(blocks))
+	assert.Equal(t, user1Block2.ULID, blocks[0].ID)
+	assert.Equal(t, user1Block1.ULID, blocks[1].ID)
+
+	blocks, _, err = s.GetBlocks(ctx, "user-2", 0, 30)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(blocks))
+	assert.Equal(t, user2Block1.ULID, blocks[0].ID)
+
+	// Simulate user-1 has not been queried since longer than the idle timeout.
+	s.lazyUsersMx.Lock()
+	s.lazyUsers["user-1"].lastQueriedAt.Store(time.Now().Add(-2 * cfg.LazyLoadingIdleTimeout).Unix())
+	s.lazyUsersMx.Unlock()
+
+	require.NoError(t, s.scan(ctx))
+
+	s.userMx.RLock()
+	assert.Len(t, s.userMetas, 1)
+	assert.Contains(t, s.userMetas, "user-2")
+	s.userMx.RUnlock()
+
+	// Querying the evicted tenant should lazy load it again.
+	blocks, _, err = s.GetBlocks(ctx, "user-1", 0, 30)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(blocks))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_querier_blocks_scan_lazy_loads_total Total number of tenants whose blocks have been lazy loaded because queried for the first time.
+		# TYPE cortex_querier_blocks_scan_lazy_loads_total counter
+		cortex_querier_blocks_scan_lazy_loads_total 3
+
+		# HELP cortex_querier_blocks_scan_lazy_load_failures_total Total number of tenants whose blocks have failed to be lazy loaded.
+		# TYPE cortex_querier_blocks_scan_lazy_load_failures_total counter
+		cortex_querier_blocks_scan_lazy_load_failures_total 0
+
+		# HELP cortex_querier_blocks_scan_lazy_evictions_total Total number of lazy loaded tenants which have been evicted because idle.
+		# TYPE cortex_querier_blocks_scan_lazy_evictions_total counter
+		cortex_querier_blocks_scan_lazy_evictions_total 1
+	`),
+		"cortex_querier_blocks_scan_lazy_loads_total",
+		"cortex_querier_blocks_scan_lazy_load_failures_total",
+		"cortex_querier_blocks_scan_lazy_evictions_total",
+	))
+}
+
+func TestBlocksScanner_Snapshot(t *testing.T) {
+	ctx := context.Background()
+	cfg := prepareBlocksScannerConfig()
+	cfg.SnapshotMaxStaleness = time.Hour
+	s, bkt, _, _, cleanup := prepareBlocksScanner(t, cfg)
+	defer cleanup()
+
+	block1 := mockStorageBlock(t, bkt, "user-1", 10, 20)
+	block2 := mockStorageBlock(t, bkt, "user-1", 20, 30)
+	mark2 := bucketindex.BlockDeletionMarkFromThanosMarker(mockStorageDeletionMark(t, bkt, "user-1", block2))
+
+	// The initial scan should write the snapshot.
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+	require.NoError(t, services.StopAndAwaitTerminated(ctx, s))
+	require.FileExists(t, path.Join(s.cfg.CacheDir, blocksScannerSnapshotFilename))
+
+	t.Run("should load a non stale snapshot even if the storage is unavailable", func(t *testing.T) {
+		failingBucket := &bucket.ClientMock{}
+		failingBucket.MockIter("", nil, errors.New("mocked error"))
+
+		snapshotCfg := cfg
+		snapshotCfg.CacheDir = s.cfg.CacheDir
+		restarted := NewBlocksScanner(snapshotCfg, failingBucket, log.NewNopLogger(), nil)
+		require.NoError(t, services.StartAndAwaitRunning(ctx, restarted))
+		defer services.StopAndAwaitTerminated(ctx, restarted) //nolint:errcheck
+
+		blocks, deletionMarks, err := restarted.GetBlocks(ctx, "user-1", 0, 30)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(blocks))
+		assert.Equal(t, block2.ULID, blocks[0].ID)
+		assert.Equal(t, block1.ULID, blocks[1].ID)
+		assert.Equal(t, map[ulid.ULID]*bucketindex.BlockDeletionMark{block2.ULID: mark2}, deletionMarks)
+	})
+
+	t.Run("should run the initial scan if the snapshot is stale", func(t *testing.T) {
+		snapshot, err := readBlocksScannerSnapshot(s.cfg.CacheDir)
+		require.NoError(t, err)
+		snapshot.CreatedAt = time.Now().Add(-2 * cfg.SnapshotMaxStaleness).Unix()
+		require.NoError(t, writeBlocksScannerSnapshot(s.cfg.CacheDir, snapshot))
+
+		failingBucket := &bucket.ClientMock{}
+		failingBucket.MockIter("", nil, errors.New("mocked error"))
+
+		snapshotCfg := cfg
+		snapshotCfg.CacheDir = s.cfg.CacheDir
+		restarted := NewBlocksScanner(snapshotCfg, failingBucket, log.NewNopLogger(), nil)
+		require.NoError(t, restarted.StartAsync(ctx))
+		require.Error(t, restarted.AwaitRunning(ctx))
+	})
+}
+
 func TestBlocksScanner_GetBlocks(t *testing.T) {
 	ctx := context.Background()
 	s, bucket, _, _, cleanup := prepareBlocksScanner(t, prepareBlocksScannerConfig())
@@ -487,6 +669,20 @@ func TestBlocksScanner_GetBlocks(t *testing.T) {
 			for i, expectedBlock := range testData.expectedMetas {
 				assert.Equal(t, expectedBlock.ULID, metas[i].ID)
 			}
+
+			// The iterator should return the same blocks, in the same order.
+			it, err := s.GetBlocksIter(ctx, "user-1", testData.minT, testData.maxT)
+			require.NoError(t, err)
+
+			for _, expectedBlock := range testData.expectedMetas {
+				require.True(t, it.Next())
+				actualBlock, actualMark := it.At()
+				assert.Equal(t, expectedBlock.ULID, actualBlock.ID)
+				assert.Equal(t, testData.expectedMarks[expectedBlock.ULID], actualMark)
+			}
+
+			assert.False(t, it.Next())
+			assert.False(t, it.Next())
 		})
 	}
 }
@@ -21,6 +21,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/extprom"
 	"github.com/thanos-io/thanos/pkg/store/hintspb"
+	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/thanos-io/thanos/pkg/strutil"
 	"go.uber.org/atomic"
@@ -28,6 +29,7 @@ import (
 	grpc_metadata "google.golang.org/grpc/metadata"
 
 	"github.com/cortexproject/cortex/pkg/querier/series"
+	"github.com/cortexproject/cortex/pkg/querier/stats"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
@@ -37,20 +39,29 @@ import (
 	"github.com/cortexproject/cortex/pkg/storegateway/storegatewaypb"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 )
 
 const (
-	// The maximum number of times we attempt fetching missing blocks from different
-	// store-gateways. If no more store-gateways are left (ie. due to lower replication
-	// factor) than we'll end the retries earlier.
-	maxFetchSeriesAttempts = 3
+	// Reasons used as label values of the metric tracking the re-fetched blocks.
+	refetchReasonMissing = "missing"
+	refetchReasonFailed  = "failed"
+
+	// The max number of batches of blocks queried concurrently by a single query.
+	maxConcurrentBlocksBatches = 4
 )
 
 var (
 	errNoStoreGatewayAddress  = errors.New("no store-gateway address configured")
 	errMaxChunksPerQueryLimit = "the query hit the max number of chunks limit while fetching chunks for %s (limit: %d)"
+
+	// The backoff applied before retrying to fetch the blocks whose store-gateways failed.
+	storeGatewayFailureBackoffConfig = util.BackoffConfig{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
 )
 
 // BlocksStoreSet is the interface used to get the clients to query series on a set of blocks.
@@ -70,6 +81,20 @@ type BlocksFinder interface {
 	// GetBlocks returns known blocks for userID containing samples within the range minT
 	// and maxT (milliseconds, both included). Returned blocks are sorted by MaxTime descending.
 	GetBlocks(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error)
+
+	// GetBlocksIter is like GetBlocks() but returns an iterator over the matching blocks,
+	// allowing the caller to query the blocks in batches.
+	GetBlocksIter(ctx context.Context, userID string, minT, maxT int64) (BlocksIterator, error)
+}
+
+// BlocksIterator iterates over a set of blocks, sorted by MaxTime descending.
+type BlocksIterator interface {
+	// Next advances the iterator to the next block. Returns false if there are no more blocks.
+	Next() bool
+
+	// At returns the current block and its deletion mark, or nil if the block
+	// has not been marked for deletion.
+	At() (*bucketindex.Block, *bucketindex.BlockDeletionMark)
 }
 
 // BlocksStoreClient is the interface that should be implemented by any client used
@@ -86,11 +111,16 @@ type BlocksStoreClient interface {
 type BlocksStoreLimits interface {
 	MaxChunksPerQuery(userID string) int
 	StoreGatewayTenantShardSize(userID string) int
+
+	// QueryStoreAfter returns the time after which the tenant's queries are sent
+	// to the storage, or 0 to use the querier config.
+	QueryStoreAfter(userID string) time.Duration
 }
 
 type blocksStoreQueryableMetrics struct {
-	storesHit prometheus.Histogram
-	refetches prometheus.Histogram
+	storesHit       prometheus.Histogram
+	refetches       prometheus.Histogram
+	refetchedBlocks *prometheus.CounterVec
 }
 
 func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQueryableMetrics {
@@ -107,6 +137,11 @@ func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQuery
 			Help:      "Number of re-fetches attempted while querying store-gateway instances due to missing blocks.",
 			Buckets:   []float64{0, 1, 2},
 		}),
+		refetchedBlocks: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Namespace: "cortex",
+			Name:      "querier_storegateway_refetched_blocks_total",
+			Help:      "Total number of blocks re-fetched from a different store-gateway instance, because missing from the store-gateway response or because the store-gateway failed.",
+		}, []string{"reason"}),
 	}
 }
 
@@ -115,20 +150,22 @@ func newBlocksStoreQueryableMetrics(reg prometheus.Registerer) *blocksStoreQuery
 type BlocksStoreQueryable struct {
 	services.Service
 
-	stores          BlocksStoreSet
-	finder          BlocksFinder
-	consistency     *BlocksConsistencyChecker
-	logger          log.Logger
-	queryStoreAfter time.Duration
-	metrics         *blocksStoreQueryableMetrics
-	limits          BlocksStoreLimits
+	stores           BlocksStoreSet
+	finder           BlocksFinder
+	consistency      *BlocksConsistencyChecker
+	logger           log.Logger
+	queryStoreAfter  time.Duration
+	blocksBatchSize  int
+	maxFetchAttempts int
+	metrics          *blocksStoreQueryableMetrics
+	limits           BlocksStoreLimits
 
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
 }
 
-func NewBlocksStoreQueryable(stores BlocksStoreSet, finder BlocksFinder, consistency *BlocksConsistencyChecker, limits BlocksStoreLimits, queryStoreAfter time.Duration, logger log.Logger, reg prometheus.Registerer) (*BlocksStoreQueryable, error) {
+func NewBlocksStoreQueryable(stores BlocksStoreSet, finder BlocksFinder, consistency *BlocksConsistencyChecker, limits BlocksStoreLimits, queryStoreAfter time.Duration, blocksBatchSize, maxFetchAttempts int, logger log.Logger, reg prometheus.Registerer) (*BlocksStoreQueryable, error) {
 	manager, err := services.NewManager(stores, finder)
 	if err != nil {
 		return nil, errors.Wrap(err, "register blocks storage queryable subservices")
@@ -139,6 +176,8 @@ func NewBlocksStoreQueryable(stores BlocksStoreSet, finder BlocksFinder, consist
 		finder:             finder,
 		consistency:        consistency,
 		queryStoreAfter:    queryStoreAfter,
+		blocksBatchSize:    blocksBatchSize,
+		maxFetchAttempts:   maxFetchAttempts,
 		logger:             logger,
 		subservices:        manager,
 		subservicesWatcher: services.NewFailureWatcher(),
@@ -160,7 +199,7 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 	}
 
 	// Blocks scanner doesn't use chunks, but we pass config for consistency.
-	cachingBucket, err := cortex_tsdb.CreateCachingBucket(storageCfg.BucketStore.ChunksCache, storageCfg.BucketStore.MetadataCache, bucketClient, logger, extprom.WrapRegistererWith(prometheus.Labels{"component": "querier"}, reg))
+	cachingBucket, err := cortex_tsdb.CreateCachingBucket(storageCfg.BucketStore.ChunksCache, storageCfg.BucketStore.MetadataCache, bucketClient, nil, logger, extprom.WrapRegistererWith(prometheus.Labels{"component": "querier"}, reg))
 	if err != nil {
 		return nil, errors.Wrap(err, "create caching bucket")
 	}
@@ -172,6 +211,10 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		MetasConcurrency:         storageCfg.BucketStore.MetaSyncConcurrency,
 		CacheDir:                 storageCfg.BucketStore.SyncDir,
 		IgnoreDeletionMarksDelay: storageCfg.BucketStore.IgnoreDeletionMarksDelay,
+		BlocksDiscoveryStrategy:  storageCfg.BucketStore.BlocksDiscoveryStrategy,
+		LazyLoadingEnabled:       storageCfg.BucketStore.TenantsLazyLoadingEnabled,
+		LazyLoadingIdleTimeout:   storageCfg.BucketStore.TenantsLazyLoadingIdleTimeout,
+		SnapshotMaxStaleness:     storageCfg.BucketStore.ScanSnapshotMaxStaleness,
 	}, bucketClient, logger, reg)
 
 	if gatewayCfg.ShardingEnabled {
@@ -194,7 +237,7 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 			reg.MustRegister(storesRing)
 		}
 
-		stores, err = newBlocksStoreReplicationSet(storesRing, gatewayCfg.ShardingStrategy, limits, querierCfg.StoreGatewayClient, logger, reg)
+		stores, err = newBlocksStoreReplicationSet(storesRing, gatewayCfg.ShardingStrategy, querierCfg.StoreGatewayPreferredZone, limits, querierCfg.StoreGatewayClient, logger, reg)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create store set")
 		}
@@ -208,8 +251,8 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 
 	consistency := NewBlocksConsistencyChecker(
 		// Exclude blocks which have been recently uploaded, in order to give enough time to store-gateways
-		// to discover and load them (3 times the sync interval).
-		storageCfg.BucketStore.ConsistencyDelay+(3*storageCfg.BucketStore.SyncInterval),
+		// to discover and load them.
+		consistencyCheckUploadGracePeriod(querierCfg, storageCfg),
 		// To avoid any false positive in the consistency check, we do exclude blocks which have been
 		// recently marked for deletion, until the "ignore delay / 2". This means the consistency checker
 		// exclude such blocks about 50% of the time before querier and store-gateway stops querying them.
@@ -218,7 +261,18 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 		reg,
 	)
 
-	return NewBlocksStoreQueryable(stores, scanner, consistency, limits, querierCfg.QueryStoreAfter, logger, reg)
+	return NewBlocksStoreQueryable(stores, scanner, consistency, limits, querierCfg.QueryStoreAfter, querierCfg.StoreGatewayBlocksBatchSize, querierCfg.StoreGatewayMaxFetchAttempts, logger, reg)
+}
+
+// consistencyCheckUploadGracePeriod returns the configured upload grace period of the blocks
+// consistency check or, if not configured, 3 times the store-gateway sync interval after the
+// consistency delay, which is the time store-gateways are expected to take to load a new block.
+func consistencyCheckUploadGracePeriod(querierCfg Config, storageCfg cortex_tsdb.BlocksStorageConfig) time.Duration {
+	if querierCfg.BlocksConsistencyCheckUploadGracePeriod > 0 {
+		return querierCfg.BlocksConsistencyCheckUploadGracePeriod
+	}
+
+	return storageCfg.BucketStore.ConsistencyDelay + (3 * storageCfg.BucketStore.SyncInterval)
 }
 
 func (q *BlocksStoreQueryable) starting(ctx context.Context) error {
@@ -258,17 +312,19 @@ func (q *BlocksStoreQueryable) Querier(ctx context.Context, mint, maxt int64) (s
 	}
 
 	return &blocksStoreQuerier{
-		ctx:             ctx,
-		minT:            mint,
-		maxT:            maxt,
-		userID:          userID,
-		finder:          q.finder,
-		stores:          q.stores,
-		metrics:         q.metrics,
-		limits:          q.limits,
-		consistency:     q.consistency,
-		logger:          q.logger,
-		queryStoreAfter: q.queryStoreAfter,
+		ctx:              ctx,
+		minT:             mint,
+		maxT:             maxt,
+		userID:           userID,
+		finder:           q.finder,
+		stores:           q.stores,
+		metrics:          q.metrics,
+		limits:           q.limits,
+		consistency:      q.consistency,
+		logger:           q.logger,
+		queryStoreAfter:  q.queryStoreAfter,
+		blocksBatchSize:  q.blocksBatchSize,
+		maxFetchAttempts: q.maxFetchAttempts,
 	}, nil
 }
 
@@ -286,6 +342,16 @@ type blocksStoreQuerier struct {
 	// If set, the querier manipulates the max time to not be greater than
 	// "now - queryStoreAfter" so that most recent blocks are not queried.
 	queryStoreAfter time.Duration
+
+	// If > 0, blocks are queried in batches of up to this size, with
+	// up to maxConcurrentBlocksBatches batches queried concurrently.
+	blocksBatchSize int
+
+	// The maximum number of times we attempt fetching blocks from different store-gateways,
+	// when missing from the responses or when the store-gateways failed. If no more
+	// store-gateways are left (ie. due to lower replication factor) then we'll end the
+	// retries earlier.
+	maxFetchAttempts int
 }
 
 // Select implements storage.Querier interface.
@@ -306,10 +372,10 @@ func (q *blocksStoreQuerier) LabelNames() ([]string, storage.Warnings, error) {
 		resWarnings = storage.Warnings(nil)
 	)
 
-	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		nameSets, warnings, queriedBlocks, err := q.fetchLabelNamesFromStore(spanCtx, clients, minT, maxT)
+	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, []ulid.ULID, error) {
+		nameSets, warnings, queriedBlocks, failedBlocks, err := q.fetchLabelNamesFromStore(spanCtx, clients, minT, maxT)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		resMtx.Lock()
@@ -317,7 +383,7 @@ func (q *blocksStoreQuerier) LabelNames() ([]string, storage.Warnings, error) {
 		resWarnings = append(resWarnings, warnings...)
 		resMtx.Unlock()
 
-		return queriedBlocks, nil
+		return queriedBlocks, failedBlocks, nil
 	}
 
 	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, queryFunc)
@@ -341,10 +407,10 @@ func (q *blocksStoreQuerier) LabelValues(name string) ([]string, storage.Warning
 		resultMtx sync.Mutex
 	)
 
-	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		valueSets, warnings, queriedBlocks, err := q.fetchLabelValuesFromStore(spanCtx, name, clients, minT, maxT)
+	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, []ulid.ULID, error) {
+		valueSets, warnings, queriedBlocks, failedBlocks, err := q.fetchLabelValuesFromStore(spanCtx, name, clients, minT, maxT)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		resultMtx.Lock()
@@ -352,7 +418,7 @@ func (q *blocksStoreQuerier) LabelValues(name string) ([]string, storage.Warning
 		resWarnings = append(resWarnings, warnings...)
 		resultMtx.Unlock()
 
-		return queriedBlocks, nil
+		return queriedBlocks, failedBlocks, nil
 	}
 
 	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, queryFunc)
@@ -381,32 +447,27 @@ func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*
 		resSeriesSets     = []storage.SeriesSet(nil)
 		resWarnings       = storage.Warnings(nil)
 
-		maxChunksLimit  = q.limits.MaxChunksPerQuery(q.userID)
-		leftChunksLimit = maxChunksLimit
+		maxChunksLimit = q.limits.MaxChunksPerQuery(q.userID)
+
+		// The number of chunks fetched so far, shared by the batches of blocks queried concurrently.
+		// Given a single block is guaranteed to not be queried twice, the chunks are counted once.
+		numChunks = atomic.NewInt32(0)
 
 		resultMtx sync.Mutex
 	)
 
-	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		seriesSets, queriedBlocks, warnings, numChunks, err := q.fetchSeriesFromStores(spanCtx, sp, clients, minT, maxT, matchers, convertedMatchers, maxChunksLimit, leftChunksLimit)
+	queryFunc := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, []ulid.ULID, error) {
+		seriesSets, queriedBlocks, failedBlocks, warnings, err := q.fetchSeriesFromStores(spanCtx, sp, clients, minT, maxT, matchers, convertedMatchers, maxChunksLimit, numChunks)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		resultMtx.Lock()
-
 		resSeriesSets = append(resSeriesSets, seriesSets...)
 		resWarnings = append(resWarnings, warnings...)
-
-		// Given a single block is guaranteed to not be queried twice, we can safely decrease the number of
-		// chunks we can still read before hitting the limit (max == 0 means disabled).
-		if maxChunksLimit > 0 {
-			leftChunksLimit -= numChunks
-		}
-
 		resultMtx.Unlock()
 
-		return queriedBlocks, nil
+		return queriedBlocks, failedBlocks, nil
 	}
 
 	err := q.queryWithConsistencyCheck(spanCtx, spanLog, minT, maxT, queryFunc)
@@ -423,16 +484,26 @@ func (q *blocksStoreQuerier) selectSorted(sp *storage.SelectHints, matchers ...*
 		resWarnings)
 }
 
+// blocksQueryFunc queries the blocks from the input store-gateway clients, and returns the
+// blocks actually queried and the blocks which failed to be fetched because their store-gateway
+// failed. The failed blocks should be retried on other store-gateways.
+type blocksQueryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) (queriedBlocks, failedBlocks []ulid.ULID, err error)
+
 func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logger log.Logger, minT, maxT int64,
-	queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error)) error {
+	queryFunc blocksQueryFunc) error {
+	queryStoreAfter := q.queryStoreAfter
+	if v := q.limits.QueryStoreAfter(q.userID); v > 0 {
+		queryStoreAfter = v
+	}
+
 	// If queryStoreAfter is enabled, we do manipulate the query maxt to query samples up until
 	// now - queryStoreAfter, because the most recent time range is covered by ingesters. This
 	// optimization is particularly important for the blocks storage because can be used to skip
 	// querying most recent not-compacted-yet blocks from the storage.
-	if q.queryStoreAfter > 0 {
+	if queryStoreAfter > 0 {
 		now := time.Now()
 		origMaxT := maxT
-		maxT = util.Min64(maxT, util.TimeToMillis(now.Add(-q.queryStoreAfter)))
+		maxT = util.Min64(maxT, util.TimeToMillis(now.Add(-queryStoreAfter)))
 
 		if origMaxT != maxT {
 			level.Debug(logger).Log("msg", "the max time of the query to blocks storage has been manipulated", "original", origMaxT, "updated", maxT)
@@ -445,30 +516,95 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 		}
 	}
 
-	// Find the list of blocks we need to query given the time range.
-	knownBlocks, knownDeletionMarks, err := q.finder.GetBlocks(ctx, q.userID, minT, maxT)
+	// Find the blocks we need to query given the time range.
+	blocksIter, err := q.finder.GetBlocksIter(ctx, q.userID, minT, maxT)
 	if err != nil {
 		return err
 	}
 
-	if len(knownBlocks) == 0 {
+	var (
+		g, gCtx         = errgroup.WithContext(ctx)
+		batchesSem      = make(chan struct{}, maxConcurrentBlocksBatches)
+		statsMtx        = sync.Mutex{}
+		touchedStores   = map[string]struct{}{}
+		maxRefetches    = 0
+		numKnownBlocks  = 0
+		numBlockBatches = 0
+	)
+
+	// Query the blocks in batches, with a limited number of batches queried concurrently.
+	// The next batch is read only once a previous one has completed.
+batches:
+	for {
+		select {
+		case batchesSem <- struct{}{}:
+		case <-gCtx.Done():
+			// Another batch failed, so there's no need to query the remaining blocks.
+			break batches
+		}
+
+		knownBlocks, knownDeletionMarks := readBlocksBatch(blocksIter, q.blocksBatchSize)
+		if len(knownBlocks) == 0 {
+			<-batchesSem
+			break
+		}
+
+		numKnownBlocks += len(knownBlocks)
+		numBlockBatches++
+		level.Debug(logger).Log("msg", "found blocks to query", "expected", knownBlocks.String())
+
+		g.Go(func() error {
+			defer func() { <-batchesSem }()
+
+			stores, refetches, err := q.queryBlocksWithConsistencyCheck(gCtx, logger, knownBlocks, knownDeletionMarks, minT, maxT, queryFunc)
+
+			statsMtx.Lock()
+			for addr := range stores {
+				touchedStores[addr] = struct{}{}
+			}
+			maxRefetches = util.Max(maxRefetches, refetches)
+			statsMtx.Unlock()
+
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	if numKnownBlocks == 0 {
 		q.metrics.storesHit.Observe(0)
 		level.Debug(logger).Log("msg", "no blocks found")
 		return nil
 	}
 
-	level.Debug(logger).Log("msg", "found blocks to query", "expected", knownBlocks.String())
+	level.Debug(logger).Log("msg", "queried all blocks", "num blocks", numKnownBlocks, "num batches", numBlockBatches)
+	q.metrics.storesHit.Observe(float64(len(touchedStores)))
+	q.metrics.refetches.Observe(float64(maxRefetches))
+
+	return nil
+}
 
+// queryBlocksWithConsistencyCheck queries the input blocks, retrying the blocks missing
+// from the store-gateways responses and the blocks whose store-gateways failed. Returns
+// the addresses of the store-gateways touched and the number of re-fetches done.
+func (q *blocksStoreQuerier) queryBlocksWithConsistencyCheck(ctx context.Context, logger log.Logger, knownBlocks bucketindex.Blocks, knownDeletionMarks map[ulid.ULID]*bucketindex.BlockDeletionMark, minT, maxT int64,
+	queryFunc blocksQueryFunc) (map[string]struct{}, int, error) {
 	var (
 		// At the beginning the list of blocks to query are all known blocks.
 		remainingBlocks = knownBlocks.GetULIDs()
 		attemptedBlocks = map[ulid.ULID][]string{}
 		touchedStores   = map[string]struct{}{}
 
+		// The blocks whose store-gateways failed in the previous attempt.
+		failedBlocks = map[ulid.ULID]struct{}{}
+		backoff      = util.NewBackoff(ctx, storeGatewayFailureBackoffConfig)
+
 		resQueriedBlocks = []ulid.ULID(nil)
 	)
 
-	for attempt := 1; attempt <= maxFetchSeriesAttempts; attempt++ {
+	for attempt := 1; attempt <= q.maxFetchAttempts; attempt++ {
 		// Find the set of store-gateway instances having the blocks. The exclude parameter is the
 		// map of blocks queried so far, with the list of store-gateway addresses for each block.
 		clients, err := q.stores.GetClientsFor(q.userID, remainingBlocks, attemptedBlocks)
@@ -480,15 +616,34 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 				break
 			}
 
-			return err
+			return nil, 0, err
 		}
 		level.Debug(logger).Log("msg", "found store-gateway instances to query", "num instances", len(clients), "attempt", attempt)
 
-		// Fetch series from stores. If an error occur we do not retry because retries
-		// are only meant to cover missing blocks.
-		queriedBlocks, err := queryFunc(clients, minT, maxT)
+		if attempt > 1 {
+			for _, blockID := range remainingBlocks {
+				if _, ok := failedBlocks[blockID]; ok {
+					q.metrics.refetchedBlocks.WithLabelValues(refetchReasonFailed).Inc()
+				} else {
+					q.metrics.refetchedBlocks.WithLabelValues(refetchReasonMissing).Inc()
+				}
+			}
+
+			// Give some time to the failing store-gateways (or the network) to recover
+			// before retrying, given the other replicas may be affected as well.
+			if len(failedBlocks) > 0 {
+				backoff.Wait()
+				if err := ctx.Err(); err != nil {
+					return nil, 0, err
+				}
+			}
+		}
+
+		// Fetch series from stores. Store-gateway failures are returned as failed blocks
+		// and retried on other store-gateways, while any other error is not retried.
+		queriedBlocks, attemptFailedBlocks, err := queryFunc(clients, minT, maxT)
 		if err != nil {
-			return err
+			return nil, 0, err
 		}
 		level.Debug(logger).Log("msg", "received series from all store-gateways", "queried blocks", strings.Join(convertULIDsToString(queriedBlocks), " "))
 
@@ -503,13 +658,19 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 			}
 		}
 
-		// Ensure all expected blocks have been queried (during all tries done so far).
+		// Ensure all expected blocks have been queried (during all tries done so far). The blocks
+		// whose store-gateways failed must be retried even if they're excluded from the consistency
+		// check, otherwise they would be silently missing from the result.
 		missingBlocks := q.consistency.Check(knownBlocks, knownDeletionMarks, resQueriedBlocks)
-		if len(missingBlocks) == 0 {
-			q.metrics.storesHit.Observe(float64(len(touchedStores)))
-			q.metrics.refetches.Observe(float64(attempt - 1))
+		missingBlocks = appendMissingULIDs(missingBlocks, attemptFailedBlocks)
 
-			return nil
+		failedBlocks = make(map[ulid.ULID]struct{}, len(attemptFailedBlocks))
+		for _, blockID := range attemptFailedBlocks {
+			failedBlocks[blockID] = struct{}{}
+		}
+
+		if len(missingBlocks) == 0 {
+			return touchedStores, attempt - 1, nil
 		}
 
 		level.Debug(logger).Log("msg", "consistency check failed", "attempt", attempt, "missing blocks", strings.Join(convertULIDsToString(missingBlocks), " "))
@@ -519,8 +680,9 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(ctx context.Context, logg
 	}
 
 	// We've not been able to query all expected blocks after all retries.
+	err := fmt.Errorf("consistency check failed because some blocks were not queried: %s", strings.Join(convertULIDsToString(remainingBlocks), " "))
 	level.Warn(util.WithContext(ctx, logger)).Log("msg", "failed consistency check", "err", err)
-	return fmt.Errorf("consistency check failed because some blocks were not queried: %s", strings.Join(convertULIDsToString(remainingBlocks), " "))
+	return nil, 0, err
 }
 
 func (q *blocksStoreQuerier) fetchSeriesFromStores(
@@ -532,8 +694,8 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 	matchers []*labels.Matcher,
 	convertedMatchers []storepb.LabelMatcher,
 	maxChunksLimit int,
-	leftChunksLimit int,
-) ([]storage.SeriesSet, []ulid.ULID, storage.Warnings, int, error) {
+	numChunks *atomic.Int32,
+) ([]storage.SeriesSet, []ulid.ULID, []ulid.ULID, storage.Warnings, error) {
 	var (
 		reqCtx        = grpc_metadata.AppendToOutgoingContext(ctx, cortex_tsdb.TenantIDExternalLabel, q.userID)
 		g, gCtx       = errgroup.WithContext(reqCtx)
@@ -541,8 +703,10 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 		seriesSets    = []storage.SeriesSet(nil)
 		warnings      = storage.Warnings(nil)
 		queriedBlocks = []ulid.ULID(nil)
-		numChunks     = atomic.NewInt32(0)
+		failures      = &storeGatewayFailures{}
 		spanLog       = spanlogger.FromContext(ctx)
+		reqStats      = stats.FromContext(ctx)
+		queryLimiter  = limiter.QueryLimiterFromContextWithFallback(ctx)
 	)
 
 	// Concurrently fetch series from all clients.
@@ -565,10 +729,10 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 
 			stream, err := c.Series(gCtx, req)
 			if err != nil {
-				return errors.Wrapf(err, "failed to fetch series from %s", c.RemoteAddress())
+				return failures.track(ctx, spanLog, c, blockIDs, errors.Wrapf(err, "failed to fetch series from %s", c.RemoteAddress()))
 			}
 
-			mySeries := []*storepb.Series(nil)
+			mySeries := &blockQuerierSeriesSetsBuilder{}
 			myWarnings := storage.Warnings(nil)
 			myQueriedBlocks := []ulid.ULID(nil)
 
@@ -584,20 +748,32 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 					break
 				}
 				if err != nil {
-					return errors.Wrapf(err, "failed to receive series from %s", c.RemoteAddress())
+					return failures.track(ctx, spanLog, c, blockIDs, errors.Wrapf(err, "failed to receive series from %s", c.RemoteAddress()))
 				}
 
 				// Response may either contain series, warning or hints.
 				if s := resp.GetSeries(); s != nil {
-					mySeries = append(mySeries, s)
+					seriesBytes := countSeriesBytes([]*storepb.Series{s})
+					mySeries.add(s, seriesBytes)
 
 					// Ensure the max number of chunks limit hasn't been reached (max == 0 means disabled).
 					if maxChunksLimit > 0 {
 						actual := numChunks.Add(int32(len(s.Chunks)))
-						if actual > int32(leftChunksLimit) {
+						if actual > int32(maxChunksLimit) {
 							return fmt.Errorf(errMaxChunksPerQueryLimit, convertMatchersToString(matchers), maxChunksLimit)
 						}
 					}
+
+					// Ensure the per-query limits haven't been reached.
+					if err := queryLimiter.AddSeries(s.PromLabels()); err != nil {
+						return err
+					}
+					if err := queryLimiter.AddChunks(len(s.Chunks)); err != nil {
+						return err
+					}
+					if err := queryLimiter.AddChunkBytes(int(seriesBytes)); err != nil {
+						return err
+					}
 				}
 
 				if w := resp.GetWarning(); w != "" {
@@ -621,14 +797,20 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 
 			level.Debug(spanLog).Log("msg", "received series from store-gateway",
 				"instance", c.RemoteAddress(),
-				"num series", len(mySeries),
-				"bytes series", countSeriesBytes(mySeries),
+				"num series", mySeries.numSeries,
+				"bytes series", mySeries.numChunkBytes,
 				"requested blocks", strings.Join(convertULIDsToString(blockIDs), " "),
 				"queried blocks", strings.Join(convertULIDsToString(myQueriedBlocks), " "))
 
+			// Track the fetched series and chunks for the query stats.
+			reqStats.AddFetchedSeries(uint64(mySeries.numSeries))
+			reqStats.AddFetchedChunks(mySeries.numChunks)
+			reqStats.AddFetchedChunkBytes(mySeries.numChunkBytes)
+			reqStats.AddFetchedStoreGatewayChunkBytes(mySeries.numChunkBytes)
+
 			// Store the result.
 			mtx.Lock()
-			seriesSets = append(seriesSets, &blockQuerierSeriesSet{series: mySeries})
+			seriesSets = append(seriesSets, mySeries.seriesSets()...)
 			warnings = append(warnings, myWarnings...)
 			queriedBlocks = append(queriedBlocks, myQueriedBlocks...)
 			mtx.Unlock()
@@ -639,10 +821,10 @@ func (q *blocksStoreQuerier) fetchSeriesFromStores(
 
 	// Wait until all client requests complete.
 	if err := g.Wait(); err != nil {
-		return nil, nil, nil, 0, err
+		return nil, nil, nil, nil, err
 	}
 
-	return seriesSets, queriedBlocks, warnings, int(numChunks.Load()), nil
+	return seriesSets, queriedBlocks, failures.blocks, warnings, nil
 }
 
 func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
@@ -650,7 +832,7 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 	clients map[BlocksStoreClient][]ulid.ULID,
 	minT int64,
 	maxT int64,
-) ([][]string, storage.Warnings, []ulid.ULID, error) {
+) ([][]string, storage.Warnings, []ulid.ULID, []ulid.ULID, error) {
 	var (
 		reqCtx        = grpc_metadata.AppendToOutgoingContext(ctx, cortex_tsdb.TenantIDExternalLabel, q.userID)
 		g, gCtx       = errgroup.WithContext(reqCtx)
@@ -658,6 +840,7 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 		nameSets      = [][]string{}
 		warnings      = storage.Warnings(nil)
 		queriedBlocks = []ulid.ULID(nil)
+		failures      = &storeGatewayFailures{}
 		spanLog       = spanlogger.FromContext(ctx)
 	)
 
@@ -675,7 +858,7 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 
 			namesResp, err := c.LabelNames(gCtx, req)
 			if err != nil {
-				return errors.Wrapf(err, "failed to fetch series from %s", c.RemoteAddress())
+				return failures.track(ctx, spanLog, c, blockIDs, errors.Wrapf(err, "failed to fetch series from %s", c.RemoteAddress()))
 			}
 
 			myQueriedBlocks := []ulid.ULID(nil)
@@ -714,10 +897,10 @@ func (q *blocksStoreQuerier) fetchLabelNamesFromStore(
 
 	// Wait until all client requests complete.
 	if err := g.Wait(); err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
 	}
 
-	return nameSets, warnings, queriedBlocks, nil
+	return nameSets, warnings, queriedBlocks, failures.blocks, nil
 }
 
 func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
@@ -726,7 +909,7 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 	clients map[BlocksStoreClient][]ulid.ULID,
 	minT int64,
 	maxT int64,
-) ([][]string, storage.Warnings, []ulid.ULID, error) {
+) ([][]string, storage.Warnings, []ulid.ULID, []ulid.ULID, error) {
 	var (
 		reqCtx        = grpc_metadata.AppendToOutgoingContext(ctx, cortex_tsdb.TenantIDExternalLabel, q.userID)
 		g, gCtx       = errgroup.WithContext(reqCtx)
@@ -734,6 +917,7 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 		valueSets     = [][]string{}
 		warnings      = storage.Warnings(nil)
 		queriedBlocks = []ulid.ULID(nil)
+		failures      = &storeGatewayFailures{}
 		spanLog       = spanlogger.FromContext(ctx)
 	)
 
@@ -751,7 +935,7 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 
 			valuesResp, err := c.LabelValues(gCtx, req)
 			if err != nil {
-				return errors.Wrapf(err, "failed to fetch series from %s", c.RemoteAddress())
+				return failures.track(ctx, spanLog, c, blockIDs, errors.Wrapf(err, "failed to fetch series from %s", c.RemoteAddress()))
 			}
 
 			myQueriedBlocks := []ulid.ULID(nil)
@@ -793,10 +977,46 @@ func (q *blocksStoreQuerier) fetchLabelValuesFromStore(
 
 	// Wait until all client requests complete.
 	if err := g.Wait(); err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, nil, err
+	}
+
+	return valueSets, warnings, queriedBlocks, failures.blocks, nil
+}
+
+// storeGatewayFailures tracks the blocks which failed to be fetched because their
+// store-gateway failed, so that they can be retried on other store-gateways.
+type storeGatewayFailures struct {
+	mtx    sync.Mutex
+	blocks []ulid.ULID
+}
+
+// track records the blocks requested to the failed store-gateway and returns nil if the
+// error can be retried on another store-gateway, otherwise it returns the input error.
+func (f *storeGatewayFailures) track(ctx context.Context, logger log.Logger, c BlocksStoreClient, blockIDs []ulid.ULID, err error) error {
+	if !isRetryableStoreGatewayError(ctx, err) {
+		return err
 	}
 
-	return valueSets, warnings, queriedBlocks, nil
+	level.Warn(logger).Log("msg", "failed to fetch blocks from store-gateway, the blocks will be retried on another store-gateway", "instance", c.RemoteAddress(), "err", err)
+
+	f.mtx.Lock()
+	f.blocks = append(f.blocks, blockIDs...)
+	f.mtx.Unlock()
+
+	return nil
+}
+
+// isRetryableStoreGatewayError returns whether the input error received from a store-gateway
+// can be retried on another store-gateway holding the same blocks.
+func isRetryableStoreGatewayError(ctx context.Context, err error) bool {
+	// The query has been canceled or has timed out.
+	if ctx.Err() != nil {
+		return false
+	}
+
+	// The store-gateway limit on the max chunks per query has been hit, and
+	// the same limit is enforced by the other store-gateways too.
+	return !strings.Contains(err.Error(), "exceeded chunks limit")
 }
 
 func createSeriesRequest(minT, maxT int64, matchers []storepb.LabelMatcher, skipChunks bool, blockIDs []ulid.ULID) (*storepb.SeriesRequest, error) {
@@ -881,6 +1101,23 @@ func createLabelValuesRequest(minT, maxT int64, label string, blockIDs []ulid.UL
 	return req, nil
 }
 
+// appendMissingULIDs appends to dst the IDs in src not already in dst.
+func appendMissingULIDs(dst, src []ulid.ULID) []ulid.ULID {
+	existing := make(map[ulid.ULID]struct{}, len(dst))
+	for _, id := range dst {
+		existing[id] = struct{}{}
+	}
+
+	for _, id := range src {
+		if _, ok := existing[id]; !ok {
+			existing[id] = struct{}{}
+			dst = append(dst, id)
+		}
+	}
+
+	return dst
+}
+
 func convertULIDsToString(ids []ulid.ULID) []string {
 	res := make([]string, len(ids))
 	for idx, id := range ids {
@@ -916,6 +1153,34 @@ func countSeriesBytes(series []*storepb.Series) (count uint64) {
 	return count
 }
 
+// blockQuerierSeriesSetsBuilder builds the series sets of the series received from a store-gateway,
+// while they're received. The store-gateway may query blocks in batches, sending series sorted
+// within each batch but not across batches, so a new series set is started each time the order breaks.
+type blockQuerierSeriesSetsBuilder struct {
+	sets  []storage.SeriesSet
+	batch []*storepb.Series
+
+	numSeries     int
+	numChunks     uint64
+	numChunkBytes uint64
+}
+
+func (b *blockQuerierSeriesSetsBuilder) add(s *storepb.Series, chunkBytes uint64) {
+	if n := len(b.batch); n > 0 && labels.Compare(labelpb.ZLabelsToPromLabels(b.batch[n-1].Labels), labelpb.ZLabelsToPromLabels(s.Labels)) > 0 {
+		b.sets = append(b.sets, &blockQuerierSeriesSet{series: b.batch})
+		b.batch = nil
+	}
+
+	b.batch = append(b.batch, s)
+	b.numSeries++
+	b.numChunks += uint64(len(s.Chunks))
+	b.numChunkBytes += chunkBytes
+}
+
+func (b *blockQuerierSeriesSetsBuilder) seriesSets() []storage.SeriesSet {
+	return append(b.sets, &blockQuerierSeriesSet{series: b.batch})
+}
+
 func convertMatchersToString(matchers []*labels.Matcher) string {
 	out := strings.Builder{}
 	out.WriteRune('{')
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -28,9 +29,11 @@ import (
 	"github.com/weaveworks/common/user"
 	"google.golang.org/grpc"
 
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/storage/tsdb/bucketindex"
 	"github.com/cortexproject/cortex/pkg/storegateway/storegatewaypb"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/services"
 )
 
@@ -66,6 +69,7 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 		finderErr         error
 		storeSetResponses []interface{}
 		limits            BlocksStoreLimits
+		queryLimiter      *limiter.QueryLimiter
 		expectedSeries    []seriesResult
 		expectedErr       string
 		expectedMetrics   string
@@ -147,6 +151,40 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				},
 			},
 		},
+		"a single store-gateway instance holds the required blocks and sends series in batches of blocks": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						// First batch.
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockSeriesResponse(labels.Labels{metricNameLabel, series2Label}, minT, 3),
+						mockHintsResponse(block1),
+						// Second batch.
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT+1, 2),
+						mockHintsResponse(block2),
+					}}: {block1, block2},
+				},
+			},
+			limits: &blocksStoreLimitsMock{},
+			expectedSeries: []seriesResult{
+				{
+					lbls: labels.New(metricNameLabel, series1Label),
+					values: []valueResult{
+						{t: minT, v: 1},
+						{t: minT + 1, v: 2},
+					},
+				}, {
+					lbls: labels.New(metricNameLabel, series2Label),
+					values: []valueResult{
+						{t: minT, v: 3},
+					},
+				},
+			},
+		},
 		"multiple store-gateway instances holds the required blocks without overlapping series (single returned series)": {
 			finderResult: bucketindex.Blocks{
 				{ID: block1},
@@ -317,6 +355,101 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 			limits:      &blocksStoreLimitsMock{},
 			expectedErr: fmt.Sprintf("consistency check failed because some blocks were not queried: %s %s", block3.String(), block4.String()),
 		},
+		"a store-gateway instance fails but its blocks are queried from a replica during the subsequent attempt": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				// First attempt returns a client which fails.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedErr: errors.New("store-gateway unavailable")}: {block2},
+				},
+				// Second attempt queries the failed block from a replica.
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "3.3.3.3", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series2Label}, minT, 2),
+						mockHintsResponse(block2),
+					}}: {block2},
+				},
+			},
+			limits: &blocksStoreLimitsMock{},
+			expectedSeries: []seriesResult{
+				{
+					lbls:   labels.New(metricNameLabel, series1Label),
+					values: []valueResult{{t: minT, v: 1}},
+				}, {
+					lbls:   labels.New(metricNameLabel, series2Label),
+					values: []valueResult{{t: minT, v: 2}},
+				},
+			},
+			expectedMetrics: `
+				# HELP cortex_querier_storegateway_instances_hit_per_query Number of store-gateway instances hit for a single query.
+				# TYPE cortex_querier_storegateway_instances_hit_per_query histogram
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="0"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="1"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="2"} 0
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="3"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="4"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="5"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="6"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="7"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="8"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="9"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="10"} 1
+				cortex_querier_storegateway_instances_hit_per_query_bucket{le="+Inf"} 1
+				cortex_querier_storegateway_instances_hit_per_query_sum 3
+				cortex_querier_storegateway_instances_hit_per_query_count 1
+
+				# HELP cortex_querier_storegateway_refetched_blocks_total Total number of blocks re-fetched from a different store-gateway instance, because missing from the store-gateway response or because the store-gateway failed.
+				# TYPE cortex_querier_storegateway_refetched_blocks_total counter
+				cortex_querier_storegateway_refetched_blocks_total{reason="failed"} 1
+
+				# HELP cortex_querier_storegateway_refetches_per_query Number of re-fetches attempted while querying store-gateway instances due to missing blocks.
+				# TYPE cortex_querier_storegateway_refetches_per_query histogram
+				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
+				cortex_querier_storegateway_refetches_per_query_bucket{le="1"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="2"} 1
+				cortex_querier_storegateway_refetches_per_query_bucket{le="+Inf"} 1
+				cortex_querier_storegateway_refetches_per_query_sum 1
+				cortex_querier_storegateway_refetches_per_query_count 1
+			`,
+		},
+		"a store-gateway instance fails and no other store-gateway holds its blocks": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockHintsResponse(block1),
+					}}: {block1},
+					&storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedErr: errors.New("store-gateway unavailable")}: {block2},
+				},
+				// Second attempt returns an error because there are no other store-gateways left.
+				errors.New("no store-gateway remaining after exclude"),
+			},
+			limits:      &blocksStoreLimitsMock{},
+			expectedErr: fmt.Sprintf("consistency check failed because some blocks were not queried: %s", block2.String()),
+		},
+		"a store-gateway instance fails because of the store-gateway max chunks limit": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedErr: errors.New("exceeded chunks limit")}: {block1},
+				},
+			},
+			limits:      &blocksStoreLimitsMock{},
+			expectedErr: "failed to fetch series from 1.1.1.1: exceeded chunks limit",
+		},
 		"multiple store-gateway instances have some missing blocks but queried from a replica during subsequent attempts": {
 			finderResult: bucketindex.Blocks{
 				{ID: block1},
@@ -385,6 +518,10 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 				cortex_querier_storegateway_instances_hit_per_query_sum 4
 				cortex_querier_storegateway_instances_hit_per_query_count 1
 
+				# HELP cortex_querier_storegateway_refetched_blocks_total Total number of blocks re-fetched from a different store-gateway instance, because missing from the store-gateway response or because the store-gateway failed.
+				# TYPE cortex_querier_storegateway_refetched_blocks_total counter
+				cortex_querier_storegateway_refetched_blocks_total{reason="missing"} 3
+
 				# HELP cortex_querier_storegateway_refetches_per_query Number of re-fetches attempted while querying store-gateway instances due to missing blocks.
 				# TYPE cortex_querier_storegateway_refetches_per_query histogram
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
@@ -474,27 +611,84 @@ func TestBlocksStoreQuerier_Select(t *testing.T) {
 			limits:      &blocksStoreLimitsMock{maxChunksPerQuery: 3},
 			expectedErr: fmt.Sprintf(errMaxChunksPerQueryLimit, fmt.Sprintf("{__name__=%q}", metricName), 3),
 		},
+		"max fetched series per query limit hit": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockSeriesResponse(labels.Labels{metricNameLabel, series2Label}, minT, 2),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{},
+			queryLimiter: limiter.NewQueryLimiter(1, 0, 0, nil),
+			expectedErr:  fmt.Sprintf(limiter.ErrMaxFetchedSeriesPerQuery, 1),
+		},
+		"max fetched chunks per query limit hit": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT+1, 2),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{},
+			queryLimiter: limiter.NewQueryLimiter(0, 0, 1, nil),
+			expectedErr:  fmt.Sprintf(limiter.ErrMaxFetchedChunksPerQuery, 1),
+		},
+		"max fetched chunk bytes per query limit hit": {
+			finderResult: bucketindex.Blocks{
+				{ID: block1},
+				{ID: block2},
+			},
+			storeSetResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{
+					&storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+						mockSeriesResponse(labels.Labels{metricNameLabel, series1Label}, minT, 1),
+						mockHintsResponse(block1, block2),
+					}}: {block1, block2},
+				},
+			},
+			limits:       &blocksStoreLimitsMock{},
+			queryLimiter: limiter.NewQueryLimiter(0, 1, 0, nil),
+			expectedErr:  fmt.Sprintf(limiter.ErrMaxFetchedChunkBytesPerQuery, 1),
+		},
 	}
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
 			ctx := context.Background()
+			if testData.queryLimiter != nil {
+				ctx = limiter.AddQueryLimiterToContext(ctx, testData.queryLimiter)
+			}
 			reg := prometheus.NewPedanticRegistry()
 			stores := &blocksStoreSetMock{mockedResponses: testData.storeSetResponses}
 			finder := &blocksFinderMock{}
 			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), testData.finderErr)
 
 			q := &blocksStoreQuerier{
-				ctx:         ctx,
-				minT:        minT,
-				maxT:        maxT,
-				userID:      "user-1",
-				finder:      finder,
-				stores:      stores,
-				consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
-				logger:      log.NewNopLogger(),
-				metrics:     newBlocksStoreQueryableMetrics(reg),
-				limits:      testData.limits,
+				ctx:              ctx,
+				minT:             minT,
+				maxT:             maxT,
+				userID:           "user-1",
+				finder:           finder,
+				stores:           stores,
+				consistency:      NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:           log.NewNopLogger(),
+				metrics:          newBlocksStoreQueryableMetrics(reg),
+				maxFetchAttempts: 3,
+				limits:           testData.limits,
 			}
 
 			matchers := []*labels.Matcher{
@@ -938,6 +1132,10 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 				cortex_querier_storegateway_instances_hit_per_query_sum 4
 				cortex_querier_storegateway_instances_hit_per_query_count 1
 
+				# HELP cortex_querier_storegateway_refetched_blocks_total Total number of blocks re-fetched from a different store-gateway instance, because missing from the store-gateway response or because the store-gateway failed.
+				# TYPE cortex_querier_storegateway_refetched_blocks_total counter
+				cortex_querier_storegateway_refetched_blocks_total{reason="missing"} 3
+
 				# HELP cortex_querier_storegateway_refetches_per_query Number of re-fetches attempted while querying store-gateway instances due to missing blocks.
 				# TYPE cortex_querier_storegateway_refetches_per_query histogram
 				cortex_querier_storegateway_refetches_per_query_bucket{le="0"} 0
@@ -962,16 +1160,17 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 				finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(testData.finderResult, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), testData.finderErr)
 
 				q := &blocksStoreQuerier{
-					ctx:         ctx,
-					minT:        minT,
-					maxT:        maxT,
-					userID:      "user-1",
-					finder:      finder,
-					stores:      stores,
-					consistency: NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
-					logger:      log.NewNopLogger(),
-					metrics:     newBlocksStoreQueryableMetrics(reg),
-					limits:      &blocksStoreLimitsMock{},
+					ctx:              ctx,
+					minT:             minT,
+					maxT:             maxT,
+					userID:           "user-1",
+					finder:           finder,
+					stores:           stores,
+					consistency:      NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+					logger:           log.NewNopLogger(),
+					metrics:          newBlocksStoreQueryableMetrics(reg),
+					maxFetchAttempts: 3,
+					limits:           &blocksStoreLimitsMock{},
 				}
 
 				if testFunc == "LabelNames" {
@@ -1012,15 +1211,159 @@ func TestBlocksStoreQuerier_Labels(t *testing.T) {
 	}
 }
 
+func TestBlocksStoreQuerier_SelectSortedShouldQueryBlocksInBatches(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1          = ulid.MustNew(1, nil)
+		block2          = ulid.MustNew(2, nil)
+		block3          = ulid.MustNew(3, nil)
+		metricNameLabel = labels.Label{Name: labels.MetricName, Value: metricName}
+	)
+
+	// Each block is held by a different store-gateway, so that each batch
+	// of a single block queries a different store-gateway.
+	stores := &blocksStoreSetByBlockMock{clients: map[ulid.ULID]BlocksStoreClient{
+		block1: &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel}, minT, 1),
+			mockHintsResponse(block1),
+		}},
+		block2: &storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel}, minT+1, 2),
+			mockHintsResponse(block2),
+		}},
+		block3: &storeGatewayClientMock{remoteAddr: "3.3.3.3", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel}, minT+2, 3),
+			mockHintsResponse(block3),
+		}},
+	}}
+
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block3}, {ID: block2}, {ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	reg := prometheus.NewPedanticRegistry()
+	q := &blocksStoreQuerier{
+		ctx:              context.Background(),
+		minT:             minT,
+		maxT:             maxT,
+		userID:           "user-1",
+		finder:           finder,
+		stores:           stores,
+		consistency:      NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:           log.NewNopLogger(),
+		metrics:          newBlocksStoreQueryableMetrics(reg),
+		maxFetchAttempts: 3,
+		limits:           &blocksStoreLimitsMock{},
+		blocksBatchSize:  1,
+	}
+
+	set := q.Select(true, nil, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName))
+	require.True(t, set.Next())
+	assert.Equal(t, labels.New(metricNameLabel), set.At().Labels())
+
+	var actualTimestamps []int64
+	it := set.At().Iterator()
+	for it.Next() {
+		ts, _ := it.At()
+		actualTimestamps = append(actualTimestamps, ts)
+	}
+	require.NoError(t, it.Err())
+	assert.Equal(t, []int64{minT, minT + 1, minT + 2}, actualTimestamps)
+
+	assert.False(t, set.Next())
+	require.NoError(t, set.Err())
+
+	// Each batch has been sent to a different store-gateway.
+	assert.Equal(t, 3, stores.getCalls())
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_querier_storegateway_instances_hit_per_query Number of store-gateway instances hit for a single query.
+		# TYPE cortex_querier_storegateway_instances_hit_per_query histogram
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="0"} 0
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="1"} 0
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="2"} 0
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="3"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="4"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="5"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="6"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="7"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="8"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="9"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="10"} 1
+		cortex_querier_storegateway_instances_hit_per_query_bucket{le="+Inf"} 1
+		cortex_querier_storegateway_instances_hit_per_query_sum 3
+		cortex_querier_storegateway_instances_hit_per_query_count 1
+	`), "cortex_querier_storegateway_instances_hit_per_query"))
+}
+
+func TestBlocksStoreQuerier_SelectSortedShouldEnforceTheMaxChunksLimitAcrossBatches(t *testing.T) {
+	const (
+		metricName = "test_metric"
+		minT       = int64(10)
+		maxT       = int64(20)
+	)
+
+	var (
+		block1          = ulid.MustNew(1, nil)
+		block2          = ulid.MustNew(2, nil)
+		block3          = ulid.MustNew(3, nil)
+		metricNameLabel = labels.Label{Name: labels.MetricName, Value: metricName}
+	)
+
+	// Each batch of a single block fetches a single chunk, which is within the limit,
+	// while the chunks fetched by all the batches are not.
+	stores := &blocksStoreSetByBlockMock{clients: map[ulid.ULID]BlocksStoreClient{
+		block1: &storeGatewayClientMock{remoteAddr: "1.1.1.1", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel}, minT, 1),
+			mockHintsResponse(block1),
+		}},
+		block2: &storeGatewayClientMock{remoteAddr: "2.2.2.2", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel}, minT+1, 2),
+			mockHintsResponse(block2),
+		}},
+		block3: &storeGatewayClientMock{remoteAddr: "3.3.3.3", mockedSeriesResponses: []*storepb.SeriesResponse{
+			mockSeriesResponse(labels.Labels{metricNameLabel}, minT+2, 3),
+			mockHintsResponse(block3),
+		}},
+	}}
+
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{{ID: block3}, {ID: block2}, {ID: block1}}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	q := &blocksStoreQuerier{
+		ctx:              context.Background(),
+		minT:             minT,
+		maxT:             maxT,
+		userID:           "user-1",
+		finder:           finder,
+		stores:           stores,
+		consistency:      NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+		logger:           log.NewNopLogger(),
+		metrics:          newBlocksStoreQueryableMetrics(prometheus.NewPedanticRegistry()),
+		maxFetchAttempts: 3,
+		limits:           &blocksStoreLimitsMock{maxChunksPerQuery: 2},
+		blocksBatchSize:  1,
+	}
+
+	matcher := labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, metricName)
+	set := q.Select(true, nil, matcher)
+	assert.False(t, set.Next())
+	require.EqualError(t, set.Err(), fmt.Sprintf(errMaxChunksPerQueryLimit, convertMatchersToString([]*labels.Matcher{matcher}), 2))
+}
+
 func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T) {
 	now := time.Now()
 
 	tests := map[string]struct {
-		queryStoreAfter time.Duration
-		queryMinT       int64
-		queryMaxT       int64
-		expectedMinT    int64
-		expectedMaxT    int64
+		queryStoreAfter       time.Duration
+		tenantQueryStoreAfter time.Duration
+		queryMinT             int64
+		queryMaxT             int64
+		expectedMinT          int64
+		expectedMaxT          int64
 	}{
 		"should not manipulate query time range if queryStoreAfter is disabled": {
 			queryStoreAfter: 0,
@@ -1043,6 +1386,14 @@ func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T)
 			expectedMinT:    util.TimeToMillis(now.Add(-100 * time.Minute)),
 			expectedMaxT:    util.TimeToMillis(now.Add(-60 * time.Minute)),
 		},
+		"should manipulate query time range with the tenant queryStoreAfter if overridden": {
+			queryStoreAfter:       time.Hour,
+			tenantQueryStoreAfter: 40 * time.Minute,
+			queryMinT:             util.TimeToMillis(now.Add(-100 * time.Minute)),
+			queryMaxT:             util.TimeToMillis(now.Add(-30 * time.Minute)),
+			expectedMinT:          util.TimeToMillis(now.Add(-100 * time.Minute)),
+			expectedMaxT:          util.TimeToMillis(now.Add(-40 * time.Minute)),
+		},
 		"should skip the query if the query min time is more recent than queryStoreAfter": {
 			queryStoreAfter: time.Hour,
 			queryMinT:       util.TimeToMillis(now.Add(-50 * time.Minute)),
@@ -1058,17 +1409,18 @@ func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T)
 			finder.On("GetBlocks", mock.Anything, "user-1", mock.Anything, mock.Anything).Return(bucketindex.Blocks(nil), map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), error(nil))
 
 			q := &blocksStoreQuerier{
-				ctx:             context.Background(),
-				minT:            testData.queryMinT,
-				maxT:            testData.queryMaxT,
-				userID:          "user-1",
-				finder:          finder,
-				stores:          &blocksStoreSetMock{},
-				consistency:     NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
-				logger:          log.NewNopLogger(),
-				metrics:         newBlocksStoreQueryableMetrics(nil),
-				limits:          &blocksStoreLimitsMock{},
-				queryStoreAfter: testData.queryStoreAfter,
+				ctx:              context.Background(),
+				minT:             testData.queryMinT,
+				maxT:             testData.queryMaxT,
+				userID:           "user-1",
+				finder:           finder,
+				stores:           &blocksStoreSetMock{},
+				consistency:      NewBlocksConsistencyChecker(0, 0, log.NewNopLogger(), nil),
+				logger:           log.NewNopLogger(),
+				metrics:          newBlocksStoreQueryableMetrics(nil),
+				maxFetchAttempts: 3,
+				limits:           &blocksStoreLimitsMock{queryStoreAfter: testData.tenantQueryStoreAfter},
+				queryStoreAfter:  testData.queryStoreAfter,
 			}
 
 			sp := &storage.SelectHints{
@@ -1090,6 +1442,16 @@ func TestBlocksStoreQuerier_SelectSortedShouldHonorQueryStoreAfter(t *testing.T)
 	}
 }
 
+func TestConsistencyCheckUploadGracePeriod(t *testing.T) {
+	storageCfg := cortex_tsdb.BlocksStorageConfig{}
+	storageCfg.BucketStore.ConsistencyDelay = time.Minute
+	storageCfg.BucketStore.SyncInterval = 15 * time.Minute
+
+	// The upload grace period is computed from the bucket store config, if not configured.
+	assert.Equal(t, 46*time.Minute, consistencyCheckUploadGracePeriod(Config{}, storageCfg))
+	assert.Equal(t, time.Hour, consistencyCheckUploadGracePeriod(Config{BlocksConsistencyCheckUploadGracePeriod: time.Hour}, storageCfg))
+}
+
 func TestBlocksStoreQuerier_PromQLExecution(t *testing.T) {
 	block1 := ulid.MustNew(1, nil)
 	block2 := ulid.MustNew(2, nil)
@@ -1182,7 +1544,7 @@ func TestBlocksStoreQuerier_PromQLExecution(t *testing.T) {
 
 	// Instance the querier that will be executed to run the query.
 	logger := log.NewNopLogger()
-	queryable, err := NewBlocksStoreQueryable(stores, finder, NewBlocksConsistencyChecker(0, 0, logger, nil), &blocksStoreLimitsMock{}, 0, logger, nil)
+	queryable, err := NewBlocksStoreQueryable(stores, finder, NewBlocksConsistencyChecker(0, 0, logger, nil), &blocksStoreLimitsMock{}, 0, 0, 3, logger, nil)
 	require.NoError(t, err)
 	require.NoError(t, services.StartAndAwaitRunning(context.Background(), queryable))
 	defer services.StopAndAwaitTerminated(context.Background(), queryable) // nolint:errcheck
@@ -1237,6 +1599,41 @@ func (m *blocksStoreSetMock) GetClientsFor(_ string, _ []ulid.ULID, _ map[ulid.U
 	return nil, errors.New("unknown data type in the mocked result")
 }
 
+// blocksStoreSetByBlockMock returns, for each requested block, the client holding it.
+type blocksStoreSetByBlockMock struct {
+	services.Service
+
+	clients map[ulid.ULID]BlocksStoreClient
+
+	callsMx sync.Mutex
+	calls   int
+}
+
+func (m *blocksStoreSetByBlockMock) GetClientsFor(_ string, blockIDs []ulid.ULID, _ map[ulid.ULID][]string) (map[BlocksStoreClient][]ulid.ULID, error) {
+	m.callsMx.Lock()
+	m.calls++
+	m.callsMx.Unlock()
+
+	res := map[BlocksStoreClient][]ulid.ULID{}
+	for _, blockID := range blockIDs {
+		c, ok := m.clients[blockID]
+		if !ok {
+			return nil, fmt.Errorf("no client found for block %s", blockID.String())
+		}
+
+		res[c] = append(res[c], blockID)
+	}
+
+	return res, nil
+}
+
+func (m *blocksStoreSetByBlockMock) getCalls() int {
+	m.callsMx.Lock()
+	defer m.callsMx.Unlock()
+
+	return m.calls
+}
+
 type blocksFinderMock struct {
 	services.Service
 	mock.Mock
@@ -1247,14 +1644,28 @@ func (m *blocksFinderMock) GetBlocks(ctx context.Context, userID string, minT, m
 	return args.Get(0).(bucketindex.Blocks), args.Get(1).(map[ulid.ULID]*bucketindex.BlockDeletionMark), args.Error(2)
 }
 
+func (m *blocksFinderMock) GetBlocksIter(ctx context.Context, userID string, minT, maxT int64) (BlocksIterator, error) {
+	blocks, deletionMarks, err := m.GetBlocks(ctx, userID, minT, maxT)
+	if err != nil {
+		return nil, err
+	}
+
+	return newSliceBlocksIterator(blocks, deletionMarks), nil
+}
+
 type storeGatewayClientMock struct {
 	remoteAddr                string
 	mockedSeriesResponses     []*storepb.SeriesResponse
 	mockedLabelNamesResponse  *storepb.LabelNamesResponse
 	mockedLabelValuesResponse *storepb.LabelValuesResponse
+	mockedErr                 error
 }
 
 func (m *storeGatewayClientMock) Series(ctx context.Context, in *storepb.SeriesRequest, opts ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	if m.mockedErr != nil {
+		return nil, m.mockedErr
+	}
+
 	seriesClient := &storeGatewaySeriesClientMock{
 		mockedResponses: m.mockedSeriesResponses,
 	}
@@ -1263,10 +1674,18 @@ func (m *storeGatewayClientMock) Series(ctx context.Context, in *storepb.SeriesR
 }
 
 func (m *storeGatewayClientMock) LabelNames(context.Context, *storepb.LabelNamesRequest, ...grpc.CallOption) (*storepb.LabelNamesResponse, error) {
+	if m.mockedErr != nil {
+		return nil, m.mockedErr
+	}
+
 	return m.mockedLabelNamesResponse, nil
 }
 
 func (m *storeGatewayClientMock) LabelValues(context.Context, *storepb.LabelValuesRequest, ...grpc.CallOption) (*storepb.LabelValuesResponse, error) {
+	if m.mockedErr != nil {
+		return nil, m.mockedErr
+	}
+
 	return m.mockedLabelValuesResponse, nil
 }
 
@@ -1296,6 +1715,7 @@ func (m *storeGatewaySeriesClientMock) Recv() (*storepb.SeriesResponse, error) {
 type blocksStoreLimitsMock struct {
 	maxChunksPerQuery           int
 	storeGatewayTenantShardSize int
+	queryStoreAfter             time.Duration
 }
 
 func (m *blocksStoreLimitsMock) MaxChunksPerQuery(_ string) int {
@@ -1306,6 +1726,10 @@ func (m *blocksStoreLimitsMock) StoreGatewayTenantShardSize(userID string) int {
 	return m.storeGatewayTenantShardSize
 }
 
+func (m *blocksStoreLimitsMock) QueryStoreAfter(_ string) time.Duration {
+	return m.queryStoreAfter
+}
+
 func mockSeriesResponse(lbls labels.Labels, timeMillis int64, value float64) *storepb.SeriesResponse {
 	// Generate a chunk containing a single value (for simplicity).
 	chunk := chunkenc.NewXORChunk()
@@ -28,6 +28,9 @@ type blocksStoreReplicationSet struct {
 	shardingStrategy string
 	limits           BlocksStoreLimits
 
+	// Zone of the store-gateways to query in first instance, if any.
+	preferredZone string
+
 	// Subservices manager.
 	subservices        *services.Manager
 	subservicesWatcher *services.FailureWatcher
@@ -36,6 +39,7 @@ type blocksStoreReplicationSet struct {
 func newBlocksStoreReplicationSet(
 	storesRing *ring.Ring,
 	shardingStrategy string,
+	preferredZone string,
 	limits BlocksStoreLimits,
 	tlsCfg tls.ClientConfig,
 	logger log.Logger,
@@ -45,6 +49,7 @@ func newBlocksStoreReplicationSet(
 		storesRing:       storesRing,
 		clientsPool:      newStoreGatewayClientPool(client.NewRingServiceDiscovery(storesRing), tlsCfg, logger, reg),
 		shardingStrategy: shardingStrategy,
+		preferredZone:    preferredZone,
 		limits:           limits,
 	}
 
@@ -108,8 +113,8 @@ func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid
 			return nil, errors.Wrapf(err, "failed to get store-gateway replication set owning the block %s", blockID.String())
 		}
 
-		// Pick the first non excluded store-gateway instance.
-		addr := getFirstNonExcludedInstanceAddr(set, exclude[blockID])
+		// Pick the first non excluded store-gateway instance, preferring the ones in the preferred zone.
+		addr := getFirstNonExcludedInstanceAddr(set, exclude[blockID], s.preferredZone)
 		if addr == "" {
 			return nil, fmt.Errorf("no store-gateway instance left after checking exclude for block %s", blockID.String())
 		}
@@ -132,7 +137,16 @@ func (s *blocksStoreReplicationSet) GetClientsFor(userID string, blockIDs []ulid
 	return clients, nil
 }
 
-func getFirstNonExcludedInstanceAddr(set ring.ReplicationSet, exclude []string) string {
+func getFirstNonExcludedInstanceAddr(set ring.ReplicationSet, exclude []string, preferredZone string) string {
+	if preferredZone != "" {
+		for _, instance := range set.Ingesters {
+			if instance.Zone == preferredZone && !util.StringsContain(exclude, instance.Addr) {
+				return instance.Addr
+			}
+		}
+	}
+
+	// Fallback to any zone.
 	for _, instance := range set.Ingesters {
 		if !util.StringsContain(exclude, instance.Addr) {
 			return instance.Addr
@@ -42,14 +42,16 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 	registeredAt := time.Now()
 
 	tests := map[string]struct {
-		shardingStrategy  string
-		tenantShardSize   int
-		replicationFactor int
-		setup             func(*ring.Desc)
-		queryBlocks       []ulid.ULID
-		exclude           map[ulid.ULID][]string
-		expectedClients   map[string][]ulid.ULID
-		expectedErr       error
+		shardingStrategy     string
+		tenantShardSize      int
+		replicationFactor    int
+		zoneAwarenessEnabled bool
+		preferredZone        string
+		setup                func(*ring.Desc)
+		queryBlocks          []ulid.ULID
+		exclude              map[ulid.ULID][]string
+		expectedClients      map[string][]ulid.ULID
+		expectedErr          error
 	}{
 		//
 		// Sharding strategy: default
@@ -305,6 +307,70 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 			},
 			expectedErr: fmt.Errorf("no store-gateway instance left after checking exclude for block %s", block1.String()),
 		},
+		//
+		// Zone-awareness
+		//
+		"zone-awareness enabled, RF = 2, no preferred zone": {
+			shardingStrategy:     util.ShardingStrategyDefault,
+			replicationFactor:    2,
+			zoneAwarenessEnabled: true,
+			setup: func(d *ring.Desc) {
+				d.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{block1Hash + 1, block3Hash + 1}, ring.ACTIVE, registeredAt)
+				d.AddIngester("instance-2", "127.0.0.2", "zone-b", []uint32{block2Hash + 1, block4Hash + 1}, ring.ACTIVE, registeredAt)
+			},
+			queryBlocks: []ulid.ULID{block1, block2},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.1": {block1},
+				"127.0.0.2": {block2},
+			},
+		},
+		"zone-awareness enabled, RF = 2, should query the store-gateways in the preferred zone": {
+			shardingStrategy:     util.ShardingStrategyDefault,
+			replicationFactor:    2,
+			zoneAwarenessEnabled: true,
+			preferredZone:        "zone-b",
+			setup: func(d *ring.Desc) {
+				d.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{block1Hash + 1, block3Hash + 1}, ring.ACTIVE, registeredAt)
+				d.AddIngester("instance-2", "127.0.0.2", "zone-b", []uint32{block2Hash + 1, block4Hash + 1}, ring.ACTIVE, registeredAt)
+			},
+			queryBlocks: []ulid.ULID{block1, block2},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.2": {block1, block2},
+			},
+		},
+		"zone-awareness enabled, RF = 2, should fallback to another zone if the preferred zone instance is excluded": {
+			shardingStrategy:     util.ShardingStrategyDefault,
+			replicationFactor:    2,
+			zoneAwarenessEnabled: true,
+			preferredZone:        "zone-b",
+			setup: func(d *ring.Desc) {
+				d.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{block1Hash + 1, block3Hash + 1}, ring.ACTIVE, registeredAt)
+				d.AddIngester("instance-2", "127.0.0.2", "zone-b", []uint32{block2Hash + 1, block4Hash + 1}, ring.ACTIVE, registeredAt)
+			},
+			queryBlocks: []ulid.ULID{block1, block2},
+			exclude: map[ulid.ULID][]string{
+				block1: {"127.0.0.2"},
+			},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.1": {block1},
+				"127.0.0.2": {block2},
+			},
+		},
+		"zone-awareness enabled, RF = 2, should fallback to another zone if no instance in the preferred zone": {
+			shardingStrategy:     util.ShardingStrategyDefault,
+			replicationFactor:    2,
+			zoneAwarenessEnabled: true,
+			preferredZone:        "zone-c",
+			setup: func(d *ring.Desc) {
+				d.AddIngester("instance-1", "127.0.0.1", "zone-a", []uint32{block1Hash + 1, block3Hash + 1}, ring.ACTIVE, registeredAt)
+				d.AddIngester("instance-2", "127.0.0.2", "zone-b", []uint32{block2Hash + 1, block4Hash + 1}, ring.ACTIVE, registeredAt)
+			},
+			queryBlocks: []ulid.ULID{block1, block2},
+			expectedClients: map[string][]ulid.ULID{
+				"127.0.0.1": {block1},
+				"127.0.0.2": {block2},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -326,6 +392,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 			ringCfg := ring.Config{}
 			flagext.DefaultValues(&ringCfg)
 			ringCfg.ReplicationFactor = testData.replicationFactor
+			ringCfg.ZoneAwarenessEnabled = testData.zoneAwarenessEnabled
 
 			r, err := ring.NewWithStoreClientAndStrategy(ringCfg, "test", "test", ringStore, &storegateway.BlocksReplicationStrategy{})
 			require.NoError(t, err)
@@ -335,7 +402,7 @@ func TestBlocksStoreReplicationSet_GetClientsFor(t *testing.T) {
 			}
 
 			reg := prometheus.NewPedanticRegistry()
-			s, err := newBlocksStoreReplicationSet(r, testData.shardingStrategy, limits, tls.ClientConfig{}, log.NewNopLogger(), reg)
+			s, err := newBlocksStoreReplicationSet(r, testData.shardingStrategy, testData.preferredZone, limits, tls.ClientConfig{}, log.NewNopLogger(), reg)
 			require.NoError(t, err)
 			require.NoError(t, services.StartAndAwaitRunning(ctx, s))
 			defer services.StopAndAwaitTerminated(ctx, s) //nolint:errcheck
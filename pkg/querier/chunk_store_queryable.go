@@ -12,7 +12,9 @@ import (
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	"github.com/cortexproject/cortex/pkg/querier/chunkstore"
 	seriesset "github.com/cortexproject/cortex/pkg/querier/series"
+	"github.com/cortexproject/cortex/pkg/querier/stats"
 	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 )
 
 type chunkIteratorFunc func(chunks []chunk.Chunk, from, through model.Time) chunkenc.Iterator
@@ -48,6 +50,34 @@ func (q *chunkStoreQuerier) Select(_ bool, sp *storage.SelectHints, matchers ...
 		return storage.ErrSeriesSet(err)
 	}
 
+	// Track the fetched series and chunks for the query stats, and enforce the per-query limits.
+	reqStats := stats.FromContext(q.ctx)
+	queryLimiter := limiter.QueryLimiterFromContextWithFallback(q.ctx)
+
+	fingerprints := map[model.Fingerprint]struct{}{}
+	chunkBytes := 0
+	for _, c := range chunks {
+		if _, ok := fingerprints[c.Fingerprint]; !ok {
+			fingerprints[c.Fingerprint] = struct{}{}
+
+			if err := queryLimiter.AddSeries(c.Metric); err != nil {
+				return storage.ErrSeriesSet(err)
+			}
+		}
+		chunkBytes += c.Data.Size()
+	}
+
+	reqStats.AddFetchedSeries(uint64(len(fingerprints)))
+	reqStats.AddFetchedChunks(uint64(len(chunks)))
+	reqStats.AddFetchedChunkBytes(uint64(chunkBytes))
+
+	if err := queryLimiter.AddChunks(len(chunks)); err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+	if err := queryLimiter.AddChunkBytes(chunkBytes); err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
 	return partitionChunks(chunks, q.mint, q.maxt, q.chunkIteratorFunc)
 }
 
@@ -14,9 +14,11 @@ import (
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	"github.com/cortexproject/cortex/pkg/prom1/storage/metric"
 	"github.com/cortexproject/cortex/pkg/querier/series"
+	"github.com/cortexproject/cortex/pkg/querier/stats"
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/chunkcompat"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 )
 
@@ -31,12 +33,20 @@ type Distributor interface {
 	MetricsMetadata(ctx context.Context) ([]scrape.MetricMetadata, error)
 }
 
-func newDistributorQueryable(distributor Distributor, streaming bool, iteratorFn chunkIteratorFunc, queryIngestersWithin time.Duration) QueryableWithFilter {
+// DistributorQueryableLimits is the interface that should be implemented by the limits provider.
+type DistributorQueryableLimits interface {
+	// QueryIngestersWithin returns the maximum lookback beyond which the tenant's
+	// queries are not sent to ingesters, or 0 to use the querier config.
+	QueryIngestersWithin(userID string) time.Duration
+}
+
+func newDistributorQueryable(distributor Distributor, streaming bool, iteratorFn chunkIteratorFunc, queryIngestersWithin time.Duration, limits DistributorQueryableLimits) QueryableWithFilter {
 	return distributorQueryable{
 		distributor:          distributor,
 		streaming:            streaming,
 		iteratorFn:           iteratorFn,
 		queryIngestersWithin: queryIngestersWithin,
+		limits:               limits,
 	}
 }
 
@@ -45,9 +55,19 @@ type distributorQueryable struct {
 	streaming            bool
 	iteratorFn           chunkIteratorFunc
 	queryIngestersWithin time.Duration
+	limits               DistributorQueryableLimits
 }
 
 func (d distributorQueryable) Querier(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+	queryIngestersWithin := d.queryIngestersWithin
+	if d.limits != nil {
+		userID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		queryIngestersWithin = d.queryIngestersWithinForUser(userID)
+	}
+
 	return &distributorQuerier{
 		distributor:          d.distributor,
 		ctx:                  ctx,
@@ -55,13 +75,25 @@ func (d distributorQueryable) Querier(ctx context.Context, mint, maxt int64) (st
 		maxt:                 maxt,
 		streaming:            d.streaming,
 		chunkIterFn:          d.iteratorFn,
-		queryIngestersWithin: d.queryIngestersWithin,
+		queryIngestersWithin: queryIngestersWithin,
 	}, nil
 }
 
-func (d distributorQueryable) UseQueryable(now time.Time, _, queryMaxT int64) bool {
+func (d distributorQueryable) UseQueryable(now time.Time, userID string, _, queryMaxT int64) bool {
 	// Include ingester only if maxt is within QueryIngestersWithin w.r.t. current time.
-	return d.queryIngestersWithin == 0 || queryMaxT >= util.TimeToMillis(now.Add(-d.queryIngestersWithin))
+	queryIngestersWithin := d.queryIngestersWithinForUser(userID)
+	return queryIngestersWithin == 0 || queryMaxT >= util.TimeToMillis(now.Add(-queryIngestersWithin))
+}
+
+// queryIngestersWithinForUser returns the query ingesters within period of the tenant,
+// which overrides the querier config if set.
+func (d distributorQueryable) queryIngestersWithinForUser(userID string) time.Duration {
+	if d.limits != nil {
+		if v := d.limits.QueryIngestersWithin(userID); v > 0 {
+			return v
+		}
+	}
+	return d.queryIngestersWithin
 }
 
 type distributorQuerier struct {
@@ -124,6 +156,15 @@ func (q *distributorQuerier) Select(_ bool, sp *storage.SelectHints, matchers ..
 		return storage.ErrSeriesSet(err)
 	}
 
+	stats.FromContext(ctx).AddFetchedSeries(uint64(len(matrix)))
+
+	queryLimiter := limiter.QueryLimiterFromContextWithFallback(ctx)
+	for _, stream := range matrix {
+		if err := queryLimiter.AddSeries(client.FromLabelAdaptersToLabels(client.FromMetricsToLabelAdapters(stream.Metric))); err != nil {
+			return storage.ErrSeriesSet(err)
+		}
+	}
+
 	// Using MatrixToSeriesSet (and in turn NewConcreteSeriesSet), sorts the series.
 	return series.MatrixToSeriesSet(matrix)
 }
@@ -139,6 +180,36 @@ func (q *distributorQuerier) streamingSelect(minT, maxT int64, matchers []*label
 		return storage.ErrSeriesSet(err)
 	}
 
+	// Track the fetched series and chunks for the query stats, and enforce the per-query limits.
+	reqStats := stats.FromContext(q.ctx)
+	queryLimiter := limiter.QueryLimiterFromContextWithFallback(q.ctx)
+	reqStats.AddFetchedSeries(uint64(len(results.Chunkseries) + len(results.Timeseries)))
+	for _, result := range results.Chunkseries {
+		chunkBytes := 0
+		for _, c := range result.Chunks {
+			chunkBytes += len(c.Data)
+		}
+
+		reqStats.AddFetchedChunks(uint64(len(result.Chunks)))
+		reqStats.AddFetchedChunkBytes(uint64(chunkBytes))
+		reqStats.AddFetchedIngesterChunkBytes(uint64(chunkBytes))
+
+		if err := queryLimiter.AddSeries(client.FromLabelAdaptersToLabels(result.Labels)); err != nil {
+			return storage.ErrSeriesSet(err)
+		}
+		if err := queryLimiter.AddChunks(len(result.Chunks)); err != nil {
+			return storage.ErrSeriesSet(err)
+		}
+		if err := queryLimiter.AddChunkBytes(chunkBytes); err != nil {
+			return storage.ErrSeriesSet(err)
+		}
+	}
+	for _, result := range results.Timeseries {
+		if err := queryLimiter.AddSeries(client.FromLabelAdaptersToLabels(result.Labels)); err != nil {
+			return storage.ErrSeriesSet(err)
+		}
+	}
+
 	sets := []storage.SeriesSet(nil)
 	if len(results.Timeseries) > 0 {
 		sets = append(sets, newTimeSeriesSeriesSet(results.Timeseries))
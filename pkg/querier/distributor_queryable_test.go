@@ -19,8 +19,10 @@ import (
 	"github.com/cortexproject/cortex/pkg/chunk/encoding"
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	"github.com/cortexproject/cortex/pkg/prom1/storage/metric"
+	"github.com/cortexproject/cortex/pkg/querier/stats"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/chunkcompat"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 )
 
 const (
@@ -45,7 +47,7 @@ func TestDistributorQuerier(t *testing.T) {
 		},
 		nil)
 
-	queryable := newDistributorQueryable(d, false, nil, 0)
+	queryable := newDistributorQueryable(d, false, nil, 0, nil)
 	querier, err := queryable.Querier(context.Background(), mint, maxt)
 	require.NoError(t, err)
 
@@ -122,7 +124,7 @@ func TestDistributorQuerier_SelectShouldHonorQueryIngestersWithin(t *testing.T)
 				distributor.On("MetricsForLabelMatchers", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return([]metric.Metric{}, nil)
 
 				ctx := user.InjectOrgID(context.Background(), "test")
-				queryable := newDistributorQueryable(distributor, streamingEnabled, nil, testData.queryIngestersWithin)
+				queryable := newDistributorQueryable(distributor, streamingEnabled, nil, testData.queryIngestersWithin, nil)
 				querier, err := queryable.Querier(ctx, testData.queryMinT, testData.queryMaxT)
 				require.NoError(t, err)
 
@@ -149,18 +151,31 @@ func TestDistributorQuerier_SelectShouldHonorQueryIngestersWithin(t *testing.T)
 
 func TestDistributorQueryableFilter(t *testing.T) {
 	d := &mockDistributor{}
-	dq := newDistributorQueryable(d, false, nil, 1*time.Hour)
+	dq := newDistributorQueryable(d, false, nil, 1*time.Hour, nil)
 
 	now := time.Now()
 
 	queryMinT := util.TimeToMillis(now.Add(-5 * time.Minute))
 	queryMaxT := util.TimeToMillis(now)
 
-	require.True(t, dq.UseQueryable(now, queryMinT, queryMaxT))
-	require.True(t, dq.UseQueryable(now.Add(time.Hour), queryMinT, queryMaxT))
+	require.True(t, dq.UseQueryable(now, "user-1", queryMinT, queryMaxT))
+	require.True(t, dq.UseQueryable(now.Add(time.Hour), "user-1", queryMinT, queryMaxT))
 
 	// Same query, hour+1ms later, is not sent to ingesters.
-	require.False(t, dq.UseQueryable(now.Add(time.Hour).Add(1*time.Millisecond), queryMinT, queryMaxT))
+	require.False(t, dq.UseQueryable(now.Add(time.Hour).Add(1*time.Millisecond), "user-1", queryMinT, queryMaxT))
+
+	// The query is still sent to ingesters for the tenant with a longer per-tenant period.
+	dq = newDistributorQueryable(d, false, nil, 1*time.Hour, &distributorQueryableLimitsMock{queryIngestersWithin: map[string]time.Duration{"user-2": 2 * time.Hour}})
+	require.False(t, dq.UseQueryable(now.Add(time.Hour).Add(1*time.Millisecond), "user-1", queryMinT, queryMaxT))
+	require.True(t, dq.UseQueryable(now.Add(time.Hour).Add(1*time.Millisecond), "user-2", queryMinT, queryMaxT))
+}
+
+type distributorQueryableLimitsMock struct {
+	queryIngestersWithin map[string]time.Duration
+}
+
+func (m *distributorQueryableLimitsMock) QueryIngestersWithin(userID string) time.Duration {
+	return m.queryIngestersWithin[userID]
 }
 
 func TestIngesterStreaming(t *testing.T) {
@@ -194,8 +209,8 @@ func TestIngesterStreaming(t *testing.T) {
 		},
 		nil)
 
-	ctx := user.InjectOrgID(context.Background(), "0")
-	queryable := newDistributorQueryable(d, true, mergeChunks, 0)
+	queryStats, ctx := stats.ContextWithEmptyStats(user.InjectOrgID(context.Background(), "0"))
+	queryable := newDistributorQueryable(d, true, mergeChunks, 0, nil)
 	querier, err := queryable.Querier(ctx, mint, maxt)
 	require.NoError(t, err)
 
@@ -212,9 +227,80 @@ func TestIngesterStreaming(t *testing.T) {
 
 	require.False(t, seriesSet.Next())
 	require.NoError(t, seriesSet.Err())
+
+	// Ensure the fetched series and chunks have been tracked in the query stats.
+	assert.Equal(t, uint64(2), queryStats.LoadFetchedSeries())
+	assert.Equal(t, uint64(2*len(clientChunks)), queryStats.LoadFetchedChunks())
+	assert.Equal(t, uint64(2*len(clientChunks[0].Data)), queryStats.LoadFetchedChunkBytes())
+}
+
+func TestIngesterStreaming_ShouldEnforceQueryLimits(t *testing.T) {
+	promChunk, err := encoding.NewForEncoding(encoding.Bigchunk)
+	require.NoError(t, err)
+
+	clientChunks, err := chunkcompat.ToChunks([]chunk.Chunk{
+		chunk.NewChunk("", 0, nil, promChunk, model.Earliest, model.Earliest),
+	})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		queryLimiter *limiter.QueryLimiter
+		expectedErr  string
+	}{
+		"should succeed if no limit is hit": {
+			queryLimiter: limiter.NewQueryLimiter(2, 2*len(clientChunks[0].Data), 2, nil),
+		},
+		"should fail if the max fetched series limit is hit": {
+			queryLimiter: limiter.NewQueryLimiter(1, 0, 0, nil),
+			expectedErr:  fmt.Sprintf(limiter.ErrMaxFetchedSeriesPerQuery, 1),
+		},
+		"should fail if the max fetched chunks limit is hit": {
+			queryLimiter: limiter.NewQueryLimiter(0, 0, 1, nil),
+			expectedErr:  fmt.Sprintf(limiter.ErrMaxFetchedChunksPerQuery, 1),
+		},
+		"should fail if the max fetched chunk bytes limit is hit": {
+			queryLimiter: limiter.NewQueryLimiter(0, 1, 0, nil),
+			expectedErr:  fmt.Sprintf(limiter.ErrMaxFetchedChunkBytesPerQuery, 1),
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			d := &mockDistributor{}
+			d.On("QueryStream", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(
+				&client.QueryStreamResponse{
+					Chunkseries: []client.TimeSeriesChunk{
+						{Labels: []client.LabelAdapter{{Name: "bar", Value: "baz"}}, Chunks: clientChunks},
+						{Labels: []client.LabelAdapter{{Name: "foo", Value: "bar"}}, Chunks: clientChunks},
+					},
+				},
+				nil)
+
+			ctx := limiter.AddQueryLimiterToContext(user.InjectOrgID(context.Background(), "0"), testData.queryLimiter)
+			queryable := newDistributorQueryable(d, true, mergeChunks, 0, nil)
+			querier, err := queryable.Querier(ctx, mint, maxt)
+			require.NoError(t, err)
+
+			seriesSet := querier.Select(true, &storage.SelectHints{Start: mint, End: maxt})
+			if testData.expectedErr != "" {
+				require.EqualError(t, seriesSet.Err(), testData.expectedErr)
+				return
+			}
+
+			require.NoError(t, seriesSet.Err())
+		})
+	}
 }
 
 func TestIngesterStreamingMixedResults(t *testing.T) {
+	for _, enc := range []encoding.Encoding{encoding.Bigchunk, encoding.PrometheusXorChunk} {
+		t.Run(enc.String(), func(t *testing.T) {
+			testIngesterStreamingMixedResults(t, enc)
+		})
+	}
+}
+
+func testIngesterStreamingMixedResults(t *testing.T, enc encoding.Encoding) {
 	const (
 		mint = 0
 		maxt = 10000
@@ -249,11 +335,11 @@ func TestIngesterStreamingMixedResults(t *testing.T) {
 			Chunkseries: []client.TimeSeriesChunk{
 				{
 					Labels: []client.LabelAdapter{{Name: labels.MetricName, Value: "one"}},
-					Chunks: convertToChunks(t, s1),
+					Chunks: convertToChunks(t, enc, s1),
 				},
 				{
 					Labels: []client.LabelAdapter{{Name: labels.MetricName, Value: "two"}},
-					Chunks: convertToChunks(t, s1),
+					Chunks: convertToChunks(t, enc, s1),
 				},
 			},
 
@@ -271,7 +357,7 @@ func TestIngesterStreamingMixedResults(t *testing.T) {
 		nil)
 
 	ctx := user.InjectOrgID(context.Background(), "0")
-	queryable := newDistributorQueryable(d, true, mergeChunks, 0)
+	queryable := newDistributorQueryable(d, true, mergeChunks, 0, nil)
 	querier, err := queryable.Querier(ctx, mint, maxt)
 	require.NoError(t, err)
 
@@ -306,10 +392,10 @@ func verifySeries(t *testing.T, series storage.Series, l labels.Labels, samples
 	require.Nil(t, it.Err())
 }
 
-func convertToChunks(t *testing.T, samples []client.Sample) []client.Chunk {
+func convertToChunks(t *testing.T, enc encoding.Encoding, samples []client.Sample) []client.Chunk {
 	// We need to make sure that there is atleast one chunk present,
 	// else no series will be selected.
-	promChunk, err := encoding.NewForEncoding(encoding.Bigchunk)
+	promChunk, err := encoding.NewForEncoding(enc)
 	require.NoError(t, err)
 
 	for _, s := range samples {
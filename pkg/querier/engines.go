@@ -0,0 +1,164 @@
+package querier
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/promql"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+const (
+	errQueryEngineMaxSamples = "the query loaded more samples than the max samples limit (limit: %d samples)"
+	errQueryEngineTimeout    = "the query evaluation exceeded the query timeout limit (limit: %s)"
+)
+
+// engineOptions are the PromQL engine options which can be overridden per tenant.
+type engineOptions struct {
+	maxSamples                int
+	timeout                   time.Duration
+	lookbackDelta             time.Duration
+	defaultEvaluationInterval time.Duration
+}
+
+// Engines holds the PromQL engines evaluating the queries. The queries of the tenants
+// without engine options overrides are evaluated by the default engine, while the
+// queries of the other tenants are evaluated by an engine created on demand, shared
+// by all the tenants with the same options. The engine metrics are exported only for
+// the default engine.
+type Engines struct {
+	cfg           Config
+	limits        *validation.Overrides
+	tracker       *promql.ActiveQueryTracker
+	defaultEngine *promql.Engine
+
+	limitsExceeded *prometheus.CounterVec
+
+	mtx     sync.Mutex
+	engines map[engineOptions]*promql.Engine
+}
+
+// NewEngines makes a new Engines.
+func NewEngines(cfg Config, limits *validation.Overrides, reg prometheus.Registerer) *Engines {
+	e := &Engines{
+		cfg:     cfg,
+		limits:  limits,
+		tracker: createActiveQueryTracker(cfg),
+		engines: map[engineOptions]*promql.Engine{},
+		limitsExceeded: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_querier_engine_limits_exceeded_total",
+			Help: "Total number of queries which exceeded a per-tenant PromQL engine limit.",
+		}, []string{"user", "limit"}),
+	}
+
+	e.defaultEngine = e.newEngine(e.defaultOptions(), reg)
+	return e
+}
+
+func (e *Engines) defaultOptions() engineOptions {
+	return engineOptions{
+		maxSamples:                e.cfg.MaxSamples,
+		timeout:                   e.cfg.Timeout,
+		lookbackDelta:             e.cfg.LookbackDelta,
+		defaultEvaluationInterval: e.cfg.DefaultEvaluationInterval,
+	}
+}
+
+func (e *Engines) newEngine(opts engineOptions, reg prometheus.Registerer) *promql.Engine {
+	return promql.NewEngine(promql.EngineOpts{
+		Logger:             util.Logger,
+		Reg:                reg,
+		ActiveQueryTracker: e.tracker,
+		MaxSamples:         opts.maxSamples,
+		Timeout:            opts.timeout,
+		LookbackDelta:      opts.lookbackDelta,
+		NoStepSubqueryIntervalFn: func(int64) int64 {
+			return opts.defaultEvaluationInterval.Milliseconds()
+		},
+	})
+}
+
+// Default returns the engine evaluating the queries with the options of the querier config.
+func (e *Engines) Default() *promql.Engine {
+	return e.defaultEngine
+}
+
+// tenantsOptions returns the engine options of the given tenants. For multi-tenant
+// queries, the smallest of the tenants' overrides is used.
+func (e *Engines) tenantsOptions(tenantIDs []string) engineOptions {
+	opts := e.defaultOptions()
+	if e.limits == nil {
+		return opts
+	}
+
+	if v := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, e.limits.QueryEngineMaxSamples); v > 0 {
+		opts.maxSamples = v
+	}
+	if v := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.QueryEngineTimeout); v > 0 {
+		opts.timeout = v
+	}
+	if v := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.QueryEngineLookbackDelta); v > 0 {
+		opts.lookbackDelta = v
+	}
+	if v := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.QueryEngineDefaultEvaluationInterval); v > 0 {
+		opts.defaultEvaluationInterval = v
+	}
+	return opts
+}
+
+// ForTenants returns the engine evaluating the queries of the given tenants.
+func (e *Engines) ForTenants(tenantIDs []string) *promql.Engine {
+	opts := e.tenantsOptions(tenantIDs)
+	if opts == e.defaultOptions() {
+		return e.defaultEngine
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	engine, ok := e.engines[opts]
+	if !ok {
+		engine = e.newEngine(opts, nil)
+		e.engines[opts] = engine
+	}
+	return engine
+}
+
+// TranslateError returns the error to return for the error of a query of the given tenants,
+// evaluated by the engine returned by ForTenants. Exceeding the max samples or the timeout
+// overridden for the tenants is a limit error, which is tracked and returned as an error
+// not retriable (HTTP status 422) instead of the engine error.
+func (e *Engines) TranslateError(tenantIDs []string, err error) error {
+	if err == nil || e.limits == nil {
+		return err
+	}
+
+	var limit string
+	var limitErr error
+
+	switch errors.Cause(err).(type) {
+	case promql.ErrTooManySamples:
+		if v := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, e.limits.QueryEngineMaxSamples); v > 0 {
+			limit, limitErr = "max_samples", validation.LimitError(fmt.Sprintf(errQueryEngineMaxSamples, v))
+		}
+	case promql.ErrQueryTimeout:
+		if v := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, e.limits.QueryEngineTimeout); v > 0 {
+			limit, limitErr = "timeout", validation.LimitError(fmt.Sprintf(errQueryEngineTimeout, v))
+		}
+	}
+
+	if limitErr == nil {
+		return err
+	}
+
+	for _, userID := range tenantIDs {
+		e.limitsExceeded.WithLabelValues(userID, limit).Inc()
+	}
+	return limitErr
+}
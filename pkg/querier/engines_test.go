@@ -0,0 +1,100 @@
+package querier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestEngines_ForTenants(t *testing.T) {
+	limits := defaultLimitsConfig()
+	overrides, err := validation.NewOverrides(limits, func(userID string) *validation.Limits {
+		l := limits
+		switch userID {
+		case "user-2", "user-3":
+			l.QueryEngineMaxSamples = 10
+		case "user-4":
+			l.QueryEngineTimeout = time.Second
+		}
+		return &l
+	})
+	require.NoError(t, err)
+
+	engines := NewEngines(Config{MaxSamples: 1000, Timeout: time.Minute}, overrides, nil)
+
+	// The queries of the tenants without overrides are evaluated by the default engine.
+	assert.Same(t, engines.Default(), engines.ForTenants([]string{"user-1"}))
+
+	// The tenants with the same overrides share the same engine.
+	assert.NotSame(t, engines.Default(), engines.ForTenants([]string{"user-2"}))
+	assert.Same(t, engines.ForTenants([]string{"user-2"}), engines.ForTenants([]string{"user-3"}))
+	assert.Same(t, engines.ForTenants([]string{"user-2"}), engines.ForTenants([]string{"user-1", "user-2"}))
+	assert.NotSame(t, engines.ForTenants([]string{"user-2"}), engines.ForTenants([]string{"user-4"}))
+
+	// Multi-tenant queries get the smallest overrides.
+	assert.Equal(t, engineOptions{maxSamples: 10, timeout: time.Second}, engines.tenantsOptions([]string{"user-2", "user-4"}))
+}
+
+func TestEngines_TranslateError(t *testing.T) {
+	storage := teststorage.New(t)
+	defer storage.Close()
+
+	app := storage.Appender(context.Background())
+	for ts := int64(0); ts <= 120000; ts += 15000 {
+		_, err := app.Add(labels.FromStrings(labels.MetricName, "up"), ts, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+
+	limits := defaultLimitsConfig()
+	overrides, err := validation.NewOverrides(limits, func(userID string) *validation.Limits {
+		l := limits
+		if userID == "user-2" {
+			l.QueryEngineMaxSamples = 2
+		}
+		return &l
+	})
+	require.NoError(t, err)
+
+	reg := prometheus.NewPedanticRegistry()
+	engines := NewEngines(Config{MaxSamples: 1000, Timeout: time.Minute}, overrides, reg)
+
+	exec := func(userID string) error {
+		tenantIDs := []string{userID}
+		qry, err := engines.ForTenants(tenantIDs).NewRangeQuery(storage, "up", time.Unix(0, 0), time.Unix(120, 0), 15*time.Second)
+		require.NoError(t, err)
+		defer qry.Close()
+
+		return engines.TranslateError(tenantIDs, qry.Exec(context.Background()).Err)
+	}
+
+	// The query of the tenant without overrides succeeds.
+	require.NoError(t, exec("user-1"))
+
+	// The query of the tenant with a lower max samples fails with a limit error.
+	err = exec("user-2")
+	require.Error(t, err)
+	assert.IsType(t, validation.LimitError(""), err)
+	assert.Contains(t, err.Error(), "max samples limit (limit: 2 samples)")
+
+	// The errors not caused by the per-tenant overrides are returned as is.
+	assert.Equal(t, promql.ErrQueryTimeout("query timeout"), engines.TranslateError([]string{"user-1"}, promql.ErrQueryTimeout("query timeout")))
+	assert.Equal(t, promql.ErrTooManySamples("query execution"), engines.TranslateError([]string{"user-1"}, promql.ErrTooManySamples("query execution")))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_querier_engine_limits_exceeded_total Total number of queries which exceeded a per-tenant PromQL engine limit.
+		# TYPE cortex_querier_engine_limits_exceeded_total counter
+		cortex_querier_engine_limits_exceeded_total{limit="max_samples",user="user-2"} 1
+	`), "cortex_querier_engine_limits_exceeded_total"))
+}
@@ -2,6 +2,7 @@ package querier
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/cortexproject/cortex/pkg/util"
 )
@@ -24,9 +25,22 @@ type metadataResult struct {
 }
 
 // MetadataHandler returns metric metadata held by Cortex for a given tenant.
-// It is kept and returned as a set.
+// It is kept and returned as a set. Like in Prometheus, the optional "limit"
+// parameter limits the number of metrics returned, and the optional "metric"
+// parameter filters the metadata for the given metric only.
 func MetadataHandler(d Distributor) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := -1
+		if s := r.FormValue("limit"); s != "" {
+			var err error
+			if limit, err = strconv.Atoi(s); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				util.WriteJSONResponse(w, metadataResult{Status: statusError, Error: "limit must be a number"})
+				return
+			}
+		}
+		metric := r.FormValue("metric")
+
 		resp, err := d.MetricsMetadata(r.Context())
 		if err != nil {
 			w.WriteHeader(http.StatusBadRequest)
@@ -37,8 +51,16 @@ func MetadataHandler(d Distributor) http.Handler {
 		// Put all the elements of the pseudo-set into a map of slices for marshalling.
 		metrics := map[string][]metricMetadata{}
 		for _, m := range resp {
+			if metric != "" && m.Metric != metric {
+				continue
+			}
+
 			ms, ok := metrics[m.Metric]
 			if !ok {
+				if limit >= 0 && len(metrics) >= limit {
+					continue
+				}
+
 				// Most metrics will only hold 1 copy of the same metadata.
 				ms = make([]metricMetadata, 0, 1)
 				metrics[m.Metric] = ms
@@ -50,6 +50,66 @@ func TestMetadataHandler_Success(t *testing.T) {
 	require.JSONEq(t, expectedJSON, string(responseBody))
 }
 
+func TestMetadataHandler_LimitAndMetricParams(t *testing.T) {
+	d := &mockDistributor{}
+	d.On("MetricsMetadata", mock.Anything).Return(
+		[]scrape.MetricMetadata{
+			{Metric: "metric_1", Help: "First help", Type: "gauge", Unit: ""},
+			{Metric: "metric_2", Help: "Second help", Type: "counter", Unit: ""},
+			{Metric: "metric_1", Help: "First help changed", Type: "gauge", Unit: ""},
+		},
+		nil)
+
+	handler := MetadataHandler(d)
+
+	tests := map[string]struct {
+		query          string
+		expectedStatus int
+		expectedJSON   string
+	}{
+		"should limit the number of metrics returned": {
+			query:          "limit=1",
+			expectedStatus: http.StatusOK,
+			expectedJSON: `{"status": "success", "data": {"metric_1": [
+				{"help": "First help", "type": "gauge", "unit": ""},
+				{"help": "First help changed", "type": "gauge", "unit": ""}
+			]}}`,
+		},
+		"should return no metrics if the limit is 0": {
+			query:          "limit=0",
+			expectedStatus: http.StatusOK,
+			expectedJSON:   `{"status": "success"}`,
+		},
+		"should filter the metadata by metric": {
+			query:          "metric=metric_2",
+			expectedStatus: http.StatusOK,
+			expectedJSON: `{"status": "success", "data": {"metric_2": [
+				{"help": "Second help", "type": "counter", "unit": ""}
+			]}}`,
+		},
+		"should fail on invalid limit": {
+			query:          "limit=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedJSON:   `{"status": "error", "error": "limit must be a number"}`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			request, err := http.NewRequest("GET", "/metadata?"+testData.query, nil)
+			require.NoError(t, err)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			require.Equal(t, testData.expectedStatus, recorder.Result().StatusCode)
+			responseBody, err := ioutil.ReadAll(recorder.Result().Body)
+			require.NoError(t, err)
+			require.JSONEq(t, testData.expectedJSON, string(responseBody))
+		})
+	}
+}
+
 func TestMetadataHandler_Error(t *testing.T) {
 	d := &mockDistributor{}
 	d.On("MetricsMetadata", mock.Anything).Return([]scrape.MetricMetadata{}, fmt.Errorf("no user id"))
@@ -11,6 +11,7 @@ import (
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/promql"
@@ -20,6 +21,7 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/chunk"
 	"github.com/cortexproject/cortex/pkg/chunk/purger"
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
 	"github.com/cortexproject/cortex/pkg/querier/batch"
 	"github.com/cortexproject/cortex/pkg/querier/chunkstore"
 	"github.com/cortexproject/cortex/pkg/querier/iterators"
@@ -28,6 +30,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/limiter"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
 	"github.com/cortexproject/cortex/pkg/util/tls"
 	"github.com/cortexproject/cortex/pkg/util/validation"
@@ -63,19 +66,29 @@ type Config struct {
 	LookbackDelta time.Duration `yaml:"lookback_delta"`
 
 	// Blocks storage only.
-	StoreGatewayAddresses string           `yaml:"store_gateway_addresses"`
-	StoreGatewayClient    tls.ClientConfig `yaml:"store_gateway_client"`
+	StoreGatewayAddresses        string           `yaml:"store_gateway_addresses"`
+	StoreGatewayClient           tls.ClientConfig `yaml:"store_gateway_client"`
+	StoreGatewayBlocksBatchSize  int              `yaml:"store_gateway_blocks_batch_size"`
+	StoreGatewayPreferredZone    string           `yaml:"store_gateway_preferred_zone"`
+	StoreGatewayMaxFetchAttempts int              `yaml:"store_gateway_max_fetch_attempts"`
+
+	BlocksConsistencyCheckUploadGracePeriod time.Duration `yaml:"blocks_consistency_check_upload_grace_period"`
 
 	SecondStoreEngine        string       `yaml:"second_store_engine"`
 	UseSecondStoreBeforeTime flagext.Time `yaml:"use_second_store_before_time"`
 
 	ShuffleShardingIngestersLookbackPeriod time.Duration `yaml:"shuffle_sharding_ingesters_lookback_period"`
+
+	RemoteReadMaxBytesInFrame int `yaml:"remote_read_max_bytes_in_frame"`
 }
 
 var (
 	errBadLookbackConfigs                             = errors.New("bad settings, query_store_after >= query_ingesters_within which can result in queries not being sent")
 	errShuffleShardingLookbackLessThanQueryStoreAfter = errors.New("the shuffle-sharding lookback period should be greater or equal than the configured 'query store after'")
+	errInvalidStoreGatewayMaxFetchAttempts            = errors.New("the store-gateway max fetch attempts should be greater than 0")
+	errNegativeConsistencyCheckUploadGracePeriod      = errors.New("the blocks consistency check upload grace period should be greater or equal than 0")
 	errEmptyTimeRange                                 = errors.New("empty time range")
+	errInvalidRemoteReadMaxBytesInFrame               = errors.New("the remote read max bytes in frame should be greater than 0")
 )
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -94,10 +107,15 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.QueryStoreAfter, "querier.query-store-after", 0, "The time after which a metric should only be queried from storage and not just ingesters. 0 means all queries are sent to store. When running the blocks storage, if this option is enabled, the time range of the query sent to the store will be manipulated to ensure the query end is not more recent than 'now - query-store-after'.")
 	f.StringVar(&cfg.ActiveQueryTrackerDir, "querier.active-query-tracker-dir", "./active-query-tracker", "Active query tracker monitors active queries, and writes them to the file in given directory. If Cortex discovers any queries in this log during startup, it will log them to the log file. Setting to empty value disables active query tracker, which also disables -querier.max-concurrent option.")
 	f.StringVar(&cfg.StoreGatewayAddresses, "querier.store-gateway-addresses", "", "Comma separated list of store-gateway addresses in DNS Service Discovery format. This option should be set when using the blocks storage and the store-gateway sharding is disabled (when enabled, the store-gateway instances form a ring and addresses are picked from the ring).")
+	f.StringVar(&cfg.StoreGatewayPreferredZone, "querier.store-gateway-preferred-zone", "", "The availability zone of the store-gateways to query blocks from, when the store-gateway zone-awareness is enabled. Blocks are queried from another zone only if no store-gateway in the preferred zone holds them or the query to it failed. Typically set to the zone where the querier is running, to reduce inter-zone data transfer.")
+	f.IntVar(&cfg.StoreGatewayBlocksBatchSize, "querier.store-gateway-blocks-batch-size", 0, "Maximum number of blocks queried from store-gateways in a single batch. When > 0, the blocks matching a query are queried in batches of up to this size, with a limited number of batches queried concurrently, which bounds the number of blocks requested from store-gateways at the same time. It doesn't reduce the querier memory usage, since the series fetched from all the batches are kept in memory until the query completes. 0 means all blocks are queried in a single batch.")
+	f.IntVar(&cfg.StoreGatewayMaxFetchAttempts, "querier.store-gateway-max-fetch-attempts", 3, "Maximum number of attempts to fetch blocks from store-gateways. When a store-gateway fails or doesn't return some of the requested blocks, the querier retries fetching the blocks from the other store-gateways holding them (if any), backing off between retries after failures, until this number of attempts is reached. Must be greater than 0.")
+	f.DurationVar(&cfg.BlocksConsistencyCheckUploadGracePeriod, "querier.blocks-consistency-check-upload-grace-period", 0, "Blocks uploaded to the storage more recently than this period are excluded from the querier blocks consistency check, giving the store-gateways time to discover and load them. The other blocks not returned by the store-gateways are retried, and the query fails if they can't be queried. 0 means the period is computed automatically as the bucket store consistency delay plus 3 times the bucket store sync interval.")
 	f.DurationVar(&cfg.LookbackDelta, "querier.lookback-delta", 5*time.Minute, "Time since the last sample after which a time series is considered stale and ignored by expression evaluations.")
 	f.StringVar(&cfg.SecondStoreEngine, "querier.second-store-engine", "", "Second store engine to use for querying. Empty = disabled.")
 	f.Var(&cfg.UseSecondStoreBeforeTime, "querier.use-second-store-before-time", "If specified, second store is only used for queries before this timestamp. Default value 0 means secondary store is always queried.")
 	f.DurationVar(&cfg.ShuffleShardingIngestersLookbackPeriod, "querier.shuffle-sharding-ingesters-lookback-period", 0, "When distributor's sharding strategy is shuffle-sharding and this setting is > 0, queriers fetch in-memory series from the minimum set of required ingesters, selecting only ingesters which may have received series since 'now - lookback period'. The lookback period should be greater or equal than the configured 'query store after'. If this setting is 0, queriers always query all ingesters (ingesters shuffle sharding on read path is disabled).")
+	f.IntVar(&cfg.RemoteReadMaxBytesInFrame, "querier.remote-read-max-bytes-in-frame", 1048576, "Maximum number of bytes in a frame of the remote read responses streamed as chunks to the clients accepting the STREAMED_XOR_CHUNKS response type. Each frame contains the chunks of a single series, and a series is split over multiple frames if needed. The frames may exceed this limit by the size of a chunk.")
 }
 
 // Validate the config
@@ -115,6 +133,18 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if cfg.StoreGatewayMaxFetchAttempts < 1 {
+		return errInvalidStoreGatewayMaxFetchAttempts
+	}
+
+	if cfg.BlocksConsistencyCheckUploadGracePeriod < 0 {
+		return errNegativeConsistencyCheckUploadGracePeriod
+	}
+
+	if cfg.RemoteReadMaxBytesInFrame < 1 {
+		return errInvalidRemoteReadMaxBytesInFrame
+	}
+
 	return nil
 }
 
@@ -140,21 +170,22 @@ func NewChunkStoreQueryable(cfg Config, chunkStore chunkstore.ChunkStore) storag
 	return newChunkStoreQueryable(chunkStore, getChunksIteratorFunction(cfg))
 }
 
-// New builds a queryable and promql engine.
-func New(cfg Config, limits *validation.Overrides, distributor Distributor, stores []QueryableWithFilter, tombstonesLoader *purger.TombstonesLoader, reg prometheus.Registerer) (storage.SampleAndChunkQueryable, *promql.Engine) {
+// New builds a queryable and the promql engines.
+func New(cfg Config, limits *validation.Overrides, distributor Distributor, stores []QueryableWithFilter, tombstonesLoader *purger.TombstonesLoader, reg prometheus.Registerer) (storage.SampleAndChunkQueryable, *Engines) {
 	iteratorFunc := getChunksIteratorFunction(cfg)
 
-	distributorQueryable := newDistributorQueryable(distributor, cfg.IngesterStreaming, iteratorFunc, cfg.QueryIngestersWithin)
+	distributorQueryable := newDistributorQueryable(distributor, cfg.IngesterStreaming, iteratorFunc, cfg.QueryIngestersWithin, limits)
 
 	ns := make([]QueryableWithFilter, len(stores))
 	for ix, s := range stores {
 		ns[ix] = storeQueryable{
 			QueryableWithFilter: s,
 			QueryStoreAfter:     cfg.QueryStoreAfter,
+			limits:              limits,
 		}
 	}
 
-	queryable := NewQueryable(distributorQueryable, ns, iteratorFunc, cfg, limits, tombstonesLoader)
+	queryable := NewQueryable(distributorQueryable, ns, iteratorFunc, cfg, limits, tombstonesLoader, reg)
 
 	lazyQueryable := storage.QueryableFunc(func(ctx context.Context, mint int64, maxt int64) (storage.Querier, error) {
 		querier, err := queryable.Querier(ctx, mint, maxt)
@@ -164,18 +195,13 @@ func New(cfg Config, limits *validation.Overrides, distributor Distributor, stor
 		return lazyquery.NewLazyQuerier(querier), nil
 	})
 
-	engine := promql.NewEngine(promql.EngineOpts{
-		Logger:             util.Logger,
-		Reg:                reg,
-		ActiveQueryTracker: createActiveQueryTracker(cfg),
-		MaxSamples:         cfg.MaxSamples,
-		Timeout:            cfg.Timeout,
-		LookbackDelta:      cfg.LookbackDelta,
-		NoStepSubqueryIntervalFn: func(int64) int64 {
-			return cfg.DefaultEvaluationInterval.Milliseconds()
-		},
-	})
-	return &sampleAndChunkQueryable{lazyQueryable}, engine
+	return &sampleAndChunkQueryable{lazyQueryable}, NewEngines(cfg, limits, reg)
+}
+
+// NewSampleAndChunkQueryable creates a SampleAndChunkQueryable from a
+// Queryable with a ChunkQueryable stub, that errors once it gets called.
+func NewSampleAndChunkQueryable(q storage.Queryable) storage.SampleAndChunkQueryable {
+	return &sampleAndChunkQueryable{q}
 }
 
 type sampleAndChunkQueryable struct {
@@ -200,13 +226,18 @@ func createActiveQueryTracker(cfg Config) *promql.ActiveQueryTracker {
 type QueryableWithFilter interface {
 	storage.Queryable
 
-	// UseQueryable returns true if this queryable should be used to satisfy the query for given time range.
+	// UseQueryable returns true if this queryable should be used to satisfy the tenant's query for given time range.
 	// Query min and max time are in milliseconds since epoch.
-	UseQueryable(now time.Time, queryMinT, queryMaxT int64) bool
+	UseQueryable(now time.Time, userID string, queryMinT, queryMaxT int64) bool
 }
 
 // NewQueryable creates a new Queryable for cortex.
-func NewQueryable(distributor QueryableWithFilter, stores []QueryableWithFilter, chunkIterFn chunkIteratorFunc, cfg Config, limits *validation.Overrides, tombstonesLoader *purger.TombstonesLoader) storage.Queryable {
+func NewQueryable(distributor QueryableWithFilter, stores []QueryableWithFilter, chunkIterFn chunkIteratorFunc, cfg Config, limits *validation.Overrides, tombstonesLoader *purger.TombstonesLoader, reg prometheus.Registerer) storage.Queryable {
+	limitedQueries := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "cortex_querier_queries_limited_total",
+		Help: "Total number of queries which hit a per-query limit on the fetched series, chunks or chunk bytes.",
+	}, []string{"user", "reason"})
+
 	return storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 		now := time.Now()
 
@@ -222,6 +253,14 @@ func NewQueryable(distributor QueryableWithFilter, stores []QueryableWithFilter,
 			return nil, err
 		}
 
+		// The limiter is shared by all the queriers fetching series for this query.
+		ctx = limiter.AddQueryLimiterToContext(ctx, limiter.NewQueryLimiter(
+			limits.MaxFetchedSeriesPerQuery(userID),
+			limits.MaxFetchedChunkBytesPerQuery(userID),
+			limits.MaxFetchedChunksPerQuery(userID),
+			limitedQueries.MustCurryWith(prometheus.Labels{"user": userID}),
+		))
+
 		q := querier{
 			ctx:                 ctx,
 			mint:                mint,
@@ -240,12 +279,12 @@ func NewQueryable(distributor QueryableWithFilter, stores []QueryableWithFilter,
 
 		q.metadataQuerier = dqr
 
-		if distributor.UseQueryable(now, mint, maxt) {
+		if distributor.UseQueryable(now, userID, mint, maxt) {
 			q.queriers = append(q.queriers, dqr)
 		}
 
 		for _, s := range stores {
-			if !s.UseQueryable(now, mint, maxt) {
+			if !s.UseQueryable(now, userID, mint, maxt) {
 				continue
 			}
 
@@ -331,6 +370,13 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 		return storage.ErrSeriesSet(limitErr)
 	}
 
+	// The shard matcher is passed to the stores, which only select the series belonging to
+	// the query shard, while the shard label is added to the selected series here.
+	shard, _, err := astmapper.RemoveShardFromMatchers(matchers)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+
 	tombstones, err := q.tombstonesLoader.GetPendingTombstonesForInterval(userID, startTime, endTime)
 	if err != nil {
 		return storage.ErrSeriesSet(err)
@@ -342,6 +388,9 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 		if tombstones.Len() != 0 {
 			seriesSet = series.NewDeletedSeriesSet(seriesSet, tombstones, model.Interval{Start: startTime, End: endTime})
 		}
+		if shard != nil {
+			seriesSet = series.NewShardedSeriesSet(seriesSet, *shard)
+		}
 
 		return seriesSet
 	}
@@ -371,6 +420,9 @@ func (q querier) Select(_ bool, sp *storage.SelectHints, matchers ...*labels.Mat
 	if tombstones.Len() != 0 {
 		seriesSet = series.NewDeletedSeriesSet(seriesSet, tombstones, model.Interval{Start: startTime, End: endTime})
 	}
+	if shard != nil {
+		seriesSet = series.NewShardedSeriesSet(seriesSet, *shard)
+	}
 	return seriesSet
 }
 
@@ -538,21 +590,31 @@ func (s *sliceSeriesSet) Warnings() storage.Warnings {
 type storeQueryable struct {
 	QueryableWithFilter
 	QueryStoreAfter time.Duration
+
+	// Optional per-tenant overrides of QueryStoreAfter.
+	limits *validation.Overrides
 }
 
-func (s storeQueryable) UseQueryable(now time.Time, queryMinT, queryMaxT int64) bool {
+func (s storeQueryable) UseQueryable(now time.Time, userID string, queryMinT, queryMaxT int64) bool {
+	queryStoreAfter := s.QueryStoreAfter
+	if s.limits != nil {
+		if v := s.limits.QueryStoreAfter(userID); v > 0 {
+			queryStoreAfter = v
+		}
+	}
+
 	// Include this store only if mint is within QueryStoreAfter w.r.t current time.
-	if s.QueryStoreAfter != 0 && queryMinT > util.TimeToMillis(now.Add(-s.QueryStoreAfter)) {
+	if queryStoreAfter != 0 && queryMinT > util.TimeToMillis(now.Add(-queryStoreAfter)) {
 		return false
 	}
-	return s.QueryableWithFilter.UseQueryable(now, queryMinT, queryMaxT)
+	return s.QueryableWithFilter.UseQueryable(now, userID, queryMinT, queryMaxT)
 }
 
 type alwaysTrueFilterQueryable struct {
 	storage.Queryable
 }
 
-func (alwaysTrueFilterQueryable) UseQueryable(_ time.Time, _, _ int64) bool {
+func (alwaysTrueFilterQueryable) UseQueryable(_ time.Time, _ string, _, _ int64) bool {
 	return true
 }
 
@@ -566,7 +628,7 @@ type useBeforeTimestampQueryable struct {
 	ts int64 // Timestamp in milliseconds
 }
 
-func (u useBeforeTimestampQueryable) UseQueryable(_ time.Time, queryMinT, _ int64) bool {
+func (u useBeforeTimestampQueryable) UseQueryable(_ time.Time, _ string, queryMinT, _ int64) bool {
 	if u.ts == 0 {
 		return true
 	}
@@ -900,7 +900,7 @@ func TestUseAlwaysQueryable(t *testing.T) {
 	m := &mockQueryableWithFilter{}
 	qwf := UseAlwaysQueryable(m)
 
-	require.True(t, qwf.UseQueryable(time.Now(), 0, 0))
+	require.True(t, qwf.UseQueryable(time.Now(), "user-1", 0, 0))
 	require.False(t, m.useQueryableCalled)
 }
 
@@ -909,31 +909,51 @@ func TestUseBeforeTimestamp(t *testing.T) {
 	now := time.Now()
 	qwf := UseBeforeTimestampQueryable(m, now.Add(-1*time.Hour))
 
-	require.False(t, qwf.UseQueryable(now, util.TimeToMillis(now.Add(-5*time.Minute)), util.TimeToMillis(now)))
+	require.False(t, qwf.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-5*time.Minute)), util.TimeToMillis(now)))
 	require.False(t, m.useQueryableCalled)
 
-	require.False(t, qwf.UseQueryable(now, util.TimeToMillis(now.Add(-1*time.Hour)), util.TimeToMillis(now)))
+	require.False(t, qwf.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-1*time.Hour)), util.TimeToMillis(now)))
 	require.False(t, m.useQueryableCalled)
 
-	require.True(t, qwf.UseQueryable(now, util.TimeToMillis(now.Add(-1*time.Hour).Add(-time.Millisecond)), util.TimeToMillis(now)))
+	require.True(t, qwf.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-1*time.Hour).Add(-time.Millisecond)), util.TimeToMillis(now)))
 	require.False(t, m.useQueryableCalled) // UseBeforeTimestampQueryable wraps Queryable, and not QueryableWithFilter.
 }
 
 func TestStoreQueryable(t *testing.T) {
 	m := &mockQueryableWithFilter{}
 	now := time.Now()
-	sq := storeQueryable{m, time.Hour}
+	sq := storeQueryable{QueryableWithFilter: m, QueryStoreAfter: time.Hour}
 
-	require.False(t, sq.UseQueryable(now, util.TimeToMillis(now.Add(-5*time.Minute)), util.TimeToMillis(now)))
+	require.False(t, sq.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-5*time.Minute)), util.TimeToMillis(now)))
 	require.False(t, m.useQueryableCalled)
 
-	require.False(t, sq.UseQueryable(now, util.TimeToMillis(now.Add(-1*time.Hour).Add(time.Millisecond)), util.TimeToMillis(now)))
+	require.False(t, sq.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-1*time.Hour).Add(time.Millisecond)), util.TimeToMillis(now)))
 	require.False(t, m.useQueryableCalled)
 
-	require.True(t, sq.UseQueryable(now, util.TimeToMillis(now.Add(-1*time.Hour)), util.TimeToMillis(now)))
+	require.True(t, sq.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-1*time.Hour)), util.TimeToMillis(now)))
 	require.True(t, m.useQueryableCalled) // storeQueryable wraps QueryableWithFilter, so it must call its UseQueryable method.
 }
 
+func TestStoreQueryable_ShouldHonorTenantQueryStoreAfter(t *testing.T) {
+	tenantLimits := defaultLimitsConfig()
+	tenantLimits.QueryStoreAfter = 10 * time.Minute
+
+	overrides, err := validation.NewOverrides(defaultLimitsConfig(), func(userID string) *validation.Limits {
+		if userID == "user-1" {
+			return &tenantLimits
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	now := time.Now()
+	sq := storeQueryable{QueryableWithFilter: &mockQueryableWithFilter{}, QueryStoreAfter: time.Hour, limits: overrides}
+
+	// The tenant with overridden limits queries the store for more recent time ranges.
+	require.True(t, sq.UseQueryable(now, "user-1", util.TimeToMillis(now.Add(-30*time.Minute)), util.TimeToMillis(now)))
+	require.False(t, sq.UseQueryable(now, "user-2", util.TimeToMillis(now.Add(-30*time.Minute)), util.TimeToMillis(now)))
+}
+
 func TestConfig_Validate(t *testing.T) {
 	tests := map[string]struct {
 		setup    func(cfg *Config)
@@ -960,6 +980,24 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expected: errShuffleShardingLookbackLessThanQueryStoreAfter,
 		},
+		"should fail if the store-gateway max fetch attempts is 0": {
+			setup: func(cfg *Config) {
+				cfg.StoreGatewayMaxFetchAttempts = 0
+			},
+			expected: errInvalidStoreGatewayMaxFetchAttempts,
+		},
+		"should fail if the blocks consistency check upload grace period is negative": {
+			setup: func(cfg *Config) {
+				cfg.BlocksConsistencyCheckUploadGracePeriod = -time.Minute
+			},
+			expected: errNegativeConsistencyCheckUploadGracePeriod,
+		},
+		"should fail if the remote read max bytes in frame is 0": {
+			setup: func(cfg *Config) {
+				cfg.RemoteReadMaxBytesInFrame = 0
+			},
+			expected: errInvalidRemoteReadMaxBytesInFrame,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -981,7 +1019,7 @@ func (m *mockQueryableWithFilter) Querier(_ context.Context, _, _ int64) (storag
 	return nil, nil
 }
 
-func (m *mockQueryableWithFilter) UseQueryable(_ time.Time, _, _ int64) bool {
+func (m *mockQueryableWithFilter) UseQueryable(_ time.Time, _ string, _, _ int64) bool {
 	m.useQueryableCalled = true
 	return true
 }
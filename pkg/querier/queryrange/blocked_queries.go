@@ -0,0 +1,104 @@
+package queryrange
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// queryBlocker rejects the queries matching the blocked queries configured for the tenant.
+type queryBlocker struct {
+	limits Limits
+	logger log.Logger
+
+	blockedQueries *prometheus.CounterVec
+}
+
+func newQueryBlocker(limits Limits, logger log.Logger, reg prometheus.Registerer) *queryBlocker {
+	return &queryBlocker{
+		limits: limits,
+		logger: logger,
+		blockedQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_blocked_queries_total",
+			Help: "Total number of queries rejected because they match a blocked query configured for the tenant.",
+		}, []string{"user"}),
+	}
+}
+
+// check returns an error if the input request is a query blocked for any of the tenants.
+func (b *queryBlocker) check(r *http.Request, tenantIDs []string) error {
+	anyBlocked := false
+	for _, tenantID := range tenantIDs {
+		anyBlocked = anyBlocked || len(b.limits.BlockedQueries(tenantID)) > 0
+	}
+	if !anyBlocked {
+		return nil
+	}
+
+	params, err := parseRequestParams(r)
+	if err != nil {
+		return err
+	}
+
+	query := strings.TrimSpace(params.Get("query"))
+	if query == "" {
+		return nil
+	}
+
+	// Instant queries have a time range of zero.
+	var timeRange time.Duration
+	if strings.HasSuffix(r.URL.Path, "/query_range") {
+		start, startErr := util.ParseTime(params.Get("start"))
+		end, endErr := util.ParseTime(params.Get("end"))
+		if startErr == nil && endErr == nil && end > start {
+			timeRange = time.Duration(end-start) * time.Millisecond
+		}
+	}
+
+	for _, tenantID := range tenantIDs {
+		for _, q := range b.limits.BlockedQueries(tenantID) {
+			if !q.Matches(query, timeRange) {
+				continue
+			}
+
+			b.blockedQueries.WithLabelValues(tenantID).Inc()
+			level.Info(util.WithContext(r.Context(), b.logger)).Log("msg", "query blocked", "query", query, "time_range", timeRange, "pattern", q.Pattern, "regex", q.Regex)
+
+			return httpgrpc.Errorf(http.StatusForbidden, "the query is blocked by the blocked queries configured for the tenant (pattern: %q)", q.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// parseRequestParams returns the query and form params of the request, preserving
+// the request body so that the request can be forwarded downstream.
+func parseRequestParams(r *http.Request) (url.Values, error) {
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	req := r.Clone(r.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := req.ParseForm(); err != nil {
+		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
+	}
+
+	return req.Form, nil
+}
@@ -0,0 +1,147 @@
+package queryrange
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestQueryBlocker_Check(t *testing.T) {
+	blocked := []*validation.BlockedQuery{
+		{Pattern: `sum(rate(http_requests_total[5m]))`},
+		{Pattern: `.*expensive_metric.*`, Regex: true},
+		{Pattern: `up`, MinTimeRange: 7 * 24 * time.Hour},
+	}
+
+	tests := map[string]struct {
+		method          string
+		path            string
+		params          url.Values
+		expectedBlocked bool
+	}{
+		"should block an instant query matching exactly": {
+			method:          http.MethodGet,
+			path:            "/api/v1/query",
+			params:          url.Values{"query": []string{"sum(rate(http_requests_total[5m]))"}},
+			expectedBlocked: true,
+		},
+		"should block a POST range query matching exactly": {
+			method:          http.MethodPost,
+			path:            "/api/v1/query_range",
+			params:          url.Values{"query": []string{"sum(rate(http_requests_total[5m]))"}, "start": []string{"0"}, "end": []string{"3600"}, "step": []string{"60"}},
+			expectedBlocked: true,
+		},
+		"should not block a query not matching exactly": {
+			method: http.MethodGet,
+			path:   "/api/v1/query",
+			params: url.Values{"query": []string{"sum(rate(http_requests_total[1m]))"}},
+		},
+		"should block a query matching the regex": {
+			method:          http.MethodGet,
+			path:            "/api/v1/query",
+			params:          url.Values{"query": []string{"count(expensive_metric)"}},
+			expectedBlocked: true,
+		},
+		"should block a range query whose time range is longer than the min time range": {
+			method:          http.MethodGet,
+			path:            "/api/v1/query_range",
+			params:          url.Values{"query": []string{"up"}, "start": []string{"0"}, "end": []string{"864000"}, "step": []string{"60"}},
+			expectedBlocked: true,
+		},
+		"should not block a range query whose time range is shorter than the min time range": {
+			method: http.MethodGet,
+			path:   "/api/v1/query_range",
+			params: url.Values{"query": []string{"up"}, "start": []string{"0"}, "end": []string{"3600"}, "step": []string{"60"}},
+		},
+		"should not block an instant query if the blocked query has a min time range": {
+			method: http.MethodGet,
+			path:   "/api/v1/query",
+			params: url.Values{"query": []string{"up"}},
+		},
+		"should not block requests without a query": {
+			method: http.MethodGet,
+			path:   "/api/v1/labels",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			reg := prometheus.NewPedanticRegistry()
+			blocker := newQueryBlocker(mockLimits{blockedQueries: blocked}, log.NewNopLogger(), reg)
+
+			req := blockedQueriesTestRequest(t, testData.method, testData.path, testData.params)
+			err := blocker.check(req, []string{"user-1"})
+
+			if !testData.expectedBlocked {
+				require.NoError(t, err)
+				assert.Equal(t, float64(0), testutil.ToFloat64(blocker.blockedQueries.WithLabelValues("user-1")))
+			} else {
+				require.Error(t, err)
+				resp, ok := httpgrpc.HTTPResponseFromError(err)
+				require.True(t, ok)
+				assert.Equal(t, int32(http.StatusForbidden), resp.Code)
+				assert.Equal(t, float64(1), testutil.ToFloat64(blocker.blockedQueries.WithLabelValues("user-1")))
+			}
+
+			// The request body should be preserved, so that it can be forwarded downstream.
+			if testData.method == http.MethodPost {
+				body, err := ioutil.ReadAll(req.Body)
+				require.NoError(t, err)
+				assert.Equal(t, testData.params.Encode(), string(body))
+			}
+		})
+	}
+}
+
+func TestQueryBlocker_CheckWithoutBlockedQueries(t *testing.T) {
+	blocker := newQueryBlocker(mockLimits{}, log.NewNopLogger(), nil)
+
+	req := blockedQueriesTestRequest(t, http.MethodGet, "/api/v1/query", url.Values{"query": []string{"up"}})
+	require.NoError(t, blocker.check(req, []string{"user-1"}))
+}
+
+func TestQueryBlocker_CheckMultipleTenants(t *testing.T) {
+	limits := multiTenantMockLimits{
+		"user-1": {},
+		"user-2": {blockedQueries: []*validation.BlockedQuery{{Pattern: "up"}}},
+	}
+	blocker := newQueryBlocker(limits, log.NewNopLogger(), nil)
+
+	req := blockedQueriesTestRequest(t, http.MethodGet, "/api/v1/query", url.Values{"query": []string{"up"}})
+	require.NoError(t, blocker.check(req, []string{"user-1"}))
+
+	err := blocker.check(req, []string{"user-1", "user-2"})
+	require.Error(t, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(blocker.blockedQueries.WithLabelValues("user-2")))
+}
+
+func blockedQueriesTestRequest(t *testing.T, method, path string, params url.Values) *http.Request {
+	var (
+		req *http.Request
+		err error
+	)
+
+	if method == http.MethodPost {
+		req, err = http.NewRequest(method, path, strings.NewReader(params.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequest(method, path+"?"+params.Encode(), http.NoBody)
+		require.NoError(t, err)
+	}
+
+	return req.WithContext(context.Background())
+}
@@ -0,0 +1,191 @@
+package queryrange
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+// failedQueriesCache is a round tripper caching the failures of the queries which are
+// deterministic, like invalid queries or queries exceeding a limit, so that the same
+// query issued again within the TTL (e.g. by a dashboard refreshed often) fails fast
+// without being executed by the queriers.
+type failedQueriesCache struct {
+	logger               log.Logger
+	next                 http.RoundTripper
+	cache                cache.Cache
+	ttl                  time.Duration
+	cacheGenNumberLoader CacheGenNumberLoader
+
+	// Metrics.
+	hits prometheus.Counter
+}
+
+// NewFailedQueriesCacheTripperware returns a Tripperware caching the deterministic
+// failures of the queries into the input cache for the given TTL.
+func NewFailedQueriesCacheTripperware(c cache.Cache, ttl time.Duration, cacheGenNumberLoader CacheGenNumberLoader, logger log.Logger, reg prometheus.Registerer) Tripperware {
+	hits := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_failed_queries_cache_hits_total",
+		Help: "Total number of queries failed with the error cached in the results cache.",
+	})
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &failedQueriesCache{
+			logger:               logger,
+			next:                 next,
+			cache:                c,
+			ttl:                  ttl,
+			cacheGenNumberLoader: cacheGenNumberLoader,
+			hits:                 hits,
+		}
+	}
+}
+
+func (c *failedQueriesCache) RoundTrip(r *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(r.URL.Path, "/query") && !strings.HasSuffix(r.URL.Path, "/query_range") {
+		return c.next.RoundTrip(r)
+	}
+
+	for _, value := range r.Header.Values(cacheControlHeader) {
+		if strings.Contains(value, noStoreValue) {
+			return c.next.RoundTrip(r)
+		}
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// The request body is read to build the cache key, and restored for the next round tripper.
+	var body []byte
+	if r.Body != nil {
+		if body, err = ioutil.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		_ = r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	ctx := r.Context()
+	if c.cacheGenNumberLoader != nil {
+		ctx = cache.InjectCacheGenNumber(ctx, c.cacheGenNumberLoader.GetResultsCacheGenNumber(tenantIDs))
+	}
+
+	key := cache.HashKey(fmt.Sprintf("failed:%s:%s:%s:%s", tenant.JoinTenantIDs(tenantIDs), r.URL.Path, r.URL.RawQuery, body))
+	now := time.Now()
+
+	if found, bufs, _ := c.cache.Fetch(ctx, []string{key}); len(found) == 1 {
+		if code, errBody, ok := decodeFailedQueryCacheEntry(bufs[0], now); ok {
+			c.hits.Inc()
+			return nil, httpgrpc.ErrorFromHTTPResponse(&httpgrpc.HTTPResponse{Code: code, Body: errBody})
+		}
+	}
+
+	resp, err := c.next.RoundTrip(r)
+
+	var (
+		code    int32
+		errBody []byte
+	)
+	switch {
+	case err != nil:
+		errResp, ok := httpgrpc.HTTPResponseFromError(err)
+		if !ok || !isDeterministicFailure(errResp.Code, errResp.Body) {
+			return resp, err
+		}
+		code, errBody = errResp.Code, errResp.Body
+
+	case isFailureStatusCode(int32(resp.StatusCode)) && resp.Header.Get("Content-Encoding") == "":
+		if errBody, err = ioutil.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+		_ = resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(errBody))
+		code = int32(resp.StatusCode)
+
+		if !isDeterministicFailure(code, errBody) {
+			return resp, nil
+		}
+
+	default:
+		return resp, err
+	}
+
+	c.cache.Store(ctx, []string{key}, [][]byte{encodeFailedQueryCacheEntry(code, errBody, now.Add(c.ttl))})
+	level.Debug(c.logger).Log("msg", "cached failed query", "status_code", code)
+
+	return resp, err
+}
+
+// deterministicExecutionFailures are the messages of the query execution failures which are
+// known to fail again if retried, like the queries exceeding a limit.
+var deterministicExecutionFailures = []string{
+	"the query hit the ", // The query limiter and the max chunks per query limit.
+	"the query loaded more samples than the max samples limit",
+	"query processing would load too many samples into memory",
+	"the query time range exceeds the limit",
+}
+
+// isFailureStatusCode returns whether the status code is the one of a query failure which
+// may be cached, if deterministic.
+func isFailureStatusCode(code int32) bool {
+	return code == http.StatusBadRequest || code == http.StatusUnprocessableEntity
+}
+
+// isDeterministicFailure returns whether a query failed with the given status code and body
+// would fail again if retried: invalid queries (400) and the query execution failures (422)
+// explicitly recognised as deterministic. Any other execution failure (e.g. a canceled query),
+// rate limited queries (429) and server errors may succeed if retried, so they're not cached.
+func isDeterministicFailure(code int32, body []byte) bool {
+	switch code {
+	case http.StatusBadRequest:
+		return true
+	case http.StatusUnprocessableEntity:
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &apiErr); err != nil {
+			return false
+		}
+		for _, msg := range deterministicExecutionFailures {
+			if strings.Contains(apiErr.Error, msg) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// encodeFailedQueryCacheEntry encodes the status code and the body of the failure, prefixed
+// by its expiration time, like the instant queries cache entries.
+func encodeFailedQueryCacheEntry(code int32, body []byte, expires time.Time) []byte {
+	buf := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(code))
+	copy(buf[4:], body)
+	return encodeInstantQueryCacheEntry(buf, expires)
+}
+
+func decodeFailedQueryCacheEntry(buf []byte, now time.Time) (int32, []byte, bool) {
+	buf, ok := decodeInstantQueryCacheEntry(buf, now)
+	if !ok || len(buf) < 4 {
+		return 0, nil, false
+	}
+
+	return int32(binary.BigEndian.Uint32(buf)), buf[4:], true
+}
@@ -0,0 +1,192 @@
+package queryrange
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+func TestFailedQueriesCache(t *testing.T) {
+	const body = `{"status":"error","errorType":"execution","error":"query processing would load too many samples into memory"}`
+
+	tests := map[string]struct {
+		requests           []*http.Request
+		ttl                time.Duration
+		downstreamStatus   int
+		downstreamErr      bool
+		downstreamBody     string
+		expectedDownstream int
+		expectedHits       float64
+	}{
+		"should cache a query failed with a 422 response": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			expectedDownstream: 1,
+			expectedHits:       1,
+		},
+		"should cache a query failed with a 400 error": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusBadRequest,
+			downstreamErr:      true,
+			expectedDownstream: 1,
+			expectedHits:       1,
+		},
+		"should cache POST requests": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodPost, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodPost, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			expectedDownstream: 1,
+			expectedHits:       1,
+		},
+		"should not serve the cached failure of a different query": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "2000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			expectedDownstream: 2,
+		},
+		"should not cache server errors": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusInternalServerError,
+			downstreamErr:      true,
+			expectedDownstream: 2,
+		},
+		"should not cache a query failed with an unrecognised 422 response": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			downstreamBody:     `{"status":"error","errorType":"execution","error":"context canceled"}`,
+			expectedDownstream: 2,
+		},
+		"should not cache a query failed with an unrecognised 422 error": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			downstreamErr:      true,
+			downstreamBody:     `{"status":"error","errorType":"execution","error":"consistency check failed because some blocks were not queried"}`,
+			expectedDownstream: 2,
+		},
+		"should cache a query failed with the max chunks per query limit": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			downstreamBody:     `{"status":"error","errorType":"execution","error":"the query hit the max number of chunks limit (limit: 10 chunks)"}`,
+			expectedDownstream: 1,
+			expectedHits:       1,
+		},
+		"should not cache rate limited queries": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusTooManyRequests,
+			expectedDownstream: 2,
+		},
+		"should not serve expired entries": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                0,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			expectedDownstream: 2,
+		},
+		"should bypass the cache if caching is disabled by the request": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", http.Header{cacheControlHeader: []string{noStoreValue}}),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusUnprocessableEntity,
+			expectedDownstream: 2,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			body := body
+			if testData.downstreamBody != "" {
+				body = testData.downstreamBody
+			}
+
+			downstream := 0
+			next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				downstream++
+
+				// The request body must be preserved.
+				require.NoError(t, r.ParseForm())
+				require.Equal(t, "up", r.Form.Get("query"))
+
+				if testData.downstreamErr {
+					return nil, httpgrpc.Errorf(testData.downstreamStatus, body)
+				}
+				return &http.Response{
+					StatusCode: testData.downstreamStatus,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			})
+
+			c := cache.NewFifoCache("test", cache.FifoCacheConfig{MaxSizeItems: 10}, nil, log.NewNopLogger())
+			rt := NewFailedQueriesCacheTripperware(c, testData.ttl, nil, log.NewNopLogger(), nil)(next)
+
+			for _, req := range testData.requests {
+				resp, err := rt.RoundTrip(req)
+
+				// The cached failures are returned as errors.
+				if err != nil {
+					errResp, ok := httpgrpc.HTTPResponseFromError(err)
+					require.True(t, ok)
+					assert.Equal(t, int32(testData.downstreamStatus), errResp.Code)
+					assert.Equal(t, body, string(errResp.Body))
+					continue
+				}
+
+				assert.Equal(t, testData.downstreamStatus, resp.StatusCode)
+				actual, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(actual))
+			}
+
+			assert.Equal(t, testData.expectedDownstream, downstream)
+			assert.Equal(t, testData.expectedHits, testutil.ToFloat64(rt.(*failedQueriesCache).hits))
+		})
+	}
+}
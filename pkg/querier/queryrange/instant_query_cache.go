@@ -0,0 +1,180 @@
+package queryrange
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// instantQueryCache is a round tripper caching the responses of instant queries. The
+// evaluation timestamp of each query is aligned to the configured interval, so that the
+// same query issued multiple times within the same interval is served from the cache.
+type instantQueryCache struct {
+	logger               log.Logger
+	next                 http.RoundTripper
+	cache                cache.Cache
+	alignment            time.Duration
+	ttl                  time.Duration
+	cacheGenNumberLoader CacheGenNumberLoader
+
+	// Metrics.
+	requests prometheus.Counter
+	hits     prometheus.Counter
+	misses   prometheus.Counter
+}
+
+// NewInstantQueryCacheTripperware returns a Tripperware caching the instant queries
+// responses into the input cache for the given TTL.
+func NewInstantQueryCacheTripperware(c cache.Cache, alignment, ttl time.Duration, cacheGenNumberLoader CacheGenNumberLoader, logger log.Logger, reg prometheus.Registerer) Tripperware {
+	requests := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_instant_queries_cache_requests_total",
+		Help: "Total number of instant queries looked up in the results cache.",
+	})
+	hits := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_instant_queries_cache_hits_total",
+		Help: "Total number of instant queries served from the results cache.",
+	})
+	misses := promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name: "cortex_query_frontend_instant_queries_cache_misses_total",
+		Help: "Total number of instant queries not found in the results cache, or found expired.",
+	})
+
+	return func(next http.RoundTripper) http.RoundTripper {
+		return &instantQueryCache{
+			logger:               logger,
+			next:                 next,
+			cache:                c,
+			alignment:            alignment,
+			ttl:                  ttl,
+			cacheGenNumberLoader: cacheGenNumberLoader,
+			requests:             requests,
+			hits:                 hits,
+			misses:               misses,
+		}
+	}
+}
+
+func (c *instantQueryCache) RoundTrip(r *http.Request) (*http.Response, error) {
+	for _, value := range r.Header.Values(cacheControlHeader) {
+		if strings.Contains(value, noStoreValue) {
+			return c.next.RoundTrip(r)
+		}
+	}
+
+	tenantIDs, err := tenant.TenantIDs(r.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	// Invalid requests are forwarded as is, so that the querier returns the error.
+	if err := r.ParseForm(); err != nil {
+		return c.next.RoundTrip(r)
+	}
+
+	params := r.Form
+	ts := util.TimeToMillis(time.Now())
+	if value := params.Get("time"); value != "" {
+		if ts, err = util.ParseTime(value); err != nil {
+			return c.next.RoundTrip(r)
+		}
+	}
+
+	alignment := c.alignment.Milliseconds()
+	ts -= ts % alignment
+	params.Set("time", encodeTime(ts))
+
+	ctx := r.Context()
+	if c.cacheGenNumberLoader != nil {
+		ctx = cache.InjectCacheGenNumber(ctx, c.cacheGenNumberLoader.GetResultsCacheGenNumber(tenantIDs))
+	}
+
+	// The key is hashed, because the query may be longer than the max key length supported
+	// by the cache backend or contain characters not allowed in a key (e.g. spaces).
+	key := cache.HashKey(fmt.Sprintf("instant:%s:%s:%d", tenant.JoinTenantIDs(tenantIDs), params.Get("query"), ts))
+	now := time.Now()
+
+	c.requests.Inc()
+	if found, bufs, _ := c.cache.Fetch(ctx, []string{key}); len(found) == 1 {
+		if body, ok := decodeInstantQueryCacheEntry(bufs[0], now); ok {
+			c.hits.Inc()
+			return instantQueryResponse(body), nil
+		}
+	}
+	c.misses.Inc()
+
+	// The request is always forwarded as a GET request with the aligned timestamp, like
+	// the range queries, and without compression so that the response can be cached.
+	u := *r.URL
+	u.RawQuery = params.Encode()
+
+	req := r.WithContext(r.Context())
+	req.URL = &u
+	req.Method = http.MethodGet
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	req.Header = r.Header.Clone()
+	req.Header.Del("Content-Type")
+	req.Header.Del("Accept-Encoding")
+
+	resp, err := c.next.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Encoding") != "" {
+		return resp, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	c.cache.Store(ctx, []string{key}, [][]byte{encodeInstantQueryCacheEntry(body, now.Add(c.ttl))})
+	level.Debug(c.logger).Log("msg", "cached instant query response", "key", key)
+
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// encodeInstantQueryCacheEntry encodes the response body prefixed by its expiration
+// time, because the TTL of the cache backend is shared with the range queries results.
+func encodeInstantQueryCacheEntry(body []byte, expires time.Time) []byte {
+	buf := make([]byte, 8+len(body))
+	binary.BigEndian.PutUint64(buf, uint64(util.TimeToMillis(expires)))
+	copy(buf[8:], body)
+	return buf
+}
+
+func decodeInstantQueryCacheEntry(buf []byte, now time.Time) ([]byte, bool) {
+	if len(buf) < 8 {
+		return nil, false
+	}
+
+	if expires := int64(binary.BigEndian.Uint64(buf)); expires <= util.TimeToMillis(now) {
+		return nil, false
+	}
+
+	return buf[8:], true
+}
+
+func instantQueryResponse(body []byte) *http.Response {
+	return &http.Response{
+		Header: http.Header{
+			"Content-Type": []string{"application/json"},
+		},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		StatusCode:    http.StatusOK,
+	}
+}
@@ -0,0 +1,209 @@
+package queryrange
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+func TestInstantQueryCache(t *testing.T) {
+	const body = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+	tests := map[string]struct {
+		requests           []*http.Request
+		ttl                time.Duration
+		downstreamStatus   int
+		expectedDownstream []string
+		expectedHits       float64
+	}{
+		"should serve from the cache the same query within the same aligned interval": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1019.5", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusOK,
+			expectedDownstream: []string{"query=up&time=960"},
+			expectedHits:       1,
+		},
+		"should not serve from the cache the same query in a different aligned interval": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1020", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusOK,
+			expectedDownstream: []string{"query=up&time=960", "query=up&time=1020"},
+		},
+		"should not serve from the cache a different query": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "down", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusOK,
+			expectedDownstream: []string{"query=up&time=960", "query=down&time=960"},
+		},
+		"should cache POST requests": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodPost, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusOK,
+			expectedDownstream: []string{"query=up&time=960"},
+			expectedHits:       1,
+		},
+		"should not serve expired entries": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                0,
+			downstreamStatus:   http.StatusOK,
+			expectedDownstream: []string{"query=up&time=960", "query=up&time=960"},
+		},
+		"should not cache failed requests": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusInternalServerError,
+			expectedDownstream: []string{"query=up&time=960", "query=up&time=960"},
+		},
+		"should bypass the cache if caching is disabled by the request": {
+			requests: []*http.Request{
+				instantQueryRequest(t, http.MethodGet, "up", "1000", nil),
+				instantQueryRequest(t, http.MethodGet, "up", "1000", http.Header{cacheControlHeader: []string{noStoreValue}}),
+			},
+			ttl:                time.Minute,
+			downstreamStatus:   http.StatusOK,
+			expectedDownstream: []string{"query=up&time=960", "query=up&time=1000"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var downstream []string
+			next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				downstream = append(downstream, r.URL.RawQuery)
+				return &http.Response{
+					StatusCode: testData.downstreamStatus,
+					Header:     http.Header{"Content-Type": []string{"application/json"}},
+					Body:       ioutil.NopCloser(strings.NewReader(body)),
+				}, nil
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			c := cache.NewFifoCache("test", cache.FifoCacheConfig{MaxSizeItems: 10}, nil, log.NewNopLogger())
+			rt := NewInstantQueryCacheTripperware(c, time.Minute, testData.ttl, nil, log.NewNopLogger(), reg)(next)
+
+			for _, req := range testData.requests {
+				resp, err := rt.RoundTrip(req)
+				require.NoError(t, err)
+				assert.Equal(t, testData.downstreamStatus, resp.StatusCode)
+
+				actual, err := ioutil.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, body, string(actual))
+			}
+
+			assert.Equal(t, testData.expectedDownstream, downstream)
+			assert.Equal(t, testData.expectedHits, testutil.ToFloat64(rt.(*instantQueryCache).hits))
+			assert.Equal(t, testutil.ToFloat64(rt.(*instantQueryCache).requests)-testData.expectedHits, testutil.ToFloat64(rt.(*instantQueryCache).misses))
+		})
+	}
+}
+
+func TestInstantQueryCache_ShouldHashTheCacheKey(t *testing.T) {
+	next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	c := &keysRecorderCache{Cache: cache.NewFifoCache("test", cache.FifoCacheConfig{MaxSizeItems: 10}, nil, log.NewNopLogger())}
+	rt := NewInstantQueryCacheTripperware(c, time.Minute, time.Minute, nil, log.NewNopLogger(), nil)(next)
+
+	query := `sum by (namespace) (rate(http_requests_total{job="api", status=~"5.."}[5m])) / ` + strings.Repeat("x", 300)
+	_, err := rt.RoundTrip(instantQueryRequest(t, http.MethodGet, query, "1000", nil))
+	require.NoError(t, err)
+
+	// The same key is used to fetch and store the response, and it's valid for memcached.
+	require.Len(t, c.keys, 2)
+	assert.Equal(t, c.keys[0], c.keys[1])
+	assert.LessOrEqual(t, len(c.keys[0]), 250)
+	assert.NotContains(t, c.keys[0], " ")
+}
+
+// keysRecorderCache is a cache.Cache recording the keys fetched and stored.
+type keysRecorderCache struct {
+	cache.Cache
+	keys []string
+}
+
+func (c *keysRecorderCache) Fetch(ctx context.Context, keys []string) ([]string, [][]byte, []string) {
+	c.keys = append(c.keys, keys...)
+	return c.Cache.Fetch(ctx, keys)
+}
+
+func (c *keysRecorderCache) Store(ctx context.Context, keys []string, bufs [][]byte) {
+	c.keys = append(c.keys, keys...)
+	c.Cache.Store(ctx, keys, bufs)
+}
+
+func TestInstantQueryCache_ShouldAlignRequestsWithoutTime(t *testing.T) {
+	var downstream []string
+	next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		downstream = append(downstream, r.URL.Query().Get("time"))
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{}"))}, nil
+	})
+
+	c := cache.NewFifoCache("test", cache.FifoCacheConfig{MaxSizeItems: 10}, nil, log.NewNopLogger())
+	rt := NewInstantQueryCacheTripperware(c, time.Hour, time.Minute, nil, log.NewNopLogger(), nil)(next)
+
+	_, err := rt.RoundTrip(instantQueryRequest(t, http.MethodGet, "up", "", nil))
+	require.NoError(t, err)
+
+	require.Len(t, downstream, 1)
+	assert.Equal(t, encodeTime(time.Now().Truncate(time.Hour).UnixNano()/int64(time.Millisecond)), downstream[0])
+}
+
+func instantQueryRequest(t *testing.T, method, query, ts string, header http.Header) *http.Request {
+	params := url.Values{"query": []string{query}}
+	if ts != "" {
+		params.Set("time", ts)
+	}
+
+	var req *http.Request
+	var err error
+	if method == http.MethodPost {
+		req, err = http.NewRequest(method, "/api/v1/query", bytes.NewReader([]byte(params.Encode())))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		req, err = http.NewRequest(method, "/api/v1/query?"+params.Encode(), http.NoBody)
+		require.NoError(t, err)
+	}
+
+	for name, values := range header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	return req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+}
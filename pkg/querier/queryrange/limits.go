@@ -31,6 +31,17 @@ type Limits interface {
 	// MaxCacheFreshness returns the period after which results are cacheable,
 	// to prevent caching of very recent results.
 	MaxCacheFreshness(string) time.Duration
+
+	// BlockedQueries returns the queries blocked for the tenant.
+	BlockedQueries(userID string) []*validation.BlockedQuery
+
+	// SplitQueriesByInterval returns the interval to split the tenant's queries by,
+	// or 0 to use the query-frontend config.
+	SplitQueriesByInterval(userID string) time.Duration
+
+	// SplitQueriesByTimeRange returns the intervals to split the tenant's queries by,
+	// depending on their time range.
+	SplitQueriesByTimeRange(userID string) []*validation.SplitQueriesInterval
 }
 
 type limitsMiddleware struct {
@@ -52,13 +63,13 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 	log, ctx := spanlogger.New(ctx, "limits")
 	defer log.Finish()
 
-	userID, err := tenant.TenantID(ctx)
+	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
 		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
 	}
 
 	// Clamp the time range based on the max query lookback.
-	if maxQueryLookback := l.MaxQueryLookback(userID); maxQueryLookback > 0 {
+	if maxQueryLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryLookback); maxQueryLookback > 0 {
 		minStartTime := util.TimeToMillis(time.Now().Add(-maxQueryLookback))
 
 		if r.GetEnd() < minStartTime {
@@ -85,7 +96,7 @@ func (l limitsMiddleware) Do(ctx context.Context, r Request) (Response, error) {
 	}
 
 	// Enforce the max query length.
-	if maxQueryLength := l.MaxQueryLength(userID); maxQueryLength > 0 {
+	if maxQueryLength := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, l.MaxQueryLength); maxQueryLength > 0 {
 		queryLen := timestamp.Time(r.GetEnd()).Sub(timestamp.Time(r.GetStart()))
 		if queryLen > maxQueryLength {
 			return nil, httpgrpc.Errorf(http.StatusBadRequest, validation.ErrQueryTooLong, queryLen, maxQueryLength)
@@ -10,7 +10,9 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/user"
 
+	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 func TestLimitsMiddleware_MaxQueryLookback(t *testing.T) {
@@ -186,10 +188,60 @@ func TestLimitsMiddleware_MaxQueryLength(t *testing.T) {
 	}
 }
 
+func TestLimitsMiddleware_MultipleTenants(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	defer tenant.WithDefaultResolver(tenant.NewSingleResolver())
+
+	now := time.Now()
+	limits := multiTenantMockLimits{
+		"team-a": {maxQueryLength: 0},
+		"team-b": {maxQueryLength: 7 * 24 * time.Hour},
+		"team-c": {maxQueryLength: 24 * time.Hour},
+	}
+
+	tests := map[string]struct {
+		orgID       string
+		expectedErr bool
+	}{
+		"should succeed if the query doesn't exceed the limit of any tenant": {
+			orgID: "team-a|team-b",
+		},
+		"should fail if the query exceeds the limit of a tenant": {
+			orgID:       "team-a|team-b|team-c",
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := &PrometheusRequest{
+				Start: util.TimeToMillis(now.Add(-48 * time.Hour)),
+				End:   util.TimeToMillis(now),
+			}
+
+			inner := &mockHandler{}
+			inner.On("Do", mock.Anything, mock.Anything).Return(NewEmptyPrometheusResponse(), nil)
+
+			ctx := user.InjectOrgID(context.Background(), testData.orgID)
+			_, err := NewLimitsMiddleware(limits).Wrap(inner).Do(ctx, req)
+
+			if testData.expectedErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "the query time range exceeds the limit")
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 type mockLimits struct {
 	maxQueryLookback  time.Duration
 	maxQueryLength    time.Duration
 	maxCacheFreshness time.Duration
+	blockedQueries    []*validation.BlockedQuery
+	splitInterval     time.Duration
+	splitByTimeRange  []*validation.SplitQueriesInterval
 }
 
 func (m mockLimits) MaxQueryLookback(string) time.Duration {
@@ -208,6 +260,48 @@ func (m mockLimits) MaxCacheFreshness(string) time.Duration {
 	return m.maxCacheFreshness
 }
 
+func (m mockLimits) BlockedQueries(string) []*validation.BlockedQuery {
+	return m.blockedQueries
+}
+
+func (m mockLimits) SplitQueriesByInterval(string) time.Duration {
+	return m.splitInterval
+}
+
+func (m mockLimits) SplitQueriesByTimeRange(string) []*validation.SplitQueriesInterval {
+	return m.splitByTimeRange
+}
+
+type multiTenantMockLimits map[string]mockLimits
+
+func (m multiTenantMockLimits) MaxQueryLookback(userID string) time.Duration {
+	return m[userID].MaxQueryLookback(userID)
+}
+
+func (m multiTenantMockLimits) MaxQueryLength(userID string) time.Duration {
+	return m[userID].MaxQueryLength(userID)
+}
+
+func (m multiTenantMockLimits) MaxQueryParallelism(userID string) int {
+	return m[userID].MaxQueryParallelism(userID)
+}
+
+func (m multiTenantMockLimits) MaxCacheFreshness(userID string) time.Duration {
+	return m[userID].MaxCacheFreshness(userID)
+}
+
+func (m multiTenantMockLimits) BlockedQueries(userID string) []*validation.BlockedQuery {
+	return m[userID].BlockedQueries(userID)
+}
+
+func (m multiTenantMockLimits) SplitQueriesByInterval(userID string) time.Duration {
+	return m[userID].SplitQueriesByInterval(userID)
+}
+
+func (m multiTenantMockLimits) SplitQueriesByTimeRange(userID string) []*validation.SplitQueriesInterval {
+	return m[userID].SplitQueriesByTimeRange(userID)
+}
+
 type mockHandler struct {
 	mock.Mock
 }
@@ -28,6 +28,18 @@ import (
 // StatusSuccess Prometheus success result.
 const StatusSuccess = "success"
 
+// statusError Prometheus error result.
+const statusError = "error"
+
+const (
+	// ProtobufResponseContentType is the content type of the protobuf-encoded PrometheusResponse,
+	// used by the queriers to send query range responses to the query-frontend when requested
+	// via the Accept header. Responses are converted to JSON by the query-frontend.
+	ProtobufResponseContentType = "application/x-cortex-query+protobuf"
+
+	jsonResponseContentType = "application/json"
+)
+
 var (
 	matrix            = model.ValMatrix.String()
 	json              = jsoniter.ConfigCompatibleWithStandardLibrary
@@ -246,7 +258,10 @@ func (prometheusCodec) EncodeRequest(ctx context.Context, r Request) (*http.Requ
 		RequestURI: u.String(), // This is what the httpgrpc code looks at.
 		URL:        u,
 		Body:       http.NoBody,
-		Header:     http.Header{},
+		Header: http.Header{
+			// Queriers not supporting protobuf responses ignore it and respond with JSON.
+			"Accept": []string{ProtobufResponseContentType + ", " + jsonResponseContentType},
+		},
 	}
 
 	return req.WithContext(ctx), nil
@@ -269,10 +284,32 @@ func (prometheusCodec) DecodeResponse(ctx context.Context, r *http.Response, _ R
 	log.LogFields(otlog.Int("bytes", len(buf)))
 
 	var resp PrometheusResponse
-	if err := json.Unmarshal(buf, &resp); err != nil {
+	if r.Header.Get("Content-Type") == ProtobufResponseContentType {
+		err = resp.Unmarshal(buf)
+
+		// An empty result is decoded as nil, while it should be encoded as an empty list in JSON.
+		if err == nil && resp.Data.Result == nil {
+			resp.Data.Result = []SampleStream{}
+		}
+	} else {
+		err = json.Unmarshal(buf, &resp)
+	}
+	if err != nil {
 		return nil, httpgrpc.Errorf(http.StatusInternalServerError, "error decoding response: %v", err)
 	}
 
+	// The queriers stream the JSON response while it's encoded, so an error occurring after the
+	// response has started (e.g. the max query response size exceeded) is returned with a 2xx
+	// status code, and the partial result received so far must be discarded.
+	if resp.Status == statusError {
+		body, _ := json.Marshal(struct {
+			Status    string `json:"status"`
+			ErrorType string `json:"errorType"`
+			Error     string `json:"error"`
+		}{resp.Status, resp.ErrorType, resp.Error})
+		return nil, httpgrpc.Errorf(http.StatusUnprocessableEntity, "%s", body)
+	}
+
 	for h, hv := range r.Header {
 		resp.Headers = append(resp.Headers, &PrometheusResponseHeader{Name: h, Values: hv})
 	}
@@ -299,7 +336,7 @@ func (prometheusCodec) EncodeResponse(ctx context.Context, res Response) (*http.
 
 	resp := http.Response{
 		Header: http.Header{
-			"Content-Type": []string{"application/json"},
+			"Content-Type": []string{jsonResponseContentType},
 		},
 		Body:       ioutil.NopCloser(bytes.NewBuffer(b)),
 		StatusCode: http.StatusOK,
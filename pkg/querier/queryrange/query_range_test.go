@@ -69,6 +69,7 @@ func TestRequest(t *testing.T) {
 			rdash, err := PrometheusCodec.EncodeRequest(context.Background(), req)
 			require.NoError(t, err)
 			require.EqualValues(t, tc.url, rdash.RequestURI)
+			require.Equal(t, ProtobufResponseContentType+", application/json", rdash.Header.Get("Accept"))
 		})
 	}
 }
@@ -108,6 +109,56 @@ func TestResponse(t *testing.T) {
 	}
 }
 
+func TestResponse_Protobuf(t *testing.T) {
+	for testName, expected := range map[string]*PrometheusResponse{
+		"non-empty result": parsedResponse,
+		"empty result": {
+			Status: StatusSuccess,
+			Data: PrometheusData{
+				ResultType: matrix,
+				Result:     []SampleStream{},
+			},
+		},
+	} {
+		t.Run(testName, func(t *testing.T) {
+			body, err := expected.Marshal()
+			require.NoError(t, err)
+
+			response := &http.Response{
+				StatusCode: 200,
+				Header:     http.Header{"Content-Type": []string{ProtobufResponseContentType}},
+				Body:       ioutil.NopCloser(bytes.NewBuffer(body)),
+			}
+			resp, err := PrometheusCodec.DecodeResponse(context.Background(), response, nil)
+			require.NoError(t, err)
+
+			actual := resp.(*PrometheusResponse)
+			assert.Equal(t, []*PrometheusResponseHeader{{Name: "Content-Type", Values: []string{ProtobufResponseContentType}}}, actual.Headers)
+
+			actual.Headers = nil
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestResponse_PartialResultWithError(t *testing.T) {
+	// A streamed response aborted after it has been started.
+	body := `{"data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1536673680,"137"]]}]},"status":"error","errorType":"execution","error":"the result is partial"}`
+
+	response := &http.Response{
+		StatusCode: 200,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewBuffer([]byte(body))),
+	}
+	_, err := PrometheusCodec.DecodeResponse(context.Background(), response, nil)
+	require.Error(t, err)
+
+	resp, ok := httpgrpc.HTTPResponseFromError(err)
+	require.True(t, ok)
+	assert.Equal(t, int32(http.StatusUnprocessableEntity), resp.Code)
+	assert.JSONEq(t, `{"status":"error","errorType":"execution","error":"the result is partial"}`, string(resp.Body))
+}
+
 func TestMergeAPIResponses(t *testing.T) {
 	for i, tc := range []struct {
 		input    []Response
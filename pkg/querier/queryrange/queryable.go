@@ -9,6 +9,7 @@ import (
 	"github.com/prometheus/prometheus/storage"
 
 	"github.com/cortexproject/cortex/pkg/querier/astmapper"
+	"github.com/cortexproject/cortex/pkg/querier/stats"
 )
 
 const (
@@ -79,6 +80,8 @@ func (q *ShardedQuerier) handleEmbeddedQuery(encoded string) storage.SeriesSet {
 		return storage.ErrSeriesSet(err)
 	}
 
+	stats.FromContext(q.Ctx).AddShardedQueries(uint64(len(queries)))
+
 	ctx, cancel := context.WithCancel(q.Ctx)
 	defer cancel()
 
@@ -25,6 +25,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	"github.com/cortexproject/cortex/pkg/util/spanlogger"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 var (
@@ -36,13 +37,14 @@ var (
 )
 
 type CacheGenNumberLoader interface {
-	GetResultsCacheGenNumber(userID string) string
+	GetResultsCacheGenNumber(tenantIDs []string) string
 }
 
 // ResultsCacheConfig is the config for the results cache.
 type ResultsCacheConfig struct {
-	CacheConfig cache.Config `yaml:"cache"`
-	Compression string       `yaml:"compression"`
+	CacheConfig      cache.Config `yaml:"cache"`
+	Compression      string       `yaml:"compression"`
+	MaxItemSizeBytes int          `yaml:"max_item_size_bytes"`
 }
 
 // RegisterFlags registers flags.
@@ -50,6 +52,7 @@ func (cfg *ResultsCacheConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.CacheConfig.RegisterFlagsWithPrefix("frontend.", "", f)
 
 	f.StringVar(&cfg.Compression, "frontend.compression", "", "Use compression in results cache. Supported values are: 'snappy' and '' (disable compression).")
+	f.IntVar(&cfg.MaxItemSizeBytes, "frontend.max-item-size-bytes", 0, "The max size in bytes of a cached query result (after compression, if enabled). Bigger results are not stored in the results cache. It should be set to the max item size of the cache backend (e.g. the memcached -I option), to not send items rejected by the backend. 0 to disable.")
 	flagext.DeprecatedFlag(f, "frontend.cache-split-interval", "Deprecated: The maximum interval expected for each request, results will be cached per single interval. This behavior is now determined by querier.split-queries-by-interval.")
 }
 
@@ -61,6 +64,10 @@ func (cfg *ResultsCacheConfig) Validate() error {
 		return errors.Errorf("unsupported compression type: %s", cfg.Compression)
 	}
 
+	if cfg.MaxItemSizeBytes < 0 {
+		return errors.New("the results cache max item size must not be negative")
+	}
+
 	return cfg.CacheConfig.Validate()
 }
 
@@ -154,6 +161,10 @@ func NewResultsCacheMiddleware(
 	if err != nil {
 		return nil, nil, err
 	}
+	// The max item size applies to the compressed items, so the compression wraps it.
+	if cfg.MaxItemSizeBytes > 0 {
+		c = cache.NewMaxItemSize(cfg.CacheConfig.Prefix+"results-cache", cfg.MaxItemSizeBytes, c, reg)
+	}
 	if cfg.Compression == "snappy" {
 		c = cache.NewSnappy(c, logger)
 	}
@@ -179,7 +190,7 @@ func NewResultsCacheMiddleware(
 }
 
 func (s resultsCache) Do(ctx context.Context, r Request) (Response, error) {
-	userID, err := tenant.TenantID(ctx)
+	tenantIDs, err := tenant.TenantIDs(ctx)
 	if err != nil {
 		return nil, httpgrpc.Errorf(http.StatusBadRequest, err.Error())
 	}
@@ -189,16 +200,16 @@ func (s resultsCache) Do(ctx context.Context, r Request) (Response, error) {
 	}
 
 	if s.cacheGenNumberLoader != nil {
-		ctx = cache.InjectCacheGenNumber(ctx, s.cacheGenNumberLoader.GetResultsCacheGenNumber(userID))
+		ctx = cache.InjectCacheGenNumber(ctx, s.cacheGenNumberLoader.GetResultsCacheGenNumber(tenantIDs))
 	}
 
 	var (
-		key      = s.splitter.GenerateCacheKey(userID, r)
+		key      = s.splitter.GenerateCacheKey(tenant.JoinTenantIDs(tenantIDs), r)
 		extents  []Extent
 		response Response
 	)
 
-	maxCacheFreshness := s.limits.MaxCacheFreshness(userID)
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, s.limits.MaxCacheFreshness)
 	maxCacheTime := int64(model.Now().Add(-maxCacheFreshness))
 	if r.GetStart() > maxCacheTime {
 		return s.next.Do(ctx, r)
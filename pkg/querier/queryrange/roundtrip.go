@@ -57,6 +57,13 @@ type Config struct {
 	CacheResults           bool `yaml:"cache_results"`
 	MaxRetries             int  `yaml:"max_retries"`
 	ShardedQueries         bool `yaml:"parallelise_shardable_queries"`
+
+	CacheInstantQueries          bool          `yaml:"cache_instant_queries"`
+	InstantQueriesCacheAlignment time.Duration `yaml:"instant_queries_cache_alignment"`
+	InstantQueriesCacheTTL       time.Duration `yaml:"instant_queries_cache_ttl"`
+
+	CacheFailedQueries    bool          `yaml:"cache_failed_queries"`
+	FailedQueriesCacheTTL time.Duration `yaml:"failed_queries_cache_ttl"`
 }
 
 // RegisterFlags adds the flags required to config this to the given FlagSet.
@@ -67,6 +74,11 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.AlignQueriesWithStep, "querier.align-querier-with-step", false, "Mutate incoming queries to align their start and end with their step.")
 	f.BoolVar(&cfg.CacheResults, "querier.cache-results", false, "Cache query results.")
 	f.BoolVar(&cfg.ShardedQueries, "querier.parallelise-shardable-queries", false, "Perform query parallelisations based on storage sharding configuration and query ASTs. This feature is supported only by the chunks storage engine.")
+	f.BoolVar(&cfg.CacheInstantQueries, "querier.cache-instant-queries", false, "Cache instant queries results. The results are stored into the results cache, so it requires querier.cache-results to be enabled.")
+	f.DurationVar(&cfg.InstantQueriesCacheAlignment, "querier.instant-queries-cache-alignment", time.Minute, "The evaluation time of cached instant queries is aligned to this interval, so that the same query issued within the same interval is served from the cache.")
+	f.DurationVar(&cfg.InstantQueriesCacheTTL, "querier.instant-queries-cache-ttl", time.Minute, "How long the results of instant queries are cached.")
+	f.BoolVar(&cfg.CacheFailedQueries, "querier.cache-failed-queries", false, "Cache the failures of the instant and range queries which would fail again if retried, like invalid queries (HTTP status 400) or queries exceeding a limit (HTTP status 422). Other query execution failures (HTTP status 422), like canceled queries, are not cached. When issued again, a query whose failure is cached fails without being executed by the queriers. The failures are stored into the results cache, so it requires querier.cache-results to be enabled.")
+	f.DurationVar(&cfg.FailedQueriesCacheTTL, "querier.failed-queries-cache-ttl", 30*time.Second, "How long the failures of the queries are cached.")
 	cfg.ResultsCacheConfig.RegisterFlags(f)
 }
 
@@ -86,6 +98,24 @@ func (cfg *Config) Validate(log log.Logger) error {
 			return errors.Wrap(err, "invalid ResultsCache config")
 		}
 	}
+
+	if cfg.CacheInstantQueries {
+		if !cfg.CacheResults {
+			return errors.New("querier.cache-instant-queries may only be enabled in conjunction with querier.cache-results. Please set the latter")
+		}
+		if cfg.InstantQueriesCacheAlignment < time.Millisecond {
+			return errors.New("querier.instant-queries-cache-alignment must be at least 1ms")
+		}
+	}
+
+	if cfg.CacheFailedQueries {
+		if !cfg.CacheResults {
+			return errors.New("querier.cache-failed-queries may only be enabled in conjunction with querier.cache-results. Please set the latter")
+		}
+		if cfg.FailedQueriesCacheTTL <= 0 {
+			return errors.New("querier.failed-queries-cache-ttl must be greater than 0")
+		}
+	}
 	return nil
 }
 
@@ -160,13 +190,15 @@ func NewTripperware(
 	// Metric used to keep track of each middleware execution duration.
 	metrics := NewInstrumentMiddlewareMetrics(registerer)
 
+	blocker := newQueryBlocker(limits, log, registerer)
+
 	queryRangeMiddleware := []Middleware{NewLimitsMiddleware(limits)}
 	if cfg.AlignQueriesWithStep {
 		queryRangeMiddleware = append(queryRangeMiddleware, InstrumentMiddleware("step_align", metrics), StepAlignMiddleware)
 	}
 	if cfg.SplitQueriesByInterval != 0 {
-		staticIntervalFn := func(_ Request) time.Duration { return cfg.SplitQueriesByInterval }
-		queryRangeMiddleware = append(queryRangeMiddleware, InstrumentMiddleware("split_by_interval", metrics), SplitByIntervalMiddleware(staticIntervalFn, limits, codec, registerer))
+		intervalFn := TenantIntervalFn(cfg.SplitQueriesByInterval, limits)
+		queryRangeMiddleware = append(queryRangeMiddleware, InstrumentMiddleware("split_by_interval", metrics), SplitByIntervalMiddleware(intervalFn, limits, codec, registerer))
 	}
 
 	var c cache.Cache
@@ -182,6 +214,16 @@ func NewTripperware(
 		queryRangeMiddleware = append(queryRangeMiddleware, InstrumentMiddleware("results_cache", metrics), queryCacheMiddleware)
 	}
 
+	var instantQueryCache Tripperware
+	if cfg.CacheInstantQueries && c != nil {
+		instantQueryCache = NewInstantQueryCacheTripperware(c, cfg.InstantQueriesCacheAlignment, cfg.InstantQueriesCacheTTL, cacheGenNumberLoader, log, registerer)
+	}
+
+	var failedQueriesCache Tripperware
+	if cfg.CacheFailedQueries && c != nil {
+		failedQueriesCache = NewFailedQueriesCacheTripperware(c, cfg.FailedQueriesCacheTTL, cacheGenNumberLoader, log, registerer)
+	}
+
 	if cfg.ShardedQueries {
 		if minShardingLookback == 0 {
 			return nil, nil, errInvalidMinShardingLookback
@@ -211,6 +253,27 @@ func NewTripperware(
 		// Finally, if the user selected any query range middleware, stitch it in.
 		if len(queryRangeMiddleware) > 0 {
 			queryrange := NewRoundTripper(next, codec, queryRangeMiddleware...)
+
+			var instantQuery http.RoundTripper
+			if instantQueryCache != nil {
+				instantQuery = instantQueryCache(next)
+			}
+
+			// The queries are looked up in the failed queries cache after being checked
+			// against the blocked queries, which don't reach the queriers anyway.
+			var query http.RoundTripper = RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if !strings.HasSuffix(r.URL.Path, "/query_range") {
+					if instantQuery != nil && strings.HasSuffix(r.URL.Path, "/query") {
+						return instantQuery.RoundTrip(r)
+					}
+					return next.RoundTrip(r)
+				}
+				return queryrange.RoundTrip(r)
+			})
+			if failedQueriesCache != nil {
+				query = failedQueriesCache(query)
+			}
+
 			return RoundTripFunc(func(r *http.Request) (*http.Response, error) {
 				isQueryRange := strings.HasSuffix(r.URL.Path, "/query_range")
 				op := "query"
@@ -218,17 +281,18 @@ func NewTripperware(
 					op = "query_range"
 				}
 
-				user, err := tenant.TenantID(r.Context())
+				tenantIDs, err := tenant.TenantIDs(r.Context())
 				// This should never happen anyways because we have auth middleware before this.
 				if err != nil {
 					return nil, err
 				}
-				queriesPerTenant.WithLabelValues(op, user).Inc()
+				queriesPerTenant.WithLabelValues(op, tenant.JoinTenantIDs(tenantIDs)).Inc()
 
-				if !isQueryRange {
-					return next.RoundTrip(r)
+				if err := blocker.check(r, tenantIDs); err != nil {
+					return nil, err
 				}
-				return queryrange.RoundTrip(r)
+
+				return query.RoundTrip(r)
 			})
 		}
 		return next
@@ -6,9 +6,39 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
-type IntervalFn func(r Request) time.Duration
+type IntervalFn func(ctx context.Context, r Request) time.Duration
+
+// TenantIntervalFn returns an IntervalFn returning the interval to split the queries of the
+// request tenants by, which depends on the query time range, if configured for the tenants, or
+// the tenants' interval otherwise. The smallest interval is used for multi-tenant queries, and
+// the default interval is used for the tenants without any override.
+func TenantIntervalFn(defaultInterval time.Duration, limits Limits) IntervalFn {
+	return func(ctx context.Context, r Request) time.Duration {
+		tenantIDs, err := tenant.TenantIDs(ctx)
+		if err != nil {
+			return defaultInterval
+		}
+
+		timeRange := time.Duration(r.GetEnd()-r.GetStart()) * time.Millisecond
+		interval := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, func(userID string) time.Duration {
+			if interval := validation.SplitQueriesIntervalForTimeRange(limits.SplitQueriesByTimeRange(userID), timeRange); interval > 0 {
+				return interval
+			}
+			return limits.SplitQueriesByInterval(userID)
+		})
+
+		if interval <= 0 {
+			return defaultInterval
+		}
+		return interval
+	}
+}
 
 // SplitByIntervalMiddleware creates a new Middleware that splits requests by a given interval.
 func SplitByIntervalMiddleware(interval IntervalFn, limits Limits, merger Merger, registerer prometheus.Registerer) Middleware {
@@ -40,8 +70,9 @@ type splitByInterval struct {
 func (s splitByInterval) Do(ctx context.Context, r Request) (Response, error) {
 	// First we're going to build new requests, one for each day, taking care
 	// to line up the boundaries with step.
-	reqs := splitQuery(r, s.interval(r))
+	reqs := splitQuery(r, s.interval(ctx, r))
 	s.splitByCounter.Add(float64(len(reqs)))
+	stats.FromContext(ctx).AddSplitQueries(uint64(len(reqs)))
 
 	reqResps, err := DoRequests(ctx, s.next, reqs, s.limits)
 	if err != nil {
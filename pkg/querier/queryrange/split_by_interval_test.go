@@ -14,6 +14,9 @@ import (
 	"github.com/weaveworks/common/middleware"
 	"github.com/weaveworks/common/user"
 	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 const seconds = 1e3 // 1e3 milliseconds per second.
@@ -275,7 +278,7 @@ func TestSplitByDay(t *testing.T) {
 			u, err := url.Parse(s.URL)
 			require.NoError(t, err)
 
-			interval := func(_ Request) time.Duration { return 24 * time.Hour }
+			interval := func(context.Context, Request) time.Duration { return 24 * time.Hour }
 			roundtripper := NewRoundTripper(singleHostRoundTripper{
 				host: u.Host,
 				next: http.DefaultTransport,
@@ -298,3 +301,73 @@ func TestSplitByDay(t *testing.T) {
 		})
 	}
 }
+
+func TestTenantIntervalFn(t *testing.T) {
+	limits := multiTenantMockLimits{
+		"user-1": mockLimits{},
+		"user-2": mockLimits{splitInterval: 12 * time.Hour},
+		"user-3": mockLimits{
+			splitInterval: 12 * time.Hour,
+			splitByTimeRange: []*validation.SplitQueriesInterval{
+				{MinTimeRange: 7 * day, Interval: 7 * day},
+				{MinTimeRange: 0, Interval: day},
+			},
+		},
+		"user-4": mockLimits{
+			splitByTimeRange: []*validation.SplitQueriesInterval{
+				{MinTimeRange: 7 * day, Interval: 7 * day},
+			},
+		},
+	}
+
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	defer tenant.WithDefaultResolver(tenant.NewSingleResolver())
+
+	intervalFn := TenantIntervalFn(day, limits)
+
+	tests := map[string]struct {
+		tenantID  string
+		timeRange time.Duration
+		expected  time.Duration
+	}{
+		"tenant without overrides": {
+			tenantID:  "user-1",
+			timeRange: 30 * day,
+			expected:  day,
+		},
+		"tenant with the interval overridden": {
+			tenantID:  "user-2",
+			timeRange: 30 * day,
+			expected:  12 * time.Hour,
+		},
+		"tenant with intervals by time range, short query": {
+			tenantID:  "user-3",
+			timeRange: 7*day - time.Millisecond,
+			expected:  day,
+		},
+		"tenant with intervals by time range, long query": {
+			tenantID:  "user-3",
+			timeRange: 7 * day,
+			expected:  7 * day,
+		},
+		"tenant with intervals by time range, query not matching any entry": {
+			tenantID:  "user-4",
+			timeRange: time.Hour,
+			expected:  day,
+		},
+		"multi-tenant query gets the smallest interval": {
+			tenantID:  "user-2|user-4",
+			timeRange: 30 * day,
+			expected:  12 * time.Hour,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			ctx := user.InjectOrgID(context.Background(), testData.tenantID)
+			req := &PrometheusRequest{Start: 0, End: testData.timeRange.Milliseconds(), Step: 60 * seconds}
+
+			require.Equal(t, testData.expected, intervalFn(ctx, req))
+		})
+	}
+}
@@ -1,25 +1,42 @@
 package querier
 
 import (
+	"context"
 	"net/http"
 
+	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
 
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
-// Queries are a set of matchers with time ranges - should not get into megabytes
-const maxRemoteReadQuerySize = 1024 * 1024
+const (
+	// Queries are a set of matchers with time ranges - should not get into megabytes
+	maxRemoteReadQuerySize = 1024 * 1024
 
-// RemoteReadHandler handles Prometheus remote read requests.
-func RemoteReadHandler(q storage.Queryable) http.Handler {
+	// The max number of samples encoded in each chunk of the streamed remote read
+	// responses, like the chunks cut by the Prometheus TSDB head.
+	maxRemoteReadSamplesPerChunk = 120
+)
+
+// RemoteReadHandler handles Prometheus remote read requests. The series are returned in
+// a single response of raw samples, or streamed as XOR chunks in frames of at most
+// maxBytesInFrame bytes if the client accepts the STREAMED_XOR_CHUNKS response type.
+func RemoteReadHandler(q storage.Queryable, maxBytesInFrame int) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		compressionType := util.CompressionTypeFor(r.Header.Get("X-Prometheus-Remote-Read-Version"))
 
 		ctx := r.Context()
-		var req client.ReadRequest
+		// The Prometheus read request is wire compatible with the Cortex one, but it also
+		// carries the response types accepted by the client.
+		var req prompb.ReadRequest
 		logger := util.WithContext(r.Context(), util.Logger)
 		if err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRemoteReadQuerySize, &req, compressionType); err != nil {
 			level.Error(logger).Log("err", err.Error())
@@ -27,51 +44,124 @@ func RemoteReadHandler(q storage.Queryable) http.Handler {
 			return
 		}
 
-		// Fetch samples for all queries in parallel.
-		resp := client.ReadResponse{
-			Results: make([]*client.QueryResponse, len(req.Queries)),
+		responseType, err := remote.NegotiateResponseType(req.AcceptedResponseTypes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
-		errors := make(chan error)
-		for i, qr := range req.Queries {
-			go func(i int, qr *client.QueryRequest) {
-				from, to, matchers, err := client.FromQueryRequest(qr)
-				if err != nil {
-					errors <- err
-					return
-				}
 
-				querier, err := q.Querier(ctx, int64(from), int64(to))
-				if err != nil {
-					errors <- err
-					return
-				}
+		switch responseType {
+		case prompb.ReadRequest_STREAMED_XOR_CHUNKS:
+			remoteReadStreamedXORChunks(ctx, q, w, &req, maxBytesInFrame, logger)
+		default:
+			remoteReadSamples(ctx, q, w, &req, compressionType, logger)
+		}
+	})
+}
 
-				params := &storage.SelectHints{
-					Start: int64(from),
-					End:   int64(to),
-				}
-				seriesSet := querier.Select(false, params, matchers...)
-				resp.Results[i], err = seriesSetToQueryResponse(seriesSet)
+func remoteReadSamples(ctx context.Context, q storage.Queryable, w http.ResponseWriter, req *prompb.ReadRequest, compressionType util.CompressionType, logger log.Logger) {
+	// Fetch samples for all queries in parallel.
+	resp := client.ReadResponse{
+		Results: make([]*client.QueryResponse, len(req.Queries)),
+	}
+	errors := make(chan error)
+	for i, qr := range req.Queries {
+		go func(i int, qr *prompb.Query) {
+			from, to, matchers, err := fromRemoteReadQuery(qr)
+			if err != nil {
 				errors <- err
-			}(i, qr)
-		}
+				return
+			}
 
-		var lastErr error
-		for range req.Queries {
-			err := <-errors
+			querier, err := q.Querier(ctx, from, to)
 			if err != nil {
-				lastErr = err
+				errors <- err
+				return
 			}
+
+			params := &storage.SelectHints{
+				Start: from,
+				End:   to,
+			}
+			seriesSet := querier.Select(false, params, matchers...)
+			resp.Results[i], err = seriesSetToQueryResponse(seriesSet)
+			errors <- err
+		}(i, qr)
+	}
+
+	var lastErr error
+	for range req.Queries {
+		err := <-errors
+		if err != nil {
+			lastErr = err
 		}
-		if lastErr != nil {
-			http.Error(w, lastErr.Error(), http.StatusBadRequest)
+	}
+	if lastErr != nil {
+		http.Error(w, lastErr.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Content-Type", "application/x-protobuf")
+	if err := util.SerializeProtoResponse(w, &resp, compressionType); err != nil {
+		level.Error(logger).Log("msg", "error sending remote read response", "err", err)
+	}
+}
+
+func remoteReadStreamedXORChunks(ctx context.Context, q storage.Queryable, w http.ResponseWriter, req *prompb.ReadRequest, maxBytesInFrame int, logger log.Logger) {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "internal http.ResponseWriter does not implement http.Flusher interface", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse")
+
+	// The queries are run sequentially, so that only the series of a single
+	// query at a time are held in memory while streaming the response.
+	for i, qr := range req.Queries {
+		if err := func() error {
+			from, to, matchers, err := fromRemoteReadQuery(qr)
+			if err != nil {
+				return err
+			}
+
+			querier, err := q.Querier(ctx, from, to)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				if err := querier.Close(); err != nil {
+					level.Warn(logger).Log("msg", "error closing querier", "err", err)
+				}
+			}()
+
+			params := &storage.SelectHints{
+				Start: from,
+				End:   to,
+			}
+
+			// The streamed response requires the series to be sorted.
+			seriesSet := querier.Select(true, params, matchers...)
+			_, err = remote.StreamChunkedReadResponses(remote.NewChunkedWriter(w, f), int64(i), newXORChunkSeriesSet(seriesSet), nil, maxBytesInFrame)
+			return err
+		}(); err != nil {
+			level.Error(logger).Log("msg", "error streaming remote read response", "err", err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		w.Header().Add("Content-Type", "application/x-protobuf")
-		if err := util.SerializeProtoResponse(w, &resp, compressionType); err != nil {
-			level.Error(logger).Log("msg", "error sending remote read response", "err", err)
+	}
+}
+
+// fromRemoteReadQuery returns the time range and matchers of a remote read query.
+func fromRemoteReadQuery(qr *prompb.Query) (int64, int64, []*labels.Matcher, error) {
+	matchers := make([]*labels.Matcher, 0, len(qr.Matchers))
+	for _, m := range qr.Matchers {
+		matcher, err := labels.NewMatcher(labels.MatchType(m.Type), m.Name, m.Value)
+		if err != nil {
+			return 0, 0, nil, err
 		}
-	})
+		matchers = append(matchers, matcher)
+	}
+
+	return qr.StartTimestampMs, qr.EndTimestampMs, matchers, nil
 }
 
 func seriesSetToQueryResponse(s storage.SeriesSet) (*client.QueryResponse, error) {
@@ -99,3 +189,88 @@ func seriesSetToQueryResponse(s storage.SeriesSet) (*client.QueryResponse, error
 
 	return result, s.Err()
 }
+
+// xorChunkSeriesSet is a storage.ChunkSeriesSet encoding the samples of the series
+// of a storage.SeriesSet into XOR chunks of at most maxRemoteReadSamplesPerChunk
+// samples, so that the frames of a streamed response can be cut between chunks.
+type xorChunkSeriesSet struct {
+	storage.SeriesSet
+}
+
+func newXORChunkSeriesSet(s storage.SeriesSet) storage.ChunkSeriesSet {
+	return &xorChunkSeriesSet{SeriesSet: s}
+}
+
+func (c *xorChunkSeriesSet) At() storage.ChunkSeries {
+	return &xorChunkSeries{Series: c.SeriesSet.At()}
+}
+
+type xorChunkSeries struct {
+	storage.Series
+}
+
+func (s *xorChunkSeries) Iterator() chunks.Iterator {
+	return &xorChunkSeriesIterator{it: s.Series.Iterator()}
+}
+
+type xorChunkSeriesIterator struct {
+	it  chunkenc.Iterator
+	cur chunks.Meta
+	err error
+
+	// Whether the sample the underlying iterator is positioned at has not been encoded yet.
+	pending bool
+	done    bool
+}
+
+func (c *xorChunkSeriesIterator) Next() bool {
+	if c.done || c.err != nil {
+		return false
+	}
+
+	chk := chunkenc.NewXORChunk()
+	app, err := chk.Appender()
+	if err != nil {
+		c.err = err
+		return false
+	}
+
+	c.cur = chunks.Meta{Chunk: chk}
+	for chk.NumSamples() < maxRemoteReadSamplesPerChunk {
+		if !c.pending && !c.it.Next() {
+			c.done = true
+			break
+		}
+		c.pending = false
+
+		t, v := c.it.At()
+		if chk.NumSamples() == 0 {
+			c.cur.MinTime = t
+		}
+		c.cur.MaxTime = t
+		app.Append(t, v)
+	}
+
+	if c.done {
+		if c.err = c.it.Err(); c.err != nil {
+			return false
+		}
+	} else {
+		// Peek the next sample, to avoid returning an empty chunk at the end of the series.
+		c.pending = c.it.Next()
+		c.done = !c.pending
+	}
+
+	return chk.NumSamples() > 0
+}
+
+func (c *xorChunkSeriesIterator) At() chunks.Meta {
+	return c.cur
+}
+
+func (c *xorChunkSeriesIterator) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+	return c.it.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
@@ -13,7 +14,11 @@ import (
 	"github.com/golang/snappy"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/cortexproject/cortex/pkg/ingester/client"
@@ -36,7 +41,7 @@ func TestRemoteReadHandler(t *testing.T) {
 			},
 		}, nil
 	})
-	handler := RemoteReadHandler(q)
+	handler := RemoteReadHandler(q, 1048576)
 
 	requestBody, err := proto.Marshal(&client.ReadRequest{
 		Queries: []*client.QueryRequest{
@@ -84,6 +89,97 @@ func TestRemoteReadHandler(t *testing.T) {
 	require.Equal(t, expected, response)
 }
 
+func TestRemoteReadHandler_StreamedXORChunks(t *testing.T) {
+	// A series with enough samples to be encoded in multiple chunks.
+	var values []model.SamplePair
+	for ts := 0; ts < 250; ts++ {
+		values = append(values, model.SamplePair{Timestamp: model.Time(ts), Value: model.SampleValue(ts)})
+	}
+
+	q := storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
+		return mockQuerier{
+			matrix: model.Matrix{
+				{Metric: model.Metric{"foo": "bar"}, Values: values},
+				{Metric: model.Metric{"foo": "baz"}, Values: values[:10]},
+			},
+		}, nil
+	})
+
+	tests := map[string]struct {
+		maxBytesInFrame int
+		expectedFrames  int
+	}{
+		"should send each series in a single frame if it fits": {
+			maxBytesInFrame: 1048576,
+			expectedFrames:  2,
+		},
+		"should split a series over multiple frames if it doesn't fit": {
+			maxBytesInFrame: 1,
+			expectedFrames:  4,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			handler := RemoteReadHandler(q, testData.maxBytesInFrame)
+
+			requestBody, err := proto.Marshal(&prompb.ReadRequest{
+				Queries: []*prompb.Query{
+					{StartTimestampMs: 0, EndTimestampMs: 250},
+				},
+				AcceptedResponseTypes: []prompb.ReadRequest_ResponseType{prompb.ReadRequest_STREAMED_XOR_CHUNKS},
+			})
+			require.NoError(t, err)
+			requestBody = snappy.Encode(nil, requestBody)
+			request, err := http.NewRequest("POST", "/api/v1/read", bytes.NewReader(requestBody))
+			require.NoError(t, err)
+			request.Header.Set("X-Prometheus-Remote-Read-Version", "0.1.0")
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			require.Equal(t, 200, recorder.Result().StatusCode)
+			require.Equal(t, "application/x-streamed-protobuf; proto=prometheus.ChunkedReadResponse", recorder.Result().Header.Get("Content-Type"))
+
+			// Decode the streamed frames and the samples of the chunks they contain.
+			actual := map[string][]model.SamplePair{}
+			frames := 0
+			reader := remote.NewChunkedReader(recorder.Result().Body, maxRemoteReadQuerySize, nil)
+			for {
+				var frame prompb.ChunkedReadResponse
+				err := reader.NextProto(&frame)
+				if err == io.EOF {
+					break
+				}
+				require.NoError(t, err)
+				require.Len(t, frame.ChunkedSeries, 1)
+				require.Equal(t, int64(0), frame.QueryIndex)
+				frames++
+
+				series := frame.ChunkedSeries[0]
+				require.Len(t, series.Labels, 1)
+				for _, chk := range series.Chunks {
+					require.Equal(t, prompb.Chunk_XOR, chk.Type)
+
+					c, err := chunkenc.FromData(chunkenc.EncXOR, chk.Data)
+					require.NoError(t, err)
+					require.LessOrEqual(t, c.NumSamples(), maxRemoteReadSamplesPerChunk)
+
+					it := c.Iterator(nil)
+					for it.Next() {
+						ts, v := it.At()
+						actual[series.Labels[0].Value] = append(actual[series.Labels[0].Value], model.SamplePair{Timestamp: model.Time(ts), Value: model.SampleValue(v)})
+					}
+					require.NoError(t, it.Err())
+				}
+			}
+
+			assert.Equal(t, testData.expectedFrames, frames)
+			assert.Equal(t, map[string][]model.SamplePair{"bar": values, "baz": values[:10]}, actual)
+		})
+	}
+}
+
 type mockQuerier struct {
 	matrix model.Matrix
 }
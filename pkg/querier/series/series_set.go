@@ -26,6 +26,7 @@ import (
 
 	"github.com/cortexproject/cortex/pkg/chunk/purger"
 	"github.com/cortexproject/cortex/pkg/prom1/storage/metric"
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
 )
 
 // ConcreteSeriesSet implements storage.SeriesSet.
@@ -383,3 +384,46 @@ func (s seriesSetWithWarnings) Err() error {
 func (s seriesSetWithWarnings) Warnings() storage.Warnings {
 	return append(s.wrapped.Warnings(), s.warnings...)
 }
+
+// ShardedSeriesSet is a storage.SeriesSet adding the query shard label to the series, so that
+// the series selected by different query shards are not merged while evaluating the query.
+type ShardedSeriesSet struct {
+	seriesSet storage.SeriesSet
+	shard     labels.Label
+}
+
+func NewShardedSeriesSet(seriesSet storage.SeriesSet, shard astmapper.ShardAnnotation) storage.SeriesSet {
+	return &ShardedSeriesSet{
+		seriesSet: seriesSet,
+		shard:     shard.Label(),
+	}
+}
+
+func (s ShardedSeriesSet) Next() bool {
+	return s.seriesSet.Next()
+}
+
+func (s ShardedSeriesSet) At() storage.Series {
+	series := s.seriesSet.At()
+
+	b := labels.NewBuilder(series.Labels())
+	b.Set(s.shard.Name, s.shard.Value)
+	return &shardedSeries{Series: series, labels: b.Labels()}
+}
+
+func (s ShardedSeriesSet) Err() error {
+	return s.seriesSet.Err()
+}
+
+func (s ShardedSeriesSet) Warnings() storage.Warnings {
+	return s.seriesSet.Warnings()
+}
+
+type shardedSeries struct {
+	storage.Series
+	labels labels.Labels
+}
+
+func (s *shardedSeries) Labels() labels.Labels {
+	return s.labels
+}
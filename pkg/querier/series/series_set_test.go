@@ -8,6 +8,8 @@ import (
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
 )
 
 func TestConcreteSeriesSet(t *testing.T) {
@@ -27,6 +29,25 @@ func TestConcreteSeriesSet(t *testing.T) {
 	require.False(t, c.Next())
 }
 
+func TestShardedSeriesSet(t *testing.T) {
+	series1 := NewConcreteSeries(labels.FromStrings("foo", "bar"), []model.SamplePair{{Value: 1, Timestamp: 2}})
+	series2 := NewConcreteSeries(labels.FromStrings("foo", "baz"), []model.SamplePair{{Value: 3, Timestamp: 4}})
+
+	c := NewShardedSeriesSet(NewConcreteSeriesSet([]storage.Series{series1, series2}), astmapper.ShardAnnotation{Shard: 1, Of: 2})
+	require.True(t, c.Next())
+	require.Equal(t, labels.FromStrings("__cortex_shard__", "1_of_2", "foo", "bar"), c.At().Labels())
+	require.True(t, c.Next())
+	require.Equal(t, labels.FromStrings("__cortex_shard__", "1_of_2", "foo", "baz"), c.At().Labels())
+
+	// The samples are left untouched.
+	it := c.At().Iterator()
+	require.True(t, it.Next())
+	ts, v := it.At()
+	require.Equal(t, int64(4), ts)
+	require.Equal(t, float64(3), v)
+	require.False(t, c.Next())
+}
+
 func TestMatrixToSeriesSetSortsMetricLabels(t *testing.T) {
 	matrix := model.Matrix{
 		{
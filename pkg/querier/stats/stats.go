@@ -0,0 +1,283 @@
+package stats
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+)
+
+type contextKey int
+
+var ctxKey = contextKey(0)
+
+// HeaderName is the HTTP header used by the querier to report the query statistics
+// to the query-frontend.
+const HeaderName = "X-Cortex-Query-Stats"
+
+const (
+	wallTimeParam          = "wall_time"
+	fetchedSeriesParam     = "fetched_series"
+	fetchedChunksParam     = "fetched_chunks"
+	fetchedChunkBytesParam = "fetched_chunk_bytes"
+
+	fetchedIngesterChunkBytesParam     = "fetched_ingester_chunk_bytes"
+	fetchedStoreGatewayChunkBytesParam = "fetched_store_gateway_chunk_bytes"
+	splitQueriesParam                  = "split_queries"
+	shardedQueriesParam                = "sharded_queries"
+)
+
+// Stats holds the statistics of a single query. All methods are safe to be called
+// concurrently and on a nil Stats, in which case they are a no-op.
+type Stats struct {
+	wallTime          atomic.Duration
+	fetchedSeries     atomic.Uint64
+	fetchedChunks     atomic.Uint64
+	fetchedChunkBytes atomic.Uint64
+
+	// Breakdown of the fetched chunk bytes, by the component they've been fetched from.
+	fetchedIngesterChunkBytes     atomic.Uint64
+	fetchedStoreGatewayChunkBytes atomic.Uint64
+
+	// Number of queries the query has been split or sharded into by the query-frontend.
+	splitQueries   atomic.Uint64
+	shardedQueries atomic.Uint64
+}
+
+// ContextWithEmptyStats returns a context with empty stats.
+func ContextWithEmptyStats(ctx context.Context) (*Stats, context.Context) {
+	stats := &Stats{}
+	ctx = context.WithValue(ctx, ctxKey, stats)
+	return stats, ctx
+}
+
+// FromContext gets the Stats out of the Context. Returns nil if stats have not
+// been initialised in the context.
+func FromContext(ctx context.Context) *Stats {
+	o := ctx.Value(ctxKey)
+	if o == nil {
+		return nil
+	}
+	return o.(*Stats)
+}
+
+// AddWallTime adds some time to the counter.
+func (s *Stats) AddWallTime(t time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.wallTime.Add(t)
+}
+
+// LoadWallTime returns current wall time.
+func (s *Stats) LoadWallTime() time.Duration {
+	if s == nil {
+		return 0
+	}
+
+	return s.wallTime.Load()
+}
+
+// AddFetchedSeries adds the number of fetched series.
+func (s *Stats) AddFetchedSeries(series uint64) {
+	if s == nil {
+		return
+	}
+
+	s.fetchedSeries.Add(series)
+}
+
+// LoadFetchedSeries returns the number of fetched series.
+func (s *Stats) LoadFetchedSeries() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.fetchedSeries.Load()
+}
+
+// AddFetchedChunks adds the number of fetched chunks.
+func (s *Stats) AddFetchedChunks(chunks uint64) {
+	if s == nil {
+		return
+	}
+
+	s.fetchedChunks.Add(chunks)
+}
+
+// LoadFetchedChunks returns the number of fetched chunks.
+func (s *Stats) LoadFetchedChunks() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.fetchedChunks.Load()
+}
+
+// AddFetchedChunkBytes adds the size of the fetched chunks, in bytes.
+func (s *Stats) AddFetchedChunkBytes(bytes uint64) {
+	if s == nil {
+		return
+	}
+
+	s.fetchedChunkBytes.Add(bytes)
+}
+
+// LoadFetchedChunkBytes returns the size of the fetched chunks, in bytes.
+func (s *Stats) LoadFetchedChunkBytes() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.fetchedChunkBytes.Load()
+}
+
+// AddFetchedIngesterChunkBytes adds the size of the chunks fetched from the ingesters, in bytes.
+// The size is not added to the total fetched chunk bytes, which must be tracked separately.
+func (s *Stats) AddFetchedIngesterChunkBytes(bytes uint64) {
+	if s == nil {
+		return
+	}
+
+	s.fetchedIngesterChunkBytes.Add(bytes)
+}
+
+// LoadFetchedIngesterChunkBytes returns the size of the chunks fetched from the ingesters, in bytes.
+func (s *Stats) LoadFetchedIngesterChunkBytes() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.fetchedIngesterChunkBytes.Load()
+}
+
+// AddFetchedStoreGatewayChunkBytes adds the size of the chunks fetched from the store-gateways, in bytes.
+// The size is not added to the total fetched chunk bytes, which must be tracked separately.
+func (s *Stats) AddFetchedStoreGatewayChunkBytes(bytes uint64) {
+	if s == nil {
+		return
+	}
+
+	s.fetchedStoreGatewayChunkBytes.Add(bytes)
+}
+
+// LoadFetchedStoreGatewayChunkBytes returns the size of the chunks fetched from the store-gateways, in bytes.
+func (s *Stats) LoadFetchedStoreGatewayChunkBytes() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.fetchedStoreGatewayChunkBytes.Load()
+}
+
+// AddSplitQueries adds the number of queries the query has been split into.
+func (s *Stats) AddSplitQueries(queries uint64) {
+	if s == nil {
+		return
+	}
+
+	s.splitQueries.Add(queries)
+}
+
+// LoadSplitQueries returns the number of queries the query has been split into.
+func (s *Stats) LoadSplitQueries() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.splitQueries.Load()
+}
+
+// AddShardedQueries adds the number of queries the query has been sharded into.
+func (s *Stats) AddShardedQueries(queries uint64) {
+	if s == nil {
+		return
+	}
+
+	s.shardedQueries.Add(queries)
+}
+
+// LoadShardedQueries returns the number of queries the query has been sharded into.
+func (s *Stats) LoadShardedQueries() uint64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.shardedQueries.Load()
+}
+
+// Merge the provided Stats into this one.
+func (s *Stats) Merge(other *Stats) {
+	if s == nil || other == nil {
+		return
+	}
+
+	s.AddWallTime(other.LoadWallTime())
+	s.AddFetchedSeries(other.LoadFetchedSeries())
+	s.AddFetchedChunks(other.LoadFetchedChunks())
+	s.AddFetchedChunkBytes(other.LoadFetchedChunkBytes())
+	s.AddFetchedIngesterChunkBytes(other.LoadFetchedIngesterChunkBytes())
+	s.AddFetchedStoreGatewayChunkBytes(other.LoadFetchedStoreGatewayChunkBytes())
+	s.AddSplitQueries(other.LoadSplitQueries())
+	s.AddShardedQueries(other.LoadShardedQueries())
+}
+
+// Encode the stats into a string which can be sent as the value of the HeaderName HTTP header.
+func (s *Stats) Encode() string {
+	values := url.Values{}
+	values.Set(wallTimeParam, s.LoadWallTime().String())
+	values.Set(fetchedSeriesParam, strconv.FormatUint(s.LoadFetchedSeries(), 10))
+	values.Set(fetchedChunksParam, strconv.FormatUint(s.LoadFetchedChunks(), 10))
+	values.Set(fetchedChunkBytesParam, strconv.FormatUint(s.LoadFetchedChunkBytes(), 10))
+	values.Set(fetchedIngesterChunkBytesParam, strconv.FormatUint(s.LoadFetchedIngesterChunkBytes(), 10))
+	values.Set(fetchedStoreGatewayChunkBytesParam, strconv.FormatUint(s.LoadFetchedStoreGatewayChunkBytes(), 10))
+	values.Set(splitQueriesParam, strconv.FormatUint(s.LoadSplitQueries(), 10))
+	values.Set(shardedQueriesParam, strconv.FormatUint(s.LoadShardedQueries(), 10))
+	return values.Encode()
+}
+
+// Decode the stats from a string encoded with Encode().
+func Decode(encoded string) (*Stats, error) {
+	values, err := url.ParseQuery(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode query stats")
+	}
+
+	s := &Stats{}
+
+	if v := values.Get(wallTimeParam); v != "" {
+		wallTime, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode query stats %s", wallTimeParam)
+		}
+		s.AddWallTime(wallTime)
+	}
+
+	for param, add := range map[string]func(uint64){
+		fetchedSeriesParam:     s.AddFetchedSeries,
+		fetchedChunksParam:     s.AddFetchedChunks,
+		fetchedChunkBytesParam: s.AddFetchedChunkBytes,
+
+		fetchedIngesterChunkBytesParam:     s.AddFetchedIngesterChunkBytes,
+		fetchedStoreGatewayChunkBytesParam: s.AddFetchedStoreGatewayChunkBytes,
+		splitQueriesParam:                  s.AddSplitQueries,
+		shardedQueriesParam:                s.AddShardedQueries,
+	} {
+		v := values.Get(param)
+		if v == "" {
+			continue
+		}
+
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode query stats %s", param)
+		}
+		add(n)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,186 @@
+package stats
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats_WallTime(t *testing.T) {
+	t.Run("add and load wall time", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddWallTime(time.Second)
+		stats.AddWallTime(time.Second)
+
+		assert.Equal(t, 2*time.Second, stats.LoadWallTime())
+	})
+
+	t.Run("add and load wall time nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddWallTime(time.Second)
+
+		assert.Equal(t, time.Duration(0), stats.LoadWallTime())
+	})
+}
+
+func TestStats_FetchedSeries(t *testing.T) {
+	t.Run("add and load series", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddFetchedSeries(100)
+		stats.AddFetchedSeries(50)
+
+		assert.Equal(t, uint64(150), stats.LoadFetchedSeries())
+	})
+
+	t.Run("add and load series nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddFetchedSeries(50)
+
+		assert.Equal(t, uint64(0), stats.LoadFetchedSeries())
+	})
+}
+
+func TestStats_FetchedChunks(t *testing.T) {
+	t.Run("add and load chunks and bytes", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddFetchedChunks(10)
+		stats.AddFetchedChunks(5)
+		stats.AddFetchedChunkBytes(1024)
+
+		assert.Equal(t, uint64(15), stats.LoadFetchedChunks())
+		assert.Equal(t, uint64(1024), stats.LoadFetchedChunkBytes())
+	})
+
+	t.Run("add and load chunks and bytes nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddFetchedChunks(10)
+		stats.AddFetchedChunkBytes(1024)
+
+		assert.Equal(t, uint64(0), stats.LoadFetchedChunks())
+		assert.Equal(t, uint64(0), stats.LoadFetchedChunkBytes())
+	})
+}
+
+func TestStats_FetchedChunkBytesByComponent(t *testing.T) {
+	t.Run("add and load chunk bytes by component", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddFetchedIngesterChunkBytes(1024)
+		stats.AddFetchedStoreGatewayChunkBytes(2048)
+		stats.AddFetchedStoreGatewayChunkBytes(1024)
+
+		assert.Equal(t, uint64(1024), stats.LoadFetchedIngesterChunkBytes())
+		assert.Equal(t, uint64(3072), stats.LoadFetchedStoreGatewayChunkBytes())
+	})
+
+	t.Run("add and load chunk bytes by component nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddFetchedIngesterChunkBytes(1024)
+		stats.AddFetchedStoreGatewayChunkBytes(2048)
+
+		assert.Equal(t, uint64(0), stats.LoadFetchedIngesterChunkBytes())
+		assert.Equal(t, uint64(0), stats.LoadFetchedStoreGatewayChunkBytes())
+	})
+}
+
+func TestStats_SplitAndShardedQueries(t *testing.T) {
+	t.Run("add and load split and sharded queries", func(t *testing.T) {
+		stats, _ := ContextWithEmptyStats(context.Background())
+		stats.AddSplitQueries(3)
+		stats.AddShardedQueries(16)
+		stats.AddShardedQueries(16)
+
+		assert.Equal(t, uint64(3), stats.LoadSplitQueries())
+		assert.Equal(t, uint64(32), stats.LoadShardedQueries())
+	})
+
+	t.Run("add and load split and sharded queries nil receiver", func(t *testing.T) {
+		var stats *Stats
+		stats.AddSplitQueries(3)
+		stats.AddShardedQueries(16)
+
+		assert.Equal(t, uint64(0), stats.LoadSplitQueries())
+		assert.Equal(t, uint64(0), stats.LoadShardedQueries())
+	})
+}
+
+func TestStats_FromContext(t *testing.T) {
+	assert.Nil(t, FromContext(context.Background()))
+
+	stats, ctx := ContextWithEmptyStats(context.Background())
+	assert.Same(t, stats, FromContext(ctx))
+}
+
+func TestStats_Merge(t *testing.T) {
+	t.Run("merge two stats objects", func(t *testing.T) {
+		stats1 := &Stats{}
+		stats1.AddWallTime(time.Millisecond)
+		stats1.AddFetchedSeries(50)
+		stats1.AddFetchedChunks(10)
+		stats1.AddFetchedChunkBytes(42)
+
+		stats2 := &Stats{}
+		stats2.AddWallTime(time.Second)
+		stats2.AddFetchedSeries(60)
+		stats2.AddFetchedChunks(20)
+		stats2.AddFetchedChunkBytes(100)
+		stats2.AddFetchedStoreGatewayChunkBytes(100)
+		stats2.AddSplitQueries(2)
+
+		stats1.Merge(stats2)
+
+		assert.Equal(t, 1001*time.Millisecond, stats1.LoadWallTime())
+		assert.Equal(t, uint64(110), stats1.LoadFetchedSeries())
+		assert.Equal(t, uint64(30), stats1.LoadFetchedChunks())
+		assert.Equal(t, uint64(142), stats1.LoadFetchedChunkBytes())
+		assert.Equal(t, uint64(100), stats1.LoadFetchedStoreGatewayChunkBytes())
+		assert.Equal(t, uint64(2), stats1.LoadSplitQueries())
+	})
+
+	t.Run("merge two nil stats objects", func(t *testing.T) {
+		var stats1 *Stats
+		var stats2 *Stats
+
+		stats1.Merge(stats2)
+
+		assert.Equal(t, time.Duration(0), stats1.LoadWallTime())
+		assert.Equal(t, uint64(0), stats1.LoadFetchedSeries())
+	})
+}
+
+func TestStats_EncodeDecode(t *testing.T) {
+	stats := &Stats{}
+	stats.AddWallTime(1500 * time.Millisecond)
+	stats.AddFetchedSeries(10)
+	stats.AddFetchedChunks(20)
+	stats.AddFetchedChunkBytes(30)
+	stats.AddFetchedIngesterChunkBytes(10)
+	stats.AddFetchedStoreGatewayChunkBytes(20)
+	stats.AddSplitQueries(2)
+	stats.AddShardedQueries(32)
+
+	decoded, err := Decode(stats.Encode())
+	require.NoError(t, err)
+	assert.Equal(t, stats.LoadWallTime(), decoded.LoadWallTime())
+	assert.Equal(t, stats.LoadFetchedSeries(), decoded.LoadFetchedSeries())
+	assert.Equal(t, stats.LoadFetchedChunks(), decoded.LoadFetchedChunks())
+	assert.Equal(t, stats.LoadFetchedChunkBytes(), decoded.LoadFetchedChunkBytes())
+	assert.Equal(t, stats.LoadFetchedIngesterChunkBytes(), decoded.LoadFetchedIngesterChunkBytes())
+	assert.Equal(t, stats.LoadFetchedStoreGatewayChunkBytes(), decoded.LoadFetchedStoreGatewayChunkBytes())
+	assert.Equal(t, stats.LoadSplitQueries(), decoded.LoadSplitQueries())
+	assert.Equal(t, stats.LoadShardedQueries(), decoded.LoadShardedQueries())
+
+	// Missing params should be decoded as zero.
+	decoded, err = Decode("fetched_series=5")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), decoded.LoadFetchedSeries())
+	assert.Equal(t, time.Duration(0), decoded.LoadWallTime())
+
+	// Invalid values should return an error.
+	_, err = Decode("fetched_series=abc")
+	assert.Error(t, err)
+	_, err = Decode("wall_time=abc")
+	assert.Error(t, err)
+}
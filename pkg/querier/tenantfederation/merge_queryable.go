@@ -0,0 +1,312 @@
+package tenantfederation
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+const (
+	// defaultTenantLabel is the label injected in the series of a federated query,
+	// holding the ID of the tenant the series belongs to.
+	defaultTenantLabel = "__tenant_id__"
+
+	// retainExistingPrefix is the prefix of the label retaining the original value
+	// of a series label conflicting with the injected tenant label.
+	retainExistingPrefix       = "original_"
+	originalDefaultTenantLabel = retainExistingPrefix + defaultTenantLabel
+
+	errTooManyTenants = "the query spans too many tenants (limit: %d, actual: %d)"
+)
+
+// NewQueryable returns a queryable that iterates through all the tenant IDs
+// that are part of the request and aggregates the results from each tenant's
+// Querier by sending of subsequent requests. The result contains the label
+// __tenant_id__ to identify the tenant ID that it originally resulted from.
+// If the label __tenant_id__ is already existing, its value is overwritten by
+// the tenant ID and the previous value is exposed through a new label
+// prefixed with "original_". This behaviour is not implemented recursively.
+// Requests for a single tenant are passed through to the upstream queryable.
+func NewQueryable(upstream storage.Queryable, maxTenants int) storage.Queryable {
+	return &mergeQueryable{
+		upstream:   upstream,
+		maxTenants: maxTenants,
+	}
+}
+
+type mergeQueryable struct {
+	upstream   storage.Queryable
+	maxTenants int
+}
+
+// Querier returns a new mergeQuerier, which aggregates results from multiple
+// tenants into a single result.
+func (m *mergeQueryable) Querier(ctx context.Context, mint int64, maxt int64) (storage.Querier, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tenantIDs) <= 1 {
+		return m.upstream.Querier(ctx, mint, maxt)
+	}
+
+	if m.maxTenants > 0 && len(tenantIDs) > m.maxTenants {
+		return nil, fmt.Errorf(errTooManyTenants, m.maxTenants, len(tenantIDs))
+	}
+
+	queriers := make([]storage.Querier, 0, len(tenantIDs))
+	for _, tenantID := range tenantIDs {
+		q, err := m.upstream.Querier(user.InjectOrgID(ctx, tenantID), mint, maxt)
+		if err != nil {
+			// Close the queriers opened so far.
+			for _, opened := range queriers {
+				_ = opened.Close()
+			}
+			return nil, err
+		}
+		queriers = append(queriers, q)
+	}
+
+	return &mergeQuerier{
+		queriers:  queriers,
+		tenantIDs: tenantIDs,
+	}, nil
+}
+
+// mergeQuerier aggregates the results from underlying queriers and adds a
+// label __tenant_id__ to identify the tenant ID that the metric resulted from.
+// If the label __tenant_id__ is already existing, its value is overwritten by
+// the tenant ID and the previous value is exposed through a new label
+// prefixed with "original_". This behaviour is not implemented recursively.
+type mergeQuerier struct {
+	queriers  []storage.Querier
+	tenantIDs []string
+}
+
+// LabelValues returns all potential values for a label name. For the label
+// __tenant_id__ it returns the tenant IDs of the query. If the label
+// original___tenant_id__ is requested, the values of the conflicting label
+// __tenant_id__ are returned.
+func (m *mergeQuerier) LabelValues(name string) ([]string, storage.Warnings, error) {
+	if name == defaultTenantLabel {
+		return m.tenantIDs, nil, nil
+	}
+
+	// Ensure the name of a retained label gets handled under the original
+	// label name.
+	if name == originalDefaultTenantLabel {
+		name = defaultTenantLabel
+	}
+
+	return m.mergeDistinctStringSlice(func(q storage.Querier) ([]string, storage.Warnings, error) {
+		return q.LabelValues(name)
+	})
+}
+
+// LabelNames returns all the unique label names present in the underlying
+// queriers, in sorted order. It also adds the __tenant_id__ label (and the
+// original___tenant_id__ label, if __tenant_id__ is already existing).
+func (m *mergeQuerier) LabelNames() ([]string, storage.Warnings, error) {
+	labelNames, warnings, err := m.mergeDistinctStringSlice(func(q storage.Querier) ([]string, storage.Warnings, error) {
+		return q.LabelNames()
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// If the tenant label already exists, the original value is exposed
+	// through the retained label.
+	labelToAdd := defaultTenantLabel
+	labelPos := sort.SearchStrings(labelNames, labelToAdd)
+	if labelPos < len(labelNames) && labelNames[labelPos] == labelToAdd {
+		labelToAdd = originalDefaultTenantLabel
+		labelPos = sort.SearchStrings(labelNames, labelToAdd)
+	}
+
+	// Insert the label at the correct position.
+	labelNames = append(labelNames, "")
+	copy(labelNames[labelPos+1:], labelNames[labelPos:])
+	labelNames[labelPos] = labelToAdd
+
+	return labelNames, warnings, nil
+}
+
+type stringSliceFunc func(storage.Querier) ([]string, storage.Warnings, error)
+
+// mergeDistinctStringSlice is aggregating results from stringSliceFunc calls
+// on each querier. It removes duplicates and sorts the result.
+func (m *mergeQuerier) mergeDistinctStringSlice(f stringSliceFunc) ([]string, storage.Warnings, error) {
+	var warnings storage.Warnings
+	resultMap := make(map[string]struct{})
+
+	for pos, q := range m.queriers {
+		result, resultWarnings, err := f(q)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, e := range result {
+			resultMap[e] = struct{}{}
+		}
+		for _, w := range resultWarnings {
+			warnings = append(warnings, errors.Wrapf(w, "warning querying tenant_id %s", m.tenantIDs[pos]))
+		}
+	}
+
+	result := make([]string, 0, len(resultMap))
+	for e := range resultMap {
+		result = append(result, e)
+	}
+	sort.Strings(result)
+
+	return result, warnings, nil
+}
+
+// Close releases the resources of all the underlying queriers.
+func (m *mergeQuerier) Close() error {
+	errs := tsdb_errors.NewMulti()
+	for pos, q := range m.queriers {
+		if err := q.Close(); err != nil {
+			errs.Add(errors.Wrapf(err, "failed to close querier for tenant_id %s", m.tenantIDs[pos]))
+		}
+	}
+	return errs.Err()
+}
+
+// Select returns a set of series that matches the given label matchers. The
+// matchers on the __tenant_id__ label are used to select the tenants to query,
+// and are not passed to the underlying queriers.
+func (m *mergeQuerier) Select(sortSeries bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	matchedTenants, filteredMatchers := filterValuesByMatchers(defaultTenantLabel, m.tenantIDs, matchers...)
+
+	// The series sets can be merged only if they're sorted.
+	sortSeries = sortSeries || len(matchedTenants) > 1
+
+	seriesSets := make([]storage.SeriesSet, 0, len(matchedTenants))
+	for pos, tenantID := range m.tenantIDs {
+		if _, matched := matchedTenants[tenantID]; !matched {
+			continue
+		}
+
+		seriesSets = append(seriesSets, &addLabelsSeriesSet{
+			upstream: m.queriers[pos].Select(sortSeries, hints, filteredMatchers...),
+			labels:   labels.Labels{{Name: defaultTenantLabel, Value: tenantID}},
+		})
+	}
+
+	return storage.NewMergeSeriesSet(seriesSets, storage.ChainedSeriesMerge)
+}
+
+// filterValuesByMatchers applies the matchers to the input label name and
+// values. It returns the set of matched values and all the matchers not
+// related to the label name. The matchers on the retained label (prefixed with
+// "original_") are rewritten to match the original label name, so that they
+// can be passed to the underlying queriers.
+func filterValuesByMatchers(labelName string, labelValues []string, matchers ...*labels.Matcher) (matchedValues map[string]struct{}, unrelatedMatchers []*labels.Matcher) {
+	unrelatedMatchers = make([]*labels.Matcher, 0, len(matchers))
+
+	matchedValues = make(map[string]struct{}, len(labelValues))
+	for _, value := range labelValues {
+		matchedValues[value] = struct{}{}
+	}
+
+	for _, m := range matchers {
+		if m.Name != labelName {
+			if m.Name == retainExistingPrefix+labelName {
+				rewritten := *m
+				rewritten.Name = labelName
+				unrelatedMatchers = append(unrelatedMatchers, &rewritten)
+			} else {
+				unrelatedMatchers = append(unrelatedMatchers, m)
+			}
+			continue
+		}
+
+		for value := range matchedValues {
+			if !m.Matches(value) {
+				delete(matchedValues, value)
+			}
+		}
+	}
+
+	return matchedValues, unrelatedMatchers
+}
+
+// addLabelsSeriesSet adds the input labels to all the series of the upstream set.
+type addLabelsSeriesSet struct {
+	upstream   storage.SeriesSet
+	labels     labels.Labels
+	currSeries storage.Series
+}
+
+func (m *addLabelsSeriesSet) Next() bool {
+	m.currSeries = nil
+	return m.upstream.Next()
+}
+
+// At returns the current series, with the additional labels set.
+func (m *addLabelsSeriesSet) At() storage.Series {
+	if m.currSeries == nil {
+		upstream := m.upstream.At()
+		m.currSeries = &addLabelsSeries{
+			Series: upstream,
+			labels: setLabelsRetainExisting(upstream.Labels(), m.labels...),
+		}
+	}
+	return m.currSeries
+}
+
+// Err returns the error of the upstream set. The error is not wrapped, so that
+// its type (e.g. a limit error) is preserved.
+func (m *addLabelsSeriesSet) Err() error {
+	return m.upstream.Err()
+}
+
+// Warnings returns the warnings of the upstream set, annotated with the
+// additional labels, in order to identify the tenant they originated from.
+func (m *addLabelsSeriesSet) Warnings() storage.Warnings {
+	upstream := m.upstream.Warnings()
+	if len(upstream) == 0 {
+		return nil
+	}
+
+	warnings := make(storage.Warnings, 0, len(upstream))
+	for _, w := range upstream {
+		warnings = append(warnings, errors.Wrapf(w, "warning querying %s", m.labels.String()))
+	}
+	return warnings
+}
+
+type addLabelsSeries struct {
+	storage.Series
+	labels labels.Labels
+}
+
+func (a *addLabelsSeries) Labels() labels.Labels {
+	return a.labels
+}
+
+// setLabelsRetainExisting sets the input labels, retaining the value of an
+// existing label in a new label prefixed with "original_". It doesn't do this
+// recursively.
+func setLabelsRetainExisting(src labels.Labels, additionalLabels ...labels.Label) labels.Labels {
+	lb := labels.NewBuilder(src)
+
+	for _, additionalL := range additionalLabels {
+		if oldValue := src.Get(additionalL.Name); oldValue != "" {
+			lb.Set(retainExistingPrefix+additionalL.Name, oldValue)
+		}
+		lb.Set(additionalL.Name, additionalL.Value)
+	}
+
+	return lb.Labels()
+}
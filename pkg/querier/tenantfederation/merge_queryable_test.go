@@ -0,0 +1,238 @@
+package tenantfederation
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/querier/series"
+	"github.com/cortexproject/cortex/pkg/tenant"
+)
+
+func TestMergeQueryable_Select(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	defer tenant.WithDefaultResolver(tenant.NewSingleResolver())
+
+	upstream := &mockTenantQueryable{series: map[string][]labels.Labels{
+		"team-a": {
+			labels.FromStrings(labels.MetricName, "up", "instance", "host-1"),
+			labels.FromStrings(labels.MetricName, "up", "instance", "host-2"),
+		},
+		"team-b": {
+			labels.FromStrings(labels.MetricName, "up", "instance", "host-1"),
+		},
+		"team-c": {
+			labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "original-value"),
+		},
+	}}
+
+	tests := map[string]struct {
+		orgID          string
+		matchers       []*labels.Matcher
+		expectedSeries []labels.Labels
+	}{
+		"single tenant": {
+			orgID:    "team-a",
+			matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up")},
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1"),
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-2"),
+			},
+		},
+		"multiple tenants": {
+			orgID:    "team-b|team-a",
+			matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up")},
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "team-a"),
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-2", defaultTenantLabel, "team-a"),
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "team-b"),
+			},
+		},
+		"multiple tenants with a matcher on the tenant label": {
+			orgID: "team-a|team-b",
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"),
+				labels.MustNewMatcher(labels.MatchNotEqual, defaultTenantLabel, "team-a"),
+			},
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "team-b"),
+			},
+		},
+		"multiple tenants with series having the tenant label": {
+			orgID:    "team-b|team-c",
+			matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "instance", "host-1")},
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "team-b"),
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "team-c", originalDefaultTenantLabel, "original-value"),
+			},
+		},
+		"multiple tenants with a matcher on the original tenant label": {
+			orgID: "team-b|team-c",
+			matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"),
+				labels.MustNewMatcher(labels.MatchEqual, originalDefaultTenantLabel, "original-value"),
+			},
+			expectedSeries: []labels.Labels{
+				labels.FromStrings(labels.MetricName, "up", "instance", "host-1", defaultTenantLabel, "team-c", originalDefaultTenantLabel, "original-value"),
+			},
+		},
+		"multiple tenants without matching series": {
+			orgID:          "team-a|team-b",
+			matchers:       []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "down")},
+			expectedSeries: nil,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			q, err := NewQueryable(upstream, 0).Querier(user.InjectOrgID(context.Background(), testData.orgID), 0, 1000)
+			require.NoError(t, err)
+			defer q.Close()
+
+			set := q.Select(true, nil, testData.matchers...)
+
+			var actual []labels.Labels
+			for set.Next() {
+				actual = append(actual, set.At().Labels())
+			}
+			require.NoError(t, set.Err())
+			assert.Equal(t, testData.expectedSeries, actual)
+		})
+	}
+}
+
+func TestMergeQueryable_LabelNamesAndValues(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	defer tenant.WithDefaultResolver(tenant.NewSingleResolver())
+
+	upstream := &mockTenantQueryable{series: map[string][]labels.Labels{
+		"team-a": {labels.FromStrings(labels.MetricName, "up", "instance", "host-1")},
+		"team-b": {labels.FromStrings(labels.MetricName, "down", "instance", "host-2")},
+		"team-c": {labels.FromStrings(labels.MetricName, "up", defaultTenantLabel, "original-value")},
+	}}
+
+	t.Run("multiple tenants", func(t *testing.T) {
+		q, err := NewQueryable(upstream, 0).Querier(user.InjectOrgID(context.Background(), "team-a|team-b"), 0, 1000)
+		require.NoError(t, err)
+		defer q.Close()
+
+		names, _, err := q.LabelNames()
+		require.NoError(t, err)
+		assert.Equal(t, []string{labels.MetricName, defaultTenantLabel, "instance"}, names)
+
+		values, _, err := q.LabelValues(labels.MetricName)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"down", "up"}, values)
+
+		values, _, err = q.LabelValues(defaultTenantLabel)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"team-a", "team-b"}, values)
+	})
+
+	t.Run("multiple tenants with series having the tenant label", func(t *testing.T) {
+		q, err := NewQueryable(upstream, 0).Querier(user.InjectOrgID(context.Background(), "team-a|team-c"), 0, 1000)
+		require.NoError(t, err)
+		defer q.Close()
+
+		names, _, err := q.LabelNames()
+		require.NoError(t, err)
+		assert.Equal(t, []string{labels.MetricName, defaultTenantLabel, "instance", originalDefaultTenantLabel}, names)
+
+		values, _, err := q.LabelValues(defaultTenantLabel)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"team-a", "team-c"}, values)
+
+		values, _, err = q.LabelValues(originalDefaultTenantLabel)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"original-value"}, values)
+	})
+}
+
+func TestMergeQueryable_MaxTenants(t *testing.T) {
+	tenant.WithDefaultResolver(tenant.NewMultiResolver())
+	defer tenant.WithDefaultResolver(tenant.NewSingleResolver())
+
+	upstream := &mockTenantQueryable{}
+	queryable := NewQueryable(upstream, 2)
+
+	q, err := queryable.Querier(user.InjectOrgID(context.Background(), "team-a|team-b"), 0, 1000)
+	require.NoError(t, err)
+	require.NoError(t, q.Close())
+
+	_, err = queryable.Querier(user.InjectOrgID(context.Background(), "team-a|team-b|team-c"), 0, 1000)
+	require.EqualError(t, err, "the query spans too many tenants (limit: 2, actual: 3)")
+}
+
+// mockTenantQueryable returns the series of the tenant querying it.
+type mockTenantQueryable struct {
+	series map[string][]labels.Labels
+}
+
+func (m *mockTenantQueryable) Querier(ctx context.Context, _, _ int64) (storage.Querier, error) {
+	tenantID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mockTenantQuerier{series: m.series[tenantID]}, nil
+}
+
+type mockTenantQuerier struct {
+	series []labels.Labels
+}
+
+func (m *mockTenantQuerier) Select(_ bool, _ *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	var result []storage.Series
+
+outer:
+	for _, s := range m.series {
+		for _, matcher := range matchers {
+			if !matcher.Matches(s.Get(matcher.Name)) {
+				continue outer
+			}
+		}
+		result = append(result, series.NewConcreteSeries(s, []model.SamplePair{{Timestamp: 0, Value: 1}}))
+	}
+
+	return series.NewConcreteSeriesSet(result)
+}
+
+func (m *mockTenantQuerier) LabelValues(name string) ([]string, storage.Warnings, error) {
+	values := map[string]struct{}{}
+	for _, s := range m.series {
+		if value := s.Get(name); value != "" {
+			values[value] = struct{}{}
+		}
+	}
+	return sortedKeys(values), nil, nil
+}
+
+func (m *mockTenantQuerier) LabelNames() ([]string, storage.Warnings, error) {
+	names := map[string]struct{}{}
+	for _, s := range m.series {
+		for _, l := range s {
+			names[l.Name] = struct{}{}
+		}
+	}
+	return sortedKeys(names), nil, nil
+}
+
+func (m *mockTenantQuerier) Close() error {
+	return nil
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
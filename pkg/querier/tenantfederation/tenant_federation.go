@@ -0,0 +1,20 @@
+package tenantfederation
+
+import (
+	"flag"
+)
+
+// Config configures the tenant federation, allowing a query to span multiple tenants.
+type Config struct {
+	// Enabled switches on support for multi tenant query federation.
+	Enabled bool `yaml:"enabled"`
+
+	// MaxTenantsPerQuery is the max number of tenants a single query can span.
+	MaxTenantsPerQuery int `yaml:"max_tenants_per_query"`
+}
+
+// RegisterFlags registers the flags for the tenant federation config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tenant-federation.enabled", false, "If enabled on all Cortex services, queries can be federated across multiple tenants. The tenant IDs involved need to be specified separated by a `|` character in the `X-Scope-OrgID` header (experimental).")
+	f.IntVar(&cfg.MaxTenantsPerQuery, "tenant-federation.max-tenants-per-query", 0, "The max number of tenants a federated query can span. Queries exceeding this limit are rejected. 0 to disable.")
+}
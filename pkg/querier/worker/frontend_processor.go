@@ -12,6 +12,7 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/cortexproject/cortex/pkg/frontend/v1/frontendv1pb"
+	querier_stats "github.com/cortexproject/cortex/pkg/querier/stats"
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
@@ -99,7 +100,12 @@ func (fp *frontendProcessor) process(c frontendv1pb.Frontend_ProcessClient) erro
 }
 
 func (fp *frontendProcessor) runRequest(ctx context.Context, request *httpgrpc.HTTPRequest, sendHTTPResponse func(response *httpgrpc.HTTPResponse) error) {
+	stats, ctx := querier_stats.ContextWithEmptyStats(ctx)
+
+	startTime := time.Now()
 	response, err := fp.handler.Handle(ctx, request)
+	stats.AddWallTime(time.Since(startTime))
+
 	if err != nil {
 		var ok bool
 		response, ok = httpgrpc.HTTPResponseFromError(err)
@@ -121,6 +127,8 @@ func (fp *frontendProcessor) runRequest(ctx context.Context, request *httpgrpc.H
 		level.Error(fp.log).Log("msg", "error processing query", "err", errMsg)
 	}
 
+	injectQueryStats(response, stats)
+
 	if err := sendHTTPResponse(response); err != nil {
 		level.Error(fp.log).Log("msg", "error processing requests", "err", err)
 	}
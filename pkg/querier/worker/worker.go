@@ -14,6 +14,8 @@ import (
 	"github.com/weaveworks/common/httpgrpc"
 	"google.golang.org/grpc"
 
+	querier_stats "github.com/cortexproject/cortex/pkg/querier/stats"
+	"github.com/cortexproject/cortex/pkg/scheduler/schedulerdiscovery"
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/grpcclient"
 	"github.com/cortexproject/cortex/pkg/util/services"
@@ -31,6 +33,9 @@ type Config struct {
 	QuerierID string `yaml:"id"`
 
 	GRPCClientConfig grpcclient.ConfigWithTLS `yaml:"grpc_client_config"`
+
+	// Injected internally from the query-scheduler config.
+	QuerySchedulerDiscovery schedulerdiscovery.Config `yaml:"-"`
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
@@ -96,29 +101,37 @@ func NewQuerierWorker(cfg Config, handler RequestHandler, log log.Logger, reg pr
 
 	var processor processor
 	var servs []services.Service
-	var address string
+	var newDiscovery discoveryFactory
 
 	switch {
-	case cfg.SchedulerAddress != "":
-		level.Info(log).Log("msg", "Starting querier worker connected to query-scheduler", "scheduler", cfg.SchedulerAddress)
+	case cfg.SchedulerAddress != "" || cfg.QuerySchedulerDiscovery.Mode == schedulerdiscovery.ModeRing:
+		level.Info(log).Log("msg", "Starting querier worker connected to query-scheduler", "scheduler", cfg.SchedulerAddress, "service_discovery_mode", cfg.QuerySchedulerDiscovery.Mode)
 
-		address = cfg.SchedulerAddress
 		processor, servs = newSchedulerProcessor(cfg, handler, log, reg)
+		newDiscovery = func(notifications util.DNSNotifications) (services.Service, error) {
+			return schedulerdiscovery.New(cfg.QuerySchedulerDiscovery, cfg.SchedulerAddress, cfg.DNSLookupPeriod, "querier", notifications, log, reg)
+		}
 
 	case cfg.FrontendAddress != "":
 		level.Info(log).Log("msg", "Starting querier worker connected to query-frontend", "frontend", cfg.FrontendAddress)
 
-		address = cfg.FrontendAddress
 		processor = newFrontendProcessor(cfg, handler, log)
+		newDiscovery = func(notifications util.DNSNotifications) (services.Service, error) {
+			return util.NewDNSWatcher(cfg.FrontendAddress, cfg.DNSLookupPeriod, notifications)
+		}
 
 	default:
 		return nil, errors.New("no query-scheduler or query-frontend address")
 	}
 
-	return newQuerierWorkerWithProcessor(cfg, log, processor, address, servs)
+	return newQuerierWorkerWithProcessor(cfg, log, processor, newDiscovery, servs)
 }
 
-func newQuerierWorkerWithProcessor(cfg Config, log log.Logger, processor processor, address string, servs []services.Service) (*querierWorker, error) {
+// discoveryFactory makes the service discovering the query-frontends or query-schedulers
+// to connect to, and notifying their addresses to the input notifications.
+type discoveryFactory func(notifications util.DNSNotifications) (services.Service, error)
+
+func newQuerierWorkerWithProcessor(cfg Config, log log.Logger, processor processor, newDiscovery discoveryFactory, servs []services.Service) (*querierWorker, error) {
 	f := &querierWorker{
 		cfg:       cfg,
 		log:       log,
@@ -126,9 +139,9 @@ func newQuerierWorkerWithProcessor(cfg Config, log log.Logger, processor process
 		processor: processor,
 	}
 
-	// Empty address is only used in tests, where individual targets are added manually.
-	if address != "" {
-		w, err := util.NewDNSWatcher(address, cfg.DNSLookupPeriod, f)
+	// Nil discovery is only used in tests, where individual targets are added manually.
+	if newDiscovery != nil {
+		w, err := newDiscovery(f)
 		if err != nil {
 			return nil, err
 		}
@@ -264,3 +277,12 @@ func (w *querierWorker) connect(ctx context.Context, address string) (*grpc.Clie
 	}
 	return conn, nil
 }
+
+// injectQueryStats adds the query statistics to the response headers, so that they're
+// reported back to the query-frontend.
+func injectQueryStats(response *httpgrpc.HTTPResponse, stats *querier_stats.Stats) {
+	response.Headers = append(response.Headers, &httpgrpc.Header{
+		Key:    querier_stats.HeaderName,
+		Values: []string{stats.Encode()},
+	})
+}
@@ -135,14 +135,20 @@ func (d *AutoForgetDelegate) OnRingInstanceStopping(lifecycler *BasicLifecycler)
 }
 
 func (d *AutoForgetDelegate) OnRingInstanceHeartbeat(lifecycler *BasicLifecycler, ringDesc *Desc, instanceDesc *IngesterDesc) {
+	forgetUnhealthyInstances(ringDesc, d.forgetPeriod, time.Now(), d.logger)
+
+	d.next.OnRingInstanceHeartbeat(lifecycler, ringDesc, instanceDesc)
+}
+
+// forgetUnhealthyInstances removes from the ring all the instances whose last
+// heartbeat is older than the forget period.
+func forgetUnhealthyInstances(ringDesc *Desc, forgetPeriod time.Duration, now time.Time, logger log.Logger) {
 	for id, instance := range ringDesc.Ingesters {
 		lastHeartbeat := time.Unix(instance.GetTimestamp(), 0)
 
-		if time.Since(lastHeartbeat) > d.forgetPeriod {
-			level.Warn(d.logger).Log("msg", "auto-forgetting instance from the ring because it is unhealthy for a long time", "instance", id, "last_heartbeat", lastHeartbeat.String(), "forget_period", d.forgetPeriod)
+		if now.Sub(lastHeartbeat) > forgetPeriod {
+			level.Warn(logger).Log("msg", "auto-forgetting instance from the ring because it is unhealthy for a long time", "instance", id, "last_heartbeat", lastHeartbeat.String(), "forget_period", forgetPeriod)
 			ringDesc.RemoveIngester(id)
 		}
 	}
-
-	d.next.OnRingInstanceHeartbeat(lifecycler, ringDesc, instanceDesc)
 }
@@ -2,11 +2,13 @@ package ring
 
 import (
 	"context"
+	"encoding/csv"
 	"fmt"
 	"html/template"
 	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"time"
 
 	"github.com/go-kit/kit/log/level"
@@ -24,6 +26,33 @@ const pageContent = `
 	<body>
 		<h1>Cortex Ring Status</h1>
 		<p>Current time: {{ .Now }}</p>
+		<p>Export: <a href="?format=json">JSON</a> | <a href="?format=csv">CSV</a></p>
+		{{ if .Zones }}
+		<h2>Zones</h2>
+		<table border="1" cellpadding="5" style="border-collapse: collapse">
+			<thead>
+				<tr>
+					<th>Availability Zone</th>
+					<th>Instances</th>
+					<th>Healthy Instances</th>
+					<th>Tokens</th>
+					<th>Ownership</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range .Zones }}
+				<tr>
+					<td>{{ .Zone }}</td>
+					<td>{{ .NumInstances }}</td>
+					<td>{{ .NumHealthyInstances }}</td>
+					<td>{{ .NumTokens }}</td>
+					<td>{{ printf "%.2f" .Ownership }}%</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+		<h2>Instances</h2>
+		{{ end }}
 		<form action="" method="POST">
 			<input type="hidden" name="csrf_token" value="$__CSRF_TOKEN_PLACEHOLDER__">
 			<table width="100%" border="1">
@@ -41,7 +70,7 @@ const pageContent = `
 					</tr>
 				</thead>
 				<tbody>
-					{{ range $i, $ing := .Ingesters }}
+					{{ range $i, $ing := .Instances }}
 					{{ if mod $i 2 }}
 					<tr>
 					{{ else }}
@@ -54,7 +83,7 @@ const pageContent = `
 						<td>{{ .RegisteredTimestamp }}</td>
 						<td>{{ .HeartbeatTimestamp }}</td>
 						<td>{{ .NumTokens }}</td>
-						<td>{{ .Ownership }}%</td>
+						<td>{{ printf "%.2f" .Ownership }}%</td>
 						<td><button name="forget" value="{{ .ID }}" type="submit">Forget</button></td>
 					</tr>
 					{{ end }}
@@ -68,7 +97,7 @@ const pageContent = `
 			{{ end }}
 
 			{{ if .ShowTokens }}
-				{{ range $i, $ing := .Ingesters }}
+				{{ range $i, $ing := .Instances }}
 					<h2>Instance: {{ .ID }}</h2>
 					<p>
 						Tokens:<br />
@@ -122,22 +151,70 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	instances, zones := r.instancesStatus()
+
+	switch req.URL.Query().Get("format") {
+	case "json":
+		util.WriteJSONResponse(w, ringStatus{Instances: instances, Zones: zones, Now: time.Now()})
+		return
+	case "csv":
+		writeRingCSV(w, instances)
+		return
+	}
+
+	util.RenderHTTPResponse(w, ringStatus{
+		Instances:  instances,
+		Zones:      zones,
+		Now:        time.Now(),
+		ShowTokens: req.URL.Query().Get("tokens") == "true",
+	}, pageTemplate, req)
+}
+
+type ringStatus struct {
+	Instances  []instanceStatus `json:"shards"`
+	Zones      []zoneStatus     `json:"zones,omitempty"`
+	Now        time.Time        `json:"now"`
+	ShowTokens bool             `json:"-"`
+}
+
+// instanceStatus is the status of an instance shown in the ring page.
+type instanceStatus struct {
+	ID                  string   `json:"id"`
+	State               string   `json:"state"`
+	Address             string   `json:"address"`
+	HeartbeatTimestamp  string   `json:"timestamp"`
+	RegisteredTimestamp string   `json:"registered_timestamp"`
+	Zone                string   `json:"zone"`
+	Tokens              []uint32 `json:"tokens"`
+	NumTokens           int      `json:"num_tokens"`
+	Ownership           float64  `json:"ownership"`
+}
+
+// zoneStatus is the status of an availability zone shown in the ring page.
+type zoneStatus struct {
+	Zone                string  `json:"zone"`
+	NumInstances        int     `json:"num_instances"`
+	NumHealthyInstances int     `json:"num_healthy_instances"`
+	NumTokens           int     `json:"num_tokens"`
+	Ownership           float64 `json:"ownership"`
+}
+
+// instancesStatus returns the status of the ring instances, sorted by zone and ID, and the
+// status of each zone. The zones are returned only if the instances have a zone.
+func (r *Ring) instancesStatus() ([]instanceStatus, []zoneStatus) {
 	r.mtx.RLock()
 	defer r.mtx.RUnlock()
 
-	ingesterIDs := []string{}
-	for id := range r.ringDesc.Ingesters {
-		ingesterIDs = append(ingesterIDs, id)
-	}
-	sort.Strings(ingesterIDs)
+	instances := make([]instanceStatus, 0, len(r.ringDesc.Ingesters))
+	zonesByName := map[string]*zoneStatus{}
+	hasZones := false
 
-	ingesters := []interface{}{}
 	_, owned := countTokens(r.ringDesc, r.ringTokens)
-	for _, id := range ingesterIDs {
-		ing := r.ringDesc.Ingesters[id]
+	for id, ing := range r.ringDesc.Ingesters {
 		heartbeatTimestamp := time.Unix(ing.Timestamp, 0)
+		healthy := r.IsHealthy(&ing, Reporting)
 		state := ing.State.String()
-		if !r.IsHealthy(&ing, Reporting) {
+		if !healthy {
 			state = unhealthy
 		}
 
@@ -147,17 +224,7 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			registeredTimestamp = ing.GetRegisteredAt().String()
 		}
 
-		ingesters = append(ingesters, struct {
-			ID                  string   `json:"id"`
-			State               string   `json:"state"`
-			Address             string   `json:"address"`
-			HeartbeatTimestamp  string   `json:"timestamp"`
-			RegisteredTimestamp string   `json:"registered_timestamp"`
-			Zone                string   `json:"zone"`
-			Tokens              []uint32 `json:"tokens"`
-			NumTokens           int      `json:"-"`
-			Ownership           float64  `json:"-"`
-		}{
+		instance := instanceStatus{
 			ID:                  id,
 			State:               state,
 			Address:             ing.Addr,
@@ -167,18 +234,65 @@ func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			Zone:                ing.Zone,
 			NumTokens:           len(ing.Tokens),
 			Ownership:           (float64(owned[id]) / float64(math.MaxUint32)) * 100,
-		})
+		}
+		instances = append(instances, instance)
+
+		zone := zonesByName[ing.Zone]
+		if zone == nil {
+			zone = &zoneStatus{Zone: ing.Zone}
+			zonesByName[ing.Zone] = zone
+		}
+		zone.NumInstances++
+		zone.NumTokens += instance.NumTokens
+		zone.Ownership += instance.Ownership
+		if healthy {
+			zone.NumHealthyInstances++
+		}
+		hasZones = hasZones || ing.Zone != ""
 	}
 
-	tokensParam := req.URL.Query().Get("tokens")
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Zone != instances[j].Zone {
+			return instances[i].Zone < instances[j].Zone
+		}
+		return instances[i].ID < instances[j].ID
+	})
 
-	util.RenderHTTPResponse(w, struct {
-		Ingesters  []interface{} `json:"shards"`
-		Now        time.Time     `json:"now"`
-		ShowTokens bool          `json:"-"`
-	}{
-		Ingesters:  ingesters,
-		Now:        time.Now(),
-		ShowTokens: tokensParam == "true",
-	}, pageTemplate, req)
+	if !hasZones {
+		return instances, nil
+	}
+
+	zones := make([]zoneStatus, 0, len(zonesByName))
+	for _, zone := range zonesByName {
+		zones = append(zones, *zone)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Zone < zones[j].Zone
+	})
+
+	return instances, zones
+}
+
+// writeRingCSV writes the ring instances in CSV format, with a header row.
+func writeRingCSV(w http.ResponseWriter, instances []instanceStatus) {
+	w.Header().Set("Content-Type", "text/csv")
+
+	cw := csv.NewWriter(w)
+	records := [][]string{{"id", "zone", "state", "address", "registered_timestamp", "heartbeat_timestamp", "tokens", "ownership"}}
+	for _, instance := range instances {
+		records = append(records, []string{
+			instance.ID,
+			instance.Zone,
+			instance.State,
+			instance.Address,
+			instance.RegisteredTimestamp,
+			instance.HeartbeatTimestamp,
+			strconv.Itoa(instance.NumTokens),
+			strconv.FormatFloat(instance.Ownership, 'f', 2, 64),
+		})
+	}
+
+	if err := cw.WriteAll(records); err != nil {
+		level.Error(util.Logger).Log("msg", "error writing the ring status in CSV format", "err", err)
+	}
 }
@@ -0,0 +1,80 @@
+package ring
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing_ServeHTTP(t *testing.T) {
+	now := time.Now()
+
+	desc := NewDesc()
+	desc.AddIngester("instance-2", "127.0.0.2", "zone-a", []uint32{math.MaxUint32 / 4}, ACTIVE, now)
+	desc.AddIngester("instance-1", "127.0.0.1", "zone-b", []uint32{math.MaxUint32 / 2}, ACTIVE, now)
+	desc.AddIngester("instance-3", "127.0.0.3", "zone-a", []uint32{math.MaxUint32 / 4 * 3}, ACTIVE, now)
+
+	// Make an instance unhealthy.
+	unhealthyInstance := desc.Ingesters["instance-3"]
+	unhealthyInstance.Timestamp = now.Add(-time.Hour).Unix()
+	desc.Ingesters["instance-3"] = unhealthyInstance
+
+	r := Ring{
+		cfg:              Config{HeartbeatTimeout: time.Minute},
+		ringDesc:         desc,
+		ringTokens:       desc.getTokens(),
+		ringTokensByZone: desc.getTokensByZone(),
+		ringZones:        getZones(desc.getTokensByZone()),
+		strategy:         NewDefaultReplicationStrategy(true),
+	}
+
+	t.Run("html", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/ring", nil))
+
+		assert.Equal(t, 200, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<h2>Zones</h2>")
+		assert.Contains(t, rec.Body.String(), `<button name="forget" value="instance-1" type="submit">Forget</button>`)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/ring?format=json", nil))
+
+		var status ringStatus
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+
+		// The instances are grouped by zone.
+		require.Len(t, status.Instances, 3)
+		assert.Equal(t, "instance-2", status.Instances[0].ID)
+		assert.Equal(t, "instance-3", status.Instances[1].ID)
+		assert.Equal(t, "instance-1", status.Instances[2].ID)
+		assert.Equal(t, unhealthy, status.Instances[1].State)
+		assert.InDelta(t, 25, status.Instances[2].Ownership, 0.01)
+
+		require.Len(t, status.Zones, 2)
+		assert.Equal(t, zoneStatus{Zone: "zone-a", NumInstances: 2, NumHealthyInstances: 1, NumTokens: 2, Ownership: status.Instances[0].Ownership + status.Instances[1].Ownership}, status.Zones[0])
+		assert.Equal(t, "zone-b", status.Zones[1].Zone)
+		assert.InDelta(t, 100, status.Zones[0].Ownership+status.Zones[1].Ownership, 0.01)
+	})
+
+	t.Run("csv", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", "/ring?format=csv", nil))
+
+		assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 4)
+		assert.Equal(t, []string{"id", "zone", "state", "address", "registered_timestamp", "heartbeat_timestamp", "tokens", "ownership"}, records[0])
+		assert.Equal(t, []string{"instance-2", "zone-a", "ACTIVE", "127.0.0.2"}, records[1][:4])
+		assert.Equal(t, []string{"1", "25.00"}, records[3][6:])
+	})
+}
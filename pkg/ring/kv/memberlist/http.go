@@ -0,0 +1,220 @@
+package memberlist
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/hashicorp/memberlist"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+const (
+	// The rate of updates of a key is computed over a 1 minute window, in 10 seconds buckets.
+	keyStatsBuckets        = 6
+	keyStatsBucketDuration = 10 // seconds
+)
+
+// keyStats holds the stats of a key in the KV store, displayed on the status page.
+type keyStats struct {
+	lastUpdate        time.Time
+	droppedBroadcasts int
+
+	buckets      [keyStatsBuckets]int
+	bucketSlots  [keyStatsBuckets]int64
+	totalUpdates int
+}
+
+func (s *keyStats) recordUpdate(now time.Time) {
+	slot := now.Unix() / keyStatsBucketDuration
+	i := slot % keyStatsBuckets
+
+	if s.bucketSlots[i] != slot {
+		s.bucketSlots[i] = slot
+		s.buckets[i] = 0
+	}
+
+	s.buckets[i]++
+	s.totalUpdates++
+	s.lastUpdate = now
+}
+
+// updatesRate returns the per-second rate of updates over the last minute.
+func (s *keyStats) updatesRate(now time.Time) float64 {
+	slot := now.Unix() / keyStatsBucketDuration
+
+	updates := 0
+	for i := range s.buckets {
+		if slot-s.bucketSlots[i] < keyStatsBuckets {
+			updates += s.buckets[i]
+		}
+	}
+
+	return float64(updates) / (keyStatsBuckets * keyStatsBucketDuration)
+}
+
+// getKeyStats returns the stats of the given key. Must be called with storeMu locked.
+func (m *KV) getKeyStats(key string) *keyStats {
+	s, ok := m.stats[key]
+	if !ok {
+		s = &keyStats{}
+		m.stats[key] = s
+	}
+	return s
+}
+
+const statusPageContent = `
+<!DOCTYPE html>
+<html>
+	<head>
+		<meta charset="UTF-8">
+		<title>Cortex Memberlist Status</title>
+	</head>
+	<body>
+		<h1>Cortex Memberlist Status</h1>
+		<p>Current time: {{ .Now }}</p>
+		<p>Messages in the broadcast queue: {{ .QueuedBroadcasts }}</p>
+
+		<h2>KV Store</h2>
+		<table width="100%" border="1">
+			<thead>
+				<tr>
+					<th>Key</th>
+					<th>Codec</th>
+					<th>Version</th>
+					<th>Size (bytes)</th>
+					<th>Updates (per second, last minute)</th>
+					<th>Total Updates</th>
+					<th>Last Update</th>
+					<th>Dropped Broadcasts</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range .Keys }}
+				<tr>
+					<td>{{ .Key }}</td>
+					<td>{{ .Codec }}</td>
+					<td>{{ .Version }}</td>
+					<td>{{ .Size }}</td>
+					<td>{{ printf "%.2f" .UpdatesRate }}</td>
+					<td>{{ .TotalUpdates }}</td>
+					<td>{{ .LastUpdate }}</td>
+					<td>{{ .DroppedBroadcasts }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+
+		<h2>Members</h2>
+		<table width="100%" border="1">
+			<thead>
+				<tr>
+					<th>Name</th>
+					<th>Address</th>
+					<th>State</th>
+				</tr>
+			</thead>
+			<tbody>
+				{{ range .Members }}
+				<tr>
+					<td>{{ .Name }}</td>
+					<td>{{ .Address }}</td>
+					<td>{{ .State }}</td>
+				</tr>
+				{{ end }}
+			</tbody>
+		</table>
+	</body>
+</html>`
+
+var statusPageTemplate = template.Must(template.New("webpage").Parse(statusPageContent))
+
+type statusPageKey struct {
+	Key               string
+	Codec             string
+	Version           uint
+	Size              int
+	UpdatesRate       float64
+	TotalUpdates      int
+	LastUpdate        time.Time
+	DroppedBroadcasts int
+}
+
+type statusPageMember struct {
+	Name    string
+	Address string
+	State   string
+}
+
+type statusPageData struct {
+	Now              time.Time
+	QueuedBroadcasts int
+	Keys             []statusPageKey
+	Members          []statusPageMember
+}
+
+func (m *KV) statusPageData(now time.Time) statusPageData {
+	data := statusPageData{Now: now}
+
+	m.storeMu.Lock()
+	for key, val := range m.store {
+		s := m.getKeyStats(key)
+		data.Keys = append(data.Keys, statusPageKey{
+			Key:               key,
+			Codec:             val.codecID,
+			Version:           val.version,
+			Size:              len(val.value),
+			UpdatesRate:       s.updatesRate(now),
+			TotalUpdates:      s.totalUpdates,
+			LastUpdate:        s.lastUpdate,
+			DroppedBroadcasts: s.droppedBroadcasts,
+		})
+	}
+	m.storeMu.Unlock()
+
+	sort.Slice(data.Keys, func(i, j int) bool { return data.Keys[i].Key < data.Keys[j].Key })
+
+	// The memberlist and broadcasts fields are not set before Starting state.
+	if s := m.State(); s == services.Running || s == services.Stopping {
+		data.QueuedBroadcasts = m.broadcasts.NumQueued()
+
+		for _, n := range m.memberlist.Members() {
+			data.Members = append(data.Members, statusPageMember{
+				Name:    n.Name,
+				Address: n.Address(),
+				State:   nodeStateName(n.State),
+			})
+		}
+		sort.Slice(data.Members, func(i, j int) bool { return data.Members[i].Name < data.Members[j].Name })
+	}
+
+	return data
+}
+
+func nodeStateName(s memberlist.NodeStateType) string {
+	switch s {
+	case memberlist.StateAlive:
+		return "Alive"
+	case memberlist.StateSuspect:
+		return "Suspect"
+	case memberlist.StateDead:
+		return "Dead"
+	case memberlist.StateLeft:
+		return "Left"
+	default:
+		return "Unknown"
+	}
+}
+
+// ServeHTTP renders the memberlist status page, with the members of the cluster
+// and the per-key update rates and dropped broadcast messages.
+func (m *KV) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if err := statusPageTemplate.Execute(w, m.statusPageData(time.Now())); err != nil {
+		level.Error(util.Logger).Log("msg", "unable to serve memberlist status page", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
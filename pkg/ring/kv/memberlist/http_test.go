@@ -0,0 +1,71 @@
+package memberlist
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestKeyStats_UpdatesRate(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := &keyStats{}
+
+	assert.Equal(t, float64(0), s.updatesRate(now))
+
+	// 6 updates over the last minute.
+	for i := 0; i < 6; i++ {
+		s.recordUpdate(now.Add(time.Duration(-i*10) * time.Second))
+	}
+	assert.Equal(t, 0.1, s.updatesRate(now))
+	assert.Equal(t, 6, s.totalUpdates)
+
+	// Updates older than a minute are not accounted in the rate.
+	assert.Equal(t, 0.05, s.updatesRate(now.Add(30*time.Second)))
+	assert.Equal(t, float64(0), s.updatesRate(now.Add(2*time.Minute)))
+
+	// A bucket is reset when reused for a new period.
+	s.recordUpdate(now.Add(time.Minute))
+	assert.Equal(t, float64(1)/60, s.updatesRate(now.Add(time.Minute)))
+}
+
+func TestKVInitService_ServeHTTP(t *testing.T) {
+	cfg := &KVConfig{
+		TCPTransport: TCPTransportConfig{
+			BindAddrs: []string{"localhost"},
+		},
+		Codecs: []codec.Codec{dataCodec{}},
+	}
+
+	kvs := NewKVInitService(cfg, log.NewNopLogger())
+
+	// The KV is not initialized by the status page.
+	rec := httptest.NewRecorder()
+	kvs.ServeHTTP(rec, httptest.NewRequest("GET", "/memberlist", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Body.String(), "doesn't use memberlist")
+	assert.Nil(t, kvs.getKV())
+
+	mkv, err := kvs.GetMemberlistKV()
+	require.NoError(t, err)
+	require.NoError(t, mkv.AwaitRunning(context.Background()))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv) //nolint:errcheck
+
+	client, err := NewClient(mkv, dataCodec{})
+	require.NoError(t, err)
+	cas(t, client, key, updateFn("Ing 1"))
+
+	rec = httptest.NewRecorder()
+	kvs.ServeHTTP(rec, httptest.NewRequest("GET", "/memberlist", nil))
+	assert.Equal(t, 200, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<td>"+key+"</td>")
+	assert.Contains(t, rec.Body.String(), "<td>testDataCodec</td>")
+	assert.Contains(t, rec.Body.String(), "<td>Alive</td>")
+}
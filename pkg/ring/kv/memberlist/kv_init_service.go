@@ -2,6 +2,7 @@ package memberlist
 
 import (
 	"context"
+	"net/http"
 	"sync"
 
 	"github.com/go-kit/kit/log"
@@ -22,6 +23,7 @@ type KVInitService struct {
 	init sync.Once
 
 	// state
+	kvMtx   sync.Mutex // Protects kv, which is read by the status page without initializing it.
 	kv      *KV
 	err     error
 	watcher *services.FailureWatcher
@@ -40,12 +42,23 @@ func NewKVInitService(cfg *KVConfig, logger log.Logger) *KVInitService {
 // This method will initialize Memberlist.KV on first call, and add it to service failure watcher.
 func (kvs *KVInitService) GetMemberlistKV() (*KV, error) {
 	kvs.init.Do(func() {
-		kvs.kv = NewKV(*kvs.cfg, kvs.logger)
-		kvs.watcher.WatchService(kvs.kv)
-		kvs.err = kvs.kv.StartAsync(context.Background())
+		kv := NewKV(*kvs.cfg, kvs.logger)
+		kvs.watcher.WatchService(kv)
+		kvs.err = kv.StartAsync(context.Background())
+
+		kvs.kvMtx.Lock()
+		kvs.kv = kv
+		kvs.kvMtx.Unlock()
 	})
 
-	return kvs.kv, kvs.err
+	return kvs.getKV(), kvs.err
+}
+
+// getKV returns the KV, or nil if it hasn't been initialized yet.
+func (kvs *KVInitService) getKV() *KV {
+	kvs.kvMtx.Lock()
+	defer kvs.kvMtx.Unlock()
+	return kvs.kv
 }
 
 func (kvs *KVInitService) running(ctx context.Context) error {
@@ -59,9 +72,24 @@ func (kvs *KVInitService) running(ctx context.Context) error {
 }
 
 func (kvs *KVInitService) stopping(_ error) error {
-	if kvs.kv == nil {
+	kv := kvs.getKV()
+	if kv == nil {
 		return nil
 	}
 
-	return services.StopAndAwaitTerminated(context.Background(), kvs.kv)
+	return services.StopAndAwaitTerminated(context.Background(), kv)
+}
+
+// ServeHTTP serves the memberlist status page. The KV is not initialized by this
+// method: if no component uses it, a message is rendered instead.
+func (kvs *KVInitService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	kv := kvs.getKV()
+	if kv == nil {
+		w.Header().Set("Content-Type", "text/plain")
+		// Ignore inactionable errors.
+		_, _ = w.Write([]byte("This instance doesn't use memberlist."))
+		return
+	}
+
+	kv.ServeHTTP(w, req)
 }
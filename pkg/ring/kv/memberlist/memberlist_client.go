@@ -29,6 +29,11 @@ import (
 const (
 	maxCasRetries              = 10          // max retries in CAS operation
 	noChangeDetectedRetrySleep = time.Second // how long to sleep after no change was detected in CAS
+
+	// Max size of a broadcast message. Memberlist will happily let us send bigger messages via gossip,
+	// but then it will fail to parse them properly, because its own size field is 2-bytes only.
+	// (github.com/hashicorp/memberlist@v0.1.4/util.go:167, makeCompoundMessage function)
+	maxBroadcastMessageSize = math.MaxUint16
 )
 
 // Client implements kv.Client interface, by using memberlist.KV
@@ -135,6 +140,7 @@ type KVConfig struct {
 	GossipNodes         int           `yaml:"gossip_nodes"`
 	GossipToTheDeadTime time.Duration `yaml:"gossip_to_dead_nodes_time"`
 	DeadNodeReclaimTime time.Duration `yaml:"dead_node_reclaim_time"`
+	EnableCompression   bool          `yaml:"compression_enabled"`
 
 	// List of members to join
 	JoinMembers      flagext.StringSlice `yaml:"join_members"`
@@ -180,6 +186,7 @@ func (cfg *KVConfig) RegisterFlags(f *flag.FlagSet, prefix string) {
 	f.DurationVar(&cfg.PushPullInterval, prefix+"memberlist.pullpush-interval", 0, "How often to use pull/push sync. Uses memberlist LAN defaults if 0.")
 	f.DurationVar(&cfg.GossipToTheDeadTime, prefix+"memberlist.gossip-to-dead-nodes-time", 0, "How long to keep gossiping to dead nodes, to give them chance to refute their death. Uses memberlist LAN defaults if 0.")
 	f.DurationVar(&cfg.DeadNodeReclaimTime, prefix+"memberlist.dead-node-reclaim-time", 0, "How soon can dead node's name be reclaimed with new address. Defaults to 0, which is disabled.")
+	f.BoolVar(&cfg.EnableCompression, prefix+"memberlist.compression-enabled", true, "Enable message compression. This can be used to reduce bandwidth usage at the cost of slightly more CPU utilization.")
 
 	cfg.TCPTransport.RegisterFlags(f, prefix)
 }
@@ -216,6 +223,7 @@ type KV struct {
 	// KV Store.
 	storeMu sync.Mutex
 	store   map[string]valueDesc
+	stats   map[string]*keyStats // Per-key stats, displayed on the status page.
 
 	// Codec registry
 	codecs map[string]codec.Codec
@@ -242,6 +250,7 @@ type KV struct {
 	casFailures                         prometheus.Counter
 	casSuccesses                        prometheus.Counter
 	watchPrefixDroppedNotifications     *prometheus.CounterVec
+	droppedBroadcasts                   prometheus.Counter
 
 	storeValuesDesc *prometheus.Desc
 	storeSizesDesc  *prometheus.Desc
@@ -294,6 +303,7 @@ func NewKV(cfg KVConfig, logger log.Logger) *KV {
 		logger:         logger,
 		provider:       dns.NewProvider(logger, mr, dns.GolangResolverType),
 		store:          make(map[string]valueDesc),
+		stats:          make(map[string]*keyStats),
 		codecs:         make(map[string]codec.Codec),
 		watchers:       make(map[string][]chan string),
 		prefixWatchers: make(map[string][]chan string),
@@ -344,6 +354,7 @@ func (m *KV) buildMemberlistConfig() (*memberlist.Config, error) {
 	if m.cfg.NodeName != "" {
 		mlCfg.Name = m.cfg.NodeName
 	}
+	mlCfg.EnableCompression = m.cfg.EnableCompression
 	if m.cfg.RandomizeNodeName {
 		mlCfg.Name = mlCfg.Name + "-" + generateRandomSuffix()
 		level.Info(m.logger).Log("msg", "Using memberlist cluster node name", "name", mlCfg.Name)
@@ -850,30 +861,60 @@ func (m *KV) trySingleCas(key string, codec codec.Codec, f func(in interface{})
 }
 
 func (m *KV) broadcastNewValue(key string, change Mergeable, version uint, codec codec.Codec) {
-	data, err := codec.Encode(change)
+	pairData, err := encodeKVPair(key, change, codec)
 	if err != nil {
-		level.Error(m.logger).Log("msg", "failed to encode change", "err", err)
+		level.Error(m.logger).Log("msg", "failed to encode change", "key", key, "err", err)
 		return
 	}
 
-	kvPair := KeyValuePair{Key: key, Value: data, Codec: codec.CodecID()}
-	pairData, err := kvPair.Marshal()
-	if err != nil {
-		level.Error(m.logger).Log("msg", "failed to serialize KV pair", "err", err)
+	if len(pairData) <= maxBroadcastMessageSize {
+		m.queueBroadcast(key, change.MergeContent(), version, pairData)
+		return
 	}
 
-	if len(pairData) > 65535 {
-		// Unfortunately, memberlist will happily let us send bigger messages via gossip,
-		// but then it will fail to parse them properly, because its own size field is 2-bytes only.
-		// (github.com/hashicorp/memberlist@v0.1.4/util.go:167, makeCompoundMessage function)
-		//
-		// Typically messages are smaller (when dealing with couple of updates only), but can get bigger
-		// when broadcasting result of push/pull update.
-		level.Debug(m.logger).Log("msg", "broadcast message too big, not broadcasting", "len", len(pairData))
+	// Typically messages are smaller (when dealing with couple of updates only), but can get bigger
+	// when broadcasting result of push/pull update, or in very large rings. If supported by the value,
+	// the change is split and its parts are broadcast separately.
+	splittable, ok := change.(Splittable)
+	if !ok {
+		level.Debug(m.logger).Log("msg", "broadcast message too big, not broadcasting", "key", key, "len", len(pairData))
+		m.recordDroppedBroadcast(key)
 		return
 	}
 
-	m.queueBroadcast(key, change.MergeContent(), version, pairData)
+	for _, part := range splittable.Split() {
+		partData, err := encodeKVPair(key, part, codec)
+		if err != nil {
+			level.Error(m.logger).Log("msg", "failed to encode change", "key", key, "err", err)
+			continue
+		}
+
+		if len(partData) > maxBroadcastMessageSize {
+			level.Debug(m.logger).Log("msg", "broadcast message too big, not broadcasting", "key", key, "len", len(partData))
+			m.recordDroppedBroadcast(key)
+			continue
+		}
+
+		m.queueBroadcast(key, part.MergeContent(), version, partData)
+	}
+}
+
+func encodeKVPair(key string, value Mergeable, codec codec.Codec) ([]byte, error) {
+	data, err := codec.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	kvPair := KeyValuePair{Key: key, Value: data, Codec: codec.CodecID()}
+	return kvPair.Marshal()
+}
+
+func (m *KV) recordDroppedBroadcast(key string) {
+	m.droppedBroadcasts.Inc()
+
+	m.storeMu.Lock()
+	defer m.storeMu.Unlock()
+	m.getKeyStats(key).droppedBroadcasts++
 }
 
 // NodeMeta is method from Memberlist Delegate interface
@@ -1119,6 +1160,7 @@ func (m *KV) mergeValueForKey(key string, incomingValue Mergeable, casVersion ui
 		version: newVersion,
 		codecID: codec.CodecID(),
 	}
+	m.getKeyStats(key).recordUpdate(time.Now())
 
 	return change, newVersion, nil
 }
@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
@@ -86,6 +88,14 @@ func (d *data) RemoveTombstones(limit time.Time) {
 	// nothing to do
 }
 
+func (d *data) Split() []Mergeable {
+	out := []Mergeable(nil)
+	for k, v := range d.Members {
+		out = append(out, &data{Members: map[string]member{k: v}})
+	}
+	return out
+}
+
 func (d *data) getAllTokens() []uint32 {
 	out := []uint32(nil)
 	for _, m := range d.Members {
@@ -1030,3 +1040,61 @@ func TestRejoin(t *testing.T) {
 
 	test.Poll(t, 5*time.Second, 2, membersFunc)
 }
+
+func TestBroadcastNewValue_ShouldSplitLargeChanges(t *testing.T) {
+	cfg := KVConfig{
+		TCPTransport: TCPTransportConfig{
+			BindAddrs: []string{"localhost"},
+		},
+		Codecs: []codec.Codec{dataCodec{}},
+	}
+
+	mkv := NewKV(cfg, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv) //nolint:errcheck
+
+	// Build a change too big to be gossiped in a single message.
+	change := &data{Members: map[string]member{}}
+	for i := 0; i < 100; i++ {
+		change.Members[fmt.Sprintf("Ing %d", i)] = member{Timestamp: time.Now().Unix(), Tokens: generateTokens(512), State: ACTIVE}
+	}
+
+	data, err := encodeKVPair(key, change, dataCodec{})
+	require.NoError(t, err)
+	require.Greater(t, len(data), maxBroadcastMessageSize)
+
+	mkv.broadcastNewValue(key, change, 1, dataCodec{})
+
+	// Each member has been broadcast separately.
+	assert.Equal(t, 100, mkv.broadcasts.NumQueued())
+	assert.Equal(t, float64(0), testutil.ToFloat64(mkv.droppedBroadcasts))
+}
+
+func TestBroadcastNewValue_ShouldDropLargeChangesWhichCannotBeSplit(t *testing.T) {
+	cfg := KVConfig{
+		TCPTransport: TCPTransportConfig{
+			BindAddrs: []string{"localhost"},
+		},
+		Codecs: []codec.Codec{distributedCounterCodec{}},
+	}
+
+	mkv := NewKV(cfg, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv) //nolint:errcheck
+
+	change := distributedCounter{}
+	for i := 0; i < 10000; i++ {
+		change[fmt.Sprintf("counter-%d", i)] = i
+	}
+
+	mkv.broadcastNewValue(key, change, 1, distributedCounterCodec{})
+
+	assert.Equal(t, 0, mkv.broadcasts.NumQueued())
+	assert.Equal(t, float64(1), testutil.ToFloat64(mkv.droppedBroadcasts))
+
+	pageData := mkv.statusPageData(time.Now())
+	require.Len(t, pageData.Keys, 0)
+	mkv.storeMu.Lock()
+	assert.Equal(t, 1, mkv.getKeyStats(key).droppedBroadcasts)
+	mkv.storeMu.Unlock()
+}
@@ -40,3 +40,12 @@ type Mergeable interface {
 	// time when client is accessing value from the store. It can be used to hide tombstones from the clients.
 	RemoveTombstones(limit time.Time)
 }
+
+// Splittable is an optional interface of Mergeable values. When a change is too large to be
+// broadcast in a single gossip message, the memberlist client splits it and broadcasts the parts
+// separately, instead of relying on the periodic full state push/pull to propagate it.
+type Splittable interface {
+	// Split the value into smaller values which, merged together, are equal to the value.
+	// Each part should describe a single entry of the MergeContent.
+	Split() []Mergeable
+}
@@ -77,6 +77,13 @@ func (m *KV) createAndRegisterMetrics() {
 		return 0
 	})
 
+	m.droppedBroadcasts = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: m.cfg.MetricsNamespace,
+		Subsystem: subsystem,
+		Name:      "messages_to_broadcast_dropped_total",
+		Help:      "Number of broadcast messages dropped because too big to be gossiped",
+	})
+
 	m.totalSizeOfBroadcastMessagesInQueue = prometheus.NewGauge(prometheus.GaugeOpts{
 		Namespace: m.cfg.MetricsNamespace,
 		Subsystem: subsystem,
@@ -162,6 +169,7 @@ func (m *KV) createAndRegisterMetrics() {
 		m.totalSizeOfPushes,
 		m.totalSizeOfPulls,
 		m.totalSizeOfBroadcastMessagesInQueue,
+		m.droppedBroadcasts,
 		m.casAttempts,
 		m.casFailures,
 		m.casSuccesses,
@@ -3,6 +3,8 @@ package memberlist
 import (
 	"bytes"
 	"crypto/md5"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -19,6 +21,7 @@ import (
 	"go.uber.org/atomic"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+	cortextls "github.com/cortexproject/cortex/pkg/util/tls"
 )
 
 type messageType uint8
@@ -53,6 +56,11 @@ type TCPTransportConfig struct {
 	// Transport logs lot of messages at debug level, so it deserves an extra flag for turning it on
 	TransportDebug bool `yaml:"-"`
 
+	// TLS options used both to accept connections from, and to connect to, other members.
+	TLSEnabled    bool                   `yaml:"tls_enabled"`
+	TLSServerName string                 `yaml:"tls_server_name"`
+	TLS           cortextls.ClientConfig `yaml:",inline"`
+
 	// Where to put custom metrics. nil = don't register.
 	MetricsRegisterer prometheus.Registerer `yaml:"-"`
 	MetricsNamespace  string                `yaml:"-"`
@@ -66,6 +74,40 @@ func (cfg *TCPTransportConfig) RegisterFlags(f *flag.FlagSet, prefix string) {
 	f.DurationVar(&cfg.PacketDialTimeout, prefix+"memberlist.packet-dial-timeout", 5*time.Second, "Timeout used when connecting to other nodes to send packet.")
 	f.DurationVar(&cfg.PacketWriteTimeout, prefix+"memberlist.packet-write-timeout", 5*time.Second, "Timeout for writing 'packet' data.")
 	f.BoolVar(&cfg.TransportDebug, prefix+"memberlist.transport-debug", false, "Log debug transport messages. Note: global log.level must be at debug level as well.")
+	f.BoolVar(&cfg.TLSEnabled, prefix+"memberlist.tls-enabled", false, "Enable TLS on the memberlist transport. The certificate and key are used both to accept connections from, and to connect to, other members. When the CA is configured, the members certificates are verified against it in both directions.")
+	f.StringVar(&cfg.TLSServerName, prefix+"memberlist.tls-server-name", "", "Override the expected name on the certificate of the other members. Useful when the members are addressed by IP.")
+	cfg.TLS.RegisterFlagsWithPrefix(prefix+"memberlist", f)
+}
+
+var errMemberlistTLSCertMissing = errors.New("the memberlist TLS certificate and key must be configured when TLS is enabled")
+
+// buildTLSConfigs returns the TLS configs used to accept and open connections, or nil if TLS is disabled.
+func (cfg *TCPTransportConfig) buildTLSConfigs() (serverCfg, clientCfg *tls.Config, _ error) {
+	if !cfg.TLSEnabled {
+		return nil, nil, nil
+	}
+
+	if cfg.TLS.CertPath == "" || cfg.TLS.KeyPath == "" {
+		return nil, nil, errMemberlistTLSCertMissing
+	}
+
+	clientCfg, err := cfg.TLS.GetTLSConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+	clientCfg.ServerName = cfg.TLSServerName
+
+	serverCfg = &tls.Config{
+		Certificates: clientCfg.Certificates,
+	}
+
+	// The members authenticate each other with certificates issued by the configured CA.
+	if clientCfg.RootCAs != nil {
+		serverCfg.ClientCAs = clientCfg.RootCAs
+		serverCfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return serverCfg, clientCfg, nil
 }
 
 // TCPTransport is a memberlist.Transport implementation that uses TCP for both packet and stream
@@ -79,6 +121,10 @@ type TCPTransport struct {
 	wg           sync.WaitGroup
 	tcpListeners []*net.TCPListener
 
+	// TLS configs, nil if TLS is disabled.
+	tlsServerConfig *tls.Config
+	tlsClientConfig *tls.Config
+
 	shutdown atomic.Int32
 
 	advertiseMu   sync.RWMutex
@@ -114,6 +160,12 @@ func NewTCPTransport(config TCPTransportConfig, logger log.Logger) (*TCPTranspor
 		connCh:   make(chan net.Conn),
 	}
 
+	var err error
+	t.tlsServerConfig, t.tlsClientConfig, err = config.buildTLSConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build TLS config: %v", err)
+	}
+
 	t.registerMetrics()
 
 	// Clean up listeners if there's an error.
@@ -193,7 +245,11 @@ func (t *TCPTransport) tcpListen(tcpLn *net.TCPListener) {
 		// No error, reset loop delay
 		loopDelay = 0
 
-		go t.handleConnection(conn)
+		if t.tlsServerConfig != nil {
+			go t.handleConnection(tls.Server(conn, t.tlsServerConfig))
+		} else {
+			go t.handleConnection(conn)
+		}
 	}
 }
 
@@ -206,7 +262,7 @@ func (t *TCPTransport) debugLog() log.Logger {
 	return noopLogger
 }
 
-func (t *TCPTransport) handleConnection(conn *net.TCPConn) {
+func (t *TCPTransport) handleConnection(conn net.Conn) {
 	t.debugLog().Log("msg", "TCPTransport: New connection", "addr", conn.RemoteAddr())
 
 	closeConn := true
@@ -396,7 +452,7 @@ func (t *TCPTransport) WriteTo(b []byte, addr string) (time.Time, error) {
 
 func (t *TCPTransport) writeTo(b []byte, addr string) error {
 	// Open connection, write packet header and data, data hash, close. Simple.
-	c, err := net.DialTimeout("tcp", addr, t.cfg.PacketDialTimeout)
+	c, err := t.dial(addr, t.cfg.PacketDialTimeout)
 	if err != nil {
 		return nil
 	}
@@ -477,7 +533,7 @@ func (t *TCPTransport) PacketCh() <-chan *memberlist.Packet {
 func (t *TCPTransport) DialTimeout(addr string, timeout time.Duration) (net.Conn, error) {
 	t.outgoingStreams.Inc()
 
-	c, err := net.DialTimeout("tcp", addr, timeout)
+	c, err := t.dial(addr, timeout)
 	if err != nil {
 		t.outgoingStreamErrors.Inc()
 		return nil, err
@@ -493,6 +549,14 @@ func (t *TCPTransport) DialTimeout(addr string, timeout time.Duration) (net.Conn
 	return c, nil
 }
 
+// dial opens a connection to the given address, over TLS if enabled.
+func (t *TCPTransport) dial(addr string, timeout time.Duration) (net.Conn, error) {
+	if t.tlsClientConfig != nil {
+		return tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, t.tlsClientConfig)
+	}
+	return net.DialTimeout("tcp", addr, timeout)
+}
+
 // StreamCh returns a channel that can be read to handle incoming stream
 // connections from other peers.
 func (t *TCPTransport) StreamCh() <-chan net.Conn {
@@ -0,0 +1,118 @@
+package memberlist
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/integration/ca"
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+	cortextls "github.com/cortexproject/cortex/pkg/util/tls"
+)
+
+func TestTCPTransportConfig_BuildTLSConfigs(t *testing.T) {
+	cfg := TCPTransportConfig{}
+	serverCfg, clientCfg, err := cfg.buildTLSConfigs()
+	require.NoError(t, err)
+	assert.Nil(t, serverCfg)
+	assert.Nil(t, clientCfg)
+
+	cfg.TLSEnabled = true
+	_, _, err = cfg.buildTLSConfigs()
+	assert.Equal(t, errMemberlistTLSCertMissing, err)
+}
+
+func TestMemberlistOverTLS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "memberlist-tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	tlsCfg := writeMemberlistCerts(t, dir)
+
+	ports, err := getFreePorts(2)
+	require.NoError(t, err)
+
+	cfg1 := KVConfig{
+		TCPTransport: TCPTransportConfig{
+			BindAddrs:     []string{"localhost"},
+			BindPort:      ports[0],
+			TLSEnabled:    true,
+			TLSServerName: "memberlist",
+			TLS:           tlsCfg,
+		},
+		RandomizeNodeName: true,
+		Codecs:            []codec.Codec{dataCodec{}},
+	}
+
+	cfg2 := cfg1
+	cfg2.TCPTransport.BindPort = ports[1]
+	cfg2.JoinMembers = []string{fmt.Sprintf("localhost:%d", ports[0])}
+
+	mkv1 := NewKV(cfg1, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv1))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv1) //nolint:errcheck
+
+	mkv2 := NewKV(cfg2, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv2))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv2) //nolint:errcheck
+
+	test.Poll(t, 5*time.Second, 2, func() interface{} {
+		return mkv1.memberlist.NumMembers()
+	})
+
+	// Values are gossiped over TLS.
+	client1, err := NewClient(mkv1, dataCodec{})
+	require.NoError(t, err)
+	client2, err := NewClient(mkv2, dataCodec{})
+	require.NoError(t, err)
+
+	cas(t, client1, key, updateFn("Ing 1"))
+	test.Poll(t, 5*time.Second, true, func() interface{} {
+		d := getData(t, client2, key)
+		return d != nil && d.Members["Ing 1"].Timestamp > 0
+	})
+
+	// A member without a certificate can't join the cluster.
+	cfg3 := cfg2
+	cfg3.TCPTransport.TLSEnabled = false
+	cfg3.TCPTransport.BindPort = 0
+
+	mkv3 := NewKV(cfg3, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), mkv3))
+	defer services.StopAndAwaitTerminated(context.Background(), mkv3) //nolint:errcheck
+
+	time.Sleep(time.Second)
+	assert.Equal(t, 1, mkv3.memberlist.NumMembers())
+	assert.Equal(t, 2, mkv1.memberlist.NumMembers())
+}
+
+// writeMemberlistCerts writes a CA and a certificate signed by it, used by all the members.
+func writeMemberlistCerts(t *testing.T, dir string) cortextls.ClientConfig {
+	cfg := cortextls.ClientConfig{
+		CAPath:   filepath.Join(dir, "ca.crt"),
+		CertPath: filepath.Join(dir, "memberlist.crt"),
+		KeyPath:  filepath.Join(dir, "memberlist.key"),
+	}
+
+	authority := ca.New("Memberlist Test")
+	require.NoError(t, authority.WriteCACertificate(cfg.CAPath))
+	require.NoError(t, authority.WriteCertificate(&x509.Certificate{
+		DNSNames:    []string{"memberlist"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, cfg.CertPath, cfg.KeyPath))
+
+	return cfg
+}
@@ -298,7 +298,13 @@ func (m *MultiClient) Get(ctx context.Context, key string) (interface{}, error)
 // Delete is a part of the kv.Client interface.
 func (m *MultiClient) Delete(ctx context.Context, key string) error {
 	_, kv := m.getPrimaryClient()
-	return kv.client.Delete(ctx, key)
+
+	err := kv.client.Delete(ctx, key)
+	if err == nil && m.mirroringEnabled.Load() {
+		m.deleteFromSecondary(ctx, kv, key)
+	}
+
+	return err
 }
 
 // CAS is a part of kv.Client interface.
@@ -336,29 +342,43 @@ func (m *MultiClient) WatchPrefix(ctx context.Context, prefix string, f func(str
 }
 
 func (m *MultiClient) writeToSecondary(ctx context.Context, primary kvclient, key string, newValue interface{}) {
+	m.mirrorToSecondary(ctx, primary, key, "stored updated value to secondary store", "failed to update value in secondary store", func(ctx context.Context, secondary Client) error {
+		return secondary.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
+			// try once
+			return newValue, false, nil
+		})
+	})
+}
+
+// deleteFromSecondary propagates the deletion of the key to the secondary stores, so that a key deleted
+// before switching the primary store doesn't reappear after the switch.
+func (m *MultiClient) deleteFromSecondary(ctx context.Context, primary kvclient, key string) {
+	m.mirrorToSecondary(ctx, primary, key, "deleted value from secondary store", "failed to delete value from secondary store", func(ctx context.Context, secondary Client) error {
+		return secondary.Delete(ctx, key)
+	})
+}
+
+func (m *MultiClient) mirrorToSecondary(ctx context.Context, primary kvclient, key, successMsg, failureMsg string, fn func(ctx context.Context, secondary Client) error) {
 	if m.mirrorTimeout > 0 {
 		var cfn context.CancelFunc
 		ctx, cfn = context.WithTimeout(ctx, m.mirrorTimeout)
 		defer cfn()
 	}
 
-	// let's propagate new value to all remaining clients
+	// let's propagate the change to all remaining clients
 	for _, kvc := range m.clients {
 		if kvc == primary {
 			continue
 		}
 
 		mirrorWritesCounter.Inc()
-		err := kvc.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
-			// try once
-			return newValue, false, nil
-		})
+		err := fn(ctx, kvc.client)
 
 		if err != nil {
 			mirrorFailuresCounter.Inc()
-			level.Warn(m.logger).Log("msg", "failed to update value in secondary store", "key", key, "err", err, "primary", primary.name, "secondary", kvc.name)
+			level.Warn(m.logger).Log("msg", failureMsg, "key", key, "err", err, "primary", primary.name, "secondary", kvc.name)
 		} else {
-			level.Debug(m.logger).Log("msg", "stored updated value to secondary store", "key", key, "primary", primary.name, "secondary", kvc.name)
+			level.Debug(m.logger).Log("msg", successMsg, "key", key, "primary", primary.name, "secondary", kvc.name)
 		}
 	}
 }
@@ -1,10 +1,17 @@
 package kv
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/ring/kv/codec"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/test"
 )
 
 func boolPtr(b bool) *bool {
@@ -30,3 +37,76 @@ func TestMultiRuntimeConfigWithVariousEnabledValues(t *testing.T) {
 		})
 	}
 }
+
+func TestMultiClient_ShouldMirrorWritesAndDeletesToSecondary(t *testing.T) {
+	ctx := context.Background()
+	primary := consul.NewInMemoryClient(codec.String{})
+	secondary := consul.NewInMemoryClient(codec.String{})
+
+	client := NewMultiClient(MultiConfig{MirrorEnabled: true}, []kvclient{
+		{client: primary, name: "primary"},
+		{client: secondary, name: "secondary"},
+	})
+
+	require.NoError(t, client.CAS(ctx, "key", func(in interface{}) (out interface{}, retry bool, err error) {
+		return "value", false, nil
+	}))
+
+	val, err := secondary.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	require.NoError(t, client.Delete(ctx, "key"))
+
+	val, err = primary.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, val)
+
+	val, err = secondary.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Nil(t, val)
+}
+
+func TestMultiClient_ShouldSwitchPrimaryStoreAtRuntime(t *testing.T) {
+	ctx := context.Background()
+	primary := consul.NewInMemoryClient(codec.String{})
+	secondary := consul.NewInMemoryClient(codec.String{})
+
+	configCh := make(chan MultiRuntimeConfig, 1)
+	client := NewMultiClient(MultiConfig{
+		ConfigProvider: func() <-chan MultiRuntimeConfig { return configCh },
+	}, []kvclient{
+		{client: primary, name: "primary"},
+		{client: secondary, name: "secondary"},
+	})
+	defer client.cancel()
+
+	require.NoError(t, primary.CAS(ctx, "key", func(in interface{}) (out interface{}, retry bool, err error) {
+		return "from-primary", false, nil
+	}))
+	require.NoError(t, secondary.CAS(ctx, "key", func(in interface{}) (out interface{}, retry bool, err error) {
+		return "from-secondary", false, nil
+	}))
+
+	val, err := client.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "from-primary", val)
+
+	configCh <- MultiRuntimeConfig{PrimaryStore: "secondary", Mirroring: boolPtr(true)}
+
+	test.Poll(t, time.Second, "from-secondary", func() interface{} {
+		val, err := client.Get(ctx, "key")
+		require.NoError(t, err)
+		return val
+	})
+	assert.True(t, client.mirroringEnabled.Load())
+
+	// Writes now go to the new primary, and are mirrored to the previous one.
+	require.NoError(t, client.CAS(ctx, "key", func(in interface{}) (out interface{}, retry bool, err error) {
+		return "updated", false, nil
+	}))
+
+	val, err = primary.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "updated", val)
+}
@@ -58,6 +58,14 @@ type LifecyclerConfig struct {
 	Zone                 string        `yaml:"availability_zone"`
 	UnregisterOnShutdown bool          `yaml:"unregister_on_shutdown"`
 
+	// Max tolerated difference in number of instances between the zone of this
+	// instance and the smallest zone, when joining the ring.
+	ZoneMaxInstancesImbalance int `yaml:"zone_max_instances_imbalance"`
+
+	// Number of heartbeat timeouts after which an unhealthy instance is
+	// automatically removed from the ring by the other instances.
+	AutoForgetUnhealthyPeriods int `yaml:"auto_forget_unhealthy_periods"`
+
 	// For testing, you can override the address and ID of this ingester
 	Addr string `yaml:"address" doc:"hidden"`
 	Port int    `doc:"hidden"`
@@ -102,6 +110,8 @@ func (cfg *LifecyclerConfig) RegisterFlagsWithPrefix(prefix string, f *flag.Flag
 	f.IntVar(&cfg.Port, prefix+"lifecycler.port", 0, "port to advertise in consul (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.ID, prefix+"lifecycler.ID", hostname, "ID to register in the ring.")
 	f.StringVar(&cfg.Zone, prefix+"availability-zone", "", "The availability zone where this instance is running.")
+	f.IntVar(&cfg.ZoneMaxInstancesImbalance, prefix+"zone-max-instances-imbalance", 0, "When zone-awareness is enabled, the maximum tolerated difference between the number of instances in the zone of this instance and in the zone with the fewest instances. A new instance whose zone would exceed this tolerance fails to join the ring, and its startup fails. Instances already registered in the ring (ie. restarted during a rollout) are not checked, and the zones becoming unbalanced afterwards (ie. when instances leave the ring) are not detected. 0 to disable.")
+	f.IntVar(&cfg.AutoForgetUnhealthyPeriods, prefix+"auto-forget-unhealthy-periods", 0, "Number of consecutive heartbeat timeouts after which an unhealthy instance is automatically removed from the ring by the healthy instances. 0 to disable.")
 	f.BoolVar(&cfg.UnregisterOnShutdown, prefix+"unregister-on-shutdown", true, "Unregister from the ring upon clean shutdown. It can be useful to disable for rolling restarts with consistent naming in conjunction with -distributor.extend-writes=false.")
 }
 
@@ -464,10 +474,12 @@ func (i *Lifecycler) stopping(runningError error) error {
 	heartbeatTicker := time.NewTicker(i.cfg.HeartbeatPeriod)
 	defer heartbeatTicker.Stop()
 
-	// Mark ourselved as Leaving so no more samples are send to us.
-	err := i.changeState(context.Background(), LEAVING)
-	if err != nil {
-		level.Error(util.Logger).Log("msg", "failed to set state to LEAVING", "ring", i.RingName, "err", err)
+	// Mark ourselved as Leaving so no more samples are send to us. The instance
+	// may already be LEAVING if the shutdown has been prepared.
+	if i.GetState() != LEAVING {
+		if err := i.changeState(context.Background(), LEAVING); err != nil {
+			level.Error(util.Logger).Log("msg", "failed to set state to LEAVING", "ring", i.RingName, "err", err)
+		}
 	}
 
 	// Do the transferring / flushing on a background goroutine so we can continue
@@ -514,7 +526,9 @@ func (i *Lifecycler) initRing(ctx context.Context) error {
 
 	if i.cfg.TokensFilePath != "" {
 		tokensFromFile, err = LoadTokensFromFile(i.cfg.TokensFilePath)
-		if err != nil {
+		if os.IsNotExist(err) {
+			level.Info(util.Logger).Log("msg", "not loading tokens from file, tokens file doesn't exist", "path", i.cfg.TokensFilePath)
+		} else if err != nil {
 			level.Error(util.Logger).Log("msg", "error in getting tokens from file", "err", err)
 		}
 	} else {
@@ -530,6 +544,10 @@ func (i *Lifecycler) initRing(ctx context.Context) error {
 
 		ingesterDesc, ok := ringDesc.Ingesters[i.ID]
 		if !ok {
+			if err := i.checkZoneBalance(ringDesc); err != nil {
+				return nil, false, err
+			}
+
 			// The instance doesn't exist in the ring, so it's safe to set the registered timestamp
 			// as of now.
 			registeredAt := time.Now()
@@ -556,6 +574,20 @@ func (i *Lifecycler) initRing(ctx context.Context) error {
 		// but we need to update the local state accordingly.
 		i.setRegisteredAt(ingesterDesc.GetRegisteredAt())
 
+		// If the instance is registered in the ring without its tokens (ie. it crashed or has been restarted
+		// before joining the ring) but the tokens file has them, we rejoin the ring with the tokens from the
+		// file, skipping the JOINING phase.
+		if ingesterDesc.State != ACTIVE && len(ingesterDesc.Tokens) < i.cfg.NumTokens && len(tokensFromFile) >= i.cfg.NumTokens {
+			level.Info(util.Logger).Log("msg", "instance found in ring without tokens, adding tokens from file", "state", ingesterDesc.State, "num_tokens", len(tokensFromFile), "ring", i.RingName)
+			ingesterDesc.State = ACTIVE
+			ingesterDesc.Tokens = tokensFromFile
+			ringDesc.Ingesters[i.ID] = ingesterDesc
+
+			i.setState(ACTIVE)
+			i.setTokens(tokensFromFile)
+			return ringDesc, true, nil
+		}
+
 		// If the ingester is in the JOINING state this means it crashed due to
 		// a failed token transfer or some other reason during startup. We want
 		// to set it back to PENDING in order to start the lifecycle from the
@@ -591,6 +623,32 @@ func (i *Lifecycler) initRing(ctx context.Context) error {
 	return err
 }
 
+// checkZoneBalance returns an error if adding this instance to the ring would misbalance
+// its zone beyond the configured tolerance, compared to the zone with the fewest instances.
+// It's only called when the instance isn't registered in the ring yet: a ring becoming
+// unbalanced afterwards, ie. because instances of another zone leave it, is not detected.
+func (i *Lifecycler) checkZoneBalance(ringDesc *Desc) error {
+	if !i.cfg.RingConfig.ZoneAwarenessEnabled || i.cfg.ZoneMaxInstancesImbalance <= 0 || i.Zone == "" {
+		return nil
+	}
+
+	counts := ringDesc.getInstancesCountPerZone()
+	counts[i.Zone]++
+
+	minZone, minCount := i.Zone, counts[i.Zone]
+	for zone, count := range counts {
+		if count < minCount {
+			minZone, minCount = zone, count
+		}
+	}
+
+	if imbalance := counts[i.Zone] - minCount; imbalance > i.cfg.ZoneMaxInstancesImbalance {
+		return fmt.Errorf("joining the ring would misbalance the zones beyond the tolerated imbalance of %d instances: zone %s would have %d instances while zone %s has %d", i.cfg.ZoneMaxInstancesImbalance, i.Zone, counts[i.Zone], minZone, minCount)
+	}
+
+	return nil
+}
+
 // Verifies that tokens that this ingester has registered to the ring still belong to it.
 // Gossiping ring may change the ownership of tokens in case of conflicts.
 // If ingester doesn't own its tokens anymore, this method generates new tokens and puts them to the ring.
@@ -719,6 +777,10 @@ func (i *Lifecycler) updateConsul(ctx context.Context) error {
 			ringDesc.Ingesters[i.ID] = ingesterDesc
 		}
 
+		if i.cfg.AutoForgetUnhealthyPeriods > 0 {
+			forgetUnhealthyInstances(ringDesc, time.Duration(i.cfg.AutoForgetUnhealthyPeriods)*i.cfg.RingConfig.HeartbeatTimeout, time.Now(), util.Logger)
+		}
+
 		return ringDesc, true, nil
 	})
 
@@ -739,7 +801,8 @@ func (i *Lifecycler) changeState(ctx context.Context, state IngesterState) error
 		(currState == JOINING && state == PENDING) || // triggered by TransferChunks on failure
 		(currState == JOINING && state == ACTIVE) || // triggered by TransferChunks on success
 		(currState == PENDING && state == ACTIVE) || // triggered by autoJoin
-		(currState == ACTIVE && state == LEAVING)) { // triggered by shutdown
+		(currState == ACTIVE && state == LEAVING) || // triggered by shutdown or by preparing the shutdown
+		(currState == LEAVING && state == ACTIVE)) { // triggered by canceling the shutdown preparation
 		return fmt.Errorf("Changing instance state from %v -> %v is disallowed", currState, state)
 	}
 
@@ -129,6 +129,113 @@ func TestLifecycler_ZonesCount(t *testing.T) {
 	}
 }
 
+func TestLifecycler_ZoneMaxInstancesImbalance(t *testing.T) {
+	var ringConfig Config
+	flagext.DefaultValues(&ringConfig)
+	ringConfig.KVStore.Mock = consul.NewInMemoryClient(GetCodec())
+	ringConfig.ZoneAwarenessEnabled = true
+
+	events := []struct {
+		zone          string
+		expectedError bool
+	}{
+		{"zone-a", false},
+		{"zone-a", false}, // The single zone is never misbalanced.
+		{"zone-b", false}, // Joining the smallest zone is always allowed.
+		{"zone-a", false},
+		{"zone-a", true}, // zone-a would have 3 more instances than zone-b.
+		{"zone-b", false},
+		{"zone-a", false},
+	}
+
+	joined := 0
+	for idx, event := range events {
+		ctx := context.Background()
+
+		cfg := testLifecyclerConfig(ringConfig, fmt.Sprintf("instance-%d", idx))
+		cfg.JoinAfter = 100 * time.Millisecond
+		cfg.Zone = event.zone
+		cfg.ZoneMaxInstancesImbalance = 2
+
+		lifecycler, err := NewLifecycler(cfg, &nopFlushTransferer{}, "ingester", IngesterRingKey, true, nil)
+		require.NoError(t, err)
+
+		if event.expectedError {
+			_ = services.StartAndAwaitRunning(ctx, lifecycler)
+			err := lifecycler.AwaitTerminated(ctx)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "misbalance the zones")
+			continue
+		}
+
+		require.NoError(t, services.StartAndAwaitRunning(ctx, lifecycler))
+		defer services.StopAndAwaitTerminated(ctx, lifecycler) // nolint:errcheck
+
+		joined++
+		test.Poll(t, time.Second, joined, func() interface{} {
+			return lifecycler.HealthyInstancesCount()
+		})
+	}
+}
+
+func TestLifecycler_AutoForgetUnhealthyInstances(t *testing.T) {
+	for _, autoForgetEnabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("auto-forget enabled: %t", autoForgetEnabled), func(t *testing.T) {
+			var ringConfig Config
+			flagext.DefaultValues(&ringConfig)
+			ringConfig.HeartbeatTimeout = time.Minute
+			ringConfig.KVStore.Mock = consul.NewInMemoryClient(GetCodec())
+
+			ctx := context.Background()
+
+			// Setup the initial state of the ring with an instance which stopped heartbeating
+			// for longer than the forget period, and one which didn't reach it yet.
+			require.NoError(t, ringConfig.KVStore.Mock.CAS(ctx, IngesterRingKey, func(in interface{}) (out interface{}, retry bool, err error) {
+				ringDesc := NewDesc()
+				for id, lastHeartbeat := range map[string]time.Time{"instance-1": time.Now().Add(-3 * time.Minute), "instance-2": time.Now().Add(-90 * time.Second)} {
+					instance := ringDesc.AddIngester(id, "1.1.1.1", "", nil, ACTIVE, lastHeartbeat)
+					instance.Timestamp = lastHeartbeat.Unix()
+					ringDesc.Ingesters[id] = instance
+				}
+				return ringDesc, true, nil
+			}))
+
+			cfg := testLifecyclerConfig(ringConfig, "ing1")
+			cfg.JoinAfter = 100 * time.Millisecond
+			if autoForgetEnabled {
+				cfg.AutoForgetUnhealthyPeriods = 2
+			}
+
+			lifecycler, err := NewLifecycler(cfg, &nopFlushTransferer{}, "ingester", IngesterRingKey, true, nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(ctx, lifecycler))
+			defer services.StopAndAwaitTerminated(ctx, lifecycler) // nolint:errcheck
+
+			// Wait until joined.
+			test.Poll(t, time.Second, 1, func() interface{} {
+				return lifecycler.HealthyInstancesCount()
+			})
+
+			expectedInstances := []string{"ing1", "instance-1", "instance-2"}
+			if autoForgetEnabled {
+				expectedInstances = []string{"ing1", "instance-2"}
+			}
+
+			test.Poll(t, time.Second, expectedInstances, func() interface{} {
+				v, err := ringConfig.KVStore.Mock.Get(ctx, IngesterRingKey)
+				require.NoError(t, err)
+
+				var actualInstances []string
+				for id := range GetOrCreateRingDesc(v).GetIngesters() {
+					actualInstances = append(actualInstances, id)
+				}
+				sort.Strings(actualInstances)
+				return actualInstances
+			})
+		})
+	}
+}
+
 func TestLifecycler_NilFlushTransferer(t *testing.T) {
 	var ringConfig Config
 	flagext.DefaultValues(&ringConfig)
@@ -394,6 +501,57 @@ func TestTokensOnDisk(t *testing.T) {
 	}
 }
 
+func TestTokensOnDisk_ShouldRestoreTokensIfInstanceIsRegisteredWithoutTokens(t *testing.T) {
+	for _, state := range []IngesterState{PENDING, JOINING, LEAVING} {
+		t.Run(state.String(), func(t *testing.T) {
+			var ringConfig Config
+			flagext.DefaultValues(&ringConfig)
+			ringConfig.KVStore.Mock = consul.NewInMemoryClient(GetCodec())
+
+			tokenDir, err := ioutil.TempDir(os.TempDir(), "tokens_on_disk")
+			require.NoError(t, err)
+			defer func() {
+				require.NoError(t, os.RemoveAll(tokenDir))
+			}()
+
+			expectedTokens := Tokens{1, 2, 3}
+			require.NoError(t, expectedTokens.StoreToFile(tokenDir+"/tokens"))
+
+			// Register the instance in the ring without tokens, like if it has been restarted before joining.
+			err = ringConfig.KVStore.Mock.CAS(context.Background(), IngesterRingKey, func(in interface{}) (interface{}, bool, error) {
+				desc := NewDesc()
+				desc.AddIngester("ing1", "0.0.0.0:1", "zone1", nil, state, time.Now())
+				return desc, true, nil
+			})
+			require.NoError(t, err)
+
+			// The instance is expected to be ACTIVE with the tokens from file without waiting the join timeout.
+			cfg := testLifecyclerConfig(ringConfig, "ing1")
+			cfg.NumTokens = len(expectedTokens)
+			cfg.TokensFilePath = tokenDir + "/tokens"
+			cfg.JoinAfter = time.Hour
+
+			l1, err := NewLifecycler(cfg, &noopFlushTransferer{}, "ingester", IngesterRingKey, true, nil)
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), l1))
+			defer services.StopAndAwaitTerminated(context.Background(), l1) //nolint:errcheck
+
+			test.Poll(t, time.Second, true, func() interface{} {
+				d, err := ringConfig.KVStore.Mock.Get(context.Background(), IngesterRingKey)
+				require.NoError(t, err)
+
+				desc, ok := d.(*Desc)
+				return ok &&
+					desc.Ingesters["ing1"].State == ACTIVE &&
+					assert.ObjectsAreEqual([]uint32(expectedTokens), desc.Ingesters["ing1"].Tokens)
+			})
+
+			assert.Equal(t, ACTIVE, l1.GetState())
+			assert.Equal(t, expectedTokens, l1.getTokens())
+		})
+	}
+}
+
 // JoinInLeavingState ensures that if the lifecycler starts up and the ring already has it in a LEAVING state that it still is able to auto join
 func TestJoinInLeavingState(t *testing.T) {
 	var ringConfig Config
@@ -168,6 +168,12 @@ func (i *IngesterDesc) IsHealthy(op Operation, heartbeatTimeout time.Duration) b
 
 	case Compactor:
 		healthy = i.State == ACTIVE
+
+	case QueryScheduler:
+		healthy = i.State == ACTIVE
+
+	case Alertmanager:
+		healthy = i.State == ACTIVE
 	}
 
 	return healthy && time.Since(time.Unix(i.Timestamp, 0)) <= heartbeatTimeout
@@ -268,6 +274,16 @@ func (d *Desc) MergeContent() []string {
 	return result
 }
 
+// Split returns one ring per ingester, so that a large ring change can be gossiped in smaller messages.
+// This method is part of memberlist.Splittable interface, and is only used by gossiping ring.
+func (d *Desc) Split() []memberlist.Mergeable {
+	result := make([]memberlist.Mergeable, 0, len(d.Ingesters))
+	for id, ing := range d.Ingesters {
+		result = append(result, &Desc{Ingesters: map[string]IngesterDesc{id: ing}})
+	}
+	return result
+}
+
 // buildNormalizedIngestersMap will do the following:
 // - sorts tokens and removes duplicates (only within single ingester)
 // - it doesn't modify input ring
@@ -453,6 +469,21 @@ func (d *Desc) getTokensByZone() map[string][]TokenDesc {
 	return zones
 }
 
+// getInstancesCountPerZone returns the number of instances by zone, excluding the LEFT ones
+// and the ones without a zone.
+func (d *Desc) getInstancesCountPerZone() map[string]int {
+	counts := map[string]int{}
+
+	for _, ing := range d.Ingesters {
+		if ing.State == LEFT || ing.Zone == "" {
+			continue
+		}
+		counts[ing.Zone]++
+	}
+
+	return counts
+}
+
 type CompareResult int
 
 const (
@@ -271,6 +271,25 @@ func TestDesc_getTokensByZone(t *testing.T) {
 	}
 }
 
+func TestDesc_Split(t *testing.T) {
+	desc := &Desc{Ingesters: map[string]IngesterDesc{
+		"ing-1": {Addr: "127.0.0.1", Timestamp: 1000, Tokens: []uint32{1, 2}, State: ACTIVE},
+		"ing-2": {Addr: "127.0.0.2", Timestamp: 1000, Tokens: []uint32{3, 4}, State: JOINING},
+	}}
+
+	merged := NewDesc()
+	parts := desc.Split()
+	assert.Len(t, parts, 2)
+
+	for _, part := range parts {
+		assert.Len(t, part.(*Desc).Ingesters, 1)
+		_, err := merged.Merge(part, false)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, Equal, desc.RingCompare(merged))
+}
+
 func TestDesc_RingsCompare(t *testing.T) {
 	tests := map[string]struct {
 		r1, r2   *Desc
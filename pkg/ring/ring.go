@@ -34,6 +34,12 @@ const (
 
 	// CompactorRingKey is the key under which we store the compactors ring in the KVStore.
 	CompactorRingKey = "compactor"
+
+	// QuerySchedulerRingKey is the key under which we store the query-schedulers ring in the KVStore.
+	QuerySchedulerRingKey = "query-scheduler"
+
+	// AlertmanagerRingKey is the key under which we store the alertmanagers ring in the KVStore.
+	AlertmanagerRingKey = "alertmanager"
 )
 
 // ReadRing represents the read interface to the ring.
@@ -91,6 +97,12 @@ const (
 
 	// Compactor is the operation used for distributing tenants/blocks across compactors.
 	Compactor
+
+	// QueryScheduler is the operation used by query-frontends and queriers to discover query-schedulers.
+	QueryScheduler
+
+	// Alertmanager is the operation used for distributing tenants across alertmanagers.
+	Alertmanager
 )
 
 var (
@@ -1,6 +1,8 @@
 package ruler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
@@ -16,7 +18,6 @@ import (
 	"github.com/pkg/errors"
 	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/prometheus/pkg/labels"
-	"github.com/prometheus/prometheus/pkg/rulefmt"
 	"github.com/weaveworks/common/user"
 	"gopkg.in/yaml.v3"
 
@@ -285,8 +286,51 @@ var (
 	ErrNoRuleGroups = errors.New("no rule groups found")
 	// ErrBadRuleGroup is returned when the provided rule group can not be unmarshalled
 	ErrBadRuleGroup = errors.New("unable to decoded rule group")
+	// ErrRuleGroupChanged is returned when the stored rule group doesn't match the
+	// one expected by the request, via the If-Match or If-None-Match headers
+	ErrRuleGroupChanged = errors.New("the rule group has been changed or doesn't match the expected state")
 )
 
+// ruleGroupETag returns the entity tag of a stored rule group, which is the hash
+// of its content. It's used for the optimistic concurrency control of the updates.
+func ruleGroupETag(rg *rules.RuleGroupDesc) (string, error) {
+	data, err := rg.Marshal()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(data)
+	return `"` + hex.EncodeToString(hash[:]) + `"`, nil
+}
+
+// preconditions returns the condition of the update of a rule group, checking the If-Match
+// and If-None-Match request headers against the stored rule group, or nil if the request
+// has no conditional headers. The headers support a single entity tag or "*", and allow
+// to update a rule group only if it hasn't been changed since it was read.
+func preconditions(req *http.Request) rules.RuleGroupCondition {
+	ifMatch, ifNoneMatch := req.Header.Get("If-Match"), req.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		return nil
+	}
+
+	return func(current *rules.RuleGroupDesc) error {
+		var etag string
+		if current != nil {
+			var err error
+			if etag, err = ruleGroupETag(current); err != nil {
+				return err
+			}
+		}
+
+		if ifMatch != "" && (current == nil || (ifMatch != "*" && ifMatch != etag)) {
+			return ErrRuleGroupChanged
+		}
+		if ifNoneMatch != "" && current != nil && (ifNoneMatch == "*" || ifNoneMatch == etag) {
+			return ErrRuleGroupChanged
+		}
+		return nil
+	}
+}
+
 func marshalAndSend(output interface{}, w http.ResponseWriter, logger log.Logger) {
 	d, err := yaml.Marshal(&output)
 	if err != nil {
@@ -410,7 +454,7 @@ func (a *API) ListRules(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "retrieved rule groups from rule store", "userID", userID, "num_namespaces", len(rgs))
 
-	formatted := rgs.Formatted()
+	formatted := rgs.FormattedWithSourceTenants()
 	marshalAndSend(formatted, w, logger)
 }
 
@@ -432,7 +476,14 @@ func (a *API) GetRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	formatted := store.FromProto(rg)
+	etag, err := ruleGroupETag(rg)
+	if err != nil {
+		respondError(logger, w, err.Error())
+		return
+	}
+	w.Header().Set("ETag", etag)
+
+	formatted := store.FromProtoWithSourceTenants(rg)
 	marshalAndSend(formatted, w, logger)
 }
 
@@ -453,7 +504,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 
 	level.Debug(logger).Log("msg", "attempting to unmarshal rulegroup", "userID", userID, "group", string(payload))
 
-	rg := rulefmt.RuleGroup{}
+	rg := store.RuleGroup{}
 	err = yaml.Unmarshal(payload, &rg)
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to unmarshal rule group payload", "err", err.Error())
@@ -461,7 +512,7 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	errs := a.ruler.manager.ValidateRuleGroup(rg)
+	errs := a.ruler.manager.ValidateRuleGroup(rg.RuleGroup)
 	if len(errs) > 0 {
 		e := []string{}
 		for _, err := range errs {
@@ -479,6 +530,18 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if err := a.ruler.AssertSourceTenants(userID, rg.SourceTenants); err != nil {
+		level.Error(logger).Log("msg", "source tenants validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := a.ruler.AssertDestinationTenant(userID, rg.DestinationTenant, rg.Rules); err != nil {
+		level.Error(logger).Log("msg", "destination tenant validation failure", "err", err.Error(), "user", userID)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	rgs, err := a.store.ListRuleGroupsForUserAndNamespace(req.Context(), userID, "")
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to fetch current rule groups for validation", "err", err.Error(), "user", userID)
@@ -492,16 +555,29 @@ func (a *API) CreateRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	rgProto := store.ToProto(userID, namespace, rg)
+	rgProto := store.ToProto(userID, namespace, rg.RuleGroup)
+	rgProto.SourceTenants = rg.SourceTenants
+	rgProto.DestinationTenant = rg.DestinationTenant
 
 	level.Debug(logger).Log("msg", "attempting to store rulegroup", "userID", userID, "group", rgProto.String())
-	err = a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto)
+	if cond := preconditions(req); cond != nil {
+		err = a.store.SetRuleGroupIf(req.Context(), userID, namespace, rgProto, cond)
+	} else {
+		err = a.store.SetRuleGroup(req.Context(), userID, namespace, rgProto)
+	}
+	if err == ErrRuleGroupChanged {
+		http.Error(w, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
 		level.Error(logger).Log("msg", "unable to store rule group", "err", err.Error())
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if etag, err := ruleGroupETag(rgProto); err == nil {
+		w.Header().Set("ETag", etag)
+	}
 	respondAccepted(w, logger)
 }
 
@@ -536,8 +612,16 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	err = a.store.DeleteRuleGroup(req.Context(), userID, namespace, groupName)
+	if cond := preconditions(req); cond != nil {
+		err = a.store.DeleteRuleGroupIf(req.Context(), userID, namespace, groupName, cond)
+	} else {
+		err = a.store.DeleteRuleGroup(req.Context(), userID, namespace, groupName)
+	}
 	if err != nil {
+		if err == ErrRuleGroupChanged {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
 		if err == rules.ErrGroupNotFound {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
@@ -260,6 +260,196 @@ rules:
 	}
 }
 
+func TestRuler_CreateFederatedRuleGroup(t *testing.T) {
+	const input = `
+name: test
+source_tenants: [tenant-b, tenant-a]
+rules:
+- record: up_rule
+  expr: up{}
+`
+
+	tc := []struct {
+		name             string
+		enableFederation bool
+		input            string
+		output           string
+		status           int
+	}{
+		{
+			name:             "with the tenant federation disabled",
+			enableFederation: false,
+			input:            input,
+			output:           "rule groups with source tenants are not allowed because the ruler tenant federation is disabled\n",
+			status:           400,
+		},
+		{
+			name:             "with an invalid source tenant",
+			enableFederation: true,
+			input:            "name: test\nsource_tenants: [tenant/a]\nrules:\n- record: up_rule\n  expr: up{}\n",
+			output:           "invalid source tenant \"tenant/a\": tenant ID 'tenant/a' contains unsupported character '/'\n",
+			status:           400,
+		},
+		{
+			name:             "with a source tenant not allowed",
+			enableFederation: true,
+			input:            "name: test\nsource_tenants: [tenant-a, tenant-c]\nrules:\n- record: up_rule\n  expr: up{}\n",
+			output:           "source tenant \"tenant-c\" is not allowed: it must be listed in the ruler_allowed_source_tenants limit of the tenant\n",
+			status:           400,
+		},
+		{
+			name:             "with the tenant federation enabled",
+			enableFederation: true,
+			input:            input,
+			output:           "name: test\nrules:\n    - record: up_rule\n      expr: up{}\nsource_tenants:\n    - tenant-b\n    - tenant-a\n",
+			status:           202,
+		},
+		{
+			name:             "with a destination tenant and the tenant federation disabled",
+			enableFederation: false,
+			input:            "name: test\ndestination_tenant: aggregations\nrules:\n- record: up_rule\n  expr: up{}\n",
+			output:           "rule groups with source tenants are not allowed because the ruler tenant federation is disabled\n",
+			status:           400,
+		},
+		{
+			name:             "with an invalid destination tenant",
+			enableFederation: true,
+			input:            "name: test\ndestination_tenant: tenant/a\nrules:\n- record: up_rule\n  expr: up{}\n",
+			output:           "invalid destination tenant \"tenant/a\": tenant ID 'tenant/a' contains unsupported character '/'\n",
+			status:           400,
+		},
+		{
+			name:             "with a destination tenant not allowed",
+			enableFederation: true,
+			input:            "name: test\ndestination_tenant: tenant-a\nrules:\n- record: up_rule\n  expr: up{}\n",
+			output:           "destination tenant \"tenant-a\" is not allowed: it must be listed in the ruler_allowed_destination_tenants limit of the tenant\n",
+			status:           400,
+		},
+		{
+			name:             "with a destination tenant and alerting rules",
+			enableFederation: true,
+			input:            "name: test\ndestination_tenant: aggregations\nrules:\n- alert: up_alert\n  expr: up{} < 1\n",
+			output:           "rule groups with a destination tenant can only contain recording rules\n",
+			status:           400,
+		},
+		{
+			name:             "with source tenants and a destination tenant",
+			enableFederation: true,
+			input:            "name: test\nsource_tenants: [tenant-a, tenant-b]\ndestination_tenant: aggregations\nrules:\n- record: up_rule\n  expr: sum(up{})\n",
+			output:           "name: test\nrules:\n    - record: up_rule\n      expr: sum(up{})\nsource_tenants:\n    - tenant-a\n    - tenant-b\ndestination_tenant: aggregations\n",
+			status:           202,
+		},
+	}
+
+	for _, tt := range tc {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, cleanup := defaultRulerConfig(newMockRuleStore(make(map[string]rules.RuleGroupList)))
+			defer cleanup()
+			cfg.TenantFederation.Enabled = tt.enableFederation
+
+			r, rcleanup := newTestRuler(t, cfg)
+			defer rcleanup()
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			a := NewAPI(r, r.store)
+
+			router := mux.NewRouter()
+			router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+			router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, tt.status, w.Code)
+
+			if tt.status != 202 {
+				require.Equal(t, tt.output, w.Body.String())
+				return
+			}
+
+			req = requestFor(t, http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", nil, "user1")
+			w = httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+			require.Equal(t, 200, w.Code)
+			require.Equal(t, tt.output, w.Body.String())
+		})
+	}
+}
+
+func TestRuler_RuleGroupPreconditions(t *testing.T) {
+	cfg, cleanup := defaultRulerConfig(newMockRuleStore(make(map[string]rules.RuleGroupList)))
+	defer cleanup()
+
+	r, rcleanup := newTestRuler(t, cfg)
+	defer rcleanup()
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	a := NewAPI(r, r.store)
+
+	router := mux.NewRouter()
+	router.Path("/api/v1/rules/{namespace}").Methods("POST").HandlerFunc(a.CreateRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("GET").HandlerFunc(a.GetRuleGroup)
+	router.Path("/api/v1/rules/{namespace}/{groupName}").Methods("DELETE").HandlerFunc(a.DeleteRuleGroup)
+
+	const (
+		firstGroup  = "name: test\nrules:\n- record: up_rule\n  expr: up{}\n"
+		secondGroup = "name: test\nrules:\n- record: up_rule\n  expr: up{job=\"a\"}\n"
+	)
+
+	serve := func(method, url, body string, headers map[string]string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = strings.NewReader(body)
+		}
+		req := requestFor(t, method, url, reader, "user1")
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Updating a missing rule group is not allowed.
+	w := serve(http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", firstGroup, map[string]string{"If-Match": "*"})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// Create the rule group only if it doesn't exist.
+	w = serve(http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", firstGroup, map[string]string{"If-None-Match": "*"})
+	require.Equal(t, http.StatusAccepted, w.Code)
+	createdETag := w.Header().Get("ETag")
+	require.NotEmpty(t, createdETag)
+
+	w = serve(http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", firstGroup, map[string]string{"If-None-Match": "*"})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	// The ETag returned when reading the rule group matches the one returned on creation.
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", "", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, createdETag, w.Header().Get("ETag"))
+
+	// Update the rule group with the current ETag.
+	w = serve(http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", secondGroup, map[string]string{"If-Match": createdETag})
+	require.Equal(t, http.StatusAccepted, w.Code)
+	updatedETag := w.Header().Get("ETag")
+	require.NotEqual(t, createdETag, updatedETag)
+
+	// Updating or deleting the rule group with a stale ETag fails.
+	w = serve(http.MethodPost, "https://localhost:8080/api/v1/rules/namespace", firstGroup, map[string]string{"If-Match": createdETag})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = serve(http.MethodDelete, "https://localhost:8080/api/v1/rules/namespace/test", "", map[string]string{"If-Match": createdETag})
+	require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	w = serve(http.MethodDelete, "https://localhost:8080/api/v1/rules/namespace/test", "", map[string]string{"If-Match": updatedETag})
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	w = serve(http.MethodGet, "https://localhost:8080/api/v1/rules/namespace/test", "", nil)
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func TestRuler_DeleteNamespace(t *testing.T) {
 	cfg, cleanup := defaultRulerConfig(newMockRuleStore(mockRulesNamespaces))
 	defer cleanup()
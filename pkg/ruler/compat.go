@@ -2,6 +2,13 @@ package ruler
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -9,11 +16,15 @@ import (
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/pkg/labels"
 	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
 	"github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/weaveworks/common/user"
 
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	store "github.com/cortexproject/cortex/pkg/ruler/rules"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
 )
 
 // Pusher is an ingester server that accepts pushes.
@@ -57,18 +68,39 @@ func (a *pusherAppender) Rollback() error {
 	return nil
 }
 
+// errAppender is a storage.Appender failing to append any sample.
+type errAppender struct {
+	err error
+}
+
+func (a errAppender) Add(_ labels.Labels, _ int64, _ float64) (uint64, error) { return 0, a.err }
+func (a errAppender) AddFast(_ uint64, _ int64, _ float64) error              { return a.err }
+func (a errAppender) Commit() error                                           { return a.err }
+func (a errAppender) Rollback() error                                         { return nil }
+
 // PusherAppendable fulfills the storage.Appendable interface for prometheus manager
 type PusherAppendable struct {
 	pusher Pusher
+	limits RulesLimits
 	userID string
 }
 
 // Appender returns a storage.Appender
 func (t *PusherAppendable) Appender(ctx context.Context) storage.Appender {
+	// The series of a rule group having a destination tenant are written to it,
+	// instead of the owner of the rule group, as long as it's still allowed.
+	userID := t.userID
+	if destinationTenant := ruleGroupFederationFromContext(ctx).destinationTenant; destinationTenant != "" {
+		if err := checkDestinationTenantAllowed(t.limits, t.userID, destinationTenant); err != nil {
+			return errAppender{err: err}
+		}
+		userID = destinationTenant
+	}
+
 	return &pusherAppender{
 		ctx:    ctx,
 		pusher: t.pusher,
-		userID: t.userID,
+		userID: userID,
 	}
 }
 
@@ -78,17 +110,361 @@ type RulesLimits interface {
 	RulerTenantShardSize(userID string) int
 	RulerMaxRuleGroupsPerTenant(userID string) int
 	RulerMaxRulesPerRuleGroup(userID string) int
+	RulerMaxConcurrentRuleGroupEvaluations(userID string) int
+	RulerEvaluationAlignmentEnabled(userID string) bool
+	RulerEvaluationJitter(userID string) time.Duration
+	RulerAllowedSourceTenants(userID string) []string
+	RulerAllowedDestinationTenants(userID string) []string
+}
+
+// checkSourceTenantsAllowed returns an error if any of the source tenants can't be
+// queried by the rule groups of the user. The user can always query its own series.
+func checkSourceTenantsAllowed(limits RulesLimits, userID string, sourceTenants []string) error {
+	allowed := limits.RulerAllowedSourceTenants(userID)
+	for _, sourceTenant := range sourceTenants {
+		if sourceTenant != userID && !util.StringsContain(allowed, sourceTenant) {
+			return fmt.Errorf(errSourceTenantNotAllowed, sourceTenant)
+		}
+	}
+	return nil
 }
 
-// engineQueryFunc returns a new query function using the rules.EngineQueryFunc function
-// and passing an altered timestamp.
-func engineQueryFunc(engine *promql.Engine, q storage.Queryable, overrides RulesLimits, userID string) rules.QueryFunc {
+// checkDestinationTenantAllowed returns an error if the rule groups of the user can't
+// write their series to the destination tenant. The user can always write its own series.
+func checkDestinationTenantAllowed(limits RulesLimits, userID, destinationTenant string) error {
+	if destinationTenant != userID && !util.StringsContain(limits.RulerAllowedDestinationTenants(userID), destinationTenant) {
+		return fmt.Errorf(errDestinationTenantNotAllowed, destinationTenant)
+	}
+	return nil
+}
+
+// tenantQueryFunc returns a new query function wrapping the input one, which
+// federates the query across the source tenants, applies the evaluation limits
+// of the tenant and passes an altered timestamp.
+func tenantQueryFunc(queryFunc rules.QueryFunc, overrides RulesLimits, userID string) rules.QueryFunc {
+	// Used when the context doesn't carry the evaluations of the tenant, in which case
+	// the concurrency limit only applies to the queries of the same manager.
+	fallback := newTenantEvaluations()
+
 	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
-		orig := rules.EngineQueryFunc(engine, q)
+		evaluations := tenantEvaluationsFromContext(ctx)
+		if evaluations == nil {
+			evaluations = fallback
+		}
+		federation := ruleGroupFederationFromContext(ctx)
+
+		// The source and destination tenants are checked at each evaluation, because
+		// the allowed ones may have changed since the rule group was uploaded.
+		if federation.destinationTenant != "" {
+			if err := checkDestinationTenantAllowed(overrides, userID, federation.destinationTenant); err != nil {
+				return nil, err
+			}
+		}
+
+		// Federate the query across the source tenants of the rule group being
+		// evaluated, if any.
+		if sourceTenants := federation.sourceTenants; len(sourceTenants) > 0 {
+			if err := checkSourceTenantsAllowed(overrides, userID, sourceTenants); err != nil {
+				return nil, err
+			}
+			ctx = user.InjectOrgID(ctx, tenant.JoinTenantIDs(sourceTenants))
+		}
+
+		limit := overrides.RulerMaxConcurrentRuleGroupEvaluations(userID)
+
+		if group := evaluations.group(ctx); group != nil {
+			// The first query of each evaluation of the rule group is delayed by the jitter,
+			// and the whole evaluation holds a single concurrency slot, so that the rule
+			// groups whose evaluation started are not slowed down by the others.
+			if err := evaluations.startQuery(ctx, group, t, overrides.RulerEvaluationJitter(userID), limit); err != nil {
+				return nil, err
+			}
+			defer evaluations.endQuery(group, qs)
+
+			// Align the evaluation timestamp to the interval of the rule group, so that
+			// the resulting samples are aligned regardless of when the rule group runs.
+			if overrides.RulerEvaluationAlignmentEnabled(userID) {
+				t = alignTimestamp(t, group.interval)
+			}
+		} else {
+			// The rule group being evaluated is unknown, so the query holds a slot on its own.
+			release, err := evaluations.limiter.acquire(ctx, limit)
+			if err != nil {
+				return nil, err
+			}
+			defer release()
+		}
+
 		// Delay the evaluation of all rules by a set interval to give a buffer
 		// to metric that haven't been forwarded to cortex yet.
 		evaluationDelay := overrides.EvaluationDelay(userID)
-		return orig(ctx, qs, t.Add(-evaluationDelay))
+		return queryFunc(ctx, qs, t.Add(-evaluationDelay))
+	}
+}
+
+// alignTimestamp returns the input timestamp aligned to the input interval,
+// since the Unix epoch.
+func alignTimestamp(t time.Time, interval time.Duration) time.Time {
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(interval))
+}
+
+// tenantEvaluations holds the state shared by the evaluations of the rule groups
+// of a tenant, across all the rules managers of the tenant.
+type tenantEvaluations struct {
+	limiter concurrencyLimiter
+
+	// The rule groups of the tenant, keyed by namespace and group name.
+	mtx    sync.Mutex
+	groups map[ruleGroupKey]*ruleGroupInfo
+}
+
+type ruleGroupKey struct {
+	namespace string
+	name      string
+}
+
+type ruleGroupInfo struct {
+	interval time.Duration
+
+	// The queries of the rules of the group, in evaluation order, as passed to the query function.
+	queries []string
+
+	// The evaluation in progress, protected by the mutex of tenantEvaluations.
+	evaluation *ruleGroupEvaluation
+}
+
+// ruleGroupEvaluation is an evaluation of a rule group, whose rules are evaluated in
+// sequence at the same timestamp.
+type ruleGroupEvaluation struct {
+	ts time.Time
+
+	// The index of the next rule whose query is expected.
+	next int
+
+	// Releases the concurrency slot held by the evaluation, if any.
+	release func()
+}
+
+func (e *ruleGroupEvaluation) releaseSlot() {
+	if e != nil && e.release != nil {
+		e.release()
+		e.release = nil
+	}
+}
+
+func newTenantEvaluations() *tenantEvaluations {
+	return &tenantEvaluations{groups: map[ruleGroupKey]*ruleGroupInfo{}}
+}
+
+// update replaces the tracked rule groups with the input ones. The input interval
+// is the one of the rule groups not specifying it.
+func (e *tenantEvaluations) update(groups store.RuleGroupList, defaultInterval time.Duration) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	infos := make(map[ruleGroupKey]*ruleGroupInfo, len(groups))
+	for _, rg := range groups {
+		key := ruleGroupKey{namespace: rg.Namespace, name: rg.Name}
+		info := &ruleGroupInfo{interval: rg.Interval, queries: make([]string, 0, len(rg.Rules))}
+		if info.interval <= 0 {
+			info.interval = defaultInterval
+		}
+		for _, r := range rg.Rules {
+			info.queries = append(info.queries, ruleQuery(r.Expr))
+		}
+		if prev, ok := e.groups[key]; ok {
+			info.evaluation = prev.evaluation
+		}
+		infos[key] = info
+	}
+
+	// Release the slots held by the evaluations of the removed rule groups.
+	for key, prev := range e.groups {
+		if _, ok := infos[key]; !ok {
+			prev.evaluation.releaseSlot()
+		}
+	}
+	e.groups = infos
+}
+
+// group returns the rule group being evaluated, or nil if unknown. The rule group is
+// identified by the query origin, which the Prometheus rules manager attaches to the
+// context: its file is the namespace mapped to disk.
+func (e *tenantEvaluations) group(ctx context.Context) *ruleGroupInfo {
+	origin, ok := ctx.Value(promql.QueryOrigin{}).(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	group, ok := origin["ruleGroup"].(map[string]string)
+	if !ok {
+		return nil
+	}
+	namespace, err := url.PathUnescape(filepath.Base(group["file"]))
+	if err != nil {
+		return nil
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	return e.groups[ruleGroupKey{namespace: namespace, name: group["name"]}]
+}
+
+// startQuery is called before each query of the rule group. If the query starts a new
+// evaluation of the rule group, it waits for a random jitter lower than the interval
+// of the rule group and then for a concurrency slot, held until the last rule query.
+func (e *tenantEvaluations) startQuery(ctx context.Context, g *ruleGroupInfo, ts time.Time, jitter time.Duration, limit int) error {
+	e.mtx.Lock()
+	if g.evaluation != nil && g.evaluation.ts.Equal(ts) {
+		e.mtx.Unlock()
+		return nil
+	}
+
+	// The slot of the previous evaluation is released here if the query of its last
+	// rule hasn't been seen, e.g. because the rules have changed in the meanwhile.
+	g.evaluation.releaseSlot()
+	evaluation := &ruleGroupEvaluation{ts: ts}
+	g.evaluation = evaluation
+	e.mtx.Unlock()
+
+	if jitter > 0 {
+		if jitter > g.interval {
+			jitter = g.interval
+		}
+
+		select {
+		case <-time.After(time.Duration(rand.Int63n(int64(jitter)))):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	release, err := e.limiter.acquire(ctx, limit)
+	if err != nil {
+		return err
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	evaluation.release = release
+	return nil
+}
+
+// endQuery is called after each query of the rule group, and releases the concurrency
+// slot of the evaluation after the query of its last rule. Other queries, like the ones
+// run by the alerts templates, don't advance the evaluation.
+func (e *tenantEvaluations) endQuery(g *ruleGroupInfo, qs string) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	evaluation := g.evaluation
+	if evaluation == nil {
+		return
+	}
+
+	if evaluation.next < len(g.queries) && g.queries[evaluation.next] == qs {
+		evaluation.next++
+	}
+	if evaluation.next >= len(g.queries) {
+		evaluation.releaseSlot()
+	}
+}
+
+// ruleQuery returns the query run by the rule with the input expression, which is
+// the expression formatted by the PromQL parser.
+func ruleQuery(expr string) string {
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return expr
+	}
+	return parsed.String()
+}
+
+type tenantEvaluationsContextKey struct{}
+
+// withTenantEvaluations returns a context holding the evaluations state of a tenant.
+// The context passed to a ManagerFactory is inherited by the rules evaluation.
+func withTenantEvaluations(ctx context.Context, e *tenantEvaluations) context.Context {
+	return context.WithValue(ctx, tenantEvaluationsContextKey{}, e)
+}
+
+func tenantEvaluationsFromContext(ctx context.Context) *tenantEvaluations {
+	e, _ := ctx.Value(tenantEvaluationsContextKey{}).(*tenantEvaluations)
+	return e
+}
+
+// ruleGroupFederation holds the tenants a federated rule group reads from and
+// writes to. The rule groups of a tenant sharing the same federation are evaluated
+// by the same rules manager, whose context carries it.
+type ruleGroupFederation struct {
+	// The sorted and deduplicated tenants queried by the rules.
+	sourceTenants []string
+	// The tenant the series resulting from the rules are written to.
+	destinationTenant string
+}
+
+func newRuleGroupFederation(rg *store.RuleGroupDesc) ruleGroupFederation {
+	f := ruleGroupFederation{destinationTenant: rg.DestinationTenant}
+	if len(rg.SourceTenants) > 0 {
+		f.sourceTenants = tenant.NormalizeTenantIDs(append([]string(nil), rg.SourceTenants...))
+	}
+	return f
+}
+
+// key returns the key identifying the federation, which is empty for the rule
+// groups not federated.
+func (f ruleGroupFederation) key() string {
+	if len(f.sourceTenants) == 0 && f.destinationTenant == "" {
+		return ""
+	}
+
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(tenant.JoinTenantIDs(f.sourceTenants)))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(f.destinationTenant))
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+type ruleGroupFederationContextKey struct{}
+
+// withRuleGroupFederation returns a context holding the federation of the rule
+// groups evaluated with it.
+func withRuleGroupFederation(ctx context.Context, f ruleGroupFederation) context.Context {
+	return context.WithValue(ctx, ruleGroupFederationContextKey{}, f)
+}
+
+func ruleGroupFederationFromContext(ctx context.Context) ruleGroupFederation {
+	f, _ := ctx.Value(ruleGroupFederationContextKey{}).(ruleGroupFederation)
+	return f
+}
+
+// concurrencyLimiter limits the number of concurrent operations. The limit can
+// change over time: the operations running while it changes are not accounted
+// against the new limit.
+type concurrencyLimiter struct {
+	mtx sync.Mutex
+	sem chan struct{}
+}
+
+// acquire waits until the operation can run, and returns the function to call
+// once it completes. A limit <= 0 means unlimited.
+func (l *concurrencyLimiter) acquire(ctx context.Context, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+
+	l.mtx.Lock()
+	if cap(l.sem) != limit {
+		l.sem = make(chan struct{}, limit)
+	}
+	sem := l.sem
+	l.mtx.Unlock()
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
 	}
 }
 
@@ -110,12 +486,43 @@ type RulesManager interface {
 // ManagerFactory is a function that creates new RulesManager for given user and notifier.Manager.
 type ManagerFactory func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager
 
-func DefaultTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, engine *promql.Engine, overrides RulesLimits) ManagerFactory {
+// QueryEngines provides the PromQL engines evaluating the rule queries, which
+// may have options overridden per tenant.
+type QueryEngines interface {
+	// ForTenants returns the engine evaluating the queries of the given tenants.
+	ForTenants(tenantIDs []string) *promql.Engine
+
+	// TranslateError returns the error to return for a failed query of the given tenants.
+	TranslateError(tenantIDs []string, err error) error
+}
+
+func DefaultTenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, engines QueryEngines, overrides RulesLimits) ManagerFactory {
+	return TenantManagerFactory(cfg, p, q, enginesQueryFunc(engines, q), overrides)
+}
+
+// enginesQueryFunc returns a QueryFunc evaluating the queries with the engine of
+// the tenants the query is run for, which are the source tenants of federated rule groups.
+func enginesQueryFunc(engines QueryEngines, q storage.Queryable) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		tenantIDs, err := tenant.TenantIDs(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := rules.EngineQueryFunc(engines.ForTenants(tenantIDs), q)(ctx, qs, t)
+		return v, engines.TranslateError(tenantIDs, err)
+	}
+}
+
+// TenantManagerFactory returns a ManagerFactory evaluating the rule queries with the
+// input QueryFunc, e.g. remotely via the query-frontend. The Queryable is still used
+// to restore the "for" state of the alerts.
+func TenantManagerFactory(cfg Config, p Pusher, q storage.Queryable, queryFunc rules.QueryFunc, overrides RulesLimits) ManagerFactory {
 	return func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager {
 		return rules.NewManager(&rules.ManagerOptions{
-			Appendable:      &PusherAppendable{pusher: p, userID: userID},
+			Appendable:      &PusherAppendable{pusher: p, limits: overrides, userID: userID},
 			Queryable:       q,
-			QueryFunc:       engineQueryFunc(engine, q, overrides, userID),
+			QueryFunc:       tenantQueryFunc(queryFunc, overrides, userID),
 			Context:         user.InjectOrgID(ctx, userID),
 			ExternalURL:     cfg.ExternalURL.URL,
 			NotifyFunc:      SendAlerts(notifier, cfg.ExternalURL.URL.String()),
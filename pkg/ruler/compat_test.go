@@ -0,0 +1,345 @@
+package ruler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/ruler/rules"
+)
+
+func TestTenantQueryFunc_FederatedRuleGroups(t *testing.T) {
+	cfg, cleanup := defaultRulerConfig(newMockRuleStore(nil))
+	defer cleanup()
+
+	engines, _, _, _, overrides, setupCleanup := testSetup(t, cfg)
+	defer setupCleanup()
+
+	var queriedOrgID string
+	queryable := storage.QueryableFunc(func(ctx context.Context, _, _ int64) (storage.Querier, error) {
+		orgID, err := user.ExtractOrgID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		queriedOrgID = orgID
+		return storage.NoopQuerier(), nil
+	})
+
+	tests := map[string]struct {
+		group         *rules.RuleGroupDesc
+		expectedOrgID string
+		expectedErr   string
+	}{
+		"federated rule group": {
+			group:         &rules.RuleGroupDesc{Namespace: "namespace", Name: "federated", User: "user1", SourceTenants: []string{"tenant-b", "tenant-a", "tenant-b"}},
+			expectedOrgID: "tenant-a|tenant-b",
+		},
+		"federated rule group querying its owner": {
+			group:         &rules.RuleGroupDesc{Namespace: "namespace", Name: "federated", User: "user1", SourceTenants: []string{"tenant-a", "user1"}},
+			expectedOrgID: "tenant-a|user1",
+		},
+		"federated rule group with a source tenant not allowed": {
+			group:       &rules.RuleGroupDesc{Namespace: "namespace", Name: "federated", User: "user1", SourceTenants: []string{"tenant-a", "tenant-c"}},
+			expectedErr: `source tenant "tenant-c" is not allowed: it must be listed in the ruler_allowed_source_tenants limit of the tenant`,
+		},
+		"rule group with a destination tenant not allowed": {
+			group:       &rules.RuleGroupDesc{Namespace: "namespace", Name: "federated", User: "user1", SourceTenants: []string{"tenant-a"}, DestinationTenant: "tenant-b"},
+			expectedErr: `destination tenant "tenant-b" is not allowed: it must be listed in the ruler_allowed_destination_tenants limit of the tenant`,
+		},
+		"rule group without source tenants": {
+			group:         &rules.RuleGroupDesc{Namespace: "namespace", Name: "local", User: "user1"},
+			expectedOrgID: "user1",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			queriedOrgID = ""
+
+			ctx := withRuleGroupFederation(user.InjectOrgID(context.Background(), "user1"), newRuleGroupFederation(testData.group))
+
+			_, err := tenantQueryFunc(promRules.EngineQueryFunc(engines.Default(), queryable), overrides, "user1")(ctx, "up", time.Now())
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				assert.Empty(t, queriedOrgID)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedOrgID, queriedOrgID)
+		})
+	}
+}
+
+func TestPusherAppendable_DestinationTenant(t *testing.T) {
+	tests := map[string]struct {
+		group          *rules.RuleGroupDesc
+		expectedUserID string
+		expectedErr    string
+	}{
+		"rule group with a destination tenant": {
+			group:          &rules.RuleGroupDesc{Namespace: "namespace", Name: "aggregations", User: "user1", SourceTenants: []string{"tenant-a", "tenant-b"}, DestinationTenant: "aggregations"},
+			expectedUserID: "aggregations",
+		},
+		"rule group with a destination tenant not allowed": {
+			group:       &rules.RuleGroupDesc{Namespace: "namespace", Name: "aggregations", User: "user1", DestinationTenant: "tenant-a"},
+			expectedErr: `destination tenant "tenant-a" is not allowed: it must be listed in the ruler_allowed_destination_tenants limit of the tenant`,
+		},
+		"rule group without a destination tenant": {
+			group:          &rules.RuleGroupDesc{Namespace: "namespace", Name: "local", User: "user1"},
+			expectedUserID: "user1",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var pushedUserID string
+			pusher := newPusherMock()
+			pusher.On("Push", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				pushedUserID, _ = user.ExtractOrgID(args.Get(0).(context.Context))
+			}).Return(&client.WriteResponse{}, nil)
+
+			ctx := withRuleGroupFederation(user.InjectOrgID(context.Background(), "user1"), newRuleGroupFederation(testData.group))
+
+			limits := ruleLimits{allowedDestinationTenants: []string{"aggregations"}}
+			app := (&PusherAppendable{pusher: pusher, limits: limits, userID: "user1"}).Appender(ctx)
+			_, err := app.Add(labels.FromStrings("__name__", "up:sum"), 0, 1)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+				require.EqualError(t, app.Commit(), testData.expectedErr)
+				pusher.AssertNotCalled(t, "Push", mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			require.NoError(t, app.Commit())
+
+			assert.Equal(t, testData.expectedUserID, pushedUserID)
+		})
+	}
+}
+
+func TestRuleGroupFederation_Key(t *testing.T) {
+	local := newRuleGroupFederation(&rules.RuleGroupDesc{Name: "local"})
+	federated := newRuleGroupFederation(&rules.RuleGroupDesc{Name: "federated", SourceTenants: []string{"tenant-b", "tenant-a"}})
+
+	assert.Equal(t, "", local.key())
+	assert.NotEqual(t, "", federated.key())
+
+	// The source tenants are normalized.
+	assert.Equal(t, federated.key(), newRuleGroupFederation(&rules.RuleGroupDesc{SourceTenants: []string{"tenant-a", "tenant-b", "tenant-a"}}).key())
+
+	// The destination tenant is part of the federation.
+	assert.NotEqual(t, federated.key(), newRuleGroupFederation(&rules.RuleGroupDesc{SourceTenants: []string{"tenant-a", "tenant-b"}, DestinationTenant: "aggregations"}).key())
+	assert.NotEqual(t, "", newRuleGroupFederation(&rules.RuleGroupDesc{DestinationTenant: "aggregations"}).key())
+}
+
+func TestTenantQueryFunc_EvaluationControls(t *testing.T) {
+	newEvaluations := func(groups rules.RuleGroupList) *tenantEvaluations {
+		evaluations := newTenantEvaluations()
+		evaluations.update(groups, time.Minute)
+		return evaluations
+	}
+
+	groupContext := func(evaluations *tenantEvaluations, name string) context.Context {
+		ctx := withTenantEvaluations(user.InjectOrgID(context.Background(), "user1"), evaluations)
+		return promql.NewOriginContext(ctx, map[string]interface{}{
+			"ruleGroup": map[string]string{
+				"file": "/rules/user1/namespace",
+				"name": name,
+			},
+		})
+	}
+
+	evalTime := time.Unix(1600000123, 0)
+
+	t.Run("evaluation timestamp alignment", func(t *testing.T) {
+		evaluations := newEvaluations(rules.RuleGroupList{
+			{Namespace: "namespace", Name: "group", User: "user1", Interval: 5 * time.Minute, Rules: []*rules.RuleDesc{{Record: "rule", Expr: "up"}}},
+			{Namespace: "namespace", Name: "default-interval", User: "user1", Rules: []*rules.RuleDesc{{Record: "rule", Expr: "up"}}},
+		})
+
+		tests := map[string]struct {
+			group            string
+			alignmentEnabled bool
+			expected         time.Time
+		}{
+			"alignment disabled": {
+				group:    "group",
+				expected: evalTime.Add(-time.Minute),
+			},
+			"aligned to the rule group interval": {
+				group:            "group",
+				alignmentEnabled: true,
+				expected:         time.Unix(1599999900, 0).Add(-time.Minute),
+			},
+			"aligned to the default interval": {
+				group:            "default-interval",
+				alignmentEnabled: true,
+				expected:         time.Unix(1600000080, 0).Add(-time.Minute),
+			},
+			"unknown rule group is not aligned": {
+				group:            "missing",
+				alignmentEnabled: true,
+				expected:         evalTime.Add(-time.Minute),
+			},
+		}
+
+		for testName, testData := range tests {
+			t.Run(testName, func(t *testing.T) {
+				var queried time.Time
+				queryFunc := func(_ context.Context, _ string, ts time.Time) (promql.Vector, error) {
+					queried = ts
+					return nil, nil
+				}
+
+				limits := ruleLimits{evalDelay: time.Minute, evaluationAlignmentEnabled: testData.alignmentEnabled}
+				_, err := tenantQueryFunc(queryFunc, limits, "user1")(groupContext(evaluations, testData.group), "up", evalTime)
+				require.NoError(t, err)
+				assert.True(t, testData.expected.Equal(queried), "expected %s, got %s", testData.expected, queried)
+			})
+		}
+	})
+
+	t.Run("max concurrent rule group evaluations across the managers of the tenant", func(t *testing.T) {
+		const concurrency = 5
+
+		// Each rule group has two rules, evaluated in sequence.
+		var groups rules.RuleGroupList
+		for i := 0; i < concurrency; i++ {
+			groups = append(groups, &rules.RuleGroupDesc{Namespace: "namespace", Name: fmt.Sprintf("group-%d", i), User: "user1", Rules: []*rules.RuleDesc{
+				{Record: "first", Expr: "up"},
+				{Record: "second", Expr: "sum(up)"},
+			}})
+		}
+		evaluations := newEvaluations(groups)
+
+		var (
+			mtx     sync.Mutex
+			running = map[string]bool{}
+			maxSeen int
+		)
+		queryFunc := func(ctx context.Context, _ string, _ time.Time) (promql.Vector, error) {
+			group := ctx.Value(promql.QueryOrigin{}).(map[string]interface{})["ruleGroup"].(map[string]string)["name"]
+
+			mtx.Lock()
+			running[group] = true
+			if len(running) > maxSeen {
+				maxSeen = len(running)
+			}
+			mtx.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+			return nil, nil
+		}
+
+		// Each manager of the tenant has its own query function, sharing the tenant evaluations.
+		limits := ruleLimits{maxConcurrentEvaluations: 2}
+		queries := []promRules.QueryFunc{
+			tenantQueryFunc(queryFunc, limits, "user1"),
+			tenantQueryFunc(queryFunc, limits, "user1"),
+		}
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < concurrency; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				name := fmt.Sprintf("group-%d", i)
+				query := queries[i%len(queries)]
+				for _, qs := range []string{"up", "sum(up)"} {
+					_, err := query(groupContext(evaluations, name), qs, evalTime)
+					assert.NoError(t, err)
+				}
+
+				// The rule group holds its slot until the query of its last rule completes.
+				mtx.Lock()
+				delete(running, name)
+				mtx.Unlock()
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, 2, maxSeen)
+	})
+
+	t.Run("slot of an evaluation is released after the query of its last rule", func(t *testing.T) {
+		evaluations := newEvaluations(rules.RuleGroupList{
+			{Namespace: "namespace", Name: "group", User: "user1", Rules: []*rules.RuleDesc{
+				{Alert: "first", Expr: "up == 0"},
+				{Record: "second", Expr: "sum by(job) (up)"},
+			}},
+		})
+		queryFunc := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+			return nil, nil
+		}
+		query := tenantQueryFunc(queryFunc, ruleLimits{maxConcurrentEvaluations: 1}, "user1")
+		ctx := groupContext(evaluations, "group")
+
+		// The queries are the ones of the rules formatted by the PromQL parser, interleaved
+		// with the queries of the alerts templates.
+		for _, qs := range []string{"up == 0", "count(up)", "sum by(job) (up)"} {
+			_, err := query(ctx, qs, evalTime)
+			require.NoError(t, err)
+		}
+
+		// The slot is free, so the next evaluation doesn't block.
+		timeoutCtx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		_, err := query(timeoutCtx, "up == 0", evalTime.Add(time.Minute))
+		require.NoError(t, err)
+	})
+
+	t.Run("jitter is applied once per evaluation and is lower than the rule group interval", func(t *testing.T) {
+		evaluations := newEvaluations(rules.RuleGroupList{
+			{Namespace: "namespace", Name: "group", User: "user1", Interval: 10 * time.Millisecond, Rules: []*rules.RuleDesc{{Record: "rule", Expr: "up"}}},
+		})
+
+		queries := 0
+		queryFunc := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+			queries++
+			return nil, nil
+		}
+		query := tenantQueryFunc(queryFunc, ruleLimits{evaluationJitter: time.Hour}, "user1")
+
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 2; j++ {
+				_, err := query(groupContext(evaluations, "group"), "up", evalTime.Add(time.Duration(i)*time.Minute))
+				require.NoError(t, err)
+			}
+		}
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		assert.Equal(t, 6, queries)
+	})
+
+	t.Run("query fails when the context is canceled during the jitter", func(t *testing.T) {
+		evaluations := newEvaluations(rules.RuleGroupList{
+			{Namespace: "namespace", Name: "group", User: "user1", Interval: time.Hour, Rules: []*rules.RuleDesc{{Record: "rule", Expr: "up"}}},
+		})
+
+		queries := 0
+		queryFunc := func(_ context.Context, _ string, _ time.Time) (promql.Vector, error) {
+			queries++
+			return nil, nil
+		}
+
+		ctx, cancel := context.WithCancel(groupContext(evaluations, "group"))
+		cancel()
+
+		_, err := tenantQueryFunc(queryFunc, ruleLimits{evaluationJitter: time.Hour}, "user1")(ctx, "up", evalTime)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 0, queries)
+	})
+}
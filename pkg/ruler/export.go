@@ -0,0 +1,322 @@
+package ruler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	store "github.com/cortexproject/cortex/pkg/ruler/rules"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// Rule groups archive
+// ===================
+// The rule groups of all the tenants are exported as a gzipped tarball, holding
+// a file per tenant and namespace: "<tenant>/<URL path escaped namespace>.yaml".
+// Each file has the format of a Prometheus rules file, with the rule groups
+// extended with the Cortex specific fields (e.g. source tenants).
+
+const (
+	ruleGroupsArchiveFileExt = ".yaml"
+
+	// Max size of the imported rule groups archive, and of its decompressed content.
+	maxRuleGroupsArchiveSize             = 64 << 20
+	maxRuleGroupsArchiveDecompressedSize = 512 << 20
+)
+
+var errRuleGroupsArchiveTooLarge = fmt.Errorf("the decompressed rule groups archive exceeds the limit of %d bytes", maxRuleGroupsArchiveDecompressedSize)
+
+type ruleGroupsFile struct {
+	Groups []store.RuleGroup `yaml:"groups"`
+}
+
+// ExportRuleGroups writes the rule groups of all the tenants, as stored in the
+// configured rule store, to a gzipped tarball. It's meant to backup the rule
+// groups, or migrate them between clusters or backends via ImportRuleGroups.
+func (a *API) ExportRuleGroups(w http.ResponseWriter, req *http.Request) {
+	logger := util.WithContext(req.Context(), util.Logger)
+
+	allGroups, err := a.store.ListAllRuleGroups(req.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to list rule groups to export", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := a.store.LoadRuleGroups(req.Context(), allGroups); err != nil {
+		level.Error(logger).Log("msg", "unable to load rule groups to export", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Build the archive in memory, in order to return an error if any file
+	// can't be encoded, before starting to send the response.
+	archive, err := writeRuleGroupsArchive(allGroups)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to build the rule groups archive", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/tar+gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="rule-groups.tar.gz"`)
+	if _, err := w.Write(archive); err != nil {
+		level.Error(logger).Log("msg", "unable to write the rule groups archive", "err", err)
+	}
+}
+
+// ImportRuleGroups restores the rule groups from a gzipped tarball generated by
+// ExportRuleGroups. The imported rule groups replace the existing ones with the
+// same tenant, namespace and name, while the other ones are left untouched. All
+// the rule groups are validated, including the tenants' rule limits, before any
+// is stored.
+func (a *API) ImportRuleGroups(w http.ResponseWriter, req *http.Request) {
+	logger := util.WithContext(req.Context(), util.Logger)
+
+	body := http.MaxBytesReader(w, req.Body, maxRuleGroupsArchiveSize)
+	groups, err := readRuleGroupsArchive(body, maxRuleGroupsArchiveDecompressedSize)
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to read the rule groups archive", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, rg := range groups {
+		if err := a.validateImportedRuleGroup(rg); err != nil {
+			msg := fmt.Sprintf("invalid rule group %q in namespace %q of tenant %q: %s", rg.Name, rg.Namespace, rg.User, err)
+			level.Error(logger).Log("msg", "unable to validate the imported rule groups", "err", msg)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := a.assertImportedRuleGroupsLimit(req.Context(), groups); err != nil {
+		level.Error(logger).Log("msg", "limit validation failure of the imported rule groups", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, rg := range groups {
+		if err := a.store.SetRuleGroup(req.Context(), rg.User, rg.Namespace, rg); err != nil {
+			level.Error(logger).Log("msg", "unable to store imported rule group", "user", rg.User, "namespace", rg.Namespace, "group", rg.Name, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	level.Info(logger).Log("msg", "imported rule groups", "groups", len(groups))
+	respondAccepted(w, logger)
+}
+
+func (a *API) validateImportedRuleGroup(rg *store.RuleGroupDesc) error {
+	if err := tenant.ValidTenantID(rg.User); err != nil {
+		return err
+	}
+
+	formatted := store.FromProtoWithSourceTenants(rg)
+	if errs := a.ruler.manager.ValidateRuleGroup(formatted.RuleGroup); len(errs) > 0 {
+		e := make([]string, 0, len(errs))
+		for _, err := range errs {
+			e = append(e, err.Error())
+		}
+		return errors.New(strings.Join(e, ", "))
+	}
+
+	if err := a.ruler.AssertMaxRulesPerRuleGroup(rg.User, len(rg.Rules)); err != nil {
+		return err
+	}
+
+	if err := a.ruler.AssertSourceTenants(rg.User, formatted.SourceTenants); err != nil {
+		return err
+	}
+	return a.ruler.AssertDestinationTenant(rg.User, formatted.DestinationTenant, formatted.Rules)
+}
+
+// assertImportedRuleGroupsLimit checks that the tenants don't exceed the max number of
+// rule groups once the imported ones are added. The imported rule groups replacing an
+// existing one don't add to the count.
+func (a *API) assertImportedRuleGroupsLimit(ctx context.Context, groups store.RuleGroupList) error {
+	added := map[string]map[string]bool{}
+	for _, rg := range groups {
+		if added[rg.User] == nil {
+			added[rg.User] = map[string]bool{}
+		}
+		added[rg.User][rg.Namespace+"/"+rg.Name] = true
+	}
+
+	for userID, userGroups := range added {
+		existing, err := a.store.ListRuleGroupsForUserAndNamespace(ctx, userID, "")
+		if err != nil {
+			return errors.Wrapf(err, "unable to fetch the current rule groups of tenant %q", userID)
+		}
+
+		count := len(userGroups)
+		for _, rg := range existing {
+			if !userGroups[rg.Namespace+"/"+rg.Name] {
+				count++
+			}
+		}
+
+		if limit := a.ruler.limits.RulerMaxRuleGroupsPerTenant(userID); limit > 0 && count > limit {
+			return fmt.Errorf("tenant %q: "+errMaxRuleGroupsPerUserLimitExceeded, userID, limit, count)
+		}
+	}
+
+	return nil
+}
+
+// writeRuleGroupsArchive returns the gzipped tarball of the input rule groups,
+// keyed by tenant. The files are sorted to get a stable output.
+func writeRuleGroupsArchive(allGroups map[string]store.RuleGroupList) ([]byte, error) {
+	type archiveFile struct {
+		name   string
+		groups []store.RuleGroup
+	}
+
+	var files []archiveFile
+	for userID, groups := range allGroups {
+		namespaces := map[string][]store.RuleGroup{}
+		for _, g := range groups {
+			namespaces[g.Namespace] = append(namespaces[g.Namespace], store.FromProtoWithSourceTenants(g))
+		}
+
+		for namespace, nsGroups := range namespaces {
+			files = append(files, archiveFile{
+				name:   path.Join(userID, url.PathEscape(namespace)+ruleGroupsArchiveFileExt),
+				groups: nsGroups,
+			})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+
+	buf := bytes.Buffer{}
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+
+	for _, f := range files {
+		data, err := yaml.Marshal(ruleGroupsFile{Groups: f.groups})
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to encode the rule groups file %s", f.name)
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     f.name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// readRuleGroupsArchive reads the rule groups from a gzipped tarball written by
+// writeRuleGroupsArchive, failing if the decompressed archive exceeds maxSize bytes.
+func readRuleGroupsArchive(r io.Reader, maxSize int64) (store.RuleGroupList, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to decompress the rule groups archive")
+	}
+	defer gr.Close()
+
+	var groups store.RuleGroupList
+	tr := tar.NewReader(&sizeLimitedReader{r: gr, remaining: maxSize})
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the rule groups archive")
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		userID, namespace, err := parseRuleGroupsArchiveFileName(header.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the rule groups file %s", header.Name)
+		}
+
+		var file ruleGroupsFile
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&file); err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "unable to decode the rule groups file %s", header.Name)
+		}
+
+		for _, rg := range file.Groups {
+			desc := store.ToProto(userID, namespace, rg.RuleGroup)
+			desc.SourceTenants = rg.SourceTenants
+			desc.DestinationTenant = rg.DestinationTenant
+			groups = append(groups, desc)
+		}
+	}
+
+	return groups, nil
+}
+
+// sizeLimitedReader returns errRuleGroupsArchiveTooLarge once more than the
+// remaining bytes are read, instead of the EOF returned by io.LimitedReader.
+type sizeLimitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errRuleGroupsArchiveTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return 0, errRuleGroupsArchiveTooLarge
+	}
+	return n, err
+}
+
+func parseRuleGroupsArchiveFileName(name string) (userID, namespace string, err error) {
+	parts := strings.Split(strings.TrimPrefix(name, "./"), "/")
+	if len(parts) != 2 || parts[0] == "" || !strings.HasSuffix(parts[1], ruleGroupsArchiveFileExt) {
+		return "", "", fmt.Errorf("unexpected file %s in the rule groups archive, expected <tenant>/<namespace>%s", name, ruleGroupsArchiveFileExt)
+	}
+
+	namespace, err = url.PathUnescape(strings.TrimSuffix(parts[1], ruleGroupsArchiveFileExt))
+	if err != nil || namespace == "" {
+		return "", "", fmt.Errorf("invalid namespace in the file %s of the rule groups archive", name)
+	}
+	return parts[0], namespace, nil
+}
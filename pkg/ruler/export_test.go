@@ -0,0 +1,224 @@
+package ruler
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rules"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+func TestAPI_ExportImportRuleGroups(t *testing.T) {
+	source := newMockRuleStore(map[string]rules.RuleGroupList{
+		"user1": {
+			{User: "user1", Namespace: "namespace/1", Name: "group1", Interval: time.Minute, Rules: []*rules.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+			{User: "user1", Namespace: "namespace/1", Name: "group2", Rules: []*rules.RuleDesc{{Alert: "UP_ALERT", Expr: "up < 1", For: time.Minute}}},
+			{User: "user1", Namespace: "namespace2", Name: "federated", SourceTenants: []string{"tenant-a", "tenant-b"}, DestinationTenant: "aggregations", Rules: []*rules.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+		},
+		"user2": {
+			{User: "user2", Namespace: "namespace1", Name: "group1", Rules: []*rules.RuleDesc{{Record: "up:count", Expr: "count(up)"}}},
+		},
+	})
+
+	cfg, cleanup := defaultRulerConfig(source)
+	defer cleanup()
+	cfg.TenantFederation.Enabled = true
+
+	r, rcleanup := newTestRuler(t, cfg)
+	defer rcleanup()
+	defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+	// Export the rule groups.
+	w := httptest.NewRecorder()
+	NewAPI(r, source).ExportRuleGroups(w, httptest.NewRequest(http.MethodGet, "/ruler/rule_groups/export", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "application/tar+gzip", w.Header().Get("Content-Type"))
+
+	archive := w.Body.Bytes()
+	assert.Equal(t, []string{"user1/namespace%2F1.yaml", "user1/namespace2.yaml", "user2/namespace1.yaml"}, archiveFileNames(t, archive))
+
+	// Import the rule groups into an empty store.
+	destination := newMockRuleStore(map[string]rules.RuleGroupList{})
+	w = httptest.NewRecorder()
+	NewAPI(r, destination).ImportRuleGroups(w, httptest.NewRequest(http.MethodPost, "/ruler/rule_groups/import", bytes.NewReader(archive)))
+	require.Equal(t, http.StatusAccepted, w.Code)
+
+	expected, err := source.ListAllRuleGroups(context.Background())
+	require.NoError(t, err)
+	actual, err := destination.ListAllRuleGroups(context.Background())
+	require.NoError(t, err)
+
+	require.Len(t, actual, len(expected))
+	for userID, groups := range expected {
+		require.Len(t, actual[userID], len(groups))
+		for _, g := range groups {
+			imported, err := destination.GetRuleGroup(context.Background(), userID, g.Namespace, g.Name)
+			require.NoError(t, err)
+			assert.True(t, g.Equal(imported), "expected %s, got %s", g, imported)
+		}
+	}
+}
+
+func TestAPI_ImportRuleGroups_Invalid(t *testing.T) {
+	validFile := "groups:\n- name: group\n  rules:\n  - record: up:sum\n    expr: sum(up)\n"
+
+	tests := map[string]struct {
+		files    map[string]string
+		expected string
+	}{
+		"file outside of a tenant directory": {
+			files:    map[string]string{"namespace.yaml": validFile},
+			expected: "unexpected file namespace.yaml in the rule groups archive, expected <tenant>/<namespace>.yaml\n",
+		},
+		"invalid YAML": {
+			files:    map[string]string{"user1/namespace.yaml": "groups:\n- name: group\n  unknown: field\n"},
+			expected: "unable to decode the rule groups file user1/namespace.yaml: yaml: unmarshal errors:\n  line 3: field unknown not found in type rules.RuleGroup\n",
+		},
+		"invalid rule group": {
+			files: map[string]string{
+				"user1/namespace.yaml": validFile,
+				"user2/namespace.yaml": "groups:\n- name: group\n  rules:\n  - record: up:sum\n    expr: sum(up\n",
+			},
+			expected: "invalid rule group \"group\" in namespace \"namespace\" of tenant \"user2\": 0:0: group \"group\", rule 0, \"up:sum\": could not parse expression: 1:7: parse error: unclosed left parenthesis\n",
+		},
+		"source tenants with the tenant federation disabled": {
+			files:    map[string]string{"user1/namespace.yaml": "groups:\n- name: group\n  source_tenants: [tenant-a]\n  rules:\n  - record: up:sum\n    expr: sum(up)\n"},
+			expected: "invalid rule group \"group\" in namespace \"namespace\" of tenant \"user1\": rule groups with source tenants are not allowed because the ruler tenant federation is disabled\n",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store := newMockRuleStore(map[string]rules.RuleGroupList{})
+
+			cfg, cleanup := defaultRulerConfig(store)
+			defer cleanup()
+
+			r, rcleanup := newTestRuler(t, cfg)
+			defer rcleanup()
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			w := httptest.NewRecorder()
+			NewAPI(r, store).ImportRuleGroups(w, httptest.NewRequest(http.MethodPost, "/ruler/rule_groups/import", bytes.NewReader(buildArchive(t, testData.files))))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, testData.expected, w.Body.String())
+
+			// No rule group has been stored.
+			groups, err := store.ListAllRuleGroups(context.Background())
+			require.NoError(t, err)
+			assert.Empty(t, groups)
+		})
+	}
+}
+
+func TestAPI_ImportRuleGroups_Limits(t *testing.T) {
+	group := func(name string, rules int) string {
+		g := "- name: " + name + "\n  rules:\n"
+		for i := 0; i < rules; i++ {
+			g += "  - record: up:sum\n    expr: sum(up)\n"
+		}
+		return g
+	}
+
+	tests := map[string]struct {
+		files            map[string]string
+		expectedStatus   int
+		expectedResponse string
+	}{
+		"rule groups replacing the existing ones within the limit": {
+			files:          map[string]string{"user1/namespace.yaml": "groups:\n" + group("existing", 1) + group("new", 1)},
+			expectedStatus: http.StatusAccepted,
+		},
+		"new rule groups exceeding the max rule groups": {
+			files:            map[string]string{"user1/namespace.yaml": "groups:\n" + group("new-1", 1) + group("new-2", 1)},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: "tenant \"user1\": per-user rule groups limit (limit: 2 actual: 3) exceeded\n",
+		},
+		"rule group exceeding the max rules per rule group": {
+			files:            map[string]string{"user2/namespace.yaml": "groups:\n" + group("group", 3)},
+			expectedStatus:   http.StatusBadRequest,
+			expectedResponse: "invalid rule group \"group\" in namespace \"namespace\" of tenant \"user2\": per-user rules per rule group limit (limit: 2 actual: 3) exceeded\n",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			store := newMockRuleStore(map[string]rules.RuleGroupList{
+				"user1": {
+					{User: "user1", Namespace: "namespace", Name: "existing", Rules: []*rules.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+				},
+			})
+
+			cfg, cleanup := defaultRulerConfig(store)
+			defer cleanup()
+
+			r, rcleanup := newTestRuler(t, cfg)
+			defer rcleanup()
+			defer services.StopAndAwaitTerminated(context.Background(), r) //nolint:errcheck
+
+			r.limits = &ruleLimits{maxRuleGroups: 2, maxRulesPerRuleGroup: 2}
+
+			w := httptest.NewRecorder()
+			NewAPI(r, store).ImportRuleGroups(w, httptest.NewRequest(http.MethodPost, "/ruler/rule_groups/import", bytes.NewReader(buildArchive(t, testData.files))))
+			require.Equal(t, testData.expectedStatus, w.Code)
+			if testData.expectedResponse != "" {
+				assert.Equal(t, testData.expectedResponse, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReadRuleGroupsArchive_ShouldFailIfTheDecompressedArchiveIsTooLarge(t *testing.T) {
+	archive := buildArchive(t, map[string]string{"user1/namespace.yaml": "groups: []\n" + strings.Repeat("#", 4096) + "\n"})
+
+	_, err := readRuleGroupsArchive(bytes.NewReader(archive), 1024)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), errRuleGroupsArchiveTooLarge.Error())
+
+	groups, err := readRuleGroupsArchive(bytes.NewReader(archive), int64(10*len(archive)+8192))
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+}
+
+func buildArchive(t *testing.T, files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+	return buf.Bytes()
+}
+
+func archiveFileNames(t *testing.T, archive []byte) []string {
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	require.NoError(t, err)
+
+	var names []string
+	tr := tar.NewReader(gr)
+	for {
+		header, err := tr.Next()
+		if err != nil {
+			break
+		}
+		_, err = ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		names = append(names, header.Name)
+	}
+	return names
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"path/filepath"
+	"sort"
 	"sync"
 
 	"github.com/go-kit/kit/log"
@@ -30,11 +32,17 @@ type DefaultMultiTenantManager struct {
 	mapper *mapper
 
 	// Structs for holding per-user Prometheus rules Managers
-	// and a corresponding metrics struct
+	// and a corresponding metrics struct. The rule groups of a user sharing the
+	// same federation are evaluated by the same manager, keyed by federation key.
 	userManagerMtx     sync.Mutex
-	userManagers       map[string]RulesManager
+	userManagers       map[string]map[string]*userManager
+	userRegistries     map[string]*prometheus.Registry
 	userManagerMetrics *ManagerMetrics
 
+	// Per-user state of the rule groups evaluations, shared by the managers of the
+	// user and protected by userManagerMtx.
+	userEvaluations map[string]*tenantEvaluations
+
 	// Per-user notifiers with separate queues.
 	notifiersMtx sync.Mutex
 	notifiers    map[string]*rulerNotifier
@@ -64,8 +72,10 @@ func NewDefaultMultiTenantManager(cfg Config, managerFactory ManagerFactory, reg
 		managerFactory:     managerFactory,
 		notifiers:          map[string]*rulerNotifier{},
 		mapper:             newMapper(cfg.RulePath, logger),
-		userManagers:       map[string]RulesManager{},
+		userManagers:       map[string]map[string]*userManager{},
+		userRegistries:     map[string]*prometheus.Registry{},
 		userManagerMetrics: userManagerMetrics,
+		userEvaluations:    map[string]*tenantEvaluations{},
 		managersTotal: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
 			Namespace: "cortex",
 			Name:      "ruler_managers_total",
@@ -102,10 +112,14 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 	}
 
 	// Check for deleted users and remove them
-	for userID, mngr := range r.userManagers {
+	for userID, managers := range r.userManagers {
 		if _, exists := ruleGroups[userID]; !exists {
-			go mngr.Stop()
+			for key, mngr := range managers {
+				r.stopManager(userID, key, mngr)
+			}
 			delete(r.userManagers, userID)
+			delete(r.userRegistries, userID)
+			delete(r.userEvaluations, userID)
 			r.lastReloadSuccessful.DeleteLabelValues(userID)
 			r.lastReloadSuccessfulTimestamp.DeleteLabelValues(userID)
 			r.configUpdatesTotal.DeleteLabelValues(userID)
@@ -114,65 +128,170 @@ func (r *DefaultMultiTenantManager) SyncRuleGroups(ctx context.Context, ruleGrou
 		}
 	}
 
-	r.managersTotal.Set(float64(len(r.userManagers)))
+	managersTotal := 0
+	for _, managers := range r.userManagers {
+		managersTotal += len(managers)
+	}
+	r.managersTotal.Set(float64(managersTotal))
 }
 
 // syncRulesToManager maps the rule files to disk, detects any changes and will create/update the
-// the users Prometheus Rules Manager.
+// the users Prometheus Rules Managers.
 func (r *DefaultMultiTenantManager) syncRulesToManager(ctx context.Context, user string, groups store.RuleGroupList) {
-	// Map the files to disk and return the file names to be passed to the users manager if they
-	// have been updated
-	update, files, err := r.mapper.MapRules(user, groups.Formatted())
-	if err != nil {
-		r.lastReloadSuccessful.WithLabelValues(user).Set(0)
-		level.Error(r.logger).Log("msg", "unable to map rule files", "user", user, "err", err)
-		return
-	}
-
-	manager, exists := r.userManagers[user]
-	if !exists || update {
-		level.Debug(r.logger).Log("msg", "updating rules", "user", user)
-		r.configUpdatesTotal.WithLabelValues(user).Inc()
-		if !exists {
-			level.Debug(r.logger).Log("msg", "creating rule manager for user", "user", user)
-			manager, err = r.newManager(ctx, user)
-			if err != nil {
-				r.lastReloadSuccessful.WithLabelValues(user).Set(0)
-				level.Error(r.logger).Log("msg", "unable to create rule manager", "user", user, "err", err)
-				return
-			}
-			// manager.Run() starts running the manager and blocks until Stop() is called.
-			// Hence run it as another goroutine.
-			go manager.Run()
-			r.userManagers[user] = manager
-		}
-		err = manager.Update(r.cfg.EvaluationInterval, files, nil)
+	if !r.cfg.TenantFederation.Enabled {
+		groups = r.removeFederatedRuleGroups(user, groups)
+	}
+
+	evaluations, ok := r.userEvaluations[user]
+	if !ok {
+		evaluations = newTenantEvaluations()
+		r.userEvaluations[user] = evaluations
+	}
+	evaluations.update(groups, r.cfg.EvaluationInterval)
+
+	// The rule groups are evaluated by a manager per federation, so that the
+	// federation is carried by the context of the evaluations. The rule groups
+	// not federated are always evaluated by a manager, even if there are none.
+	federations := map[string]ruleGroupFederation{"": {}}
+	federationGroups := map[string]store.RuleGroupList{"": nil}
+	for _, g := range groups {
+		f := newRuleGroupFederation(g)
+		federations[f.key()] = f
+		federationGroups[f.key()] = append(federationGroups[f.key()], g)
+	}
+
+	managers, ok := r.userManagers[user]
+	if !ok {
+		managers = map[string]*userManager{}
+		r.userManagers[user] = managers
+	}
+
+	anyUpdated := false
+	for key, f := range federations {
+		updated, err := r.syncFederationRulesToManager(withTenantEvaluations(ctx, evaluations), user, key, f, federationGroups[key])
 		if err != nil {
 			r.lastReloadSuccessful.WithLabelValues(user).Set(0)
-			level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
 			return
 		}
+		anyUpdated = anyUpdated || updated
+	}
+
+	// Stop the managers of the federations not used anymore.
+	for key, mngr := range managers {
+		if _, ok := federations[key]; !ok {
+			r.stopManager(user, key, mngr)
+			delete(managers, key)
+			anyUpdated = true
+		}
+	}
 
+	if anyUpdated {
 		r.lastReloadSuccessful.WithLabelValues(user).Set(1)
 		r.lastReloadSuccessfulTimestamp.WithLabelValues(user).SetToCurrentTime()
 	}
 }
 
+// syncFederationRulesToManager creates/updates the manager evaluating the rule groups of the
+// user with the input federation, and returns whether the manager has been updated.
+func (r *DefaultMultiTenantManager) syncFederationRulesToManager(ctx context.Context, user, key string, f ruleGroupFederation, groups store.RuleGroupList) (bool, error) {
+	// Map the files to disk and return the file names to be passed to the users manager if they
+	// have been updated
+	update, files, err := r.mapper.MapRules(federationRulesDir(user, key), groups.Formatted())
+	if err != nil {
+		level.Error(r.logger).Log("msg", "unable to map rule files", "user", user, "err", err)
+		return false, err
+	}
+
+	manager, exists := r.userManagers[user][key]
+	if exists && !update {
+		return false, nil
+	}
+
+	level.Debug(r.logger).Log("msg", "updating rules", "user", user)
+	r.configUpdatesTotal.WithLabelValues(user).Inc()
+	if !exists {
+		level.Debug(r.logger).Log("msg", "creating rule manager for user", "user", user)
+		manager, err = r.newManager(withRuleGroupFederation(ctx, f), user, key)
+		if err != nil {
+			level.Error(r.logger).Log("msg", "unable to create rule manager", "user", user, "err", err)
+			return false, err
+		}
+		// manager.Run() starts running the manager and blocks until Stop() is called.
+		// Hence run it as another goroutine.
+		go manager.Run()
+		r.userManagers[user][key] = manager
+	}
+	if err := manager.Update(r.cfg.EvaluationInterval, files, nil); err != nil {
+		level.Error(r.logger).Log("msg", "unable to update rule manager", "user", user, "err", err)
+		return false, err
+	}
+	return true, nil
+}
+
+// stopManager stops the manager of the user with the input federation key, and
+// removes its metrics and rule files.
+func (r *DefaultMultiTenantManager) stopManager(user, key string, mngr *userManager) {
+	go mngr.Stop()
+	mngr.registerer.unregisterAll()
+
+	if key != "" {
+		dir := filepath.Join(r.mapper.Path, federationRulesDir(user, key))
+		if err := r.mapper.FS.RemoveAll(dir); err != nil {
+			level.Warn(r.logger).Log("msg", "unable to remove federated rule groups directory", "path", dir, "err", err)
+		}
+	}
+}
+
+// federationRulesDir returns the directory, relative to the rule path, the rule files of
+// the user with the input federation key are mapped to. The separator can't be part of
+// a tenant ID, so the directories can't conflict with the ones of other tenants.
+func federationRulesDir(user, key string) string {
+	if key == "" {
+		return user
+	}
+	return user + "+" + key
+}
+
+// removeFederatedRuleGroups removes the rule groups having source or destination
+// tenants, which can't be evaluated when the ruler tenant federation is disabled.
+func (r *DefaultMultiTenantManager) removeFederatedRuleGroups(user string, groups store.RuleGroupList) store.RuleGroupList {
+	filtered := make(store.RuleGroupList, 0, len(groups))
+	for _, g := range groups {
+		if len(g.SourceTenants) > 0 || g.DestinationTenant != "" {
+			level.Warn(r.logger).Log("msg", "skipping federated rule group because the ruler tenant federation is disabled", "user", user, "namespace", g.Namespace, "group", g.Name)
+			continue
+		}
+		filtered = append(filtered, g)
+	}
+	return filtered
+}
+
 // newManager creates a prometheus rule manager wrapped with a user id
 // configured storage, appendable, notifier, and instrumentation
-func (r *DefaultMultiTenantManager) newManager(ctx context.Context, userID string) (RulesManager, error) {
+func (r *DefaultMultiTenantManager) newManager(ctx context.Context, userID, key string) (*userManager, error) {
 	notifier, err := r.getOrCreateNotifier(userID)
 	if err != nil {
 		return nil, err
 	}
 
 	// Create a new Prometheus registry and register it within
-	// our metrics struct for the provided user.
-	reg := prometheus.NewRegistry()
-	r.userManagerMetrics.AddUserRegistry(userID, reg)
+	// our metrics struct for the provided user, shared by the managers of the user.
+	reg, ok := r.userRegistries[userID]
+	if !ok {
+		reg = prometheus.NewRegistry()
+		r.userRegistries[userID] = reg
+		r.userManagerMetrics.AddUserRegistry(userID, reg)
+	}
+
+	// The metrics of the managers are distinguished by the federation key, and
+	// are unregistered once the manager is stopped.
+	registerer := &managerRegisterer{Registerer: prometheus.WrapRegistererWith(prometheus.Labels{"federation": key}, reg)}
 
 	logger := log.With(r.logger, "user", userID)
-	return r.managerFactory(ctx, userID, notifier, logger, reg), nil
+	return &userManager{
+		RulesManager: r.managerFactory(ctx, userID, notifier, logger, registerer),
+		registerer:   registerer,
+	}, nil
 }
 
 func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifier.Manager, error) {
@@ -218,12 +337,21 @@ func (r *DefaultMultiTenantManager) getOrCreateNotifier(userID string) (*notifie
 }
 
 func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
-	var groups []*promRules.Group
 	r.userManagerMtx.Lock()
-	if mngr, exists := r.userManagers[userID]; exists {
-		groups = mngr.RuleGroups()
+	defer r.userManagerMtx.Unlock()
+
+	// Return the rule groups in a stable order across the managers of the user.
+	managers := r.userManagers[userID]
+	keys := make([]string, 0, len(managers))
+	for key := range managers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var groups []*promRules.Group
+	for _, key := range keys {
+		groups = append(groups, managers[key].RuleGroups()...)
 	}
-	r.userManagerMtx.Unlock()
 	return groups
 }
 
@@ -237,14 +365,16 @@ func (r *DefaultMultiTenantManager) Stop() {
 	level.Info(r.logger).Log("msg", "stopping user managers")
 	wg := sync.WaitGroup{}
 	r.userManagerMtx.Lock()
-	for user, manager := range r.userManagers {
-		level.Debug(r.logger).Log("msg", "shutting down user  manager", "user", user)
-		wg.Add(1)
-		go func(manager RulesManager, user string) {
-			manager.Stop()
-			wg.Done()
-			level.Debug(r.logger).Log("msg", "user manager shut down", "user", user)
-		}(manager, user)
+	for user, managers := range r.userManagers {
+		for _, manager := range managers {
+			level.Debug(r.logger).Log("msg", "shutting down user  manager", "user", user)
+			wg.Add(1)
+			go func(manager RulesManager, user string) {
+				manager.Stop()
+				wg.Done()
+				level.Debug(r.logger).Log("msg", "user manager shut down", "user", user)
+			}(manager, user)
+		}
 	}
 	wg.Wait()
 	r.userManagerMtx.Unlock()
@@ -286,3 +416,48 @@ func (*DefaultMultiTenantManager) ValidateRuleGroup(g rulefmt.RuleGroup) []error
 
 	return errs
 }
+
+// userManager is a rules manager evaluating rule groups of a user.
+type userManager struct {
+	RulesManager
+
+	registerer *managerRegisterer
+}
+
+// managerRegisterer registers the metrics of a rules manager, keeping track of
+// them in order to unregister them once the manager is stopped.
+type managerRegisterer struct {
+	prometheus.Registerer
+
+	mtx        sync.Mutex
+	collectors []prometheus.Collector
+}
+
+func (r *managerRegisterer) Register(c prometheus.Collector) error {
+	if err := r.Registerer.Register(c); err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.collectors = append(r.collectors, c)
+	return nil
+}
+
+func (r *managerRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+func (r *managerRegisterer) unregisterAll() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	for _, c := range r.collectors {
+		r.Registerer.Unregister(c)
+	}
+	r.collectors = nil
+}
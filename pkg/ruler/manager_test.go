@@ -4,14 +4,17 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/pkg/labels"
 	promRules "github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/atomic"
 
@@ -77,11 +80,104 @@ func TestSyncRuleGroups(t *testing.T) {
 	})
 }
 
+func TestSyncRuleGroups_FederatedRuleGroups(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	// Keep track of the federation carried by the context of each manager.
+	var federations []ruleGroupFederation
+	factory := func(ctx context.Context, _ string, _ *notifier.Manager, _ log.Logger, _ prometheus.Registerer) RulesManager {
+		federations = append(federations, ruleGroupFederationFromContext(ctx))
+		return &mockRulesManager{done: make(chan struct{})}
+	}
+
+	cfg := Config{RulePath: dir}
+	cfg.TenantFederation.Enabled = true
+	m, err := NewDefaultMultiTenantManager(cfg, factory, prometheus.NewPedanticRegistry(), log.NewNopLogger())
+	require.NoError(t, err)
+	defer m.Stop()
+
+	const user = "testUser"
+
+	m.SyncRuleGroups(context.Background(), map[string]rules.RuleGroupList{
+		user: {
+			{Name: "local", Namespace: "ns", User: user},
+			{Name: "federated-1", Namespace: "ns", User: user, SourceTenants: []string{"tenant-a", "tenant-b"}},
+			{Name: "federated-2", Namespace: "ns", User: user, SourceTenants: []string{"tenant-b", "tenant-a"}},
+			{Name: "aggregations", Namespace: "ns", User: user, SourceTenants: []string{"tenant-a", "tenant-b"}, DestinationTenant: "aggregations"},
+		},
+	})
+
+	// The rule groups sharing the same federation are evaluated by the same manager.
+	assert.ElementsMatch(t, []ruleGroupFederation{
+		{},
+		{sourceTenants: []string{"tenant-a", "tenant-b"}},
+		{sourceTenants: []string{"tenant-a", "tenant-b"}, destinationTenant: "aggregations"},
+	}, federations)
+	assert.Len(t, m.userManagers[user], 3)
+
+	aggregationsKey := newRuleGroupFederation(&rules.RuleGroupDesc{SourceTenants: []string{"tenant-a", "tenant-b"}, DestinationTenant: "aggregations"}).key()
+	assert.DirExists(t, filepath.Join(dir, federationRulesDir(user, aggregationsKey)))
+
+	// The managers of the federations not used anymore are stopped.
+	federated := m.userManagers[user][aggregationsKey]
+	require.NotNil(t, federated)
+
+	m.SyncRuleGroups(context.Background(), map[string]rules.RuleGroupList{
+		user: {
+			{Name: "local", Namespace: "ns", User: user},
+			{Name: "federated-1", Namespace: "ns", User: user, SourceTenants: []string{"tenant-a", "tenant-b"}},
+		},
+	})
+
+	assert.Len(t, m.userManagers[user], 2)
+	assert.NoDirExists(t, filepath.Join(dir, federationRulesDir(user, aggregationsKey)))
+	test.Poll(t, time.Second, true, func() interface{} {
+		return federated.RulesManager.(*mockRulesManager).stopped.Load()
+	})
+}
+
+func TestSyncRuleGroups_FederatedRuleGroupsMetrics(t *testing.T) {
+	cfg, cleanup := defaultRulerConfig(newMockRuleStore(nil))
+	defer cleanup()
+	cfg.TenantFederation.Enabled = true
+
+	m, mcleanup := newManager(t, cfg)
+	defer mcleanup()
+	defer m.Stop()
+
+	const user = "testUser"
+
+	groups := rules.RuleGroupList{
+		{Name: "local", Namespace: "ns", User: user, Rules: []*rules.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+		{Name: "federated", Namespace: "ns", User: user, SourceTenants: []string{"tenant-a"}, Rules: []*rules.RuleDesc{{Record: "up:sum", Expr: "sum(up)"}}},
+	}
+
+	// The metrics of the manager of a federation are unregistered once it's stopped,
+	// so that it can be created again.
+	m.SyncRuleGroups(context.Background(), map[string]rules.RuleGroupList{user: groups})
+	m.SyncRuleGroups(context.Background(), map[string]rules.RuleGroupList{user: groups[:1]})
+	m.SyncRuleGroups(context.Background(), map[string]rules.RuleGroupList{user: groups})
+
+	assert.Len(t, m.GetRules(user), 2)
+	assert.Equal(t, float64(1), testutil.ToFloat64(m.lastReloadSuccessful.WithLabelValues(user)))
+
+	families, err := m.userRegistries[user].Gather()
+	require.NoError(t, err)
+	assert.NotEmpty(t, families)
+}
+
 func getManager(m *DefaultMultiTenantManager, user string) RulesManager {
 	m.userManagerMtx.Lock()
 	defer m.userManagerMtx.Unlock()
 
-	return m.userManagers[user]
+	if mngr, ok := m.userManagers[user][""]; ok {
+		return mngr.RulesManager
+	}
+	return nil
 }
 
 func factory(_ context.Context, _ string, _ *notifier.Manager, _ log.Logger, _ prometheus.Registerer) RulesManager {
@@ -90,6 +186,7 @@ func factory(_ context.Context, _ string, _ *notifier.Manager, _ log.Logger, _ p
 
 type mockRulesManager struct {
 	running atomic.Bool
+	stopped atomic.Bool
 	done    chan struct{}
 }
 
@@ -100,6 +197,7 @@ func (m *mockRulesManager) Run() {
 
 func (m *mockRulesManager) Stop() {
 	m.running.Store(false)
+	m.stopped.Store(true)
 	close(m.done)
 }
 
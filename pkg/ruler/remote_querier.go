@@ -0,0 +1,244 @@
+package ruler
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	otgrpc "github.com/opentracing-contrib/go-grpc"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/grpcclient"
+)
+
+var errNegativeQueryFrontendMaxRetries = errors.New("the query-frontend max retries can't be negative")
+
+// QueryFrontendConfig configures the remote evaluation of the rules, sending
+// their queries to the query-frontend instead of the embedded querier.
+type QueryFrontendConfig struct {
+	Address          string                   `yaml:"address"`
+	Timeout          time.Duration            `yaml:"timeout"`
+	MaxRetries       int                      `yaml:"max_retries"`
+	GRPCClientConfig grpcclient.ConfigWithTLS `yaml:"grpc_client_config"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *QueryFrontendConfig) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.Address, "ruler.query-frontend.address", "", "GRPC listen address of the query-frontend(s), in the form host:port. If set, the rule queries are evaluated remotely by the query-frontend instead of the querier embedded in the ruler (experimental).")
+	f.DurationVar(&cfg.Timeout, "ruler.query-frontend.timeout", 2*time.Minute, "Timeout of each rule query sent to the query-frontend, including the retries.")
+	f.IntVar(&cfg.MaxRetries, "ruler.query-frontend.max-retries", 3, "Maximum number of times a rule query sent to the query-frontend is retried, when failed with a server or network error.")
+	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("ruler.query-frontend.grpc-client-config", f)
+}
+
+// Validate the config.
+func (cfg *QueryFrontendConfig) Validate(log log.Logger) error {
+	if cfg.Address == "" {
+		return nil
+	}
+	if cfg.MaxRetries < 0 {
+		return errNegativeQueryFrontendMaxRetries
+	}
+	return cfg.GRPCClientConfig.Validate(log)
+}
+
+// DialQueryFrontend creates a client of the query-frontend, sending HTTP
+// requests over the gRPC connection.
+func DialQueryFrontend(cfg QueryFrontendConfig) (httpgrpc.HTTPClient, error) {
+	opts, err := cfg.GRPCClientConfig.DialOption([]grpc.UnaryClientInterceptor{
+		otgrpc.OpenTracingClientInterceptor(opentracing.GlobalTracer()),
+		middleware.ClientUserHeaderInterceptor,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.Dial(cfg.Address, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to dial the query-frontend %s", cfg.Address)
+	}
+	return httpgrpc.NewHTTPClient(conn), nil
+}
+
+// RemoteQuerier evaluates the rule queries remotely, through the Prometheus
+// instant query API of the query-frontend.
+type RemoteQuerier struct {
+	client         httpgrpc.HTTPClient
+	cfg            QueryFrontendConfig
+	promHTTPPrefix string
+	logger         log.Logger
+
+	requestDuration *prometheus.HistogramVec
+	retries         prometheus.Counter
+}
+
+// NewRemoteQuerier returns a RemoteQuerier sending the queries to the query-frontend
+// via the input client. The Prometheus API is expected under the input prefix.
+func NewRemoteQuerier(client httpgrpc.HTTPClient, cfg QueryFrontendConfig, promHTTPPrefix string, logger log.Logger, reg prometheus.Registerer) *RemoteQuerier {
+	return &RemoteQuerier{
+		client:         client,
+		cfg:            cfg,
+		promHTTPPrefix: promHTTPPrefix,
+		logger:         logger,
+
+		requestDuration: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_ruler_query_frontend_request_duration_seconds",
+			Help:    "Time spent by the ruler doing requests to the query-frontend.",
+			Buckets: prometheus.ExponentialBuckets(0.005, 4, 7),
+		}, []string{"status_code"}),
+		retries: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_frontend_retries_total",
+			Help: "Total number of rule queries retried by the ruler after failing on the query-frontend.",
+		}),
+	}
+}
+
+// Query implements rules.QueryFunc, running an instant query at the input time.
+func (q *RemoteQuerier) Query(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+	// The org ID may hold multiple tenants in case of federated rule groups.
+	orgID, err := user.ExtractOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, q.cfg.Timeout)
+	defer cancel()
+
+	body := []byte(url.Values{
+		"query": []string{qs},
+		"time":  []string{strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', -1, 64)},
+	}.Encode())
+
+	req := &httpgrpc.HTTPRequest{
+		Method: http.MethodPost,
+		Url:    q.promHTTPPrefix + "/api/v1/query",
+		Body:   body,
+		Headers: []*httpgrpc.Header{
+			{Key: "Content-Type", Values: []string{"application/x-www-form-urlencoded"}},
+			{Key: "Content-Length", Values: []string{strconv.Itoa(len(body))}},
+			{Key: "Accept", Values: []string{"application/json"}},
+			{Key: user.OrgIDHeaderName, Values: []string{orgID}},
+		},
+	}
+
+	resp, err := q.sendWithRetries(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeQueryResponse(resp)
+}
+
+// sendWithRetries sends the request, retrying it on the server and network errors.
+// The query-frontend returns the 5xx responses as errors.
+func (q *RemoteQuerier) sendWithRetries(ctx context.Context, req *httpgrpc.HTTPRequest) (*httpgrpc.HTTPResponse, error) {
+	backoff := util.NewBackoff(ctx, util.BackoffConfig{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: 2 * time.Second,
+		MaxRetries: q.cfg.MaxRetries + 1,
+	})
+
+	var lastErr error
+	for backoff.Ongoing() {
+		if lastErr != nil {
+			q.retries.Inc()
+		}
+
+		start := time.Now()
+		resp, err := q.client.Handle(ctx, req)
+		q.requestDuration.WithLabelValues(statusCode(resp, err)).Observe(time.Since(start).Seconds())
+		if err == nil {
+			return resp, nil
+		}
+
+		level.Warn(q.logger).Log("msg", "failed to run the rule query on the query-frontend", "err", err)
+		lastErr = err
+		backoff.Wait()
+	}
+
+	if lastErr == nil {
+		lastErr = backoff.Err()
+	}
+	return nil, errors.Wrap(lastErr, "failed to run the rule query on the query-frontend")
+}
+
+func statusCode(resp *httpgrpc.HTTPResponse, err error) string {
+	if err == nil {
+		return strconv.Itoa(int(resp.Code))
+	}
+	if errResp, ok := httpgrpc.HTTPResponseFromError(err); ok {
+		return strconv.Itoa(int(errResp.Code))
+	}
+	return "error"
+}
+
+// decodeQueryResponse decodes the JSON response of the instant query API. Like
+// the query engine, only the vector and scalar results are allowed.
+func decodeQueryResponse(resp *httpgrpc.HTTPResponse) (promql.Vector, error) {
+	var apiResp struct {
+		Status string `json:"status"`
+		Data   struct {
+			ResultType model.ValueType `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+		ErrorType string `json:"errorType"`
+		Error     string `json:"error"`
+	}
+
+	if err := json.Unmarshal(resp.Body, &apiResp); err != nil {
+		if resp.Code/100 != 2 {
+			return nil, fmt.Errorf("the query-frontend returned status code %d: %s", resp.Code, resp.Body)
+		}
+		return nil, errors.Wrap(err, "failed to decode the response of the query-frontend")
+	}
+	if apiResp.Status != "success" {
+		return nil, fmt.Errorf("the rule query failed with %s error: %s", apiResp.ErrorType, apiResp.Error)
+	}
+
+	switch apiResp.Data.ResultType {
+	case model.ValVector:
+		var vector model.Vector
+		if err := json.Unmarshal(apiResp.Data.Result, &vector); err != nil {
+			return nil, errors.Wrap(err, "failed to decode the vector result of the query-frontend")
+		}
+
+		result := make(promql.Vector, 0, len(vector))
+		for _, s := range vector {
+			result = append(result, promql.Sample{
+				Metric: client.FromLabelAdaptersToLabels(client.FromMetricsToLabelAdapters(s.Metric)),
+				Point:  promql.Point{T: int64(s.Timestamp), V: float64(s.Value)},
+			})
+		}
+		return result, nil
+
+	case model.ValScalar:
+		var scalar model.Scalar
+		if err := json.Unmarshal(apiResp.Data.Result, &scalar); err != nil {
+			return nil, errors.Wrap(err, "failed to decode the scalar result of the query-frontend")
+		}
+
+		return promql.Vector{promql.Sample{
+			Metric: labels.Labels{},
+			Point:  promql.Point{T: int64(scalar.Timestamp), V: float64(scalar.Value)},
+		}}, nil
+	}
+
+	return nil, fmt.Errorf("rule result is not a vector or scalar: %s", apiResp.Data.ResultType)
+}
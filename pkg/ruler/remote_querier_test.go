@@ -0,0 +1,146 @@
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/grpc"
+)
+
+type mockHTTPGRPCClient func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error)
+
+func (c mockHTTPGRPCClient) Handle(ctx context.Context, req *httpgrpc.HTTPRequest, opts ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+	return c(ctx, req, opts...)
+}
+
+func TestRemoteQuerier_Query(t *testing.T) {
+	now := time.Unix(1600000000, 500*int64(time.Millisecond))
+
+	tests := map[string]struct {
+		responses        []*httpgrpc.HTTPResponse
+		errors           []error
+		expected         promql.Vector
+		expectedErr      string
+		expectedRequests int
+	}{
+		"vector result": {
+			responses: []*httpgrpc.HTTPResponse{
+				{Code: 200, Body: []byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"job":"a","__name__":"up"},"value":[1600000000.5,"1"]}]}}`)},
+			},
+			expected: promql.Vector{
+				{Metric: labels.FromStrings("__name__", "up", "job", "a"), Point: promql.Point{T: 1600000000500, V: 1}},
+			},
+			expectedRequests: 1,
+		},
+		"scalar result": {
+			responses: []*httpgrpc.HTTPResponse{
+				{Code: 200, Body: []byte(`{"status":"success","data":{"resultType":"scalar","result":[1600000000.5,"2"]}}`)},
+			},
+			expected: promql.Vector{
+				{Metric: labels.Labels{}, Point: promql.Point{T: 1600000000500, V: 2}},
+			},
+			expectedRequests: 1,
+		},
+		"matrix result": {
+			responses: []*httpgrpc.HTTPResponse{
+				{Code: 200, Body: []byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`)},
+			},
+			expectedErr:      "rule result is not a vector or scalar: matrix",
+			expectedRequests: 1,
+		},
+		"client error is not retried": {
+			responses: []*httpgrpc.HTTPResponse{
+				{Code: 400, Body: []byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`)},
+			},
+			expectedErr:      "the rule query failed with bad_data error: parse error",
+			expectedRequests: 1,
+		},
+		"server error is retried": {
+			responses: []*httpgrpc.HTTPResponse{
+				nil,
+				{Code: 200, Body: []byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`)},
+			},
+			errors: []error{
+				httpgrpc.Errorf(http.StatusInternalServerError, "internal error"),
+				nil,
+			},
+			expected:         promql.Vector{},
+			expectedRequests: 2,
+		},
+		"server error retried until the max retries": {
+			errors: []error{
+				httpgrpc.Errorf(http.StatusInternalServerError, "internal error"),
+				httpgrpc.Errorf(http.StatusServiceUnavailable, "unavailable"),
+				httpgrpc.Errorf(http.StatusBadGateway, "bad gateway"),
+			},
+			expectedErr:      "failed to run the rule query on the query-frontend: rpc error: code = Code(502) desc = bad gateway",
+			expectedRequests: 3,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			requests := 0
+			client := mockHTTPGRPCClient(func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error) {
+				assert.Equal(t, http.MethodPost, req.Method)
+				assert.Equal(t, "/prometheus/api/v1/query", req.Url)
+
+				headers := http.Header{}
+				for _, h := range req.Headers {
+					for _, v := range h.Values {
+						headers.Add(h.Key, v)
+					}
+				}
+				assert.Equal(t, "user-1|user-2", headers.Get(user.OrgIDHeaderName))
+
+				values, err := url.ParseQuery(string(req.Body))
+				require.NoError(t, err)
+				assert.Equal(t, "up", values.Get("query"))
+				assert.Equal(t, "1600000000.5", values.Get("time"))
+
+				_, hasDeadline := ctx.Deadline()
+				assert.True(t, hasDeadline)
+
+				defer func() { requests++ }()
+				var (
+					resp    *httpgrpc.HTTPResponse
+					respErr error
+				)
+				if requests < len(testData.responses) {
+					resp = testData.responses[requests]
+				}
+				if requests < len(testData.errors) {
+					respErr = testData.errors[requests]
+				}
+				return resp, respErr
+			})
+
+			reg := prometheus.NewPedanticRegistry()
+			q := NewRemoteQuerier(client, QueryFrontendConfig{Timeout: time.Minute, MaxRetries: 2}, "/prometheus", log.NewNopLogger(), reg)
+
+			ctx := user.InjectOrgID(context.Background(), "user-1|user-2")
+			vector, err := q.Query(ctx, "up", now)
+			if testData.expectedErr != "" {
+				require.EqualError(t, err, testData.expectedErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, testData.expected, vector)
+			}
+
+			assert.Equal(t, testData.expectedRequests, requests)
+			assert.Equal(t, float64(testData.expectedRequests-1), testutil.ToFloat64(q.retries))
+		})
+	}
+}
@@ -57,6 +57,14 @@ const (
 	// Limit errors
 	errMaxRuleGroupsPerUserLimitExceeded        = "per-user rule groups limit (limit: %d actual: %d) exceeded"
 	errMaxRulesPerRuleGroupPerUserLimitExceeded = "per-user rules per rule group limit (limit: %d actual: %d) exceeded"
+
+	// Federated rule groups errors
+	errFederatedRuleGroupsDisabled = "rule groups with source tenants are not allowed because the ruler tenant federation is disabled"
+	errInvalidSourceTenant         = "invalid source tenant %q: %s"
+	errSourceTenantNotAllowed      = "source tenant %q is not allowed: it must be listed in the ruler_allowed_source_tenants limit of the tenant"
+	errInvalidDestinationTenant    = "invalid destination tenant %q: %s"
+	errDestinationTenantNotAllowed = "destination tenant %q is not allowed: it must be listed in the ruler_allowed_destination_tenants limit of the tenant"
+	errDestinationTenantAlerts     = "rule groups with a destination tenant can only contain recording rules"
 )
 
 // Config is the configuration for the recording rules server.
@@ -106,9 +114,25 @@ type Config struct {
 
 	EnableAPI bool `yaml:"enable_api"`
 
+	TenantFederation TenantFederationConfig `yaml:"tenant_federation"`
+
+	// Remote evaluation of the rule queries via the query-frontend.
+	QueryFrontend QueryFrontendConfig `yaml:"query_frontend"`
+
 	RingCheckPeriod time.Duration `yaml:"-"`
 }
 
+// TenantFederationConfig configures the federated rule groups, whose rules are
+// evaluated querying the series of multiple source tenants.
+type TenantFederationConfig struct {
+	Enabled bool `yaml:"enabled"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet
+func (cfg *TenantFederationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "ruler.tenant-federation.enabled", false, "Enable running rule groups against multiple tenants. The tenant IDs involved need to be in the rule group's 'source_tenants' field, and the tenant the resulting series are written to can be set via the 'destination_tenant' field. If this flag is set to 'false' when there are already created federated rule groups, then these rule groups will be skipped during evaluations. Requires -tenant-federation.enabled (experimental).")
+}
+
 // Validate config and returns error on failure
 func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 	if !util.StringsContain(supportedShardingStrategies, cfg.ShardingStrategy) {
@@ -125,6 +149,9 @@ func (cfg *Config) Validate(limits validation.Limits, log log.Logger) error {
 	if err := cfg.ClientTLSConfig.Validate(log); err != nil {
 		return errors.Wrap(err, "invalid ruler gRPC client config")
 	}
+	if err := cfg.QueryFrontend.Validate(log); err != nil {
+		return errors.Wrap(err, "invalid ruler query-frontend config")
+	}
 	return nil
 }
 
@@ -133,6 +160,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	cfg.ClientTLSConfig.RegisterFlagsWithPrefix("ruler.client", f)
 	cfg.StoreConfig.RegisterFlags(f)
 	cfg.Ring.RegisterFlags(f)
+	cfg.TenantFederation.RegisterFlags(f)
+	cfg.QueryFrontend.RegisterFlags(f)
 
 	// Deprecated Flags that will be maintained to avoid user disruption
 	flagext.DeprecatedFlag(f, "ruler.client-timeout", "This flag has been renamed to ruler.configs.client-timeout")
@@ -276,7 +305,9 @@ func enableSharding(r *Ruler, ringStore kv.Client) error {
 	// chained via "next delegate").
 	delegate := ring.BasicLifecyclerDelegate(r)
 	delegate = ring.NewLeaveOnStoppingDelegate(delegate, r.logger)
-	delegate = ring.NewAutoForgetDelegate(r.cfg.Ring.HeartbeatTimeout*ringAutoForgetUnhealthyPeriods, delegate, r.logger)
+	if r.cfg.Ring.AutoForgetUnhealthyPeriods > 0 {
+		delegate = ring.NewAutoForgetDelegate(r.cfg.Ring.HeartbeatTimeout*time.Duration(r.cfg.Ring.AutoForgetUnhealthyPeriods), delegate, r.logger)
+	}
 
 	rulerRingName := "ruler"
 	r.lifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, rulerRingName, ring.RulerRingKey, ringStore, delegate, r.logger, r.registry)
@@ -604,13 +635,12 @@ func (r *Ruler) getLocalRules(userID string) ([]*GroupStateDesc, error) {
 	groups := r.manager.GetRules(userID)
 
 	groupDescs := make([]*GroupStateDesc, 0, len(groups))
-	prefix := filepath.Join(r.cfg.RulePath, userID) + "/"
 
 	for _, group := range groups {
 		interval := group.Interval()
 
 		// The mapped filename is url path escaped encoded to make handling `/` characters easier
-		decodedNamespace, err := url.PathUnescape(strings.TrimPrefix(group.File(), prefix))
+		decodedNamespace, err := url.PathUnescape(filepath.Base(group.File()))
 		if err != nil {
 			return nil, errors.Wrap(err, "unable to decode rule filename")
 		}
@@ -771,3 +801,51 @@ func (r *Ruler) AssertMaxRulesPerRuleGroup(userID string, rules int) error {
 	}
 	return fmt.Errorf(errMaxRulesPerRuleGroupPerUserLimitExceeded, limit, rules)
 }
+
+// AssertSourceTenants validates the source tenants of a rule group of the user
+// and returns an error if the rule group can't be federated across them.
+func (r *Ruler) AssertSourceTenants(userID string, sourceTenants []string) error {
+	if len(sourceTenants) == 0 {
+		return nil
+	}
+
+	if !r.cfg.TenantFederation.Enabled {
+		return errors.New(errFederatedRuleGroupsDisabled)
+	}
+
+	for _, sourceTenant := range sourceTenants {
+		if err := tenant.ValidTenantID(sourceTenant); err != nil {
+			return fmt.Errorf(errInvalidSourceTenant, sourceTenant, err)
+		}
+	}
+	return checkSourceTenantsAllowed(r.limits, userID, sourceTenants)
+}
+
+// AssertDestinationTenant validates the destination tenant of a rule group of the
+// user and returns an error if the series of the rule group can't be written to it.
+// Only the recording rules are allowed, because the "for" state of the alerts
+// is restored from the owner of the rule group.
+func (r *Ruler) AssertDestinationTenant(userID, destinationTenant string, rules []rulefmt.RuleNode) error {
+	if destinationTenant == "" {
+		return nil
+	}
+
+	if !r.cfg.TenantFederation.Enabled {
+		return errors.New(errFederatedRuleGroupsDisabled)
+	}
+
+	if err := tenant.ValidTenantID(destinationTenant); err != nil {
+		return fmt.Errorf(errInvalidDestinationTenant, destinationTenant, err)
+	}
+
+	if err := checkDestinationTenantAllowed(r.limits, userID, destinationTenant); err != nil {
+		return err
+	}
+
+	for _, rule := range rules {
+		if rule.Alert.Value != "" {
+			return errors.New(errDestinationTenantAlerts)
+		}
+	}
+	return nil
+}
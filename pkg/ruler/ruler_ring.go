@@ -37,6 +37,8 @@ type RingConfig struct {
 	InstanceAddr           string   `yaml:"instance_addr" doc:"hidden"`
 	NumTokens              int      `yaml:"num_tokens"`
 
+	AutoForgetUnhealthyPeriods int `yaml:"auto_forget_unhealthy_periods"`
+
 	// Injected internally
 	ListenPort int `yaml:"-"`
 
@@ -64,6 +66,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.InstancePort, "ruler.ring.instance-port", 0, "Port to advertise in the ring (defaults to server.grpc-listen-port).")
 	f.StringVar(&cfg.InstanceID, "ruler.ring.instance-id", hostname, "Instance ID to register in the ring.")
 	f.IntVar(&cfg.NumTokens, "ruler.ring.num-tokens", 128, "Number of tokens for each ingester.")
+	f.IntVar(&cfg.AutoForgetUnhealthyPeriods, "ruler.ring.auto-forget-unhealthy-periods", ringAutoForgetUnhealthyPeriods, "Number of consecutive heartbeat timeouts after which an unhealthy ruler is automatically removed from the ring by the healthy rulers. 0 to disable.")
 }
 
 // ToLifecyclerConfig returns a LifecyclerConfig based on the ruler
@@ -20,7 +20,6 @@ import (
 	prom_testutil "github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/notifier"
 	"github.com/prometheus/prometheus/pkg/labels"
-	"github.com/prometheus/prometheus/promql"
 	promRules "github.com/prometheus/prometheus/rules"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/assert"
@@ -28,6 +27,7 @@ import (
 	"github.com/weaveworks/common/user"
 
 	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/querier"
 	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/ring/kv"
 	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
@@ -63,10 +63,15 @@ func defaultRulerConfig(store rules.RuleStore) (Config, func()) {
 }
 
 type ruleLimits struct {
-	evalDelay            time.Duration
-	tenantShard          int
-	maxRulesPerRuleGroup int
-	maxRuleGroups        int
+	evalDelay                  time.Duration
+	tenantShard                int
+	maxRulesPerRuleGroup       int
+	maxRuleGroups              int
+	maxConcurrentEvaluations   int
+	evaluationAlignmentEnabled bool
+	evaluationJitter           time.Duration
+	allowedSourceTenants       []string
+	allowedDestinationTenants  []string
 }
 
 func (r ruleLimits) EvaluationDelay(_ string) time.Duration {
@@ -85,20 +90,39 @@ func (r ruleLimits) RulerMaxRulesPerRuleGroup(_ string) int {
 	return r.maxRulesPerRuleGroup
 }
 
-func testSetup(t *testing.T, cfg Config) (*promql.Engine, storage.QueryableFunc, Pusher, log.Logger, RulesLimits, func()) {
+func (r ruleLimits) RulerMaxConcurrentRuleGroupEvaluations(_ string) int {
+	return r.maxConcurrentEvaluations
+}
+
+func (r ruleLimits) RulerEvaluationAlignmentEnabled(_ string) bool {
+	return r.evaluationAlignmentEnabled
+}
+
+func (r ruleLimits) RulerEvaluationJitter(_ string) time.Duration {
+	return r.evaluationJitter
+}
+
+func (r ruleLimits) RulerAllowedSourceTenants(_ string) []string {
+	return r.allowedSourceTenants
+}
+
+func (r ruleLimits) RulerAllowedDestinationTenants(_ string) []string {
+	return r.allowedDestinationTenants
+}
+
+func testSetup(t *testing.T, cfg Config) (*querier.Engines, storage.QueryableFunc, Pusher, log.Logger, RulesLimits, func()) {
 	dir, err := ioutil.TempDir("", filepath.Base(t.Name()))
 	assert.NoError(t, err)
 	cleanup := func() {
 		os.RemoveAll(dir)
 	}
 
-	tracker := promql.NewActiveQueryTracker(dir, 20, util.Logger)
-
-	engine := promql.NewEngine(promql.EngineOpts{
-		MaxSamples:         1e6,
-		ActiveQueryTracker: tracker,
-		Timeout:            2 * time.Minute,
-	})
+	engines := querier.NewEngines(querier.Config{
+		ActiveQueryTrackerDir: dir,
+		MaxConcurrent:         20,
+		MaxSamples:            1e6,
+		Timeout:               2 * time.Minute,
+	}, nil, nil)
 
 	noopQueryable := storage.QueryableFunc(func(ctx context.Context, mint, maxt int64) (storage.Querier, error) {
 		return storage.NoopQuerier(), nil
@@ -111,24 +135,24 @@ func testSetup(t *testing.T, cfg Config) (*promql.Engine, storage.QueryableFunc,
 	l := log.NewLogfmtLogger(os.Stdout)
 	l = level.NewFilter(l, level.AllowInfo())
 
-	return engine, noopQueryable, pusher, l, ruleLimits{evalDelay: 0, maxRuleGroups: 20, maxRulesPerRuleGroup: 15}, cleanup
+	return engines, noopQueryable, pusher, l, ruleLimits{evalDelay: 0, maxRuleGroups: 20, maxRulesPerRuleGroup: 15, allowedSourceTenants: []string{"tenant-a", "tenant-b"}, allowedDestinationTenants: []string{"aggregations"}}, cleanup
 }
 
 func newManager(t *testing.T, cfg Config) (*DefaultMultiTenantManager, func()) {
-	engine, noopQueryable, pusher, logger, overrides, cleanup := testSetup(t, cfg)
-	manager, err := NewDefaultMultiTenantManager(cfg, DefaultTenantManagerFactory(cfg, pusher, noopQueryable, engine, overrides), prometheus.NewRegistry(), logger)
+	engines, noopQueryable, pusher, logger, overrides, cleanup := testSetup(t, cfg)
+	manager, err := NewDefaultMultiTenantManager(cfg, DefaultTenantManagerFactory(cfg, pusher, noopQueryable, engines, overrides), prometheus.NewRegistry(), logger)
 	require.NoError(t, err)
 
 	return manager, cleanup
 }
 
 func newRuler(t *testing.T, cfg Config) (*Ruler, func()) {
-	engine, noopQueryable, pusher, logger, overrides, cleanup := testSetup(t, cfg)
-	storage, err := NewRuleStorage(cfg.StoreConfig, promRules.FileLoader{})
+	engines, noopQueryable, pusher, logger, overrides, cleanup := testSetup(t, cfg)
+	storage, err := NewRuleStorage(cfg.StoreConfig, promRules.FileLoader{}, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	reg := prometheus.NewRegistry()
-	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, engine, overrides)
+	managerFactory := DefaultTenantManagerFactory(cfg, pusher, noopQueryable, engines, overrides)
 	manager, err := NewDefaultMultiTenantManager(cfg, managerFactory, reg, util.Logger)
 	require.NoError(t, err)
 
@@ -0,0 +1,353 @@
+package bucketclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gogo/protobuf/proto"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rules"
+)
+
+// Bucket Rule Storage Schema
+// =======================
+// Object Name: "rules/<user_id>/<base64 URL Encoded: namespace>/<base64 URL Encoded: group_name>"
+// Storage Format: Encoded RuleGroupDesc
+//
+// The schema is the same used by the object client rule store, so that the rule groups
+// can be moved between the two stores by copying the objects.
+
+const (
+	delim      = "/"
+	rulePrefix = "rules" + delim
+)
+
+// BucketRuleStore is used to support the RuleStore interface against an object
+// storage bucket, configured like the blocks storage bucket.
+type BucketRuleStore struct {
+	bucket          objstore.Bucket
+	loadConcurrency int
+	logger          log.Logger
+
+	// Serializes the updates of the rule groups of each user, so that the conditional
+	// updates check the condition and update the rule group atomically. The updates
+	// done by other rulers sharing the storage are not serialized.
+	updatesLocks rules.UserLocks
+}
+
+// NewBucketRuleStore returns a new BucketRuleStore.
+func NewBucketRuleStore(bkt objstore.Bucket, loadConcurrency int, logger log.Logger) *BucketRuleStore {
+	return &BucketRuleStore{
+		bucket:          bkt,
+		loadConcurrency: loadConcurrency,
+		logger:          logger,
+	}
+}
+
+// getRuleGroup loads and returns a rule group. If existing rule group is supplied, it is Reset and reused. If nil, new RuleGroupDesc is allocated.
+func (b *BucketRuleStore) getRuleGroup(ctx context.Context, objectKey string, rg *rules.RuleGroupDesc) (*rules.RuleGroupDesc, error) {
+	reader, err := b.bucket.Get(ctx, objectKey)
+	if b.bucket.IsObjNotFoundErr(err) {
+		level.Debug(b.logger).Log("msg", "rule group does not exist", "name", objectKey)
+		return nil, rules.ErrGroupNotFound
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get rule group %s", objectKey)
+	}
+	defer func() { _ = reader.Close() }()
+
+	buf, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read rule group %s", objectKey)
+	}
+
+	if rg == nil {
+		rg = &rules.RuleGroupDesc{}
+	} else {
+		rg.Reset()
+	}
+
+	err = proto.Unmarshal(buf, rg)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal rule group %s", objectKey)
+	}
+
+	return rg, nil
+}
+
+// ListAllUsers implements rules.RuleStore.
+func (b *BucketRuleStore) ListAllUsers(ctx context.Context) ([]string, error) {
+	var users []string
+	err := b.bucket.Iter(ctx, rulePrefix, func(name string) error {
+		if user := strings.TrimSuffix(strings.TrimPrefix(name, rulePrefix), delim); user != "" {
+			users = append(users, user)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list users in rule store bucket")
+	}
+
+	return users, nil
+}
+
+// ListAllRuleGroups implements rules.RuleStore.
+func (b *BucketRuleStore) ListAllRuleGroups(ctx context.Context) (map[string]rules.RuleGroupList, error) {
+	users, err := b.ListAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]rules.RuleGroupList{}
+	for _, user := range users {
+		groups, err := b.ListRuleGroupsForUserAndNamespace(ctx, user, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(groups) > 0 {
+			result[user] = groups
+		}
+	}
+
+	return result, nil
+}
+
+// ListRuleGroupsForUserAndNamespace implements rules.RuleStore.
+func (b *BucketRuleStore) ListRuleGroupsForUserAndNamespace(ctx context.Context, userID string, namespace string) (rules.RuleGroupList, error) {
+	var namespaces []string
+	if namespace != "" {
+		namespaces = []string{generateRuleObjectKey(userID, namespace, "")}
+	} else {
+		err := b.bucket.Iter(ctx, generateRuleObjectKey(userID, "", ""), func(name string) error {
+			if strings.HasSuffix(name, delim) {
+				namespaces = append(namespaces, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list namespaces of user %s", userID)
+		}
+	}
+
+	var groups rules.RuleGroupList
+	for _, prefix := range namespaces {
+		err := b.bucket.Iter(ctx, prefix, func(key string) error {
+			user, namespace, group := decomposeRuleObjectKey(key)
+			if user == "" || namespace == "" || group == "" {
+				return nil
+			}
+
+			groups = append(groups, &rules.RuleGroupDesc{
+				User:      user,
+				Namespace: namespace,
+				Name:      group,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list rule groups of user %s", userID)
+		}
+	}
+
+	return groups, nil
+}
+
+// LoadRuleGroups implements rules.RuleStore.
+func (b *BucketRuleStore) LoadRuleGroups(ctx context.Context, groupsToLoad map[string]rules.RuleGroupList) error {
+	ch := make(chan *rules.RuleGroupDesc)
+
+	// Given we store one file per rule group. With this, we create a pool of workers that will
+	// download all rule groups in parallel. We limit the number of workers to avoid a
+	// particular user having too many rule groups rate limiting us with the object storage.
+	g, gCtx := errgroup.WithContext(ctx)
+	for i := 0; i < b.loadConcurrency; i++ {
+		g.Go(func() error {
+			for gr := range ch {
+				if gr == nil {
+					continue
+				}
+
+				user, namespace, group := gr.GetUser(), gr.GetNamespace(), gr.GetName()
+				if user == "" || namespace == "" || group == "" {
+					return fmt.Errorf("invalid rule group: user=%q, namespace=%q, group=%q", user, namespace, group)
+				}
+
+				key := generateRuleObjectKey(user, namespace, group)
+
+				level.Debug(b.logger).Log("msg", "loading rule group", "key", key, "user", user)
+				gr, err := b.getRuleGroup(gCtx, key, gr) // reuse group pointer from the map.
+				if err != nil {
+					level.Error(b.logger).Log("msg", "failed to get rule group", "key", key, "user", user)
+					return err
+				}
+
+				if user != gr.User || namespace != gr.Namespace || group != gr.Name {
+					return fmt.Errorf("mismatch between requested rule group and loaded rule group, requested: user=%q, namespace=%q, group=%q, loaded: user=%q, namespace=%q, group=%q", user, namespace, group, gr.User, gr.Namespace, gr.Name)
+				}
+			}
+
+			return nil
+		})
+	}
+
+outer:
+	for _, gs := range groupsToLoad {
+		for _, g := range gs {
+			select {
+			case <-gCtx.Done():
+				break outer
+			case ch <- g:
+				// ok
+			}
+		}
+	}
+	close(ch)
+
+	return g.Wait()
+}
+
+// GetRuleGroup implements rules.RuleStore.
+func (b *BucketRuleStore) GetRuleGroup(ctx context.Context, userID string, namespace string, group string) (*rules.RuleGroupDesc, error) {
+	return b.getRuleGroup(ctx, generateRuleObjectKey(userID, namespace, group), nil)
+}
+
+// SetRuleGroup implements rules.RuleStore.
+func (b *BucketRuleStore) SetRuleGroup(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc) error {
+	defer b.updatesLocks.Lock(userID)()
+
+	return b.setRuleGroup(ctx, userID, namespace, group)
+}
+
+// SetRuleGroupIf implements rules.RuleStore.
+func (b *BucketRuleStore) SetRuleGroupIf(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc, cond rules.RuleGroupCondition) error {
+	defer b.updatesLocks.Lock(userID)()
+
+	if err := b.checkCondition(ctx, userID, namespace, group.Name, cond); err != nil {
+		return err
+	}
+	return b.setRuleGroup(ctx, userID, namespace, group)
+}
+
+func (b *BucketRuleStore) setRuleGroup(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc) error {
+	data, err := proto.Marshal(group)
+	if err != nil {
+		return err
+	}
+
+	return b.bucket.Upload(ctx, generateRuleObjectKey(userID, namespace, group.Name), bytes.NewBuffer(data))
+}
+
+// DeleteRuleGroup implements rules.RuleStore.
+func (b *BucketRuleStore) DeleteRuleGroup(ctx context.Context, userID string, namespace string, group string) error {
+	defer b.updatesLocks.Lock(userID)()
+
+	return b.deleteRuleGroup(ctx, userID, namespace, group)
+}
+
+// DeleteRuleGroupIf implements rules.RuleStore.
+func (b *BucketRuleStore) DeleteRuleGroupIf(ctx context.Context, userID string, namespace string, group string, cond rules.RuleGroupCondition) error {
+	defer b.updatesLocks.Lock(userID)()
+
+	if err := b.checkCondition(ctx, userID, namespace, group, cond); err != nil {
+		return err
+	}
+	return b.deleteRuleGroup(ctx, userID, namespace, group)
+}
+
+func (b *BucketRuleStore) deleteRuleGroup(ctx context.Context, userID string, namespace string, group string) error {
+	err := b.bucket.Delete(ctx, generateRuleObjectKey(userID, namespace, group))
+	if b.bucket.IsObjNotFoundErr(err) {
+		return rules.ErrGroupNotFound
+	}
+	return err
+}
+
+// checkCondition checks the condition of a conditional update against the stored rule group.
+// Must be called with the updates of the user locked.
+func (b *BucketRuleStore) checkCondition(ctx context.Context, userID string, namespace string, group string, cond rules.RuleGroupCondition) error {
+	current, err := b.getRuleGroup(ctx, generateRuleObjectKey(userID, namespace, group), nil)
+	if err == rules.ErrGroupNotFound {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	return cond(current)
+}
+
+// DeleteNamespace implements rules.RuleStore.
+func (b *BucketRuleStore) DeleteNamespace(ctx context.Context, userID string, namespace string) error {
+	defer b.updatesLocks.Lock(userID)()
+
+	ruleGroups, err := b.ListRuleGroupsForUserAndNamespace(ctx, userID, namespace)
+	if err != nil {
+		return err
+	}
+
+	if len(ruleGroups) == 0 {
+		return rules.ErrGroupNamespaceNotFound
+	}
+
+	for _, rg := range ruleGroups {
+		objectKey := generateRuleObjectKey(userID, rg.Namespace, rg.Name)
+		level.Debug(b.logger).Log("msg", "deleting rule group", "namespace", namespace, "key", objectKey)
+		err = b.bucket.Delete(ctx, objectKey)
+		if err != nil {
+			level.Error(b.logger).Log("msg", "unable to delete rule group from namespace", "err", err, "namespace", namespace, "key", objectKey)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func generateRuleObjectKey(userID, namespace, groupName string) string {
+	if userID == "" {
+		return rulePrefix
+	}
+
+	prefix := rulePrefix + userID + delim
+	if namespace == "" {
+		return prefix
+	}
+
+	ns := base64.URLEncoding.EncodeToString([]byte(namespace)) + delim
+	if groupName == "" {
+		return prefix + ns
+	}
+
+	return prefix + ns + base64.URLEncoding.EncodeToString([]byte(groupName))
+}
+
+func decomposeRuleObjectKey(objectKey string) (userID, namespace, groupName string) {
+	if !strings.HasPrefix(objectKey, rulePrefix) {
+		return
+	}
+
+	components := strings.Split(objectKey, delim)
+	if len(components) != 4 {
+		return
+	}
+
+	ns, err := base64.URLEncoding.DecodeString(components[2])
+	if err != nil {
+		return
+	}
+
+	gr, err := base64.URLEncoding.DecodeString(components[3])
+	if err != nil {
+		return
+	}
+
+	return components[1], string(ns), string(gr)
+}
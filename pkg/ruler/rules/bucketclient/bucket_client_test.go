@@ -0,0 +1,169 @@
+package bucketclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"gopkg.in/yaml.v3"
+
+	"github.com/cortexproject/cortex/pkg/ruler/rules"
+)
+
+type testGroup struct {
+	user, namespace string
+	ruleGroup       rulefmt.RuleGroup
+}
+
+func TestBucketRuleStore_ListRules(t *testing.T) {
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), 5, log.NewNopLogger())
+
+	groups := []testGroup{
+		{user: "user1", namespace: "hello", ruleGroup: rulefmt.RuleGroup{Name: "first testGroup"}},
+		{user: "user1", namespace: "hello", ruleGroup: rulefmt.RuleGroup{Name: "second testGroup"}},
+		{user: "user1", namespace: "world", ruleGroup: rulefmt.RuleGroup{Name: "another namespace testGroup"}},
+		{user: "user2", namespace: "+-!@#$%. ", ruleGroup: rulefmt.RuleGroup{Name: "different user"}},
+	}
+
+	for _, g := range groups {
+		desc := rules.ToProto(g.user, g.namespace, g.ruleGroup)
+		require.NoError(t, rs.SetRuleGroup(context.Background(), g.user, g.namespace, desc))
+	}
+
+	users, err := rs.ListAllUsers(context.Background())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"user1", "user2"}, users)
+
+	allGroupsMap, err := rs.ListAllRuleGroups(context.Background())
+	require.NoError(t, err)
+	require.Len(t, allGroupsMap, 2)
+	require.ElementsMatch(t, []*rules.RuleGroupDesc{
+		{User: "user1", Namespace: "hello", Name: "first testGroup"},
+		{User: "user1", Namespace: "hello", Name: "second testGroup"},
+		{User: "user1", Namespace: "world", Name: "another namespace testGroup"},
+	}, allGroupsMap["user1"])
+	require.ElementsMatch(t, []*rules.RuleGroupDesc{
+		{User: "user2", Namespace: "+-!@#$%. ", Name: "different user"},
+	}, allGroupsMap["user2"])
+
+	helloGroups, err := rs.ListRuleGroupsForUserAndNamespace(context.Background(), "user1", "hello")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []*rules.RuleGroupDesc{
+		{User: "user1", Namespace: "hello", Name: "first testGroup"},
+		{User: "user1", Namespace: "hello", Name: "second testGroup"},
+	}, helloGroups)
+
+	missingGroups, err := rs.ListRuleGroupsForUserAndNamespace(context.Background(), "user3", "")
+	require.NoError(t, err)
+	require.Empty(t, missingGroups)
+}
+
+func TestBucketRuleStore_LoadGetAndDelete(t *testing.T) {
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), 5, log.NewNopLogger())
+
+	expr := yaml.Node{}
+	expr.SetString("up")
+	record := yaml.Node{}
+	record.SetString("up_rule")
+
+	desc := rules.ToProto("user1", "namespace", rulefmt.RuleGroup{
+		Name:     "group",
+		Interval: model.Duration(time.Minute),
+		Rules:    []rulefmt.RuleNode{{Record: record, Expr: expr}},
+	})
+	desc.SourceTenants = []string{"tenant-a"}
+	require.NoError(t, rs.SetRuleGroup(context.Background(), "user1", "namespace", desc))
+	require.NoError(t, rs.SetRuleGroup(context.Background(), "user1", "other", rules.ToProto("user1", "other", rulefmt.RuleGroup{Name: "group"})))
+
+	// Load the listed rule groups.
+	all, err := rs.ListAllRuleGroups(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, rs.LoadRuleGroups(context.Background(), all))
+	require.Len(t, all["user1"], 2)
+	for _, rg := range all["user1"] {
+		if rg.Namespace == "namespace" {
+			require.True(t, desc.Equal(rg))
+		}
+	}
+
+	// Get a single rule group.
+	rg, err := rs.GetRuleGroup(context.Background(), "user1", "namespace", "group")
+	require.NoError(t, err)
+	require.True(t, desc.Equal(rg))
+
+	_, err = rs.GetRuleGroup(context.Background(), "user1", "namespace", "missing")
+	require.Equal(t, rules.ErrGroupNotFound, err)
+
+	// Delete a single rule group.
+	require.NoError(t, rs.DeleteRuleGroup(context.Background(), "user1", "namespace", "group"))
+	_, err = rs.GetRuleGroup(context.Background(), "user1", "namespace", "group")
+	require.Equal(t, rules.ErrGroupNotFound, err)
+
+	// Delete a namespace.
+	require.NoError(t, rs.DeleteNamespace(context.Background(), "user1", "other"))
+	require.Equal(t, rules.ErrGroupNamespaceNotFound, rs.DeleteNamespace(context.Background(), "user1", "other"))
+
+	users, err := rs.ListAllUsers(context.Background())
+	require.NoError(t, err)
+	require.Empty(t, users)
+}
+
+func TestBucketRuleStore_ConditionalUpdates(t *testing.T) {
+	rs := NewBucketRuleStore(objstore.NewInMemBucket(), 5, log.NewNopLogger())
+	errChanged := errors.New("changed")
+
+	// Creates the rule group only if it doesn't exist.
+	createIfMissing := func(current *rules.RuleGroupDesc) error {
+		if current != nil {
+			return errChanged
+		}
+		return nil
+	}
+
+	// Only one of the concurrent conditional creations succeeds.
+	var (
+		wg        sync.WaitGroup
+		mtx       sync.Mutex
+		succeeded int
+	)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := rs.SetRuleGroupIf(context.Background(), "user1", "namespace", rules.ToProto("user1", "namespace", rulefmt.RuleGroup{Name: "group"}), createIfMissing)
+			if err == nil {
+				mtx.Lock()
+				succeeded++
+				mtx.Unlock()
+			} else {
+				assert.Equal(t, errChanged, err)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, 1, succeeded)
+
+	// The condition is checked against the stored rule group, and aborts the update on error.
+	rejectStored := func(current *rules.RuleGroupDesc) error {
+		require.NotNil(t, current)
+		require.Equal(t, "group", current.Name)
+		return errChanged
+	}
+	require.Equal(t, errChanged, rs.DeleteRuleGroupIf(context.Background(), "user1", "namespace", "group", rejectStored))
+
+	_, err := rs.GetRuleGroup(context.Background(), "user1", "namespace", "group")
+	require.NoError(t, err)
+
+	require.NoError(t, rs.DeleteRuleGroupIf(context.Background(), "user1", "namespace", "group", func(*rules.RuleGroupDesc) error { return nil }))
+	_, err = rs.GetRuleGroup(context.Background(), "user1", "namespace", "group")
+	require.Equal(t, rules.ErrGroupNotFound, err)
+}
@@ -11,6 +11,21 @@ import (
 	"github.com/cortexproject/cortex/pkg/ingester/client"
 )
 
+// RuleGroup is the format of the rule groups exposed by the ruler API. It extends
+// the Prometheus rule group with the source and destination tenants of a federated
+// rule group.
+type RuleGroup struct {
+	rulefmt.RuleGroup `yaml:",inline"`
+
+	// SourceTenants are the tenants queried when evaluating the rules of a
+	// federated rule group.
+	SourceTenants []string `yaml:"source_tenants,omitempty"`
+
+	// DestinationTenant is the tenant the series resulting from the rules are
+	// written to, instead of the owner of the rule group.
+	DestinationTenant string `yaml:"destination_tenant,omitempty"`
+}
+
 // ToProto transforms a formatted prometheus rulegroup to a rule group protobuf
 func ToProto(user string, namespace string, rl rulefmt.RuleGroup) *RuleGroupDesc {
 	rg := RuleGroupDesc{
@@ -73,3 +88,13 @@ func FromProto(rg *RuleGroupDesc) rulefmt.RuleGroup {
 
 	return formattedRuleGroup
 }
+
+// FromProtoWithSourceTenants generates a RuleGroup, including the source and
+// destination tenants of a federated rule group.
+func FromProtoWithSourceTenants(rg *RuleGroupDesc) RuleGroup {
+	return RuleGroup{
+		RuleGroup:         FromProto(rg),
+		SourceTenants:     rg.GetSourceTenants(),
+		DestinationTenant: rg.GetDestinationTenant(),
+	}
+}
@@ -0,0 +1,33 @@
+package rules
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleGroupDesc_SourceTenantsRoundTrip(t *testing.T) {
+	desc := &RuleGroupDesc{
+		Name:              "group",
+		Namespace:         "namespace",
+		Interval:          time.Minute,
+		Rules:             []*RuleDesc{{Record: "up_rule", Expr: "up"}},
+		User:              "user1",
+		SourceTenants:     []string{"tenant-a", "tenant-b"},
+		DestinationTenant: "aggregations",
+	}
+
+	data, err := desc.Marshal()
+	require.NoError(t, err)
+
+	decoded := &RuleGroupDesc{}
+	require.NoError(t, decoded.Unmarshal(data))
+	assert.True(t, desc.Equal(decoded))
+
+	formatted := FromProtoWithSourceTenants(decoded)
+	assert.Equal(t, "group", formatted.Name)
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, formatted.SourceTenants)
+	assert.Equal(t, "aggregations", formatted.DestinationTenant)
+}
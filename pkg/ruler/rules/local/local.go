@@ -122,6 +122,16 @@ func (l *Client) DeleteNamespace(ctx context.Context, userID, namespace string)
 	return errors.New("DeleteNamespace unsupported in rule local store")
 }
 
+// SetRuleGroupIf implements RuleStore
+func (l *Client) SetRuleGroupIf(ctx context.Context, userID, namespace string, group *rules.RuleGroupDesc, cond rules.RuleGroupCondition) error {
+	return errors.New("SetRuleGroupIf unsupported in rule local store")
+}
+
+// DeleteRuleGroupIf implements RuleStore
+func (l *Client) DeleteRuleGroupIf(ctx context.Context, userID, namespace string, group string, cond rules.RuleGroupCondition) error {
+	return errors.New("DeleteRuleGroupIf unsupported in rule local store")
+}
+
 func (l *Client) loadAllRulesGroupsForUser(ctx context.Context, userID string) (rules.RuleGroupList, error) {
 	var allLists rules.RuleGroupList
 
@@ -35,6 +35,11 @@ const (
 type RuleStore struct {
 	client          chunk.ObjectClient
 	loadConcurrency int
+
+	// Serializes the updates of the rule groups of each user, so that the conditional
+	// updates check the condition and update the rule group atomically. The updates
+	// done by other rulers sharing the storage are not serialized.
+	updatesLocks rules.UserLocks
 }
 
 // NewRuleStore returns a new RuleStore
@@ -196,6 +201,22 @@ func (o *RuleStore) GetRuleGroup(ctx context.Context, userID string, namespace s
 
 // SetRuleGroup sets provided rule group
 func (o *RuleStore) SetRuleGroup(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc) error {
+	defer o.updatesLocks.Lock(userID)()
+
+	return o.setRuleGroup(ctx, userID, namespace, group)
+}
+
+// SetRuleGroupIf sets provided rule group if the condition is satisfied by the stored rule group
+func (o *RuleStore) SetRuleGroupIf(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc, cond rules.RuleGroupCondition) error {
+	defer o.updatesLocks.Lock(userID)()
+
+	if err := o.checkCondition(ctx, userID, namespace, group.Name, cond); err != nil {
+		return err
+	}
+	return o.setRuleGroup(ctx, userID, namespace, group)
+}
+
+func (o *RuleStore) setRuleGroup(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc) error {
 	data, err := proto.Marshal(group)
 	if err != nil {
 		return err
@@ -207,6 +228,22 @@ func (o *RuleStore) SetRuleGroup(ctx context.Context, userID string, namespace s
 
 // DeleteRuleGroup deletes the specified rule group
 func (o *RuleStore) DeleteRuleGroup(ctx context.Context, userID string, namespace string, groupName string) error {
+	defer o.updatesLocks.Lock(userID)()
+
+	return o.deleteRuleGroup(ctx, userID, namespace, groupName)
+}
+
+// DeleteRuleGroupIf deletes the specified rule group if the condition is satisfied by the stored rule group
+func (o *RuleStore) DeleteRuleGroupIf(ctx context.Context, userID string, namespace string, groupName string, cond rules.RuleGroupCondition) error {
+	defer o.updatesLocks.Lock(userID)()
+
+	if err := o.checkCondition(ctx, userID, namespace, groupName, cond); err != nil {
+		return err
+	}
+	return o.deleteRuleGroup(ctx, userID, namespace, groupName)
+}
+
+func (o *RuleStore) deleteRuleGroup(ctx context.Context, userID string, namespace string, groupName string) error {
 	objectKey := generateRuleObjectKey(userID, namespace, groupName)
 	err := o.client.DeleteObject(ctx, objectKey)
 	if err == chunk.ErrStorageObjectNotFound {
@@ -215,8 +252,23 @@ func (o *RuleStore) DeleteRuleGroup(ctx context.Context, userID string, namespac
 	return err
 }
 
+// checkCondition checks the condition of a conditional update against the stored rule group.
+// Must be called with the updates of the user locked.
+func (o *RuleStore) checkCondition(ctx context.Context, userID string, namespace string, groupName string, cond rules.RuleGroupCondition) error {
+	current, err := o.getRuleGroup(ctx, generateRuleObjectKey(userID, namespace, groupName), nil)
+	if err == rules.ErrGroupNotFound {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	return cond(current)
+}
+
 // DeleteNamespace deletes all the rule groups in the specified namespace
 func (o *RuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
+	defer o.updatesLocks.Lock(userID)()
+
 	ruleGroupObjects, _, err := o.client.List(ctx, generateRuleObjectKey(userID, namespace, ""), "")
 	if err != nil {
 		return err
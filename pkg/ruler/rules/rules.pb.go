@@ -43,7 +43,9 @@ type RuleGroupDesc struct {
 	// having to repeatedly redefine the proto description. It can also be leveraged
 	// to create custom `ManagerOpts` based on rule configs which can then be passed
 	// to the Prometheus Manager.
-	Options []*types.Any `protobuf:"bytes,9,rep,name=options,proto3" json:"options,omitempty"`
+	Options           []*types.Any `protobuf:"bytes,9,rep,name=options,proto3" json:"options,omitempty"`
+	SourceTenants     []string     `protobuf:"bytes,10,rep,name=source_tenants,json=sourceTenants,proto3" json:"source_tenants,omitempty"`
+	DestinationTenant string       `protobuf:"bytes,11,opt,name=destination_tenant,json=destinationTenant,proto3" json:"destination_tenant,omitempty"`
 }
 
 func (m *RuleGroupDesc) Reset()      { *m = RuleGroupDesc{} }
@@ -120,6 +122,20 @@ func (m *RuleGroupDesc) GetOptions() []*types.Any {
 	return nil
 }
 
+func (m *RuleGroupDesc) GetSourceTenants() []string {
+	if m != nil {
+		return m.SourceTenants
+	}
+	return nil
+}
+
+func (m *RuleGroupDesc) GetDestinationTenant() string {
+	if m != nil {
+		return m.DestinationTenant
+	}
+	return ""
+}
+
 // RuleDesc is a proto representation of a Prometheus Rule
 type RuleDesc struct {
 	Expr        string                                                             `protobuf:"bytes,1,opt,name=expr,proto3" json:"expr,omitempty"`
@@ -198,38 +214,40 @@ func init() {
 func init() { proto.RegisterFile("rules.proto", fileDescriptor_8e722d3e922f0937) }
 
 var fileDescriptor_8e722d3e922f0937 = []byte{
-	// 483 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x52, 0x41, 0x6b, 0xd4, 0x40,
-	0x14, 0xce, 0x74, 0xb3, 0x69, 0x32, 0x4b, 0x71, 0x1d, 0x8a, 0xa4, 0x45, 0x66, 0x97, 0x82, 0xb0,
-	0x17, 0x13, 0xa8, 0x78, 0xf2, 0xa0, 0x5d, 0x0a, 0xca, 0xe2, 0x41, 0x72, 0xf4, 0x36, 0x9b, 0x7d,
-	0x8d, 0xd1, 0x74, 0x26, 0x4c, 0x26, 0x62, 0x0f, 0x82, 0x3f, 0xc1, 0xa3, 0x3f, 0xc1, 0x9f, 0xd2,
-	0xe3, 0x1e, 0x8b, 0x87, 0xea, 0x66, 0x2f, 0x1e, 0xfb, 0x07, 0x44, 0x99, 0x99, 0xc4, 0x2e, 0x7a,
-	0x11, 0xa1, 0xa7, 0xbc, 0x6f, 0xbe, 0x79, 0xf3, 0x7d, 0xef, 0x7b, 0xc1, 0x03, 0x59, 0x17, 0x50,
-	0x45, 0xa5, 0x14, 0x4a, 0x90, 0xbe, 0x01, 0xfb, 0xf7, 0xb3, 0x5c, 0xbd, 0xaa, 0xe7, 0x51, 0x2a,
-	0x4e, 0xe3, 0x4c, 0x64, 0x22, 0x36, 0xec, 0xbc, 0x3e, 0x31, 0xc8, 0x00, 0x53, 0xd9, 0xae, 0x7d,
-	0x9a, 0x09, 0x91, 0x15, 0x70, 0x7d, 0x6b, 0x51, 0x4b, 0xa6, 0x72, 0xc1, 0x5b, 0x7e, 0xef, 0x4f,
-	0x9e, 0xf1, 0xb3, 0x96, 0x7a, 0xb2, 0xa1, 0x94, 0x0a, 0xa9, 0xe0, 0x5d, 0x29, 0xc5, 0x6b, 0x48,
-	0x55, 0x8b, 0xe2, 0xf2, 0x4d, 0x16, 0xe7, 0x3c, 0x83, 0x4a, 0x81, 0x8c, 0xd3, 0x22, 0x07, 0xde,
-	0x51, 0xf6, 0x85, 0x83, 0x9f, 0x08, 0xef, 0x24, 0x75, 0x01, 0x4f, 0xa5, 0xa8, 0xcb, 0x63, 0xa8,
-	0x52, 0x42, 0xb0, 0xcb, 0xd9, 0x29, 0x84, 0x68, 0x8c, 0x26, 0x41, 0x62, 0x6a, 0x72, 0x17, 0x07,
-	0xfa, 0x5b, 0x95, 0x2c, 0x85, 0x70, 0xcb, 0x10, 0xd7, 0x07, 0xe4, 0x31, 0xf6, 0x73, 0xae, 0x40,
-	0xbe, 0x65, 0x45, 0xd8, 0x1b, 0xa3, 0xc9, 0xe0, 0x70, 0x2f, 0xb2, 0x9e, 0xa3, 0xce, 0x73, 0x74,
-	0xdc, 0xce, 0x34, 0xf5, 0xcf, 0x2f, 0x47, 0xce, 0xa7, 0xaf, 0x23, 0x94, 0xfc, 0x6e, 0x22, 0xf7,
-	0xb0, 0x4d, 0x2e, 0x74, 0xc7, 0xbd, 0xc9, 0xe0, 0xf0, 0x56, 0x64, 0x43, 0xd5, 0xbe, 0xb4, 0xa5,
-	0xc4, 0xb2, 0xda, 0x59, 0x5d, 0x81, 0x0c, 0x3d, 0xeb, 0x4c, 0xd7, 0x24, 0xc2, 0xdb, 0xa2, 0xd4,
-	0x0f, 0x57, 0x61, 0x60, 0x9a, 0x77, 0xff, 0x92, 0x3e, 0xe2, 0x67, 0x49, 0x77, 0x69, 0xe6, 0xfa,
-	0xfd, 0xa1, 0x37, 0x73, 0xfd, 0xed, 0xa1, 0x3f, 0x73, 0x7d, 0x7f, 0x18, 0x1c, 0xfc, 0xd8, 0xc2,
-	0x7e, 0xa7, 0xa4, 0x25, 0x74, 0x86, 0xdd, 0xf0, 0xba, 0x26, 0x77, 0xb0, 0x27, 0x21, 0x15, 0x72,
-	0xd1, 0x4e, 0xde, 0x22, 0xb2, 0x8b, 0xfb, 0xac, 0x00, 0xa9, 0xcc, 0xcc, 0x41, 0x62, 0x01, 0x79,
-	0x88, 0x7b, 0x27, 0x42, 0x86, 0xee, 0xbf, 0xe7, 0xa0, 0xef, 0x93, 0x0a, 0x7b, 0x05, 0x9b, 0x43,
-	0x51, 0x85, 0x7d, 0x33, 0xc6, 0xed, 0xa8, 0x5d, 0xd3, 0x73, 0x7d, 0xfa, 0x82, 0xe5, 0x72, 0xfa,
-	0x4c, 0x77, 0x7c, 0xb9, 0x1c, 0xfd, 0xcf, 0xd2, 0xed, 0x33, 0x47, 0x0b, 0x56, 0x2a, 0x90, 0x49,
-	0x2b, 0x45, 0xde, 0xe3, 0x01, 0xe3, 0x5c, 0x28, 0x66, 0x03, 0xf4, 0x6e, 0x5e, 0x79, 0x53, 0xcf,
-	0x6c, 0x61, 0x67, 0xfa, 0x68, 0xb9, 0xa2, 0xce, 0xc5, 0x8a, 0x3a, 0x57, 0x2b, 0x8a, 0x3e, 0x34,
-	0x14, 0x7d, 0x6e, 0x28, 0x3a, 0x6f, 0x28, 0x5a, 0x36, 0x14, 0x7d, 0x6b, 0x28, 0xfa, 0xde, 0x50,
-	0xe7, 0xaa, 0xa1, 0xe8, 0xe3, 0x9a, 0x3a, 0xcb, 0x35, 0x75, 0x2e, 0xd6, 0xd4, 0x79, 0x69, 0x7f,
-	0x89, 0xb9, 0x67, 0x82, 0x7d, 0xf0, 0x2b, 0x00, 0x00, 0xff, 0xff, 0xf0, 0x09, 0x30, 0x58, 0x87,
-	0x03, 0x00, 0x00,
+	// 527 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x52, 0x4f, 0x6b, 0x13, 0x4f,
+	0x18, 0xde, 0x49, 0x36, 0xdb, 0xdd, 0x09, 0xf9, 0xfd, 0xd2, 0xa1, 0xc8, 0xb4, 0xc8, 0x24, 0x14,
+	0x0a, 0xb9, 0x74, 0x03, 0x15, 0x4f, 0x1e, 0xb4, 0xa1, 0xa0, 0x04, 0x0f, 0xb2, 0x78, 0xf2, 0x22,
+	0x93, 0xcd, 0x74, 0x5d, 0xdd, 0xce, 0x2c, 0x33, 0xb3, 0x62, 0x0f, 0x82, 0x1f, 0xc1, 0xa3, 0x1f,
+	0xc1, 0x8f, 0xd2, 0x63, 0x2e, 0x42, 0xf1, 0x50, 0xcd, 0xe6, 0xe2, 0xb1, 0x5f, 0x40, 0x90, 0x99,
+	0xd9, 0xd8, 0xa0, 0x17, 0x11, 0x3c, 0xed, 0xfb, 0xbc, 0xcf, 0xfb, 0xe7, 0xd9, 0xe7, 0x1d, 0xd8,
+	0x95, 0x55, 0xc1, 0x54, 0x5c, 0x4a, 0xa1, 0x05, 0xea, 0x58, 0xb0, 0x77, 0x98, 0xe5, 0xfa, 0x45,
+	0x35, 0x8b, 0x53, 0x71, 0x36, 0xce, 0x44, 0x26, 0xc6, 0x96, 0x9d, 0x55, 0xa7, 0x16, 0x59, 0x60,
+	0x23, 0xd7, 0xb5, 0x47, 0x32, 0x21, 0xb2, 0x82, 0xdd, 0x54, 0xcd, 0x2b, 0x49, 0x75, 0x2e, 0x78,
+	0xc3, 0xef, 0xfe, 0xca, 0x53, 0x7e, 0xde, 0x50, 0x0f, 0x36, 0x36, 0xa5, 0x42, 0x6a, 0xf6, 0xa6,
+	0x94, 0xe2, 0x25, 0x4b, 0x75, 0x83, 0xc6, 0xe5, 0xab, 0x6c, 0x9c, 0xf3, 0x8c, 0x29, 0xcd, 0xe4,
+	0x38, 0x2d, 0x72, 0xc6, 0xd7, 0x94, 0x9b, 0xb0, 0xff, 0xa9, 0x05, 0x7b, 0x49, 0x55, 0xb0, 0x87,
+	0x52, 0x54, 0xe5, 0x09, 0x53, 0x29, 0x42, 0xd0, 0xe7, 0xf4, 0x8c, 0x61, 0x30, 0x04, 0xa3, 0x28,
+	0xb1, 0x31, 0xba, 0x0d, 0x23, 0xf3, 0x55, 0x25, 0x4d, 0x19, 0x6e, 0x59, 0xe2, 0x26, 0x81, 0xee,
+	0xc3, 0x30, 0xe7, 0x9a, 0xc9, 0xd7, 0xb4, 0xc0, 0xed, 0x21, 0x18, 0x75, 0x8f, 0x76, 0x63, 0xa7,
+	0x39, 0x5e, 0x6b, 0x8e, 0x4f, 0x9a, 0x7f, 0x9a, 0x84, 0x17, 0x57, 0x03, 0xef, 0xc3, 0x97, 0x01,
+	0x48, 0x7e, 0x36, 0xa1, 0x03, 0xe8, 0x9c, 0xc3, 0xfe, 0xb0, 0x3d, 0xea, 0x1e, 0xfd, 0x1f, 0x5b,
+	0x14, 0x1b, 0x5d, 0x46, 0x52, 0xe2, 0x58, 0xa3, 0xac, 0x52, 0x4c, 0xe2, 0xc0, 0x29, 0x33, 0x31,
+	0x8a, 0xe1, 0x96, 0x28, 0xcd, 0x60, 0x85, 0x23, 0xdb, 0xbc, 0xf3, 0xdb, 0xea, 0x63, 0x7e, 0x9e,
+	0xac, 0x8b, 0xd0, 0x01, 0xfc, 0x4f, 0x89, 0x4a, 0xa6, 0xec, 0xb9, 0x66, 0x9c, 0x72, 0xad, 0x30,
+	0x1c, 0xb6, 0x47, 0x51, 0xd2, 0x73, 0xd9, 0xa7, 0x2e, 0x89, 0x0e, 0x21, 0x9a, 0x33, 0xa5, 0x73,
+	0x6e, 0x45, 0x37, 0xb5, 0xb8, 0x6b, 0x17, 0x6f, 0x6f, 0x30, 0xae, 0x7e, 0xea, 0x87, 0x9d, 0x7e,
+	0x30, 0xf5, 0xc3, 0xad, 0x7e, 0x38, 0xf5, 0xc3, 0xb0, 0x1f, 0xed, 0x7f, 0x6f, 0xc1, 0x70, 0xad,
+	0xdf, 0x08, 0x37, 0x97, 0x59, 0x5b, 0x6a, 0x62, 0x74, 0x0b, 0x06, 0x92, 0xa5, 0x42, 0xce, 0x1b,
+	0x3f, 0x1b, 0x84, 0x76, 0x60, 0x87, 0x16, 0x4c, 0x6a, 0xeb, 0x64, 0x94, 0x38, 0x80, 0xee, 0xc2,
+	0xf6, 0xa9, 0x90, 0xd8, 0xff, 0x73, 0x77, 0x4d, 0x3d, 0x52, 0x30, 0x28, 0xe8, 0x8c, 0x15, 0x0a,
+	0x77, 0xac, 0x39, 0xdb, 0x71, 0x73, 0xfc, 0xc7, 0x26, 0xfb, 0x84, 0xe6, 0x72, 0xf2, 0xc8, 0x74,
+	0x7c, 0xbe, 0x1a, 0xfc, 0xcd, 0x53, 0x72, 0x63, 0x8e, 0xe7, 0xb4, 0xd4, 0x4c, 0x26, 0xcd, 0x2a,
+	0xf4, 0x16, 0x76, 0x29, 0xe7, 0x42, 0x53, 0x77, 0x96, 0xe0, 0xdf, 0x6f, 0xde, 0xdc, 0x67, 0xaf,
+	0xd0, 0x9b, 0xdc, 0x5b, 0x2c, 0x89, 0x77, 0xb9, 0x24, 0xde, 0xf5, 0x92, 0x80, 0x77, 0x35, 0x01,
+	0x1f, 0x6b, 0x02, 0x2e, 0x6a, 0x02, 0x16, 0x35, 0x01, 0x5f, 0x6b, 0x02, 0xbe, 0xd5, 0xc4, 0xbb,
+	0xae, 0x09, 0x78, 0xbf, 0x22, 0xde, 0x62, 0x45, 0xbc, 0xcb, 0x15, 0xf1, 0x9e, 0xb9, 0x87, 0x36,
+	0x0b, 0xac, 0xb1, 0x77, 0x7e, 0x0c, 0x00, 0xd5, 0xe4, 0x06, 0x98, 0xdd, 0x03, 0x00, 0x00,
 }
 
 func (this *RuleGroupDesc) Equal(that interface{}) bool {
@@ -279,6 +297,17 @@ func (this *RuleGroupDesc) Equal(that interface{}) bool {
 			return false
 		}
 	}
+	if len(this.SourceTenants) != len(that1.SourceTenants) {
+		return false
+	}
+	for i := range this.SourceTenants {
+		if this.SourceTenants[i] != that1.SourceTenants[i] {
+			return false
+		}
+	}
+	if this.DestinationTenant != that1.DestinationTenant {
+		return false
+	}
 	return true
 }
 func (this *RuleDesc) Equal(that interface{}) bool {
@@ -334,7 +363,7 @@ func (this *RuleGroupDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 10)
+	s := make([]string, 0, 11)
 	s = append(s, "&rules.RuleGroupDesc{")
 	s = append(s, "Name: "+fmt.Sprintf("%#v", this.Name)+",\n")
 	s = append(s, "Namespace: "+fmt.Sprintf("%#v", this.Namespace)+",\n")
@@ -346,6 +375,8 @@ func (this *RuleGroupDesc) GoString() string {
 	if this.Options != nil {
 		s = append(s, "Options: "+fmt.Sprintf("%#v", this.Options)+",\n")
 	}
+	s = append(s, "SourceTenants: "+fmt.Sprintf("%#v", this.SourceTenants)+",\n")
+	s = append(s, "DestinationTenant: "+fmt.Sprintf("%#v", this.DestinationTenant)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -392,6 +423,22 @@ func (m *RuleGroupDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
+	if len(m.DestinationTenant) > 0 {
+		i -= len(m.DestinationTenant)
+		copy(dAtA[i:], m.DestinationTenant)
+		i = encodeVarintRules(dAtA, i, uint64(len(m.DestinationTenant)))
+		i--
+		dAtA[i] = 0x5a
+	}
+	if len(m.SourceTenants) > 0 {
+		for iNdEx := len(m.SourceTenants) - 1; iNdEx >= 0; iNdEx-- {
+			i -= len(m.SourceTenants[iNdEx])
+			copy(dAtA[i:], m.SourceTenants[iNdEx])
+			i = encodeVarintRules(dAtA, i, uint64(len(m.SourceTenants[iNdEx])))
+			i--
+			dAtA[i] = 0x52
+		}
+	}
 	if len(m.Options) > 0 {
 		for iNdEx := len(m.Options) - 1; iNdEx >= 0; iNdEx-- {
 			{
@@ -575,6 +622,16 @@ func (m *RuleGroupDesc) Size() (n int) {
 			n += 1 + l + sovRules(uint64(l))
 		}
 	}
+	if len(m.SourceTenants) > 0 {
+		for _, s := range m.SourceTenants {
+			l = len(s)
+			n += 1 + l + sovRules(uint64(l))
+		}
+	}
+	l = len(m.DestinationTenant)
+	if l > 0 {
+		n += 1 + l + sovRules(uint64(l))
+	}
 	return n
 }
 
@@ -640,6 +697,8 @@ func (this *RuleGroupDesc) String() string {
 		`Rules:` + repeatedStringForRules + `,`,
 		`User:` + fmt.Sprintf("%v", this.User) + `,`,
 		`Options:` + repeatedStringForOptions + `,`,
+		`SourceTenants:` + fmt.Sprintf("%v", this.SourceTenants) + `,`,
+		`DestinationTenant:` + fmt.Sprintf("%v", this.DestinationTenant) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -893,6 +952,70 @@ func (m *RuleGroupDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field SourceTenants", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.SourceTenants = append(m.SourceTenants, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 11:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DestinationTenant", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRules
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthRules
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex < 0 {
+				return ErrInvalidLengthRules
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.DestinationTenant = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRules(dAtA[iNdEx:])
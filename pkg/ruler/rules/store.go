@@ -3,6 +3,7 @@ package rules
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/prometheus/prometheus/pkg/rulefmt"
 
@@ -38,6 +39,57 @@ type RuleStore interface {
 	SetRuleGroup(ctx context.Context, userID, namespace string, group *RuleGroupDesc) error
 	DeleteRuleGroup(ctx context.Context, userID, namespace string, group string) error
 	DeleteNamespace(ctx context.Context, userID, namespace string) error
+
+	// SetRuleGroupIf and DeleteRuleGroupIf update the rule group only if the condition, checked
+	// against the currently stored rule group, is satisfied. The condition is checked and the rule
+	// group updated atomically with respect to the other updates of the rule group done by the same
+	// store instance only: the updates done by other processes (ie. other rulers) are not serialized.
+	SetRuleGroupIf(ctx context.Context, userID, namespace string, group *RuleGroupDesc, cond RuleGroupCondition) error
+	DeleteRuleGroupIf(ctx context.Context, userID, namespace string, group string, cond RuleGroupCondition) error
+}
+
+// RuleGroupCondition is the condition of a conditional update of a rule group. It's called with
+// the currently stored rule group, nil if it doesn't exist, and returns an error to abort the update.
+type RuleGroupCondition func(current *RuleGroupDesc) error
+
+// UserLocks serializes the updates of the rule groups of each user done by a store, so that the
+// conditional updates check the condition and update the rule group atomically within the process.
+type UserLocks struct {
+	mtx   sync.Mutex
+	locks map[string]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+
+	// Number of callers holding or waiting for the lock, protected by UserLocks.mtx.
+	refs int
+}
+
+// Lock locks the updates of the rule groups of the user, and returns the function to unlock them.
+func (l *UserLocks) Lock(userID string) (unlock func()) {
+	l.mtx.Lock()
+	if l.locks == nil {
+		l.locks = map[string]*userLock{}
+	}
+	lock, ok := l.locks[userID]
+	if !ok {
+		lock = &userLock{}
+		l.locks[userID] = lock
+	}
+	lock.refs++
+	l.mtx.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		if lock.refs--; lock.refs == 0 {
+			delete(l.locks, userID)
+		}
+	}
 }
 
 // RuleGroupList contains a set of rule groups
@@ -58,6 +110,16 @@ func (l RuleGroupList) Formatted() map[string][]rulefmt.RuleGroup {
 	return ruleMap
 }
 
+// FormattedWithSourceTenants returns the rule group list as a set of rule groups,
+// including the source tenants of the federated rule groups, mapped by namespace.
+func (l RuleGroupList) FormattedWithSourceTenants() map[string][]RuleGroup {
+	ruleMap := map[string][]RuleGroup{}
+	for _, g := range l {
+		ruleMap[g.Namespace] = append(ruleMap[g.Namespace], FromProtoWithSourceTenants(g))
+	}
+	return ruleMap
+}
+
 // ConfigRuleStore is a concrete implementation of RuleStore that sources rules from the config service
 type ConfigRuleStore struct {
 	configClient  client.Client
@@ -175,3 +237,13 @@ func (c *ConfigRuleStore) DeleteRuleGroup(ctx context.Context, userID, namespace
 func (c *ConfigRuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
 	return errors.New("not implemented by the config service rule store")
 }
+
+// SetRuleGroupIf is not implemented
+func (c *ConfigRuleStore) SetRuleGroupIf(ctx context.Context, userID, namespace string, group *RuleGroupDesc, cond RuleGroupCondition) error {
+	return errors.New("not implemented by the config service rule store")
+}
+
+// DeleteRuleGroupIf is not implemented
+func (c *ConfigRuleStore) DeleteRuleGroupIf(ctx context.Context, userID, namespace string, group string, cond RuleGroupCondition) error {
+	return errors.New("not implemented by the config service rule store")
+}
@@ -183,3 +183,37 @@ groups:
 		},
 	}
 }
+
+func TestUserLocks(t *testing.T) {
+	locks := UserLocks{}
+
+	unlock := locks.Lock("user-1")
+
+	// The updates of another user are not blocked.
+	locks.Lock("user-2")()
+
+	// The updates of the same user wait for the lock.
+	locked, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+
+		unlock := locks.Lock("user-1")
+		close(locked)
+		unlock()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("the lock of the user has been acquired twice")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	unlock()
+	<-locked
+	<-done
+
+	// The locks of the users are removed once released.
+	locks.mtx.Lock()
+	defer locks.mtx.Unlock()
+	assert.Empty(t, locks.locks)
+}
@@ -5,7 +5,9 @@ import (
 	"flag"
 	"fmt"
 
+	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
 	promRules "github.com/prometheus/prometheus/rules"
 
 	"github.com/cortexproject/cortex/pkg/chunk"
@@ -15,8 +17,10 @@ import (
 	"github.com/cortexproject/cortex/pkg/chunk/openstack"
 	"github.com/cortexproject/cortex/pkg/configs/client"
 	"github.com/cortexproject/cortex/pkg/ruler/rules"
+	"github.com/cortexproject/cortex/pkg/ruler/rules/bucketclient"
 	"github.com/cortexproject/cortex/pkg/ruler/rules/local"
 	"github.com/cortexproject/cortex/pkg/ruler/rules/objectclient"
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
 )
 
 // RuleStoreConfig configures a rule store.
@@ -31,6 +35,9 @@ type RuleStoreConfig struct {
 	Swift openstack.SwiftConfig   `yaml:"swift"`
 	Local local.Config            `yaml:"local"`
 
+	// Bucket config, shared with the blocks storage.
+	Bucket bucket.Config `yaml:"bucket"`
+
 	mock rules.RuleStore `yaml:"-"`
 }
 
@@ -42,8 +49,9 @@ func (cfg *RuleStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	cfg.S3.RegisterFlagsWithPrefix("ruler.storage.", f)
 	cfg.Swift.RegisterFlagsWithPrefix("ruler.storage.", f)
 	cfg.Local.RegisterFlagsWithPrefix("ruler.storage.", f)
+	cfg.Bucket.RegisterFlagsWithPrefix("ruler.storage.bucket.", f)
 
-	f.StringVar(&cfg.Type, "ruler.storage.type", "configdb", "Method to use for backend rule storage (configdb, azure, gcs, s3, swift, local)")
+	f.StringVar(&cfg.Type, "ruler.storage.type", "configdb", "Method to use for backend rule storage (configdb, azure, gcs, s3, swift, local, bucket). The bucket storage supports the same backends of the blocks storage, configured via the -ruler.storage.bucket.* flags. With the object storage backends, the conditional updates of the rule groups (If-Match and If-None-Match headers of the rules API) are best-effort: they are only atomic within a single ruler.")
 }
 
 // Validate config and returns error on failure
@@ -57,6 +65,11 @@ func (cfg *RuleStoreConfig) Validate() error {
 	if err := cfg.S3.Validate(); err != nil {
 		return errors.Wrap(err, "invalid S3 Storage config")
 	}
+	if cfg.Type == "bucket" {
+		if err := cfg.Bucket.Validate(); err != nil {
+			return errors.Wrap(err, "invalid bucket config")
+		}
+	}
 	return nil
 }
 
@@ -66,7 +79,7 @@ func (cfg *RuleStoreConfig) IsDefaults() bool {
 }
 
 // NewRuleStorage returns a new rule storage backend poller and store
-func NewRuleStorage(cfg RuleStoreConfig, loader promRules.GroupLoader) (rules.RuleStore, error) {
+func NewRuleStorage(cfg RuleStoreConfig, loader promRules.GroupLoader, logger log.Logger, reg prometheus.Registerer) (rules.RuleStore, error) {
 	if cfg.mock != nil {
 		return cfg.mock, nil
 	}
@@ -94,8 +107,14 @@ func NewRuleStorage(cfg RuleStoreConfig, loader promRules.GroupLoader) (rules.Ru
 		return newObjRuleStore(openstack.NewSwiftObjectClient(cfg.Swift))
 	case "local":
 		return local.NewLocalRulesClient(cfg.Local, loader)
+	case "bucket":
+		bucketClient, err := bucket.NewClient(context.Background(), cfg.Bucket, "ruler-storage", logger, reg)
+		if err != nil {
+			return nil, err
+		}
+		return bucketclient.NewBucketRuleStore(bucketClient, loadRulesConcurrency, logger), nil
 	default:
-		return nil, fmt.Errorf("Unrecognized rule storage mode %v, choose one of: configdb, gcs, s3, swift, azure, local", cfg.Type)
+		return nil, fmt.Errorf("Unrecognized rule storage mode %v, choose one of: configdb, gcs, s3, swift, azure, local, bucket", cfg.Type)
 	}
 }
 
@@ -12,6 +12,9 @@ import (
 type mockRuleStore struct {
 	rules map[string]rules.RuleGroupList
 	mtx   sync.Mutex
+
+	// Serializes the conditional updates.
+	condMtx sync.Mutex
 }
 
 var (
@@ -234,7 +237,7 @@ func (m *mockRuleStore) DeleteRuleGroup(ctx context.Context, userID string, name
 
 	for i, rg := range userRules {
 		if rg.Namespace == namespace && rg.Name == group {
-			m.rules[userID] = append(userRules[:i], userRules[:i+1]...)
+			m.rules[userID] = append(userRules[:i], userRules[i+1:]...)
 			return nil
 		}
 	}
@@ -242,6 +245,37 @@ func (m *mockRuleStore) DeleteRuleGroup(ctx context.Context, userID string, name
 	return nil
 }
 
+func (m *mockRuleStore) SetRuleGroupIf(ctx context.Context, userID string, namespace string, group *rules.RuleGroupDesc, cond rules.RuleGroupCondition) error {
+	m.condMtx.Lock()
+	defer m.condMtx.Unlock()
+
+	if err := m.checkCondition(ctx, userID, namespace, group.Name, cond); err != nil {
+		return err
+	}
+	return m.SetRuleGroup(ctx, userID, namespace, group)
+}
+
+func (m *mockRuleStore) DeleteRuleGroupIf(ctx context.Context, userID string, namespace string, group string, cond rules.RuleGroupCondition) error {
+	m.condMtx.Lock()
+	defer m.condMtx.Unlock()
+
+	if err := m.checkCondition(ctx, userID, namespace, group, cond); err != nil {
+		return err
+	}
+	return m.DeleteRuleGroup(ctx, userID, namespace, group)
+}
+
+func (m *mockRuleStore) checkCondition(ctx context.Context, userID string, namespace string, group string, cond rules.RuleGroupCondition) error {
+	current, err := m.GetRuleGroup(ctx, userID, namespace, group)
+	if err == rules.ErrGroupNotFound || err == rules.ErrGroupNamespaceNotFound || err == rules.ErrUserNotFound {
+		current, err = nil, nil
+	}
+	if err != nil {
+		return err
+	}
+	return cond(current)
+}
+
 func (m *mockRuleStore) DeleteNamespace(ctx context.Context, userID, namespace string) error {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
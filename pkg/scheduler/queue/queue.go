@@ -62,7 +62,8 @@ func NewRequestQueue(maxOutstandingPerTenant int, queueLength *prometheus.GaugeV
 	return q
 }
 
-// Puts the request into the queue. MaxQueries is user-specific value that specifies how many queriers can
+// Puts the request into the queue. Requests of the same user are dequeued by priority (see PrioritizedRequest),
+// and in FIFO order for the same priority. MaxQueries is user-specific value that specifies how many queriers can
 // this user use (zero or negative = all queriers). It is passed to each EnqueueRequest, because it can change
 // between calls.
 //
@@ -81,18 +82,18 @@ func (q *RequestQueue) EnqueueRequest(userID string, req Request, maxQueriers in
 		return errors.New("no queue found")
 	}
 
-	select {
-	case queue <- req:
-		q.queueLength.WithLabelValues(userID).Inc()
-		q.cond.Broadcast()
-		// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
-		if successFn != nil {
-			successFn()
-		}
-		return nil
-	default:
+	if queue.len() >= q.queues.maxUserQueueSize {
 		return ErrTooManyRequests
 	}
+
+	queue.enqueue(req)
+	q.queueLength.WithLabelValues(userID).Inc()
+	q.cond.Broadcast()
+	// Call this function while holding a lock. This guarantees that no querier can fetch the request before function returns.
+	if successFn != nil {
+		successFn()
+	}
+	return nil
 }
 
 // GetNextRequestForQuerier find next user queue and takes the next request off of it. Will block if there are no requests.
@@ -128,8 +129,8 @@ FindQueue:
 
 		// Pick next request from the queue.
 		for {
-			request := <-queue
-			if len(queue) == 0 {
+			request := queue.dequeue()
+			if queue.len() == 0 {
 				q.queues.deleteQueue(userID)
 			}
 
@@ -7,8 +7,67 @@ import (
 	"testing"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+type prioritizedRequest struct {
+	id       string
+	priority int64
+}
+
+func (r prioritizedRequest) Priority() int64 {
+	return r.priority
+}
+
+func TestRequestQueue_ShouldDequeueRequestsByPriority(t *testing.T) {
+	const userID = "user-1"
+
+	queue := NewRequestQueue(10, prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}))
+	queue.RegisterQuerierConnection("querier-1")
+
+	for _, req := range []Request{
+		prioritizedRequest{id: "low-1", priority: -1},
+		prioritizedRequest{id: "default-1", priority: 0},
+		prioritizedRequest{id: "high-1", priority: 10},
+		"no-priority",
+		prioritizedRequest{id: "high-2", priority: 10},
+		prioritizedRequest{id: "low-2", priority: -1},
+	} {
+		require.NoError(t, queue.EnqueueRequest(userID, req, 0, nil))
+	}
+
+	var dequeued []Request
+	idx := FirstUser()
+	for i := 0; i < 6; i++ {
+		req, nidx, err := queue.GetNextRequestForQuerier(context.Background(), idx, "querier-1")
+		require.NoError(t, err)
+		dequeued = append(dequeued, req)
+		idx = nidx
+	}
+
+	// Requests with the highest priority come first, and requests with the same priority are FIFO.
+	assert.Equal(t, []Request{
+		prioritizedRequest{id: "high-1", priority: 10},
+		prioritizedRequest{id: "high-2", priority: 10},
+		prioritizedRequest{id: "default-1", priority: 0},
+		"no-priority",
+		prioritizedRequest{id: "low-1", priority: -1},
+		prioritizedRequest{id: "low-2", priority: -1},
+	}, dequeued)
+}
+
+func TestRequestQueue_ShouldRejectRequestsOnMaxOutstandingPerTenant(t *testing.T) {
+	queue := NewRequestQueue(2, prometheus.NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}))
+
+	require.NoError(t, queue.EnqueueRequest("user-1", "request-1", 0, nil))
+	require.NoError(t, queue.EnqueueRequest("user-1", prioritizedRequest{id: "request-2", priority: 10}, 0, nil))
+	assert.Equal(t, ErrTooManyRequests, queue.EnqueueRequest("user-1", prioritizedRequest{id: "request-3", priority: 100}, 0, nil))
+
+	// The limit is per tenant.
+	require.NoError(t, queue.EnqueueRequest("user-2", "request-1", 0, nil))
+}
+
 func BenchmarkGetNextRequest(b *testing.B) {
 	const maxOutstandingPerTenant = 2
 	const numTenants = 50
@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"container/heap"
+)
+
+// PrioritizedRequest is a Request with a priority. Among the queued requests of the same user,
+// the ones with the highest priority are dequeued first. Requests not implementing this interface
+// have priority 0.
+type PrioritizedRequest interface {
+	Request
+
+	// Priority returns the priority of the request. The larger the number the higher the priority.
+	Priority() int64
+}
+
+// userRequests holds the queued requests of a user, ordered by priority and then by
+// enqueue order, so that requests with the same priority are dequeued in FIFO order.
+type userRequests struct {
+	heap requestsHeap
+
+	// Sequence number assigned to the next enqueued request.
+	nextSeq uint64
+}
+
+type queuedRequest struct {
+	req      Request
+	priority int64
+	seq      uint64
+}
+
+func (r *userRequests) len() int {
+	return len(r.heap)
+}
+
+func (r *userRequests) enqueue(req Request) {
+	var priority int64
+	if p, ok := req.(PrioritizedRequest); ok {
+		priority = p.Priority()
+	}
+
+	heap.Push(&r.heap, queuedRequest{req: req, priority: priority, seq: r.nextSeq})
+	r.nextSeq++
+}
+
+// dequeue removes and returns the request with the highest priority. It must not be
+// called on an empty queue.
+func (r *userRequests) dequeue() Request {
+	return heap.Pop(&r.heap).(queuedRequest).req
+}
+
+type requestsHeap []queuedRequest
+
+func (h requestsHeap) Len() int { return len(h) }
+
+func (h requestsHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h requestsHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+// Push and Pop use pointer receivers because they modify the slice's length,
+// not just its contents.
+func (h *requestsHeap) Push(x interface{}) {
+	*h = append(*h, x.(queuedRequest))
+}
+
+func (h *requestsHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	old[n-1] = queuedRequest{}
+	*h = old[0 : n-1]
+	return x
+}
@@ -2,7 +2,6 @@ package scheduler
 
 import (
 	"context"
-	"errors"
 	"flag"
 	"io"
 	"net/http"
@@ -13,6 +12,7 @@ import (
 	"github.com/go-kit/kit/log/level"
 	otgrpc "github.com/opentracing-contrib/go-grpc"
 	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
@@ -21,11 +21,16 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/cortexproject/cortex/pkg/frontend/v2/frontendv2pb"
+	"github.com/cortexproject/cortex/pkg/ring"
 	"github.com/cortexproject/cortex/pkg/scheduler/queue"
+	"github.com/cortexproject/cortex/pkg/scheduler/schedulerdiscovery"
 	"github.com/cortexproject/cortex/pkg/scheduler/schedulerpb"
+	"github.com/cortexproject/cortex/pkg/tenant"
 	"github.com/cortexproject/cortex/pkg/util/grpcclient"
 	"github.com/cortexproject/cortex/pkg/util/grpcutil"
+	"github.com/cortexproject/cortex/pkg/util/querypriority"
 	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 var (
@@ -46,6 +51,10 @@ type Scheduler struct {
 
 	requestQueue *queue.RequestQueue
 
+	// Lifecycler used to register the query-scheduler in the ring, when the
+	// ring-based service discovery is enabled. Nil otherwise.
+	ringLifecycler *ring.Lifecycler
+
 	pendingRequestsMu sync.Mutex
 	pendingRequests   map[requestKey]*schedulerRequest // Request is kept in this map even after being dispatched to querier. It can still be canceled at that time.
 
@@ -73,11 +82,19 @@ type Config struct {
 	MaxOutstandingPerTenant int `yaml:"max_outstanding_requests_per_tenant"`
 
 	GRPCClientConfig grpcclient.ConfigWithTLS `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
+
+	ServiceDiscovery schedulerdiscovery.Config `yaml:",inline"`
 }
 
 func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 100, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
+	cfg.ServiceDiscovery.RegisterFlags(f)
+}
+
+// Validate the Config.
+func (cfg *Config) Validate() error {
+	return cfg.ServiceDiscovery.Validate()
 }
 
 // NewScheduler creates a new Scheduler.
@@ -111,12 +128,24 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Help: "Number of query-frontend worker clients currently connected to the query-scheduler.",
 	}, s.getConnectedFrontendClientsMetric)
 
-	s.Service = services.NewIdleService(nil, s.stopping)
+	if cfg.ServiceDiscovery.Mode == schedulerdiscovery.ModeRing {
+		lifecyclerCfg := cfg.ServiceDiscovery.SchedulerRing.ToLifecyclerConfig()
+
+		var err error
+		s.ringLifecycler, err = ring.NewLifecycler(lifecyclerCfg, ring.NewNoopFlushTransferer(), "query-scheduler", ring.QuerySchedulerRingKey, false, registerer)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to initialize query-scheduler ring lifecycler")
+		}
+	}
+
+	s.Service = services.NewIdleService(s.starting, s.stopping)
 	return s, nil
 }
 
 // Limits needed for the Query Frontend - interface used for decoupling.
 type Limits interface {
+	querypriority.Limits
+
 	// Returns max queriers to use per tenant, or 0 if shuffle sharding is disabled.
 	MaxQueriersPerUser(user string) int
 }
@@ -126,6 +155,7 @@ type schedulerRequest struct {
 	userID          string
 	queryID         uint64
 	request         *httpgrpc.HTTPRequest
+	priority        int64
 
 	enqueueTime time.Time
 
@@ -137,6 +167,11 @@ type schedulerRequest struct {
 	parentSpanContext opentracing.SpanContext
 }
 
+// Priority implements queue.PrioritizedRequest.
+func (s *schedulerRequest) Priority() int64 {
+	return s.priority
+}
+
 // This method handles connection from frontend.
 func (s *Scheduler) FrontendLoop(frontend schedulerpb.SchedulerForFrontend_FrontendLoopServer) error {
 	frontendAddress, frontendCtx, err := s.frontendConnected(frontend)
@@ -270,8 +305,15 @@ func (s *Scheduler) enqueueRequest(frontendContext context.Context, frontendAddr
 	req.queueSpan, req.ctx = opentracing.StartSpanFromContextWithTracer(ctx, tracer, "queued", opentracing.ChildOf(parentSpanContext))
 	req.enqueueTime = time.Now()
 	req.ctxCancel = cancel
+	// The user ID of a query spanning multiple tenants holds all the tenant IDs.
+	tenantIDs, err := tenant.TenantIDsFromOrgID(userID)
+	if err != nil {
+		return err
+	}
 
-	maxQueriers := s.limits.MaxQueriersPerUser(userID)
+	req.priority = querypriority.GetForTenants(msg.HttpRequest, tenantIDs, s.limits)
+
+	maxQueriers := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, s.limits.MaxQueriersPerUser)
 
 	return s.requestQueue.EnqueueRequest(userID, req, maxQueriers, func() {
 		shouldCancel = false
@@ -442,8 +484,25 @@ func (s *Scheduler) isRunningOrStopping() bool {
 }
 
 // Close the Scheduler.
+func (s *Scheduler) starting(ctx context.Context) error {
+	if s.ringLifecycler == nil {
+		return nil
+	}
+
+	// Register the query-scheduler in the ring, so that query-frontends and queriers can discover it.
+	if err := services.StartAndAwaitRunning(ctx, s.ringLifecycler); err != nil {
+		return errors.Wrap(err, "unable to start query-scheduler ring lifecycler")
+	}
+	return nil
+}
+
 func (s *Scheduler) stopping(_ error) error {
 	s.requestQueue.Stop()
+
+	if s.ringLifecycler != nil {
+		// Unregister from the ring, so that query-frontends and queriers stop using this query-scheduler.
+		return services.StopAndAwaitTerminated(context.Background(), s.ringLifecycler)
+	}
 	return nil
 }
 
@@ -18,11 +18,15 @@ import (
 	"google.golang.org/grpc"
 
 	"github.com/cortexproject/cortex/pkg/frontend/v2/frontendv2pb"
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/scheduler/schedulerdiscovery"
 	"github.com/cortexproject/cortex/pkg/scheduler/schedulerpb"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 	chunk "github.com/cortexproject/cortex/pkg/util/grpcutil"
 	"github.com/cortexproject/cortex/pkg/util/services"
 	"github.com/cortexproject/cortex/pkg/util/test"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 const testMaxOutstandingPerTenant = 5
@@ -32,7 +36,7 @@ func setupScheduler(t *testing.T) (*Scheduler, schedulerpb.SchedulerForFrontendC
 	flagext.DefaultValues(&cfg)
 	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
 
-	s, err := NewScheduler(cfg, &limits{queriers: 2}, log.NewNopLogger(), nil)
+	s, err := NewScheduler(cfg, &limits{queriers: 2, priorities: []*validation.QueryPriority{{Priority: 10, Pattern: "dashboard_query"}}}, log.NewNopLogger(), nil)
 	require.NoError(t, err)
 
 	server := grpc.NewServer()
@@ -93,6 +97,36 @@ func TestSchedulerBasicEnqueue(t *testing.T) {
 	verifyNoPendingRequestsLeft(t, scheduler)
 }
 
+func TestSchedulerShouldDequeueHigherPriorityRequestsFirst(t *testing.T) {
+	scheduler, frontendClient, querierClient := setupScheduler(t)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     1,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=batch_query"},
+	})
+	frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+		Type:        schedulerpb.ENQUEUE,
+		QueryID:     2,
+		UserID:      "test",
+		HttpRequest: &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=dashboard_query"},
+	})
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+
+	// The query matching the higher priority is dequeued first, even if enqueued later.
+	for _, expectedQueryID := range []uint64{2, 1} {
+		msg, err := querierLoop.Recv()
+		require.NoError(t, err)
+		require.Equal(t, expectedQueryID, msg.QueryID)
+		require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	}
+
+	verifyNoPendingRequestsLeft(t, scheduler)
+}
+
 func TestSchedulerEnqueueWithCancel(t *testing.T) {
 	scheduler, frontendClient, querierClient := setupScheduler(t)
 
@@ -403,6 +437,43 @@ func TestSchedulerForwardsErrorToFrontend(t *testing.T) {
 	})
 }
 
+func TestSchedulerShouldRegisterInTheRingWhenRingServiceDiscoveryIsEnabled(t *testing.T) {
+	ctx := context.Background()
+	kvStore := consul.NewInMemoryClient(ring.GetCodec())
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.ServiceDiscovery.Mode = schedulerdiscovery.ModeRing
+	cfg.ServiceDiscovery.SchedulerRing.KVStore.Mock = kvStore
+	cfg.ServiceDiscovery.SchedulerRing.InstanceID = "scheduler-1"
+	cfg.ServiceDiscovery.SchedulerRing.InstanceAddr = "1.1.1.1"
+	cfg.ServiceDiscovery.SchedulerRing.InstancePort = 9095
+
+	s, err := NewScheduler(cfg, &limits{queriers: 2}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, s))
+
+	getRingInstances := func() interface{} {
+		desc, err := kvStore.Get(ctx, ring.QuerySchedulerRingKey)
+		require.NoError(t, err)
+
+		instances := map[string]string{}
+		if desc != nil {
+			for id, instance := range desc.(*ring.Desc).Ingesters {
+				instances[id] = fmt.Sprintf("%s %s", instance.Addr, instance.State)
+			}
+		}
+		return instances
+	}
+
+	// The query-scheduler should join the ring once started.
+	test.Poll(t, time.Second, map[string]string{"scheduler-1": "1.1.1.1:9095 ACTIVE"}, getRingInstances)
+
+	// The query-scheduler should leave the ring once stopped.
+	require.NoError(t, services.StopAndAwaitTerminated(ctx, s))
+	test.Poll(t, time.Second, map[string]string{}, getRingInstances)
+}
+
 func initFrontendLoop(t *testing.T, client schedulerpb.SchedulerForFrontendClient, frontendAddr string) schedulerpb.SchedulerForFrontend_FrontendLoopClient {
 	loop, err := client.FrontendLoop(context.Background())
 	require.NoError(t, err)
@@ -457,13 +528,26 @@ func verifyNoPendingRequestsLeft(t *testing.T, scheduler *Scheduler) {
 }
 
 type limits struct {
-	queriers int
+	queriers   int
+	priorities []*validation.QueryPriority
 }
 
 func (l limits) MaxQueriersPerUser(_ string) int {
 	return l.queriers
 }
 
+func (l limits) QueryPriorities(_ string) []*validation.QueryPriority {
+	return l.priorities
+}
+
+func (l limits) DefaultQueryPriority(_ string) int64 {
+	return 0
+}
+
+func (l limits) QueryPriorityHeaderEnabled(_ string) bool {
+	return false
+}
+
 type frontendMock struct {
 	mu   sync.Mutex
 	resp map[uint64]*httpgrpc.HTTPResponse
@@ -0,0 +1,111 @@
+package schedulerdiscovery
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv"
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+// New returns a service discovering the query-schedulers and notifying the addresses
+// added and removed. When the ring mode is configured the query-schedulers are discovered
+// via the ring, checked every lookup period, otherwise the input address is periodically
+// resolved via DNS. The component is the name of the component running the discovery
+// (eg. querier), used to identify its ring client.
+func New(cfg Config, address string, lookupPeriod time.Duration, component string, notifications util.DNSNotifications, logger log.Logger, reg prometheus.Registerer) (services.Service, error) {
+	if cfg.Mode == ModeRing {
+		return newRingServiceDiscovery(cfg.SchedulerRing, lookupPeriod, component, notifications, logger, reg)
+	}
+	return util.NewDNSWatcher(address, lookupPeriod, notifications)
+}
+
+type ringServiceDiscovery struct {
+	services.Service
+
+	ringClient    *ring.Ring
+	notifications util.DNSNotifications
+	logger        log.Logger
+
+	// Addresses of the query-schedulers discovered so far.
+	discovered map[string]struct{}
+}
+
+func newRingServiceDiscovery(cfg RingConfig, ringCheckPeriod time.Duration, component string, notifications util.DNSNotifications, logger log.Logger, reg prometheus.Registerer) (*ringServiceDiscovery, error) {
+	ringCfg := cfg.ToLifecyclerConfig().RingConfig
+
+	kvClient, err := kv.NewClient(ringCfg.KVStore, ring.GetCodec(), kv.RegistererWithKVName(reg, "query-scheduler-"+component+"-ring"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize query-schedulers ring KV client")
+	}
+
+	ringClient, err := ring.NewWithStoreClientAndStrategy(ringCfg, "query-scheduler", ring.QuerySchedulerRingKey, kvClient, ring.NewDefaultReplicationStrategy(ringCfg.ExtendWrites))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to initialize query-schedulers ring client")
+	}
+
+	d := &ringServiceDiscovery{
+		ringClient:    ringClient,
+		notifications: notifications,
+		logger:        logger,
+		discovered:    map[string]struct{}{},
+	}
+
+	d.Service = services.NewTimerService(ringCheckPeriod, d.starting, d.iteration, d.stopping)
+	return d, nil
+}
+
+func (d *ringServiceDiscovery) starting(ctx context.Context) error {
+	if err := services.StartAndAwaitRunning(ctx, d.ringClient); err != nil {
+		return errors.Wrap(err, "failed to start query-schedulers ring client")
+	}
+
+	// Notify the query-schedulers already in the ring, without waiting for the first tick.
+	d.discover()
+	return nil
+}
+
+func (d *ringServiceDiscovery) iteration(_ context.Context) error {
+	d.discover()
+	return nil
+}
+
+func (d *ringServiceDiscovery) stopping(_ error) error {
+	return services.StopAndAwaitTerminated(context.Background(), d.ringClient)
+}
+
+// discover notifies the query-schedulers added to and removed from the ring since the
+// previous call. All notifications are sent on the same goroutine.
+func (d *ringServiceDiscovery) discover() {
+	rs, err := d.ringClient.GetAllHealthy(ring.QueryScheduler)
+	if err != nil && err != ring.ErrEmptyRing {
+		level.Warn(d.logger).Log("msg", "failed to discover query-schedulers from the ring", "err", err)
+		return
+	}
+
+	healthy := make(map[string]struct{}, len(rs.Ingesters))
+	for _, instance := range rs.Ingesters {
+		healthy[instance.Addr] = struct{}{}
+	}
+
+	for addr := range d.discovered {
+		if _, ok := healthy[addr]; !ok {
+			delete(d.discovered, addr)
+			d.notifications.AddressRemoved(addr)
+		}
+	}
+
+	for addr := range healthy {
+		if _, ok := d.discovered[addr]; !ok {
+			d.discovered[addr] = struct{}{}
+			d.notifications.AddressAdded(addr)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package schedulerdiscovery
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/ring"
+	"github.com/cortexproject/cortex/pkg/ring/kv/consul"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	require.NoError(t, cfg.Validate())
+
+	cfg.Mode = ModeRing
+	require.NoError(t, cfg.Validate())
+
+	cfg.Mode = "unknown"
+	require.EqualError(t, cfg.Validate(), "unsupported query-scheduler service discovery mode: unknown")
+}
+
+func TestRingServiceDiscovery_ShouldNotifyQuerySchedulersAddedAndRemoved(t *testing.T) {
+	ctx := context.Background()
+	kvStore := consul.NewInMemoryClient(ring.GetCodec())
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.Mode = ModeRing
+	cfg.SchedulerRing.KVStore.Mock = kvStore
+
+	notifications := &notificationsMock{}
+	d, err := New(cfg, "", 100*time.Millisecond, "test", notifications, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, d))
+	defer services.StopAndAwaitTerminated(ctx, d) //nolint:errcheck
+
+	// Register two query-schedulers in the ring.
+	require.NoError(t, kvStore.CAS(ctx, ring.QuerySchedulerRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := ring.NewDesc()
+		desc.AddIngester("scheduler-1", "1.1.1.1:9095", "", []uint32{1}, ring.ACTIVE, time.Now())
+		desc.AddIngester("scheduler-2", "2.2.2.2:9095", "", []uint32{2}, ring.ACTIVE, time.Now())
+		return desc, true, nil
+	}))
+
+	test.Poll(t, 2*time.Second, []string{"1.1.1.1:9095", "2.2.2.2:9095"}, func() interface{} {
+		return notifications.getAdded()
+	})
+
+	// Remove a query-scheduler from the ring, and mark the other one as not ACTIVE.
+	require.NoError(t, kvStore.CAS(ctx, ring.QuerySchedulerRingKey, func(in interface{}) (interface{}, bool, error) {
+		desc := in.(*ring.Desc)
+		desc.RemoveIngester("scheduler-1")
+
+		instance := desc.Ingesters["scheduler-2"]
+		instance.State = ring.LEAVING
+		desc.Ingesters["scheduler-2"] = instance
+		return desc, true, nil
+	}))
+
+	test.Poll(t, 2*time.Second, []string{"1.1.1.1:9095", "2.2.2.2:9095"}, func() interface{} {
+		return notifications.getRemoved()
+	})
+}
+
+type notificationsMock struct {
+	mtx     sync.Mutex
+	added   []string
+	removed []string
+}
+
+func (n *notificationsMock) AddressAdded(address string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.added = append(n.added, address)
+}
+
+func (n *notificationsMock) AddressRemoved(address string) {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	n.removed = append(n.removed, address)
+}
+
+func (n *notificationsMock) getAdded() []string {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return sortedCopy(n.added)
+}
+
+func (n *notificationsMock) getRemoved() []string {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+	return sortedCopy(n.removed)
+}
+
+func sortedCopy(values []string) []string {
+	out := append([]string(nil), values...)
+	sort.Strings(out)
+	return out
+}
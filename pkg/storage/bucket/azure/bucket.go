@@ -0,0 +1,266 @@
+package azure
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Azure/azure-pipeline-go/pipeline"
+	blob "github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+const (
+	dirDelim = "/"
+
+	// encryptionScopeServiceVersion is the first version of the Azure storage APIs supporting
+	// encryption scopes, which is newer than the one used by the Azure storage client.
+	encryptionScopeServiceVersion = "2019-07-07"
+)
+
+var errorCodeRegex = regexp.MustCompile(`X-Ms-Error-Code:\D*\[(\w+)\]`)
+
+func init() {
+	// Disable `ForceLog` in Azure storage module, because it doesn't correctly detect expected
+	// REST errors like 404 and logs them to syslog along with a stacktrace. This needs to be done
+	// at startup because the underlying variable is not thread safe.
+	// https://github.com/Azure/azure-storage-blob-go/issues/214
+	pipeline.SetForceLogEnabled(false)
+}
+
+// Bucket implements the objstore.Bucket interface against the Azure Blob Storage APIs. It's
+// based on the Thanos implementation, with support for managed identity and workload identity
+// authentication and for encryption scopes: the Thanos client builds its requests pipeline
+// internally with a shared key credential, so neither a token credential nor the encryption
+// scope header can be injected in it.
+type Bucket struct {
+	logger        log.Logger
+	containerName string
+	containerURL  blob.ContainerURL
+	maxRetries    int
+}
+
+func newBucket(ctx context.Context, logger log.Logger, containerName string, containerURL blob.ContainerURL, maxRetries int) (*Bucket, error) {
+	// Create the container if it doesn't exist yet.
+	if _, err := containerURL.GetProperties(ctx, blob.LeaseAccessConditions{}); err != nil {
+		if parseError(err.Error()) != "ContainerNotFound" {
+			return nil, errors.Wrapf(err, "cannot get Azure blob container: %s", containerName)
+		}
+
+		if _, err := containerURL.Create(ctx, blob.Metadata{}, blob.PublicAccessNone); err != nil && parseError(err.Error()) != "ContainerAlreadyExists" {
+			return nil, errors.Wrapf(err, "error creating Azure blob container: %s", containerName)
+		}
+		level.Info(logger).Log("msg", "Azure blob container successfully created", "container", containerName)
+	}
+
+	return &Bucket{
+		logger:        logger,
+		containerName: containerName,
+		containerURL:  containerURL,
+		maxRetries:    maxRetries,
+	}, nil
+}
+
+// newPipeline returns the Azure storage requests pipeline, authenticating requests with credential.
+func newPipeline(credential blob.Credential, maxRetries int, encryptionScope string) pipeline.Pipeline {
+	// Closest to API goes first; closest to the wire goes last.
+	factories := []pipeline.Factory{
+		blob.NewTelemetryPolicyFactory(blob.TelemetryOptions{Value: "Cortex"}),
+		blob.NewUniqueRequestIDPolicyFactory(),
+		blob.NewRetryPolicyFactory(blob.RetryOptions{MaxTries: int32(maxRetries)}),
+	}
+
+	// The encryption scope header must be added before the credential policy, which signs it.
+	if encryptionScope != "" {
+		factories = append(factories, newEncryptionScopePolicyFactory(encryptionScope))
+	}
+
+	factories = append(factories,
+		credential,
+		// Log a warning if an operation takes longer than the specified duration (-1 means no logging).
+		blob.NewRequestLogPolicyFactory(blob.RequestLogOptions{LogWarningIfTryOverThreshold: -1}),
+		pipeline.MethodFactoryMarker(),
+	)
+
+	return pipeline.NewPipeline(factories, pipeline.Options{})
+}
+
+// newEncryptionScopePolicyFactory returns a policy setting the encryption scope of the objects
+// written by PUT requests (Put Blob, Put Block and Put Block List).
+func newEncryptionScopePolicyFactory(encryptionScope string) pipeline.Factory {
+	return pipeline.FactoryFunc(func(next pipeline.Policy, _ *pipeline.PolicyOptions) pipeline.PolicyFunc {
+		return func(ctx context.Context, req pipeline.Request) (pipeline.Response, error) {
+			if req.Method == http.MethodPut {
+				req.Header.Set("x-ms-version", encryptionScopeServiceVersion)
+				req.Header.Set("x-ms-encryption-scope", encryptionScope)
+			}
+			return next.Do(ctx, req)
+		}
+	})
+}
+
+// Iter calls f for each entry in the given directory. The argument to f is the full
+// object name including the prefix of the inspected directory.
+func (b *Bucket) Iter(ctx context.Context, dir string, f func(string) error) error {
+	prefix := dir
+	if prefix != "" && !strings.HasSuffix(prefix, dirDelim) {
+		prefix += dirDelim
+	}
+
+	marker := blob.Marker{}
+
+	for i := 1; ; i++ {
+		list, err := b.containerURL.ListBlobsHierarchySegment(ctx, marker, dirDelim, blob.ListBlobsSegmentOptions{
+			Prefix: prefix,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "cannot list blobs in directory %s (iteration #%d)", dir, i)
+		}
+
+		marker = list.NextMarker
+
+		var listNames []string
+		for _, blob := range list.Segment.BlobItems {
+			listNames = append(listNames, blob.Name)
+		}
+		for _, blobPrefix := range list.Segment.BlobPrefixes {
+			listNames = append(listNames, blobPrefix.Name)
+		}
+
+		for _, name := range listNames {
+			if err := f(name); err != nil {
+				return err
+			}
+		}
+
+		// Continue iterating if we are not done.
+		if !marker.NotDone() {
+			break
+		}
+	}
+
+	return nil
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found. Relevant to Get operations.
+func (b *Bucket) IsObjNotFoundErr(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	errorCode := parseError(err.Error())
+	return errorCode == "InvalidUri" || errorCode == "BlobNotFound"
+}
+
+func (b *Bucket) getBlobReader(ctx context.Context, name string, offset, length int64) (io.ReadCloser, error) {
+	if len(name) == 0 {
+		return nil, errors.New("X-Ms-Error-Code: [EmptyContainerName]")
+	}
+
+	blobURL := b.containerURL.NewBlockBlobURL(name)
+	props, err := blobURL.GetProperties(ctx, blob.BlobAccessConditions{})
+	if err != nil {
+		if b.IsObjNotFoundErr(err) {
+			return nil, errors.New("X-Ms-Error-Code: [BlobNotFound]")
+		}
+		return nil, errors.Wrapf(err, "cannot get properties for Azure blob, address: %s", name)
+	}
+
+	// If a length is specified and it won't go past the end of the file, then set it as the size.
+	size := props.ContentLength() - offset
+	if length > 0 && length <= size {
+		size = length
+	}
+
+	destBuffer := make([]byte, size)
+	if err := blob.DownloadBlobToBuffer(ctx, blobURL.BlobURL, offset, size, destBuffer, blob.DownloadFromBlobOptions{
+		BlockSize:   blob.BlobDefaultDownloadBlockSize,
+		Parallelism: uint16(3),
+		RetryReaderOptionsPerBlock: blob.RetryReaderOptions{
+			MaxRetryRequests: b.maxRetries,
+		},
+	}); err != nil {
+		return nil, errors.Wrapf(err, "cannot download blob, address: %s", name)
+	}
+
+	return ioutil.NopCloser(bytes.NewReader(destBuffer)), nil
+}
+
+// Get returns a reader for the given object name.
+func (b *Bucket) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.getBlobReader(ctx, name, 0, blob.CountToEnd)
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *Bucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	return b.getBlobReader(ctx, name, off, length)
+}
+
+// Attributes returns information about the specified object.
+func (b *Bucket) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	props, err := b.containerURL.NewBlockBlobURL(name).GetProperties(ctx, blob.BlobAccessConditions{})
+	if err != nil {
+		return objstore.ObjectAttributes{}, err
+	}
+
+	return objstore.ObjectAttributes{
+		Size:         props.ContentLength(),
+		LastModified: props.LastModified(),
+	}, nil
+}
+
+// Exists checks if the given object exists.
+func (b *Bucket) Exists(ctx context.Context, name string) (bool, error) {
+	if _, err := b.containerURL.NewBlockBlobURL(name).GetProperties(ctx, blob.BlobAccessConditions{}); err != nil {
+		if b.IsObjNotFoundErr(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "cannot get properties for Azure blob, address: %s", name)
+	}
+
+	return true, nil
+}
+
+// Upload the contents of the reader as an object into the bucket.
+func (b *Bucket) Upload(ctx context.Context, name string, r io.Reader) error {
+	if _, err := blob.UploadStreamToBlockBlob(ctx, r, b.containerURL.NewBlockBlobURL(name), blob.UploadStreamToBlockBlobOptions{
+		BufferSize: 3 * 1024 * 1024,
+		MaxBuffers: 4,
+	}); err != nil {
+		return errors.Wrapf(err, "cannot upload Azure blob, address: %s", name)
+	}
+	return nil
+}
+
+// Delete removes the object with the given name.
+func (b *Bucket) Delete(ctx context.Context, name string) error {
+	if _, err := b.containerURL.NewBlockBlobURL(name).Delete(ctx, blob.DeleteSnapshotsOptionInclude, blob.BlobAccessConditions{}); err != nil {
+		return errors.Wrapf(err, "error deleting blob, address: %s", name)
+	}
+	return nil
+}
+
+// Name returns Azure container name.
+func (b *Bucket) Name() string {
+	return b.containerName
+}
+
+// Close bucket.
+func (b *Bucket) Close() error {
+	return nil
+}
+
+func parseError(errorCode string) string {
+	match := errorCodeRegex.FindStringSubmatch(errorCode)
+	if len(match) == 2 {
+		return match[1]
+	}
+	return errorCode
+}
@@ -1,27 +1,53 @@
 package azure
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+
+	blob "github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
 	"github.com/thanos-io/thanos/pkg/objstore"
-	"github.com/thanos-io/thanos/pkg/objstore/azure"
-	yaml "gopkg.in/yaml.v2"
 )
 
-func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
-	bucketConfig := azure.Config{
-		StorageAccountName: cfg.StorageAccountName,
-		StorageAccountKey:  cfg.StorageAccountKey.Value,
-		ContainerName:      cfg.ContainerName,
-		Endpoint:           cfg.Endpoint,
-		MaxRetries:         cfg.MaxRetries,
+const defaultEndpoint = "blob.core.windows.net"
+
+func NewBucketClient(ctx context.Context, cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
 	}
 
-	// Thanos currently doesn't support passing the config as is, but expects a YAML,
-	// so we're going to serialize it.
-	serialized, err := yaml.Marshal(bucketConfig)
+	credential, err := newCredential(ctx, cfg, logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "create Azure credential")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	u, err := url.Parse(fmt.Sprintf("https://%s.%s", cfg.StorageAccountName, endpoint))
 	if err != nil {
 		return nil, err
 	}
 
-	return azure.NewBucket(logger, serialized, name)
+	service := blob.NewServiceURL(*u, newPipeline(credential, cfg.MaxRetries, cfg.EncryptionScope))
+	return newBucket(ctx, logger, cfg.ContainerName, service.NewContainerURL(cfg.ContainerName), cfg.MaxRetries)
+}
+
+func newCredential(ctx context.Context, cfg Config, logger log.Logger) (blob.Credential, error) {
+	switch {
+	case cfg.UseManagedIdentity:
+		return newTokenCredential(ctx, newManagedIdentityTokenProvider(cfg.UserAssignedID), logger)
+	case cfg.UseWorkloadIdentity:
+		p, err := newWorkloadIdentityTokenProvider(cfg.UserAssignedID)
+		if err != nil {
+			return nil, err
+		}
+		return newTokenCredential(ctx, p, logger)
+	default:
+		return blob.NewSharedKeyCredential(cfg.StorageAccountName, cfg.StorageAccountKey.Value)
+	}
 }
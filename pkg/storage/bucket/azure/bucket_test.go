@@ -0,0 +1,73 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	blob "github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucket_UploadWithEncryptionScope(t *testing.T) {
+	tests := map[string]struct {
+		encryptionScope string
+		expectedVersion string
+	}{
+		"without encryption scope": {
+			encryptionScope: "",
+			expectedVersion: blob.ServiceVersion,
+		},
+		"with encryption scope": {
+			encryptionScope: "scope",
+			expectedVersion: encryptionScopeServiceVersion,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				mtx     sync.Mutex
+				headers []http.Header
+			)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mtx.Lock()
+				defer mtx.Unlock()
+
+				// The container already exists.
+				if r.Method == http.MethodGet {
+					return
+				}
+
+				assert.Equal(t, http.MethodPut, r.Method)
+				assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:"))
+				headers = append(headers, r.Header)
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+
+			credential, err := blob.NewSharedKeyCredential("account", "a2V5")
+			require.NoError(t, err)
+
+			u, err := url.Parse(server.URL)
+			require.NoError(t, err)
+
+			service := blob.NewServiceURL(*u, newPipeline(credential, 1, testData.encryptionScope))
+			bkt, err := newBucket(context.Background(), log.NewNopLogger(), "container", service.NewContainerURL("container"), 1)
+			require.NoError(t, err)
+
+			require.NoError(t, bkt.Upload(context.Background(), "object", strings.NewReader("content")))
+
+			require.Len(t, headers, 1)
+			assert.Equal(t, testData.expectedVersion, headers[0].Get("x-ms-version"))
+			assert.Equal(t, testData.encryptionScope, headers[0].Get("x-ms-encryption-scope"))
+		})
+	}
+}
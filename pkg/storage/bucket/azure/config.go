@@ -1,18 +1,32 @@
 package azure
 
 import (
+	"errors"
 	"flag"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
+var (
+	errMultipleAuthMethods     = errors.New("Azure managed identity and workload identity authentication can't be enabled at the same time")
+	errAccountKeyWithIdentity  = errors.New("the Azure storage account key must not be set when authenticating via managed identity or workload identity")
+	errMissingAccountKey       = errors.New("the Azure storage account key is required when authenticating via account key")
+	errMissingStorageAccount   = errors.New("no Azure storage account name specified")
+	errMissingContainerName    = errors.New("no Azure storage container name specified")
+	errNegativeMaxRetriesValue = errors.New("the Azure max retries must be greater than or equal to 0")
+)
+
 // Config holds the config options for an Azure backend
 type Config struct {
-	StorageAccountName string         `yaml:"account_name"`
-	StorageAccountKey  flagext.Secret `yaml:"account_key"`
-	ContainerName      string         `yaml:"container_name"`
-	Endpoint           string         `yaml:"endpoint_suffix"`
-	MaxRetries         int            `yaml:"max_retries"`
+	StorageAccountName  string         `yaml:"account_name"`
+	StorageAccountKey   flagext.Secret `yaml:"account_key"`
+	ContainerName       string         `yaml:"container_name"`
+	Endpoint            string         `yaml:"endpoint_suffix"`
+	MaxRetries          int            `yaml:"max_retries"`
+	UseManagedIdentity  bool           `yaml:"use_managed_identity"`
+	UseWorkloadIdentity bool           `yaml:"use_workload_identity"`
+	UserAssignedID      string         `yaml:"user_assigned_id"`
+	EncryptionScope     string         `yaml:"encryption_scope"`
 }
 
 // RegisterFlags registers the flags for Azure storage
@@ -27,4 +41,35 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.StringVar(&cfg.ContainerName, prefix+"azure.container-name", "", "Azure storage container name")
 	f.StringVar(&cfg.Endpoint, prefix+"azure.endpoint-suffix", "", "Azure storage endpoint suffix without schema. The account name will be prefixed to this value to create the FQDN")
 	f.IntVar(&cfg.MaxRetries, prefix+"azure.max-retries", 20, "Number of retries for recoverable errors")
+	f.BoolVar(&cfg.UseManagedIdentity, prefix+"azure.use-managed-identity", false, "Authenticate via the Azure managed identity of the VM or pod instead of the storage account key. The access token is fetched from the Azure Instance Metadata Service.")
+	f.BoolVar(&cfg.UseWorkloadIdentity, prefix+"azure.use-workload-identity", false, "Authenticate via Azure AD workload identity instead of the storage account key. The tenant ID, client ID and federated token file are read from the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE environment variables.")
+	f.StringVar(&cfg.UserAssignedID, prefix+"azure.user-assigned-id", "", "Client ID of the user-assigned identity to authenticate with, when using managed identity or workload identity. If empty, the system-assigned managed identity (or the AZURE_CLIENT_ID environment variable for workload identity) is used.")
+	f.StringVar(&cfg.EncryptionScope, prefix+"azure.encryption-scope", "", "Name of the encryption scope used to encrypt the uploaded objects, ie. to encrypt them with a customer-managed key. If empty, the default encryption scope of the container is used.")
+}
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	if cfg.StorageAccountName == "" {
+		return errMissingStorageAccount
+	}
+	if cfg.ContainerName == "" {
+		return errMissingContainerName
+	}
+	if cfg.UseManagedIdentity && cfg.UseWorkloadIdentity {
+		return errMultipleAuthMethods
+	}
+	if cfg.useIdentity() && cfg.StorageAccountKey.Value != "" {
+		return errAccountKeyWithIdentity
+	}
+	if !cfg.useIdentity() && cfg.StorageAccountKey.Value == "" {
+		return errMissingAccountKey
+	}
+	if cfg.MaxRetries < 0 {
+		return errNegativeMaxRetriesValue
+	}
+	return nil
+}
+
+func (cfg *Config) useIdentity() bool {
+	return cfg.UseManagedIdentity || cfg.UseWorkloadIdentity
 }
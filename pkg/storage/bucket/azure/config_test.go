@@ -0,0 +1,59 @@
+package azure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg      Config
+		expected error
+	}{
+		"account key authentication": {
+			cfg:      Config{StorageAccountName: "account", StorageAccountKey: flagext.Secret{Value: "key"}, ContainerName: "container"},
+			expected: nil,
+		},
+		"managed identity authentication": {
+			cfg:      Config{StorageAccountName: "account", ContainerName: "container", UseManagedIdentity: true},
+			expected: nil,
+		},
+		"workload identity authentication": {
+			cfg:      Config{StorageAccountName: "account", ContainerName: "container", UseWorkloadIdentity: true, UserAssignedID: "client-id"},
+			expected: nil,
+		},
+		"missing storage account name": {
+			cfg:      Config{StorageAccountKey: flagext.Secret{Value: "key"}, ContainerName: "container"},
+			expected: errMissingStorageAccount,
+		},
+		"missing container name": {
+			cfg:      Config{StorageAccountName: "account", StorageAccountKey: flagext.Secret{Value: "key"}},
+			expected: errMissingContainerName,
+		},
+		"missing account key": {
+			cfg:      Config{StorageAccountName: "account", ContainerName: "container"},
+			expected: errMissingAccountKey,
+		},
+		"account key with managed identity": {
+			cfg:      Config{StorageAccountName: "account", StorageAccountKey: flagext.Secret{Value: "key"}, ContainerName: "container", UseManagedIdentity: true},
+			expected: errAccountKeyWithIdentity,
+		},
+		"managed identity and workload identity": {
+			cfg:      Config{StorageAccountName: "account", ContainerName: "container", UseManagedIdentity: true, UseWorkloadIdentity: true},
+			expected: errMultipleAuthMethods,
+		},
+		"negative max retries": {
+			cfg:      Config{StorageAccountName: "account", StorageAccountKey: flagext.Secret{Value: "key"}, ContainerName: "container", MaxRetries: -1},
+			expected: errNegativeMaxRetriesValue,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, testData.cfg.Validate())
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	blob "github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// storageResource is the Azure AD resource of the Azure storage APIs.
+	storageResource = "https://storage.azure.com/"
+
+	defaultIMDSEndpoint  = "http://169.254.169.254/metadata/identity/oauth2/token"
+	defaultAuthorityHost = "https://login.microsoftonline.com/"
+
+	// The access token is refreshed this long before it expires.
+	tokenRefreshMargin = 5 * time.Minute
+
+	// How long to wait before retrying to refresh the access token after a failure.
+	tokenRefreshRetryDelay = 30 * time.Second
+
+	tokenRequestTimeout = 30 * time.Second
+)
+
+// tokenProvider fetches Azure AD access tokens for the Azure storage APIs.
+type tokenProvider interface {
+	fetchToken(ctx context.Context) (token string, expiresOn time.Time, err error)
+}
+
+// managedIdentityTokenProvider fetches the access token of a managed identity from the Azure Instance Metadata Service.
+type managedIdentityTokenProvider struct {
+	endpoint string
+	clientID string
+	client   *http.Client
+}
+
+func newManagedIdentityTokenProvider(clientID string) *managedIdentityTokenProvider {
+	return &managedIdentityTokenProvider{
+		endpoint: defaultIMDSEndpoint,
+		clientID: clientID,
+		client:   &http.Client{Timeout: tokenRequestTimeout},
+	}
+}
+
+func (p *managedIdentityTokenProvider) fetchToken(ctx context.Context) (string, time.Time, error) {
+	params := url.Values{}
+	params.Set("api-version", "2018-02-01")
+	params.Set("resource", storageResource)
+	if p.clientID != "" {
+		params.Set("client_id", p.clientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+"?"+params.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Metadata", "true")
+
+	return doTokenRequest(p.client, req)
+}
+
+// workloadIdentityTokenProvider exchanges the federated token of a Kubernetes service account for an
+// Azure AD access token, as configured by the Azure AD workload identity webhook.
+type workloadIdentityTokenProvider struct {
+	authorityHost string
+	tenantID      string
+	clientID      string
+	tokenFile     string
+	client        *http.Client
+}
+
+func newWorkloadIdentityTokenProvider(clientID string) (*workloadIdentityTokenProvider, error) {
+	p := &workloadIdentityTokenProvider{
+		authorityHost: os.Getenv("AZURE_AUTHORITY_HOST"),
+		tenantID:      os.Getenv("AZURE_TENANT_ID"),
+		clientID:      clientID,
+		tokenFile:     os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		client:        &http.Client{Timeout: tokenRequestTimeout},
+	}
+
+	if p.authorityHost == "" {
+		p.authorityHost = defaultAuthorityHost
+	}
+	if p.clientID == "" {
+		p.clientID = os.Getenv("AZURE_CLIENT_ID")
+	}
+
+	if p.tenantID == "" || p.clientID == "" || p.tokenFile == "" {
+		return nil, errors.New("Azure workload identity requires the AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_FEDERATED_TOKEN_FILE environment variables to be set")
+	}
+
+	return p, nil
+}
+
+func (p *workloadIdentityTokenProvider) fetchToken(ctx context.Context) (string, time.Time, error) {
+	// The federated token is periodically rotated, so it's read on every request.
+	assertion, err := ioutil.ReadFile(p.tokenFile)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "read federated token file")
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", p.clientID)
+	form.Set("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+	form.Set("client_assertion", strings.TrimSpace(string(assertion)))
+	form.Set("scope", storageResource+".default")
+
+	endpoint := fmt.Sprintf("%s/%s/oauth2/v2.0/token", strings.TrimSuffix(p.authorityHost, "/"), p.tenantID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return doTokenRequest(p.client, req)
+}
+
+// tokenResponse is the response of both the Azure Instance Metadata Service and the Azure AD token endpoint.
+// The expiration fields are encoded as strings by the former and as numbers by the latter.
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	ExpiresIn   json.Number `json:"expires_in"`
+	ExpiresOn   json.Number `json:"expires_on"`
+}
+
+func doTokenRequest(client *http.Client, req *http.Request) (string, time.Time, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "request Azure access token")
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "read Azure access token response")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, errors.Errorf("request Azure access token failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", time.Time{}, errors.Wrap(err, "decode Azure access token response")
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, errors.New("no access token in the Azure access token response")
+	}
+
+	return tr.AccessToken, tr.expiresAt(time.Now()), nil
+}
+
+func (tr tokenResponse) expiresAt(now time.Time) time.Time {
+	if secs, err := strconv.ParseInt(tr.ExpiresOn.String(), 10, 64); err == nil {
+		return time.Unix(secs, 0)
+	}
+	if secs, err := strconv.ParseInt(tr.ExpiresIn.String(), 10, 64); err == nil {
+		return now.Add(time.Duration(secs) * time.Second)
+	}
+
+	// Access tokens are valid for at least 1 hour, so be conservative if the expiration is unknown.
+	return now.Add(time.Hour)
+}
+
+// newTokenCredential returns a credential authenticating with the access tokens fetched from p,
+// which are refreshed in the background before they expire.
+func newTokenCredential(ctx context.Context, p tokenProvider, logger log.Logger) (blob.TokenCredential, error) {
+	// Fetch the first token synchronously, so that a misconfiguration is detected at startup.
+	token, expiresOn, err := p.fetchToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	refresh := newTokenRefresher(p, logger)
+	initialized := false
+
+	return blob.NewTokenCredential(token, func(c blob.TokenCredential) time.Duration {
+		// The refresher is immediately called on creation, when the token has just been fetched.
+		if !initialized {
+			initialized = true
+			return tokenRefreshDelay(expiresOn, time.Now())
+		}
+		return refresh(c)
+	}), nil
+}
+
+// newTokenRefresher returns a refresher setting a new access token fetched from p to the credential,
+// and returning the delay before the next refresh. On failure, the current token is kept and the
+// refresh is retried after tokenRefreshRetryDelay.
+func newTokenRefresher(p tokenProvider, logger log.Logger) blob.TokenRefresher {
+	return func(c blob.TokenCredential) time.Duration {
+		ctx, cancel := context.WithTimeout(context.Background(), tokenRequestTimeout)
+		defer cancel()
+
+		token, expiresOn, err := p.fetchToken(ctx)
+		if err != nil {
+			level.Warn(logger).Log("msg", "failed to refresh Azure access token", "err", err)
+			return tokenRefreshRetryDelay
+		}
+
+		c.SetToken(token)
+		return tokenRefreshDelay(expiresOn, time.Now())
+	}
+}
+
+func tokenRefreshDelay(expiresOn, now time.Time) time.Duration {
+	if delay := expiresOn.Sub(now) - tokenRefreshMargin; delay > tokenRefreshRetryDelay {
+		return delay
+	}
+	return tokenRefreshRetryDelay
+}
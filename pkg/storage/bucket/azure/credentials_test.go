@@ -0,0 +1,243 @@
+package azure
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	blob "github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagedIdentityTokenProvider(t *testing.T) {
+	expiresOn := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.Header.Get("Metadata"))
+		assert.Equal(t, storageResource, r.URL.Query().Get("resource"))
+		assert.Equal(t, "client-id", r.URL.Query().Get("client_id"))
+
+		// The Azure Instance Metadata Service encodes the expiration as a string.
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":"3599","expires_on":"` + strconv.FormatInt(expiresOn.Unix(), 10) + `"}`))
+	}))
+	defer server.Close()
+
+	p := newManagedIdentityTokenProvider("client-id")
+	p.endpoint = server.URL
+
+	token, actualExpiresOn, err := p.fetchToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token", token)
+	assert.True(t, expiresOn.Equal(actualExpiresOn))
+}
+
+func TestManagedIdentityTokenProvider_ShouldReturnErrorOnFailedRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "identity not found", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	p := newManagedIdentityTokenProvider("")
+	p.endpoint = server.URL
+
+	_, _, err := p.fetchToken(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed with status 400: identity not found")
+}
+
+func TestManagedIdentityTokenProvider_ShouldReturnErrorOnInvalidResponse(t *testing.T) {
+	tests := map[string]string{
+		"malformed response":       `{"access_token":`,
+		"response without a token": `{"expires_in":"3599"}`,
+	}
+
+	for testName, response := range tests {
+		t.Run(testName, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(response))
+			}))
+			defer server.Close()
+
+			p := newManagedIdentityTokenProvider("")
+			p.endpoint = server.URL
+
+			_, _, err := p.fetchToken(context.Background())
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestManagedIdentityTokenProvider_ShouldReturnErrorIfUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	p := newManagedIdentityTokenProvider("")
+	p.endpoint = server.URL
+
+	_, _, err := p.fetchToken(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "request Azure access token")
+}
+
+func TestNewTokenCredential(t *testing.T) {
+	imds := newFakeIMDS()
+	server := httptest.NewServer(imds)
+	defer server.Close()
+
+	p := newManagedIdentityTokenProvider("")
+	p.endpoint = server.URL
+
+	t.Run("should fail if the first token can't be fetched", func(t *testing.T) {
+		imds.setResponse(http.StatusBadRequest, "", time.Time{})
+
+		_, err := newTokenCredential(context.Background(), p, log.NewNopLogger())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed with status 400")
+	})
+
+	t.Run("should not fetch the token again on creation", func(t *testing.T) {
+		imds.setResponse(http.StatusOK, "token-1", time.Now().Add(time.Hour))
+		requests := imds.requestsCount()
+
+		c, err := newTokenCredential(context.Background(), p, log.NewNopLogger())
+		require.NoError(t, err)
+		assert.Equal(t, "token-1", c.Token())
+		assert.Equal(t, requests+1, imds.requestsCount())
+	})
+}
+
+func TestTokenRefresher(t *testing.T) {
+	imds := newFakeIMDS()
+	server := httptest.NewServer(imds)
+	defer server.Close()
+
+	p := newManagedIdentityTokenProvider("")
+	p.endpoint = server.URL
+
+	refresh := newTokenRefresher(p, log.NewNopLogger())
+	c := blob.NewTokenCredential("token-1", nil)
+
+	// The token is refreshed before it expires.
+	imds.setResponse(http.StatusOK, "token-2", time.Now().Add(time.Hour))
+	delay := refresh(c)
+	assert.Equal(t, "token-2", c.Token())
+	assert.InDelta(t, float64(55*time.Minute), float64(delay), float64(time.Minute))
+
+	// On failure, the current token is kept and the refresh is retried.
+	imds.setResponse(http.StatusInternalServerError, "", time.Time{})
+	assert.Equal(t, tokenRefreshRetryDelay, refresh(c))
+	assert.Equal(t, "token-2", c.Token())
+
+	// A token expiring soon is refreshed again after the retry delay.
+	imds.setResponse(http.StatusOK, "token-3", time.Now().Add(time.Minute))
+	assert.Equal(t, tokenRefreshRetryDelay, refresh(c))
+	assert.Equal(t, "token-3", c.Token())
+
+	assert.Equal(t, 3, imds.requestsCount())
+}
+
+func TestWorkloadIdentityTokenProvider(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "azure")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	tokenFile := filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(tokenFile, []byte("federated-token\n"), 0600))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/tenant-id/oauth2/v2.0/token", r.URL.Path)
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client-id", r.PostForm.Get("client_id"))
+		assert.Equal(t, "federated-token", r.PostForm.Get("client_assertion"))
+		assert.Equal(t, "https://storage.azure.com/.default", r.PostForm.Get("scope"))
+
+		// Azure AD encodes the expiration as a number.
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	}))
+	defer server.Close()
+
+	for name, value := range map[string]string{
+		"AZURE_AUTHORITY_HOST":       server.URL + "/",
+		"AZURE_TENANT_ID":            "tenant-id",
+		"AZURE_CLIENT_ID":            "client-id",
+		"AZURE_FEDERATED_TOKEN_FILE": tokenFile,
+	} {
+		require.NoError(t, os.Setenv(name, value))
+		defer os.Unsetenv(name) //nolint:errcheck
+	}
+
+	p, err := newWorkloadIdentityTokenProvider("")
+	require.NoError(t, err)
+
+	before := time.Now()
+	token, expiresOn, err := p.fetchToken(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "token", token)
+	assert.False(t, expiresOn.Before(before.Add(time.Hour)))
+}
+
+func TestWorkloadIdentityTokenProvider_ShouldRequireEnvironmentVariables(t *testing.T) {
+	_, err := newWorkloadIdentityTokenProvider("client-id")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "AZURE_FEDERATED_TOKEN_FILE")
+}
+
+func TestTokenRefreshDelay(t *testing.T) {
+	now := time.Now()
+
+	assert.Equal(t, 55*time.Minute, tokenRefreshDelay(now.Add(time.Hour), now))
+	assert.Equal(t, tokenRefreshRetryDelay, tokenRefreshDelay(now.Add(time.Minute), now))
+	assert.Equal(t, tokenRefreshRetryDelay, tokenRefreshDelay(now.Add(-time.Minute), now))
+}
+
+// fakeIMDS is a fake Azure Instance Metadata Service, serving the configured token response.
+type fakeIMDS struct {
+	mtx       sync.Mutex
+	status    int
+	token     string
+	expiresOn time.Time
+	requests  int
+}
+
+func newFakeIMDS() *fakeIMDS {
+	return &fakeIMDS{status: http.StatusOK}
+}
+
+func (f *fakeIMDS) setResponse(status int, token string, expiresOn time.Time) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.status, f.token, f.expiresOn = status, token, expiresOn
+}
+
+func (f *fakeIMDS) requestsCount() int {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	return f.requests
+}
+
+func (f *fakeIMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.requests++
+	if r.Header.Get("Metadata") != "true" {
+		http.Error(w, "missing Metadata header", http.StatusBadRequest)
+		return
+	}
+	if f.status != http.StatusOK {
+		http.Error(w, http.StatusText(f.status), f.status)
+		return
+	}
+	_, _ = w.Write([]byte(`{"access_token":"` + f.token + `","expires_on":"` + strconv.FormatInt(f.expiresOn.Unix(), 10) + `"}`))
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/cortexproject/cortex/pkg/storage/bucket/azure"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/gcs"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/oss"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/s3"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/swift"
 	"github.com/cortexproject/cortex/pkg/util"
@@ -32,14 +33,29 @@ const (
 	// Swift is the value for the Openstack Swift storage backend.
 	Swift = "swift"
 
+	// OSS is the value for the Alibaba Cloud OSS storage backend.
+	OSS = "oss"
+
 	// Filesystem is the value for the filesystem storage backend.
 	Filesystem = "filesystem"
 )
 
+// Names of the bucket operations, used in the metrics labels.
+const (
+	opUpload     = "upload"
+	opDelete     = "delete"
+	opIter       = "iter"
+	opGet        = "get"
+	opGetRange   = "get_range"
+	opExists     = "exists"
+	opAttributes = "attributes"
+)
+
 var (
-	supportedBackends = []string{S3, GCS, Azure, Swift, Filesystem}
+	supportedBackends = []string{S3, GCS, Azure, Swift, OSS, Filesystem}
 
 	ErrUnsupportedStorageBackend = errors.New("unsupported storage backend")
+	ErrInvalidStoragePrefix      = errors.New("invalid storage prefix: it must not start or end with a slash, or contain empty, '.' or '..' path segments")
 )
 
 // Config holds configuration for accessing long-term storage.
@@ -50,8 +66,13 @@ type Config struct {
 	GCS        gcs.Config        `yaml:"gcs"`
 	Azure      azure.Config      `yaml:"azure"`
 	Swift      swift.Config      `yaml:"swift"`
+	OSS        oss.Config        `yaml:"oss"`
 	Filesystem filesystem.Config `yaml:"filesystem"`
 
+	StoragePrefix string          `yaml:"storage_prefix"`
+	Hedging       HedgingConfig   `yaml:"hedging"`
+	RateLimit     RateLimitConfig `yaml:"rate_limit"`
+
 	// Not used internally, meant to allow callers to wrap Buckets
 	// created using this config
 	Middlewares []func(objstore.Bucket) (objstore.Bucket, error) `yaml:"-"`
@@ -67,9 +88,13 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	cfg.GCS.RegisterFlagsWithPrefix(prefix, f)
 	cfg.Azure.RegisterFlagsWithPrefix(prefix, f)
 	cfg.Swift.RegisterFlagsWithPrefix(prefix, f)
+	cfg.OSS.RegisterFlagsWithPrefix(prefix, f)
 	cfg.Filesystem.RegisterFlagsWithPrefix(prefix, f)
+	cfg.Hedging.RegisterFlagsWithPrefix(prefix, f)
+	cfg.RateLimit.RegisterFlagsWithPrefix(prefix, f)
 
 	f.StringVar(&cfg.Backend, prefix+"backend", "s3", fmt.Sprintf("Backend storage to use. Supported backends are: %s.", strings.Join(supportedBackends, ", ")))
+	f.StringVar(&cfg.StoragePrefix, prefix+"storage-prefix", "", "Prefix under which all the objects are stored in the bucket. It allows multiple Cortex clusters, or the blocks, ruler and alertmanager storages, to share the same bucket. Empty means objects are stored at the root of the bucket.")
 }
 
 func (cfg *Config) Validate() error {
@@ -77,12 +102,32 @@ func (cfg *Config) Validate() error {
 		return ErrUnsupportedStorageBackend
 	}
 
+	if !isValidStoragePrefix(cfg.StoragePrefix) {
+		return ErrInvalidStoragePrefix
+	}
+
+	if err := cfg.Hedging.Validate(); err != nil {
+		return err
+	}
+
 	if cfg.Backend == S3 {
 		if err := cfg.S3.Validate(); err != nil {
 			return err
 		}
 	}
 
+	if cfg.Backend == Azure {
+		if err := cfg.Azure.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if cfg.Backend == OSS {
+		if err := cfg.OSS.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -94,9 +139,11 @@ func NewClient(ctx context.Context, cfg Config, name string, logger log.Logger,
 	case GCS:
 		client, err = gcs.NewBucketClient(ctx, cfg.GCS, name, logger)
 	case Azure:
-		client, err = azure.NewBucketClient(cfg.Azure, name, logger)
+		client, err = azure.NewBucketClient(ctx, cfg.Azure, name, logger)
 	case Swift:
 		client, err = swift.NewBucketClient(cfg.Swift, name, logger)
+	case OSS:
+		client, err = oss.NewBucketClient(cfg.OSS, name, logger)
 	case Filesystem:
 		client, err = filesystem.NewBucketClient(cfg.Filesystem)
 	default:
@@ -107,7 +154,20 @@ func NewClient(ctx context.Context, cfg Config, name string, logger log.Logger,
 		return nil, err
 	}
 
-	client = objstore.NewTracingBucket(bucketWithMetrics(client, name, reg))
+	// The rate limits are enforced before hedging, so that the hedged requests are rate limited too.
+	if cfg.RateLimit.Enabled() {
+		client = NewRateLimitedBucketClient(client, cfg.RateLimit, componentRegisterer(name, reg))
+	}
+
+	if cfg.Hedging.Enabled() {
+		client = NewHedgedBucketClient(client, cfg.Hedging, componentRegisterer(name, reg))
+	}
+
+	if cfg.StoragePrefix != "" {
+		client = NewPrefixedBucketClient(client, cfg.StoragePrefix)
+	}
+
+	client = NewTracingBucketClient(bucketWithMetrics(client, name, reg))
 
 	// Wrap the client with any provided middleware
 	for _, wrap := range cfg.Middlewares {
@@ -128,5 +188,27 @@ func bucketWithMetrics(bucketClient objstore.Bucket, name string, reg prometheus
 	return objstore.BucketWithMetrics(
 		"", // bucket label value
 		bucketClient,
-		prometheus.WrapRegistererWith(prometheus.Labels{"component": name}, reg))
+		componentRegisterer(name, reg))
+}
+
+func componentRegisterer(name string, reg prometheus.Registerer) prometheus.Registerer {
+	if reg == nil {
+		return nil
+	}
+
+	return prometheus.WrapRegistererWith(prometheus.Labels{"component": name}, reg)
+}
+
+func isValidStoragePrefix(prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+
+	for _, segment := range strings.Split(prefix, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
 }
@@ -92,6 +92,14 @@ func (m *ClientMock) MockGet(name, content string, err error) {
 	}
 }
 
+func (m *ClientMock) MockUpload(name string, err error) {
+	m.On("Upload", mock.Anything, name, mock.Anything).Return(err)
+}
+
+func (m *ClientMock) MockAttributes(name string, attrs objstore.ObjectAttributes, err error) {
+	m.On("Attributes", mock.Anything, name).Return(attrs, err)
+}
+
 func (m *ClientMock) MockDelete(name string, err error) {
 	m.On("Delete", mock.Anything, name).Return(err)
 }
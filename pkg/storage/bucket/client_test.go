@@ -1,7 +1,11 @@
 package bucket
 
 import (
+	"bytes"
 	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -42,6 +46,15 @@ gcs:
     }
 `
 
+	configWithOSSBackend = `
+backend: oss
+oss:
+  endpoint:          oss-cn-hangzhou.aliyuncs.com
+  bucket_name:       test
+  access_key_id:     xxx
+  access_key_secret: yyy
+`
+
 	configWithUnknownBackend = `
 backend: unknown
 `
@@ -62,6 +75,10 @@ func TestNewClient(t *testing.T) {
 			config:      configWithGCSBackend,
 			expectedErr: nil,
 		},
+		"should create an OSS bucket": {
+			config:      configWithOSSBackend,
+			expectedErr: nil,
+		},
 		"should return error on unknown backend": {
 			config:      configWithUnknownBackend,
 			expectedErr: ErrUnsupportedStorageBackend,
@@ -92,3 +109,51 @@ func TestNewClient(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_Validate_StoragePrefix(t *testing.T) {
+	tests := map[string]struct {
+		prefix      string
+		expectedErr error
+	}{
+		"empty prefix":                   {prefix: "", expectedErr: nil},
+		"single segment":                 {prefix: "cluster-a", expectedErr: nil},
+		"multiple segments":              {prefix: "cluster-a/blocks", expectedErr: nil},
+		"leading slash":                  {prefix: "/cluster-a", expectedErr: ErrInvalidStoragePrefix},
+		"trailing slash":                 {prefix: "cluster-a/", expectedErr: ErrInvalidStoragePrefix},
+		"empty segment":                  {prefix: "cluster-a//blocks", expectedErr: ErrInvalidStoragePrefix},
+		"relative path segment":          {prefix: "cluster-a/../blocks", expectedErr: ErrInvalidStoragePrefix},
+		"current directory path segment": {prefix: "./cluster-a", expectedErr: ErrInvalidStoragePrefix},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			cfg.Backend = Filesystem
+			cfg.StoragePrefix = testData.prefix
+
+			assert.Equal(t, testData.expectedErr, cfg.Validate())
+		})
+	}
+}
+
+func TestNewClient_ShouldStoreObjectsUnderStoragePrefix(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "bucket")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.Backend = Filesystem
+	cfg.Filesystem.Directory = dir
+	cfg.StoragePrefix = "cluster-a"
+
+	client, err := NewClient(context.Background(), cfg, "test", util.Logger, nil)
+	require.NoError(t, err)
+	defer client.Close() //nolint:errcheck
+
+	require.NoError(t, client.Upload(context.Background(), "user-1/object", bytes.NewBufferString("content")))
+
+	_, err = os.Stat(filepath.Join(dir, "cluster-a", "user-1", "object"))
+	require.NoError(t, err)
+}
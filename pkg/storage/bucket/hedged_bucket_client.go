@@ -0,0 +1,173 @@
+package bucket
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+var errInvalidHedgingMaxRequests = errors.New("the hedging max requests must be greater than 0 when hedging is enabled")
+
+// HedgingConfig configures the hedged requests to the object storage.
+type HedgingConfig struct {
+	Delay       time.Duration `yaml:"delay"`
+	MaxRequests int           `yaml:"max_requests"`
+}
+
+// RegisterFlagsWithPrefix registers the hedging flags with the provided prefix.
+func (cfg *HedgingConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.DurationVar(&cfg.Delay, prefix+"hedging.delay", 0, "If a read request to the object storage (Get and GetRange) hasn't completed after this delay, a hedged request is sent and the response of whichever request completes first is used. 0 disables hedged requests.")
+	f.IntVar(&cfg.MaxRequests, prefix+"hedging.max-requests", 2, "Maximum number of hedged requests sent for a single read request, in addition to the original one. A new hedged request is sent each time the delay elapses without a response.")
+}
+
+// Validate the config.
+func (cfg *HedgingConfig) Validate() error {
+	if cfg.Enabled() && cfg.MaxRequests <= 0 {
+		return errInvalidHedgingMaxRequests
+	}
+	return nil
+}
+
+// Enabled returns whether hedged requests are enabled.
+func (cfg *HedgingConfig) Enabled() bool {
+	return cfg.Delay > 0
+}
+
+type hedgingMetrics struct {
+	hedgedRequests *prometheus.CounterVec
+	wins           *prometheus.CounterVec
+}
+
+func newHedgingMetrics(reg prometheus.Registerer) *hedgingMetrics {
+	return &hedgingMetrics{
+		hedgedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_hedged_requests_total",
+			Help: "Total number of hedged requests sent to the object storage.",
+		}, []string{"operation"}),
+		wins: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_hedged_request_wins_total",
+			Help: "Total number of read requests to the object storage, with hedging enabled, by the request that completed first (primary or hedged).",
+		}, []string{"operation", "winner"}),
+	}
+}
+
+// HedgedBucketClient is a wrapper around a objstore.Bucket that hedges the read requests:
+// if a request hasn't completed after the configured delay, another one is sent, and
+// the response of the first request completing is used.
+type HedgedBucketClient struct {
+	objstore.Bucket
+
+	cfg     HedgingConfig
+	metrics *hedgingMetrics
+}
+
+// NewHedgedBucketClient returns a new HedgedBucketClient.
+func NewHedgedBucketClient(bucket objstore.Bucket, cfg HedgingConfig, reg prometheus.Registerer) *HedgedBucketClient {
+	return &HedgedBucketClient{
+		Bucket:  bucket,
+		cfg:     cfg,
+		metrics: newHedgingMetrics(reg),
+	}
+}
+
+// Get returns a reader for the given object name.
+func (b *HedgedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.hedge(ctx, opGet, func(ctx context.Context) (io.ReadCloser, error) {
+		return b.Bucket.Get(ctx, name)
+	})
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *HedgedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	return b.hedge(ctx, opGetRange, func(ctx context.Context) (io.ReadCloser, error) {
+		return b.Bucket.GetRange(ctx, name, off, length)
+	})
+}
+
+type hedgedResult struct {
+	attempt int
+	reader  io.ReadCloser
+	err     error
+}
+
+func (b *HedgedBucketClient) hedge(ctx context.Context, op string, fn func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	maxAttempts := 1 + b.cfg.MaxRequests
+
+	// The channel is buffered so that the attempts completing after the winner never block.
+	results := make(chan hedgedResult, maxAttempts)
+	cancels := make([]context.CancelFunc, 0, maxAttempts)
+
+	launch := func() {
+		attempt := len(cancels)
+		attemptCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+
+		go func() {
+			r, err := fn(attemptCtx)
+			results <- hedgedResult{attempt: attempt, reader: r, err: err}
+		}()
+	}
+
+	launch()
+
+	timer := time.NewTimer(b.cfg.Delay)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			if len(cancels) < maxAttempts {
+				b.metrics.hedgedRequests.WithLabelValues(op).Inc()
+				launch()
+				timer.Reset(b.cfg.Delay)
+			}
+
+		case res := <-results:
+			winner := "primary"
+			if res.attempt > 0 {
+				winner = "hedged"
+			}
+			b.metrics.wins.WithLabelValues(op, winner).Inc()
+
+			// Cancel the other attempts, and release any reader they may return.
+			for i, cancel := range cancels {
+				if i != res.attempt {
+					cancel()
+				}
+			}
+			go drainHedgedResults(results, len(cancels)-1)
+
+			if res.err != nil {
+				cancels[res.attempt]()
+				return nil, res.err
+			}
+
+			// The winner context is canceled only once its reader has been closed.
+			return &cancelOnCloseReader{ReadCloser: res.reader, cancel: cancels[res.attempt]}, nil
+		}
+	}
+}
+
+func drainHedgedResults(results <-chan hedgedResult, count int) {
+	for i := 0; i < count; i++ {
+		if res := <-results; res.err == nil && res.reader != nil {
+			_ = res.reader.Close()
+		}
+	}
+}
+
+type cancelOnCloseReader struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelOnCloseReader) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
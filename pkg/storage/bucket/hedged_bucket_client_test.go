@@ -0,0 +1,168 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestHedgedBucketClient(t *testing.T) {
+	tests := map[string]struct {
+		// Delay of each request, by attempt. Attempts not listed don't block.
+		delays          []time.Duration
+		expectedCalls   int
+		expectedWinner  string
+		expectedHedged  int
+		expectedContent string
+	}{
+		"should not send hedged requests if the primary request completes before the delay": {
+			delays:          nil,
+			expectedCalls:   1,
+			expectedWinner:  "primary",
+			expectedHedged:  0,
+			expectedContent: "content",
+		},
+		"should use the hedged request response if the primary request is slow": {
+			delays:          []time.Duration{time.Minute},
+			expectedCalls:   2,
+			expectedWinner:  "hedged",
+			expectedHedged:  1,
+			expectedContent: "content",
+		},
+		"should send up to the max number of hedged requests": {
+			delays:          []time.Duration{time.Minute, time.Minute, 0},
+			expectedCalls:   3,
+			expectedWinner:  "hedged",
+			expectedHedged:  2,
+			expectedContent: "content",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			bkt := &slowBucket{Bucket: objstore.NewInMemBucket(), delays: testData.delays}
+			require.NoError(t, bkt.Upload(context.Background(), "object", bytes.NewBufferString("content")))
+
+			reg := prometheus.NewPedanticRegistry()
+			client := NewHedgedBucketClient(bkt, HedgingConfig{Delay: 50 * time.Millisecond, MaxRequests: 2}, reg)
+
+			reader, err := client.GetRange(context.Background(), "object", 0, 7)
+			require.NoError(t, err)
+			content, err := ioutil.ReadAll(reader)
+			require.NoError(t, err)
+			require.NoError(t, reader.Close())
+
+			assert.Equal(t, testData.expectedContent, string(content))
+			assert.Equal(t, testData.expectedCalls, bkt.getCalls())
+
+			// The slow requests must have been canceled.
+			for attempt, delay := range testData.delays {
+				if delay > 0 {
+					assert.Eventually(t, func() bool { return bkt.isCanceled(attempt) }, time.Second, 10*time.Millisecond)
+				}
+			}
+
+			assert.Equal(t, float64(testData.expectedHedged), testutil.ToFloat64(client.metrics.hedgedRequests.WithLabelValues(opGetRange)))
+			assert.Equal(t, float64(1), testutil.ToFloat64(client.metrics.wins.WithLabelValues(opGetRange, testData.expectedWinner)))
+		})
+	}
+}
+
+func TestHedgedBucketClient_ShouldReturnTheFirstError(t *testing.T) {
+	client := NewHedgedBucketClient(objstore.NewInMemBucket(), HedgingConfig{Delay: time.Minute, MaxRequests: 2}, nil)
+
+	_, err := client.Get(context.Background(), "missing")
+	require.Error(t, err)
+	assert.True(t, client.IsObjNotFoundErr(err))
+}
+
+func TestHedgingConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&HedgingConfig{Delay: 0, MaxRequests: 0}).Validate())
+	assert.NoError(t, (&HedgingConfig{Delay: time.Second, MaxRequests: 1}).Validate())
+	assert.Equal(t, errInvalidHedgingMaxRequests, (&HedgingConfig{Delay: time.Second, MaxRequests: 0}).Validate())
+}
+
+// slowBucket delays the read requests by attempt, until the request context is canceled.
+type slowBucket struct {
+	objstore.Bucket
+	delays []time.Duration
+
+	mtx      sync.Mutex
+	calls    int
+	canceled map[int]bool
+}
+
+func (b *slowBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	b.mtx.Lock()
+	attempt := b.calls
+	b.calls++
+	b.mtx.Unlock()
+
+	if attempt < len(b.delays) && b.delays[attempt] > 0 {
+		select {
+		case <-time.After(b.delays[attempt]):
+		case <-ctx.Done():
+			b.mtx.Lock()
+			if b.canceled == nil {
+				b.canceled = map[int]bool{}
+			}
+			b.canceled[attempt] = true
+			b.mtx.Unlock()
+			return nil, ctx.Err()
+		}
+	}
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	reader, err := b.Bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure the reader is consumable only while the request context is not canceled.
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &contextReader{ctx: ctx, Reader: strings.NewReader(string(content))}, nil
+}
+
+func (b *slowBucket) getCalls() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.calls
+}
+
+func (b *slowBucket) isCanceled(attempt int) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.canceled[attempt]
+}
+
+type contextReader struct {
+	io.Reader
+	ctx context.Context
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, errors.New("read after the request context has been canceled")
+	}
+	return r.Reader.Read(p)
+}
+
+func (r *contextReader) Close() error { return nil }
@@ -0,0 +1,19 @@
+package oss
+
+import (
+	"github.com/go-kit/kit/log"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/objstore/s3"
+)
+
+// NewBucketClient creates a new Alibaba Cloud OSS bucket client. The client talks
+// to the OSS S3-compatible API, which supports all the operations used by Cortex.
+func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
+	s3Cfg := s3.DefaultConfig
+	s3Cfg.Bucket = cfg.BucketName
+	s3Cfg.Endpoint = cfg.Endpoint
+	s3Cfg.AccessKey = cfg.AccessKeyID
+	s3Cfg.SecretKey = cfg.AccessKeySecret.Value
+
+	return s3.NewBucketWithConfig(logger, s3Cfg, name)
+}
@@ -0,0 +1,45 @@
+package oss
+
+import (
+	"errors"
+	"flag"
+
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var (
+	errMissingEndpoint   = errors.New("no Alibaba Cloud OSS endpoint specified")
+	errMissingBucketName = errors.New("no Alibaba Cloud OSS bucket name specified")
+)
+
+// Config holds the config options for an Alibaba Cloud OSS backend
+type Config struct {
+	Endpoint        string         `yaml:"endpoint"`
+	BucketName      string         `yaml:"bucket_name"`
+	AccessKeyID     string         `yaml:"access_key_id"`
+	AccessKeySecret flagext.Secret `yaml:"access_key_secret"`
+}
+
+// RegisterFlags registers the flags for Alibaba Cloud OSS storage
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers the flags for Alibaba Cloud OSS storage with the provided prefix
+func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Endpoint, prefix+"oss.endpoint", "", "Alibaba Cloud OSS endpoint, without schema, ie. oss-cn-hangzhou.aliyuncs.com. The endpoints are listed at https://www.alibabacloud.com/help/doc-detail/31837.htm")
+	f.StringVar(&cfg.BucketName, prefix+"oss.bucket-name", "", "Alibaba Cloud OSS bucket name")
+	f.StringVar(&cfg.AccessKeyID, prefix+"oss.access-key-id", "", "Alibaba Cloud OSS access key ID")
+	f.Var(&cfg.AccessKeySecret, prefix+"oss.access-key-secret", "Alibaba Cloud OSS access key secret")
+}
+
+// Validate config and returns error on failure
+func (cfg *Config) Validate() error {
+	if cfg.Endpoint == "" {
+		return errMissingEndpoint
+	}
+	if cfg.BucketName == "" {
+		return errMissingBucketName
+	}
+	return nil
+}
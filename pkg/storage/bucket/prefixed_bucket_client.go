@@ -0,0 +1,77 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+// PrefixedBucketClient is a wrapper around a objstore.Bucket that stores all the objects
+// under a prefix, so that multiple Cortex clusters (or stores) can share the same bucket.
+type PrefixedBucketClient struct {
+	bucket objstore.Bucket
+	prefix string
+}
+
+// NewPrefixedBucketClient returns a new PrefixedBucketClient.
+func NewPrefixedBucketClient(bucket objstore.Bucket, prefix string) *PrefixedBucketClient {
+	return &PrefixedBucketClient{
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+func (b *PrefixedBucketClient) fullName(name string) string {
+	return b.prefix + objstore.DirDelim + name
+}
+
+// Close implements io.Closer
+func (b *PrefixedBucketClient) Close() error { return b.bucket.Close() }
+
+// Upload the contents of the reader as an object into the bucket.
+func (b *PrefixedBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	return b.bucket.Upload(ctx, b.fullName(name), r)
+}
+
+// Delete removes the object with the given name.
+func (b *PrefixedBucketClient) Delete(ctx context.Context, name string) error {
+	return b.bucket.Delete(ctx, b.fullName(name))
+}
+
+// Name returns the bucket name for the provider.
+func (b *PrefixedBucketClient) Name() string { return b.bucket.Name() }
+
+// Iter calls f for each entry in the given directory (not recursive.). The argument to f is the full
+// object name including the prefix of the inspected directory, but excluding the storage prefix.
+func (b *PrefixedBucketClient) Iter(ctx context.Context, dir string, f func(string) error) error {
+	return b.bucket.Iter(ctx, b.fullName(dir), func(s string) error {
+		return f(strings.TrimPrefix(s, b.prefix+objstore.DirDelim))
+	})
+}
+
+// Get returns a reader for the given object name.
+func (b *PrefixedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	return b.bucket.Get(ctx, b.fullName(name))
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *PrefixedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	return b.bucket.GetRange(ctx, b.fullName(name), off, length)
+}
+
+// Exists checks if the given object exists in the bucket.
+func (b *PrefixedBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	return b.bucket.Exists(ctx, b.fullName(name))
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found. Relevant to Get operations.
+func (b *PrefixedBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.bucket.IsObjNotFoundErr(err)
+}
+
+// Attributes returns attributes of the specified object.
+func (b *PrefixedBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	return b.bucket.Attributes(ctx, b.fullName(name))
+}
@@ -0,0 +1,61 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestPrefixedBucketClient(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	client := NewPrefixedBucketClient(bkt, "cluster-a/blocks")
+
+	require.NoError(t, client.Upload(ctx, "user-1/block-1/meta.json", bytes.NewBufferString("content")))
+	require.NoError(t, client.Upload(ctx, "user-2/block-2/meta.json", bytes.NewBufferString("content")))
+	require.NoError(t, bkt.Upload(ctx, "cluster-b/blocks/user-3/block-3/meta.json", bytes.NewBufferString("content")))
+
+	// The objects are stored under the prefix in the underlying bucket.
+	exists, err := bkt.Exists(ctx, "cluster-a/blocks/user-1/block-1/meta.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// The prefix is transparent to the client.
+	exists, err = client.Exists(ctx, "user-1/block-1/meta.json")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	reader, err := client.Get(ctx, "user-1/block-1/meta.json")
+	require.NoError(t, err)
+	content, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "content", string(content))
+
+	var users []string
+	require.NoError(t, client.Iter(ctx, "", func(name string) error {
+		users = append(users, name)
+		return nil
+	}))
+	assert.Equal(t, []string{"user-1/", "user-2/"}, users)
+
+	var blocks []string
+	require.NoError(t, client.Iter(ctx, "user-1/", func(name string) error {
+		blocks = append(blocks, name)
+		return nil
+	}))
+	assert.Equal(t, []string{"user-1/block-1/"}, blocks)
+
+	require.NoError(t, client.Delete(ctx, "user-1/block-1/meta.json"))
+	exists, err = bkt.Exists(ctx, "cluster-a/blocks/user-1/block-1/meta.json")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	_, err = client.Get(ctx, "user-1/block-1/meta.json")
+	assert.True(t, client.IsObjNotFoundErr(err))
+}
@@ -0,0 +1,319 @@
+package bucket
+
+import (
+	"context"
+	"flag"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"golang.org/x/time/rate"
+)
+
+// Classes of operations rate limited together.
+const (
+	readOps  = "read"
+	listOps  = "list"
+	writeOps = "write"
+)
+
+// RateLimitConfig configures the rate limiting of the requests to the object storage.
+type RateLimitConfig struct {
+	ReadOpsPerSecond  float64 `yaml:"read_ops_per_second"`
+	ListOpsPerSecond  float64 `yaml:"list_ops_per_second"`
+	WriteOpsPerSecond float64 `yaml:"write_ops_per_second"`
+}
+
+// RegisterFlagsWithPrefix registers the rate limit flags with the provided prefix.
+func (cfg *RateLimitConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.Float64Var(&cfg.ReadOpsPerSecond, prefix+"rate-limit.read-ops-per-second", 0, "Maximum number of read operations (Get, GetRange, Exists and Attributes) per second sent to the object storage. Operations exceeding the limit are queued. 0 to disable.")
+	f.Float64Var(&cfg.ListOpsPerSecond, prefix+"rate-limit.list-ops-per-second", 0, "Maximum number of list operations (Iter) per second sent to the object storage. Operations exceeding the limit are queued. 0 to disable.")
+	f.Float64Var(&cfg.WriteOpsPerSecond, prefix+"rate-limit.write-ops-per-second", 0, "Maximum number of write operations (Upload and Delete) per second sent to the object storage. Operations exceeding the limit are queued. 0 to disable.")
+}
+
+// Enabled returns whether any rate limit is configured.
+func (cfg *RateLimitConfig) Enabled() bool {
+	return cfg.ReadOpsPerSecond > 0 || cfg.ListOpsPerSecond > 0 || cfg.WriteOpsPerSecond > 0
+}
+
+// TenantRateLimitsProvider provides the per-tenant rate limits of the requests to the object storage.
+type TenantRateLimitsProvider interface {
+	// ReadOpsPerSecond returns the per-tenant limit of read operations per second, or 0 if unlimited.
+	ReadOpsPerSecond(userID string) float64
+
+	// ListOpsPerSecond returns the per-tenant limit of list operations per second, or 0 if unlimited.
+	ListOpsPerSecond(userID string) float64
+}
+
+// opsLimiter returns the rate limiter to apply to an operation on the object with the given name,
+// or nil if the operation is not rate limited.
+type opsLimiter interface {
+	limiterFor(class, name string) *rate.Limiter
+}
+
+// RateLimitedBucketClient is a wrapper around a objstore.Bucket that rate limits the
+// operations, queueing the ones exceeding the limits.
+type RateLimitedBucketClient struct {
+	bucket    objstore.Bucket
+	limiter   opsLimiter
+	throttled *prometheus.CounterVec
+}
+
+// NewRateLimitedBucketClient returns a new RateLimitedBucketClient enforcing the global rate limits.
+func NewRateLimitedBucketClient(bucket objstore.Bucket, cfg RateLimitConfig, reg prometheus.Registerer) *RateLimitedBucketClient {
+	return &RateLimitedBucketClient{
+		bucket: bucket,
+		limiter: &globalOpsLimiter{limiters: map[string]*rate.Limiter{
+			readOps:  newOpsRateLimiter(cfg.ReadOpsPerSecond),
+			listOps:  newOpsRateLimiter(cfg.ListOpsPerSecond),
+			writeOps: newOpsRateLimiter(cfg.WriteOpsPerSecond),
+		}},
+		throttled: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_throttled_operations_total",
+			Help: "Total number of object storage operations delayed because of the rate limits.",
+		}, []string{"operation"}),
+	}
+}
+
+// NewTenantRateLimitedBucketClient returns a new RateLimitedBucketClient enforcing the per-tenant
+// rate limits. The tenant is the first segment of the object name, so the client must wrap a
+// bucket client storing the tenants objects in per-tenant directories.
+func NewTenantRateLimitedBucketClient(bucket objstore.Bucket, limits TenantRateLimitsProvider, reg prometheus.Registerer) *RateLimitedBucketClient {
+	return &RateLimitedBucketClient{
+		bucket: bucket,
+		limiter: &tenantOpsLimiter{
+			limits:   limits,
+			limiters: map[string]*tenantLimiter{},
+		},
+		throttled: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_tenant_throttled_operations_total",
+			Help: "Total number of object storage operations delayed because of the per-tenant rate limits.",
+		}, []string{"user", "operation"}),
+	}
+}
+
+func (b *RateLimitedBucketClient) wait(ctx context.Context, op, class, name string) error {
+	l := b.limiter.limiterFor(class, name)
+	if l == nil {
+		return nil
+	}
+
+	r := l.Reserve()
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+
+	if _, ok := b.limiter.(*tenantOpsLimiter); ok {
+		b.throttled.WithLabelValues(tenantFromObjectName(name), op).Inc()
+	} else {
+		b.throttled.WithLabelValues(op).Inc()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// RemoveUser removes the per-tenant rate limiters and metrics of the tenant. It's a no-op for
+// the clients enforcing the global rate limits.
+func (b *RateLimitedBucketClient) RemoveUser(userID string) {
+	l, ok := b.limiter.(*tenantOpsLimiter)
+	if !ok {
+		return
+	}
+
+	l.removeUser(userID)
+	for _, op := range []string{opUpload, opDelete, opIter, opGet, opGetRange, opExists, opAttributes} {
+		b.throttled.DeleteLabelValues(userID, op)
+	}
+}
+
+// Close implements io.Closer
+func (b *RateLimitedBucketClient) Close() error { return b.bucket.Close() }
+
+// Upload the contents of the reader as an object into the bucket.
+func (b *RateLimitedBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	if err := b.wait(ctx, opUpload, writeOps, name); err != nil {
+		return err
+	}
+	return b.bucket.Upload(ctx, name, r)
+}
+
+// Delete removes the object with the given name.
+func (b *RateLimitedBucketClient) Delete(ctx context.Context, name string) error {
+	if err := b.wait(ctx, opDelete, writeOps, name); err != nil {
+		return err
+	}
+	return b.bucket.Delete(ctx, name)
+}
+
+// Name returns the bucket name for the provider.
+func (b *RateLimitedBucketClient) Name() string { return b.bucket.Name() }
+
+// Iter calls f for each entry in the given directory (not recursive.).
+func (b *RateLimitedBucketClient) Iter(ctx context.Context, dir string, f func(string) error) error {
+	if err := b.wait(ctx, opIter, listOps, dir); err != nil {
+		return err
+	}
+	return b.bucket.Iter(ctx, dir, f)
+}
+
+// Get returns a reader for the given object name.
+func (b *RateLimitedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	if err := b.wait(ctx, opGet, readOps, name); err != nil {
+		return nil, err
+	}
+	return b.bucket.Get(ctx, name)
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *RateLimitedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if err := b.wait(ctx, opGetRange, readOps, name); err != nil {
+		return nil, err
+	}
+	return b.bucket.GetRange(ctx, name, off, length)
+}
+
+// Exists checks if the given object exists in the bucket.
+func (b *RateLimitedBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	if err := b.wait(ctx, opExists, readOps, name); err != nil {
+		return false, err
+	}
+	return b.bucket.Exists(ctx, name)
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found. Relevant to Get operations.
+func (b *RateLimitedBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.bucket.IsObjNotFoundErr(err)
+}
+
+// Attributes returns attributes of the specified object.
+func (b *RateLimitedBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	if err := b.wait(ctx, opAttributes, readOps, name); err != nil {
+		return objstore.ObjectAttributes{}, err
+	}
+	return b.bucket.Attributes(ctx, name)
+}
+
+// ReaderWithExpectedErrs allows to specify a filter that marks certain errors as expected, so it will not increment
+// thanos_objstore_bucket_operation_failures_total metric.
+func (b *RateLimitedBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs allows to specify a filter that marks certain errors as expected, so it will not increment
+// thanos_objstore_bucket_operation_failures_total metric.
+func (b *RateLimitedBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.bucket.(objstore.InstrumentedBucket); ok {
+		return &RateLimitedBucketClient{
+			bucket:    ib.WithExpectedErrs(fn),
+			limiter:   b.limiter,
+			throttled: b.throttled,
+		}
+	}
+
+	return b
+}
+
+// newOpsRateLimiter returns a rate limiter allowing the given ops per second, or nil if unlimited.
+func newOpsRateLimiter(opsPerSecond float64) *rate.Limiter {
+	if opsPerSecond <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(opsPerSecond), opsBurst(opsPerSecond))
+}
+
+// opsBurst returns the burst allowed for the given ops per second, which is a
+// second worth of operations.
+func opsBurst(opsPerSecond float64) int {
+	return int(math.Max(1, math.Ceil(opsPerSecond)))
+}
+
+type globalOpsLimiter struct {
+	limiters map[string]*rate.Limiter
+}
+
+func (l *globalOpsLimiter) limiterFor(class, _ string) *rate.Limiter {
+	return l.limiters[class]
+}
+
+type tenantLimiter struct {
+	opsPerSecond float64
+	limiter      *rate.Limiter
+}
+
+type tenantOpsLimiter struct {
+	limits TenantRateLimitsProvider
+
+	mtx sync.Mutex
+	// Keyed by class and tenant.
+	limiters map[string]*tenantLimiter
+}
+
+func (l *tenantOpsLimiter) limiterFor(class, name string) *rate.Limiter {
+	userID := tenantFromObjectName(name)
+	if userID == "" {
+		return nil
+	}
+
+	var opsPerSecond float64
+	switch class {
+	case readOps:
+		opsPerSecond = l.limits.ReadOpsPerSecond(userID)
+	case listOps:
+		opsPerSecond = l.limits.ListOpsPerSecond(userID)
+	}
+
+	key := class + "/" + userID
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if opsPerSecond <= 0 {
+		delete(l.limiters, key)
+		return nil
+	}
+
+	entry, ok := l.limiters[key]
+	if !ok {
+		entry = &tenantLimiter{opsPerSecond: opsPerSecond, limiter: newOpsRateLimiter(opsPerSecond)}
+		l.limiters[key] = entry
+	} else if entry.opsPerSecond != opsPerSecond {
+		// The limit has changed (ie. runtime config reload).
+		entry.opsPerSecond = opsPerSecond
+		entry.limiter.SetLimit(rate.Limit(opsPerSecond))
+		entry.limiter.SetBurst(opsBurst(opsPerSecond))
+	}
+
+	return entry.limiter
+}
+
+func (l *tenantOpsLimiter) removeUser(userID string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	for _, class := range []string{readOps, listOps, writeOps} {
+		delete(l.limiters, class+"/"+userID)
+	}
+}
+
+// tenantFromObjectName returns the tenant owning the object, which is the first segment of its name.
+func tenantFromObjectName(name string) string {
+	if idx := strings.Index(name, objstore.DirDelim); idx >= 0 {
+		return name[:idx]
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestRateLimitedBucketClient_GlobalLimits(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	require.NoError(t, bkt.Upload(ctx, "user-1/object", bytes.NewBufferString("content")))
+
+	client := NewRateLimitedBucketClient(bkt, RateLimitConfig{ReadOpsPerSecond: 10}, nil)
+
+	// The burst allows a second worth of operations, then they're queued.
+	start := time.Now()
+	for i := 0; i < 12; i++ {
+		_, err := client.Exists(ctx, "user-1/object")
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, time.Since(start).Milliseconds(), int64(150))
+	assert.Equal(t, float64(2), testutil.ToFloat64(client.throttled.WithLabelValues(opExists)))
+
+	// Other operation classes are not rate limited.
+	for i := 0; i < 20; i++ {
+		require.NoError(t, client.Iter(ctx, "", func(string) error { return nil }))
+	}
+	assert.Equal(t, float64(0), testutil.ToFloat64(client.throttled.WithLabelValues(opIter)))
+}
+
+func TestRateLimitedBucketClient_TenantLimits(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	require.NoError(t, bkt.Upload(ctx, "user-1/object", bytes.NewBufferString("content")))
+	require.NoError(t, bkt.Upload(ctx, "user-2/object", bytes.NewBufferString("content")))
+
+	limits := &mockTenantRateLimits{read: map[string]float64{"user-1": 1}}
+	client := NewTenantRateLimitedBucketClient(bkt, limits, nil)
+
+	// The tenant without limits is never throttled.
+	for i := 0; i < 10; i++ {
+		_, err := client.Exists(ctx, "user-2/object")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, float64(0), testutil.ToFloat64(client.throttled.WithLabelValues("user-2", opExists)))
+
+	// The first operation of the rate limited tenant is allowed by the burst, while
+	// the next one is queued and fails once the context is canceled.
+	_, err := client.Exists(ctx, "user-1/object")
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Exists(timeoutCtx, "user-1/object")
+	require.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, float64(1), testutil.ToFloat64(client.throttled.WithLabelValues("user-1", opExists)))
+
+	// Removing the tenant limit takes effect immediately.
+	limits.read = nil
+	for i := 0; i < 10; i++ {
+		_, err := client.Exists(ctx, "user-1/object")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(client.throttled.WithLabelValues("user-1", opExists)))
+}
+
+func TestRateLimitedBucketClient_RemoveUser(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	require.NoError(t, bkt.Upload(ctx, "user-1/object", bytes.NewBufferString("content")))
+
+	reg := prometheus.NewPedanticRegistry()
+	limits := &mockTenantRateLimits{read: map[string]float64{"user-1": 1}}
+	client := NewTenantRateLimitedBucketClient(bkt, limits, reg)
+
+	_, err := client.Exists(ctx, "user-1/object")
+	require.NoError(t, err)
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = client.Exists(timeoutCtx, "user-1/object")
+	require.Equal(t, context.DeadlineExceeded, err)
+
+	assert.Len(t, client.limiter.(*tenantOpsLimiter).limiters, 1)
+	assert.Equal(t, 1, testutil.CollectAndCount(client.throttled))
+
+	client.RemoveUser("user-1")
+	assert.Len(t, client.limiter.(*tenantOpsLimiter).limiters, 0)
+	assert.Equal(t, 0, testutil.CollectAndCount(client.throttled))
+}
+
+func TestTenantFromObjectName(t *testing.T) {
+	assert.Equal(t, "user-1", tenantFromObjectName("user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json"))
+	assert.Equal(t, "user-1", tenantFromObjectName("user-1/"))
+	assert.Equal(t, "", tenantFromObjectName("object"))
+	assert.Equal(t, "", tenantFromObjectName(""))
+}
+
+type mockTenantRateLimits struct {
+	read map[string]float64
+	list map[string]float64
+}
+
+func (m *mockTenantRateLimits) ReadOpsPerSecond(userID string) float64 {
+	return m.read[userID]
+}
+
+func (m *mockTenantRateLimits) ListOpsPerSecond(userID string) float64 {
+	return m.list[userID]
+}
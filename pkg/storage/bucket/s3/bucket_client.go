@@ -1,29 +1,130 @@
 package s3
 
 import (
+	"context"
+	"io"
+	"sync"
+
 	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
 	"github.com/thanos-io/thanos/pkg/objstore"
 	"github.com/thanos-io/thanos/pkg/objstore/s3"
 )
 
+type sseConfigContextKey struct{}
+
+// ContextWithSSEConfig returns a context overriding the SSE config of the objects uploaded
+// with it, ie. to encrypt the objects of a tenant with its own KMS key.
+func ContextWithSSEConfig(ctx context.Context, cfg SSEConfig) context.Context {
+	return context.WithValue(ctx, sseConfigContextKey{}, cfg)
+}
+
+func sseConfigFromContext(ctx context.Context) (SSEConfig, bool) {
+	cfg, ok := ctx.Value(sseConfigContextKey{}).(SSEConfig)
+	return cfg, ok
+}
+
 // NewBucketClient creates a new S3 bucket client
 func NewBucketClient(cfg Config, name string, logger log.Logger) (objstore.Bucket, error) {
-	return s3.NewBucketWithConfig(logger, newS3Config(cfg), name)
+	s3Cfg, err := newS3Config(cfg, cfg.SSE)
+	if err != nil {
+		return nil, err
+	}
+
+	bkt, err := s3.NewBucketWithConfig(logger, s3Cfg, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BucketClient{
+		Bucket:     bkt,
+		cfg:        cfg,
+		name:       name,
+		logger:     logger,
+		sseBuckets: map[SSEConfig]*s3.Bucket{},
+	}, nil
 }
 
 // NewBucketReaderClient creates a new S3 bucket client
 func NewBucketReaderClient(cfg Config, name string, logger log.Logger) (objstore.BucketReader, error) {
-	return s3.NewBucketWithConfig(logger, newS3Config(cfg), name)
+	s3Cfg, err := newS3Config(cfg, cfg.SSE)
+	if err != nil {
+		return nil, err
+	}
+
+	return s3.NewBucketWithConfig(logger, s3Cfg, name)
+}
+
+// BucketClient is an S3 bucket client supporting per-upload SSE config overrides.
+type BucketClient struct {
+	*s3.Bucket
+
+	cfg    Config
+	name   string
+	logger log.Logger
+
+	// The Thanos client SSE config can't be changed per-request, so a client
+	// is created for each SSE config override.
+	sseBucketsMtx sync.Mutex
+	sseBuckets    map[SSEConfig]*s3.Bucket
+}
+
+// Upload the contents of the reader as an object into the bucket, encrypted with the
+// SSE config of the context, if any.
+func (b *BucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	sse, ok := sseConfigFromContext(ctx)
+	if !ok || sse == b.cfg.SSE {
+		return b.Bucket.Upload(ctx, name, r)
+	}
+
+	bkt, err := b.getSSEBucket(sse)
+	if err != nil {
+		return err
+	}
+
+	return bkt.Upload(ctx, name, r)
+}
+
+func (b *BucketClient) getSSEBucket(sse SSEConfig) (*s3.Bucket, error) {
+	b.sseBucketsMtx.Lock()
+	defer b.sseBucketsMtx.Unlock()
+
+	if bkt, ok := b.sseBuckets[sse]; ok {
+		return bkt, nil
+	}
+
+	if err := sse.Validate(); err != nil {
+		return nil, errors.Wrap(err, "invalid S3 SSE config")
+	}
+
+	s3Cfg, err := newS3Config(b.cfg, sse)
+	if err != nil {
+		return nil, err
+	}
+
+	bkt, err := s3.NewBucketWithConfig(b.logger, s3Cfg, b.name)
+	if err != nil {
+		return nil, err
+	}
+
+	b.sseBuckets[sse] = bkt
+	return bkt, nil
 }
 
-func newS3Config(cfg Config) s3.Config {
+func newS3Config(cfg Config, sse SSEConfig) (s3.Config, error) {
+	sseCfg, err := sse.BuildThanosConfig()
+	if err != nil {
+		return s3.Config{}, err
+	}
+
 	return s3.Config{
 		Bucket:    cfg.BucketName,
 		Endpoint:  cfg.Endpoint,
 		AccessKey: cfg.AccessKeyID,
 		SecretKey: cfg.SecretAccessKey.Value,
 		Insecure:  cfg.Insecure,
+		SSEConfig: sseCfg,
 		HTTPConfig: s3.HTTPConfig{
 			IdleConnTimeout:       model.Duration(cfg.HTTP.IdleConnTimeout),
 			ResponseHeaderTimeout: model.Duration(cfg.HTTP.ResponseHeaderTimeout),
@@ -32,5 +133,5 @@ func newS3Config(cfg Config) s3.Config {
 		},
 		// Enforce signature version 2 if CLI flag is set
 		SignatureV2: cfg.SignatureVersion == SignatureVersionV2,
-	}
+	}, nil
 }
@@ -1,13 +1,16 @@
 package s3
 
 import (
-	"errors"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/objstore/s3"
+
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
@@ -15,11 +18,23 @@ import (
 const (
 	SignatureVersionV4 = "v4"
 	SignatureVersionV2 = "v2"
+
+	// SSEKMS config type constant to configure S3 server side encryption using KMS
+	// https://docs.aws.amazon.com/AmazonS3/latest/dev/UsingKMSEncryption.html
+	SSEKMS = "SSE-KMS"
+
+	// SSES3 config type constant to configure S3 server side encryption with AES-256
+	// https://docs.aws.amazon.com/AmazonS3/latest/dev/UsingServerSideEncryption.html
+	SSES3 = "SSE-S3"
 )
 
 var (
 	supportedSignatureVersions     = []string{SignatureVersionV4, SignatureVersionV2}
+	supportedSSETypes              = []string{SSEKMS, SSES3}
 	errUnsupportedSignatureVersion = errors.New("unsupported signature version")
+	errUnsupportedSSEType          = errors.New("unsupported S3 SSE type")
+	errMissingSSEKMSKeyID          = errors.New("the KMS key ID is required when the S3 SSE type is SSE-KMS")
+	errInvalidSSEContext           = errors.New("invalid S3 SSE encryption context")
 )
 
 // HTTPConfig stores the http.Transport configuration for the s3 minio client.
@@ -48,6 +63,7 @@ type Config struct {
 	Insecure         bool           `yaml:"insecure"`
 	SignatureVersion string         `yaml:"signature_version"`
 
+	SSE  SSEConfig  `yaml:"sse"`
 	HTTP HTTPConfig `yaml:"http"`
 }
 
@@ -64,6 +80,7 @@ func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
 	f.StringVar(&cfg.Endpoint, prefix+"s3.endpoint", "", "The S3 bucket endpoint. It could be an AWS S3 endpoint listed at https://docs.aws.amazon.com/general/latest/gr/s3.html or the address of an S3-compatible service in hostname:port format.")
 	f.BoolVar(&cfg.Insecure, prefix+"s3.insecure", false, "If enabled, use http:// for the S3 endpoint instead of https://. This could be useful in local dev/test environments while using an S3-compatible backend storage, like Minio.")
 	f.StringVar(&cfg.SignatureVersion, prefix+"s3.signature-version", SignatureVersionV4, fmt.Sprintf("The signature version to use for authenticating against S3. Supported values are: %s.", strings.Join(supportedSignatureVersions, ", ")))
+	cfg.SSE.RegisterFlagsWithPrefix(prefix+"s3.sse.", f)
 	cfg.HTTP.RegisterFlagsWithPrefix(prefix, f)
 }
 
@@ -72,5 +89,72 @@ func (cfg *Config) Validate() error {
 	if !util.StringsContain(supportedSignatureVersions, cfg.SignatureVersion) {
 		return errUnsupportedSignatureVersion
 	}
+	return cfg.SSE.Validate()
+}
+
+// SSEConfig configures the S3 server side encryption.
+type SSEConfig struct {
+	Type                 string `yaml:"type"`
+	KMSKeyID             string `yaml:"kms_key_id"`
+	KMSEncryptionContext string `yaml:"kms_encryption_context"`
+}
+
+// RegisterFlags registers the flags for the S3 server side encryption.
+func (cfg *SSEConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.RegisterFlagsWithPrefix("", f)
+}
+
+// RegisterFlagsWithPrefix registers the flags for the S3 server side encryption with the provided prefix.
+func (cfg *SSEConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Type, prefix+"type", "", fmt.Sprintf("Enable AWS Server Side Encryption. Supported values: %s.", strings.Join(supportedSSETypes, ", ")))
+	f.StringVar(&cfg.KMSKeyID, prefix+"kms-key-id", "", "KMS Key ID used to encrypt objects in S3")
+	f.StringVar(&cfg.KMSEncryptionContext, prefix+"kms-encryption-context", "", "KMS Encryption Context used for object encryption. It expects JSON formatted string.")
+}
+
+// Validate the SSE config and returns an error on failure.
+func (cfg *SSEConfig) Validate() error {
+	if cfg.Type != "" && !util.StringsContain(supportedSSETypes, cfg.Type) {
+		return errUnsupportedSSEType
+	}
+	if cfg.Type == SSEKMS && cfg.KMSKeyID == "" {
+		return errMissingSSEKMSKeyID
+	}
+	if _, err := parseKMSEncryptionContext(cfg.KMSEncryptionContext); err != nil {
+		return errInvalidSSEContext
+	}
 	return nil
 }
+
+// BuildThanosConfig builds the SSE config expected by the Thanos client.
+func (cfg *SSEConfig) BuildThanosConfig() (s3.SSEConfig, error) {
+	switch cfg.Type {
+	case "":
+		return s3.SSEConfig{}, nil
+	case SSEKMS:
+		encryptionCtx, err := parseKMSEncryptionContext(cfg.KMSEncryptionContext)
+		if err != nil {
+			return s3.SSEConfig{}, err
+		}
+
+		return s3.SSEConfig{
+			Type:                 s3.SSEKMS,
+			KMSKeyID:             cfg.KMSKeyID,
+			KMSEncryptionContext: encryptionCtx,
+		}, nil
+	case SSES3:
+		return s3.SSEConfig{
+			Type: s3.SSES3,
+		}, nil
+	default:
+		return s3.SSEConfig{}, errUnsupportedSSEType
+	}
+}
+
+func parseKMSEncryptionContext(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	decoded := map[string]string{}
+	return decoded, errors.Wrap(json.Unmarshal([]byte(data), &decoded), "unable to parse KMS encryption context")
+}
@@ -0,0 +1,140 @@
+package bucket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket/s3"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+func TestSSEBucketClient_Upload_ShouldInjectCustomSSEConfig(t *testing.T) {
+	tests := map[string]struct {
+		withExpectedErrs bool
+	}{
+		"default client": {
+			withExpectedErrs: false,
+		},
+		"client with expected errors": {
+			withExpectedErrs: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var req *http.Request
+
+			// Start a fake HTTP server which simulate S3.
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// The client looks up the bucket region before the first request.
+				if _, ok := r.URL.Query()["location"]; ok {
+					_, _ = w.Write([]byte(`<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`))
+					return
+				}
+
+				// Keep track of the received request.
+				req = r
+
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer srv.Close()
+
+			s3Cfg := s3.Config{
+				Endpoint:         srv.Listener.Addr().String(),
+				BucketName:       "test-bucket",
+				SecretAccessKey:  flagext.Secret{Value: "test"},
+				AccessKeyID:      "test",
+				Insecure:         true,
+				SignatureVersion: s3.SignatureVersionV4,
+			}
+
+			s3Client, err := s3.NewBucketClient(s3Cfg, "test", log.NewNopLogger())
+			require.NoError(t, err)
+
+			cfgProvider := &mockTenantConfigProvider{
+				s3SseType: s3.SSES3,
+			}
+
+			var sseBkt *SSEBucketClient
+			if testData.withExpectedErrs {
+				sseBkt = NewSSEBucketClient("user-1", s3Client, cfgProvider).WithExpectedErrs(s3Client.IsObjNotFoundErr).(*SSEBucketClient)
+			} else {
+				sseBkt = NewSSEBucketClient("user-1", s3Client, cfgProvider)
+			}
+
+			err = sseBkt.Upload(context.Background(), "test", strings.NewReader("test"))
+			require.NoError(t, err)
+
+			// Ensure the SSE header has been injected.
+			assert.Equal(t, "AES256", req.Header.Get("x-amz-server-side-encryption"))
+
+			// Remove the tenant override, the default (no SSE) config is used.
+			cfgProvider.s3SseType = ""
+
+			err = sseBkt.Upload(context.Background(), "test", strings.NewReader("test"))
+			require.NoError(t, err)
+			assert.Equal(t, "", req.Header.Get("x-amz-server-side-encryption"))
+		})
+	}
+}
+
+func TestSSEBucketClient_getCustomS3SSEConfig(t *testing.T) {
+	cfgProvider := &mockTenantConfigProvider{}
+	bkt := NewSSEBucketClient("user-1", &ClientMock{}, cfgProvider)
+
+	// No override if the SSE type is not set.
+	cfgProvider.s3KmsKeyID = "ABC"
+	_, ok := bkt.getCustomS3SSEConfig()
+	assert.False(t, ok)
+
+	cfgProvider.s3SseType = s3.SSEKMS
+	cfgProvider.s3KmsEncryptionContext = `{"department":"10103.0"}`
+	sse, ok := bkt.getCustomS3SSEConfig()
+	assert.True(t, ok)
+	assert.Equal(t, s3.SSEConfig{Type: s3.SSEKMS, KMSKeyID: "ABC", KMSEncryptionContext: `{"department":"10103.0"}`}, sse)
+
+	// No override without a config provider.
+	_, ok = NewSSEBucketClient("user-1", &ClientMock{}, nil).getCustomS3SSEConfig()
+	assert.False(t, ok)
+}
+
+func TestSSEBucketClient_Upload_ShouldFailOnInvalidCustomSSEConfig(t *testing.T) {
+	cfgProvider := &mockTenantConfigProvider{s3SseType: "unknown"}
+
+	// The SSE config is ignored by non-S3 buckets.
+	bkt := &ClientMock{}
+	bkt.MockUpload("test", nil)
+	require.NoError(t, NewSSEBucketClient("user-1", bkt, cfgProvider).Upload(context.Background(), "test", strings.NewReader("test")))
+
+	s3Client, err := s3.NewBucketClient(s3.Config{Endpoint: "localhost:1", BucketName: "test-bucket", Insecure: true}, "test", log.NewNopLogger())
+	require.NoError(t, err)
+
+	err = NewSSEBucketClient("user-1", s3Client, cfgProvider).Upload(context.Background(), "test", strings.NewReader("test"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid S3 SSE config")
+}
+
+type mockTenantConfigProvider struct {
+	s3SseType              string
+	s3KmsKeyID             string
+	s3KmsEncryptionContext string
+}
+
+func (m *mockTenantConfigProvider) S3SSEType(_ string) string {
+	return m.s3SseType
+}
+
+func (m *mockTenantConfigProvider) S3SSEKMSKeyID(_ string) string {
+	return m.s3KmsKeyID
+}
+
+func (m *mockTenantConfigProvider) S3SSEKMSEncryptionContext(_ string) string {
+	return m.s3KmsEncryptionContext
+}
@@ -0,0 +1,202 @@
+package bucket
+
+import (
+	"context"
+	"io"
+	"strings"
+
+	"github.com/oklog/ulid"
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+// TracingBucketClient is a wrapper around a objstore.Bucket that includes the bucket operations
+// in the request traces. Each operation span is tagged with the tenant and the block owning the
+// object (if any), and the read operations with the number of bytes fetched, so that a query
+// trace shows which blocks have been touched and how much data has been fetched from each one.
+type TracingBucketClient struct {
+	bucket objstore.Bucket
+}
+
+// NewTracingBucketClient returns a new TracingBucketClient.
+func NewTracingBucketClient(bucket objstore.Bucket) *TracingBucketClient {
+	return &TracingBucketClient{bucket: bucket}
+}
+
+// startSpan starts the span of an operation on the object (or directory) with the given name. The operations
+// are traced only if they're part of a traced request, to not create a trace for each background operation.
+func (b *TracingBucketClient) startSpan(ctx context.Context, op, name string) (opentracing.Span, context.Context) {
+	if opentracing.SpanFromContext(ctx) == nil {
+		return opentracing.NoopTracer{}.StartSpan("bucket_" + op), ctx
+	}
+
+	span, ctx := opentracing.StartSpanFromContext(ctx, "bucket_"+op)
+	span.SetTag("object", name)
+
+	if tenant, block := tenantAndBlockFromObjectName(name); tenant != "" {
+		span.SetTag("tenant", tenant)
+		if block != "" {
+			span.SetTag("block", block)
+		}
+	}
+
+	return span, ctx
+}
+
+func finishSpan(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogKV("err", err)
+	}
+	span.Finish()
+}
+
+// Close implements io.Closer
+func (b *TracingBucketClient) Close() error { return b.bucket.Close() }
+
+// Upload the contents of the reader as an object into the bucket.
+func (b *TracingBucketClient) Upload(ctx context.Context, name string, r io.Reader) (err error) {
+	span, ctx := b.startSpan(ctx, opUpload, name)
+	defer func() { finishSpan(span, err) }()
+
+	return b.bucket.Upload(ctx, name, r)
+}
+
+// Delete removes the object with the given name.
+func (b *TracingBucketClient) Delete(ctx context.Context, name string) (err error) {
+	span, ctx := b.startSpan(ctx, opDelete, name)
+	defer func() { finishSpan(span, err) }()
+
+	return b.bucket.Delete(ctx, name)
+}
+
+// Name returns the bucket name for the provider.
+func (b *TracingBucketClient) Name() string { return b.bucket.Name() }
+
+// Iter calls f for each entry in the given directory (not recursive.).
+func (b *TracingBucketClient) Iter(ctx context.Context, dir string, f func(string) error) (err error) {
+	span, ctx := b.startSpan(ctx, opIter, dir)
+	defer func() { finishSpan(span, err) }()
+
+	return b.bucket.Iter(ctx, dir, f)
+}
+
+// Get returns a reader for the given object name.
+func (b *TracingBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	span, ctx := b.startSpan(ctx, opGet, name)
+
+	r, err := b.bucket.Get(ctx, name)
+	if err != nil {
+		finishSpan(span, err)
+		return nil, err
+	}
+
+	// The span is finished once the reader has been closed.
+	return &tracingReadCloser{ReadCloser: r, span: span}, nil
+}
+
+// GetRange returns a new range reader for the given object name and range.
+func (b *TracingBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	span, ctx := b.startSpan(ctx, opGetRange, name)
+	span.SetTag("offset", off)
+	span.SetTag("length", length)
+
+	r, err := b.bucket.GetRange(ctx, name, off, length)
+	if err != nil {
+		finishSpan(span, err)
+		return nil, err
+	}
+
+	// The span is finished once the reader has been closed.
+	return &tracingReadCloser{ReadCloser: r, span: span}, nil
+}
+
+// Exists checks if the given object exists in the bucket.
+func (b *TracingBucketClient) Exists(ctx context.Context, name string) (_ bool, err error) {
+	span, ctx := b.startSpan(ctx, opExists, name)
+	defer func() { finishSpan(span, err) }()
+
+	return b.bucket.Exists(ctx, name)
+}
+
+// IsObjNotFoundErr returns true if error means that object is not found. Relevant to Get operations.
+func (b *TracingBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.bucket.IsObjNotFoundErr(err)
+}
+
+// Attributes returns attributes of the specified object.
+func (b *TracingBucketClient) Attributes(ctx context.Context, name string) (_ objstore.ObjectAttributes, err error) {
+	span, ctx := b.startSpan(ctx, opAttributes, name)
+	defer func() { finishSpan(span, err) }()
+
+	return b.bucket.Attributes(ctx, name)
+}
+
+// ReaderWithExpectedErrs allows to specify a filter that marks certain errors as expected, so it will not increment
+// thanos_objstore_bucket_operation_failures_total metric.
+func (b *TracingBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs allows to specify a filter that marks certain errors as expected, so it will not increment
+// thanos_objstore_bucket_operation_failures_total metric.
+func (b *TracingBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.bucket.(objstore.InstrumentedBucket); ok {
+		return &TracingBucketClient{bucket: ib.WithExpectedErrs(fn)}
+	}
+
+	return b
+}
+
+// tracingReadCloser tracks the number of bytes read, and finishes the span once closed.
+type tracingReadCloser struct {
+	io.ReadCloser
+	span    opentracing.Span
+	fetched int64
+	err     error
+}
+
+func (r *tracingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.fetched += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *tracingReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+
+	if r.span != nil {
+		r.span.SetTag("bytes_fetched", r.fetched)
+
+		// The read error (if any) is reported in the span, but not returned by Close().
+		spanErr := err
+		if spanErr == nil {
+			spanErr = r.err
+		}
+		finishSpan(r.span, spanErr)
+		r.span = nil
+	}
+
+	return err
+}
+
+// tenantAndBlockFromObjectName returns the tenant and the block owning the object, which are the
+// first and second segments of its name. The block is empty if the object doesn't belong to a block.
+func tenantAndBlockFromObjectName(name string) (tenant, block string) {
+	parts := strings.SplitN(name, objstore.DirDelim, 3)
+	if len(parts) < 2 {
+		return "", ""
+	}
+
+	if len(parts) == 3 {
+		if _, err := ulid.Parse(parts[1]); err == nil {
+			block = parts[1]
+		}
+	}
+
+	return parts[0], block
+}
@@ -0,0 +1,59 @@
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestTracingBucketClient(t *testing.T) {
+	bkt := objstore.NewInMemBucket()
+	client := NewTracingBucketClient(bkt)
+
+	// The operations are transparent to the caller, whether they're part of a traced request or not.
+	for _, ctx := range []context.Context{
+		context.Background(),
+		opentracing.ContextWithSpan(context.Background(), opentracing.NoopTracer{}.StartSpan("test")),
+	} {
+		require.NoError(t, client.Upload(ctx, "user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json", bytes.NewBufferString("content")))
+
+		reader, err := client.GetRange(ctx, "user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json", 1, 3)
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		assert.Equal(t, "ont", string(content))
+
+		_, err = client.Get(ctx, "user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/index")
+		assert.True(t, client.IsObjNotFoundErr(err))
+
+		require.NoError(t, client.Delete(ctx, "user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json"))
+	}
+}
+
+func TestTenantAndBlockFromObjectName(t *testing.T) {
+	tests := map[string]struct {
+		tenant string
+		block  string
+	}{
+		"user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/chunks/000001": {tenant: "user-1", block: "01EQK4QKFHVSZYVJ908Y7HH9E0"},
+		"user-1/01EQK4QKFHVSZYVJ908Y7HH9E0/meta.json":     {tenant: "user-1", block: "01EQK4QKFHVSZYVJ908Y7HH9E0"},
+		"user-1/bucket-index.json.gz":                     {tenant: "user-1"},
+		"user-1/markers/deletion-mark.json":               {tenant: "user-1"},
+		"user-1/":                                         {tenant: "user-1"},
+		"object":                                          {},
+		"":                                                {},
+	}
+
+	for name, expected := range tests {
+		tenant, block := tenantAndBlockFromObjectName(name)
+		assert.Equal(t, expected.tenant, tenant, name)
+		assert.Equal(t, expected.block, block, name)
+	}
+}
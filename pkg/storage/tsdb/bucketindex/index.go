@@ -16,8 +16,9 @@ import (
 )
 
 const (
-	IndexFilename = "bucket-index.json"
-	IndexVersion1 = 1
+	IndexFilename           = "bucket-index.json"
+	IndexCompressedFilename = IndexFilename + ".gz"
+	IndexVersion1           = 1
 
 	SegmentsFormatUnknown = ""
 
@@ -42,6 +43,27 @@ type Index struct {
 	UpdatedAt int64 `json:"updated_at"`
 }
 
+func (idx *Index) GetUpdatedAt() time.Time {
+	return time.Unix(idx.UpdatedAt, 0)
+}
+
+// RemoveBlock removes block and its deletion mark (if any) from index.
+func (idx *Index) RemoveBlock(id ulid.ULID) {
+	for i := 0; i < len(idx.Blocks); i++ {
+		if idx.Blocks[i].ID == id {
+			idx.Blocks = append(idx.Blocks[:i], idx.Blocks[i+1:]...)
+			break
+		}
+	}
+
+	for i := 0; i < len(idx.BlockDeletionMarks); i++ {
+		if idx.BlockDeletionMarks[i].ID == id {
+			idx.BlockDeletionMarks = append(idx.BlockDeletionMarks[:i], idx.BlockDeletionMarks[i+1:]...)
+			break
+		}
+	}
+}
+
 // Block holds the information about a block in the index.
 type Block struct {
 	// Block ID.
@@ -166,6 +188,10 @@ type BlockDeletionMark struct {
 	DeletionTime int64 `json:"deletion_time"`
 }
 
+func (m *BlockDeletionMark) GetDeletionTime() time.Time {
+	return time.Unix(m.DeletionTime, 0)
+}
+
 func BlockDeletionMarkFromThanosMarker(mark *metadata.DeletionMark) *BlockDeletionMark {
 	return &BlockDeletionMark{
 		ID:           mark.ID,
@@ -0,0 +1,88 @@
+package bucketindex
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+)
+
+func TestReadIndex_ShouldReturnErrorIfIndexDoesNotExist(t *testing.T) {
+	bkt, cleanup := prepareFilesystemBucket(t)
+	defer cleanup()
+
+	idx, err := ReadIndex(context.Background(), bkt, "user-1", log.NewNopLogger())
+	require.Equal(t, ErrIndexNotFound, err)
+	require.Nil(t, idx)
+}
+
+func TestReadIndex_ShouldReturnErrorIfIndexIsCorrupted(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt, cleanup := prepareFilesystemBucket(t)
+	defer cleanup()
+
+	// Write a corrupted index.
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, IndexCompressedFilename), bytes.NewReader([]byte("invalid!}"))))
+
+	idx, err := ReadIndex(ctx, bkt, userID, log.NewNopLogger())
+	require.Equal(t, ErrIndexCorrupted, err)
+	require.Nil(t, idx)
+}
+
+func TestWriteIndex_ShouldBeReadBackByReadIndex(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt, cleanup := prepareFilesystemBucket(t)
+	defer cleanup()
+
+	expected := &Index{
+		Version: IndexVersion1,
+		Blocks: Blocks{
+			{ID: ulid.MustNew(1, nil), MinTime: 10, MaxTime: 20, UploadedAt: 100},
+			{ID: ulid.MustNew(2, nil), MinTime: 20, MaxTime: 30, UploadedAt: 200, SegmentsFormat: SegmentsFormat1Based6Digits, SegmentsNum: 3},
+		},
+		BlockDeletionMarks: []*BlockDeletionMark{
+			{ID: ulid.MustNew(1, nil), DeletionTime: 300},
+		},
+		UpdatedAt: 400,
+	}
+
+	require.NoError(t, WriteIndex(ctx, bkt, userID, nil, expected))
+
+	actual, err := ReadIndex(ctx, bkt, userID, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	// Deleting the index should make it not found anymore, and deleting
+	// a non existing index should not fail.
+	require.NoError(t, DeleteIndex(ctx, bkt, userID))
+	require.NoError(t, DeleteIndex(ctx, bkt, userID))
+
+	_, err = ReadIndex(ctx, bkt, userID, log.NewNopLogger())
+	require.Equal(t, ErrIndexNotFound, err)
+}
+
+func prepareFilesystemBucket(t testing.TB) (objstore.Bucket, func()) {
+	storageDir, err := ioutil.TempDir(os.TempDir(), "bucket-index")
+	require.NoError(t, err)
+
+	bkt, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	return bkt, func() {
+		require.NoError(t, os.RemoveAll(storageDir))
+	}
+}
@@ -0,0 +1,202 @@
+package bucketindex
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+var (
+	ErrBlockMetaNotFound  = block.ErrorSyncMetaNotFound
+	ErrBlockMetaCorrupted = block.ErrorSyncMetaCorrupted
+)
+
+// Updater is responsible to generate an update in-memory bucket index.
+type Updater struct {
+	bkt    objstore.InstrumentedBucket
+	logger log.Logger
+}
+
+func NewUpdater(bkt objstore.Bucket, userID string, logger log.Logger) *Updater {
+	return &Updater{
+		bkt:    bucket.NewUserBucketClient(userID, bkt),
+		logger: util.WithUserID(userID, logger),
+	}
+}
+
+// UpdateIndex generates the bucket index and returns it, without storing it to the storage.
+// If the old index is not passed in input, then the bucket index will be generated from scratch.
+// The partial blocks found in the bucket are returned too, together with the reason why each
+// block is considered partial.
+func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid.ULID]error, error) {
+	var oldBlocks []*Block
+	var oldBlockDeletionMarks []*BlockDeletionMark
+
+	// Read the old index, if provided.
+	if old != nil {
+		oldBlocks = old.Blocks
+		oldBlockDeletionMarks = old.BlockDeletionMarks
+	}
+
+	blocks, partials, err := w.updateBlocks(ctx, oldBlocks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	blockDeletionMarks, err := w.updateBlockDeletionMarks(ctx, blocks, oldBlockDeletionMarks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Index{
+		Version:            IndexVersion1,
+		Blocks:             blocks,
+		BlockDeletionMarks: blockDeletionMarks,
+		UpdatedAt:          time.Now().Unix(),
+	}, partials, nil
+}
+
+// updateBlocks lists the blocks in the bucket, reusing the entries of the old index for
+// the blocks already known and fetching the meta.json of the new ones only.
+func (w *Updater) updateBlocks(ctx context.Context, old []*Block) (blocks []*Block, partials map[ulid.ULID]error, _ error) {
+	discovered := map[ulid.ULID]struct{}{}
+	partials = map[ulid.ULID]error{}
+
+	// Find all blocks in the storage.
+	err := w.bkt.Iter(ctx, "", func(name string) error {
+		if id, ok := block.IsBlockDir(name); ok {
+			discovered[id] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "list blocks")
+	}
+
+	// Since blocks are immutable, all blocks already existing in the index can just be copied.
+	for _, b := range old {
+		if _, ok := discovered[b.ID]; ok {
+			blocks = append(blocks, b)
+			delete(discovered, b.ID)
+		}
+	}
+
+	// Remaining blocks are new ones and we have to fetch the meta.json for each of them, in order
+	// to find out if their upload has been completed (meta.json is uploaded last) and get the block
+	// information to store in the bucket index.
+	for id := range discovered {
+		b, err := w.updateBlockIndexEntry(ctx, id)
+		if err == nil {
+			blocks = append(blocks, b)
+			continue
+		}
+
+		if errors.Is(err, ErrBlockMetaNotFound) {
+			partials[id] = ErrBlockMetaNotFound
+			level.Warn(w.logger).Log("msg", "skipped partial block when updating bucket index", "block", id.String())
+			continue
+		}
+		if errors.Is(err, ErrBlockMetaCorrupted) {
+			partials[id] = ErrBlockMetaCorrupted
+			level.Error(w.logger).Log("msg", "skipped block with corrupted meta.json when updating bucket index", "block", id.String(), "err", err)
+			continue
+		}
+		return nil, nil, err
+	}
+
+	return blocks, partials, nil
+}
+
+func (w *Updater) updateBlockIndexEntry(ctx context.Context, id ulid.ULID) (*Block, error) {
+	metaFile := path.Join(id.String(), block.MetaFilename)
+
+	// Get the block's meta.json file.
+	r, err := w.bkt.Get(ctx, metaFile)
+	if w.bkt.IsObjNotFoundErr(err) {
+		return nil, ErrBlockMetaNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get block meta file: %v", metaFile)
+	}
+	defer runutil.CloseWithLogOnErr(w.logger, r, "close get block meta file")
+
+	metaContent, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read block meta file: %v", metaFile)
+	}
+
+	// Unmarshal it.
+	m := metadata.Meta{}
+	if err := json.Unmarshal(metaContent, &m); err != nil {
+		return nil, errors.Wrapf(ErrBlockMetaCorrupted, "unmarshal block meta file %s: %v", metaFile, err)
+	}
+
+	if m.Version != metadata.TSDBVersion1 {
+		return nil, errors.Errorf("unexpected block meta version: %s version: %d", metaFile, m.Version)
+	}
+
+	b := BlockFromThanosMeta(m)
+
+	// Get the meta.json attributes.
+	attrs, err := w.bkt.Attributes(ctx, metaFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read meta file attributes: %v", metaFile)
+	}
+
+	// Since the meta.json file is the last file of a block being uploaded and it's immutable
+	// we can safely assume that the last modified timestamp of the meta.json is the time when
+	// the block has completed to be uploaded.
+	b.UploadedAt = attrs.LastModified.Unix()
+
+	return b, nil
+}
+
+// updateBlockDeletionMarks returns the deletion marks of the input blocks, reusing the marks
+// of the old index and reading the deletion-mark.json of the other blocks only.
+func (w *Updater) updateBlockDeletionMarks(ctx context.Context, blocks []*Block, old []*BlockDeletionMark) ([]*BlockDeletionMark, error) {
+	oldMarks := make(map[ulid.ULID]*BlockDeletionMark, len(old))
+	for _, m := range old {
+		oldMarks[m.ID] = m
+	}
+
+	var out []*BlockDeletionMark
+
+	for _, b := range blocks {
+		// Since deletion marks are immutable, the marks already existing in the index can just be copied.
+		if m, ok := oldMarks[b.ID]; ok {
+			out = append(out, m)
+			continue
+		}
+
+		m := &metadata.DeletionMark{}
+		err := metadata.ReadMarker(ctx, w.logger, w.bkt, b.ID.String(), m)
+		if errors.Is(err, metadata.ErrorMarkerNotFound) {
+			continue
+		}
+		if errors.Is(err, metadata.ErrorUnmarshalMarker) {
+			level.Warn(w.logger).Log("msg", "skipped corrupted block deletion mark when updating bucket index", "block", b.ID.String(), "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "read block deletion mark %s", b.ID.String())
+		}
+
+		out = append(out, BlockDeletionMarkFromThanosMarker(m))
+	}
+
+	return out, nil
+}
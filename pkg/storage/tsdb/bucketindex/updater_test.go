@@ -0,0 +1,155 @@
+package bucketindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+)
+
+func TestUpdater_UpdateIndex(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	bkt, cleanup := prepareFilesystemBucket(t)
+	defer cleanup()
+
+	// Generate the initial index.
+	block1 := uploadTestBlock(t, bkt, userID, 10, 20)
+	block2 := uploadTestBlock(t, bkt, userID, 20, 30)
+	block2Mark := markTestBlockForDeletion(t, bkt, userID, block2)
+
+	w := NewUpdater(bkt, userID, logger)
+	returnedIdx, partials, err := w.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, partials)
+	assertBucketIndexEqual(t, returnedIdx, []ulid.ULID{block1, block2}, []*BlockDeletionMark{block2Mark})
+
+	// Create new blocks, a partial block and a block with corrupted meta.json, and update the index.
+	block3 := uploadTestBlock(t, bkt, userID, 30, 40)
+	block4 := uploadTestBlock(t, bkt, userID, 40, 50)
+	block4Mark := markTestBlockForDeletion(t, bkt, userID, block4)
+	block5 := uploadTestBlock(t, bkt, userID, 50, 60)
+	require.NoError(t, bkt.Delete(ctx, path.Join(userID, block5.String(), block.MetaFilename)))
+	block6 := uploadTestBlock(t, bkt, userID, 60, 70)
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, block6.String(), block.MetaFilename), strings.NewReader("invalid!}")))
+
+	returnedIdx, partials, err = w.UpdateIndex(ctx, returnedIdx)
+	require.NoError(t, err)
+	assert.Equal(t, map[ulid.ULID]error{block5: ErrBlockMetaNotFound, block6: ErrBlockMetaCorrupted}, partials)
+	assertBucketIndexEqual(t, returnedIdx, []ulid.ULID{block1, block2, block3, block4}, []*BlockDeletionMark{block2Mark, block4Mark})
+
+	// Hard delete a block and update the index.
+	require.NoError(t, block.Delete(ctx, logger, bucket.NewUserBucketClient(userID, bkt), block2))
+
+	returnedIdx, _, err = w.UpdateIndex(ctx, returnedIdx)
+	require.NoError(t, err)
+	assertBucketIndexEqual(t, returnedIdx, []ulid.ULID{block1, block3, block4}, []*BlockDeletionMark{block4Mark})
+}
+
+func TestUpdater_UpdateIndex_ShouldReuseTheEntriesOfTheOldIndex(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+
+	bkt, cleanup := prepareFilesystemBucket(t)
+	defer cleanup()
+
+	block1 := uploadTestBlock(t, bkt, userID, 10, 20)
+
+	// The old index contains an entry for the block which differs from the meta.json,
+	// and it's expected to be kept as is given blocks are immutable.
+	old := &Index{
+		Version:            IndexVersion1,
+		Blocks:             []*Block{{ID: block1, MinTime: 100, MaxTime: 200, UploadedAt: 300}},
+		BlockDeletionMarks: []*BlockDeletionMark{{ID: block1, DeletionTime: 400}},
+	}
+
+	w := NewUpdater(bkt, userID, log.NewNopLogger())
+	returnedIdx, _, err := w.UpdateIndex(ctx, old)
+	require.NoError(t, err)
+	assert.Equal(t, old.Blocks, returnedIdx.Blocks)
+	assert.Equal(t, old.BlockDeletionMarks, returnedIdx.BlockDeletionMarks)
+}
+
+func TestIndex_RemoveBlock(t *testing.T) {
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+
+	idx := &Index{
+		Blocks:             []*Block{{ID: block1}, {ID: block2}, {ID: block3}},
+		BlockDeletionMarks: []*BlockDeletionMark{{ID: block2}, {ID: block3}},
+	}
+
+	idx.RemoveBlock(block2)
+	assert.Equal(t, []*Block{{ID: block1}, {ID: block3}}, idx.Blocks)
+	assert.Equal(t, []*BlockDeletionMark{{ID: block3}}, idx.BlockDeletionMarks)
+}
+
+func uploadTestBlock(t testing.TB, bkt objstore.Bucket, userID string, minT, maxT int64) ulid.ULID {
+	id := ulid.MustNew(uint64(time.Now().UnixNano()/int64(time.Millisecond)), bytes.NewReader(bytes.Repeat([]byte{byte(minT)}, 16)))
+
+	meta := metadata.Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:    id,
+			MinTime: minT,
+			MaxTime: maxT,
+			Version: metadata.TSDBVersion1,
+		},
+		Thanos: metadata.Thanos{
+			Version: metadata.ThanosVersion1,
+		},
+	}
+
+	content, err := json.Marshal(meta)
+	require.NoError(t, err)
+
+	// The meta.json is uploaded last, like when uploading a real block.
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(userID, id.String(), block.ChunksDirname, "000001"), strings.NewReader("chunks")))
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(userID, id.String(), block.IndexFilename), strings.NewReader("index")))
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(userID, id.String(), block.MetaFilename), bytes.NewReader(content)))
+
+	return id
+}
+
+func markTestBlockForDeletion(t testing.TB, bkt objstore.Bucket, userID string, id ulid.ULID) *BlockDeletionMark {
+	mark := metadata.DeletionMark{
+		ID:           id,
+		DeletionTime: time.Now().Unix(),
+		Version:      metadata.DeletionMarkVersion1,
+	}
+
+	content, err := json.Marshal(mark)
+	require.NoError(t, err)
+	require.NoError(t, bkt.Upload(context.Background(), path.Join(userID, id.String(), metadata.DeletionMarkFilename), bytes.NewReader(content)))
+
+	return BlockDeletionMarkFromThanosMarker(&mark)
+}
+
+func assertBucketIndexEqual(t testing.TB, idx *Index, expectedBlocks []ulid.ULID, expectedMarks []*BlockDeletionMark) {
+	assert.Equal(t, IndexVersion1, idx.Version)
+	assert.InDelta(t, time.Now().Unix(), idx.UpdatedAt, 2)
+	assert.ElementsMatch(t, expectedBlocks, Blocks(idx.Blocks).GetULIDs())
+	assert.ElementsMatch(t, expectedMarks, idx.BlockDeletionMarks)
+
+	for _, b := range idx.Blocks {
+		assert.InDelta(t, time.Now().Unix(), b.UploadedAt, 2)
+	}
+}
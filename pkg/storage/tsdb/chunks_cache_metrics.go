@@ -0,0 +1,89 @@
+package tsdb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/thanos-io/thanos/pkg/cache"
+)
+
+// ChunksCacheMetrics tracks the chunks cache subranges requests and hits for each tenant.
+type ChunksCacheMetrics struct {
+	requests *prometheus.CounterVec
+	hits     *prometheus.CounterVec
+}
+
+func NewChunksCacheMetrics(reg prometheus.Registerer) *ChunksCacheMetrics {
+	return &ChunksCacheMetrics{
+		requests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_chunks_cache_requests_total",
+			Help: "Total number of chunks subranges requested to the chunks cache, per tenant.",
+		}, []string{"user"}),
+		hits: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_bucket_store_chunks_cache_hits_total",
+			Help: "Total number of chunks subranges found in the chunks cache, per tenant.",
+		}, []string{"user"}),
+	}
+}
+
+// RemoveUser removes the metrics of the tenant, once its blocks are not queried anymore.
+func (m *ChunksCacheMetrics) RemoveUser(userID string) {
+	if m == nil {
+		return
+	}
+
+	m.requests.DeleteLabelValues(userID)
+	m.hits.DeleteLabelValues(userID)
+}
+
+// tenantChunksCache wraps the chunks cache to track the subranges requests and hits
+// for each tenant. The chunks cache is shared across all tenants, so the tenant is
+// extracted from the object name included in the cache key.
+type tenantChunksCache struct {
+	cache.Cache
+
+	metrics *ChunksCacheMetrics
+}
+
+func newTenantChunksCache(c cache.Cache, metrics *ChunksCacheMetrics) *tenantChunksCache {
+	return &tenantChunksCache{
+		Cache:   c,
+		metrics: metrics,
+	}
+}
+
+func (c *tenantChunksCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	found := c.Cache.Fetch(ctx, keys)
+
+	for _, key := range keys {
+		userID, ok := userIDFromSubrangeCacheKey(key)
+		if !ok {
+			continue
+		}
+
+		c.metrics.requests.WithLabelValues(userID).Inc()
+		if _, hit := found[key]; hit {
+			c.metrics.hits.WithLabelValues(userID).Inc()
+		}
+	}
+
+	return found
+}
+
+// userIDFromSubrangeCacheKey returns the tenant owning the object of a subrange cache key,
+// which has the format "subrange:<user>/<block>/chunks/<segment>:<start>:<end>".
+func userIDFromSubrangeCacheKey(key string) (string, bool) {
+	parts := strings.SplitN(key, ":", 3)
+	if len(parts) != 3 || parts[0] != "subrange" {
+		return "", false
+	}
+
+	idx := strings.Index(parts[1], "/")
+	if idx <= 0 {
+		return "", false
+	}
+
+	return parts[1][:idx], true
+}
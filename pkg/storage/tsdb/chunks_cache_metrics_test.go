@@ -0,0 +1,97 @@
+package tsdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/thanos-io/thanos/pkg/cache"
+)
+
+func TestUserIDFromSubrangeCacheKey(t *testing.T) {
+	tests := map[string]struct {
+		key      string
+		expected string
+		ok       bool
+	}{
+		"subrange key": {
+			key:      "subrange:user-1/01EQ1FCCTJ4FVXZJ4X6WAV56Z6/chunks/000001:0:16000",
+			expected: "user-1",
+			ok:       true,
+		},
+		"attributes key": {
+			key: "attrs:user-1/01EQ1FCCTJ4FVXZJ4X6WAV56Z6/chunks/000001",
+		},
+		"subrange key without tenant": {
+			key: "subrange:000001:0:16000",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual, ok := userIDFromSubrangeCacheKey(testData.key)
+			assert.Equal(t, testData.ok, ok)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestTenantChunksCache_Fetch(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	c := newTenantChunksCache(&mockCache{data: map[string][]byte{
+		"subrange:user-1/block/chunks/000001:0:16000": []byte("data"),
+	}}, NewChunksCacheMetrics(reg))
+
+	found := c.Fetch(context.Background(), []string{
+		"subrange:user-1/block/chunks/000001:0:16000",
+		"subrange:user-1/block/chunks/000001:16000:32000",
+		"subrange:user-2/block/chunks/000001:0:16000",
+		"attrs:user-2/block/chunks/000001",
+	})
+	assert.Len(t, found, 1)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_chunks_cache_hits_total Total number of chunks subranges found in the chunks cache, per tenant.
+		# TYPE cortex_bucket_store_chunks_cache_hits_total counter
+		cortex_bucket_store_chunks_cache_hits_total{user="user-1"} 1
+
+		# HELP cortex_bucket_store_chunks_cache_requests_total Total number of chunks subranges requested to the chunks cache, per tenant.
+		# TYPE cortex_bucket_store_chunks_cache_requests_total counter
+		cortex_bucket_store_chunks_cache_requests_total{user="user-1"} 2
+		cortex_bucket_store_chunks_cache_requests_total{user="user-2"} 1
+	`)))
+
+	// The metrics of a removed tenant are deleted.
+	c.metrics.RemoveUser("user-1")
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_chunks_cache_requests_total Total number of chunks subranges requested to the chunks cache, per tenant.
+		# TYPE cortex_bucket_store_chunks_cache_requests_total counter
+		cortex_bucket_store_chunks_cache_requests_total{user="user-2"} 1
+	`)))
+}
+
+type mockCache struct {
+	data map[string][]byte
+}
+
+var _ cache.Cache = &mockCache{}
+
+func (m *mockCache) Store(_ context.Context, data map[string][]byte, _ time.Duration) {
+	for k, v := range data {
+		m.data[k] = v
+	}
+}
+
+func (m *mockCache) Fetch(_ context.Context, keys []string) map[string][]byte {
+	found := map[string][]byte{}
+	for _, k := range keys {
+		if v, ok := m.data[k]; ok {
+			found[k] = v
+		}
+	}
+	return found
+}
@@ -2,6 +2,7 @@ package tsdb
 
 import (
 	"flag"
+	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
@@ -13,6 +14,7 @@ import (
 	"github.com/thanos-io/thanos/pkg/store"
 
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/util"
 )
 
 const (
@@ -28,13 +30,26 @@ const (
 	// and can be used to shard blocks.
 	ShardIDExternalLabel = "__shard_id__"
 
+	// CompactorShardIDExternalLabel is the external label containing the shard ID
+	// of the blocks split by the compactor with the split-and-merge compaction strategy.
+	CompactorShardIDExternalLabel = "__compactor_shard_id__"
+
 	// How often are open TSDBs checked for being idle and closed.
 	DefaultCloseIdleTSDBInterval = 5 * time.Minute
 
 	// How often to check for tenant deletion mark.
 	DeletionMarkCheckInterval = 1 * time.Hour
+
+	// BlocksDiscoveryScan discovers blocks by listing the tenant's objects in the bucket.
+	BlocksDiscoveryScan = "scan"
+
+	// BlocksDiscoveryBucketIndex discovers blocks reading the per-tenant bucket index,
+	// falling back to scan the bucket if the index doesn't exist.
+	BlocksDiscoveryBucketIndex = "bucket-index"
 )
 
+var supportedBlocksDiscoveryStrategies = []string{BlocksDiscoveryScan, BlocksDiscoveryBucketIndex}
+
 // Validation errors
 var (
 	errInvalidShipConcurrency       = errors.New("invalid TSDB ship concurrency")
@@ -44,6 +59,8 @@ var (
 	errInvalidWALSegmentSizeBytes   = errors.New("invalid TSDB WAL segment size bytes")
 	errInvalidStripeSize            = errors.New("invalid TSDB stripe size")
 	errEmptyBlockranges             = errors.New("empty block ranges for TSDB")
+
+	errInvalidBlocksDiscoveryStrategy = errors.New("invalid blocks discovery strategy")
 )
 
 // BlocksStorageConfig holds the config information for the blocks storage.
@@ -132,6 +149,10 @@ type TSDBConfig struct {
 	// MaxTSDBOpeningConcurrencyOnStartup limits the number of concurrently opening TSDB's during startup.
 	MaxTSDBOpeningConcurrencyOnStartup int `yaml:"max_tsdb_opening_concurrency_on_startup"`
 
+	// If true, the WAL of a TSDB failing to open on startup is moved aside instead of failing the startup.
+	QuarantineWALOnOpenFailure bool          `yaml:"quarantine_wal_on_open_failure"`
+	QuarantinedWALRetention    time.Duration `yaml:"quarantined_wal_retention"`
+
 	// If true, user TSDBs are not closed on shutdown. Only for testing.
 	// If false (default), user TSDBs are closed to make sure all resources are released and closed properly.
 	KeepUserTSDBOpenOnShutdown bool `yaml:"-"`
@@ -152,6 +173,8 @@ func (cfg *TSDBConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.ShipInterval, "blocks-storage.tsdb.ship-interval", 1*time.Minute, "How frequently the TSDB blocks are scanned and new ones are shipped to the storage. 0 means shipping is disabled.")
 	f.IntVar(&cfg.ShipConcurrency, "blocks-storage.tsdb.ship-concurrency", 10, "Maximum number of tenants concurrently shipping blocks to the storage.")
 	f.IntVar(&cfg.MaxTSDBOpeningConcurrencyOnStartup, "blocks-storage.tsdb.max-tsdb-opening-concurrency-on-startup", 10, "limit the number of concurrently opening TSDB's on startup")
+	f.BoolVar(&cfg.QuarantineWALOnOpenFailure, "blocks-storage.tsdb.quarantine-wal-on-open-failure", false, "True to quarantine the WAL of a tenant whose TSDB fails to open on startup (ie. because of a corrupted WAL) instead of failing the ingester startup. The WAL and head chunks are moved to a 'quarantine-<timestamp>' directory inside the tenant TSDB directory, and the TSDB is opened without them: the samples not compacted into a block yet are not queryable anymore.")
+	f.DurationVar(&cfg.QuarantinedWALRetention, "blocks-storage.tsdb.quarantined-wal-retention", 0, "How long the quarantined WALs are kept on the local disk before being deleted, checked on startup and at each head compaction interval. 0 to keep them until they're manually deleted.")
 	f.DurationVar(&cfg.HeadCompactionInterval, "blocks-storage.tsdb.head-compaction-interval", 1*time.Minute, "How frequently does Cortex try to compact TSDB head. Block is only created if data covers smallest block range. Must be greater than 0 and max 5 minutes.")
 	f.IntVar(&cfg.HeadCompactionConcurrency, "blocks-storage.tsdb.head-compaction-concurrency", 5, "Maximum number of tenants concurrently compacting TSDB head into a new block")
 	f.DurationVar(&cfg.HeadCompactionIdleTimeout, "blocks-storage.tsdb.head-compaction-idle-timeout", 1*time.Hour, "If TSDB head is idle for this duration, it is compacted. 0 means disabled.")
@@ -160,7 +183,7 @@ func (cfg *TSDBConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.WALCompressionEnabled, "blocks-storage.tsdb.wal-compression-enabled", false, "True to enable TSDB WAL compression.")
 	f.IntVar(&cfg.WALSegmentSizeBytes, "blocks-storage.tsdb.wal-segment-size-bytes", wal.DefaultSegmentSize, "TSDB WAL segments files max size (bytes).")
 	f.BoolVar(&cfg.FlushBlocksOnShutdown, "blocks-storage.tsdb.flush-blocks-on-shutdown", false, "True to flush blocks to storage on shutdown. If false, incomplete blocks will be reused after restart.")
-	f.DurationVar(&cfg.CloseIdleTSDBTimeout, "blocks-storage.tsdb.close-idle-tsdb-timeout", 0, "If TSDB has not received any data for this duration, and all blocks from TSDB have been shipped, TSDB is closed and deleted from local disk. If set to positive value, this value should be equal or higher than -querier.query-ingesters-within flag to make sure that TSDB is not closed prematurely, which could cause partial query results. 0 or negative value disables closing of idle TSDB.")
+	f.DurationVar(&cfg.CloseIdleTSDBTimeout, "blocks-storage.tsdb.close-idle-tsdb-timeout", 0, "If TSDB has not received any data for this duration, its head is compacted, all blocks from TSDB are shipped, and then TSDB is closed and deleted from local disk. If set to positive value, this value should be equal or higher than -querier.query-ingesters-within flag to make sure that TSDB is not closed prematurely, which could cause partial query results. 0 or negative value disables closing of idle TSDB.")
 }
 
 // Validate the config.
@@ -220,11 +243,21 @@ type BucketStoreConfig struct {
 	ChunksCache              ChunksCacheConfig   `yaml:"chunks_cache"`
 	MetadataCache            MetadataCacheConfig `yaml:"metadata_cache"`
 	IgnoreDeletionMarksDelay time.Duration       `yaml:"ignore_deletion_mark_delay"`
+	BlocksDiscoveryStrategy  string              `yaml:"blocks_discovery_strategy"`
+
+	// Controls whether the querier lazy loads the blocks of a tenant the first time it's queried.
+	TenantsLazyLoadingEnabled     bool          `yaml:"tenants_lazy_loading_enabled"`
+	TenantsLazyLoadingIdleTimeout time.Duration `yaml:"tenants_lazy_loading_idle_timeout"`
+
+	// Controls whether the querier persists the blocks found to a local snapshot loaded at startup.
+	ScanSnapshotMaxStaleness time.Duration `yaml:"scan_snapshot_max_staleness"`
 
-	// Controls whether index-header lazy loading is enabled. This config option is hidden
-	// while it is marked as experimental.
-	IndexHeaderLazyLoadingEnabled     bool          `yaml:"index_header_lazy_loading_enabled" doc:"hidden"`
-	IndexHeaderLazyLoadingIdleTimeout time.Duration `yaml:"index_header_lazy_loading_idle_timeout" doc:"hidden"`
+	// Controls whether index-header lazy loading is enabled.
+	IndexHeaderLazyLoadingEnabled     bool          `yaml:"index_header_lazy_loading_enabled"`
+	IndexHeaderLazyLoadingIdleTimeout time.Duration `yaml:"index_header_lazy_loading_idle_timeout"`
+
+	// Controls how many blocks are queried at once by the store-gateway while serving a Series() request.
+	SeriesBatchMaxBlocks int `yaml:"series_batch_max_blocks"`
 
 	// Controls what is the ratio of postings offsets store will hold in memory.
 	// Larger value will keep less offsets, which will increase CPU cycles needed for query touching those postings.
@@ -251,8 +284,13 @@ func (cfg *BucketStoreConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.IgnoreDeletionMarksDelay, "blocks-storage.bucket-store.ignore-deletion-marks-delay", time.Hour*6, "Duration after which the blocks marked for deletion will be filtered out while fetching blocks. "+
 		"The idea of ignore-deletion-marks-delay is to ignore blocks that are marked for deletion with some delay. This ensures store can still serve blocks that are meant to be deleted but do not have a replacement yet. "+
 		"Default is 6h, half of the default value for -compactor.deletion-delay.")
+	f.StringVar(&cfg.BlocksDiscoveryStrategy, "blocks-storage.bucket-store.blocks-discovery-strategy", BlocksDiscoveryScan, fmt.Sprintf("How the querier discovers the blocks of each tenant. The %q strategy lists all the tenant's objects in the bucket, while the %q strategy reads the per-tenant bucket index and falls back to listing objects only when the index doesn't exist. Supported values are: %s.", BlocksDiscoveryScan, BlocksDiscoveryBucketIndex, strings.Join(supportedBlocksDiscoveryStrategies, ", ")))
+	f.BoolVar(&cfg.TenantsLazyLoadingEnabled, "blocks-storage.bucket-store.tenants-lazy-loading-enabled", false, "If enabled, the querier discovers the blocks of a tenant the first time the tenant is queried, instead of discovering the blocks of all tenants at startup. Lazy loaded tenants are then kept updated by the periodic scan.")
+	f.DurationVar(&cfg.TenantsLazyLoadingIdleTimeout, "blocks-storage.bucket-store.tenants-lazy-loading-idle-timeout", time.Hour, "If tenants lazy loading is enabled and this setting is > 0, the querier evicts the blocks of a tenant which has not been queried for longer than the timeout. The next query for the tenant lazy loads them again.")
+	f.DurationVar(&cfg.ScanSnapshotMaxStaleness, "blocks-storage.bucket-store.scan-snapshot-max-staleness", 0, "If > 0, the querier persists the blocks found by each successful bucket scan to a snapshot in the sync directory. At startup, the snapshot is loaded if not older than the configured max staleness, and then asynchronously refreshed, so that the querier doesn't have to wait for the initial bucket scan to complete. Ignored when tenants lazy loading is enabled. 0 disables the snapshot.")
 	f.IntVar(&cfg.PostingOffsetsInMemSampling, "blocks-storage.bucket-store.posting-offsets-in-mem-sampling", store.DefaultPostingOffsetInMemorySampling, "Controls what is the ratio of postings offsets that the store will hold in memory.")
-	f.BoolVar(&cfg.IndexHeaderLazyLoadingEnabled, "blocks-storage.bucket-store.index-header-lazy-loading-enabled", false, "If enabled, store-gateway will lazy load an index-header only once required by a query.")
+	f.IntVar(&cfg.SeriesBatchMaxBlocks, "blocks-storage.bucket-store.series-batch-max-blocks", 0, "If > 0, the store-gateway queries the blocks requested by a series request in batches of up to this number of blocks, streaming the series of each batch to the querier before loading the next one. This bounds the memory used by requests touching many blocks, at the cost of series not being sorted across batches: queriers must be running a version supporting it before enabling it. 0 disables batching.")
+	f.BoolVar(&cfg.IndexHeaderLazyLoadingEnabled, "blocks-storage.bucket-store.index-header-lazy-loading-enabled", false, "If enabled, store-gateway will lazy load an index-header only once required by a query, instead of loading all index-headers at startup.")
 	f.DurationVar(&cfg.IndexHeaderLazyLoadingIdleTimeout, "blocks-storage.bucket-store.index-header-lazy-loading-idle-timeout", 20*time.Minute, "If index-header lazy loading is enabled and this setting is > 0, the store-gateway will offload unused index-headers after 'idle timeout' inactivity.")
 }
 
@@ -270,5 +308,8 @@ func (cfg *BucketStoreConfig) Validate() error {
 	if err != nil {
 		return errors.Wrap(err, "metadata-cache configuration")
 	}
+	if !util.StringsContain(supportedBlocksDiscoveryStrategies, cfg.BlocksDiscoveryStrategy) {
+		return errInvalidBlocksDiscoveryStrategy
+	}
 	return nil
 }
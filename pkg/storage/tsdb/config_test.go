@@ -115,6 +115,18 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expectedErr: errInvalidWALSegmentSizeBytes,
 		},
+		"should pass on bucket index blocks discovery strategy": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.BlocksDiscoveryStrategy = BlocksDiscoveryBucketIndex
+			},
+			expectedErr: nil,
+		},
+		"should fail on unknown blocks discovery strategy": {
+			setup: func(cfg *BlocksStorageConfig) {
+				cfg.BucketStore.BlocksDiscoveryStrategy = "unknown"
+			},
+			expectedErr: errInvalidBlocksDiscoveryStrategy,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -40,6 +40,20 @@ func TestIndexCacheConfig_Validate(t *testing.T) {
 				},
 			},
 		},
+		"no redis endpoint should fail": {
+			cfg: IndexCacheConfig{
+				Backend: "redis",
+			},
+			expected: errNoRedisEndpoint,
+		},
+		"one redis endpoint should pass": {
+			cfg: IndexCacheConfig{
+				Backend: "redis",
+				Redis: RedisClientConfig{
+					Endpoint: "localhost:6379",
+				},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -0,0 +1,134 @@
+package tsdb
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	thanos_cache "github.com/thanos-io/thanos/pkg/cache"
+	"github.com/thanos-io/thanos/pkg/cacheutil"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+)
+
+// redisClient adapts the Redis client to the Thanos memcached client interface,
+// so that it can be used as backend for the Thanos index cache.
+type redisClient struct {
+	name   string
+	client *cache.RedisClient
+	logger log.Logger
+}
+
+var _ cacheutil.MemcachedClient = &redisClient{}
+
+func newRedisClient(name string, cfg RedisClientConfig, logger log.Logger) *redisClient {
+	redisCfg := cfg.ToRedisConfig()
+
+	c := &redisClient{
+		name:   name,
+		client: cache.NewRedisClient(&redisCfg),
+		logger: log.With(logger, "name", name),
+	}
+
+	if err := c.client.Ping(context.Background()); err != nil {
+		level.Error(c.logger).Log("msg", "error connecting to redis", "err", err)
+	}
+
+	return c
+}
+
+// GetMulti implements cacheutil.MemcachedClient.
+func (c *redisClient) GetMulti(ctx context.Context, keys []string) map[string][]byte {
+	if len(keys) == 0 {
+		return nil
+	}
+
+	values, err := c.client.MGet(ctx, keys)
+	if err != nil {
+		level.Warn(c.logger).Log("msg", "failed to get items from redis", "items", len(keys), "err", err)
+		return nil
+	}
+
+	hits := make(map[string][]byte, len(keys))
+	for i, value := range values {
+		if value != nil {
+			hits[keys[i]] = value
+		}
+	}
+
+	return hits
+}
+
+// SetAsync implements cacheutil.MemcachedClient. The item is stored synchronously,
+// bounded by the configured redis timeout.
+func (c *redisClient) SetAsync(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.setMulti(ctx, map[string][]byte{key: value}, ttl)
+}
+
+func (c *redisClient) setMulti(ctx context.Context, data map[string][]byte, ttl time.Duration) error {
+	keys := make([]string, 0, len(data))
+	values := make([][]byte, 0, len(data))
+	for key, value := range data {
+		keys = append(keys, key)
+		values = append(values, value)
+	}
+
+	return c.client.MSetWithExpiration(ctx, keys, values, ttl)
+}
+
+// Stop implements cacheutil.MemcachedClient.
+func (c *redisClient) Stop() {
+	if err := c.client.Close(); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to close redis client", "err", err)
+	}
+}
+
+// redisCache is a Redis-based Thanos cache.
+type redisCache struct {
+	client *redisClient
+
+	// Metrics.
+	requests prometheus.Counter
+	hits     prometheus.Counter
+}
+
+var _ thanos_cache.Cache = &redisCache{}
+
+func newRedisCache(client *redisClient, reg prometheus.Registerer) *redisCache {
+	return &redisCache{
+		client: client,
+		requests: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_cache_redis_requests_total",
+			Help:        "Total number of items requests to redis.",
+			ConstLabels: prometheus.Labels{"name": client.name},
+		}),
+		hits: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name:        "cortex_cache_redis_hits_total",
+			Help:        "Total number of items requests to the cache that were a hit.",
+			ConstLabels: prometheus.Labels{"name": client.name},
+		}),
+	}
+}
+
+// Store implements thanos_cache.Cache.
+func (c *redisCache) Store(ctx context.Context, data map[string][]byte, ttl time.Duration) {
+	if len(data) == 0 {
+		return
+	}
+
+	if err := c.client.setMulti(ctx, data, ttl); err != nil {
+		level.Warn(c.client.logger).Log("msg", "failed to store items into redis", "items", len(data), "err", err)
+	}
+}
+
+// Fetch implements thanos_cache.Cache.
+func (c *redisCache) Fetch(ctx context.Context, keys []string) map[string][]byte {
+	c.requests.Add(float64(len(keys)))
+	hits := c.client.GetMulti(ctx, keys)
+	c.hits.Add(float64(len(hits)))
+
+	return hits
+}
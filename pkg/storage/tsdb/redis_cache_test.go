@@ -0,0 +1,67 @@
+package tsdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis"
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisCache(t *testing.T) {
+	server, err := miniredis.Run()
+	require.NoError(t, err)
+	defer server.Close()
+
+	reg := prometheus.NewPedanticRegistry()
+	c, err := createCache("chunks-cache", CacheBackend{
+		Backend: CacheBackendRedis,
+		Redis:   RedisClientConfig{Endpoint: server.Addr(), Timeout: time.Second},
+	}, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	c.Store(ctx, map[string][]byte{
+		"key-1": []byte("value-1"),
+		"key-2": []byte("value-2"),
+	}, time.Minute)
+
+	assert.Equal(t, map[string][]byte{
+		"key-1": []byte("value-1"),
+		"key-2": []byte("value-2"),
+	}, c.Fetch(ctx, []string{"key-1", "key-2", "key-3"}))
+
+	// The input TTL should be honored.
+	server.FastForward(2 * time.Minute)
+	assert.Empty(t, c.Fetch(ctx, []string{"key-1"}))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_cache_redis_hits_total Total number of items requests to the cache that were a hit.
+		# TYPE cortex_cache_redis_hits_total counter
+		cortex_cache_redis_hits_total{name="chunks-cache"} 2
+
+		# HELP cortex_cache_redis_requests_total Total number of items requests to redis.
+		# TYPE cortex_cache_redis_requests_total counter
+		cortex_cache_redis_requests_total{name="chunks-cache"} 4
+	`)))
+}
+
+func TestRedisClient_ShouldNotFailOnUnreachableServer(t *testing.T) {
+	server, err := miniredis.Run()
+	require.NoError(t, err)
+	addr := server.Addr()
+	server.Close()
+
+	c := newRedisClient("index-cache", RedisClientConfig{Endpoint: addr, Timeout: 100 * time.Millisecond}, log.NewNopLogger())
+	defer c.Stop()
+
+	ctx := context.Background()
+	assert.Error(t, c.SetAsync(ctx, "key-1", []byte("value-1"), time.Minute))
+	assert.Empty(t, c.GetMulti(ctx, []string{"key-1"}))
+}
@@ -0,0 +1,63 @@
+package tsdb
+
+import (
+	"flag"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/chunk/cache"
+	"github.com/cortexproject/cortex/pkg/util/flagext"
+)
+
+var errNoRedisEndpoint = errors.New("no redis endpoint")
+
+type RedisClientConfig struct {
+	Endpoint           string         `yaml:"endpoint"`
+	MasterName         string         `yaml:"master_name"`
+	Timeout            time.Duration  `yaml:"timeout"`
+	DB                 int            `yaml:"db"`
+	PoolSize           int            `yaml:"pool_size"`
+	Password           flagext.Secret `yaml:"password"`
+	EnableTLS          bool           `yaml:"tls_enabled"`
+	InsecureSkipVerify bool           `yaml:"tls_insecure_skip_verify"`
+	IdleTimeout        time.Duration  `yaml:"idle_timeout"`
+	MaxConnAge         time.Duration  `yaml:"max_connection_age"`
+}
+
+func (cfg *RedisClientConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.StringVar(&cfg.Endpoint, prefix+"endpoint", "", "Redis server endpoint to use for caching. A comma-separated list of endpoints for Redis Cluster or Redis Sentinel.")
+	f.StringVar(&cfg.MasterName, prefix+"master-name", "", "Redis Sentinel master name. An empty string for Redis Server or Redis Cluster.")
+	f.DurationVar(&cfg.Timeout, prefix+"timeout", 500*time.Millisecond, "Maximum time to wait before giving up on redis requests.")
+	f.IntVar(&cfg.DB, prefix+"db", 0, "Database index.")
+	f.IntVar(&cfg.PoolSize, prefix+"pool-size", 0, "Maximum number of connections in the pool. If set to 0, the redis client default is used.")
+	f.Var(&cfg.Password, prefix+"password", "Password to use when connecting to redis.")
+	f.BoolVar(&cfg.EnableTLS, prefix+"tls-enabled", false, "Enable connecting to redis with TLS.")
+	f.BoolVar(&cfg.InsecureSkipVerify, prefix+"tls-insecure-skip-verify", false, "Skip validating server certificate.")
+	f.DurationVar(&cfg.IdleTimeout, prefix+"idle-timeout", 0, "Close connections after remaining idle for this duration. If the value is zero, then idle connections are not closed.")
+	f.DurationVar(&cfg.MaxConnAge, prefix+"max-connection-age", 0, "Close connections older than this duration. If the value is zero, then the pool does not close connections based on age.")
+}
+
+// Validate the config.
+func (cfg *RedisClientConfig) Validate() error {
+	if cfg.Endpoint == "" {
+		return errNoRedisEndpoint
+	}
+
+	return nil
+}
+
+func (cfg RedisClientConfig) ToRedisConfig() cache.RedisConfig {
+	return cache.RedisConfig{
+		Endpoint:           cfg.Endpoint,
+		MasterName:         cfg.MasterName,
+		Timeout:            cfg.Timeout,
+		DB:                 cfg.DB,
+		PoolSize:           cfg.PoolSize,
+		Password:           cfg.Password,
+		EnableTLS:          cfg.EnableTLS,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		IdleTimeout:        cfg.IdleTimeout,
+		MaxConnAge:         cfg.MaxConnAge,
+	}
+}
@@ -7,8 +7,10 @@ import (
 	"path"
 	"time"
 
+	"github.com/go-kit/kit/log"
 	"github.com/pkg/errors"
 	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
 )
 
 // Relative to user-specific prefix.
@@ -38,3 +40,24 @@ func WriteTenantDeletionMark(ctx context.Context, bkt objstore.Bucket, userID st
 	markerFile := path.Join(userID, TenantDeletionMarkPath)
 	return errors.Wrap(bkt.Upload(ctx, markerFile, bytes.NewReader(data)), "upload tenant deletion mark")
 }
+
+// Returns the tenant deletion mark, or nil if the tenant is not marked for deletion.
+func ReadTenantDeletionMark(ctx context.Context, bkt objstore.BucketReader, userID string, logger log.Logger) (*TenantDeletionMark, error) {
+	markerFile := path.Join(userID, TenantDeletionMarkPath)
+
+	r, err := bkt.Get(ctx, markerFile)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read tenant deletion mark %s", markerFile)
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close tenant deletion mark reader")
+
+	m := &TenantDeletionMark{}
+	if err := json.NewDecoder(r).Decode(m); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode tenant deletion mark %s", markerFile)
+	}
+
+	return m, nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/go-kit/kit/log"
 	"github.com/stretchr/testify/require"
 	"github.com/thanos-io/thanos/pkg/objstore"
 )
@@ -49,3 +50,21 @@ func TestTenantDeletionMarkExists(t *testing.T) {
 		})
 	}
 }
+
+func TestReadTenantDeletionMark(t *testing.T) {
+	const username = "user"
+
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+
+	mark, err := ReadTenantDeletionMark(ctx, bkt, username, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Nil(t, mark)
+
+	require.NoError(t, WriteTenantDeletionMark(ctx, bkt, username))
+
+	mark, err = ReadTenantDeletionMark(ctx, bkt, username, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NotNil(t, mark)
+	require.NotZero(t, mark.DeletionTime)
+}
@@ -0,0 +1,150 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"hash/fnv"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/thanos-io/thanos/pkg/runutil"
+)
+
+// Relative to user-specific prefix.
+const TombstonesPath = "tombstones"
+
+type TombstoneState string
+
+const (
+	// TombstonePending is the state of a tombstone whose series haven't been
+	// removed from the blocks yet.
+	TombstonePending TombstoneState = "pending"
+
+	// TombstoneProcessed is the state of a tombstone whose series have been
+	// removed from all the blocks in the storage.
+	TombstoneProcessed TombstoneState = "processed"
+)
+
+// Tombstone is a series deletion request stored in the bucket.
+type Tombstone struct {
+	RequestID string   `json:"request_id"`
+	StartTime int64    `json:"start_time"`
+	EndTime   int64    `json:"end_time"`
+	Selectors []string `json:"selectors"`
+
+	// Unix timestamp (milliseconds) when the deletion request was received.
+	RequestCreatedAt int64          `json:"request_created_at"`
+	State            TombstoneState `json:"state"`
+}
+
+// NewTombstone returns a pending tombstone. The request ID is derived from the
+// tombstone content, so that the same deletion request is stored only once.
+func NewTombstone(userID string, createdAt, startTime, endTime int64, selectors []string) *Tombstone {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(userID))
+	_, _ = h.Write([]byte(strconv.FormatInt(startTime, 10)))
+	_, _ = h.Write([]byte(strconv.FormatInt(endTime, 10)))
+	_, _ = h.Write([]byte(strings.Join(selectors, "\000")))
+
+	return &Tombstone{
+		RequestID:        hex.EncodeToString(h.Sum(nil)),
+		StartTime:        startTime,
+		EndTime:          endTime,
+		Selectors:        selectors,
+		RequestCreatedAt: createdAt,
+		State:            TombstonePending,
+	}
+}
+
+// Matchers returns the parsed selectors of the tombstone.
+func (t *Tombstone) Matchers() ([][]*labels.Matcher, error) {
+	matchers := make([][]*labels.Matcher, 0, len(t.Selectors))
+
+	for _, selector := range t.Selectors {
+		m, err := parser.ParseMetricSelector(selector)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse selector %s", selector)
+		}
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
+
+// Uploads the tombstone to the tenant "directory", overwriting any existing
+// tombstone with the same request ID.
+func WriteTombstone(ctx context.Context, bkt objstore.Bucket, userID string, t *Tombstone) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "serialize tombstone")
+	}
+
+	return errors.Wrap(bkt.Upload(ctx, tombstonePath(userID, t.RequestID), bytes.NewReader(data)), "upload tombstone")
+}
+
+// Returns the tombstone with the given request ID, or nil if it doesn't exist.
+func ReadTombstone(ctx context.Context, bkt objstore.BucketReader, userID, requestID string, logger log.Logger) (*Tombstone, error) {
+	tombstoneFile := tombstonePath(userID, requestID)
+
+	r, err := bkt.Get(ctx, tombstoneFile)
+	if err != nil {
+		if bkt.IsObjNotFoundErr(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to read tombstone %s", tombstoneFile)
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close tombstone reader")
+
+	t := &Tombstone{}
+	if err := json.NewDecoder(r).Decode(t); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode tombstone %s", tombstoneFile)
+	}
+
+	return t, nil
+}
+
+// Returns all the tombstones of the tenant.
+func ReadTombstones(ctx context.Context, bkt objstore.BucketReader, userID string, logger log.Logger) ([]*Tombstone, error) {
+	var tombstones []*Tombstone
+
+	err := bkt.Iter(ctx, path.Join(userID, TombstonesPath), func(name string) error {
+		requestID := strings.TrimSuffix(path.Base(name), ".json")
+		if requestID == path.Base(name) {
+			return nil
+		}
+
+		t, err := ReadTombstone(ctx, bkt, userID, requestID, logger)
+		if err != nil {
+			return err
+		}
+
+		// The tombstone may have been deleted in the meanwhile.
+		if t != nil {
+			tombstones = append(tombstones, t)
+		}
+		return nil
+	})
+
+	return tombstones, err
+}
+
+// Deletes the tombstone with the given request ID. It's not an error if the tombstone doesn't exist.
+func DeleteTombstone(ctx context.Context, bkt objstore.Bucket, userID, requestID string) error {
+	err := bkt.Delete(ctx, tombstonePath(userID, requestID))
+	if err != nil && !bkt.IsObjNotFoundErr(err) {
+		return errors.Wrap(err, "delete tombstone")
+	}
+	return nil
+}
+
+func tombstonePath(userID, requestID string) string {
+	return path.Join(userID, TombstonesPath, requestID+".json")
+}
@@ -0,0 +1,83 @@
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/objstore"
+)
+
+func TestNewTombstone(t *testing.T) {
+	t1 := NewTombstone("user-1", 100, 10, 20, []string{`{job="a"}`})
+	assert.Equal(t, TombstonePending, t1.State)
+	assert.NotEmpty(t, t1.RequestID)
+
+	// The same request should get the same ID, regardless of when it has been received.
+	assert.Equal(t, t1.RequestID, NewTombstone("user-1", 200, 10, 20, []string{`{job="a"}`}).RequestID)
+
+	assert.NotEqual(t, t1.RequestID, NewTombstone("user-2", 100, 10, 20, []string{`{job="a"}`}).RequestID)
+	assert.NotEqual(t, t1.RequestID, NewTombstone("user-1", 100, 10, 21, []string{`{job="a"}`}).RequestID)
+	assert.NotEqual(t, t1.RequestID, NewTombstone("user-1", 100, 10, 20, []string{`{job="b"}`}).RequestID)
+}
+
+func TestTombstone_Matchers(t *testing.T) {
+	matchers, err := NewTombstone("user-1", 0, 0, 0, []string{`{job="a"}`, `up{instance=~"b.*"}`}).Matchers()
+	require.NoError(t, err)
+	assert.Equal(t, [][]*labels.Matcher{
+		{labels.MustNewMatcher(labels.MatchEqual, "job", "a")},
+		{labels.MustNewMatcher(labels.MatchRegexp, "instance", "b.*"), labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up")},
+	}, matchers)
+
+	_, err = NewTombstone("user-1", 0, 0, 0, []string{`{job=`}).Matchers()
+	assert.Error(t, err)
+}
+
+func TestWriteAndReadTombstones(t *testing.T) {
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+
+	// No tombstones.
+	actual, err := ReadTombstones(ctx, bkt, "user-1", log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Empty(t, actual)
+
+	t1 := NewTombstone("user-1", 100, 10, 20, []string{`{job="a"}`})
+	t2 := NewTombstone("user-1", 100, 30, 40, []string{`{job="b"}`})
+	t3 := NewTombstone("user-2", 100, 30, 40, []string{`{job="b"}`})
+	for _, ts := range []*Tombstone{t1, t2} {
+		require.NoError(t, WriteTombstone(ctx, bkt, "user-1", ts))
+	}
+	require.NoError(t, WriteTombstone(ctx, bkt, "user-2", t3))
+
+	// Unrelated objects should be ignored.
+	require.NoError(t, bkt.Upload(ctx, "user-1/tombstones/unknown", bytes.NewReader(nil)))
+
+	actual, err = ReadTombstones(ctx, bkt, "user-1", log.NewNopLogger())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []*Tombstone{t1, t2}, actual)
+
+	// Update the state of a tombstone.
+	t1.State = TombstoneProcessed
+	require.NoError(t, WriteTombstone(ctx, bkt, "user-1", t1))
+
+	read, err := ReadTombstone(ctx, bkt, "user-1", t1.RequestID, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, t1, read)
+
+	// Delete a tombstone.
+	require.NoError(t, DeleteTombstone(ctx, bkt, "user-1", t2.RequestID))
+	require.NoError(t, DeleteTombstone(ctx, bkt, "user-1", "not-existing"))
+
+	read, err = ReadTombstone(ctx, bkt, "user-1", t2.RequestID, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Nil(t, read)
+
+	actual, err = ReadTombstones(ctx, bkt, "user-1", log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, []*Tombstone{t1}, actual)
+}
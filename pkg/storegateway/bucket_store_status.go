@@ -0,0 +1,243 @@
+package storegateway
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/thanos-io/thanos/pkg/block"
+	thanos_metadata "github.com/thanos-io/thanos/pkg/block/metadata"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// Index-header states reported by the store-gateway status pages.
+const (
+	// The index-header is loaded in memory.
+	indexHeaderLoaded = "loaded"
+
+	// The index-header lazy loading is enabled and only some of the tenant index-headers
+	// are currently loaded. The per-block state is not tracked by the underlying store.
+	indexHeaderLazy = "lazy"
+
+	// The index-header lazy loading is enabled and none of the tenant index-headers are
+	// currently loaded. They will be loaded at the first query touching the block.
+	indexHeaderOffloaded = "offloaded"
+
+	// The index-header doesn't exist on the local disk, which means the block has not
+	// been loaded yet or failed to load.
+	indexHeaderMissing = "missing"
+)
+
+// TenantStatus is the status of a tenant bucket store, as shown in the store-gateway tenants page.
+type TenantStatus struct {
+	UserID string `json:"user_id"`
+
+	// Whether the tenant belonged to the store-gateway shard at the last sync.
+	Owned bool `json:"owned"`
+
+	NumBlocks             int    `json:"num_blocks"`
+	BlocksSizeBytes       int64  `json:"blocks_size_bytes"`
+	IndexHeadersSizeBytes int64  `json:"index_headers_size_bytes"`
+	NumLoadedIndexHeaders int    `json:"num_loaded_index_headers"`
+	LastSync              int64  `json:"last_sync"`
+	LastSyncDuration      string `json:"last_sync_duration"`
+	LastSyncError         string `json:"last_sync_error,omitempty"`
+}
+
+// BlockStatus is the status of a block synced by a tenant bucket store.
+type BlockStatus struct {
+	ID                   string `json:"id"`
+	MinTime              int64  `json:"min_time"`
+	MaxTime              int64  `json:"max_time"`
+	CompactionLevel      int    `json:"compaction_level"`
+	NumSeries            uint64 `json:"num_series"`
+	SizeBytes            int64  `json:"size_bytes"`
+	IndexHeaderSizeBytes int64  `json:"index_header_size_bytes"`
+	IndexHeaderState     string `json:"index_header_state"`
+}
+
+// bucketStoreStatus keeps track of the status of a tenant bucket store.
+type bucketStoreStatus struct {
+	fetcher *blocksRecordingFetcher
+	reg     *prometheus.Registry
+
+	mtx              sync.Mutex
+	lastSync         time.Time
+	lastSyncDuration time.Duration
+	lastSyncErr      error
+}
+
+func (s *bucketStoreStatus) recordSync(start time.Time, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.lastSync = start
+	s.lastSyncDuration = time.Since(start)
+	s.lastSyncErr = err
+}
+
+// blocksRecordingFetcher wraps a MetadataFetcher to keep track of the blocks
+// returned by the last fetch, which are the blocks synced by the bucket store.
+type blocksRecordingFetcher struct {
+	block.MetadataFetcher
+
+	mtx   sync.Mutex
+	metas map[ulid.ULID]*thanos_metadata.Meta
+}
+
+func newBlocksRecordingFetcher(fetcher block.MetadataFetcher) *blocksRecordingFetcher {
+	return &blocksRecordingFetcher{MetadataFetcher: fetcher}
+}
+
+// Fetch implements block.MetadataFetcher.
+func (f *blocksRecordingFetcher) Fetch(ctx context.Context) (map[ulid.ULID]*thanos_metadata.Meta, map[ulid.ULID]error, error) {
+	metas, partial, err := f.MetadataFetcher.Fetch(ctx)
+
+	// The bucket store syncs the fetched blocks even in case of a partial view.
+	if metas != nil {
+		f.mtx.Lock()
+		f.metas = metas
+		f.mtx.Unlock()
+	}
+
+	return metas, partial, err
+}
+
+func (f *blocksRecordingFetcher) lastMetas() []*thanos_metadata.Meta {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	metas := make([]*thanos_metadata.Meta, 0, len(f.metas))
+	for _, m := range f.metas {
+		metas = append(metas, m)
+	}
+	return metas
+}
+
+// TenantsStatus returns the status of the tenants for which this store-gateway has a bucket store.
+func (u *BucketStores) TenantsStatus() []TenantStatus {
+	u.storesMu.RLock()
+	userIDs := make([]string, 0, len(u.statuses))
+	for userID := range u.statuses {
+		userIDs = append(userIDs, userID)
+	}
+	u.storesMu.RUnlock()
+
+	sort.Strings(userIDs)
+
+	tenants := make([]TenantStatus, 0, len(userIDs))
+	for _, userID := range userIDs {
+		tenant, _, ok := u.TenantBlocks(userID)
+		if ok {
+			tenants = append(tenants, tenant)
+		}
+	}
+	return tenants
+}
+
+// TenantBlocks returns the status of a tenant and of the blocks synced for it. The returned bool
+// is false if this store-gateway has no bucket store for the tenant.
+func (u *BucketStores) TenantBlocks(userID string) (TenantStatus, []BlockStatus, bool) {
+	u.storesMu.RLock()
+	status := u.statuses[userID]
+	_, owned := u.ownedUsers[userID]
+	u.storesMu.RUnlock()
+
+	if status == nil {
+		return TenantStatus{}, nil, false
+	}
+
+	tenant := TenantStatus{UserID: userID, Owned: owned}
+
+	status.mtx.Lock()
+	if !status.lastSync.IsZero() {
+		tenant.LastSync = util.TimeToMillis(status.lastSync)
+		tenant.LastSyncDuration = status.lastSyncDuration.Round(time.Millisecond).String()
+	}
+	if status.lastSyncErr != nil {
+		tenant.LastSyncError = status.lastSyncErr.Error()
+	}
+	status.mtx.Unlock()
+
+	metas := status.fetcher.lastMetas()
+	sort.Slice(metas, func(i, j int) bool {
+		if metas[i].MinTime != metas[j].MinTime {
+			return metas[i].MinTime < metas[j].MinTime
+		}
+		return metas[i].ULID.Compare(metas[j].ULID) < 0
+	})
+
+	blocks := make([]BlockStatus, 0, len(metas))
+	numHeadersOnDisk := 0
+	for _, m := range metas {
+		b := BlockStatus{
+			ID:               m.ULID.String(),
+			MinTime:          m.MinTime,
+			MaxTime:          m.MaxTime,
+			CompactionLevel:  m.Compaction.Level,
+			NumSeries:        m.Stats.NumSeries,
+			IndexHeaderState: indexHeaderMissing,
+		}
+		for _, f := range m.Thanos.Files {
+			b.SizeBytes += f.SizeBytes
+		}
+		if info, err := os.Stat(filepath.Join(u.cfg.BucketStore.SyncDir, userID, m.ULID.String(), block.IndexHeaderFilename)); err == nil {
+			b.IndexHeaderSizeBytes = info.Size()
+			numHeadersOnDisk++
+		}
+
+		tenant.BlocksSizeBytes += b.SizeBytes
+		tenant.IndexHeadersSizeBytes += b.IndexHeaderSizeBytes
+		blocks = append(blocks, b)
+	}
+
+	tenant.NumBlocks = len(blocks)
+	tenant.NumLoadedIndexHeaders = numHeadersOnDisk
+	if u.cfg.BucketStore.IndexHeaderLazyLoadingEnabled {
+		tenant.NumLoadedIndexHeaders = util.Min(numHeadersOnDisk, loadedLazyIndexHeaders(status.reg))
+	}
+
+	// When the lazy loading is enabled, we only know how many index-headers are loaded,
+	// so the state of a single block is known only if all or none of them are loaded.
+	state := indexHeaderLazy
+	switch {
+	case tenant.NumLoadedIndexHeaders == numHeadersOnDisk:
+		state = indexHeaderLoaded
+	case tenant.NumLoadedIndexHeaders == 0:
+		state = indexHeaderOffloaded
+	}
+	for i := range blocks {
+		if blocks[i].IndexHeaderSizeBytes > 0 {
+			blocks[i].IndexHeaderState = state
+		}
+	}
+
+	return tenant, blocks, true
+}
+
+// loadedLazyIndexHeaders returns the number of lazy loaded index-headers currently
+// loaded, based on the metrics of a tenant bucket store.
+func loadedLazyIndexHeaders(reg prometheus.Gatherer) int {
+	families, err := reg.Gather()
+	if err != nil {
+		return 0
+	}
+
+	mfm, err := util.NewMetricFamilyMap(families)
+	if err != nil {
+		return 0
+	}
+
+	loaded := mfm.SumCounters("thanos_bucket_store_indexheader_lazy_load_total") -
+		mfm.SumCounters("thanos_bucket_store_indexheader_lazy_load_failed_total") -
+		mfm.SumCounters("thanos_bucket_store_indexheader_lazy_unload_total") +
+		mfm.SumCounters("thanos_bucket_store_indexheader_lazy_unload_failed_total")
+
+	return util.Max(0, int(loaded))
+}
@@ -0,0 +1,179 @@
+package storegateway
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/extprom"
+	"github.com/thanos-io/thanos/pkg/store"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+func TestBucketStores_TenantBlocks(t *testing.T) {
+	for _, lazyLoadingEnabled := range []bool{false, true} {
+		t.Run(map[bool]string{false: "lazy loading disabled", true: "lazy loading enabled"}[lazyLoadingEnabled], func(t *testing.T) {
+			ctx := context.Background()
+			cfg, cleanup := prepareStorageConfig(t)
+			cfg.BucketStore.IndexHeaderLazyLoadingEnabled = lazyLoadingEnabled
+			cfg.BucketStore.IndexHeaderLazyLoadingIdleTimeout = time.Hour
+			defer cleanup()
+
+			storageDir, err := ioutil.TempDir(os.TempDir(), "storage-*")
+			require.NoError(t, err)
+			defer os.RemoveAll(storageDir) //nolint:errcheck
+
+			generateStorageBlock(t, storageDir, "user-1", "series_1", 10, 100, 15)
+			generateStorageBlock(t, storageDir, "user-1", "series_1", 100, 200, 15)
+			generateStorageBlock(t, storageDir, "user-2", "series_2", 10, 100, 15)
+
+			bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+			require.NoError(t, err)
+
+			stores, err := NewBucketStores(cfg, NewNoShardingStrategy(), bucket, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			// No tenant is known before the initial sync.
+			assert.Empty(t, stores.TenantsStatus())
+			_, _, ok := stores.TenantBlocks("user-1")
+			assert.False(t, ok)
+
+			require.NoError(t, stores.InitialSync(ctx))
+
+			tenants := stores.TenantsStatus()
+			require.Len(t, tenants, 2)
+			assert.Equal(t, "user-1", tenants[0].UserID)
+			assert.Equal(t, "user-2", tenants[1].UserID)
+
+			tenant, blocks, ok := stores.TenantBlocks("user-1")
+			require.True(t, ok)
+			assert.True(t, tenant.Owned)
+			assert.Equal(t, 2, tenant.NumBlocks)
+			assert.Greater(t, tenant.IndexHeadersSizeBytes, int64(0))
+			assert.NotZero(t, tenant.LastSync)
+			assert.Empty(t, tenant.LastSyncError)
+
+			require.Len(t, blocks, 2)
+			assert.Equal(t, int64(10), blocks[0].MinTime)
+			assert.Equal(t, int64(100), blocks[1].MinTime)
+			assert.Equal(t, uint64(1), blocks[0].NumSeries)
+
+			expectedState := indexHeaderLoaded
+			if lazyLoadingEnabled {
+				expectedState = indexHeaderOffloaded
+			}
+			for _, b := range blocks {
+				assert.Greater(t, b.IndexHeaderSizeBytes, int64(0))
+				assert.Equal(t, expectedState, b.IndexHeaderState)
+			}
+
+			// Querying a single block loads its index-header.
+			_, _, err = querySeries(stores, "user-1", "series_1", 20, 40)
+			require.NoError(t, err)
+
+			tenant, blocks, ok = stores.TenantBlocks("user-1")
+			require.True(t, ok)
+
+			if lazyLoadingEnabled {
+				assert.Equal(t, 1, tenant.NumLoadedIndexHeaders)
+				assert.Equal(t, indexHeaderLazy, blocks[0].IndexHeaderState)
+			} else {
+				assert.Equal(t, 2, tenant.NumLoadedIndexHeaders)
+				assert.Equal(t, indexHeaderLoaded, blocks[0].IndexHeaderState)
+			}
+		})
+	}
+}
+
+func TestBucketStores_TenantBlocksShouldTrackSyncErrorsAndOwnership(t *testing.T) {
+	ctx := context.Background()
+	cfg, cleanup := prepareStorageConfig(t)
+	defer cleanup()
+
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	generateStorageBlock(t, storageDir, "user-1", "series_1", 10, 100, 15)
+	generateStorageBlock(t, storageDir, "user-2", "series_2", 10, 100, 15)
+
+	bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	sharding := &staticUsersShardingStrategy{ownedUsers: []string{"user-1", "user-2"}}
+	stores, err := NewBucketStores(cfg, sharding, bucket, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, stores.InitialSync(ctx))
+
+	tenant, _, ok := stores.TenantBlocks("user-2")
+	require.True(t, ok)
+	assert.True(t, tenant.Owned)
+
+	// The tenant moved out of the shard is still listed until its store is removed, but it's not owned anymore.
+	sharding.ownedUsers = []string{"user-1"}
+	require.Error(t, stores.syncUsersBlocks(ctx, func(ctx context.Context, s *store.BucketStore) error {
+		return errors.New("sync failed")
+	}))
+
+	tenant, _, ok = stores.TenantBlocks("user-2")
+	require.True(t, ok)
+	assert.False(t, tenant.Owned)
+	assert.Equal(t, "sync failed", tenant.LastSyncError)
+}
+
+func TestStoreGateway_StatusPagesTemplates(t *testing.T) {
+	now := time.Now()
+	tenant := TenantStatus{UserID: "user-1", Owned: true, NumBlocks: 1, LastSync: util.TimeToMillis(now), LastSyncError: "<failed>"}
+
+	req := httptest.NewRequest("GET", "/store-gateway/tenants", nil)
+	rec := httptest.NewRecorder()
+	util.RenderHTTPResponse(rec, struct {
+		Now                time.Time
+		LazyLoadingEnabled bool
+		Tenants            []TenantStatus
+	}{Now: now, Tenants: []TenantStatus{tenant}}, tenantsPageTemplate, req)
+
+	assert.Contains(t, rec.Body.String(), `<a href="tenant/user-1/blocks">user-1</a>`)
+	assert.Contains(t, rec.Body.String(), "&lt;failed&gt;")
+
+	req = httptest.NewRequest("GET", "/store-gateway/tenant/user-1/blocks", nil)
+	rec = httptest.NewRecorder()
+	util.RenderHTTPResponse(rec, struct {
+		Now    time.Time
+		Tenant TenantStatus
+		Blocks []BlockStatus
+	}{Now: now, Tenant: tenant, Blocks: []BlockStatus{{ID: "block-1", MinTime: 10, MaxTime: 20, IndexHeaderState: indexHeaderLoaded}}}, tenantBlocksPageTemplate, req)
+
+	assert.Contains(t, rec.Body.String(), "<td>block-1</td>")
+	assert.Contains(t, rec.Body.String(), "<td>loaded</td>")
+}
+
+// staticUsersShardingStrategy is a ShardingStrategy owning a fixed set of tenants and all their blocks.
+type staticUsersShardingStrategy struct {
+	ownedUsers []string
+}
+
+func (s *staticUsersShardingStrategy) FilterUsers(_ context.Context, userIDs []string) []string {
+	var filtered []string
+	for _, userID := range userIDs {
+		if util.StringsContain(s.ownedUsers, userID) {
+			filtered = append(filtered, userID)
+		}
+	}
+	return filtered
+}
+
+func (s *staticUsersShardingStrategy) FilterBlocks(_ context.Context, _ string, _ map[ulid.ULID]*metadata.Meta, _ *extprom.TxGaugeVec) error {
+	return nil
+}
@@ -3,6 +3,8 @@ package storegateway
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -42,6 +44,8 @@ type BucketStores struct {
 	logLevel           logging.Level
 	bucketStoreMetrics *BucketStoreMetrics
 	metaFetcherMetrics *MetadataFetcherMetrics
+	chunksCacheMetrics *tsdb.ChunksCacheMetrics
+	rateLimitedBucket  *bucket.RateLimitedBucketClient
 	shardingStrategy   ShardingStrategy
 
 	// Index cache shared across all tenants.
@@ -54,16 +58,32 @@ type BucketStores struct {
 	storesMu sync.RWMutex
 	stores   map[string]*store.BucketStore
 
+	// Keeps the status of each tenant bucket store and the tenants belonging to the
+	// store-gateway shard as of the last sync. Protected by storesMu.
+	statuses   map[string]*bucketStoreStatus
+	ownedUsers map[string]struct{}
+
 	// Metrics.
 	syncTimes         prometheus.Histogram
 	syncLastSuccess   prometheus.Gauge
 	tenantsDiscovered prometheus.Gauge
 	tenantsSynced     prometheus.Gauge
+
+	batchedQueriesDropped prometheus.Counter
 }
 
 // NewBucketStores makes a new BucketStores.
 func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStrategy, bucketClient objstore.Bucket, limits *validation.Overrides, logLevel logging.Level, logger log.Logger, reg prometheus.Registerer) (*BucketStores, error) {
-	cachingBucket, err := tsdb.CreateCachingBucket(cfg.BucketStore.ChunksCache, cfg.BucketStore.MetadataCache, bucketClient, logger, reg)
+	// The per-tenant rate limits are enforced below the caching layer, so that
+	// only the operations actually sent to the object storage are rate limited.
+	var rateLimitedBucket *bucket.RateLimitedBucketClient
+	if limits != nil {
+		rateLimitedBucket = bucket.NewTenantRateLimitedBucketClient(bucketClient, bucketRateLimitsProvider{limits: limits}, reg)
+		bucketClient = rateLimitedBucket
+	}
+
+	chunksCacheMetrics := tsdb.NewChunksCacheMetrics(reg)
+	cachingBucket, err := tsdb.CreateCachingBucket(cfg.BucketStore.ChunksCache, cfg.BucketStore.MetadataCache, bucketClient, chunksCacheMetrics, logger, reg)
 	if err != nil {
 		return nil, errors.Wrapf(err, "create caching bucket")
 	}
@@ -83,9 +103,13 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 		bucket:             cachingBucket,
 		shardingStrategy:   shardingStrategy,
 		stores:             map[string]*store.BucketStore{},
+		statuses:           map[string]*bucketStoreStatus{},
+		ownedUsers:         map[string]struct{}{},
 		logLevel:           logLevel,
 		bucketStoreMetrics: NewBucketStoreMetrics(),
 		metaFetcherMetrics: NewMetadataFetcherMetrics(),
+		chunksCacheMetrics: chunksCacheMetrics,
+		rateLimitedBucket:  rateLimitedBucket,
 		queryGate:          queryGate,
 		syncTimes: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
 			Name:    "cortex_bucket_stores_blocks_sync_seconds",
@@ -104,6 +128,10 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 			Name: "cortex_bucket_stores_tenants_synced",
 			Help: "Number of tenants synced.",
 		}),
+		batchedQueriesDropped: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_bucket_stores_batched_queries_dropped_total",
+			Help: "Number of series requests queried in batches of blocks which have been dropped because the chunks fetched by all the batches exceeded the limit.",
+		}),
 	}
 
 	// Init the index cache.
@@ -129,10 +157,43 @@ func (u *BucketStores) InitialSync(ctx context.Context) error {
 		return err
 	}
 
+	// The local index-headers of the tenants we own are re-used across restarts, while
+	// the ones of tenants we don't own anymore would never be removed otherwise.
+	u.deleteLocalFilesForExcludedTenants()
+
 	level.Info(u.logger).Log("msg", "successfully synchronized TSDB blocks for all users")
 	return nil
 }
 
+// deleteLocalFilesForExcludedTenants removes the local sync directory of each tenant
+// for which this store-gateway has no bucket store (ie. tenants not belonging to its
+// shard or no longer existing in the bucket). The index-headers of the tenants we own
+// are neither validated nor evicted here: the bucket store re-uses them if their TOC
+// checksum is valid, and removes them along with their block.
+func (u *BucketStores) deleteLocalFilesForExcludedTenants() {
+	entries, err := ioutil.ReadDir(u.cfg.BucketStore.SyncDir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			level.Warn(u.logger).Log("msg", "failed to read local sync directory", "dir", u.cfg.BucketStore.SyncDir, "err", err)
+		}
+		return
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || u.getStore(entry.Name()) != nil {
+			continue
+		}
+
+		dir := filepath.Join(u.cfg.BucketStore.SyncDir, entry.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			level.Warn(u.logger).Log("msg", "failed to remove local files of tenant not belonging to this store-gateway", "user", entry.Name(), "dir", dir, "err", err)
+			continue
+		}
+
+		level.Info(u.logger).Log("msg", "removed local files of tenant not belonging to this store-gateway", "user", entry.Name())
+	}
+}
+
 // SyncBlocks synchronizes the stores state with the Bucket store for every user.
 func (u *BucketStores) SyncBlocks(ctx context.Context) error {
 	return u.syncUsersBlocks(ctx, func(ctx context.Context, s *store.BucketStore) error {
@@ -151,6 +212,7 @@ func (u *BucketStores) syncUsersBlocks(ctx context.Context, f func(context.Conte
 	type job struct {
 		userID string
 		store  *store.BucketStore
+		status *bucketStoreStatus
 	}
 
 	wg := &sync.WaitGroup{}
@@ -171,6 +233,11 @@ func (u *BucketStores) syncUsersBlocks(ctx context.Context, f func(context.Conte
 		includeUserIDs[userID] = struct{}{}
 	}
 
+	u.storesMu.Lock()
+	prevOwnedUsers := u.ownedUsers
+	u.ownedUsers = includeUserIDs
+	u.storesMu.Unlock()
+
 	u.tenantsDiscovered.Set(float64(len(userIDs)))
 	u.tenantsSynced.Set(float64(len(includeUserIDs)))
 
@@ -183,7 +250,11 @@ func (u *BucketStores) syncUsersBlocks(ctx context.Context, f func(context.Conte
 			defer wg.Done()
 
 			for job := range jobs {
-				if err := f(ctx, job.store); err != nil {
+				start := time.Now()
+				err := f(ctx, job.store)
+				job.status.recordSync(start, err)
+
+				if err != nil {
 					errsMx.Lock()
 					errs.Add(errors.Wrapf(err, "failed to synchronize TSDB blocks for user %s", job.userID))
 					errsMx.Unlock()
@@ -213,7 +284,7 @@ func (u *BucketStores) syncUsersBlocks(ctx context.Context, f func(context.Conte
 		}
 
 		select {
-		case jobs <- job{userID: userID, store: bs}:
+		case jobs <- job{userID: userID, store: bs, status: u.getStoreStatus(userID)}:
 			// Nothing to do. Will loop to push more jobs.
 		case <-ctx.Done():
 			return ctx.Err()
@@ -224,6 +295,17 @@ func (u *BucketStores) syncUsersBlocks(ctx context.Context, f func(context.Conte
 	close(jobs)
 	wg.Wait()
 
+	// The blocks of the tenants not belonging to this store-gateway anymore have been unloaded,
+	// so their per-tenant chunks cache metrics and object storage rate limiters are removed.
+	for userID := range prevOwnedUsers {
+		if _, owned := includeUserIDs[userID]; !owned {
+			u.chunksCacheMetrics.RemoveUser(userID)
+			if u.rateLimitedBucket != nil {
+				u.rateLimitedBucket.RemoveUser(userID)
+			}
+		}
+	}
+
 	return errs.Err()
 }
 
@@ -236,16 +318,65 @@ func (u *BucketStores) Series(req *storepb.SeriesRequest, srv storepb.Store_Seri
 	if userID == "" {
 		return fmt.Errorf("no userID")
 	}
+	spanLog.Span.SetTag("tenant", userID)
 
 	store := u.getStore(userID)
 	if store == nil {
 		return nil
 	}
 
-	return store.Series(req, spanSeriesServer{
+	// The query shard label doesn't exist in the blocks, so it's removed from the matchers
+	// and the series not belonging to the shard are filtered out while sending the response.
+	// The bucket store still reads the postings and chunks of the series of all the shards.
+	shard, matchers, err := removeShardMatcher(req.Matchers)
+	if err != nil {
+		return err
+	}
+
+	var seriesSrv storepb.Store_SeriesServer = spanSeriesServer{
 		Store_SeriesServer: srv,
 		ctx:                spanCtx,
-	})
+	}
+
+	if shard != nil {
+		shardedReq := *req
+		shardedReq.Matchers = matchers
+		req = &shardedReq
+
+		seriesSrv = shardSeriesServer{Store_SeriesServer: seriesSrv, shard: shard}
+	}
+
+	// Query the requested blocks in batches, so that the series and chunks of a batch are
+	// sent to the querier before the next batch is loaded. The series are sorted within each
+	// batch, but not across batches.
+	reqs, err := splitSeriesRequestByBlocks(req, u.cfg.BucketStore.SeriesBatchMaxBlocks)
+	if err != nil {
+		return err
+	}
+
+	// The chunks limit applies to the whole request, so all the batches share the same limiter.
+	var limitedSrv *chunksLimitedSeriesServer
+	if len(reqs) > 1 {
+		limitedSrv = &chunksLimitedSeriesServer{
+			Store_SeriesServer: seriesSrv,
+			limiter:            newChunksLimiterFactory(u.limits, userID)(u.batchedQueriesDropped),
+		}
+		seriesSrv = limitedSrv
+	}
+
+	for _, batchReq := range reqs {
+		if err := store.Series(batchReq, seriesSrv); err != nil {
+			return err
+		}
+
+		// The bucket store overrides the error returned while sending the series when
+		// sending the response hints, so the chunks limit error is checked here.
+		if limitedSrv != nil && limitedSrv.err != nil {
+			return limitedSrv.err
+		}
+	}
+
+	return nil
 }
 
 // LabelNames implements the Storegateway proto service.
@@ -257,13 +388,14 @@ func (u *BucketStores) LabelNames(ctx context.Context, req *storepb.LabelNamesRe
 	if userID == "" {
 		return nil, fmt.Errorf("no userID")
 	}
+	spanLog.Span.SetTag("tenant", userID)
 
 	store := u.getStore(userID)
 	if store == nil {
 		return &storepb.LabelNamesResponse{}, nil
 	}
 
-	return store.LabelNames(ctx, req)
+	return store.LabelNames(spanCtx, req)
 }
 
 // LabelValues implements the Storegateway proto service.
@@ -275,13 +407,14 @@ func (u *BucketStores) LabelValues(ctx context.Context, req *storepb.LabelValues
 	if userID == "" {
 		return nil, fmt.Errorf("no userID")
 	}
+	spanLog.Span.SetTag("tenant", userID)
 
 	store := u.getStore(userID)
 	if store == nil {
 		return &storepb.LabelValuesResponse{}, nil
 	}
 
-	return store.LabelValues(ctx, req)
+	return store.LabelValues(spanCtx, req)
 }
 
 // scanUsers in the bucket and return the list of found users. If an error occurs while
@@ -308,6 +441,14 @@ func (u *BucketStores) getStore(userID string) *store.BucketStore {
 	return store
 }
 
+func (u *BucketStores) getStoreStatus(userID string) *bucketStoreStatus {
+	u.storesMu.RLock()
+	status := u.statuses[userID]
+	u.storesMu.RUnlock()
+
+	return status
+}
+
 func (u *BucketStores) getOrCreateStore(userID string) (*store.BucketStore, error) {
 	// Check if the store already exists.
 	bs := u.getStore(userID)
@@ -359,6 +500,7 @@ func (u *BucketStores) getOrCreateStore(userID string) (*store.BucketStore, erro
 				tsdb.TenantIDExternalLabel,
 				tsdb.IngesterIDExternalLabel,
 				tsdb.ShardIDExternalLabel,
+				tsdb.CompactorShardIDExternalLabel,
 			}),
 		},
 	)
@@ -366,12 +508,15 @@ func (u *BucketStores) getOrCreateStore(userID string) (*store.BucketStore, erro
 		return nil, err
 	}
 
+	// Keep track of the synced blocks to show them in the store-gateway status pages.
+	recordingFetcher := newBlocksRecordingFetcher(fetcher)
+
 	bucketStoreReg := prometheus.NewRegistry()
 	bs, err = store.NewBucketStore(
 		userLogger,
 		bucketStoreReg,
 		userBkt,
-		fetcher,
+		recordingFetcher,
 		filepath.Join(u.cfg.BucketStore.SyncDir, userID),
 		u.indexCache,
 		u.queryGate,
@@ -391,6 +536,7 @@ func (u *BucketStores) getOrCreateStore(userID string) (*store.BucketStore, erro
 	}
 
 	u.stores[userID] = bs
+	u.statuses[userID] = &bucketStoreStatus{fetcher: recordingFetcher, reg: bucketStoreReg}
 	u.metaFetcherMetrics.AddUserRegistry(userID, fetcherReg)
 	u.bucketStoreMetrics.AddUserRegistry(userID, bucketStoreReg)
 
@@ -448,10 +594,36 @@ func (s spanSeriesServer) Context() context.Context {
 	return s.ctx
 }
 
+// bucketRateLimitsProvider provides the store-gateway per-tenant object storage rate limits.
+type bucketRateLimitsProvider struct {
+	limits *validation.Overrides
+}
+
+func (p bucketRateLimitsProvider) ReadOpsPerSecond(userID string) float64 {
+	return p.limits.StoreGatewayBucketReadOpsPerSecond(userID)
+}
+
+func (p bucketRateLimitsProvider) ListOpsPerSecond(userID string) float64 {
+	return p.limits.StoreGatewayBucketListOpsPerSecond(userID)
+}
+
 func newChunksLimiterFactory(limits *validation.Overrides, userID string) store.ChunksLimiterFactory {
 	return func(failedCounter prometheus.Counter) store.ChunksLimiter {
 		// Since limit overrides could be live reloaded, we have to get the current user's limit
 		// each time a new limiter is instantiated.
-		return store.NewLimiter(uint64(limits.MaxChunksPerQuery(userID)), failedCounter)
+		return store.NewLimiter(uint64(maxChunksPerQuery(limits, userID)), failedCounter)
+	}
+}
+
+// maxChunksPerQuery returns the lowest non-zero limit between the max chunks limit enforced
+// on the long-term storage and the max fetched chunks limit enforced on the whole query:
+// a single store-gateway can't return more chunks than the query is allowed to fetch.
+func maxChunksPerQuery(limits *validation.Overrides, userID string) int {
+	storeLimit := limits.MaxChunksPerQuery(userID)
+	queryLimit := limits.MaxFetchedChunksPerQuery(userID)
+
+	if storeLimit == 0 || (queryLimit > 0 && queryLimit < storeLimit) {
+		return queryLimit
 	}
+	return storeLimit
 }
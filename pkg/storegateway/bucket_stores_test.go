@@ -12,6 +12,8 @@ import (
 	"time"
 
 	"github.com/go-kit/kit/log"
+	"github.com/gogo/protobuf/types"
+	"github.com/oklog/ulid"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/prometheus/prometheus/pkg/labels"
@@ -20,17 +22,21 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
 	"github.com/thanos-io/thanos/pkg/store"
+	"github.com/thanos-io/thanos/pkg/store/hintspb"
 	"github.com/thanos-io/thanos/pkg/store/labelpb"
 	"github.com/thanos-io/thanos/pkg/store/storepb"
 	"github.com/weaveworks/common/logging"
 	"go.uber.org/atomic"
 	"google.golang.org/grpc/metadata"
 
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
 	"github.com/cortexproject/cortex/pkg/storage/bucket"
 	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
 	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
 	"github.com/cortexproject/cortex/pkg/util/flagext"
+	"github.com/cortexproject/cortex/pkg/util/validation"
 )
 
 func TestBucketStores_InitialSync(t *testing.T) {
@@ -113,6 +119,38 @@ func TestBucketStores_InitialSync(t *testing.T) {
 	assert.Greater(t, testutil.ToFloat64(stores.syncLastSuccess), float64(0))
 }
 
+func TestBucketStores_InitialSyncShouldRemoveLocalFilesOfExcludedTenants(t *testing.T) {
+	ctx := context.Background()
+	cfg, cleanup := prepareStorageConfig(t)
+	defer cleanup()
+
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	generateStorageBlock(t, storageDir, "user-1", "series_1", 10, 100, 15)
+
+	bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	// Simulate the local files left by a previous run for a tenant which
+	// doesn't belong to this store-gateway anymore.
+	staleDir := filepath.Join(cfg.BucketStore.SyncDir, "user-2")
+	require.NoError(t, os.MkdirAll(filepath.Join(staleDir, "01EQ1FCCTJ4FVXZJ4X6WAV56Z6"), os.ModePerm))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(staleDir, "01EQ1FCCTJ4FVXZJ4X6WAV56Z6", "index-header"), []byte("data"), os.ModePerm))
+
+	stores, err := NewBucketStores(cfg, NewNoShardingStrategy(), bucket, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, stores.InitialSync(ctx))
+
+	_, err = os.Stat(staleDir)
+	assert.True(t, os.IsNotExist(err))
+
+	// The local files of the tenant belonging to this store-gateway should be preserved.
+	_, err = os.Stat(filepath.Join(cfg.BucketStore.SyncDir, "user-1"))
+	assert.NoError(t, err)
+}
+
 func TestBucketStores_SyncBlocks(t *testing.T) {
 	const (
 		userID     = "user-1"
@@ -308,6 +346,263 @@ func testBucketStoresSeriesShouldCorrectlyQuerySeriesSpanningMultipleChunks(t *t
 	}
 }
 
+func TestBucketStores_Series_ShouldFilterSeriesByQueryShard(t *testing.T) {
+	const (
+		userID     = "user-1"
+		metricName = "series"
+		numSeries  = 20
+		numShards  = 3
+	)
+
+	ctx := context.Background()
+	cfg, cleanup := prepareStorageConfig(t)
+	defer cleanup()
+
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	// Generate a block with many series of the same metric.
+	userDir := filepath.Join(storageDir, userID)
+	require.NoError(t, os.Mkdir(userDir, os.ModePerm))
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "tsdb-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmpDir) //nolint:errcheck
+
+	db, err := tsdb.Open(tmpDir, log.NewNopLogger(), nil, tsdb.DefaultOptions())
+	require.NoError(t, err)
+
+	app := db.Appender(ctx)
+	for i := 0; i < numSeries; i++ {
+		_, err = app.Add(labels.Labels{{Name: labels.MetricName, Value: metricName}, {Name: "id", Value: fmt.Sprintf("%d", i)}}, 10, 1)
+		require.NoError(t, err)
+	}
+	require.NoError(t, app.Commit())
+	require.NoError(t, db.Snapshot(userDir, true))
+	require.NoError(t, db.Close())
+
+	bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	stores, err := NewBucketStores(cfg, NewNoShardingStrategy(), bucket, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, stores.InitialSync(ctx))
+
+	// Query each shard and ensure each series is returned by exactly one shard.
+	seen := map[string]int{}
+	for shard := 0; shard < numShards; shard++ {
+		req := &storepb.SeriesRequest{
+			MinTime: 0,
+			MaxTime: 100,
+			Matchers: []storepb.LabelMatcher{
+				{Type: storepb.LabelMatcher_EQ, Name: "__cortex_shard__", Value: fmt.Sprintf("%d_of_%d", shard, numShards)},
+				{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: metricName},
+			},
+			PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+		}
+
+		srv := newBucketStoreSeriesServer(setUserIDToGRPCContext(ctx, userID))
+		require.NoError(t, stores.Series(req, srv))
+		assert.Empty(t, srv.Warnings)
+		assert.NotEmpty(t, srv.SeriesSet)
+
+		for _, series := range srv.SeriesSet {
+			lbls := labelpb.ZLabelsToPromLabels(series.Labels)
+			assert.Equal(t, uint64(shard), lbls.Hash()%numShards)
+			seen[lbls.String()]++
+		}
+	}
+
+	assert.Len(t, seen, numSeries)
+	for series, count := range seen {
+		assert.Equal(t, 1, count, series)
+	}
+
+	// An invalid shard should be rejected.
+	req := &storepb.SeriesRequest{
+		MinTime:  0,
+		MaxTime:  100,
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "__cortex_shard__", Value: "3_of_3"}},
+	}
+	require.Error(t, stores.Series(req, newBucketStoreSeriesServer(setUserIDToGRPCContext(ctx, userID))))
+
+	// A selector made of the shard matcher only should be rejected.
+	req = &storepb.SeriesRequest{
+		MinTime:  0,
+		MaxTime:  100,
+		Matchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "__cortex_shard__", Value: "1_of_3"}},
+	}
+	assert.Equal(t, astmapper.ErrShardOnlySelector, stores.Series(req, newBucketStoreSeriesServer(setUserIDToGRPCContext(ctx, userID))))
+}
+
+func TestBucketStores_Series_ShouldQueryBlocksInBatches(t *testing.T) {
+	const (
+		userID     = "user-1"
+		metricName = "series_1"
+	)
+
+	ctx := context.Background()
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	// Generate 3 blocks with the same series, each one covering a different time range.
+	generateStorageBlock(t, storageDir, userID, metricName, 0, 100, 15)
+	generateStorageBlock(t, storageDir, userID, metricName, 100, 200, 15)
+	generateStorageBlock(t, storageDir, userID, metricName, 200, 300, 15)
+
+	entries, err := ioutil.ReadDir(filepath.Join(storageDir, userID))
+	require.NoError(t, err)
+
+	var blockIDs []string
+	for _, entry := range entries {
+		if _, err := ulid.Parse(entry.Name()); err == nil {
+			blockIDs = append(blockIDs, entry.Name())
+		}
+	}
+	require.Len(t, blockIDs, 3)
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		maxBlocks      int
+		withHints      bool
+		expectedSeries int
+	}{
+		"batching disabled": {
+			maxBlocks:      0,
+			withHints:      true,
+			expectedSeries: 1,
+		},
+		"batching enabled but the request doesn't select the blocks": {
+			maxBlocks:      1,
+			withHints:      false,
+			expectedSeries: 1,
+		},
+		"batching enabled with a batch size smaller than the number of blocks": {
+			maxBlocks:      2,
+			withHints:      true,
+			expectedSeries: 2,
+		},
+		"batching enabled with 1 block per batch": {
+			maxBlocks:      1,
+			withHints:      true,
+			expectedSeries: 3,
+		},
+		"batching enabled with a batch size larger than the number of blocks": {
+			maxBlocks:      10,
+			withHints:      true,
+			expectedSeries: 1,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg, cleanup := prepareStorageConfig(t)
+			cfg.BucketStore.SeriesBatchMaxBlocks = testData.maxBlocks
+			defer cleanup()
+
+			stores, err := NewBucketStores(cfg, NewNoShardingStrategy(), bucketClient, defaultLimitsOverrides(t), mockLoggingLevel(), log.NewNopLogger(), nil)
+			require.NoError(t, err)
+			require.NoError(t, stores.InitialSync(ctx))
+
+			req := &storepb.SeriesRequest{
+				MinTime:                 0,
+				MaxTime:                 300,
+				Matchers:                []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: metricName}},
+				PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+			}
+
+			if testData.withHints {
+				req.Hints, err = types.MarshalAny(&hintspb.SeriesRequestHints{
+					BlockMatchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: block.BlockIDLabel, Value: strings.Join(blockIDs, "|")}},
+				})
+				require.NoError(t, err)
+			}
+
+			srv := newBucketStoreSeriesServer(setUserIDToGRPCContext(ctx, userID))
+			require.NoError(t, stores.Series(req, srv))
+			assert.Empty(t, srv.Warnings)
+			require.Len(t, srv.SeriesSet, testData.expectedSeries)
+
+			// Regardless of the batching, all samples of the series should be returned.
+			numSamples := 0
+			for _, series := range srv.SeriesSet {
+				assert.Equal(t, labels.Labels{{Name: labels.MetricName, Value: metricName}}, labelpb.ZLabelsToPromLabels(series.Labels))
+
+				samples, err := readSamplesFromChunks(series.Chunks)
+				require.NoError(t, err)
+				numSamples += len(samples)
+			}
+			assert.Equal(t, 21, numSamples)
+		})
+	}
+}
+
+func TestBucketStores_Series_ShouldEnforceTheChunksLimitAcrossBatches(t *testing.T) {
+	const (
+		userID     = "user-1"
+		metricName = "series_1"
+	)
+
+	ctx := context.Background()
+	storageDir, err := ioutil.TempDir(os.TempDir(), "storage-*")
+	require.NoError(t, err)
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	// Generate 3 blocks with the same series, each one containing a single chunk.
+	generateStorageBlock(t, storageDir, userID, metricName, 0, 100, 15)
+	generateStorageBlock(t, storageDir, userID, metricName, 100, 200, 15)
+	generateStorageBlock(t, storageDir, userID, metricName, 200, 300, 15)
+
+	entries, err := ioutil.ReadDir(filepath.Join(storageDir, userID))
+	require.NoError(t, err)
+
+	var blockIDs []string
+	for _, entry := range entries {
+		if _, err := ulid.Parse(entry.Name()); err == nil {
+			blockIDs = append(blockIDs, entry.Name())
+		}
+	}
+	require.Len(t, blockIDs, 3)
+
+	bucketClient, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	// Each batch of a single block fetches a single chunk, which is within the limit,
+	// while the chunks fetched by all the batches are not.
+	limits := defaultLimitsConfig()
+	limits.MaxChunksPerQuery = 2
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+
+	cfg, cleanup := prepareStorageConfig(t)
+	cfg.BucketStore.SeriesBatchMaxBlocks = 1
+	defer cleanup()
+
+	stores, err := NewBucketStores(cfg, NewNoShardingStrategy(), bucketClient, overrides, mockLoggingLevel(), log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, stores.InitialSync(ctx))
+
+	req := &storepb.SeriesRequest{
+		MinTime:                 0,
+		MaxTime:                 300,
+		Matchers:                []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: labels.MetricName, Value: metricName}},
+		PartialResponseStrategy: storepb.PartialResponseStrategy_ABORT,
+	}
+	req.Hints, err = types.MarshalAny(&hintspb.SeriesRequestHints{
+		BlockMatchers: []storepb.LabelMatcher{{Type: storepb.LabelMatcher_RE, Name: block.BlockIDLabel, Value: strings.Join(blockIDs, "|")}},
+	})
+	require.NoError(t, err)
+
+	srv := newBucketStoreSeriesServer(setUserIDToGRPCContext(ctx, userID))
+	err = stores.Series(req, srv)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeded chunks limit")
+}
+
 func prepareStorageConfig(t *testing.T) (cortex_tsdb.BlocksStorageConfig, func()) {
 	tmpDir, err := ioutil.TempDir(os.TempDir(), "blocks-sync-*")
 	require.NoError(t, err)
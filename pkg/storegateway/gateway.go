@@ -36,8 +36,8 @@ const (
 	// set on the querier in order to work correct.
 	sharedOptionWithQuerier = " This option needs be set both on the store-gateway and querier when running in microservices mode."
 
-	// ringAutoForgetUnhealthyPeriods is how many consecutive timeout periods an unhealthy instance
-	// in the ring will be automatically removed.
+	// ringAutoForgetUnhealthyPeriods is the default number of consecutive timeout periods after
+	// which an unhealthy instance in the ring will be automatically removed.
 	ringAutoForgetUnhealthyPeriods = 10
 )
 
@@ -155,7 +155,9 @@ func newStoreGateway(gatewayCfg Config, storageCfg cortex_tsdb.BlocksStorageConf
 		delegate := ring.BasicLifecyclerDelegate(g)
 		delegate = ring.NewLeaveOnStoppingDelegate(delegate, logger)
 		delegate = ring.NewTokensPersistencyDelegate(gatewayCfg.ShardingRing.TokensFilePath, ring.JOINING, delegate, logger)
-		delegate = ring.NewAutoForgetDelegate(ringAutoForgetUnhealthyPeriods*gatewayCfg.ShardingRing.HeartbeatTimeout, delegate, logger)
+		if gatewayCfg.ShardingRing.AutoForgetUnhealthyPeriods > 0 {
+			delegate = ring.NewAutoForgetDelegate(time.Duration(gatewayCfg.ShardingRing.AutoForgetUnhealthyPeriods)*gatewayCfg.ShardingRing.HeartbeatTimeout, delegate, logger)
+		}
 
 		g.ringLifecycler, err = ring.NewBasicLifecycler(lifecyclerCfg, RingNameForServer, RingKey, ringStore, delegate, logger, reg)
 		if err != nil {
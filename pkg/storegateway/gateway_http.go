@@ -1,10 +1,12 @@
 package storegateway
 
 import (
+	"html/template"
 	"net/http"
-	"text/template"
+	"time"
 
 	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
 
 	"github.com/cortexproject/cortex/pkg/util"
 	"github.com/cortexproject/cortex/pkg/util/services"
@@ -23,6 +25,101 @@ var (
 			<p>{{ .Message }}</p>
 		</body>
 	</html>`))
+
+	statusPageFuncs = template.FuncMap{
+		"formatTimestamp": func(ts int64) string {
+			if ts == 0 {
+				return "-"
+			}
+			return util.TimeFromMillis(ts).UTC().Format(time.RFC3339)
+		},
+	}
+
+	tenantsPageTemplate = template.Must(template.New("tenants").Funcs(statusPageFuncs).Parse(`
+	<!DOCTYPE html>
+	<html>
+		<head>
+			<meta charset="UTF-8">
+			<title>Cortex Store Gateway Tenants</title>
+		</head>
+		<body>
+			<h1>Cortex Store Gateway Tenants</h1>
+			<p>Current time: {{ .Now.UTC.Format "2006-01-02T15:04:05Z07:00" }}</p>
+			<p>Index-header lazy loading: {{ if .LazyLoadingEnabled }}enabled{{ else }}disabled{{ end }}</p>
+			<table border="1" cellpadding="5" style="border-collapse: collapse">
+				<thead>
+					<tr>
+						<th>Tenant</th>
+						<th>Owned</th>
+						<th>Blocks</th>
+						<th>Blocks size (bytes)</th>
+						<th>Index-headers size (bytes)</th>
+						<th>Loaded index-headers</th>
+						<th>Last sync</th>
+						<th>Last sync duration</th>
+						<th>Last sync error</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{ range .Tenants }}
+					<tr>
+						<td><a href="tenant/{{ .UserID }}/blocks">{{ .UserID }}</a></td>
+						<td>{{ .Owned }}</td>
+						<td align="right">{{ .NumBlocks }}</td>
+						<td align="right">{{ .BlocksSizeBytes }}</td>
+						<td align="right">{{ .IndexHeadersSizeBytes }}</td>
+						<td align="right">{{ .NumLoadedIndexHeaders }}</td>
+						<td>{{ formatTimestamp .LastSync }}</td>
+						<td>{{ .LastSyncDuration }}</td>
+						<td>{{ .LastSyncError }}</td>
+					</tr>
+					{{ end }}
+				</tbody>
+			</table>
+		</body>
+	</html>`))
+
+	tenantBlocksPageTemplate = template.Must(template.New("blocks").Funcs(statusPageFuncs).Parse(`
+	<!DOCTYPE html>
+	<html>
+		<head>
+			<meta charset="UTF-8">
+			<title>Cortex Store Gateway Tenant Blocks</title>
+		</head>
+		<body>
+			<h1>Cortex Store Gateway Tenant Blocks: {{ .Tenant.UserID }}</h1>
+			<p>Current time: {{ .Now.UTC.Format "2006-01-02T15:04:05Z07:00" }}</p>
+			<p>Last sync: {{ formatTimestamp .Tenant.LastSync }}{{ if .Tenant.LastSyncError }} ({{ .Tenant.LastSyncError }}){{ end }}</p>
+			<table border="1" cellpadding="5" style="border-collapse: collapse">
+				<thead>
+					<tr>
+						<th>Block</th>
+						<th>Min time</th>
+						<th>Max time</th>
+						<th>Compaction level</th>
+						<th>Series</th>
+						<th>Size (bytes)</th>
+						<th>Index-header size (bytes)</th>
+						<th>Index-header state</th>
+					</tr>
+				</thead>
+				<tbody>
+					{{ range .Blocks }}
+					<tr>
+						<td>{{ .ID }}</td>
+						<td>{{ formatTimestamp .MinTime }}</td>
+						<td>{{ formatTimestamp .MaxTime }}</td>
+						<td align="right">{{ .CompactionLevel }}</td>
+						<td align="right">{{ .NumSeries }}</td>
+						<td align="right">{{ .SizeBytes }}</td>
+						<td align="right">{{ .IndexHeaderSizeBytes }}</td>
+						<td>{{ .IndexHeaderState }}</td>
+					</tr>
+					{{ end }}
+				</tbody>
+			</table>
+		</body>
+	</html>`))
 )
 
 func writeMessage(w http.ResponseWriter, message string) {
@@ -51,3 +148,36 @@ func (c *StoreGateway) RingHandler(w http.ResponseWriter, req *http.Request) {
 
 	c.ring.ServeHTTP(w, req)
 }
+
+// TenantsHandler shows the tenants for which the store-gateway has a bucket store,
+// along with their synced blocks and the result of the last sync.
+func (c *StoreGateway) TenantsHandler(w http.ResponseWriter, req *http.Request) {
+	util.RenderHTTPResponse(w, struct {
+		Now                time.Time      `json:"now"`
+		LazyLoadingEnabled bool           `json:"index_header_lazy_loading_enabled"`
+		Tenants            []TenantStatus `json:"tenants"`
+	}{
+		Now:                time.Now(),
+		LazyLoadingEnabled: c.storageCfg.BucketStore.IndexHeaderLazyLoadingEnabled,
+		Tenants:            c.stores.TenantsStatus(),
+	}, tenantsPageTemplate, req)
+}
+
+// TenantBlocksHandler shows the blocks synced for a tenant, along with their index-header state.
+func (c *StoreGateway) TenantBlocksHandler(w http.ResponseWriter, req *http.Request) {
+	tenant, blocks, ok := c.stores.TenantBlocks(mux.Vars(req)["tenant"])
+	if !ok {
+		http.Error(w, "the tenant is not synced by this store-gateway", http.StatusNotFound)
+		return
+	}
+
+	util.RenderHTTPResponse(w, struct {
+		Now    time.Time     `json:"now"`
+		Tenant TenantStatus  `json:"tenant"`
+		Blocks []BlockStatus `json:"blocks"`
+	}{
+		Now:    time.Now(),
+		Tenant: tenant,
+		Blocks: blocks,
+	}, tenantBlocksPageTemplate, req)
+}
@@ -42,6 +42,8 @@ type RingConfig struct {
 	TokensFilePath       string        `yaml:"tokens_file_path"`
 	ZoneAwarenessEnabled bool          `yaml:"zone_awareness_enabled"`
 
+	AutoForgetUnhealthyPeriods int `yaml:"auto_forget_unhealthy_periods"`
+
 	// Instance details
 	InstanceID             string   `yaml:"instance_id" doc:"hidden"`
 	InstanceInterfaceNames []string `yaml:"instance_interface_names"`
@@ -71,6 +73,7 @@ func (cfg *RingConfig) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&cfg.ReplicationFactor, ringFlagsPrefix+"replication-factor", 3, "The replication factor to use when sharding blocks."+sharedOptionWithQuerier)
 	f.StringVar(&cfg.TokensFilePath, ringFlagsPrefix+"tokens-file-path", "", "File path where tokens are stored. If empty, tokens are not stored at shutdown and restored at startup.")
 	f.BoolVar(&cfg.ZoneAwarenessEnabled, ringFlagsPrefix+"zone-awareness-enabled", false, "True to enable zone-awareness and replicate blocks across different availability zones.")
+	f.IntVar(&cfg.AutoForgetUnhealthyPeriods, ringFlagsPrefix+"auto-forget-unhealthy-periods", ringAutoForgetUnhealthyPeriods, "Number of consecutive heartbeat timeouts after which an unhealthy store gateway is automatically removed from the ring by the healthy store gateways. 0 to disable.")
 
 	// Instance flags
 	cfg.InstanceInterfaceNames = []string{"eth0", "en0"}
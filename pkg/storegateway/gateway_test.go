@@ -715,8 +715,9 @@ func TestStoreGateway_SeriesQueryingShouldEnforceMaxChunksPerQueryLimit(t *testi
 	const chunksQueried = 10
 
 	tests := map[string]struct {
-		limit       int
-		expectedErr string
+		limit              int
+		fetchedChunksLimit int
+		expectedErr        string
 	}{
 		"no limit enforced if zero": {
 			limit:       0,
@@ -730,6 +731,16 @@ func TestStoreGateway_SeriesQueryingShouldEnforceMaxChunksPerQueryLimit(t *testi
 			limit:       chunksQueried - 1,
 			expectedErr: fmt.Sprintf("exceeded chunks limit: limit %d violated (got %d)", chunksQueried-1, chunksQueried),
 		},
+		"should return error if the actual number of queried chunks is > max fetched chunks limit": {
+			limit:              0,
+			fetchedChunksLimit: chunksQueried - 1,
+			expectedErr:        fmt.Sprintf("exceeded chunks limit: limit %d violated (got %d)", chunksQueried-1, chunksQueried),
+		},
+		"should enforce the lowest limit between the max chunks and max fetched chunks limits": {
+			limit:              chunksQueried + 1,
+			fetchedChunksLimit: chunksQueried - 2,
+			expectedErr:        fmt.Sprintf("exceeded chunks limit: limit %d violated (got %d)", chunksQueried-2, chunksQueried-1),
+		},
 	}
 
 	ctx := context.Background()
@@ -770,6 +781,7 @@ func TestStoreGateway_SeriesQueryingShouldEnforceMaxChunksPerQueryLimit(t *testi
 			// Customise the limits.
 			limits := defaultLimitsConfig()
 			limits.MaxChunksPerQuery = testData.limit
+			limits.MaxFetchedChunksPerQuery = testData.fetchedChunksLimit
 			overrides, err := validation.NewOverrides(limits, nil)
 			require.NoError(t, err)
 
@@ -54,6 +54,12 @@ func (m *MetadataFetcherMetrics) AddUserRegistry(user string, reg *prometheus.Re
 	m.regs.AddUserRegistry(user, reg)
 }
 
+// RemoveUserRegistry removes the registry of the given user, preserving the
+// latest values of its counters for future aggregations.
+func (m *MetadataFetcherMetrics) RemoveUserRegistry(user string) {
+	m.regs.RemoveUserRegistry(user, false)
+}
+
 func (m *MetadataFetcherMetrics) Describe(out chan<- *prometheus.Desc) {
 	out <- m.syncs
 	out <- m.syncFailures
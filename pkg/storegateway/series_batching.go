@@ -0,0 +1,101 @@
+package storegateway
+
+import (
+	"strings"
+
+	"github.com/gogo/protobuf/types"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/store"
+	"github.com/thanos-io/thanos/pkg/store/hintspb"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+)
+
+// splitSeriesRequestByBlocks splits the input request into multiple requests, each one querying
+// up to maxBlocks of the blocks selected by the request hints, so that the store-gateway loads
+// the series and chunks of a batch of blocks at a time. The input request is returned as is if
+// batching is disabled (maxBlocks <= 0) or the request doesn't select an explicit list of blocks.
+func splitSeriesRequestByBlocks(req *storepb.SeriesRequest, maxBlocks int) ([]*storepb.SeriesRequest, error) {
+	if maxBlocks <= 0 || req.Hints == nil {
+		return []*storepb.SeriesRequest{req}, nil
+	}
+
+	reqHints := hintspb.SeriesRequestHints{}
+	if err := types.UnmarshalAny(req.Hints, &reqHints); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal series request hints")
+	}
+
+	blockIDs := blockIDsFromMatchers(reqHints.BlockMatchers)
+	if len(blockIDs) <= maxBlocks {
+		return []*storepb.SeriesRequest{req}, nil
+	}
+
+	reqs := make([]*storepb.SeriesRequest, 0, (len(blockIDs)+maxBlocks-1)/maxBlocks)
+
+	for start := 0; start < len(blockIDs); start += maxBlocks {
+		end := start + maxBlocks
+		if end > len(blockIDs) {
+			end = len(blockIDs)
+		}
+
+		batchHints := reqHints
+		batchHints.BlockMatchers = []storepb.LabelMatcher{{
+			Type:  storepb.LabelMatcher_RE,
+			Name:  block.BlockIDLabel,
+			Value: strings.Join(blockIDs[start:end], "|"),
+		}}
+
+		anyHints, err := types.MarshalAny(&batchHints)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to marshal series request hints")
+		}
+
+		batchReq := *req
+		batchReq.Hints = anyHints
+		reqs = append(reqs, &batchReq)
+	}
+
+	return reqs, nil
+}
+
+// blockIDsFromMatchers returns the block IDs selected by the input block matchers, if they
+// consist of a single regex matcher listing the block IDs (as generated by the querier).
+// Returns nil for any other matcher, given the selected blocks can't be inferred.
+func blockIDsFromMatchers(matchers []storepb.LabelMatcher) []string {
+	if len(matchers) != 1 || matchers[0].Type != storepb.LabelMatcher_RE || matchers[0].Name != block.BlockIDLabel {
+		return nil
+	}
+
+	blockIDs := strings.Split(matchers[0].Value, "|")
+	for _, id := range blockIDs {
+		if _, err := ulid.Parse(id); err != nil {
+			return nil
+		}
+	}
+
+	return blockIDs
+}
+
+// chunksLimitedSeriesServer is a storepb.Store_SeriesServer enforcing the chunks limit on
+// the series sent by all the batches of a request. The bucket store creates a new chunks
+// limiter on each Series() call, so it only enforces the limit on each batch.
+type chunksLimitedSeriesServer struct {
+	storepb.Store_SeriesServer
+
+	limiter store.ChunksLimiter
+
+	// The error returned once the limit has been exceeded.
+	err error
+}
+
+func (s *chunksLimitedSeriesServer) Send(r *storepb.SeriesResponse) error {
+	if series := r.GetSeries(); series != nil {
+		if err := s.limiter.Reserve(uint64(len(series.Chunks))); err != nil {
+			s.err = errors.Wrap(err, "exceeded chunks limit")
+			return s.err
+		}
+	}
+
+	return s.Store_SeriesServer.Send(r)
+}
@@ -0,0 +1,46 @@
+package storegateway
+
+import (
+	"github.com/thanos-io/thanos/pkg/store/labelpb"
+	"github.com/thanos-io/thanos/pkg/store/storepb"
+
+	"github.com/cortexproject/cortex/pkg/querier/astmapper"
+)
+
+// removeShardMatcher returns the query shard selected by the input matchers (if any)
+// and the input matchers without the shard one.
+func removeShardMatcher(matchers []storepb.LabelMatcher) (*astmapper.ShardAnnotation, []storepb.LabelMatcher, error) {
+	promMatchers, err := storepb.TranslateFromPromMatchers(matchers...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	shard, _, err := astmapper.RemoveShardFromMatchers(promMatchers)
+	if err != nil || shard == nil {
+		return nil, matchers, err
+	}
+
+	filtered := make([]storepb.LabelMatcher, 0, len(matchers)-1)
+	for _, m := range matchers {
+		if m.Name != astmapper.ShardLabel {
+			filtered = append(filtered, m)
+		}
+	}
+	return shard, filtered, nil
+}
+
+// shardSeriesServer is a storepb.Store_SeriesServer which only sends the series
+// belonging to the query shard, dropping all the others.
+type shardSeriesServer struct {
+	storepb.Store_SeriesServer
+
+	shard *astmapper.ShardAnnotation
+}
+
+func (s shardSeriesServer) Send(r *storepb.SeriesResponse) error {
+	if series := r.GetSeries(); series != nil && !s.shard.Matches(labelpb.ZLabelsToPromLabels(series.Labels)) {
+		return nil
+	}
+
+	return s.Store_SeriesServer.Send(r)
+}
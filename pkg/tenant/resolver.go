@@ -36,6 +36,14 @@ func TenantIDs(ctx context.Context) ([]string, error) {
 	return defaultResolver.TenantIDs(ctx)
 }
 
+// TenantIDsFromOrgID extracts different tenants from an orgID string value
+//
+// ignore stutter warning
+//nolint:golint
+func TenantIDsFromOrgID(orgID string) ([]string, error) {
+	return TenantIDs(user.InjectOrgID(context.TODO(), orgID))
+}
+
 type Resolver interface {
 	// TenantID returns exactly a single tenant ID from the context. It should be
 	// used when a certain endpoint should only support exactly a single
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 )
 
 var (
@@ -45,6 +46,11 @@ func NormalizeTenantIDs(tenantIDs []string) []string {
 	return tenantIDs[0:posOut]
 }
 
+// JoinTenantIDs returns all tenant IDs concatenated with the separator character `|`
+func JoinTenantIDs(tenantIDs []string) string {
+	return strings.Join(tenantIDs, tenantIDsLabelSeparator)
+}
+
 // ValidTenantID
 func ValidTenantID(s string) error {
 	// check if it contains invalid runes
@@ -40,3 +40,8 @@ func TestValidTenantIDs(t *testing.T) {
 		})
 	}
 }
+
+func TestJoinTenantIDs(t *testing.T) {
+	assert.Equal(t, "tenant-a", JoinTenantIDs([]string{"tenant-a"}))
+	assert.Equal(t, "tenant-a|tenant-b", JoinTenantIDs([]string{"tenant-a", "tenant-b"}))
+}
@@ -0,0 +1,174 @@
+package tenant
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+)
+
+const (
+	// NormalizationNone doesn't normalize the tenant IDs.
+	NormalizationNone = "none"
+	// NormalizationLowercase converts the tenant IDs to lowercase.
+	NormalizationLowercase = "lowercase"
+
+	defaultAllowedSpecialCharacters = "!-_.*'()"
+)
+
+var (
+	errInvalidMaxLength     = errors.New("the tenant ID max length must be greater than 0")
+	errInvalidNormalization = fmt.Errorf("unsupported tenant ID normalization, supported values: %s, %s", NormalizationNone, NormalizationLowercase)
+	errInvalidCharacters    = errors.New("the allowed tenant ID special characters must not contain letters, digits, the tenant IDs separator '|' or the path separators '/' and '\\'")
+)
+
+// ValidationConfig configures the validation and normalization of the tenant IDs
+// of the HTTP requests, applied after the auth middleware.
+type ValidationConfig struct {
+	Enabled                  bool   `yaml:"enabled"`
+	MaxLength                int    `yaml:"max_length"`
+	AllowedSpecialCharacters string `yaml:"allowed_special_characters"`
+	DenyList                 string `yaml:"deny_list"`
+	Normalization            string `yaml:"normalization"`
+
+	// Normalizer is an optional hook normalizing the tenant IDs, applied after the
+	// configured normalization and before the validation. It can be set by the
+	// applications embedding Cortex.
+	Normalizer func(tenantID string) string `yaml:"-"`
+}
+
+// RegisterFlags registers the flags for the tenant ID validation config.
+func (cfg *ValidationConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "tenant-validation.enabled", false, "Validate and normalize the tenant ID of the HTTP requests, rejecting the requests with an invalid tenant ID. The tenant IDs made only of dots (e.g. '.' and '..') are always rejected when enabled. Requires -auth.enabled.")
+	f.IntVar(&cfg.MaxLength, "tenant-validation.max-length", 150, "Maximum length of the tenant ID, in bytes.")
+	f.StringVar(&cfg.AllowedSpecialCharacters, "tenant-validation.allowed-special-characters", defaultAllowedSpecialCharacters, "The special characters allowed in the tenant ID, in addition to the alphanumeric characters. The path separators '/' and '\\' are not allowed.")
+	f.StringVar(&cfg.DenyList, "tenant-validation.deny-list", "", "Comma separated list of tenant IDs which are rejected (after normalization).")
+	f.StringVar(&cfg.Normalization, "tenant-validation.normalization", NormalizationNone, fmt.Sprintf("Normalization applied to the tenant ID before the validation. Supported values: %s, %s.", NormalizationNone, NormalizationLowercase))
+}
+
+// Validate the config and returns an error if the validation fails.
+func (cfg *ValidationConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.MaxLength <= 0 {
+		return errInvalidMaxLength
+	}
+	if cfg.Normalization != NormalizationNone && cfg.Normalization != NormalizationLowercase {
+		return errInvalidNormalization
+	}
+	for _, c := range cfg.AllowedSpecialCharacters {
+		if isAlphanumeric(c) || string(c) == tenantIDsLabelSeparator || c == '/' || c == '\\' {
+			return errInvalidCharacters
+		}
+	}
+
+	return nil
+}
+
+// Validator validates and normalizes the tenant IDs.
+type Validator struct {
+	cfg      ValidationConfig
+	denyList map[string]struct{}
+}
+
+// NewValidator makes a new Validator.
+func NewValidator(cfg ValidationConfig) *Validator {
+	denyList := map[string]struct{}{}
+	for _, tenantID := range strings.Split(cfg.DenyList, ",") {
+		if tenantID = strings.TrimSpace(tenantID); tenantID != "" {
+			denyList[tenantID] = struct{}{}
+		}
+	}
+
+	return &Validator{
+		cfg:      cfg,
+		denyList: denyList,
+	}
+}
+
+// Normalize returns the normalized tenant ID.
+func (v *Validator) Normalize(tenantID string) string {
+	if v.cfg.Normalization == NormalizationLowercase {
+		tenantID = strings.ToLower(tenantID)
+	}
+	if v.cfg.Normalizer != nil {
+		tenantID = v.cfg.Normalizer(tenantID)
+	}
+	return tenantID
+}
+
+// Validate returns an error if the tenant ID is not valid.
+func (v *Validator) Validate(tenantID string) error {
+	if tenantID == "" {
+		return errors.New("tenant ID is empty")
+	}
+
+	// The tenant ID is used as a path segment in the storage (e.g. the bucket prefix
+	// of the blocks storage), so it must not reference the current or parent directory.
+	if strings.Trim(tenantID, ".") == "" {
+		return fmt.Errorf("tenant ID '%s' is not allowed", tenantID)
+	}
+
+	if len(tenantID) > v.cfg.MaxLength {
+		return fmt.Errorf("tenant ID is too long: max %d characters", v.cfg.MaxLength)
+	}
+
+	for pos, r := range tenantID {
+		if !isAlphanumeric(r) && !strings.ContainsRune(v.cfg.AllowedSpecialCharacters, r) {
+			return &errTenantIDUnsupportedCharacter{
+				tenantID: tenantID,
+				pos:      pos,
+			}
+		}
+	}
+
+	if _, ok := v.denyList[tenantID]; ok {
+		return fmt.Errorf("tenant ID '%s' is not allowed", tenantID)
+	}
+
+	return nil
+}
+
+// Wrap implements middleware.Interface. It must run after the auth middleware,
+// which injects the tenant ID into the request context.
+func (v *Validator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantIDs, err := TenantIDs(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		normalized := make([]string, 0, len(tenantIDs))
+		for _, tenantID := range tenantIDs {
+			tenantID = v.Normalize(tenantID)
+			if err := v.Validate(tenantID); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			normalized = append(normalized, tenantID)
+		}
+
+		// The header is updated too, because it's propagated to the downstream
+		// components (e.g. from the query-frontend to the queriers).
+		orgID := JoinTenantIDs(NormalizeTenantIDs(normalized))
+		r.Header.Set(user.OrgIDHeaderName, orgID)
+		next.ServeHTTP(w, r.WithContext(user.InjectOrgID(r.Context(), orgID)))
+	})
+}
+
+// NewValidationMiddleware returns a middleware running the input auth middleware
+// followed by the tenant ID validation.
+func NewValidationMiddleware(cfg ValidationConfig, auth middleware.Interface) middleware.Interface {
+	return middleware.Merge(auth, NewValidator(cfg))
+}
+
+func isAlphanumeric(c rune) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
@@ -0,0 +1,168 @@
+package tenant
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/middleware"
+	"github.com/weaveworks/common/user"
+)
+
+func TestValidator_Validate(t *testing.T) {
+	cfg := defaultValidationConfig()
+	cfg.MaxLength = 10
+	cfg.DenyList = "admin, system"
+	v := NewValidator(cfg)
+
+	for _, tc := range []struct {
+		tenantID string
+		err      string
+	}{
+		{tenantID: "tenant-a"},
+		{tenantID: "ab_.*'()!"},
+		{tenantID: "", err: "tenant ID is empty"},
+		{tenantID: ".", err: "tenant ID '.' is not allowed"},
+		{tenantID: "..", err: "tenant ID '..' is not allowed"},
+		{tenantID: "...", err: "tenant ID '...' is not allowed"},
+		{tenantID: "a.b"},
+		{tenantID: "../tenant", err: "tenant ID '../tenant' contains unsupported character '/'"},
+		{tenantID: "tenant a", err: "tenant ID 'tenant a' contains unsupported character ' '"},
+		{tenantID: strings.Repeat("a", 11), err: "tenant ID is too long: max 10 characters"},
+		{tenantID: "admin", err: "tenant ID 'admin' is not allowed"},
+		{tenantID: "system", err: "tenant ID 'system' is not allowed"},
+	} {
+		t.Run(tc.tenantID, func(t *testing.T) {
+			err := v.Validate(tc.tenantID)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
+
+func TestValidationConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		setup    func(cfg *ValidationConfig)
+		expected error
+	}{
+		"should pass with default config": {
+			setup: func(cfg *ValidationConfig) {},
+		},
+		"should fail on invalid max length": {
+			setup:    func(cfg *ValidationConfig) { cfg.MaxLength = 0 },
+			expected: errInvalidMaxLength,
+		},
+		"should fail on unsupported normalization": {
+			setup:    func(cfg *ValidationConfig) { cfg.Normalization = "uppercase" },
+			expected: errInvalidNormalization,
+		},
+		"should fail if the separator is an allowed character": {
+			setup:    func(cfg *ValidationConfig) { cfg.AllowedSpecialCharacters = "-|" },
+			expected: errInvalidCharacters,
+		},
+		"should fail if the slash is an allowed character": {
+			setup:    func(cfg *ValidationConfig) { cfg.AllowedSpecialCharacters = "-/" },
+			expected: errInvalidCharacters,
+		},
+		"should fail if the backslash is an allowed character": {
+			setup:    func(cfg *ValidationConfig) { cfg.AllowedSpecialCharacters = "-\\" },
+			expected: errInvalidCharacters,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := defaultValidationConfig()
+			cfg.Enabled = true
+			testData.setup(&cfg)
+
+			assert.Equal(t, testData.expected, cfg.Validate())
+		})
+	}
+}
+
+func TestValidationMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		setup            func(cfg *ValidationConfig)
+		orgID            string
+		expectedStatus   int
+		expectedTenantID string
+	}{
+		"should accept a valid tenant ID": {
+			orgID:            "tenant-a",
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-a",
+		},
+		"should reject an invalid tenant ID": {
+			orgID:          "..",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"should reject a request without tenant ID": {
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"should normalize the tenant ID to lowercase": {
+			setup:            func(cfg *ValidationConfig) { cfg.Normalization = NormalizationLowercase },
+			orgID:            "Tenant-A",
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-a",
+		},
+		"should apply the deny-list after the normalization": {
+			setup: func(cfg *ValidationConfig) {
+				cfg.Normalization = NormalizationLowercase
+				cfg.DenyList = "admin"
+			},
+			orgID:          "Admin",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"should apply the normalization hook": {
+			setup: func(cfg *ValidationConfig) {
+				cfg.Normalizer = func(tenantID string) string { return strings.TrimPrefix(tenantID, "org-") }
+			},
+			orgID:            "org-tenant-a",
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-a",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := defaultValidationConfig()
+			cfg.Enabled = true
+			if testData.setup != nil {
+				testData.setup(&cfg)
+			}
+
+			var actualTenantID string
+			handler := NewValidationMiddleware(cfg, middleware.AuthenticateUser).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				actualTenantID, err = TenantID(r.Context())
+				require.NoError(t, err)
+				assert.Equal(t, actualTenantID, r.Header.Get(user.OrgIDHeaderName))
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/query", nil)
+			if testData.orgID != "" {
+				req.Header.Set(user.OrgIDHeaderName, testData.orgID)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, testData.expectedStatus, recorder.Code)
+			assert.Equal(t, testData.expectedTenantID, actualTenantID)
+		})
+	}
+}
+
+func defaultValidationConfig() ValidationConfig {
+	cfg := ValidationConfig{}
+	cfg.RegisterFlags(flag.NewFlagSet("", flag.PanicOnError))
+	return cfg
+}
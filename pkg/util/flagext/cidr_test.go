@@ -0,0 +1,37 @@
+package flagext
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func Test_CIDRSliceCSV(t *testing.T) {
+	type TestStruct struct {
+		CIDRs CIDRSliceCSV `yaml:"cidrs"`
+	}
+
+	var testStruct TestStruct
+	s := "127.0.0.1/32,10.0.10.0/28,fdf8:f53b:82e4::/100,192.168.0.0/20"
+	require.NoError(t, testStruct.CIDRs.Set(s))
+
+	assert.Len(t, testStruct.CIDRs, 4)
+	assert.Equal(t, s, testStruct.CIDRs.String())
+
+	expected := []byte(`cidrs: 127.0.0.1/32,10.0.10.0/28,fdf8:f53b:82e4::/100,192.168.0.0/20
+`)
+
+	actual, err := yaml.Marshal(testStruct)
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	var testStruct2 TestStruct
+	require.NoError(t, yaml.Unmarshal(expected, &testStruct2))
+	assert.Equal(t, testStruct, testStruct2)
+
+	// An invalid CIDR is rejected.
+	var invalid CIDRSliceCSV
+	assert.Error(t, invalid.Set("127.0.0.1"))
+}
@@ -0,0 +1,131 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"go.uber.org/atomic"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+type queryLimiterCtxKey struct{}
+
+var ctxKey = &queryLimiterCtxKey{}
+
+const (
+	ErrMaxFetchedSeriesPerQuery     = "the query hit the max number of series limit (limit: %d series)"
+	ErrMaxFetchedChunkBytesPerQuery = "the query hit the aggregated chunks size limit (limit: %d bytes)"
+	ErrMaxFetchedChunksPerQuery     = "the query hit the max number of chunks limit (limit: %d chunks)"
+)
+
+// Reasons used as label values of the metric tracking the queries which hit a limit.
+const (
+	ReasonMaxFetchedSeriesPerQuery     = "max_fetched_series_per_query"
+	ReasonMaxFetchedChunkBytesPerQuery = "max_fetched_chunk_bytes_per_query"
+	ReasonMaxFetchedChunksPerQuery     = "max_fetched_chunks_per_query"
+)
+
+// QueryLimiter enforces the limits on the series, chunks and chunk bytes fetched by a
+// single query. It's safe to be used concurrently by the goroutines fetching the data
+// for the query.
+type QueryLimiter struct {
+	uniqueSeriesMx sync.Mutex
+	uniqueSeries   map[uint64]struct{}
+
+	chunkBytesCount atomic.Int64
+	chunkCount      atomic.Int64
+
+	maxSeriesPerQuery     int
+	maxChunkBytesPerQuery int
+	maxChunksPerQuery     int
+
+	// limitedQueries is incremented once per query and reason when a limit is hit.
+	// It's expected to have the "reason" label only, and may be nil.
+	limitedQueries *prometheus.CounterVec
+	limitReached   sync.Map
+}
+
+// NewQueryLimiter makes a new per-query limiter. Each limit is disabled when set to 0.
+// The optional limitedQueries counter must have the "reason" label only.
+func NewQueryLimiter(maxSeriesPerQuery, maxChunkBytesPerQuery, maxChunksPerQuery int, limitedQueries *prometheus.CounterVec) *QueryLimiter {
+	return &QueryLimiter{
+		uniqueSeries:          map[uint64]struct{}{},
+		maxSeriesPerQuery:     maxSeriesPerQuery,
+		maxChunkBytesPerQuery: maxChunkBytesPerQuery,
+		maxChunksPerQuery:     maxChunksPerQuery,
+		limitedQueries:        limitedQueries,
+	}
+}
+
+// AddQueryLimiterToContext returns a copy of the input context carrying the query limiter.
+func AddQueryLimiterToContext(ctx context.Context, limiter *QueryLimiter) context.Context {
+	return context.WithValue(ctx, ctxKey, limiter)
+}
+
+// QueryLimiterFromContextWithFallback returns the query limiter carried by the context,
+// or a limiter enforcing no limit if the context doesn't carry any.
+func QueryLimiterFromContextWithFallback(ctx context.Context) *QueryLimiter {
+	ql, ok := ctx.Value(ctxKey).(*QueryLimiter)
+	if !ok {
+		return NewQueryLimiter(0, 0, 0, nil)
+	}
+	return ql
+}
+
+// AddSeries adds the input series to the set of unique series fetched by the query,
+// and returns an error if the max number of series per query has been exceeded.
+func (ql *QueryLimiter) AddSeries(series labels.Labels) error {
+	if ql.maxSeriesPerQuery == 0 {
+		return nil
+	}
+
+	ql.uniqueSeriesMx.Lock()
+	ql.uniqueSeries[series.Hash()] = struct{}{}
+	count := len(ql.uniqueSeries)
+	ql.uniqueSeriesMx.Unlock()
+
+	if count > ql.maxSeriesPerQuery {
+		return ql.limitError(ReasonMaxFetchedSeriesPerQuery, ErrMaxFetchedSeriesPerQuery, ql.maxSeriesPerQuery)
+	}
+	return nil
+}
+
+// AddChunkBytes adds the input size to the chunk bytes fetched by the query, and returns
+// an error if the max chunk bytes per query has been exceeded.
+func (ql *QueryLimiter) AddChunkBytes(chunkSizeInBytes int) error {
+	if ql.maxChunkBytesPerQuery == 0 {
+		return nil
+	}
+
+	if ql.chunkBytesCount.Add(int64(chunkSizeInBytes)) > int64(ql.maxChunkBytesPerQuery) {
+		return ql.limitError(ReasonMaxFetchedChunkBytesPerQuery, ErrMaxFetchedChunkBytesPerQuery, ql.maxChunkBytesPerQuery)
+	}
+	return nil
+}
+
+// AddChunks adds the input count to the chunks fetched by the query, and returns an
+// error if the max number of chunks per query has been exceeded.
+func (ql *QueryLimiter) AddChunks(count int) error {
+	if ql.maxChunksPerQuery == 0 {
+		return nil
+	}
+
+	if ql.chunkCount.Add(int64(count)) > int64(ql.maxChunksPerQuery) {
+		return ql.limitError(ReasonMaxFetchedChunksPerQuery, ErrMaxFetchedChunksPerQuery, ql.maxChunksPerQuery)
+	}
+	return nil
+}
+
+func (ql *QueryLimiter) limitError(reason, format string, limit int) error {
+	// Track the query only the first time the limit is hit, because the data
+	// is fetched concurrently and the limit may be hit by multiple goroutines.
+	if _, reached := ql.limitReached.LoadOrStore(reason, struct{}{}); !reached && ql.limitedQueries != nil {
+		ql.limitedQueries.WithLabelValues(reason).Inc()
+	}
+
+	return validation.LimitError(fmt.Sprintf(format, limit))
+}
@@ -0,0 +1,104 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryLimiter_AddSeries_ShouldReturnNoErrorOnLimitNotExceeded(t *testing.T) {
+	limiter := NewQueryLimiter(2, 0, 0, nil)
+
+	series1 := labels.FromStrings(labels.MetricName, "series_1", "job", "test")
+	series2 := labels.FromStrings(labels.MetricName, "series_2", "job", "test")
+
+	require.NoError(t, limiter.AddSeries(series1))
+	require.NoError(t, limiter.AddSeries(series2))
+
+	// Adding the same series again should not count it twice.
+	require.NoError(t, limiter.AddSeries(series1))
+}
+
+func TestQueryLimiter_AddSeries_ShouldReturnErrorOnLimitExceeded(t *testing.T) {
+	limiter := NewQueryLimiter(1, 0, 0, nil)
+
+	require.NoError(t, limiter.AddSeries(labels.FromStrings(labels.MetricName, "series_1")))
+
+	err := limiter.AddSeries(labels.FromStrings(labels.MetricName, "series_2"))
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf(ErrMaxFetchedSeriesPerQuery, 1), err.Error())
+}
+
+func TestQueryLimiter_AddChunks(t *testing.T) {
+	limiter := NewQueryLimiter(0, 0, 10, nil)
+
+	require.NoError(t, limiter.AddChunks(5))
+	require.NoError(t, limiter.AddChunks(5))
+
+	err := limiter.AddChunks(1)
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf(ErrMaxFetchedChunksPerQuery, 10), err.Error())
+}
+
+func TestQueryLimiter_AddChunkBytes(t *testing.T) {
+	limiter := NewQueryLimiter(0, 100, 0, nil)
+
+	require.NoError(t, limiter.AddChunkBytes(100))
+
+	err := limiter.AddChunkBytes(1)
+	require.Error(t, err)
+	assert.Equal(t, fmt.Sprintf(ErrMaxFetchedChunkBytesPerQuery, 100), err.Error())
+}
+
+func TestQueryLimiter_ShouldEnforceNoLimitIfZero(t *testing.T) {
+	limiter := NewQueryLimiter(0, 0, 0, nil)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, limiter.AddSeries(labels.FromStrings(labels.MetricName, fmt.Sprintf("series_%d", i))))
+		require.NoError(t, limiter.AddChunks(1000))
+		require.NoError(t, limiter.AddChunkBytes(1000))
+	}
+}
+
+func TestQueryLimiter_ShouldTrackLimitedQueriesOncePerReason(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	limitedQueries := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "test_queries_limited_total",
+		Help: "Test.",
+	}, []string{"reason"})
+	reg.MustRegister(limitedQueries)
+
+	limiter := NewQueryLimiter(0, 10, 1, limitedQueries)
+
+	require.NoError(t, limiter.AddChunks(1))
+
+	for i := 0; i < 3; i++ {
+		require.Error(t, limiter.AddChunks(1))
+		require.Error(t, limiter.AddChunkBytes(20))
+	}
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP test_queries_limited_total Test.
+		# TYPE test_queries_limited_total counter
+		test_queries_limited_total{reason="max_fetched_chunk_bytes_per_query"} 1
+		test_queries_limited_total{reason="max_fetched_chunks_per_query"} 1
+	`)))
+}
+
+func TestQueryLimiterFromContextWithFallback(t *testing.T) {
+	// Should return a limiter enforcing no limit if the context doesn't carry any.
+	fallback := QueryLimiterFromContextWithFallback(context.Background())
+	require.NotNil(t, fallback)
+	require.NoError(t, fallback.AddChunks(1000000))
+
+	limiter := NewQueryLimiter(1, 1, 1, nil)
+	ctx := AddQueryLimiterToContext(context.Background(), limiter)
+	assert.Same(t, limiter, QueryLimiterFromContextWithFallback(ctx))
+}
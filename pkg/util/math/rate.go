@@ -1,4 +1,4 @@
-package ingester
+package math
 
 import (
 	"sync"
@@ -7,8 +7,8 @@ import (
 	"go.uber.org/atomic"
 )
 
-// ewmaRate tracks an exponentially weighted moving average of a per-second rate.
-type ewmaRate struct {
+// EwmaRate tracks an exponentially weighted moving average of a per-second rate.
+type EwmaRate struct {
 	newEvents atomic.Int64
 	alpha     float64
 	interval  time.Duration
@@ -17,22 +17,23 @@ type ewmaRate struct {
 	mutex     sync.Mutex
 }
 
-func newEWMARate(alpha float64, interval time.Duration) *ewmaRate {
-	return &ewmaRate{
+// NewEWMARate returns a new EwmaRate with the given smoothing factor, ticked every interval.
+func NewEWMARate(alpha float64, interval time.Duration) *EwmaRate {
+	return &EwmaRate{
 		alpha:    alpha,
 		interval: interval,
 	}
 }
 
-// rate returns the per-second rate.
-func (r *ewmaRate) rate() float64 {
+// Rate returns the per-second rate.
+func (r *EwmaRate) Rate() float64 {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	return r.lastRate
 }
 
-// tick assumes to be called every r.interval.
-func (r *ewmaRate) tick() {
+// Tick assumes to be called every r.interval.
+func (r *EwmaRate) Tick() {
 	newEvents := r.newEvents.Load()
 	r.newEvents.Sub(newEvents)
 	instantRate := float64(newEvents) / r.interval.Seconds()
@@ -48,11 +49,12 @@ func (r *ewmaRate) tick() {
 	}
 }
 
-// inc counts one event.
-func (r *ewmaRate) inc() {
+// Inc counts one event.
+func (r *EwmaRate) Inc() {
 	r.newEvents.Inc()
 }
 
-func (r *ewmaRate) add(delta int64) {
+// Add counts delta events.
+func (r *EwmaRate) Add(delta int64) {
 	r.newEvents.Add(delta)
 }
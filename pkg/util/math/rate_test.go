@@ -1,4 +1,4 @@
-package ingester
+package math
 
 import (
 	"testing"
@@ -22,25 +22,25 @@ func TestRate(t *testing.T) {
 		{0, 0.20342374400000002},
 		{0, 0.16273899520000001},
 	}
-	r := newEWMARate(0.2, time.Minute)
+	r := NewEWMARate(0.2, time.Minute)
 
 	for i, tick := range ticks {
 		for e := 0; e < tick.events; e++ {
-			r.inc()
+			r.Inc()
 		}
-		r.tick()
-		if r.rate() != tick.want {
-			t.Fatalf("%d. unexpected rate: want %v, got %v", i, tick.want, r.rate())
+		r.Tick()
+		if r.Rate() != tick.want {
+			t.Fatalf("%d. unexpected rate: want %v, got %v", i, tick.want, r.Rate())
 		}
 	}
 
-	r = newEWMARate(0.2, time.Minute)
+	r = NewEWMARate(0.2, time.Minute)
 
 	for i, tick := range ticks {
-		r.add(int64(tick.events))
-		r.tick()
-		if r.rate() != tick.want {
-			t.Fatalf("%d. unexpected rate: want %v, got %v", i, tick.want, r.rate())
+		r.Add(int64(tick.events))
+		r.Tick()
+		if r.Rate() != tick.want {
+			t.Fatalf("%d. unexpected rate: want %v, got %v", i, tick.want, r.Rate())
 		}
 	}
 }
@@ -0,0 +1,717 @@
+package push
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/value"
+	"github.com/weaveworks/common/httpgrpc"
+	"github.com/weaveworks/common/middleware"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cortexproject/cortex/pkg/distributor"
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// Field numbers of the OTLP metrics messages.
+// See https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/metrics/v1/metrics.proto.
+const (
+	otlpRequestResourceMetricsField = 1
+
+	otlpResourceMetricsResourceField     = 1
+	otlpResourceMetricsScopeMetricsField = 2
+	otlpResourceAttributesField          = 1
+	otlpScopeMetricsMetricsField         = 2
+
+	otlpKeyValueKeyField   = 1
+	otlpKeyValueValueField = 2
+
+	otlpAnyValueStringField = 1
+	otlpAnyValueBoolField   = 2
+	otlpAnyValueIntField    = 3
+	otlpAnyValueDoubleField = 4
+	otlpAnyValueBytesField  = 7
+
+	otlpMetricNameField                 = 1
+	otlpMetricDescriptionField          = 2
+	otlpMetricUnitField                 = 3
+	otlpMetricGaugeField                = 5
+	otlpMetricSumField                  = 7
+	otlpMetricHistogramField            = 9
+	otlpMetricExponentialHistogramField = 10
+	otlpMetricSummaryField              = 11
+
+	// Fields shared by the Gauge, Sum, Histogram and Summary messages.
+	otlpDataPointsField             = 1
+	otlpAggregationTemporalityField = 2
+	otlpSumIsMonotonicField         = 3
+
+	otlpNumberDataPointTimeField       = 3
+	otlpNumberDataPointAsDoubleField   = 4
+	otlpNumberDataPointAsIntField      = 6
+	otlpNumberDataPointAttributesField = 7
+	otlpNumberDataPointFlagsField      = 8
+
+	otlpHistogramDataPointTimeField           = 3
+	otlpHistogramDataPointCountField          = 4
+	otlpHistogramDataPointSumField            = 5
+	otlpHistogramDataPointBucketCountsField   = 6
+	otlpHistogramDataPointExplicitBoundsField = 7
+	otlpHistogramDataPointAttributesField     = 9
+	otlpHistogramDataPointFlagsField          = 10
+
+	otlpSummaryDataPointTimeField           = 3
+	otlpSummaryDataPointCountField          = 4
+	otlpSummaryDataPointSumField            = 5
+	otlpSummaryDataPointQuantileValuesField = 6
+	otlpSummaryDataPointAttributesField     = 7
+	otlpSummaryDataPointFlagsField          = 8
+
+	otlpValueAtQuantileQuantileField = 1
+	otlpValueAtQuantileValueField    = 2
+
+	otlpAggregationTemporalityDelta  = 1
+	otlpDataPointFlagNoRecordedValue = 1
+
+	// Fields of the ExportMetricsServiceResponse message.
+	otlpResponsePartialSuccessField       = 1
+	otlpPartialSuccessRejectedPointsField = 1
+	otlpPartialSuccessErrorMessageField   = 2
+)
+
+const (
+	otlpDroppedDeltaTemporality      = "delta_temporality"
+	otlpDroppedExponentialHistogram  = "exponential_histogram"
+	otlpDroppedUnsupportedMetricType = "unsupported_metric_type"
+)
+
+var otlpDroppedDataPoints = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "cortex",
+	Name:      "distributor_otlp_dropped_data_points_total",
+	Help:      "The total number of OTLP data points dropped because they can't be converted to Cortex series.",
+}, []string{"reason"})
+
+// OTLPLimits is the per-tenant configuration used to translate OTLP metrics.
+type OTLPLimits interface {
+	PromoteOTelResourceAttributes(userID string) []string
+}
+
+// OTLPHandler is a http.Handler which accepts OTLP metrics, encoded as protobuf, and
+// pushes them as WriteRequests.
+func OTLPHandler(cfg distributor.Config, sourceIPs *middleware.SourceIPExtractor, limits OTLPLimits, push func(context.Context, *client.WriteRequest) (*client.WriteResponse, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		logger := util.WithContext(ctx, util.Logger)
+		if sourceIPs != nil {
+			source := sourceIPs.Get(r)
+			if source != "" {
+				ctx = util.AddSourceIPsToOutgoingContext(ctx, source)
+				logger = util.WithSourceIPs(source, logger)
+			}
+		}
+
+		userID, err := tenant.TenantID(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/x-protobuf" {
+			http.Error(w, "unsupported content type, only application/x-protobuf is supported", http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var (
+			body         io.Reader = r.Body
+			expectedSize           = int(r.ContentLength)
+		)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gzipReader, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer gzipReader.Close()
+
+			// The content length is the compressed size, so it can't be used to size the buffer.
+			body, expectedSize = gzipReader, 0
+		}
+
+		req := otlpWriteRequest{promoteResourceAttributes: limits.PromoteOTelResourceAttributes(userID)}
+		if err := util.ParseProtoReader(ctx, body, expectedSize, cfg.MaxRecvMsgSize, &req, util.NoCompression); err != nil {
+			level.Error(logger).Log("err", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Source = client.API
+
+		for reason, count := range req.dropped {
+			otlpDroppedDataPoints.WithLabelValues(reason).Add(float64(count))
+		}
+
+		if _, err := push(ctx, &req.WriteRequest); err != nil {
+			resp, ok := httpgrpc.HTTPResponseFromError(err)
+			if !ok {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if resp.GetCode() != 202 {
+				level.Error(logger).Log("msg", "push error", "err", err)
+			}
+			http.Error(w, string(resp.Body), int(resp.Code))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		if _, err := w.Write(req.exportResponse()); err != nil {
+			level.Warn(logger).Log("msg", "failed to write OTLP response", "err", err)
+		}
+	})
+}
+
+// otlpWriteRequest is an OTLP ExportMetricsServiceRequest, translated into the Cortex
+// WriteRequest while decoding it.
+type otlpWriteRequest struct {
+	client.WriteRequest
+
+	// Resource attributes to promote to series labels.
+	promoteResourceAttributes []string
+
+	// Number of data points dropped, by reason.
+	dropped map[string]int
+
+	// Metric families for which metadata has already been added to the request.
+	seenMetadata map[string]struct{}
+}
+
+// Reset resets the translated request, preserving the translation config.
+func (r *otlpWriteRequest) Reset() {
+	r.WriteRequest.Reset()
+	r.dropped = nil
+	r.seenMetadata = nil
+}
+
+// Unmarshal implements proto.Unmarshaler.
+func (r *otlpWriteRequest) Unmarshal(data []byte) error {
+	return forEachField(data, func(f wireField) error {
+		if f.num == otlpRequestResourceMetricsField && f.typ == protowire.BytesType {
+			return r.translateResourceMetrics(f.bytes)
+		}
+		return nil
+	})
+}
+
+// exportResponse returns the protobuf-encoded ExportMetricsServiceResponse.
+func (r *otlpWriteRequest) exportResponse() []byte {
+	rejected := 0
+	for _, count := range r.dropped {
+		rejected += count
+	}
+	if rejected == 0 {
+		return nil
+	}
+
+	var partialSuccess []byte
+	partialSuccess = protowire.AppendTag(partialSuccess, otlpPartialSuccessRejectedPointsField, protowire.VarintType)
+	partialSuccess = protowire.AppendVarint(partialSuccess, uint64(rejected))
+	partialSuccess = protowire.AppendTag(partialSuccess, otlpPartialSuccessErrorMessageField, protowire.BytesType)
+	partialSuccess = protowire.AppendString(partialSuccess, "delta temporality, exponential histograms and metrics without data points are not supported")
+
+	resp := protowire.AppendTag(nil, otlpResponsePartialSuccessField, protowire.BytesType)
+	return protowire.AppendBytes(resp, partialSuccess)
+}
+
+func (r *otlpWriteRequest) translateResourceMetrics(data []byte) error {
+	// The resource can be encoded anywhere in the message, so it needs
+	// to be read before translating the metrics.
+	resourceLabels := labels.Labels(nil)
+	err := forEachField(data, func(f wireField) (err error) {
+		if f.num == otlpResourceMetricsResourceField && f.typ == protowire.BytesType {
+			resourceLabels, err = r.resourceLabels(f.bytes)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return forEachField(data, func(f wireField) error {
+		if f.num != otlpResourceMetricsScopeMetricsField || f.typ != protowire.BytesType {
+			return nil
+		}
+
+		return forEachField(f.bytes, func(f wireField) error {
+			if f.num == otlpScopeMetricsMetricsField && f.typ == protowire.BytesType {
+				return r.translateMetric(f.bytes, resourceLabels)
+			}
+			return nil
+		})
+	})
+}
+
+// resourceLabels returns the series labels built from the resource attributes: the job
+// and instance labels follow the OpenTelemetry to Prometheus compatibility spec, while
+// the other attributes are added only if they're configured to be promoted.
+func (r *otlpWriteRequest) resourceLabels(data []byte) (labels.Labels, error) {
+	var keyValues [][]byte
+	err := forEachField(data, func(f wireField) error {
+		if f.num == otlpResourceAttributesField && f.typ == protowire.BytesType {
+			keyValues = append(keyValues, f.bytes)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := otlpAttributes(keyValues)
+	if err != nil {
+		return nil, err
+	}
+
+	b := labels.NewBuilder(nil)
+	for _, name := range r.promoteResourceAttributes {
+		if v, ok := attrs[name]; ok {
+			b.Set(sanitizeLabelName(name), v)
+		}
+	}
+
+	if name, ok := attrs["service.name"]; ok {
+		if namespace, ok := attrs["service.namespace"]; ok {
+			name = namespace + "/" + name
+		}
+		b.Set("job", name)
+	}
+	if instance, ok := attrs["service.instance.id"]; ok {
+		b.Set("instance", instance)
+	}
+
+	return b.Labels(), nil
+}
+
+func (r *otlpWriteRequest) translateMetric(data []byte, resourceLabels labels.Labels) error {
+	var (
+		name, help, unit string
+		dataType         protowire.Number
+		dataBytes        []byte
+	)
+
+	err := forEachField(data, func(f wireField) error {
+		if f.typ != protowire.BytesType {
+			return nil
+		}
+
+		switch f.num {
+		case otlpMetricNameField:
+			name = string(f.bytes)
+		case otlpMetricDescriptionField:
+			help = string(f.bytes)
+		case otlpMetricUnitField:
+			unit = string(f.bytes)
+		case otlpMetricGaugeField, otlpMetricSumField, otlpMetricHistogramField, otlpMetricExponentialHistogramField, otlpMetricSummaryField:
+			dataType, dataBytes = f.num, f.bytes
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var (
+		temporality uint64
+		monotonic   bool
+		points      [][]byte
+	)
+	err = forEachField(dataBytes, func(f wireField) error {
+		switch {
+		case f.num == otlpDataPointsField && f.typ == protowire.BytesType:
+			points = append(points, f.bytes)
+		case f.num == otlpAggregationTemporalityField && f.typ == protowire.VarintType:
+			temporality = f.varint
+		case f.num == otlpSumIsMonotonicField && f.typ == protowire.VarintType:
+			monotonic = protowire.DecodeBool(f.varint)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	name = sanitizeMetricName(name)
+
+	var metricType client.MetricMetadata_MetricType
+	switch {
+	case dataType == otlpMetricExponentialHistogramField:
+		r.drop(otlpDroppedExponentialHistogram, len(points))
+		return nil
+	case temporality == otlpAggregationTemporalityDelta:
+		r.drop(otlpDroppedDeltaTemporality, len(points))
+		return nil
+	case dataType == otlpMetricGaugeField, dataType == otlpMetricSumField && !monotonic:
+		metricType = client.GAUGE
+	case dataType == otlpMetricSumField:
+		metricType = client.COUNTER
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+	case dataType == otlpMetricHistogramField:
+		metricType = client.HISTOGRAM
+	case dataType == otlpMetricSummaryField:
+		metricType = client.SUMMARY
+	default:
+		r.drop(otlpDroppedUnsupportedMetricType, 1)
+		return nil
+	}
+
+	for _, p := range points {
+		switch metricType {
+		case client.HISTOGRAM:
+			err = r.translateHistogramDataPoint(p, name, resourceLabels)
+		case client.SUMMARY:
+			err = r.translateSummaryDataPoint(p, name, resourceLabels)
+		default:
+			err = r.translateNumberDataPoint(p, name, resourceLabels)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	r.addMetadata(client.MetricMetadata{Type: metricType, MetricFamilyName: name, Help: help, Unit: unit})
+	return nil
+}
+
+func (r *otlpWriteRequest) translateNumberDataPoint(data []byte, name string, resourceLabels labels.Labels) error {
+	var (
+		attrs     [][]byte
+		timestamp int64
+		val       float64
+		flags     uint64
+	)
+
+	err := forEachField(data, func(f wireField) error {
+		switch {
+		case f.num == otlpNumberDataPointAttributesField && f.typ == protowire.BytesType:
+			attrs = append(attrs, f.bytes)
+		case f.num == otlpNumberDataPointTimeField && f.typ == protowire.Fixed64Type:
+			timestamp = otlpTimestampMs(f.varint)
+		case f.num == otlpNumberDataPointAsDoubleField && f.typ == protowire.Fixed64Type:
+			val = math.Float64frombits(f.varint)
+		case f.num == otlpNumberDataPointAsIntField && f.typ == protowire.Fixed64Type:
+			val = float64(int64(f.varint))
+		case f.num == otlpNumberDataPointFlagsField && f.typ == protowire.VarintType:
+			flags = f.varint
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	lbls, err := otlpSeriesLabels(attrs, resourceLabels)
+	if err != nil {
+		return err
+	}
+
+	r.addSample(lbls, name, nil, val, timestamp, flags)
+	return nil
+}
+
+func (r *otlpWriteRequest) translateHistogramDataPoint(data []byte, name string, resourceLabels labels.Labels) error {
+	var (
+		attrs        [][]byte
+		timestamp    int64
+		count        uint64
+		sum          float64
+		hasSum       bool
+		bucketCounts []uint64
+		bounds       []float64
+		flags        uint64
+	)
+
+	err := forEachField(data, func(f wireField) (err error) {
+		switch {
+		case f.num == otlpHistogramDataPointAttributesField && f.typ == protowire.BytesType:
+			attrs = append(attrs, f.bytes)
+		case f.num == otlpHistogramDataPointTimeField && f.typ == protowire.Fixed64Type:
+			timestamp = otlpTimestampMs(f.varint)
+		case f.num == otlpHistogramDataPointCountField && f.typ == protowire.Fixed64Type:
+			count = f.varint
+		case f.num == otlpHistogramDataPointSumField && f.typ == protowire.Fixed64Type:
+			sum, hasSum = math.Float64frombits(f.varint), true
+		case f.num == otlpHistogramDataPointBucketCountsField:
+			bucketCounts, err = appendFixed64s(bucketCounts, f)
+		case f.num == otlpHistogramDataPointExplicitBoundsField:
+			var bits []uint64
+			if bits, err = appendFixed64s(nil, f); err == nil {
+				for _, b := range bits {
+					bounds = append(bounds, math.Float64frombits(b))
+				}
+			}
+		case f.num == otlpHistogramDataPointFlagsField && f.typ == protowire.VarintType:
+			flags = f.varint
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	lbls, err := otlpSeriesLabels(attrs, resourceLabels)
+	if err != nil {
+		return err
+	}
+
+	// The OTLP bucket counts are not cumulative, and the last bucket is the +Inf one.
+	cumulative := uint64(0)
+	for i, bound := range bounds {
+		if i < len(bucketCounts) {
+			cumulative += bucketCounts[i]
+		}
+		r.addSample(lbls, name+"_bucket", &labels.Label{Name: labels.BucketLabel, Value: formatFloat(bound)}, float64(cumulative), timestamp, flags)
+	}
+	r.addSample(lbls, name+"_bucket", &labels.Label{Name: labels.BucketLabel, Value: "+Inf"}, float64(count), timestamp, flags)
+	r.addSample(lbls, name+"_count", nil, float64(count), timestamp, flags)
+	if hasSum {
+		r.addSample(lbls, name+"_sum", nil, sum, timestamp, flags)
+	}
+
+	return nil
+}
+
+func (r *otlpWriteRequest) translateSummaryDataPoint(data []byte, name string, resourceLabels labels.Labels) error {
+	var (
+		attrs     [][]byte
+		timestamp int64
+		count     uint64
+		sum       float64
+		quantiles [][]byte
+		flags     uint64
+	)
+
+	err := forEachField(data, func(f wireField) error {
+		switch {
+		case f.num == otlpSummaryDataPointAttributesField && f.typ == protowire.BytesType:
+			attrs = append(attrs, f.bytes)
+		case f.num == otlpSummaryDataPointTimeField && f.typ == protowire.Fixed64Type:
+			timestamp = otlpTimestampMs(f.varint)
+		case f.num == otlpSummaryDataPointCountField && f.typ == protowire.Fixed64Type:
+			count = f.varint
+		case f.num == otlpSummaryDataPointSumField && f.typ == protowire.Fixed64Type:
+			sum = math.Float64frombits(f.varint)
+		case f.num == otlpSummaryDataPointQuantileValuesField && f.typ == protowire.BytesType:
+			quantiles = append(quantiles, f.bytes)
+		case f.num == otlpSummaryDataPointFlagsField && f.typ == protowire.VarintType:
+			flags = f.varint
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	lbls, err := otlpSeriesLabels(attrs, resourceLabels)
+	if err != nil {
+		return err
+	}
+
+	for _, q := range quantiles {
+		var quantile, val float64
+		err := forEachField(q, func(f wireField) error {
+			switch {
+			case f.num == otlpValueAtQuantileQuantileField && f.typ == protowire.Fixed64Type:
+				quantile = math.Float64frombits(f.varint)
+			case f.num == otlpValueAtQuantileValueField && f.typ == protowire.Fixed64Type:
+				val = math.Float64frombits(f.varint)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		r.addSample(lbls, name, &labels.Label{Name: "quantile", Value: formatFloat(quantile)}, val, timestamp, flags)
+	}
+	r.addSample(lbls, name+"_count", nil, float64(count), timestamp, flags)
+	r.addSample(lbls, name+"_sum", nil, sum, timestamp, flags)
+
+	return nil
+}
+
+// addSample adds a series with a single sample to the request. The series labels are the
+// input labels plus the metric name and the optional extra label.
+func (r *otlpWriteRequest) addSample(lbls labels.Labels, name string, extra *labels.Label, val float64, timestamp int64, flags uint64) {
+	b := labels.NewBuilder(lbls).Set(labels.MetricName, name)
+	if extra != nil {
+		b.Set(extra.Name, extra.Value)
+	}
+
+	// Data points without a recorded value are converted to staleness markers.
+	if flags&otlpDataPointFlagNoRecordedValue != 0 {
+		val = math.Float64frombits(value.StaleNaN)
+	}
+
+	r.Timeseries = append(r.Timeseries, client.PreallocTimeseries{
+		TimeSeries: &client.TimeSeries{
+			Labels:  client.FromLabelsToLabelAdapters(b.Labels()),
+			Samples: []client.Sample{{Value: val, TimestampMs: timestamp}},
+		},
+	})
+}
+
+func (r *otlpWriteRequest) addMetadata(m client.MetricMetadata) {
+	if _, ok := r.seenMetadata[m.MetricFamilyName]; ok {
+		return
+	}
+	if r.seenMetadata == nil {
+		r.seenMetadata = map[string]struct{}{}
+	}
+	r.seenMetadata[m.MetricFamilyName] = struct{}{}
+	r.Metadata = append(r.Metadata, &m)
+}
+
+func (r *otlpWriteRequest) drop(reason string, count int) {
+	if r.dropped == nil {
+		r.dropped = map[string]int{}
+	}
+	r.dropped[reason] += count
+}
+
+// otlpSeriesLabels returns the labels built from the data point attributes (KeyValue
+// messages), overridden by the resource labels.
+func otlpSeriesLabels(keyValues [][]byte, resourceLabels labels.Labels) (labels.Labels, error) {
+	attrs, err := otlpAttributes(keyValues)
+	if err != nil {
+		return nil, err
+	}
+
+	b := labels.NewBuilder(nil)
+	for name, v := range attrs {
+		b.Set(sanitizeLabelName(name), v)
+	}
+	for _, l := range resourceLabels {
+		b.Set(l.Name, l.Value)
+	}
+
+	return b.Labels(), nil
+}
+
+// otlpAttributes decodes the input KeyValue messages. Attributes with an array or
+// key-value list value are not supported and skipped.
+func otlpAttributes(keyValues [][]byte) (map[string]string, error) {
+	attrs := make(map[string]string, len(keyValues))
+
+	for _, kv := range keyValues {
+		var (
+			key, val string
+			hasValue bool
+		)
+		err := forEachField(kv, func(f wireField) (err error) {
+			switch {
+			case f.num == otlpKeyValueKeyField && f.typ == protowire.BytesType:
+				key = string(f.bytes)
+			case f.num == otlpKeyValueValueField && f.typ == protowire.BytesType:
+				val, hasValue, err = otlpAnyValueString(f.bytes)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if key != "" && hasValue {
+			attrs[key] = val
+		}
+	}
+
+	return attrs, nil
+}
+
+func otlpAnyValueString(data []byte) (val string, ok bool, err error) {
+	err = forEachField(data, func(f wireField) error {
+		switch {
+		case f.num == otlpAnyValueStringField && f.typ == protowire.BytesType:
+			val, ok = string(f.bytes), true
+		case f.num == otlpAnyValueBoolField && f.typ == protowire.VarintType:
+			val, ok = strconv.FormatBool(protowire.DecodeBool(f.varint)), true
+		case f.num == otlpAnyValueIntField && f.typ == protowire.VarintType:
+			val, ok = strconv.FormatInt(int64(f.varint), 10), true
+		case f.num == otlpAnyValueDoubleField && f.typ == protowire.Fixed64Type:
+			val, ok = formatFloat(math.Float64frombits(f.varint)), true
+		case f.num == otlpAnyValueBytesField && f.typ == protowire.BytesType:
+			val, ok = base64.StdEncoding.EncodeToString(f.bytes), true
+		}
+		return nil
+	})
+	return
+}
+
+// appendFixed64s appends a repeated fixed64 field to the input slice, supporting
+// both the packed and non-packed encodings.
+func appendFixed64s(dst []uint64, f wireField) ([]uint64, error) {
+	if f.typ == protowire.Fixed64Type {
+		return append(dst, f.varint), nil
+	}
+	if f.typ != protowire.BytesType {
+		return dst, nil
+	}
+
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeFixed64(b)
+		if n < 0 {
+			return dst, protowire.ParseError(n)
+		}
+		dst = append(dst, v)
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+func otlpTimestampMs(unixNano uint64) int64 {
+	return int64(unixNano / 1e6)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// sanitizeMetricName replaces the characters not allowed in metric names with underscores.
+func sanitizeMetricName(name string) string {
+	return sanitizeName(name, true)
+}
+
+// sanitizeLabelName replaces the characters not allowed in label names with underscores.
+func sanitizeLabelName(name string) string {
+	return sanitizeName(name, false)
+}
+
+func sanitizeName(name string, allowColons bool) string {
+	if name == "" {
+		return name
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || (allowColons && r == ':') {
+			return r
+		}
+		return '_'
+	}, name)
+
+	if sanitized[0] >= '0' && sanitized[0] <= '9' {
+		sanitized = "key_" + sanitized
+	}
+	return sanitized
+}
@@ -0,0 +1,241 @@
+package push
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cortexproject/cortex/pkg/distributor"
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+)
+
+const otlpTestTimestampNs = 1000 * 1e6
+
+type otlpLimitsMock map[string][]string
+
+func (m otlpLimitsMock) PromoteOTelResourceAttributes(userID string) []string {
+	return m[userID]
+}
+
+func TestOTLPHandler(t *testing.T) {
+	resource := otlpResource(
+		otlpKeyValue("service.name", "api"),
+		otlpKeyValue("service.namespace", "prod"),
+		otlpKeyValue("service.instance.id", "host-1"),
+		otlpKeyValue("k8s.cluster.name", "eu-1"),
+		otlpKeyValue("host.arch", "amd64"),
+	)
+
+	metrics := [][]byte{
+		// Gauge with an int value.
+		otlpMetric("memory.usage", "Memory usage.", "By", otlpMetricGaugeField, otlpMessage(
+			otlpBytesField(otlpDataPointsField, otlpNumberDataPoint(otlpNumberDataPointAsIntField, 10, otlpKeyValue("state", "used"))),
+		)),
+		// Monotonic cumulative sum.
+		otlpMetric("requests", "Total requests.", "", otlpMetricSumField, otlpMessage(
+			otlpBytesField(otlpDataPointsField, otlpNumberDataPoint(otlpNumberDataPointAsDoubleField, math.Float64bits(5))),
+			otlpVarintField(otlpAggregationTemporalityField, 2),
+			otlpVarintField(otlpSumIsMonotonicField, 1),
+		)),
+		// Delta sum, which is not supported.
+		otlpMetric("delta", "", "", otlpMetricSumField, otlpMessage(
+			otlpBytesField(otlpDataPointsField, otlpNumberDataPoint(otlpNumberDataPointAsDoubleField, math.Float64bits(5))),
+			otlpVarintField(otlpAggregationTemporalityField, otlpAggregationTemporalityDelta),
+			otlpVarintField(otlpSumIsMonotonicField, 1),
+		)),
+		// Histogram.
+		otlpMetric("latency", "", "s", otlpMetricHistogramField, otlpMessage(
+			otlpBytesField(otlpDataPointsField, otlpMessage(
+				otlpFixed64Field(otlpHistogramDataPointTimeField, otlpTestTimestampNs),
+				otlpFixed64Field(otlpHistogramDataPointCountField, 6),
+				otlpFixed64Field(otlpHistogramDataPointSumField, math.Float64bits(3.5)),
+				otlpBytesField(otlpHistogramDataPointBucketCountsField, otlpPackedFixed64(1, 2, 3)),
+				otlpBytesField(otlpHistogramDataPointExplicitBoundsField, otlpPackedFixed64(math.Float64bits(0.1), math.Float64bits(1))),
+			)),
+			otlpVarintField(otlpAggregationTemporalityField, 2),
+		)),
+		// Summary.
+		otlpMetric("rpc.duration", "", "", otlpMetricSummaryField, otlpMessage(
+			otlpBytesField(otlpDataPointsField, otlpMessage(
+				otlpFixed64Field(otlpSummaryDataPointTimeField, otlpTestTimestampNs),
+				otlpFixed64Field(otlpSummaryDataPointCountField, 2),
+				otlpFixed64Field(otlpSummaryDataPointSumField, math.Float64bits(4)),
+				otlpBytesField(otlpSummaryDataPointQuantileValuesField, otlpMessage(
+					otlpFixed64Field(otlpValueAtQuantileQuantileField, math.Float64bits(0.5)),
+					otlpFixed64Field(otlpValueAtQuantileValueField, math.Float64bits(1.5)),
+				)),
+			)),
+		)),
+	}
+
+	var received *client.WriteRequest
+	handler := OTLPHandler(distributor.Config{MaxRecvMsgSize: 100000}, nil, otlpLimitsMock{"user-1": {"k8s.cluster.name"}}, func(ctx context.Context, req *client.WriteRequest) (*client.WriteResponse, error) {
+		received = req
+		return &client.WriteResponse{}, nil
+	})
+
+	for _, compressed := range []bool{false, true} {
+		received = nil
+
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, createOTLPRequest(t, otlpExportRequest(resource, metrics...), compressed))
+		require.Equal(t, 200, resp.Code, resp.Body.String())
+		assert.NotEmpty(t, resp.Body.Bytes(), "the response should contain the partial success")
+
+		require.NotNil(t, received)
+		assert.Equal(t, client.API, received.Source)
+
+		actual := map[string]float64{}
+		for _, ts := range received.Timeseries {
+			require.Len(t, ts.Samples, 1)
+			assert.Equal(t, int64(1000), ts.Samples[0].TimestampMs)
+			actual[client.FromLabelAdaptersToLabels(ts.Labels).String()] = ts.Samples[0].Value
+		}
+
+		const resourceLabels = `instance="host-1", job="prod/api", k8s_cluster_name="eu-1"`
+		assert.Equal(t, map[string]float64{
+			`{__name__="memory_usage", ` + resourceLabels + `, state="used"}`:   10,
+			`{__name__="requests_total", ` + resourceLabels + `}`:               5,
+			`{__name__="latency_bucket", ` + resourceLabels + `, le="0.1"}`:     1,
+			`{__name__="latency_bucket", ` + resourceLabels + `, le="1"}`:       3,
+			`{__name__="latency_bucket", ` + resourceLabels + `, le="+Inf"}`:    6,
+			`{__name__="latency_count", ` + resourceLabels + `}`:                6,
+			`{__name__="latency_sum", ` + resourceLabels + `}`:                  3.5,
+			`{__name__="rpc_duration", ` + resourceLabels + `, quantile="0.5"}`: 1.5,
+			`{__name__="rpc_duration_count", ` + resourceLabels + `}`:           2,
+			`{__name__="rpc_duration_sum", ` + resourceLabels + `}`:             4,
+		}, actual)
+
+		assert.Equal(t, []*client.MetricMetadata{
+			{Type: client.GAUGE, MetricFamilyName: "memory_usage", Help: "Memory usage.", Unit: "By"},
+			{Type: client.COUNTER, MetricFamilyName: "requests_total", Help: "Total requests."},
+			{Type: client.HISTOGRAM, MetricFamilyName: "latency", Unit: "s"},
+			{Type: client.SUMMARY, MetricFamilyName: "rpc_duration"},
+		}, received.Metadata)
+	}
+}
+
+func TestOTLPHandler_ShouldRejectUnsupportedContentType(t *testing.T) {
+	handler := OTLPHandler(distributor.Config{MaxRecvMsgSize: 100000}, nil, otlpLimitsMock{}, func(ctx context.Context, req *client.WriteRequest) (*client.WriteResponse, error) {
+		t.Fatal("push should not be called")
+		return nil, nil
+	})
+
+	req := createOTLPRequest(t, []byte("{}"), false)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.Code)
+}
+
+func TestSanitizeName(t *testing.T) {
+	assert.Equal(t, "http_server_duration", sanitizeMetricName("http.server.duration"))
+	assert.Equal(t, "job:requests:rate5m", sanitizeMetricName("job:requests:rate5m"))
+	assert.Equal(t, "job_requests", sanitizeLabelName("job:requests"))
+	assert.Equal(t, "key_0_name", sanitizeLabelName("0.name"))
+	assert.Equal(t, "", sanitizeLabelName(""))
+}
+
+func createOTLPRequest(t *testing.T, body []byte, compressed bool) *http.Request {
+	t.Helper()
+
+	if compressed {
+		buf := bytes.Buffer{}
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(body)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		body = buf.Bytes()
+	}
+
+	req, err := http.NewRequest("POST", "http://localhost/otlp/v1/metrics", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	return req.WithContext(user.InjectOrgID(req.Context(), "user-1"))
+}
+
+func otlpExportRequest(resource []byte, metrics ...[]byte) []byte {
+	scopeMetrics := []byte(nil)
+	for _, m := range metrics {
+		scopeMetrics = append(scopeMetrics, otlpBytesField(otlpScopeMetricsMetricsField, m)...)
+	}
+
+	return otlpBytesField(otlpRequestResourceMetricsField, otlpMessage(
+		otlpBytesField(otlpResourceMetricsResourceField, resource),
+		otlpBytesField(otlpResourceMetricsScopeMetricsField, scopeMetrics),
+	))
+}
+
+func otlpMetric(name, help, unit string, dataField protowire.Number, data []byte) []byte {
+	return otlpMessage(
+		otlpBytesField(otlpMetricNameField, []byte(name)),
+		otlpBytesField(otlpMetricDescriptionField, []byte(help)),
+		otlpBytesField(otlpMetricUnitField, []byte(unit)),
+		otlpBytesField(dataField, data),
+	)
+}
+
+func otlpNumberDataPoint(valueField protowire.Number, value uint64, attrs ...[]byte) []byte {
+	fields := [][]byte{
+		otlpFixed64Field(otlpNumberDataPointTimeField, otlpTestTimestampNs),
+		otlpFixed64Field(valueField, value),
+	}
+	for _, attr := range attrs {
+		fields = append(fields, otlpBytesField(otlpNumberDataPointAttributesField, attr))
+	}
+	return otlpMessage(fields...)
+}
+
+func otlpResource(attrs ...[]byte) []byte {
+	fields := [][]byte(nil)
+	for _, attr := range attrs {
+		fields = append(fields, otlpBytesField(otlpResourceAttributesField, attr))
+	}
+	return otlpMessage(fields...)
+}
+
+// otlpKeyValue returns a KeyValue message with a string value.
+func otlpKeyValue(key, value string) []byte {
+	return otlpMessage(
+		otlpBytesField(otlpKeyValueKeyField, []byte(key)),
+		otlpBytesField(otlpKeyValueValueField, otlpBytesField(otlpAnyValueStringField, []byte(value))),
+	)
+}
+
+func otlpMessage(fields ...[]byte) []byte {
+	return bytes.Join(fields, nil)
+}
+
+func otlpBytesField(num protowire.Number, value []byte) []byte {
+	return protowire.AppendBytes(protowire.AppendTag(nil, num, protowire.BytesType), value)
+}
+
+func otlpVarintField(num protowire.Number, value uint64) []byte {
+	return protowire.AppendVarint(protowire.AppendTag(nil, num, protowire.VarintType), value)
+}
+
+func otlpFixed64Field(num protowire.Number, value uint64) []byte {
+	return protowire.AppendFixed64(protowire.AppendTag(nil, num, protowire.Fixed64Type), value)
+}
+
+func otlpPackedFixed64(values ...uint64) []byte {
+	packed := []byte(nil)
+	for _, v := range values {
+		packed = protowire.AppendFixed64(packed, v)
+	}
+	return packed
+}
@@ -2,9 +2,14 @@ package push
 
 import (
 	"context"
+	"fmt"
+	"mime"
 	"net/http"
+	"strconv"
 
 	"github.com/go-kit/kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/weaveworks/common/httpgrpc"
 	"github.com/weaveworks/common/middleware"
 
@@ -13,6 +18,33 @@ import (
 	"github.com/cortexproject/cortex/pkg/util"
 )
 
+const (
+	remoteWriteV1 = "v1"
+	remoteWriteV2 = "v2"
+
+	// Protobuf messages accepted by the Content-Type "proto" parameter.
+	remoteWriteV1ProtoMessage = "prometheus.WriteRequest"
+	remoteWriteV2ProtoMessage = "io.prometheus.write.v2.Request"
+
+	// Response headers defined by the Remote Write 2.0 spec.
+	remoteWriteSamplesWrittenHeader    = "X-Prometheus-Remote-Write-Samples-Written"
+	remoteWriteHistogramsWrittenHeader = "X-Prometheus-Remote-Write-Histograms-Written"
+	remoteWriteExemplarsWrittenHeader  = "X-Prometheus-Remote-Write-Exemplars-Written"
+)
+
+var (
+	remoteWriteRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "distributor_remote_write_requests_total",
+		Help:      "The total number of remote write requests received, by protocol version.",
+	}, []string{"version"})
+	remoteWriteDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cortex",
+		Name:      "distributor_remote_write_dropped_total",
+		Help:      "The total number of native histograms and exemplars received via remote write 2.0 and dropped because not supported.",
+	}, []string{"type"})
+)
+
 // Handler is a http.Handler which accepts WriteRequests.
 func Handler(cfg distributor.Config, sourceIPs *middleware.SourceIPExtractor, push func(context.Context, *client.WriteRequest) (*client.WriteResponse, error)) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -25,9 +57,32 @@ func Handler(cfg distributor.Config, sourceIPs *middleware.SourceIPExtractor, pu
 				logger = util.WithSourceIPs(source, logger)
 			}
 		}
-		compressionType := util.CompressionTypeFor(r.Header.Get("X-Prometheus-Remote-Write-Version"))
-		var req client.PreallocWriteRequest
-		err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), cfg.MaxRecvMsgSize, &req, compressionType)
+
+		version, err := remoteWriteVersionFor(r.Header.Get("Content-Type"))
+		if err != nil {
+			level.Error(logger).Log("err", err.Error())
+			http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+			return
+		}
+		remoteWriteRequests.WithLabelValues(version).Inc()
+
+		var req *client.WriteRequest
+		if version == remoteWriteV2 {
+			// Remote write 2.0 always uses the snappy block format.
+			var v2 writeRequestV2
+			err = util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), cfg.MaxRecvMsgSize, &v2, util.RawSnappy)
+			req = &v2.WriteRequest
+
+			if err == nil {
+				remoteWriteDropped.WithLabelValues("histogram").Add(float64(v2.histograms))
+				remoteWriteDropped.WithLabelValues("exemplar").Add(float64(v2.exemplars))
+			}
+		} else {
+			compressionType := util.CompressionTypeFor(r.Header.Get("X-Prometheus-Remote-Write-Version"))
+			var v1 client.PreallocWriteRequest
+			err = util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), cfg.MaxRecvMsgSize, &v1, compressionType)
+			req = &v1.WriteRequest
+		}
 		if err != nil {
 			level.Error(logger).Log("err", err.Error())
 			http.Error(w, err.Error(), http.StatusBadRequest)
@@ -37,7 +92,13 @@ func Handler(cfg distributor.Config, sourceIPs *middleware.SourceIPExtractor, pu
 			req.Source = client.API
 		}
 
-		if _, err := push(ctx, &req.WriteRequest); err != nil {
+		// Count the samples before pushing, because the request may be reused afterwards.
+		numSamples := 0
+		for _, ts := range req.Timeseries {
+			numSamples += len(ts.Samples)
+		}
+
+		if _, err := push(ctx, req); err != nil {
 			resp, ok := httpgrpc.HTTPResponseFromError(err)
 			if !ok {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -47,6 +108,36 @@ func Handler(cfg distributor.Config, sourceIPs *middleware.SourceIPExtractor, pu
 				level.Error(logger).Log("msg", "push error", "err", err)
 			}
 			http.Error(w, string(resp.Body), int(resp.Code))
+			return
+		}
+
+		if version == remoteWriteV2 {
+			w.Header().Set(remoteWriteSamplesWrittenHeader, strconv.Itoa(numSamples))
+			w.Header().Set(remoteWriteHistogramsWrittenHeader, "0")
+			w.Header().Set(remoteWriteExemplarsWrittenHeader, "0")
 		}
 	})
 }
+
+// remoteWriteVersionFor returns the remote write protocol version for the input Content-Type.
+// Requests without a protobuf Content-Type are assumed to be remote write 1.0 requests,
+// for backward compatibility with clients not setting it.
+func remoteWriteVersionFor(contentType string) (string, error) {
+	if contentType == "" {
+		return remoteWriteV1, nil
+	}
+
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "application/x-protobuf" {
+		return remoteWriteV1, nil
+	}
+
+	switch params["proto"] {
+	case "", remoteWriteV1ProtoMessage:
+		return remoteWriteV1, nil
+	case remoteWriteV2ProtoMessage:
+		return remoteWriteV2, nil
+	default:
+		return "", fmt.Errorf("unsupported remote write protobuf message %q", params["proto"])
+	}
+}
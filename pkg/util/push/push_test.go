@@ -3,6 +3,7 @@ package push
 import (
 	"bytes"
 	"context"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -13,6 +14,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/weaveworks/common/middleware"
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"github.com/cortexproject/cortex/pkg/distributor"
 	"github.com/cortexproject/cortex/pkg/ingester/client"
@@ -35,6 +37,56 @@ func TestHandler_cortexWriteRequest(t *testing.T) {
 	assert.Equal(t, 200, resp.Code)
 }
 
+func TestHandler_remoteWriteV2(t *testing.T) {
+	var received *client.WriteRequest
+
+	req := createRequest(t, createPrometheusRemoteWriteV2Protobuf(t, 1, 2))
+	req.Header.Set("Content-Type", "application/x-protobuf;proto=io.prometheus.write.v2.Request")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "2.0.0")
+	resp := httptest.NewRecorder()
+	handler := Handler(distributor.Config{MaxRecvMsgSize: 100000}, nil, func(ctx context.Context, request *client.WriteRequest) (*client.WriteResponse, error) {
+		received = request
+		return &client.WriteResponse{}, nil
+	})
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, 200, resp.Code)
+	assert.Equal(t, "1", resp.Header().Get("X-Prometheus-Remote-Write-Samples-Written"))
+	assert.Equal(t, "0", resp.Header().Get("X-Prometheus-Remote-Write-Exemplars-Written"))
+
+	require.NotNil(t, received)
+	assert.Equal(t, client.API, received.Source)
+	require.Len(t, received.Timeseries, 1)
+	assert.Equal(t, []client.LabelAdapter{{Name: "__name__", Value: "foo_total"}, {Name: "job", Value: "test"}}, received.Timeseries[0].Labels)
+	assert.Equal(t, []client.Sample{{Value: 1.5, TimestampMs: 1000}}, received.Timeseries[0].Samples)
+	assert.Equal(t, []*client.MetricMetadata{{
+		Type:             client.COUNTER,
+		MetricFamilyName: "foo_total",
+		Help:             "Total number of foos.",
+		Unit:             "",
+	}}, received.Metadata)
+}
+
+func TestHandler_remoteWriteV2InvalidSymbolRef(t *testing.T) {
+	req := createRequest(t, createPrometheusRemoteWriteV2Protobuf(t, 1, 10))
+	req.Header.Set("Content-Type", "application/x-protobuf;proto=io.prometheus.write.v2.Request")
+	resp := httptest.NewRecorder()
+	handler := Handler(distributor.Config{MaxRecvMsgSize: 100000}, nil, func(ctx context.Context, request *client.WriteRequest) (*client.WriteResponse, error) {
+		t.Fatal("push should not be called")
+		return nil, nil
+	})
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, 400, resp.Code)
+}
+
+func TestHandler_unsupportedProtoMessage(t *testing.T) {
+	req := createRequest(t, createPrometheusRemoteWriteProtobuf(t))
+	req.Header.Set("Content-Type", "application/x-protobuf;proto=io.prometheus.write.v3.Request")
+	resp := httptest.NewRecorder()
+	handler := Handler(distributor.Config{MaxRecvMsgSize: 100000}, nil, verifyWriteRequestHandler(t, client.API))
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, 415, resp.Code)
+}
+
 func verifyWriteRequestHandler(t *testing.T, expectSource client.WriteRequest_SourceEnum) func(ctx context.Context, request *client.WriteRequest) (response *client.WriteResponse, err error) {
 	t.Helper()
 	return func(ctx context.Context, request *client.WriteRequest) (response *client.WriteResponse, err error) {
@@ -95,3 +147,53 @@ func createCortexWriteRequestProtobuf(t *testing.T) []byte {
 	require.NoError(t, err)
 	return inoutBytes
 }
+
+// createPrometheusRemoteWriteV2Protobuf encodes a remote write 2.0 request containing
+// a single counter series with a sample, an exemplar and metadata. The series labels
+// reference the symbols at the input indexes.
+func createPrometheusRemoteWriteV2Protobuf(t *testing.T, nameRef, valueRef uint64) []byte {
+	t.Helper()
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(1.5))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, 1000)
+
+	var exemplar []byte
+	exemplar = protowire.AppendTag(exemplar, 2, protowire.Fixed64Type)
+	exemplar = protowire.AppendFixed64(exemplar, math.Float64bits(1))
+
+	var metadata []byte
+	metadata = protowire.AppendTag(metadata, 1, protowire.VarintType)
+	metadata = protowire.AppendVarint(metadata, uint64(client.COUNTER))
+	metadata = protowire.AppendTag(metadata, 3, protowire.VarintType)
+	metadata = protowire.AppendVarint(metadata, 5)
+
+	var refs []byte
+	for _, ref := range []uint64{nameRef, valueRef, 3, 4} {
+		refs = protowire.AppendVarint(refs, ref)
+	}
+
+	var series []byte
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	series = protowire.AppendBytes(series, refs)
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	series = protowire.AppendBytes(series, sample)
+	series = protowire.AppendTag(series, 4, protowire.BytesType)
+	series = protowire.AppendBytes(series, exemplar)
+	series = protowire.AppendTag(series, 5, protowire.BytesType)
+	series = protowire.AppendBytes(series, metadata)
+	series = protowire.AppendTag(series, 6, protowire.VarintType)
+	series = protowire.AppendVarint(series, 500)
+
+	var req []byte
+	for _, symbol := range []string{"", "__name__", "foo_total", "job", "test", "Total number of foos."} {
+		req = protowire.AppendTag(req, 4, protowire.BytesType)
+		req = protowire.AppendString(req, symbol)
+	}
+	req = protowire.AppendTag(req, 5, protowire.BytesType)
+	req = protowire.AppendBytes(req, series)
+
+	return req
+}
@@ -0,0 +1,276 @@
+package push
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/cortexproject/cortex/pkg/ingester/client"
+)
+
+// Field numbers of the io.prometheus.write.v2.Request message and its nested messages.
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/.
+const (
+	v2RequestSymbolsField    = 4
+	v2RequestTimeseriesField = 5
+
+	v2TimeSeriesLabelsRefsField       = 1
+	v2TimeSeriesSamplesField          = 2
+	v2TimeSeriesHistogramsField       = 3
+	v2TimeSeriesExemplarsField        = 4
+	v2TimeSeriesMetadataField         = 5
+	v2TimeSeriesCreatedTimestampField = 6
+
+	v2SampleValueField     = 1
+	v2SampleTimestampField = 2
+
+	v2MetadataTypeField    = 1
+	v2MetadataHelpRefField = 3
+	v2MetadataUnitRefField = 4
+)
+
+var errInvalidSymbolRef = errors.New("invalid symbol reference")
+
+// writeRequestV2 is a Prometheus Remote Write 2.0 request, decoded straight into
+// the Cortex WriteRequest. Data which can't be ingested by Cortex (native histograms
+// and exemplars) is counted and dropped, while created timestamps are ignored.
+type writeRequestV2 struct {
+	client.WriteRequest
+
+	histograms int
+	exemplars  int
+
+	// Metric families for which metadata has already been added to the request.
+	seenMetadata map[string]struct{}
+}
+
+func (r *writeRequestV2) Reset() {
+	*r = writeRequestV2{}
+}
+
+// Unmarshal implements proto.Unmarshaler.
+func (r *writeRequestV2) Unmarshal(data []byte) error {
+	// The symbols table can be encoded anywhere in the message, so
+	// it needs to be read before decoding the series.
+	var symbols []string
+
+	err := forEachField(data, func(f wireField) error {
+		if f.num == v2RequestSymbolsField && f.typ == protowire.BytesType {
+			symbols = append(symbols, string(f.bytes))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(symbols) > 0 && symbols[0] != "" {
+		return errors.New("the first entry of the symbols table must be an empty string")
+	}
+
+	return forEachField(data, func(f wireField) error {
+		if f.num == v2RequestTimeseriesField && f.typ == protowire.BytesType {
+			return r.unmarshalTimeseries(f.bytes, symbols)
+		}
+		return nil
+	})
+}
+
+func (r *writeRequestV2) unmarshalTimeseries(data []byte, symbols []string) error {
+	var (
+		refs    []uint32
+		samples []client.Sample
+
+		hasMetadata bool
+		metadata    client.MetricMetadata
+	)
+
+	err := forEachField(data, func(f wireField) (err error) {
+		switch f.num {
+		case v2TimeSeriesLabelsRefsField:
+			refs, err = appendUint32s(refs, f)
+			return err
+
+		case v2TimeSeriesSamplesField:
+			var s client.Sample
+			if s, err = unmarshalSampleV2(f.bytes); err != nil {
+				return err
+			}
+			samples = append(samples, s)
+
+		case v2TimeSeriesHistogramsField:
+			r.histograms++
+
+		case v2TimeSeriesExemplarsField:
+			r.exemplars++
+
+		case v2TimeSeriesMetadataField:
+			hasMetadata = true
+			metadata, err = unmarshalMetadataV2(f.bytes, symbols)
+			return err
+
+		case v2TimeSeriesCreatedTimestampField:
+			// Not supported by the ingesters, so it's ignored.
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(refs)%2 != 0 {
+		return errors.New("odd number of label references")
+	}
+
+	lbls := make([]client.LabelAdapter, 0, len(refs)/2)
+	for i := 0; i < len(refs); i += 2 {
+		name, value := lookupSymbol(symbols, refs[i]), lookupSymbol(symbols, refs[i+1])
+		if name == nil || value == nil {
+			return errInvalidSymbolRef
+		}
+		lbls = append(lbls, client.LabelAdapter{Name: *name, Value: *value})
+	}
+
+	if len(samples) > 0 {
+		r.Timeseries = append(r.Timeseries, client.PreallocTimeseries{
+			TimeSeries: &client.TimeSeries{Labels: lbls, Samples: samples},
+		})
+	}
+
+	if hasMetadata && !isEmptyMetadata(metadata) {
+		metadata.MetricFamilyName = client.FromLabelAdaptersToLabels(lbls).Get("__name__")
+
+		if _, ok := r.seenMetadata[metadata.MetricFamilyName]; !ok && metadata.MetricFamilyName != "" {
+			if r.seenMetadata == nil {
+				r.seenMetadata = map[string]struct{}{}
+			}
+			r.seenMetadata[metadata.MetricFamilyName] = struct{}{}
+
+			m := metadata
+			r.Metadata = append(r.Metadata, &m)
+		}
+	}
+
+	return nil
+}
+
+func unmarshalSampleV2(data []byte) (client.Sample, error) {
+	s := client.Sample{}
+
+	err := forEachField(data, func(f wireField) error {
+		switch {
+		case f.num == v2SampleValueField && f.typ == protowire.Fixed64Type:
+			s.Value = math.Float64frombits(f.varint)
+		case f.num == v2SampleTimestampField && f.typ == protowire.VarintType:
+			s.TimestampMs = int64(f.varint)
+		}
+		return nil
+	})
+
+	return s, err
+}
+
+func unmarshalMetadataV2(data []byte, symbols []string) (client.MetricMetadata, error) {
+	m := client.MetricMetadata{}
+
+	err := forEachField(data, func(f wireField) error {
+		if f.typ != protowire.VarintType {
+			return nil
+		}
+
+		switch f.num {
+		case v2MetadataTypeField:
+			m.Type = client.MetricMetadata_MetricType(f.varint)
+		case v2MetadataHelpRefField, v2MetadataUnitRefField:
+			s := lookupSymbol(symbols, uint32(f.varint))
+			if s == nil {
+				return errInvalidSymbolRef
+			}
+			if f.num == v2MetadataHelpRefField {
+				m.Help = *s
+			} else {
+				m.Unit = *s
+			}
+		}
+		return nil
+	})
+
+	return m, err
+}
+
+func isEmptyMetadata(m client.MetricMetadata) bool {
+	return m.Type == client.UNKNOWN && m.Help == "" && m.Unit == ""
+}
+
+func lookupSymbol(symbols []string, ref uint32) *string {
+	if int(ref) >= len(symbols) {
+		return nil
+	}
+	return &symbols[ref]
+}
+
+// appendUint32s appends a repeated uint32 field to the input slice, supporting
+// both the packed and non-packed encodings.
+func appendUint32s(dst []uint32, f wireField) ([]uint32, error) {
+	if f.typ == protowire.VarintType {
+		return append(dst, uint32(f.varint)), nil
+	}
+	if f.typ != protowire.BytesType {
+		return dst, nil
+	}
+
+	for b := f.bytes; len(b) > 0; {
+		v, n := protowire.ConsumeVarint(b)
+		if n < 0 {
+			return dst, protowire.ParseError(n)
+		}
+		dst = append(dst, uint32(v))
+		b = b[n:]
+	}
+	return dst, nil
+}
+
+// wireField is a single field read from a protobuf-encoded message.
+type wireField struct {
+	num protowire.Number
+	typ protowire.Type
+
+	// Value of varint and fixed64 fields.
+	varint uint64
+
+	// Value of length-delimited fields.
+	bytes []byte
+}
+
+// forEachField calls fn for each field of the protobuf-encoded message.
+func forEachField(data []byte, fn func(f wireField) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		f := wireField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.varint, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
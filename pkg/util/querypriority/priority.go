@@ -0,0 +1,157 @@
+package querypriority
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/util"
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+// HeaderName is the HTTP header clients can use to set the priority of a query,
+// when enabled for the tenant.
+const HeaderName = "X-Cortex-Query-Priority"
+
+type headerCtxKey struct{}
+
+var ctxKey = &headerCtxKey{}
+
+// Limits required to compute the priority of a query.
+type Limits interface {
+	// QueryPriorities returns the priorities assigned to the tenant's queries.
+	QueryPriorities(userID string) []*validation.QueryPriority
+
+	// DefaultQueryPriority returns the priority of the tenant's queries not matching any query priority.
+	DefaultQueryPriority(userID string) int64
+
+	// QueryPriorityHeaderEnabled returns whether the tenant's queries priority can be set via HTTP header.
+	QueryPriorityHeaderEnabled(userID string) bool
+}
+
+// ContextWithHeaderValue returns a copy of the input context carrying the priority header value
+// received from the client, so that it can be propagated to the requests derived from the
+// original one (eg. split by the query-frontend).
+func ContextWithHeaderValue(ctx context.Context, value string) context.Context {
+	return context.WithValue(ctx, ctxKey, value)
+}
+
+// HeaderValueFromContext returns the priority header value carried by the context, if any.
+func HeaderValueFromContext(ctx context.Context) string {
+	value, _ := ctx.Value(ctxKey).(string)
+	return value
+}
+
+// Get returns the priority of the input request issued by the tenant. The priority set via
+// HTTP header (if enabled for the tenant) takes precedence over the first query priority
+// matching the query; if none, the default priority of the tenant is returned.
+func Get(req *httpgrpc.HTTPRequest, userID string, limits Limits) int64 {
+	return newRequest(req).priority(userID, limits)
+}
+
+// GetForTenants returns the priority of the input request issued by the tenants (e.g. a query
+// federated across multiple tenants), which is the lowest of the priorities of each tenant, so
+// that a query spanning multiple tenants can't get a priority higher than the ones it would get
+// when issued by any of them.
+func GetForTenants(req *httpgrpc.HTTPRequest, tenantIDs []string, limits Limits) int64 {
+	// The request is parsed once, and shared by all the tenants.
+	r := newRequest(req)
+
+	var result int64
+	for i, tenantID := range tenantIDs {
+		if priority := r.priority(tenantID, limits); i == 0 || priority < result {
+			result = priority
+		}
+	}
+	return result
+}
+
+// request is a query request whose query and time range are parsed lazily, at most once,
+// only if a query priority has to be matched.
+type request struct {
+	req *httpgrpc.HTTPRequest
+
+	parsed    bool
+	query     string
+	timeRange time.Duration
+}
+
+func newRequest(req *httpgrpc.HTTPRequest) *request {
+	return &request{req: req}
+}
+
+func (r *request) priority(userID string, limits Limits) int64 {
+	if limits.QueryPriorityHeaderEnabled(userID) {
+		if value := r.headerValue(); value != "" {
+			if priority, err := strconv.ParseInt(value, 10, 64); err == nil {
+				return priority
+			}
+		}
+	}
+
+	priorities := limits.QueryPriorities(userID)
+	if len(priorities) == 0 {
+		return limits.DefaultQueryPriority(userID)
+	}
+
+	query, timeRange := r.queryAndTimeRange()
+	if query == "" {
+		return limits.DefaultQueryPriority(userID)
+	}
+
+	for _, p := range priorities {
+		if p.Matches(query, timeRange) {
+			return p.Priority
+		}
+	}
+
+	return limits.DefaultQueryPriority(userID)
+}
+
+func (r *request) headerValue() string {
+	for _, h := range r.req.Headers {
+		if http.CanonicalHeaderKey(h.Key) == HeaderName && len(h.Values) > 0 {
+			return h.Values[0]
+		}
+	}
+	return ""
+}
+
+// queryAndTimeRange returns the query and its time range, or an empty query if the
+// request can't be parsed. Instant queries have a time range of zero.
+func (r *request) queryAndTimeRange() (string, time.Duration) {
+	if r.parsed {
+		return r.query, r.timeRange
+	}
+	r.parsed = true
+
+	httpReq, err := http.NewRequest(r.req.Method, r.req.Url, bytes.NewReader(r.req.Body))
+	if err != nil {
+		return "", 0
+	}
+	for _, h := range r.req.Headers {
+		for _, v := range h.Values {
+			httpReq.Header.Add(h.Key, v)
+		}
+	}
+
+	if err := httpReq.ParseForm(); err != nil {
+		return "", 0
+	}
+
+	r.query = strings.TrimSpace(httpReq.Form.Get("query"))
+	if strings.HasSuffix(httpReq.URL.Path, "/query_range") {
+		start, startErr := util.ParseTime(httpReq.Form.Get("start"))
+		end, endErr := util.ParseTime(httpReq.Form.Get("end"))
+		if startErr == nil && endErr == nil && end > start {
+			r.timeRange = time.Duration(end-start) * time.Millisecond
+		}
+	}
+
+	return r.query, r.timeRange
+}
@@ -0,0 +1,144 @@
+package querypriority
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/weaveworks/common/httpgrpc"
+
+	"github.com/cortexproject/cortex/pkg/util/validation"
+)
+
+func TestGet(t *testing.T) {
+	priorities := []*validation.QueryPriority{
+		{Priority: 10, Pattern: "up"},
+		{Priority: -10, Pattern: "sum.*", Regex: true, MinTimeRange: 24 * time.Hour},
+		{Priority: 5, Pattern: "sum.*", Regex: true},
+	}
+
+	tests := map[string]struct {
+		req      *httpgrpc.HTTPRequest
+		limits   mockLimits
+		expected int64
+	}{
+		"no query priorities configured": {
+			req:      &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=up"},
+			limits:   mockLimits{defaultPriority: 1},
+			expected: 1,
+		},
+		"instant query matching a query priority": {
+			req:      &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=up"},
+			limits:   mockLimits{priorities: priorities, defaultPriority: 1},
+			expected: 10,
+		},
+		"instant query not matching any query priority": {
+			req:      &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=rate(foo[1m])"},
+			limits:   mockLimits{priorities: priorities, defaultPriority: 1},
+			expected: 1,
+		},
+		"range query shorter than the min time range": {
+			req:      &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query_range?query=sum(up)&start=0&end=3600&step=60"},
+			limits:   mockLimits{priorities: priorities},
+			expected: 5,
+		},
+		"range query longer than the min time range": {
+			req:      &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query_range?query=sum(up)&start=0&end=172800&step=60"},
+			limits:   mockLimits{priorities: priorities},
+			expected: -10,
+		},
+		"query sent as form in the request body": {
+			req: &httpgrpc.HTTPRequest{
+				Method:  "POST",
+				Url:     "/api/v1/query",
+				Headers: []*httpgrpc.Header{{Key: "Content-Type", Values: []string{"application/x-www-form-urlencoded"}}},
+				Body:    []byte("query=up"),
+			},
+			limits:   mockLimits{priorities: priorities},
+			expected: 10,
+		},
+		"priority header ignored if not enabled": {
+			req: &httpgrpc.HTTPRequest{
+				Method:  "GET",
+				Url:     "/api/v1/query?query=up",
+				Headers: []*httpgrpc.Header{{Key: HeaderName, Values: []string{"100"}}},
+			},
+			limits:   mockLimits{priorities: priorities},
+			expected: 10,
+		},
+		"priority header takes precedence if enabled": {
+			req: &httpgrpc.HTTPRequest{
+				Method:  "GET",
+				Url:     "/api/v1/query?query=up",
+				Headers: []*httpgrpc.Header{{Key: HeaderName, Values: []string{"100"}}},
+			},
+			limits:   mockLimits{priorities: priorities, headerEnabled: true},
+			expected: 100,
+		},
+		"invalid priority header is ignored": {
+			req: &httpgrpc.HTTPRequest{
+				Method:  "GET",
+				Url:     "/api/v1/query?query=up",
+				Headers: []*httpgrpc.Header{{Key: HeaderName, Values: []string{"high"}}},
+			},
+			limits:   mockLimits{priorities: priorities, headerEnabled: true},
+			expected: 10,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, Get(testData.req, "user-1", testData.limits))
+		})
+	}
+}
+
+func TestContextWithHeaderValue(t *testing.T) {
+	assert.Equal(t, "", HeaderValueFromContext(context.Background()))
+	assert.Equal(t, "10", HeaderValueFromContext(ContextWithHeaderValue(context.Background(), "10")))
+}
+
+func TestGetForTenants(t *testing.T) {
+	limits := mockTenantLimits{
+		"user-1": {priorities: []*validation.QueryPriority{{Priority: 10, Pattern: "up"}}},
+		"user-2": {defaultPriority: 1},
+	}
+	req := &httpgrpc.HTTPRequest{Method: "GET", Url: "/api/v1/query?query=up"}
+
+	assert.Equal(t, int64(10), GetForTenants(req, []string{"user-1"}, limits))
+	assert.Equal(t, int64(1), GetForTenants(req, []string{"user-2"}, limits))
+	assert.Equal(t, int64(1), GetForTenants(req, []string{"user-1", "user-2"}, limits))
+}
+
+type mockLimits struct {
+	priorities      []*validation.QueryPriority
+	defaultPriority int64
+	headerEnabled   bool
+}
+
+func (m mockLimits) QueryPriorities(_ string) []*validation.QueryPriority {
+	return m.priorities
+}
+
+func (m mockLimits) DefaultQueryPriority(_ string) int64 {
+	return m.defaultPriority
+}
+
+func (m mockLimits) QueryPriorityHeaderEnabled(_ string) bool {
+	return m.headerEnabled
+}
+
+type mockTenantLimits map[string]mockLimits
+
+func (m mockTenantLimits) QueryPriorities(userID string) []*validation.QueryPriority {
+	return m[userID].priorities
+}
+
+func (m mockTenantLimits) DefaultQueryPriority(userID string) int64 {
+	return m[userID].defaultPriority
+}
+
+func (m mockTenantLimits) QueryPriorityHeaderEnabled(userID string) bool {
+	return m[userID].headerEnabled
+}
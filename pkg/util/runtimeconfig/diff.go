@@ -0,0 +1,117 @@
+package runtimeconfig
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigChange is a value changed by a runtime config reload.
+type ConfigChange struct {
+	// Path of the value in the YAML config, with the keys separated by dots.
+	Path string      `yaml:"path" json:"path"`
+	Old  interface{} `yaml:"old,omitempty" json:"old,omitempty"`
+	New  interface{} `yaml:"new,omitempty" json:"new,omitempty"`
+}
+
+// mergeYAML merges the YAML documents in order: the maps are merged recursively,
+// and any other value of a document overrides the value of the previous ones.
+func mergeYAML(docs [][]byte) ([]byte, error) {
+	var merged interface{}
+	for _, doc := range docs {
+		var value interface{}
+		if err := yaml.Unmarshal(doc, &value); err != nil {
+			return nil, err
+		}
+		merged = mergeValues(merged, value)
+	}
+
+	if merged == nil {
+		return nil, nil
+	}
+	return yaml.Marshal(merged)
+}
+
+func mergeValues(dst, src interface{}) interface{} {
+	dstMap, dstOK := dst.(map[interface{}]interface{})
+	srcMap, srcOK := src.(map[interface{}]interface{})
+	if !dstOK || !srcOK {
+		if src == nil {
+			return dst
+		}
+		return src
+	}
+
+	for k, v := range srcMap {
+		dstMap[k] = mergeValues(dstMap[k], v)
+	}
+	return dstMap
+}
+
+// diffConfigs returns the values changed between the two configs, sorted by path.
+func diffConfigs(oldConfig, newConfig interface{}) ([]ConfigChange, error) {
+	oldValues, err := flattenConfig(oldConfig)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := flattenConfig(newConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []ConfigChange
+	for path, oldValue := range oldValues {
+		if newValue, ok := newValues[path]; !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes = append(changes, ConfigChange{Path: path, Old: oldValue, New: newValue})
+		}
+	}
+	for path, newValue := range newValues {
+		if _, ok := oldValues[path]; !ok {
+			changes = append(changes, ConfigChange{Path: path, New: newValue})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// flattenConfig returns the leaf values of the config, as serialized in YAML, by their path.
+func flattenConfig(config interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if config == nil {
+		return out, nil
+	}
+
+	buf, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	if err := yaml.Unmarshal(buf, &value); err != nil {
+		return nil, err
+	}
+
+	flattenValue("", value, out)
+	return out, nil
+}
+
+func flattenValue(prefix string, value interface{}, out map[string]interface{}) {
+	m, ok := value.(map[interface{}]interface{})
+	if !ok || len(m) == 0 {
+		if prefix != "" {
+			out[prefix] = value
+		}
+		return
+	}
+
+	for k, v := range m {
+		path := fmt.Sprint(k)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		flattenValue(path, v, out)
+	}
+}
@@ -0,0 +1,64 @@
+package runtimeconfig
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cortexproject/cortex/pkg/util"
+)
+
+// maxValidateRequestSize is the max size of the config posted to the validation endpoint.
+const maxValidateRequestSize = 10 * 1024 * 1024
+
+// ServeHTTP serves the currently active runtime config, including the default values.
+// The "mode" URL parameter can be set to "diff" to get the changes of the last reload,
+// or to "history" to get the latest reloads which changed the config or failed.
+func (om *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	om.configMtx.RLock()
+	defer om.configMtx.RUnlock()
+
+	switch mode := r.URL.Query().Get("mode"); mode {
+	case "":
+		util.WriteYAMLResponse(w, om.config)
+
+	case "diff":
+		// The changes of the last successful reload.
+		var last ReloadEvent
+		for i := len(om.history) - 1; i >= 0; i-- {
+			if om.history[i].Error == "" {
+				last = om.history[i]
+				break
+			}
+		}
+		util.WriteYAMLResponse(w, last)
+
+	case "history":
+		// The most recent reloads first.
+		history := make([]ReloadEvent, 0, len(om.history))
+		for i := len(om.history) - 1; i >= 0; i-- {
+			history = append(history, om.history[i])
+		}
+		util.WriteYAMLResponse(w, history)
+
+	default:
+		http.Error(w, fmt.Sprintf("unknown mode %q, supported modes: diff, history", mode), http.StatusBadRequest)
+	}
+}
+
+// ValidateHandler validates the runtime config in the request body, without applying it.
+func (om *Manager) ValidateHandler(w http.ResponseWriter, r *http.Request) {
+	buf, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxValidateRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := om.cfg.Loader(bytes.NewReader(buf)); err != nil {
+		http.Error(w, fmt.Sprintf("invalid runtime config: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	util.WriteTextResponse(w, "the runtime config is valid")
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 	"sync"
 	"time"
 
@@ -27,18 +28,40 @@ type Loader func(r io.Reader) (interface{}, error)
 // It holds config related to loading per-tenant config.
 type ManagerConfig struct {
 	ReloadPeriod time.Duration `yaml:"period"`
-	// LoadPath contains the path to the runtime config file, requires an
-	// non-empty value
+	// LoadPath contains the comma separated paths to the runtime config files,
+	// requires an non-empty value
 	LoadPath string `yaml:"file"`
 	Loader   Loader `yaml:"-"`
 }
 
 // RegisterFlags registers flags.
 func (mc *ManagerConfig) RegisterFlags(f *flag.FlagSet) {
-	f.StringVar(&mc.LoadPath, "runtime-config.file", "", "File with the configuration that can be updated in runtime.")
+	f.StringVar(&mc.LoadPath, "runtime-config.file", "", "Comma separated list of files with the configuration that can be updated in runtime. The files are merged in order: the values of a file override the values of the previous ones, and the maps (e.g. the per-tenant overrides) are merged recursively.")
 	f.DurationVar(&mc.ReloadPeriod, "runtime-config.reload-period", 10*time.Second, "How often to check runtime config file.")
 }
 
+// LoadPaths returns the paths of the runtime config files.
+func (mc *ManagerConfig) LoadPaths() []string {
+	var paths []string
+	for _, p := range strings.Split(mc.LoadPath, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// maxReloadHistory is the max number of reloads kept in the history.
+const maxReloadHistory = 10
+
+// ReloadEvent is a reload of the runtime config which changed the config or failed.
+type ReloadEvent struct {
+	Time    time.Time      `yaml:"time" json:"time"`
+	Hash    string         `yaml:"hash,omitempty" json:"hash,omitempty"`
+	Error   string         `yaml:"error,omitempty" json:"error,omitempty"`
+	Changes []ConfigChange `yaml:"changes,omitempty" json:"changes,omitempty"`
+}
+
 // Manager periodically reloads the configuration from a file, and keeps this
 // configuration available for clients.
 type Manager struct {
@@ -49,11 +72,14 @@ type Manager struct {
 	listenersMtx sync.Mutex
 	listeners    []chan interface{}
 
-	configMtx sync.RWMutex
-	config    interface{}
+	configMtx  sync.RWMutex
+	config     interface{}
+	configHash string
+	history    []ReloadEvent
 
-	configLoadSuccess prometheus.Gauge
-	configHash        *prometheus.GaugeVec
+	configLoadSuccess  prometheus.Gauge
+	configLoadFailures prometheus.Counter
+	configHashGauge    *prometheus.GaugeVec
 }
 
 // NewRuntimeConfigManager creates an instance of Manager and starts reload config loop based on config
@@ -68,7 +94,11 @@ func NewRuntimeConfigManager(cfg ManagerConfig, registerer prometheus.Registerer
 			Name: "cortex_runtime_config_last_reload_successful",
 			Help: "Whether the last runtime-config reload attempt was successful.",
 		}),
-		configHash: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+		configLoadFailures: promauto.With(registerer).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_runtime_config_reload_failures_total",
+			Help: "Total number of runtime-config reloads rejected because the config files couldn't be read or the config is invalid.",
+		}),
+		configHashGauge: promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
 			Name: "cortex_runtime_config_hash",
 			Help: "Hash of the currently active runtime config file.",
 		}, []string{"sha256"}),
@@ -145,34 +175,94 @@ func (om *Manager) loop(ctx context.Context) error {
 // loadConfig loads configuration using the loader function, and if successful,
 // stores it as current configuration and notifies listeners.
 func (om *Manager) loadConfig() error {
-	buf, err := ioutil.ReadFile(om.cfg.LoadPath)
+	buf, err := om.readConfigFiles()
 	if err != nil {
-		om.configLoadSuccess.Set(0)
+		om.reloadFailed(err)
 		return err
 	}
-	hash := sha256.Sum256(buf)
+	hash := fmt.Sprintf("%x", sha256.Sum256(buf))
 
 	cfg, err := om.cfg.Loader(bytes.NewReader(buf))
 	if err != nil {
-		om.configLoadSuccess.Set(0)
+		err = fmt.Errorf("invalid runtime config: %w", err)
+		om.reloadFailed(err)
 		return err
 	}
 	om.configLoadSuccess.Set(1)
 
-	om.setConfig(cfg)
+	om.setConfig(cfg, hash)
 	om.callListeners(cfg)
 
 	// expose hash of runtime config
-	om.configHash.Reset()
-	om.configHash.WithLabelValues(fmt.Sprintf("%x", hash[:])).Set(1)
+	om.configHashGauge.Reset()
+	om.configHashGauge.WithLabelValues(hash).Set(1)
 
 	return nil
 }
 
-func (om *Manager) setConfig(config interface{}) {
+// readConfigFiles reads the runtime config files, and merges them if more than one.
+func (om *Manager) readConfigFiles() ([]byte, error) {
+	paths := om.cfg.LoadPaths()
+
+	docs := make([][]byte, 0, len(paths))
+	for _, p := range paths {
+		buf, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, buf)
+	}
+
+	if len(docs) == 1 {
+		return docs[0], nil
+	}
+
+	merged, err := mergeYAML(docs)
+	if err != nil {
+		return nil, fmt.Errorf("merge runtime config files: %w", err)
+	}
+	return merged, nil
+}
+
+func (om *Manager) reloadFailed(err error) {
+	om.configLoadSuccess.Set(0)
+	om.configLoadFailures.Inc()
+
+	om.configMtx.Lock()
+	defer om.configMtx.Unlock()
+	om.addToHistory(ReloadEvent{Time: time.Now(), Error: err.Error()})
+}
+
+// addToHistory must be called with the configMtx lock held.
+func (om *Manager) addToHistory(event ReloadEvent) {
+	om.history = append(om.history, event)
+	if len(om.history) > maxReloadHistory {
+		om.history = om.history[len(om.history)-maxReloadHistory:]
+	}
+}
+
+// setConfig stores the config, and tracks its changes in the history if the
+// content of the config files changed since the last reload.
+func (om *Manager) setConfig(config interface{}, hash string) {
 	om.configMtx.Lock()
 	defer om.configMtx.Unlock()
+
+	if om.config != nil && hash == om.configHash {
+		om.config = config
+		return
+	}
+
+	changes, err := diffConfigs(om.config, config)
+	if err != nil {
+		level.Warn(util.Logger).Log("msg", "failed to compute the runtime config changes", "err", err)
+	}
+	if om.config != nil {
+		level.Info(util.Logger).Log("msg", "runtime config reloaded", "hash", hash, "changes", len(changes))
+	}
+
 	om.config = config
+	om.configHash = hash
+	om.addToHistory(ReloadEvent{Time: time.Now(), Hash: hash, Changes: changes})
 }
 
 func (om *Manager) callListeners(newValue interface{}) {
@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -150,6 +153,9 @@ func TestOverridesManager_ListenerWithDefaultLimits(t *testing.T) {
 					# HELP cortex_runtime_config_last_reload_successful Whether the last runtime-config reload attempt was successful.
 					# TYPE cortex_runtime_config_last_reload_successful gauge
 					cortex_runtime_config_last_reload_successful 1
+					# HELP cortex_runtime_config_reload_failures_total Total number of runtime-config reloads rejected because the config files couldn't be read or the config is invalid.
+					# TYPE cortex_runtime_config_reload_failures_total counter
+					cortex_runtime_config_reload_failures_total 0
 				`, fmt.Sprintf("%x", sha256.Sum256(config))))))
 
 	// need to use buffer, otherwise loadConfig will throw away update
@@ -186,6 +192,9 @@ func TestOverridesManager_ListenerWithDefaultLimits(t *testing.T) {
 					# HELP cortex_runtime_config_last_reload_successful Whether the last runtime-config reload attempt was successful.
 					# TYPE cortex_runtime_config_last_reload_successful gauge
 					cortex_runtime_config_last_reload_successful 1
+					# HELP cortex_runtime_config_reload_failures_total Total number of runtime-config reloads rejected because the config files couldn't be read or the config is invalid.
+					# TYPE cortex_runtime_config_reload_failures_total counter
+					cortex_runtime_config_reload_failures_total 0
 				`, fmt.Sprintf("%x", sha256.Sum256(config))))))
 
 	// Cleaning up
@@ -254,3 +263,134 @@ func TestOverridesManager_StopClosesListenerChannels(t *testing.T) {
 		t.Fatal("channel not closed")
 	}
 }
+
+func TestOverridesManager_MultipleFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runtime-config")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	first := filepath.Join(dir, "first.yaml")
+	second := filepath.Join(dir, "second.yaml")
+	require.NoError(t, ioutil.WriteFile(first, []byte(`overrides:
+  user1:
+    limit1: 10
+    limit2: 20
+  user2:
+    limit1: 30`), 0600))
+	require.NoError(t, ioutil.WriteFile(second, []byte(`overrides:
+  user1:
+    limit2: 40
+  user3:
+    limit1: 50`), 0600))
+
+	defaultTestLimits = &TestLimits{Limit1: 100}
+
+	overridesManager, err := NewRuntimeConfigManager(ManagerConfig{
+		ReloadPeriod: time.Second,
+		LoadPath:     first + "," + second,
+		Loader:       testLoadOverrides,
+	}, nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), overridesManager))
+	defer services.StopAndAwaitTerminated(context.Background(), overridesManager) //nolint:errcheck
+
+	// The files are merged in order.
+	assert.Equal(t, &testOverrides{Overrides: map[string]*TestLimits{
+		"user1": {Limit1: 10, Limit2: 40},
+		"user2": {Limit1: 30},
+		"user3": {Limit1: 50},
+	}}, overridesManager.GetConfig())
+}
+
+func TestOverridesManager_ReloadHistory(t *testing.T) {
+	tempFile, err := ioutil.TempFile("", "test-validation")
+	require.NoError(t, err)
+	require.NoError(t, tempFile.Close())
+	defer os.Remove(tempFile.Name()) //nolint:errcheck
+
+	require.NoError(t, ioutil.WriteFile(tempFile.Name(), []byte(`overrides:
+  user1:
+    limit2: 150`), 0600))
+
+	defaultTestLimits = &TestLimits{Limit1: 100}
+
+	reg := prometheus.NewPedanticRegistry()
+	overridesManager, err := NewRuntimeConfigManager(ManagerConfig{
+		ReloadPeriod: time.Minute,
+		LoadPath:     tempFile.Name(),
+		Loader:       testLoadOverrides,
+	}, reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), overridesManager))
+	defer services.StopAndAwaitTerminated(context.Background(), overridesManager) //nolint:errcheck
+
+	// Change a limit, and reload.
+	require.NoError(t, ioutil.WriteFile(tempFile.Name(), []byte(`overrides:
+  user1:
+    limit2: 200`), 0600))
+	require.NoError(t, overridesManager.loadConfig())
+
+	// Reloading the same config is not tracked in the history.
+	require.NoError(t, overridesManager.loadConfig())
+
+	// An invalid config is rejected, and the previous config is kept.
+	require.NoError(t, ioutil.WriteFile(tempFile.Name(), []byte(`overrides:
+  user1:
+    unknown: 1`), 0600))
+	require.Error(t, overridesManager.loadConfig())
+	assert.Equal(t, 200, overridesManager.GetConfig().(*testOverrides).Overrides["user1"].Limit2)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_runtime_config_last_reload_successful Whether the last runtime-config reload attempt was successful.
+		# TYPE cortex_runtime_config_last_reload_successful gauge
+		cortex_runtime_config_last_reload_successful 0
+		# HELP cortex_runtime_config_reload_failures_total Total number of runtime-config reloads rejected because the config files couldn't be read or the config is invalid.
+		# TYPE cortex_runtime_config_reload_failures_total counter
+		cortex_runtime_config_reload_failures_total 1
+	`), "cortex_runtime_config_last_reload_successful", "cortex_runtime_config_reload_failures_total"))
+
+	// The diff shows the changes of the last successful reload.
+	resp := httptest.NewRecorder()
+	overridesManager.ServeHTTP(resp, httptest.NewRequest("GET", "/runtime_config?mode=diff", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var diff ReloadEvent
+	require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &diff))
+	assert.Empty(t, diff.Error)
+	assert.Equal(t, []ConfigChange{{Path: "overrides.user1.limit2", Old: 150, New: 200}}, diff.Changes)
+
+	// The history shows the most recent reloads first.
+	resp = httptest.NewRecorder()
+	overridesManager.ServeHTTP(resp, httptest.NewRequest("GET", "/runtime_config?mode=history", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+
+	var history []ReloadEvent
+	require.NoError(t, yaml.Unmarshal(resp.Body.Bytes(), &history))
+	require.Len(t, history, 3)
+	assert.Contains(t, history[0].Error, "invalid runtime config")
+	assert.Len(t, history[1].Changes, 1)
+	assert.Len(t, history[2].Changes, 2)
+
+	// The current config includes the default values.
+	resp = httptest.NewRecorder()
+	overridesManager.ServeHTTP(resp, httptest.NewRequest("GET", "/runtime_config", nil))
+	require.Equal(t, http.StatusOK, resp.Code)
+	assert.Equal(t, "overrides:\n  user1:\n    limit1: 100\n    limit2: 200\n", resp.Body.String())
+}
+
+func TestOverridesManager_ValidateHandler(t *testing.T) {
+	_, cfg := newTestOverridesManagerConfig(t, 1)
+	cfg.Loader = testLoadOverrides
+
+	overridesManager, err := NewRuntimeConfigManager(cfg, nil)
+	require.NoError(t, err)
+
+	resp := httptest.NewRecorder()
+	overridesManager.ValidateHandler(resp, httptest.NewRequest("POST", "/runtime_config/validate", strings.NewReader("overrides:\n  user1:\n    limit1: 1\n")))
+	assert.Equal(t, http.StatusOK, resp.Code)
+
+	resp = httptest.NewRecorder()
+	overridesManager.ValidateHandler(resp, httptest.NewRequest("POST", "/runtime_config/validate", strings.NewReader("overrides:\n  user1:\n    unknown: 1\n")))
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+	assert.Contains(t, resp.Body.String(), "invalid runtime config")
+}
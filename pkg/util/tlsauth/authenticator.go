@@ -0,0 +1,193 @@
+// Package tlsauth provides an HTTP middleware authenticating the requests with the
+// tenant ID derived from the client TLS certificate, to support multi-tenancy based
+// on mutual TLS without an authenticating proxy in front of Cortex.
+package tlsauth
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/weaveworks/common/user"
+	"gopkg.in/yaml.v2"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/services"
+)
+
+// Mapping is the content of the mapping file.
+type Mapping struct {
+	// Tenants maps the client identities to tenant IDs.
+	Tenants map[string]string `yaml:"tenants"`
+}
+
+// Authenticator is an HTTP middleware injecting the tenant ID mapped from the client
+// TLS certificate into the requests. If a mapping file is configured, it's periodically
+// reloaded while the service is running.
+type Authenticator struct {
+	services.Service
+
+	cfg    Config
+	logger log.Logger
+
+	mappingMtx sync.RWMutex
+	mapping    map[string]string
+
+	// Metrics.
+	mappingLoadSuccess prometheus.Gauge
+	rejectedRequests   *prometheus.CounterVec
+}
+
+// NewAuthenticator makes a new Authenticator. The mapping file, if any, is loaded
+// before returning, so that an invalid mapping file fails the startup.
+func NewAuthenticator(cfg Config, logger log.Logger, reg prometheus.Registerer) (*Authenticator, error) {
+	a := &Authenticator{
+		cfg:    cfg,
+		logger: logger,
+		mappingLoadSuccess: promauto.With(reg).NewGauge(prometheus.GaugeOpts{
+			Name: "cortex_tls_auth_mapping_last_reload_successful",
+			Help: "Whether the last reload of the TLS tenant mapping file was successful.",
+		}),
+		rejectedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_tls_auth_rejected_requests_total",
+			Help: "Total number of HTTP requests rejected because the tenant ID couldn't be derived from the client TLS certificate.",
+		}, []string{"reason"}),
+	}
+
+	if cfg.MappingFile == "" {
+		a.Service = services.NewIdleService(nil, nil)
+		return a, nil
+	}
+
+	if err := a.reloadMapping(); err != nil {
+		return nil, err
+	}
+
+	a.Service = services.NewTimerService(cfg.MappingReloadPeriod, nil, a.iteration, nil)
+	return a, nil
+}
+
+func (a *Authenticator) iteration(_ context.Context) error {
+	if err := a.reloadMapping(); err != nil {
+		// Keep serving with the last valid mapping.
+		level.Error(a.logger).Log("msg", "failed to reload the TLS tenant mapping file", "file", a.cfg.MappingFile, "err", err)
+	}
+	return nil
+}
+
+func (a *Authenticator) reloadMapping() error {
+	mapping, err := LoadMapping(a.cfg.MappingFile)
+	if err != nil {
+		a.mappingLoadSuccess.Set(0)
+		return err
+	}
+
+	a.mappingMtx.Lock()
+	a.mapping = mapping.Tenants
+	a.mappingMtx.Unlock()
+
+	a.mappingLoadSuccess.Set(1)
+	return nil
+}
+
+// LoadMapping loads and validates the mapping file at the given path.
+func LoadMapping(path string) (*Mapping, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "read TLS tenant mapping file")
+	}
+
+	mapping := &Mapping{}
+	if err := yaml.UnmarshalStrict(buf, mapping); err != nil {
+		return nil, errors.Wrap(err, "parse TLS tenant mapping file")
+	}
+
+	for identity, tenantID := range mapping.Tenants {
+		if err := tenant.ValidTenantID(tenantID); err != nil {
+			return nil, errors.Wrapf(err, "invalid tenant ID mapped to the client identity %s", identity)
+		}
+	}
+
+	return mapping, nil
+}
+
+// Wrap implements middleware.Interface.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			a.rejectedRequests.WithLabelValues("missing_certificate").Inc()
+			http.Error(w, "no verified client TLS certificate", http.StatusUnauthorized)
+			return
+		}
+
+		tenantID, err := a.tenantID(r.TLS.VerifiedChains[0][0])
+		if err != nil {
+			a.rejectedRequests.WithLabelValues("unknown_identity").Inc()
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		// The header is overwritten too, because it's propagated to the downstream
+		// components (e.g. from the query-frontend to the queriers).
+		r.Header.Set(user.OrgIDHeaderName, tenantID)
+		next.ServeHTTP(w, r.WithContext(user.InjectOrgID(r.Context(), tenantID)))
+	})
+}
+
+// tenantID returns the tenant ID of the client holding the input certificate.
+func (a *Authenticator) tenantID(cert *x509.Certificate) (string, error) {
+	identities := clientIdentities(cert, a.cfg.IdentitySource)
+
+	if a.cfg.MappingFile == "" {
+		if len(identities) == 0 {
+			return "", fmt.Errorf("no client identity found in the TLS certificate field %s", a.cfg.IdentitySource)
+		}
+		if err := tenant.ValidTenantID(identities[0]); err != nil {
+			return "", errors.Wrap(err, "invalid tenant ID in the client TLS certificate")
+		}
+		return identities[0], nil
+	}
+
+	a.mappingMtx.RLock()
+	defer a.mappingMtx.RUnlock()
+
+	for _, identity := range identities {
+		if tenantID, ok := a.mapping[identity]; ok {
+			return tenantID, nil
+		}
+	}
+	return "", fmt.Errorf("the client identities %v in the TLS certificate field %s are not mapped to any tenant", identities, a.cfg.IdentitySource)
+}
+
+// clientIdentities returns the values of the given source in the certificate.
+func clientIdentities(cert *x509.Certificate, source string) []string {
+	switch source {
+	case SourceCommonName:
+		if cert.Subject.CommonName == "" {
+			return nil
+		}
+		return []string{cert.Subject.CommonName}
+	case SourceOrganizationalUnit:
+		return cert.Subject.OrganizationalUnit
+	case SourceSANDNS:
+		return cert.DNSNames
+	case SourceSANEmail:
+		return cert.EmailAddresses
+	case SourceSANURI:
+		uris := make([]string, 0, len(cert.URIs))
+		for _, u := range cert.URIs {
+			uris = append(uris, u.String())
+		}
+		return uris
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package tlsauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/tenant"
+	"github.com/cortexproject/cortex/pkg/util/services"
+	"github.com/cortexproject/cortex/pkg/util/test"
+)
+
+func TestAuthenticator_Wrap(t *testing.T) {
+	cert := &x509.Certificate{
+		Subject: pkix.Name{
+			CommonName:         "client-1",
+			OrganizationalUnit: []string{"team-a", "team-b"},
+		},
+		DNSNames:       []string{"client-1.example.com"},
+		EmailAddresses: []string{"client-1@example.com"},
+		URIs:           []*url.URL{{Scheme: "spiffe", Host: "example.com", Path: "/client-1"}},
+	}
+
+	tests := map[string]struct {
+		cfg              Config
+		mapping          string
+		cert             *x509.Certificate
+		expectedStatus   int
+		expectedTenantID string
+	}{
+		"should use the common name as tenant ID if no mapping file is configured": {
+			cfg:              Config{IdentitySource: SourceCommonName},
+			cert:             cert,
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "client-1",
+		},
+		"should use the first organizational unit as tenant ID if no mapping file is configured": {
+			cfg:              Config{IdentitySource: SourceOrganizationalUnit},
+			cert:             cert,
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "team-a",
+		},
+		"should map the first organizational unit found in the mapping": {
+			cfg:              Config{IdentitySource: SourceOrganizationalUnit},
+			mapping:          "tenants:\n  team-b: tenant-b\n",
+			cert:             cert,
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-b",
+		},
+		"should map the DNS SAN": {
+			cfg:              Config{IdentitySource: SourceSANDNS},
+			mapping:          "tenants:\n  client-1.example.com: tenant-1\n",
+			cert:             cert,
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-1",
+		},
+		"should map the email SAN": {
+			cfg:              Config{IdentitySource: SourceSANEmail},
+			mapping:          "tenants:\n  client-1@example.com: tenant-1\n",
+			cert:             cert,
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-1",
+		},
+		"should map the URI SAN": {
+			cfg:              Config{IdentitySource: SourceSANURI},
+			mapping:          "tenants:\n  spiffe://example.com/client-1: tenant-1\n",
+			cert:             cert,
+			expectedStatus:   http.StatusOK,
+			expectedTenantID: "tenant-1",
+		},
+		"should reject the request if the client identity is not mapped": {
+			cfg:            Config{IdentitySource: SourceCommonName},
+			mapping:        "tenants:\n  client-2: tenant-2\n",
+			cert:           cert,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"should reject the request if the client identity is not a valid tenant ID": {
+			cfg:            Config{IdentitySource: SourceSANURI},
+			cert:           cert,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		"should reject the request without a client certificate": {
+			cfg:            Config{IdentitySource: SourceCommonName},
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := testData.cfg
+			if testData.mapping != "" {
+				cfg.MappingFile = writeMappingFile(t, testData.mapping)
+				cfg.MappingReloadPeriod = time.Minute
+			}
+
+			a, err := NewAuthenticator(cfg, log.NewNopLogger(), nil)
+			require.NoError(t, err)
+
+			var actualTenantID string
+			handler := a.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var err error
+				actualTenantID, err = tenant.TenantID(r.Context())
+				require.NoError(t, err)
+				assert.Equal(t, actualTenantID, r.Header.Get(user.OrgIDHeaderName))
+			}))
+
+			req := httptest.NewRequest("GET", "/api/v1/query", nil)
+			// The tenant ID in the header must be ignored.
+			req.Header.Set(user.OrgIDHeaderName, "spoofed")
+			if testData.cert != nil {
+				req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{testData.cert}}}
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, testData.expectedStatus, recorder.Code)
+			assert.Equal(t, testData.expectedTenantID, actualTenantID)
+		})
+	}
+}
+
+func TestAuthenticator_ReloadMapping(t *testing.T) {
+	mappingFile := writeMappingFile(t, "tenants:\n  client-1: tenant-1\n")
+
+	a, err := NewAuthenticator(Config{IdentitySource: SourceCommonName, MappingFile: mappingFile, MappingReloadPeriod: 50 * time.Millisecond}, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), a))
+	defer services.StopAndAwaitTerminated(context.Background(), a) //nolint:errcheck
+
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client-1"}}
+	tenantID, err := a.tenantID(cert)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", tenantID)
+
+	// The updated mapping is reloaded.
+	require.NoError(t, ioutil.WriteFile(mappingFile, []byte("tenants:\n  client-1: tenant-2\n"), 0600))
+	test.Poll(t, time.Second, "tenant-2", func() interface{} {
+		tenantID, _ := a.tenantID(cert)
+		return tenantID
+	})
+
+	// The last valid mapping is kept if the file is invalid.
+	require.NoError(t, ioutil.WriteFile(mappingFile, []byte("tenants:\n  client-1: tenant/3\n"), 0600))
+	test.Poll(t, time.Second, float64(0), func() interface{} {
+		return testutil.ToFloat64(a.mappingLoadSuccess)
+	})
+	tenantID, err = a.tenantID(cert)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-2", tenantID)
+}
+
+func TestNewAuthenticator_InvalidMappingFile(t *testing.T) {
+	_, err := NewAuthenticator(Config{IdentitySource: SourceCommonName, MappingFile: writeMappingFile(t, "tenants:\n  client-1: tenant/1\n"), MappingReloadPeriod: time.Minute}, log.NewNopLogger(), nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid tenant ID mapped to the client identity client-1")
+
+	_, err = NewAuthenticator(Config{IdentitySource: SourceCommonName, MappingFile: writeMappingFile(t, "unknown: field\n"), MappingReloadPeriod: time.Minute}, log.NewNopLogger(), nil)
+	require.Error(t, err)
+}
+
+func writeMappingFile(t *testing.T, content string) string {
+	dir, err := ioutil.TempDir("", "tlsauth")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, "mapping.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
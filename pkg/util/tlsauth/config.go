@@ -0,0 +1,65 @@
+package tlsauth
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// The sources of the client identity in the TLS client certificate.
+const (
+	SourceCommonName         = "cn"
+	SourceOrganizationalUnit = "ou"
+	SourceSANDNS             = "san-dns"
+	SourceSANEmail           = "san-email"
+	SourceSANURI             = "san-uri"
+)
+
+var (
+	supportedSources = []string{SourceCommonName, SourceOrganizationalUnit, SourceSANDNS, SourceSANEmail, SourceSANURI}
+
+	errInvalidMappingReloadPeriod = errors.New("the TLS tenant mapping file reload period must be greater than 0")
+)
+
+// Config configures the authentication of the HTTP requests based on the client TLS certificate.
+type Config struct {
+	Enabled             bool          `yaml:"enabled"`
+	IdentitySource      string        `yaml:"identity_source"`
+	MappingFile         string        `yaml:"mapping_file"`
+	MappingReloadPeriod time.Duration `yaml:"mapping_reload_period"`
+}
+
+// RegisterFlags registers the flags for the TLS authentication config.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "auth.tls.enabled", false, "Derive the tenant ID of the HTTP requests from the verified client TLS certificate, instead of the X-Scope-OrgID header. Requires -auth.enabled and the HTTP server to verify the client certificates (-server.http-tls-client-auth=RequireAndVerifyClientCert and -server.http-tls-ca-path). The gRPC requests are not affected.")
+	f.StringVar(&cfg.IdentitySource, "auth.tls.identity-source", SourceCommonName, fmt.Sprintf("The field of the client certificate holding the client identity. Supported values: %s. When the field has multiple values (e.g. multiple OUs or SANs), the first one found in the mapping file is used (or the first one, if no mapping file is configured).", strings.Join(supportedSources, ", ")))
+	f.StringVar(&cfg.MappingFile, "auth.tls.mapping-file", "", "YAML file mapping the client identities to tenant IDs, under the 'tenants' key. The requests of the clients not in the mapping are rejected. If empty, the client identity is used as tenant ID.")
+	f.DurationVar(&cfg.MappingReloadPeriod, "auth.tls.mapping-reload-period", 10*time.Second, "How frequently the TLS tenant mapping file is reloaded.")
+}
+
+// Validate the config and returns an error if the validation fails.
+func (cfg *Config) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	supported := false
+	for _, s := range supportedSources {
+		if cfg.IdentitySource == s {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return fmt.Errorf("unsupported TLS client identity source %q, supported values: %s", cfg.IdentitySource, strings.Join(supportedSources, ", "))
+	}
+
+	if cfg.MappingFile != "" && cfg.MappingReloadPeriod <= 0 {
+		return errInvalidMappingReloadPeriod
+	}
+
+	return nil
+}
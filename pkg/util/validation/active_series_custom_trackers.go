@@ -0,0 +1,37 @@
+package validation
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// ActiveSeriesCustomTracker is an additional active series tracker, counting the active
+// series matching a series selector.
+type ActiveSeriesCustomTracker struct {
+	Name     string `yaml:"name"`
+	Selector string `yaml:"selector"`
+}
+
+// ActiveSeriesCustomTrackersConfig configures additional active series trackers.
+type ActiveSeriesCustomTrackersConfig []ActiveSeriesCustomTracker
+
+// Validate the config.
+func (c ActiveSeriesCustomTrackersConfig) Validate() error {
+	names := make(map[string]struct{}, len(c))
+
+	for _, tracker := range c {
+		if tracker.Name == "" {
+			return errors.New("the name of an active series custom tracker is empty")
+		}
+		if _, ok := names[tracker.Name]; ok {
+			return errors.Errorf("duplicated active series custom tracker name %q", tracker.Name)
+		}
+		names[tracker.Name] = struct{}{}
+
+		if _, err := parser.ParseMetricSelector(tracker.Selector); err != nil {
+			return errors.Wrapf(err, "failed to parse the selector of the active series custom tracker %q", tracker.Name)
+		}
+	}
+
+	return nil
+}
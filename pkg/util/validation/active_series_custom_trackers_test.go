@@ -0,0 +1,71 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestActiveSeriesCustomTrackersConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         ActiveSeriesCustomTrackersConfig
+		expectedErr bool
+	}{
+		"empty": {
+			cfg: nil,
+		},
+		"valid trackers with separators in the selectors": {
+			cfg: ActiveSeriesCustomTrackersConfig{
+				{Name: "foo", Selector: `{foo="bar"}`},
+				{Name: "baz", Selector: `{baz=~"a:b;c"}`},
+			},
+		},
+		"invalid selector": {
+			cfg:         ActiveSeriesCustomTrackersConfig{{Name: "foo", Selector: `{foo=`}},
+			expectedErr: true,
+		},
+		"empty name": {
+			cfg:         ActiveSeriesCustomTrackersConfig{{Selector: `{foo="bar"}`}},
+			expectedErr: true,
+		},
+		"duplicated name": {
+			cfg: ActiveSeriesCustomTrackersConfig{
+				{Name: "foo", Selector: `{foo="bar"}`},
+				{Name: "foo", Selector: `{foo="baz"}`},
+			},
+			expectedErr: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			err := testData.cfg.Validate()
+			if testData.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestActiveSeriesCustomTrackersConfig_LoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	inp := `
+active_series_custom_trackers:
+  - name: foo
+    selector: '{foo="bar"}'
+  - name: baz
+    selector: '{baz=~"a:b;c"}'
+`
+
+	l := Limits{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(inp), &l))
+	assert.Equal(t, ActiveSeriesCustomTrackersConfig{
+		{Name: "foo", Selector: `{foo="bar"}`},
+		{Name: "baz", Selector: `{baz=~"a:b;c"}`},
+	}, l.ActiveSeriesCustomTrackers)
+}
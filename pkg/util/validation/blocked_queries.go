@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// BlockedQuery configures a query to block for a tenant.
+type BlockedQuery struct {
+	// Pattern is the query to block. It's compared with the query as is, unless Regex is true.
+	Pattern string `yaml:"pattern"`
+
+	// Regex is true if Pattern is a regular expression, matching the whole query.
+	Regex bool `yaml:"regex"`
+
+	// MinTimeRange blocks the query only if its time range is at least this long. 0 to block the
+	// query regardless of its time range.
+	MinTimeRange time.Duration `yaml:"min_time_range"`
+
+	// The compiled Pattern, if Regex is true. It's compiled once when the config is loaded.
+	regex *regexp.Regexp
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, compiling the regex pattern.
+func (q *BlockedQuery) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain BlockedQuery
+	if err := unmarshal((*plain)(q)); err != nil {
+		return err
+	}
+
+	// An invalid regex is reported by Validate(), which is called once the config is loaded.
+	q.regex = compileQueryRegexIfEnabled(q.Pattern, q.Regex)
+	return nil
+}
+
+// Validate the blocked query config.
+func (q *BlockedQuery) Validate() error {
+	if q.Pattern == "" {
+		return errors.New("the pattern of a blocked query is empty")
+	}
+
+	if q.Regex {
+		if _, err := compileQueryRegex(q.Pattern); err != nil {
+			return errors.Wrapf(err, "invalid blocked query regex %q", q.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns whether the input query, with the given time range, matches this blocked query.
+func (q *BlockedQuery) Matches(query string, timeRange time.Duration) bool {
+	return matchesQuery(q.Pattern, q.Regex, q.regex, q.MinTimeRange, query, timeRange)
+}
+
+// matchesQuery returns whether the input query, with the given time range, matches the
+// pattern (as is, or as a regular expression matching the whole query) and the min time range.
+// The compiled regex is nil if the config has not been loaded from YAML (e.g. in tests), in
+// which case the pattern is compiled on the fly.
+func matchesQuery(pattern string, regex bool, compiled *regexp.Regexp, minTimeRange time.Duration, query string, timeRange time.Duration) bool {
+	if minTimeRange > 0 && timeRange < minTimeRange {
+		return false
+	}
+
+	if !regex {
+		return pattern == query
+	}
+
+	if compiled == nil {
+		compiled = compileQueryRegexIfEnabled(pattern, regex)
+	}
+	if compiled == nil {
+		// The config is validated when loaded, so this should never happen.
+		return false
+	}
+
+	return compiled.MatchString(query)
+}
+
+// compileQueryRegexIfEnabled returns the compiled pattern, or nil if the pattern is not
+// a regex or it's invalid.
+func compileQueryRegexIfEnabled(pattern string, regex bool) *regexp.Regexp {
+	if !regex {
+		return nil
+	}
+
+	re, err := compileQueryRegex(pattern)
+	if err != nil {
+		return nil
+	}
+	return re
+}
+
+func compileQueryRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
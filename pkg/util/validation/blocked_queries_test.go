@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestBlockedQuery_Validate(t *testing.T) {
+	assert.NoError(t, (&BlockedQuery{Pattern: "up"}).Validate())
+	assert.NoError(t, (&BlockedQuery{Pattern: "up.*", Regex: true}).Validate())
+	assert.Error(t, (&BlockedQuery{Pattern: ""}).Validate())
+	assert.Error(t, (&BlockedQuery{Pattern: "up[", Regex: true}).Validate())
+
+	// The pattern is not a regex, so it's not compiled.
+	assert.NoError(t, (&BlockedQuery{Pattern: "up["}).Validate())
+}
+
+func TestBlockedQuery_Matches(t *testing.T) {
+	tests := map[string]struct {
+		blocked   BlockedQuery
+		query     string
+		timeRange time.Duration
+		expected  bool
+	}{
+		"exact match": {
+			blocked:  BlockedQuery{Pattern: "up"},
+			query:    "up",
+			expected: true,
+		},
+		"exact mismatch": {
+			blocked:  BlockedQuery{Pattern: "up"},
+			query:    "up{job=\"a\"}",
+			expected: false,
+		},
+		"regex match": {
+			blocked:  BlockedQuery{Pattern: "up.*", Regex: true},
+			query:    "up{job=\"a\"}",
+			expected: true,
+		},
+		"regex should match the whole query": {
+			blocked:  BlockedQuery{Pattern: "up", Regex: true},
+			query:    "sum(up)",
+			expected: false,
+		},
+		"time range longer than the min time range": {
+			blocked:   BlockedQuery{Pattern: "up", MinTimeRange: time.Hour},
+			query:     "up",
+			timeRange: 2 * time.Hour,
+			expected:  true,
+		},
+		"time range equal to the min time range": {
+			blocked:   BlockedQuery{Pattern: "up", MinTimeRange: time.Hour},
+			query:     "up",
+			timeRange: time.Hour,
+			expected:  true,
+		},
+		"time range shorter than the min time range": {
+			blocked:   BlockedQuery{Pattern: "up", MinTimeRange: time.Hour},
+			query:     "up",
+			timeRange: time.Minute,
+			expected:  false,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, testData.blocked.Matches(testData.query, testData.timeRange))
+		})
+	}
+}
+
+func TestBlockedQueries_LoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	inp := `
+blocked_queries:
+  - pattern: 'up'
+  - pattern: '.*expensive.*'
+    regex: true
+    min_time_range: 24h
+`
+
+	l := Limits{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(inp), &l))
+	require.Len(t, l.BlockedQueries, 2)
+
+	assert.Equal(t, "up", l.BlockedQueries[0].Pattern)
+	assert.False(t, l.BlockedQueries[0].Regex)
+	assert.Nil(t, l.BlockedQueries[0].regex)
+
+	// The regex is compiled once when the config is loaded.
+	assert.Equal(t, ".*expensive.*", l.BlockedQueries[1].Pattern)
+	assert.True(t, l.BlockedQueries[1].Regex)
+	assert.Equal(t, 24*time.Hour, l.BlockedQueries[1].MinTimeRange)
+	require.NotNil(t, l.BlockedQueries[1].regex)
+	assert.True(t, l.BlockedQueries[1].Matches("sum(expensive_metric)", 48*time.Hour))
+}
@@ -0,0 +1,103 @@
+package validation
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AllTenantLimits is a function that returns the limits of all the tenants
+// with tenant-specific limits, by tenant ID.
+type AllTenantLimits func() map[string]*Limits
+
+// OverridesExporter exposes the per-tenant limits and the default limits as
+// metrics, so that the actual usage can be compared against the configured limits.
+// The numeric limits are exported, with the durations in seconds.
+type OverridesExporter struct {
+	defaultLimits *Limits
+	tenantLimits  AllTenantLimits
+
+	overridesDesc *prometheus.Desc
+	defaultsDesc  *prometheus.Desc
+}
+
+// NewOverridesExporter makes a new OverridesExporter.
+func NewOverridesExporter(defaults *Limits, tenantLimits AllTenantLimits) *OverridesExporter {
+	return &OverridesExporter{
+		defaultLimits: defaults,
+		tenantLimits:  tenantLimits,
+		overridesDesc: prometheus.NewDesc(
+			"cortex_limits_overrides",
+			"Resource limit overrides applied to tenants",
+			[]string{"limit_name", "user"},
+			nil,
+		),
+		defaultsDesc: prometheus.NewDesc(
+			"cortex_limits_defaults",
+			"Resource limit defaults for tenants without overrides",
+			[]string{"limit_name"},
+			nil,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (oe *OverridesExporter) Describe(ch chan<- *prometheus.Desc) {
+	ch <- oe.overridesDesc
+	ch <- oe.defaultsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (oe *OverridesExporter) Collect(ch chan<- prometheus.Metric) {
+	for _, l := range numericLimits(oe.defaultLimits) {
+		ch <- prometheus.MustNewConstMetric(oe.defaultsDesc, prometheus.GaugeValue, l.value, l.name)
+	}
+
+	if oe.tenantLimits == nil {
+		return
+	}
+
+	for userID, limits := range oe.tenantLimits() {
+		if limits == nil {
+			continue
+		}
+		for _, l := range numericLimits(limits) {
+			ch <- prometheus.MustNewConstMetric(oe.overridesDesc, prometheus.GaugeValue, l.value, l.name, userID)
+		}
+	}
+}
+
+type numericLimit struct {
+	name  string
+	value float64
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// numericLimits returns the numeric limits, named after their YAML field.
+func numericLimits(limits *Limits) []numericLimit {
+	v := reflect.ValueOf(limits).Elem()
+	t := v.Type()
+
+	out := make([]numericLimit, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		field := v.Field(i)
+		switch {
+		case field.Type() == durationType:
+			out = append(out, numericLimit{name: name, value: time.Duration(field.Int()).Seconds()})
+		case field.Kind() == reflect.Int || field.Kind() == reflect.Int64:
+			out = append(out, numericLimit{name: name, value: float64(field.Int())})
+		case field.Kind() == reflect.Float64:
+			out = append(out, numericLimit{name: name, value: field.Float()})
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,58 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverridesExporter(t *testing.T) {
+	defaults := &Limits{
+		IngestionRate:          10,
+		MaxGlobalSeriesPerUser: 100,
+		MaxQueryLength:         time.Hour,
+	}
+
+	tenantLimits := map[string]*Limits{
+		"tenant-a": {IngestionRate: 20, MaxGlobalSeriesPerUser: 200},
+		"tenant-b": {IngestionRate: 30, MaxQueryLength: 2 * time.Hour},
+		"tenant-c": nil,
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(NewOverridesExporter(defaults, func() map[string]*Limits { return tenantLimits }))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			key := family.GetName()
+			for _, l := range m.GetLabel() {
+				key += "," + l.GetValue()
+			}
+			values[key] = m.GetGauge().GetValue()
+		}
+	}
+
+	assert.Equal(t, float64(10), values["cortex_limits_defaults,ingestion_rate"])
+	assert.Equal(t, float64(100), values["cortex_limits_defaults,max_global_series_per_user"])
+	assert.Equal(t, float64(3600), values["cortex_limits_defaults,max_query_length"])
+
+	assert.Equal(t, float64(20), values["cortex_limits_overrides,ingestion_rate,tenant-a"])
+	assert.Equal(t, float64(200), values["cortex_limits_overrides,max_global_series_per_user,tenant-a"])
+	assert.Equal(t, float64(30), values["cortex_limits_overrides,ingestion_rate,tenant-b"])
+	assert.Equal(t, float64(7200), values["cortex_limits_overrides,max_query_length,tenant-b"])
+
+	// The non-numeric limits are not exported.
+	assert.NotContains(t, values, "cortex_limits_defaults,ingestion_rate_strategy")
+
+	// The tenants without limits are not exported.
+	for key := range values {
+		assert.NotContains(t, key, "tenant-c")
+	}
+}
@@ -6,12 +6,18 @@ import (
 	"time"
 
 	"github.com/prometheus/prometheus/pkg/relabel"
+	"golang.org/x/time/rate"
 
 	"github.com/cortexproject/cortex/pkg/util/flagext"
 )
 
 var (
-	errMaxGlobalSeriesPerUserValidation = errors.New("The ingester.max-global-series-per-user limit is unsupported if distributor.shard-by-all-labels is disabled")
+	errMaxGlobalSeriesPerUserValidation  = errors.New("The ingester.max-global-series-per-user limit is unsupported if distributor.shard-by-all-labels is disabled")
+	errLimitsPerLabelSetValidation       = errors.New("The limits_per_label_set limit is unsupported if distributor.shard-by-all-labels is disabled")
+	errQueryStoreAfterValidation         = errors.New("The query_store_after limit should be lower than the query_ingesters_within limit, otherwise queries may be sent neither to ingesters nor to the storage")
+	errQueryStoreAfterLookbackValidation = errors.New("The query_store_after limit should be lower or equal than the querier.shuffle-sharding-ingesters-lookback-period, otherwise queries may not be sent to all the ingesters holding the series")
+	errNegativeRulerEvaluationJitter     = errors.New("The ruler_evaluation_jitter limit should not be negative")
+	errRulerEvaluationJitterValidation   = errors.New("The ruler_evaluation_jitter limit should be lower than the ruler.evaluation-interval, otherwise the evaluations of the rule groups may overlap")
 )
 
 // Supported values for enum limits
@@ -20,7 +26,7 @@ const (
 	GlobalIngestionRateStrategy = "global"
 )
 
-//LimitError are errors that do not comply with the limits specified.
+// LimitError are errors that do not comply with the limits specified.
 type LimitError string
 
 func (e LimitError) Error() string {
@@ -37,6 +43,7 @@ type Limits struct {
 	AcceptHASamples           bool                `yaml:"accept_ha_samples"`
 	HAClusterLabel            string              `yaml:"ha_cluster_label"`
 	HAReplicaLabel            string              `yaml:"ha_replica_label"`
+	HATrackerFailoverTimeout  time.Duration       `yaml:"ha_tracker_failover_timeout"`
 	DropLabels                flagext.StringSlice `yaml:"drop_labels"`
 	MaxLabelNameLength        int                 `yaml:"max_label_name_length"`
 	MaxLabelValueLength       int                 `yaml:"max_label_value_length"`
@@ -50,6 +57,9 @@ type Limits struct {
 	IngestionTenantShardSize  int                 `yaml:"ingestion_tenant_shard_size"`
 	MetricRelabelConfigs      []*relabel.Config   `yaml:"metric_relabel_configs,omitempty" doc:"nocli|description=List of metric relabel configurations. Note that in most situations, it is more effective to use metrics relabeling directly in the Prometheus server, e.g. remote_write.write_relabel_configs."`
 
+	// OTLP ingestion.
+	PromoteOTelResourceAttributes flagext.StringSliceCSV `yaml:"promote_otel_resource_attributes"`
+
 	// Ingester enforced limits.
 	// Series
 	MaxSeriesPerQuery        int `yaml:"max_series_per_query"`
@@ -59,6 +69,10 @@ type Limits struct {
 	MaxGlobalSeriesPerUser   int `yaml:"max_global_series_per_user"`
 	MaxGlobalSeriesPerMetric int `yaml:"max_global_series_per_metric"`
 	MinChunkLength           int `yaml:"min_chunk_length"`
+	// Active series
+	ActiveSeriesCustomTrackers ActiveSeriesCustomTrackersConfig `yaml:"active_series_custom_trackers" doc:"nocli|description=Additional active series trackers, counting the active series matching a custom series selector. Each entry has a 'name' and a 'selector' (e.g. '{team=\"a\"}'). Matching series are exported by the ingester in the cortex_ingester_active_series_custom_tracker metric. Requires -ingester.active-series-metrics-enabled=true. Changes are applied to a tenant only once its in-memory series are reloaded (e.g. on ingester restart)."`
+	// Series per label set
+	LimitsPerLabelSet []*LimitsPerLabelSet `yaml:"limits_per_label_set,omitempty" doc:"nocli|description=List of limits on the number of series matching a label set, across the cluster, so that a subset of the tenant's series (e.g. the series of a team) can't exhaust the whole tenant's series limit. Each entry has a 'label_set' (map of label name/value pairs a series must have to be accounted) and a 'max_global_series' (0 to disable). Requires -distributor.shard-by-all-labels=true. Series created before a label set is configured are not accounted until they're reloaded (e.g. on ingester restart)."`
 	// Metadata
 	MaxLocalMetricsWithMetadataPerUser  int `yaml:"max_metadata_per_user"`
 	MaxLocalMetadataPerMetric           int `yaml:"max_metadata_per_metric"`
@@ -66,13 +80,40 @@ type Limits struct {
 	MaxGlobalMetadataPerMetric          int `yaml:"max_global_metadata_per_metric"`
 
 	// Querier enforced limits.
-	MaxChunksPerQuery    int           `yaml:"max_chunks_per_query"`
-	MaxQueryLookback     time.Duration `yaml:"max_query_lookback"`
-	MaxQueryLength       time.Duration `yaml:"max_query_length"`
-	MaxQueryParallelism  int           `yaml:"max_query_parallelism"`
-	CardinalityLimit     int           `yaml:"cardinality_limit"`
-	MaxCacheFreshness    time.Duration `yaml:"max_cache_freshness"`
-	MaxQueriersPerTenant int           `yaml:"max_queriers_per_tenant"`
+	MaxChunksPerQuery            int           `yaml:"max_chunks_per_query"`
+	MaxFetchedSeriesPerQuery     int           `yaml:"max_fetched_series_per_query"`
+	MaxFetchedChunkBytesPerQuery int           `yaml:"max_fetched_chunk_bytes_per_query"`
+	MaxFetchedChunksPerQuery     int           `yaml:"max_fetched_chunks_per_query"`
+	MaxQueryResponseSizeBytes    int           `yaml:"max_query_response_size_bytes"`
+	MaxSeriesPerSeriesRequest    int           `yaml:"max_series_per_series_request"`
+	MaxQueryLookback             time.Duration `yaml:"max_query_lookback"`
+	MaxQueryLength               time.Duration `yaml:"max_query_length"`
+	MaxQueryParallelism          int           `yaml:"max_query_parallelism"`
+	CardinalityLimit             int           `yaml:"cardinality_limit"`
+	MaxCacheFreshness            time.Duration `yaml:"max_cache_freshness"`
+	MaxQueriersPerTenant         int           `yaml:"max_queriers_per_tenant"`
+
+	// Querier time ranges queried from ingesters and from the storage overrides.
+	QueryIngestersWithin time.Duration `yaml:"query_ingesters_within"`
+	QueryStoreAfter      time.Duration `yaml:"query_store_after"`
+
+	// Querier PromQL engine options overrides.
+	QueryEngineMaxSamples                int           `yaml:"query_engine_max_samples"`
+	QueryEngineTimeout                   time.Duration `yaml:"query_engine_timeout"`
+	QueryEngineLookbackDelta             time.Duration `yaml:"query_engine_lookback_delta"`
+	QueryEngineDefaultEvaluationInterval time.Duration `yaml:"query_engine_default_evaluation_interval"`
+
+	// Query-frontend enforced limits.
+	BlockedQueries []*BlockedQuery `yaml:"blocked_queries,omitempty" doc:"nocli|description=List of queries to block for the tenant, enforced by the query-frontend. Each entry has a 'pattern', matched with the whole query string as is, or as a regular expression if 'regex' is true. If 'min_time_range' is set, the query is blocked only if its time range (end - start) is at least that long; instant queries have a time range of zero. Blocked queries are rejected with HTTP status 403."`
+
+	// Query-frontend split by interval.
+	SplitQueriesByInterval  time.Duration           `yaml:"split_queries_by_interval"`
+	SplitQueriesByTimeRange []*SplitQueriesInterval `yaml:"split_queries_by_time_range,omitempty" doc:"nocli|description=List of intervals to split the tenant's queries by, depending on the query time range, enforced by the query-frontend. Each entry has an 'interval' and a 'min_time_range': a query is split by the interval of the entry with the longest min time range not exceeding the query time range (end - start). Queries not matching any entry are split by split_queries_by_interval. Longer intervals for longer queries reduce the number of split queries, while shorter intervals for shorter queries increase their parallelism and results cache hit rate."`
+
+	// Query-frontend and query-scheduler queue priorities.
+	QueryPriorities            []*QueryPriority `yaml:"query_priorities,omitempty" doc:"nocli|description=List of priorities assigned to the tenant's queries, used by the query-frontend and query-scheduler to dequeue the tenant's higher priority queries first. Each entry has a 'priority', and a 'pattern', 'regex' and 'min_time_range' matched against the query the same way as in blocked_queries. The first matching entry wins; queries not matching any entry get the default query priority."`
+	DefaultQueryPriority       int64            `yaml:"default_query_priority"`
+	QueryPriorityHeaderEnabled bool             `yaml:"query_priority_header_enabled"`
 
 	// Ruler defaults and limits.
 	RulerEvaluationDelay        time.Duration `yaml:"ruler_evaluation_delay_duration"`
@@ -80,8 +121,39 @@ type Limits struct {
 	RulerMaxRulesPerRuleGroup   int           `yaml:"ruler_max_rules_per_rule_group"`
 	RulerMaxRuleGroupsPerTenant int           `yaml:"ruler_max_rule_groups_per_tenant"`
 
+	RulerMaxConcurrentRuleGroupEvaluations int                    `yaml:"ruler_max_concurrent_rule_group_evaluations"`
+	RulerEvaluationAlignmentEnabled        bool                   `yaml:"ruler_evaluation_alignment_enabled"`
+	RulerEvaluationJitter                  time.Duration          `yaml:"ruler_evaluation_jitter"`
+	RulerAllowedSourceTenants              flagext.StringSliceCSV `yaml:"ruler_allowed_source_tenants"`
+	RulerAllowedDestinationTenants         flagext.StringSliceCSV `yaml:"ruler_allowed_destination_tenants"`
+
 	// Store-gateway.
-	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size"`
+	StoreGatewayTenantShardSize        int     `yaml:"store_gateway_tenant_shard_size"`
+	StoreGatewayBucketReadOpsPerSecond float64 `yaml:"store_gateway_bucket_read_ops_per_second"`
+	StoreGatewayBucketListOpsPerSecond float64 `yaml:"store_gateway_bucket_list_ops_per_second"`
+
+	// Compactor.
+	CompactorBlocksRetentionPeriod time.Duration `yaml:"compactor_blocks_retention_period"`
+	CompactorTenantShardSize       int           `yaml:"compactor_tenant_shard_size"`
+	CompactorSplitAndMergeShards   int           `yaml:"compactor_split_and_merge_shards"`
+	CompactorBlockUploadEnabled    bool          `yaml:"compactor_block_upload_enabled"`
+
+	// Blocks storage.
+	S3SSEType                 string `yaml:"s3_sse_type"`
+	S3SSEKMSKeyID             string `yaml:"s3_sse_kms_key_id"`
+	S3SSEKMSEncryptionContext string `yaml:"s3_sse_kms_encryption_context"`
+
+	// Alertmanager.
+	AlertmanagerMaxConfigSizeBytes int `yaml:"alertmanager_max_config_size_bytes"`
+	AlertmanagerMaxReceivers       int `yaml:"alertmanager_max_receivers"`
+
+	AlertmanagerReceiversBlockCIDRNetworks     flagext.CIDRSliceCSV `yaml:"alertmanager_receivers_firewall_block_cidr_networks"`
+	AlertmanagerReceiversBlockPrivateAddresses bool                 `yaml:"alertmanager_receivers_firewall_block_private_addresses"`
+	AlertmanagerReceiversAllowCIDRNetworks     flagext.CIDRSliceCSV `yaml:"alertmanager_receivers_firewall_allow_cidr_networks"`
+
+	NotificationRateLimit               float64            `yaml:"alertmanager_notification_rate_limit"`
+	NotificationRateLimitPerIntegration map[string]float64 `yaml:"alertmanager_notification_rate_limit_per_integration" doc:"nocli|description=Per-integration notification rate limits, overriding -alertmanager.notification-rate-limit for the given integrations. The keys are the integration names (webhook, email, pagerduty, opsgenie, wechat, slack, victorops, pushover)."`
+	NotificationBurstSize               int                `yaml:"alertmanager_notification_burst_size"`
 
 	// Config for overrides, convenient if it goes here. [Deprecated in favor of RuntimeConfig flag in cortex.Config]
 	PerTenantOverrideConfig string        `yaml:"per_tenant_override_config"`
@@ -97,6 +169,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&l.AcceptHASamples, "distributor.ha-tracker.enable-for-all-users", false, "Flag to enable, for all users, handling of samples with external labels identifying replicas in an HA Prometheus setup.")
 	f.StringVar(&l.HAClusterLabel, "distributor.ha-tracker.cluster", "cluster", "Prometheus label to look for in samples to identify a Prometheus HA cluster.")
 	f.StringVar(&l.HAReplicaLabel, "distributor.ha-tracker.replica", "__replica__", "Prometheus label to look for in samples to identify a Prometheus HA replica.")
+	f.DurationVar(&l.HATrackerFailoverTimeout, "distributor.ha-tracker.tenant-failover-timeout", 0, "Per-tenant HA tracker failover timeout. If > 0, it overrides -distributor.ha-tracker.failover-timeout for the tenant's HA clusters. Values lower than the minimum failover timeout allowed by the HA tracker config (update timeout + max jitter + 1s) are raised to it.")
 	f.Var(&l.DropLabels, "distributor.drop-label", "This flag can be used to specify label names that to drop during sample ingestion within the distributor and can be repeated in order to drop multiple labels.")
 	f.IntVar(&l.MaxLabelNameLength, "validation.max-length-label-name", 1024, "Maximum length accepted for label names")
 	f.IntVar(&l.MaxLabelValueLength, "validation.max-length-label-value", 2048, "Maximum length accepted for label value. This setting also applies to the metric name")
@@ -107,6 +180,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&l.CreationGracePeriod, "validation.create-grace-period", 10*time.Minute, "Duration which table will be created/deleted before/after it's needed; we won't accept sample from before this time.")
 	f.BoolVar(&l.EnforceMetricName, "validation.enforce-metric-name", true, "Enforce every sample has a metric name.")
 	f.BoolVar(&l.EnforceMetadataMetricName, "validation.enforce-metadata-metric-name", true, "Enforce every metadata has a metric name.")
+	f.Var(&l.PromoteOTelResourceAttributes, "distributor.otlp-promote-resource-attributes", "Comma separated list of OpenTelemetry resource attributes to promote to series labels when ingesting metrics via the OTLP endpoint. The service.name, service.namespace and service.instance.id attributes are always converted to the job and instance labels.")
 
 	f.IntVar(&l.MaxSeriesPerQuery, "ingester.max-series-per-query", 100000, "The maximum number of series for which a query can fetch samples from each ingester. This limit is enforced only in the ingesters (when querying samples not flushed to the storage yet) and it's a per-instance limit. This limit is ignored when running the Cortex blocks storage.")
 	f.IntVar(&l.MaxSamplesPerQuery, "ingester.max-samples-per-query", 1000000, "The maximum number of samples that a query can return. This limit only applies when running the Cortex chunks storage with -querier.ingester-streaming=false.")
@@ -122,23 +196,65 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.MaxGlobalMetadataPerMetric, "ingester.max-global-metadata-per-metric", 0, "The maximum number of metadata per metric, across the cluster. 0 to disable.")
 
 	f.IntVar(&l.MaxChunksPerQuery, "store.query-chunk-limit", 2e6, "Maximum number of chunks that can be fetched in a single query. This limit is enforced when fetching chunks from the long-term storage. When running the Cortex chunks storage, this limit is enforced in the querier, while when running the Cortex blocks storage this limit is both enforced in the querier and store-gateway. 0 to disable.")
+	f.IntVar(&l.MaxFetchedSeriesPerQuery, "querier.max-fetched-series-per-query", 0, "The maximum number of unique series for which a query can fetch samples from ingesters and the long-term storage. This limit is enforced in the querier and, as a per-instance limit, in the ingesters. 0 to disable.")
+	f.IntVar(&l.MaxQueryResponseSizeBytes, "querier.max-query-response-size-bytes", 0, "The maximum size in bytes of the JSON (or protobuf, when sent to the query-frontend) encoded response of an instant or range query. The JSON response is streamed to the client while encoded, so once the limit is exceeded the partial result is followed by a 'status' set to 'error'. This limit is enforced in the querier. 0 to disable.")
+	f.IntVar(&l.MaxSeriesPerSeriesRequest, "querier.max-series-per-series-request", 0, "The maximum number of series returned by a single request to the series API (/api/v1/series). Requests matching more series are rejected, unless they're paginated with the 'limit' parameter, in which case each page contains up to this number of series. This limit is enforced in the querier: the ingesters and storage still return all the series matching the request, or all the ones from the metric name of the page onward for a paginated request. 0 to disable.")
+	f.IntVar(&l.MaxFetchedChunkBytesPerQuery, "querier.max-fetched-chunk-bytes-per-query", 0, "The maximum size of all chunks in bytes that a query can fetch from ingesters and the long-term storage. This limit is enforced in the querier and, as a per-instance limit, in the ingesters. 0 to disable.")
+	f.IntVar(&l.MaxFetchedChunksPerQuery, "querier.max-fetched-chunks-per-query", 0, "The maximum number of chunks that a query can fetch from ingesters and the long-term storage. This limit is enforced in the querier and, as a per-instance limit, in the ingesters and store-gateways. 0 to disable.")
 	f.DurationVar(&l.MaxQueryLength, "store.max-query-length", 0, "Limit the query time range (end - start time). This limit is enforced in the query-frontend (on the received query), in the querier (on the query possibly split by the query-frontend) and in the chunks storage. 0 to disable.")
 	f.DurationVar(&l.MaxQueryLookback, "querier.max-query-lookback", 0, "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 14, "Maximum number of split queries will be scheduled in parallel by the frontend.")
 	f.IntVar(&l.CardinalityLimit, "store.cardinality-limit", 1e5, "Cardinality limit for index queries. This limit is ignored when running the Cortex blocks storage. 0 to disable.")
 	f.DurationVar(&l.MaxCacheFreshness, "frontend.max-cache-freshness", 1*time.Minute, "Most recent allowed cacheable result per-tenant, to prevent caching very recent results that might still be in flux.")
 	f.IntVar(&l.MaxQueriersPerTenant, "frontend.max-queriers-per-tenant", 0, "Maximum number of queriers that can handle requests for a single tenant. If set to 0 or value higher than number of available queriers, *all* queriers will handle requests for the tenant. Each frontend (or query-scheduler, if used) will select the same set of queriers for the same tenant (given that all queriers are connected to all frontends / query-schedulers). This option only works with queriers connecting to the query-frontend / query-scheduler, not when using downstream URL.")
+	f.IntVar(&l.QueryEngineMaxSamples, "querier.tenant-max-samples", 0, "Per-tenant maximum number of samples a single query can load into memory. If > 0, it overrides -querier.max-samples for the tenant's queries evaluated by the querier and ruler. Exceeding it fails the query with HTTP status 422.")
+	f.DurationVar(&l.QueryEngineTimeout, "querier.tenant-timeout", 0, "Per-tenant timeout of the query evaluation. If > 0, it overrides -querier.timeout for the tenant's queries evaluated by the querier and ruler. Exceeding it fails the query with HTTP status 422.")
+	f.DurationVar(&l.QueryEngineLookbackDelta, "querier.tenant-lookback-delta", 0, "Per-tenant time since the last sample after which a time series is considered stale and ignored by expression evaluations. If > 0, it overrides -querier.lookback-delta for the tenant's queries evaluated by the querier and ruler.")
+	f.DurationVar(&l.QueryEngineDefaultEvaluationInterval, "querier.tenant-default-evaluation-interval", 0, "Per-tenant default evaluation interval or step size for subqueries. If > 0, it overrides -querier.default-evaluation-interval for the tenant's queries evaluated by the querier and ruler.")
+	f.DurationVar(&l.QueryIngestersWithin, "querier.tenant-query-ingesters-within", 0, "Per-tenant maximum lookback beyond which queries are not sent to ingesters. If > 0, it overrides -querier.query-ingesters-within for the tenant's queries.")
+	f.DurationVar(&l.QueryStoreAfter, "querier.tenant-query-store-after", 0, "Per-tenant time after which a metric should be queried from the storage and not just ingesters. If > 0, it overrides -querier.query-store-after for the tenant's queries. It should be lower than the query ingesters within period applied to the tenant.")
+	f.DurationVar(&l.SplitQueriesByInterval, "frontend.tenant-split-queries-by-interval", 0, "Per-tenant interval to split the queries by. If > 0, it overrides -querier.split-queries-by-interval for the tenant's queries. The queries are split only if -querier.split-queries-by-interval is enabled.")
+	f.Int64Var(&l.DefaultQueryPriority, "frontend.default-query-priority", 0, "Priority of the tenant's queries not matching any of the configured query priorities. Among the queued queries of a tenant, the query-frontend and query-scheduler dequeue the ones with the highest priority first.")
+	f.BoolVar(&l.QueryPriorityHeaderEnabled, "frontend.query-priority-header-enabled", false, "If true, the priority of a query can be set by the client via the X-Cortex-Query-Priority HTTP header, taking precedence over the configured query priorities.")
 
 	f.DurationVar(&l.RulerEvaluationDelay, "ruler.evaluation-delay-duration", 0, "Duration to delay the evaluation of rules to ensure the underlying metrics have been pushed to Cortex.")
 	f.IntVar(&l.RulerTenantShardSize, "ruler.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used by ruler. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant.")
 	f.IntVar(&l.RulerMaxRulesPerRuleGroup, "ruler.max-rules-per-rule-group", 0, "Maximum number of rules per rule group per-tenant. 0 to disable.")
 	f.IntVar(&l.RulerMaxRuleGroupsPerTenant, "ruler.max-rule-groups-per-tenant", 0, "Maximum number of rule groups per-tenant. 0 to disable.")
+	f.IntVar(&l.RulerMaxConcurrentRuleGroupEvaluations, "ruler.max-concurrent-rule-group-evaluations", 0, "Maximum number of rule groups per-tenant evaluated concurrently by a ruler. Each evaluation of a rule group holds a slot until all its rules are evaluated. When the limit is reached, the evaluation of the other rule groups waits. 0 to disable.")
+	f.BoolVar(&l.RulerEvaluationAlignmentEnabled, "ruler.evaluation-alignment-enabled", false, "Align the timestamp at which the rules are evaluated to the evaluation interval of their rule group, so that the samples written by the rules are aligned. The evaluation schedule of the rule groups is unchanged: the timestamp is moved back by up to the interval.")
+	f.DurationVar(&l.RulerEvaluationJitter, "ruler.evaluation-jitter", 0, "Maximum random delay added before each evaluation of a rule group, to spread the queries of the rule groups evaluated at the same time. It must be lower than the ruler evaluation interval, and is capped to the interval of each rule group. 0 to disable.")
+	f.Var(&l.RulerAllowedSourceTenants, "ruler.allowed-source-tenants", "Comma separated list of tenants whose series the federated rule groups of the tenant are allowed to query, in addition to the tenant itself. Rule groups with other source tenants are rejected when uploaded, and fail to evaluate if the tenants are removed from the list afterwards. Requires -ruler.tenant-federation.enabled.")
+	f.Var(&l.RulerAllowedDestinationTenants, "ruler.allowed-destination-tenants", "Comma separated list of tenants the rule groups of the tenant are allowed to write their series to, in addition to the tenant itself. Rule groups with another destination tenant are rejected when uploaded, and fail to evaluate if the tenant is removed from the list afterwards. Requires -ruler.tenant-federation.enabled.")
 
 	f.StringVar(&l.PerTenantOverrideConfig, "limits.per-user-override-config", "", "File name of per-user overrides. [deprecated, use -runtime-config.file instead]")
 	f.DurationVar(&l.PerTenantOverridePeriod, "limits.per-user-override-period", 10*time.Second, "Period with which to reload the overrides. [deprecated, use -runtime-config.reload-period instead]")
 
 	// Store-gateway.
 	f.IntVar(&l.StoreGatewayTenantShardSize, "store-gateway.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used. Must be set when the store-gateway sharding is enabled with the shuffle-sharding strategy. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant.")
+	f.Float64Var(&l.StoreGatewayBucketReadOpsPerSecond, "store-gateway.bucket-read-ops-per-second", 0, "Maximum number of read operations (Get, GetRange, Exists and Attributes) per second sent by the store-gateway to the object storage for the tenant, before the caching layer. Operations exceeding the limit are queued. This limit is only enforced by the store-gateway. 0 to disable.")
+	f.Float64Var(&l.StoreGatewayBucketListOpsPerSecond, "store-gateway.bucket-list-ops-per-second", 0, "Maximum number of list operations (Iter) per second sent by the store-gateway to the object storage for the tenant, before the caching layer. Operations exceeding the limit are queued. This limit is only enforced by the store-gateway. 0 to disable.")
+
+	// Compactor.
+	f.DurationVar(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", 0, "Delete blocks containing samples older than the specified retention period. 0 to disable.")
+	f.IntVar(&l.CompactorTenantShardSize, "compactor.tenant-shard-size", 0, "The default tenant's shard size when the shuffle-sharding strategy is used by the compactor. Must be set when the compactor sharding is enabled with the shuffle-sharding strategy. When this setting is specified in the per-tenant overrides, a value of 0 disables shuffle sharding for the tenant.")
+	f.IntVar(&l.CompactorSplitAndMergeShards, "compactor.split-and-merge-shards", 0, "The number of shards the blocks of a tenant are split into when compacted with the split-and-merge compaction strategy. The blocks are split into shards, by series hash, at the first level of compaction and the blocks of the same shard are merged at the next levels, allowing multiple compactors to compact the tenant concurrently when sharding is enabled. 0 to use the default compaction strategy.")
+	f.BoolVar(&l.CompactorBlockUploadEnabled, "compactor.block-upload-enabled", false, "Enable the compactor blocks upload API for the tenant, which allows to upload externally built TSDB blocks to backfill historical data.")
+
+	// Blocks storage.
+	f.StringVar(&l.S3SSEType, "s3.sse-type", "", "S3 server-side encryption type used for the tenant's objects. Supported values are: SSE-S3, SSE-KMS. If not set, the S3 client SSE settings (-<prefix>.s3.sse.type) are used.")
+	f.StringVar(&l.S3SSEKMSKeyID, "s3.sse-kms-key-id", "", "S3 server-side encryption KMS key ID used for the tenant's objects. Ignored if the SSE type is not set.")
+	f.StringVar(&l.S3SSEKMSEncryptionContext, "s3.sse-kms-encryption-context", "", "S3 server-side encryption KMS encryption context used for the tenant's objects, as a JSON formatted string. If unset, no encryption context is provided to S3. Ignored if the SSE type is not set.")
+
+	f.IntVar(&l.AlertmanagerMaxConfigSizeBytes, "alertmanager.max-config-size-bytes", 0, "Maximum size of the configuration a tenant can upload via the Alertmanager API, including the template files. 0 to disable.")
+	f.IntVar(&l.AlertmanagerMaxReceivers, "alertmanager.max-receivers", 0, "Maximum number of receivers in the Alertmanager configuration of a tenant. 0 to disable.")
+
+	f.Var(&l.AlertmanagerReceiversBlockCIDRNetworks, "alertmanager.receivers-firewall-block-cidr-networks", "Comma-separated list of network CIDRs to block in Alertmanager receiver integrations.")
+	f.BoolVar(&l.AlertmanagerReceiversBlockPrivateAddresses, "alertmanager.receivers-firewall-block-private-addresses", false, "True to block private and local addresses in Alertmanager receiver integrations. It blocks private addresses defined by RFC 1918 (IPv4 addresses) and RFC 4193 (IPv6 addresses), as well as loopback, local unicast and local multicast addresses.")
+	f.Var(&l.AlertmanagerReceiversAllowCIDRNetworks, "alertmanager.receivers-firewall-allow-cidr-networks", "Comma-separated list of network CIDRs to allow in Alertmanager receiver integrations, taking precedence over the blocked networks and private addresses.")
+
+	f.Float64Var(&l.NotificationRateLimit, "alertmanager.notification-rate-limit", 0, "Per-tenant rate limit for sending notifications from Alertmanager, in notifications per second, applied to each integration separately. 0 = rate limit disabled.")
+	f.IntVar(&l.NotificationBurstSize, "alertmanager.notification-burst-size", 1, "Per-tenant burst size for the notifications sent from Alertmanager, applied to each integration separately.")
 }
 
 // Validate the limits config and returns an error if the validation
@@ -150,6 +266,73 @@ func (l *Limits) Validate(shardByAllLabels bool) error {
 		return errMaxGlobalSeriesPerUserValidation
 	}
 
+	if len(l.LimitsPerLabelSet) > 0 && !shardByAllLabels {
+		return errLimitsPerLabelSetValidation
+	}
+
+	for _, ls := range l.LimitsPerLabelSet {
+		if err := ls.Validate(); err != nil {
+			return err
+		}
+	}
+
+	if err := l.ActiveSeriesCustomTrackers.Validate(); err != nil {
+		return err
+	}
+
+	for _, q := range l.BlockedQueries {
+		if err := q.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range l.SplitQueriesByTimeRange {
+		if err := s.Validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range l.QueryPriorities {
+		if err := p.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateQueryTimeRanges validates the time ranges queried from ingesters and from the storage
+// applied to the tenant, which are the per-tenant limits if set, otherwise the input querier config.
+func (l *Limits) ValidateQueryTimeRanges(queryIngestersWithin, queryStoreAfter, shuffleShardingIngestersLookbackPeriod time.Duration) error {
+	if l.QueryIngestersWithin > 0 {
+		queryIngestersWithin = l.QueryIngestersWithin
+	}
+	if l.QueryStoreAfter > 0 {
+		queryStoreAfter = l.QueryStoreAfter
+	}
+
+	if queryIngestersWithin > 0 && queryStoreAfter >= queryIngestersWithin {
+		return errQueryStoreAfterValidation
+	}
+
+	if shuffleShardingIngestersLookbackPeriod > 0 && queryStoreAfter > shuffleShardingIngestersLookbackPeriod {
+		return errQueryStoreAfterLookbackValidation
+	}
+
+	return nil
+}
+
+// ValidateRulerEvaluationJitter validates the ruler evaluation jitter against the
+// default evaluation interval of the rule groups.
+func (l *Limits) ValidateRulerEvaluationJitter(evaluationInterval time.Duration) error {
+	if l.RulerEvaluationJitter < 0 {
+		return errNegativeRulerEvaluationJitter
+	}
+
+	if l.RulerEvaluationJitter > 0 && l.RulerEvaluationJitter >= evaluationInterval {
+		return errRulerEvaluationJitterValidation
+	}
+
 	return nil
 }
 
@@ -231,6 +414,16 @@ func (o *Overrides) HAReplicaLabel(userID string) string {
 	return o.getOverridesForUser(userID).HAReplicaLabel
 }
 
+// HATrackerFailoverTimeout returns the failover timeout of the user's Prometheus HA clusters, or 0 to use the HA tracker default.
+func (o *Overrides) HATrackerFailoverTimeout(userID string) time.Duration {
+	return o.getOverridesForUser(userID).HATrackerFailoverTimeout
+}
+
+// PromoteOTelResourceAttributes returns the list of OpenTelemetry resource attributes to promote to series labels for the user.
+func (o *Overrides) PromoteOTelResourceAttributes(userID string) []string {
+	return o.getOverridesForUser(userID).PromoteOTelResourceAttributes
+}
+
 // DropLabels returns the list of labels to be dropped when ingesting HA samples for the user.
 func (o *Overrides) DropLabels(userID string) flagext.StringSlice {
 	return o.getOverridesForUser(userID).DropLabels
@@ -305,11 +498,76 @@ func (o *Overrides) MaxGlobalSeriesPerMetric(userID string) int {
 	return o.getOverridesForUser(userID).MaxGlobalSeriesPerMetric
 }
 
+// LimitsPerLabelSet returns the limits on the number of series matching a label set for a given user.
+func (o *Overrides) LimitsPerLabelSet(userID string) []*LimitsPerLabelSet {
+	return o.getOverridesForUser(userID).LimitsPerLabelSet
+}
+
+// ActiveSeriesCustomTrackers returns the additional active series trackers for a given user.
+func (o *Overrides) ActiveSeriesCustomTrackers(userID string) ActiveSeriesCustomTrackersConfig {
+	return o.getOverridesForUser(userID).ActiveSeriesCustomTrackers
+}
+
 // MaxChunksPerQuery returns the maximum number of chunks allowed per query.
 func (o *Overrides) MaxChunksPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxChunksPerQuery
 }
 
+// MaxFetchedSeriesPerQuery returns the maximum number of unique series a query is allowed to fetch.
+func (o *Overrides) MaxFetchedSeriesPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxFetchedSeriesPerQuery
+}
+
+// MaxQueryResponseSizeBytes returns the maximum size in bytes of the response of a query.
+func (o *Overrides) MaxQueryResponseSizeBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxQueryResponseSizeBytes
+}
+
+// MaxSeriesPerSeriesRequest returns the maximum number of series returned by a single series API request.
+func (o *Overrides) MaxSeriesPerSeriesRequest(userID string) int {
+	return o.getOverridesForUser(userID).MaxSeriesPerSeriesRequest
+}
+
+// QueryIngestersWithin returns the maximum lookback beyond which queries are not sent to ingesters, or 0 to use the querier config.
+func (o *Overrides) QueryIngestersWithin(userID string) time.Duration {
+	return o.getOverridesForUser(userID).QueryIngestersWithin
+}
+
+// QueryStoreAfter returns the time after which a metric should be queried from the storage, or 0 to use the querier config.
+func (o *Overrides) QueryStoreAfter(userID string) time.Duration {
+	return o.getOverridesForUser(userID).QueryStoreAfter
+}
+
+// QueryEngineMaxSamples returns the maximum number of samples a query can load into memory, or 0 to use the querier config.
+func (o *Overrides) QueryEngineMaxSamples(userID string) int {
+	return o.getOverridesForUser(userID).QueryEngineMaxSamples
+}
+
+// QueryEngineTimeout returns the timeout of the query evaluation, or 0 to use the querier config.
+func (o *Overrides) QueryEngineTimeout(userID string) time.Duration {
+	return o.getOverridesForUser(userID).QueryEngineTimeout
+}
+
+// QueryEngineLookbackDelta returns the PromQL lookback delta, or 0 to use the querier config.
+func (o *Overrides) QueryEngineLookbackDelta(userID string) time.Duration {
+	return o.getOverridesForUser(userID).QueryEngineLookbackDelta
+}
+
+// QueryEngineDefaultEvaluationInterval returns the default evaluation interval of the subqueries, or 0 to use the querier config.
+func (o *Overrides) QueryEngineDefaultEvaluationInterval(userID string) time.Duration {
+	return o.getOverridesForUser(userID).QueryEngineDefaultEvaluationInterval
+}
+
+// MaxFetchedChunkBytesPerQuery returns the maximum size of chunks in bytes a query is allowed to fetch.
+func (o *Overrides) MaxFetchedChunkBytesPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxFetchedChunkBytesPerQuery
+}
+
+// MaxFetchedChunksPerQuery returns the maximum number of chunks a query is allowed to fetch.
+func (o *Overrides) MaxFetchedChunksPerQuery(userID string) int {
+	return o.getOverridesForUser(userID).MaxFetchedChunksPerQuery
+}
+
 // MaxQueryLookback returns the max lookback period of queries.
 func (o *Overrides) MaxQueryLookback(userID string) time.Duration {
 	return o.getOverridesForUser(userID).MaxQueryLookback
@@ -331,6 +589,36 @@ func (o *Overrides) MaxQueriersPerUser(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueriersPerTenant
 }
 
+// BlockedQueries returns the queries blocked for the tenant.
+func (o *Overrides) BlockedQueries(userID string) []*BlockedQuery {
+	return o.getOverridesForUser(userID).BlockedQueries
+}
+
+// SplitQueriesByInterval returns the interval to split the tenant's queries by, or 0 to use the query-frontend config.
+func (o *Overrides) SplitQueriesByInterval(userID string) time.Duration {
+	return o.getOverridesForUser(userID).SplitQueriesByInterval
+}
+
+// SplitQueriesByTimeRange returns the intervals to split the tenant's queries by, depending on their time range.
+func (o *Overrides) SplitQueriesByTimeRange(userID string) []*SplitQueriesInterval {
+	return o.getOverridesForUser(userID).SplitQueriesByTimeRange
+}
+
+// QueryPriorities returns the priorities assigned to the tenant's queries.
+func (o *Overrides) QueryPriorities(userID string) []*QueryPriority {
+	return o.getOverridesForUser(userID).QueryPriorities
+}
+
+// DefaultQueryPriority returns the priority of the tenant's queries not matching any query priority.
+func (o *Overrides) DefaultQueryPriority(userID string) int64 {
+	return o.getOverridesForUser(userID).DefaultQueryPriority
+}
+
+// QueryPriorityHeaderEnabled returns whether the tenant's queries priority can be set via HTTP header.
+func (o *Overrides) QueryPriorityHeaderEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).QueryPriorityHeaderEnabled
+}
+
 // MaxQueryParallelism returns the limit to the number of split queries the
 // frontend will process in parallel.
 func (o *Overrides) MaxQueryParallelism(userID string) int {
@@ -407,11 +695,134 @@ func (o *Overrides) RulerMaxRuleGroupsPerTenant(userID string) int {
 	return o.getOverridesForUser(userID).RulerMaxRuleGroupsPerTenant
 }
 
+// RulerMaxConcurrentRuleGroupEvaluations returns the maximum number of rule groups evaluated concurrently for a given user.
+func (o *Overrides) RulerMaxConcurrentRuleGroupEvaluations(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxConcurrentRuleGroupEvaluations
+}
+
+// RulerEvaluationAlignmentEnabled returns whether the evaluation timestamps of the rule groups are aligned to their interval for a given user.
+func (o *Overrides) RulerEvaluationAlignmentEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).RulerEvaluationAlignmentEnabled
+}
+
+// RulerEvaluationJitter returns the maximum random delay added before each rule group evaluation for a given user.
+func (o *Overrides) RulerEvaluationJitter(userID string) time.Duration {
+	return o.getOverridesForUser(userID).RulerEvaluationJitter
+}
+
+// RulerAllowedSourceTenants returns the tenants the federated rule groups of a given user are allowed to query.
+func (o *Overrides) RulerAllowedSourceTenants(userID string) []string {
+	return o.getOverridesForUser(userID).RulerAllowedSourceTenants
+}
+
+// RulerAllowedDestinationTenants returns the tenants the rule groups of a given user are allowed to write their series to.
+func (o *Overrides) RulerAllowedDestinationTenants(userID string) []string {
+	return o.getOverridesForUser(userID).RulerAllowedDestinationTenants
+}
+
+// AlertmanagerMaxConfigSize returns the maximum size of the Alertmanager configuration for a given user.
+func (o *Overrides) AlertmanagerMaxConfigSize(userID string) int {
+	return o.getOverridesForUser(userID).AlertmanagerMaxConfigSizeBytes
+}
+
+// AlertmanagerMaxReceivers returns the maximum number of receivers in the Alertmanager configuration for a given user.
+func (o *Overrides) AlertmanagerMaxReceivers(userID string) int {
+	return o.getOverridesForUser(userID).AlertmanagerMaxReceivers
+}
+
+// AlertmanagerReceiversBlockCIDRNetworks returns the network CIDRs blocked in the Alertmanager receivers for a given user.
+func (o *Overrides) AlertmanagerReceiversBlockCIDRNetworks(userID string) []flagext.CIDR {
+	return o.getOverridesForUser(userID).AlertmanagerReceiversBlockCIDRNetworks
+}
+
+// AlertmanagerReceiversBlockPrivateAddresses returns whether private addresses are blocked in the Alertmanager receivers for a given user.
+func (o *Overrides) AlertmanagerReceiversBlockPrivateAddresses(userID string) bool {
+	return o.getOverridesForUser(userID).AlertmanagerReceiversBlockPrivateAddresses
+}
+
+// AlertmanagerReceiversAllowCIDRNetworks returns the network CIDRs always allowed in the Alertmanager receivers for a given user.
+func (o *Overrides) AlertmanagerReceiversAllowCIDRNetworks(userID string) []flagext.CIDR {
+	return o.getOverridesForUser(userID).AlertmanagerReceiversAllowCIDRNetworks
+}
+
+// NotificationRateLimit returns the notification rate limit of an integration for a given user.
+func (o *Overrides) NotificationRateLimit(userID string, integration string) rate.Limit {
+	l := o.getOverridesForUser(userID)
+	if limit, ok := l.NotificationRateLimitPerIntegration[integration]; ok {
+		return limitToRate(limit)
+	}
+	return limitToRate(l.NotificationRateLimit)
+}
+
+// NotificationBurstSize returns the notification burst size of an integration for a given user.
+func (o *Overrides) NotificationBurstSize(userID string, integration string) int {
+	return o.getOverridesForUser(userID).NotificationBurstSize
+}
+
+// limitToRate converts a rate limit, in events per second, to a rate.Limit,
+// where 0 means the rate limit is disabled.
+func limitToRate(limit float64) rate.Limit {
+	if limit <= 0 {
+		return rate.Inf
+	}
+	return rate.Limit(limit)
+}
+
+// CompactorBlocksRetentionPeriod returns the retention period for a given user.
+func (o *Overrides) CompactorBlocksRetentionPeriod(userID string) time.Duration {
+	return o.getOverridesForUser(userID).CompactorBlocksRetentionPeriod
+}
+
+// CompactorTenantShardSize returns the compactor shard size for a given user.
+func (o *Overrides) CompactorTenantShardSize(userID string) int {
+	return o.getOverridesForUser(userID).CompactorTenantShardSize
+}
+
+// CompactorSplitAndMergeShards returns the number of shards used by the split-and-merge compaction strategy for a given user.
+func (o *Overrides) CompactorSplitAndMergeShards(userID string) int {
+	return o.getOverridesForUser(userID).CompactorSplitAndMergeShards
+}
+
+// CompactorBlockUploadEnabled returns whether the blocks upload API is enabled for a given user.
+func (o *Overrides) CompactorBlockUploadEnabled(userID string) bool {
+	return o.getOverridesForUser(userID).CompactorBlockUploadEnabled
+}
+
+// S3SSEType returns the per-tenant S3 SSE type.
+func (o *Overrides) S3SSEType(user string) string {
+	return o.getOverridesForUser(user).S3SSEType
+}
+
+// S3SSEKMSKeyID returns the per-tenant S3 KMS-SSE key id.
+func (o *Overrides) S3SSEKMSKeyID(user string) string {
+	return o.getOverridesForUser(user).S3SSEKMSKeyID
+}
+
+// S3SSEKMSEncryptionContext returns the per-tenant S3 KMS-SSE encryption context.
+func (o *Overrides) S3SSEKMSEncryptionContext(user string) string {
+	return o.getOverridesForUser(user).S3SSEKMSEncryptionContext
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).StoreGatewayTenantShardSize
 }
 
+// StoreGatewayBucketReadOpsPerSecond returns the max read operations per second sent by the store-gateway to the object storage for a given user.
+func (o *Overrides) StoreGatewayBucketReadOpsPerSecond(userID string) float64 {
+	return o.getOverridesForUser(userID).StoreGatewayBucketReadOpsPerSecond
+}
+
+// StoreGatewayBucketListOpsPerSecond returns the max list operations per second sent by the store-gateway to the object storage for a given user.
+func (o *Overrides) StoreGatewayBucketListOpsPerSecond(userID string) float64 {
+	return o.getOverridesForUser(userID).StoreGatewayBucketListOpsPerSecond
+}
+
+// Limits returns the limits applied to the given user.
+func (o *Overrides) Limits(userID string) *Limits {
+	return o.getOverridesForUser(userID)
+}
+
 func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	if o.tenantLimits != nil {
 		l := o.tenantLimits(userID)
@@ -421,3 +832,68 @@ func (o *Overrides) getOverridesForUser(userID string) *Limits {
 	}
 	return o.defaultLimits
 }
+
+// SmallestPositiveIntPerTenant is returning the minimal positive value of the
+// supplied limit function for all given tenants.
+func SmallestPositiveIntPerTenant(tenantIDs []string, f func(string) int) int {
+	var result *int
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if result == nil || v < *result {
+			result = &v
+		}
+	}
+	if result == nil {
+		return 0
+	}
+	return *result
+}
+
+// SmallestPositiveNonZeroIntPerTenant is returning the minimal positive and
+// non-zero value of the supplied limit function for all given tenants. In many
+// limits a value of 0 means unlimited so the method will return 0 only if all
+// inputs have a limit of 0 or an empty tenant list is given.
+func SmallestPositiveNonZeroIntPerTenant(tenantIDs []string, f func(string) int) int {
+	var result *int
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if v > 0 && (result == nil || v < *result) {
+			result = &v
+		}
+	}
+	if result == nil {
+		return 0
+	}
+	return *result
+}
+
+// SmallestPositiveNonZeroDurationPerTenant is returning the minimal positive
+// and non-zero value of the supplied limit function for all given tenants. In
+// many limits a value of 0 means unlimited so the method will return 0 only if
+// all inputs have a limit of 0 or an empty tenant list is given.
+func SmallestPositiveNonZeroDurationPerTenant(tenantIDs []string, f func(string) time.Duration) time.Duration {
+	var result *time.Duration
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if v > 0 && (result == nil || v < *result) {
+			result = &v
+		}
+	}
+	if result == nil {
+		return 0
+	}
+	return *result
+}
+
+// MaxDurationPerTenant is returning the maximum duration per tenant. Without
+// tenants given it will return a time.Duration(0).
+func MaxDurationPerTenant(tenantIDs []string, f func(string) time.Duration) time.Duration {
+	result := time.Duration(0)
+	for _, tenantID := range tenantIDs {
+		v := f(tenantID)
+		if v > result {
+			result = v
+		}
+	}
+	return result
+}
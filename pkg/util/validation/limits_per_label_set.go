@@ -0,0 +1,53 @@
+package validation
+
+import (
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+)
+
+// LimitsPerLabelSet configures the max number of series a tenant can have for the
+// series matching a given label set.
+type LimitsPerLabelSet struct {
+	// LabelSet is the set of label name/value pairs a series must have to be accounted in this limit.
+	LabelSet map[string]string `yaml:"label_set"`
+
+	// MaxGlobalSeries is the max number of series matching LabelSet, across the cluster.
+	MaxGlobalSeries int `yaml:"max_global_series"`
+}
+
+// Validate the limits per label set config.
+func (l *LimitsPerLabelSet) Validate() error {
+	if len(l.LabelSet) == 0 {
+		return errors.New("the label set of a limit per label set is empty")
+	}
+
+	for name := range l.LabelSet {
+		if !model.LabelName(name).IsValid() {
+			return errors.Errorf("invalid label name %q in the limit per label set", name)
+		}
+	}
+
+	if l.MaxGlobalSeries < 0 {
+		return errors.Errorf("the max global series of the limit per label set %s is negative", l.String())
+	}
+
+	return nil
+}
+
+// Matches returns whether the input series has all the label name/value pairs of this label set.
+func (l *LimitsPerLabelSet) Matches(series labels.Labels) bool {
+	for name, value := range l.LabelSet {
+		if series.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the label set formatted as a Prometheus series selector,
+// uniquely identifying this limit.
+func (l *LimitsPerLabelSet) String() string {
+	return labels.FromMap(l.LabelSet).String()
+}
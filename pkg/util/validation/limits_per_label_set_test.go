@@ -0,0 +1,44 @@
+package validation
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLimitsPerLabelSet_Validate(t *testing.T) {
+	assert.NoError(t, (&LimitsPerLabelSet{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: 10}).Validate())
+	assert.NoError(t, (&LimitsPerLabelSet{LabelSet: map[string]string{"team": "a"}}).Validate())
+	assert.Error(t, (&LimitsPerLabelSet{MaxGlobalSeries: 10}).Validate())
+	assert.Error(t, (&LimitsPerLabelSet{LabelSet: map[string]string{"0team": "a"}, MaxGlobalSeries: 10}).Validate())
+	assert.Error(t, (&LimitsPerLabelSet{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: -1}).Validate())
+}
+
+func TestLimitsPerLabelSet_Matches(t *testing.T) {
+	limit := LimitsPerLabelSet{LabelSet: map[string]string{"team": "a", "env": "prod"}}
+
+	assert.True(t, limit.Matches(labels.FromStrings("__name__", "up", "env", "prod", "team", "a")))
+	assert.False(t, limit.Matches(labels.FromStrings("__name__", "up", "env", "dev", "team", "a")))
+	assert.False(t, limit.Matches(labels.FromStrings("__name__", "up", "team", "a")))
+	assert.Equal(t, `{env="prod", team="a"}`, limit.String())
+}
+
+func TestLimitsPerLabelSet_LoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	inp := `
+limits_per_label_set:
+  - label_set:
+      team: a
+    max_global_series: 50000
+`
+
+	l := Limits{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(inp), &l))
+	assert.Equal(t, []*LimitsPerLabelSet{
+		{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: 50000},
+	}, l.LimitsPerLabelSet)
+}
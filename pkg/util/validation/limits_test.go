@@ -2,6 +2,7 @@ package validation
 
 import (
 	"testing"
+	"time"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/pkg/relabel"
@@ -33,6 +34,16 @@ func TestLimits_Validate(t *testing.T) {
 			shardByAllLabels: true,
 			expected:         nil,
 		},
+		"limits per label set enabled and shard-by-all-labels=false": {
+			limits:           Limits{LimitsPerLabelSet: []*LimitsPerLabelSet{{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: 10}}},
+			shardByAllLabels: false,
+			expected:         errLimitsPerLabelSetValidation,
+		},
+		"limits per label set enabled and shard-by-all-labels=true": {
+			limits:           Limits{LimitsPerLabelSet: []*LimitsPerLabelSet{{LabelSet: map[string]string{"team": "a"}, MaxGlobalSeries: 10}}},
+			shardByAllLabels: true,
+			expected:         nil,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -44,6 +55,99 @@ func TestLimits_Validate(t *testing.T) {
 	}
 }
 
+func TestLimits_ValidateQueryTimeRanges(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		limits                  Limits
+		queryIngestersWithin    time.Duration
+		queryStoreAfter         time.Duration
+		shuffleShardingLookback time.Duration
+		expected                error
+	}{
+		"no limits and no querier config": {
+			expected: nil,
+		},
+		"query store after lower than query ingesters within": {
+			limits:   Limits{QueryIngestersWithin: 2 * time.Hour, QueryStoreAfter: time.Hour},
+			expected: nil,
+		},
+		"query store after greater or equal than query ingesters within": {
+			limits:   Limits{QueryIngestersWithin: time.Hour, QueryStoreAfter: time.Hour},
+			expected: errQueryStoreAfterValidation,
+		},
+		"per-tenant query store after greater than the querier query ingesters within": {
+			limits:               Limits{QueryStoreAfter: 3 * time.Hour},
+			queryIngestersWithin: 2 * time.Hour,
+			queryStoreAfter:      time.Hour,
+			expected:             errQueryStoreAfterValidation,
+		},
+		"per-tenant query ingesters within lower than the querier query store after": {
+			limits:               Limits{QueryIngestersWithin: time.Hour},
+			queryIngestersWithin: 13 * time.Hour,
+			queryStoreAfter:      12 * time.Hour,
+			expected:             errQueryStoreAfterValidation,
+		},
+		"per-tenant values overriding both the querier config": {
+			limits:               Limits{QueryIngestersWithin: 3 * time.Hour, QueryStoreAfter: 2 * time.Hour},
+			queryIngestersWithin: time.Hour,
+			queryStoreAfter:      2 * time.Hour,
+			expected:             nil,
+		},
+		"per-tenant query store after greater than the shuffle-sharding lookback period": {
+			limits:                  Limits{QueryStoreAfter: 2 * time.Hour},
+			shuffleShardingLookback: time.Hour,
+			expected:                errQueryStoreAfterLookbackValidation,
+		},
+		"per-tenant query store after equal to the shuffle-sharding lookback period": {
+			limits:                  Limits{QueryStoreAfter: time.Hour},
+			shuffleShardingLookback: time.Hour,
+			expected:                nil,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, testData.limits.ValidateQueryTimeRanges(testData.queryIngestersWithin, testData.queryStoreAfter, testData.shuffleShardingLookback))
+		})
+	}
+}
+
+func TestLimits_ValidateRulerEvaluationJitter(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		limits   Limits
+		expected error
+	}{
+		"no jitter": {
+			expected: nil,
+		},
+		"jitter lower than the evaluation interval": {
+			limits:   Limits{RulerEvaluationJitter: 30 * time.Second},
+			expected: nil,
+		},
+		"jitter equal to the evaluation interval": {
+			limits:   Limits{RulerEvaluationJitter: time.Minute},
+			expected: errRulerEvaluationJitterValidation,
+		},
+		"negative jitter": {
+			limits:   Limits{RulerEvaluationJitter: -time.Second},
+			expected: errNegativeRulerEvaluationJitter,
+		},
+	}
+
+	for testName, testData := range tests {
+		testData := testData
+
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expected, testData.limits.ValidateRulerEvaluationJitter(time.Minute))
+		})
+	}
+}
+
 func TestOverridesManager_GetOverrides(t *testing.T) {
 	tenantLimits := map[string]*Limits{}
 
@@ -113,3 +217,53 @@ metric_relabel_configs:
 
 	assert.Equal(t, []*relabel.Config{&exp}, l.MetricRelabelConfigs)
 }
+
+func TestLimitsPerTenant(t *testing.T) {
+	intLimits := map[string]int{"tenant-a": 0, "tenant-b": 10, "tenant-c": 5}
+	durationLimits := map[string]time.Duration{"tenant-a": 0, "tenant-b": time.Hour, "tenant-c": time.Minute}
+
+	intLimit := func(tenantID string) int { return intLimits[tenantID] }
+	durationLimit := func(tenantID string) time.Duration { return durationLimits[tenantID] }
+
+	tests := map[string]struct {
+		tenantIDs                       []string
+		expectedSmallestPositive        int
+		expectedSmallestPositiveNonZero int
+		expectedSmallestNonZeroDuration time.Duration
+		expectedMaxDuration             time.Duration
+	}{
+		"no tenants": {
+			tenantIDs: nil,
+		},
+		"single tenant": {
+			tenantIDs:                       []string{"tenant-b"},
+			expectedSmallestPositive:        10,
+			expectedSmallestPositiveNonZero: 10,
+			expectedSmallestNonZeroDuration: time.Hour,
+			expectedMaxDuration:             time.Hour,
+		},
+		"multiple tenants": {
+			tenantIDs:                       []string{"tenant-b", "tenant-c"},
+			expectedSmallestPositive:        5,
+			expectedSmallestPositiveNonZero: 5,
+			expectedSmallestNonZeroDuration: time.Minute,
+			expectedMaxDuration:             time.Hour,
+		},
+		"multiple tenants with a disabled limit": {
+			tenantIDs:                       []string{"tenant-a", "tenant-b", "tenant-c"},
+			expectedSmallestPositive:        0,
+			expectedSmallestPositiveNonZero: 5,
+			expectedSmallestNonZeroDuration: time.Minute,
+			expectedMaxDuration:             time.Hour,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedSmallestPositive, SmallestPositiveIntPerTenant(testData.tenantIDs, intLimit))
+			assert.Equal(t, testData.expectedSmallestPositiveNonZero, SmallestPositiveNonZeroIntPerTenant(testData.tenantIDs, intLimit))
+			assert.Equal(t, testData.expectedSmallestNonZeroDuration, SmallestPositiveNonZeroDurationPerTenant(testData.tenantIDs, durationLimit))
+			assert.Equal(t, testData.expectedMaxDuration, MaxDurationPerTenant(testData.tenantIDs, durationLimit))
+		})
+	}
+}
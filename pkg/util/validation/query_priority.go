@@ -0,0 +1,59 @@
+package validation
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// QueryPriority configures the priority assigned to the queries of a tenant matching a pattern.
+type QueryPriority struct {
+	// Priority assigned to the matching queries. The higher the value, the higher the priority.
+	Priority int64 `yaml:"priority"`
+
+	// Pattern is the query to match. It's compared with the query as is, unless Regex is true.
+	Pattern string `yaml:"pattern"`
+
+	// Regex is true if Pattern is a regular expression, matching the whole query.
+	Regex bool `yaml:"regex"`
+
+	// MinTimeRange matches the query only if its time range is at least this long. 0 to match the
+	// query regardless of its time range.
+	MinTimeRange time.Duration `yaml:"min_time_range"`
+
+	// The compiled Pattern, if Regex is true. It's compiled once when the config is loaded.
+	regex *regexp.Regexp
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, compiling the regex pattern.
+func (p *QueryPriority) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	type plain QueryPriority
+	if err := unmarshal((*plain)(p)); err != nil {
+		return err
+	}
+
+	// An invalid regex is reported by Validate(), which is called once the config is loaded.
+	p.regex = compileQueryRegexIfEnabled(p.Pattern, p.Regex)
+	return nil
+}
+
+// Validate the query priority config.
+func (p *QueryPriority) Validate() error {
+	if p.Pattern == "" {
+		return errors.New("the pattern of a query priority is empty")
+	}
+
+	if p.Regex {
+		if _, err := compileQueryRegex(p.Pattern); err != nil {
+			return errors.Wrapf(err, "invalid query priority regex %q", p.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns whether the input query, with the given time range, matches this query priority.
+func (p *QueryPriority) Matches(query string, timeRange time.Duration) bool {
+	return matchesQuery(p.Pattern, p.Regex, p.regex, p.MinTimeRange, query, timeRange)
+}
@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestQueryPriority_Validate(t *testing.T) {
+	assert.NoError(t, (&QueryPriority{Priority: 1, Pattern: "up"}).Validate())
+	assert.NoError(t, (&QueryPriority{Priority: 1, Pattern: "up.*", Regex: true}).Validate())
+	assert.Error(t, (&QueryPriority{Priority: 1, Pattern: ""}).Validate())
+	assert.Error(t, (&QueryPriority{Priority: 1, Pattern: "up[", Regex: true}).Validate())
+}
+
+func TestQueryPriority_Matches(t *testing.T) {
+	p := QueryPriority{Priority: 1, Pattern: "sum.*", Regex: true, MinTimeRange: time.Hour}
+
+	assert.True(t, p.Matches("sum(up)", 2*time.Hour))
+	assert.False(t, p.Matches("sum(up)", time.Minute))
+	assert.False(t, p.Matches("up", 2*time.Hour))
+}
+
+func TestQueryPriorities_LoadingFromYaml(t *testing.T) {
+	SetDefaultLimitsForYAMLUnmarshalling(Limits{})
+
+	inp := `
+query_priorities:
+  - priority: 10
+    pattern: 'sum.*'
+    regex: true
+`
+
+	l := Limits{}
+	require.NoError(t, yaml.UnmarshalStrict([]byte(inp), &l))
+	require.Len(t, l.QueryPriorities, 1)
+
+	// The regex is compiled once when the config is loaded.
+	require.NotNil(t, l.QueryPriorities[0].regex)
+	assert.True(t, l.QueryPriorities[0].Matches("sum(up)", 0))
+	assert.False(t, l.QueryPriorities[0].Matches("up", 0))
+}
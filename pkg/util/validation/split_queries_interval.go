@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SplitQueriesInterval configures the interval to split the queries of a tenant by, when
+// their time range is at least MinTimeRange.
+type SplitQueriesInterval struct {
+	// MinTimeRange is the min time range of the queries split by this interval.
+	MinTimeRange time.Duration `yaml:"min_time_range"`
+
+	// Interval to split the queries by.
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Validate the split queries interval config.
+func (s *SplitQueriesInterval) Validate() error {
+	if s.Interval <= 0 {
+		return errors.New("the interval to split the queries by must be greater than 0")
+	}
+	if s.MinTimeRange < 0 {
+		return errors.New("the min time range of the queries to split by interval must not be negative")
+	}
+
+	return nil
+}
+
+// SplitQueriesIntervalForTimeRange returns the interval of the entry with the longest
+// min time range not exceeding the given query time range, or 0 if no entry applies.
+func SplitQueriesIntervalForTimeRange(intervals []*SplitQueriesInterval, timeRange time.Duration) time.Duration {
+	var match *SplitQueriesInterval
+	for _, s := range intervals {
+		if s.MinTimeRange <= timeRange && (match == nil || s.MinTimeRange > match.MinTimeRange) {
+			match = s
+		}
+	}
+
+	if match == nil {
+		return 0
+	}
+	return match.Interval
+}
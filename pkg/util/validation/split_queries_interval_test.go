@@ -0,0 +1,29 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitQueriesInterval_Validate(t *testing.T) {
+	assert.NoError(t, (&SplitQueriesInterval{Interval: time.Hour}).Validate())
+	assert.NoError(t, (&SplitQueriesInterval{MinTimeRange: 24 * time.Hour, Interval: time.Hour}).Validate())
+	assert.Error(t, (&SplitQueriesInterval{}).Validate())
+	assert.Error(t, (&SplitQueriesInterval{MinTimeRange: -time.Hour, Interval: time.Hour}).Validate())
+}
+
+func TestSplitQueriesIntervalForTimeRange(t *testing.T) {
+	intervals := []*SplitQueriesInterval{
+		{MinTimeRange: 7 * 24 * time.Hour, Interval: 7 * 24 * time.Hour},
+		{MinTimeRange: time.Hour, Interval: 24 * time.Hour},
+	}
+
+	assert.Equal(t, time.Duration(0), SplitQueriesIntervalForTimeRange(nil, time.Hour))
+	assert.Equal(t, time.Duration(0), SplitQueriesIntervalForTimeRange(intervals, time.Minute))
+	assert.Equal(t, 24*time.Hour, SplitQueriesIntervalForTimeRange(intervals, time.Hour))
+	assert.Equal(t, 24*time.Hour, SplitQueriesIntervalForTimeRange(intervals, 7*24*time.Hour-time.Millisecond))
+	assert.Equal(t, 7*24*time.Hour, SplitQueriesIntervalForTimeRange(intervals, 7*24*time.Hour))
+	assert.Equal(t, 7*24*time.Hour, SplitQueriesIntervalForTimeRange(intervals, 30*24*time.Hour))
+}
@@ -0,0 +1,77 @@
+package backfill
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+)
+
+// Backfill builds the blocks from the configured input and writes them to the configured output.
+func Backfill(ctx context.Context, cfg Config, logger log.Logger, reg prometheus.Registerer) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	var uploader blockUploader
+	switch cfg.Output {
+	case OutputBucket:
+		bkt, err := bucket.NewClient(ctx, cfg.Bucket, "backfill", logger, reg)
+		if err != nil {
+			return errors.Wrap(err, "create bucket client")
+		}
+		uploader = newBucketUploader(bkt, cfg.TenantID, logger)
+	case OutputUploadAPI:
+		uploader = newAPIUploader(cfg.UploadAPIURL, cfg.TenantID)
+	}
+
+	var reader samplesReader
+	switch cfg.InputFormat {
+	case InputFormatOpenMetrics:
+		content, err := ioutil.ReadFile(cfg.InputPath)
+		if err != nil {
+			return errors.Wrap(err, "read input file")
+		}
+		r, err := newOpenMetricsReader(content, cfg.BlockDuration.Milliseconds())
+		if err != nil {
+			return err
+		}
+		reader = r
+	case InputFormatTSDB:
+		r, err := newTSDBReader(cfg.InputPath, logger)
+		if err != nil {
+			return err
+		}
+		defer r.Close() //nolint:errcheck
+		reader = r
+	}
+
+	outputDir, err := ioutil.TempDir(cfg.WorkingDir, "backfill")
+	if err != nil {
+		return errors.Wrap(err, "create working directory")
+	}
+	defer os.RemoveAll(outputDir) //nolint:errcheck
+
+	ids, err := CreateBlocks(ctx, reader, outputDir, cfg.TenantID, cfg.BlockDuration.Milliseconds(), logger)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := uploader.Upload(ctx, filepath.Join(outputDir, id.String())); err != nil {
+			return errors.Wrapf(err, "upload block %s", id.String())
+		}
+
+		level.Info(logger).Log("msg", "uploaded block", "block", id.String(), "user", cfg.TenantID)
+	}
+
+	level.Info(logger).Log("msg", "backfill completed", "blocks", len(ids), "user", cfg.TenantID)
+	return nil
+}
@@ -0,0 +1,220 @@
+package backfill
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+	"github.com/cortexproject/cortex/pkg/storage/bucket/filesystem"
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+// The OpenMetrics timestamps are in seconds.
+const openMetricsInput = `# HELP http_requests_total The total number of HTTP requests.
+# TYPE http_requests_total counter
+http_requests_total{code="200"} 1 1
+http_requests_total{code="200"} 2 3601
+http_requests_total{code="200"} 3 7201
+http_requests_total{code="500"} 1 1
+# EOF
+`
+
+func TestCreateBlocks_OpenMetrics(t *testing.T) {
+	outputDir, err := ioutil.TempDir(os.TempDir(), "backfill")
+	require.NoError(t, err)
+	defer os.RemoveAll(outputDir) //nolint:errcheck
+
+	ids, err := CreateBlocks(context.Background(), newTestOpenMetricsReader(t, openMetricsInput, time.Hour.Milliseconds()), outputDir, "user-1", time.Hour.Milliseconds(), log.NewNopLogger())
+	require.NoError(t, err)
+
+	// The samples span 3 hours, so 3 blocks are expected.
+	require.Len(t, ids, 3)
+
+	expectedRanges := [][2]int64{{0, 3600000}, {3600000, 7200000}, {7200000, 10800000}}
+	expectedSamples := []uint64{2, 1, 1}
+
+	for i, id := range ids {
+		meta, err := metadata.ReadFromDir(filepath.Join(outputDir, id.String()))
+		require.NoError(t, err)
+
+		assert.GreaterOrEqual(t, meta.MinTime, expectedRanges[i][0])
+		assert.LessOrEqual(t, meta.MaxTime, expectedRanges[i][1])
+		assert.Equal(t, expectedSamples[i], meta.Stats.NumSamples)
+		assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-1"}, meta.Thanos.Labels)
+		assert.Equal(t, backfillSource, meta.Thanos.Source)
+
+		require.Len(t, meta.Thanos.Files, 2)
+		assert.Equal(t, "chunks/000001", meta.Thanos.Files[0].RelPath)
+		assert.Equal(t, block.IndexFilename, meta.Thanos.Files[1].RelPath)
+	}
+}
+
+func TestCreateBlocks_OpenMetricsMissingTimestamp(t *testing.T) {
+	input := "http_requests_total{code=\"200\"} 1\n# EOF\n"
+
+	_, err := newOpenMetricsReader([]byte(input), time.Hour.Milliseconds())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "missing timestamp")
+}
+
+func TestCreateBlocks_TSDB(t *testing.T) {
+	inputDir, err := ioutil.TempDir(os.TempDir(), "input")
+	require.NoError(t, err)
+	defer os.RemoveAll(inputDir) //nolint:errcheck
+
+	outputDir, err := ioutil.TempDir(os.TempDir(), "backfill")
+	require.NoError(t, err)
+	defer os.RemoveAll(outputDir) //nolint:errcheck
+
+	// Build the input TSDB blocks with a small block duration, and then backfill them into a single block.
+	_, err = CreateBlocks(context.Background(), newTestOpenMetricsReader(t, openMetricsInput, time.Hour.Milliseconds()), inputDir, "user-1", time.Hour.Milliseconds(), log.NewNopLogger())
+	require.NoError(t, err)
+
+	r, err := newTSDBReader(inputDir, log.NewNopLogger())
+	require.NoError(t, err)
+	defer r.Close() //nolint:errcheck
+
+	ids, err := CreateBlocks(context.Background(), r, outputDir, "user-2", 24*time.Hour.Milliseconds(), log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+
+	meta, err := metadata.ReadFromDir(filepath.Join(outputDir, ids[0].String()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), meta.MinTime)
+	assert.Equal(t, int64(7201001), meta.MaxTime)
+	assert.Equal(t, uint64(4), meta.Stats.NumSamples)
+	assert.Equal(t, uint64(2), meta.Stats.NumSeries)
+	assert.Equal(t, map[string]string{cortex_tsdb.TenantIDExternalLabel: "user-2"}, meta.Thanos.Labels)
+}
+
+func TestBucketUploader(t *testing.T) {
+	blocksDir, storageDir := createTestBlocksAndStorage(t)
+	defer os.RemoveAll(blocksDir)  //nolint:errcheck
+	defer os.RemoveAll(storageDir) //nolint:errcheck
+
+	bkt, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+
+	ids := listBlocks(t, blocksDir)
+	u := newBucketUploader(bkt, "user-1", log.NewNopLogger())
+	for _, id := range ids {
+		require.NoError(t, u.Upload(context.Background(), filepath.Join(blocksDir, id.String())))
+	}
+
+	userBucket := bucket.NewUserBucketClient("user-1", bkt)
+	for _, id := range ids {
+		exists, err := userBucket.Exists(context.Background(), filepath.ToSlash(filepath.Join(id.String(), block.MetaFilename)))
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+}
+
+func TestAPIUploader(t *testing.T) {
+	blocksDir, err := ioutil.TempDir(os.TempDir(), "backfill")
+	require.NoError(t, err)
+	defer os.RemoveAll(blocksDir) //nolint:errcheck
+
+	ids, err := CreateBlocks(context.Background(), newTestOpenMetricsReader(t, openMetricsInput, 24*time.Hour.Milliseconds()), blocksDir, "user-1", 24*time.Hour.Milliseconds(), log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, ids, 1)
+
+	var (
+		mtx      sync.Mutex
+		requests []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mtx.Lock()
+		defer mtx.Unlock()
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "user-1", r.Header.Get(user.OrgIDHeaderName))
+
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, r.ContentLength, int64(len(body)))
+
+		target := r.URL.Path
+		if p := r.URL.Query().Get("path"); p != "" {
+			target += "?path=" + p
+		}
+		requests = append(requests, target)
+	}))
+	defer server.Close()
+
+	u := newAPIUploader(server.URL+"/", "user-1")
+	require.NoError(t, u.Upload(context.Background(), filepath.Join(blocksDir, ids[0].String())))
+
+	prefix := "/api/v1/upload/block/" + ids[0].String()
+	assert.Equal(t, []string{
+		prefix + "/start",
+		prefix + "/files?path=chunks/000001",
+		prefix + "/files?path=index",
+		prefix + "/finish",
+	}, requests)
+}
+
+func TestAPIUploader_ShouldReturnErrorOnFailedRequest(t *testing.T) {
+	blocksDir, err := ioutil.TempDir(os.TempDir(), "backfill")
+	require.NoError(t, err)
+	defer os.RemoveAll(blocksDir) //nolint:errcheck
+
+	ids, err := CreateBlocks(context.Background(), newTestOpenMetricsReader(t, openMetricsInput, 24*time.Hour.Milliseconds()), blocksDir, "user-1", 24*time.Hour.Milliseconds(), log.NewNopLogger())
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "block upload is disabled", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err = newAPIUploader(server.URL, "user-1").Upload(context.Background(), filepath.Join(blocksDir, ids[0].String()))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed with status 403: block upload is disabled")
+}
+
+func createTestBlocksAndStorage(t *testing.T) (blocksDir, storageDir string) {
+	blocksDir, err := ioutil.TempDir(os.TempDir(), "backfill")
+	require.NoError(t, err)
+
+	storageDir, err = ioutil.TempDir(os.TempDir(), "storage")
+	require.NoError(t, err)
+
+	_, err = CreateBlocks(context.Background(), newTestOpenMetricsReader(t, openMetricsInput, time.Hour.Milliseconds()), blocksDir, "user-1", time.Hour.Milliseconds(), log.NewNopLogger())
+	require.NoError(t, err)
+
+	return blocksDir, storageDir
+}
+
+func newTestOpenMetricsReader(t *testing.T, input string, blockDuration int64) *openMetricsReader {
+	r, err := newOpenMetricsReader([]byte(input), blockDuration)
+	require.NoError(t, err)
+	return r
+}
+
+func listBlocks(t *testing.T, dir string) []ulid.ULID {
+	entries, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+
+	var ids []ulid.ULID
+	for _, e := range entries {
+		if id, err := ulid.Parse(e.Name()); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,152 @@
+package backfill
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+
+	cortex_tsdb "github.com/cortexproject/cortex/pkg/storage/tsdb"
+)
+
+const (
+	// backfillSource is the source of the blocks built by the backfill tool.
+	backfillSource metadata.SourceType = "backfill"
+
+	// Number of samples appended to a block before committing them.
+	commitBatchSize = 5000
+)
+
+// samplesReader reads the samples to backfill.
+type samplesReader interface {
+	// TimeRange returns the min and max timestamp (inclusive) of the samples.
+	TimeRange() (mint, maxt int64, err error)
+
+	// ReadSamples calls fn for each sample whose timestamp is within [mint, maxt).
+	// The samples of each series are passed in timestamp order.
+	ReadSamples(mint, maxt int64, fn func(l labels.Labels, t int64, v float64) error) error
+}
+
+// CreateBlocks writes the samples read from r to blocks in outputDir, one block for each
+// blockDuration aligned time range. The blocks have the external labels of the blocks
+// shipped by ingesters for the tenant.
+func CreateBlocks(ctx context.Context, r samplesReader, outputDir, tenantID string, blockDuration int64, logger log.Logger) ([]ulid.ULID, error) {
+	mint, maxt, err := r.TimeRange()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []ulid.ULID
+
+	for start := mint - mint%blockDuration; start <= maxt; start += blockDuration {
+		id, err := createBlock(ctx, r, outputDir, tenantID, start, start+blockDuration, logger)
+		if err != nil {
+			return nil, errors.Wrapf(err, "create block for time range [%d, %d)", start, start+blockDuration)
+		}
+
+		// No block is created if there are no samples in the time range.
+		if id != (ulid.ULID{}) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func createBlock(ctx context.Context, r samplesReader, outputDir, tenantID string, mint, maxt int64, logger log.Logger) (_ ulid.ULID, returnErr error) {
+	w, err := tsdb.NewBlockWriter(logger, outputDir, maxt-mint)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "create block writer")
+	}
+	defer func() {
+		if err := w.Close(); err != nil && returnErr == nil {
+			returnErr = errors.Wrap(err, "close block writer")
+		}
+	}()
+
+	app := w.Appender(ctx)
+	samples := 0
+
+	err = r.ReadSamples(mint, maxt, func(l labels.Labels, t int64, v float64) error {
+		if _, err := app.Add(l, t, v); err != nil {
+			return errors.Wrapf(err, "add sample of series %s", l.String())
+		}
+
+		samples++
+		if samples%commitBatchSize == 0 {
+			if err := app.Commit(); err != nil {
+				return errors.Wrap(err, "commit samples")
+			}
+			app = w.Appender(ctx)
+		}
+		return nil
+	})
+	if err != nil {
+		_ = app.Rollback()
+		return ulid.ULID{}, err
+	}
+
+	if err := app.Commit(); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "commit samples")
+	}
+
+	if samples == 0 {
+		return ulid.ULID{}, nil
+	}
+
+	id, err := w.Flush(ctx)
+	if err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "flush block")
+	}
+
+	blockDir := filepath.Join(outputDir, id.String())
+	files, err := gatherBlockFiles(blockDir)
+	if err != nil {
+		return ulid.ULID{}, err
+	}
+
+	// Add the Cortex external labels, and the block files required by the blocks upload API.
+	if _, err := metadata.InjectThanos(logger, blockDir, metadata.Thanos{
+		Version: metadata.ThanosVersion1,
+		Labels:  map[string]string{cortex_tsdb.TenantIDExternalLabel: tenantID},
+		Source:  backfillSource,
+		Files:   files,
+	}, nil); err != nil {
+		return ulid.ULID{}, errors.Wrap(err, "inject block external labels")
+	}
+
+	level.Info(logger).Log("msg", "created block", "block", id.String(), "samples", samples)
+	return id, nil
+}
+
+// gatherBlockFiles returns the index and chunks files of the block in blockDir.
+func gatherBlockFiles(blockDir string) ([]metadata.File, error) {
+	var files []metadata.File
+
+	err := filepath.Walk(blockDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relPath, err := filepath.Rel(blockDir, path)
+		if err != nil {
+			return err
+		}
+
+		relPath = filepath.ToSlash(relPath)
+		if relPath == block.IndexFilename || filepath.ToSlash(filepath.Dir(relPath)) == block.ChunksDirname {
+			files = append(files, metadata.File{RelPath: relPath, SizeBytes: info.Size()})
+		}
+		return nil
+	})
+
+	return files, errors.Wrap(err, "gather block files")
+}
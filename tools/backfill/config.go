@@ -0,0 +1,88 @@
+package backfill
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+)
+
+const (
+	InputFormatOpenMetrics = "openmetrics"
+	InputFormatTSDB        = "tsdb"
+
+	OutputBucket    = "bucket"
+	OutputUploadAPI = "upload-api"
+)
+
+var (
+	supportedInputFormats = []string{InputFormatOpenMetrics, InputFormatTSDB}
+	supportedOutputs      = []string{OutputBucket, OutputUploadAPI}
+)
+
+// Config holds the backfill tool config.
+type Config struct {
+	InputFormat   string
+	InputPath     string
+	TenantID      string
+	BlockDuration time.Duration
+	WorkingDir    string
+
+	Output       string
+	UploadAPIURL string
+	Bucket       bucket.Config
+}
+
+// RegisterFlags registers the backfill tool flags.
+func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
+	f.StringVar(&cfg.InputFormat, "backfill.input-format", InputFormatOpenMetrics, "Format of the input data. Supported values are: openmetrics (a file in the OpenMetrics text format, with a timestamp for each sample) and tsdb (a directory containing Prometheus TSDB blocks, ie. a Prometheus snapshot).")
+	f.StringVar(&cfg.InputPath, "backfill.input-path", "", "Path of the input OpenMetrics file or TSDB directory.")
+	f.StringVar(&cfg.TenantID, "backfill.tenant-id", "", "Tenant to backfill the data to.")
+	f.DurationVar(&cfg.BlockDuration, "backfill.block-duration", 2*time.Hour, "Duration of the blocks to build. Blocks are aligned to the duration, which should be the smallest -compactor.block-ranges period.")
+	f.StringVar(&cfg.WorkingDir, "backfill.working-dir", os.TempDir(), "Directory where the blocks are built before being written to the output.")
+	f.StringVar(&cfg.Output, "backfill.output", OutputBucket, "Where to write the blocks. Supported values are: bucket (the blocks are uploaded to the bucket configured via the -blocks-storage.* flags) and upload-api (the blocks are uploaded via the compactor blocks upload API).")
+	f.StringVar(&cfg.UploadAPIURL, "backfill.upload-api-url", "", "URL of the Cortex blocks upload API, ie. http://compactor:8080. Required when the output is upload-api.")
+
+	cfg.Bucket.RegisterFlagsWithPrefix("blocks-storage.", f)
+}
+
+// Validate the config.
+func (cfg *Config) Validate() error {
+	if !contains(supportedInputFormats, cfg.InputFormat) {
+		return errors.Errorf("unsupported input format: %s", cfg.InputFormat)
+	}
+	if cfg.InputPath == "" {
+		return errors.New("no input path specified")
+	}
+	if cfg.TenantID == "" {
+		return errors.New("no tenant ID specified")
+	}
+	if cfg.BlockDuration < time.Minute || cfg.BlockDuration%time.Minute != 0 {
+		return errors.New("the block duration must be a multiple of 1 minute")
+	}
+	if !contains(supportedOutputs, cfg.Output) {
+		return errors.Errorf("unsupported output: %s", cfg.Output)
+	}
+	if cfg.Output == OutputUploadAPI && cfg.UploadAPIURL == "" {
+		return errors.New("no upload API URL specified")
+	}
+	if cfg.Output == OutputBucket {
+		if err := cfg.Bucket.Validate(); err != nil {
+			return errors.Wrap(err, "invalid bucket config")
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,217 @@
+package backfill
+
+import (
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/textparse"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	tsdb_errors "github.com/prometheus/prometheus/tsdb/errors"
+)
+
+// openMetricsReader reads the samples from OpenMetrics text content, where each sample must have a timestamp.
+// The content is parsed once, and the samples are bucketed by the block time range they belong to.
+type openMetricsReader struct {
+	blockDuration int64
+	series        []labels.Labels
+	buckets       map[int64][]openMetricsSample
+	mint, maxt    int64
+}
+
+// openMetricsSample is a sample of the series at the index ref of openMetricsReader.series.
+type openMetricsSample struct {
+	ref int
+	t   int64
+	v   float64
+}
+
+func newOpenMetricsReader(content []byte, blockDuration int64) (*openMetricsReader, error) {
+	r := &openMetricsReader{
+		blockDuration: blockDuration,
+		buckets:       map[int64][]openMetricsSample{},
+	}
+
+	// Series refs by the series text, so that the labels of each series are parsed and stored once.
+	refs := map[string]int{}
+	p := textparse.NewOpenMetricsParser(content)
+
+	for {
+		e, err := p.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "parse OpenMetrics input")
+		}
+		if e != textparse.EntrySeries {
+			continue
+		}
+
+		series, ts, v := p.Series()
+		ref, ok := refs[string(series)]
+		if !ok {
+			l := labels.Labels{}
+			p.Metric(&l)
+
+			ref = len(r.series)
+			refs[string(series)] = ref
+			r.series = append(r.series, l)
+		}
+
+		if ts == nil {
+			return nil, errors.Errorf("missing timestamp for the sample of series %s", r.series[ref].String())
+		}
+
+		t, first := *ts, len(r.buckets) == 0
+		if first || t < r.mint {
+			r.mint = t
+		}
+		if first || t > r.maxt {
+			r.maxt = t
+		}
+
+		start := r.blockStart(t)
+		r.buckets[start] = append(r.buckets[start], openMetricsSample{ref: ref, t: t, v: v})
+	}
+
+	return r, nil
+}
+
+// TimeRange implements samplesReader.
+func (r *openMetricsReader) TimeRange() (mint, maxt int64, _ error) {
+	if len(r.buckets) == 0 {
+		return 0, 0, errors.New("no samples found in the input")
+	}
+
+	return r.mint, r.maxt, nil
+}
+
+// ReadSamples implements samplesReader.
+func (r *openMetricsReader) ReadSamples(mint, maxt int64, fn func(l labels.Labels, t int64, v float64) error) error {
+	for start := r.blockStart(mint); start < maxt; start += r.blockDuration {
+		for _, s := range r.buckets[start] {
+			if s.t < mint || s.t >= maxt {
+				continue
+			}
+			if err := fn(r.series[s.ref], s.t, s.v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// blockStart returns the start of the block time range t belongs to, aligned like in CreateBlocks().
+func (r *openMetricsReader) blockStart(t int64) int64 {
+	return t - t%r.blockDuration
+}
+
+// tsdbReader reads the samples from the Prometheus TSDB blocks in a directory.
+type tsdbReader struct {
+	blocks []*tsdb.Block
+}
+
+func newTSDBReader(dir string, logger log.Logger) (*tsdbReader, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read TSDB directory")
+	}
+
+	r := &tsdbReader{}
+	for _, e := range entries {
+		if _, err := ulid.Parse(e.Name()); err != nil || !e.IsDir() {
+			continue
+		}
+
+		b, err := tsdb.OpenBlock(logger, filepath.Join(dir, e.Name()), nil)
+		if err != nil {
+			_ = r.Close()
+			return nil, errors.Wrapf(err, "open block %s", e.Name())
+		}
+		r.blocks = append(r.blocks, b)
+	}
+
+	if len(r.blocks) == 0 {
+		return nil, errors.Errorf("no blocks found in %s", dir)
+	}
+
+	return r, nil
+}
+
+// TimeRange implements samplesReader.
+func (r *tsdbReader) TimeRange() (mint, maxt int64, _ error) {
+	for i, b := range r.blocks {
+		// The block max time is exclusive.
+		if i == 0 || b.Meta().MinTime < mint {
+			mint = b.Meta().MinTime
+		}
+		if i == 0 || b.Meta().MaxTime-1 > maxt {
+			maxt = b.Meta().MaxTime - 1
+		}
+	}
+
+	return mint, maxt, nil
+}
+
+// ReadSamples implements samplesReader.
+func (r *tsdbReader) ReadSamples(mint, maxt int64, fn func(l labels.Labels, t int64, v float64) error) error {
+	var queriers []storage.Querier
+
+	for _, b := range r.blocks {
+		if !b.OverlapsClosedInterval(mint, maxt-1) {
+			continue
+		}
+
+		q, err := tsdb.NewBlockQuerier(b, mint, maxt-1)
+		if err != nil {
+			return errors.Wrapf(err, "query block %s", b.Meta().ULID.String())
+		}
+		queriers = append(queriers, q)
+	}
+
+	if len(queriers) == 0 {
+		return nil
+	}
+
+	// Merge the series of all blocks, so that the samples of each series are sorted even if blocks overlap.
+	q := storage.NewMergeQuerier(queriers, nil, storage.ChainedSeriesMerge)
+	defer q.Close()
+
+	set := q.Select(false, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+	for set.Next() {
+		series := set.At()
+
+		it := series.Iterator()
+		for it.Next() {
+			t, v := it.At()
+			if t < mint || t >= maxt {
+				continue
+			}
+
+			if err := fn(series.Labels(), t, v); err != nil {
+				return err
+			}
+		}
+		if err := it.Err(); err != nil {
+			return errors.Wrapf(err, "iterate samples of series %s", series.Labels().String())
+		}
+	}
+
+	return errors.Wrap(set.Err(), "iterate series")
+}
+
+// Close the TSDB blocks.
+func (r *tsdbReader) Close() error {
+	errs := tsdb_errors.NewMulti()
+	for _, b := range r.blocks {
+		errs.Add(b.Close())
+	}
+	return errs.Err()
+}
@@ -0,0 +1,130 @@
+package backfill
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-kit/kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/thanos/pkg/block"
+	"github.com/thanos-io/thanos/pkg/block/metadata"
+	"github.com/thanos-io/thanos/pkg/objstore"
+	"github.com/weaveworks/common/user"
+
+	"github.com/cortexproject/cortex/pkg/storage/bucket"
+)
+
+// blockUploader writes a block built locally to the output.
+type blockUploader interface {
+	Upload(ctx context.Context, blockDir string) error
+}
+
+// bucketUploader uploads the blocks straight to the tenant's location in the bucket.
+type bucketUploader struct {
+	bkt    objstore.Bucket
+	logger log.Logger
+}
+
+func newBucketUploader(bkt objstore.Bucket, tenantID string, logger log.Logger) *bucketUploader {
+	return &bucketUploader{
+		bkt:    bucket.NewUserBucketClient(tenantID, bkt),
+		logger: logger,
+	}
+}
+
+// Upload implements blockUploader.
+func (u *bucketUploader) Upload(ctx context.Context, blockDir string) error {
+	return block.Upload(ctx, u.logger, u.bkt, blockDir)
+}
+
+// apiUploader uploads the blocks via the compactor blocks upload API.
+type apiUploader struct {
+	baseURL  string
+	tenantID string
+	client   *http.Client
+}
+
+func newAPIUploader(baseURL, tenantID string) *apiUploader {
+	return &apiUploader{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		tenantID: tenantID,
+		client:   http.DefaultClient,
+	}
+}
+
+// Upload implements blockUploader.
+func (u *apiUploader) Upload(ctx context.Context, blockDir string) error {
+	meta, err := metadata.ReadFromDir(blockDir)
+	if err != nil {
+		return errors.Wrap(err, "read block meta")
+	}
+
+	metaContent, err := ioutil.ReadFile(filepath.Join(blockDir, block.MetaFilename))
+	if err != nil {
+		return errors.Wrap(err, "read block meta")
+	}
+
+	if err := u.post(ctx, meta.ULID, "start", nil, bytes.NewReader(metaContent), int64(len(metaContent))); err != nil {
+		return err
+	}
+
+	for _, f := range meta.Thanos.Files {
+		if err := u.uploadFile(ctx, meta.ULID, blockDir, f.RelPath); err != nil {
+			return err
+		}
+	}
+
+	return u.post(ctx, meta.ULID, "finish", nil, http.NoBody, 0)
+}
+
+// uploadFile streams the block file at relPath to the upload API, without reading it in memory.
+func (u *apiUploader) uploadFile(ctx context.Context, id ulid.ULID, blockDir, relPath string) error {
+	f, err := os.Open(filepath.Join(blockDir, filepath.FromSlash(relPath)))
+	if err != nil {
+		return errors.Wrapf(err, "open block file %s", relPath)
+	}
+	defer f.Close() //nolint:errcheck
+
+	info, err := f.Stat()
+	if err != nil {
+		return errors.Wrapf(err, "stat block file %s", relPath)
+	}
+
+	return u.post(ctx, id, "files", url.Values{"path": []string{relPath}}, f, info.Size())
+}
+
+func (u *apiUploader) post(ctx context.Context, id ulid.ULID, action string, params url.Values, body io.Reader, size int64) error {
+	target := fmt.Sprintf("%s/api/v1/upload/block/%s/%s", u.baseURL, id.String(), action)
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, body)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set(user.OrgIDHeaderName, u.tenantID)
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "%s upload of block %s", action, id.String())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("%s upload of block %s failed with status %d: %s", action, id.String(), resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	return nil
+}
@@ -253,8 +253,22 @@ func getFieldType(t reflect.Type) (string, error) {
 		return "string", nil
 	case "flagext.StringSliceCSV":
 		return "string", nil
+	case "flagext.CIDRSliceCSV":
+		return "string", nil
 	case "[]*relabel.Config":
 		return "relabel_config...", nil
+	case "[]*validation.BlockedQuery":
+		return "list of blocked_query", nil
+	case "[]*validation.QueryPriority":
+		return "list of query_priority", nil
+	case "[]*validation.SplitQueriesInterval":
+		return "list of split_queries_interval", nil
+	case "[]*validation.LimitsPerLabelSet":
+		return "list of limits_per_label_set", nil
+	case "validation.ActiveSeriesCustomTrackersConfig":
+		return "list of active_series_custom_tracker", nil
+	case "[]*federation.ClusterConfig":
+		return "list of federated_cluster", nil
 	}
 
 	// Fallback to auto-detection of built-in data types